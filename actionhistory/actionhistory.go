@@ -0,0 +1,356 @@
+// Package actionhistory keeps the output of recent service actions. Actions
+// stream their progress over SSE, which is lost if nobody is listening (a
+// closed tab, or a restart nobody watched), so every event is also captured
+// here in a small per-service ring that can be read back later.
+package actionhistory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"home_server_dashboard/services"
+)
+
+// Defaults.
+const (
+	// DefaultPerService is how many actions are kept for each service.
+	DefaultPerService = 5
+	// DefaultMaxOutput is how many bytes of output are kept for each action.
+	DefaultMaxOutput = 64 << 10
+)
+
+// Outcomes of an action.
+const (
+	OutcomeRunning     = "running"
+	OutcomeSuccess     = "success"
+	OutcomeFailed      = "failed"
+	OutcomeInterrupted = "interrupted" // the action ended without reporting an outcome
+)
+
+// Line is one event streamed by an action.
+type Line struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"` // SSE event type, e.g. "status", "error"
+	Message string    `json:"message"`
+}
+
+// Record is one executed action and its captured output.
+type Record struct {
+	ID          string    `json:"id"`
+	Host        string    `json:"host"`
+	Service     string    `json:"service"`
+	Source      string    `json:"source"`
+	Action      string    `json:"action"`
+	User        string    `json:"user,omitempty"`
+	ContainerID string    `json:"container_id,omitempty"` // container acted on, for Docker actions whose container could be looked up
+	Started     time.Time `json:"started"`
+	Finished    time.Time `json:"finished,omitempty"`
+	DurationMs  int64     `json:"duration_ms"`
+	Outcome     string    `json:"outcome"`
+	Truncated   bool      `json:"truncated,omitempty"` // earlier output was dropped to stay within the size cap
+	Lines       []Line    `json:"lines,omitempty"`
+
+	size int // bytes of message text in Lines
+}
+
+// serviceKey returns the key the actions of a service are kept under.
+func serviceKey(host, service string) services.Key {
+	return services.Key{Host: host, Name: service}
+}
+
+// Store holds the recent actions of every service. It is safe for concurrent use.
+type Store struct {
+	mu         sync.Mutex
+	perService int
+	maxOutput  int
+	records    map[services.Key][]*Record // key: host and service, oldest first
+	nextID     int
+	path       string // file the store is saved to ("" keeps it in memory only)
+	now        func() time.Time
+	onFinish   func(Record)
+}
+
+// NewStore creates an in-memory store keeping perService actions per service
+// and up to maxOutput bytes of output per action.
+func NewStore(perService, maxOutput int) *Store {
+	if perService <= 0 {
+		perService = DefaultPerService
+	}
+	if maxOutput <= 0 {
+		maxOutput = DefaultMaxOutput
+	}
+	return &Store{
+		perService: perService,
+		maxOutput:  maxOutput,
+		records:    make(map[services.Key][]*Record),
+		now:        time.Now,
+	}
+}
+
+// Open creates a store that is saved to path after every action. Records
+// already in the file are loaded; a missing file starts an empty history.
+func Open(path string, perService, maxOutput int) (*Store, error) {
+	s := NewStore(perService, maxOutput)
+	s.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read action history: %w", err)
+	}
+
+	var records []*Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse action history %s: %w", path, err)
+	}
+	for _, rec := range records {
+		if rec.Outcome == OutcomeRunning {
+			// The dashboard stopped while it ran
+			rec.Outcome = OutcomeInterrupted
+		}
+		for _, line := range rec.Lines {
+			rec.size += len(line.Message)
+		}
+		key := serviceKey(rec.Host, rec.Service)
+		s.records[key] = append(s.records[key], rec)
+		if id, err := strconv.Atoi(rec.ID); err == nil && id > s.nextID {
+			s.nextID = id
+		}
+	}
+	for key, recs := range s.records {
+		sort.Slice(recs, func(i, j int) bool { return recs[i].Started.Before(recs[j].Started) })
+		if len(recs) > s.perService {
+			s.records[key] = recs[len(recs)-s.perService:]
+		}
+	}
+	return s, nil
+}
+
+// OnFinish sets a function told about every action that finishes, with the
+// record of the action without its output. It is called with the store
+// locked, so it must be quick and must not call back into the store.
+func (s *Store) OnFinish(fn func(Record)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onFinish = fn
+}
+
+// Begin records the start of an action and returns a Recorder for its output.
+// The oldest action of the service is dropped once it has perService actions.
+func (s *Store) Begin(host, service, source, action, user string) *Recorder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	rec := &Record{
+		ID:      strconv.Itoa(s.nextID),
+		Host:    host,
+		Service: service,
+		Source:  source,
+		Action:  action,
+		User:    user,
+		Started: s.now(),
+		Outcome: OutcomeRunning,
+	}
+	key := serviceKey(host, service)
+	recs := append(s.records[key], rec)
+	if len(recs) > s.perService {
+		recs = recs[len(recs)-s.perService:]
+	}
+	s.records[key] = recs
+	return &Recorder{store: s, rec: rec}
+}
+
+// List returns the recorded actions of a service, newest first, without their output.
+func (s *Store) List(host, service string) []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recs := s.records[serviceKey(host, service)]
+	list := make([]Record, 0, len(recs))
+	for i := len(recs) - 1; i >= 0; i-- {
+		rec := *recs[i]
+		rec.Lines = nil
+		list = append(list, rec)
+	}
+	return list
+}
+
+// Last returns the most recent action of a service without its output, or
+// false if none was recorded.
+func (s *Store) Last(host, service string) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recs := s.records[serviceKey(host, service)]
+	if len(recs) == 0 {
+		return Record{}, false
+	}
+	rec := *recs[len(recs)-1]
+	rec.Lines = nil
+	return rec, true
+}
+
+// Get returns a recorded action of a service with its output.
+func (s *Store) Get(host, service, id string) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, rec := range s.records[serviceKey(host, service)] {
+		if rec.ID == id {
+			copied := *rec
+			copied.Lines = append([]Line(nil), rec.Lines...)
+			return copied, true
+		}
+	}
+	return Record{}, false
+}
+
+// Forget drops the recorded actions of a service, e.g. once it was removed,
+// so a new service reusing its name starts with an empty history.
+func (s *Store) Forget(host, service string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := serviceKey(host, service)
+	if _, ok := s.records[key]; !ok {
+		return
+	}
+	delete(s.records, key)
+	s.save()
+}
+
+// save writes the store to its file, if it has one. Called with s.mu held.
+func (s *Store) save() {
+	if s.path == "" {
+		return
+	}
+
+	var all []*Record
+	for _, recs := range s.records {
+		all = append(all, recs...)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Started.Before(all[j].Started) })
+
+	data, err := json.Marshal(all)
+	if err != nil {
+		log.Printf("Warning: failed to encode action history: %v", err)
+		return
+	}
+	// Write to a temporary file first so a crash can't leave a truncated history
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".action-history-*")
+	if err != nil {
+		log.Printf("Warning: failed to save action history: %v", err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("Warning: failed to save action history: %v", err)
+	}
+}
+
+// Recorder captures the output of one action.
+type Recorder struct {
+	store *Store
+	rec   *Record
+}
+
+// ID returns the ID of the action being recorded.
+func (r *Recorder) ID() string {
+	return r.rec.ID
+}
+
+// Wrap returns a sendEvent function that records every event before passing
+// it on to send. A "complete" event finishes the record with its data
+// ("success" or "failed") as the outcome.
+func (r *Recorder) Wrap(send func(eventType, message string)) func(eventType, message string) {
+	return func(eventType, message string) {
+		r.add(eventType, message)
+		if eventType == "complete" {
+			outcome := OutcomeFailed
+			if message == "success" {
+				outcome = OutcomeSuccess
+			}
+			r.finish(outcome)
+		}
+		send(eventType, message)
+	}
+}
+
+// SetContainerID records the ID of the container the action is on.
+func (r *Recorder) SetContainerID(id string) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.rec.ContainerID = id
+}
+
+// Close finishes the record as interrupted if the action never reported an
+// outcome. It is meant to be deferred by whoever began the record.
+func (r *Recorder) Close() {
+	r.finish(OutcomeInterrupted)
+}
+
+// add appends a line, dropping the oldest lines once the output exceeds the cap.
+// The end of the output is kept since that is where failures are reported.
+func (r *Recorder) add(eventType, message string) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.rec.Outcome != OutcomeRunning {
+		return
+	}
+	if len(message) > s.maxOutput {
+		message = message[len(message)-s.maxOutput:]
+		r.rec.Truncated = true
+	}
+	r.rec.Lines = append(r.rec.Lines, Line{Time: s.now(), Event: eventType, Message: message})
+	r.rec.size += len(message)
+
+	drop := 0
+	for r.rec.size > s.maxOutput {
+		r.rec.size -= len(r.rec.Lines[drop].Message)
+		drop++
+	}
+	if drop > 0 {
+		r.rec.Lines = append([]Line(nil), r.rec.Lines[drop:]...)
+		r.rec.Truncated = true
+	}
+}
+
+// finish sets the outcome of a running record and saves the store.
+func (r *Recorder) finish(outcome string) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.rec.Outcome != OutcomeRunning {
+		return
+	}
+	r.rec.Outcome = outcome
+	r.rec.Finished = s.now()
+	r.rec.DurationMs = r.rec.Finished.Sub(r.rec.Started).Milliseconds()
+	s.save()
+	if s.onFinish != nil {
+		rec := *r.rec
+		rec.Lines = nil
+		s.onFinish(rec)
+	}
+}
@@ -0,0 +1,244 @@
+package actionhistory
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// discard is a sendEvent that drops events.
+func discard(eventType, message string) {}
+
+func TestRecorder_CapturesEvents(t *testing.T) {
+	s := NewStore(5, 1024)
+	start := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return start }
+
+	var forwarded []string
+	rec := s.Begin("nas", "jellyfin", "docker", "restart", "alice")
+	send := rec.Wrap(func(eventType, message string) {
+		forwarded = append(forwarded, eventType+": "+message)
+	})
+
+	send("status", "Starting restart action on jellyfin...")
+	s.now = func() time.Time { return start.Add(1500 * time.Millisecond) }
+	send("error", "compose up failed")
+	send("complete", "failed")
+	rec.Close()
+
+	if len(forwarded) != 3 {
+		t.Errorf("forwarded %d events, want 3", len(forwarded))
+	}
+
+	list := s.List("nas", "jellyfin")
+	if len(list) != 1 {
+		t.Fatalf("List() returned %d records, want 1", len(list))
+	}
+	got := list[0]
+	if got.Outcome != OutcomeFailed || got.DurationMs != 1500 || got.User != "alice" || got.Action != "restart" {
+		t.Errorf("record = %+v", got)
+	}
+	if got.Lines != nil {
+		t.Error("List() should not include output")
+	}
+
+	full, ok := s.Get("nas", "jellyfin", rec.ID())
+	if !ok {
+		t.Fatal("Get() did not find the record")
+	}
+	if len(full.Lines) != 3 || full.Lines[1].Event != "error" || full.Lines[1].Message != "compose up failed" {
+		t.Errorf("lines = %+v", full.Lines)
+	}
+	if !full.Lines[1].Time.Equal(start.Add(1500 * time.Millisecond)) {
+		t.Errorf("line time = %v", full.Lines[1].Time)
+	}
+}
+
+func TestRecorder_CloseWithoutOutcome(t *testing.T) {
+	s := NewStore(5, 1024)
+	rec := s.Begin("nas", "jellyfin", "docker", "stop", "")
+	rec.Wrap(discard)("status", "Stopping...")
+	rec.Close()
+
+	if got := s.List("nas", "jellyfin")[0].Outcome; got != OutcomeInterrupted {
+		t.Errorf("Outcome = %q, want %q", got, OutcomeInterrupted)
+	}
+}
+
+func TestStore_RingEviction(t *testing.T) {
+	s := NewStore(3, 1024)
+	var ids []string
+	for i := 0; i < 5; i++ {
+		rec := s.Begin("nas", "jellyfin", "docker", "restart", "")
+		rec.Wrap(discard)("complete", "success")
+		ids = append(ids, rec.ID())
+	}
+	// Another service has its own ring
+	s.Begin("nas", "sonarr", "docker", "stop", "").Close()
+
+	list := s.List("nas", "jellyfin")
+	if len(list) != 3 {
+		t.Fatalf("List() returned %d records, want 3", len(list))
+	}
+	// Newest first
+	for i, want := range []string{ids[4], ids[3], ids[2]} {
+		if list[i].ID != want {
+			t.Errorf("list[%d].ID = %s, want %s", i, list[i].ID, want)
+		}
+	}
+	if _, ok := s.Get("nas", "jellyfin", ids[0]); ok {
+		t.Error("evicted record is still returned")
+	}
+	if len(s.List("nas", "sonarr")) != 1 {
+		t.Error("other service's history was affected")
+	}
+}
+
+func TestRecorder_OutputCap(t *testing.T) {
+	s := NewStore(5, 100)
+	rec := s.Begin("nas", "jellyfin", "docker", "restart", "")
+	send := rec.Wrap(discard)
+
+	for i := 0; i < 20; i++ {
+		send("status", strings.Repeat("x", 9)+string(rune('a'+i)))
+	}
+	send("error", "the failure at the end")
+	send("complete", "failed")
+
+	full, _ := s.Get("nas", "jellyfin", rec.ID())
+	if !full.Truncated {
+		t.Error("Truncated = false, want true")
+	}
+	size := 0
+	for _, line := range full.Lines {
+		size += len(line.Message)
+	}
+	if size > 100 {
+		t.Errorf("kept %d bytes, want at most 100", size)
+	}
+	// The end of the output is kept
+	if last := full.Lines[len(full.Lines)-1]; last.Event != "complete" {
+		t.Errorf("last line = %+v, want the complete event", last)
+	}
+
+	// A single oversized line keeps its tail
+	rec = s.Begin("nas", "jellyfin", "docker", "restart", "")
+	rec.Wrap(discard)("status", strings.Repeat("a", 150)+"END")
+	full, _ = s.Get("nas", "jellyfin", rec.ID())
+	if len(full.Lines) != 1 || len(full.Lines[0].Message) != 100 || !strings.HasSuffix(full.Lines[0].Message, "END") {
+		t.Errorf("oversized line = %d bytes", len(full.Lines[0].Message))
+	}
+}
+
+func TestOpen_PersistsAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+
+	s, err := Open(path, 5, 1024)
+	if err != nil {
+		t.Fatalf("Open() on a missing file = %v", err)
+	}
+	done := s.Begin("nas", "jellyfin", "docker", "restart", "alice")
+	done.Wrap(discard)("complete", "success")
+	running := s.Begin("nas", "nas-dashboard.service", "systemd", "restart", "alice")
+	running.Wrap(discard)("status", "Restarting...")
+	// The dashboard goes down before this action reports an outcome; another
+	// finished action saves the store with it still running
+	other := s.Begin("nas", "sonarr", "docker", "stop", "")
+	other.Wrap(discard)("complete", "success")
+
+	reopened, err := Open(path, 5, 1024)
+	if err != nil {
+		t.Fatalf("Open() = %v", err)
+	}
+	if got := reopened.List("nas", "jellyfin"); len(got) != 1 || got[0].Outcome != OutcomeSuccess {
+		t.Errorf("jellyfin history = %+v", got)
+	}
+	interrupted, ok := reopened.Get("nas", "nas-dashboard.service", running.ID())
+	if !ok || interrupted.Outcome != OutcomeInterrupted || len(interrupted.Lines) != 1 {
+		t.Errorf("interrupted record = %+v", interrupted)
+	}
+
+	// IDs continue after the loaded records
+	if rec := reopened.Begin("nas", "jellyfin", "docker", "stop", ""); rec.ID() != "4" {
+		t.Errorf("next ID = %s, want 4", rec.ID())
+	}
+}
+
+func TestStore_Forget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	s, err := Open(path, 5, 1024)
+	if err != nil {
+		t.Fatalf("Open() = %v", err)
+	}
+	s.Begin("nas", "redis", "docker", "restart", "").Wrap(discard)("complete", "success")
+	s.Begin("nas", "jellyfin", "docker", "stop", "").Wrap(discard)("complete", "success")
+
+	s.Forget("nas", "redis")
+	s.Forget("nas", "missing")
+	if got := s.List("nas", "redis"); len(got) != 0 {
+		t.Errorf("forgotten history = %+v", got)
+	}
+
+	reopened, err := Open(path, 5, 1024)
+	if err != nil {
+		t.Fatalf("Open() = %v", err)
+	}
+	if len(reopened.List("nas", "redis")) != 0 || len(reopened.List("nas", "jellyfin")) != 1 {
+		t.Errorf("saved history: redis %+v, jellyfin %+v", reopened.List("nas", "redis"), reopened.List("nas", "jellyfin"))
+	}
+}
+
+func TestStore_Last(t *testing.T) {
+	s := NewStore(5, 1024)
+	if _, ok := s.Last("nas", "redis"); ok {
+		t.Error("Last() found an action for a service without history")
+	}
+
+	s.Begin("nas", "redis", "docker", "stop", "alice").Wrap(discard)("complete", "success")
+	s.Begin("nas", "redis", "docker", "start", "bob").Wrap(discard)("status", "Starting...")
+
+	rec, ok := s.Last("nas", "redis")
+	if !ok || rec.Action != "start" || rec.User != "bob" || rec.Outcome != OutcomeRunning {
+		t.Errorf("Last() = %+v, %v, want bob's running start", rec, ok)
+	}
+	if rec.Lines != nil {
+		t.Errorf("Last() included output: %+v", rec.Lines)
+	}
+}
+
+func TestOpen_CorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path, 5, 1024); err == nil {
+		t.Error("Open() on a corrupt file = nil, want error")
+	}
+}
+
+func TestStore_OnFinish(t *testing.T) {
+	s := NewStore(5, 1024)
+	var finished []Record
+	s.OnFinish(func(rec Record) { finished = append(finished, rec) })
+
+	rec := s.Begin("nas", "jellyfin", "docker", "restart", "alice")
+	send := rec.Wrap(func(string, string) {})
+	send("status", "Restarting...")
+	send("complete", "success")
+	rec.Close()
+
+	// Closing after the outcome doesn't finish it again
+	if len(finished) != 1 {
+		t.Fatalf("OnFinish called %d times, want once", len(finished))
+	}
+	if got := finished[0]; got.Outcome != OutcomeSuccess || got.Action != "restart" || got.Lines != nil {
+		t.Errorf("finished = %+v, want the successful restart without output", got)
+	}
+
+	s.Begin("nas", "jellyfin", "docker", "stop", "alice").Close()
+	if len(finished) != 2 || finished[1].Outcome != OutcomeInterrupted {
+		t.Errorf("finished = %+v, want the interrupted stop too", finished)
+	}
+}
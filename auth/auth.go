@@ -6,19 +6,23 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	osuser "os/user"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
-	"github.com/msteinert/pam/v2"
 	"golang.org/x/oauth2"
 
 	"home_server_dashboard/config"
+	"home_server_dashboard/realip"
 )
 
 // ContextKey is a type for context keys used by the auth package.
@@ -53,7 +57,11 @@ type User struct {
 	IsAdmin         bool                `json:"is_admin"`
 	HasGlobalAccess bool                `json:"has_global_access"`
 	AllowedServices map[string][]string `json:"allowed_services,omitempty"` // host -> []service names
-	Expiry          time.Time           `json:"-"`
+	// ServicePermissions limits what the user may do with some of their
+	// allowed services: host -> service -> permissions (config.PermissionView,
+	// ...). Allowed services missing from it have every permission.
+	ServicePermissions map[string]map[string][]string `json:"service_permissions,omitempty"`
+	Expiry             time.Time                      `json:"-"`
 }
 
 // CanAccessService checks if the user can access a specific service on a host.
@@ -77,6 +85,74 @@ func (u *User) CanAccessService(host, serviceName string) bool {
 	return false
 }
 
+// CanViewLogs checks if the user can open the logs of a service on a host.
+func (u *User) CanViewLogs(host, serviceName string) bool {
+	return u.hasPermission(host, serviceName, config.PermissionLogs)
+}
+
+// CanControlService checks if the user can start, stop and restart a service
+// on a host.
+func (u *User) CanControlService(host, serviceName string) bool {
+	return u.hasPermission(host, serviceName, config.PermissionControl)
+}
+
+// hasPermission checks if the user has permission on a service they can access.
+func (u *User) hasPermission(host, serviceName, permission string) bool {
+	if !u.CanAccessService(host, serviceName) {
+		return false
+	}
+	if u.HasGlobalAccess {
+		return true
+	}
+	permissions, limited := u.ServicePermissions[host][serviceName]
+	return !limited || slices.Contains(permissions, permission)
+}
+
+// CanAccessHost returns true if the user has global access or at least one
+// allowed service on the host.
+func (u *User) CanAccessHost(host string) bool {
+	if u.HasGlobalAccess {
+		return true
+	}
+	return len(u.AllowedServices[host]) > 0
+}
+
+// InAnyGroup returns true if the user is a member of at least one of groups.
+func (u *User) InAnyGroup(groups []string) bool {
+	for _, group := range groups {
+		for _, userGroup := range u.Groups {
+			if userGroup == group {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AccessSummary describes what a user can see, so the UI can explain scoped access.
+type AccessSummary struct {
+	Global       bool     `json:"global"`          // true if the user can see every service
+	Hosts        []string `json:"hosts,omitempty"` // hosts with at least one allowed service (sorted)
+	ServiceCount int      `json:"service_count"`   // number of allowed services across all hosts
+}
+
+// Access summarizes the user's service access.
+func (u *User) Access() AccessSummary {
+	summary := AccessSummary{Global: u.HasGlobalAccess}
+	if u.HasGlobalAccess {
+		return summary
+	}
+	for host, services := range u.AllowedServices {
+		if len(services) == 0 {
+			continue
+		}
+		summary.Hosts = append(summary.Hosts, host)
+		summary.ServiceCount += len(services)
+	}
+	sort.Strings(summary.Hosts)
+	return summary
+}
+
 // HasAnyAccess returns true if the user has global access or at least one allowed service.
 func (u *User) HasAnyAccess() bool {
 	if u.HasGlobalAccess {
@@ -96,130 +172,106 @@ type Session struct {
 	ExpiresAt time.Time
 }
 
-// SessionStore manages user sessions in memory.
+// SessionStore manages user sessions in memory. It holds at most the
+// number of sessions it was created with, logging the oldest out first.
 type SessionStore struct {
-	sessions map[string]*Session
-	mu       sync.RWMutex
+	store *expiringStore[*Session]
 }
 
-// NewSessionStore creates a new session store.
-func NewSessionStore() *SessionStore {
-	store := &SessionStore{
-		sessions: make(map[string]*Session),
-	}
-	// Start cleanup goroutine
-	go store.cleanupExpired()
-	return store
+// NewSessionStore creates a new session store holding up to maxSessions
+// sessions, or any number if maxSessions is 0. Close stops its cleanup.
+func NewSessionStore(maxSessions int) *SessionStore {
+	return &SessionStore{store: newExpiringStore[*Session](maxSessions, 5*time.Minute)}
 }
 
 // Set stores a session.
 func (s *SessionStore) Set(id string, session *Session) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.sessions[id] = session
+	s.store.set(id, session, session.ExpiresAt)
 }
 
 // Get retrieves a session by ID.
 func (s *SessionStore) Get(id string) (*Session, bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	session, ok := s.sessions[id]
-	if !ok {
-		return nil, false
-	}
-	if time.Now().After(session.ExpiresAt) {
-		return nil, false
-	}
-	return session, true
+	return s.store.get(id)
 }
 
 // Delete removes a session.
 func (s *SessionStore) Delete(id string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	delete(s.sessions, id)
-}
-
-// cleanupExpired periodically removes expired sessions.
-func (s *SessionStore) cleanupExpired() {
-	ticker := time.NewTicker(5 * time.Minute)
-	for range ticker.C {
-		s.mu.Lock()
-		now := time.Now()
-		for id, session := range s.sessions {
-			if now.After(session.ExpiresAt) {
-				delete(s.sessions, id)
-			}
-		}
-		s.mu.Unlock()
-	}
+	s.store.delete(id)
 }
 
-// StateStore manages OIDC state tokens.
+// Close stops removing expired sessions.
+func (s *SessionStore) Close() {
+	s.store.Close()
+}
+
+// StateStore manages OIDC state tokens. It holds at most the number of
+// states it was created with, forgetting the oldest logins first.
 type StateStore struct {
-	states map[string]time.Time
-	mu     sync.RWMutex
+	store *expiringStore[struct{}]
 }
 
-// NewStateStore creates a new state store.
-func NewStateStore() *StateStore {
-	store := &StateStore{
-		states: make(map[string]time.Time),
-	}
-	go store.cleanupExpired()
-	return store
+// NewStateStore creates a new state store holding up to maxStates states,
+// or any number if maxStates is 0. Close stops its cleanup.
+func NewStateStore(maxStates int) *StateStore {
+	return &StateStore{store: newExpiringStore[struct{}](maxStates, time.Minute)}
 }
 
 // Set stores a state token.
 func (s *StateStore) Set(state string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.states[state] = time.Now().Add(StateExpiry)
+	s.store.set(state, struct{}{}, time.Now().Add(StateExpiry))
 }
 
 // Validate checks if a state token is valid and removes it.
 func (s *StateStore) Validate(state string) bool {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	expiry, ok := s.states[state]
-	if !ok {
-		return false
-	}
-	delete(s.states, state)
-	return time.Now().Before(expiry)
+	_, ok := s.store.take(state)
+	return ok
 }
 
-// cleanupExpired periodically removes expired states.
-func (s *StateStore) cleanupExpired() {
-	ticker := time.NewTicker(1 * time.Minute)
-	for range ticker.C {
-		s.mu.Lock()
-		now := time.Now()
-		for state, expiry := range s.states {
-			if now.After(expiry) {
-				delete(s.states, state)
-			}
-		}
-		s.mu.Unlock()
-	}
+// Close stops removing expired states.
+func (s *StateStore) Close() {
+	s.store.Close()
+}
+
+// StoreStats are the sizes of a provider's in-memory stores and how many
+// entries were dropped from each to stay within its limit.
+type StoreStats struct {
+	Sessions         int    `json:"sessions"`
+	MaxSessions      int    `json:"max_sessions"`
+	SessionEvictions uint64 `json:"session_evictions"`
+	States           int    `json:"login_states"`
+	MaxStates        int    `json:"max_login_states"`
+	StateEvictions   uint64 `json:"login_state_evictions"`
 }
 
 // Provider handles OIDC authentication.
 type Provider struct {
-	config         *config.OIDCConfig
-	localConfig    *config.LocalConfig
-	oauth2Config   *oauth2.Config
-	verifier       *oidc.IDTokenVerifier
-	sessions       *SessionStore
-	states         *StateStore
-	groupsClaim    string                           // claim name where groups are found
-	adminGroup     string                           // group name that grants admin access
-	serviceURLHost string                           // hostname from service_url for Host header comparison
-	localAdmins    map[string]bool                  // parsed local admin usernames
-	groupConfigs   map[string]*config.OIDCGroupConfig // parsed group configurations
-}
-
-// NewProvider creates a new OIDC provider.
+	config          *config.OIDCConfig
+	localConfig     *config.LocalConfig
+	oauth2Config    *oauth2.Config
+	verifier        *oidc.IDTokenVerifier
+	sessions        *SessionStore
+	states          *StateStore
+	groupsClaim     string                                  // claim name where groups are found
+	adminGroup      string                                  // group name that grants admin access
+	serviceURLHost  string                                  // hostname from service_url for Host header comparison
+	localAdmins     map[string]bool                         // parsed local admin usernames
+	localAdminGroup string                                  // system group whose members are local admins
+	userGroups      func(username string) ([]string, error) // looks up a local user's group names
+	usersFile       *UsersFile                              // dashboard-only local users, checked before PAM
+	loginFailures   *loginLimiter                           // failed local logins per client
+	groupConfigs    map[string]*config.OIDCGroupConfig      // parsed group configurations
+	httpClient      *http.Client                            // client requests to the OIDC provider are made with, nil for the default
+}
+
+// WithHTTPClient returns a copy of ctx that makes NewProvider connect to the
+// OIDC provider with client: for discovery, its signing keys and token
+// exchanges.
+func WithHTTPClient(ctx context.Context, client *http.Client) context.Context {
+	return oidc.ClientContext(ctx, client)
+}
+
+// NewProvider creates a new OIDC provider. Requests to the OIDC provider use
+// the client set on ctx with WithHTTPClient, if any.
 func NewProvider(ctx context.Context, cfg *config.OIDCConfig, localCfg *config.LocalConfig) (*Provider, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("OIDC config is nil")
@@ -239,9 +291,32 @@ func NewProvider(ctx context.Context, cfg *config.OIDCConfig, localCfg *config.L
 		}
 	}
 
+	// Check the local admin group exists so a typo shows up at startup rather than as failed logins
+	var localAdminGroup string
+	if localCfg != nil {
+		localAdminGroup = strings.TrimSpace(localCfg.AdminGroup)
+	}
+	if localAdminGroup != "" {
+		if _, err := osuser.LookupGroup(localAdminGroup); err != nil {
+			log.Printf("Warning: local admin_group %q not found on this system: %v", localAdminGroup, err)
+		}
+	}
+	if !PAMAvailable && (len(localAdmins) > 0 || localAdminGroup != "") {
+		log.Printf("Warning: local admins are configured but this build has no PAM support; only users of the local users file can log in")
+	}
+
+	var usersFile *UsersFile
+	if localCfg != nil && localCfg.UsersFile != "" {
+		var err error
+		if usersFile, err = LoadUsersFile(localCfg.UsersFile); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUsersFile, err)
+		}
+	}
+
 	// Discover OIDC provider using the exact config_url provided.
 	// We fetch the discovery document manually to respect the user's config_url exactly.
-	discoveryDoc, err := fetchDiscoveryDocument(ctx, cfg.ConfigURL)
+	httpClient, _ := ctx.Value(oauth2.HTTPClient).(*http.Client)
+	discoveryDoc, err := fetchDiscoveryDocument(ctx, httpClient, cfg.ConfigURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch OIDC discovery document at %s: %w", cfg.ConfigURL, err)
 	}
@@ -280,20 +355,41 @@ func NewProvider(ctx context.Context, cfg *config.OIDCConfig, localCfg *config.L
 	}
 
 	return &Provider{
-		config:         cfg,
-		localConfig:    localCfg,
-		oauth2Config:   oauth2Config,
-		verifier:       verifier,
-		sessions:       NewSessionStore(),
-		states:         NewStateStore(),
-		groupsClaim:    groupsClaim,
-		adminGroup:     adminGroup,
-		serviceURLHost: serviceURLHost,
-		localAdmins:    localAdmins,
-		groupConfigs:   cfg.Groups,
+		config:          cfg,
+		localConfig:     localCfg,
+		oauth2Config:    oauth2Config,
+		verifier:        verifier,
+		sessions:        NewSessionStore(cfg.GetMaxSessions()),
+		states:          NewStateStore(cfg.GetMaxLoginStates()),
+		groupsClaim:     groupsClaim,
+		adminGroup:      adminGroup,
+		serviceURLHost:  serviceURLHost,
+		localAdmins:     localAdmins,
+		localAdminGroup: localAdminGroup,
+		userGroups:      lookupUserGroups,
+		usersFile:       usersFile,
+		loginFailures:   newLoginLimiter(),
+		groupConfigs:    cfg.Groups,
+		httpClient:      httpClient,
 	}, nil
 }
 
+// StoreStats returns the sizes and evictions of the session and login
+// state stores.
+func (p *Provider) StoreStats() StoreStats {
+	stats := StoreStats{MaxSessions: p.sessions.store.max, MaxStates: p.states.store.max}
+	stats.Sessions, stats.SessionEvictions = p.sessions.store.stats()
+	stats.States, stats.StateEvictions = p.states.store.stats()
+	return stats
+}
+
+// Close stops the cleanup of the provider's stores. The provider must not be
+// used afterwards.
+func (p *Provider) Close() {
+	p.sessions.Close()
+	p.states.Close()
+}
+
 // discoveryDocument represents the OIDC discovery document.
 type discoveryDocument struct {
 	Issuer                string `json:"issuer"`
@@ -303,14 +399,19 @@ type discoveryDocument struct {
 	JwksURI               string `json:"jwks_uri"`
 }
 
-// fetchDiscoveryDocument fetches and parses the OIDC discovery document from the given URL.
-func fetchDiscoveryDocument(ctx context.Context, configURL string) (*discoveryDocument, error) {
+// fetchDiscoveryDocument fetches and parses the OIDC discovery document from
+// the given URL with client, or http.DefaultClient if it is nil.
+func fetchDiscoveryDocument(ctx context.Context, client *http.Client, configURL string) (*discoveryDocument, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
 	req, err := http.NewRequestWithContext(ctx, "GET", configURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch: %w", err)
 	}
@@ -333,37 +434,69 @@ func fetchDiscoveryDocument(ctx context.Context, configURL string) (*discoveryDo
 	return &doc, nil
 }
 
-// validatePAMAuth validates a username and password using PAM.
-func validatePAMAuth(username, password string) error {
-	t, err := pam.StartFunc("login", username, func(s pam.Style, msg string) (string, error) {
-		switch s {
-		case pam.PromptEchoOff:
-			// Password prompt
-			return password, nil
-		case pam.PromptEchoOn:
-			// Username prompt (shouldn't happen since we provide it)
-			return username, nil
-		case pam.ErrorMsg, pam.TextInfo:
-			// Informational messages - just acknowledge
-			return "", nil
-		default:
-			return "", fmt.Errorf("unrecognized PAM message style: %v", s)
-		}
-	})
+// ErrUsersFile is returned by NewProvider when the local users file can't
+// be loaded.
+var ErrUsersFile = errors.New("failed to load local users file")
+
+// ErrPAMUnavailable is returned for local logins by builds without PAM.
+var ErrPAMUnavailable = errors.New("local PAM authentication not compiled in")
+
+// pamAvailable is PAMAvailable, replaced in tests.
+var pamAvailable = PAMAvailable
+
+// lookupUserGroups returns the names of all groups a local system user belongs to.
+func lookupUserGroups(username string) ([]string, error) {
+	u, err := osuser.Lookup(username)
+	if err != nil {
+		return nil, err
+	}
+	gids, err := u.GroupIds()
 	if err != nil {
-		return fmt.Errorf("failed to start PAM transaction: %w", err)
+		return nil, fmt.Errorf("failed to list groups for %s: %w", username, err)
 	}
-	defer t.End()
 
-	if err := t.Authenticate(0); err != nil {
-		return fmt.Errorf("authentication failed: %w", err)
+	names := make([]string, 0, len(gids))
+	for _, gid := range gids {
+		group, err := osuser.LookupGroupId(gid)
+		if err != nil {
+			continue // Group IDs without a name can't match the configured group
+		}
+		names = append(names, group.Name)
 	}
+	return names, nil
+}
+
+// localAuthEnabled returns true if any user can log in via local access,
+// either through the explicit admins list, the admin group or the users file.
+func (p *Provider) localAuthEnabled() bool {
+	return len(p.localAdmins) > 0 || p.localAdminGroup != "" || p.usersFile != nil
+}
 
-	if err := t.AcctMgmt(0); err != nil {
-		return fmt.Errorf("account validation failed: %w", err)
+// isLocalAdmin reports whether a PAM-authenticated user is a local admin.
+// The explicit admins list is checked first and needs no lookup; otherwise the
+// user must belong to the configured admin group.
+func (p *Provider) isLocalAdmin(username string) (bool, error) {
+	if p.localAdmins[username] {
+		return true, nil
+	}
+	if p.localAdminGroup == "" {
+		return false, nil
 	}
 
-	return nil
+	lookup := p.userGroups
+	if lookup == nil {
+		lookup = lookupUserGroups
+	}
+	groups, err := lookup(username)
+	if err != nil {
+		return false, err
+	}
+	for _, group := range groups {
+		if group == p.localAdminGroup {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 // extractHostname extracts the hostname from a URL string.
@@ -377,25 +510,29 @@ func extractHostname(urlStr string) string {
 	if idx := strings.Index(host, "/"); idx != -1 {
 		host = host[:idx]
 	}
-	// Remove port
-	if idx := strings.LastIndex(host, ":"); idx != -1 {
-		// Make sure it's not part of an IPv6 address
-		if !strings.Contains(host, "[") {
-			host = host[:idx]
+	return strings.ToLower(stripPort(host))
+}
+
+// stripPort removes an optional port from a host, handling IPv6 literals.
+// "[::1]:9001" and "[::1]" become "::1"; a bare IPv6 address is returned unchanged.
+func stripPort(host string) string {
+	if strings.HasPrefix(host, "[") {
+		if idx := strings.Index(host, "]"); idx != -1 {
+			return host[1:idx]
 		}
+		return host
+	}
+	// A single colon separates host and port; more than one means a bare IPv6 address
+	if strings.Count(host, ":") == 1 {
+		return host[:strings.Index(host, ":")]
 	}
-	return strings.ToLower(host)
+	return host
 }
 
 // isLocalAccess checks if the request is coming from a local hostname (not the service_url).
+// Behind a trusted reverse proxy the host the client requested comes from X-Forwarded-Host.
 func (p *Provider) isLocalAccess(r *http.Request) bool {
-	requestHost := strings.ToLower(r.Host)
-	// Remove port from request host
-	if idx := strings.LastIndex(requestHost, ":"); idx != -1 {
-		if !strings.Contains(requestHost, "[") {
-			requestHost = requestHost[:idx]
-		}
-	}
+	requestHost := strings.ToLower(stripPort(realip.Host(r)))
 	return requestHost != p.serviceURLHost
 }
 
@@ -463,7 +600,11 @@ func (p *Provider) CallbackHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Exchange code for tokens
 	code := r.URL.Query().Get("code")
-	token, err := p.oauth2Config.Exchange(ctx, code)
+	exchangeCtx := ctx
+	if p.httpClient != nil {
+		exchangeCtx = WithHTTPClient(ctx, p.httpClient)
+	}
+	token, err := p.oauth2Config.Exchange(exchangeCtx, code)
 	if err != nil {
 		log.Printf("Failed to exchange code: %v", err)
 		http.Error(w, "Failed to exchange authorization code", http.StatusInternalServerError)
@@ -499,7 +640,7 @@ func (p *Provider) CallbackHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Check access - user must be an admin OR have at least one allowed service via group membership
 	if !user.HasAnyAccess() {
-		log.Printf("User %s (%s) denied access - not an admin and no group permissions (groups: %v)", user.Email, user.ID, user.Groups)
+		log.Printf("User %s (%s) denied access from %s - not an admin and no group permissions (groups: %v)", user.Email, user.ID, realip.FromRequest(r), user.Groups)
 		http.Error(w, "Access denied: admin privileges or group membership required", http.StatusForbidden)
 		return
 	}
@@ -518,7 +659,12 @@ func (p *Provider) CallbackHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	p.sessions.Set(sessionID, session)
 
-	log.Printf("User %s (%s) logged in successfully", user.Email, user.ID)
+	if user.HasGlobalAccess {
+		log.Printf("User %s (%s) logged in successfully from %s", user.Email, user.ID, realip.FromRequest(r))
+	} else {
+		access := user.Access()
+		log.Printf("User %s (%s) logged in successfully from %s with access to %d services on %v", user.Email, user.ID, realip.FromRequest(r), access.ServiceCount, access.Hosts)
+	}
 
 	// Set session cookie
 	http.SetCookie(w, &http.Cookie{
@@ -584,6 +730,7 @@ func (p *Provider) buildUserFromClaims(claims map[string]interface{}) *User {
 
 	// Compute allowed services from group memberships
 	user.AllowedServices = p.computeAllowedServices(user.Groups)
+	user.ServicePermissions = p.computeServicePermissions(user.Groups)
 
 	return user
 }
@@ -609,7 +756,7 @@ func (p *Provider) computeAllowedServices(userGroups []string) map[string][]stri
 				hostServices[host] = make(map[string]bool)
 			}
 			for _, svc := range services {
-				hostServices[host][svc] = true
+				hostServices[host][svc.Name] = true
 			}
 		}
 	}
@@ -625,12 +772,61 @@ func (p *Provider) computeAllowedServices(userGroups []string) map[string][]stri
 		for svc := range svcSet {
 			services = append(services, svc)
 		}
+		sort.Strings(services)
 		result[host] = services
 	}
 
 	return result
 }
 
+// computeServicePermissions calculates the permissions a user has on each of
+// their allowed services that don't have every permission. Permissions are
+// additive across groups like services: a service granted by its plain name
+// in any group has every permission.
+func (p *Provider) computeServicePermissions(userGroups []string) map[string]map[string][]string {
+	granted := make(map[string]map[string]map[string]bool)
+	for _, userGroup := range userGroups {
+		groupConfig, ok := p.groupConfigs[userGroup]
+		if !ok || groupConfig == nil {
+			continue
+		}
+		for host, services := range groupConfig.Services {
+			if granted[host] == nil {
+				granted[host] = make(map[string]map[string]bool)
+			}
+			for _, svc := range services {
+				if granted[host][svc.Name] == nil {
+					granted[host][svc.Name] = make(map[string]bool)
+				}
+				for _, permission := range svc.Granted() {
+					granted[host][svc.Name][permission] = true
+				}
+			}
+		}
+	}
+
+	var result map[string]map[string][]string
+	for host, svcs := range granted {
+		for svc, set := range svcs {
+			if len(set) == len(config.AllPermissions) {
+				continue
+			}
+			if result == nil {
+				result = make(map[string]map[string][]string)
+			}
+			if result[host] == nil {
+				result[host] = make(map[string][]string)
+			}
+			for _, permission := range config.AllPermissions {
+				if set[permission] {
+					result[host][svc] = append(result[host][svc], permission)
+				}
+			}
+		}
+	}
+	return result
+}
+
 // checkAdminClaim determines if the user has admin privileges.
 func (p *Provider) checkAdminClaim(claims map[string]interface{}) bool {
 	// First, check if there's a direct "admin" boolean claim
@@ -693,16 +889,19 @@ func (p *Provider) StatusHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	type AuthStatus struct {
-		Authenticated bool   `json:"authenticated"`
-		User          *User  `json:"user,omitempty"`
-		OIDCEnabled   bool   `json:"oidc_enabled"`
-		LocalAccess   bool   `json:"local_access"`
+		Authenticated bool           `json:"authenticated"`
+		User          *User          `json:"user,omitempty"`
+		OIDCEnabled   bool           `json:"oidc_enabled"`
+		LocalAccess   bool           `json:"local_access"`
+		PAMAvailable  bool           `json:"pam_available"`
+		Access        *AccessSummary `json:"access,omitempty"`
 	}
 
 	isLocal := p.isLocalAccess(r)
 	status := AuthStatus{
-		OIDCEnabled: !isLocal, // OIDC is used for external access
-		LocalAccess: isLocal,
+		OIDCEnabled:  !isLocal, // OIDC is used for external access
+		LocalAccess:  isLocal,
+		PAMAvailable: pamAvailable,
 	}
 
 	// Check for valid session
@@ -710,6 +909,8 @@ func (p *Provider) StatusHandler(w http.ResponseWriter, r *http.Request) {
 		if session, ok := p.sessions.Get(cookie.Value); ok {
 			status.Authenticated = true
 			status.User = session.User
+			access := session.User.Access()
+			status.Access = &access
 		}
 	}
 
@@ -762,12 +963,19 @@ func (p *Provider) handleLocalAuth(w http.ResponseWriter, r *http.Request, next
 	}
 
 	// If no local admins configured, deny local access
-	if len(p.localAdmins) == 0 {
-		log.Printf("Local access attempted from %s but no local admins configured", r.Host)
+	if !p.localAuthEnabled() {
+		log.Printf("Local access attempted via %s from %s but no local admins configured", realip.Host(r), realip.FromRequest(r))
 		http.Error(w, "Local access not configured", http.StatusForbidden)
 		return true
 	}
 
+	// Without PAM or a users file no password can be checked, so don't ask for one
+	if !pamAvailable && p.usersFile == nil {
+		log.Printf("Local access attempted via %s from %s but this build has no PAM support", realip.Host(r), realip.FromRequest(r))
+		http.Error(w, "Local authentication is unavailable: this build has no PAM support", http.StatusForbidden)
+		return true
+	}
+
 	// Check for Basic Auth
 	username, password, hasAuth := r.BasicAuth()
 	if !hasAuth {
@@ -777,21 +985,30 @@ func (p *Provider) handleLocalAuth(w http.ResponseWriter, r *http.Request, next
 		return true
 	}
 
-	// Check if username is in local admins list
-	if !p.localAdmins[username] {
-		log.Printf("Local auth failed: user %s not in local admins list", username)
-		w.Header().Set("WWW-Authenticate", `Basic realm="Home Server Dashboard (Local)"`)
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+	// Refuse clients guessing passwords before checking another one
+	client := realip.FromRequest(r)
+	if wait := p.loginFailures.blocked(client); wait > 0 {
+		log.Printf("Local auth refused from %s: too many failed logins", client)
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Round(time.Second).Seconds())))
+		http.Error(w, "Too many failed logins, try again later", http.StatusTooManyRequests)
 		return true
 	}
 
-	// Validate password using PAM
-	if err := validatePAMAuth(username, password); err != nil {
-		log.Printf("Local auth failed: PAM authentication failed for user %s: %v", username, err)
+	// Users of the users file never fall back to PAM, so a system account of
+	// the same name can't be logged into with the file's password or vice versa
+	var user *User
+	if p.usersFile != nil && p.usersFile.Has(username) {
+		user = p.authenticateFileUser(r, username, password)
+	} else {
+		user = p.authenticatePAMUser(r, username, password)
+	}
+	if user == nil {
+		p.loginFailures.fail(client)
 		w.Header().Set("WWW-Authenticate", `Basic realm="Home Server Dashboard (Local)"`)
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return true
 	}
+	p.loginFailures.succeed(client)
 
 	// Create a session for the local user
 	sessionID, err := generateRandomString(64)
@@ -801,22 +1018,13 @@ func (p *Provider) handleLocalAuth(w http.ResponseWriter, r *http.Request, next
 		return true
 	}
 
-	user := &User{
-		ID:              "local:" + username,
-		Name:            username,
-		Email:           username + "@localhost",
-		Groups:          []string{"local", "admin"},
-		IsAdmin:         true,
-		HasGlobalAccess: true, // Local admins always have global access
-	}
-
 	session := &Session{
 		User:      user,
 		ExpiresAt: time.Now().Add(DefaultSessionDuration),
 	}
 	p.sessions.Set(sessionID, session)
 
-	log.Printf("Local user %s authenticated via local access from %s", username, r.Host)
+	log.Printf("Local user %s authenticated via local access to %s from %s", username, realip.Host(r), realip.FromRequest(r))
 
 	// Set session cookie
 	http.SetCookie(w, &http.Cookie{
@@ -835,12 +1043,81 @@ func (p *Provider) handleLocalAuth(w http.ResponseWriter, r *http.Request, next
 	return true
 }
 
+// authenticateFileUser checks the password of a user of the users file,
+// returning the user to log in or nil. Members of the admin group are
+// admins; the services of everyone else come from their groups, as for OIDC
+// users.
+func (p *Provider) authenticateFileUser(r *http.Request, username, password string) *User {
+	entry, ok := p.usersFile.Authenticate(username, password)
+	if !ok {
+		log.Printf("Local auth failed from %s: wrong password for users file user %s", realip.FromRequest(r), username)
+		return nil
+	}
+
+	user := &User{
+		ID:     "local:" + username,
+		Name:   username,
+		Email:  username + "@localhost",
+		Groups: append([]string{"local"}, entry.Groups...),
+	}
+	user.IsAdmin = slices.Contains(entry.Groups, p.adminGroup)
+	user.HasGlobalAccess = user.IsAdmin
+	user.AllowedServices = p.computeAllowedServices(entry.Groups)
+	user.ServicePermissions = p.computeServicePermissions(entry.Groups)
+	if !user.HasAnyAccess() {
+		log.Printf("Local auth failed from %s: users file user %s is not an admin and has no group permissions (groups: %v)", realip.FromRequest(r), username, entry.Groups)
+		return nil
+	}
+	return user
+}
+
+// authenticatePAMUser checks the password of a system user with PAM,
+// returning the local admin to log in or nil.
+func (p *Provider) authenticatePAMUser(r *http.Request, username, password string) *User {
+	if !pamAvailable {
+		log.Printf("Local auth failed from %s: user %s is not in the users file and this build has no PAM support", realip.FromRequest(r), username)
+		return nil
+	}
+
+	// Without an admin group, only listed usernames may attempt PAM authentication
+	if p.localAdminGroup == "" && !p.localAdmins[username] {
+		log.Printf("Local auth failed from %s: user %s not in local admins list", realip.FromRequest(r), username)
+		return nil
+	}
+
+	// Validate password using PAM
+	if err := validatePAMAuth(username, password); err != nil {
+		log.Printf("Local auth failed from %s: PAM authentication failed for user %s: %v", realip.FromRequest(r), username, err)
+		return nil
+	}
+
+	// Check admin status once per login; the result is kept in the session
+	isAdmin, err := p.isLocalAdmin(username)
+	if err != nil {
+		log.Printf("Local auth failed from %s: group lookup failed for user %s: %v", realip.FromRequest(r), username, err)
+		return nil
+	}
+	if !isAdmin {
+		log.Printf("Local auth failed from %s: user %s is not in local admins list or group %q", realip.FromRequest(r), username, p.localAdminGroup)
+		return nil
+	}
+
+	return &User{
+		ID:              "local:" + username,
+		Name:            username,
+		Email:           username + "@localhost",
+		Groups:          []string{"local", "admin"},
+		IsAdmin:         true,
+		HasGlobalAccess: true, // Local admins always have global access
+	}
+}
+
 // handleUnauthorized handles unauthenticated requests.
 func (p *Provider) handleUnauthorized(w http.ResponseWriter, r *http.Request) {
 	// Check if this is local access
 	if p.isLocalAccess(r) {
 		// For local access, request Basic Auth
-		if len(p.localAdmins) > 0 {
+		if p.localAuthEnabled() {
 			w.Header().Set("WWW-Authenticate", `Basic realm="Home Server Dashboard (Local)"`)
 		}
 		w.WriteHeader(http.StatusUnauthorized)
@@ -882,5 +1159,6 @@ func NoAuthStatusHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"authenticated": true,
 		"oidc_enabled":  false,
+		"pam_available": pamAvailable,
 	})
 }
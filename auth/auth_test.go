@@ -2,16 +2,27 @@ package auth
 
 import (
 	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"reflect"
 	"testing"
 	"time"
 
 	"home_server_dashboard/config"
+	"home_server_dashboard/realip"
 )
 
 func TestSessionStore(t *testing.T) {
-	store := NewSessionStore()
+	store := NewSessionStore(100)
 
 	// Test Set and Get
 	session := &Session{
@@ -49,7 +60,7 @@ func TestSessionStore(t *testing.T) {
 }
 
 func TestSessionStore_ExpiredSession(t *testing.T) {
-	store := NewSessionStore()
+	store := NewSessionStore(100)
 
 	// Create expired session
 	session := &Session{
@@ -69,7 +80,7 @@ func TestSessionStore_ExpiredSession(t *testing.T) {
 }
 
 func TestStateStore(t *testing.T) {
-	store := NewStateStore()
+	store := NewStateStore(100)
 
 	// Set a state
 	store.Set("state123")
@@ -381,6 +392,9 @@ func TestExtractHostname(t *testing.T) {
 		{"no protocol", "dashboard.example.com", "dashboard.example.com"},
 		{"ip address", "http://192.168.1.8:9001", "192.168.1.8"},
 		{"uppercase", "HTTPS://Dashboard.Example.COM", "dashboard.example.com"},
+		{"bracketed ipv6 with port", "http://[fd00::1]:9001/path", "fd00::1"},
+		{"bracketed ipv6", "https://[FD00::1]", "fd00::1"},
+		{"ipv6 loopback with port", "http://[::1]:9001", "::1"},
 	}
 
 	for _, tt := range tests {
@@ -393,6 +407,38 @@ func TestExtractHostname(t *testing.T) {
 	}
 }
 
+func TestIsLocalAccess_IPv6ServiceURL(t *testing.T) {
+	p := &Provider{serviceURLHost: extractHostname("https://[fd00::1]:8443")}
+
+	for _, host := range []string{"[fd00::1]", "[fd00::1]:8443", "[FD00::1]:443"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = host
+		if p.isLocalAccess(req) {
+			t.Errorf("isLocalAccess(Host=%q) = true, want false for service_url host", host)
+		}
+	}
+}
+
+func TestStripPort(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"example.com", "example.com"},
+		{"example.com:443", "example.com"},
+		{"192.168.1.8:9001", "192.168.1.8"},
+		{"[fd00::1]:9001", "fd00::1"},
+		{"[fd00::1]", "fd00::1"},
+		{"fd00::1", "fd00::1"},
+	}
+
+	for _, tt := range tests {
+		if got := stripPort(tt.host); got != tt.expected {
+			t.Errorf("stripPort(%q) = %q, want %q", tt.host, got, tt.expected)
+		}
+	}
+}
+
 func TestIsLocalAccess(t *testing.T) {
 	p := &Provider{
 		serviceURLHost: "dashboard.example.com",
@@ -411,6 +457,8 @@ func TestIsLocalAccess(t *testing.T) {
 		{"ip with port is local", "192.168.1.8:9001", true},
 		{"different domain is local", "other.example.com", true},
 		{"case insensitive match", "Dashboard.Example.COM", false},
+		{"bracketed ipv6 is local", "[fd00::1]", true},
+		{"bracketed ipv6 with port is local", "[fd00::1]:9001", true},
 	}
 
 	for _, tt := range tests {
@@ -425,6 +473,40 @@ func TestIsLocalAccess(t *testing.T) {
 	}
 }
 
+func TestIsLocalAccess_ForwardedHost(t *testing.T) {
+	realip.Configure([]netip.Prefix{netip.MustParsePrefix("172.18.0.0/16")})
+	t.Cleanup(func() { realip.Configure(nil) })
+
+	p := &Provider{serviceURLHost: "dashboard.example.com"}
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		host          string
+		forwardedHost string
+		expected      bool
+	}{
+		{"trusted proxy forwards LAN host", "172.18.0.2:40000", "dashboard.example.com", "nas.local:9001", true},
+		{"trusted proxy forwards public host", "172.18.0.2:40000", "nas.local", "dashboard.example.com", false},
+		{"trusted proxy without forwarded host", "172.18.0.2:40000", "dashboard.example.com", "", false},
+		{"untrusted peer cannot claim local host", "203.0.113.7:5000", "dashboard.example.com", "nas.local", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Host = tt.host
+			if tt.forwardedHost != "" {
+				req.Header.Set("X-Forwarded-Host", tt.forwardedHost)
+			}
+			if got := p.isLocalAccess(req); got != tt.expected {
+				t.Errorf("isLocalAccess() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
 func TestLocalAdminsParsing(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -460,6 +542,69 @@ func TestLocalAdminsParsing(t *testing.T) {
 	}
 }
 
+func TestIsLocalAdmin(t *testing.T) {
+	groups := map[string][]string{
+		"alice": {"alice", "wheel"},
+		"bob":   {"bob", "users"},
+	}
+	lookups := 0
+	lookup := func(username string) ([]string, error) {
+		lookups++
+		g, ok := groups[username]
+		if !ok {
+			return nil, errors.New("unknown user")
+		}
+		return g, nil
+	}
+
+	tests := []struct {
+		name        string
+		admins      map[string]bool
+		adminGroup  string
+		username    string
+		want        bool
+		wantErr     bool
+		wantLookups int
+	}{
+		{"group member", nil, "wheel", "alice", true, false, 1},
+		{"group non-member", nil, "wheel", "bob", false, false, 1},
+		{"listed user skips lookup", map[string]bool{"bob": true}, "wheel", "bob", true, false, 0},
+		{"group member with list configured", map[string]bool{"bob": true}, "wheel", "alice", true, false, 1},
+		{"list only", map[string]bool{"bob": true}, "", "alice", false, false, 0},
+		{"lookup error", nil, "wheel", "mallory", false, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookups = 0
+			p := &Provider{localAdmins: tt.admins, localAdminGroup: tt.adminGroup, userGroups: lookup}
+
+			got, err := p.isLocalAdmin(tt.username)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("isLocalAdmin(%q) error = %v, wantErr %v", tt.username, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("isLocalAdmin(%q) = %v, want %v", tt.username, got, tt.want)
+			}
+			if lookups != tt.wantLookups {
+				t.Errorf("group lookups = %d, want %d", lookups, tt.wantLookups)
+			}
+		})
+	}
+}
+
+func TestLocalAuthEnabled(t *testing.T) {
+	if (&Provider{}).localAuthEnabled() {
+		t.Error("expected local auth disabled with no admins or group")
+	}
+	if !(&Provider{localAdmins: map[string]bool{"xero": true}}).localAuthEnabled() {
+		t.Error("expected local auth enabled with admins list")
+	}
+	if !(&Provider{localAdminGroup: "wheel"}).localAuthEnabled() {
+		t.Error("expected local auth enabled with admin group")
+	}
+}
+
 // Helper function to split and trim
 func splitAndTrim(s string) []string {
 	var result []string
@@ -634,6 +779,42 @@ func TestUser_HasAnyAccess(t *testing.T) {
 	}
 }
 
+func TestUser_CanAccessHost(t *testing.T) {
+	user := &User{
+		AllowedServices: map[string][]string{
+			"nas":   {"docker.service"},
+			"empty": {},
+		},
+	}
+
+	if !user.CanAccessHost("nas") {
+		t.Error("CanAccessHost(nas) = false, want true")
+	}
+	if user.CanAccessHost("empty") {
+		t.Error("CanAccessHost(empty) = true, want false")
+	}
+	if user.CanAccessHost("other") {
+		t.Error("CanAccessHost(other) = true, want false")
+	}
+	if !(&User{HasGlobalAccess: true}).CanAccessHost("other") {
+		t.Error("CanAccessHost() with global access = false, want true")
+	}
+}
+
+func TestUser_InAnyGroup(t *testing.T) {
+	user := &User{Groups: []string{"family", "media"}}
+
+	if !user.InAnyGroup([]string{"admins", "media"}) {
+		t.Error("InAnyGroup(admins, media) = false, want true")
+	}
+	if user.InAnyGroup([]string{"admins"}) {
+		t.Error("InAnyGroup(admins) = true, want false")
+	}
+	if user.InAnyGroup(nil) {
+		t.Error("InAnyGroup(nil) = true, want false")
+	}
+}
+
 func TestProvider_ComputeAllowedServices(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -657,8 +838,8 @@ func TestProvider_ComputeAllowedServices(t *testing.T) {
 			name: "user not in any configured group",
 			groupConfigs: map[string]*config.OIDCGroupConfig{
 				"poweruser": {
-					Services: map[string][]string{
-						"nas": {"docker.service"},
+					Services: map[string][]config.GroupService{
+						"nas": {{Name: "docker.service"}},
 					},
 				},
 			},
@@ -669,8 +850,8 @@ func TestProvider_ComputeAllowedServices(t *testing.T) {
 			name: "single group membership",
 			groupConfigs: map[string]*config.OIDCGroupConfig{
 				"poweruser": {
-					Services: map[string][]string{
-						"nas": {"docker.service", "audiobookshelf"},
+					Services: map[string][]config.GroupService{
+						"nas": {{Name: "docker.service"}, {Name: "audiobookshelf"}},
 					},
 				},
 			},
@@ -683,13 +864,13 @@ func TestProvider_ComputeAllowedServices(t *testing.T) {
 			name: "multiple group membership - additive",
 			groupConfigs: map[string]*config.OIDCGroupConfig{
 				"poweruser": {
-					Services: map[string][]string{
-						"nas": {"docker.service", "audiobookshelf"},
+					Services: map[string][]config.GroupService{
+						"nas": {{Name: "docker.service"}, {Name: "audiobookshelf"}},
 					},
 				},
 				"bookreader": {
-					Services: map[string][]string{
-						"nas": {"traefik", "audiobookshelf"},
+					Services: map[string][]config.GroupService{
+						"nas": {{Name: "traefik"}, {Name: "audiobookshelf"}},
 					},
 				},
 			},
@@ -702,9 +883,9 @@ func TestProvider_ComputeAllowedServices(t *testing.T) {
 			name: "multiple hosts",
 			groupConfigs: map[string]*config.OIDCGroupConfig{
 				"poweruser": {
-					Services: map[string][]string{
-						"nas":         {"docker.service"},
-						"anotherhost": {"ollama.service"},
+					Services: map[string][]config.GroupService{
+						"nas":         {{Name: "docker.service"}},
+						"anotherhost": {{Name: "ollama.service"}},
 					},
 				},
 			},
@@ -777,8 +958,8 @@ func TestBuildUserFromClaims_WithGroupConfig(t *testing.T) {
 		adminGroup:  "admin",
 		groupConfigs: map[string]*config.OIDCGroupConfig{
 			"poweruser": {
-				Services: map[string][]string{
-					"nas": {"docker.service", "audiobookshelf"},
+				Services: map[string][]config.GroupService{
+					"nas": {{Name: "docker.service"}, {Name: "audiobookshelf"}},
 				},
 			},
 		},
@@ -825,8 +1006,8 @@ func TestBuildUserFromClaims_AdminHasGlobalAccess(t *testing.T) {
 		adminGroup:  "admin",
 		groupConfigs: map[string]*config.OIDCGroupConfig{
 			"poweruser": {
-				Services: map[string][]string{
-					"nas": {"docker.service"},
+				Services: map[string][]config.GroupService{
+					"nas": {{Name: "docker.service"}},
 				},
 			},
 		},
@@ -858,3 +1039,377 @@ func TestBuildUserFromClaims_AdminHasGlobalAccess(t *testing.T) {
 		t.Error("Expected admin to have access to any service on any host")
 	}
 }
+
+func TestBuildUserFromClaims_ServicePermissions(t *testing.T) {
+	p := &Provider{
+		groupsClaim: "groups",
+		adminGroup:  "admin",
+		groupConfigs: map[string]*config.OIDCGroupConfig{
+			"household": {
+				Services: map[string][]config.GroupService{
+					"nas": {
+						{Name: "plex", Permissions: []string{"view", "logs"}},
+						{Name: "jellyfin", Permissions: []string{"view"}},
+						{Name: "sonarr", Permissions: []string{"view"}},
+					},
+				},
+			},
+			"poweruser": {
+				Services: map[string][]config.GroupService{
+					"nas": {{Name: "plex"}, {Name: "jellyfin", Permissions: []string{"control"}}},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		groups      []interface{}
+		service     string
+		wantView    bool
+		wantLogs    bool
+		wantControl bool
+	}{
+		{"view and logs", []interface{}{"household"}, "plex", true, true, false},
+		{"view only", []interface{}{"household"}, "sonarr", true, false, false},
+		{"plain name in another group wins", []interface{}{"household", "poweruser"}, "plex", true, true, true},
+		{"permissions merge across groups", []interface{}{"household", "poweruser"}, "jellyfin", true, false, true},
+		{"control implies view", []interface{}{"poweruser"}, "jellyfin", true, false, true},
+		{"not granted", []interface{}{"poweruser"}, "sonarr", false, false, false},
+		{"admin", []interface{}{"admin", "household"}, "sonarr", true, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := p.buildUserFromClaims(map[string]interface{}{"sub": "user", "groups": tt.groups})
+			view := user.CanAccessService("nas", tt.service)
+			logs := user.CanViewLogs("nas", tt.service)
+			control := user.CanControlService("nas", tt.service)
+			if view != tt.wantView || logs != tt.wantLogs || control != tt.wantControl {
+				t.Errorf("view, logs, control = %v, %v, %v; want %v, %v, %v",
+					view, logs, control, tt.wantView, tt.wantLogs, tt.wantControl)
+			}
+		})
+	}
+
+	// Services with every permission aren't listed
+	user := p.buildUserFromClaims(map[string]interface{}{"sub": "user", "groups": []interface{}{"household", "poweruser"}})
+	want := map[string]map[string][]string{"nas": {"jellyfin": {"view", "control"}, "sonarr": {"view"}}}
+	if !reflect.DeepEqual(user.ServicePermissions, want) {
+		t.Errorf("ServicePermissions = %v, want %v", user.ServicePermissions, want)
+	}
+}
+
+func TestUser_Permissions_AllowedServicesOnly(t *testing.T) {
+	// Users granted services without permissions have every permission
+	user := &User{AllowedServices: map[string][]string{"nas": {"plex"}}}
+	if !user.CanViewLogs("nas", "plex") || !user.CanControlService("nas", "plex") {
+		t.Error("Expected every permission on plex")
+	}
+	if user.CanViewLogs("nas", "sonarr") || user.CanControlService("nas", "sonarr") {
+		t.Error("Expected no permission on sonarr")
+	}
+}
+
+// fakeIdP is a minimal OIDC provider that issues RS256-signed ID tokens with fixed claims.
+type fakeIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]interface{}
+}
+
+func newFakeIdP(t *testing.T, claims map[string]interface{}) *fakeIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	idp := &fakeIdP{key: key, claims: claims}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                idp.server.URL,
+			"authorization_endpoint":                idp.server.URL + "/auth",
+			"token_endpoint":                        idp.server.URL + "/token",
+			"jwks_uri":                              idp.server.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "test-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idp.signIDToken(t),
+		})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// signIDToken builds a signed ID token for the test client from the IdP's claims.
+func (idp *fakeIdP) signIDToken(t *testing.T) string {
+	claims := map[string]interface{}{
+		"iss": idp.server.URL,
+		"aud": "test-client",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range idp.claims {
+		claims[k] = v
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test-key", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Errorf("failed to sign ID token: %v", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func newCallbackTestProvider(t *testing.T, idp *fakeIdP) *Provider {
+	t.Helper()
+
+	cfg := &config.OIDCConfig{
+		ServiceURL:   "https://dashboard.example.com",
+		Callback:     "/oidc/callback",
+		ConfigURL:    idp.server.URL + "/.well-known/openid-configuration",
+		ClientID:     "test-client",
+		ClientSecret: "test-secret",
+		Groups: map[string]*config.OIDCGroupConfig{
+			"media": {Services: map[string][]config.GroupService{
+				"nas": {{Name: "jellyfin"}, {Name: "sonarr"}},
+			}},
+		},
+	}
+	p, err := NewProvider(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatalf("NewProvider() error: %v", err)
+	}
+	return p
+}
+
+// runCallback drives CallbackHandler with a valid state and returns the response.
+func runCallback(p *Provider) *httptest.ResponseRecorder {
+	p.states.Set("test-state")
+	req := httptest.NewRequest(http.MethodGet, "/oidc/callback?state=test-state&code=test-code", nil)
+	req.Host = "dashboard.example.com"
+	w := httptest.NewRecorder()
+	p.CallbackHandler(w, req)
+	return w
+}
+
+func sessionUserFromResponse(t *testing.T, p *Provider, w *httptest.ResponseRecorder) *User {
+	t.Helper()
+	for _, c := range w.Result().Cookies() {
+		if c.Name == SessionCookieName && c.Value != "" {
+			session, ok := p.sessions.Get(c.Value)
+			if !ok {
+				t.Fatal("session cookie set but session not stored")
+			}
+			return session.User
+		}
+	}
+	t.Fatal("no session cookie set")
+	return nil
+}
+
+func TestCallbackHandler_ScopedGroupUser(t *testing.T) {
+	idp := newFakeIdP(t, map[string]interface{}{
+		"sub":    "user-1",
+		"email":  "viewer@example.com",
+		"name":   "Viewer",
+		"groups": []string{"media", "unrelated"},
+	})
+	p := newCallbackTestProvider(t, idp)
+
+	w := runCallback(p)
+	if w.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("Status = %d, want %d (body: %s)", w.Code, http.StatusTemporaryRedirect, w.Body.String())
+	}
+
+	user := sessionUserFromResponse(t, p, w)
+	if user.IsAdmin || user.HasGlobalAccess {
+		t.Errorf("scoped user should not be admin: %+v", user)
+	}
+	want := []string{"jellyfin", "sonarr"}
+	got := user.AllowedServices["nas"]
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("AllowedServices[nas] = %v, want %v", got, want)
+	}
+
+	// The status payload carries the access summary for the UI
+	req := httptest.NewRequest(http.MethodGet, "/auth/status", nil)
+	req.Host = "dashboard.example.com"
+	for _, c := range w.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	sw := httptest.NewRecorder()
+	p.StatusHandler(sw, req)
+
+	var status struct {
+		Authenticated bool           `json:"authenticated"`
+		Access        *AccessSummary `json:"access"`
+	}
+	if err := json.NewDecoder(sw.Body).Decode(&status); err != nil {
+		t.Fatalf("failed to decode status: %v", err)
+	}
+	if !status.Authenticated || status.Access == nil {
+		t.Fatalf("expected authenticated status with access summary, got %+v", status)
+	}
+	if status.Access.Global || status.Access.ServiceCount != 2 || len(status.Access.Hosts) != 1 || status.Access.Hosts[0] != "nas" {
+		t.Errorf("Access = %+v, want 2 services on [nas]", *status.Access)
+	}
+
+	// The middleware lets the scoped user through with their user in context
+	mreq := httptest.NewRequest(http.MethodGet, "/api/services", nil)
+	mreq.Host = "dashboard.example.com"
+	for _, c := range w.Result().Cookies() {
+		mreq.AddCookie(c)
+	}
+	var ctxUser *User
+	mw := httptest.NewRecorder()
+	p.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctxUser = GetUserFromContext(r.Context())
+	})).ServeHTTP(mw, mreq)
+	if ctxUser == nil || ctxUser.ID != "user-1" {
+		t.Errorf("middleware context user = %+v, want user-1", ctxUser)
+	}
+}
+
+func TestCallbackHandler_NoAccessRejected(t *testing.T) {
+	idp := newFakeIdP(t, map[string]interface{}{
+		"sub":    "user-2",
+		"email":  "nobody@example.com",
+		"groups": []string{"unrelated"},
+	})
+	p := newCallbackTestProvider(t, idp)
+
+	w := runCallback(p)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == SessionCookieName {
+			t.Error("no session cookie should be set for a user without access")
+		}
+	}
+}
+
+func TestCallbackHandler_AdminUser(t *testing.T) {
+	idp := newFakeIdP(t, map[string]interface{}{
+		"sub":    "admin-1",
+		"email":  "admin@example.com",
+		"groups": []string{"admin"},
+	})
+	p := newCallbackTestProvider(t, idp)
+
+	w := runCallback(p)
+	if w.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("Status = %d, want %d (body: %s)", w.Code, http.StatusTemporaryRedirect, w.Body.String())
+	}
+	user := sessionUserFromResponse(t, p, w)
+	if !user.IsAdmin || !user.HasGlobalAccess {
+		t.Errorf("admin user should have global access: %+v", user)
+	}
+	if access := user.Access(); !access.Global {
+		t.Errorf("Access() = %+v, want global", access)
+	}
+}
+
+// withoutPAM makes the provider behave as in a build without PAM.
+func withoutPAM(t *testing.T) {
+	t.Helper()
+	original := pamAvailable
+	pamAvailable = false
+	t.Cleanup(func() { pamAvailable = original })
+}
+
+func TestHandleLocalAuth_WithoutPAM(t *testing.T) {
+	withoutPAM(t)
+	p := &Provider{
+		serviceURLHost: "dashboard.example.com",
+		sessions:       NewSessionStore(100),
+		localAdmins:    map[string]bool{"xero": true},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("next handler reached without a session")
+	})
+
+	// No password prompt: it could never be checked
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "192.168.1.8:9001"
+	req.SetBasicAuth("xero", "secret")
+	w := httptest.NewRecorder()
+	p.Middleware(next).ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w.Header().Get("WWW-Authenticate") != "" {
+		t.Error("WWW-Authenticate set, want no Basic Auth challenge")
+	}
+	if !contains(w.Body.String(), "no PAM support") {
+		t.Errorf("body = %q, want it to explain PAM is unavailable", w.Body.String())
+	}
+}
+
+func TestMiddleware_OIDCWithoutPAM(t *testing.T) {
+	withoutPAM(t)
+	p := &Provider{
+		serviceURLHost: "dashboard.example.com",
+		sessions:       NewSessionStore(100),
+		localAdmins:    map[string]bool{"xero": true},
+	}
+	p.sessions.Set("session-1", &Session{User: &User{ID: "user-1"}, ExpiresAt: time.Now().Add(time.Hour)})
+
+	var got *User
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = GetUserFromContext(r.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "dashboard.example.com"
+	req.AddCookie(&http.Cookie{Name: SessionCookieName, Value: "session-1"})
+	w := httptest.NewRecorder()
+	p.Middleware(next).ServeHTTP(w, req)
+
+	if got == nil || got.ID != "user-1" {
+		t.Errorf("user = %+v, want the OIDC session user (status %d)", got, w.Code)
+	}
+
+	// The status probe tells the UI local login is unavailable
+	sw := httptest.NewRecorder()
+	p.StatusHandler(sw, req)
+	var status struct {
+		PAMAvailable *bool `json:"pam_available"`
+	}
+	if err := json.NewDecoder(sw.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status.PAMAvailable == nil || *status.PAMAvailable {
+		t.Errorf("pam_available = %v, want false", status.PAMAvailable)
+	}
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// Limits on failed local logins: a client that fails maxLoginFailures times
+// within loginFailureWindow is refused until the window has passed since its
+// first failure.
+const (
+	maxLoginFailures   = 5
+	loginFailureWindow = 15 * time.Minute
+)
+
+// loginFailures is a client's failed logins in the current window.
+type loginFailures struct {
+	count int
+	first time.Time
+}
+
+// loginLimiter counts failed local logins per client IP, whichever backend,
+// the users file or PAM, checked the password. A nil limiter never limits.
+type loginLimiter struct {
+	mu       sync.Mutex
+	failures map[string]*loginFailures
+	now      func() time.Time
+}
+
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{failures: make(map[string]*loginFailures), now: time.Now}
+}
+
+// blocked reports how long the client at ip must wait before trying again,
+// or 0 if it may try now.
+func (l *loginLimiter) blocked(ip string) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, ok := l.failures[ip]
+	if !ok {
+		return 0
+	}
+	wait := f.first.Add(loginFailureWindow).Sub(l.now())
+	if wait <= 0 {
+		delete(l.failures, ip)
+		return 0
+	}
+	if f.count < maxLoginFailures {
+		return 0
+	}
+	return wait
+}
+
+// fail records a failed login from ip.
+func (l *loginLimiter) fail(ip string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	f, ok := l.failures[ip]
+	if !ok || now.Sub(f.first) >= loginFailureWindow {
+		f = &loginFailures{first: now}
+		l.failures[ip] = f
+		l.prune(now)
+	}
+	f.count++
+}
+
+// succeed forgets the failed logins of ip.
+func (l *loginLimiter) succeed(ip string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.failures, ip)
+}
+
+// prune drops the windows that have passed. l.mu must be held.
+func (l *loginLimiter) prune(now time.Time) {
+	for ip, f := range l.failures {
+		if now.Sub(f.first) >= loginFailureWindow {
+			delete(l.failures, ip)
+		}
+	}
+}
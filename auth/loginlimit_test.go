@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginLimiter(t *testing.T) {
+	now := time.Now()
+	l := newLoginLimiter()
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < maxLoginFailures-1; i++ {
+		l.fail("10.0.0.5")
+	}
+	if wait := l.blocked("10.0.0.5"); wait != 0 {
+		t.Errorf("blocked after %d failures for %v, want 0", maxLoginFailures-1, wait)
+	}
+
+	now = now.Add(time.Minute)
+	l.fail("10.0.0.5")
+	if wait := l.blocked("10.0.0.5"); wait != loginFailureWindow-time.Minute {
+		t.Errorf("blocked for %v, want the rest of the window", wait)
+	}
+	if wait := l.blocked("10.0.0.6"); wait != 0 {
+		t.Errorf("another client blocked for %v", wait)
+	}
+
+	now = now.Add(loginFailureWindow)
+	if wait := l.blocked("10.0.0.5"); wait != 0 {
+		t.Errorf("still blocked for %v after the window", wait)
+	}
+	if len(l.failures) != 0 {
+		t.Errorf("failures = %v, want the passed window forgotten", l.failures)
+	}
+}
+
+func TestLoginLimiter_Succeed(t *testing.T) {
+	l := newLoginLimiter()
+	for i := 0; i < maxLoginFailures-1; i++ {
+		l.fail("10.0.0.5")
+	}
+	l.succeed("10.0.0.5")
+	l.fail("10.0.0.5")
+	if wait := l.blocked("10.0.0.5"); wait != 0 {
+		t.Errorf("blocked for %v, want the failures before the login forgotten", wait)
+	}
+
+	var none *loginLimiter
+	none.fail("10.0.0.5")
+	none.succeed("10.0.0.5")
+	if wait := none.blocked("10.0.0.5"); wait != 0 {
+		t.Errorf("nil limiter blocked for %v", wait)
+	}
+}
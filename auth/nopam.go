@@ -0,0 +1,13 @@
+//go:build nopam || (!pam && !(cgo && (linux || freebsd || darwin)))
+
+package auth
+
+// PAMAvailable reports whether local PAM authentication is compiled in.
+// This build has no libpam, either because cgo is unavailable or because it
+// was built with -tags nopam, so local access is always refused.
+const PAMAvailable = false
+
+// validatePAMAuth always fails: there is no PAM to validate against.
+func validatePAMAuth(username, password string) error {
+	return ErrPAMUnavailable
+}
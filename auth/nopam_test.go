@@ -0,0 +1,17 @@
+//go:build nopam || (!pam && !(cgo && (linux || freebsd || darwin)))
+
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidatePAMAuth_NotCompiledIn(t *testing.T) {
+	if PAMAvailable {
+		t.Fatal("PAMAvailable = true in a build without PAM")
+	}
+	if err := validatePAMAuth("xero", "secret"); !errors.Is(err, ErrPAMUnavailable) {
+		t.Errorf("validatePAMAuth() = %v, want %v", err, ErrPAMUnavailable)
+	}
+}
@@ -0,0 +1,47 @@
+//go:build (pam || (cgo && (linux || freebsd || darwin))) && !nopam
+
+package auth
+
+import (
+	"fmt"
+
+	"github.com/msteinert/pam/v2"
+)
+
+// PAMAvailable reports whether local PAM authentication is compiled in.
+// It is by default wherever cgo is available; build with -tags nopam to
+// leave it out, or -tags pam to insist on it.
+const PAMAvailable = true
+
+// validatePAMAuth validates a username and password using PAM.
+func validatePAMAuth(username, password string) error {
+	t, err := pam.StartFunc("login", username, func(s pam.Style, msg string) (string, error) {
+		switch s {
+		case pam.PromptEchoOff:
+			// Password prompt
+			return password, nil
+		case pam.PromptEchoOn:
+			// Username prompt (shouldn't happen since we provide it)
+			return username, nil
+		case pam.ErrorMsg, pam.TextInfo:
+			// Informational messages - just acknowledge
+			return "", nil
+		default:
+			return "", fmt.Errorf("unrecognized PAM message style: %v", s)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start PAM transaction: %w", err)
+	}
+	defer t.End()
+
+	if err := t.Authenticate(0); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+
+	if err := t.AcctMgmt(0); err != nil {
+		return fmt.Errorf("account validation failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// expiringStore holds values under string keys until they expire, up to max
+// of them: adding one more drops the oldest first. Expired values are
+// removed every cleanup interval by a goroutine that runs until Close.
+type expiringStore[V any] struct {
+	mu        sync.Mutex
+	max       int
+	entries   map[string]*list.Element
+	order     *list.List // *storeEntry[V], oldest first
+	evictions uint64
+	now       func() time.Time
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+type storeEntry[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+}
+
+func newExpiringStore[V any](max int, cleanup time.Duration) *expiringStore[V] {
+	s := &expiringStore[V]{
+		max:     max,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go s.cleanupExpired(cleanup)
+	return s
+}
+
+// set stores value under key until expires, as the newest value. If that
+// makes more than max values, the oldest are dropped.
+func (s *expiringStore[V]) set(key string, value V, expires time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		s.order.Remove(e)
+	}
+	s.entries[key] = s.order.PushBack(&storeEntry[V]{key: key, value: value, expires: expires})
+	for s.max > 0 && s.order.Len() > s.max {
+		oldest := s.order.Remove(s.order.Front()).(*storeEntry[V])
+		delete(s.entries, oldest.key)
+		s.evictions++
+	}
+}
+
+// get returns the value under key, if it hasn't expired.
+func (s *expiringStore[V]) get(key string) (V, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lookup(key)
+}
+
+// take returns the value under key, if it hasn't expired, and removes it.
+func (s *expiringStore[V]) take(key string) (V, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.lookup(key)
+	s.remove(key)
+	return value, ok
+}
+
+// delete removes the value under key.
+func (s *expiringStore[V]) delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(key)
+}
+
+// lookup is get with s.mu held.
+func (s *expiringStore[V]) lookup(key string) (V, bool) {
+	var zero V
+	e, ok := s.entries[key]
+	if !ok {
+		return zero, false
+	}
+	entry := e.Value.(*storeEntry[V])
+	if s.now().After(entry.expires) {
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// remove is delete with s.mu held.
+func (s *expiringStore[V]) remove(key string) {
+	if e, ok := s.entries[key]; ok {
+		s.order.Remove(e)
+		delete(s.entries, key)
+	}
+}
+
+// stats returns how many values are held and how many were dropped to stay
+// within max.
+func (s *expiringStore[V]) stats() (size int, evictions uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries), s.evictions
+}
+
+// removeExpired removes the values that have expired.
+func (s *expiringStore[V]) removeExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for e := s.order.Front(); e != nil; {
+		next := e.Next()
+		if entry := e.Value.(*storeEntry[V]); now.After(entry.expires) {
+			s.order.Remove(e)
+			delete(s.entries, entry.key)
+		}
+		e = next
+	}
+}
+
+// cleanupExpired removes expired values every interval until Close.
+func (s *expiringStore[V]) cleanupExpired(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.removeExpired()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Close stops the cleanup goroutine and waits for it to return. It may be
+// called more than once.
+func (s *expiringStore[V]) Close() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestExpiringStore_EvictsOldestFirst(t *testing.T) {
+	s := newExpiringStore[int](3, time.Hour)
+	defer s.Close()
+	expires := time.Now().Add(time.Hour)
+
+	for i := 1; i <= 3; i++ {
+		s.set(fmt.Sprint(i), i, expires)
+	}
+	// Setting 1 again makes it the newest, so 2 goes first
+	s.set("1", 10, expires)
+	s.set("4", 4, expires)
+	s.set("5", 5, expires)
+
+	for key, want := range map[string]bool{"1": true, "2": false, "3": false, "4": true, "5": true} {
+		if _, ok := s.get(key); ok != want {
+			t.Errorf("get(%s) found = %v, want %v", key, ok, want)
+		}
+	}
+	if value, _ := s.get("1"); value != 10 {
+		t.Errorf("get(1) = %d, want the value set last", value)
+	}
+	if size, evictions := s.stats(); size != 3 || evictions != 2 {
+		t.Errorf("stats() = %d, %d; want 3 held and 2 evicted", size, evictions)
+	}
+
+	// Removed values make room without evicting anything
+	s.delete("4")
+	if v, ok := s.take("5"); !ok || v != 5 {
+		t.Errorf("take(5) = %d, %v", v, ok)
+	}
+	s.set("6", 6, expires)
+	if size, evictions := s.stats(); size != 2 || evictions != 2 {
+		t.Errorf("stats() = %d, %d; want 2 held and still 2 evicted", size, evictions)
+	}
+}
+
+func TestExpiringStore_RemoveExpired(t *testing.T) {
+	s := newExpiringStore[int](0, time.Hour)
+	defer s.Close()
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	s.set("old", 1, now.Add(time.Minute))
+	s.set("new", 2, now.Add(time.Hour))
+	now = now.Add(2 * time.Minute)
+	if _, ok := s.get("old"); ok {
+		t.Error("expired value returned")
+	}
+	s.removeExpired()
+	if size, _ := s.stats(); size != 1 {
+		t.Errorf("%d values held after cleanup, want 1", size)
+	}
+	if _, ok := s.get("new"); !ok {
+		t.Error("unexpired value removed")
+	}
+}
+
+func TestExpiringStore_Close(t *testing.T) {
+	before := runtime.NumGoroutine()
+	stores := make([]*expiringStore[int], 20)
+	for i := range stores {
+		stores[i] = newExpiringStore[int](10, time.Millisecond)
+	}
+	for _, s := range stores {
+		s.Close()
+		s.Close()
+	}
+	// Close waits for each cleanup goroutine, so none are left behind
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines after closing the stores, %d before", after, before)
+	}
+}
+
+func TestProvider_StoreStats(t *testing.T) {
+	p := &Provider{sessions: NewSessionStore(2), states: NewStateStore(1)}
+	defer p.Close()
+
+	expires := time.Now().Add(time.Hour)
+	for _, id := range []string{"a", "b", "c"} {
+		p.sessions.Set(id, &Session{User: &User{ID: id}, ExpiresAt: expires})
+	}
+	p.states.Set("first")
+	p.states.Set("second")
+
+	if _, ok := p.sessions.Get("a"); ok {
+		t.Error("oldest session kept past the limit")
+	}
+	if p.states.Validate("first") || !p.states.Validate("second") {
+		t.Error("oldest login state kept past the limit, or the newest dropped")
+	}
+	want := StoreStats{Sessions: 2, MaxSessions: 2, SessionEvictions: 1, States: 0, MaxStates: 1, StateEvictions: 1}
+	if got := p.StoreStats(); got != want {
+		t.Errorf("StoreStats() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// usersFileCheckInterval is how often the users file is checked for changes.
+const usersFileCheckInterval = 2 * time.Second
+
+// fileUser is a dashboard-only user from the local users file.
+type fileUser struct {
+	Name   string
+	hash   []byte
+	Groups []string
+}
+
+// UsersFile holds the users of an htpasswd-style file, one per line as
+// "user:bcrypt-hash:group1,group2", for local logins without system
+// accounts. Blank lines and lines starting with # are ignored. The file is
+// read again when it changes; if the new contents can't be parsed, the
+// users read before are kept.
+type UsersFile struct {
+	path string
+
+	mu      sync.Mutex
+	users   map[string]fileUser
+	modTime time.Time
+	size    int64
+	checked time.Time
+	now     func() time.Time
+}
+
+// dummyHash is compared against for unknown users, so their logins take as
+// long as those of users with a wrong password.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// LoadUsersFile reads the users file at path.
+func LoadUsersFile(path string) (*UsersFile, error) {
+	f := &UsersFile{path: path, now: time.Now}
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// reload reads the file if it changed since it was last read.
+func (f *UsersFile) reload() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	if f.users != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return nil
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		log.Printf("Warning: local users file %s is accessible by other users (mode %#o); restrict it with chmod 600", f.path, info.Mode().Perm())
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	users, err := parseUsersFile(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.path, err)
+	}
+	if f.users != nil {
+		log.Printf("Reloaded %d local users from %s", len(users), f.path)
+	}
+	f.users, f.modTime, f.size = users, info.ModTime(), info.Size()
+	return nil
+}
+
+// parseUsersFile parses the lines of a users file.
+func parseUsersFile(data []byte) (map[string]fileUser, error) {
+	users := make(map[string]fileUser)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// bcrypt hashes contain no colons, so the groups may be left off
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) < 2 || fields[0] == "" {
+			return nil, fmt.Errorf("line %d: want user:bcrypt-hash:groups", n)
+		}
+		user := fileUser{Name: fields[0], hash: []byte(fields[1])}
+		if _, err := bcrypt.Cost(user.hash); err != nil {
+			return nil, fmt.Errorf("line %d: user %s: password is not a bcrypt hash: %w", n, user.Name, err)
+		}
+		if len(fields) == 3 {
+			for _, group := range strings.Split(fields[2], ",") {
+				if group = strings.TrimSpace(group); group != "" {
+					user.Groups = append(user.Groups, group)
+				}
+			}
+		}
+		if _, ok := users[user.Name]; ok {
+			return nil, fmt.Errorf("line %d: user %s is listed twice", n, user.Name)
+		}
+		users[user.Name] = user
+	}
+	return users, scanner.Err()
+}
+
+// refresh rereads the file if it changed, at most every
+// usersFileCheckInterval. f.mu must be held.
+func (f *UsersFile) refresh() {
+	now := f.now()
+	if now.Sub(f.checked) < usersFileCheckInterval {
+		return
+	}
+	f.checked = now
+	if err := f.reload(); err != nil {
+		log.Printf("Warning: keeping the local users read before: %v", err)
+	}
+}
+
+// Has reports whether username is listed in the file.
+func (f *UsersFile) Has(username string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.refresh()
+	_, ok := f.users[username]
+	return ok
+}
+
+// Authenticate checks password against the hash of username, returning
+// the user if it matches. Unknown users take as long to reject as wrong
+// passwords.
+func (f *UsersFile) Authenticate(username, password string) (fileUser, bool) {
+	f.mu.Lock()
+	f.refresh()
+	user, ok := f.users[username]
+	f.mu.Unlock()
+
+	hash := user.hash
+	if !ok {
+		hash = dummyHash()
+	}
+	// bcrypt compares the hashes in constant time
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || !ok {
+		return fileUser{}, false
+	}
+	return user, true
+}
+
+// HashPassword returns the users file line of username with password and
+// groups.
+func HashPassword(username, password string, groups []string) (string, error) {
+	if username == "" || strings.ContainsAny(username, ":\n") {
+		return "", fmt.Errorf("invalid username %q", username)
+	}
+	for _, group := range groups {
+		if strings.ContainsAny(group, ":,\n") {
+			return "", fmt.Errorf("invalid group %q", group)
+		}
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return username + ":" + string(hash) + ":" + strings.Join(groups, ","), nil
+}
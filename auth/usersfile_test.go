@@ -0,0 +1,332 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"home_server_dashboard/config"
+)
+
+// usersFileLine returns the users file line of username with a cheap hash of
+// password.
+func usersFileLine(t *testing.T, username, password, groups string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return username + ":" + string(hash) + ":" + groups
+}
+
+// writeUsersFile writes lines to the users file at path, readable only by
+// its owner.
+func writeUsersFile(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseUsersFile(t *testing.T) {
+	alice := usersFileLine(t, "alice", "wonderland", "admin")
+	bob := usersFileLine(t, "bob", "builder", " media , family,")
+	carol := usersFileLine(t, "carol", "singer", "")
+
+	users, err := parseUsersFile([]byte("# dashboard users\n\n" + alice + "\n" + bob + "\n" + strings.TrimSuffix(carol, ":") + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 3 {
+		t.Fatalf("users = %v, want alice, bob and carol", users)
+	}
+	if got := users["alice"].Groups; len(got) != 1 || got[0] != "admin" {
+		t.Errorf("alice's groups = %v, want [admin]", got)
+	}
+	if got := users["bob"].Groups; len(got) != 2 || got[0] != "media" || got[1] != "family" {
+		t.Errorf("bob's groups = %v, want [media family]", got)
+	}
+	if got := users["carol"].Groups; len(got) != 0 {
+		t.Errorf("carol's groups = %v, want none", got)
+	}
+
+	for name, data := range map[string]string{
+		"no hash":         "alice\n",
+		"no user":         ":" + strings.TrimPrefix(alice, "alice:") + "\n",
+		"plain password":  "alice:wonderland:admin\n",
+		"listed twice":    alice + "\n" + alice + "\n",
+		"truncated hash":  alice[:20] + "\n",
+		"htpasswd md5":    "alice:$apr1$Kf3oVg0Q$0Yh0pXx7u5vZ8S1m6XbBx/\n",
+		"second line bad": alice + "\nbob\n",
+	} {
+		if _, err := parseUsersFile([]byte(data)); err == nil {
+			t.Errorf("%s: parsed, want an error", name)
+		}
+	}
+	if _, err := parseUsersFile([]byte(alice + "\nbob\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("err = %v, want it to name line 2", err)
+	}
+}
+
+func TestUsersFile_Authenticate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	writeUsersFile(t, path, usersFileLine(t, "alice", "wonderland", "admin,media"))
+	f, err := LoadUsersFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, ok := f.Authenticate("alice", "wonderland")
+	if !ok || user.Name != "alice" || len(user.Groups) != 2 {
+		t.Errorf("Authenticate(alice, right password) = %+v, %v", user, ok)
+	}
+	if _, ok := f.Authenticate("alice", "Wonderland"); ok {
+		t.Error("alice logged in with the wrong password")
+	}
+	if _, ok := f.Authenticate("mallory", "wonderland"); ok {
+		t.Error("unknown user logged in")
+	}
+	if !f.Has("alice") || f.Has("mallory") {
+		t.Errorf("Has(alice), Has(mallory) = %v, %v; want true, false", f.Has("alice"), f.Has("mallory"))
+	}
+}
+
+func TestUsersFile_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	writeUsersFile(t, path, usersFileLine(t, "alice", "wonderland", "admin"))
+	f, err := LoadUsersFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	f.now = func() time.Time { return now }
+	f.Has("alice")
+
+	writeUsersFile(t, path, usersFileLine(t, "alice", "looking-glass", "admin"), usersFileLine(t, "bob", "builder", "media"))
+
+	// The file isn't checked again until the interval passed
+	if f.Has("bob") {
+		t.Error("bob added before the file was checked again")
+	}
+	now = now.Add(usersFileCheckInterval)
+	if !f.Has("bob") {
+		t.Error("bob not added after the file changed")
+	}
+	if _, ok := f.Authenticate("alice", "looking-glass"); !ok {
+		t.Error("alice's new password refused")
+	}
+
+	// Broken contents keep the users read before
+	writeUsersFile(t, path, "bob:not-a-hash:media")
+	now = now.Add(usersFileCheckInterval)
+	if _, ok := f.Authenticate("bob", "builder"); !ok {
+		t.Error("bob dropped after the file was broken")
+	}
+
+	// So does a deleted file
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(usersFileCheckInterval)
+	if !f.Has("alice") {
+		t.Error("alice dropped after the file was deleted")
+	}
+}
+
+func TestLoadUsersFile_Errors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := LoadUsersFile(filepath.Join(dir, "missing")); err == nil {
+		t.Error("loaded a missing file")
+	}
+	path := filepath.Join(dir, "users")
+	writeUsersFile(t, path, "alice:wonderland")
+	if _, err := LoadUsersFile(path); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("err = %v, want one naming the file", err)
+	}
+}
+
+func TestNewProvider_UsersFileError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing")
+	_, err := NewProvider(context.Background(), &config.OIDCConfig{ServiceURL: "https://dashboard.example.com"}, &config.LocalConfig{UsersFile: path})
+	if !errors.Is(err, ErrUsersFile) || !strings.Contains(err.Error(), path) {
+		t.Errorf("err = %v, want ErrUsersFile naming the file", err)
+	}
+}
+
+func TestLoadUsersFile_WarnsOfPermissions(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	path := filepath.Join(t.TempDir(), "users")
+	writeUsersFile(t, path, usersFileLine(t, "alice", "wonderland", "admin"))
+	if _, err := LoadUsersFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(logs.String(), "chmod 600") {
+		t.Errorf("warned of a private file: %s", logs.String())
+	}
+
+	if err := os.Chmod(path, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadUsersFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logs.String(), "chmod 600") {
+		t.Errorf("no warning of a world-readable file: %s", logs.String())
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	line, err := HashPassword("alice", "wonderland", []string{"admin", "media"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := parseUsersFile([]byte(line))
+	if err != nil {
+		t.Fatalf("hash-password line %q doesn't parse: %v", line, err)
+	}
+	alice := users["alice"]
+	if bcrypt.CompareHashAndPassword(alice.hash, []byte("wonderland")) != nil {
+		t.Error("hash doesn't match the password")
+	}
+	if len(alice.Groups) != 2 || alice.Groups[0] != "admin" || alice.Groups[1] != "media" {
+		t.Errorf("groups = %v, want [admin media]", alice.Groups)
+	}
+
+	for _, bad := range []struct{ user, group string }{{"", "admin"}, {"al:ice", "admin"}, {"alice", "ad,min"}, {"alice", "ad:min"}} {
+		if _, err := HashPassword(bad.user, "wonderland", []string{bad.group}); err == nil {
+			t.Errorf("HashPassword(%q, groups %q) succeeded, want an error", bad.user, bad.group)
+		}
+	}
+}
+
+// usersFileProvider returns a provider whose local users are alice, an
+// admin, bob, who may see jellyfin on nas through the media group, and eve,
+// who is in no configured group.
+func usersFileProvider(t *testing.T) *Provider {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "users")
+	writeUsersFile(t, path,
+		usersFileLine(t, "alice", "wonderland", "admin"),
+		usersFileLine(t, "bob", "builder", "media"),
+		usersFileLine(t, "eve", "listener", "strangers"),
+	)
+	usersFile, err := LoadUsersFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Provider{
+		serviceURLHost: "dashboard.example.com",
+		sessions:       NewSessionStore(100),
+		adminGroup:     "admin",
+		usersFile:      usersFile,
+		loginFailures:  newLoginLimiter(),
+		groupConfigs: map[string]*config.OIDCGroupConfig{
+			"media": {Services: map[string][]config.GroupService{"nas": {{Name: "jellyfin"}}}},
+		},
+	}
+}
+
+// localLogin sends a local request to p with Basic Auth credentials,
+// returning the response and the user the request reached the handler as.
+func localLogin(p *Provider, username, password string) (*httptest.ResponseRecorder, *User) {
+	var got *User
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = GetUserFromContext(r.Context())
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "192.168.1.8:9001"
+	req.SetBasicAuth(username, password)
+	w := httptest.NewRecorder()
+	p.Middleware(next).ServeHTTP(w, req)
+	return w, got
+}
+
+func TestHandleLocalAuth_UsersFile(t *testing.T) {
+	withoutPAM(t)
+	p := usersFileProvider(t)
+
+	w, alice := localLogin(p, "alice", "wonderland")
+	if alice == nil {
+		t.Fatalf("alice not logged in: status %d", w.Code)
+	}
+	if !alice.IsAdmin || !alice.HasGlobalAccess || alice.ID != "local:alice" {
+		t.Errorf("alice = %+v, want a local admin", alice)
+	}
+	if len(w.Result().Cookies()) == 0 {
+		t.Error("no session cookie set")
+	}
+
+	w, bob := localLogin(p, "bob", "builder")
+	if bob == nil {
+		t.Fatalf("bob not logged in: status %d", w.Code)
+	}
+	if bob.IsAdmin || bob.HasGlobalAccess {
+		t.Errorf("bob = %+v, want no admin rights", bob)
+	}
+	if !bob.CanAccessService("nas", "jellyfin") || bob.CanAccessService("nas", "vaultwarden") {
+		t.Errorf("bob's services = %v, want just jellyfin on nas", bob.AllowedServices)
+	}
+	if len(bob.Groups) != 2 || bob.Groups[0] != "local" || bob.Groups[1] != "media" {
+		t.Errorf("bob's groups = %v, want [local media]", bob.Groups)
+	}
+
+	// Users without any access are refused like wrong passwords
+	for _, creds := range [][2]string{{"alice", "builder"}, {"eve", "listener"}, {"mallory", "wonderland"}} {
+		w, user := localLogin(p, creds[0], creds[1])
+		if user != nil || w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status %d, user %+v; want 401", creds[0], w.Code, user)
+		}
+	}
+}
+
+func TestHandleLocalAuth_UsersFileNoPAMFallback(t *testing.T) {
+	p := usersFileProvider(t)
+	p.localAdmins = map[string]bool{"alice": true}
+
+	// alice is in the users file, so PAM never checks her password
+	originalPAM := pamAvailable
+	pamAvailable = true
+	t.Cleanup(func() { pamAvailable = originalPAM })
+	if w, user := localLogin(p, "alice", "system-password"); user != nil || w.Code != http.StatusUnauthorized {
+		t.Errorf("status %d, user %+v; want the file's password only", w.Code, user)
+	}
+}
+
+func TestHandleLocalAuth_RateLimited(t *testing.T) {
+	withoutPAM(t)
+	p := usersFileProvider(t)
+
+	for i := 0; i < maxLoginFailures; i++ {
+		if w, _ := localLogin(p, "alice", "guess"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("failure %d: status %d, want 401", i+1, w.Code)
+		}
+	}
+
+	// Even the right password is refused until the window has passed
+	w, user := localLogin(p, "alice", "wonderland")
+	if user != nil || w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status %d, user %+v; want 429", w.Code, user)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("no Retry-After header")
+	}
+
+	later := time.Now().Add(loginFailureWindow)
+	p.loginFailures.now = func() time.Time { return later }
+	if w, user := localLogin(p, "alice", "wonderland"); user == nil {
+		t.Errorf("status %d after the window passed, want a login", w.Code)
+	}
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// composeRootsTTL is how long the expansion of docker_compose_roots_glob is
+// reused before the patterns are matched again.
+const composeRootsTTL = time.Minute
+
+// ComposeFileNames are the file names compose looks for in a project
+// directory, in the order it tries them.
+var ComposeFileNames = []string{"docker-compose.yml", "docker-compose.yaml", "compose.yml", "compose.yaml"}
+
+// HasComposeFile returns true if dir contains a compose file.
+func HasComposeFile(dir string) bool {
+	for _, name := range ComposeFileNames {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeProjectName returns name as compose turns it into a project name:
+// lowercased, with every character other than a-z, 0-9, "_" and "-"
+// dropped, and leading "_" and "-" trimmed. A directory "Media-Stack" is the
+// project "media-stack", and "My Stack!" is "mystack". Project names from
+// labels and requests should be compared in this form.
+func NormalizeProjectName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			b.WriteRune(r)
+		}
+	}
+	return strings.TrimLeft(b.String(), "_-")
+}
+
+// ComposeProjectName returns the name of the compose project in dir: the
+// top-level name key of its compose file if it sets one, and otherwise the
+// name of the directory, normalized as compose does.
+func ComposeProjectName(dir string) string {
+	for _, file := range ComposeFileNames {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			continue
+		}
+		var project struct {
+			Name string `yaml:"name"`
+		}
+		if yaml.Unmarshal(data, &project) == nil && project.Name != "" {
+			return NormalizeProjectName(project.Name)
+		}
+		break // compose only reads the first file it finds
+	}
+	return NormalizeProjectName(filepath.Base(dir))
+}
+
+// composeRoots caches the expansion of a host's docker_compose_roots_glob.
+// It is shared by the copies of the host's config.
+type composeRoots struct {
+	debug bool
+	now   func() time.Time
+
+	mu       sync.Mutex
+	expanded []string
+	expires  time.Time
+}
+
+// ComposeRoots returns the host's effective compose roots: its
+// docker_compose_roots followed by the directories matching its
+// docker_compose_roots_glob that contain a compose file. The matches are
+// expanded when the config is loaded and again once they are older than
+// composeRootsTTL, so a newly created stack is picked up without a restart.
+func (h *HostConfig) ComposeRoots() []string {
+	if h == nil {
+		return nil
+	}
+	if len(h.DockerComposeRootsGlob) == 0 {
+		return h.DockerComposeRoots
+	}
+
+	var expanded []string
+	if c := h.composeRoots; c != nil {
+		c.mu.Lock()
+		if now := c.now(); !now.Before(c.expires) {
+			c.expanded = expandComposeGlobs(h.DockerComposeRootsGlob, c.debug)
+			c.expires = now.Add(composeRootsTTL)
+		}
+		expanded = c.expanded
+		c.mu.Unlock()
+	} else {
+		expanded = expandComposeGlobs(h.DockerComposeRootsGlob, false)
+	}
+
+	roots := slices.Clone(h.DockerComposeRoots)
+	for _, dir := range expanded {
+		if !slices.Contains(roots, dir) {
+			roots = append(roots, dir)
+		}
+	}
+	return roots
+}
+
+// expandComposeGlobs returns the directories matching patterns that contain
+// a compose file, sorted and without duplicates. Other matches are skipped,
+// and logged with debug set.
+func expandComposeGlobs(patterns []string, debug bool) []string {
+	var dirs []string
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern) // Patterns are checked by Validate
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			switch {
+			case err != nil || !info.IsDir():
+				if debug {
+					log.Printf("Debug: compose root %s (from %s) skipped: not a directory", match, pattern)
+				}
+			case !HasComposeFile(match):
+				if debug {
+					log.Printf("Debug: compose root %s (from %s) skipped: no compose file", match, pattern)
+				}
+			default:
+				dirs = append(dirs, match)
+			}
+		}
+	}
+	slices.Sort(dirs)
+	return slices.Compact(dirs)
+}
+
+// validateComposeGlobs checks that every docker_compose_roots_glob pattern
+// is well-formed.
+func (c *Config) validateComposeGlobs() error {
+	var errs []error
+	for _, host := range c.Hosts {
+		for _, pattern := range host.DockerComposeRootsGlob {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				errs = append(errs, fmt.Errorf("host %q: invalid docker_compose_roots_glob %q: %w", host.Name, pattern, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
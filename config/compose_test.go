@@ -0,0 +1,166 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// makeStacks creates a directory under root for each name, with a compose
+// file unless the name ends in "-empty".
+func makeStacks(t *testing.T, root string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasSuffix(name, "-empty") {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, "compose.yaml"), []byte("services: {}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestComposeRoots_Glob(t *testing.T) {
+	apps := t.TempDir()
+	makeStacks(t, apps, "media", "paperless", "notes-empty")
+	// A file matching the pattern isn't a root
+	if err := os.WriteFile(filepath.Join(apps, "README"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	host := &HostConfig{
+		Name:                   "nas",
+		DockerComposeRoots:     []string{"/opt/stacks", filepath.Join(apps, "media")},
+		DockerComposeRootsGlob: []string{filepath.Join(apps, "*"), filepath.Join(apps, "pap*")},
+	}
+	want := []string{"/opt/stacks", filepath.Join(apps, "media"), filepath.Join(apps, "paperless")}
+	if got := host.ComposeRoots(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ComposeRoots() = %q, want %q", got, want)
+	}
+
+	// Without a glob the configured roots are used as they are
+	host.DockerComposeRootsGlob = nil
+	if got := host.ComposeRoots(); !reflect.DeepEqual(got, host.DockerComposeRoots) {
+		t.Errorf("ComposeRoots() without a glob = %q", got)
+	}
+
+	var nilHost *HostConfig
+	if nilHost.ComposeRoots() != nil {
+		t.Error("ComposeRoots() of a nil host should be nil")
+	}
+}
+
+func TestComposeRoots_Reexpanded(t *testing.T) {
+	apps := t.TempDir()
+	makeStacks(t, apps, "media")
+
+	configPath := filepath.Join(t.TempDir(), "services.json")
+	content := fmt.Sprintf(`{"hosts": [{"name": "nas", "address": "localhost", "docker_compose_roots_glob": [%q]}]}`, filepath.Join(apps, "*"))
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	host := &cfg.Hosts[0]
+	clock := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	host.composeRoots.now = func() time.Time { return clock }
+	host.composeRoots.expires = clock.Add(composeRootsTTL)
+
+	makeStacks(t, apps, "paperless")
+	if got := host.ComposeRoots(); len(got) != 1 {
+		t.Errorf("ComposeRoots() within the TTL = %q, want the expansion from Load", got)
+	}
+
+	// Copies of the host share the cache
+	copied := cfg.Hosts[0]
+	clock = clock.Add(composeRootsTTL)
+	want := []string{filepath.Join(apps, "media"), filepath.Join(apps, "paperless")}
+	if got := copied.ComposeRoots(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ComposeRoots() after the TTL = %q, want %q", got, want)
+	}
+
+	// Loading the config again expands the patterns again
+	makeStacks(t, apps, "wiki")
+	cfg, err = Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := cfg.Hosts[0].ComposeRoots(); len(got) != 3 {
+		t.Errorf("ComposeRoots() after reloading = %q, want 3 roots", got)
+	}
+}
+
+func TestValidateComposeGlobs(t *testing.T) {
+	cfg := &Config{Hosts: []HostConfig{{Name: "nas", Address: "localhost", DockerComposeRootsGlob: []string{"/srv/apps/[a-"}}}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "docker_compose_roots_glob") {
+		t.Errorf("Validate() = %v, want an error about the pattern", err)
+	}
+	cfg.Hosts[0].DockerComposeRootsGlob = []string{"/srv/apps/*"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+}
+
+// TestNormalizeProjectName checks the project names compose derives from
+// directory names, as `docker compose config` reports them.
+func TestNormalizeProjectName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"media", "media"},
+		{"Media-Stack", "media-stack"},
+		{"MEDIA", "media"},
+		{"my stack", "mystack"},
+		{"home.lab", "homelab"},
+		{"media_stack", "media_stack"},
+		{"123app", "123app"},
+		{"2024-backup", "2024-backup"},
+		{"_private", "private"},
+		{"--media", "media"},
+		{"-_-9lives", "9lives"},
+		{"Café", "caf"},
+		{"Über Stack", "berstack"},
+		{"日本", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeProjectName(tt.name); got != tt.want {
+			t.Errorf("NormalizeProjectName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestComposeProjectName(t *testing.T) {
+	root := t.TempDir()
+	makeStacks(t, root, "Media-Stack", "downloads-empty")
+
+	if got := ComposeProjectName(filepath.Join(root, "Media-Stack")); got != "media-stack" {
+		t.Errorf("directory name: got %q, want %q", got, "media-stack")
+	}
+	if got := ComposeProjectName(filepath.Join(root, "downloads-empty")); got != "downloads-empty" {
+		t.Errorf("without a compose file: got %q, want %q", got, "downloads-empty")
+	}
+
+	// The name key of the compose file wins over the directory
+	named := filepath.Join(root, "stack")
+	if err := os.Mkdir(named, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(named, "docker-compose.yml"), []byte("name: arr-suite\nservices: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := ComposeProjectName(named); got != "arr-suite" {
+		t.Errorf("name key: got %q, want %q", got, "arr-suite")
+	}
+}
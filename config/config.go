@@ -3,21 +3,29 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"net/netip"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/tailscale/hujson"
+
+	"home_server_dashboard/redact"
 )
 
 // OIDCGroupConfig defines the services a group can access.
 type OIDCGroupConfig struct {
-	// Services maps host names to lists of service names the group can access.
-	// Service names can be Docker service names or systemd unit names.
-	Services map[string][]string `json:"services"`
+	// Services maps host names to lists of services the group can access.
+	// Service names can be Docker service names or systemd unit names, each
+	// with every permission or only some of them.
+	Services map[string][]GroupService `json:"services"`
 }
 
 // OIDCConfig holds OpenID Connect authentication settings.
@@ -40,6 +48,30 @@ type OIDCConfig struct {
 	// Users who are members of these groups will have access to the specified services.
 	// Group permissions are additive - a user in multiple groups gets access to all services.
 	Groups map[string]*OIDCGroupConfig `json:"groups,omitempty"`
+	// MaxSessions is how many login sessions are kept; past it, the oldest
+	// session is logged out (default 1000).
+	MaxSessions int `json:"max_sessions,omitempty"`
+	// MaxLoginStates is how many OIDC logins may be in progress; past it,
+	// the oldest can no longer complete (default 10000).
+	MaxLoginStates int `json:"max_login_states,omitempty"`
+}
+
+// GetMaxSessions returns how many login sessions are kept.
+// Returns 1000 if not specified. Safe to call on a nil OIDCConfig.
+func (o *OIDCConfig) GetMaxSessions() int {
+	if o == nil || o.MaxSessions <= 0 {
+		return 1000
+	}
+	return o.MaxSessions
+}
+
+// GetMaxLoginStates returns how many OIDC logins may be in progress.
+// Returns 10000 if not specified. Safe to call on a nil OIDCConfig.
+func (o *OIDCConfig) GetMaxLoginStates() int {
+	if o == nil || o.MaxLoginStates <= 0 {
+		return 10000
+	}
+	return o.MaxLoginStates
 }
 
 // TraefikConfig holds Traefik API connection settings for a host.
@@ -48,10 +80,16 @@ type TraefikConfig struct {
 	Enabled bool `json:"enabled"`
 	// APIPort is the port where Traefik API is listening (default 8080).
 	APIPort int `json:"api_port"`
+	// Service is the service running Traefik on this host, which the
+	// dashboard itself may be served through (default "traefik").
+	Service string `json:"service,omitempty"`
 }
 
 // HomeAssistantConfig holds Home Assistant API connection settings for a host.
 type HomeAssistantConfig struct {
+	// Enabled turns collection from Home Assistant off when false, keeping
+	// the rest of the settings (default true).
+	Enabled *bool `json:"enabled,omitempty"`
 	// Port is the Home Assistant API port (default 8123).
 	Port int `json:"port"`
 	// UseHTTPS determines whether to use HTTPS for the API connection.
@@ -67,6 +105,10 @@ type HomeAssistantConfig struct {
 	// Used to tunnel Supervisor API requests through SSH.
 	// The SUPERVISOR_TOKEN environment variable must be set on the dashboard host.
 	SSHAddonPort int `json:"ssh_addon_port,omitempty"`
+	// ContainerName is the Docker container Home Assistant runs in (Container
+	// and Supervised installs). On the local host, its logs and core
+	// start/stop/restart go through Docker when the Supervisor API isn't available.
+	ContainerName string `json:"container_name,omitempty"`
 }
 
 // SSHConfig holds SSH connection settings for remote hosts.
@@ -77,6 +119,9 @@ type SSHConfig struct {
 	// Port is the SSH port to use when connecting to remote hosts.
 	// If 0, the default SSH port (22) is used.
 	Port int `json:"port,omitempty"`
+	// ConnectTimeout is how long (in seconds) to wait for the SSH connection
+	// to be established (default 5).
+	ConnectTimeout int `json:"connect_timeout,omitempty"`
 }
 
 // WatchtowerConfig holds Watchtower API connection settings for a host.
@@ -84,6 +129,9 @@ type SSHConfig struct {
 // When configured, the dashboard will suppress false-positive service down notifications
 // during container updates, only alerting if a container doesn't recover within the timeout.
 type WatchtowerConfig struct {
+	// Enabled turns the Watchtower integration off when false, keeping the
+	// rest of the settings (default true).
+	Enabled *bool `json:"enabled,omitempty"`
 	// Port is the Watchtower HTTP API port (default 8080).
 	Port int `json:"port"`
 	// Token is the Bearer token for authenticating with the Watchtower API.
@@ -94,6 +142,113 @@ type WatchtowerConfig struct {
 	UpdateTimeout int `json:"update_timeout,omitempty"`
 }
 
+// StatsConfig holds the container stats sampling settings of a host.
+type StatsConfig struct {
+	// Enabled turns on sampling the CPU and memory use of running compose
+	// containers for the sparklines in the dashboard.
+	Enabled bool `json:"enabled"`
+	// Interval is how often (in seconds) containers are sampled (default 30).
+	Interval int `json:"interval,omitempty"`
+}
+
+// SelfMonitorConfig sets when the dashboard alerts about its own resource
+// use, which it samples every minute.
+type SelfMonitorConfig struct {
+	// MaxGoroutines alerts when the dashboard runs more goroutines (default
+	// 1000, negative disables).
+	MaxGoroutines int `json:"max_goroutines,omitempty"`
+	// MaxOpenFDs alerts when the dashboard has more files and sockets open
+	// (default 1000, negative disables).
+	MaxOpenFDs int `json:"max_open_fds,omitempty"`
+	// TrendWindow is how many minutes goroutines or open files may grow
+	// steadily before it is alerted (default 60, negative disables).
+	TrendWindow int `json:"trend_window,omitempty"`
+}
+
+// GetMaxGoroutines returns the goroutine count alerted above, or 0 if it
+// isn't alerted. Safe to call on a nil SelfMonitorConfig.
+func (s *SelfMonitorConfig) GetMaxGoroutines() int {
+	if s == nil || s.MaxGoroutines == 0 {
+		return 1000
+	}
+	return max(s.MaxGoroutines, 0)
+}
+
+// GetMaxOpenFDs returns the open file count alerted above, or 0 if it isn't
+// alerted. Safe to call on a nil SelfMonitorConfig.
+func (s *SelfMonitorConfig) GetMaxOpenFDs() int {
+	if s == nil || s.MaxOpenFDs == 0 {
+		return 1000
+	}
+	return max(s.MaxOpenFDs, 0)
+}
+
+// GetTrendWindow returns how long steady growth is alerted after, or 0 if
+// it isn't. Safe to call on a nil SelfMonitorConfig.
+func (s *SelfMonitorConfig) GetTrendWindow() time.Duration {
+	if s == nil || s.TrendWindow == 0 {
+		return time.Hour
+	}
+	return time.Duration(max(s.TrendWindow, 0)) * time.Minute
+}
+
+// NotificationDigestConfig sets how bursts of notifications, such as every
+// service going down when the network drops, are combined into digests.
+type NotificationDigestConfig struct {
+	// Window is how many seconds events are held back to be grouped with
+	// others of the same kind (default 30, negative disables digests).
+	Window int `json:"window,omitempty"`
+	// Threshold is how many events of a kind a window may hold before they
+	// are sent as one digest instead of one by one (default 3).
+	Threshold int `json:"threshold,omitempty"`
+}
+
+// GetWindow returns how long events are held back for digests, or 0 if
+// they are sent straight away. Safe to call on a nil NotificationDigestConfig.
+func (n *NotificationDigestConfig) GetWindow() time.Duration {
+	if n == nil || n.Window == 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(max(n.Window, 0)) * time.Second
+}
+
+// GetThreshold returns how many events of a kind are sent one by one; more
+// are sent as a digest. Safe to call on a nil NotificationDigestConfig.
+func (n *NotificationDigestConfig) GetThreshold() int {
+	if n == nil || n.Threshold <= 0 {
+		return 3
+	}
+	return n.Threshold
+}
+
+// EventExportConfig mirrors the dashboard's events to other processes.
+type EventExportConfig struct {
+	// Socket is the path of a Unix socket on which every connected client
+	// gets the events as newline-delimited JSON.
+	Socket string `json:"socket,omitempty"`
+	// SocketMode is the socket's permissions, in octal (default "0600").
+	SocketMode string `json:"socket_mode,omitempty"`
+}
+
+// GetSocketMode returns the permissions the event socket is created with.
+// Safe to call on a nil EventExportConfig.
+func (e *EventExportConfig) GetSocketMode() (os.FileMode, error) {
+	if e == nil || e.SocketMode == "" {
+		return 0o600, nil
+	}
+	mode, err := strconv.ParseUint(e.SocketMode, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid event_export socket_mode %q: want octal permissions such as \"0660\"", e.SocketMode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// IntegrationToggle turns an integration of a host on or off. A nil toggle
+// or one without enabled leaves the integration on.
+type IntegrationToggle struct {
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
 // HostConfig represents a single host's configuration.
 type HostConfig struct {
 	Name               string               `json:"name"`
@@ -105,122 +260,127 @@ type HostConfig struct {
 	Traefik            TraefikConfig        `json:"traefik"`
 	HomeAssistant      *HomeAssistantConfig `json:"homeassistant,omitempty"`
 	Watchtower         *WatchtowerConfig    `json:"watchtower,omitempty"`
+	// DockerComposeRootsGlob adds every directory matching one of these
+	// patterns (e.g., "/srv/apps/*") that contains a compose file to the
+	// compose roots (see ComposeRoots).
+	DockerComposeRootsGlob []string `json:"docker_compose_roots_glob,omitempty"`
+	// Enabled turns all collection from this host off when false, without
+	// removing its configuration (default true).
+	Enabled *bool `json:"enabled,omitempty"`
+	// Docker turns collection of Docker containers on this host off when
+	// docker.enabled is false.
+	Docker *IntegrationToggle `json:"docker,omitempty"`
+	// Systemd turns collection of systemd_services on this host off when
+	// systemd.enabled is false.
+	Systemd *IntegrationToggle `json:"systemd,omitempty"`
+	// Stats turns on sampling container CPU and memory use. Only the local
+	// host's Docker containers are sampled.
+	Stats *StatsConfig `json:"stats,omitempty"`
+	// PollInterval is how often (in seconds) the monitor polls this host,
+	// overriding the global poll_interval.
+	PollInterval int `json:"poll_interval,omitempty"`
+	// Links are static links shown for this host.
+	Links []LinkConfig `json:"links,omitempty"`
+	// Demo adds synthetic services to this host (see the demo provider).
+	Demo bool `json:"demo,omitempty"`
+	// JournalAccess is how journalctl gets read access to the system journal:
+	// "group" (default) expects the dashboard or SSH user to be in the
+	// systemd-journal group, "sudo" runs journalctl through passwordless sudo.
+	JournalAccess string `json:"journal_access,omitempty"`
+	// SystemdFailureDetails adds why each failed unit failed to the service
+	// list, which costs a systemctl show and a journalctl per failed unit.
+	SystemdFailureDetails bool `json:"systemd_failure_details,omitempty"`
+	// Outbound overrides the global outbound settings for the APIs of this
+	// host.
+	Outbound *OutboundConfig `json:"outbound,omitempty"`
+
+	// specs caches the parsed SystemdServices, set by Load.
+	specs []ServiceSpec
+	// composeRoots caches the expansion of DockerComposeRootsGlob, set by Load.
+	composeRoots *composeRoots
 }
 
-// SystemdServiceEntry represents a parsed systemd service entry with optional flags.
-type SystemdServiceEntry struct {
-	// Name is the unit name (e.g., "docker.service")
-	Name string
-	// User is the username for user-level systemd services (empty for system services)
-	// When set, the service is managed via `systemctl --user` instead of system D-Bus.
-	User string
-	// ReadOnly if true, disables start/stop/restart actions for ALL users
-	ReadOnly bool
-	// Ports are the port numbers advertised for this service in the UI
-	Ports []uint16
-}
-
-// GetSystemdServiceEntries parses the SystemdServices list and returns entries with flags.
-// Service names support the following formats:
-//   - "servicename.service" - system service
-//   - "servicename.service:ro" - system service, read-only
-//   - "servicename.service#8080" - system service exposing port 8080
-//   - "servicename.service#8080,8443" - system service exposing multiple ports
-//   - "servicename.service#8080:ro" - read-only service with ports
-//   - "username:servicename.service" - user service (managed via systemctl --user)
-//   - "username:servicename.service:ro" - user service, read-only
-//   - "username:servicename.service#8080" - user service with ports
-//   - "username:servicename.service#8080,8443:ro" - user service with ports, read-only
-//
-// Examples:
-//   - "docker.service" returns {Name: "docker.service", User: "", ReadOnly: false}
-//   - "nas-dashboard.service:ro" returns {Name: "nas-dashboard.service", User: "", ReadOnly: true}
-//   - "xero:zunesync.service" returns {Name: "zunesync.service", User: "xero", ReadOnly: false}
-//   - "xero:zunesync.service:ro" returns {Name: "zunesync.service", User: "xero", ReadOnly: true}
-func (h *HostConfig) GetSystemdServiceEntries() []SystemdServiceEntry {
-	entries := make([]SystemdServiceEntry, 0, len(h.SystemdServices))
-	for _, svc := range h.SystemdServices {
-		entry := ParseSystemdServiceEntry(svc)
-		entries = append(entries, entry)
-	}
-	return entries
-}
-
-// GetSystemdServiceNames returns just the service names without any flags.
-// This is for backwards compatibility with code that just needs the unit names.
-func (h *HostConfig) GetSystemdServiceNames() []string {
-	names := make([]string, 0, len(h.SystemdServices))
-	for _, svc := range h.SystemdServices {
-		entry := ParseSystemdServiceEntry(svc)
-		names = append(names, entry.Name)
-	}
-	return names
-}
-
-// ParseSystemdServiceEntry parses a service entry string into a SystemdServiceEntry.
-// Recognizes the following formats:
-//   - "servicename.service" - system service
-//   - "servicename.service:ro" - system service, read-only
-//   - "servicename.service#8080" - system service with port
-//   - "servicename.service#8080,8443" - system service with multiple ports
-//   - "servicename.service#8080:ro" - read-only service with port
-//   - "username:servicename.service" - user service
-//   - "username:servicename.service:ro" - user service, read-only
-//   - "username:servicename.service#8080" - user service with port
-//   - "username:servicename.service#8080,8443:ro" - user service with ports, read-only
-//
-// The parser strips suffixes in order: ":ro", then "#ports", then checks for "username:" prefix.
-// A username prefix is identified by finding a colon before a dot (systemd units always
-// have an extension like .service, .timer, .socket, etc.).
-func ParseSystemdServiceEntry(entry string) SystemdServiceEntry {
-	result := SystemdServiceEntry{}
-
-	// Check for :ro suffix first
-	if strings.HasSuffix(entry, ":ro") {
-		entry = strings.TrimSuffix(entry, ":ro")
-		result.ReadOnly = true
-	}
-
-	// Check for #ports suffix (comma-separated port numbers)
-	if hashIdx := strings.LastIndex(entry, "#"); hashIdx > 0 {
-		portStr := entry[hashIdx+1:]
-		entry = entry[:hashIdx]
-
-		// Parse comma-separated port numbers
-		portParts := strings.Split(portStr, ",")
-		for _, portPart := range portParts {
-			portPart = strings.TrimSpace(portPart)
-			if portPart == "" {
-				continue
-			}
-			var port uint64
-			if _, err := fmt.Sscanf(portPart, "%d", &port); err == nil && port > 0 && port <= 65535 {
-				result.Ports = append(result.Ports, uint16(port))
-			}
-		}
+// GetPollInterval returns how often the monitor polls this host, or fallback
+// if the host doesn't set its own interval.
+func (h *HostConfig) GetPollInterval(fallback time.Duration) time.Duration {
+	if h.PollInterval <= 0 {
+		return fallback
 	}
+	return time.Duration(h.PollInterval) * time.Second
+}
 
-	// Check for username:servicename format
-	// A username prefix exists if there's a colon before any dot
-	// (since systemd unit names must have an extension like .service)
-	colonIdx := strings.Index(entry, ":")
-	dotIdx := strings.Index(entry, ".")
+// Journal access methods for HostConfig.JournalAccess.
+const (
+	JournalAccessGroup = "group"
+	JournalAccessSudo  = "sudo"
+)
 
-	if colonIdx > 0 && dotIdx > colonIdx {
-		// Format is "username:servicename.unit"
-		result.User = entry[:colonIdx]
-		result.Name = entry[colonIdx+1:]
-	} else {
-		// No user prefix, just the service name
-		result.Name = entry
+// GetJournalAccess returns how journalctl reads the system journal on this
+// host, defaulting to JournalAccessGroup.
+func (h *HostConfig) GetJournalAccess() string {
+	if h == nil || h.JournalAccess == "" {
+		return JournalAccessGroup
 	}
+	return h.JournalAccess
+}
+
+// enabled reads an optional enabled flag, which defaults to true.
+func enabled(flag *bool) bool {
+	return flag == nil || *flag
+}
 
-	return result
+// IsEnabled returns false if collection from this host is turned off.
+func (h *HostConfig) IsEnabled() bool {
+	return h != nil && enabled(h.Enabled)
 }
 
-// HasHomeAssistant returns true if this host has Home Assistant configured.
+// HasDocker returns true unless Docker collection is turned off for this host.
+func (h *HostConfig) HasDocker() bool {
+	return h.IsEnabled() && (h.Docker == nil || enabled(h.Docker.Enabled))
+}
+
+// HasStats returns true if container stats are sampled on this host.
+func (h *HostConfig) HasStats() bool {
+	return h.HasDocker() && h.Stats != nil && h.Stats.Enabled
+}
+
+// GetStatsInterval returns how often container stats are sampled on this
+// host. Returns 30 seconds if not specified.
+func (h *HostConfig) GetStatsInterval() time.Duration {
+	if h == nil || h.Stats == nil || h.Stats.Interval <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(h.Stats.Interval) * time.Second
+}
+
+// HasSystemd returns true if this host has systemd services configured and
+// enabled.
+func (h *HostConfig) HasSystemd() bool {
+	return h.IsEnabled() && len(h.SystemdServices) > 0 && (h.Systemd == nil || enabled(h.Systemd.Enabled))
+}
+
+// HasTraefik returns true if Traefik is enabled for this host.
+func (h *HostConfig) HasTraefik() bool {
+	return h.IsEnabled() && h.Traefik.Enabled
+}
+
+// GetTraefikService returns the name of the service running Traefik on this
+// host, or "" if Traefik isn't enabled.
+func (h *HostConfig) GetTraefikService() string {
+	if !h.HasTraefik() {
+		return ""
+	}
+	if h.Traefik.Service != "" {
+		return h.Traefik.Service
+	}
+	return "traefik"
+}
+
+// HasHomeAssistant returns true if this host has Home Assistant configured
+// and enabled.
 func (h *HostConfig) HasHomeAssistant() bool {
-	return h.HomeAssistant != nil && h.HomeAssistant.LongLivedToken != ""
+	return h.IsEnabled() && h.HomeAssistant != nil && h.HomeAssistant.LongLivedToken != "" &&
+		enabled(h.HomeAssistant.Enabled)
 }
 
 // HasSupervisorAPI returns true if this host has Supervisor API access configured.
@@ -240,9 +400,37 @@ func (h *HostConfig) GetSSHAddonPort() int {
 	return h.HomeAssistant.SSHAddonPort
 }
 
-// HasWatchtower returns true if this host has Watchtower configured.
+// HasWatchtower returns true if this host has Watchtower configured and
+// enabled.
 func (h *HostConfig) HasWatchtower() bool {
-	return h.Watchtower != nil && h.Watchtower.Port > 0
+	return h.IsEnabled() && h.Watchtower != nil && h.Watchtower.Port > 0 && enabled(h.Watchtower.Enabled)
+}
+
+// DisabledIntegrations returns the integrations configured on this host but
+// turned off, e.g. ["docker", "watchtower"], or ["host"] if the whole host
+// is. Docker is only listed when turned off explicitly, as it needs no other
+// configuration.
+func (h *HostConfig) DisabledIntegrations() []string {
+	if h == nil {
+		return nil
+	}
+	if !h.IsEnabled() {
+		return []string{"host"}
+	}
+	var disabled []string
+	if h.Docker != nil && !enabled(h.Docker.Enabled) {
+		disabled = append(disabled, "docker")
+	}
+	if len(h.SystemdServices) > 0 && !h.HasSystemd() {
+		disabled = append(disabled, "systemd")
+	}
+	if h.HomeAssistant != nil && h.HomeAssistant.LongLivedToken != "" && !h.HasHomeAssistant() {
+		disabled = append(disabled, "homeassistant")
+	}
+	if h.Watchtower != nil && h.Watchtower.Port > 0 && !h.HasWatchtower() {
+		disabled = append(disabled, "watchtower")
+	}
+	return disabled
 }
 
 // GetWatchtowerToken returns the Watchtower API token, checking the environment variable first.
@@ -292,6 +480,15 @@ func (h *HostConfig) GetSSHPort() int {
 	return h.SSHConfig.Port
 }
 
+// GetSSHConnectTimeout returns how long to wait for an SSH connection to
+// this host. Returns 5 seconds if not specified.
+func (h *HostConfig) GetSSHConnectTimeout() time.Duration {
+	if h.SSHConfig == nil || h.SSHConfig.ConnectTimeout <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(h.SSHConfig.ConnectTimeout) * time.Second
+}
+
 // GetSSHTarget returns the SSH target string for this host.
 // Format is "user@address" if a custom user is set, otherwise just "address".
 // For use with SSH commands.
@@ -305,7 +502,8 @@ func (h *HostConfig) GetSSHTarget() string {
 // GetSSHArgs returns common SSH arguments including port if configured.
 // Returns arguments suitable for prepending to SSH commands.
 func (h *HostConfig) GetSSHArgs() []string {
-	args := []string{"-o", "ConnectTimeout=5", "-o", "StrictHostKeyChecking=accept-new"}
+	connectTimeout := fmt.Sprintf("ConnectTimeout=%d", int(h.GetSSHConnectTimeout().Seconds()))
+	args := []string{"-o", connectTimeout, "-o", "StrictHostKeyChecking=accept-new"}
 	if port := h.GetSSHPort(); port > 0 {
 		args = append(args, "-p", fmt.Sprintf("%d", port))
 	}
@@ -339,14 +537,7 @@ func isPrivateIP(ip net.IP) bool {
 	if ip == nil {
 		return false
 	}
-	// Check for private IPv4 ranges: 10.0.0.0/8, 172.16.0.0/12, 192.168.0.0/16
-	private := []string{
-		"10.0.0.0/8",
-		"172.16.0.0/12",
-		"192.168.0.0/16",
-	}
-	for _, cidr := range private {
-		_, block, _ := net.ParseCIDR(cidr)
+	for _, block := range privateBlocks {
 		if block.Contains(ip) {
 			return true
 		}
@@ -354,14 +545,25 @@ func isPrivateIP(ip net.IP) bool {
 	return false
 }
 
+// privateBlocks are the private IPv4 ranges (10.0.0.0/8, 172.16.0.0/12,
+// 192.168.0.0/16) and IPv6 unique local addresses (fc00::/7).
+var privateBlocks = func() []*net.IPNet {
+	var blocks []*net.IPNet
+	for _, cidr := range []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"} {
+		_, block, _ := net.ParseCIDR(cidr)
+		blocks = append(blocks, block)
+	}
+	return blocks
+}()
+
 // GetPrivateIP returns the private network IP address for this host.
 // If the host address is already a private IP, it returns that.
 // If NIC interfaces are specified, it tries to get the IP from those interfaces.
 // Returns empty string if no private IP can be determined.
 func (h *HostConfig) GetPrivateIP() string {
 	// First, check if address is already a private IP
-	if ip := net.ParseIP(h.Address); ip != nil && isPrivateIP(ip) {
-		return h.Address
+	if ip := net.ParseIP(unbracket(h.Address)); ip != nil && isPrivateIP(ip) {
+		return ip.String()
 	}
 
 	// Try to get IP from specified NIC interfaces (only works for local host)
@@ -394,10 +596,70 @@ func (h *HostConfig) GetPrivateIP() string {
 	return ""
 }
 
+// GetLinkHost returns the host to use in port links for this host.
+// It prefers the private IP, and otherwise uses a configured DNS name as-is.
+// Returns empty string for localhost and public IPs so the browser's own
+// hostname is used instead.
+func (h *HostConfig) GetLinkHost() string {
+	if ip := h.GetPrivateIP(); ip != "" {
+		return ip
+	}
+	if h.IsLocal() || net.ParseIP(unbracket(h.Address)) != nil {
+		return ""
+	}
+	return h.Address
+}
+
+// unbracket strips the brackets from an IPv6 literal like "[fd00::1]".
+func unbracket(addr string) string {
+	if strings.HasPrefix(addr, "[") && strings.HasSuffix(addr, "]") {
+		return addr[1 : len(addr)-1]
+	}
+	return addr
+}
+
+// validateAddress checks that addr is an IP address (IPv6 may be bracketed)
+// or a hostname. Schemes, ports, and paths are rejected.
+func validateAddress(addr string) error {
+	if addr == "" {
+		return fmt.Errorf("address is empty")
+	}
+	if net.ParseIP(unbracket(addr)) != nil {
+		return nil
+	}
+	if strings.HasPrefix(addr, "[") || strings.HasSuffix(addr, "]") {
+		return fmt.Errorf("%q is not a valid IPv6 address", addr)
+	}
+	if strings.Contains(addr, "://") || strings.ContainsAny(addr, "/:") {
+		return fmt.Errorf("%q must be a bare IP address or hostname (no scheme, port, or path)", addr)
+	}
+	if len(addr) > 253 {
+		return fmt.Errorf("%q is too long for a hostname", addr)
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(addr, "."), ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("%q is not a valid hostname", addr)
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return fmt.Errorf("%q is not a valid hostname", addr)
+			}
+		}
+	}
+	return nil
+}
+
 // LocalConfig holds local authentication settings for non-OIDC access.
 type LocalConfig struct {
 	// Admins is a comma-separated list of local usernames with admin access.
 	Admins string `json:"admins"`
+	// AdminGroup is a system group (e.g., "wheel" or "sudo") whose members have admin access.
+	// Members are checked after PAM authentication; Admins are always allowed as well.
+	AdminGroup string `json:"admin_group,omitempty"`
+	// UsersFile is the path of an htpasswd-style file of dashboard-only users,
+	// one "user:bcrypt-hash:group1,group2" per line, checked instead of PAM for
+	// the users it lists. It is reread when it changes.
+	UsersFile string `json:"users_file,omitempty"`
 }
 
 // GotifyConfig holds Gotify notification settings.
@@ -423,6 +685,98 @@ type Config struct {
 	Gotify *GotifyConfig `json:"gotify,omitempty"`
 	// Port is the HTTP server port (default 9001).
 	Port int `json:"port,omitempty"`
+	// ServicesTimeout is how long (in seconds) each provider may take while
+	// collecting the services list (default 10).
+	ServicesTimeout int `json:"services_timeout,omitempty"`
+	// ServicesPollTimeout is how long (in seconds) GET /api/services/poll holds
+	// a request open waiting for the services to change (default 55).
+	ServicesPollTimeout int `json:"services_poll_timeout,omitempty"`
+	// WarmupTimeout is how long (in seconds) the services collected at startup
+	// are waited for before the dashboard counts as warmed up (default 60).
+	WarmupTimeout int `json:"warmup_timeout,omitempty"`
+	// ReadyAfterWarmup makes /readyz answer 503 until the startup warm-up ends.
+	ReadyAfterWarmup bool `json:"ready_after_warmup,omitempty"`
+	// ActionTimeout is how long (in seconds) a start/stop/restart action may take (default 120).
+	ActionTimeout int `json:"action_timeout,omitempty"`
+	// ComposeTimeout is how long (in seconds) a docker compose down/up restart may take (default 300).
+	ComposeTimeout int `json:"compose_timeout,omitempty"`
+	// CircuitThreshold is how many consecutive failed reads open a remote host's circuit breaker (default 3).
+	CircuitThreshold int `json:"circuit_threshold,omitempty"`
+	// CircuitCooldown is how long (in seconds) an open circuit breaker fails calls fast (default 30).
+	CircuitCooldown int `json:"circuit_cooldown,omitempty"`
+	// SSHMaxSessionsPerHost caps concurrent SSH sessions to each remote host (default 2).
+	SSHMaxSessionsPerHost int `json:"ssh_max_sessions_per_host,omitempty"`
+	// SSHMaxSessions caps concurrent SSH sessions across all hosts (default 8).
+	SSHMaxSessions int `json:"ssh_max_sessions,omitempty"`
+	// SSHMultiplexing runs the SSH commands to each remote host over one shared connection (default true).
+	SSHMultiplexing *bool `json:"ssh_multiplexing,omitempty"`
+	// MaxStreamsPerUser caps the SSE streams (logs, action progress) one user may have open (default 10).
+	MaxStreamsPerUser int `json:"max_streams_per_user,omitempty"`
+	// MaxStreams caps the SSE streams open across all users (default 100).
+	MaxStreams int `json:"max_streams,omitempty"`
+	// DockerRestartDebounce is how long (in seconds) a container may stay down before
+	// its stop is reported; a start within this window is reported as a restart (default 5).
+	DockerRestartDebounce int `json:"docker_restart_debounce,omitempty"`
+	// PollInterval is how often (in seconds) the monitor polls remote hosts and
+	// Home Assistant, unless a host sets its own poll_interval (default 60).
+	PollInterval int `json:"poll_interval,omitempty"`
+	// ServicePruneAfter is how many poll intervals a service may go unreported
+	// before the monitor forgets it, e.g. after its container was deleted (default 5).
+	ServicePruneAfter int `json:"service_prune_after,omitempty"`
+	// ComposeLockWait is how long (in seconds) an operation waits for another
+	// operation on the same compose project to finish (default 60).
+	ComposeLockWait int `json:"compose_lock_wait,omitempty"`
+	// ImageStaleDays is how old (in days) a container's image may be before the
+	// service is flagged as stale (default 180, negative disables).
+	ImageStaleDays int `json:"image_stale_days,omitempty"`
+	// ComposeChangeDetection flags containers whose compose files changed
+	// after they were created, so they need to be brought up again.
+	ComposeChangeDetection bool `json:"compose_change_detection,omitempty"`
+	// LogTail is how many lines of history the log viewer shows when it opens,
+	// unless the service or the request sets its own (default 100).
+	LogTail int `json:"log_tail,omitempty"`
+	// AllowedOrigins lists extra origins (e.g., "https://homepage.example.com") allowed to
+	// make credentialed cross-origin requests. The OIDC service_url origin is always allowed.
+	AllowedOrigins []string `json:"allowed_origins,omitempty"`
+	// TrustedProxies lists the reverse proxy addresses or CIDR ranges (e.g., "172.18.0.0/16")
+	// whose X-Forwarded-For and X-Forwarded-Host headers are honored.
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+	// Debug logs extra detail for diagnosing problems, such as how each
+	// service was matched to Traefik routers.
+	Debug bool `json:"debug,omitempty"`
+	// Links are static links shown alongside services.
+	Links []LinkConfig `json:"links,omitempty"`
+	// ActionHistoryPath is a file the output of recent service actions is saved to,
+	// so it survives restarts. Empty keeps the history in memory only.
+	ActionHistoryPath string `json:"action_history_path,omitempty"`
+	// UsageStatsPath is a file the daily counts of log streams and actions
+	// per service are saved to. Empty keeps them in memory only.
+	UsageStatsPath string `json:"usage_stats_path,omitempty"`
+	// NoticeExpiryDays is how many days a notice for administrators is kept
+	// after it was last posted (default 7).
+	NoticeExpiryDays int `json:"notice_expiry_days,omitempty"`
+	// AdvertiseMDNS advertises the dashboard on the local network with
+	// multicast DNS, as a web server and to other dashboard instances.
+	AdvertiseMDNS bool `json:"advertise_mdns,omitempty"`
+	// LogRedaction masks secrets, such as API keys, in streamed logs.
+	LogRedaction *LogRedactionConfig `json:"log_redaction,omitempty"`
+	// SelfMonitor sets when the dashboard alerts about its own goroutines
+	// and open files.
+	SelfMonitor *SelfMonitorConfig `json:"self_monitor,omitempty"`
+	// Outbound sets the CA file, proxy and certificate checks of connections
+	// to HTTP APIs.
+	Outbound *OutboundConfig `json:"outbound,omitempty"`
+	// NotificationDigest sets how bursts of notifications are combined.
+	NotificationDigest *NotificationDigestConfig `json:"notification_digest,omitempty"`
+	// EventExport mirrors the events to a Unix socket for other processes.
+	EventExport *EventExportConfig `json:"event_export,omitempty"`
+
+	// secretKeys lists the keys merged from the encrypted secrets sidecar.
+	secretKeys []string
+	// linkHosts caches each host's link host by name, set by Load.
+	linkHosts map[string]string
+	// redactor is the compiled log_redaction rules, set by Load.
+	redactor *redact.Redactor
 }
 
 // IsOIDCEnabled returns true if OIDC authentication is configured and enabled.
@@ -438,6 +792,255 @@ func (c *Config) GetPort() int {
 	return c.Port
 }
 
+// GetServicesTimeout returns the per-provider deadline used while collecting services.
+// Returns 10 seconds if not specified. Safe to call on a nil Config.
+func (c *Config) GetServicesTimeout() time.Duration {
+	if c == nil || c.ServicesTimeout <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(c.ServicesTimeout) * time.Second
+}
+
+// GetServicesPollTimeout returns how long a services long-poll waits for a change.
+// Returns 55 seconds if not specified, short of the 60 second idle timeout of
+// common proxies. Safe to call on a nil Config.
+func (c *Config) GetServicesPollTimeout() time.Duration {
+	if c == nil || c.ServicesPollTimeout <= 0 {
+		return 55 * time.Second
+	}
+	return time.Duration(c.ServicesPollTimeout) * time.Second
+}
+
+// GetWarmupTimeout returns how long the startup warm-up may take.
+// Returns 60 seconds if not specified. Safe to call on a nil Config.
+func (c *Config) GetWarmupTimeout() time.Duration {
+	if c == nil || c.WarmupTimeout <= 0 {
+		return 60 * time.Second
+	}
+	return time.Duration(c.WarmupTimeout) * time.Second
+}
+
+// GetActionTimeout returns the deadline for start/stop/restart actions.
+// Returns 120 seconds if not specified. Safe to call on a nil Config.
+func (c *Config) GetActionTimeout() time.Duration {
+	if c == nil || c.ActionTimeout <= 0 {
+		return 120 * time.Second
+	}
+	return time.Duration(c.ActionTimeout) * time.Second
+}
+
+// GetComposeTimeout returns the deadline for docker compose down/up restarts.
+// Compose restarts may pull images and recreate containers, so the default
+// of 300 seconds is longer than the plain action timeout. Safe to call on a nil Config.
+func (c *Config) GetComposeTimeout() time.Duration {
+	if c == nil || c.ComposeTimeout <= 0 {
+		return 300 * time.Second
+	}
+	return time.Duration(c.ComposeTimeout) * time.Second
+}
+
+// GetCircuitThreshold returns how many consecutive failures open a host's circuit breaker.
+// Returns 3 if not specified. Safe to call on a nil Config.
+func (c *Config) GetCircuitThreshold() int {
+	if c == nil || c.CircuitThreshold <= 0 {
+		return 3
+	}
+	return c.CircuitThreshold
+}
+
+// GetCircuitCooldown returns how long an open circuit breaker rejects calls.
+// Returns 30 seconds if not specified. Safe to call on a nil Config.
+func (c *Config) GetCircuitCooldown() time.Duration {
+	if c == nil || c.CircuitCooldown <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.CircuitCooldown) * time.Second
+}
+
+// GetSSHMaxSessionsPerHost returns the maximum concurrent SSH sessions per remote host.
+// Returns 2 if not specified. Safe to call on a nil Config.
+func (c *Config) GetSSHMaxSessionsPerHost() int {
+	if c == nil || c.SSHMaxSessionsPerHost <= 0 {
+		return 2
+	}
+	return c.SSHMaxSessionsPerHost
+}
+
+// GetSSHMaxSessions returns the maximum concurrent SSH sessions across all hosts.
+// Returns 8 if not specified. Safe to call on a nil Config.
+func (c *Config) GetSSHMaxSessions() int {
+	if c == nil || c.SSHMaxSessions <= 0 {
+		return 8
+	}
+	return c.SSHMaxSessions
+}
+
+// GetSSHMultiplexing returns whether SSH commands share a connection to each
+// remote host. Returns true if not specified. Safe to call on a nil Config.
+func (c *Config) GetSSHMultiplexing() bool {
+	return c == nil || enabled(c.SSHMultiplexing)
+}
+
+// GetMaxStreamsPerUser returns the maximum open SSE streams per user.
+// Returns 10 if not specified. Safe to call on a nil Config.
+func (c *Config) GetMaxStreamsPerUser() int {
+	if c == nil || c.MaxStreamsPerUser <= 0 {
+		return 10
+	}
+	return c.MaxStreamsPerUser
+}
+
+// GetMaxStreams returns the maximum open SSE streams across all users.
+// Returns 100 if not specified. Safe to call on a nil Config.
+func (c *Config) GetMaxStreams() int {
+	if c == nil || c.MaxStreams <= 0 {
+		return 100
+	}
+	return c.MaxStreams
+}
+
+// GetDockerRestartDebounce returns how long a container stop is held back in case it restarts.
+// Returns 5 seconds if not specified. Safe to call on a nil Config.
+func (c *Config) GetDockerRestartDebounce() time.Duration {
+	if c == nil || c.DockerRestartDebounce <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(c.DockerRestartDebounce) * time.Second
+}
+
+// GetPollInterval returns how often the monitor polls hosts without their own interval.
+// Returns 60 seconds if not specified. Safe to call on a nil Config.
+func (c *Config) GetPollInterval() time.Duration {
+	if c == nil || c.PollInterval <= 0 {
+		return 60 * time.Second
+	}
+	return time.Duration(c.PollInterval) * time.Second
+}
+
+// GetServicePruneAfter returns how many poll intervals a service may go unreported.
+// Returns 5 if not specified. Safe to call on a nil Config.
+func (c *Config) GetServicePruneAfter() int {
+	if c == nil || c.ServicePruneAfter <= 0 {
+		return 5
+	}
+	return c.ServicePruneAfter
+}
+
+// GetNoticeExpiryDays returns how many days a notice is kept after it was last posted.
+// Returns 7 if not specified. Safe to call on a nil Config.
+func (c *Config) GetNoticeExpiryDays() int {
+	if c == nil || c.NoticeExpiryDays <= 0 {
+		return 7
+	}
+	return c.NoticeExpiryDays
+}
+
+// GetComposeLockWait returns how long an operation waits for a busy compose project.
+// Returns 60 seconds if not specified. Safe to call on a nil Config.
+func (c *Config) GetComposeLockWait() time.Duration {
+	if c == nil || c.ComposeLockWait <= 0 {
+		return 60 * time.Second
+	}
+	return time.Duration(c.ComposeLockWait) * time.Second
+}
+
+// GetLogTail returns how many lines of history the log viewer shows by default.
+// Returns 100 if not specified. Safe to call on a nil Config.
+func (c *Config) GetLogTail() int {
+	if c == nil || c.LogTail <= 0 {
+		return 100
+	}
+	return c.LogTail
+}
+
+// GetImageStaleAfter returns how old a container's image may be before it is flagged stale.
+// Returns 180 days if not specified, or 0 (disabled) if negative. Safe to call on a nil Config.
+func (c *Config) GetImageStaleAfter() time.Duration {
+	if c == nil || c.ImageStaleDays == 0 {
+		return 180 * 24 * time.Hour
+	}
+	if c.ImageStaleDays < 0 {
+		return 0
+	}
+	return time.Duration(c.ImageStaleDays) * 24 * time.Hour
+}
+
+// GetComposeChangeDetection returns whether containers are checked for
+// compose file changes made after they were created. Safe to call on a nil
+// Config.
+func (c *Config) GetComposeChangeDetection() bool {
+	return c != nil && c.ComposeChangeDetection
+}
+
+// GetAllowedOrigins returns the normalized origins allowed for cross-origin requests:
+// the origin of the OIDC service_url (if set) followed by allowed_origins.
+// Invalid entries are skipped with a warning. Safe to call on a nil Config.
+func (c *Config) GetAllowedOrigins() []string {
+	if c == nil {
+		return nil
+	}
+
+	candidates := make([]string, 0, len(c.AllowedOrigins)+1)
+	if c.OIDC != nil && c.OIDC.ServiceURL != "" {
+		candidates = append(candidates, c.OIDC.ServiceURL)
+	}
+	candidates = append(candidates, c.AllowedOrigins...)
+
+	seen := make(map[string]bool)
+	var origins []string
+	for _, candidate := range candidates {
+		origin, ok := NormalizeOrigin(candidate)
+		if !ok {
+			log.Printf("Warning: ignoring invalid allowed origin %q (expected scheme://host[:port])", candidate)
+			continue
+		}
+		if !seen[origin] {
+			seen[origin] = true
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// GetTrustedProxies returns the parsed trusted proxy ranges. A bare IP address
+// is treated as a single-address range. Invalid entries are skipped with a
+// warning. Safe to call on a nil Config.
+func (c *Config) GetTrustedProxies() []netip.Prefix {
+	if c == nil {
+		return nil
+	}
+
+	var prefixes []netip.Prefix
+	for _, entry := range c.TrustedProxies {
+		entry = strings.TrimSpace(entry)
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		log.Printf("Warning: ignoring invalid trusted proxy %q (expected an IP address or CIDR range)", entry)
+	}
+	return prefixes
+}
+
+// NormalizeOrigin reduces a URL to its lowercase "scheme://host[:port]" origin.
+// Returns false if the value has no http(s) scheme or host.
+func NormalizeOrigin(raw string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return "", false
+	}
+	return scheme + "://" + strings.ToLower(u.Host), true
+}
+
 // GetLocalHostName returns the name of the localhost host config, or "localhost" if not found.
 func (c *Config) GetLocalHostName() string {
 	for _, host := range c.Hosts {
@@ -472,25 +1075,127 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read %s: %w", path, err)
 	}
 
+	cfg, err := Parse(data, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Store as global config
+	configMutex.Lock()
+	globalConfig = cfg
+	configMutex.Unlock()
+
+	return cfg, nil
+}
+
+// Parse parses and validates data as the contents of the configuration file
+// at path, merging the secrets sidecar next to path, without storing the
+// result as the global config.
+func Parse(data []byte, path string) (*Config, error) {
 	// Sanitize JSON: strip comments and trailing commas
-	data, err = standardizeJSON(data)
+	data, err := standardizeJSON(data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
 	}
 
+	// Merge values from the encrypted secrets sidecar, if present
+	secretsPath := SecretsPath(path)
+	secrets, err := loadSecrets(secretsPath)
+	if err != nil {
+		return nil, err
+	}
+	var secretKeys []string
+	if secrets != nil {
+		data, secretKeys, err = mergeSecrets(data, secrets)
+		if err != nil {
+			return nil, fmt.Errorf("failed to merge %s into %s: %w", secretsPath, path, err)
+		}
+	}
+
 	var cfg Config
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
 	}
+	cfg.secretKeys = secretKeys
 
-	// Store as global config
-	configMutex.Lock()
-	globalConfig = &cfg
-	configMutex.Unlock()
-
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration in %s: %w", path, err)
+	}
+	cfg.precompute()
 	return &cfg, nil
 }
 
+// precompute caches values derived from the configuration that would
+// otherwise be recomputed on every services request. Link hosts of hosts with
+// NICs are resolved here too, so a NIC's address changing is picked up on the
+// next load.
+func (c *Config) precompute() {
+	c.linkHosts = make(map[string]string, len(c.Hosts))
+	for i := range c.Hosts {
+		host := &c.Hosts[i]
+		c.linkHosts[host.Name] = host.GetLinkHost()
+		host.specs = host.parseServiceSpecs()
+		host.composeRoots = &composeRoots{debug: c.Debug, now: time.Now}
+		host.ComposeRoots() // Expand the globs now rather than on the first restart
+		host.Outbound.load()
+	}
+	c.Outbound.load()
+	if c.LogRedaction != nil && c.LogRedaction.Enabled {
+		c.redactor, _ = redact.New(c.LogRedaction.rules()) // Validate checked the rules
+	}
+}
+
+// LinkHosts returns each host's GetLinkHost keyed by host name. The map must
+// not be modified.
+func (c *Config) LinkHosts() map[string]string {
+	if c.linkHosts != nil {
+		return c.linkHosts
+	}
+	linkHosts := make(map[string]string, len(c.Hosts))
+	for i := range c.Hosts {
+		linkHosts[c.Hosts[i].Name] = c.Hosts[i].GetLinkHost()
+	}
+	return linkHosts
+}
+
+// Validate checks the configuration for malformed values.
+// It verifies that every host address is an IP address or hostname, that
+// journal_access names a known method, that every link has a name and an
+// http(s) URL, that every docker_compose_roots_glob pattern is well-formed,
+// that OIDC group services only grant known permissions, that every
+// log_redaction rule compiles, and that event_export's socket_mode is octal
+// permissions.
+func (c *Config) Validate() error {
+	var errs []error
+	for _, host := range c.Hosts {
+		if err := validateAddress(host.Address); err != nil {
+			errs = append(errs, fmt.Errorf("host %q: %w", host.Name, err))
+		}
+		if access := host.GetJournalAccess(); access != JournalAccessGroup && access != JournalAccessSudo {
+			errs = append(errs, fmt.Errorf("host %q: journal_access must be %q or %q, got %q", host.Name, JournalAccessGroup, JournalAccessSudo, access))
+		}
+	}
+	if err := c.validateLinks(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.validateComposeGlobs(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.validateGroups(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.validateLogRedaction(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.validateOutbound(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := c.EventExport.GetSocketMode(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
 // standardizeJSON strips comments and trailing commas from JSON.
 func standardizeJSON(b []byte) ([]byte, error) {
 	ast, err := hujson.Parse(b)
@@ -530,14 +1235,34 @@ func Default() *Config {
 	return cfg
 }
 
+// Demo returns the configuration used by --demo: two hosts with only
+// synthetic services, so the dashboard runs without any real integrations.
+// It also stores the configuration as the global configuration.
+func Demo() *Config {
+	cfg := &Config{
+		Hosts: []HostConfig{
+			{Name: "demo-nas", Address: "192.168.50.10", Demo: true},
+			{Name: "demo-pi", Address: "192.168.50.20", Demo: true},
+		},
+	}
+	cfg.precompute()
+
+	// Store as global config
+	configMutex.Lock()
+	globalConfig = cfg
+	configMutex.Unlock()
+
+	return cfg
+}
+
 // GetAllConfiguredServices returns a set of all services configured across all hosts.
 // The returned map has keys in the format "host:service" for quick lookup.
 func (c *Config) GetAllConfiguredServices() map[string]bool {
 	services := make(map[string]bool)
 	for _, host := range c.Hosts {
 		// Add systemd services
-		for _, svc := range host.SystemdServices {
-			services[host.Name+":"+svc] = true
+		for _, unitName := range host.GetSystemdServiceNames() {
+			services[host.Name+":"+unitName] = true
 		}
 		// Note: Docker services are discovered at runtime, not from config,
 		// so we can't validate them at startup. Those will be checked at runtime.
@@ -546,12 +1271,14 @@ func (c *Config) GetAllConfiguredServices() map[string]bool {
 }
 
 // ValidateGroupConfigs checks if services referenced in group configs exist in the host config.
-// It logs warnings for any services that are referenced but not found.
+// It logs and returns a warning for each host or service that is referenced but not found,
+// naming the grants that can never match. GET /api/auth/access-preview checks Docker
+// services against those collected.
 // Note: This only validates systemd services since Docker services are runtime-discovered.
 // Docker service validation happens at runtime when services are filtered.
-func (c *Config) ValidateGroupConfigs() {
+func (c *Config) ValidateGroupConfigs() []string {
 	if c.OIDC == nil || c.OIDC.Groups == nil {
-		return
+		return nil
 	}
 
 	configuredServices := c.GetAllConfiguredServices()
@@ -562,6 +1289,13 @@ func (c *Config) ValidateGroupConfigs() {
 		validHosts[host.Name] = true
 	}
 
+	var warnings []string
+	warn := func(format string, args ...any) {
+		warning := fmt.Sprintf(format, args...)
+		log.Printf("Warning: %s", warning)
+		warnings = append(warnings, warning)
+	}
+
 	for groupName, groupConfig := range c.OIDC.Groups {
 		if groupConfig == nil || groupConfig.Services == nil {
 			continue
@@ -570,11 +1304,17 @@ func (c *Config) ValidateGroupConfigs() {
 		for hostName, services := range groupConfig.Services {
 			// Check if host exists
 			if !validHosts[hostName] {
-				log.Printf("Warning: OIDC group '%s' references non-existent host '%s'", groupName, hostName)
+				names := make([]string, len(services))
+				for i, svc := range services {
+					names[i] = svc.Name
+				}
+				warn("OIDC group '%s' references non-existent host '%s'; its grants there (%s) can never match",
+					groupName, hostName, strings.Join(names, ", "))
 				continue
 			}
 
-			for _, svcName := range services {
+			for _, svc := range services {
+				svcName := svc.Name
 				key := hostName + ":" + svcName
 				// Only warn for systemd services (which are in config)
 				// Docker services might be valid but we can't check until runtime
@@ -584,12 +1324,13 @@ func (c *Config) ValidateGroupConfigs() {
 						// Could be a Docker service, which we can't validate at startup
 						continue
 					}
-					log.Printf("Warning: OIDC group '%s' references non-existent systemd service '%s' on host '%s'",
+					warn("OIDC group '%s' references non-existent systemd service '%s' on host '%s'",
 						groupName, svcName, hostName)
 				}
 			}
 		}
 	}
+	return warnings
 }
 
 // isSystemdUnit checks if a service name looks like a systemd unit.
@@ -5,7 +5,12 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestHostConfig_IsLocal(t *testing.T) {
@@ -402,6 +407,16 @@ func TestHostConfig_GetPrivateIP(t *testing.T) {
 			host:     HostConfig{Address: "server.example.com"},
 			expected: "",
 		},
+		{
+			name:     "IPv6 unique local address",
+			host:     HostConfig{Address: "fd00::10"},
+			expected: "fd00::10",
+		},
+		{
+			name:     "bracketed IPv6 unique local address",
+			host:     HostConfig{Address: "[fd00::10]"},
+			expected: "fd00::10",
+		},
 	}
 
 	for _, tt := range tests {
@@ -414,6 +429,112 @@ func TestHostConfig_GetPrivateIP(t *testing.T) {
 	}
 }
 
+func TestHostConfig_GetLinkHost(t *testing.T) {
+	tests := []struct {
+		name     string
+		address  string
+		expected string
+	}{
+		{"private IPv4", "192.168.1.100", "192.168.1.100"},
+		{"private IPv6", "fd00::10", "fd00::10"},
+		{"bracketed IPv6", "[fd00::10]", "fd00::10"},
+		{"hostname kept as-is", "nas.lan", "nas.lan"},
+		{"public IPv4 returns empty", "8.8.8.8", ""},
+		{"localhost returns empty", "localhost", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := HostConfig{Address: tt.address}
+			if got := h.GetLinkHost(); got != tt.expected {
+				t.Errorf("GetLinkHost() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		wantErr bool
+	}{
+		{"IPv4", "192.168.1.10", false},
+		{"IPv6", "fd00::1", false},
+		{"bracketed IPv6", "[fd00::1]", false},
+		{"hostname", "nas.example.com", false},
+		{"single label", "localhost", false},
+		{"empty", "", true},
+		{"with port", "192.168.1.10:22", true},
+		{"bracketed IPv6 with port", "[fd00::1]:22", true},
+		{"unclosed bracket", "[fd00::1", true},
+		{"with scheme", "http://nas.lan", true},
+		{"with path", "nas.lan/api", true},
+		{"invalid characters", "nas lan", true},
+		{"leading hyphen", "-nas.lan", true},
+		{"empty label", "nas..lan", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{Hosts: []HostConfig{{Name: "test", Address: tt.address}}}
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() with address %q error = %v, wantErr %v", tt.address, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad_InvalidAddress(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "services.json")
+	content := `{"hosts": [{"name": "nas", "address": "http://192.168.1.10:8080"}]}`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	_, err := Load(configPath)
+	if err == nil {
+		t.Fatal("Load() expected error for malformed address")
+	}
+	if !strings.Contains(err.Error(), `host "nas"`) {
+		t.Errorf("Load() error = %v, want it to name the host", err)
+	}
+}
+
+func TestValidate_JournalAccess(t *testing.T) {
+	for _, access := range []string{"", "group", "sudo"} {
+		cfg := &Config{Hosts: []HostConfig{{Name: "nas", Address: "192.168.1.10", JournalAccess: access}}}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Validate() with journal_access %q = %v", access, err)
+		}
+	}
+	cfg := &Config{Hosts: []HostConfig{{Name: "nas", Address: "192.168.1.10", JournalAccess: "root"}}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "journal_access") {
+		t.Errorf("Validate() with journal_access \"root\" = %v", err)
+	}
+	if got := (&HostConfig{}).GetJournalAccess(); got != JournalAccessGroup {
+		t.Errorf("GetJournalAccess() default = %q, want %q", got, JournalAccessGroup)
+	}
+}
+
+func TestEventExportConfig_GetSocketMode(t *testing.T) {
+	var unset *EventExportConfig
+	if mode, err := unset.GetSocketMode(); err != nil || mode != 0o600 {
+		t.Errorf("GetSocketMode() default = %v, %v; want 0600", mode, err)
+	}
+	if mode, err := (&EventExportConfig{SocketMode: "0660"}).GetSocketMode(); err != nil || mode != 0o660 {
+		t.Errorf("GetSocketMode(0660) = %v, %v", mode, err)
+	}
+	for _, mode := range []string{"rw-rw----", "0999", "01777"} {
+		cfg := &Config{EventExport: &EventExportConfig{Socket: "/run/dashboard/events.sock", SocketMode: mode}}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "socket_mode") {
+			t.Errorf("Validate() with socket_mode %q = %v", mode, err)
+		}
+	}
+}
+
 func TestConfig_IsOIDCEnabled(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -521,6 +642,206 @@ func TestConfig_GetPort(t *testing.T) {
 	}
 }
 
+func TestConfig_GetTimeouts(t *testing.T) {
+	t.Run("defaults when not set", func(t *testing.T) {
+		cfg := Config{}
+		if got := cfg.GetServicesTimeout(); got != 10*time.Second {
+			t.Errorf("GetServicesTimeout() = %v, want 10s", got)
+		}
+		if got := cfg.GetActionTimeout(); got != 120*time.Second {
+			t.Errorf("GetActionTimeout() = %v, want 2m0s", got)
+		}
+		if got := cfg.GetComposeTimeout(); got != 300*time.Second {
+			t.Errorf("GetComposeTimeout() = %v, want 5m0s", got)
+		}
+		if got := cfg.GetServicesPollTimeout(); got != 55*time.Second {
+			t.Errorf("GetServicesPollTimeout() = %v, want 55s", got)
+		}
+		if got := cfg.GetWarmupTimeout(); got != 60*time.Second {
+			t.Errorf("GetWarmupTimeout() = %v, want 1m0s", got)
+		}
+	})
+
+	t.Run("configured values", func(t *testing.T) {
+		cfg := Config{ServicesTimeout: 5, ActionTimeout: 30, ComposeTimeout: 600, ServicesPollTimeout: 20, WarmupTimeout: 15}
+		if got := cfg.GetWarmupTimeout(); got != 15*time.Second {
+			t.Errorf("GetWarmupTimeout() = %v, want 15s", got)
+		}
+		if got := cfg.GetServicesPollTimeout(); got != 20*time.Second {
+			t.Errorf("GetServicesPollTimeout() = %v, want 20s", got)
+		}
+		if got := cfg.GetServicesTimeout(); got != 5*time.Second {
+			t.Errorf("GetServicesTimeout() = %v, want 5s", got)
+		}
+		if got := cfg.GetActionTimeout(); got != 30*time.Second {
+			t.Errorf("GetActionTimeout() = %v, want 30s", got)
+		}
+		if got := cfg.GetComposeTimeout(); got != 600*time.Second {
+			t.Errorf("GetComposeTimeout() = %v, want 10m0s", got)
+		}
+	})
+
+	t.Run("nil config uses defaults", func(t *testing.T) {
+		var cfg *Config
+		if got := cfg.GetServicesTimeout(); got != 10*time.Second {
+			t.Errorf("GetServicesTimeout() = %v, want 10s", got)
+		}
+		if got := cfg.GetActionTimeout(); got != 120*time.Second {
+			t.Errorf("GetActionTimeout() = %v, want 2m0s", got)
+		}
+	})
+}
+
+func TestConfig_GetCircuitSettings(t *testing.T) {
+	var nilCfg *Config
+	if got := nilCfg.GetCircuitThreshold(); got != 3 {
+		t.Errorf("GetCircuitThreshold() = %d, want 3", got)
+	}
+	if got := nilCfg.GetCircuitCooldown(); got != 30*time.Second {
+		t.Errorf("GetCircuitCooldown() = %v, want 30s", got)
+	}
+
+	cfg := Config{CircuitThreshold: 5, CircuitCooldown: 90}
+	if got := cfg.GetCircuitThreshold(); got != 5 {
+		t.Errorf("GetCircuitThreshold() = %d, want 5", got)
+	}
+	if got := cfg.GetCircuitCooldown(); got != 90*time.Second {
+		t.Errorf("GetCircuitCooldown() = %v, want 1m30s", got)
+	}
+}
+
+func TestConfig_GetSSHSessionLimits(t *testing.T) {
+	var nilCfg *Config
+	if got := nilCfg.GetSSHMaxSessionsPerHost(); got != 2 {
+		t.Errorf("GetSSHMaxSessionsPerHost() = %d, want 2", got)
+	}
+	if got := nilCfg.GetSSHMaxSessions(); got != 8 {
+		t.Errorf("GetSSHMaxSessions() = %d, want 8", got)
+	}
+
+	cfg := Config{SSHMaxSessionsPerHost: 1, SSHMaxSessions: 4}
+	if got := cfg.GetSSHMaxSessionsPerHost(); got != 1 {
+		t.Errorf("GetSSHMaxSessionsPerHost() = %d, want 1", got)
+	}
+	if got := cfg.GetSSHMaxSessions(); got != 4 {
+		t.Errorf("GetSSHMaxSessions() = %d, want 4", got)
+	}
+}
+
+func TestGetDockerRestartDebounce(t *testing.T) {
+	var nilCfg *Config
+	if got := nilCfg.GetDockerRestartDebounce(); got != 5*time.Second {
+		t.Errorf("GetDockerRestartDebounce() = %v, want 5s", got)
+	}
+
+	cfg := Config{DockerRestartDebounce: 12}
+	if got := cfg.GetDockerRestartDebounce(); got != 12*time.Second {
+		t.Errorf("GetDockerRestartDebounce() = %v, want 12s", got)
+	}
+}
+
+func TestGetPollInterval(t *testing.T) {
+	var nilCfg *Config
+	if got := nilCfg.GetPollInterval(); got != 60*time.Second {
+		t.Errorf("GetPollInterval() = %v, want 60s", got)
+	}
+
+	cfg := Config{PollInterval: 30}
+	if got := cfg.GetPollInterval(); got != 30*time.Second {
+		t.Errorf("GetPollInterval() = %v, want 30s", got)
+	}
+
+	host := HostConfig{Name: "pi"}
+	if got := host.GetPollInterval(cfg.GetPollInterval()); got != 30*time.Second {
+		t.Errorf("HostConfig.GetPollInterval() without override = %v, want the global 30s", got)
+	}
+	host.PollInterval = 300
+	if got := host.GetPollInterval(cfg.GetPollInterval()); got != 5*time.Minute {
+		t.Errorf("HostConfig.GetPollInterval() = %v, want 5m0s", got)
+	}
+}
+
+func TestGetServicePruneAfter(t *testing.T) {
+	var nilCfg *Config
+	if got := nilCfg.GetServicePruneAfter(); got != 5 {
+		t.Errorf("GetServicePruneAfter() = %d, want 5", got)
+	}
+
+	cfg := Config{ServicePruneAfter: 2}
+	if got := cfg.GetServicePruneAfter(); got != 2 {
+		t.Errorf("GetServicePruneAfter() = %d, want 2", got)
+	}
+}
+
+func TestGetComposeLockWait(t *testing.T) {
+	var nilCfg *Config
+	if got := nilCfg.GetComposeLockWait(); got != 60*time.Second {
+		t.Errorf("GetComposeLockWait() = %v, want 60s", got)
+	}
+
+	cfg := Config{ComposeLockWait: 15}
+	if got := cfg.GetComposeLockWait(); got != 15*time.Second {
+		t.Errorf("GetComposeLockWait() = %v, want 15s", got)
+	}
+}
+
+func TestGetLogTail(t *testing.T) {
+	var nilCfg *Config
+	if got := nilCfg.GetLogTail(); got != 100 {
+		t.Errorf("GetLogTail() = %d, want 100", got)
+	}
+
+	cfg := Config{LogTail: 500}
+	if got := cfg.GetLogTail(); got != 500 {
+		t.Errorf("GetLogTail() = %d, want 500", got)
+	}
+}
+
+func TestGetImageStaleAfter(t *testing.T) {
+	var nilCfg *Config
+	if got := nilCfg.GetImageStaleAfter(); got != 180*24*time.Hour {
+		t.Errorf("GetImageStaleAfter() = %v, want 180 days", got)
+	}
+
+	cfg := Config{ImageStaleDays: 30}
+	if got := cfg.GetImageStaleAfter(); got != 30*24*time.Hour {
+		t.Errorf("GetImageStaleAfter() = %v, want 30 days", got)
+	}
+
+	disabled := Config{ImageStaleDays: -1}
+	if got := disabled.GetImageStaleAfter(); got != 0 {
+		t.Errorf("GetImageStaleAfter() = %v, want 0 (disabled)", got)
+	}
+}
+
+func TestGetAllowedOrigins(t *testing.T) {
+	var nilCfg *Config
+	if got := nilCfg.GetAllowedOrigins(); got != nil {
+		t.Errorf("GetAllowedOrigins() on nil = %v, want nil", got)
+	}
+
+	cfg := Config{
+		OIDC: &OIDCConfig{ServiceURL: "https://Dashboard.example.com/"},
+		AllowedOrigins: []string{
+			"https://homepage.example.com/some/path",
+			"http://192.168.1.5:3000",
+			"https://dashboard.example.com", // duplicate of service_url
+			"not a url",
+			"ftp://files.example.com",
+		},
+	}
+	want := []string{"https://dashboard.example.com", "https://homepage.example.com", "http://192.168.1.5:3000"}
+	got := cfg.GetAllowedOrigins()
+	if len(got) != len(want) {
+		t.Fatalf("GetAllowedOrigins() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("GetAllowedOrigins()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
 func TestLoad_OIDCConfig(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -1105,7 +1426,7 @@ func TestLoad_HomeAssistantConfig(t *testing.T) {
 	})
 }
 
-func TestParseSystemdServiceEntry(t *testing.T) {
+func TestParseServiceSpec(t *testing.T) {
 	tests := []struct {
 		name         string
 		entry        string
@@ -1142,13 +1463,19 @@ func TestParseSystemdServiceEntry(t *testing.T) {
 		{"port 0 ignored", "myapp.service#0,8080", "myapp.service", "", false, []uint16{8080}},
 		{"port over 65535 ignored", "myapp.service#8080,99999", "myapp.service", "", false, []uint16{8080}},
 		{"just hash no ports", "myapp.service#", "myapp.service", "", false, nil},
+		// Weird inputs
+		{"repeated readonly suffix", "foo.service:ro:ro", "foo.service", "", true, nil},
+		{"repeated readonly suffix with ports", "foo.service#80:ro:ro", "foo.service", "", true, []uint16{80}},
+		{"surrounding whitespace", "  docker.service:ro ", "docker.service", "", true, nil},
+		{"whitespace only", "   ", "", "", false, nil},
+		{"readonly in the middle is kept", "foo:ro.service", "ro.service", "foo", false, nil},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := ParseSystemdServiceEntry(tt.entry)
-			if result.Name != tt.wantName {
-				t.Errorf("Name = %q, want %q", result.Name, tt.wantName)
+			result := ParseServiceSpec(tt.entry)
+			if result.UnitName != tt.wantName {
+				t.Errorf("UnitName = %q, want %q", result.UnitName, tt.wantName)
 			}
 			if result.User != tt.wantUser {
 				t.Errorf("User = %q, want %q", result.User, tt.wantUser)
@@ -1169,7 +1496,79 @@ func TestParseSystemdServiceEntry(t *testing.T) {
 	}
 }
 
-func TestHostConfig_GetSystemdServiceEntries(t *testing.T) {
+func TestParseServiceSpecDisplayName(t *testing.T) {
+	tests := []struct {
+		name            string
+		entry           string
+		wantName        string
+		wantUser        string
+		wantReadOnly    bool
+		wantDisplayName string
+	}{
+		{"no display name", "nginx.service", "nginx.service", "", false, ""},
+		{"display name", "nginx.service|name=Web Server", "nginx.service", "", false, "Web Server"},
+		{"display name with readonly", "nginx.service:ro|name=Web Server", "nginx.service", "", true, "Web Server"},
+		{"display name with user and ports", "xero:app.service#3000:ro|name=My App", "app.service", "xero", true, "My App"},
+		{"display name trimmed", "nginx.service| name = Web ", "nginx.service", "", false, "Web"},
+		{"display name may contain colons", "nginx.service|name=Web: Frontend", "nginx.service", "", false, "Web: Frontend"},
+		{"unknown option ignored", "nginx.service|color=red|name=Web", "nginx.service", "", false, "Web"},
+		{"option without value ignored", "nginx.service|bogus", "nginx.service", "", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ParseServiceSpec(tt.entry)
+			if result.UnitName != tt.wantName {
+				t.Errorf("UnitName = %q, want %q", result.UnitName, tt.wantName)
+			}
+			if result.User != tt.wantUser {
+				t.Errorf("User = %q, want %q", result.User, tt.wantUser)
+			}
+			if result.ReadOnly != tt.wantReadOnly {
+				t.Errorf("ReadOnly = %v, want %v", result.ReadOnly, tt.wantReadOnly)
+			}
+			if result.DisplayName != tt.wantDisplayName {
+				t.Errorf("DisplayName = %q, want %q", result.DisplayName, tt.wantDisplayName)
+			}
+		})
+	}
+}
+
+func TestParseServiceSpecLogSettings(t *testing.T) {
+	tests := []struct {
+		name           string
+		entry          string
+		wantTail       int
+		wantTimestamps string // "", "true" or "false"
+	}{
+		{"none", "nginx.service", 0, ""},
+		{"tail", "nginx.service|tail=500", 500, ""},
+		{"timestamps off", "nginx.service|timestamps=false", 0, "false"},
+		{"both with other options", "xero:app.service#3000:ro|name=App|tail=50|timestamps=true", 50, "true"},
+		{"zero tail ignored", "nginx.service|tail=0", 0, ""},
+		{"negative tail ignored", "nginx.service|tail=-5", 0, ""},
+		{"invalid tail ignored", "nginx.service|tail=lots", 0, ""},
+		{"invalid timestamps ignored", "nginx.service|timestamps=sometimes", 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ParseServiceSpec(tt.entry)
+			if result.LogTail != tt.wantTail {
+				t.Errorf("LogTail = %d, want %d", result.LogTail, tt.wantTail)
+			}
+			got := ""
+			if result.LogTimestamps != nil {
+				got = strconv.FormatBool(*result.LogTimestamps)
+			}
+			if got != tt.wantTimestamps {
+				t.Errorf("LogTimestamps = %q, want %q", got, tt.wantTimestamps)
+			}
+		})
+	}
+}
+
+func TestHostConfig_GetServiceSpecs(t *testing.T) {
 	host := HostConfig{
 		Name:    "testhost",
 		Address: "localhost",
@@ -1181,15 +1580,15 @@ func TestHostConfig_GetSystemdServiceEntries(t *testing.T) {
 		},
 	}
 
-	entries := host.GetSystemdServiceEntries()
+	entries := host.GetServiceSpecs()
 
 	if len(entries) != 4 {
 		t.Fatalf("Expected 4 entries, got %d", len(entries))
 	}
 
 	// Check first entry (not readonly)
-	if entries[0].Name != "docker.service" {
-		t.Errorf("entries[0].Name = %q, want %q", entries[0].Name, "docker.service")
+	if entries[0].UnitName != "docker.service" {
+		t.Errorf("entries[0].UnitName = %q, want %q", entries[0].UnitName, "docker.service")
 	}
 	if entries[0].ReadOnly {
 		t.Error("entries[0].ReadOnly = true, want false")
@@ -1199,24 +1598,24 @@ func TestHostConfig_GetSystemdServiceEntries(t *testing.T) {
 	}
 
 	// Check second entry (readonly)
-	if entries[1].Name != "nas-dashboard.service" {
-		t.Errorf("entries[1].Name = %q, want %q", entries[1].Name, "nas-dashboard.service")
+	if entries[1].UnitName != "nas-dashboard.service" {
+		t.Errorf("entries[1].UnitName = %q, want %q", entries[1].UnitName, "nas-dashboard.service")
 	}
 	if !entries[1].ReadOnly {
 		t.Error("entries[1].ReadOnly = false, want true")
 	}
 
 	// Check third entry (not readonly)
-	if entries[2].Name != "nginx.service" {
-		t.Errorf("entries[2].Name = %q, want %q", entries[2].Name, "nginx.service")
+	if entries[2].UnitName != "nginx.service" {
+		t.Errorf("entries[2].UnitName = %q, want %q", entries[2].UnitName, "nginx.service")
 	}
 	if entries[2].ReadOnly {
 		t.Error("entries[2].ReadOnly = true, want false")
 	}
 
 	// Check fourth entry (with ports)
-	if entries[3].Name != "webapp.service" {
-		t.Errorf("entries[3].Name = %q, want %q", entries[3].Name, "webapp.service")
+	if entries[3].UnitName != "webapp.service" {
+		t.Errorf("entries[3].UnitName = %q, want %q", entries[3].UnitName, "webapp.service")
 	}
 	if len(entries[3].Ports) != 2 {
 		t.Errorf("entries[3].Ports length = %d, want 2", len(entries[3].Ports))
@@ -1255,7 +1654,81 @@ func TestHostConfig_GetSystemdServiceNames(t *testing.T) {
 	}
 }
 
-func TestHostConfig_GetSystemdServiceEntriesWithUserServices(t *testing.T) {
+func TestHostConfig_GetServiceSpecsSkipsEmptyEntries(t *testing.T) {
+	host := HostConfig{
+		Name:            "testhost",
+		SystemdServices: []string{"", ":ro", "docker.service", "  "},
+	}
+
+	specs := host.GetServiceSpecs()
+	if len(specs) != 1 || specs[0].UnitName != "docker.service" {
+		t.Errorf("GetServiceSpecs() = %+v, want only docker.service", specs)
+	}
+}
+
+// TestLoad_Precompute tests that Load caches link hosts and parsed specs, and
+// that configs built in code compute them on demand.
+func TestLoad_Precompute(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "services.json")
+	configJSON := `{
+		"hosts": [
+			{"name": "nas", "address": "192.168.1.10", "systemd_services": ["docker.service:ro", ""]},
+			{"name": "vps", "address": "vps.example.com"},
+			{"name": "local", "address": "localhost"}
+		]
+	}`
+	if err := os.WriteFile(configPath, []byte(configJSON), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := map[string]string{"nas": "192.168.1.10", "vps": "vps.example.com", "local": ""}
+	if got := cfg.LinkHosts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("LinkHosts() = %v, want %v", got, want)
+	}
+	built := &Config{Hosts: cfg.Hosts}
+	if got := built.LinkHosts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("LinkHosts() without Load = %v, want %v", got, want)
+	}
+
+	if specs := cfg.Hosts[0].GetServiceSpecs(); len(specs) != 1 || specs[0].UnitName != "docker.service" || !specs[0].ReadOnly {
+		t.Errorf("GetServiceSpecs() = %+v, want read-only docker.service", specs)
+	}
+	if specs := cfg.Hosts[1].GetServiceSpecs(); specs == nil || len(specs) != 0 {
+		t.Errorf("GetServiceSpecs() = %#v, want an empty cached list", specs)
+	}
+}
+
+func TestHostConfig_GetServiceSpec(t *testing.T) {
+	host := HostConfig{
+		Name: "testhost",
+		SystemdServices: []string{
+			"docker.service",
+			"xero:zunesync.service#8080:ro|name=Zune Sync",
+		},
+	}
+
+	spec, ok := host.GetServiceSpec("zunesync.service")
+	if !ok {
+		t.Fatal("GetServiceSpec(zunesync.service) not found")
+	}
+	if spec.User != "xero" || !spec.ReadOnly || spec.DisplayName != "Zune Sync" || len(spec.Ports) != 1 {
+		t.Errorf("GetServiceSpec(zunesync.service) = %+v", spec)
+	}
+
+	// Lookups use the unit name, not the raw entry
+	if _, ok := host.GetServiceSpec("xero:zunesync.service#8080:ro|name=Zune Sync"); ok {
+		t.Error("GetServiceSpec() matched a raw entry string")
+	}
+	if _, ok := host.GetServiceSpec("missing.service"); ok {
+		t.Error("GetServiceSpec(missing.service) found, want not found")
+	}
+}
+
+func TestHostConfig_GetServiceSpecsWithUserServices(t *testing.T) {
 	host := HostConfig{
 		Name:    "testhost",
 		Address: "localhost",
@@ -1267,34 +1740,34 @@ func TestHostConfig_GetSystemdServiceEntriesWithUserServices(t *testing.T) {
 		},
 	}
 
-	entries := host.GetSystemdServiceEntries()
+	entries := host.GetServiceSpecs()
 
 	if len(entries) != 4 {
 		t.Fatalf("Expected 4 entries, got %d", len(entries))
 	}
 
 	// Check first entry (system service, not readonly)
-	if entries[0].Name != "docker.service" || entries[0].User != "" || entries[0].ReadOnly {
+	if entries[0].UnitName != "docker.service" || entries[0].User != "" || entries[0].ReadOnly {
 		t.Errorf("entries[0] = {Name: %q, User: %q, ReadOnly: %v}, want {Name: \"docker.service\", User: \"\", ReadOnly: false}",
-			entries[0].Name, entries[0].User, entries[0].ReadOnly)
+			entries[0].UnitName, entries[0].User, entries[0].ReadOnly)
 	}
 
 	// Check second entry (system service, readonly)
-	if entries[1].Name != "nas-dashboard.service" || entries[1].User != "" || !entries[1].ReadOnly {
+	if entries[1].UnitName != "nas-dashboard.service" || entries[1].User != "" || !entries[1].ReadOnly {
 		t.Errorf("entries[1] = {Name: %q, User: %q, ReadOnly: %v}, want {Name: \"nas-dashboard.service\", User: \"\", ReadOnly: true}",
-			entries[1].Name, entries[1].User, entries[1].ReadOnly)
+			entries[1].UnitName, entries[1].User, entries[1].ReadOnly)
 	}
 
 	// Check third entry (user service, not readonly)
-	if entries[2].Name != "zunesync.service" || entries[2].User != "xero" || entries[2].ReadOnly {
+	if entries[2].UnitName != "zunesync.service" || entries[2].User != "xero" || entries[2].ReadOnly {
 		t.Errorf("entries[2] = {Name: %q, User: %q, ReadOnly: %v}, want {Name: \"zunesync.service\", User: \"xero\", ReadOnly: false}",
-			entries[2].Name, entries[2].User, entries[2].ReadOnly)
+			entries[2].UnitName, entries[2].User, entries[2].ReadOnly)
 	}
 
 	// Check fourth entry (user service, readonly)
-	if entries[3].Name != "backup.timer" || entries[3].User != "alice" || !entries[3].ReadOnly {
+	if entries[3].UnitName != "backup.timer" || entries[3].User != "alice" || !entries[3].ReadOnly {
 		t.Errorf("entries[3] = {Name: %q, User: %q, ReadOnly: %v}, want {Name: \"backup.timer\", User: \"alice\", ReadOnly: true}",
-			entries[3].Name, entries[3].User, entries[3].ReadOnly)
+			entries[3].UnitName, entries[3].User, entries[3].ReadOnly)
 	}
 }
 
@@ -1629,6 +2102,18 @@ func TestHostConfig_GetSSHArgs(t *testing.T) {
 			t.Error("GetSSHArgs() should include -p 2222")
 		}
 	})
+
+	t.Run("connect timeout", func(t *testing.T) {
+		host := HostConfig{Name: "test", Address: "192.168.1.100"}
+		if args := host.GetSSHArgs(); args[1] != "ConnectTimeout=5" {
+			t.Errorf("default connect timeout = %q, want ConnectTimeout=5", args[1])
+		}
+
+		host.SSHConfig = &SSHConfig{ConnectTimeout: 20}
+		if args := host.GetSSHArgs(); args[1] != "ConnectTimeout=20" {
+			t.Errorf("connect timeout = %q, want ConnectTimeout=20", args[1])
+		}
+	})
 }
 
 // TestLoad_SSHConfig tests that SSH config is loaded from JSON.
@@ -1674,3 +2159,187 @@ func TestLoad_SSHConfig(t *testing.T) {
 		t.Errorf("SSHConfig.Port = %v, want 2222", host.SSHConfig.Port)
 	}
 }
+
+func TestGetTrustedProxies(t *testing.T) {
+	cfg := &Config{TrustedProxies: []string{"172.18.0.0/16", "10.0.0.1", "192.168.1.7/24", "fd00::/64", "not-an-ip", " 10.0.0.2 "}}
+
+	var got []string
+	for _, prefix := range cfg.GetTrustedProxies() {
+		got = append(got, prefix.String())
+	}
+	want := []string{"172.18.0.0/16", "10.0.0.1/32", "192.168.1.0/24", "fd00::/64", "10.0.0.2/32"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("GetTrustedProxies() = %v, want %v", got, want)
+	}
+
+	var nilCfg *Config
+	if nilCfg.GetTrustedProxies() != nil {
+		t.Error("GetTrustedProxies() on nil config should return nil")
+	}
+}
+
+func TestHostConfig_IntegrationToggles(t *testing.T) {
+	off, on := false, true
+	full := func() HostConfig {
+		return HostConfig{
+			Name:            "nas",
+			SystemdServices: []string{"nginx.service"},
+			Traefik:         TraefikConfig{Enabled: true},
+			HomeAssistant:   &HomeAssistantConfig{LongLivedToken: "token"},
+			Watchtower:      &WatchtowerConfig{Port: 8080},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		toggle       func(h *HostConfig)
+		wantEnabled  []string // Of docker, systemd, traefik, homeassistant, watchtower
+		wantDisabled []string
+	}{
+		{"defaults", func(h *HostConfig) {}, []string{"docker", "systemd", "traefik", "homeassistant", "watchtower"}, nil},
+		{"explicitly enabled", func(h *HostConfig) {
+			h.Enabled = &on
+			h.Docker = &IntegrationToggle{Enabled: &on}
+			h.HomeAssistant.Enabled = &on
+		}, []string{"docker", "systemd", "traefik", "homeassistant", "watchtower"}, nil},
+		{"host disabled", func(h *HostConfig) { h.Enabled = &off }, nil, []string{"host"}},
+		{"docker disabled", func(h *HostConfig) { h.Docker = &IntegrationToggle{Enabled: &off} },
+			[]string{"systemd", "traefik", "homeassistant", "watchtower"}, []string{"docker"}},
+		{"systemd disabled", func(h *HostConfig) { h.Systemd = &IntegrationToggle{Enabled: &off} },
+			[]string{"docker", "traefik", "homeassistant", "watchtower"}, []string{"systemd"}},
+		{"homeassistant disabled", func(h *HostConfig) { h.HomeAssistant.Enabled = &off },
+			[]string{"docker", "systemd", "traefik", "watchtower"}, []string{"homeassistant"}},
+		{"watchtower disabled", func(h *HostConfig) { h.Watchtower.Enabled = &off },
+			[]string{"docker", "systemd", "traefik", "homeassistant"}, []string{"watchtower"}},
+		{"empty toggle", func(h *HostConfig) { h.Systemd = &IntegrationToggle{} },
+			[]string{"docker", "systemd", "traefik", "homeassistant", "watchtower"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := full()
+			tt.toggle(&host)
+
+			var enabled []string
+			for _, check := range []struct {
+				name string
+				has  bool
+			}{
+				{"docker", host.HasDocker()},
+				{"systemd", host.HasSystemd()},
+				{"traefik", host.HasTraefik()},
+				{"homeassistant", host.HasHomeAssistant()},
+				{"watchtower", host.HasWatchtower()},
+			} {
+				if check.has {
+					enabled = append(enabled, check.name)
+				}
+			}
+			if !reflect.DeepEqual(enabled, tt.wantEnabled) {
+				t.Errorf("enabled = %v, want %v", enabled, tt.wantEnabled)
+			}
+			if got := host.DisabledIntegrations(); !reflect.DeepEqual(got, tt.wantDisabled) {
+				t.Errorf("DisabledIntegrations() = %v, want %v", got, tt.wantDisabled)
+			}
+		})
+	}
+
+	// Unconfigured integrations are neither enabled nor disabled
+	bare := HostConfig{Name: "pi", Systemd: &IntegrationToggle{Enabled: &off}, Watchtower: &WatchtowerConfig{Enabled: &off}}
+	if got := bare.DisabledIntegrations(); !reflect.DeepEqual(got, []string(nil)) {
+		t.Errorf("DisabledIntegrations() without units or a Watchtower port = %v, want none", got)
+	}
+}
+
+func TestLoad_IntegrationToggles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "services.json")
+	data := `{"hosts": [{
+		"name": "nas", "address": "localhost", "enabled": true,
+		"docker": {"enabled": false},
+		"systemd_services": ["nginx.service"], "systemd": {"enabled": false},
+		"homeassistant": {"longlivedtoken": "token", "enabled": false},
+		"watchtower": {"port": 8080, "enabled": false}
+	}]}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	host := cfg.Hosts[0]
+	if got := host.DisabledIntegrations(); !reflect.DeepEqual(got, []string{"docker", "systemd", "homeassistant", "watchtower"}) {
+		t.Errorf("DisabledIntegrations() = %v", got)
+	}
+}
+
+func TestHostConfig_Stats(t *testing.T) {
+	off := false
+	tests := []struct {
+		name         string
+		host         *HostConfig
+		wantStats    bool
+		wantInterval time.Duration
+	}{
+		{"not configured", &HostConfig{}, false, 30 * time.Second},
+		{"disabled", &HostConfig{Stats: &StatsConfig{Interval: 10}}, false, 10 * time.Second},
+		{"enabled", &HostConfig{Stats: &StatsConfig{Enabled: true}}, true, 30 * time.Second},
+		{"custom interval", &HostConfig{Stats: &StatsConfig{Enabled: true, Interval: 60}}, true, time.Minute},
+		{"docker disabled", &HostConfig{Stats: &StatsConfig{Enabled: true}, Docker: &IntegrationToggle{Enabled: &off}}, false, 30 * time.Second},
+		{"nil host", nil, false, 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.host.HasStats(); got != tt.wantStats {
+				t.Errorf("HasStats() = %v, want %v", got, tt.wantStats)
+			}
+			if got := tt.host.GetStatsInterval(); got != tt.wantInterval {
+				t.Errorf("GetStatsInterval() = %v, want %v", got, tt.wantInterval)
+			}
+		})
+	}
+}
+
+func TestHostConfig_GetTraefikService(t *testing.T) {
+	tests := []struct {
+		name string
+		host HostConfig
+		want string
+	}{
+		{"traefik disabled", HostConfig{Traefik: TraefikConfig{Service: "edge"}}, ""},
+		{"default", HostConfig{Traefik: TraefikConfig{Enabled: true}}, "traefik"},
+		{"named", HostConfig{Traefik: TraefikConfig{Enabled: true, Service: "edge"}}, "edge"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.host.GetTraefikService(); got != tt.want {
+				t.Errorf("GetTraefikService() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateGroupConfigs_Warnings(t *testing.T) {
+	cfg := &Config{
+		Hosts: []HostConfig{{Name: "nas", SystemdServices: []string{"backup.service"}}},
+		OIDC: &OIDCConfig{Groups: map[string]*OIDCGroupConfig{
+			"media": {Services: map[string][]GroupService{
+				"nas": {{Name: "backup.service"}, {Name: "missing.service"}, {Name: "jellyfin"}},
+				"old": {{Name: "sonarr"}, {Name: "radarr", Permissions: []string{"view"}}},
+			}},
+		}},
+	}
+	warnings := cfg.ValidateGroupConfigs()
+	sort.Strings(warnings)
+	want := []string{
+		"OIDC group 'media' references non-existent host 'old'; its grants there (sonarr, radarr) can never match",
+		"OIDC group 'media' references non-existent systemd service 'missing.service' on host 'nas'",
+	}
+	if strings.Join(warnings, "\n") != strings.Join(want, "\n") {
+		t.Errorf("ValidateGroupConfigs() = %q, want %q", warnings, want)
+	}
+	if warnings := (&Config{}).ValidateGroupConfigs(); warnings != nil {
+		t.Errorf("ValidateGroupConfigs() without groups = %q", warnings)
+	}
+}
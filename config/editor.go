@@ -0,0 +1,261 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaskedSecret replaces the value of every secret in a config document
+// shown to administrators. Submitted back unchanged, it keeps the saved value.
+const MaskedSecret = "***"
+
+// secretFields are the keys whose values MaskSecrets hides: the OIDC client
+// secret and the Home Assistant, Watchtower and Gotify tokens.
+var secretFields = map[string]bool{
+	"client_secret":  true,
+	"longlivedtoken": true,
+	"token":          true,
+}
+
+// passwordFields are the keys of URLs whose password MaskSecrets hides,
+// keeping the rest of the URL readable: the outbound proxy_url.
+var passwordFields = map[string]bool{
+	"proxy_url": true,
+}
+
+// ReadDocument reads the configuration file at path as a JSON document,
+// without merging the secrets sidecar or validating it.
+func ReadDocument(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	data, err = standardizeJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	var doc map[string]any
+	if err := decodeJSON(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return doc, nil
+}
+
+// MaskSecrets replaces every non-empty secret in doc with MaskedSecret, and
+// the password of every URL with a password field.
+func MaskSecrets(doc any) {
+	switch d := doc.(type) {
+	case map[string]any:
+		for k, v := range d {
+			if s, ok := v.(string); ok && secretFields[k] && s != "" {
+				d[k] = MaskedSecret
+				continue
+			}
+			if s, ok := v.(string); ok && passwordFields[k] {
+				if before, password, after, ok := splitURLPassword(s); ok && password != "" {
+					d[k] = before + MaskedSecret + after
+				}
+				continue
+			}
+			MaskSecrets(v)
+		}
+	case []any:
+		for _, v := range d {
+			MaskSecrets(v)
+		}
+	}
+}
+
+// UnmaskSecrets puts the values from current back in place of the secrets
+// of candidate that are still MaskedSecret. Entries of named lists such as
+// hosts are matched by name, other list entries by position. A masked secret
+// with no saved value is an error.
+func UnmaskSecrets(candidate, current any) error {
+	var errs []error
+	unmaskValue(candidate, current, "", &errs)
+	return errors.Join(errs...)
+}
+
+// unmaskValue unmasks candidate against current at the given key path.
+func unmaskValue(candidate, current any, path string, errs *[]error) {
+	switch c := candidate.(type) {
+	case map[string]any:
+		saved, _ := current.(map[string]any)
+		for k, v := range c {
+			if secretFields[k] && v == MaskedSecret {
+				value, ok := saved[k].(string)
+				if !ok || value == "" {
+					*errs = append(*errs, fmt.Errorf("%s is %q but no value is saved for it", joinKeyPath(path, k), MaskedSecret))
+					continue
+				}
+				c[k] = value
+				continue
+			}
+			if s, ok := v.(string); ok && passwordFields[k] {
+				before, password, after, ok := splitURLPassword(s)
+				if !ok || password != MaskedSecret {
+					continue
+				}
+				value, _ := saved[k].(string)
+				if _, savedPassword, _, ok := splitURLPassword(value); ok && savedPassword != "" {
+					c[k] = before + savedPassword + after
+				} else {
+					*errs = append(*errs, fmt.Errorf("%s has the password %q but no password is saved for it", joinKeyPath(path, k), MaskedSecret))
+				}
+				continue
+			}
+			unmaskValue(v, saved[k], joinKeyPath(path, k), errs)
+		}
+	case []any:
+		saved, _ := current.([]any)
+		named := isNamedList(c)
+		for i, item := range c {
+			if named {
+				name := item.(map[string]any)["name"].(string)
+				unmaskValue(item, findNamed(saved, name), fmt.Sprintf("%s[%s]", path, name), errs)
+				continue
+			}
+			var match any
+			if i < len(saved) {
+				match = saved[i]
+			}
+			unmaskValue(item, match, path+"["+strconv.Itoa(i)+"]", errs)
+		}
+	}
+}
+
+// splitURLPassword splits rawURL around the password of its user info, as
+// written, so it can be replaced without re-encoding the rest. ok is false
+// if rawURL has no password.
+func splitURLPassword(rawURL string) (before, password, after string, ok bool) {
+	_, rest, found := strings.Cut(rawURL, "://")
+	if !found {
+		return "", "", "", false
+	}
+	start := len(rawURL) - len(rest)
+	authority := rest
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		authority = rest[:i]
+	}
+	at := strings.LastIndex(authority, "@")
+	if at < 0 {
+		return "", "", "", false
+	}
+	colon := strings.Index(authority[:at], ":")
+	if colon < 0 {
+		return "", "", "", false
+	}
+	return rawURL[:start+colon+1], authority[colon+1 : at], rawURL[start+at:], true
+}
+
+// findNamed returns the object in items whose "name" is name, or nil.
+func findNamed(items []any, name string) any {
+	for _, item := range items {
+		if m, ok := item.(map[string]any); ok && m["name"] == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// PrepareCandidate turns data submitted for the configuration file at path
+// into the document to save: comments and trailing commas are stripped and
+// masked secrets are replaced by the saved values. It doesn't validate the
+// result; see Parse.
+func PrepareCandidate(data []byte, path string) ([]byte, error) {
+	data, err := standardizeJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the configuration: %w", err)
+	}
+	var doc map[string]any
+	if err := decodeJSON(data, &doc); err != nil || doc == nil {
+		return nil, errors.New("the configuration must be a JSON object")
+	}
+
+	var current any
+	if saved, err := ReadDocument(path); err == nil {
+		current = saved
+	}
+	if err := UnmaskSecrets(doc, current); err != nil {
+		return nil, err
+	}
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// Apply validates data with Parse and, if it is valid, replaces the
+// configuration file at path with it and makes it the global config. The
+// previous file is kept next to it with a timestamp suffix, whose path is
+// returned ("" if there was no previous file). The new file is written to a
+// temporary file that is renamed over path, so a crash never leaves a
+// partial file behind.
+func Apply(path string, data []byte) (string, *Config, error) {
+	cfg, err := Parse(data, path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	mode := os.FileMode(0o600)
+	backup := ""
+	if previous, err := os.ReadFile(path); err == nil {
+		if info, err := os.Stat(path); err == nil {
+			mode = info.Mode().Perm()
+		}
+		backup = path + "." + time.Now().Format("20060102-150405.000") + ".bak"
+		if err := os.WriteFile(backup, previous, mode); err != nil {
+			return "", nil, fmt.Errorf("failed to back up %s: %w", path, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if err := writeFileAtomic(path, data, mode); err != nil {
+		return backup, nil, err
+	}
+
+	configMutex.Lock()
+	globalConfig = cfg
+	configMutex.Unlock()
+
+	return backup, cfg, nil
+}
+
+// writeFileAtomic writes data to a temporary file in the directory of path
+// and renames it over path.
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
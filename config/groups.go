@@ -0,0 +1,122 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Permissions an OIDC group can be granted on a service.
+const (
+	// PermissionView shows the service's state in the services list.
+	PermissionView = "view"
+	// PermissionLogs opens the service's logs.
+	PermissionLogs = "logs"
+	// PermissionControl starts, stops and restarts the service.
+	PermissionControl = "control"
+)
+
+// AllPermissions lists every permission, the access of a service granted by
+// its plain name.
+var AllPermissions = []string{PermissionView, PermissionLogs, PermissionControl}
+
+// GroupService is a service an OIDC group can access. In the config it is
+// either a plain service name, granting every permission, or an object
+// {"name": "plex", "permissions": ["view", "logs"]} granting only those.
+// Any permission implies view, since a service can't be used without being
+// seen.
+type GroupService struct {
+	Name string `json:"name"`
+	// Permissions are the permissions granted; nil grants all of them.
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// UnmarshalJSON accepts a plain service name or a {name, permissions} object.
+func (s *GroupService) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		*s = GroupService{}
+		return json.Unmarshal(data, &s.Name)
+	}
+	var o struct {
+		Name        string    `json:"name"`
+		Permissions *[]string `json:"permissions"`
+	}
+	if err := json.Unmarshal(data, &o); err != nil {
+		return fmt.Errorf("group service must be a name or a {\"name\", \"permissions\"} object: %w", err)
+	}
+	*s = GroupService{Name: o.Name}
+	if o.Permissions != nil {
+		// "permissions": [] grants nothing rather than everything
+		s.Permissions = append([]string{}, *o.Permissions...)
+	}
+	return nil
+}
+
+// MarshalJSON writes services with every permission as their plain name, so
+// rewriting a config keeps the shape it was written in.
+func (s GroupService) MarshalJSON() ([]byte, error) {
+	if s.Permissions == nil {
+		return json.Marshal(s.Name)
+	}
+	// A distinct type, so encoding the object doesn't recurse into here
+	type object GroupService
+	return json.Marshal(object(s))
+}
+
+// Granted returns the permissions the entry grants, view included.
+func (s GroupService) Granted() []string {
+	if s.Permissions == nil {
+		return AllPermissions
+	}
+	granted := []string{PermissionView}
+	for _, permission := range AllPermissions[1:] {
+		if slices.Contains(s.Permissions, permission) {
+			granted = append(granted, permission)
+		}
+	}
+	return granted
+}
+
+// validateGroups checks that every service of an OIDC group has a name and
+// only known permissions, at least one of them.
+func (c *Config) validateGroups() error {
+	if c.OIDC == nil {
+		return nil
+	}
+	names := make([]string, 0, len(c.OIDC.Groups))
+	for name := range c.OIDC.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, groupName := range names {
+		group := c.OIDC.Groups[groupName]
+		if group == nil {
+			continue
+		}
+		for hostName, services := range group.Services {
+			for _, svc := range services {
+				if strings.TrimSpace(svc.Name) == "" {
+					errs = append(errs, fmt.Errorf("oidc group %q: a service on host %q has no name", groupName, hostName))
+					continue
+				}
+				if svc.Permissions != nil && len(svc.Permissions) == 0 {
+					errs = append(errs, fmt.Errorf("oidc group %q: service %q on host %q must list at least one of %s",
+						groupName, svc.Name, hostName, strings.Join(AllPermissions, ", ")))
+				}
+				for _, permission := range svc.Permissions {
+					if !slices.Contains(AllPermissions, permission) {
+						errs = append(errs, fmt.Errorf("oidc group %q: service %q on host %q has unknown permission %q, must be one of %s",
+							groupName, svc.Name, hostName, permission, strings.Join(AllPermissions, ", ")))
+					}
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGroupService_Parsing(t *testing.T) {
+	data := []byte(`{
+		"hosts": [{"name": "nas", "address": "localhost"}],
+		"oidc": {
+			"groups": {
+				"household": {
+					"services": {
+						// Both shapes in the same list
+						"nas": ["jellyfin", {"name": "plex", "permissions": ["view", "logs"]}, {"name": "sonarr"}]
+					}
+				}
+			}
+		}
+	}`)
+
+	cfg, err := Parse(data, "services.json")
+	if err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	want := []GroupService{
+		{Name: "jellyfin"},
+		{Name: "plex", Permissions: []string{"view", "logs"}},
+		{Name: "sonarr"},
+	}
+	if got := cfg.OIDC.Groups["household"].Services["nas"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("services = %+v, want %+v", got, want)
+	}
+}
+
+func TestGroupService_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		service string
+		wantErr string
+	}{
+		{"unknown permission", `{"name": "plex", "permissions": ["view", "restart"]}`, `unknown permission "restart"`},
+		{"no permissions", `{"name": "plex", "permissions": []}`, "must list at least one of view, logs, control"},
+		{"no name", `{"permissions": ["view"]}`, "has no name"},
+		{"wrong shape", `42`, "must be a name or"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := `{"hosts": [{"name": "nas", "address": "localhost"}], "oidc": {"groups": {"household": {"services": {"nas": [` + tt.service + `]}}}}}`
+			_, err := Parse([]byte(data), "services.json")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGroupService_Granted(t *testing.T) {
+	tests := []struct {
+		service GroupService
+		want    []string
+	}{
+		{GroupService{Name: "plex"}, []string{"view", "logs", "control"}},
+		{GroupService{Name: "plex", Permissions: []string{"logs"}}, []string{"view", "logs"}},
+		{GroupService{Name: "plex", Permissions: []string{"control", "view"}}, []string{"view", "control"}},
+	}
+
+	for _, tt := range tests {
+		if got := tt.service.Granted(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v.Granted() = %v, want %v", tt.service, got, tt.want)
+		}
+	}
+}
+
+func TestGroupService_MarshalKeepsShape(t *testing.T) {
+	services := []GroupService{{Name: "jellyfin"}, {Name: "plex", Permissions: []string{"view"}}}
+	data, err := json.Marshal(services)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `["jellyfin",{"name":"plex","permissions":["view"]}]`; string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// LinkConfig is a static link shown on the dashboard alongside services, for
+// things that aren't services (a router admin page, an ISP status page, ...).
+type LinkConfig struct {
+	// Name is the link text.
+	Name string `json:"name"`
+	// URL is where the link goes (http or https).
+	URL string `json:"url"`
+	// Description is an optional tooltip.
+	Description string `json:"description,omitempty"`
+	// Icon is an optional Bootstrap icon name (e.g., "bi-router").
+	Icon string `json:"icon,omitempty"`
+	// Group places the link with the services of the project of the same
+	// name. Links without a group are listed on their own.
+	Group string `json:"group,omitempty"`
+	// AllowedGroups limits the link to users in any of these OIDC groups.
+	// Admins always see every link.
+	AllowedGroups []string `json:"allowed_groups,omitempty"`
+}
+
+// Link is a configured link together with where it was configured.
+type Link struct {
+	LinkConfig
+	// Host is the host the link was configured under (empty for global links).
+	Host string `json:"host,omitempty"`
+}
+
+// GetLinks returns the global links followed by each host's links, in config order.
+func (c *Config) GetLinks() []Link {
+	if c == nil {
+		return nil
+	}
+	var links []Link
+	for _, link := range c.Links {
+		links = append(links, Link{LinkConfig: link})
+	}
+	for _, host := range c.Hosts {
+		for _, link := range host.Links {
+			links = append(links, Link{LinkConfig: link, Host: host.Name})
+		}
+	}
+	return links
+}
+
+// validateLinks checks that every link has a name and an absolute http(s) URL.
+func (c *Config) validateLinks() error {
+	var errs []error
+	for _, link := range c.GetLinks() {
+		where := "links"
+		if link.Host != "" {
+			where = fmt.Sprintf("host %q links", link.Host)
+		}
+		if err := link.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", where, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validate checks a single link.
+func (l LinkConfig) validate() error {
+	if strings.TrimSpace(l.Name) == "" {
+		return fmt.Errorf("link to %q has no name", l.URL)
+	}
+	u, err := url.Parse(l.URL)
+	if err != nil {
+		return fmt.Errorf("link %q: invalid url: %w", l.Name, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("link %q: url %q must be an absolute http or https URL", l.Name, l.URL)
+	}
+	return nil
+}
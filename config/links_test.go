@@ -0,0 +1,104 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetLinks(t *testing.T) {
+	cfg := &Config{
+		Links: []LinkConfig{{Name: "Router", URL: "http://192.168.1.1"}},
+		Hosts: []HostConfig{
+			{Name: "nas", Address: "localhost", Links: []LinkConfig{{Name: "Paperless", URL: "https://paperless.lan", Group: "documents"}}},
+			{Name: "pi", Address: "192.168.1.20"},
+		},
+	}
+
+	links := cfg.GetLinks()
+	if len(links) != 2 {
+		t.Fatalf("GetLinks() returned %d links, want 2", len(links))
+	}
+	if links[0].Name != "Router" || links[0].Host != "" {
+		t.Errorf("links[0] = %+v, want the global Router link", links[0])
+	}
+	if links[1].Name != "Paperless" || links[1].Host != "nas" || links[1].Group != "documents" {
+		t.Errorf("links[1] = %+v, want Paperless on nas", links[1])
+	}
+
+	var nilCfg *Config
+	if nilCfg.GetLinks() != nil {
+		t.Error("GetLinks() on nil config should return nil")
+	}
+}
+
+func TestValidateLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		link    LinkConfig
+		wantErr string
+	}{
+		{"valid http", LinkConfig{Name: "Router", URL: "http://192.168.1.1"}, ""},
+		{"valid https with path", LinkConfig{Name: "ISP", URL: "https://status.example.com/area?id=4"}, ""},
+		{"missing name", LinkConfig{URL: "http://192.168.1.1"}, "has no name"},
+		{"blank name", LinkConfig{Name: "  ", URL: "http://192.168.1.1"}, "has no name"},
+		{"relative url", LinkConfig{Name: "Docs", URL: "/docs"}, "absolute http or https"},
+		{"missing url", LinkConfig{Name: "Docs"}, "absolute http or https"},
+		{"javascript url", LinkConfig{Name: "Evil", URL: "javascript:alert(1)"}, "absolute http or https"},
+		{"unparseable url", LinkConfig{Name: "Bad", URL: "http://[::1"}, "invalid url"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Hosts: []HostConfig{{Name: "nas", Address: "localhost", Links: []LinkConfig{tt.link}}}}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), `host "nas" links`) {
+				t.Errorf("Validate() = %v, want the host named", err)
+			}
+		})
+	}
+}
+
+func TestLoad_Links(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "services.json")
+	data := `{
+		"links": [
+			// Only for the family group
+			{"name": "Photos", "url": "https://photos.example.com", "icon": "bi-images", "allowed_groups": ["family"]},
+		],
+		"hosts": [
+			{"name": "nas", "address": "localhost", "links": [{"name": "Router", "url": "ftp://192.168.1.1"}]},
+		],
+	}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), `link "Router"`) {
+		t.Fatalf("Load() = %v, want invalid Router link", err)
+	}
+
+	data = strings.Replace(data, "ftp://", "http://", 1)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	links := cfg.GetLinks()
+	if len(links) != 2 || links[0].Icon != "bi-images" || len(links[0].AllowedGroups) != 1 {
+		t.Errorf("GetLinks() = %+v", links)
+	}
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// OutboundConfig sets how the dashboard connects to HTTP APIs: Traefik,
+// Home Assistant and its Supervisor, Watchtower, Gotify, the OIDC provider
+// and health probes. Set globally, it applies to every connection; set on a
+// host, its fields override the global ones for that host's APIs.
+type OutboundConfig struct {
+	// CAFile is a PEM file of CA certificates trusted besides the system's,
+	// for APIs whose certificates are issued by an internal CA.
+	CAFile string `json:"ca_file,omitempty"`
+	// ProxyURL is the HTTP proxy requests go through, e.g.
+	// "http://proxy.lan:3128", instead of the one in HTTPS_PROXY and
+	// HTTP_PROXY. Loopback addresses and hosts in NO_PROXY are reached
+	// directly.
+	ProxyURL string `json:"proxy_url,omitempty"`
+	// Insecure skips TLS certificate verification. Prefer ca_file; this is a
+	// last resort for certificates that can't be verified at all.
+	Insecure *bool `json:"insecure,omitempty"`
+
+	// pool is CAFile loaded on top of the system pool, set by Load.
+	pool *x509.CertPool
+}
+
+// Outbound is what one connection to an HTTP API uses, resolved from the
+// global and host outbound settings.
+type Outbound struct {
+	// RootCAs are the CAs trusted, or nil for the system's.
+	RootCAs *x509.CertPool
+	// ProxyURL is the proxy to use, or nil to take it from the environment.
+	ProxyURL *url.URL
+	// Insecure skips TLS certificate verification.
+	Insecure bool
+}
+
+// OutboundFor returns the outbound settings of connections to the APIs of
+// host, or the global ones if host is nil. Safe to call on a nil Config.
+func (c *Config) OutboundFor(host *HostConfig) Outbound {
+	var out Outbound
+	if c != nil {
+		c.Outbound.apply(&out)
+	}
+	if host != nil {
+		host.Outbound.apply(&out)
+	}
+	return out
+}
+
+// apply sets the fields of o configured in these settings, leaving the
+// others as they are.
+func (o *OutboundConfig) apply(out *Outbound) {
+	if o == nil {
+		return
+	}
+	if o.CAFile != "" {
+		pool := o.pool
+		if pool == nil {
+			// Not loaded with Load; Validate reports files that can't be used
+			pool, _ = loadCAPool(o.CAFile)
+		}
+		out.RootCAs = pool
+	}
+	if o.ProxyURL != "" {
+		out.ProxyURL, _ = parseProxyURL(o.ProxyURL)
+	}
+	if o.Insecure != nil {
+		out.Insecure = *o.Insecure
+	}
+}
+
+// loadCAPool returns the system's CA pool with the certificates of the PEM
+// file at path added.
+func loadCAPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s contains no PEM certificates", path)
+	}
+	return pool, nil
+}
+
+// parseProxyURL parses the URL of an HTTP, HTTPS or SOCKS5 proxy.
+func parseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("proxy URL %q must start with http://, https:// or socks5://", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", raw)
+	}
+	return u, nil
+}
+
+// load loads the CA file of these settings, if any.
+func (o *OutboundConfig) load() {
+	if o != nil && o.CAFile != "" {
+		o.pool, _ = loadCAPool(o.CAFile) // Validate checked the file
+	}
+}
+
+// validate checks that the CA file can be loaded and the proxy URL parsed.
+func (o *OutboundConfig) validate() error {
+	if o == nil {
+		return nil
+	}
+	var errs []error
+	if o.CAFile != "" {
+		if _, err := loadCAPool(o.CAFile); err != nil {
+			errs = append(errs, fmt.Errorf("ca_file: %w", err))
+		}
+	}
+	if o.ProxyURL != "" {
+		if _, err := parseProxyURL(o.ProxyURL); err != nil {
+			errs = append(errs, fmt.Errorf("proxy_url: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateOutbound checks the global outbound settings and those of every
+// host.
+func (c *Config) validateOutbound() error {
+	var errs []error
+	if err := c.Outbound.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("outbound: %w", err))
+	}
+	for _, host := range c.Hosts {
+		if err := host.Outbound.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("host %q: outbound: %w", host.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeCAFile writes the certificate of a test TLS server to a PEM file and
+// returns its path.
+func writeCAFile(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOutboundFor(t *testing.T) {
+	caFile := writeCAFile(t)
+	path := filepath.Join(t.TempDir(), "services.json")
+	data := `{
+		"outbound": {"ca_file": "` + caFile + `", "proxy_url": "http://proxy.lan:3128", "insecure": true},
+		"hosts": [
+			{"name": "nas", "address": "localhost"},
+			{"name": "pi", "address": "192.168.1.20", "outbound": {"proxy_url": "http://pi-proxy.lan:8080", "insecure": false}}
+		]
+	}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	global := cfg.OutboundFor(nil)
+	if global.RootCAs == nil || global.ProxyURL.String() != "http://proxy.lan:3128" || !global.Insecure {
+		t.Errorf("OutboundFor(nil) = %+v, want the global settings", global)
+	}
+	if nas := cfg.OutboundFor(cfg.GetHostByName("nas")); nas.ProxyURL.String() != "http://proxy.lan:3128" || nas.RootCAs != global.RootCAs {
+		t.Errorf("OutboundFor(nas) = %+v, want the global settings", nas)
+	}
+	pi := cfg.OutboundFor(cfg.GetHostByName("pi"))
+	if pi.ProxyURL.String() != "http://pi-proxy.lan:8080" || pi.Insecure {
+		t.Errorf("OutboundFor(pi) = %+v, want its own proxy with certificate checks", pi)
+	}
+	if pi.RootCAs != global.RootCAs {
+		t.Error("OutboundFor(pi) doesn't keep the global CA file it doesn't override")
+	}
+
+	var nilCfg *Config
+	if out := nilCfg.OutboundFor(nil); out.RootCAs != nil || out.ProxyURL != nil || out.Insecure {
+		t.Errorf("OutboundFor() on nil config = %+v, want the defaults", out)
+	}
+}
+
+func TestValidate_Outbound(t *testing.T) {
+	notPEM := filepath.Join(t.TempDir(), "ca.txt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr string
+	}{
+		{"missing CA file", &Config{Outbound: &OutboundConfig{CAFile: "/nonexistent/ca.pem"}}, "outbound: ca_file"},
+		{"not PEM", &Config{Outbound: &OutboundConfig{CAFile: notPEM}}, "contains no PEM certificates"},
+		{"proxy scheme", &Config{Outbound: &OutboundConfig{ProxyURL: "ftp://proxy.lan"}}, "must start with http://"},
+		{"host proxy", &Config{Hosts: []HostConfig{{Name: "pi", Address: "192.168.1.20", Outbound: &OutboundConfig{ProxyURL: "http://"}}}}, `host "pi": outbound: proxy_url`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Probe defaults and limits.
+const (
+	DefaultProbeInterval = 30 * time.Second
+	DefaultProbeTimeout  = 5 * time.Second
+	MinProbeInterval     = 5 * time.Second
+)
+
+// ProbeContainerHost is the host name of a probe URL that means the
+// container's own port, e.g. "http://container:8080/health". The probe goes
+// to the host port it is published on.
+const ProbeContainerHost = "container"
+
+// ProbeSpec is a custom health probe of a service: an HTTP GET of URL every
+// Interval, which passes if it answers within Timeout with ExpectStatus, or
+// with any 2xx or 3xx status if ExpectStatus is 0.
+type ProbeSpec struct {
+	URL          string
+	Interval     time.Duration
+	Timeout      time.Duration
+	ExpectStatus int
+}
+
+// ParseProbeSpec builds a probe from its settings as Docker labels and
+// systemd_services options give them. interval and timeout are seconds
+// ("30") or durations ("30s"), and empty for the defaults; expectStatus is
+// an HTTP status code, or empty for any 2xx or 3xx. The interval is at least
+// MinProbeInterval and the timeout at most the interval.
+func ParseProbeSpec(rawURL, interval, timeout, expectStatus string) (ProbeSpec, error) {
+	spec := ProbeSpec{URL: strings.TrimSpace(rawURL), Interval: DefaultProbeInterval, Timeout: DefaultProbeTimeout}
+	u, err := url.Parse(spec.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ProbeSpec{}, fmt.Errorf("probe url %q is not an http or https URL", spec.URL)
+	}
+	if interval = strings.TrimSpace(interval); interval != "" {
+		if spec.Interval, err = parseProbeDuration(interval); err != nil {
+			return ProbeSpec{}, fmt.Errorf("probe interval: %w", err)
+		}
+	}
+	if timeout = strings.TrimSpace(timeout); timeout != "" {
+		if spec.Timeout, err = parseProbeDuration(timeout); err != nil {
+			return ProbeSpec{}, fmt.Errorf("probe timeout: %w", err)
+		}
+	}
+	if expectStatus = strings.TrimSpace(expectStatus); expectStatus != "" {
+		status, err := strconv.Atoi(expectStatus)
+		if err != nil || status < 100 || status > 599 {
+			return ProbeSpec{}, fmt.Errorf("probe expect_status %q is not an HTTP status code", expectStatus)
+		}
+		spec.ExpectStatus = status
+	}
+	spec.Interval = max(spec.Interval, MinProbeInterval)
+	spec.Timeout = min(spec.Timeout, spec.Interval)
+	return spec, nil
+}
+
+// parseProbeDuration parses a positive number of seconds or a duration.
+func parseProbeDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, convErr := strconv.Atoi(value)
+		if convErr != nil {
+			return 0, fmt.Errorf("%q is not a number of seconds or a duration", value)
+		}
+		d = time.Duration(seconds) * time.Second
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%q is not positive", value)
+	}
+	return d, nil
+}
+
+// Passes reports whether an answer with status passes the probe.
+func (p ProbeSpec) Passes(status int) bool {
+	if p.ExpectStatus != 0 {
+		return status == p.ExpectStatus
+	}
+	return status >= 200 && status < 400
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseProbeSpec(t *testing.T) {
+	tests := []struct {
+		name                           string
+		url, interval, timeout, status string
+		wantInterval, wantTimeout      time.Duration
+		wantStatus                     int
+		wantErr                        bool
+	}{
+		{"defaults", "http://container:8080/health", "", "", "", DefaultProbeInterval, DefaultProbeTimeout, 0, false},
+		{"seconds", "https://app.example.com/ping", "60", "3", "204", time.Minute, 3 * time.Second, 204, false},
+		{"durations", "http://10.0.0.5/health", "2m", "1500ms", "", 2 * time.Minute, 1500 * time.Millisecond, 0, false},
+		{"interval raised to the minimum", "http://10.0.0.5/health", "1", "", "", MinProbeInterval, MinProbeInterval, 0, false},
+		{"timeout capped by the interval", "http://10.0.0.5/health", "10", "30", "", 10 * time.Second, 10 * time.Second, 0, false},
+		{"not http", "tcp://10.0.0.5:5432", "", "", "", 0, 0, 0, true},
+		{"no host", "/health", "", "", "", 0, 0, 0, true},
+		{"bad interval", "http://10.0.0.5/health", "often", "", "", 0, 0, 0, true},
+		{"negative timeout", "http://10.0.0.5/health", "", "-1", "", 0, 0, 0, true},
+		{"bad status", "http://10.0.0.5/health", "", "", "700", 0, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := ParseProbeSpec(tt.url, tt.interval, tt.timeout, tt.status)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseProbeSpec() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if spec.Interval != tt.wantInterval || spec.Timeout != tt.wantTimeout || spec.ExpectStatus != tt.wantStatus {
+				t.Errorf("ParseProbeSpec() = %+v", spec)
+			}
+		})
+	}
+}
+
+func TestProbeSpec_Passes(t *testing.T) {
+	anyStatus := ProbeSpec{}
+	for status, want := range map[int]bool{200: true, 204: true, 301: true, 404: false, 500: false, 503: false} {
+		if got := anyStatus.Passes(status); got != want {
+			t.Errorf("Passes(%d) = %v, want %v", status, got, want)
+		}
+	}
+	exact := ProbeSpec{ExpectStatus: 401}
+	if !exact.Passes(401) || exact.Passes(200) {
+		t.Error("a probe expecting 401 should pass only on 401")
+	}
+}
+
+func TestParseServiceSpecProbe(t *testing.T) {
+	spec := ParseServiceSpec("app.service#8080:ro|name=App|probe=http://10.0.0.5:8080/health|probe_interval=60|probe_status=204")
+	if spec.UnitName != "app.service" || spec.DisplayName != "App" || !spec.ReadOnly {
+		t.Errorf("ParseServiceSpec() = %+v", spec)
+	}
+	if spec.Probe == nil || spec.Probe.URL != "http://10.0.0.5:8080/health" || spec.Probe.Interval != time.Minute || spec.Probe.ExpectStatus != 204 {
+		t.Errorf("Probe = %+v", spec.Probe)
+	}
+
+	// An invalid probe is ignored like other invalid options
+	if spec := ParseServiceSpec("app.service|probe=not a url"); spec.Probe != nil {
+		t.Errorf("Probe = %+v, want none", spec.Probe)
+	}
+	if spec := ParseServiceSpec("app.service|probe_interval=60"); spec.Probe != nil {
+		t.Errorf("Probe = %+v without a url", spec.Probe)
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+
+	"home_server_dashboard/redact"
+)
+
+// LogRedactionConfig masks secrets in streamed logs, replacing what its rules
+// match with "[REDACTED:<rule>]".
+type LogRedactionConfig struct {
+	// Enabled turns redaction on for every log stream. Admins may still ask
+	// for a stream unredacted with redact=false.
+	Enabled bool `json:"enabled"`
+	// Rules are extra patterns to redact, e.g. a dynamic DNS password. A rule
+	// named like a default rule replaces it.
+	Rules []redact.Rule `json:"rules,omitempty"`
+	// NoDefaultRules drops the default rules (AWS keys, bearer tokens and hex
+	// strings of 32 or more characters), leaving only Rules.
+	NoDefaultRules bool `json:"no_default_rules,omitempty"`
+}
+
+// rules returns the default rules, unless turned off, followed by the
+// configured ones.
+func (l *LogRedactionConfig) rules() []redact.Rule {
+	var rules []redact.Rule
+	overridden := make(map[string]bool, len(l.Rules))
+	for _, rule := range l.Rules {
+		overridden[rule.Name] = true
+	}
+	if !l.NoDefaultRules {
+		for _, rule := range redact.DefaultRules {
+			if !overridden[rule.Name] {
+				rules = append(rules, rule)
+			}
+		}
+	}
+	return append(rules, l.Rules...)
+}
+
+// LogRedactor returns the redactor applied to streamed logs, or nil if log
+// redaction is off. Safe to call on a nil Config.
+func (c *Config) LogRedactor() *redact.Redactor {
+	if c == nil || c.LogRedaction == nil || !c.LogRedaction.Enabled {
+		return nil
+	}
+	if c.redactor != nil {
+		return c.redactor
+	}
+	// Not loaded with Load; Validate reports rules that don't compile
+	r, _ := redact.New(c.LogRedaction.rules())
+	return r
+}
+
+// validateLogRedaction checks that every redaction rule compiles.
+func (c *Config) validateLogRedaction() error {
+	if c.LogRedaction == nil {
+		return nil
+	}
+	if _, err := redact.New(c.LogRedaction.rules()); err != nil {
+		return fmt.Errorf("log_redaction: %w", err)
+	}
+	return nil
+}
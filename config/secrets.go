@@ -0,0 +1,206 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// AgeKeyFileEnv names the environment variable holding the path to the age
+// identity file used to decrypt the secrets sidecar.
+const AgeKeyFileEnv = "AGE_KEY_FILE"
+
+// Errors returned when the encrypted secrets sidecar can't be loaded.
+var (
+	// ErrSecretsKeyMissing means the sidecar exists but no usable key was provided.
+	ErrSecretsKeyMissing = errors.New("missing decryption key")
+	// ErrSecretsCorrupt means the sidecar could not be decrypted or parsed.
+	ErrSecretsCorrupt = errors.New("corrupt secrets file")
+)
+
+// SecretsPath returns the encrypted secrets sidecar path for a config file.
+// For "services.json" this is "services.secrets.json.age" in the same directory.
+func SecretsPath(configPath string) string {
+	return strings.TrimSuffix(configPath, ".json") + ".secrets.json.age"
+}
+
+// SecretKeys returns the config keys whose values came from the encrypted
+// secrets sidecar (e.g., "hosts[nas].homeassistant.longlivedtoken").
+// Only the key paths are recorded, never the values.
+func (c *Config) SecretKeys() []string {
+	if c == nil {
+		return nil
+	}
+	return c.secretKeys
+}
+
+// loadSecrets decrypts the secrets sidecar at path using the identities in
+// the file named by AGE_KEY_FILE. Returns nil data if the sidecar doesn't exist.
+func loadSecrets(path string) ([]byte, error) {
+	encrypted, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	keyFile := os.Getenv(AgeKeyFileEnv)
+	if keyFile == "" {
+		return nil, fmt.Errorf("%s exists but %s is not set: %w", path, AgeKeyFileEnv, ErrSecretsKeyMissing)
+	}
+	keyData, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read age key file %s: %v: %w", keyFile, err, ErrSecretsKeyMissing)
+	}
+	identities, err := age.ParseIdentities(bytes.NewReader(keyData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse age key file %s: %v: %w", keyFile, err, ErrSecretsKeyMissing)
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(encrypted), identities...)
+	if err != nil {
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			return nil, fmt.Errorf("no key in %s can decrypt %s: %w", keyFile, path, ErrSecretsKeyMissing)
+		}
+		return nil, fmt.Errorf("failed to decrypt %s: %v: %w", path, err, ErrSecretsCorrupt)
+	}
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %v: %w", path, err, ErrSecretsCorrupt)
+	}
+
+	plaintext, err = standardizeJSON(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse decrypted %s: %v: %w", path, err, ErrSecretsCorrupt)
+	}
+	return plaintext, nil
+}
+
+// mergeSecrets overlays the decrypted secrets document on the main config
+// document. Objects are merged key by key and arrays of named objects (such
+// as hosts) are matched by their "name" field; any other value in the
+// secrets document replaces the one in the config. Returns the merged
+// document and the sorted key paths that came from the secrets document.
+func mergeSecrets(base, secrets []byte) ([]byte, []string, error) {
+	var baseDoc, secretsDoc any
+	if err := decodeJSON(base, &baseDoc); err != nil {
+		return nil, nil, err
+	}
+	if err := decodeJSON(secrets, &secretsDoc); err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", err, ErrSecretsCorrupt)
+	}
+	if _, ok := secretsDoc.(map[string]any); !ok {
+		return nil, nil, fmt.Errorf("secrets document must be a JSON object: %w", ErrSecretsCorrupt)
+	}
+
+	var keys []string
+	merged, err := mergeValue(baseDoc, secretsDoc, "", &keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(keys)
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, keys, nil
+}
+
+// mergeValue merges overlay into base at the given key path.
+func mergeValue(base, overlay any, path string, keys *[]string) (any, error) {
+	switch o := overlay.(type) {
+	case map[string]any:
+		b, ok := base.(map[string]any)
+		if !ok {
+			b = make(map[string]any)
+		}
+		for k, v := range o {
+			merged, err := mergeValue(b[k], v, joinKeyPath(path, k), keys)
+			if err != nil {
+				return nil, err
+			}
+			b[k] = merged
+		}
+		return b, nil
+
+	case []any:
+		if b, ok := base.([]any); ok && isNamedList(o) {
+			return mergeNamedList(b, o, path, keys)
+		}
+	}
+
+	*keys = append(*keys, path)
+	return overlay, nil
+}
+
+// mergeNamedList merges each named overlay element into the base element with
+// the same name. An overlay element without a match is an error, since it
+// usually means the name is misspelled.
+func mergeNamedList(base, overlay []any, path string, keys *[]string) (any, error) {
+	for _, item := range overlay {
+		fields := item.(map[string]any)
+		name := fields["name"].(string)
+		// The name only identifies the entry; it isn't a value from the secrets file
+		delete(fields, "name")
+
+		found := false
+		for i, existing := range base {
+			if m, ok := existing.(map[string]any); ok && m["name"] == name {
+				merged, err := mergeValue(m, fields, fmt.Sprintf("%s[%s]", path, name), keys)
+				if err != nil {
+					return nil, err
+				}
+				base[i] = merged
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("secrets entry %s[%s] does not match any entry in the config", path, name)
+		}
+	}
+	return base, nil
+}
+
+// isNamedList reports whether every element is an object with a string "name".
+func isNamedList(items []any) bool {
+	if len(items) == 0 {
+		return false
+	}
+	for _, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return false
+		}
+		if _, ok := m["name"].(string); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeJSON decodes data into v, keeping numbers as json.Number so they
+// survive re-encoding unchanged.
+func decodeJSON(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// joinKeyPath appends a key to a dotted key path.
+func joinKeyPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,260 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+const secretsTestConfig = `{
+	// Main config kept in git; tokens live in the encrypted sidecar
+	"hosts": [
+		{"name": "nas", "address": "localhost", "homeassistant": {"port": 8123, "longlivedtoken": "placeholder"}},
+		{"name": "pi", "address": "192.168.1.20", "watchtower": {"port": 8081}},
+	],
+	"oidc": {"service_url": "https://dash.example.com", "config_url": "https://idp.example.com", "client_id": "dashboard"},
+	"gotify": {"enabled": true, "hostname": "https://gotify.example.com"},
+}`
+
+// writeSecretsFixture writes the config and an age-encrypted sidecar with the
+// given plaintext to a temp directory, returning the config path and key file path.
+func writeSecretsFixture(t *testing.T, plaintext string) (configPath, keyPath string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("GenerateX25519Identity() = %v", err)
+	}
+	keyPath = filepath.Join(dir, "key.txt")
+	if err := os.WriteFile(keyPath, []byte(identity.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	configPath = filepath.Join(dir, "services.json")
+	if err := os.WriteFile(configPath, []byte(secretsTestConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(SecretsPath(configPath), encryptFor(t, identity.Recipient(), plaintext), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return configPath, keyPath
+}
+
+func encryptFor(t *testing.T, recipient age.Recipient, plaintext string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipient)
+	if err != nil {
+		t.Fatalf("age.Encrypt() = %v", err)
+	}
+	if _, err := w.Write([]byte(plaintext)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSecretsPath(t *testing.T) {
+	if got := SecretsPath("/etc/dashboard/services.json"); got != "/etc/dashboard/services.secrets.json.age" {
+		t.Errorf("SecretsPath() = %q", got)
+	}
+}
+
+func TestLoad_MergesEncryptedSecrets(t *testing.T) {
+	configPath, keyPath := writeSecretsFixture(t, `{
+		"hosts": [
+			{"name": "pi", "watchtower": {"token": "wt-secret"}},
+			{"name": "nas", "homeassistant": {"longlivedtoken": "ha-secret"}},
+		],
+		"oidc": {"client_secret": "oidc-secret"},
+		"gotify": {"token": "gotify-secret"},
+	}`)
+	t.Setenv(AgeKeyFileEnv, keyPath)
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+
+	// Secrets override placeholders and fill in missing values
+	if got := cfg.Hosts[0].HomeAssistant.LongLivedToken; got != "ha-secret" {
+		t.Errorf("nas longlivedtoken = %q, want ha-secret", got)
+	}
+	if got := cfg.Hosts[1].Watchtower.Token; got != "wt-secret" {
+		t.Errorf("pi watchtower token = %q, want wt-secret", got)
+	}
+	if cfg.OIDC.ClientSecret != "oidc-secret" {
+		t.Errorf("oidc client_secret = %q, want oidc-secret", cfg.OIDC.ClientSecret)
+	}
+	if cfg.Gotify.Token != "gotify-secret" {
+		t.Errorf("gotify token = %q, want gotify-secret", cfg.Gotify.Token)
+	}
+
+	// Values only in the main config are kept, and host order is unchanged
+	if cfg.Hosts[0].Name != "nas" || cfg.Hosts[0].HomeAssistant.Port != 8123 {
+		t.Errorf("nas host = %+v, want main config values kept", cfg.Hosts[0])
+	}
+	if cfg.Hosts[1].Watchtower.Port != 8081 {
+		t.Errorf("pi watchtower port = %d, want 8081", cfg.Hosts[1].Watchtower.Port)
+	}
+	if cfg.OIDC.ClientID != "dashboard" {
+		t.Errorf("oidc client_id = %q, want dashboard", cfg.OIDC.ClientID)
+	}
+
+	wantKeys := []string{
+		"gotify.token",
+		"hosts[nas].homeassistant.longlivedtoken",
+		"hosts[pi].watchtower.token",
+		"oidc.client_secret",
+	}
+	if got := cfg.SecretKeys(); !reflect.DeepEqual(got, wantKeys) {
+		t.Errorf("SecretKeys() = %v, want %v", got, wantKeys)
+	}
+}
+
+func TestLoad_NoSecretsFile(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "services.json")
+	if err := os.WriteFile(configPath, []byte(secretsTestConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(AgeKeyFileEnv, "")
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if keys := cfg.SecretKeys(); len(keys) != 0 {
+		t.Errorf("SecretKeys() = %v, want none", keys)
+	}
+	if cfg.Hosts[0].HomeAssistant.LongLivedToken != "placeholder" {
+		t.Errorf("longlivedtoken = %q, want placeholder", cfg.Hosts[0].HomeAssistant.LongLivedToken)
+	}
+}
+
+func TestLoad_SecretsErrors(t *testing.T) {
+	t.Run("key file not set", func(t *testing.T) {
+		configPath, _ := writeSecretsFixture(t, `{"gotify": {"token": "x"}}`)
+		t.Setenv(AgeKeyFileEnv, "")
+
+		_, err := Load(configPath)
+		if !errors.Is(err, ErrSecretsKeyMissing) {
+			t.Fatalf("Load() = %v, want ErrSecretsKeyMissing", err)
+		}
+		if !strings.Contains(err.Error(), AgeKeyFileEnv) {
+			t.Errorf("error %q should mention %s", err, AgeKeyFileEnv)
+		}
+	})
+
+	t.Run("key file unreadable", func(t *testing.T) {
+		configPath, _ := writeSecretsFixture(t, `{"gotify": {"token": "x"}}`)
+		t.Setenv(AgeKeyFileEnv, filepath.Join(t.TempDir(), "missing.txt"))
+
+		if _, err := Load(configPath); !errors.Is(err, ErrSecretsKeyMissing) {
+			t.Fatalf("Load() = %v, want ErrSecretsKeyMissing", err)
+		}
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		configPath, _ := writeSecretsFixture(t, `{"gotify": {"token": "x"}}`)
+		other, err := age.GenerateX25519Identity()
+		if err != nil {
+			t.Fatal(err)
+		}
+		otherKey := filepath.Join(t.TempDir(), "other.txt")
+		if err := os.WriteFile(otherKey, []byte(other.String()), 0600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv(AgeKeyFileEnv, otherKey)
+
+		_, err = Load(configPath)
+		if !errors.Is(err, ErrSecretsKeyMissing) {
+			t.Fatalf("Load() = %v, want ErrSecretsKeyMissing", err)
+		}
+		if errors.Is(err, ErrSecretsCorrupt) {
+			t.Errorf("wrong key reported as corrupt: %v", err)
+		}
+	})
+
+	t.Run("corrupt ciphertext", func(t *testing.T) {
+		configPath, keyPath := writeSecretsFixture(t, `{"gotify": {"token": "x"}}`)
+		t.Setenv(AgeKeyFileEnv, keyPath)
+
+		// Flip a byte in the payload so authentication fails
+		data, err := os.ReadFile(SecretsPath(configPath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[len(data)-1] ^= 0xff
+		if err := os.WriteFile(SecretsPath(configPath), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = Load(configPath)
+		if !errors.Is(err, ErrSecretsCorrupt) {
+			t.Fatalf("Load() = %v, want ErrSecretsCorrupt", err)
+		}
+		if errors.Is(err, ErrSecretsKeyMissing) {
+			t.Errorf("corrupt file reported as missing key: %v", err)
+		}
+	})
+
+	t.Run("not an age file", func(t *testing.T) {
+		configPath, keyPath := writeSecretsFixture(t, `{}`)
+		t.Setenv(AgeKeyFileEnv, keyPath)
+		if err := os.WriteFile(SecretsPath(configPath), []byte(`{"gotify": {"token": "plain"}}`), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := Load(configPath); !errors.Is(err, ErrSecretsCorrupt) {
+			t.Fatalf("Load() = %v, want ErrSecretsCorrupt", err)
+		}
+	})
+
+	t.Run("decrypted content is not JSON", func(t *testing.T) {
+		configPath, keyPath := writeSecretsFixture(t, `gotify.token = x`)
+		t.Setenv(AgeKeyFileEnv, keyPath)
+
+		if _, err := Load(configPath); !errors.Is(err, ErrSecretsCorrupt) {
+			t.Fatalf("Load() = %v, want ErrSecretsCorrupt", err)
+		}
+	})
+
+	t.Run("unknown host", func(t *testing.T) {
+		configPath, keyPath := writeSecretsFixture(t, `{"hosts": [{"name": "nsa", "watchtower": {"token": "x"}}]}`)
+		t.Setenv(AgeKeyFileEnv, keyPath)
+
+		_, err := Load(configPath)
+		if err == nil || !strings.Contains(err.Error(), "hosts[nsa]") {
+			t.Fatalf("Load() = %v, want error naming hosts[nsa]", err)
+		}
+	})
+}
+
+func TestMergeSecrets_ReplacesNonNamedValues(t *testing.T) {
+	base := []byte(`{"allowed_origins": ["https://a.example.com"], "port": 9001, "hosts": [{"name": "nas", "nic": ["eth0"]}]}`)
+	secrets := []byte(`{"allowed_origins": ["https://b.example.com"], "hosts": [{"name": "nas", "nic": ["eth1"]}]}`)
+
+	merged, keys, err := mergeSecrets(base, secrets)
+	if err != nil {
+		t.Fatalf("mergeSecrets() = %v", err)
+	}
+
+	want := `{"allowed_origins":["https://b.example.com"],"hosts":[{"name":"nas","nic":["eth1"]}],"port":9001}`
+	if string(merged) != want {
+		t.Errorf("merged = %s, want %s", merged, want)
+	}
+	if !reflect.DeepEqual(keys, []string{"allowed_origins", "hosts[nas].nic"}) {
+		t.Errorf("keys = %v", keys)
+	}
+}
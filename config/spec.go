@@ -0,0 +1,183 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ServiceSpec is a parsed systemd_services entry: the unit it names plus the
+// options given alongside it. Providers and handlers work from specs so that
+// the entry syntax is only understood here.
+type ServiceSpec struct {
+	// UnitName is the unit name (e.g., "docker.service")
+	UnitName string
+	// User is the username for user-level systemd services (empty for system services)
+	// When set, the service is managed via `systemctl --user` instead of system D-Bus.
+	User string
+	// ReadOnly if true, disables start/stop/restart actions for ALL users
+	ReadOnly bool
+	// Ports are the port numbers advertised for this service in the UI
+	Ports []uint16
+	// DisplayName is an optional friendly name shown in the UI instead of the unit name.
+	// It is presentation only; actions and access control always use UnitName.
+	DisplayName string
+	// LogTail is how many lines of history the log viewer shows for the unit,
+	// overriding the global log_tail (0 uses the global default).
+	LogTail int
+	// LogTimestamps, if set to false, leaves the timestamps out of the unit's log lines.
+	LogTimestamps *bool
+	// Probe is a custom health probe of the unit, nil if it has none.
+	Probe *ProbeSpec
+}
+
+// ParseServiceSpec parses a systemd_services entry into a ServiceSpec.
+// Recognizes the following formats:
+//   - "servicename.service" - system service
+//   - "servicename.service:ro" - system service, read-only
+//   - "servicename.service#8080" - system service with port
+//   - "servicename.service#8080,8443" - system service with multiple ports
+//   - "servicename.service#8080:ro" - read-only service with port
+//   - "username:servicename.service" - user service
+//   - "username:servicename.service:ro" - user service, read-only
+//   - "username:servicename.service#8080" - user service with port
+//   - "username:servicename.service#8080,8443:ro" - user service with ports, read-only
+//   - "servicename.service#8080:ro|name=Web Server" - options after "|", such as a display name
+//   - "servicename.service|tail=500|timestamps=false" - log viewer defaults for the unit
+//   - "servicename.service|probe=http://10.0.0.5:8080/health|probe_interval=60" - a custom health probe (see ParseProbeSpec)
+//
+// The parser splits off "|options" first, then strips ":ro" (repeated suffixes
+// are treated as one), then "#ports", then checks for a "username:" prefix.
+// Options are "key=value" pairs separated by "|"; unknown keys and invalid
+// values (a tail that isn't a positive number, timestamps that isn't a boolean,
+// a probe that ParseProbeSpec rejects) are ignored.
+// A username prefix is identified by finding a colon before a dot (systemd units always
+// have an extension like .service, .timer, .socket, etc.).
+// An entry with no unit name (e.g., "" or ":ro") yields an empty UnitName.
+//
+// Examples:
+//   - "docker.service" returns {UnitName: "docker.service", User: "", ReadOnly: false}
+//   - "nas-dashboard.service:ro" returns {UnitName: "nas-dashboard.service", User: "", ReadOnly: true}
+//   - "xero:zunesync.service" returns {UnitName: "zunesync.service", User: "xero", ReadOnly: false}
+//   - "xero:zunesync.service:ro" returns {UnitName: "zunesync.service", User: "xero", ReadOnly: true}
+//   - "nginx.service|name=Web Server" returns {UnitName: "nginx.service", DisplayName: "Web Server"}
+func ParseServiceSpec(entry string) ServiceSpec {
+	result := ServiceSpec{}
+	entry = strings.TrimSpace(entry)
+
+	// Split off |key=value options; everything before the first | is the unit spec
+	if pipeIdx := strings.Index(entry, "|"); pipeIdx >= 0 {
+		probe := make(map[string]string)
+		for _, opt := range strings.Split(entry[pipeIdx+1:], "|") {
+			key, value, ok := strings.Cut(opt, "=")
+			if !ok {
+				continue
+			}
+			switch strings.TrimSpace(key) {
+			case "name":
+				result.DisplayName = strings.TrimSpace(value)
+			case "tail":
+				if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && n > 0 {
+					result.LogTail = n
+				}
+			case "timestamps":
+				if b, err := strconv.ParseBool(strings.TrimSpace(value)); err == nil {
+					result.LogTimestamps = &b
+				}
+			case "probe", "probe_interval", "probe_timeout", "probe_status":
+				probe[strings.TrimSpace(key)] = value
+			}
+		}
+		if probe["probe"] != "" {
+			if spec, err := ParseProbeSpec(probe["probe"], probe["probe_interval"], probe["probe_timeout"], probe["probe_status"]); err == nil {
+				result.Probe = &spec
+			}
+		}
+		entry = strings.TrimSpace(entry[:pipeIdx])
+	}
+
+	// Check for :ro suffix
+	for strings.HasSuffix(entry, ":ro") {
+		entry = strings.TrimSuffix(entry, ":ro")
+		result.ReadOnly = true
+	}
+
+	// Check for #ports suffix (comma-separated port numbers)
+	if hashIdx := strings.LastIndex(entry, "#"); hashIdx > 0 {
+		portStr := entry[hashIdx+1:]
+		entry = entry[:hashIdx]
+
+		// Parse comma-separated port numbers
+		portParts := strings.Split(portStr, ",")
+		for _, portPart := range portParts {
+			portPart = strings.TrimSpace(portPart)
+			if portPart == "" {
+				continue
+			}
+			var port uint64
+			if _, err := fmt.Sscanf(portPart, "%d", &port); err == nil && port > 0 && port <= 65535 {
+				result.Ports = append(result.Ports, uint16(port))
+			}
+		}
+	}
+
+	// Check for username:servicename format
+	// A username prefix exists if there's a colon before any dot
+	// (since systemd unit names must have an extension like .service)
+	colonIdx := strings.Index(entry, ":")
+	dotIdx := strings.Index(entry, ".")
+
+	if colonIdx > 0 && dotIdx > colonIdx {
+		// Format is "username:servicename.unit"
+		result.User = entry[:colonIdx]
+		result.UnitName = entry[colonIdx+1:]
+	} else {
+		// No user prefix, just the service name
+		result.UnitName = entry
+	}
+
+	return result
+}
+
+// GetServiceSpecs parses the SystemdServices list. Entries without a unit
+// name are skipped. Hosts from Load return specs parsed once at load time,
+// which must not be modified.
+func (h *HostConfig) GetServiceSpecs() []ServiceSpec {
+	if h.specs != nil {
+		return h.specs
+	}
+	return h.parseServiceSpecs()
+}
+
+// parseServiceSpecs parses the SystemdServices list.
+func (h *HostConfig) parseServiceSpecs() []ServiceSpec {
+	specs := make([]ServiceSpec, 0, len(h.SystemdServices))
+	for _, svc := range h.SystemdServices {
+		spec := ParseServiceSpec(svc)
+		if spec.UnitName == "" {
+			continue
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// GetServiceSpec returns the spec for a configured unit.
+func (h *HostConfig) GetServiceSpec(unitName string) (ServiceSpec, bool) {
+	for _, spec := range h.GetServiceSpecs() {
+		if spec.UnitName == unitName {
+			return spec, true
+		}
+	}
+	return ServiceSpec{}, false
+}
+
+// GetSystemdServiceNames returns just the unit names without any options.
+func (h *HostConfig) GetSystemdServiceNames() []string {
+	specs := h.GetServiceSpecs()
+	names := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		names = append(names, spec.UnitName)
+	}
+	return names
+}
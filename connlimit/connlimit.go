@@ -0,0 +1,145 @@
+// Package connlimit caps the number of concurrent SSH sessions opened to each
+// remote host, plus a global cap across all hosts. Small hosts often rate-limit
+// sshd (MaxStartups), so a burst of parallel polls at startup can look like the
+// host is down. Callers beyond the limit wait for a free slot instead of failing.
+package connlimit
+
+import (
+	"context"
+	"sync"
+)
+
+// Default limits.
+const (
+	DefaultPerHost = 2
+	DefaultGlobal  = 8
+)
+
+// Stats reports how many sessions are currently in use.
+type Stats struct {
+	Global int            `json:"global"`
+	Hosts  map[string]int `json:"hosts"` // keyed by host address
+}
+
+// Limiter is a per-host and global counting semaphore.
+type Limiter struct {
+	perHost int
+	global  chan struct{}
+
+	mu    sync.Mutex
+	hosts map[string]chan struct{}
+	inUse map[string]int
+}
+
+// New creates a Limiter allowing perHost concurrent sessions to each host and
+// global sessions in total. Non-positive values are treated as 1.
+func New(perHost, global int) *Limiter {
+	if perHost < 1 {
+		perHost = 1
+	}
+	if global < 1 {
+		global = 1
+	}
+	return &Limiter{
+		perHost: perHost,
+		global:  make(chan struct{}, global),
+		hosts:   make(map[string]chan struct{}),
+		inUse:   make(map[string]int),
+	}
+}
+
+// hostSem returns the semaphore for host, creating it if needed.
+func (l *Limiter) hostSem(host string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sem, ok := l.hosts[host]
+	if !ok {
+		sem = make(chan struct{}, l.perHost)
+		l.hosts[host] = sem
+	}
+	return sem
+}
+
+// Acquire waits for a session slot for host. It returns a release function
+// that must be called exactly once when the session ends, or ctx's error if
+// ctx is done before a slot frees up.
+func (l *Limiter) Acquire(ctx context.Context, host string) (func(), error) {
+	// Take the host slot first so a queue for one busy host doesn't hold
+	// global slots that other hosts could use
+	sem := l.hostSem(host)
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	select {
+	case l.global <- struct{}{}:
+	case <-ctx.Done():
+		<-sem
+		return nil, ctx.Err()
+	}
+
+	l.mu.Lock()
+	l.inUse[host]++
+	l.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.inUse[host]--
+			if l.inUse[host] == 0 {
+				delete(l.inUse, host)
+			}
+			l.mu.Unlock()
+			<-l.global
+			<-sem
+		})
+	}, nil
+}
+
+// Stats returns the number of sessions currently in use.
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	hosts := make(map[string]int, len(l.inUse))
+	for host, n := range l.inUse {
+		hosts[host] = n
+	}
+	return Stats{Global: len(l.global), Hosts: hosts}
+}
+
+// InUse returns the number of sessions currently in use for host.
+func (l *Limiter) InUse(host string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inUse[host]
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultLimiter = New(DefaultPerHost, DefaultGlobal)
+)
+
+// Configure replaces the default limiter. Sessions holding slots on the old
+// limiter release them there, so this is safe to call at any time.
+func Configure(perHost, global int) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultLimiter = New(perHost, global)
+}
+
+// Default returns the shared limiter used by the SSH-based providers.
+func Default() *Limiter {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultLimiter
+}
+
+// Acquire waits for a session slot for host on the default limiter.
+func Acquire(ctx context.Context, host string) (func(), error) {
+	return Default().Acquire(ctx, host)
+}
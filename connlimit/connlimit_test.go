@@ -0,0 +1,181 @@
+package connlimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeDialer records the peak number of concurrent sessions per host and overall.
+type fakeDialer struct {
+	limiter *Limiter
+
+	mu      sync.Mutex
+	active  map[string]int
+	total   int
+	peak    map[string]int
+	peakAll int
+}
+
+func newFakeDialer(l *Limiter) *fakeDialer {
+	return &fakeDialer{
+		limiter: l,
+		active:  make(map[string]int),
+		peak:    make(map[string]int),
+	}
+}
+
+func (d *fakeDialer) dial(ctx context.Context, host string) error {
+	release, err := d.limiter.Acquire(ctx, host)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	d.mu.Lock()
+	d.active[host]++
+	d.total++
+	if d.active[host] > d.peak[host] {
+		d.peak[host] = d.active[host]
+	}
+	if d.total > d.peakAll {
+		d.peakAll = d.total
+	}
+	d.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	d.mu.Lock()
+	d.active[host]--
+	d.total--
+	d.mu.Unlock()
+	return nil
+}
+
+func TestLimiter_BurstRespectsPerHostCeiling(t *testing.T) {
+	l := New(2, 100)
+	d := newFakeDialer(l)
+
+	var wg sync.WaitGroup
+	var failed atomic.Int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.dial(context.Background(), "10.0.0.5"); err != nil {
+				failed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if failed.Load() != 0 {
+		t.Errorf("%d calls failed; calls beyond the limit should queue", failed.Load())
+	}
+	if d.peak["10.0.0.5"] > 2 {
+		t.Errorf("peak concurrent sessions = %d, want <= 2", d.peak["10.0.0.5"])
+	}
+	if d.peak["10.0.0.5"] < 2 {
+		t.Errorf("peak concurrent sessions = %d, expected the limit to be reached", d.peak["10.0.0.5"])
+	}
+}
+
+func TestLimiter_BurstRespectsGlobalCeiling(t *testing.T) {
+	l := New(2, 3)
+	d := newFakeDialer(l)
+
+	hosts := []string{"a", "b", "c", "d", "e", "f"}
+	var wg sync.WaitGroup
+	for i := 0; i < 60; i++ {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			d.dial(context.Background(), host)
+		}(hosts[i%len(hosts)])
+	}
+	wg.Wait()
+
+	if d.peakAll > 3 {
+		t.Errorf("peak concurrent sessions across hosts = %d, want <= 3", d.peakAll)
+	}
+	for _, host := range hosts {
+		if d.peak[host] > 2 {
+			t.Errorf("peak concurrent sessions for %s = %d, want <= 2", host, d.peak[host])
+		}
+	}
+}
+
+func TestLimiter_AcquireHonorsContext(t *testing.T) {
+	l := New(1, 10)
+
+	release, err := l.Acquire(context.Background(), "nas")
+	if err != nil {
+		t.Fatalf("Acquire() = %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := l.Acquire(ctx, "nas"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire() while full = %v, want context.DeadlineExceeded", err)
+	}
+
+	// The failed wait must not leak a global slot
+	if got := l.Stats().Global; got != 1 {
+		t.Errorf("global in use = %d, want 1", got)
+	}
+}
+
+func TestLimiter_GlobalWaitReleasesHostSlot(t *testing.T) {
+	l := New(2, 1)
+
+	release, _ := l.Acquire(context.Background(), "a")
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, "b"); err == nil {
+		t.Fatal("expected Acquire to time out waiting for a global slot")
+	}
+
+	// Host b's slot was returned, so both of its slots are still available once global frees up
+	release()
+	r1, err := l.Acquire(context.Background(), "b")
+	if err != nil {
+		t.Fatalf("Acquire() = %v", err)
+	}
+	r1()
+}
+
+func TestLimiter_StatsAndDoubleRelease(t *testing.T) {
+	l := New(2, 4)
+
+	r1, _ := l.Acquire(context.Background(), "nas")
+	r2, _ := l.Acquire(context.Background(), "nas")
+	r3, _ := l.Acquire(context.Background(), "pi")
+
+	stats := l.Stats()
+	if stats.Global != 3 {
+		t.Errorf("Global = %d, want 3", stats.Global)
+	}
+	if stats.Hosts["nas"] != 2 || stats.Hosts["pi"] != 1 {
+		t.Errorf("Hosts = %v, want nas:2 pi:1", stats.Hosts)
+	}
+
+	r1()
+	r1() // second release is a no-op
+	if got := l.InUse("nas"); got != 1 {
+		t.Errorf("InUse(nas) = %d, want 1", got)
+	}
+
+	r2()
+	r3()
+	stats = l.Stats()
+	if stats.Global != 0 || len(stats.Hosts) != 0 {
+		t.Errorf("Stats after release = %+v, want empty", stats)
+	}
+}
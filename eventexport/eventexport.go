@@ -0,0 +1,286 @@
+// Package eventexport mirrors the events published on the event bus to other
+// processes as newline-delimited JSON, on a Unix socket that any number of
+// clients can connect to and on stdout. Scripts can react to events, such as
+// a host going down, without a webhook server. Delivery never holds up the
+// bus: a socket client that falls behind is disconnected, the way the
+// WebSocket hub drops slow browsers, and events stdout can't take in time are
+// dropped.
+package eventexport
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"home_server_dashboard/events"
+)
+
+// clientBuffer is how many events a client may fall behind by before it is
+// dropped.
+const clientBuffer = 256
+
+// Sink receives every exported event as a line of JSON.
+type Sink interface {
+	Send(line []byte)
+}
+
+// Exporter sends the events published on a bus to its sinks.
+type Exporter struct {
+	subs  []*events.Subscription
+	sinks []Sink
+}
+
+// Start subscribes sinks to every event published on bus. The bus may call
+// them from several goroutines at once, so events can arrive slightly out of
+// order; their time orders them. Without sinks nothing is subscribed.
+func Start(bus *events.Bus, sinks ...Sink) *Exporter {
+	e := &Exporter{sinks: sinks}
+	if len(sinks) == 0 {
+		return e
+	}
+	for _, eventType := range exportedTypes {
+		e.subs = append(e.subs, bus.Subscribe(eventType, e.send))
+	}
+	return e
+}
+
+// send encodes event and sends it to every sink.
+func (e *Exporter) send(event events.Event) {
+	line, ok := encode(event)
+	if !ok {
+		return
+	}
+	for _, sink := range e.sinks {
+		sink.Send(line)
+	}
+}
+
+// Stop unsubscribes from the bus. The sinks are left open.
+func (e *Exporter) Stop() {
+	for _, sub := range e.subs {
+		sub.Unsubscribe()
+	}
+	e.subs = nil
+}
+
+// stream writes lines to w from a buffer, in its own goroutine, so a slow
+// reader doesn't block the sender.
+type stream struct {
+	w       io.Writer
+	lines   chan []byte
+	done    chan struct{}
+	dropped atomic.Uint64
+}
+
+func newStream(w io.Writer) *stream {
+	s := &stream{w: w, lines: make(chan []byte, clientBuffer), done: make(chan struct{})}
+	go s.write()
+	return s
+}
+
+// write writes lines until the buffer is closed or a write fails.
+func (s *stream) write() {
+	defer close(s.done)
+	for line := range s.lines {
+		if _, err := s.w.Write(line); err != nil {
+			// Drain the rest so senders don't block
+			for range s.lines {
+			}
+			return
+		}
+	}
+}
+
+// offer queues line, returning false if the buffer is full.
+func (s *stream) offer(line []byte) bool {
+	select {
+	case s.lines <- line:
+		return true
+	default:
+		return false
+	}
+}
+
+// Writer is a Sink writing to an io.Writer such as stdout. Events that come
+// faster than it is read are dropped.
+type Writer struct {
+	mu     sync.Mutex
+	closed bool
+	stream *stream
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{stream: newStream(w)}
+}
+
+// Send queues line to be written, or drops it if too many are waiting.
+func (w *Writer) Send(line []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	if !w.stream.offer(line) {
+		w.stream.dropped.Add(1)
+	}
+}
+
+// Dropped returns how many events were dropped.
+func (w *Writer) Dropped() uint64 {
+	return w.stream.dropped.Load()
+}
+
+// Close writes the events still queued and stops.
+func (w *Writer) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.stream.lines)
+	}
+	w.mu.Unlock()
+	<-w.stream.done
+}
+
+// SocketStats counts the clients of a Socket.
+type SocketStats struct {
+	Clients int    `json:"clients"` // Connected now
+	Sent    uint64 `json:"sent"`    // Events queued to clients
+	Dropped uint64 `json:"dropped"` // Clients disconnected for falling behind
+}
+
+// Socket is a Sink serving events on a Unix socket. Each client connected
+// gets every event from when it connected.
+type Socket struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	clients map[net.Conn]*stream
+	sent    uint64
+	dropped uint64
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// Listen serves events on a Unix socket at path, created with mode. A socket
+// left behind at path, e.g. by a crash, is replaced.
+func Listen(path string, mode os.FileMode) (*Socket, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	s := &Socket{listener: listener, clients: make(map[net.Conn]*stream)}
+	s.wg.Add(1)
+	go s.accept()
+	return s, nil
+}
+
+// accept adds clients until the socket is closed.
+func (s *Socket) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Event export: accept failed: %v", err)
+			}
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		client := newStream(conn)
+		s.clients[conn] = client
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serve(conn, client)
+	}
+}
+
+// serve waits for a client to hang up or fail, then removes it. Clients
+// aren't expected to send anything; what they do is discarded.
+func (s *Socket) serve(conn net.Conn, client *stream) {
+	defer s.wg.Done()
+	hungUp := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, conn)
+		close(hungUp)
+	}()
+	select {
+	case <-hungUp:
+	case <-client.done:
+	}
+	s.remove(conn)
+	conn.Close()
+	<-hungUp
+}
+
+// remove stops sending to the client on conn, if it is still connected.
+func (s *Socket) remove(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if client, ok := s.clients[conn]; ok {
+		delete(s.clients, conn)
+		close(client.lines)
+	}
+}
+
+// Send queues line for every client. A client whose buffer is full is
+// disconnected.
+func (s *Socket) Send(line []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn, client := range s.clients {
+		if client.offer(line) {
+			s.sent++
+			continue
+		}
+		delete(s.clients, conn)
+		close(client.lines)
+		conn.Close()
+		s.dropped++
+		log.Printf("Event export: dropped a client that fell %d events behind", clientBuffer)
+	}
+}
+
+// Stats returns the socket's client counts.
+func (s *Socket) Stats() SocketStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SocketStats{Clients: len(s.clients), Sent: s.sent, Dropped: s.dropped}
+}
+
+// Close disconnects every client and removes the socket.
+func (s *Socket) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	for conn, client := range s.clients {
+		delete(s.clients, conn)
+		close(client.lines)
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
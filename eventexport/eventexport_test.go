@@ -0,0 +1,192 @@
+package eventexport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"home_server_dashboard/events"
+)
+
+// listen serves events on a socket in a temporary directory.
+func listen(t *testing.T) (*Socket, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "events.sock")
+	s, err := Listen(path, 0o660)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, path
+}
+
+// dial connects a client to the socket at path and waits until the socket
+// has want clients.
+func dial(t *testing.T, s *Socket, path string, want int) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	waitFor(t, func() bool { return s.Stats().Clients == want })
+	return conn
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// readRecord reads the next record from r.
+func readRecord(t *testing.T, r *bufio.Reader) map[string]any {
+	t.Helper()
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("reading an event: %v", err)
+	}
+	var record map[string]any
+	if err := json.Unmarshal(line, &record); err != nil {
+		t.Fatalf("event %q is not JSON: %v", line, err)
+	}
+	return record
+}
+
+func TestSocket_DeliversPublishedEvents(t *testing.T) {
+	s, path := listen(t)
+	if info, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0o660 {
+		t.Errorf("socket mode = %v, want 0660", info.Mode().Perm())
+	}
+	bus := events.NewBus(false)
+	exporter := Start(bus, s)
+	defer exporter.Stop()
+
+	first := bufio.NewReader(dial(t, s, path, 1))
+	second := bufio.NewReader(dial(t, s, path, 2))
+
+	bus.Publish(events.NewHostUnreachableEvent("nas", "connection refused"))
+	for _, r := range []*bufio.Reader{first, second} {
+		record := readRecord(t, r)
+		data, _ := record["data"].(map[string]any)
+		if record["schema"] != float64(SchemaVersion) || record["type"] != "host_unreachable" ||
+			data["host"] != "nas" || data["reason"] != "connection refused" {
+			t.Errorf("record = %v", record)
+		}
+	}
+	if stats := s.Stats(); stats.Sent != 2 {
+		t.Errorf("Stats() = %+v, want 2 sent", stats)
+	}
+}
+
+func TestSocket_SurvivesClientDisconnect(t *testing.T) {
+	s, path := listen(t)
+	bus := events.NewBus(false)
+	defer Start(bus, s).Stop()
+
+	gone := dial(t, s, path, 1)
+	stays := bufio.NewReader(dial(t, s, path, 2))
+	gone.Close()
+	waitFor(t, func() bool { return s.Stats().Clients == 1 })
+
+	bus.Publish(events.NewServiceStateChangedEvent("nas", "nginx", "docker", "running", "stopped", "Exited (0)"))
+	record := readRecord(t, stays)
+	if data, _ := record["data"].(map[string]any); data["service"] != "nginx" || data["current_state"] != "stopped" {
+		t.Errorf("record = %v", record)
+	}
+
+	// A client can still connect after one left
+	late := bufio.NewReader(dial(t, s, path, 2))
+	bus.Publish(events.NewHostRecoveredEvent("nas"))
+	if record := readRecord(t, late); record["type"] != "host_recovered" {
+		t.Errorf("record = %v", record)
+	}
+	if stats := s.Stats(); stats.Dropped != 0 {
+		t.Errorf("Stats() = %+v, want a client that left not counted as dropped", stats)
+	}
+}
+
+func TestSocket_DropsSlowClient(t *testing.T) {
+	s, path := listen(t)
+	bus := events.NewBus(false)
+	defer Start(bus, s).Stop()
+
+	// The slow client never reads; the fast one reads every event as it comes
+	slow := dial(t, s, path, 1)
+	fast := bufio.NewReader(dial(t, s, path, 2))
+
+	// Large events fill the slow client's socket buffers, then its queue
+	event := events.NewHostUnreachableEvent("nas", strings.Repeat("x", 4096))
+	for i := 0; i < 1000; i++ {
+		bus.Publish(event)
+		readRecord(t, fast)
+	}
+	stats := s.Stats()
+	if stats.Dropped != 1 || stats.Clients != 1 {
+		t.Errorf("Stats() = %+v, want the slow client dropped and the fast one kept", stats)
+	}
+
+	// The dropped client is hung up on after what was already sent to it
+	slow.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := bufio.NewReader(slow).WriteTo(bytes.NewBuffer(nil)); err != nil {
+		t.Errorf("dropped client wasn't hung up on: %v", err)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	bus := events.NewBus(false)
+	exporter := Start(bus, w)
+
+	started := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	bus.Publish(events.NewContainerStartedEvent("nas", "media-sonarr-1", "media", "sonarr", started))
+	bus.Publish(events.NewMaintenanceEndedEvent("nas", started, nil, false))
+	exporter.Stop()
+	bus.Publish(events.NewHostRecoveredEvent("nas"))
+	w.Close()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if w.Dropped() != 0 {
+		t.Errorf("Dropped() = %d", w.Dropped())
+	}
+	if len(lines) != 2 {
+		t.Fatalf("wrote %q, want the 2 events published before Stop", out.String())
+	}
+	if !strings.Contains(lines[0], `"data":{"host":"nas","container":"media-sonarr-1","project":"media","service":"sonarr","started_at":"2026-03-01T12:00:00Z"}`) {
+		t.Errorf("line = %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"type":"maintenance_ended"`) || !strings.Contains(lines[1], `"still_down":[]`) {
+		t.Errorf("line = %s", lines[1])
+	}
+}
+
+func TestNewRecord(t *testing.T) {
+	event := events.NewServiceHealthChangedEvent("nas", "db", "docker", "healthy", "unhealthy")
+	event.MarkMaintenance()
+	record, ok := NewRecord(event)
+	if !ok || !record.Maintenance || record.Schema != SchemaVersion {
+		t.Fatalf("NewRecord() = %+v, %v", record, ok)
+	}
+	if data := record.Data.(ServiceData); data.PreviousHealth != "healthy" || data.CurrentHealth != "unhealthy" {
+		t.Errorf("Data = %+v", data)
+	}
+
+	digest := events.NewDigestEvent(events.HostUnreachable, "", nil)
+	if _, ok := NewRecord(digest); ok {
+		t.Error("digests are never published, so they aren't exported")
+	}
+}
@@ -0,0 +1,149 @@
+package eventexport
+
+import (
+	"encoding/json"
+	"time"
+
+	"home_server_dashboard/events"
+)
+
+// SchemaVersion is the version of the records written, given in each as
+// "schema". It changes only when a field is renamed or removed or its
+// meaning changes; added fields and event types keep it.
+const SchemaVersion = 1
+
+// Record is one exported event, written as a line of JSON.
+type Record struct {
+	Schema      int              `json:"schema"`
+	Type        events.EventType `json:"type"`
+	Time        time.Time        `json:"time"`
+	Maintenance bool             `json:"maintenance,omitempty"` // The host is in a maintenance window, so the event isn't notified
+	Data        any              `json:"data"`                  // One of the *Data types, by Type
+}
+
+// ServiceData is the data of service_state_changed, service_removed,
+// service_restarted and service_health_changed events; the fields that
+// don't apply to the type are left out.
+type ServiceData struct {
+	Host           string `json:"host"`
+	Service        string `json:"service"`
+	Source         string `json:"source"`
+	PreviousState  string `json:"previous_state,omitempty"`
+	CurrentState   string `json:"current_state,omitempty"`
+	Status         string `json:"status,omitempty"`
+	Quiet          bool   `json:"quiet,omitempty"` // Expected change that isn't notified
+	PreviousHealth string `json:"previous_health,omitempty"`
+	CurrentHealth  string `json:"current_health,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+}
+
+// HostData is the data of host_unreachable and host_recovered events.
+type HostData struct {
+	Host   string `json:"host"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// DockerResourceData is the data of docker_resource_changed events.
+type DockerResourceData struct {
+	Host     string `json:"host"`
+	Resource string `json:"resource"` // "image" or "volume"
+	Action   string `json:"action"`
+	ID       string `json:"id"`
+}
+
+// ContainerStartedData is the data of container_started events.
+type ContainerStartedData struct {
+	Host      string    `json:"host"`
+	Container string    `json:"container"`
+	Project   string    `json:"project"`
+	Service   string    `json:"service"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// ResourceAlertData is the data of dashboard_resource_alert events.
+type ResourceAlertData struct {
+	Host     string `json:"host"`
+	Resource string `json:"resource"` // "goroutines" or "open_fds"
+	Value    int    `json:"value"`
+	Limit    int    `json:"limit"` // 0 for an alert about steady growth
+	Reason   string `json:"reason"`
+}
+
+// MaintenanceEndedData is the data of maintenance_ended events.
+type MaintenanceEndedData struct {
+	Host        string    `json:"host"`
+	Started     time.Time `json:"started"`
+	StillDown   []string  `json:"still_down"`
+	Unreachable bool      `json:"unreachable"`
+}
+
+// exportedTypes are the event types exported: every type that is published.
+var exportedTypes = []events.EventType{
+	events.ServiceStateChanged,
+	events.ServiceRemoved,
+	events.ServiceRestarted,
+	events.ServiceHealthChanged,
+	events.HostUnreachable,
+	events.HostRecovered,
+	events.DockerResourceChanged,
+	events.ContainerStarted,
+	events.DashboardResourceAlert,
+	events.MaintenanceEnded,
+}
+
+// NewRecord returns the record of event, or false for an event type that
+// isn't exported.
+func NewRecord(event events.Event) (Record, bool) {
+	record := Record{
+		Schema:      SchemaVersion,
+		Type:        event.Type(),
+		Time:        event.Timestamp().UTC(),
+		Maintenance: events.InMaintenance(event),
+	}
+	switch e := event.(type) {
+	case *events.ServiceStateChangedEvent:
+		record.Data = ServiceData{Host: e.Host, Service: e.ServiceName, Source: e.Source,
+			PreviousState: e.PreviousState, CurrentState: e.CurrentState, Status: e.Status, Quiet: e.Quiet}
+	case *events.ServiceRemovedEvent:
+		record.Data = ServiceData{Host: e.Host, Service: e.ServiceName, Source: e.Source, Reason: e.Reason}
+	case *events.ServiceRestartedEvent:
+		record.Data = ServiceData{Host: e.Host, Service: e.ServiceName, Source: e.Source, Reason: e.Reason}
+	case *events.ServiceHealthChangedEvent:
+		record.Data = ServiceData{Host: e.Host, Service: e.ServiceName, Source: e.Source,
+			PreviousHealth: e.PreviousHealth, CurrentHealth: e.CurrentHealth}
+	case *events.HostUnreachableEvent:
+		record.Data = HostData{Host: e.Host, Reason: e.Reason}
+	case *events.HostRecoveredEvent:
+		record.Data = HostData{Host: e.Host}
+	case *events.DockerResourceChangedEvent:
+		record.Data = DockerResourceData{Host: e.Host, Resource: e.Resource, Action: e.Action, ID: e.ID}
+	case *events.ContainerStartedEvent:
+		record.Data = ContainerStartedData{Host: e.Host, Container: e.ContainerName, Project: e.Project,
+			Service: e.Service, StartedAt: e.StartedAt.UTC()}
+	case *events.DashboardResourceAlertEvent:
+		record.Data = ResourceAlertData{Host: e.Host, Resource: e.Resource, Value: e.Value, Limit: e.Limit, Reason: e.Reason}
+	case *events.MaintenanceEndedEvent:
+		stillDown := e.StillDown
+		if stillDown == nil {
+			stillDown = []string{}
+		}
+		record.Data = MaintenanceEndedData{Host: e.Host, Started: e.Started.UTC(), StillDown: stillDown, Unreachable: e.Unreachable}
+	default:
+		return Record{}, false
+	}
+	return record, true
+}
+
+// encode returns the record of event as a line of JSON, or false for an
+// event type that isn't exported.
+func encode(event events.Event) ([]byte, bool) {
+	record, ok := NewRecord(event)
+	if !ok {
+		return nil, false
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return nil, false
+	}
+	return append(line, '\n'), true
+}
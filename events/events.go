@@ -4,6 +4,8 @@
 package events
 
 import (
+	"slices"
+	"sort"
 	"sync"
 	"time"
 )
@@ -18,6 +20,30 @@ const (
 	HostUnreachable EventType = "host_unreachable"
 	// HostRecovered is emitted when a previously unreachable host becomes reachable.
 	HostRecovered EventType = "host_recovered"
+	// ServiceRestarted is emitted when a service went down and came straight back up,
+	// such as a container restarted by its restart policy.
+	ServiceRestarted EventType = "service_restarted"
+	// ServiceHealthChanged is emitted when a service's health check result changes.
+	ServiceHealthChanged EventType = "service_health_changed"
+	// ServiceRemoved is emitted when the monitor forgets a service that is no
+	// longer reported, such as a deleted container.
+	ServiceRemoved EventType = "service_removed"
+	// DockerResourceChanged is emitted when a Docker image or volume is
+	// pulled, created or removed, so caches built from them can be dropped.
+	DockerResourceChanged EventType = "docker_resource_changed"
+	// ContainerStarted is emitted when a Docker compose container starts,
+	// including containers that didn't exist before, such as after a
+	// scale-up or a recreate.
+	ContainerStarted EventType = "container_started"
+	// DashboardResourceAlert is emitted when the dashboard's own goroutines or
+	// open files pass their limit or keep growing, e.g. because of a leak.
+	DashboardResourceAlert EventType = "dashboard_resource_alert"
+	// MaintenanceEnded is emitted when a host's maintenance window ends,
+	// listing what is still down after it.
+	MaintenanceEnded EventType = "maintenance_ended"
+	// NotificationDigest is a burst of events of one kind combined by the
+	// notifier manager into one notification. It is never published.
+	NotificationDigest EventType = "notification_digest"
 )
 
 // Event represents something that happened in the system.
@@ -30,13 +56,53 @@ type Event interface {
 
 // baseEvent provides common event fields.
 type baseEvent struct {
-	eventType EventType
-	timestamp time.Time
+	eventType   EventType
+	timestamp   time.Time
+	maintenance bool
 }
 
 func (e *baseEvent) Type() EventType     { return e.eventType }
 func (e *baseEvent) Timestamp() time.Time { return e.timestamp }
 
+// InMaintenance reports whether the event happened during a maintenance
+// window of its host. Notifiers skip such events; the UI still shows them.
+func (e *baseEvent) InMaintenance() bool { return e.maintenance }
+
+// MarkMaintenance tags the event as happening during a maintenance window.
+func (e *baseEvent) MarkMaintenance() { e.maintenance = true }
+
+// MaintenanceTagger is implemented by the events that can be tagged as
+// happening during a maintenance window of their host.
+type MaintenanceTagger interface {
+	InMaintenance() bool
+	MarkMaintenance()
+}
+
+// InMaintenance reports whether event was tagged as happening during a
+// maintenance window of its host.
+func InMaintenance(event Event) bool {
+	tagged, ok := event.(MaintenanceTagger)
+	return ok && tagged.InMaintenance()
+}
+
+// HostOf returns the host whose services or reachability event is about, or
+// "" for events that aren't silenced by its maintenance window.
+func HostOf(event Event) string {
+	switch e := event.(type) {
+	case *ServiceStateChangedEvent:
+		return e.Host
+	case *ServiceRestartedEvent:
+		return e.Host
+	case *ServiceHealthChangedEvent:
+		return e.Host
+	case *HostUnreachableEvent:
+		return e.Host
+	case *HostRecoveredEvent:
+		return e.Host
+	}
+	return ""
+}
+
 // ServiceStateChangedEvent is emitted when a service changes state.
 type ServiceStateChangedEvent struct {
 	baseEvent
@@ -46,6 +112,7 @@ type ServiceStateChangedEvent struct {
 	PreviousState string // Previous state (e.g., "running", "stopped", "unknown")
 	CurrentState  string // Current state
 	Status        string // Human-readable status message
+	Quiet         bool   // Expected change (e.g., into "starting") that notifiers don't alert on
 }
 
 // NewServiceStateChangedEvent creates a new service state changed event.
@@ -64,6 +131,29 @@ func NewServiceStateChangedEvent(host, serviceName, source, previousState, curre
 	}
 }
 
+// ServiceRemovedEvent is emitted when the monitor forgets a service.
+type ServiceRemovedEvent struct {
+	baseEvent
+	Host        string // Host name where the service ran
+	ServiceName string // Name of the service
+	Source      string // "docker", "systemd", etc.
+	Reason      string // Why it was forgotten, e.g. "no longer reported"
+}
+
+// NewServiceRemovedEvent creates a new service removed event.
+func NewServiceRemovedEvent(host, serviceName, source, reason string) *ServiceRemovedEvent {
+	return &ServiceRemovedEvent{
+		baseEvent: baseEvent{
+			eventType: ServiceRemoved,
+			timestamp: time.Now(),
+		},
+		Host:        host,
+		ServiceName: serviceName,
+		Source:      source,
+		Reason:      reason,
+	}
+}
+
 // HostUnreachableEvent is emitted when a host cannot be contacted.
 type HostUnreachableEvent struct {
 	baseEvent
@@ -100,6 +190,204 @@ func NewHostRecoveredEvent(host string) *HostRecoveredEvent {
 	}
 }
 
+// ServiceRestartedEvent is emitted when a service restarts without staying down.
+type ServiceRestartedEvent struct {
+	baseEvent
+	Host        string // Host name where the service runs
+	ServiceName string // Name of the service
+	Source      string // "docker" or "systemd" or "traefik"
+	Reason      string // Why it restarted (e.g., "exit code 1", "out of memory", "restart")
+}
+
+// NewServiceRestartedEvent creates a new service restarted event.
+func NewServiceRestartedEvent(host, serviceName, source, reason string) *ServiceRestartedEvent {
+	return &ServiceRestartedEvent{
+		baseEvent: baseEvent{
+			eventType: ServiceRestarted,
+			timestamp: time.Now(),
+		},
+		Host:        host,
+		ServiceName: serviceName,
+		Source:      source,
+		Reason:      reason,
+	}
+}
+
+// ServiceHealthChangedEvent is emitted when a service's health check result changes.
+type ServiceHealthChangedEvent struct {
+	baseEvent
+	Host           string // Host name where the service runs
+	ServiceName    string // Name of the service
+	Source         string // "docker", or "systemd" for a custom health probe
+	PreviousHealth string // Previous health (e.g., "healthy", "unhealthy", "starting", or "" if unknown)
+	CurrentHealth  string // Current health
+}
+
+// NewServiceHealthChangedEvent creates a new service health changed event.
+func NewServiceHealthChangedEvent(host, serviceName, source, previousHealth, currentHealth string) *ServiceHealthChangedEvent {
+	return &ServiceHealthChangedEvent{
+		baseEvent: baseEvent{
+			eventType: ServiceHealthChanged,
+			timestamp: time.Now(),
+		},
+		Host:           host,
+		ServiceName:    serviceName,
+		Source:         source,
+		PreviousHealth: previousHealth,
+		CurrentHealth:  currentHealth,
+	}
+}
+
+// Docker resources reported by DockerResourceChangedEvent.
+const (
+	ResourceImage  = "image"
+	ResourceVolume = "volume"
+)
+
+// DockerResourceChangedEvent is emitted when a Docker image or volume changes
+// outside the containers the dashboard tracks.
+type DockerResourceChangedEvent struct {
+	baseEvent
+	Host     string // Host name of the Docker daemon
+	Resource string // ResourceImage or ResourceVolume
+	Action   string // Docker event action, e.g. "pull", "delete", "create", "destroy"
+	ID       string // Image ID or reference, or volume name
+}
+
+// NewDockerResourceChangedEvent creates a new Docker resource changed event.
+func NewDockerResourceChangedEvent(host, resource, action, id string) *DockerResourceChangedEvent {
+	return &DockerResourceChangedEvent{
+		baseEvent: baseEvent{
+			eventType: DockerResourceChanged,
+			timestamp: time.Now(),
+		},
+		Host:     host,
+		Resource: resource,
+		Action:   action,
+		ID:       id,
+	}
+}
+
+// ContainerStartedEvent is emitted when a Docker compose container starts.
+type ContainerStartedEvent struct {
+	baseEvent
+	Host          string    // Host name of the Docker daemon
+	ContainerName string    // Container name, e.g. "media-sonarr-1"
+	Project       string    // Compose project
+	Service       string    // Compose service
+	StartedAt     time.Time // When Docker started the container
+}
+
+// NewContainerStartedEvent creates a new container started event.
+func NewContainerStartedEvent(host, containerName, project, service string, startedAt time.Time) *ContainerStartedEvent {
+	return &ContainerStartedEvent{
+		baseEvent: baseEvent{
+			eventType: ContainerStarted,
+			timestamp: time.Now(),
+		},
+		Host:          host,
+		ContainerName: containerName,
+		Project:       project,
+		Service:       service,
+		StartedAt:     startedAt,
+	}
+}
+
+// Dashboard resources reported by DashboardResourceAlertEvent.
+const (
+	ResourceGoroutines = "goroutines"
+	ResourceOpenFDs    = "open_fds"
+)
+
+// DashboardResourceAlertEvent is emitted when the dashboard's own use of a
+// resource passes its limit or has grown steadily over the trend window.
+type DashboardResourceAlertEvent struct {
+	baseEvent
+	Host     string // Host name the dashboard runs on
+	Resource string // ResourceGoroutines or ResourceOpenFDs
+	Value    int    // Current count
+	Limit    int    // Configured limit, or 0 for a growth alert
+	Reason   string // Human-readable description, e.g. "412 goroutines, above the limit of 400"
+}
+
+// NewDashboardResourceAlertEvent creates a new dashboard resource alert event.
+func NewDashboardResourceAlertEvent(host, resource string, value, limit int, reason string) *DashboardResourceAlertEvent {
+	return &DashboardResourceAlertEvent{
+		baseEvent: baseEvent{
+			eventType: DashboardResourceAlert,
+			timestamp: time.Now(),
+		},
+		Host:     host,
+		Resource: resource,
+		Value:    value,
+		Limit:    limit,
+		Reason:   reason,
+	}
+}
+
+// MaintenanceEndedEvent is emitted when a host's maintenance window ends,
+// whether it expired or was ended early.
+type MaintenanceEndedEvent struct {
+	baseEvent
+	Host        string    // Host name
+	Started     time.Time // When the window started
+	StillDown   []string  // Services that were up when it started and are down now
+	Unreachable bool      // Whether the host is still unreachable
+}
+
+// NewMaintenanceEndedEvent creates a new maintenance ended event.
+func NewMaintenanceEndedEvent(host string, started time.Time, stillDown []string, unreachable bool) *MaintenanceEndedEvent {
+	return &MaintenanceEndedEvent{
+		baseEvent: baseEvent{
+			eventType: MaintenanceEnded,
+			timestamp: time.Now(),
+		},
+		Host:        host,
+		Started:     started,
+		StillDown:   stillDown,
+		Unreachable: unreachable,
+	}
+}
+
+// AllClear reports whether nothing is down after the window.
+func (e *MaintenanceEndedEvent) AllClear() bool {
+	return len(e.StillDown) == 0 && !e.Unreachable
+}
+
+// DigestEvent combines events of the same kind, such as services that went
+// down or hosts that recovered within the same window, into one notification.
+type DigestEvent struct {
+	baseEvent
+	Kind    EventType // Type of the combined events
+	Outcome string    // State or health the combined events changed to, if any
+	Events  []Event   // The combined events, in the order they happened
+}
+
+// NewDigestEvent creates a digest of events of kind that changed to outcome.
+func NewDigestEvent(kind EventType, outcome string, combined []Event) *DigestEvent {
+	return &DigestEvent{
+		baseEvent: baseEvent{
+			eventType: NotificationDigest,
+			timestamp: time.Now(),
+		},
+		Kind:    kind,
+		Outcome: outcome,
+		Events:  combined,
+	}
+}
+
+// Hosts returns the hosts of the combined events, sorted, each once.
+func (e *DigestEvent) Hosts() []string {
+	var hosts []string
+	for _, event := range e.Events {
+		if host := HostOf(event); host != "" && !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
 // Handler is a function that handles an event.
 type Handler func(event Event)
 
@@ -154,10 +442,11 @@ func (b *Bus) Subscribe(eventType EventType, handler Handler) *Subscription {
 	return sub
 }
 
-// SubscribeAll registers a handler for all event types.
-// The handler will be called for every published event.
+// SubscribeAll registers a handler for all service and host event types,
+// including the end of maintenance windows, and the dashboard's resource
+// alerts. Docker resource changes, which only matter to caches, are left out.
 func (b *Bus) SubscribeAll(handler Handler) []*Subscription {
-	eventTypes := []EventType{ServiceStateChanged, HostUnreachable, HostRecovered}
+	eventTypes := []EventType{ServiceStateChanged, HostUnreachable, HostRecovered, ServiceRestarted, ServiceHealthChanged, ServiceRemoved, DashboardResourceAlert, MaintenanceEnded}
 	subs := make([]*Subscription, len(eventTypes))
 	for i, et := range eventTypes {
 		subs[i] = b.Subscribe(et, handler)
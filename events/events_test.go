@@ -61,6 +61,42 @@ func TestNewHostRecoveredEvent(t *testing.T) {
 	}
 }
 
+func TestNewServiceRestartedEvent(t *testing.T) {
+	event := NewServiceRestartedEvent("nas", "nginx", "docker", "exit code 1")
+
+	if event.Type() != ServiceRestarted {
+		t.Errorf("expected type %s, got %s", ServiceRestarted, event.Type())
+	}
+	if event.ServiceName != "nginx" || event.Host != "nas" || event.Source != "docker" {
+		t.Errorf("unexpected event fields: %+v", event)
+	}
+	if event.Reason != "exit code 1" {
+		t.Errorf("expected reason 'exit code 1', got '%s'", event.Reason)
+	}
+}
+
+func TestNewServiceHealthChangedEvent(t *testing.T) {
+	event := NewServiceHealthChangedEvent("nas", "nginx", "docker", "healthy", "unhealthy")
+
+	if event.Type() != ServiceHealthChanged {
+		t.Errorf("expected type %s, got %s", ServiceHealthChanged, event.Type())
+	}
+	if event.PreviousHealth != "healthy" || event.CurrentHealth != "unhealthy" {
+		t.Errorf("expected healthy → unhealthy, got %s → %s", event.PreviousHealth, event.CurrentHealth)
+	}
+}
+
+func TestNewServiceRemovedEvent(t *testing.T) {
+	event := NewServiceRemovedEvent("nas", "nginx", "docker", "no longer reported")
+
+	if event.Type() != ServiceRemoved {
+		t.Errorf("expected type %s, got %s", ServiceRemoved, event.Type())
+	}
+	if event.Host != "nas" || event.ServiceName != "nginx" || event.Source != "docker" || event.Reason != "no longer reported" {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
+
 func TestBusSubscribeAndPublish(t *testing.T) {
 	bus := NewBus(false) // synchronous for testing
 
@@ -142,17 +178,51 @@ func TestBusSubscribeAll(t *testing.T) {
 		count++
 	})
 
-	if len(subs) != 3 {
-		t.Fatalf("expected 3 subscriptions, got %d", len(subs))
+	if len(subs) != 8 {
+		t.Fatalf("expected 8 subscriptions, got %d", len(subs))
 	}
 
 	// Publish different event types
 	bus.Publish(NewServiceStateChangedEvent("nas", "traefik", "docker", "stopped", "running", "Up"))
 	bus.Publish(NewHostUnreachableEvent("remote", "timeout"))
 	bus.Publish(NewHostRecoveredEvent("remote"))
+	bus.Publish(NewServiceRestartedEvent("nas", "traefik", "docker", "exit code 1"))
+	bus.Publish(NewServiceHealthChangedEvent("nas", "traefik", "docker", "healthy", "unhealthy"))
+	bus.Publish(NewServiceRemovedEvent("nas", "traefik", "docker", "no longer reported"))
+	bus.Publish(NewDashboardResourceAlertEvent("nas", ResourceGoroutines, 1200, 1000, "1200 goroutines, above the limit of 1000"))
+	bus.Publish(NewMaintenanceEndedEvent("nas", time.Now(), []string{"traefik"}, false))
+
+	if count != 8 {
+		t.Errorf("expected count 8, got %d", count)
+	}
+}
+
+func TestMaintenanceTagging(t *testing.T) {
+	tagged := []Event{
+		NewServiceStateChangedEvent("nas", "traefik", "docker", "running", "stopped", "Exited (0)"),
+		NewServiceRestartedEvent("nas", "traefik", "docker", "exit code 1"),
+		NewServiceHealthChangedEvent("nas", "traefik", "docker", "healthy", "unhealthy"),
+		NewHostUnreachableEvent("nas", "timeout"),
+		NewHostRecoveredEvent("nas"),
+	}
+	for _, event := range tagged {
+		if got := HostOf(event); got != "nas" {
+			t.Errorf("HostOf(%s) = %q, want nas", event.Type(), got)
+		}
+		if InMaintenance(event) {
+			t.Errorf("%s in maintenance before it was tagged", event.Type())
+		}
+		event.(MaintenanceTagger).MarkMaintenance()
+		if !InMaintenance(event) {
+			t.Errorf("%s not in maintenance after it was tagged", event.Type())
+		}
+	}
 
-	if count != 3 {
-		t.Errorf("expected count 3, got %d", count)
+	if got := HostOf(NewDashboardResourceAlertEvent("nas", ResourceGoroutines, 1200, 1000, "")); got != "" {
+		t.Errorf("HostOf(dashboard alert) = %q, want none", got)
+	}
+	if got := HostOf(NewMaintenanceEndedEvent("nas", time.Now(), nil, false)); got != "" {
+		t.Errorf("HostOf(maintenance ended) = %q, want none", got)
 	}
 }
 
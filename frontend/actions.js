@@ -31,8 +31,10 @@ function getActionModal() {
  * @param {string} source - The service source (docker, systemd)
  * @param {string} host - The host name
  * @param {string} project - The project name (for docker-compose)
+ * @param {boolean} [isSelf] - Whether the service is the dashboard itself
+ * @param {boolean} [proxyDependency] - Whether the connection to the dashboard goes through the service
  */
-export function confirmServiceAction(event, action, containerName, serviceName, source, host, project) {
+export function confirmServiceAction(event, action, containerName, serviceName, source, host, project, isSelf = false, proxyDependency = false, containerId = '') {
     event.stopPropagation();
     
     // Store pending action
@@ -42,7 +44,10 @@ export function confirmServiceAction(event, action, containerName, serviceName,
         serviceName,
         source,
         host,
-        project
+        project,
+        isSelf,
+        proxyDependency,
+        containerId
     };
     
     // Update modal content
@@ -55,7 +60,9 @@ export function confirmServiceAction(event, action, containerName, serviceName,
         <br>
         ${sourceIcon} <strong>${escapeHtml(serviceName)}</strong>
         ${host ? `<span class="badge bg-secondary ms-2">${escapeHtml(host)}</span>` : ''}
-        ${source === 'docker' && action === 'restart' ? '<br><small class="text-muted mt-2 d-block">Docker restart uses compose down/up</small>' : ''}
+        ${source === 'docker' && action === 'restart' && !isSelf ? '<br><small class="text-muted mt-2 d-block">Docker restart uses compose down/up</small>' : ''}
+        ${isSelf ? '<br><div class="alert alert-warning mt-2 mb-0"><i class="bi bi-exclamation-octagon me-1"></i>This is the dashboard itself. The connection will drop and the page must be reloaded once it is back.</div>' : ''}
+        ${proxyDependency && action !== 'start' ? `<br><div class="alert alert-warning mt-2 mb-0"><i class="bi bi-exclamation-octagon me-1"></i>Your connection to the dashboard goes through this service. It will drop during the ${action}, which carries on in the background; reload the page once the service is back.</div>` : ''}
     `;
     
     // Reset modal state
@@ -85,7 +92,7 @@ export function confirmServiceAction(event, action, containerName, serviceName,
 export function executeServiceAction() {
     if (!actionState.pending) return;
     
-    const { action, containerName, serviceName, source, host, project } = actionState.pending;
+    const { action, containerName, serviceName, source, host, project, isSelf, proxyDependency, containerId } = actionState.pending;
     
     // Update UI to show progress
     document.getElementById('actionModalStatus').style.display = 'block';
@@ -102,8 +109,13 @@ export function executeServiceAction() {
         service_name: serviceName,
         source: source,
         host: host,
-        project: project
+        project: project,
+        confirm_self: isSelf === true
     };
+    // Refuse to act if the container name was taken over since the list was loaded
+    if (containerId) {
+        requestBody.expected_container_id = containerId;
+    }
     
     fetch(`/api/services/${action}`, {
         method: 'POST',
@@ -112,6 +124,12 @@ export function executeServiceAction() {
         },
         body: JSON.stringify(requestBody)
     }).then(response => {
+        if (response.status === 409) {
+            // The container name now belongs to another container
+            return response.json().then(conflict => {
+                throw new Error(conflict.error || `HTTP ${response.status}: ${response.statusText}`);
+            });
+        }
         if (!response.ok) {
             throw new Error(`HTTP ${response.status}: ${response.statusText}`);
         }
@@ -147,7 +165,12 @@ export function executeServiceAction() {
                 processSSE(decoder.decode(value, { stream: true }));
                 read();
             }).catch(err => {
-                addActionLogLine('Connection error: ' + err.message, 'error');
+                if (isSelf || proxyDependency) {
+                    // Expected: the service being acted on carried this connection
+                    addActionLogLine('The connection dropped as expected; the ' + action + ' continues in the background', 'warning');
+                } else {
+                    addActionLogLine('Connection error: ' + err.message, 'error');
+                }
                 document.getElementById('actionSpinner').style.display = 'none';
             });
         }
@@ -173,11 +196,17 @@ function handleActionEvent(eventType, data) {
         case 'error':
             addActionLogLine('Error: ' + data, 'error');
             break;
+        case 'warning':
+            addActionLogLine('Warning: ' + data, 'warning');
+            break;
         case 'complete':
             document.getElementById('actionSpinner').style.display = 'none';
             if (data === 'success') {
                 addActionLogLine('✓ Action completed successfully', 'success');
                 startCountdown();
+            } else if (data === 'cancelled') {
+                addActionLogLine('✗ Action cancelled', 'warning');
+                showActionRetry();
             } else {
                 addActionLogLine('✗ Action failed', 'error');
                 showActionRetry();
@@ -193,7 +222,7 @@ function handleActionEvent(eventType, data) {
 /**
  * Add a line to the action status log.
  * @param {string} message - The message to add
- * @param {string} className - CSS class for styling (status, error, success)
+ * @param {string} className - CSS class for styling (status, error, warning, success)
  */
 function addActionLogLine(message, className) {
     const statusLog = document.getElementById('actionStatusLog');
@@ -305,8 +334,9 @@ export function confirmLogFlush(event, containerName, serviceName, host) {
 /**
  * Execute the log flush action.
  * @param {Function} doLoadServices - Callback to reload services after flush
+ * @param {string} confirmation - Phrase typed to confirm, after the server asked for one
  */
-export function executeLogFlush(doLoadServices) {
+export function executeLogFlush(doLoadServices, confirmation) {
     if (!actionState.pending || actionState.pending.action !== 'flush_logs') return;
     
     const { containerName, serviceName, host } = actionState.pending;
@@ -326,6 +356,9 @@ export function executeLogFlush(doLoadServices) {
         service_name: serviceName,
         host: host
     };
+    if (confirmation) {
+        requestBody.confirmation = confirmation;
+    }
     
     fetch('/api/logs/flush', {
         method: 'POST',
@@ -334,6 +367,14 @@ export function executeLogFlush(doLoadServices) {
         },
         body: JSON.stringify(requestBody)
     }).then(response => {
+        if (response.status === 428) {
+            // Destructive: the server wants the phrase typed back
+            return response.json().then(challenge => {
+                document.getElementById('actionSpinner').style.display = 'none';
+                showTypedConfirmation(challenge, typed => executeLogFlush(doLoadServices, typed));
+                return confirmationRequested;
+            });
+        }
         if (!response.ok) {
             return response.text().then(text => {
                 throw new Error(text || `HTTP ${response.status}: ${response.statusText}`);
@@ -341,6 +382,7 @@ export function executeLogFlush(doLoadServices) {
         }
         return response.json();
     }).then(result => {
+        if (result === confirmationRequested) return;
         document.getElementById('actionSpinner').style.display = 'none';
         addActionLogLine('✓ Logs flushed successfully', 'success');
         
@@ -357,6 +399,45 @@ export function executeLogFlush(doLoadServices) {
     });
 }
 
+/** Marks a response that asked for a typed confirmation instead of acting. */
+const confirmationRequested = Symbol('confirmationRequested');
+
+/**
+ * Ask the user to type the confirmation phrase the server issued for a
+ * destructive request. The confirm button is enabled once it matches.
+ * @param {{error: string, confirmation: string}} challenge - The 428 response body
+ * @param {Function} onConfirm - Called with the typed phrase
+ */
+function showTypedConfirmation(challenge, onConfirm) {
+    if (challenge.error && !challenge.error.startsWith('this action is destructive')) {
+        addActionLogLine(challenge.error, 'warning');
+    }
+    addActionLogLine('This cannot be undone. Type the phrase below to confirm.', 'status');
+
+    const footer = document.getElementById('actionModalFooter');
+    footer.style.display = 'flex';
+    footer.innerHTML = `
+        <div class="w-100">
+            <p class="mb-2">Type <code>${escapeHtml(challenge.confirmation)}</code> to confirm</p>
+            <input type="text" class="form-control mb-2" id="actionConfirmationInput" autocomplete="off" spellcheck="false">
+        </div>
+        <button type="button" class="btn btn-secondary" data-bs-dismiss="modal">Cancel</button>
+        <button type="button" class="btn btn-danger" id="actionConfirmationSubmit" disabled>Confirm</button>
+    `;
+
+    const input = document.getElementById('actionConfirmationInput');
+    const submit = document.getElementById('actionConfirmationSubmit');
+    input.addEventListener('input', () => {
+        submit.disabled = input.value.trim() !== challenge.confirmation;
+    });
+    submit.addEventListener('click', () => {
+        footer.style.display = 'none';
+        document.getElementById('actionSpinner').style.display = 'inline-block';
+        onConfirm(input.value.trim());
+    });
+    input.focus();
+}
+
 /**
  * Show retry/close buttons after log flush failure.
  */
@@ -2,11 +2,29 @@
  * API and authentication functions.
  */
 
-import { servicesState, authState } from './state.js';
-import { escapeHtml } from './utils.js';
+import { servicesState, authState, statsState } from './state.js';
+import { escapeHtml, formatAccessSummary } from './utils.js';
+import { renderSparkline } from './render.js';
 
 /**
- * Load services from API.
+ * Get the URL services are loaded from. Hidden services are only requested
+ * in the admin view.
+ * @returns {string} Services API URL
+ */
+export function servicesUrl() {
+    return servicesState.showHidden ? '/api/services?include_hidden=true' : '/api/services';
+}
+
+/**
+ * How long to wait before loading the services again while the server is
+ * still collecting them after a restart.
+ */
+export const WARMING_RELOAD_MS = 2000;
+
+/**
+ * Load services from API. While the server is warming up, it answers with
+ * the services collected so far as {"services": [...], "warming": true};
+ * they are shown and loaded again shortly.
  * @param {Object} callbacks - Callback functions
  * @param {Function} callbacks.onSuccess - Called with services array on success
  * @param {Function} callbacks.onError - Called on error
@@ -14,7 +32,7 @@ import { escapeHtml } from './utils.js';
  */
 export async function loadServices(callbacks = {}) {
     try {
-        const response = await fetch('/api/services');
+        const response = await fetch(servicesUrl());
         if (response.status === 401) {
             handleUnauthorized();
             return [];
@@ -22,13 +40,15 @@ export async function loadServices(callbacks = {}) {
         if (!response.ok) {
             throw new Error('Failed to fetch services');
         }
-        const rawServices = await response.json();
-        // Filter out hidden services
-        servicesState.all = rawServices.filter(service => !service.hidden);
+        const body = await response.json();
+        setServices(Array.isArray(body) ? body : (body.services || []));
         
         if (callbacks.onSuccess) {
             callbacks.onSuccess(servicesState.all);
         }
+        if (body.warming) {
+            setTimeout(() => loadServices(callbacks), WARMING_RELOAD_MS);
+        }
         
         return servicesState.all;
     } catch (error) {
@@ -40,6 +60,82 @@ export async function loadServices(callbacks = {}) {
     }
 }
 
+/**
+ * Load the configured links the user may see. Links are optional, so a
+ * failure leaves them empty.
+ * @returns {Promise<Array>} The links
+ */
+export async function loadLinks() {
+    try {
+        const response = await fetch('/api/links');
+        if (!response.ok) {
+            throw new Error('Failed to fetch links');
+        }
+        servicesState.links = await response.json() || [];
+    } catch (error) {
+        console.error('Error loading links:', error);
+        servicesState.links = [];
+    }
+    return servicesState.links;
+}
+
+/**
+ * Keep the services to show.
+ * @param {Array} rawServices - Services as the API returns them
+ */
+function setServices(rawServices) {
+    // Filter out hidden and orphaned services unless the admin view asked for them
+    servicesState.all = servicesState.showHidden
+        ? rawServices
+        : rawServices.filter(service => !service.hidden && !service.orphaned);
+}
+
+/**
+ * Read the services the server embedded in the page, so they can be shown
+ * before /api/services answers. The live services replace them once loaded.
+ * @param {Document} doc - Document to read the snapshot from
+ * @returns {Array|null} The services, or null if the page has no snapshot or
+ *     the server had none collected yet
+ */
+export function readServicesSnapshot(doc = typeof document !== 'undefined' ? document : null) {
+    const script = doc && doc.getElementById('services-snapshot');
+    if (!script) return null;
+    try {
+        const envelope = JSON.parse(script.textContent);
+        if (envelope.loading || !Array.isArray(envelope.services)) return null;
+        setServices(envelope.services);
+        return servicesState.all;
+    } catch (error) {
+        console.error('Error reading the services snapshot:', error);
+        return null;
+    }
+}
+
+/**
+ * Load the recent stats of the services the monitor samples and redraw their
+ * sparklines. Failures leave the last sparkline in place.
+ * @returns {Promise<void>}
+ */
+export async function loadSparklines() {
+    const sampled = servicesState.all.filter(service => service.stats_sampled);
+    await Promise.all(sampled.map(async service => {
+        try {
+            const response = await fetch(`/api/services/${encodeURIComponent(service.host)}/${encodeURIComponent(service.name)}/stats`);
+            if (!response.ok) return;
+            statsState.samples[`${service.host}:${service.name}`] = await response.json();
+        } catch (error) {
+            return;
+        }
+        if (typeof document === 'undefined') return;
+        for (const row of document.querySelectorAll('#servicesTable .service-row')) {
+            if (row.dataset.host === service.host && row.dataset.service === service.name && row.dataset.source === 'docker') {
+                const span = row.querySelector('.stats-sparkline');
+                if (span) span.innerHTML = renderSparkline(statsState.samples[`${service.host}:${service.name}`]);
+            }
+        }
+    }));
+}
+
 /**
  * Check authentication status.
  * @returns {Promise<Object>} Auth status object
@@ -69,6 +165,12 @@ export function updateAuthUI() {
     
     const authControls = document.getElementById('authControls');
     const userInfo = document.getElementById('userInfo');
+    const hiddenToggle = document.getElementById('tableHiddenToggle');
+    
+    // Only admins can see hidden services
+    if (hiddenToggle) {
+        hiddenToggle.style.display = authState.status?.user?.is_admin === true ? '' : 'none';
+    }
     
     if (!authState.status) {
         if (authControls) authControls.style.display = 'none';
@@ -78,7 +180,9 @@ export function updateAuthUI() {
     if (authState.status.oidc_enabled && authState.status.authenticated && authState.status.user) {
         if (authControls) authControls.style.display = 'flex';
         const displayName = authState.status.user.name || authState.status.user.email || 'User';
-        if (userInfo) userInfo.innerHTML = `<i class="bi bi-person-circle"></i> ${escapeHtml(displayName)}`;
+        const accessText = formatAccessSummary(authState.status.access);
+        const accessIcon = accessText ? ` <i class="bi bi-funnel small text-muted" title="${escapeHtml(accessText)}"></i>` : '';
+        if (userInfo) userInfo.innerHTML = `<i class="bi bi-person-circle"></i> ${escapeHtml(displayName)}${accessIcon}`;
     } else if (authState.status.oidc_enabled && !authState.status.authenticated) {
         if (authControls) authControls.style.display = 'none';
     } else {
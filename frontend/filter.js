@@ -11,6 +11,7 @@
 import { servicesState, tableSearchState } from './state.js';
 import { textMatches, evaluateAST } from './search-core.js';
 import { renderServices, renderHostFilters } from './render.js';
+import { isUpState } from './utils.js';
 
 /**
  * Tristate filter mode cycle order.
@@ -100,6 +101,7 @@ export function serviceMatchesTableSearch(service) {
     // Combine all searchable fields into a single string for matching
     const searchableText = [
         service.name || '',
+        service.display_name || '',
         service.project || '',
         service.host || '',
         service.container_name || '',
@@ -334,12 +336,13 @@ export function applyFilter(callbacks = {}) {
     if (servicesState.activeFilter && servicesState.activeFilter.status) {
         const { status, mode } = servicesState.activeFilter;
         services = services.filter(service => {
-            const isRunning = service.state.toLowerCase() === 'running';
+            const isRunning = isUpState(service.state);
             let matches;
             if (status === 'running') {
                 matches = isRunning;
             } else if (status === 'stopped') {
-                matches = !isRunning;
+                // Not counted as stopped: their compose profile isn't active
+                matches = !isRunning && service.state !== 'not_enabled';
             } else {
                 matches = true;
             }
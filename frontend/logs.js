@@ -3,7 +3,7 @@
  */
 
 import { escapeHtml } from './utils.js';
-import { logsState, resetLogsState } from './state.js';
+import { logsState, resetLogsState, userHasPermission } from './state.js';
 import { textMatches, evaluateAST, getSearchRegex, hasInversePrefix, findAllMatches } from './search-core.js';
 import { showHelpModal } from './help.js';
 import { getVisibleColumns } from './columns.js';
@@ -17,6 +17,13 @@ export function toggleLogs(row) {
     const serviceName = row.dataset.service;
     const source = row.dataset.source || 'docker';
     const host = row.dataset.host || '';
+    // Keep at least the service's own tail, so its history isn't trimmed
+    const maxLines = Math.max(1000, parseInt(row.dataset.logTail, 10) || 0);
+
+    // Groups can grant a service without its logs
+    if (!userHasPermission(host, serviceName, 'logs')) {
+        return;
+    }
 
     // If clicking the same row, close it
     if (logsState.activeLogsRow && logsState.activeLogsRow.dataset.container === containerName) {
@@ -101,11 +108,15 @@ export function toggleLogs(row) {
 
     let url;
     if (source === 'systemd') {
-        url = '/api/logs/systemd?unit=' + encodeURIComponent(serviceName) + '&host=' + encodeURIComponent(host);
+        // Structured lines carry the journal cursor, so a dropped stream resumes where it left off
+        url = '/api/logs/systemd?unit=' + encodeURIComponent(serviceName) + '&host=' + encodeURIComponent(host) + '&structured=true';
     } else if (source === 'traefik') {
         url = '/api/logs/traefik?service=' + encodeURIComponent(serviceName) + '&host=' + encodeURIComponent(host);
     } else if (source === 'homeassistant' || source === 'homeassistant-addon') {
         url = '/api/logs/homeassistant?service=' + encodeURIComponent(serviceName) + '&host=' + encodeURIComponent(host);
+    } else if (source && source !== 'docker') {
+        // Other registered sources, such as the demo source
+        url = '/api/logs/provider?source=' + encodeURIComponent(source) + '&host=' + encodeURIComponent(host) + '&service=' + encodeURIComponent(serviceName);
     } else {
         url = '/api/logs?container=' + encodeURIComponent(containerName) + '&service=' + encodeURIComponent(serviceName);
     }
@@ -136,7 +147,7 @@ export function toggleLogs(row) {
         }
 
         // Limit lines to prevent memory issues
-        while (content.children.length > 1000) {
+        while (content.children.length > maxLines) {
             content.removeChild(content.firstChild);
         }
         
@@ -146,6 +157,29 @@ export function toggleLogs(row) {
         }
     };
 
+    // The server warns when it shows less than was asked for, e.g. a clamped tail
+    logsState.eventSource.addEventListener('warning', function(event) {
+        const line = document.createElement('div');
+        line.className = 'log-line log-line-warning';
+        line.textContent = event.data;
+        line.dataset.originalText = event.data;
+        content.appendChild(line);
+    });
+
+    // A reconnect that couldn't resume after the last line seen starts over
+    // from the latest lines, so what is shown would repeat or have a gap
+    logsState.eventSource.addEventListener('reset', function(event) {
+        content.replaceChildren();
+        const line = document.createElement('div');
+        line.className = 'log-line log-line-warning';
+        line.textContent = event.data;
+        line.dataset.originalText = event.data;
+        content.appendChild(line);
+        if (logsState.searchTerm) {
+            updateAllMatches();
+        }
+    });
+
     logsState.eventSource.onerror = function() {
         status.textContent = '🔴 Disconnected';
         status.className = 'logs-status error';
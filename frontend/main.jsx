@@ -4,12 +4,12 @@
  */
 
 import { servicesState } from './state.js';
-import { renderServices, updateServiceRow, renderHostFilters, showStatusToast } from './render.js';
+import { renderServices, updateServiceRow, renderHostFilters, renderLinksBar, showStatusToast } from './render.js';
 import { toggleFilter, toggleSourceFilter, toggleHostFilter, toggleSort, applyFilter, updateHostFilterUI } from './filter.js';
 import { toggleLogs, closeLogs, onLogsSearchInput, onLogsSearchKeydown, toggleLogsSearchMode, toggleLogsCaseSensitivity, toggleLogsRegex, toggleLogsBangAndPipe, navigateMatch } from './logs.js';
 import { onTableSearchInput, onTableSearchKeydown, clearTableSearch, toggleTableCaseSensitivity, toggleTableRegex, toggleTableBangAndPipe, toggleTableSearchMode, navigateTableMatch, updateTableBangPipeToggleUI } from './table-search.js';
 import { confirmServiceAction, executeServiceAction, confirmLogFlush, executeLogFlush } from './actions.js';
-import { loadServices, checkAuthStatus, logout } from './api.js';
+import { loadServices, loadLinks, loadSparklines, checkAuthStatus, logout, readServicesSnapshot } from './api.js';
 import { showHelpModal } from './help.js';
 import { scrollToService } from './services.js';
 import { connect as wsConnect, disconnect as wsDisconnect, on as wsOn, isConnected as wsIsConnected } from './websocket.js';
@@ -52,6 +52,7 @@ if (typeof window !== 'undefined') {
         toggleTableBangAndPipe: () => toggleTableBangAndPipe(callbacks),
         toggleTableSearchMode: () => toggleTableSearchMode(callbacks),
         navigateTableMatch,
+        toggleShowHidden,
         
         // Service actions
         confirmServiceAction,
@@ -116,22 +117,26 @@ if (typeof window !== 'undefined') {
     };
 }
 
+/**
+ * Toggle the admin view of hidden services and reload the services.
+ */
+async function toggleShowHidden() {
+    servicesState.showHidden = !servicesState.showHidden;
+    if (typeof document !== 'undefined') {
+        const toggle = document.getElementById('tableHiddenToggle');
+        if (toggle) toggle.classList.toggle('active', servicesState.showHidden);
+    }
+    await doLoadServices();
+}
+
 /**
  * Load services and render them.
  */
 async function doLoadServices() {
     await loadServices({
         onSuccess: (services) => {
-            renderServices(services, true, callbacks);
-            
-            // Render host filter badges
-            renderHostFilters(services);
-            updateHostFilterUI();
-            
-            // Re-apply filter if one is active
-            if (servicesState.activeFilter || servicesState.activeSourceFilter || Object.keys(servicesState.activeHostFilters).length > 0) {
-                applyFilter(callbacks);
-            }
+            showServices(services);
+            loadSparklines();
         },
         onError: () => {
             if (typeof document !== 'undefined') {
@@ -144,6 +149,24 @@ async function doLoadServices() {
     });
 }
 
+/**
+ * Render services with the host filters and re-apply the active filters.
+ * @param {Array} services - Services to show
+ */
+function showServices(services) {
+    renderServices(services, true, callbacks);
+    
+    // Render host filter badges
+    renderHostFilters(services);
+    updateHostFilterUI();
+    renderLinksBar(services);
+    
+    // Re-apply filter if one is active
+    if (servicesState.activeFilter || servicesState.activeSourceFilter || Object.keys(servicesState.activeHostFilters).length > 0) {
+        applyFilter(callbacks);
+    }
+}
+
 /**
  * Initialize the dashboard.
  */
@@ -170,6 +193,13 @@ async function init() {
     // Initialize column settings AFTER auth so we use the correct storage key
     initColumnsState();
     
+    // Links are shown with the services, so load them first
+    await loadLinks();
+    
+    // Show the services embedded in the page while the live ones load
+    const snapshot = readServicesSnapshot();
+    if (snapshot) showServices(snapshot);
+    
     await doLoadServices();
     
     // Initialize table search UI (bangAndPipe is true by default)
@@ -177,11 +207,23 @@ async function init() {
     
     // Initialize WebSocket connection for real-time updates
     initWebSocket();
+
+    // Keep the CPU sparklines current; samples are taken every 30s by default
+    setInterval(loadSparklines, 30000);
     
     // Initialize sticky search bar detection
     initStickySearchBar();
 }
 
+/**
+ * Note for host events seen while the host is in maintenance.
+ * @param {Object} payload - Host event payload
+ * @returns {string} The note, or '' outside maintenance
+ */
+function maintenanceSuffix(payload) {
+    return payload.maintenance ? ' (in maintenance, not notified)' : '';
+}
+
 /**
  * Initialize WebSocket and register event handlers.
  */
@@ -200,19 +242,30 @@ function initWebSocket() {
         if (service) {
             service.state = payload.current_state;
             service.status = payload.status;
+            service.maintenance = payload.maintenance;
         }
     });
     
+    // Drop services the monitor no longer sees
+    wsOn('service_removed', (payload) => {
+        servicesState.all = servicesState.all.filter(s => !(
+            s.name === payload.service_name &&
+            s.host === payload.host &&
+            s.source === payload.source
+        ));
+        applyFilter(callbacks);
+    });
+    
     // Handle host unreachable events
     wsOn('host_unreachable', (payload) => {
         console.log('Host unreachable:', payload.host, payload.reason);
-        showHostNotification('error', `Host ${payload.host} is unreachable: ${payload.reason}`);
+        showHostNotification('error', `Host ${payload.host} is unreachable: ${payload.reason}${maintenanceSuffix(payload)}`);
     });
     
     // Handle host recovered events
     wsOn('host_recovered', (payload) => {
         console.log('Host recovered:', payload.host);
-        showHostNotification('success', `Host ${payload.host} is back online`);
+        showHostNotification('success', `Host ${payload.host} is back online${maintenanceSuffix(payload)}`);
         // Refresh services to get updated state
         doLoadServices();
     });
@@ -2,9 +2,9 @@
  * Service rendering functions.
  */
 
-import { escapeHtml, getStatusClass, formatLogSize } from './utils.js';
+import { escapeHtml, getStatusClass, isUpState, formatLogSize, buildHostURL, formatStateSince, formatImageAge, formatDuration } from './utils.js';
 import { getServiceHostIP, scrollToService } from './services.js';
-import { authState } from './state.js';
+import { authState, statsState, servicesState, userHasPermission } from './state.js';
 import { getVisibleColumns, renderTableHeader as renderColumnsHeader } from './columns.js';
 
 /** Toast timeout handle */
@@ -61,7 +61,7 @@ export function renderPorts(ports, hostIP, currentService) {
             const urlProtocol = port.url_protocol || 'http';
             
             if (port.label) {
-                const url = `${urlProtocol}://${targetHost}:${port.host_port}`;
+                const url = port.url || buildHostURL(urlProtocol, targetHost, port.host_port);
                 displayText = escapeHtml(port.label);
                 titleText = `${escapeHtml(port.label)} - Port ${port.host_port} (${port.protocol})`;
                 return `<a href="${url}" target="_blank" rel="noopener noreferrer" class="${badgeClass}" onclick="event.stopPropagation();" title="${titleText}">${displayText}</a>`;
@@ -72,12 +72,12 @@ export function renderPorts(ports, hostIP, currentService) {
                 return `<span class="${badgeClass}" onclick="event.stopPropagation(); window.__dashboard.scrollToService('${escapeHtml(port.target_service)}', '${escapeHtml(currentHost)}');" title="${titleText}" style="cursor: pointer;">${displayText}</span>`;
             } else if (port.source_service) {
                 const sourceIP = getServiceHostIP(port.source_service, currentHost) || targetHost;
-                const url = `${urlProtocol}://${sourceIP}:${port.host_port}`;
+                const url = port.url || buildHostURL(urlProtocol, sourceIP, port.host_port);
                 displayText = `${escapeHtml(port.source_service)}:${port.host_port}`;
                 titleText = `Open port ${port.host_port} on ${escapeHtml(port.source_service)} (${port.protocol})`;
                 return `<a href="${url}" target="_blank" rel="noopener noreferrer" class="${badgeClass}" onclick="event.stopPropagation();" title="${titleText}">${displayText}</a>`;
             } else {
-                const url = `${urlProtocol}://${targetHost}:${port.host_port}`;
+                const url = port.url || buildHostURL(urlProtocol, targetHost, port.host_port);
                 displayText = `:${port.host_port}`;
                 titleText = `Open port ${port.host_port} (${port.protocol})`;
                 return `<a href="${url}" target="_blank" rel="noopener noreferrer" class="${badgeClass}" onclick="event.stopPropagation();" title="${titleText}">${displayText}</a>`;
@@ -105,6 +105,72 @@ export function renderTraefikURLs(traefikURLs) {
     }).join('');
 }
 
+/**
+ * Get the configured links that belong with a service's compose project: the
+ * links whose group is the project, global or from the service's host.
+ * @param {Object} service - The service object
+ * @param {Array} links - Configured links
+ * @returns {Array} The service's project links
+ */
+export function projectLinks(service, links) {
+    if (!service.project || !links) {
+        return [];
+    }
+    return links.filter(link => link.group === service.project && (!link.host || link.host === service.host));
+}
+
+/**
+ * Render a configured link.
+ * @param {Object} link - The link
+ * @returns {string} HTML string of the link
+ */
+export function renderLink(link) {
+    const icon = link.icon || 'bi-link-45deg';
+    const title = link.description || link.url;
+    return `<a href="${escapeHtml(link.url)}" target="_blank" rel="noopener noreferrer" class="config-link badge bg-info text-dark me-1" onclick="event.stopPropagation();" title="${escapeHtml(title)}"><i class="bi ${escapeHtml(icon)}"></i> ${escapeHtml(link.name)}</a>`;
+}
+
+/**
+ * Render the links of a service's compose project.
+ * @param {Object} service - The service object
+ * @param {Array} links - Configured links
+ * @returns {string} HTML string of the links
+ */
+export function renderProjectLinks(service, links) {
+    return projectLinks(service, links).map(renderLink).join('');
+}
+
+/**
+ * Get the configured links that belong with none of the services: those
+ * without a group, or whose project has no services shown.
+ * @param {Array} links - Configured links
+ * @param {Array} services - Services shown
+ * @returns {Array} The links to show on their own
+ */
+export function ungroupedLinks(links, services) {
+    if (!links) {
+        return [];
+    }
+    return links.filter(link => !link.group || !services.some(service => projectLinks(service, [link]).length > 0));
+}
+
+/**
+ * Render the links that belong with none of the services above the table,
+ * hiding their row when there are none.
+ * @param {Array} services - Services shown
+ */
+export function renderLinksBar(services) {
+    if (typeof document === 'undefined') return;
+
+    const container = document.getElementById('linksContainer');
+    const row = document.getElementById('linksRow');
+    if (!container || !row) return;
+
+    const links = ungroupedLinks(servicesState.links, services);
+    container.innerHTML = links.map(renderLink).join('');
+    row.style.display = links.length > 0 ? '' : 'none';
+}
+
 /**
  * Get source icons HTML for a service.
  * @param {Object} service - The service object
@@ -150,25 +216,32 @@ export function renderControlButtons(service) {
     if (service.readonly) {
         return '<div class="service-controls"><span class="text-muted small" title="This service is read-only"><i class="bi bi-lock"></i></span></div>';
     }
+    // Groups can grant a service without control over it
+    if (!userHasPermission(service.host || '', service.name, 'control')) {
+        return '<div class="service-controls"><span class="text-muted small" title="You can view this service but not control it"><i class="bi bi-eye"></i></span></div>';
+    }
     
-    const isRunning = service.state.toLowerCase() === 'running';
+    const isRunning = isUpState(service.state);
     const containerName = escapeHtml(service.container_name);
     const serviceName = escapeHtml(service.name);
     const source = escapeHtml(service.source || 'docker');
     const host = escapeHtml(service.host || '');
     const project = escapeHtml(service.project || '');
+    const isSelf = service.is_self ? 'true' : 'false';
+    const proxyDependency = service.proxy_dependency ? 'true' : 'false';
+    const containerId = escapeHtml(service.container_id || '');
     
     let buttons = '<div class="service-controls">';
     
     if (!isRunning) {
-        buttons += `<button class="service-control-btn btn-start" onclick="window.__dashboard.confirmServiceAction(event, 'start', '${containerName}', '${serviceName}', '${source}', '${host}', '${project}')" title="Start service"><i class="bi bi-play-fill"></i></button>`;
+        buttons += `<button class="service-control-btn btn-start" onclick="window.__dashboard.confirmServiceAction(event, 'start', '${containerName}', '${serviceName}', '${source}', '${host}', '${project}', ${isSelf}, ${proxyDependency}, '${containerId}')" title="Start service"><i class="bi bi-play-fill"></i></button>`;
     }
     
     if (isRunning) {
-        buttons += `<button class="service-control-btn btn-stop" onclick="window.__dashboard.confirmServiceAction(event, 'stop', '${containerName}', '${serviceName}', '${source}', '${host}', '${project}')" title="Stop service"><i class="bi bi-stop-fill"></i></button>`;
+        buttons += `<button class="service-control-btn btn-stop" onclick="window.__dashboard.confirmServiceAction(event, 'stop', '${containerName}', '${serviceName}', '${source}', '${host}', '${project}', ${isSelf}, ${proxyDependency}, '${containerId}')" title="Stop service"><i class="bi bi-stop-fill"></i></button>`;
     }
     
-    buttons += `<button class="service-control-btn btn-restart" onclick="window.__dashboard.confirmServiceAction(event, 'restart', '${containerName}', '${serviceName}', '${source}', '${host}', '${project}')" title="Restart service"><i class="bi bi-arrow-clockwise"></i></button>`;
+    buttons += `<button class="service-control-btn btn-restart" onclick="window.__dashboard.confirmServiceAction(event, 'restart', '${containerName}', '${serviceName}', '${source}', '${host}', '${project}', ${isSelf}, ${proxyDependency}, '${containerId}')" title="Restart service"><i class="bi bi-arrow-clockwise"></i></button>`;
     
     buttons += '</div>';
     return buttons;
@@ -185,6 +258,11 @@ export function renderLogSize(service) {
     const host = escapeHtml(service.host || '');
     const source = service.source || 'docker';
     
+    // Logs sent to the journal have no file to size or flush
+    if (source === 'docker' && service.log_driver === 'journald') {
+        return '<span class="text-muted" title="Logs are in the systemd journal; use journalctl --vacuum-size to free space">journal</span>';
+    }
+
     // Only Docker services have log sizes
     if (source !== 'docker' || !service.log_size || service.log_size <= 0) {
         return '<span class="text-muted">-</span>';
@@ -238,7 +316,7 @@ export function renderServices(services, updateStats = true, callbacks = {}) {
     let homeassistantCount = 0;
 
     services.forEach(service => {
-        if (service.state.toLowerCase() === 'running') {
+        if (isUpState(service.state)) {
             running++;
         } else {
             stopped++;
@@ -258,7 +336,6 @@ export function renderServices(services, updateStats = true, callbacks = {}) {
     });
 
     const rows = services.map(service => {
-        const statusClass = getStatusClass(service.state, service.status);
         const sourceIcons = getSourceIcons(service);
         const hostBadge = service.host ? `<span class="badge bg-secondary">${escapeHtml(service.host)}</span>` : '';
         const portsHtml = renderPorts(service.ports, service.host_ip, service);
@@ -270,12 +347,12 @@ export function renderServices(services, updateStats = true, callbacks = {}) {
 
         // Build cell content map
         const cellContent = {
-            name: `${sourceIcons} ${escapeHtml(service.name)} ${portsHtml} ${traefikHtml}${descriptionHtml}`,
-            project: escapeHtml(service.project),
+            name: `${sourceIcons} ${renderServiceName(service)} ${portsHtml} ${traefikHtml}${descriptionHtml}`,
+            project: `${escapeHtml(service.project)} ${renderProjectLinks(service, servicesState.links)}`,
             host: hostBadge,
             container: `<code class="small">${escapeHtml(service.container_name)}</code>`,
-            status: `<span class="badge badge-${statusClass} status-badge" title="${escapeHtml(service.status)}" onclick="event.stopPropagation(); window.__dashboard.showStatusToast('${escapeHtml(service.status).replace(/'/g, "\\'")}', '${statusClass}')"><span class="status-text">${escapeHtml(service.status)}</span></span>`,
-            image: escapeHtml(service.image),
+            status: renderStatus(service),
+            image: renderImage(service),
             log_size: logSizeHtml,
             actions: controlButtons
         };
@@ -291,7 +368,7 @@ export function renderServices(services, updateStats = true, callbacks = {}) {
                 cellClass = 'class="status-cell"';
             } else if (col.id === 'image') {
                 cellClass = 'class="image-cell"';
-                cellAttrs = `title="${escapeHtml(service.image)}"`;
+                cellAttrs = `title="${escapeHtml(renderImageTitle(service))}"`;
             } else if (col.id === 'log_size') {
                 cellClass = 'class="logs-cell"';
             } else if (col.id === 'actions') {
@@ -302,7 +379,7 @@ export function renderServices(services, updateStats = true, callbacks = {}) {
         }).join('');
 
         return `
-            <tr class="service-row" data-container="${escapeHtml(service.container_name)}" data-service="${escapeHtml(service.name)}" data-source="${escapeHtml(service.source || 'docker')}" data-host="${escapeHtml(service.host || '')}" data-project="${escapeHtml(service.project || '')}" data-has-traefik="${hasTraefikIntegration}">
+            <tr class="service-row${service.hidden || service.orphaned ? ' service-hidden' : ''}" data-container="${escapeHtml(service.container_name)}" data-service="${escapeHtml(service.name)}" data-source="${escapeHtml(service.source || 'docker')}" data-host="${escapeHtml(service.host || '')}" data-project="${escapeHtml(service.project || '')}" data-self="${service.is_self ? 'true' : 'false'}" data-proxy="${service.proxy_dependency ? 'true' : 'false'}" data-container-id="${escapeHtml(service.container_id || '')}" data-has-traefik="${hasTraefikIntegration}" data-log-tail="${service.log_settings?.tail || ''}">
                 ${cells}
             </tr>
         `;
@@ -334,6 +411,190 @@ export function renderServices(services, updateStats = true, callbacks = {}) {
     }
 }
 
+/**
+ * Build the image cell tooltip: the image name plus its build age, platform and digest when known.
+ * @param {Object} service - Service object with image, image_created, image_platform, and image_digest
+ * @param {number} [now] - Current time in milliseconds (defaults to Date.now())
+ * @returns {string} Plain-text tooltip (not escaped)
+ */
+export function renderImageTitle(service, now = Date.now()) {
+    const parts = [service.image];
+    const age = formatImageAge(service.image_created, now);
+    if (age) {
+        parts.push(`built ${age}`);
+    }
+    if (service.image_platform) {
+        parts.push(service.image_platform);
+    }
+    if (service.image_digest) {
+        parts.push(service.image_digest);
+    }
+    return parts.join('\n');
+}
+
+/**
+ * Render the image cell content, with a "stale" badge when the image is older
+ * than the configured staleness threshold, a "drifted" badge when the
+ * container was recreated outside compose, a "re-up needed" badge when its
+ * compose file changed after it was created, an "orphaned" badge when its
+ * compose project directory is gone and a "wrong arch" badge when the image
+ * was built for an architecture its host can't run.
+ * @param {Object} service - Service object with image, image_created, stale, drifted, config_drift, orphaned, image_platform and arch_mismatch
+ * @param {number} [now] - Current time in milliseconds (defaults to Date.now())
+ * @returns {string} HTML string for the image cell
+ */
+export function renderImage(service, now = Date.now()) {
+    let html = escapeHtml(service.image);
+    if (service.stale) {
+        const age = formatImageAge(service.image_created, now);
+        const title = age ? `Image built ${age}` : 'Image is older than the staleness threshold';
+        html += ` <span class="badge image-stale" title="${escapeHtml(title)}">stale</span>`;
+    }
+    if (service.drifted) {
+        html += ' <span class="badge image-drifted" title="Recreated from the dashboard; differs from its compose file until the project is next brought up">drifted</span>';
+    }
+    if (service.config_drift) {
+        html += ' <span class="badge image-config-drift" title="Its compose file changed after the container was created; bring the project up to apply the changes">re-up needed</span>';
+    }
+    if (service.orphaned) {
+        html += ' <span class="badge image-orphaned" title="Stopped and its compose project directory no longer exists; remove it with cleanup">orphaned</span>';
+    }
+    if (service.arch_mismatch) {
+        const title = `Image built for ${service.image_platform || 'another architecture'}, which this host can't run; it fails with "exec format error"`;
+        html += ` <span class="badge image-arch-mismatch" title="${escapeHtml(title)}">wrong arch</span>`;
+    }
+    return html;
+}
+
+/**
+ * Render a service's display name. When it differs from the real service name,
+ * the real name is shown in the tooltip since that is what actions and logs use.
+ * @param {Object} service - Service object with name and optional display_name
+ * @returns {string} HTML string for the service name
+ */
+export function renderServiceName(service) {
+    const displayName = service.display_name || service.name;
+    if (displayName === service.name) {
+        return escapeHtml(service.name);
+    }
+    return `<span class="service-display-name" title="${escapeHtml(service.name)}">${escapeHtml(displayName)}</span>`;
+}
+
+/**
+ * Render the status badge for a service, with how long it has been in its
+ * current state. Docker status text already includes the duration ("Up 3 hours"),
+ * so for Docker it only goes in the tooltip, along with the last action run
+ * from the dashboard. Containers started outside the dashboard since then get
+ * an "external" badge.
+ * @param {Object} service - Service object with state, status, source, profiles, last_state_change, probe, last_action, and externally_restarted
+ * @param {number} [now] - Current time in milliseconds (defaults to Date.now())
+ * @returns {string} HTML string for the status cell
+ */
+export function renderStatus(service, now = Date.now()) {
+    const statusClass = getStatusClass(service.state, service.status);
+    const since = formatStateSince(service.last_state_change, now);
+    // A compose service whose profile isn't active is down on purpose
+    const notEnabled = service.state === 'not_enabled';
+    const profiles = notEnabled && service.profiles?.length ? `only runs with profile ${service.profiles.join(' or ')}` : '';
+    const details = [profiles, since, formatExitReason(service), formatProbe(service.probe), formatLastAction(service.last_action, now)].filter(Boolean).join(', ');
+    const title = details ? `${service.status} (${details})` : service.status;
+    const statusText = notEnabled ? 'not enabled' : service.status;
+    const sinceHtml = since && service.source !== 'docker' ? ` <span class="state-since">${escapeHtml(since)}</span>` : '';
+    const externalHtml = service.externally_restarted
+        ? ' <span class="badge status-external" title="Started outside the dashboard (compose, a restart policy or by hand) since its last dashboard action">external</span>'
+        : '';
+    const maintenanceHtml = service.maintenance
+        ? ' <span class="badge status-maintenance" title="Changed while its host is in maintenance, so it was not notified">maintenance</span>'
+        : '';
+    const sparklineHtml = service.stats_sampled
+        ? ` <span class="stats-sparkline">${renderSparkline(statsState.samples[`${service.host}:${service.name}`])}</span>`
+        : '';
+    return `<span class="badge badge-${statusClass} status-badge" title="${escapeHtml(title)}" onclick="event.stopPropagation(); window.__dashboard.showStatusToast('${escapeHtml(title).replace(/'/g, "\\'")}', '${statusClass}')"><span class="status-text">${escapeHtml(statusText)}</span>${sinceHtml}</span>${externalHtml}${maintenanceHtml}${sparklineHtml}`;
+}
+
+/**
+ * Render a container's recent CPU use as a small SVG line, with the latest
+ * CPU and memory use in its tooltip.
+ * @param {Array} samples - Samples from /api/services/{host}/{name}/stats: t, cpu_pct, mem_bytes, oldest first
+ * @param {number} [width] - Width in pixels
+ * @param {number} [height] - Height in pixels
+ * @returns {string} SVG markup, or '' with fewer than two samples
+ */
+export function renderSparkline(samples, width = 60, height = 16) {
+    if (!samples || samples.length < 2) {
+        return '';
+    }
+    // Scale to the peak, but keep idle containers near the bottom
+    const peak = Math.max(5, ...samples.map(s => s.cpu_pct));
+    const step = width / (samples.length - 1);
+    const points = samples.map((s, i) => {
+        const x = (i * step).toFixed(1);
+        const y = (height - 1 - (s.cpu_pct / peak) * (height - 2)).toFixed(1);
+        return `${x},${y}`;
+    }).join(' ');
+    const last = samples[samples.length - 1];
+    const maxCPU = Math.max(...samples.map(s => s.cpu_pct));
+    const title = `CPU ${last.cpu_pct.toFixed(1)}% (peak ${maxCPU.toFixed(1)}%), memory ${formatLogSize(last.mem_bytes)}`;
+    return `<svg class="sparkline" width="${width}" height="${height}" viewBox="0 0 ${width} ${height}" role="img" aria-label="${escapeHtml(title)}"><title>${escapeHtml(title)}</title><polyline points="${points}" fill="none" stroke="currentColor" stroke-width="1.5"/></svg>`;
+}
+
+/**
+ * Describe the last action run on a service from the dashboard, for the status tooltip.
+ * @param {Object} lastAction - The service's last_action: action, user, time, and result
+ * @param {number} [now] - Current time in milliseconds (defaults to Date.now())
+ * @returns {string} Text like "last restart by alice 5m ago: success", or '' if there is none
+ */
+export function formatLastAction(lastAction, now = Date.now()) {
+    if (!lastAction || !lastAction.action) {
+        return '';
+    }
+    let text = `last ${lastAction.action}`;
+    if (lastAction.user) {
+        text += ` by ${lastAction.user}`;
+    }
+    const time = Date.parse(lastAction.time);
+    if (!Number.isNaN(time)) {
+        text += ` ${formatDuration(now - time)} ago`;
+    }
+    return lastAction.result ? `${text}: ${lastAction.result}` : text;
+}
+
+/**
+ * Describe a custom health probe that fails or can't run, for the status tooltip.
+ * @param {Object} [probe] - Probe result with status and detail
+ * @returns {string} Probe description, or '' if there is no probe or it passes
+ */
+export function formatProbe(probe) {
+    if (!probe || probe.status === 'passing') {
+        return '';
+    }
+    const text = probe.status === 'unprobeable' ? 'probe can\'t run' : 'probe failing';
+    return probe.detail ? `${text}: ${probe.detail}` : text;
+}
+
+/**
+ * Describe how a stopped container last exited, for the status tooltip.
+ * @param {Object} service - The service object
+ * @returns {string} Exit description, or '' if the exit code is unknown
+ */
+export function formatExitReason(service) {
+    if (isUpState(service.state) || service.exit_code === undefined || service.exit_code === null) {
+        return '';
+    }
+    let reason = `exit code ${service.exit_code}`;
+    if (service.exit_code === 0) {
+        reason += ', clean';
+    } else if (service.exit_code === 137) {
+        reason += ', killed or out of memory';
+    } else if (service.exit_code === 143) {
+        reason += ', terminated';
+    }
+    if (service.exit_error) {
+        reason += `: ${service.exit_error}`;
+    }
+    return reason;
+}
+
 /**
  * Update a single service row reactively without re-rendering the entire table.
  * @param {Object} update - Service update payload from WebSocket
@@ -381,32 +642,41 @@ export function updateServiceRow(update, callbacks = {}) {
     if (statusColIndex >= 0) {
         const statusCell = targetRow.querySelector(`td:nth-child(${statusColIndex + 1})`);
         if (statusCell) {
-            const statusClass = getStatusClass(update.current_state, update.status);
-            statusCell.innerHTML = `<span class="badge badge-${statusClass} status-badge" title="${escapeHtml(update.status)}" onclick="event.stopPropagation(); window.__dashboard.showStatusToast('${escapeHtml(update.status)}', '${statusClass}')"><span class="status-text">${escapeHtml(update.status)}</span></span>`;
+            // The state just changed, so the duration restarts from now
+            statusCell.innerHTML = renderStatus({
+                state: update.current_state,
+                status: update.status,
+                source: update.source,
+                last_state_change: new Date().toISOString(),
+                maintenance: update.maintenance
+            });
         }
     }
     
     // Update the control buttons to reflect new state
     const controlsCell = targetRow.querySelector('.controls-cell');
-    if (controlsCell) {
-        const isRunning = update.current_state.toLowerCase() === 'running';
+    if (controlsCell && userHasPermission(targetRow.dataset.host, targetRow.dataset.service, 'control')) {
+        const isRunning = isUpState(update.current_state);
         const containerName = escapeHtml(targetRow.dataset.container);
         const serviceName = escapeHtml(targetRow.dataset.service);
         const source = escapeHtml(targetRow.dataset.source);
         const host = escapeHtml(targetRow.dataset.host);
         const project = escapeHtml(targetRow.dataset.project);
+        const isSelf = targetRow.dataset.self === 'true' ? 'true' : 'false';
+        const proxyDependency = targetRow.dataset.proxy === 'true' ? 'true' : 'false';
+        const containerId = escapeHtml(targetRow.dataset.containerId || '');
         
         let buttons = '<div class="service-controls">';
         
         if (!isRunning) {
-            buttons += `<button class="service-control-btn btn-start" onclick="window.__dashboard.confirmServiceAction(event, 'start', '${containerName}', '${serviceName}', '${source}', '${host}', '${project}')" title="Start service"><i class="bi bi-play-fill"></i></button>`;
+            buttons += `<button class="service-control-btn btn-start" onclick="window.__dashboard.confirmServiceAction(event, 'start', '${containerName}', '${serviceName}', '${source}', '${host}', '${project}', ${isSelf}, ${proxyDependency}, '${containerId}')" title="Start service"><i class="bi bi-play-fill"></i></button>`;
         }
         
         if (isRunning) {
-            buttons += `<button class="service-control-btn btn-stop" onclick="window.__dashboard.confirmServiceAction(event, 'stop', '${containerName}', '${serviceName}', '${source}', '${host}', '${project}')" title="Stop service"><i class="bi bi-stop-fill"></i></button>`;
+            buttons += `<button class="service-control-btn btn-stop" onclick="window.__dashboard.confirmServiceAction(event, 'stop', '${containerName}', '${serviceName}', '${source}', '${host}', '${project}', ${isSelf}, ${proxyDependency}, '${containerId}')" title="Stop service"><i class="bi bi-stop-fill"></i></button>`;
         }
         
-        buttons += `<button class="service-control-btn btn-restart" onclick="window.__dashboard.confirmServiceAction(event, 'restart', '${containerName}', '${serviceName}', '${source}', '${host}', '${project}')" title="Restart service"><i class="bi bi-arrow-clockwise"></i></button>`;
+        buttons += `<button class="service-control-btn btn-restart" onclick="window.__dashboard.confirmServiceAction(event, 'restart', '${containerName}', '${serviceName}', '${source}', '${host}', '${project}', ${isSelf}, ${proxyDependency}, '${containerId}')" title="Restart service"><i class="bi bi-arrow-clockwise"></i></button>`;
         
         buttons += '</div>';
         controlsCell.innerHTML = buttons;
@@ -443,7 +713,8 @@ export function updateStatsFromDOM() {
     rows.forEach(row => {
         // Check status badge
         const statusBadge = row.querySelector('td:nth-child(5) .badge');
-        if (statusBadge) {
+        // Services whose compose profile isn't active are neither
+        if (statusBadge && !statusBadge.classList.contains('badge-not-enabled')) {
             if (statusBadge.classList.contains('badge-running')) {
                 running++;
             } else {
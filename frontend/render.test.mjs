@@ -3,9 +3,9 @@
  */
 
 import { describe, it, assert, assertEqual, assertDeepEqual } from './test-utils.mjs';
-import { servicesState, authState } from './state.js';
+import { servicesState, authState, statsState } from './state.js';
 import { getServiceHostIP } from './services.js';
-import { renderPorts, renderTraefikURLs, getSourceIcons, renderControlButtons, renderLogSize, getUniqueHosts } from './render.js';
+import { renderPorts, renderTraefikURLs, getSourceIcons, renderControlButtons, renderLogSize, getUniqueHosts, renderStatus, formatExitReason, formatProbe, renderServiceName, renderImage, renderImageTitle, formatLastAction, renderSparkline, projectLinks, renderProjectLinks, ungroupedLinks } from './render.js';
 
 describe('getServiceHostIP', () => {
     it('returns host_ip for matching service', () => {
@@ -77,6 +77,18 @@ describe('renderPorts', () => {
         assert(result.includes('http://192.168.1.1:8080'), 'Should include URL');
     });
 
+    it('prefers server-built port URL', () => {
+        const ports = [{ host_port: 8080, protocol: 'tcp', url: 'http://[fd00::10]:8080' }];
+        const result = renderPorts(ports, 'fd00::10', {});
+        assert(result.includes('href="http://[fd00::10]:8080"'), 'Should use port.url');
+    });
+
+    it('brackets IPv6 hostIP when building URLs', () => {
+        const ports = [{ host_port: 8080, protocol: 'tcp' }];
+        const result = renderPorts(ports, 'fd00::10', {});
+        assert(result.includes('http://[fd00::10]:8080'), 'Should bracket IPv6 address');
+    });
+
     it('uses localhost when hostIP is null', () => {
         const ports = [{ host_port: 3000, protocol: 'tcp' }];
         const result = renderPorts(ports, null, { host: 'nas' });
@@ -311,6 +323,50 @@ describe('renderControlButtons', () => {
         assert(result.includes('btn-restart'), 'Should include restart button');
     });
 
+    it('passes the self flag to the action confirmation', () => {
+        const service = {
+            state: 'running',
+            container_name: 'nas-dashboard.service',
+            name: 'nas-dashboard.service',
+            source: 'systemd',
+            host: 'host1',
+            project: 'systemd',
+            is_self: true
+        };
+        const result = renderControlButtons(service);
+        assert(result.includes("'systemd', true, false, '')"), 'Should pass isSelf=true');
+        assert(!renderControlButtons({ ...service, is_self: false }).includes(', true, false)'), 'Should pass isSelf=false');
+    });
+
+    it('passes the proxy dependency flag to the action confirmation', () => {
+        const service = {
+            state: 'running',
+            container_name: 'traefik',
+            name: 'traefik',
+            source: 'docker',
+            host: 'host1',
+            project: 'proxy',
+            proxy_dependency: true
+        };
+        const result = renderControlButtons(service);
+        assert(result.includes("'proxy', false, true, '')"), 'Should pass proxyDependency=true');
+        assert(renderControlButtons({ ...service, proxy_dependency: false }).includes("'proxy', false, false, '')"), 'Should pass proxyDependency=false');
+    });
+
+    it('passes the container ID to the action confirmation', () => {
+        const service = {
+            state: 'running',
+            container_name: 'jellyfin',
+            container_id: '4f2a9c0d1e3b',
+            name: 'jellyfin',
+            source: 'docker',
+            host: 'host1',
+            project: 'media'
+        };
+        const result = renderControlButtons(service);
+        assert(result.includes("'media', false, false, '4f2a9c0d1e3b')"), 'Should pass the container ID');
+    });
+
     it('renders normal buttons when readonly is undefined', () => {
         const service = {
             state: 'running',
@@ -324,6 +380,19 @@ describe('renderControlButtons', () => {
         assert(!result.includes('bi-lock'), 'Should not include lock icon');
         assert(result.includes('btn-stop'), 'Should include stop button');
     });
+
+    it('renders no buttons for services the user may not control', () => {
+        const service = { state: 'running', container_name: 'plex', name: 'plex', source: 'docker', host: 'nas' };
+        authState.status = { user: { service_permissions: { nas: { plex: ['view', 'logs'] } } } };
+        try {
+            const result = renderControlButtons(service);
+            assert(result.includes('bi-eye'), 'Should include view-only icon');
+            assert(!result.includes('btn-restart'), 'Should not include restart button');
+            assert(renderControlButtons({ ...service, name: 'jellyfin' }).includes('btn-restart'), 'Services without permissions are controllable');
+        } finally {
+            authState.status = null;
+        }
+    });
 });
 
 describe('renderLogSize', () => {
@@ -346,6 +415,14 @@ describe('renderLogSize', () => {
         assert(result.includes('-'), 'Should show dash');
     });
 
+    it('shows journal for containers using the journald log driver', () => {
+        authState.status = { user: { is_admin: true } };
+        const service = { source: 'docker', log_driver: 'journald', container_name: 'test', name: 'test', host: 'host1' };
+        const result = renderLogSize(service);
+        assert(result.includes('journal'), 'Should show journal');
+        assert(!result.includes('confirmLogFlush'), 'Should not offer a flush');
+    });
+
     it('renders readonly button for non-admin users', () => {
         authState.status = { user: { is_admin: false } };
         const service = { source: 'docker', log_size: 1024, container_name: 'test', name: 'test', host: 'host1' };
@@ -403,3 +480,250 @@ describe('getUniqueHosts', () => {
         assertDeepEqual(result, ['host1']);
     });
 });
+
+describe('renderImage', () => {
+    const now = Date.parse('2025-06-01T00:00:00Z');
+
+    it('shows only the image when not stale', () => {
+        assertEqual(renderImage({ image: 'nginx:latest', image_created: '2025-05-01T00:00:00Z' }, now), 'nginx:latest');
+    });
+
+    it('adds a stale badge with the image age', () => {
+        const html = renderImage({ image: 'nginx:latest', image_created: '2024-03-20T00:00:00Z', stale: true }, now);
+        assert(html.includes('image-stale'), 'should include stale badge');
+        assert(html.includes('title="Image built 14 months ago"'), 'badge tooltip should include age');
+    });
+
+    it('adds a drifted badge for containers recreated outside compose', () => {
+        const html = renderImage({ image: 'nginx:latest', drifted: true }, now);
+        assert(html.startsWith('nginx:latest '), 'should keep the image name');
+        assert(html.includes('image-drifted'), 'should include drifted badge');
+        assert(!html.includes('image-stale'), 'should not include stale badge');
+    });
+
+    it('adds a re-up needed badge for containers behind their compose file', () => {
+        const html = renderImage({ image: 'nginx:latest', config_drift: true }, now);
+        assert(html.includes('image-config-drift'), 'should include config drift badge');
+        assert(html.includes('re-up needed'), 'badge should say the project needs an up');
+        assert(!html.includes('image-drifted'), 'should not include drifted badge');
+    });
+
+    it('adds an orphaned badge for containers whose compose directory is gone', () => {
+        const html = renderImage({ image: 'nginx:latest', orphaned: true }, now);
+        assert(html.includes('image-orphaned'), 'should include orphaned badge');
+        assert(!html.includes('image-drifted'), 'should not include drifted badge');
+    });
+
+    it('adds a wrong arch badge for images the host can\'t run', () => {
+        const html = renderImage({ image: 'nginx:latest', image_platform: 'linux/arm64', arch_mismatch: true }, now);
+        assert(html.includes('image-arch-mismatch'), 'should include arch mismatch badge');
+        assert(html.includes('Image built for linux/arm64'), 'badge tooltip should name the image platform');
+        assert(!renderImage({ image: 'nginx:latest', image_platform: 'linux/amd64' }, now).includes('image-arch-mismatch'), 'matching image should not get the badge');
+    });
+
+    it('includes build age and digest in the tooltip', () => {
+        const title = renderImageTitle({ image: 'nginx:latest', image_created: '2025-05-22T00:00:00Z', image_digest: 'sha256:abc' }, now);
+        assertEqual(title, 'nginx:latest\nbuilt 10 days ago\nsha256:abc');
+    });
+
+    it('includes the image platform in the tooltip', () => {
+        const title = renderImageTitle({ image: 'nginx:latest', image_platform: 'linux/arm/v7', image_digest: 'sha256:abc' }, now);
+        assertEqual(title, 'nginx:latest\nlinux/arm/v7\nsha256:abc');
+    });
+});
+
+describe('renderServiceName', () => {
+    it('shows the service name when there is no display name', () => {
+        assertEqual(renderServiceName({ name: 'nginx.service' }), 'nginx.service');
+    });
+
+    it('shows the service name when the display name matches it', () => {
+        assertEqual(renderServiceName({ name: 'nginx', display_name: 'nginx' }), 'nginx');
+    });
+
+    it('shows the display name with the real name in the tooltip', () => {
+        const html = renderServiceName({ name: 'nginx.service', display_name: 'Web <Server>' });
+        assert(html.includes('Web &lt;Server&gt;'), 'display name should be escaped');
+        assert(html.includes('title="nginx.service"'), 'tooltip should carry the service name');
+    });
+});
+
+describe('formatProbe', () => {
+    it('describes probes that fail or cannot run', () => {
+        assertEqual(formatProbe(undefined), '');
+        assertEqual(formatProbe({ status: 'passing' }), '');
+        assertEqual(formatProbe({ status: 'failing', detail: 'timed out after 5s' }), 'probe failing: timed out after 5s');
+        assertEqual(formatProbe({ status: 'unprobeable', detail: "the probe's container port isn't published" }), "probe can't run: the probe's container port isn't published");
+    });
+});
+
+describe('renderStatus', () => {
+    const now = Date.parse('2025-03-01T15:12:00Z');
+
+    it('shows duration in state for non-docker services', () => {
+        const html = renderStatus({ state: 'running', status: 'active (running)', source: 'systemd', last_state_change: '2025-03-01T12:00:00Z' }, now);
+        assert(html.includes('<span class="state-since">for 3h 12m</span>'), 'should show duration');
+        assert(html.includes('title="active (running) (for 3h 12m)"'), 'tooltip should include duration');
+    });
+
+    it('keeps docker duration in tooltip only', () => {
+        const html = renderStatus({ state: 'running', status: 'Up 3 hours', source: 'docker', last_state_change: '2025-03-01T12:00:00Z' }, now);
+        assert(!html.includes('state-since'), 'docker status already includes duration');
+        assert(html.includes('title="Up 3 hours (for 3h 12m)"'), 'tooltip should include duration');
+    });
+
+    it('omits duration when unknown', () => {
+        const html = renderStatus({ state: 'stopped', status: 'inactive (dead)', source: 'systemd' }, now);
+        assert(!html.includes('state-since'), 'should not show duration');
+        assert(html.includes('title="inactive (dead)"'), 'tooltip should be plain status');
+        assert(html.includes('badge-stopped'), 'should use status class');
+    });
+
+    it('shows services whose compose profile is not active as not enabled', () => {
+        const html = renderStatus({ state: 'not_enabled', status: 'Exited (0) 2 days ago', source: 'docker', profiles: ['debug', 'perf'] }, now);
+        assert(html.includes('badge-not-enabled'), 'should use the not-enabled class');
+        assert(html.includes('<span class="status-text">not enabled</span>'), 'should say not enabled rather than the exit');
+        assert(html.includes('title="Exited (0) 2 days ago (only runs with profile debug or perf)"'), 'tooltip should name the profiles');
+    });
+
+    it('adds the exit code of stopped containers to the tooltip', () => {
+        const html = renderStatus({ state: 'exited', status: 'Exited (137) 2 hours ago', source: 'docker', exit_code: 137 }, now);
+        assert(html.includes('title="Exited (137) 2 hours ago (exit code 137, killed or out of memory)"'), 'tooltip should include exit reason');
+    });
+
+    it('adds a failing health probe to the tooltip', () => {
+        const html = renderStatus({ state: 'unhealthy', status: 'Up 3 hours', source: 'docker',
+            probe: { status: 'failing', detail: 'answered 503 Service Unavailable' } }, now);
+        assert(html.includes('badge-unhealthy'), 'should use the unhealthy class');
+        assert(html.includes('title="Up 3 hours (probe failing: answered 503 Service Unavailable)"'), 'tooltip should include the probe failure');
+    });
+
+    it('adds the last dashboard action to the tooltip', () => {
+        const html = renderStatus({ state: 'running', status: 'Up 3 hours', source: 'docker',
+            last_action: { action: 'restart', user: 'alice', time: '2025-03-01T15:07:00Z', result: 'success' } }, now);
+        assert(html.includes('title="Up 3 hours (last restart by alice 5m ago: success)"'), 'tooltip should include the last action');
+        assert(!html.includes('status-external'), 'should not flag a container the dashboard started');
+    });
+
+    it('flags containers started outside the dashboard', () => {
+        const html = renderStatus({ state: 'running', status: 'Up 2 minutes', source: 'docker', externally_restarted: true }, now);
+        assert(html.includes('status-external'), 'should show the external badge');
+    });
+
+    it('flags changes made during host maintenance', () => {
+        const html = renderStatus({ state: 'stopped', status: 'Exited (0)', source: 'docker', maintenance: true }, now);
+        assert(html.includes('status-maintenance'), 'should show the maintenance badge');
+        assert(!renderStatus({ state: 'stopped', status: 'Exited (0)', source: 'docker' }, now).includes('status-maintenance'), 'should not flag other changes');
+    });
+
+    it('shows the sparkline of sampled containers', () => {
+        statsState.samples['nas:web'] = [
+            { t: '2025-03-01T15:11:00Z', cpu_pct: 10, mem_bytes: 1024 },
+            { t: '2025-03-01T15:11:30Z', cpu_pct: 20, mem_bytes: 2048 }
+        ];
+        const html = renderStatus({ state: 'running', status: 'Up 2 minutes', source: 'docker', host: 'nas', name: 'web', stats_sampled: true }, now);
+        assert(html.includes('class="stats-sparkline"><svg'), 'should draw the cached samples');
+        assert(!renderStatus({ state: 'running', status: 'Up', source: 'docker', host: 'nas', name: 'db' }, now).includes('stats-sparkline'),
+            'should not add a sparkline to containers that are not sampled');
+        delete statsState.samples['nas:web'];
+    });
+});
+
+describe('renderSparkline', () => {
+    it('draws one point per sample, scaled to the peak', () => {
+        const svg = renderSparkline([
+            { cpu_pct: 0, mem_bytes: 0 },
+            { cpu_pct: 50, mem_bytes: 0 },
+            { cpu_pct: 25, mem_bytes: 3 * 1024 * 1024 }
+        ], 60, 16);
+        assert(svg.includes('points="0.0,15.0 30.0,1.0 60.0,8.0"'), `unexpected points: ${svg}`);
+        assert(svg.includes('<title>CPU 25.0% (peak 50.0%), memory 3.00M</title>'), `unexpected title: ${svg}`);
+    });
+
+    it('keeps idle containers near the bottom', () => {
+        const svg = renderSparkline([{ cpu_pct: 0.5, mem_bytes: 0 }, { cpu_pct: 1, mem_bytes: 0 }], 10, 16);
+        assert(svg.includes('points="0.0,13.6 10.0,12.2"'), `unexpected points: ${svg}`);
+    });
+
+    it('is empty with fewer than two samples', () => {
+        assertEqual(renderSparkline(undefined), '');
+        assertEqual(renderSparkline([]), '');
+        assertEqual(renderSparkline([{ cpu_pct: 5, mem_bytes: 0 }]), '');
+    });
+});
+
+describe('formatLastAction', () => {
+    const now = Date.parse('2025-03-01T15:12:00Z');
+
+    it('describes the action', () => {
+        assertEqual(formatLastAction({ action: 'stop', user: 'bob', time: '2025-03-01T13:12:00Z', result: 'failed' }, now), 'last stop by bob 2h ago: failed');
+        assertEqual(formatLastAction({ action: 'start', time: 'bogus' }, now), 'last start');
+    });
+
+    it('is empty without an action', () => {
+        assertEqual(formatLastAction(undefined, now), '');
+        assertEqual(formatLastAction(null, now), '');
+    });
+});
+
+describe('formatExitReason', () => {
+    it('describes common exit codes', () => {
+        assertEqual(formatExitReason({ state: 'exited', exit_code: 0 }), 'exit code 0, clean');
+        assertEqual(formatExitReason({ state: 'exited', exit_code: 143 }), 'exit code 143, terminated');
+        assertEqual(formatExitReason({ state: 'exited', exit_code: 1, exit_error: 'port is already allocated' }), 'exit code 1: port is already allocated');
+    });
+
+    it('is empty without an exit code or while running', () => {
+        assertEqual(formatExitReason({ state: 'exited' }), '');
+        assertEqual(formatExitReason({ state: 'running', exit_code: 0 }), '');
+    });
+});
+
+
+describe('projectLinks', () => {
+    const links = [
+        { name: 'Photos', url: 'https://photos.lan', group: 'immich' },
+        { name: 'Immich admin', url: 'https://immich.lan/admin', group: 'immich', host: 'pi' },
+        { name: 'Router', url: 'http://192.168.1.1' }
+    ];
+
+    it('returns the global and same-host links of the project', () => {
+        const names = projectLinks({ project: 'immich', host: 'pi' }, links).map(l => l.name);
+        assertDeepEqual(names, ['Photos', 'Immich admin']);
+    });
+
+    it('leaves out other hosts\' links', () => {
+        const names = projectLinks({ project: 'immich', host: 'nas' }, links).map(l => l.name);
+        assertDeepEqual(names, ['Photos']);
+    });
+
+    it('returns nothing for services without a project', () => {
+        assertDeepEqual(projectLinks({ host: 'nas' }, links), []);
+    });
+
+    it('renders the links with their icon and escaped name', () => {
+        const html = renderProjectLinks({ project: 'media', host: 'nas' }, [
+            { name: 'Plex <admin>', url: 'https://plex.lan', group: 'media', icon: 'bi-film', description: 'Plex web' }
+        ]);
+        assert(html.includes('href="https://plex.lan"'), 'Should link to the URL');
+        assert(html.includes('bi-film'), 'Should use the icon');
+        assert(html.includes('Plex &lt;admin&gt;'), 'Should escape the name');
+        assert(html.includes('title="Plex web"'), 'Should use the description as the title');
+    });
+});
+
+describe('ungroupedLinks', () => {
+    it('returns the links without a group or without a shown project', () => {
+        const links = [
+            { name: 'Photos', url: 'https://photos.lan', group: 'immich' },
+            { name: 'Plex', url: 'https://plex.lan', group: 'media' },
+            { name: 'Router', url: 'http://192.168.1.1' }
+        ];
+        const names = ungroupedLinks(links, [{ name: 'immich-server', project: 'immich', host: 'nas' }]).map(l => l.name);
+        assertDeepEqual(names, ['Plex', 'Router']);
+    });
+
+    it('handles missing links', () => {
+        assertDeepEqual(ungroupedLinks(null, []), []);
+    });
+});
@@ -37,10 +37,20 @@ export const servicesState = {
     activeFilter: null,           // Status filter: null | { status: 'running'|'stopped', mode: 'include'|'exclude'|'exclusive' }
     activeSourceFilter: null,     // Source filter: null | { source: string, mode: 'include'|'exclude'|'exclusive' }
     activeHostFilters: {},        // Host filters: { [hostname]: 'include'|'exclude'|'exclusive' }
+    showHidden: false,            // Admin view: include services hidden by labels
+    links: [],                    // Configured links the user may see, from /api/links
     sortColumn: null,
     sortDirection: 'asc'
 };
 
+/**
+ * Container stats state: recent samples of services the monitor samples,
+ * keyed by "host:name", kept so re-rendered rows keep their sparkline.
+ */
+export const statsState = {
+    samples: {}
+};
+
 /**
  * Table search state
  */
@@ -79,6 +89,24 @@ export const authState = {
     status: null
 };
 
+/**
+ * Check if the signed-in user has a permission on a service.
+ * Users with global access, and services granted without a permissions
+ * list, have every permission.
+ * @param {string} host - Host of the service
+ * @param {string} serviceName - Name of the service
+ * @param {string} permission - 'view', 'logs' or 'control'
+ * @returns {boolean} True if the user has the permission
+ */
+export function userHasPermission(host, serviceName, permission) {
+    const user = authState.status?.user;
+    if (!user || user.has_global_access) {
+        return true;
+    }
+    const permissions = user.service_permissions?.[host]?.[serviceName];
+    return !permissions || permissions.includes(permission);
+}
+
 /**
  * WebSocket state
  */
@@ -11,8 +11,10 @@ import {
     helpState,
     authState,
     resetLogsState,
-    resetTableSearchState
+    resetTableSearchState,
+    userHasPermission
 } from './state.js';
+import { servicesUrl, readServicesSnapshot } from './api.js';
 
 describe('logsState', () => {
     it('has default values', () => {
@@ -56,6 +58,41 @@ describe('servicesState', () => {
         // May have been modified by other tests, so just check structure
         assertEqual(Array.isArray(servicesState.all), true);
         assertEqual(servicesState.sortDirection, 'asc');
+        assertEqual(servicesState.showHidden, false);
+    });
+});
+
+describe('servicesUrl', () => {
+    it('requests hidden services only in the admin view', () => {
+        servicesState.showHidden = false;
+        assertEqual(servicesUrl(), '/api/services');
+        servicesState.showHidden = true;
+        assertEqual(servicesUrl(), '/api/services?include_hidden=true');
+        servicesState.showHidden = false;
+    });
+});
+
+describe('readServicesSnapshot', () => {
+    const docWith = (json) => ({
+        getElementById: (id) => id === 'services-snapshot' && json !== null ? { textContent: json } : null
+    });
+
+    it('keeps the embedded services, without hidden ones', () => {
+        servicesState.all = [];
+        const services = readServicesSnapshot(docWith(JSON.stringify({
+            services: [{ name: 'plex' }, { name: 'old', hidden: true }],
+            loading: false
+        })));
+        assertDeepEqual(services.map(s => s.name), ['plex']);
+        assertDeepEqual(servicesState.all.map(s => s.name), ['plex']);
+        servicesState.all = [];
+    });
+
+    it('ignores missing, loading and broken snapshots', () => {
+        assertEqual(readServicesSnapshot(docWith(null)), null);
+        assertEqual(readServicesSnapshot(docWith('{"services":[],"loading":true}')), null);
+        assertEqual(readServicesSnapshot(docWith('{"services":')), null);
+        assertEqual(readServicesSnapshot(null), null);
     });
 });
 
@@ -107,3 +144,21 @@ describe('authState', () => {
         assertEqual(authState.status, null);
     });
 });
+
+describe('userHasPermission', () => {
+    it('grants everything without limited permissions', () => {
+        authState.status = null;
+        assertEqual(userHasPermission('nas', 'plex', 'control'), true);
+        authState.status = { user: { has_global_access: true, service_permissions: { nas: { plex: ['view'] } } } };
+        assertEqual(userHasPermission('nas', 'plex', 'control'), true);
+        authState.status = { user: { allowed_services: { nas: ['plex'] } } };
+        assertEqual(userHasPermission('nas', 'plex', 'control'), true);
+    });
+
+    it('checks limited permissions', () => {
+        authState.status = { user: { service_permissions: { nas: { plex: ['view', 'logs'] } } } };
+        assertEqual(userHasPermission('nas', 'plex', 'logs'), true);
+        assertEqual(userHasPermission('nas', 'plex', 'control'), false);
+        authState.status = null;
+    });
+});
@@ -23,21 +23,42 @@ export function escapeHtml(text) {
         .replace(/'/g, '&#039;');
 }
 
+/**
+ * Check whether a service state means its processes are alive, so it can be
+ * stopped or restarted rather than started.
+ * @param {string} state - Service state (running, starting, stopped, etc.)
+ * @returns {boolean} - True for running, unhealthy, starting and stopping
+ */
+export function isUpState(state) {
+    return ['running', 'unhealthy', 'starting', 'stopping'].includes((state || '').toLowerCase());
+}
+
 /**
  * Get CSS class for service status badge.
- * @param {string} state - Service state (running, stopped, etc.)
+ * @param {string} state - Service state (running, stopped, starting, etc.)
  * @param {string} status - Service status details
- * @returns {string} - CSS class name (running, unhealthy, stopped)
+ * @returns {string} - CSS class name (running, unhealthy, transition, unknown, stopped)
  */
 export function getStatusClass(state, status) {
     state = state.toLowerCase();
     status = status.toLowerCase();
 
-    if (state === 'running') {
-        if (status.includes('unhealthy')) {
+    switch (state) {
+        case 'running':
+            if (status.includes('unhealthy')) {
+                return 'unhealthy';
+            }
+            return 'running';
+        case 'unhealthy':
             return 'unhealthy';
-        }
-        return 'running';
+        case 'starting':
+        case 'stopping':
+        case 'paused':
+            return 'transition';
+        case 'unknown':
+            return 'unknown';
+        case 'not_enabled':
+            return 'not-enabled';
     }
     return 'stopped';
 }
@@ -76,3 +97,99 @@ export function formatLogSize(bytes) {
         return `${size.toFixed(2)}${units[unitIndex]}`;
     }
 }
+
+/**
+ * Build a URL for a port on a host, bracketing IPv6 addresses.
+ * @param {string} protocol - URL protocol ("http" or "https")
+ * @param {string} host - IP address or hostname (IPv6 may already be bracketed)
+ * @param {number} port - Port number
+ * @returns {string} - URL like "http://[fd00::1]:8080"
+ */
+export function buildHostURL(protocol, host, port) {
+    const needsBrackets = host.includes(':') && !host.startsWith('[');
+    const hostPart = needsBrackets ? `[${host}]` : host;
+    return `${protocol}://${hostPart}:${port}`;
+}
+
+/**
+ * Format a duration as a compact string using its two largest units.
+ * @param {number} ms - Duration in milliseconds
+ * @returns {string} - Formatted duration (e.g., "3h 12m", "2d 4h", "<1m")
+ */
+export function formatDuration(ms) {
+    if (!Number.isFinite(ms) || ms < 60000) {
+        return '<1m';
+    }
+
+    const minutes = Math.floor(ms / 60000);
+    const days = Math.floor(minutes / 1440);
+    const hours = Math.floor((minutes % 1440) / 60);
+    const mins = minutes % 60;
+
+    if (days > 0) {
+        return hours > 0 ? `${days}d ${hours}h` : `${days}d`;
+    }
+    if (hours > 0) {
+        return mins > 0 ? `${hours}h ${mins}m` : `${hours}h`;
+    }
+    return `${mins}m`;
+}
+
+/**
+ * Format how long a service has been in its current state.
+ * @param {string} lastStateChange - ISO timestamp of the last state change
+ * @param {number} [now] - Current time in milliseconds (defaults to Date.now())
+ * @returns {string} - Text like "for 3h 12m", or '' if the time is unknown
+ */
+export function formatStateSince(lastStateChange, now = Date.now()) {
+    if (!lastStateChange) {
+        return '';
+    }
+    const since = Date.parse(lastStateChange);
+    if (Number.isNaN(since)) {
+        return '';
+    }
+    return `for ${formatDuration(now - since)}`;
+}
+
+/**
+ * Format how long ago an image was built, in days, months or years.
+ * @param {string} created - ISO timestamp of the image build
+ * @param {number} [now] - Current time in milliseconds (defaults to Date.now())
+ * @returns {string} - Text like "14 months ago", or '' if the time is unknown
+ */
+export function formatImageAge(created, now = Date.now()) {
+    if (!created) {
+        return '';
+    }
+    const built = Date.parse(created);
+    if (Number.isNaN(built)) {
+        return '';
+    }
+
+    const days = Math.max(0, Math.floor((now - built) / 86400000));
+    const plural = (n, unit) => `${n} ${unit}${n === 1 ? '' : 's'} ago`;
+    if (days < 60) {
+        return plural(days, 'day');
+    }
+    const months = Math.floor(days / 30.44);
+    if (months < 24) {
+        return plural(months, 'month');
+    }
+    return plural(Math.floor(days / 365.25), 'year');
+}
+
+/**
+ * Describe a scoped user's access for display next to their name.
+ * @param {Object} [access] - Access summary from /auth/status ({global, hosts, service_count})
+ * @returns {string} - Text like "Limited access: 2 services on nas", or '' for full access
+ */
+export function formatAccessSummary(access) {
+    if (!access || access.global) {
+        return '';
+    }
+    const count = access.service_count || 0;
+    const services = `${count} service${count === 1 ? '' : 's'}`;
+    const hosts = access.hosts || [];
+    return hosts.length > 0 ? `Limited access: ${services} on ${hosts.join(', ')}` : `Limited access: ${services}`;
+}
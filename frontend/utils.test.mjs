@@ -3,7 +3,7 @@
  */
 
 import { describe, it, assert, assertEqual } from './test-utils.mjs';
-import { escapeHtml, getStatusClass, formatLogSize } from './utils.js';
+import { escapeHtml, getStatusClass, isUpState, formatLogSize, buildHostURL, formatDuration, formatStateSince, formatImageAge, formatAccessSummary } from './utils.js';
 
 describe('escapeHtml', () => {
     it('escapes HTML special characters', () => {
@@ -39,6 +39,29 @@ describe('getStatusClass', () => {
         assertEqual(getStatusClass('RUNNING', 'UP'), 'running');
         assertEqual(getStatusClass('Running', 'Up (UNHEALTHY)'), 'unhealthy');
     });
+
+    it('maps the finer-grained states', () => {
+        assertEqual(getStatusClass('unhealthy', 'error (v1.2)'), 'unhealthy');
+        assertEqual(getStatusClass('starting', 'activating (start)'), 'transition');
+        assertEqual(getStatusClass('stopping', 'deactivating (stop)'), 'transition');
+        assertEqual(getStatusClass('paused', 'Up 5 minutes (Paused)'), 'transition');
+        assertEqual(getStatusClass('unknown', ''), 'unknown');
+        assertEqual(getStatusClass('not_enabled', 'Exited (0) 2 days ago'), 'not-enabled');
+    });
+});
+
+describe('isUpState', () => {
+    it('treats states with live processes as up', () => {
+        for (const state of ['running', 'unhealthy', 'starting', 'stopping', 'Running']) {
+            assertEqual(isUpState(state), true);
+        }
+    });
+
+    it('treats other states as down', () => {
+        for (const state of ['stopped', 'paused', 'unknown', '', undefined]) {
+            assertEqual(isUpState(state), false);
+        }
+    });
 });
 
 describe('formatLogSize', () => {
@@ -82,3 +105,92 @@ describe('formatLogSize', () => {
         assertEqual(formatLogSize(1099511627776), '1.00T');
     });
 });
+
+describe('buildHostURL', () => {
+    it('builds IPv4 URLs', () => {
+        assertEqual(buildHostURL('http', '192.168.1.10', 8080), 'http://192.168.1.10:8080');
+    });
+
+    it('brackets IPv6 addresses', () => {
+        assertEqual(buildHostURL('http', 'fd00::10', 8080), 'http://[fd00::10]:8080');
+    });
+
+    it('keeps already bracketed IPv6 addresses', () => {
+        assertEqual(buildHostURL('https', '[fd00::10]', 443), 'https://[fd00::10]:443');
+    });
+
+    it('keeps hostnames as-is', () => {
+        assertEqual(buildHostURL('http', 'nas.lan', 3000), 'http://nas.lan:3000');
+    });
+});
+
+describe('formatDuration', () => {
+    it('returns <1m for under a minute', () => {
+        assertEqual(formatDuration(0), '<1m');
+        assertEqual(formatDuration(59999), '<1m');
+    });
+
+    it('formats minutes', () => {
+        assertEqual(formatDuration(5 * 60000), '5m');
+    });
+
+    it('formats hours and minutes', () => {
+        assertEqual(formatDuration((3 * 60 + 12) * 60000), '3h 12m');
+        assertEqual(formatDuration(2 * 3600000), '2h');
+    });
+
+    it('formats days and hours', () => {
+        assertEqual(formatDuration((2 * 24 + 4) * 3600000 + 30 * 60000), '2d 4h');
+        assertEqual(formatDuration(3 * 86400000), '3d');
+    });
+
+    it('handles invalid input', () => {
+        assertEqual(formatDuration(NaN), '<1m');
+        assertEqual(formatDuration(-5000), '<1m');
+    });
+});
+
+describe('formatStateSince', () => {
+    const now = Date.parse('2025-03-01T15:12:00Z');
+
+    it('formats time since the last state change', () => {
+        assertEqual(formatStateSince('2025-03-01T12:00:00Z', now), 'for 3h 12m');
+    });
+
+    it('returns empty string when unknown', () => {
+        assertEqual(formatStateSince(undefined, now), '');
+        assertEqual(formatStateSince('', now), '');
+        assertEqual(formatStateSince('not a date', now), '');
+    });
+});
+
+describe('formatImageAge', () => {
+    const now = Date.parse('2025-06-01T00:00:00Z');
+
+    it('uses days for recent images', () => {
+        assertEqual(formatImageAge('2025-05-31T00:00:00Z', now), '1 day ago');
+        assertEqual(formatImageAge('2025-05-01T00:00:00Z', now), '31 days ago');
+    });
+
+    it('uses months and years for older images', () => {
+        assertEqual(formatImageAge('2024-03-20T00:00:00Z', now), '14 months ago');
+        assertEqual(formatImageAge('2022-05-01T00:00:00Z', now), '3 years ago');
+    });
+
+    it('returns empty string when unknown', () => {
+        assertEqual(formatImageAge(undefined, now), '');
+        assertEqual(formatImageAge('not a date', now), '');
+    });
+});
+
+describe('formatAccessSummary', () => {
+    it('returns empty string for full or unknown access', () => {
+        assertEqual(formatAccessSummary(undefined), '');
+        assertEqual(formatAccessSummary({ global: true, service_count: 0 }), '');
+    });
+
+    it('describes scoped access', () => {
+        assertEqual(formatAccessSummary({ global: false, hosts: ['nas', 'pi'], service_count: 3 }), 'Limited access: 3 services on nas, pi');
+        assertEqual(formatAccessSummary({ global: false, hosts: ['nas'], service_count: 1 }), 'Limited access: 1 service on nas');
+    });
+});
@@ -8,6 +8,7 @@ import { websocketState } from './state.js';
 // Message types (must match backend)
 const MessageType = {
     SERVICE_UPDATE: 'service_update',
+    SERVICE_REMOVED: 'service_removed',
     HOST_UNREACHABLE: 'host_unreachable',
     HOST_RECOVERED: 'host_recovered',
     PING: 'ping'
@@ -21,6 +22,7 @@ const RECONNECT_BACKOFF = 1.5;        // Exponential backoff multiplier
 // Callbacks registered for different event types
 let eventCallbacks = {
     service_update: [],
+    service_removed: [],
     host_unreachable: [],
     host_recovered: [],
     connect: [],
@@ -164,6 +166,9 @@ function handleMessage(event) {
                 case MessageType.SERVICE_UPDATE:
                     handleServiceUpdate(message.payload, message.timestamp);
                     break;
+                case MessageType.SERVICE_REMOVED:
+                    handleServiceRemoved(message.payload, message.timestamp);
+                    break;
                 case MessageType.HOST_UNREACHABLE:
                     handleHostUnreachable(message.payload, message.timestamp);
                     break;
@@ -199,6 +204,23 @@ function handleServiceUpdate(payload, timestamp) {
     });
 }
 
+/**
+ * Handle service removed message.
+ * @param {Object} payload - The service removed payload
+ * @param {number} timestamp - The event timestamp
+ */
+function handleServiceRemoved(payload, timestamp) {
+    console.log('WebSocket: service removed', payload);
+    
+    eventCallbacks.service_removed.forEach(cb => {
+        try {
+            cb(payload, timestamp);
+        } catch (e) {
+            console.error('WebSocket: service_removed callback error', e);
+        }
+    });
+}
+
 /**
  * Handle host unreachable message.
  * @param {Object} payload - The host event payload
@@ -297,7 +319,7 @@ function updateConnectionIndicator() {
 
 /**
  * Register a callback for a specific event type.
- * @param {string} eventType - Event type: 'service_update', 'host_unreachable', 'host_recovered', 'connect', 'disconnect', 'error'
+ * @param {string} eventType - Event type: 'service_update', 'service_removed', 'host_unreachable', 'host_recovered', 'connect', 'disconnect', 'error'
  * @param {Function} callback - Callback function
  * @returns {Function} Unsubscribe function
  */
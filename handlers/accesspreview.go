@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/config"
+	"home_server_dashboard/services"
+)
+
+// PreviewedService is a service a group's grants match.
+type PreviewedService struct {
+	Name        string   `json:"name"`
+	Source      string   `json:"source"`
+	Permissions []string `json:"permissions"`
+}
+
+// UnmatchedGrant is a grant of a group that matches none of the services
+// collected, and why.
+type UnmatchedGrant struct {
+	Host    string `json:"host"`
+	Service string `json:"service"`
+	Reason  string `json:"reason"`
+}
+
+// AccessPreview is what a group's grants resolve to.
+type AccessPreview struct {
+	Group     string                        `json:"group"`
+	Collected time.Time                     `json:"collected"` // When the services matched were collected
+	Hosts     map[string][]PreviewedService `json:"hosts"`
+	Unmatched []UnmatchedGrant              `json:"unmatched"`
+}
+
+// Reasons a grant matches nothing.
+const (
+	unmatchedUnknownHost = "host is not configured"
+	unmatchedNoServices  = "no services were collected from the host"
+	unmatchedNoService   = "no such service on the host"
+)
+
+// previewAccess resolves the grants of group against svcList the way a
+// member's access is checked: by host and service name, with the
+// permissions of every grant of the same service added together.
+func previewAccess(cfg *config.Config, group string, grants *config.OIDCGroupConfig, svcList []services.ServiceInfo) AccessPreview {
+	preview := AccessPreview{Group: group, Hosts: map[string][]PreviewedService{}, Unmatched: []UnmatchedGrant{}}
+
+	configured := make(map[string]bool, len(cfg.Hosts))
+	for _, host := range cfg.Hosts {
+		configured[host.Name] = true
+	}
+	collected := make(map[string]map[string][]services.ServiceInfo)
+	for _, svc := range svcList {
+		if collected[svc.Host] == nil {
+			collected[svc.Host] = make(map[string][]services.ServiceInfo)
+		}
+		collected[svc.Host][svc.Name] = append(collected[svc.Host][svc.Name], svc)
+	}
+
+	for host, entries := range grants.Services {
+		granted := make(map[string]map[string]bool)
+		for _, entry := range entries {
+			if granted[entry.Name] == nil {
+				granted[entry.Name] = make(map[string]bool)
+			}
+			for _, permission := range entry.Granted() {
+				granted[entry.Name][permission] = true
+			}
+		}
+		for name, set := range granted {
+			matches := collected[host][name]
+			if len(matches) == 0 {
+				reason := unmatchedNoService
+				if !configured[host] {
+					reason = unmatchedUnknownHost
+				} else if collected[host] == nil {
+					reason = unmatchedNoServices
+				}
+				preview.Unmatched = append(preview.Unmatched, UnmatchedGrant{Host: host, Service: name, Reason: reason})
+				continue
+			}
+			var permissions []string
+			for _, permission := range config.AllPermissions {
+				if set[permission] {
+					permissions = append(permissions, permission)
+				}
+			}
+			for _, svc := range matches {
+				preview.Hosts[host] = append(preview.Hosts[host], PreviewedService{Name: name, Source: svc.Source, Permissions: permissions})
+			}
+		}
+	}
+
+	for _, svcs := range preview.Hosts {
+		sort.Slice(svcs, func(i, j int) bool {
+			if svcs[i].Name != svcs[j].Name {
+				return svcs[i].Name < svcs[j].Name
+			}
+			return svcs[i].Source < svcs[j].Source
+		})
+	}
+	sort.Slice(preview.Unmatched, func(i, j int) bool {
+		a, b := preview.Unmatched[i], preview.Unmatched[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		return a.Service < b.Service
+	})
+	return preview
+}
+
+// AccessPreviewHandler handles GET /api/auth/access-preview?group=<name>
+// requests. Returns the services currently known that the OIDC group's
+// grants resolve to, by host with the permissions granted, and the grants
+// that match nothing, e.g. those left behind by a renamed host or
+// container. Only administrators may preview access.
+func AccessPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil || !user.IsAdmin {
+		http.Error(w, "Access denied: administrator privileges required to preview group access", http.StatusForbidden)
+		return
+	}
+
+	group := r.URL.Query().Get("group")
+	if group == "" {
+		http.Error(w, "group is required", http.StatusBadRequest)
+		return
+	}
+	cfg := configSource()
+	if cfg == nil {
+		http.Error(w, "Configuration not loaded", http.StatusInternalServerError)
+		return
+	}
+	var grants *config.OIDCGroupConfig
+	if cfg.OIDC != nil {
+		grants = cfg.OIDC.Groups[group]
+	}
+	if grants == nil {
+		http.Error(w, "No services are configured for group "+group, http.StatusNotFound)
+		return
+	}
+
+	snapshot, _, err := servicesCache.get(r.Context(), cfg)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error getting services: %v", err), http.StatusInternalServerError)
+		return
+	}
+	preview := previewAccess(cfg, group, grants, snapshot.services)
+	preview.Collected = snapshot.collected
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
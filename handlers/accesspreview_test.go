@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/config"
+	"home_server_dashboard/services"
+)
+
+func TestAccessPreviewHandler(t *testing.T) {
+	cfg := &config.Config{
+		Hosts: []config.HostConfig{{Name: "nas"}, {Name: "pi"}},
+		OIDC: &config.OIDCConfig{Groups: map[string]*config.OIDCGroupConfig{
+			"media": {Services: map[string][]config.GroupService{
+				"nas": {
+					{Name: "jellyfin", Permissions: []string{"logs"}},
+					{Name: "jellyfin", Permissions: []string{"control"}},
+					{Name: "backup.service"},
+					{Name: "sonarr"},
+				},
+				"pi":      {{Name: "pihole"}},
+				"old-nas": {{Name: "radarr"}},
+			}},
+		}},
+	}
+	origCache, origConfig := servicesCache, configSource
+	servicesCache = newSnapshotCache()
+	servicesCache.collect = func(ctx context.Context, cfg *config.Config) ([]services.ServiceInfo, error) {
+		// Nothing could be collected from pi
+		return []services.ServiceInfo{
+			{Name: "jellyfin", Host: "nas", Source: "docker"},
+			{Name: "backup.service", Host: "nas", Source: "systemd"},
+			{Name: "plex", Host: "nas", Source: "docker"},
+		}, nil
+	}
+	SetConfigSource(func() *config.Config { return cfg })
+	t.Cleanup(func() {
+		servicesCache = origCache
+		configSource = origConfig
+	})
+
+	get := func(query string, user *auth.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/access-preview"+query, nil)
+		if user != nil {
+			req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, user))
+		}
+		w := httptest.NewRecorder()
+		AccessPreviewHandler(w, req)
+		return w
+	}
+	admin := &auth.User{ID: "admin", IsAdmin: true}
+
+	w := get("?group=media", admin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d: %s", w.Code, w.Body.String())
+	}
+	var preview AccessPreview
+	if err := json.Unmarshal(w.Body.Bytes(), &preview); err != nil {
+		t.Fatal(err)
+	}
+	wantHosts := map[string][]PreviewedService{"nas": {
+		{Name: "backup.service", Source: "systemd", Permissions: config.AllPermissions},
+		{Name: "jellyfin", Source: "docker", Permissions: config.AllPermissions},
+	}}
+	if !reflect.DeepEqual(preview.Hosts, wantHosts) {
+		t.Errorf("Hosts = %+v, want %+v", preview.Hosts, wantHosts)
+	}
+	wantUnmatched := []UnmatchedGrant{
+		{Host: "nas", Service: "sonarr", Reason: unmatchedNoService},
+		{Host: "old-nas", Service: "radarr", Reason: unmatchedUnknownHost},
+		{Host: "pi", Service: "pihole", Reason: unmatchedNoServices},
+	}
+	if !reflect.DeepEqual(preview.Unmatched, wantUnmatched) {
+		t.Errorf("Unmatched = %+v, want %+v", preview.Unmatched, wantUnmatched)
+	}
+	if preview.Group != "media" || preview.Collected.IsZero() {
+		t.Errorf("preview = %+v", preview)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		user  *auth.User
+		want  int
+	}{
+		{"without a group", "", admin, http.StatusBadRequest},
+		{"unknown group", "?group=devops", admin, http.StatusNotFound},
+		{"not an admin", "?group=media", &auth.User{ID: "user"}, http.StatusForbidden},
+		{"without a user", "?group=media", nil, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := get(tt.query, tt.user); w.Code != tt.want {
+				t.Errorf("Status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviewAccess_LimitedPermissions(t *testing.T) {
+	cfg := &config.Config{Hosts: []config.HostConfig{{Name: "nas"}}}
+	grants := &config.OIDCGroupConfig{Services: map[string][]config.GroupService{
+		"nas": {{Name: "plex", Permissions: []string{"logs"}}},
+	}}
+	preview := previewAccess(cfg, "household", grants, []services.ServiceInfo{{Name: "plex", Host: "nas", Source: "docker"}})
+	want := []PreviewedService{{Name: "plex", Source: "docker", Permissions: []string{config.PermissionView, config.PermissionLogs}}}
+	if !reflect.DeepEqual(preview.Hosts["nas"], want) || len(preview.Unmatched) != 0 {
+		t.Errorf("previewAccess() = %+v, want %+v", preview, want)
+	}
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/locks"
+)
+
+// plannedStep is one step of a service action.
+type plannedStep struct {
+	// Description says what the step does, e.g. "docker compose up -d sonarr (in /srv/media)".
+	Description string
+	// run performs the step, reporting progress through sendEvent.
+	run func(ctx context.Context, sendEvent func(string, string)) error
+}
+
+// actionPlan is a validated service action: the steps that perform it, in order.
+// Planning only looks things up; nothing changes until the steps run.
+type actionPlan struct {
+	steps []plannedStep
+	// locked means the steps run under the compose project lock of the request.
+	locked bool
+	// verify, if set, checks that the service exists. It is only called for
+	// dry runs, since a real step fails on a missing service anyway.
+	verify func(ctx context.Context) (string, error)
+	// closers release what the steps use (providers) once they are done.
+	closers []func()
+}
+
+// add appends a step to the plan.
+func (p *actionPlan) add(description string, run func(ctx context.Context, sendEvent func(string, string)) error) {
+	p.steps = append(p.steps, plannedStep{Description: description, run: run})
+}
+
+// onClose registers f to be called by close.
+func (p *actionPlan) onClose(f func()) {
+	p.closers = append(p.closers, f)
+}
+
+// close releases what the plan's steps use.
+func (p *actionPlan) close() {
+	for i := len(p.closers) - 1; i >= 0; i-- {
+		p.closers[i]()
+	}
+}
+
+// actionPlanner validates a start/stop/restart action and plans its steps,
+// reporting what it finds through sendEvent. It must not change anything.
+type actionPlanner func(ctx context.Context, cfg *config.Config, req ServiceActionRequest, action string, sendEvent func(string, string)) (*actionPlan, error)
+
+// actionExecutor runs the steps of a plan.
+type actionExecutor interface {
+	Run(ctx context.Context, step plannedStep, sendEvent func(string, string)) error
+}
+
+// stepRunner is the actionExecutor that performs each step.
+type stepRunner struct{}
+
+// Run performs step.
+func (stepRunner) Run(ctx context.Context, step plannedStep, sendEvent func(string, string)) error {
+	return step.run(ctx, sendEvent)
+}
+
+// executor runs every planned action (replaced in tests).
+var executor actionExecutor = stepRunner{}
+
+// executePlan runs the steps of plan with exec, under the project lock if the
+// plan needs it, stopping at the first step that fails.
+func executePlan(ctx context.Context, req ServiceActionRequest, action string, plan *actionPlan, exec actionExecutor, sendEvent func(string, string)) error {
+	if plan.locked {
+		// Serialize with other operations on the same compose project
+		release, err := acquireProjectLock(ctx, req, action, sendEvent)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	for _, step := range plan.steps {
+		if err := exec.Run(ctx, step, sendEvent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// describePlan reports what executing plan would do without running any of
+// its steps: whether the service exists, whether the project lock is busy,
+// and each step.
+func describePlan(ctx context.Context, req ServiceActionRequest, plan *actionPlan, sendEvent func(string, string)) error {
+	if plan.verify != nil {
+		found, err := plan.verify(ctx)
+		if err != nil {
+			return err
+		}
+		sendEvent("status", found)
+	}
+	if project := config.NormalizeProjectName(req.Project); plan.locked && project != "" {
+		if holder, busy := locks.Default().Holder(req.Host, project); busy {
+			sendEvent("status", fmt.Sprintf("Would wait for in-progress operation: %s", holder))
+		}
+	}
+	for _, step := range plan.steps {
+		sendEvent("status", "would run: "+step.Description)
+	}
+	return nil
+}
+
+// composeStep returns a step running "docker compose <args>" in dir, sending
+// status first. Output is reported as status events. If tolerateFailure is
+// set, a failing command only reports its output.
+func composeStep(dir, status string, tolerateFailure bool, args ...string) plannedStep {
+	return plannedStep{
+		Description: fmt.Sprintf("docker compose %s (in %s)", strings.Join(args, " "), dir),
+		run: func(ctx context.Context, sendEvent func(string, string)) error {
+			sendEvent("status", status)
+			cmd := exec.CommandContext(ctx, "docker", append([]string{"compose"}, args...)...)
+			cmd.Dir = dir
+			killGroupOnCancel(cmd)
+			output, err := cmd.CombinedOutput()
+			return reportComposeOutput(args, output, err, tolerateFailure, sendEvent)
+		},
+	}
+}
+
+// reportComposeOutput reports the output of a "docker compose <args>" run
+// that returned err as a status event, and returns the error the step fails
+// with. If tolerateFailure is set, a failed run only reports its output.
+func reportComposeOutput(args []string, output []byte, err error, tolerateFailure bool, sendEvent func(string, string)) error {
+	trimmed := strings.TrimSpace(string(output))
+	if err != nil {
+		subcommand := composeSubcommand(args)
+		if tolerateFailure {
+			sendEvent("status", fmt.Sprintf("docker compose %s output: %s", subcommand, trimmed))
+			return nil
+		}
+		return fmt.Errorf("docker compose %s failed: %s - %w", subcommand, trimmed, err)
+	}
+	if len(trimmed) > 0 {
+		sendEvent("status", trimmed)
+	}
+	return nil
+}
+
+// settleAfter returns step followed by a brief pause once it succeeds, so
+// the cleanup of a compose down is done before the next step.
+func settleAfter(step plannedStep) plannedStep {
+	run := step.run
+	step.run = func(ctx context.Context, sendEvent func(string, string)) error {
+		if err := run(ctx, sendEvent); err != nil {
+			return err
+		}
+		time.Sleep(500 * time.Millisecond)
+		return nil
+	}
+	return step
+}
+
+// composeSubcommand returns the compose command args run, such as "up",
+// skipping the flags before it and the profile they name.
+func composeSubcommand(args []string) string {
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--profile":
+			i++
+		case !strings.HasPrefix(args[i], "-"):
+			return args[i]
+		}
+	}
+	return ""
+}
+
+// commandWaitDelay is how long a killed command's output is waited for
+// before its pipes are closed.
+const commandWaitDelay = 5 * time.Second
+
+// killGroupOnCancel runs cmd in a process group of its own and makes the
+// end of its context kill the whole group, so the processes it started (the
+// compose plugin under the docker CLI, and whatever that runs) don't
+// outlive a timed out or cancelled action.
+func killGroupOnCancel(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = commandWaitDelay
+}
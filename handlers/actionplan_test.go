@@ -0,0 +1,461 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"home_server_dashboard/actionhistory"
+	"home_server_dashboard/config"
+	"home_server_dashboard/services"
+)
+
+// planOf returns a planner whose plan is a single step running run.
+func planOf(run func(ctx context.Context, sendEvent func(string, string)) error) actionPlanner {
+	return func(ctx context.Context, cfg *config.Config, req ServiceActionRequest, action string, sendEvent func(string, string)) (*actionPlan, error) {
+		plan := &actionPlan{}
+		plan.add(action+" "+req.ServiceName, run)
+		return plan, nil
+	}
+}
+
+// recordingExecutor records the steps it is asked to run without running them.
+type recordingExecutor struct {
+	steps []string
+}
+
+func (e *recordingExecutor) Run(ctx context.Context, step plannedStep, sendEvent func(string, string)) error {
+	e.steps = append(e.steps, step.Description)
+	return nil
+}
+
+// useExecutor replaces the executor for the rest of the test.
+func useExecutor(t *testing.T, exec actionExecutor) {
+	t.Helper()
+	original := executor
+	executor = exec
+	t.Cleanup(func() { executor = original })
+}
+
+// stepDescriptions returns the descriptions of the steps of plan.
+func stepDescriptions(plan *actionPlan) []string {
+	var descriptions []string
+	for _, step := range plan.steps {
+		descriptions = append(descriptions, step.Description)
+	}
+	return descriptions
+}
+
+// discardEvents is a sendEvent that drops every event.
+func discardEvents(string, string) {}
+
+// TestPlanDockerAction_StartStop tests that Docker start and stop go through the
+// Docker API under the project lock.
+func TestPlanDockerAction_StartStop(t *testing.T) {
+	cfg := &config.Config{Hosts: []config.HostConfig{{Name: "nas", Address: "localhost"}}}
+	req := ServiceActionRequest{ContainerName: "media-sonarr-1", ServiceName: "sonarr", Source: "docker", Host: "nas", Project: "media"}
+
+	for _, action := range []string{"start", "stop"} {
+		t.Run(action, func(t *testing.T) {
+			plan, err := planDockerAction(context.Background(), cfg, req, action, discardEvents)
+			if err != nil {
+				t.Fatalf("planDockerAction() error = %v", err)
+			}
+			defer plan.close()
+
+			want := []string{"docker " + action + " media-sonarr-1"}
+			if got := stepDescriptions(plan); !reflect.DeepEqual(got, want) {
+				t.Errorf("steps = %q, want %q", got, want)
+			}
+			if !plan.locked {
+				t.Error("plan does not take the project lock")
+			}
+			if plan.verify == nil {
+				t.Error("plan has no service check")
+			}
+		})
+	}
+}
+
+// TestPlanHomeAssistantAction_Container tests that core start and stop are
+// planned as container actions only when the HA container is local.
+func TestPlanHomeAssistantAction_Container(t *testing.T) {
+	ha := &config.HomeAssistantConfig{LongLivedToken: "token", ContainerName: "homeassistant"}
+	cfg := &config.Config{Hosts: []config.HostConfig{
+		{Name: "ha", Address: "localhost", HomeAssistant: ha},
+		{Name: "remote", Address: "192.168.1.30", HomeAssistant: ha},
+	}}
+
+	for _, action := range []string{"start", "stop", "restart"} {
+		req := ServiceActionRequest{ServiceName: "homeassistant", Source: "homeassistant", Host: "ha"}
+		plan, err := planHomeAssistantAction(context.Background(), cfg, req, action, discardEvents)
+		if err != nil {
+			t.Fatalf("planHomeAssistantAction(%s) error = %v", action, err)
+		}
+		want := []string{"Docker: " + action + " container homeassistant on ha"}
+		if got := stepDescriptions(plan); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s steps = %q, want %q", action, got, want)
+		}
+		plan.close()
+	}
+
+	req := ServiceActionRequest{ServiceName: "homeassistant", Source: "homeassistant", Host: "remote"}
+	if _, err := planHomeAssistantAction(context.Background(), cfg, req, "stop", discardEvents); err == nil {
+		t.Error("planHomeAssistantAction(stop) succeeded for a remote container")
+	}
+}
+
+// TestPlanDockerAction_ComposeRestart tests that a restart plans compose down
+// and up in the project's directory.
+func TestPlanDockerAction_ComposeRestart(t *testing.T) {
+	root := t.TempDir()
+	projectDir := filepath.Join(root, "media")
+	if err := os.Mkdir(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Hosts: []config.HostConfig{{Name: "nas", Address: "localhost", DockerComposeRoots: []string{root}}}}
+	req := ServiceActionRequest{ContainerName: "media-sonarr-1", ServiceName: "sonarr", Source: "docker", Host: "nas", Project: "media"}
+	useComposeWorkingDir(t, "")
+
+	var events []string
+	plan, err := planDockerAction(context.Background(), cfg, req, "restart", func(eventType, message string) {
+		events = append(events, message)
+	})
+	if err != nil {
+		t.Fatalf("planDockerAction() error = %v", err)
+	}
+	defer plan.close()
+
+	want := []string{
+		"docker compose down sonarr (in " + projectDir + ")",
+		"docker compose up -d sonarr (in " + projectDir + ")",
+	}
+	if got := stepDescriptions(plan); !reflect.DeepEqual(got, want) {
+		t.Errorf("steps = %q, want %q", got, want)
+	}
+	if !plan.locked {
+		t.Error("plan does not take the project lock")
+	}
+	if len(events) != 1 || events[0] != "Found compose root: "+projectDir {
+		t.Errorf("events = %q", events)
+	}
+}
+
+// useComposeWorkingDir makes every container report dir as its compose
+// working directory for the rest of the test.
+func useComposeWorkingDir(t *testing.T, dir string) {
+	t.Helper()
+	original := composeWorkingDir
+	composeWorkingDir = func(ctx context.Context, cfg *config.Config, containerName string) string { return dir }
+	t.Cleanup(func() { composeWorkingDir = original })
+}
+
+// TestPlanDockerAction_ComposeRestartDirectory tests where a compose restart
+// runs: in the project's working directory if it has a compose file, else in
+// the compose roots, including those matched by docker_compose_roots_glob.
+func TestPlanDockerAction_ComposeRestartDirectory(t *testing.T) {
+	apps := t.TempDir()
+	for _, dir := range []string{"media", "labelled", "gone"} {
+		if err := os.Mkdir(filepath.Join(apps, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, dir := range []string{"media", "labelled"} {
+		if err := os.WriteFile(filepath.Join(apps, dir, "compose.yaml"), []byte("services: {}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := &config.Config{Hosts: []config.HostConfig{{Name: "nas", Address: "localhost", DockerComposeRootsGlob: []string{filepath.Join(apps, "*")}}}}
+	req := ServiceActionRequest{ContainerName: "media-sonarr-1", ServiceName: "sonarr", Source: "docker", Host: "nas", Project: "media"}
+
+	tests := []struct {
+		name       string
+		workingDir string
+		want       string
+	}{
+		{"working directory", filepath.Join(apps, "labelled"), filepath.Join(apps, "labelled")},
+		{"working directory without a compose file", filepath.Join(apps, "gone"), filepath.Join(apps, "media")},
+		{"no working directory", "", filepath.Join(apps, "media")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useComposeWorkingDir(t, tt.workingDir)
+			plan, err := planDockerAction(context.Background(), cfg, req, "restart", discardEvents)
+			if err != nil {
+				t.Fatalf("planDockerAction() error = %v", err)
+			}
+			defer plan.close()
+
+			want := []string{
+				"docker compose down sonarr (in " + tt.want + ")",
+				"docker compose up -d sonarr (in " + tt.want + ")",
+			}
+			if got := stepDescriptions(plan); !reflect.DeepEqual(got, want) {
+				t.Errorf("steps = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// TestPlanDockerAction_ComposeRestartProjectName tests that the compose
+// root is found by the project name compose gives it: the directory name
+// normalized, or the name key of its compose file.
+func TestPlanDockerAction_ComposeRestartProjectName(t *testing.T) {
+	root := t.TempDir()
+	for dir, compose := range map[string]string{
+		"Media-Stack": "services: {}\n",
+		"Arr":         "name: downloads\nservices: {}\n",
+	} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, dir, "compose.yaml"), []byte(compose), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := &config.Config{Hosts: []config.HostConfig{{Name: "nas", Address: "localhost", DockerComposeRoots: []string{root}}}}
+	useComposeWorkingDir(t, "")
+
+	tests := []struct {
+		project string
+		want    string
+	}{
+		{"media-stack", filepath.Join(root, "Media-Stack")},
+		{"Media-Stack", filepath.Join(root, "Media-Stack")},
+		{"downloads", filepath.Join(root, "Arr")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.project, func(t *testing.T) {
+			req := ServiceActionRequest{ContainerName: "app-1", ServiceName: "app", Source: "docker", Host: "nas", Project: tt.project}
+			plan, err := planDockerAction(context.Background(), cfg, req, "restart", discardEvents)
+			if err != nil {
+				t.Fatalf("planDockerAction() error = %v", err)
+			}
+			defer plan.close()
+
+			want := []string{
+				"docker compose down app (in " + tt.want + ")",
+				"docker compose up -d app (in " + tt.want + ")",
+			}
+			if got := stepDescriptions(plan); !reflect.DeepEqual(got, want) {
+				t.Errorf("steps = %q, want %q", got, want)
+			}
+		})
+	}
+
+	// A directory whose compose file names the project isn't found by its own name
+	if got := findComposeRoot(cfg, "arr"); got != "" {
+		t.Errorf("findComposeRoot(arr) = %q, want none", got)
+	}
+}
+
+// TestPlanDockerAction_ComposeRestartProfile tests that a restart activates
+// the profile asked for, if the project's compose file has it.
+func TestPlanDockerAction_ComposeRestartProfile(t *testing.T) {
+	dir := t.TempDir()
+	compose := "services:\n  web: {image: nginx}\n  adminer:\n    image: adminer\n    profiles: [debug]\n"
+	if err := os.WriteFile(filepath.Join(dir, "compose.yaml"), []byte(compose), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Hosts: []config.HostConfig{{Name: "nas", Address: "localhost"}}}
+	useComposeWorkingDir(t, dir)
+	req := ServiceActionRequest{ContainerName: "media-adminer-1", ServiceName: "adminer", Source: "docker", Host: "nas", Project: "media", Profile: "debug"}
+
+	plan, err := planDockerAction(context.Background(), cfg, req, "restart", discardEvents)
+	if err != nil {
+		t.Fatalf("planDockerAction() error = %v", err)
+	}
+	defer plan.close()
+	want := []string{
+		"docker compose --profile debug down adminer (in " + dir + ")",
+		"docker compose --profile debug up -d adminer (in " + dir + ")",
+	}
+	if got := stepDescriptions(plan); !reflect.DeepEqual(got, want) {
+		t.Errorf("steps = %q, want %q", got, want)
+	}
+
+	// A profile the compose file doesn't have is refused
+	req.Profile = "perf"
+	if _, err := planDockerAction(context.Background(), cfg, req, "restart", discardEvents); err == nil || !strings.Contains(err.Error(), `unknown profile "perf"`) {
+		t.Errorf("planDockerAction() with an unknown profile error = %v", err)
+	}
+
+	// Without the compose project, there is no restart with a profile
+	useComposeWorkingDir(t, "")
+	req.Profile = "debug"
+	if _, err := planDockerAction(context.Background(), cfg, req, "restart", discardEvents); err == nil {
+		t.Error("planDockerAction() without a compose project = nil error")
+	}
+}
+
+func TestComposeSubcommand(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"up", "-d", "web"}, "up"},
+		{[]string{"--profile", "debug", "down", "web"}, "down"},
+		{[]string{"--profile", "up", "up"}, "up"},
+		{nil, ""},
+	} {
+		if got := composeSubcommand(tt.args); got != tt.want {
+			t.Errorf("composeSubcommand(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+// TestPlanProviderAction_Systemd tests the plan of systemd actions.
+func TestPlanProviderAction_Systemd(t *testing.T) {
+	cfg := &config.Config{Hosts: []config.HostConfig{
+		{Name: "nas", Address: "192.168.1.10", SystemdServices: []string{"nginx.service"}},
+	}}
+	req := ServiceActionRequest{ContainerName: "nginx.service", ServiceName: "nginx.service", Source: "systemd", Host: "nas"}
+
+	for _, action := range []string{"start", "stop", "restart"} {
+		t.Run(action, func(t *testing.T) {
+			plan, err := planProviderAction(context.Background(), cfg, req, action, discardEvents)
+			if err != nil {
+				t.Fatalf("planProviderAction() error = %v", err)
+			}
+			defer plan.close()
+
+			want := []string{action + " nginx.service on nas (systemd)"}
+			if got := stepDescriptions(plan); !reflect.DeepEqual(got, want) {
+				t.Errorf("steps = %q, want %q", got, want)
+			}
+			if plan.locked {
+				t.Error("systemd plan takes a project lock")
+			}
+		})
+	}
+}
+
+// TestServiceActionHandler_DryRun tests that a dry run validates the action and
+// streams its steps without executing or recording anything.
+func TestServiceActionHandler_DryRun(t *testing.T) {
+	configJSON := `{"hosts": [{"name": "fakehost", "address": "192.168.1.50"}, {"name": "nas", "address": "192.168.1.10", "systemd_services": ["backup.service:ro"]}]}`
+	cleanup := setupTestConfig(t, configJSON)
+	defer cleanup()
+
+	original := actionHistory
+	SetActionHistory(actionhistory.NewStore(actionhistory.DefaultPerService, actionhistory.DefaultMaxOutput))
+	defer func() { actionHistory = original }()
+
+	actions := registerFakeProvider(t, services.Capabilities{Actions: true})
+	exec := &recordingExecutor{}
+	useExecutor(t, exec)
+
+	post := func(url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		ServiceActionHandler(w, req)
+		return w
+	}
+	widget := `{"container_name": "widget", "service_name": "widget", "source": "fake", "host": "fakehost"}`
+
+	t.Run("dry run", func(t *testing.T) {
+		w := post("/api/services/restart?dry_run=true", widget)
+		body := w.Body.String()
+		for _, want := range []string{"Found widget (running)", "would run: restart widget on fakehost (fake)", "event: complete\ndata: dry-run"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("body missing %q: %s", want, body)
+			}
+		}
+		if len(exec.steps) != 0 || len(*actions) != 0 {
+			t.Errorf("dry run executed steps %q, actions %q", exec.steps, *actions)
+		}
+		if list := actionHistory.List("fakehost", "widget"); len(list) != 0 {
+			t.Errorf("dry run was recorded in the action history: %+v", list)
+		}
+	})
+
+	t.Run("real run uses the executor", func(t *testing.T) {
+		w := post("/api/services/restart", widget)
+		if !strings.Contains(w.Body.String(), "data: success") {
+			t.Errorf("restart did not succeed: %s", w.Body.String())
+		}
+		if want := []string{"restart widget on fakehost (fake)"}; !reflect.DeepEqual(exec.steps, want) {
+			t.Errorf("executed steps = %q, want %q", exec.steps, want)
+		}
+	})
+
+	t.Run("read-only service", func(t *testing.T) {
+		w := post("/api/services/restart?dry_run=true", `{"service_name": "backup.service", "source": "systemd", "host": "nas"}`)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Status = %d, want %d", w.Code, http.StatusForbidden)
+		}
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		w := post("/api/services/restart?dry_run=maybe", widget)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Status = %d, want %d", w.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+// fakeIntentRecorder is a fakeStateTracker that records the intents it is told about.
+type fakeIntentRecorder struct {
+	fakeStateTracker
+	log *[]string
+}
+
+func (f fakeIntentRecorder) ExpectAction(host, serviceName, action, user string) {
+	*f.log = append(*f.log, "expect "+action+" "+host+"/"+serviceName+" by "+user)
+}
+
+func (f fakeIntentRecorder) CancelExpectedAction(host, serviceName string) {
+	*f.log = append(*f.log, "cancel "+host+"/"+serviceName)
+}
+
+// failingExecutor fails every step.
+type failingExecutor struct{}
+
+func (failingExecutor) Run(ctx context.Context, step plannedStep, sendEvent func(string, string)) error {
+	return errors.New("connection refused")
+}
+
+// TestServiceActionHandler_RecordsIntent tests that the monitor is told about
+// an action before it runs, and that the intent is withdrawn if it fails.
+func TestServiceActionHandler_RecordsIntent(t *testing.T) {
+	cleanup := setupTestConfig(t, `{"hosts": [{"name": "fakehost", "address": "192.168.1.50"}]}`)
+	defer cleanup()
+	registerFakeProvider(t, services.Capabilities{Actions: true})
+
+	var intents []string
+	original := stateTracker
+	defer SetStateTracker(original)
+	SetStateTracker(fakeIntentRecorder{fakeStateTracker: fakeStateTracker{}, log: &intents})
+
+	post := func(url string) string {
+		req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(`{"container_name": "widget", "service_name": "widget", "source": "fake", "host": "fakehost"}`))
+		w := httptest.NewRecorder()
+		ServiceActionHandler(w, req)
+		return w.Body.String()
+	}
+
+	useExecutor(t, &recordingExecutor{})
+	post("/api/services/stop?dry_run=true")
+	if len(intents) != 0 {
+		t.Errorf("dry run recorded intents: %q", intents)
+	}
+	if body := post("/api/services/stop"); !strings.Contains(body, "data: success") {
+		t.Fatalf("stop did not succeed: %s", body)
+	}
+	if want := []string{"expect stop fakehost/widget by dashboard"}; !reflect.DeepEqual(intents, want) {
+		t.Errorf("intents = %q, want %q", intents, want)
+	}
+
+	intents = nil
+	useExecutor(t, failingExecutor{})
+	post("/api/services/restart")
+	if want := []string{"expect restart fakehost/widget by dashboard", "cancel fakehost/widget"}; !reflect.DeepEqual(intents, want) {
+		t.Errorf("intents after a failed restart = %q, want %q", intents, want)
+	}
+}
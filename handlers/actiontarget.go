@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/services/docker"
+)
+
+// actionTargetTimeout bounds looking up the container an action targets.
+const actionTargetTimeout = 10 * time.Second
+
+// TargetChangedError reports that a container name no longer belongs to the
+// container the client expected to act on, e.g. because it was removed and
+// compose created another container with the same name. It is sent to the
+// client as JSON with status 409.
+type TargetChangedError struct {
+	Code                string `json:"code"` // always "target_changed"
+	Message             string `json:"error"`
+	ContainerName       string `json:"container_name"`
+	ExpectedContainerID string `json:"expected_container_id"`
+	ContainerID         string `json:"container_id,omitempty"` // empty if no container has the name any more
+}
+
+func (e *TargetChangedError) Error() string {
+	return e.Message
+}
+
+// newTargetChangedError returns the error for a container name that now
+// belongs to the container with ID actual, or to none if actual is "".
+func newTargetChangedError(name, expected, actual string) *TargetChangedError {
+	message := fmt.Sprintf("%s is now container %s, not %s: reload the dashboard and try again", name, shortContainerID(actual), shortContainerID(expected))
+	if actual == "" {
+		message = fmt.Sprintf("no container is named %s any more: reload the dashboard and try again", name)
+	}
+	return &TargetChangedError{
+		Code:                "target_changed",
+		Message:             message,
+		ContainerName:       name,
+		ExpectedContainerID: expected,
+		ContainerID:         actual,
+	}
+}
+
+// shortContainerID returns the 12 characters of a container ID Docker shows.
+func shortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// containerIDOf returns the ID of the container named name on host, or ""
+// if there is none (replaced in tests). Containers on remote hosts are
+// inspected over SSH.
+var containerIDOf = func(ctx context.Context, cfg *config.Config, host *config.HostConfig, name string) (string, error) {
+	if !host.IsLocal() {
+		var stdout, stderr bytes.Buffer
+		if err := runSSH(ctx, host, shellCommand("docker", "inspect", "--format", "{{.Id}}", name), &stdout, &stderr); err != nil {
+			if strings.Contains(stderr.String(), "No such") {
+				return "", nil
+			}
+			return "", fmt.Errorf("failed to inspect container %s on %s: %s - %w", name, host.Name, strings.TrimSpace(stderr.String()), err)
+		}
+		return strings.TrimSpace(stdout.String()), nil
+	}
+
+	localHostName := "localhost"
+	if cfg != nil {
+		localHostName = cfg.GetLocalHostName()
+	}
+	dockerProvider, err := docker.NewProvider(localHostName)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Docker provider: %w", err)
+	}
+	defer dockerProvider.Close()
+	return dockerProvider.ContainerID(ctx, name)
+}
+
+// containerProjectOf returns the compose project of the container named name
+// on host, or "" for a container not managed by compose (replaced in tests).
+// Containers on remote hosts are inspected over SSH.
+var containerProjectOf = func(ctx context.Context, cfg *config.Config, host *config.HostConfig, name string) (string, error) {
+	if !host.IsLocal() {
+		var stdout, stderr bytes.Buffer
+		format := fmt.Sprintf("{{index .Config.Labels %q}}", "com.docker.compose.project")
+		if err := runSSH(ctx, host, shellCommand("docker", "inspect", "--format", format, name), &stdout, &stderr); err != nil {
+			return "", fmt.Errorf("failed to inspect container %s on %s: %s - %w", name, host.Name, strings.TrimSpace(stderr.String()), err)
+		}
+		return strings.TrimSpace(stdout.String()), nil
+	}
+
+	localHostName := "localhost"
+	if cfg != nil {
+		localHostName = cfg.GetLocalHostName()
+	}
+	dockerProvider, err := docker.NewProvider(localHostName)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Docker provider: %w", err)
+	}
+	defer dockerProvider.Close()
+	return dockerProvider.ComposeProject(ctx, name)
+}
+
+// actionProject returns the compose project a Docker action is on, read from
+// the container's labels rather than trusted from the client, so the project
+// lock can't be sidestepped by naming another project. It fails if the
+// container can't be inspected.
+func actionProject(ctx context.Context, cfg *config.Config, req ServiceActionRequest) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, actionTargetTimeout)
+	defer cancel()
+
+	project, err := containerProjectOf(ctx, cfg, hostOrLocal(cfg, req.Host), req.ContainerName)
+	if err != nil {
+		return "", fmt.Errorf("cannot read the compose project of %s: %w", req.ContainerName, err)
+	}
+	return project, nil
+}
+
+// actionTarget returns the ID of the container a Docker action is on. With
+// an expected_container_id, it checks that the container name still belongs
+// to that container, returning a *TargetChangedError if it doesn't. Without
+// one, nothing is checked: the ID is only looked up on the local host, for
+// the action history, and is "" if it can't be.
+func actionTarget(ctx context.Context, cfg *config.Config, req ServiceActionRequest) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, actionTargetTimeout)
+	defer cancel()
+
+	host := hostOrLocal(cfg, req.Host)
+	if req.ExpectedContainerID == "" {
+		if !host.IsLocal() {
+			return "", nil
+		}
+		id, _ := containerIDOf(ctx, cfg, host, req.ContainerName)
+		return id, nil
+	}
+
+	id, err := containerIDOf(ctx, cfg, host, req.ContainerName)
+	if err != nil {
+		return "", fmt.Errorf("cannot check that %s is still the same container: %w", req.ContainerName, err)
+	}
+	if id != req.ExpectedContainerID {
+		return "", newTargetChangedError(req.ContainerName, req.ExpectedContainerID, id)
+	}
+	return id, nil
+}
+
+// writeActionTargetError responds 409 with a *TargetChangedError as JSON,
+// and 502 with any other error from actionTarget.
+func writeActionTargetError(w http.ResponseWriter, err error) {
+	var changed *TargetChangedError
+	if !errors.As(err, &changed) {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(changed)
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"home_server_dashboard/actionhistory"
+	"home_server_dashboard/config"
+	"home_server_dashboard/locks"
+)
+
+// withContainerProject makes every container Docker actions are on belong
+// to the compose project project for the rest of the test.
+func withContainerProject(t *testing.T, project string) {
+	t.Helper()
+	original := containerProjectOf
+	containerProjectOf = func(ctx context.Context, cfg *config.Config, host *config.HostConfig, name string) (string, error) {
+		return project, nil
+	}
+	t.Cleanup(func() { containerProjectOf = original })
+}
+
+// TestServiceActionHandler_TargetChanged tests that a Docker action naming
+// the container it expects is refused once the name belongs to another
+// container, and runs as before otherwise.
+func TestServiceActionHandler_TargetChanged(t *testing.T) {
+	cleanup := setupTestConfig(t, `{"hosts": [{"name": "nas", "address": "localhost"}]}`)
+	defer cleanup()
+	withContainerProject(t, "")
+
+	original := actionHistory
+	SetActionHistory(actionhistory.NewStore(actionhistory.DefaultPerService, actionhistory.DefaultMaxOutput))
+	defer func() { actionHistory = original }()
+
+	// jellyfin was recreated: its name now belongs to container new-id
+	currentID := "new-id"
+	originalIDOf := containerIDOf
+	containerIDOf = func(ctx context.Context, cfg *config.Config, host *config.HostConfig, name string) (string, error) {
+		return currentID, nil
+	}
+	defer func() { containerIDOf = originalIDOf }()
+
+	var ran []string
+	originalPlanner := actionPlanners["docker"]
+	actionPlanners["docker"] = planOf(func(ctx context.Context, sendEvent func(string, string)) error {
+		ran = append(ran, "restart")
+		return nil
+	})
+	defer func() { actionPlanners["docker"] = originalPlanner }()
+
+	restart := func(expectedID string) *httptest.ResponseRecorder {
+		body := `{"container_name": "jellyfin", "service_name": "jellyfin", "source": "docker", "host": "nas", "expected_container_id": "` + expectedID + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/services/restart", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		ServiceActionHandler(w, req)
+		return w
+	}
+
+	t.Run("mismatch", func(t *testing.T) {
+		ran = nil
+		w := restart("old-id")
+		if w.Code != http.StatusConflict {
+			t.Fatalf("Status = %d, want %d: %s", w.Code, http.StatusConflict, w.Body.String())
+		}
+		var got TargetChangedError
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatalf("failed to decode error: %v", err)
+		}
+		if got.Code != "target_changed" || got.ExpectedContainerID != "old-id" || got.ContainerID != "new-id" || got.Message == "" {
+			t.Errorf("error = %+v", got)
+		}
+		if len(ran) != 0 {
+			t.Errorf("action ran on the wrong container: %q", ran)
+		}
+	})
+
+	t.Run("container removed", func(t *testing.T) {
+		currentID = ""
+		defer func() { currentID = "new-id" }()
+		if w := restart("old-id"); w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "any more") {
+			t.Errorf("Status = %d, body %s; want 409 naming the missing container", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("match", func(t *testing.T) {
+		ran = nil
+		body := restart("new-id").Body.String()
+		if !strings.Contains(body, "event: container\ndata: new-id") || !strings.Contains(body, "data: success") {
+			t.Errorf("body missing the acted-on container: %s", body)
+		}
+		if len(ran) != 1 {
+			t.Errorf("ran = %q, want one restart", ran)
+		}
+		if list := actionHistory.List("nas", "jellyfin"); len(list) == 0 || list[0].ContainerID != "new-id" {
+			t.Errorf("history = %+v, want the container ID recorded", list)
+		}
+	})
+
+	t.Run("no expected ID", func(t *testing.T) {
+		ran = nil
+		if body := restart("").Body.String(); !strings.Contains(body, "data: success") {
+			t.Errorf("restart without an expected ID failed: %s", body)
+		}
+		if len(ran) != 1 {
+			t.Errorf("ran = %q, want one restart", ran)
+		}
+	})
+}
+
+// TestServiceActionHandler_ProjectFromContainer tests that a Docker action
+// locks the compose project of the container, whatever project the client
+// names, and fails if the container can't be inspected.
+func TestServiceActionHandler_ProjectFromContainer(t *testing.T) {
+	cleanup := setupTestConfig(t, `{"hosts": [{"name": "nas", "address": "localhost"}]}`)
+	defer cleanup()
+
+	var inspectErr error
+	originalProjectOf := containerProjectOf
+	containerProjectOf = func(ctx context.Context, cfg *config.Config, host *config.HostConfig, name string) (string, error) {
+		return "media", inspectErr
+	}
+	defer func() { containerProjectOf = originalProjectOf }()
+
+	var locked []string
+	originalPlanner := actionPlanners["docker"]
+	actionPlanners["docker"] = func(ctx context.Context, cfg *config.Config, req ServiceActionRequest, action string, sendEvent func(string, string)) (*actionPlan, error) {
+		plan := &actionPlan{locked: true}
+		plan.add(action+" "+req.ServiceName, func(ctx context.Context, sendEvent func(string, string)) error {
+			for _, project := range []string{"media", "other"} {
+				if _, busy := locks.Default().Holder("nas", project); busy {
+					locked = append(locked, project)
+				}
+			}
+			return nil
+		})
+		return plan, nil
+	}
+	defer func() { actionPlanners["docker"] = originalPlanner }()
+
+	restart := func() string {
+		body := `{"container_name": "jellyfin", "service_name": "jellyfin", "source": "docker", "host": "nas", "project": "other"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/services/restart", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		ServiceActionHandler(w, req)
+		return w.Body.String()
+	}
+
+	if body := restart(); !strings.Contains(body, "data: success") {
+		t.Fatalf("restart failed: %s", body)
+	}
+	if !slices.Equal(locked, []string{"media"}) {
+		t.Errorf("locked = %q, want the container's project media", locked)
+	}
+
+	locked = nil
+	inspectErr = errors.New("docker is down")
+	body := `{"container_name": "jellyfin", "service_name": "jellyfin", "source": "docker", "host": "nas", "project": "other"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/services/restart", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	ServiceActionHandler(w, req)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("Status = %d, want %d when the container can't be inspected: %s", w.Code, http.StatusBadGateway, w.Body.String())
+	}
+	if len(locked) != 0 {
+		t.Errorf("action ran, locking %q, without the container's project", locked)
+	}
+}
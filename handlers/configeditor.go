@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/config"
+	"home_server_dashboard/notices"
+	"home_server_dashboard/realip"
+	"home_server_dashboard/selftest"
+)
+
+// maxConfigSize is the largest configuration the editor accepts.
+const maxConfigSize = 1 << 20
+
+// configApplyNoticeKey is the key of the notice about applying a
+// configuration failing.
+const configApplyNoticeKey = "config:apply"
+
+// configPath is the configuration file the editor reads and replaces (set
+// by the server package). Empty turns the editor off.
+var configPath string
+
+// SetConfigPath sets the configuration file the config editor works on.
+// Empty, as with the built-in demo configuration, turns the editor off.
+func SetConfigPath(path string) {
+	configPath = path
+}
+
+// runConfigProbes runs the checks of the hosts a candidate config changes
+// (replaced in tests).
+var runConfigProbes = func(ctx context.Context, checks []selftest.Check) *selftest.Report {
+	return selftest.Run(ctx, checks, selftest.DefaultTimeout)
+}
+
+// ConfigValidation is the outcome of validating a candidate configuration.
+type ConfigValidation struct {
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors"` // Every problem found, empty if valid
+	// Probes are the self-test results of the hosts the candidate adds or
+	// changes. A failing probe doesn't make the candidate invalid.
+	Probes []selftest.Result `json:"probes,omitempty"`
+}
+
+// ConfigHandler handles GET /api/config requests.
+// Returns the configuration file as JSON with every secret replaced by
+// config.MaskedSecret. Only administrators may read it.
+func ConfigHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireConfigEditor(w, r, "read the configuration") {
+		return
+	}
+
+	doc, err := config.ReadDocument(configPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	config.MaskSecrets(doc)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(doc)
+}
+
+// ConfigValidateHandler handles POST /api/config/validate requests.
+// It validates the candidate configuration in the body the way loading it
+// would and probes the hosts it adds or changes, without applying it.
+// Masked secrets are checked with their saved values. Only administrators
+// may validate a configuration.
+func ConfigValidateHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireConfigEditor(w, r, "validate the configuration") {
+		return
+	}
+
+	result, cfg := validateCandidate(w, r)
+	if result == nil {
+		return
+	}
+	if cfg != nil {
+		result.Probes = probeChangedHosts(r.Context(), configSource(), cfg)
+	}
+	log.Printf("Audit: user=%s ip=%s action=config-validate valid=%t errors=%d",
+		actionOwner(r.Context()), realip.FromRequest(r), result.Valid, len(result.Errors))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// ConfigApplyHandler handles POST /api/config/apply requests.
+// It validates the candidate configuration in the body and, if it is valid,
+// replaces the configuration file with it, keeping a timestamped backup of
+// the previous one, and loads it. An invalid candidate is refused with 422
+// and its errors. Only administrators may apply a configuration.
+func ConfigApplyHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireConfigEditor(w, r, "change the configuration") {
+		return
+	}
+
+	result, cfg := validateCandidate(w, r)
+	if result == nil {
+		return
+	}
+	owner, ip := actionOwner(r.Context()), realip.FromRequest(r)
+	if cfg == nil {
+		log.Printf("Audit: user=%s ip=%s action=config-apply result=refused errors=%d", owner, ip, len(result.Errors))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	backup, _, err := config.Apply(configPath, cfg.data)
+	if err != nil {
+		log.Printf("Audit: user=%s ip=%s action=config-apply result=failed error=%q", owner, ip, err)
+		noticeBoard.Post(notices.SeverityError, "config", configApplyNoticeKey, "Failed to apply the configuration: "+err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Audit: user=%s ip=%s action=config-apply result=applied backup=%s", owner, ip, backup)
+	noticeBoard.Resolve(configApplyNoticeKey)
+	notices.PostAll(noticeBoard, notices.SeverityWarning, "config", cfg.ValidateGroupConfigs())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"applied": true, "backup": backup})
+}
+
+// requireConfigEditor checks that the config editor is on and the user is
+// an administrator, writing the error response if not.
+func requireConfigEditor(w http.ResponseWriter, r *http.Request, what string) bool {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil || !user.IsAdmin {
+		http.Error(w, "Access denied: administrator privileges required to "+what, http.StatusForbidden)
+		return false
+	}
+	if configPath == "" {
+		http.Error(w, "The configuration is built in and can't be edited", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// candidateConfig is a valid candidate configuration and the document it
+// was parsed from.
+type candidateConfig struct {
+	*config.Config
+	data []byte
+}
+
+// validateCandidate reads the candidate configuration in the body and
+// validates it. It returns the validation result and, if the candidate is
+// valid, the parsed config. A nil result means the body couldn't be read and
+// the error response has been written.
+func validateCandidate(w http.ResponseWriter, r *http.Request) (*ConfigValidation, *candidateConfig) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxConfigSize))
+	if err != nil {
+		http.Error(w, "Failed to read the configuration: "+err.Error(), http.StatusBadRequest)
+		return nil, nil
+	}
+
+	result := &ConfigValidation{Errors: []string{}}
+	data, err := config.PrepareCandidate(body, configPath)
+	if err == nil {
+		var cfg *config.Config
+		if cfg, err = config.Parse(data, configPath); err == nil {
+			result.Valid = true
+			return result, &candidateConfig{Config: cfg, data: data}
+		}
+	}
+	result.Errors = errorList(err)
+	return result, nil
+}
+
+// errorList splits an error joined from several, possibly behind a wrapping
+// message, into their messages.
+func errorList(err error) []string {
+	type multiError interface{ Unwrap() []error }
+	if multi, ok := err.(multiError); ok {
+		var list []string
+		for _, e := range multi.Unwrap() {
+			list = append(list, errorList(e)...)
+		}
+		return list
+	}
+	if inner := errors.Unwrap(err); inner != nil {
+		if _, ok := inner.(multiError); ok {
+			return errorList(inner)
+		}
+	}
+	return []string{err.Error()}
+}
+
+// probeChangedHosts runs the self-test checks of the hosts of candidate that
+// are new or configured differently than in current.
+func probeChangedHosts(ctx context.Context, current *config.Config, candidate *candidateConfig) []selftest.Result {
+	changed := make(map[string]bool)
+	for i := range candidate.Hosts {
+		host := &candidate.Hosts[i]
+		var before *config.HostConfig
+		if current != nil {
+			before = current.GetHostByName(host.Name)
+		}
+		if !sameHost(before, host) {
+			changed[host.Name] = true
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	var checks []selftest.Check
+	for _, check := range selftest.BuildChecks(candidate.Config) {
+		if changed[check.Host] {
+			checks = append(checks, check)
+		}
+	}
+	return runConfigProbes(ctx, checks).Results
+}
+
+// sameHost reports whether two host configs have the same settings.
+func sameHost(a, b *config.HostConfig) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	aJSON, _ := json.Marshal(a)
+	bJSON, _ := json.Marshal(b)
+	return string(aJSON) == string(bJSON)
+}
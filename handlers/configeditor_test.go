@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/config"
+	"home_server_dashboard/selftest"
+)
+
+const editorConfig = `{
+	"hosts": [
+		{"name": "nas", "address": "localhost"},
+		{"name": "pi", "address": "192.168.1.20", "watchtower": {"port": 8080, "token": "wt-secret"}}
+	]
+}`
+
+// useConfigEditor loads editorConfig from a new services.json and points
+// the config editor at it, returning its path.
+func useConfigEditor(t *testing.T) string {
+	t.Helper()
+	setupTestConfig(t, editorConfig)
+	path := filepath.Join(t.TempDir(), "services.json")
+	if err := os.WriteFile(path, []byte(editorConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	original := configPath
+	SetConfigPath(path)
+	t.Cleanup(func() { SetConfigPath(original) })
+	return path
+}
+
+// useConfigProbes replaces the probes of changed hosts with ones that pass,
+// returning the hosts they were run for.
+func useConfigProbes(t *testing.T) *[]string {
+	t.Helper()
+	var hosts []string
+	original := runConfigProbes
+	runConfigProbes = func(ctx context.Context, checks []selftest.Check) *selftest.Report {
+		report := &selftest.Report{}
+		for _, check := range checks {
+			hosts = append(hosts, check.Host)
+			report.Results = append(report.Results, selftest.Result{Host: check.Host, Integration: check.Integration, Check: check.Name, Passed: true})
+		}
+		return report
+	}
+	t.Cleanup(func() { runConfigProbes = original })
+	return &hosts
+}
+
+// configRequest sends a request to the config editor handler as user.
+func configRequest(handler http.HandlerFunc, method, body string, user *auth.User) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/config", strings.NewReader(body))
+	if user != nil {
+		req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, user))
+	}
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+var configAdmin = &auth.User{ID: "admin", Name: "admin", IsAdmin: true}
+
+func TestConfigHandler_MasksSecrets(t *testing.T) {
+	useConfigEditor(t)
+
+	w := configRequest(ConfigHandler, http.MethodGet, "", configAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "wt-secret") {
+		t.Errorf("body contains the Watchtower token: %s", w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"token":"***"`) {
+		t.Errorf("body = %s, want the token masked", w.Body.String())
+	}
+
+	if w := configRequest(ConfigHandler, http.MethodGet, "", &auth.User{ID: "user"}); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	SetConfigPath("")
+	if w := configRequest(ConfigHandler, http.MethodGet, "", configAdmin); w.Code != http.StatusNotFound {
+		t.Errorf("without a config file: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestConfigValidateHandler(t *testing.T) {
+	useConfigEditor(t)
+	probed := useConfigProbes(t)
+
+	// Invalid: every problem is listed
+	w := configRequest(ConfigValidateHandler, http.MethodPost,
+		`{"hosts": [{"name": "nas", "address": "bad host!"}, {"name": "pi", "address": "192.168.1.20", "journal_access": "root"}]}`, configAdmin)
+	var result ConfigValidation
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Valid || len(result.Errors) != 2 {
+		t.Errorf("invalid candidate: result = %+v, want two errors", result)
+	}
+	if len(*probed) != 0 {
+		t.Errorf("invalid candidate probed %v", *probed)
+	}
+
+	// Valid: only the changed host is probed
+	w = configRequest(ConfigValidateHandler, http.MethodPost,
+		`{"hosts": [{"name": "nas", "address": "localhost"}, {"name": "pi", "address": "192.168.1.21", "systemd_services": ["nginx.service"], "watchtower": {"port": 8080, "token": "***"}}]}`, configAdmin)
+	result = ConfigValidation{}
+	json.NewDecoder(w.Body).Decode(&result)
+	if !result.Valid || len(result.Errors) != 0 {
+		t.Errorf("valid candidate: result = %+v", result)
+	}
+	for _, host := range *probed {
+		if host != "pi" {
+			t.Errorf("probed %s, want only the changed host pi", host)
+		}
+	}
+	if len(result.Probes) == 0 {
+		t.Error("valid candidate: no probes of the changed host")
+	}
+}
+
+func TestConfigApplyHandler(t *testing.T) {
+	path := useConfigEditor(t)
+	useConfigProbes(t)
+
+	w := configRequest(ConfigApplyHandler, http.MethodPost,
+		`{"hosts": [{"name": "pi", "address": "192.168.1.21", "watchtower": {"port": 8080, "token": "***"}}]}`, configAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d: %s", w.Code, w.Body.String())
+	}
+	var applied struct {
+		Applied bool   `json:"applied"`
+		Backup  string `json:"backup"`
+	}
+	json.NewDecoder(w.Body).Decode(&applied)
+	if !applied.Applied || applied.Backup == "" {
+		t.Fatalf("response = %+v, want applied with a backup", applied)
+	}
+
+	saved, _ := os.ReadFile(path)
+	if !strings.Contains(string(saved), `"token": "wt-secret"`) || strings.Contains(string(saved), "***") {
+		t.Errorf("saved file = %s, want the masked token merged from the previous file", saved)
+	}
+	if backup, _ := os.ReadFile(applied.Backup); string(backup) != editorConfig {
+		t.Errorf("backup = %s, want the previous file", backup)
+	}
+	if cfg := config.Get(); cfg.GetHostByName("pi").Address != "192.168.1.21" {
+		t.Errorf("loaded config has pi at %s, want the applied address", cfg.GetHostByName("pi").Address)
+	}
+}
+
+func TestConfigApplyHandler_RefusesInvalid(t *testing.T) {
+	path := useConfigEditor(t)
+
+	w := configRequest(ConfigApplyHandler, http.MethodPost, `{"hosts": [{"name": "nas", "address": "bad host!"}]}`, configAdmin)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if saved, _ := os.ReadFile(path); string(saved) != editorConfig {
+		t.Errorf("saved file = %s, want it unchanged", saved)
+	}
+	if matches, _ := filepath.Glob(path + ".*.bak"); len(matches) != 0 {
+		t.Errorf("backups = %v, want none for a refused config", matches)
+	}
+
+	if w := configRequest(ConfigApplyHandler, http.MethodPost, `{"hosts": []}`, &auth.User{ID: "user"}); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
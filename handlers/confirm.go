@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/realip"
+)
+
+// confirmationTTL is how long a confirmation phrase can be used.
+const confirmationTTL = 2 * time.Minute
+
+// maxConfirmedBody is the largest request body a confirmed handler reads.
+const maxConfirmedBody = 1 << 20
+
+// confirmationWords make up the random part of confirmation phrases. They
+// are short and hard to mistype.
+var confirmationWords = []string{
+	"amber", "birch", "cedar", "delta", "ember", "fjord", "granite", "harbor",
+	"indigo", "juniper", "kestrel", "lantern", "meadow", "nickel", "orchid", "pepper",
+	"quartz", "raven", "saffron", "timber", "umber", "violet", "walnut", "yarrow",
+}
+
+// DestructiveAction describes a request to a destructive endpoint for its
+// confirmation phrase, e.g. "remove 2 orphaned containers". It returns false
+// for requests that don't destroy anything, which need no confirmation.
+// body is the request body without its confirmation.
+type DestructiveAction func(body []byte) (string, bool)
+
+// ConfirmationChallenge is the 428 response to a destructive request without
+// a valid confirmation.
+type ConfirmationChallenge struct {
+	Error        string    `json:"error"`
+	Action       string    `json:"action"`
+	Confirmation string    `json:"confirmation"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// pendingConfirmation is a phrase issued for one request of one user.
+type pendingConfirmation struct {
+	userID  string
+	request string // Hash of the request the phrase was issued for
+	expires time.Time
+}
+
+// confirmationStore holds the phrases that have been issued and not yet used.
+type confirmationStore struct {
+	mu      sync.Mutex
+	pending map[string]pendingConfirmation // phrase -> confirmation
+	now     func() time.Time
+}
+
+func newConfirmationStore() *confirmationStore {
+	return &confirmationStore{pending: make(map[string]pendingConfirmation), now: time.Now}
+}
+
+// confirmations is the store used by RequireConfirmation.
+var confirmations = newConfirmationStore()
+
+// issue returns a new phrase for request by userID and when it expires.
+func (s *confirmationStore) issue(action, userID, request string) (string, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for phrase, p := range s.pending {
+		if !now.Before(p.expires) {
+			delete(s.pending, phrase)
+		}
+	}
+
+	phrase := action + " " + randomWord() + "-" + randomWord()
+	expires := now.Add(confirmationTTL)
+	s.pending[phrase] = pendingConfirmation{userID: userID, request: request, expires: expires}
+	return phrase, expires
+}
+
+// use checks phrase against request by userID and, if it matches, uses it
+// up. Otherwise it returns why the phrase was refused. A phrase given for a
+// different request is thrown away, so changing the request needs a new one.
+func (s *confirmationStore) use(phrase, userID, request string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.pending[phrase]
+	switch {
+	case !ok || p.userID != userID:
+		// Another user's phrase is left for them
+		return "confirmation phrase is not valid", false
+	case !s.now().Before(p.expires):
+		delete(s.pending, phrase)
+		return "confirmation phrase has expired", false
+	case p.request != request:
+		delete(s.pending, phrase)
+		return "the request changed since the confirmation phrase was issued", false
+	}
+	delete(s.pending, phrase)
+	return "", true
+}
+
+// randomWord returns a random word from confirmationWords.
+func randomWord() string {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(confirmationWords))))
+	if err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return confirmationWords[n.Int64()]
+}
+
+// requestHash identifies a request by its method, path, query and JSON body,
+// ignoring the order of the body's fields.
+func requestHash(r *http.Request, body map[string]any) string {
+	canonical, _ := json.Marshal(body) // Map keys are sorted
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s?%s\n", r.Method, r.URL.Path, r.URL.RawQuery)
+	h.Write(canonical)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// RequireConfirmation wraps a destructive handler so it only runs once the
+// user has repeated the request with the phrase it was challenged with. The
+// first request gets a 428 with a phrase naming the action; sending the
+// identical request again with the phrase in its "confirmation" field runs
+// it. Phrases are single-use, belong to the user they were issued to, and
+// expire after two minutes. Requests that describe doesn't report as
+// destructive go straight to next, as do requests from users who aren't
+// administrators, which the destructive handlers refuse anyway.
+func RequireConfirmation(describe DestructiveAction, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.GetUserFromContext(r.Context())
+		if r.Method != http.MethodPost || user == nil || !user.IsAdmin {
+			next(w, r)
+			return
+		}
+
+		raw, err := io.ReadAll(io.LimitReader(r.Body, maxConfirmedBody))
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(raw))
+
+		fields := map[string]any{}
+		if len(bytes.TrimSpace(raw)) > 0 {
+			if err := json.Unmarshal(raw, &fields); err != nil {
+				// Let the handler report the bad body
+				next(w, r)
+				return
+			}
+		}
+		phrase, _ := fields["confirmation"].(string)
+		delete(fields, "confirmation")
+		body, _ := json.Marshal(fields)
+
+		action, destructive := describe(body)
+		if !destructive {
+			next(w, r)
+			return
+		}
+
+		request := requestHash(r, fields)
+		owner := actionOwner(r.Context())
+		reason := "this action is destructive: repeat the request with confirmation set to the phrase to run it"
+		if phrase != "" {
+			var ok bool
+			if reason, ok = confirmations.use(phrase, user.ID, request); ok {
+				log.Printf("Audit: user=%s ip=%s action=confirm target=%q result=confirmed", owner, realip.FromRequest(r), action)
+				next(w, r)
+				return
+			}
+			log.Printf("Audit: user=%s ip=%s action=confirm target=%q result=rejected reason=%q", owner, realip.FromRequest(r), action, reason)
+		}
+
+		issued, expires := confirmations.issue(action, user.ID, request)
+		log.Printf("Audit: user=%s ip=%s action=confirm target=%q result=challenged", owner, realip.FromRequest(r), action)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusPreconditionRequired)
+		json.NewEncoder(w).Encode(ConfirmationChallenge{
+			Error:        reason,
+			Action:       action,
+			Confirmation: issued,
+			ExpiresAt:    expires,
+		})
+	}
+}
+
+// CleanupConfirmation describes a cleanup request that removes containers.
+// Listing the orphans needs no confirmation.
+func CleanupConfirmation(body []byte) (string, bool) {
+	var req CleanupRequest
+	if err := json.Unmarshal(body, &req); err != nil || !req.Confirm || len(req.Containers) == 0 {
+		return "", false
+	}
+	if len(req.Containers) == 1 {
+		return "remove 1 orphaned container", true
+	}
+	return fmt.Sprintf("remove %d orphaned containers", len(req.Containers)), true
+}
+
+// LogFlushConfirmation describes a request to truncate a container's logs.
+func LogFlushConfirmation(body []byte) (string, bool) {
+	var req LogFlushRequest
+	if err := json.Unmarshal(body, &req); err != nil || req.Validate(configSource()) != nil {
+		// Invalid requests are rejected by the handler
+		return "", false
+	}
+	return "flush logs of " + req.ContainerName, true
+}
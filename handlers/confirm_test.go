@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"home_server_dashboard/auth"
+)
+
+// confirmTest wraps a handler counting its calls with RequireConfirmation,
+// using a fresh store whose clock the test controls.
+type confirmTest struct {
+	t       *testing.T
+	handler http.HandlerFunc
+	calls   int
+	now     time.Time
+}
+
+func newConfirmTest(t *testing.T) *confirmTest {
+	ct := &confirmTest{t: t, now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	orig := confirmations
+	confirmations = newConfirmationStore()
+	confirmations.now = func() time.Time { return ct.now }
+	t.Cleanup(func() { confirmations = orig })
+
+	ct.handler = RequireConfirmation(CleanupConfirmation, func(w http.ResponseWriter, r *http.Request) {
+		ct.calls++
+		var req CleanupRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			t.Errorf("handler could not decode the body: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	return ct
+}
+
+// post sends body as user and returns the response.
+func (ct *confirmTest) post(user *auth.User, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/services/cleanup", strings.NewReader(body))
+	if user != nil {
+		req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, user))
+	}
+	w := httptest.NewRecorder()
+	ct.handler(w, req)
+	return w
+}
+
+// challenge sends body as user and returns the phrase of the 428 it gets.
+func (ct *confirmTest) challenge(user *auth.User, body string) ConfirmationChallenge {
+	ct.t.Helper()
+	w := ct.post(user, body)
+	if w.Code != http.StatusPreconditionRequired {
+		ct.t.Fatalf("Status = %d, want 428: %s", w.Code, w.Body.String())
+	}
+	var challenge ConfirmationChallenge
+	if err := json.Unmarshal(w.Body.Bytes(), &challenge); err != nil {
+		ct.t.Fatalf("Failed to decode challenge %q: %v", w.Body.String(), err)
+	}
+	return challenge
+}
+
+// withConfirmation adds a confirmation to a JSON object body.
+func withConfirmation(body, phrase string) string {
+	quoted, _ := json.Marshal(phrase)
+	return strings.TrimSuffix(body, "}") + `, "confirmation": ` + string(quoted) + "}"
+}
+
+var (
+	confirmAdmin = &auth.User{ID: "admin-1", Name: "Admin", IsAdmin: true}
+	otherAdmin   = &auth.User{ID: "admin-2", Name: "Other", IsAdmin: true}
+)
+
+const removeBody = `{"confirm": true, "containers": ["abc123", "def456"]}`
+
+func TestRequireConfirmation_HappyPath(t *testing.T) {
+	ct := newConfirmTest(t)
+
+	challenge := ct.challenge(confirmAdmin, removeBody)
+	if ct.calls != 0 {
+		t.Fatal("handler ran without confirmation")
+	}
+	if challenge.Action != "remove 2 orphaned containers" || !strings.HasPrefix(challenge.Confirmation, "remove 2 orphaned containers ") {
+		t.Errorf("challenge = %+v, want it to name the action", challenge)
+	}
+	if want := ct.now.Add(confirmationTTL); !challenge.ExpiresAt.Equal(want) {
+		t.Errorf("ExpiresAt = %v, want %v", challenge.ExpiresAt, want)
+	}
+
+	// Field order doesn't matter, only the fields
+	body := withConfirmation(`{"containers": ["abc123", "def456"], "confirm": true}`, challenge.Confirmation)
+	if w := ct.post(confirmAdmin, body); w.Code != http.StatusOK || ct.calls != 1 {
+		t.Fatalf("confirmed request: status %d, %d calls: %s", w.Code, ct.calls, w.Body.String())
+	}
+
+	// Phrases are single-use
+	if w := ct.post(confirmAdmin, body); w.Code != http.StatusPreconditionRequired || ct.calls != 1 {
+		t.Errorf("reused phrase: status %d, %d calls", w.Code, ct.calls)
+	}
+}
+
+func TestRequireConfirmation_Expired(t *testing.T) {
+	ct := newConfirmTest(t)
+
+	challenge := ct.challenge(confirmAdmin, removeBody)
+	ct.now = ct.now.Add(confirmationTTL + time.Second)
+
+	again := ct.challenge(confirmAdmin, withConfirmation(removeBody, challenge.Confirmation))
+	if ct.calls != 0 {
+		t.Error("handler ran with an expired phrase")
+	}
+	if !strings.Contains(again.Error, "expired") {
+		t.Errorf("Error = %q, want it to say the phrase expired", again.Error)
+	}
+	if again.Confirmation == challenge.Confirmation {
+		t.Error("expired phrase was issued again")
+	}
+}
+
+func TestRequireConfirmation_PayloadMismatch(t *testing.T) {
+	ct := newConfirmTest(t)
+
+	challenge := ct.challenge(confirmAdmin, `{"confirm": true, "containers": ["abc123"]}`)
+
+	// Confirming a different set of containers with the phrase is refused...
+	again := ct.challenge(confirmAdmin, withConfirmation(removeBody, challenge.Confirmation))
+	if ct.calls != 0 {
+		t.Error("handler ran with a phrase issued for another request")
+	}
+	if !strings.Contains(again.Error, "request changed") {
+		t.Errorf("Error = %q, want it to say the request changed", again.Error)
+	}
+
+	// ...and the phrase can't be used for the original request afterwards
+	ct.challenge(confirmAdmin, withConfirmation(`{"confirm": true, "containers": ["abc123"]}`, challenge.Confirmation))
+	if ct.calls != 0 {
+		t.Error("phrase still worked after the request changed")
+	}
+}
+
+func TestRequireConfirmation_OtherUser(t *testing.T) {
+	ct := newConfirmTest(t)
+
+	challenge := ct.challenge(confirmAdmin, removeBody)
+
+	ct.challenge(otherAdmin, withConfirmation(removeBody, challenge.Confirmation))
+	if ct.calls != 0 {
+		t.Fatal("handler ran with another user's phrase")
+	}
+
+	// The phrase still works for the user it was issued to
+	if w := ct.post(confirmAdmin, withConfirmation(removeBody, challenge.Confirmation)); w.Code != http.StatusOK {
+		t.Errorf("Status = %d, want 200 for the phrase's owner", w.Code)
+	}
+}
+
+func TestRequireConfirmation_PassesThrough(t *testing.T) {
+	ct := newConfirmTest(t)
+
+	tests := []struct {
+		name string
+		user *auth.User
+		body string
+	}{
+		{"listing orphans", confirmAdmin, `{}`},
+		{"empty body", confirmAdmin, ``},
+		{"confirm without containers", confirmAdmin, `{"confirm": true}`},
+		{"not an admin", &auth.User{ID: "user-1", Name: "User"}, removeBody},
+		{"no user", nil, removeBody},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := ct.calls
+			if w := ct.post(tt.user, tt.body); w.Code != http.StatusOK || ct.calls != calls+1 {
+				t.Errorf("status %d, handler called %d times, want it called once", w.Code, ct.calls-calls)
+			}
+		})
+	}
+}
+
+func TestLogFlushConfirmation(t *testing.T) {
+	if action, ok := LogFlushConfirmation([]byte(`{"container_name": "web-1"}`)); !ok || action != "flush logs of web-1" {
+		t.Errorf("LogFlushConfirmation() = %q, %v", action, ok)
+	}
+	if _, ok := LogFlushConfirmation([]byte(`{"container_name": "web 1"}`)); ok {
+		t.Error("invalid request should be left to the handler to reject")
+	}
+}
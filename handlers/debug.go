@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime/pprof"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/monitor"
+)
+
+// RuntimeReporter reports the dashboard's own recent resource use. It is
+// implemented by the monitor.
+type RuntimeReporter interface {
+	Runtime() monitor.RuntimeStats
+}
+
+// RuntimeHandler handles GET /api/debug/runtime requests.
+// Returns the dashboard's goroutines, heap and open files over the last hour
+// and the limits they are alerted at. Only administrators may read them.
+func RuntimeHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil || !user.IsAdmin {
+		http.Error(w, "Access denied: administrator privileges required for debug information", http.StatusForbidden)
+		return
+	}
+
+	reporter, _ := stateTracker.(RuntimeReporter)
+	if reporter == nil {
+		http.Error(w, "The monitor is not running", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reporter.Runtime())
+}
+
+// IntentReporter reports the stops and restarts from the dashboard the
+// monitor is waiting for. It is implemented by the monitor.
+type IntentReporter interface {
+	Intents() []monitor.ActionIntent
+}
+
+// IntentsHandler handles GET /api/debug/intents requests.
+// Returns the stops and restarts the monitor expects, which keep a service
+// going down from being notified about, with who asked for them. Only
+// administrators may read them.
+func IntentsHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil || !user.IsAdmin {
+		http.Error(w, "Access denied: administrator privileges required for debug information", http.StatusForbidden)
+		return
+	}
+
+	reporter, _ := stateTracker.(IntentReporter)
+	if reporter == nil {
+		http.Error(w, "The monitor is not running", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reporter.Intents())
+}
+
+// AuthStoreReporter reports the sizes of the in-memory login stores and how
+// many entries were dropped from them. It is implemented by *auth.Provider.
+type AuthStoreReporter interface {
+	StoreStats() auth.StoreStats
+}
+
+// authStores reports the login stores, nil when authentication is off.
+var authStores AuthStoreReporter
+
+// SetAuthStores sets what the login stores are reported from.
+func SetAuthStores(reporter AuthStoreReporter) {
+	authStores = reporter
+}
+
+// AuthStoresHandler handles GET /api/debug/auth requests.
+// Returns how many sessions and pending OIDC logins are held, their limits,
+// and how many were dropped to stay within them; evictions of login states
+// point at something hammering /login. Only administrators may read them.
+func AuthStoresHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil || !user.IsAdmin {
+		http.Error(w, "Access denied: administrator privileges required for debug information", http.StatusForbidden)
+		return
+	}
+	if authStores == nil {
+		http.Error(w, "Authentication is not enabled", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(authStores.StoreStats())
+}
+
+// GoroutinesHandler handles GET /api/debug/goroutines requests.
+// Dumps the goroutine profile as text, goroutines with the same stack
+// counted together, or every goroutine with its full stack and how long it
+// has been waiting with debug=2. It is for diagnosing leaks on a remote
+// instance without exposing a pprof port. Only administrators may read it.
+func GoroutinesHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil || !user.IsAdmin {
+		http.Error(w, "Access denied: administrator privileges required for debug information", http.StatusForbidden)
+		return
+	}
+
+	debug := 1
+	switch r.URL.Query().Get("debug") {
+	case "", "1":
+	case "2":
+		debug = 2
+	default:
+		http.Error(w, "debug must be 1 or 2", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	pprof.Lookup("goroutine").WriteTo(w, debug)
+}
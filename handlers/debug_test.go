@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/config"
+	"home_server_dashboard/events"
+	"home_server_dashboard/monitor"
+)
+
+// fakeRuntimeReporter is a fakeStateTracker that also reports the
+// dashboard's resource use.
+type fakeRuntimeReporter struct {
+	fakeStateTracker
+	stats monitor.RuntimeStats
+}
+
+func (f fakeRuntimeReporter) Runtime() monitor.RuntimeStats {
+	return f.stats
+}
+
+// getDebug requests path from handler as user (nil for none).
+func getDebug(handler http.HandlerFunc, path string, user *auth.User) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if user != nil {
+		req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, user))
+	}
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func TestRuntimeHandler(t *testing.T) {
+	original := stateTracker
+	defer SetStateTracker(original)
+	SetStateTracker(fakeRuntimeReporter{stats: monitor.RuntimeStats{
+		Samples:       []monitor.RuntimeSample{{Goroutines: 42, HeapBytes: 1 << 20, OpenFDs: 17}},
+		MaxGoroutines: 1000,
+		Alerting:      []string{"goroutines growth"},
+	}})
+	admin := &auth.User{ID: "admin", IsAdmin: true}
+
+	w := getDebug(RuntimeHandler, "/api/debug/runtime", admin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d: %s", w.Code, w.Body.String())
+	}
+	var stats monitor.RuntimeStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Samples) != 1 || stats.Samples[0].Goroutines != 42 || stats.Samples[0].OpenFDs != 17 || stats.Alerting[0] != "goroutines growth" {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	if w := getDebug(RuntimeHandler, "/api/debug/runtime", &auth.User{ID: "user"}); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	SetStateTracker(fakeStateTracker{})
+	if w := getDebug(RuntimeHandler, "/api/debug/runtime", admin); w.Code != http.StatusNotFound {
+		t.Errorf("no monitor: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+// fakeIntentReporter is a fakeStateTracker that also reports the expected
+// stops and restarts.
+type fakeIntentReporter struct {
+	fakeStateTracker
+	intents []monitor.ActionIntent
+}
+
+func (f fakeIntentReporter) Intents() []monitor.ActionIntent {
+	return f.intents
+}
+
+func TestIntentsHandler(t *testing.T) {
+	original := stateTracker
+	defer SetStateTracker(original)
+	expires := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	SetStateTracker(fakeIntentReporter{intents: []monitor.ActionIntent{
+		{Host: "nas", Service: "jellyfin", Action: "stop", User: "alice", ExpiresAt: expires, Fulfilled: true},
+	}})
+	admin := &auth.User{ID: "admin", IsAdmin: true}
+
+	w := getDebug(IntentsHandler, "/api/debug/intents", admin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d: %s", w.Code, w.Body.String())
+	}
+	want := `[{"host":"nas","service":"jellyfin","action":"stop","user":"alice","expires_at":"2026-01-02T03:04:05Z","fulfilled":true}]`
+	if got := strings.TrimSpace(w.Body.String()); got != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+
+	// The monitor reports the actions it was told to expect
+	m := monitor.New(&config.Config{}, events.NewBus(false))
+	m.ExpectAction("nas", "sonarr", "restart", "bob")
+	SetStateTracker(m)
+	var intents []monitor.ActionIntent
+	if err := json.Unmarshal(getDebug(IntentsHandler, "/api/debug/intents", admin).Body.Bytes(), &intents); err != nil {
+		t.Fatal(err)
+	}
+	if len(intents) != 1 || intents[0].Service != "sonarr" || intents[0].Action != "restart" || intents[0].User != "bob" {
+		t.Errorf("intents = %+v, want bob's restart of sonarr", intents)
+	}
+
+	if w := getDebug(IntentsHandler, "/api/debug/intents", &auth.User{ID: "user"}); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	SetStateTracker(fakeStateTracker{})
+	if w := getDebug(IntentsHandler, "/api/debug/intents", admin); w.Code != http.StatusNotFound {
+		t.Errorf("no monitor: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestGoroutinesHandler(t *testing.T) {
+	admin := &auth.User{ID: "admin", IsAdmin: true}
+
+	w := getDebug(GoroutinesHandler, "/api/debug/goroutines", admin)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "goroutine profile: total ") {
+		t.Errorf("Status = %d, body = %.100s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "TestGoroutinesHandler") {
+		t.Error("profile doesn't list the test's own goroutine")
+	}
+
+	w = getDebug(GoroutinesHandler, "/api/debug/goroutines?debug=2", admin)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "goroutine ") || !strings.Contains(w.Body.String(), "[running]") {
+		t.Errorf("debug=2: status = %d, body = %.100s", w.Code, w.Body.String())
+	}
+
+	if w := getDebug(GoroutinesHandler, "/api/debug/goroutines?debug=3", admin); w.Code != http.StatusBadRequest {
+		t.Errorf("debug=3: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := getDebug(GoroutinesHandler, "/api/debug/goroutines", &auth.User{ID: "user"}); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := getDebug(GoroutinesHandler, "/api/debug/goroutines", nil); w.Code != http.StatusForbidden {
+		t.Errorf("no user: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+// fakeAuthStores reports fixed login store stats.
+type fakeAuthStores auth.StoreStats
+
+func (f fakeAuthStores) StoreStats() auth.StoreStats {
+	return auth.StoreStats(f)
+}
+
+func TestAuthStoresHandler(t *testing.T) {
+	defer SetAuthStores(authStores)
+	admin := &auth.User{ID: "admin", IsAdmin: true}
+
+	SetAuthStores(nil)
+	if w := getDebug(AuthStoresHandler, "/api/debug/auth", admin); w.Code != http.StatusNotFound {
+		t.Errorf("without auth: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	SetAuthStores(fakeAuthStores{Sessions: 3, MaxSessions: 1000, States: 10000, MaxStates: 10000, StateEvictions: 250})
+	w := getDebug(AuthStoresHandler, "/api/debug/auth", admin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d: %s", w.Code, w.Body.String())
+	}
+	var stats auth.StoreStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Sessions != 3 || stats.StateEvictions != 250 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	if w := getDebug(AuthStoresHandler, "/api/debug/auth", &auth.User{ID: "user"}); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/config"
+	"home_server_dashboard/services"
+)
+
+// discoveryVersion is the version of the discovery document's shape. It only
+// changes when fields are removed or change meaning.
+const discoveryVersion = 1
+
+// DiscoveryDocument lists the services for other dashboards, grouped by
+// compose project (or source, for services without one).
+type DiscoveryDocument struct {
+	Version int              `json:"version"`
+	Groups  []DiscoveryGroup `json:"groups"`
+}
+
+// DiscoveryGroup is a group of services in the discovery document.
+type DiscoveryGroup struct {
+	Name     string             `json:"name"`
+	Services []DiscoveryService `json:"services"`
+}
+
+// DiscoveryService is a service in the discovery document.
+type DiscoveryService struct {
+	Name        string         `json:"name"`                  // Display name, or the service name if there is none
+	Service     string         `json:"service"`               // Service name, as used by the dashboard's API
+	Host        string         `json:"host"`                  // Host name from config
+	Source      string         `json:"source"`                // "docker", "systemd", ...
+	State       services.State `json:"state"`                 // State when the document was built
+	Description string         `json:"description,omitempty"` // Description from labels or the unit
+	URL         string         `json:"url,omitempty"`         // Link to the service, picked by services.PrimaryURL
+	Icon        string         `json:"icon,omitempty"`        // Icon from the icon label
+	HealthURL   string         `json:"health_url,omitempty"`  // URL to probe to check the service is up
+}
+
+// buildDiscovery groups services into a discovery document. Groups and the
+// services in them are sorted by name.
+func buildDiscovery(svcList []services.ServiceInfo) DiscoveryDocument {
+	byGroup := make(map[string][]DiscoveryService)
+	for _, svc := range svcList {
+		group := config.NormalizeProjectName(svc.Project)
+		if group == "" {
+			group = svc.Source
+		}
+		name := svc.DisplayName
+		if name == "" {
+			name = svc.Name
+		}
+		url := services.PrimaryURL(svc)
+		byGroup[group] = append(byGroup[group], DiscoveryService{
+			Name:        name,
+			Service:     svc.Name,
+			Host:        svc.Host,
+			Source:      svc.Source,
+			State:       svc.State,
+			Description: svc.Description,
+			URL:         url,
+			Icon:        svc.Icon,
+			HealthURL:   url,
+		})
+	}
+
+	doc := DiscoveryDocument{Version: discoveryVersion, Groups: make([]DiscoveryGroup, 0, len(byGroup))}
+	for name, svcs := range byGroup {
+		sort.Slice(svcs, func(i, j int) bool {
+			if svcs[i].Name != svcs[j].Name {
+				return svcs[i].Name < svcs[j].Name
+			}
+			return svcs[i].Host < svcs[j].Host
+		})
+		doc.Groups = append(doc.Groups, DiscoveryGroup{Name: name, Services: svcs})
+	}
+	sort.Slice(doc.Groups, func(i, j int) bool { return doc.Groups[i].Name < doc.Groups[j].Name })
+	return doc
+}
+
+// homepageService is a service entry in Homepage's services.yaml.
+type homepageService struct {
+	Href        string `yaml:"href,omitempty"`
+	Description string `yaml:"description,omitempty"`
+	Icon        string `yaml:"icon,omitempty"`
+	SiteMonitor string `yaml:"siteMonitor,omitempty"`
+}
+
+// homepageServices converts a discovery document to Homepage's services.yaml
+// structure: a list of single-key maps of group name to a list of single-key
+// maps of service name to its settings. Names shared by services on
+// different hosts within a group get the host appended, since Homepage keys
+// services by name.
+func homepageServices(doc DiscoveryDocument) []map[string][]map[string]homepageService {
+	groups := make([]map[string][]map[string]homepageService, 0, len(doc.Groups))
+	for _, group := range doc.Groups {
+		counts := make(map[string]int, len(group.Services))
+		for _, svc := range group.Services {
+			counts[svc.Name]++
+		}
+
+		entries := make([]map[string]homepageService, 0, len(group.Services))
+		for _, svc := range group.Services {
+			name := svc.Name
+			if counts[name] > 1 {
+				name = fmt.Sprintf("%s (%s)", name, svc.Host)
+			}
+			entries = append(entries, map[string]homepageService{name: {
+				Href:        svc.URL,
+				Description: svc.Description,
+				Icon:        svc.Icon,
+				SiteMonitor: svc.HealthURL,
+			}})
+		}
+		groups = append(groups, map[string][]map[string]homepageService{group.Name: entries})
+	}
+	return groups
+}
+
+// DiscoveryHandler handles GET /api/discovery requests.
+// Returns the services the user may see, grouped for other dashboards such
+// as Homepage or Glance. Hidden and orphaned services are left out. With
+// format=homepage the document is YAML in the structure of Homepage's
+// services.yaml.
+func DiscoveryHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := configSource()
+	if cfg == nil {
+		http.Error(w, "Configuration not loaded", http.StatusInternalServerError)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "homepage" {
+		http.Error(w, "Invalid format: use json or homepage", http.StatusBadRequest)
+		return
+	}
+
+	svcList, err := getAllServices(r.Context(), cfg)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error getting services: %v", err), http.StatusInternalServerError)
+		return
+	}
+	svcList = withoutHidden(filterServicesForUser(svcList, auth.GetUserFromContext(r.Context())))
+	doc := buildDiscovery(svcList)
+
+	if format == "homepage" {
+		out, err := yaml.Marshal(homepageServices(doc))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error encoding services: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+		w.Write(out)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(doc)
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/config"
+	"home_server_dashboard/services"
+)
+
+func discoveryServices() []services.ServiceInfo {
+	return []services.ServiceInfo{
+		{Name: "sonarr", Project: "media", Source: "docker", Host: "nas", State: services.StateRunning, Description: "TV shows", Icon: "sonarr.png",
+			TraefikURLs: []string{"https://sonarr.example.com"}, Ports: []services.PortInfo{{HostPort: 8989, Protocol: "tcp", URL: "http://10.0.0.2:8989"}}},
+		// Grouped with sonarr: compose would name the project "media"
+		{Name: "jellyfin", DisplayName: "Jellyfin", Project: "Media", Source: "docker", Host: "nas", State: services.StateStopped,
+			Ports: []services.PortInfo{{HostPort: 8096, Protocol: "tcp", URL: "http://10.0.0.2:8096"}}},
+		{Name: "nginx.service", Project: "systemd", Source: "systemd", Host: "nas", State: services.StateRunning},
+		{Name: "nginx.service", Project: "systemd", Source: "systemd", Host: "pi", State: services.StateRunning},
+		{Name: "core", Source: "homeassistant", Host: "ha", State: services.StateRunning},
+	}
+}
+
+func TestBuildDiscovery(t *testing.T) {
+	doc := buildDiscovery(discoveryServices())
+
+	if doc.Version != 1 {
+		t.Errorf("version = %d, want 1", doc.Version)
+	}
+	var groups []string
+	for _, group := range doc.Groups {
+		groups = append(groups, group.Name)
+	}
+	if want := []string{"homeassistant", "media", "systemd"}; !reflect.DeepEqual(groups, want) {
+		t.Fatalf("groups = %v, want %v", groups, want)
+	}
+
+	media := doc.Groups[1].Services
+	want := []DiscoveryService{
+		{Name: "Jellyfin", Service: "jellyfin", Host: "nas", Source: "docker", State: services.StateStopped, URL: "http://10.0.0.2:8096", HealthURL: "http://10.0.0.2:8096"},
+		{Name: "sonarr", Service: "sonarr", Host: "nas", Source: "docker", State: services.StateRunning, Description: "TV shows", URL: "https://sonarr.example.com", Icon: "sonarr.png", HealthURL: "https://sonarr.example.com"},
+	}
+	if !reflect.DeepEqual(media, want) {
+		t.Errorf("media = %+v, want %+v", media, want)
+	}
+}
+
+func TestHomepageServices(t *testing.T) {
+	out, err := yaml.Marshal(homepageServices(buildDiscovery(discoveryServices())))
+	if err != nil {
+		t.Fatalf("yaml.Marshal() = %v", err)
+	}
+
+	want := `- homeassistant:
+    - core: {}
+- media:
+    - Jellyfin:
+        href: http://10.0.0.2:8096
+        siteMonitor: http://10.0.0.2:8096
+    - sonarr:
+        href: https://sonarr.example.com
+        description: TV shows
+        icon: sonarr.png
+        siteMonitor: https://sonarr.example.com
+- systemd:
+    - nginx.service (nas): {}
+    - nginx.service (pi): {}
+`
+	if string(out) != want {
+		t.Errorf("homepage services =\n%s\nwant\n%s", out, want)
+	}
+}
+
+// TestDiscoveryHandler tests both formats and that hidden and inaccessible services are left out.
+func TestDiscoveryHandler(t *testing.T) {
+	cleanup := setupTestConfig(t, `{"hosts": [{"name": "fakehost", "address": "192.168.1.50"}]}`)
+	defer cleanup()
+
+	services.Register(services.Registration{
+		Source: "fake",
+		Order:  100,
+		Configured: func(host *config.HostConfig) bool {
+			return host.Name == "fakehost"
+		},
+		Factory: func(cfg *config.Config, host *config.HostConfig) (services.Provider, error) {
+			return &fakeProvider{host: host.Name, svcs: []services.ServiceInfo{
+				{Name: "widget", Project: "tools", Source: "fake", Host: host.Name, State: "running", TraefikURLs: []string{"https://widget.example.com"}},
+				{Name: "gadget", Project: "tools", Source: "fake", Host: host.Name, State: "running"},
+				{Name: "secret", Project: "tools", Source: "fake", Host: host.Name, State: "running", Hidden: true},
+				{Name: "leftover", Project: "old", Source: "fake", Host: host.Name, State: "stopped", Orphaned: true},
+			}}, nil
+		},
+	})
+	t.Cleanup(func() { services.Unregister("fake") })
+
+	// The user may see widget only; admins don't see hidden services here either
+	user := auth.User{AllowedServices: map[string][]string{"fakehost": {"widget", "secret", "leftover"}}}
+
+	request := func(query string) *httptest.ResponseRecorder {
+		// The context is canceled so Docker is not contacted; the fake ignores it
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		ctx = context.WithValue(ctx, authUserContextKey, &user)
+		w := httptest.NewRecorder()
+		DiscoveryHandler(w, httptest.NewRequest(http.MethodGet, "/api/discovery"+query, nil).WithContext(ctx))
+		return w
+	}
+
+	w := request("")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var doc DiscoveryDocument
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("failed to decode document: %v", err)
+	}
+	if len(doc.Groups) != 1 || doc.Groups[0].Name != "tools" || len(doc.Groups[0].Services) != 1 || doc.Groups[0].Services[0].URL != "https://widget.example.com" {
+		t.Errorf("document = %+v, want only widget in tools", doc)
+	}
+
+	w = request("?format=homepage")
+	if w.Code != http.StatusOK {
+		t.Fatalf("homepage status = %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/yaml") {
+		t.Errorf("Content-Type = %q, want application/yaml", got)
+	}
+	var homepage []map[string][]map[string]map[string]string
+	if err := yaml.Unmarshal(w.Body.Bytes(), &homepage); err != nil {
+		t.Fatalf("failed to parse YAML: %v\n%s", err, w.Body.String())
+	}
+	if len(homepage) != 1 || homepage[0]["tools"][0]["widget"]["href"] != "https://widget.example.com" {
+		t.Errorf("homepage services = %v", homepage)
+	}
+
+	if w := request("?format=xml"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid format status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// docAliases maps short slugs kept for existing links to the doc they name.
+var docAliases = map[string]string{
+	"bangandpipe": "bangandpipe-query-language",
+}
+
+// renderedDoc is a markdown doc rendered to HTML.
+type renderedDoc struct {
+	version string // Modification time and size of the file it was rendered from
+	title   string
+	html    []byte
+	etag    string
+	modTime time.Time // Zero for embedded docs
+}
+
+// docLibrary renders the markdown files of a docs directory. Only files found
+// by scanning the directory can be requested, and each is rendered once per
+// version of the file.
+type docLibrary struct {
+	fsys fs.FS
+	// rescan looks the directory up again for unknown slugs. Only the
+	// development fallback sets it, so docs added while running show up.
+	rescan bool
+
+	mu      sync.Mutex
+	slugs   map[string]string // slug -> file name
+	cache   map[string]*renderedDoc
+	renders int // Number of times a doc was rendered, for tests
+}
+
+// newDocLibrary scans fsys for markdown files.
+func newDocLibrary(fsys fs.FS, rescan bool) *docLibrary {
+	l := &docLibrary{fsys: fsys, rescan: rescan, cache: make(map[string]*renderedDoc)}
+	l.slugs = scanDocs(fsys)
+	return l
+}
+
+// scanDocs returns the markdown files at the top of fsys by slug, the file
+// name without its extension.
+func scanDocs(fsys fs.FS) map[string]string {
+	slugs := make(map[string]string)
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return slugs
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || path.Ext(name) != ".md" {
+			continue
+		}
+		slugs[strings.TrimSuffix(name, ".md")] = name
+	}
+	return slugs
+}
+
+// file returns the file name of a slug, or false if the slug isn't a doc.
+func (l *docLibrary) file(slug string) (string, bool) {
+	if target, ok := docAliases[slug]; ok {
+		slug = target
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	name, ok := l.slugs[slug]
+	if !ok && l.rescan {
+		l.slugs = scanDocs(l.fsys)
+		name, ok = l.slugs[slug]
+	}
+	return name, ok
+}
+
+// get returns the rendered doc for slug, rendering it again only if the file
+// changed since it was last rendered.
+func (l *docLibrary) get(slug string) (*renderedDoc, bool) {
+	name, ok := l.file(slug)
+	if !ok {
+		return nil, false
+	}
+	info, err := fs.Stat(l.fsys, name)
+	if err != nil {
+		return nil, false
+	}
+	version := info.ModTime().Format(time.RFC3339Nano) + "/" + strconv.FormatInt(info.Size(), 10)
+
+	l.mu.Lock()
+	cached := l.cache[name]
+	l.mu.Unlock()
+	if cached != nil && cached.version == version {
+		return cached, true
+	}
+
+	content, err := fs.ReadFile(l.fsys, name)
+	if err != nil {
+		return nil, false
+	}
+	sum := sha256.Sum256(content)
+	doc := &renderedDoc{
+		version: version,
+		title:   docTitle(content, strings.TrimSuffix(name, ".md")),
+		html:    renderMarkdown(content),
+		etag:    `"` + hex.EncodeToString(sum[:8]) + `"`,
+		modTime: info.ModTime(),
+	}
+
+	l.mu.Lock()
+	l.cache[name] = doc
+	l.renders++
+	l.mu.Unlock()
+	return doc, true
+}
+
+// docTitle returns the text of the first markdown heading in content, or
+// fallback if there is none.
+func docTitle(content []byte, fallback string) string {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		rest := strings.TrimLeft(line, "#")
+		if rest == line || !strings.HasPrefix(rest, " ") {
+			continue
+		}
+		// ATX headings may be closed with trailing hashes
+		if title := strings.TrimSpace(strings.TrimRight(rest, "#")); title != "" {
+			return title
+		}
+	}
+	return fallback
+}
+
+// renderMarkdown renders markdown to HTML the way the dashboard's help shows
+// it, sanitized so raw HTML in the markdown can't run script.
+func renderMarkdown(content []byte) []byte {
+	extensions := parser.CommonExtensions | parser.AutoHeadingIDs | parser.NoEmptyLineBeforeBlock
+	p := parser.NewWithExtensions(extensions)
+	doc := p.Parse(content)
+
+	htmlFlags := html.CommonFlags | html.HrefTargetBlank
+	renderer := html.NewRenderer(html.RendererOptions{Flags: htmlFlags})
+	return sanitizeHTML(markdown.Render(doc, renderer))
+}
+
+// DocEntry describes an available doc in the docs index.
+type DocEntry struct {
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// index lists the docs, sorted by slug.
+func (l *docLibrary) index() []DocEntry {
+	l.mu.Lock()
+	if l.rescan {
+		l.slugs = scanDocs(l.fsys)
+	}
+	slugs := make([]string, 0, len(l.slugs))
+	for slug := range l.slugs {
+		slugs = append(slugs, slug)
+	}
+	l.mu.Unlock()
+	sort.Strings(slugs)
+
+	entries := make([]DocEntry, 0, len(slugs))
+	for _, slug := range slugs {
+		doc, ok := l.get(slug)
+		if !ok {
+			continue
+		}
+		entries = append(entries, DocEntry{Slug: slug, Title: doc.title, URL: "/api/docs/" + slug})
+	}
+	return entries
+}
+
+var (
+	docsMu      sync.Mutex
+	docsLibrary *docLibrary
+)
+
+// setDocsFS scans the embedded docs, replacing the development fallback.
+func setDocsFS(fsys fs.FS) {
+	docsMu.Lock()
+	defer docsMu.Unlock()
+	if fsys == nil {
+		docsLibrary = nil
+		return
+	}
+	docsLibrary = newDocLibrary(fsys, false)
+}
+
+// currentDocs returns the embedded docs, or the docs directory on disk when
+// running without them during development.
+func currentDocs() *docLibrary {
+	docsMu.Lock()
+	defer docsMu.Unlock()
+	if docsLibrary == nil {
+		docsLibrary = newDocLibrary(os.DirFS("docs"), true)
+	}
+	return docsLibrary
+}
+
+// serveDoc writes a rendered doc. Browsers revalidate it with the ETag (and
+// Last-Modified when the file has a modification time) and get a 304 if it
+// hasn't changed.
+func serveDoc(w http.ResponseWriter, r *http.Request, slug string) {
+	doc, ok := currentDocs().get(slug)
+	if !ok {
+		http.Error(w, "Documentation not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", doc.etag)
+	http.ServeContent(w, r, slug+".html", doc.modTime, bytes.NewReader(doc.html))
+}
+
+// DocsHandler handles GET /api/docs/{slug} requests.
+// It renders a markdown file from the docs directory as HTML. Only docs found
+// when the directory was scanned can be requested.
+func DocsHandler(w http.ResponseWriter, r *http.Request) {
+	serveDoc(w, r, r.PathValue("slug"))
+}
+
+// DocsIndexHandler handles GET /api/docs requests.
+// It lists the available docs with the title of each.
+func DocsIndexHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(currentDocs().index())
+}
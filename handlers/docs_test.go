@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// useDocs serves docs from fsys for the rest of the test.
+func useDocs(t *testing.T, fsys fstest.MapFS) *docLibrary {
+	t.Helper()
+	setDocsFS(fsys)
+	t.Cleanup(func() { setDocsFS(nil) })
+	return currentDocs()
+}
+
+func testDocsFS() fstest.MapFS {
+	modTime := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	return fstest.MapFS{
+		"bangandpipe-query-language.md": {Data: []byte("# BangAndPipe Query Language\n\nUse `!` to negate."), ModTime: modTime},
+		"discovery.md":                  {Data: []byte("Intro without a heading.\n\n## How Services Are Found ##\n"), ModTime: modTime},
+		"notes.txt":                     {Data: []byte("not markdown")},
+		"images/screenshot.md":          {Data: []byte("# Nested")},
+	}
+}
+
+func getDoc(slug string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/docs/"+slug, nil)
+	req.SetPathValue("slug", slug)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	DocsHandler(w, req)
+	return w
+}
+
+// TestDocsHandler_Whitelist tests that only scanned markdown files can be requested.
+func TestDocsHandler_Whitelist(t *testing.T) {
+	useDocs(t, testDocsFS())
+
+	for _, slug := range []string{"discovery", "bangandpipe", "bangandpipe-query-language"} {
+		if w := getDoc(slug, nil); w.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want %d", slug, w.Code, http.StatusOK)
+		}
+	}
+	for _, slug := range []string{"../../etc/passwd", "../docs/discovery", "notes", "notes.txt", "discovery.md", "images/screenshot", "", "missing"} {
+		if w := getDoc(slug, nil); w.Code != http.StatusNotFound {
+			t.Errorf("%q: status = %d, want %d", slug, w.Code, http.StatusNotFound)
+		}
+	}
+}
+
+// TestDocsHandler_CacheHit tests that docs are rendered once per file version.
+func TestDocsHandler_CacheHit(t *testing.T) {
+	fsys := testDocsFS()
+	library := useDocs(t, fsys)
+
+	first := getDoc("discovery", nil)
+	second := getDoc("discovery", nil)
+	if library.renders != 1 {
+		t.Errorf("renders = %d after two requests, want 1", library.renders)
+	}
+	if first.Body.String() != second.Body.String() || !strings.Contains(first.Body.String(), "<h2") {
+		t.Errorf("cached body differs or isn't HTML: %q", second.Body.String())
+	}
+
+	// Editing the file renders it again
+	fsys["discovery.md"] = &fstest.MapFile{Data: []byte("# Updated"), ModTime: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)}
+	third := getDoc("discovery", nil)
+	if library.renders != 2 {
+		t.Errorf("renders = %d after the file changed, want 2", library.renders)
+	}
+	if !strings.Contains(third.Body.String(), "Updated") {
+		t.Errorf("body after change = %q", third.Body.String())
+	}
+	if third.Header().Get("ETag") == first.Header().Get("ETag") {
+		t.Error("ETag did not change with the content")
+	}
+}
+
+// TestDocsHandler_NotModified tests conditional requests.
+func TestDocsHandler_NotModified(t *testing.T) {
+	useDocs(t, testDocsFS())
+
+	w := getDoc("discovery", nil)
+	etag := w.Header().Get("ETag")
+	if etag == "" || w.Header().Get("Last-Modified") == "" {
+		t.Fatalf("missing cache headers: %v", w.Header())
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+		t.Errorf("Content-Type = %q, want text/html", got)
+	}
+
+	w = getDoc("discovery", http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("status with matching ETag = %d, want %d", w.Code, http.StatusNotModified)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("304 response has a body: %q", w.Body.String())
+	}
+
+	w = getDoc("discovery", http.Header{"If-None-Match": {`"stale"`}})
+	if w.Code != http.StatusOK {
+		t.Errorf("status with stale ETag = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+// TestDocsIndexHandler tests the index of docs and their titles.
+func TestDocsIndexHandler(t *testing.T) {
+	useDocs(t, testDocsFS())
+
+	w := httptest.NewRecorder()
+	DocsIndexHandler(w, httptest.NewRequest(http.MethodGet, "/api/docs", nil))
+
+	var entries []DocEntry
+	if err := json.NewDecoder(w.Body).Decode(&entries); err != nil {
+		t.Fatalf("failed to decode index: %v", err)
+	}
+	want := []DocEntry{
+		{Slug: "bangandpipe-query-language", Title: "BangAndPipe Query Language", URL: "/api/docs/bangandpipe-query-language"},
+		{Slug: "discovery", Title: "How Services Are Found", URL: "/api/docs/discovery"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("index = %+v, want %+v", entries, want)
+	}
+}
+
+func TestDocTitle(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{"# Title\n\nBody", "Title"},
+		{"Intro\n\n### Third Level ###\n# Later", "Third Level"},
+		{"#hashtag\n## Real", "Real"},
+		{"No headings here", "fallback"},
+		{"#\n", "fallback"},
+	}
+	for _, tt := range tests {
+		if got := docTitle([]byte(tt.content), "fallback"); got != tt.expected {
+			t.Errorf("docTitle(%q) = %q, want %q", tt.content, got, tt.expected)
+		}
+	}
+}
@@ -3,154 +3,420 @@ package handlers
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/gomarkdown/markdown"
-	"github.com/gomarkdown/markdown/html"
-	"github.com/gomarkdown/markdown/parser"
-
+	"home_server_dashboard/actionhistory"
 	"home_server_dashboard/auth"
 	"home_server_dashboard/config"
+	"home_server_dashboard/httpclient"
+	"home_server_dashboard/locks"
 	"home_server_dashboard/query"
+	"home_server_dashboard/realip"
+	"home_server_dashboard/redact"
+	"home_server_dashboard/resilience"
+	"home_server_dashboard/selfdetect"
+	"home_server_dashboard/selftest"
 	"home_server_dashboard/services"
 	"home_server_dashboard/services/docker"
 	"home_server_dashboard/services/homeassistant"
 	"home_server_dashboard/services/systemd"
 	"home_server_dashboard/services/traefik"
+	"home_server_dashboard/streams"
+	"home_server_dashboard/usagestats"
+	"home_server_dashboard/version"
 )
 
-// Embedded filesystems (set by server package)
-var (
-	embeddedStaticFS fs.FS
-	embeddedDocsFS   fs.FS
-)
+// Embedded static filesystem (set by server package)
+var embeddedStaticFS fs.FS
 
 // SetEmbeddedFS sets the embedded filesystems for serving static content.
+// The docs are scanned here, so only docs present at startup can be served.
 func SetEmbeddedFS(staticFS, docsFS fs.FS) {
 	embeddedStaticFS = staticFS
-	embeddedDocsFS = docsFS
+	setDocsFS(docsFS)
+}
+
+// StateTracker reports when services last changed state. It is implemented by
+// the monitor, which sees every transition between page loads.
+type StateTracker interface {
+	LastStateChange(host, serviceName string) (time.Time, bool)
+}
+
+// ExitTracker reports the last exit of Docker containers. It is implemented
+// by the monitor, which sees the exit code in a container's die event before
+// the next listing inspects it.
+type ExitTracker interface {
+	LastExit(host, serviceName string) (code int, at time.Time, ok bool)
+}
+
+// StatsTracker reports recent CPU and memory samples of Docker containers.
+// It is implemented by the monitor when stats sampling is enabled.
+type StatsTracker interface {
+	// Stats returns the samples of a service, oldest first, or false if
+	// stats aren't sampled on its host.
+	Stats(host, serviceName string) ([]services.StatsSample, bool)
+}
+
+// ProbeReporter reports the last results of custom health probes. It is
+// implemented by the monitor, which runs them.
+type ProbeReporter interface {
+	ProbeResult(key services.Key) (services.ProbeResult, bool)
+}
+
+// IntentRecorder is told about stops and restarts before they run, so the
+// transitions they cause aren't notified as failures. It is implemented by
+// the monitor.
+type IntentRecorder interface {
+	ExpectAction(host, serviceName, action, user string)
+	CancelExpectedAction(host, serviceName string)
+}
+
+// stateTracker fills in LastStateChange for providers that don't report it (set by server package)
+var stateTracker StateTracker
+
+// SetStateTracker sets the tracker used to fill in service state-change times.
+func SetStateTracker(t StateTracker) {
+	stateTracker = t
+}
+
+// actionHistory captures the output of every service action (replaced by the server package)
+var actionHistory = actionhistory.NewStore(actionhistory.DefaultPerService, actionhistory.DefaultMaxOutput)
+
+// SetActionHistory sets the store that service action output is recorded in.
+func SetActionHistory(store *actionhistory.Store) {
+	if store != nil {
+		actionHistory = store
+	}
+}
+
+// streamRegistry tracks the open SSE streams (replaced by the server package)
+var streamRegistry = streams.New(streams.DefaultPerUser, streams.DefaultGlobal)
+
+// SetStreamRegistry sets the registry that SSE streams are tracked in.
+func SetStreamRegistry(reg *streams.Registry) {
+	if reg != nil {
+		streamRegistry = reg
+	}
+}
+
+// usageStats counts log streams and actions per service (replaced by the server package)
+var usageStats = usagestats.NewStore(usagestats.DefaultRetentionDays)
+
+// SetUsageStats sets the store that service usage is counted in.
+func SetUsageStats(store *usagestats.Store) {
+	if store != nil {
+		usageStats = store
+	}
+}
+
+// configSource returns the config handlers work from (replaced by the server package)
+var configSource = config.Get
+
+// SetConfigSource sets the function that returns the current config. Nil
+// restores config.Get.
+func SetConfigSource(get func() *config.Config) {
+	if get == nil {
+		get = config.Get
+	}
+	configSource = get
+}
+
+// SourceRegistry finds the registered service sources and their providers.
+type SourceRegistry interface {
+	Lookup(source string) (services.Registration, bool)
+	Registered() []services.Registration
+}
+
+// globalSources is the registry that provider packages add themselves to
+// with services.Register.
+type globalSources struct{}
+
+func (globalSources) Lookup(source string) (services.Registration, bool) {
+	return services.Lookup(source)
+}
+
+func (globalSources) Registered() []services.Registration {
+	return services.Registered()
+}
+
+// sourceRegistry is where handlers look up service sources (replaced by the server package)
+var sourceRegistry SourceRegistry = globalSources{}
+
+// SetSourceRegistry sets the registry that service sources are looked up
+// in. Nil restores the services package's registry.
+func SetSourceRegistry(reg SourceRegistry) {
+	if reg == nil {
+		reg = globalSources{}
+	}
+	sourceRegistry = reg
+}
+
+// mergeStateChanges sets LastStateChange from tracker on services whose
+// provider did not report one, and the exit code of stopped Docker containers
+// if the tracker is also an ExitTracker. Provider values take precedence.
+// Docker services whose stats the tracker samples are marked StatsSampled.
+// If the tracker is a ProbeReporter, services get their probe result, and
+// running ones whose probe fails are unhealthy.
+func mergeStateChanges(svcList []services.ServiceInfo, tracker StateTracker) {
+	if tracker == nil {
+		return
+	}
+	exits, _ := tracker.(ExitTracker)
+	stats, _ := tracker.(StatsTracker)
+	probes, _ := tracker.(ProbeReporter)
+	for i := range svcList {
+		svc := &svcList[i]
+		if probes != nil {
+			// Probes tell containers apart by compose project; systemd
+			// units are unique on their host
+			key := services.KeyOf(*svc)
+			if svc.Source != "docker" {
+				key.Project = ""
+			}
+			if result, ok := probes.ProbeResult(key); ok {
+				svc.Probe = &result
+				if result.Status == services.ProbeFailing && svc.State == services.StateRunning {
+					svc.State = services.StateUnhealthy
+				}
+			}
+		}
+		if stats != nil && svc.Source == "docker" {
+			if samples, ok := stats.Stats(svc.Host, svc.Name); ok && len(samples) > 0 {
+				svc.StatsSampled = true
+			}
+		}
+		if exits != nil && svc.Source == "docker" && svc.State == services.StateStopped && svc.ExitCode == nil {
+			if code, at, ok := exits.LastExit(svc.Host, svc.Name); ok {
+				svc.ExitCode = &code
+				if svc.FinishedAt == nil {
+					svc.FinishedAt = &at
+				}
+			}
+		}
+		if svc.LastStateChange != nil {
+			continue
+		}
+		if t, ok := tracker.LastStateChange(svc.Host, svc.Name); ok {
+			svc.LastStateChange = &t
+		}
+	}
+}
+
+// callProvider runs fn with a deadline of timeout derived from ctx.
+// Failures, including an expired deadline, are returned as a *services.ProviderError
+// so the caller can treat the provider as unavailable instead of failing the request.
+func callProvider(ctx context.Context, timeout time.Duration, provider, host string, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := fn(ctx)
+	if ctx.Err() == context.DeadlineExceeded {
+		return services.NewProviderError(provider, host, ctx.Err())
+	}
+	if err != nil {
+		return services.NewProviderError(provider, host, err)
+	}
+	return nil
+}
+
+// callRemoteProvider is callProvider for idempotent reads against a remote host.
+// The call goes through the host's circuit breaker and transient failures are
+// retried within the same deadline. Local hosts are called directly.
+func callRemoteProvider(ctx context.Context, timeout time.Duration, provider string, host *config.HostConfig, fn func(ctx context.Context) error) error {
+	if host.IsLocal() {
+		return callProvider(ctx, timeout, provider, host.Name, fn)
+	}
+	return callProvider(ctx, timeout, provider, host.Name, func(ctx context.Context) error {
+		return resilience.Do(ctx, host.Name, fn)
+	})
 }
 
 // getAllServices collects services from all configured providers.
+// Each provider call is bounded by the configured services timeout so a hung
+// host only drops its own services from the result. If ctx carries a
+// collectionTiming, each source on each host and each later phase is timed;
+// every collection's phases, and its whole length as "collection", go into
+// the histograms of /api/metrics. If ctx carries the startup warm-up, the
+// services of each host are handed to it as they come in.
 func getAllServices(ctx context.Context, cfg *config.Config) ([]services.ServiceInfo, error) {
+	collectionStart := time.Now()
 	var allServices []services.ServiceInfo
-	var allPortRemaps []docker.PortRemap
+	var allPortRemaps []services.PortRemap
+	timeout := cfg.GetServicesTimeout()
+	timing := timingFrom(ctx)
+	startup := warmupFrom(ctx)
 
-	// Build a map of host names to their private IPs for quick lookup
-	hostIPMap := make(map[string]string)
-	for _, host := range cfg.Hosts {
-		hostIPMap[host.Name] = host.GetPrivateIP()
-	}
+	// Host names to their link addresses (private IP or hostname), computed at config load
+	hostIPMap := cfg.LinkHosts()
 
-	// Get Docker services from localhost
+	// Collect services from every registered source, on each host it is configured for
 	localHostName := cfg.GetLocalHostName()
-	dockerProvider, err := docker.NewProvider(localHostName)
-	if err != nil {
-		log.Printf("Warning: failed to create Docker provider: %v", err)
-	} else {
-		defer dockerProvider.Close()
-		dockerServices, portRemaps := dockerProvider.GetServicesWithRemaps(ctx)
-		// Set HostIP for each Docker service
-		for i := range dockerServices {
-			dockerServices[i].HostIP = hostIPMap[dockerServices[i].Host]
+	for _, reg := range sourceRegistry.Registered() {
+		if reg.Factory == nil {
+			continue
+		}
+		hosts := make([]*config.HostConfig, 0, len(cfg.Hosts))
+		if reg.LocalOnly {
+			hosts = append(hosts, hostOrLocal(cfg, localHostName))
+		} else {
+			for i := range cfg.Hosts {
+				hosts = append(hosts, &cfg.Hosts[i])
+			}
+		}
+
+		for _, host := range hosts {
+			if !reg.IsConfigured(host) {
+				continue
+			}
+			start := time.Now()
+			svcs, remaps, err := collectServices(ctx, cfg, reg, host, timeout)
+			timing.observe(reg.Source, host.Name, start)
+			reportCollection(ctx, reg.Source, host.Name, err)
+			if err != nil {
+				log.Printf("Warning: %v", err)
+				continue
+			}
+			// Set HostIP for each service
+			for i := range svcs {
+				svcs[i].HostIP = hostIPMap[svcs[i].Host]
+				redactFailure(svcs[i].Failure, cfg.LogRedactor())
+			}
+			allServices = append(allServices, svcs...)
+			allPortRemaps = append(allPortRemaps, remaps...)
+			startup.add(svcs)
 		}
-		allServices = append(allServices, dockerServices...)
-		allPortRemaps = append(allPortRemaps, portRemaps...)
 	}
 
-	// Get systemd services from each configured host
+	// Apply port remapping (move ports from source services to target services)
+	start := time.Now()
+	allServices = applyPortRemaps(allServices, allPortRemaps, cfg.Debug)
+	timing.observe("remap", "", start)
+
+	// Enrich services with Traefik hostnames
+	start = time.Now()
+	allServices = enrichWithTraefikURLs(ctx, cfg, allServices)
+	timing.observe("traefik-urls", "", start)
+
+	// Get Traefik-only services (services registered in Traefik but not in Docker/systemd)
+	// from each host with Traefik enabled
+	var existingServices map[string]bool
 	for _, host := range cfg.Hosts {
-		if len(host.SystemdServices) == 0 {
+		if !host.HasTraefik() {
 			continue
 		}
-
-		// Convert config entries to systemd entries
-		configEntries := host.GetSystemdServiceEntries()
-		systemdEntries := make([]systemd.ServiceEntry, 0, len(configEntries))
-		for _, entry := range configEntries {
-			systemdEntries = append(systemdEntries, systemd.ServiceEntry{
-				Name:     entry.Name,
-				User:     entry.User,
-				ReadOnly: entry.ReadOnly,
-				Ports:    entry.Ports,
-			})
+		if existingServices == nil {
+			existingServices = traefikKnownNames(allServices)
 		}
 
 		// Convert SSH config if present
-		var sshConfig *systemd.SSHConfig
+		var traefikSSHConfig *traefik.SSHConfig
 		if host.SSHConfig != nil {
-			sshConfig = &systemd.SSHConfig{
+			traefikSSHConfig = &traefik.SSHConfig{
 				Username: host.SSHConfig.Username,
 				Port:     host.SSHConfig.Port,
 			}
 		}
 
-		systemdProvider := systemd.NewProviderWithEntries(host.Name, host.Address, systemdEntries, sshConfig)
-		systemdServices, err := systemdProvider.GetServices(ctx)
+		traefikProvider := traefik.NewProvider(host.Name, host.Address, host.Traefik.APIPort, traefikSSHConfig, httpclient.New(cfg.OutboundFor(&host), 10*time.Second))
+		defer traefikProvider.Close()
+
+		var traefikServices []services.ServiceInfo
+		start := time.Now()
+		err := callRemoteProvider(ctx, timeout, "traefik", &host, func(ctx context.Context) error {
+			var err error
+			traefikServices, err = traefikProvider.GetServices(ctx, existingServices)
+			return err
+		})
+		timing.observe("traefik", host.Name, start)
+		reportCollection(ctx, "traefik", host.Name, err)
 		if err != nil {
-			log.Printf("Warning: failed to get systemd services from %s: %v", host.Name, err)
+			log.Printf("Warning: %v", err)
 			continue
 		}
-		// Set HostIP for each systemd service
-		for i := range systemdServices {
-			systemdServices[i].HostIP = hostIPMap[systemdServices[i].Host]
-		}
-		allServices = append(allServices, systemdServices...)
-	}
 
-	// Get Home Assistant services from each configured host
-	for _, host := range cfg.Hosts {
-		if !host.HasHomeAssistant() {
-			continue
+		// Set HostIP for each Traefik service
+		for i := range traefikServices {
+			traefikServices[i].HostIP = hostIPMap[traefikServices[i].Host]
 		}
 
-		haProvider, err := homeassistant.NewProvider(&host)
-		if err != nil {
-			log.Printf("Warning: failed to create Home Assistant provider for %s: %v", host.Name, err)
-			continue
+		allServices = append(allServices, traefikServices...)
+
+		// Add these services to existing set to avoid duplicates from other hosts
+		for _, svc := range traefikServices {
+			existingServices[svc.Name] = true
 		}
-		if haProvider == nil {
-			continue
+	}
+
+	// Build port links on the server so IPv6 addresses are bracketed correctly
+	start = time.Now()
+	for i := range allServices {
+		services.SetPortURLs(&allServices[i])
+		if allServices[i].DisplayName == "" {
+			allServices[i].DisplayName = allServices[i].Name
 		}
-		defer haProvider.Close()
+	}
+	selfdetect.Get().Mark(allServices, localHostName)
+	reportArchMismatches(allServices)
+	withLastActions(allServices, actionHistory)
+	sortServices(allServices)
+	timing.observe("enrich", "", start)
+	phaseHistograms.observe("collection", "", time.Since(collectionStart))
 
-		haServices, err := haProvider.GetServices(ctx)
-		if err != nil {
-			log.Printf("Warning: failed to get Home Assistant services from %s: %v", host.Name, err)
+	return allServices, nil
+}
+
+// externalRestartSlack is how much later than the end of the dashboard's
+// last action a container may have started and still count as started by
+// it, allowing for Docker reporting the start time late.
+const externalRestartSlack = 5 * time.Second
+
+// withLastActions sets each service's LastAction from the action history.
+// Running Docker containers that started after the last action finished are
+// marked ExternallyRestarted: something other than the dashboard (compose,
+// a restart policy, someone on the host) started them.
+func withLastActions(svcs []services.ServiceInfo, history *actionhistory.Store) {
+	for i := range svcs {
+		svc := &svcs[i]
+		rec, ok := history.Last(svc.Host, svc.Name)
+		if !ok {
 			continue
 		}
-		// Set HostIP for each HA service
-		for i := range haServices {
-			haServices[i].HostIP = hostIPMap[haServices[i].Host]
+		svc.LastAction = &services.LastAction{
+			Action: rec.Action,
+			User:   rec.User,
+			Time:   rec.Started,
+			Result: rec.Outcome,
 		}
-		allServices = append(allServices, haServices...)
+		svc.ExternallyRestarted = svc.Source == "docker" && svc.State.Up() &&
+			svc.LastStateChange != nil && !rec.Finished.IsZero() &&
+			svc.LastStateChange.After(rec.Finished.Add(externalRestartSlack))
 	}
+}
 
-	// Apply port remapping (move ports from source services to target services)
-	allServices = applyPortRemaps(allServices, allPortRemaps)
-
-	// Enrich services with Traefik hostnames
-	allServices = enrichWithTraefikURLs(ctx, cfg, allServices)
-
-	// Get Traefik-only services (services registered in Traefik but not in Docker/systemd)
-	// Build a set of existing service names to filter out duplicates
-	// We need to track multiple possible names that Traefik might use:
-	// - The actual service name
-	// - The TraefikServiceName from Docker labels
-	// - Common Traefik naming patterns like servicename-projectname
-	// - Normalized names (underscores to hyphens, as Traefik does)
-	existingServices := make(map[string]bool)
+// traefikKnownNames returns the names Traefik may use for the services
+// already collected, so Traefik-only services can leave them out. Traefik may
+// use the service name, the TraefikServiceName from Docker labels, the
+// servicename-projectname pattern, the container name, or any of these with
+// underscores normalized to hyphens. It is only built when a host has
+// Traefik enabled.
+func traefikKnownNames(allServices []services.ServiceInfo) map[string]bool {
+	existingServices := make(map[string]bool, 2*len(allServices))
 	for _, svc := range allServices {
 		existingServices[svc.Name] = true
 		// Also add normalized name (Traefik converts underscores to hyphens)
@@ -178,58 +444,105 @@ func getAllServices(ctx context.Context, cfg *config.Config) ([]services.Service
 			}
 		}
 	}
+	return existingServices
+}
 
-	// Get Traefik services from each host with Traefik enabled
-	for _, host := range cfg.Hosts {
-		if !host.Traefik.Enabled {
-			continue
-		}
+// collectServices lists the services of a registered source on host, along
+// with any port remaps its provider reports.
+func collectServices(ctx context.Context, cfg *config.Config, reg services.Registration, host *config.HostConfig, timeout time.Duration) ([]services.ServiceInfo, []services.PortRemap, error) {
+	provider, closeProvider, err := newProvider(cfg, reg, host)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create %s provider for %s: %w", reg.Source, host.Name, err)
+	}
+	if provider == nil {
+		return nil, nil, nil
+	}
+	defer closeProvider()
 
-		// Convert SSH config if present
-		var traefikSSHConfig *traefik.SSHConfig
-		if host.SSHConfig != nil {
-			traefikSSHConfig = &traefik.SSHConfig{
-				Username: host.SSHConfig.Username,
-				Port:     host.SSHConfig.Port,
-			}
+	var svcs []services.ServiceInfo
+	var remaps []services.PortRemap
+	err = callRemoteProvider(ctx, timeout, reg.Source, host, func(ctx context.Context) error {
+		var err error
+		if remapper, ok := provider.(services.RemapProvider); ok {
+			svcs, remaps, err = remapper.GetServicesWithRemaps(ctx)
+			return err
 		}
+		svcs, err = provider.GetServices(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	hostPlatforms.record(ctx, host.Name, provider, timeout)
+	return svcs, remaps, nil
+}
 
-		traefikProvider := traefik.NewProvider(host.Name, host.Address, host.Traefik.APIPort, traefikSSHConfig)
-		defer traefikProvider.Close()
-
-		traefikServices, err := traefikProvider.GetServices(ctx, existingServices)
-		if err != nil {
-			log.Printf("Warning: failed to get Traefik services from %s: %v", host.Name, err)
-			continue
-		}
+// newProvider creates the provider of a registered source for host. The
+// provider is nil if the source has no provider for that host. The returned
+// function releases the provider and must be called once it is no longer used.
+func newProvider(cfg *config.Config, reg services.Registration, host *config.HostConfig) (services.Provider, func(), error) {
+	noop := func() {}
+	if reg.Factory == nil {
+		return nil, noop, nil
+	}
+	provider, err := reg.Factory(cfg, host)
+	if err != nil || provider == nil {
+		return nil, noop, err
+	}
+	if closer, ok := provider.(io.Closer); ok && reg.NeedsClose {
+		return provider, func() { closer.Close() }, nil
+	}
+	return provider, noop, nil
+}
 
-		// Set HostIP for each Traefik service
-		for i := range traefikServices {
-			traefikServices[i].HostIP = hostIPMap[traefikServices[i].Host]
-		}
+// journalProvider is implemented by providers whose logs take LogOptions,
+// such as a boot and priority filter or leaving out timestamps.
+type journalProvider interface {
+	GetLogsWithOptions(ctx context.Context, unitName string, opts systemd.LogOptions) (io.ReadCloser, error)
+}
 
-		allServices = append(allServices, traefikServices...)
+// logProvider creates the provider of source for host, for reading logs. It
+// returns an error if the source is unknown, has no logs, or has no provider
+// for host; the returned function must be called once the provider is no longer used.
+func logProvider(cfg *config.Config, source string, host *config.HostConfig) (services.Provider, func(), error) {
+	reg, ok := sourceRegistry.Lookup(source)
+	if !ok || !reg.Logs {
+		return nil, func() {}, fmt.Errorf("logs are not supported for %s services", source)
+	}
+	provider, closeProvider, err := newProvider(cfg, reg, host)
+	if err != nil {
+		return nil, closeProvider, err
+	}
+	if provider == nil {
+		return nil, closeProvider, fmt.Errorf("no %s provider for host %s", source, host.Name)
+	}
+	return provider, closeProvider, nil
+}
 
-		// Add these services to existing set to avoid duplicates from other hosts
-		for _, svc := range traefikServices {
-			existingServices[svc.Name] = true
+// hostOrLocal returns the config of the named host, or a local host with that
+// name if it isn't configured. A name from a request must be checked with
+// validateHost first, or an unknown host would be taken for the local one.
+func hostOrLocal(cfg *config.Config, name string) *config.HostConfig {
+	if cfg != nil {
+		if host := cfg.GetHostByName(name); host != nil {
+			return host
 		}
 	}
-
-	return allServices, nil
+	return &config.HostConfig{Name: name, Address: "localhost"}
 }
 
 // applyPortRemaps moves ports from source services to target services based on remap labels.
 // This is used when services run in another container's network namespace (e.g., qbittorrent in gluetun).
 // The remapped ports will have SourceService set on the target to indicate which service exposes the port,
 // and TargetService set on the source to indicate where the port is remapped to.
-func applyPortRemaps(svcList []services.ServiceInfo, remaps []docker.PortRemap) []services.ServiceInfo {
+// Successful remaps are logged only with debug set, since they repeat on every request.
+func applyPortRemaps(svcList []services.ServiceInfo, remaps []services.PortRemap, debug bool) []services.ServiceInfo {
 	if len(remaps) == 0 {
 		return svcList
 	}
 
 	// Build a map of service name to index for quick lookup
-	svcIndex := make(map[string]int)
+	svcIndex := make(map[string]int, len(svcList))
 	for i, svc := range svcList {
 		svcIndex[svc.Name] = i
 	}
@@ -273,7 +586,9 @@ func applyPortRemaps(svcList []services.ServiceInfo, remaps []docker.PortRemap)
 		// Mark source port with TargetService to show where it's remapped to
 		sourceSvc.Ports[portIdx].TargetService = remap.TargetService
 
-		log.Printf("Port %d remapped from %s to %s", remap.Port, remap.SourceService, remap.TargetService)
+		if debug {
+			log.Printf("Debug: port %d remapped from %s to %s", remap.Port, remap.SourceService, remap.TargetService)
+		}
 	}
 
 	return svcList
@@ -286,15 +601,31 @@ func normalizeForTraefik(name string) string {
 }
 
 // enrichWithTraefikURLs adds Traefik-exposed URLs to services.
-// It queries each host's Traefik API for router information and matches
-// services by their name.
+// It queries each host's Traefik API for router information, unless it did
+// within traefikMappingsMaxAge, and matches services by their name.
 func enrichWithTraefikURLs(ctx context.Context, cfg *config.Config, svcList []services.ServiceInfo) []services.ServiceInfo {
 	// Collect service->hostname mappings from all hosts with Traefik enabled
 	// Key: service name, Value: list of hostnames
-	traefikMappings := make(map[string][]string)
+	allMappings := make(map[string][]string)
+	merge := func(mappings map[string][]string) {
+		// A service could be exposed via multiple hosts/routers
+		for svcName, hostnames := range mappings {
+			existing := allMappings[svcName]
+			for _, h := range hostnames {
+				if !slices.Contains(existing, h) {
+					existing = append(existing, h)
+				}
+			}
+			allMappings[svcName] = existing
+		}
+	}
 
 	for _, host := range cfg.Hosts {
-		if !host.Traefik.Enabled {
+		if !host.HasTraefik() {
+			continue
+		}
+		if mappings, ok := traefikMappings.get(&host); ok {
+			merge(mappings)
 			continue
 		}
 
@@ -307,91 +638,144 @@ func enrichWithTraefikURLs(ctx context.Context, cfg *config.Config, svcList []se
 			}
 		}
 
-		client := traefik.NewClient(host.Name, host.Address, host.Traefik.APIPort, sshConfig)
+		client := traefik.NewClient(host.Name, host.Address, host.Traefik.APIPort, sshConfig, httpclient.New(cfg.OutboundFor(&host), 10*time.Second))
 		defer client.Close()
 
-		mappings, err := client.GetServiceHostMappings(ctx)
+		var mappings map[string][]string
+		err := callRemoteProvider(ctx, cfg.GetServicesTimeout(), "traefik", &host, func(ctx context.Context) error {
+			var err error
+			mappings, err = client.GetServiceHostMappings(ctx)
+			return err
+		})
 		if err != nil {
-			log.Printf("Warning: failed to get Traefik mappings from %s: %v", host.Name, err)
+			log.Printf("Warning: %v", err)
 			continue
 		}
 
-		// Merge mappings (a service could be exposed via multiple hosts/routers)
-		for svcName, hostnames := range mappings {
-			existing := traefikMappings[svcName]
-			for _, h := range hostnames {
-				// Avoid duplicates
-				found := false
-				for _, e := range existing {
-					if e == h {
-						found = true
-						break
-					}
-				}
-				if !found {
-					existing = append(existing, h)
-				}
-			}
-			traefikMappings[svcName] = existing
-		}
+		traefikMappings.put(&host, mappings)
+		merge(mappings)
+	}
+
+	if len(allMappings) == 0 && !cfg.Debug {
+		return svcList
 	}
+	return applyTraefikURLs(svcList, allMappings, cfg.Debug)
+}
 
-	// Apply Traefik URLs to services
+// applyTraefikURLs sets TraefikURLs on each service from the Traefik
+// service->hostnames mappings. Services with URLs from a url label keep them.
+// With debug set, the names tried for each service are logged.
+func applyTraefikURLs(svcList []services.ServiceInfo, traefikMappings map[string][]string, debug bool) []services.ServiceInfo {
+	var tried []string // reused across services
 	for i := range svcList {
 		svc := &svcList[i]
-
-		// Try to match by Traefik service name if explicitly defined in labels
-		var hostnames []string
-		if svc.TraefikServiceName != "" {
-			hostnames = traefikMappings[svc.TraefikServiceName]
+		if svc.URLOverride {
+			if debug {
+				log.Printf("Debug: traefik match for %s/%s: skipped, URLs set by label", svc.Host, svc.Name)
+			}
+			continue
 		}
 
-		// Fall back to matching by service name (the Name field is the service name for Docker Compose)
-		if len(hostnames) == 0 {
-			hostnames = traefikMappings[svc.Name]
+		var hostnames []string
+		tried = appendTraefikCandidates(tried[:0], svc)
+		matched := ""
+		for _, name := range tried {
+			if hostnames = traefikMappings[name]; len(hostnames) > 0 {
+				matched = name
+				break
+			}
 		}
-
-		// Try with normalized name (underscores to hyphens, as Traefik does)
-		if len(hostnames) == 0 {
-			normalizedName := normalizeForTraefik(svc.Name)
-			if normalizedName != svc.Name {
-				hostnames = traefikMappings[normalizedName]
+		if debug && len(tried) > 0 {
+			if matched != "" {
+				log.Printf("Debug: traefik match for %s/%s: tried %s, matched %q", svc.Host, svc.Name, strings.Join(tried, ", "), matched)
+			} else {
+				log.Printf("Debug: traefik match for %s/%s: tried %s, no match", svc.Host, svc.Name, strings.Join(tried, ", "))
 			}
 		}
 
-		// Traefik often names services as "servicename-projectname", so try that pattern
-		if len(hostnames) == 0 && svc.Project != "" && svc.Project != "systemd" {
-			traefikServiceName := svc.Name + "-" + svc.Project
-			hostnames = traefikMappings[traefikServiceName]
+		// Convert hostnames to full URLs (https by default since Traefik usually terminates TLS)
+		for _, hostname := range hostnames {
+			svc.TraefikURLs = append(svc.TraefikURLs, "https://"+hostname)
 		}
+		svc.TraefikURLs = dedupeURLs(svc.TraefikURLs)
+	}
 
-		// Try the same pattern but with normalized name (underscores to hyphens)
-		if len(hostnames) == 0 && svc.Project != "" && svc.Project != "systemd" {
-			traefikServiceName := normalizeForTraefik(svc.Name) + "-" + svc.Project
-			hostnames = traefikMappings[traefikServiceName]
-		}
+	return svcList
+}
 
-		// Also try with container name for containers that might use that
-		if len(hostnames) == 0 && svc.ContainerName != "" {
-			hostnames = traefikMappings[svc.ContainerName]
+// traefikCandidates returns the Traefik service names that may belong to svc,
+// most specific first: the name from its traefik labels, then its service
+// name, the "service-project" pattern Traefik's Docker provider uses, and its
+// container name, each also with underscores normalized to hyphens. A service
+// labelled to ignore Traefik matching only uses the name from its labels.
+func traefikCandidates(svc services.ServiceInfo) []string {
+	return appendTraefikCandidates(nil, &svc)
+}
+
+// appendTraefikCandidates appends the traefikCandidates of svc to names.
+func appendTraefikCandidates(names []string, svc *services.ServiceInfo) []string {
+	start := len(names)
+	add := func(name string) {
+		// There are only a handful of candidates, so a scan beats a set
+		if name != "" && !slices.Contains(names[start:], name) {
+			names = append(names, name)
 		}
+	}
 
-		// Try normalized container name
-		if len(hostnames) == 0 && svc.ContainerName != "" {
-			normalizedContainerName := normalizeForTraefik(svc.ContainerName)
-			if normalizedContainerName != svc.ContainerName {
-				hostnames = traefikMappings[normalizedContainerName]
-			}
+	add(svc.TraefikServiceName)
+	if svc.TraefikIgnore {
+		return names
+	}
+
+	normalizedName := normalizeForTraefik(svc.Name)
+	add(svc.Name)
+	add(normalizedName)
+	// Traefik often names services as "servicename-projectname"
+	if svc.Project != "" && svc.Project != "systemd" {
+		add(svc.Name + "-" + svc.Project)
+		if normalizedName != svc.Name {
+			add(normalizedName + "-" + svc.Project)
 		}
+	}
+	// Also try with container name for containers that might use that
+	add(svc.ContainerName)
+	add(normalizeForTraefik(svc.ContainerName))
+	return names
+}
 
-		// Convert hostnames to full URLs (https by default since Traefik usually terminates TLS)
-		for _, hostname := range hostnames {
-			url := "https://" + hostname
-			svc.TraefikURLs = append(svc.TraefikURLs, url)
+// dedupeURLs removes duplicate URLs and returns the rest sorted. Hostnames
+// are compared case-insensitively, and a "www." URL is dropped when the same
+// URL without "www." is present.
+func dedupeURLs(urls []string) []string {
+	if len(urls) == 0 {
+		return urls
+	}
+	seen := make(map[string]bool, len(urls))
+	var unique []string
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err == nil && u.Host != "" {
+			u.Host = strings.TrimSuffix(strings.ToLower(u.Host), ".")
+			raw = u.String()
+		}
+		if !seen[raw] {
+			seen[raw] = true
+			unique = append(unique, raw)
 		}
 	}
 
-	return svcList
+	result := unique[:0]
+	for _, raw := range unique {
+		if u, err := url.Parse(raw); err == nil && strings.HasPrefix(u.Host, "www.") {
+			u.Host = strings.TrimPrefix(u.Host, "www.")
+			if seen[u.String()] {
+				continue
+			}
+		}
+		result = append(result, raw)
+	}
+	sort.Strings(result)
+	return result
 }
 
 // filterServicesForUser returns only the services the user is allowed to access.
@@ -411,26 +795,319 @@ func filterServicesForUser(svcList []services.ServiceInfo, user *auth.User) []se
 	return filtered
 }
 
-// ServicesHandler handles GET /api/services requests.
-func ServicesHandler(w http.ResponseWriter, r *http.Request) {
-	cfg := config.Get()
-	if cfg == nil {
-		http.Error(w, "Configuration not loaded", http.StatusInternalServerError)
-		return
-	}
+// canSeeHidden reports whether user may see and act on hidden services.
+// Hidden services are an admin view; with auth disabled everyone is an admin.
+func canSeeHidden(user *auth.User) bool {
+	return user == nil || user.IsAdmin
+}
 
-	svcList, err := getAllServices(r.Context(), cfg)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error getting services: %v", err), http.StatusInternalServerError)
-		return
+// withoutHidden returns the services not marked hidden. Orphaned containers
+// are left out too; they only clutter the dashboard until they are cleaned up.
+func withoutHidden(svcList []services.ServiceInfo) []services.ServiceInfo {
+	filtered := make([]services.ServiceInfo, 0, len(svcList))
+	for _, svc := range svcList {
+		if !svc.Hidden && !svc.Orphaned {
+			filtered = append(filtered, svc)
+		}
 	}
+	return filtered
+}
 
-	// Filter services based on user permissions
-	user := auth.GetUserFromContext(r.Context())
-	svcList = filterServicesForUser(svcList, user)
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(svcList)
+// withoutNetworkAddresses returns the services with their container IP
+// addresses removed. Network names stay visible; addresses are for admins.
+func withoutNetworkAddresses(svcList []services.ServiceInfo) []services.ServiceInfo {
+	for i := range svcList {
+		if len(svcList[i].Networks) == 0 {
+			continue
+		}
+		networks := make([]services.NetworkAttachment, len(svcList[i].Networks))
+		for j, attachment := range svcList[i].Networks {
+			networks[j] = services.NetworkAttachment{Name: attachment.Name}
+		}
+		svcList[i].Networks = networks
+	}
+	return svcList
+}
+
+// serviceHidden reports whether the service a request names is hidden from
+// the dashboard. Only Docker containers can be hidden (through their labels),
+// so other sources are never looked up. It is a variable so tests can replace it.
+var serviceHidden = func(ctx context.Context, cfg *config.Config, source, host, containerName string) bool {
+	if source != "docker" || containerName == "" {
+		return false
+	}
+	reg, ok := sourceRegistry.Lookup(source)
+	if !ok {
+		return false
+	}
+	provider, closeProvider, err := newProvider(cfg, reg, hostOrLocal(cfg, host))
+	defer closeProvider()
+	if err != nil || provider == nil {
+		return false
+	}
+	svc, err := provider.GetService(containerName)
+	if err != nil {
+		return false
+	}
+	info, err := svc.GetInfo(ctx)
+	return err == nil && info.Hidden
+}
+
+// filterLinksForUser returns the links the user may see. Links with
+// allowed_groups need membership in one of them; other host links follow the
+// user's access to that host, and other global links are shown to everyone.
+func filterLinksForUser(links []config.Link, user *auth.User) []config.Link {
+	filtered := make([]config.Link, 0, len(links))
+	for _, link := range links {
+		switch {
+		case user == nil || user.HasGlobalAccess:
+		case len(link.AllowedGroups) > 0:
+			if !user.InAnyGroup(link.AllowedGroups) {
+				continue
+			}
+		case link.Host != "":
+			if !user.CanAccessHost(link.Host) {
+				continue
+			}
+		}
+		filtered = append(filtered, link)
+	}
+	return filtered
+}
+
+// LinksHandler handles GET /api/links requests.
+// Returns the configured static links the user may see.
+func LinksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg := configSource()
+	if cfg == nil {
+		http.Error(w, "Configuration not loaded", http.StatusInternalServerError)
+		return
+	}
+
+	user := auth.GetUserFromContext(r.Context())
+	links := filterLinksForUser(cfg.GetLinks(), user)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(links)
+}
+
+// ServicesHandler handles GET /api/services requests.
+// Hidden services are left out unless an admin asks for them with
+// ?include_hidden=true, in which case they are returned with hidden set.
+// With ?group=host the services are returned as {"hosts": [...]}, grouped
+// by host with what each host supports (see groupByHost), and with
+// ?group=project as {"projects": [...], "links": [...]}, the Docker services
+// grouped by compose project with its aggregate state (see groupByProject)
+// and the links the user may see, placed in their project (see placeLinks).
+//
+// The services are served from the snapshot shared with the long-poll, and
+// collected only when it is missing, invalidated or older than
+// servicesMaxAge. While the startup warm-up runs and nothing is stored yet,
+// the services of the hosts collected so far are returned with
+// "warming": true, wrapping the list as {"services": [...], "warming": true}.
+//
+// The response carries the ETag of the services collected, which
+// GET /api/services/poll waits on, and a Server-Timing header with how long
+// each host and phase of their collection took. Admins can ask for the same
+// timings in the body with ?debug_timing=true, which collects the services
+// afresh, wraps the list as {"services": [...], "_timing": {...}} and adds
+// "_timing" to the grouped responses.
+func ServicesHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := configSource()
+	if cfg == nil {
+		http.Error(w, "Configuration not loaded", http.StatusInternalServerError)
+		return
+	}
+
+	user := auth.GetUserFromContext(r.Context())
+	query, ok := parseServicesQuery(w, r, user)
+	if !ok {
+		return
+	}
+
+	if warming, partial := currentWarmup().warming(); warming && servicesCache.last() == nil {
+		query.warming = true
+		writeServices(w, cfg, partial, user, query)
+		return
+	}
+
+	query.timing = newCollectionTiming()
+	ctx := withCollectionTiming(r.Context(), query.timing)
+	var snapshot *servicesSnapshot
+	if query.debugTiming {
+		generation := servicesCache.current()
+		svcList, err := getAllServices(ctx, cfg)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error getting services: %v", err), http.StatusInternalServerError)
+			return
+		}
+		snapshot = servicesCache.store(generation, cfg, query.timing, svcList)
+	} else {
+		var err error
+		if snapshot, _, err = servicesCache.get(ctx, cfg); err != nil {
+			if r.Context().Err() == nil {
+				http.Error(w, fmt.Sprintf("Error getting services: %v", err), http.StatusInternalServerError)
+			}
+			return
+		}
+		if len(query.timing.phases) == 0 {
+			// Served from the snapshot: report the collection that made it
+			query.timing.phases = slices.Clone(snapshot.timing)
+		}
+	}
+
+	body := encodeServices(cfg, slices.Clone(snapshot.services), user, query)
+	w.Header().Set("ETag", servicesETag(body))
+	writeServicesBody(w, body, user, query)
+}
+
+// servicesQuery is how a services request asks for the list.
+type servicesQuery struct {
+	includeHidden bool   // include hidden services (admins only)
+	group         string // "host" or "project" groups the services by host or compose project, "" doesn't
+	requestHost   string // host name the client asked for, to flag the services it came through
+	debugTiming   bool   // add the timings to the body as _timing (admins only)
+	warming       bool   // the services are those the startup warm-up collected so far
+
+	timing *collectionTiming // timings of the services collected for this request, nil if they weren't
+}
+
+// parseServicesQuery reads the include_hidden and group parameters of a
+// services request. It writes the error and returns false if they are
+// invalid or the user may not ask for them.
+func parseServicesQuery(w http.ResponseWriter, r *http.Request, user *auth.User) (servicesQuery, bool) {
+	var query servicesQuery
+	if value := r.URL.Query().Get("include_hidden"); value != "" {
+		var err error
+		if query.includeHidden, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "Invalid include_hidden value", http.StatusBadRequest)
+			return query, false
+		}
+	}
+	if query.includeHidden && !canSeeHidden(user) {
+		http.Error(w, "Access denied: administrator privileges required to view hidden services", http.StatusForbidden)
+		return query, false
+	}
+	query.group = r.URL.Query().Get("group")
+	if query.group != "" && query.group != "host" && query.group != "project" {
+		http.Error(w, "Invalid group: use host or project", http.StatusBadRequest)
+		return query, false
+	}
+	if value := r.URL.Query().Get("debug_timing"); value != "" {
+		var err error
+		if query.debugTiming, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "Invalid debug_timing value", http.StatusBadRequest)
+			return query, false
+		}
+	}
+	if query.debugTiming && !canSeeHidden(user) {
+		http.Error(w, "Access denied: administrator privileges required to view timings", http.StatusForbidden)
+		return query, false
+	}
+	query.requestHost = requestHostName(r)
+	return query, true
+}
+
+// writeServices writes the services user may see as asked by query.
+// svcList is modified, so it must not be shared.
+func writeServices(w http.ResponseWriter, cfg *config.Config, svcList []services.ServiceInfo, user *auth.User, query servicesQuery) {
+	writeServicesBody(w, encodeServices(cfg, svcList, user, query), user, query)
+}
+
+// writeServicesBody writes body, the services encoded by encodeServices.
+func writeServicesBody(w http.ResponseWriter, body []byte, user *auth.User, query servicesQuery) {
+	w.Header().Set("Content-Type", "application/json")
+	if query.timing != nil {
+		// Only administrators see every host named, as _timing does
+		w.Header().Set("Server-Timing", query.timing.header(canSeeHidden(user)))
+	}
+	w.Write(body)
+}
+
+// encodeServices returns the JSON of the services user may see as asked by
+// query: the list, or the grouped object. svcList is modified, so it must
+// not be shared.
+func encodeServices(cfg *config.Config, svcList []services.ServiceInfo, user *auth.User, query servicesQuery) []byte {
+	start := time.Now()
+	svcList = visibleServices(cfg, svcList, user, query)
+	query.timing.observe("filter", "", start)
+
+	var body any = svcList
+	switch {
+	case (query.debugTiming && query.timing != nil) || query.warming:
+		body = wrappedServices(cfg, svcList, user, query)
+	case query.group == "host":
+		body = map[string][]HostGroup{"hosts": groupByHost(cfg, svcList, user)}
+	case query.group == "project":
+		projects := groupByProject(svcList)
+		links := placeLinks(projects, filterLinksForUser(cfg.GetLinks(), user))
+		body = map[string]any{"projects": projects, "links": links}
+	}
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(body)
+	return buf.Bytes()
+}
+
+// wrappedServices returns svcList as encodeServices does, in an object
+// with "_timing" for a debug_timing request and "warming" during the
+// startup warm-up: {"services": [...]} for the plain list, or the grouped
+// object.
+func wrappedServices(cfg *config.Config, svcList []services.ServiceInfo, user *auth.User, query servicesQuery) map[string]any {
+	body := map[string]any{}
+	if query.debugTiming && query.timing != nil {
+		body["_timing"] = query.timing.report()
+	}
+	if query.warming {
+		body["warming"] = true
+	}
+	switch query.group {
+	case "host":
+		body["hosts"] = groupByHost(cfg, svcList, user)
+	case "project":
+		projects := groupByProject(svcList)
+		body["links"] = placeLinks(projects, filterLinksForUser(cfg.GetLinks(), user))
+		body["projects"] = projects
+	default:
+		body["services"] = svcList
+	}
+	return body
+}
+
+// visibleServices returns the services of svcList user may see as asked by
+// query, with their last state changes. svcList is modified, so it must not
+// be shared.
+func visibleServices(cfg *config.Config, svcList []services.ServiceInfo, user *auth.User, query servicesQuery) []services.ServiceInfo {
+	// Flag the services this request came through before any are filtered
+	// out, so the proxy is recognized from all the hosts it routes
+	markProxyDependencies(cfg, svcList, query.requestHost)
+
+	// Filter services based on user permissions
+	svcList = filterServicesForUser(svcList, user)
+	if !query.includeHidden {
+		svcList = withoutHidden(svcList)
+	}
+	if user != nil && !user.IsAdmin {
+		svcList = withoutNetworkAddresses(svcList)
+	}
+	mergeStateChanges(svcList, stateTracker)
+	return svcList
+}
+
+// VersionHandler handles GET /api/version requests.
+// Returns the build information of the running binary. This endpoint is public.
+func VersionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(version.Get())
 }
 
 // SystemdLogsHandler handles GET /api/logs/systemd requests for streaming systemd logs.
@@ -442,52 +1119,63 @@ func SystemdLogsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Optional journal filters, e.g. boot=-1&priority=err for errors from the previous boot
+	boot, err := systemd.ParseBoot(r.URL.Query().Get("boot"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	priority, err := systemd.ParsePriority(r.URL.Query().Get("priority"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params, err := parseLogParams(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Check user permissions
 	user := auth.GetUserFromContext(r.Context())
-	if user != nil && !user.CanAccessService(hostName, unitName) {
+	if user != nil && !user.CanViewLogs(hostName, unitName) {
 		http.Error(w, "Access denied: you do not have permission to view logs for this service", http.StatusForbidden)
 		return
 	}
 
-	// Find the host config to get the address and service entry
-	cfg := config.Get()
-	hostAddress := "localhost"
-	var serviceEntry systemd.ServiceEntry
-	var sshConfig *systemd.SSHConfig
-	if cfg != nil {
-		if host := cfg.GetHostByName(hostName); host != nil {
-			hostAddress = host.Address
-			// Convert SSH config if present
-			if host.SSHConfig != nil {
-				sshConfig = &systemd.SSHConfig{
-					Username: host.SSHConfig.Username,
-					Port:     host.SSHConfig.Port,
-				}
-			}
-			// Look up the service entry to get user information
-			for _, entry := range host.GetSystemdServiceEntries() {
-				if entry.Name == unitName {
-					serviceEntry = systemd.ServiceEntry{
-						Name:     entry.Name,
-						User:     entry.User,
-						ReadOnly: entry.ReadOnly,
-						Ports:    entry.Ports,
-					}
-					break
-				}
-			}
-		}
+	// Units on hosts missing from the config are read from the local journal
+	cfg := configSource()
+	redactor, ok := params.redactor(user, cfg)
+	if !ok {
+		http.Error(w, "Access denied: only admins may view logs unredacted", http.StatusForbidden)
+		return
+	}
+	host := hostOrLocal(cfg, hostName)
+	provider, closeProvider, err := logProvider(cfg, "systemd", host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
-	// If not found in config, create a basic entry
-	if serviceEntry.Name == "" {
-		serviceEntry = systemd.ServiceEntry{Name: unitName}
+	defer closeProvider()
+	filtered, ok := provider.(journalProvider)
+	if !ok {
+		http.Error(w, "systemd provider does not support journal filters", http.StatusInternalServerError)
+		return
+	}
+	logOpts, warning := params.options(serviceLogSettings(r.Context(), provider, host, "systemd", unitName), cfg)
+	logOpts.Boot, logOpts.Priority = boot, priority
+	logOpts.Structured = params.structured
+
+	r, done, ok := trackStream(w, r, hostName+"/"+unitName)
+	if !ok {
+		return
 	}
+	defer done()
 
 	// Set headers for SSE
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -496,16 +1184,37 @@ func SystemdLogsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
+	sendLogWarning(w, flusher, warning)
+
+	// A reconnecting viewer resumes after the cursor of the last line it
+	// saw, which lines only carry when structured
+	if id := logResumeID(r); id != "" {
+		if logOpts.Structured && systemd.ValidCursor(id) {
+			logOpts.AfterCursor = id
+		} else {
+			sendLogReset(w, flusher, "Cannot resume from the last line seen; showing the latest lines")
+		}
+	}
 
-	// Create systemd provider for this host with the full service entry
-	systemdProvider := systemd.NewProviderWithEntries(hostName, hostAddress, []systemd.ServiceEntry{serviceEntry}, sshConfig)
-	logs, err := systemdProvider.GetLogs(ctx, unitName, 100, true)
+	var logs io.ReadCloser
+	connect := func(ctx context.Context) error {
+		var err error
+		logs, err = filtered.GetLogsWithOptions(ctx, unitName, logOpts)
+		return err
+	}
+	if host.IsLocal() {
+		err = connect(ctx)
+	} else {
+		// Retry the initial connect to remote hosts; the stream itself is not retried
+		err = resilience.Do(ctx, hostName, connect)
+	}
 	if err != nil {
 		fmt.Fprintf(w, "data: Error starting journalctl: %v\n\n", err)
 		flusher.Flush()
 		return
 	}
-	defer logs.Close()
+	// logs is reopened if the cursor can't be resumed after
+	defer func() { logs.Close() }()
 
 	reader := bufio.NewReader(logs)
 	for {
@@ -515,7 +1224,29 @@ func SystemdLogsHandler(w http.ResponseWriter, r *http.Request) {
 		default:
 			line, err := reader.ReadString('\n')
 			if err != nil {
-				if err == io.EOF {
+				// journalctl that may not read the journal would otherwise
+				// leave the viewer empty; say what access is missing
+				var accessErr *systemd.JournalAccessError
+				if errors.As(err, &accessErr) {
+					fmt.Fprintf(w, "data: Error: %v\n\n", accessErr)
+					flusher.Flush()
+					return
+				}
+				// The cursor is gone, e.g. vacuumed or from another
+				// machine; start over from the tail
+				if errors.Is(err, systemd.ErrCannotResume) && logOpts.AfterCursor != "" {
+					sendLogReset(w, flusher, err.Error())
+					logs.Close()
+					logOpts.AfterCursor = ""
+					if err := connect(ctx); err != nil {
+						fmt.Fprintf(w, "data: Error starting journalctl: %v\n\n", err)
+						flusher.Flush()
+						return
+					}
+					reader = bufio.NewReader(logs)
+					continue
+				}
+				if err == io.EOF && logOpts.Following() {
 					select {
 					case <-ctx.Done():
 						return
@@ -523,14 +1254,21 @@ func SystemdLogsHandler(w http.ResponseWriter, r *http.Request) {
 						continue
 					}
 				}
+				// One-shot reads (previous boots) end when journalctl exits
 				return
 			}
 
 			escaped := strings.TrimSpace(line)
-			if escaped != "" {
-				fmt.Fprintf(w, "data: %s\n\n", escaped)
-				flusher.Flush()
+			if escaped == "" {
+				continue
+			}
+			var cursor string
+			if logOpts.Structured {
+				if c, text, err := systemd.ParseJournalEntry([]byte(escaped), logOpts.NoTimestamps); err == nil {
+					cursor, escaped = c, strings.TrimSpace(text)
+				}
 			}
+			sendLogLine(w, flusher, cursor, redactor.Line(escaped))
 		}
 	}
 }
@@ -547,16 +1285,21 @@ func TraefikLogsHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Check user permissions
 	user := auth.GetUserFromContext(r.Context())
-	if user != nil && !user.CanAccessService(hostName, serviceName) {
+	if user != nil && !user.CanViewLogs(hostName, serviceName) {
 		http.Error(w, "Access denied: you do not have permission to view logs for this service", http.StatusForbidden)
 		return
 	}
 
+	r, done, ok := trackStream(w, r, hostName+"/"+serviceName)
+	if !ok {
+		return
+	}
+	defer done()
+
 	// Set headers for SSE
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -601,19 +1344,35 @@ func HomeAssistantLogsHandler(w http.ResponseWriter, r *http.Request) {
 	if serviceName == "" {
 		serviceName = "homeassistant"
 	}
+	params, err := parseLogParams(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Check user permissions
 	user := auth.GetUserFromContext(r.Context())
-	if user != nil && !user.CanAccessService(hostName, serviceName) {
+	if user != nil && !user.CanViewLogs(hostName, serviceName) {
 		http.Error(w, "Access denied: you do not have permission to view logs for this service", http.StatusForbidden)
 		return
 	}
+	cfg := configSource()
+	redactor, ok := params.redactor(user, cfg)
+	if !ok {
+		http.Error(w, "Access denied: only admins may view logs unredacted", http.StatusForbidden)
+		return
+	}
+
+	r, done, ok := trackStream(w, r, hostName+"/"+serviceName)
+	if !ok {
+		return
+	}
+	defer done()
 
 	// Set headers for SSE
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -622,25 +1381,22 @@ func HomeAssistantLogsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Find the Home Assistant provider for this host
-	cfg := config.Get()
-	var provider *homeassistant.Provider
-	for _, host := range cfg.Hosts {
-		if host.Name == hostName && host.HasHomeAssistant() {
-			var err error
-			provider, err = homeassistant.NewProvider(&host)
-			if err != nil {
-				log.Printf("Failed to create HA provider for %s: %v", hostName, err)
-			}
-			break
+	var provider services.Provider
+	if host := cfg.GetHostByName(hostName); host != nil && host.HasHomeAssistant() {
+		var closeProvider func()
+		var err error
+		provider, closeProvider, err = logProvider(cfg, "homeassistant", host)
+		if err != nil {
+			log.Printf("Failed to create HA provider for %s: %v", hostName, err)
 		}
-	}
-	if provider != nil {
-		defer provider.Close()
+		defer closeProvider()
 	}
 
 	// Get logs from the provider
 	if provider != nil {
-		logs, err := provider.GetLogs(r.Context(), serviceName, 100, true)
+		logOpts, warning := params.options(nil, cfg)
+		sendLogWarning(w, flusher, warning)
+		logs, err := openLogs(r.Context(), provider, serviceName, logOpts)
 		if err != nil {
 			log.Printf("Failed to get logs for %s: %v", serviceName, err)
 			fmt.Fprintf(w, "data: Error getting logs: %v\n\n", err)
@@ -653,7 +1409,7 @@ func HomeAssistantLogsHandler(w http.ResponseWriter, r *http.Request) {
 				case <-r.Context().Done():
 					return
 				default:
-					line := scanner.Text()
+					line := redactor.Line(scanner.Text())
 					fmt.Fprintf(w, "data: %s\n\n", line)
 					flusher.Flush()
 				}
@@ -689,6 +1445,87 @@ func HomeAssistantLogsHandler(w http.ResponseWriter, r *http.Request) {
 	<-r.Context().Done()
 }
 
+// ProviderLogsHandler handles GET /api/logs/provider requests, streaming the
+// logs of a service of any registered source that supports logs (such as
+// the demo source). The built-in sources have their own endpoints.
+func ProviderLogsHandler(w http.ResponseWriter, r *http.Request) {
+	source := r.URL.Query().Get("source")
+	hostName := r.URL.Query().Get("host")
+	serviceName := r.URL.Query().Get("service")
+	if source == "" || hostName == "" || serviceName == "" {
+		http.Error(w, "source, host and service parameters required", http.StatusBadRequest)
+		return
+	}
+	params, err := parseLogParams(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user := auth.GetUserFromContext(r.Context())
+	if user != nil && !user.CanViewLogs(hostName, serviceName) {
+		http.Error(w, "Access denied: you do not have permission to view logs for this service", http.StatusForbidden)
+		return
+	}
+
+	cfg := configSource()
+	if cfg == nil {
+		http.Error(w, "Configuration not loaded", http.StatusInternalServerError)
+		return
+	}
+	redactor, ok := params.redactor(user, cfg)
+	if !ok {
+		http.Error(w, "Access denied: only admins may view logs unredacted", http.StatusForbidden)
+		return
+	}
+	host := cfg.GetHostByName(hostName)
+	if host == nil {
+		http.Error(w, "Unknown host: "+hostName, http.StatusNotFound)
+		return
+	}
+
+	r, done, ok := trackStream(w, r, hostName+"/"+serviceName)
+	if !ok {
+		return
+	}
+	defer done()
+
+	// Set headers for SSE
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	provider, closeProvider, err := logProvider(cfg, source, host)
+	defer closeProvider()
+	if err != nil {
+		fmt.Fprintf(w, "data: Error: %v\n\n", err)
+		flusher.Flush()
+		return
+	}
+
+	logOpts, warning := params.options(serviceLogSettings(r.Context(), provider, host, source, serviceName), cfg)
+	sendLogWarning(w, flusher, warning)
+	logs, err := openLogs(r.Context(), provider, serviceName, logOpts)
+	if err != nil {
+		fmt.Fprintf(w, "data: Error: %v\n\n", err)
+		flusher.Flush()
+		return
+	}
+	defer logs.Close()
+
+	scanner := bufio.NewScanner(logs)
+	for scanner.Scan() {
+		fmt.Fprintf(w, "data: %s\n\n", redactor.Line(scanner.Text()))
+		flusher.Flush()
+	}
+}
+
 // DockerLogsHandler handles GET /api/logs requests for streaming Docker container logs.
 func DockerLogsHandler(w http.ResponseWriter, r *http.Request) {
 	containerName := r.URL.Query().Get("container")
@@ -697,8 +1534,13 @@ func DockerLogsHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "container parameter required", http.StatusBadRequest)
 		return
 	}
+	params, err := parseLogParams(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	cfg := config.Get()
+	cfg := configSource()
 	localHostName := "localhost"
 	if cfg != nil {
 		localHostName = cfg.GetLocalHostName()
@@ -710,16 +1552,30 @@ func DockerLogsHandler(w http.ResponseWriter, r *http.Request) {
 		checkName = containerName
 	}
 	user := auth.GetUserFromContext(r.Context())
-	if user != nil && !user.CanAccessService(localHostName, checkName) {
+	if user != nil && !user.CanViewLogs(localHostName, checkName) {
+		http.Error(w, "Access denied: you do not have permission to view logs for this service", http.StatusForbidden)
+		return
+	}
+	if !canSeeHidden(user) && serviceHidden(r.Context(), cfg, "docker", localHostName, containerName) {
 		http.Error(w, "Access denied: you do not have permission to view logs for this service", http.StatusForbidden)
 		return
 	}
+	redactor, ok := params.redactor(user, cfg)
+	if !ok {
+		http.Error(w, "Access denied: only admins may view logs unredacted", http.StatusForbidden)
+		return
+	}
+
+	r, done, ok := trackStream(w, r, localHostName+"/"+containerName)
+	if !ok {
+		return
+	}
+	defer done()
 
 	// Set headers for SSE
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -727,17 +1583,38 @@ func DockerLogsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dockerProvider, err := docker.NewProvider(localHostName)
+	host := hostOrLocal(cfg, localHostName)
+	dockerProvider, closeProvider, err := logProvider(cfg, "docker", host)
 	if err != nil {
 		fmt.Fprintf(w, "data: Error: %v\n\n", err)
 		flusher.Flush()
 		return
 	}
-	defer dockerProvider.Close()
+	defer closeProvider()
 
 	ctx := r.Context()
 
-	logs, err := dockerProvider.GetLogs(ctx, containerName, 100, true)
+	logOpts, warning := params.options(serviceLogSettings(ctx, dockerProvider, host, "docker", containerName), cfg)
+	sendLogWarning(w, flusher, warning)
+
+	// A reconnecting viewer resumes after the timestamp of the last line it
+	// saw, which lines only carry with timestamps on
+	var since int64
+	if id := logResumeID(r); id != "" {
+		since, err = strconv.ParseInt(id, 10, 64)
+		if err != nil || since <= 0 || logOpts.NoTimestamps {
+			since = 0
+			sendLogReset(w, flusher, "Cannot resume from the last line seen; showing the latest lines")
+		} else {
+			logOpts.Since = time.Unix(0, since)
+		}
+	}
+	logs, err := openLogs(ctx, dockerProvider, containerName, logOpts)
+	if errors.Is(err, systemd.ErrCannotResume) {
+		since, logOpts.Since = 0, time.Time{}
+		sendLogReset(w, flusher, err.Error())
+		logs, err = openLogs(ctx, dockerProvider, containerName, logOpts)
+	}
 	if err != nil {
 		fmt.Fprintf(w, "data: Error: %v\n\n", err)
 		flusher.Flush()
@@ -762,32 +1639,50 @@ func DockerLogsHandler(w http.ResponseWriter, r *http.Request) {
 			// Escape for SSE and send
 			escaped := strings.ReplaceAll(string(line), "\n", "")
 			escaped = strings.ReplaceAll(escaped, "\r", "")
-			if escaped != "" {
-				fmt.Fprintf(w, "data: %s\n\n", escaped)
-				flusher.Flush()
+			if escaped == "" {
+				continue
+			}
+			var id string
+			if lineID, ok := dockerLineID(escaped); ok {
+				// Docker's since is inclusive, so the last line seen
+				// comes again
+				if lineID <= since {
+					continue
+				}
+				id = strconv.FormatInt(lineID, 10)
 			}
+			sendLogLine(w, flusher, id, redactor.Line(escaped))
 		}
 	}
 }
 
-// IndexHandler serves the main dashboard page.
+// IndexHandler serves the main dashboard page, with the last services
+// collected that the user may see embedded so it renders before
+// /api/services answers.
 func IndexHandler(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path == "/" {
 		// Try embedded filesystem first
+		var content []byte
+		err := fs.ErrNotExist
 		if embeddedStaticFS != nil {
-			content, err := fs.ReadFile(embeddedStaticFS, "index.html")
-			if err == nil {
-				w.Header().Set("Content-Type", "text/html; charset=utf-8")
-				w.Write(content)
-				return
-			}
+			content, err = fs.ReadFile(embeddedStaticFS, "index.html")
 		}
-		// Fallback to filesystem for development
-		http.ServeFile(w, r, "static/index.html")
-		return
-	}
-	http.NotFound(w, r)
-}
+		if err != nil {
+			// Fallback to filesystem for development
+			content, err = os.ReadFile("static/index.html")
+		}
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		// The page carries the services of the user who asked for it
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(withServicesSnapshot(content, r))
+		return
+	}
+	http.NotFound(w, r)
+}
 
 // BangAndPipeHandler handles GET /api/bangAndPipeToRegex requests.
 // It compiles a bang-and-pipe expression into an AST for client-side evaluation.
@@ -803,38 +1698,7 @@ func BangAndPipeHandler(w http.ResponseWriter, r *http.Request) {
 // BangAndPipeDocsHandler handles GET /api/docs/bangandpipe requests.
 // It renders the BangAndPipe query language documentation as HTML.
 func BangAndPipeDocsHandler(w http.ResponseWriter, r *http.Request) {
-	var mdContent []byte
-	var err error
-
-	// Try embedded filesystem first
-	if embeddedDocsFS != nil {
-		mdContent, err = fs.ReadFile(embeddedDocsFS, "bangandpipe-query-language.md")
-	}
-
-	// Fallback to filesystem for development
-	if err != nil || embeddedDocsFS == nil {
-		mdContent, err = os.ReadFile("docs/bangandpipe-query-language.md")
-		if err != nil {
-			http.Error(w, "Documentation not found", http.StatusNotFound)
-			return
-		}
-	}
-
-	// Create markdown parser with extensions
-	extensions := parser.CommonExtensions | parser.AutoHeadingIDs | parser.NoEmptyLineBeforeBlock
-	p := parser.NewWithExtensions(extensions)
-	doc := p.Parse(mdContent)
-
-	// Create HTML renderer with options
-	htmlFlags := html.CommonFlags | html.HrefTargetBlank
-	opts := html.RendererOptions{Flags: htmlFlags}
-	renderer := html.NewRenderer(opts)
-
-	// Render to HTML
-	htmlContent := markdown.Render(doc, renderer)
-
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.Write(htmlContent)
+	serveDoc(w, r, "bangandpipe")
 }
 
 // ServiceActionRequest represents the request body for service actions.
@@ -844,10 +1708,36 @@ type ServiceActionRequest struct {
 	Source        string `json:"source"`
 	Host          string `json:"host"`
 	Project       string `json:"project"`
+	// ConfirmSelf acknowledges that the service is the dashboard itself and
+	// the action will drop the connection.
+	ConfirmSelf bool `json:"confirm_self,omitempty"`
+	// Profile is a compose profile to activate when restarting a Docker
+	// service, passed to compose as --profile. It must be declared in the
+	// project's compose files.
+	Profile string `json:"profile,omitempty"`
+	// Timeout overrides the action's timeout in seconds, up to maxActionTimeout.
+	Timeout int `json:"timeout,omitempty"`
+	// ExpectedContainerID is the ID of the Docker container the client means
+	// to act on, from the container_id of the services list. If the container
+	// name now belongs to another container, the action is refused with a
+	// TargetChangedError.
+	ExpectedContainerID string `json:"expected_container_id,omitempty"`
+}
+
+// maxActionTimeout is the longest timeout a request may ask for.
+const maxActionTimeout = 30 * time.Minute
+
+// isSelfService reports whether the action targets the dashboard itself.
+func isSelfService(cfg *config.Config, req ServiceActionRequest) bool {
+	localHostName := "localhost"
+	if cfg != nil {
+		localHostName = cfg.GetLocalHostName()
+	}
+	return selfdetect.Get().Matches(req.Source, req.Host, req.ContainerName, localHostName)
 }
 
 // isServiceReadOnly checks if a service is configured as read-only.
-// For systemd services, this is controlled by the :ro suffix in config.
+// For systemd services, this comes from the service spec in config.
 func isServiceReadOnly(cfg *config.Config, host, serviceName, source string) bool {
 	if cfg == nil {
 		return false
@@ -859,19 +1749,17 @@ func isServiceReadOnly(cfg *config.Config, host, serviceName, source string) boo
 		if hostCfg == nil {
 			return false
 		}
-		entries := hostCfg.GetSystemdServiceEntries()
-		for _, entry := range entries {
-			if entry.Name == serviceName {
-				return entry.ReadOnly
-			}
-		}
+		spec, ok := hostCfg.GetServiceSpec(serviceName)
+		return ok && spec.ReadOnly
 	}
 
 	return false
 }
 
 // ServiceActionHandler handles POST /api/services/action requests for start/stop/restart.
-// It streams status updates via SSE.
+// It streams status updates via SSE. With ?dry_run=true the action is
+// validated and its steps are streamed without running them, completing with
+// "dry-run".
 func ServiceActionHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -886,32 +1774,592 @@ func ServiceActionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "Invalid dry_run value", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Parse request body
 	var req ServiceActionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	cfg := configSource()
+	if err := req.Validate(cfg); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if req.Profile != "" && action != "restart" {
+		http.Error(w, "A profile can only be given to restart", http.StatusBadRequest)
+		return
+	}
+
+	// Check user permissions
+	user := auth.GetUserFromContext(r.Context())
+	if user != nil && !user.CanControlService(req.Host, req.ServiceName) {
+		http.Error(w, "Access denied: you do not have permission to control this service", http.StatusForbidden)
+		return
+	}
+
+	// Hidden services can only be controlled from the admin view
+	if !canSeeHidden(user) && serviceHidden(r.Context(), cfg, req.Source, req.Host, req.ContainerName) {
+		http.Error(w, "Access denied: you do not have permission to control this service", http.StatusForbidden)
+		return
+	}
+
+	// Check if service is read-only (blocks ALL users, including admins)
+	if isServiceReadOnly(cfg, req.Host, req.ServiceName, req.Source) {
+		http.Error(w, "This service is read-only: start/stop/restart actions are disabled", http.StatusForbidden)
+		return
+	}
+
+	// A Docker container name may have been taken over by a new container
+	// since the client listed it
+	var containerID string
+	if req.Source == "docker" {
+		var err error
+		if containerID, err = actionTarget(r.Context(), cfg, req); err != nil {
+			writeActionTargetError(w, err)
+			return
+		}
+		if req.Project, err = actionProject(r.Context(), cfg, req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+
+	// Acting on the dashboard itself kills this request, so it must be asked for explicitly
+	isSelf := isSelfService(cfg, req)
+	if isSelf && !req.ConfirmSelf && !dryRun {
+		http.Error(w, "This service is the dashboard itself: set confirm_self to "+action+" it", http.StatusConflict)
+		return
+	}
+
+	r, done, ok := trackStream(w, r, req.Host+"/"+req.ServiceName)
+	if !ok {
+		return
+	}
+	defer done()
+
+	// Set headers for SSE
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// Helper to send SSE events
+	sendEvent := func(eventType, message string) {
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, message)
+		flusher.Flush()
+	}
+
+	if dryRun {
+		sendEvent("status", fmt.Sprintf("Dry run of %s on %s: nothing will be changed", action, req.ServiceName))
+	} else {
+		// Record the stream so it can be read back if nobody was watching
+		recorder := actionHistory.Begin(req.Host, req.ServiceName, req.Source, action, actionOwner(r.Context()))
+		defer recorder.Close()
+		if containerID != "" {
+			recorder.SetContainerID(containerID)
+		}
+		sendEvent = recorder.Wrap(sendEvent)
+
+		sendEvent("status", fmt.Sprintf("Starting %s action on %s...", action, req.ServiceName))
+	}
+
+	planner := actionPlanners[req.Source]
+	if planner == nil {
+		if _, ok := sourceRegistry.Lookup(req.Source); !ok {
+			sendEvent("error", "Unknown service source: "+req.Source)
+			sendEvent("complete", "failed")
+			return
+		}
+		planner = planProviderAction
+	}
+
+	// Bound the action so a hung host cannot hold the request open indefinitely
+	timeout := actionTimeout(cfg, req.Source, action)
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+		if timeout > maxActionTimeout {
+			sendEvent("warning", fmt.Sprintf("A timeout of %s is longer than the maximum; using %s", timeout, maxActionTimeout))
+			timeout = maxActionTimeout
+		}
+	}
+	parent := r.Context()
+	onProxy := !isSelf && action != "start" && actionOnProxy(r, cfg, req)
+	switch {
+	case isSelf && dryRun:
+		sendEvent("warning", fmt.Sprintf("%s is the dashboard itself; a real %s needs confirm_self and will drop the connection", req.ServiceName, action))
+	case isSelf:
+		// The connection drops partway through, which must not cancel the action
+		log.Printf("Service action on the dashboard itself: action=%s service=%s source=%s user=%s",
+			action, req.ContainerName, req.Source, actionOwner(r.Context()))
+		sendEvent("warning", fmt.Sprintf("%s is the dashboard itself; the connection will drop once it goes down", req.ServiceName))
+		parent = context.WithoutCancel(parent)
+	case onProxy && dryRun:
+		sendEvent("warning", fmt.Sprintf("This connection goes through %s; a real %s will interrupt it", req.ServiceName, action))
+	case onProxy:
+		// Likewise for the reverse proxy the connection goes through
+		log.Printf("Service action on the proxy of its own request: action=%s service=%s source=%s user=%s",
+			action, req.ContainerName, req.Source, actionOwner(r.Context()))
+		sendEvent("warning", fmt.Sprintf("This connection goes through %s, so the %s will interrupt it; the %s will continue in the background", req.ServiceName, action, action))
+		parent = context.WithoutCancel(parent)
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+	if !dryRun {
+		// Let an administrator cancel it
+		var done func()
+		ctx, done = inFlight.start(ctx, req.Host, req.ServiceName)
+		defer done()
+	}
+
+	plan, err := planner(ctx, cfg, req, action, sendEvent)
+	if err == nil {
+		if dryRun {
+			err = describePlan(ctx, req, plan, sendEvent)
+		} else {
+			intents, _ := stateTracker.(IntentRecorder)
+			if intents != nil {
+				intents.ExpectAction(req.Host, req.ServiceName, action, actionOwner(r.Context()))
+			}
+			err = executePlan(ctx, req, action, plan, executor, sendEvent)
+			if err != nil && intents != nil {
+				intents.CancelExpectedAction(req.Host, req.ServiceName)
+			}
+		}
+		plan.close()
+	}
+	if cause := context.Cause(ctx); err != nil && errors.Is(cause, errActionCancelled) {
+		log.Printf("Service action cancelled: action=%s service=%s source=%s host=%s: %v",
+			action, req.ServiceName, req.Source, req.Host, cause)
+		sendEvent("error", cause.Error())
+		sendEvent("complete", "cancelled")
+		return
+	}
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("%w (no response after %s)", services.NewProviderError(req.Source, req.Host, ctx.Err()), timeout)
+	}
+
+	if err != nil {
+		log.Printf("Service action failed: action=%s service=%s source=%s host=%s error=%v",
+			action, req.ServiceName, req.Source, req.Host, err)
+		sendEvent("error", err.Error())
+		sendEvent("complete", "failed")
+		return
+	}
+
+	if dryRun {
+		sendEvent("complete", "dry-run")
+		return
+	}
+	sendEvent("status", fmt.Sprintf("Action '%s' completed successfully", action))
+	if containerID != "" {
+		// Name the container acted on, for the client's own records
+		sendEvent("container", containerID)
+	}
+	sendEvent("complete", "success")
+}
+
+// ActionHistoryHandler handles GET /api/services/{host}/{name}/actions requests.
+// Returns the recent actions on a service, newest first, without their output.
+func ActionHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	host, name := r.PathValue("host"), r.PathValue("name")
+	user := auth.GetUserFromContext(r.Context())
+	if user != nil && !user.CanAccessService(host, name) {
+		http.Error(w, "Access denied: you do not have permission to view this service", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(actionHistory.List(host, name))
+}
+
+// ServiceStatsHandler handles GET /api/services/{host}/{name}/stats requests.
+// Returns the recent CPU and memory samples of a container, oldest first.
+func ServiceStatsHandler(w http.ResponseWriter, r *http.Request) {
+	host, name := r.PathValue("host"), r.PathValue("name")
+	user := auth.GetUserFromContext(r.Context())
+	if user != nil && !user.CanAccessService(host, name) {
+		http.Error(w, "Access denied: you do not have permission to view this service", http.StatusForbidden)
+		return
+	}
+
+	tracker, _ := stateTracker.(StatsTracker)
+	if tracker == nil {
+		http.Error(w, "Stats are not sampled on this host", http.StatusNotFound)
+		return
+	}
+	samples, ok := tracker.Stats(host, name)
+	if !ok {
+		http.Error(w, "Stats are not sampled on this host", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(samples)
+}
+
+// failureReporter is implemented by services that can tell why they failed,
+// such as systemd units.
+type failureReporter interface {
+	Failure(ctx context.Context) (*services.FailureInfo, error)
+}
+
+// ServiceFailureHandler handles GET /api/services/{host}/{name}/failure requests.
+// Returns why a failed unit failed: its result, restart count and the last
+// lines its failed run logged, or null if it isn't failed. Since it includes
+// logs, it takes the logs permission, and the lines are redacted like the
+// log streams. The source query parameter picks the provider (default
+// "systemd"); systemd units must be configured on the host.
+func ServiceFailureHandler(w http.ResponseWriter, r *http.Request) {
+	host, name := r.PathValue("host"), r.PathValue("name")
+	user := auth.GetUserFromContext(r.Context())
+	if user != nil && !user.CanViewLogs(host, name) {
+		http.Error(w, "Access denied: you do not have permission to view logs for this service", http.StatusForbidden)
+		return
+	}
+	cfg := configSource()
+	if err := validateHost(cfg, host); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if err := validateName("name", name, true); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if source := r.URL.Query().Get("source"); source == "" || source == "systemd" {
+		if _, ok := hostOrLocal(cfg, host).GetServiceSpec(name); !ok {
+			http.Error(w, fmt.Sprintf("%s is not a configured unit on host %s", name, host), http.StatusNotFound)
+			return
+		}
+	}
+
+	svc, reg, closeProvider, ok := pathService(w, r, "systemd")
+	if !ok {
+		return
+	}
+	defer closeProvider()
+	reporter, ok := svc.(failureReporter)
+	if !ok {
+		http.Error(w, "Failure details are not available for "+reg.Source+" services", http.StatusNotFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), cfg.GetServicesTimeout())
+	defer cancel()
+	failure, err := reporter.Failure(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get failure details: %v", err), http.StatusBadGateway)
+		return
+	}
+	redactFailure(failure, cfg.LogRedactor())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(failure)
+}
+
+// redactFailure redacts the log lines of a failure like those of a log
+// stream. Safe to call with a nil failure or redactor.
+func redactFailure(failure *services.FailureInfo, r *redact.Redactor) {
+	if failure == nil {
+		return
+	}
+	for i, line := range failure.Logs {
+		failure.Logs[i] = r.Line(line)
+	}
+}
+
+// ActionOutputHandler handles GET /api/services/{host}/{name}/actions/{id}/output requests.
+// Returns a recorded action with every event it streamed. Since the output
+// holds what the service's commands printed, it takes the logs permission,
+// and the lines are redacted like the log streams.
+func ActionOutputHandler(w http.ResponseWriter, r *http.Request) {
+	host, name := r.PathValue("host"), r.PathValue("name")
+	user := auth.GetUserFromContext(r.Context())
+	if user != nil && !user.CanViewLogs(host, name) {
+		http.Error(w, "Access denied: you do not have permission to view logs for this service", http.StatusForbidden)
+		return
+	}
+
+	record, ok := actionHistory.Get(host, name, r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Action not found", http.StatusNotFound)
+		return
+	}
+	redactor := configSource().LogRedactor()
+	for i, line := range record.Lines {
+		record.Lines[i].Message = redactor.Line(line.Message)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(record)
+}
+
+// RecreateRequest represents the request body for recreating a container with
+// changed environment variables.
+type RecreateRequest struct {
+	ContainerName string            `json:"container_name"`
+	EnvOverrides  map[string]string `json:"env_overrides"`
+}
+
+// ConnectionsHandler handles GET /api/connections requests.
+// Returns the open SSE streams, oldest first. Only administrators may list them.
+func ConnectionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil || !user.IsAdmin {
+		http.Error(w, "Access denied: administrator privileges required to list connections", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(streamRegistry.List())
+}
+
+// CloseConnectionHandler handles DELETE /api/connections/{id} requests.
+// It closes an open SSE stream from the server side. Only administrators may close streams.
+func CloseConnectionHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil || !user.IsAdmin {
+		http.Error(w, "Access denied: administrator privileges required to close connections", http.StatusForbidden)
+		return
+	}
+
+	id := r.PathValue("id")
+	if !streamRegistry.Cancel(id) {
+		http.Error(w, "Connection not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("Admin %s closed stream %s from %s", user.Email, id, realip.FromRequest(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// NetworksHandler handles GET /api/networks requests.
+// Returns the Docker networks on a host with the services attached to each.
+// Only administrators may list them since they include container IP addresses.
+// Docker is only read on the local host, which is also the default.
+func NetworksHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil || !user.IsAdmin {
+		http.Error(w, "Access denied: administrator privileges required to list networks", http.StatusForbidden)
+		return
+	}
+
+	cfg := configSource()
+	localHostName := "localhost"
+	if cfg != nil {
+		localHostName = cfg.GetLocalHostName()
+	}
+	hostName := r.URL.Query().Get("host")
+	if hostName == "" {
+		hostName = localHostName
+	}
+	if !hostOrLocal(cfg, hostName).IsLocal() {
+		http.Error(w, fmt.Sprintf("Docker networks are only available for the local host, not %s", hostName), http.StatusBadRequest)
+		return
+	}
+
+	dockerProvider, err := docker.NewProvider(hostName)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create Docker provider: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer dockerProvider.Close()
+
+	networks, err := dockerProvider.GetNetworks(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list networks: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(networks)
+}
+
+// RecreateHandler handles POST /api/services/recreate requests.
+// It replaces a Docker container with a copy whose environment has the given
+// overrides applied, streaming progress via SSE. This bypasses compose, so the
+// new container is marked as drifted until the project is next brought up.
+// Only administrators may recreate containers.
+func RecreateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil || !user.IsAdmin {
+		http.Error(w, "Access denied: administrator privileges required to recreate containers", http.StatusForbidden)
+		return
+	}
+
+	var req RecreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.ContainerName == "" {
+		http.Error(w, "container_name is required", http.StatusBadRequest)
+		return
+	}
+	if err := docker.ValidateEnvOverrides(req.EnvOverrides); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r, done, ok := trackStream(w, r, req.ContainerName)
+	if !ok {
+		return
+	}
+	defer done()
+
+	// Set headers for SSE
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sendEvent := func(eventType, message string) {
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, message)
+		flusher.Flush()
+	}
+
+	// Only variable names are logged; values may be secrets
+	keys := make([]string, 0, len(req.EnvOverrides))
+	for key := range req.EnvOverrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	owner := actionOwner(r.Context())
+	log.Printf("Audit: user=%s ip=%s action=recreate container=%s env=%s", owner, realip.FromRequest(r), req.ContainerName, strings.Join(keys, ","))
+
+	cfg := configSource()
+	ctx, cancel := context.WithTimeout(r.Context(), cfg.GetActionTimeout())
+	defer cancel()
+
+	err := recreateContainer(ctx, cfg, req, sendEvent)
+	if err != nil {
+		log.Printf("Audit: user=%s action=recreate container=%s result=failed error=%v", owner, req.ContainerName, err)
+		sendEvent("error", err.Error())
+		sendEvent("complete", "failed")
+		return
+	}
+
+	log.Printf("Audit: user=%s action=recreate container=%s result=success", owner, req.ContainerName)
+	log.Printf("Warning: container %s was recreated outside compose and no longer matches its compose file", req.ContainerName)
+	sendEvent("warning", fmt.Sprintf("%s was recreated outside compose and is marked as drifted until its project is next brought up with compose", req.ContainerName))
+	sendEvent("complete", "success")
+}
+
+// CleanupRequest represents a request to remove orphaned containers.
+type CleanupRequest struct {
+	// Confirm removes the listed containers; without it the orphans are only listed.
+	Confirm bool `json:"confirm"`
+	// Containers are the IDs, as listed, of the orphans to remove.
+	Containers []string `json:"containers"`
+}
+
+// orphanCleaner finds and removes orphaned containers.
+type orphanCleaner interface {
+	FindOrphans(ctx context.Context) ([]docker.OrphanedContainer, error)
+	RemoveOrphans(ctx context.Context, ids []string, report func(docker.OrphanedContainer, error)) error
+}
+
+// newOrphanCleaner returns the cleaner for the local Docker host and a
+// function that releases it. It is a variable so tests can replace it.
+var newOrphanCleaner = func(cfg *config.Config) (orphanCleaner, func(), error) {
+	localHostName := "localhost"
+	if cfg != nil {
+		localHostName = cfg.GetLocalHostName()
+	}
+	dockerProvider, err := docker.NewProvider(localHostName)
+	if err != nil {
+		return nil, func() {}, fmt.Errorf("failed to create Docker provider: %w", err)
+	}
+	if cfg != nil {
+		if host := cfg.GetHostByName(localHostName); host != nil {
+			dockerProvider.SetComposeRoots(host.DockerComposeRoots, host.DockerComposeRootsGlob)
+		}
+	}
+	return dockerProvider, func() { dockerProvider.Close() }, nil
+}
+
+// CleanupHandler handles POST /api/services/cleanup requests.
+// Without confirm it returns the orphaned containers: stopped compose
+// containers whose project directory no longer exists. With confirm it
+// removes the containers given by ID, streaming each result via SSE.
+// Containers that are no longer orphaned are skipped. Only administrators
+// may clean up containers.
+func CleanupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil || !user.IsAdmin {
+		http.Error(w, "Access denied: administrator privileges required to remove containers", http.StatusForbidden)
+		return
+	}
+
+	var req CleanupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Confirm && len(req.Containers) == 0 {
+		http.Error(w, "containers is required with confirm: list the orphaned containers first and pass the IDs to remove", http.StatusBadRequest)
+		return
+	}
+
+	cfg := configSource()
+	cleaner, closeCleaner, err := newOrphanCleaner(cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer closeCleaner()
 
-	// Check user permissions
-	user := auth.GetUserFromContext(r.Context())
-	if user != nil && !user.CanAccessService(req.Host, req.ServiceName) {
-		http.Error(w, "Access denied: you do not have permission to control this service", http.StatusForbidden)
+	if !req.Confirm {
+		orphans, err := cleaner.FindOrphans(r.Context())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to find orphaned containers: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if orphans == nil {
+			orphans = []docker.OrphanedContainer{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(orphans)
 		return
 	}
 
-	// Check if service is read-only (blocks ALL users, including admins)
-	cfg := config.Get()
-	if isServiceReadOnly(cfg, req.Host, req.ServiceName, req.Source) {
-		http.Error(w, "This service is read-only: start/stop/restart actions are disabled", http.StatusForbidden)
+	r, done, ok := trackStream(w, r, "orphaned containers")
+	if !ok {
 		return
 	}
+	defer done()
 
 	// Set headers for SSE
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -919,109 +2367,326 @@ func ServiceActionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := r.Context()
-
-	// Helper to send SSE events
 	sendEvent := func(eventType, message string) {
 		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, message)
 		flusher.Flush()
 	}
 
-	sendEvent("status", fmt.Sprintf("Starting %s action on %s...", action, req.ServiceName))
+	owner := actionOwner(r.Context())
+	log.Printf("Audit: user=%s ip=%s action=cleanup containers=%s", owner, realip.FromRequest(r), strings.Join(req.Containers, ","))
 
-	var err error
+	ctx, cancel := context.WithTimeout(r.Context(), cfg.GetActionTimeout())
+	defer cancel()
 
-	if req.Source == "docker" {
-		err = handleDockerAction(ctx, cfg, req, action, sendEvent)
-	} else if req.Source == "systemd" {
-		err = handleSystemdAction(ctx, cfg, req, action, sendEvent)
-	} else if req.Source == "traefik" {
-		err = handleTraefikAction(ctx, cfg, req, action, sendEvent)
-	} else if req.Source == "homeassistant" || req.Source == "homeassistant-addon" {
-		err = handleHomeAssistantAction(ctx, cfg, req, action, sendEvent)
-	} else {
-		sendEvent("error", "Unknown service source: "+req.Source)
+	failed := false
+	err = cleaner.RemoveOrphans(ctx, req.Containers, func(orphan docker.OrphanedContainer, err error) {
+		switch {
+		case errors.Is(err, docker.ErrNotOrphaned):
+			log.Printf("Audit: user=%s action=cleanup container=%s result=skipped", owner, orphan.ID)
+			sendEvent("warning", fmt.Sprintf("Skipped %s: %v", orphan.ID, err))
+		case err != nil:
+			failed = true
+			log.Printf("Audit: user=%s action=cleanup container=%s id=%s result=failed error=%v", owner, orphan.ContainerName, orphan.ID, err)
+			sendEvent("error", fmt.Sprintf("Failed to remove %s: %v", orphan.ContainerName, err))
+		default:
+			log.Printf("Audit: user=%s action=cleanup container=%s id=%s project=%s result=success", owner, orphan.ContainerName, orphan.ID, orphan.Project)
+			sendEvent("status", fmt.Sprintf("Removed %s (%s/%s, %s no longer exists)", orphan.ContainerName, orphan.Project, orphan.Service, orphan.WorkingDir))
+		}
+	})
+	if err != nil {
+		log.Printf("Audit: user=%s action=cleanup result=failed error=%v", owner, err)
+		sendEvent("error", err.Error())
 		sendEvent("complete", "failed")
 		return
 	}
-
-	if err != nil {
-		log.Printf("Service action failed: action=%s service=%s source=%s host=%s error=%v",
-			action, req.ServiceName, req.Source, req.Host, err)
-		sendEvent("error", err.Error())
+	if failed {
 		sendEvent("complete", "failed")
 		return
 	}
-
-	sendEvent("status", fmt.Sprintf("Action '%s' completed successfully", action))
 	sendEvent("complete", "success")
 }
 
-// handleDockerAction performs Docker container actions.
-// For restart, it uses docker-compose down/up instead of simple restart.
-func handleDockerAction(ctx context.Context, cfg *config.Config, req ServiceActionRequest, action string, sendEvent func(string, string)) error {
+// recreateContainer recreates a local Docker container while holding its compose project lock.
+func recreateContainer(ctx context.Context, cfg *config.Config, req RecreateRequest, sendEvent func(string, string)) error {
 	localHostName := "localhost"
 	if cfg != nil {
 		localHostName = cfg.GetLocalHostName()
 	}
 
-	// For restart, use docker-compose down/up
-	if action == "restart" {
-		return handleDockerComposeRestart(ctx, cfg, req, sendEvent)
-	}
-
-	// For start/stop, use Docker API
 	dockerProvider, err := docker.NewProvider(localHostName)
 	if err != nil {
 		return fmt.Errorf("failed to create Docker provider: %w", err)
 	}
 	defer dockerProvider.Close()
 
+	project, err := dockerProvider.ComposeProject(ctx, req.ContainerName)
+	if err != nil {
+		return err
+	}
+
+	release, err := acquireProjectLock(ctx, ServiceActionRequest{Host: localHostName, Project: project}, "recreate", sendEvent)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return dockerProvider.RecreateWithEnv(ctx, req.ContainerName, req.EnvOverrides, func(msg string) {
+		sendEvent("status", msg)
+	})
+}
+
+// actionPlanners maps a service source to the function that plans its
+// actions, for sources that need more than their provider's Start/Stop/Restart.
+// Other registered sources use planProviderAction.
+var actionPlanners = map[string]actionPlanner{
+	"docker":              planDockerAction,
+	"traefik":             planTraefikAction,
+	"homeassistant":       planHomeAssistantAction,
+	"homeassistant-addon": planHomeAssistantAction,
+}
+
+// actionTimeout returns the deadline for an action on a service from the given source.
+// Docker restarts go through docker compose down/up, which gets a longer budget.
+func actionTimeout(cfg *config.Config, source, action string) time.Duration {
+	if source == "docker" && action == "restart" {
+		return cfg.GetComposeTimeout()
+	}
+	return cfg.GetActionTimeout()
+}
+
+// planDockerAction plans Docker container actions.
+// For restart, it uses docker-compose down/up instead of simple restart.
+func planDockerAction(ctx context.Context, cfg *config.Config, req ServiceActionRequest, action string, sendEvent func(string, string)) (*actionPlan, error) {
+	// For restart, use docker-compose down/up. The dashboard's own container
+	// is restarted in place instead: compose down would kill this process
+	// before it could bring the project back up.
+	if action == "restart" && !isSelfService(cfg, req) {
+		return planDockerComposeRestart(ctx, cfg, req, sendEvent)
+	}
+
+	// For start/stop (and restarting the dashboard's own container), use Docker API
+	return planDockerContainerAction(cfg, req, action)
+}
+
+// planDockerContainerAction plans an action on a container through the Docker API.
+func planDockerContainerAction(cfg *config.Config, req ServiceActionRequest, action string) (*actionPlan, error) {
+	localHostName := "localhost"
+	if cfg != nil {
+		localHostName = cfg.GetLocalHostName()
+	}
+
+	dockerProvider, err := docker.NewProvider(localHostName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker provider: %w", err)
+	}
+
 	svc, err := dockerProvider.GetService(req.ContainerName)
 	if err != nil {
-		return fmt.Errorf("failed to get service: %w", err)
+		dockerProvider.Close()
+		return nil, fmt.Errorf("failed to get service: %w", err)
 	}
 
-	sendEvent("status", fmt.Sprintf("Executing %s on container %s...", action, req.ContainerName))
+	plan := &actionPlan{locked: true, verify: verifyService(svc)}
+	plan.onClose(func() { dockerProvider.Close() })
+	plan.add(fmt.Sprintf("docker %s %s", action, req.ContainerName), func(ctx context.Context, sendEvent func(string, string)) error {
+		sendEvent("status", fmt.Sprintf("Executing %s on container %s...", action, req.ContainerName))
+		if err := runServiceAction(ctx, svc, action); err != nil {
+			return fmt.Errorf("failed to %s container: %w", action, err)
+		}
+		return nil
+	})
+	return plan, nil
+}
 
+// runServiceAction calls the Start, Stop or Restart method of svc.
+func runServiceAction(ctx context.Context, svc services.Service, action string) error {
 	switch action {
 	case "start":
-		err = svc.Start(ctx)
+		return svc.Start(ctx)
 	case "stop":
-		err = svc.Stop(ctx)
+		return svc.Stop(ctx)
+	case "restart":
+		return svc.Restart(ctx)
 	}
+	return fmt.Errorf("unknown action: %s", action)
+}
 
-	if err != nil {
-		return fmt.Errorf("failed to %s container: %w", action, err)
+// verifyService returns a plan check that looks svc up and describes its state.
+func verifyService(svc services.Service) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		info, err := svc.GetInfo(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to find %s: %w", svc.GetName(), err)
+		}
+		return fmt.Sprintf("Found %s (%s)", svc.GetName(), info.State), nil
 	}
+}
 
-	return nil
+// acquireProjectLock takes the compose project lock for a Docker action,
+// reporting progress through sendEvent while another operation holds it.
+// Containers without a project are not locked.
+func acquireProjectLock(ctx context.Context, req ServiceActionRequest, action string, sendEvent func(string, string)) (func(), error) {
+	project := config.NormalizeProjectName(req.Project)
+	if project == "" {
+		return func() {}, nil
+	}
+
+	return locks.AcquireProject(ctx, req.Host, project, action, actionOwner(ctx), func(holder locks.Holder, elapsed time.Duration) {
+		sendEvent("status", fmt.Sprintf("Waiting for in-progress operation: %s, %s elapsed", holder, elapsed.Round(time.Second)))
+	})
+}
+
+// actionOwner names the user performing an action, for lock holders and audit logs.
+func actionOwner(ctx context.Context) string {
+	owner := "dashboard"
+	if user := auth.GetUserFromContext(ctx); user != nil {
+		owner = user.Name
+		if owner == "" {
+			owner = user.Email
+		}
+	}
+	return owner
+}
+
+// trackStream registers an SSE stream on target with the stream registry. It
+// returns r with a context that is also canceled when an admin closes the
+// stream, and a done function the handler must defer. Without auth, streams
+// are counted per client IP. If a stream limit is reached it responds with 429
+// and ok is false.
+func trackStream(w http.ResponseWriter, r *http.Request, target string) (*http.Request, func(), bool) {
+	client := realip.FromRequest(r)
+	owner := client
+	if user := auth.GetUserFromContext(r.Context()); user != nil {
+		owner = user.Email
+		if owner == "" {
+			owner = user.ID
+		}
+	}
+
+	ctx, done, err := streamRegistry.Register(r.Context(), streams.Info{
+		User:     owner,
+		Client:   client,
+		Endpoint: r.URL.Path,
+		Target:   target,
+	})
+	if err != nil {
+		log.Printf("Rejected stream: user=%s ip=%s endpoint=%s target=%s: %v", owner, client, r.URL.Path, target, err)
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return r, nil, false
+	}
+	return r.WithContext(ctx), done, true
 }
 
-// handleDockerComposeRestart performs docker-compose down/up for a service.
-func handleDockerComposeRestart(ctx context.Context, cfg *config.Config, req ServiceActionRequest, sendEvent func(string, string)) error {
+// planDockerComposeRestart plans docker-compose down/up for a service. It
+// runs in the directory the project was brought up from if that is visible
+// here, and otherwise looks for the project in the compose roots. Services
+// of a remote host are restarted over SSH (see planRemoteComposeRestart).
+func planDockerComposeRestart(ctx context.Context, cfg *config.Config, req ServiceActionRequest, sendEvent func(string, string)) (*actionPlan, error) {
 	if cfg == nil {
-		return fmt.Errorf("configuration not loaded")
+		return nil, fmt.Errorf("configuration not loaded")
+	}
+	if host := hostOrLocal(cfg, req.Host); !host.IsLocal() {
+		return planRemoteComposeRestart(ctx, host, req, sendEvent)
+	}
+
+	composeRoot := composeWorkingDir(ctx, cfg, req.ContainerName)
+	if composeRoot == "" || !config.HasComposeFile(composeRoot) {
+		composeRoot = findComposeRoot(cfg, req.Project)
+	}
+	if composeRoot == "" {
+		if req.Profile != "" {
+			return nil, fmt.Errorf("cannot restart with profile %q: the compose project of %s was not found", req.Profile, req.ServiceName)
+		}
+		// Fall back to simple docker restart if we can't find compose root
+		sendEvent("status", "Could not find docker-compose root, falling back to simple restart...")
+		return planDockerContainerAction(cfg, req, "restart")
+	}
+
+	sendEvent("status", fmt.Sprintf("Found compose root: %s", composeRoot))
+	profileArgs, err := composeProfileArgs(composeRoot, req.Profile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Run docker-compose down for the specific service. Failure is only
+	// reported, since the service might not be running.
+	down := settleAfter(composeStep(composeRoot, fmt.Sprintf("Running docker compose down for %s...", req.ServiceName), true, slices.Concat(profileArgs, []string{"down", req.ServiceName})...))
+
+	// Then docker-compose up for the specific service
+	up := composeStep(composeRoot, fmt.Sprintf("Running docker compose up -d for %s...", req.ServiceName), false, slices.Concat(profileArgs, []string{"up", "-d", req.ServiceName})...)
+
+	return &actionPlan{locked: true, steps: []plannedStep{down, up}}, nil
+}
+
+// composeProfileArgs returns the compose flags that activate profile in the
+// compose project in dir, after checking that its compose file has the
+// profile. An empty profile activates none.
+func composeProfileArgs(dir, profile string) ([]string, error) {
+	if profile == "" {
+		return nil, nil
+	}
+	file := findComposeFile(dir)
+	if file == "" {
+		return nil, fmt.Errorf("cannot check profile %q: no compose file in %s", profile, dir)
+	}
+	profiles, err := docker.ReadComposeProfiles([]string{file})
+	if err != nil {
+		return nil, fmt.Errorf("cannot check profile %q: %w", profile, err)
+	}
+	if !profiles.Has(profile) {
+		known := "none"
+		if names := profiles.Names(); len(names) > 0 {
+			known = strings.Join(names, ", ")
+		}
+		return nil, fmt.Errorf("unknown profile %q: the profiles of %s are %s", profile, file, known)
+	}
+	return []string{"--profile", profile}, nil
+}
+
+// composeWorkingDir returns the directory a container's compose project was
+// brought up from, or "" if it isn't known (replaced in tests).
+var composeWorkingDir = func(ctx context.Context, cfg *config.Config, containerName string) string {
+	dockerProvider, err := docker.NewProvider(cfg.GetLocalHostName())
+	if err != nil {
+		return ""
+	}
+	defer dockerProvider.Close()
+
+	dir, err := dockerProvider.ComposeWorkingDir(ctx, containerName)
+	if err != nil {
+		log.Printf("Could not read the compose working directory of %s: %v", containerName, err)
+		return ""
 	}
+	return dir
+}
 
-	// Find the compose root for this project
+// findComposeRoot returns the directory of the compose project on the local
+// host, or "" if it can't be found. It searches the host's effective compose
+// roots, including those matched by docker_compose_roots_glob.
+func findComposeRoot(cfg *config.Config, project string) string {
+	// Find the compose root for this project. Its name is the name key of
+	// its compose file or else the directory name, as compose normalizes it
+	project = config.NormalizeProjectName(project)
 	var composeRoot string
 	for _, host := range cfg.Hosts {
 		if !host.IsLocal() {
 			continue
 		}
-		for _, root := range host.DockerComposeRoots {
-			// Check if this root contains the project
-			// Docker Compose project name is typically the directory name
-			// or specified in compose file
-			testPath := filepath.Join(root, req.Project)
-			if _, err := os.Stat(testPath); err == nil {
-				composeRoot = testPath
+		for _, root := range host.ComposeRoots() {
+			// Check if the root itself is the project directory
+			if isComposeProject(root, project) {
+				composeRoot = root
 				break
 			}
-			// Also check if the root itself is the project directory
-			if filepath.Base(root) == req.Project || strings.TrimSuffix(filepath.Base(root), "/") == req.Project {
-				composeRoot = root
+			// Also check the project directories the root contains
+			entries, _ := os.ReadDir(root)
+			for _, entry := range entries {
+				if dir := filepath.Join(root, entry.Name()); entry.IsDir() && isComposeProject(dir, project) {
+					composeRoot = dir
+					break
+				}
+			}
+			if composeRoot != "" {
 				break
 			}
 		}
@@ -1037,7 +2702,7 @@ func handleDockerComposeRestart(ctx context.Context, cfg *config.Config, req Ser
 			if !host.IsLocal() {
 				continue
 			}
-			for _, root := range host.DockerComposeRoots {
+			for _, root := range host.ComposeRoots() {
 				composeFile := findComposeFile(root)
 				if composeFile != "" {
 					composeRoot = filepath.Dir(composeFile)
@@ -1050,77 +2715,19 @@ func handleDockerComposeRestart(ctx context.Context, cfg *config.Config, req Ser
 		}
 	}
 
-	if composeRoot == "" {
-		// Fall back to simple docker restart if we can't find compose root
-		sendEvent("status", "Could not find docker-compose root, falling back to simple restart...")
-		return handleDockerSimpleRestart(ctx, cfg, req, sendEvent)
-	}
-
-	sendEvent("status", fmt.Sprintf("Found compose root: %s", composeRoot))
-
-	// Run docker-compose down for the specific service
-	sendEvent("status", fmt.Sprintf("Running docker compose down for %s...", req.ServiceName))
-	
-	downCmd := exec.CommandContext(ctx, "docker", "compose", "down", req.ServiceName)
-	downCmd.Dir = composeRoot
-	downOutput, err := downCmd.CombinedOutput()
-	if err != nil {
-		// Log but don't fail - service might not be running
-		sendEvent("status", fmt.Sprintf("Down output: %s", strings.TrimSpace(string(downOutput))))
-	} else if len(downOutput) > 0 {
-		sendEvent("status", strings.TrimSpace(string(downOutput)))
-	}
-
-	// Brief pause to ensure cleanup
-	time.Sleep(500 * time.Millisecond)
-
-	// Run docker-compose up for the specific service
-	sendEvent("status", fmt.Sprintf("Running docker compose up -d for %s...", req.ServiceName))
-	
-	upCmd := exec.CommandContext(ctx, "docker", "compose", "up", "-d", req.ServiceName)
-	upCmd.Dir = composeRoot
-	upOutput, err := upCmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("docker compose up failed: %s - %w", strings.TrimSpace(string(upOutput)), err)
-	}
-	if len(upOutput) > 0 {
-		sendEvent("status", strings.TrimSpace(string(upOutput)))
-	}
-
-	return nil
+	return composeRoot
 }
 
-// handleDockerSimpleRestart falls back to Docker API restart if compose is not available.
-func handleDockerSimpleRestart(ctx context.Context, cfg *config.Config, req ServiceActionRequest, sendEvent func(string, string)) error {
-	localHostName := "localhost"
-	if cfg != nil {
-		localHostName = cfg.GetLocalHostName()
-	}
-
-	dockerProvider, err := docker.NewProvider(localHostName)
-	if err != nil {
-		return fmt.Errorf("failed to create Docker provider: %w", err)
-	}
-	defer dockerProvider.Close()
-
-	svc, err := dockerProvider.GetService(req.ContainerName)
-	if err != nil {
-		return fmt.Errorf("failed to get service: %w", err)
-	}
-
-	sendEvent("status", fmt.Sprintf("Restarting container %s...", req.ContainerName))
-	return svc.Restart(ctx)
+// isComposeProject reports whether dir holds the compose project with the
+// normalized name project.
+func isComposeProject(dir, project string) bool {
+	return project != "" && config.ComposeProjectName(dir) == project
 }
 
 // findComposeFile looks for docker-compose.yml or compose.yml in the given directory.
 func findComposeFile(dir string) string {
-	candidates := []string{
-		filepath.Join(dir, "docker-compose.yml"),
-		filepath.Join(dir, "docker-compose.yaml"),
-		filepath.Join(dir, "compose.yml"),
-		filepath.Join(dir, "compose.yaml"),
-	}
-	for _, path := range candidates {
+	for _, name := range config.ComposeFileNames {
+		path := filepath.Join(dir, name)
 		if _, err := os.Stat(path); err == nil {
 			return path
 		}
@@ -1128,65 +2735,55 @@ func findComposeFile(dir string) string {
 	return ""
 }
 
-// handleSystemdAction performs systemd service actions.
-func handleSystemdAction(ctx context.Context, cfg *config.Config, req ServiceActionRequest, action string, sendEvent func(string, string)) error {
-	// Find the host config
-	hostAddress := "localhost"
-	var sshConfig *systemd.SSHConfig
-	var serviceEntry systemd.ServiceEntry
-	serviceEntry.Name = req.ServiceName
+// progressReporter is implemented by services that report the progress of
+// an action as it runs, such as remote systemd units connecting over SSH.
+type progressReporter interface {
+	SetProgress(progress func(message string))
+}
 
-	if cfg != nil {
-		if host := cfg.GetHostByName(req.Host); host != nil {
-			hostAddress = host.Address
-			// Convert SSH config if present
-			if host.SSHConfig != nil {
-				sshConfig = &systemd.SSHConfig{
-					Username: host.SSHConfig.Username,
-					Port:     host.SSHConfig.Port,
-				}
-			}
-			// Look up the service entry to get user information
-			for _, entry := range host.GetSystemdServiceEntries() {
-				if entry.Name == req.ServiceName {
-					serviceEntry = systemd.ServiceEntry{
-						Name:     entry.Name,
-						User:     entry.User,
-						ReadOnly: entry.ReadOnly,
-						Ports:    entry.Ports,
-					}
-					break
-				}
-			}
-		}
+// planProviderAction plans an action through the provider registered for
+// the service's source.
+func planProviderAction(ctx context.Context, cfg *config.Config, req ServiceActionRequest, action string, sendEvent func(string, string)) (*actionPlan, error) {
+	reg, ok := sourceRegistry.Lookup(req.Source)
+	if !ok {
+		return nil, fmt.Errorf("unknown service source: %s", req.Source)
+	}
+	if !reg.Actions {
+		return nil, fmt.Errorf("%s is not supported for %s services", action, req.Source)
 	}
 
-	systemdProvider := systemd.NewProviderWithEntries(req.Host, hostAddress, []systemd.ServiceEntry{serviceEntry}, sshConfig)
-	svc, err := systemdProvider.GetService(req.ServiceName)
+	host := hostOrLocal(cfg, req.Host)
+	provider, closeProvider, err := newProvider(cfg, reg, host)
 	if err != nil {
-		return fmt.Errorf("failed to get service: %w", err)
+		return nil, fmt.Errorf("failed to create %s provider: %w", reg.Source, err)
 	}
-
-	sendEvent("status", fmt.Sprintf("Executing %s on unit %s...", action, req.ServiceName))
-
-	switch action {
-	case "start":
-		err = svc.Start(ctx)
-	case "stop":
-		err = svc.Stop(ctx)
-	case "restart":
-		err = svc.Restart(ctx)
+	if provider == nil {
+		return nil, fmt.Errorf("%s is not configured on host %s", reg.Source, req.Host)
 	}
 
+	svc, err := provider.GetService(req.ServiceName)
 	if err != nil {
-		return fmt.Errorf("failed to %s unit: %w", action, err)
+		closeProvider()
+		return nil, fmt.Errorf("failed to get service: %w", err)
 	}
 
-	return nil
+	plan := &actionPlan{verify: verifyService(svc)}
+	plan.onClose(closeProvider)
+	plan.add(fmt.Sprintf("%s %s on %s (%s)", action, req.ServiceName, host.Name, reg.Source), func(ctx context.Context, sendEvent func(string, string)) error {
+		sendEvent("status", fmt.Sprintf("Executing %s on %s...", action, req.ServiceName))
+		if reporter, ok := svc.(progressReporter); ok {
+			reporter.SetProgress(func(message string) { sendEvent("status", message) })
+		}
+		if err := runServiceAction(ctx, svc, action); err != nil {
+			return fmt.Errorf("failed to %s %s: %w", action, req.ServiceName, err)
+		}
+		return nil
+	})
+	return plan, nil
 }
 
-// handleTraefikAction handles actions for Traefik services (not supported).
-func handleTraefikAction(ctx context.Context, cfg *config.Config, req ServiceActionRequest, action string, sendEvent func(string, string)) error {
+// planTraefikAction handles actions for Traefik services (not supported).
+func planTraefikAction(ctx context.Context, cfg *config.Config, req ServiceActionRequest, action string, sendEvent func(string, string)) (*actionPlan, error) {
 	sendEvent("status", "Traefik services cannot be controlled from this dashboard.")
 	sendEvent("status", "")
 	sendEvent("status", "Traefik services are external services registered in Traefik's")
@@ -1196,63 +2793,169 @@ func handleTraefikAction(ctx context.Context, cfg *config.Config, req ServiceAct
 	sendEvent("status", fmt.Sprintf("To %s this service, please access the host where the", action))
 	sendEvent("status", "actual service is running.")
 
-	return fmt.Errorf("%s is not supported for Traefik services - these are external services managed outside this dashboard", action)
+	return nil, fmt.Errorf("%s is not supported for Traefik services - these are external services managed outside this dashboard", action)
+}
+
+func init() {
+	// Home Assistant Container installs are controlled through the docker provider
+	homeassistant.SetContainerDelegate(func(host *config.HostConfig) (homeassistant.ContainerDelegate, error) {
+		provider, err := docker.NewProvider(host.Name)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	})
+}
+
+// waitForSupervisorJob waits for a Supervisor job to finish, streaming its
+// progress as status events.
+func waitForSupervisorJob(ctx context.Context, haProvider *homeassistant.Provider, jobID string, sendEvent func(string, string)) error {
+	_, err := haProvider.WaitForJob(ctx, jobID, func(job homeassistant.Job) {
+		message := fmt.Sprintf("%s: %.0f%%", job.Name, job.Progress)
+		if job.Stage != "" {
+			message += " (" + job.Stage + ")"
+		}
+		sendEvent("status", message)
+	})
+	if errors.Is(err, homeassistant.ErrJobOutcomeUnknown) {
+		sendEvent("status", "The Supervisor lost track of the job, likely because it restarted; check the result in Home Assistant")
+	}
+	return err
 }
 
-// handleHomeAssistantAction handles actions for Home Assistant services.
-// Supports: homeassistant core (restart only), ha-supervisor (no actions), ha-host (no actions), addon-* (start/stop/restart)
-func handleHomeAssistantAction(ctx context.Context, cfg *config.Config, req ServiceActionRequest, action string, sendEvent func(string, string)) error {
+// planHomeAssistantAction plans actions for Home Assistant services.
+// Supports: homeassistant core (restart only, or start/stop/restart of a local
+// container_name), ha-supervisor (no actions), ha-host (no actions), addon-* (start/stop/restart)
+func planHomeAssistantAction(ctx context.Context, cfg *config.Config, req ServiceActionRequest, action string, sendEvent func(string, string)) (*actionPlan, error) {
 	if cfg == nil {
-		return fmt.Errorf("configuration not loaded")
+		return nil, fmt.Errorf("configuration not loaded")
 	}
 
 	// Find the host config
 	host := cfg.GetHostByName(req.Host)
 	if host == nil {
-		return fmt.Errorf("host not found: %s", req.Host)
+		return nil, fmt.Errorf("host not found: %s", req.Host)
 	}
 
 	if !host.HasHomeAssistant() {
-		return fmt.Errorf("Home Assistant not configured for host: %s", req.Host)
+		return nil, fmt.Errorf("Home Assistant not configured for host: %s", req.Host)
+	}
+
+	// Handle supervisor and host services (no actions supported)
+	if req.ServiceName == "ha-supervisor" {
+		return nil, fmt.Errorf("%s is not supported for Supervisor - it is managed by HAOS", action)
+	}
+	if req.ServiceName == "ha-host" {
+		return nil, fmt.Errorf("%s is not supported for Host - use HAOS interface for host control", action)
+	}
+
+	// Handle core Home Assistant service, which can only be restarted unless
+	// its container can be controlled through Docker
+	isAddon := strings.HasPrefix(req.ServiceName, "addon-")
+	hasContainer := host.HomeAssistant.ContainerName != "" && host.IsLocal()
+	if !isAddon {
+		switch action {
+		case "restart":
+		case "start", "stop":
+			if hasContainer {
+				break
+			}
+			if action == "stop" {
+				sendEvent("status", "Stop is not supported for Home Assistant via this dashboard.")
+				sendEvent("status", "")
+				sendEvent("status", "Stopping Home Assistant would disable home automation.")
+				sendEvent("status", "If you need to stop it, use the HA web UI or CLI:")
+				sendEvent("status", "  ha core stop")
+				return nil, fmt.Errorf("stop is not supported for Home Assistant - use the HA web UI if needed")
+			}
+			sendEvent("status", "Start is not supported for Home Assistant.")
+			sendEvent("status", "")
+			sendEvent("status", "If Home Assistant is down, check the host where it's running.")
+			sendEvent("status", "You may need to SSH into the host or check the hardware.")
+			return nil, fmt.Errorf("start is not supported for Home Assistant - if it's down, check the host")
+
+		default:
+			return nil, fmt.Errorf("unknown action: %s", action)
+		}
 	}
 
 	haProvider, err := homeassistant.NewProvider(host)
 	if err != nil {
-		return fmt.Errorf("failed to create Home Assistant provider: %w", err)
+		return nil, fmt.Errorf("failed to create Home Assistant provider: %w", err)
 	}
 	if haProvider == nil {
-		return fmt.Errorf("Home Assistant provider is nil for host: %s", req.Host)
+		return nil, fmt.Errorf("Home Assistant provider is nil for host: %s", req.Host)
 	}
-	defer haProvider.Close()
+	plan := &actionPlan{}
+	plan.onClose(func() { haProvider.Close() })
 
 	// Handle addon services
-	if strings.HasPrefix(req.ServiceName, "addon-") {
+	if isAddon {
 		if !haProvider.HasSupervisorAPI() {
-			return fmt.Errorf("addon control requires HAOS with Supervisor API access")
+			plan.close()
+			return nil, fmt.Errorf("addon control requires HAOS with Supervisor API access")
 		}
 
 		slug := strings.TrimPrefix(req.ServiceName, "addon-")
-		sendEvent("status", fmt.Sprintf("Executing %s on addon %s...", action, slug))
+		plan.add(fmt.Sprintf("Supervisor API: %s addon %s on %s", action, slug, req.Host), func(ctx context.Context, sendEvent func(string, string)) error {
+			sendEvent("status", fmt.Sprintf("Executing %s on addon %s...", action, slug))
 
-		if err := haProvider.AddonControl(ctx, slug, action); err != nil {
-			return fmt.Errorf("failed to %s addon %s: %w", action, slug, err)
-		}
+			jobID, err := haProvider.AddonControl(ctx, slug, action)
+			if err != nil {
+				return fmt.Errorf("failed to %s addon %s: %w", action, slug, err)
+			}
+			if jobID == "" {
+				sendEvent("status", fmt.Sprintf("Addon %s %s command sent successfully", slug, action))
+				return nil
+			}
 
-		sendEvent("status", fmt.Sprintf("Addon %s %s command sent successfully", slug, action))
-		return nil
+			sendEvent("status", fmt.Sprintf("Supervisor job %s started, waiting for it to finish...", jobID))
+			if err := waitForSupervisorJob(ctx, haProvider, jobID, sendEvent); err != nil {
+				return fmt.Errorf("failed to %s addon %s: %w", action, slug, err)
+			}
+			sendEvent("status", fmt.Sprintf("Addon %s %s completed", slug, action))
+			return nil
+		})
+		return plan, nil
 	}
 
-	// Handle supervisor and host services (no actions supported)
-	if req.ServiceName == "ha-supervisor" {
-		return fmt.Errorf("%s is not supported for Supervisor - it is managed by HAOS", action)
+	// Container installs are controlled like any other container
+	if haProvider.UsesContainer() {
+		containerName := host.HomeAssistant.ContainerName
+		plan.add(fmt.Sprintf("Docker: %s container %s on %s", action, containerName, req.Host), func(ctx context.Context, sendEvent func(string, string)) error {
+			sendEvent("status", fmt.Sprintf("Executing %s on Home Assistant container %s...", action, containerName))
+
+			svc, err := haProvider.GetService("homeassistant")
+			if err != nil {
+				return err
+			}
+			if err := runServiceAction(ctx, svc, action); err != nil {
+				return fmt.Errorf("failed to %s Home Assistant container %s: %w", action, containerName, err)
+			}
+
+			sendEvent("status", fmt.Sprintf("Home Assistant container %s %s command sent successfully", containerName, action))
+			return nil
+		})
+		return plan, nil
 	}
-	if req.ServiceName == "ha-host" {
-		return fmt.Errorf("%s is not supported for Host - use HAOS interface for host control", action)
+	if action != "restart" {
+		// Supervised installs with a container_name still go through the Supervisor
+		if !haProvider.HasSupervisorAPI() {
+			plan.close()
+			return nil, fmt.Errorf("%s is not supported for Home Assistant - container %s could not be reached through Docker", action, host.HomeAssistant.ContainerName)
+		}
+		plan.add(fmt.Sprintf("Supervisor API: %s Home Assistant Core on %s", action, req.Host), func(ctx context.Context, sendEvent func(string, string)) error {
+			sendEvent("status", fmt.Sprintf("Executing %s on Home Assistant Core...", action))
+			if err := haProvider.CoreControl(ctx, action); err != nil {
+				return fmt.Errorf("failed to %s Home Assistant: %w", action, err)
+			}
+			sendEvent("status", fmt.Sprintf("Home Assistant Core %s command sent successfully", action))
+			return nil
+		})
+		return plan, nil
 	}
 
-	// Handle core Home Assistant service
-	switch action {
-	case "restart":
+	plan.add(fmt.Sprintf("Home Assistant API: restart Home Assistant on %s", req.Host), func(ctx context.Context, sendEvent func(string, string)) error {
 		sendEvent("status", "Triggering Home Assistant restart...")
 		sendEvent("status", "")
 		sendEvent("status", "Note: Home Assistant will restart and briefly become unavailable.")
@@ -1266,25 +2969,8 @@ func handleHomeAssistantAction(ctx context.Context, cfg *config.Config, req Serv
 		sendEvent("status", "Restart command sent successfully.")
 		sendEvent("status", "Home Assistant is now restarting...")
 		return nil
-
-	case "start":
-		sendEvent("status", "Start is not supported for Home Assistant.")
-		sendEvent("status", "")
-		sendEvent("status", "If Home Assistant is down, check the host where it's running.")
-		sendEvent("status", "You may need to SSH into the host or check the hardware.")
-		return fmt.Errorf("start is not supported for Home Assistant - if it's down, check the host")
-
-	case "stop":
-		sendEvent("status", "Stop is not supported for Home Assistant via this dashboard.")
-		sendEvent("status", "")
-		sendEvent("status", "Stopping Home Assistant would disable home automation.")
-		sendEvent("status", "If you need to stop it, use the HA web UI or CLI:")
-		sendEvent("status", "  ha core stop")
-		return fmt.Errorf("stop is not supported for Home Assistant - use the HA web UI if needed")
-
-	default:
-		return fmt.Errorf("unknown action: %s", action)
-	}
+	})
+	return plan, nil
 }
 
 // LogFlushRequest represents the request body for flushing logs.
@@ -1316,12 +3002,12 @@ func LogFlushHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.ContainerName == "" {
-		http.Error(w, "container_name is required", http.StatusBadRequest)
+	cfg := configSource()
+	if err := req.Validate(cfg); err != nil {
+		writeRequestError(w, err)
 		return
 	}
 
-	cfg := config.Get()
 	localHostName := "localhost"
 	if cfg != nil {
 		localHostName = cfg.GetLocalHostName()
@@ -1337,12 +3023,16 @@ func LogFlushHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Truncate logs
 	err = dockerProvider.TruncateLogs(r.Context(), req.ContainerName)
+	if errors.Is(err, docker.ErrJournaldLogs) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to flush logs: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("Admin %s flushed logs for container %s", user.Email, req.ContainerName)
+	log.Printf("Admin %s flushed logs for container %s from %s", user.Email, req.ContainerName, realip.FromRequest(r))
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -1351,3 +3041,32 @@ func LogFlushHandler(w http.ResponseWriter, r *http.Request) {
 		"message": fmt.Sprintf("Logs flushed for %s", req.ContainerName),
 	})
 }
+
+// SelfTestHandler handles POST /api/selftest requests.
+// Runs a check against every configured integration and returns the report.
+// Only administrators may run it since it reaches every host.
+func SelfTestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil || !user.IsAdmin {
+		http.Error(w, "Access denied: administrator privileges required to run the self-test", http.StatusForbidden)
+		return
+	}
+
+	cfg := configSource()
+	if cfg == nil {
+		http.Error(w, "Configuration not loaded", http.StatusInternalServerError)
+		return
+	}
+
+	report := selftest.Run(r.Context(), selftest.BuildChecks(cfg), selftest.DefaultTimeout)
+	report.SecretKeys = cfg.SecretKeys()
+	report.ComposeRoots = selftest.ComposeRoots(cfg)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -1,19 +1,29 @@
 package handlers
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"home_server_dashboard/actionhistory"
 	"home_server_dashboard/auth"
 	"home_server_dashboard/config"
+	"home_server_dashboard/resilience"
+	"home_server_dashboard/selfdetect"
 	"home_server_dashboard/services"
 	"home_server_dashboard/services/docker"
+	"home_server_dashboard/streams"
+	"home_server_dashboard/version"
 )
 
 // authUserContextKey is the context key used by auth package for storing user
@@ -115,6 +125,39 @@ func TestServicesHandler_ReturnsJSON(t *testing.T) {
 }
 
 // TestDockerLogsHandler_MissingContainer tests logs handler without container param.
+func TestVersionHandler(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/version", nil)
+	w := httptest.NewRecorder()
+
+	VersionHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got %s", ct)
+	}
+
+	var info version.Info
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if info != version.Get() {
+		t.Errorf("Expected %+v, got %+v", version.Get(), info)
+	}
+}
+
+func TestVersionHandler_MethodNotAllowed(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/version", nil)
+	w := httptest.NewRecorder()
+
+	VersionHandler(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
+
 func TestDockerLogsHandler_MissingContainer(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/api/logs", nil)
 	w := httptest.NewRecorder()
@@ -146,6 +189,36 @@ func TestSystemdLogsHandler_MissingUnit(t *testing.T) {
 	}
 }
 
+// TestSystemdLogsHandler_InvalidFilters tests validation of the boot and priority parameters.
+func TestSystemdLogsHandler_InvalidFilters(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"invalid priority", "priority=error", "emerg, alert, crit, err, warning, notice, info, debug"},
+		{"invalid boot", "boot=yesterday", "invalid boot"},
+		{"future boot", "boot=1", "invalid boot"},
+		{"invalid tail", "tail=lots", "invalid tail"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/logs/systemd?unit=test.service&host=testhost&"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			SystemdLogsHandler(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Status code = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("Body = %q, want it to contain %q", w.Body.String(), tt.want)
+			}
+		})
+	}
+}
+
 // TestSystemdLogsHandler_SSEHeaders tests that SSE headers are set correctly.
 func TestSystemdLogsHandler_SSEHeaders(t *testing.T) {
 	configJSON := `{
@@ -289,6 +362,323 @@ func TestServiceInfoJSON(t *testing.T) {
 	}
 }
 
+// fakeStateTracker returns fixed state-change times keyed by "host:name".
+type fakeStateTracker map[string]time.Time
+
+func (f fakeStateTracker) LastStateChange(host, serviceName string) (time.Time, bool) {
+	t, ok := f[host+":"+serviceName]
+	return t, ok
+}
+
+// fakeExitTracker is a fakeStateTracker that also reports container exits.
+type fakeExitTracker struct {
+	fakeStateTracker
+	exits map[string]int // key: "host:name"
+	at    time.Time
+}
+
+func (f fakeExitTracker) LastExit(host, serviceName string) (int, time.Time, bool) {
+	code, ok := f.exits[host+":"+serviceName]
+	return code, f.at, ok
+}
+
+// TestMergeStateChanges_ExitCodes tests that exits seen by the tracker fill in
+// stopped containers whose listing had no exit code.
+func TestMergeStateChanges_ExitCodes(t *testing.T) {
+	died := time.Date(2025, 3, 2, 8, 30, 0, 0, time.UTC)
+	listed := 0
+
+	svcList := []services.ServiceInfo{
+		{Name: "nginx", Host: "nas", Source: "docker", State: "stopped"},
+		{Name: "redis", Host: "nas", Source: "docker", State: "stopped", ExitCode: &listed},
+		{Name: "app", Host: "nas", Source: "docker", State: "running"},
+	}
+	tracker := fakeExitTracker{
+		fakeStateTracker: fakeStateTracker{},
+		exits:            map[string]int{"nas:nginx": 137, "nas:redis": 1, "nas:app": 1},
+		at:               died,
+	}
+
+	mergeStateChanges(svcList, tracker)
+
+	if svcList[0].ExitCode == nil || *svcList[0].ExitCode != 137 || svcList[0].FinishedAt == nil || !svcList[0].FinishedAt.Equal(died) {
+		t.Errorf("nginx exit = %v at %v, want 137 at %v", svcList[0].ExitCode, svcList[0].FinishedAt, died)
+	}
+	if *svcList[1].ExitCode != 0 {
+		t.Errorf("redis exit = %d, want listed code 0", *svcList[1].ExitCode)
+	}
+	if svcList[2].ExitCode != nil {
+		t.Errorf("running app exit = %d, want nil", *svcList[2].ExitCode)
+	}
+}
+
+// fakeStatsTracker is a fakeStateTracker that also samples the stats of
+// services on one host.
+type fakeStatsTracker struct {
+	fakeStateTracker
+	host    string
+	samples map[string][]services.StatsSample // key: name
+}
+
+func (f fakeStatsTracker) Stats(host, serviceName string) ([]services.StatsSample, bool) {
+	if host != f.host {
+		return nil, false
+	}
+	samples := f.samples[serviceName]
+	if samples == nil {
+		samples = []services.StatsSample{}
+	}
+	return samples, true
+}
+
+// TestMergeStateChanges_Stats tests that Docker services with samples are
+// marked StatsSampled.
+func TestMergeStateChanges_Stats(t *testing.T) {
+	svcList := []services.ServiceInfo{
+		{Name: "nginx", Host: "nas", Source: "docker", State: "running"},
+		{Name: "redis", Host: "nas", Source: "docker", State: "running"},
+		{Name: "nginx", Host: "pi", Source: "docker", State: "running"},
+	}
+	tracker := fakeStatsTracker{
+		fakeStateTracker: fakeStateTracker{},
+		host:             "nas",
+		samples:          map[string][]services.StatsSample{"nginx": {{CPUPercent: 5}}},
+	}
+
+	mergeStateChanges(svcList, tracker)
+
+	for i, want := range []bool{true, false, false} {
+		if svcList[i].StatsSampled != want {
+			t.Errorf("%s on %s StatsSampled = %v, want %v", svcList[i].Name, svcList[i].Host, svcList[i].StatsSampled, want)
+		}
+	}
+}
+
+// fakeProbeReporter is a fakeStateTracker that also reports probe results,
+// keyed by "host:project:name".
+type fakeProbeReporter struct {
+	fakeStateTracker
+	results map[string]services.ProbeResult
+}
+
+func (f fakeProbeReporter) ProbeResult(key services.Key) (services.ProbeResult, bool) {
+	result, ok := f.results[key.Host+":"+key.Project+":"+key.Name]
+	return result, ok
+}
+
+func TestMergeStateChanges_Probes(t *testing.T) {
+	svcList := []services.ServiceInfo{
+		{Name: "nginx", Host: "nas", Source: "docker", Project: "web", State: "running"},
+		{Name: "redis", Host: "nas", Source: "docker", Project: "web", State: "running"},
+		{Name: "app", Host: "nas", Source: "docker", Project: "web", State: "stopped"},
+		{Name: "zunesync.service", Host: "nas", Source: "systemd", Project: "systemd", State: "running"},
+		{Name: "plex", Host: "nas", Source: "docker", Project: "media", State: "running"},
+		// Same name, another project: its probe is its own
+		{Name: "nginx", Host: "nas", Source: "docker", Project: "blog", State: "running"},
+	}
+	tracker := fakeProbeReporter{
+		results: map[string]services.ProbeResult{
+			"nas:web:nginx":         {Status: services.ProbeFailing, Detail: "answered 503 Service Unavailable"},
+			"nas:web:redis":         {Status: services.ProbePassing},
+			"nas:web:app":           {Status: services.ProbeFailing},
+			"nas::zunesync.service": {Status: services.ProbeUnprobeable},
+			"nas:blog:nginx":        {Status: services.ProbePassing},
+		},
+	}
+
+	mergeStateChanges(svcList, tracker)
+
+	for i, want := range []services.State{services.StateUnhealthy, services.StateRunning, services.StateStopped, services.StateRunning, services.StateRunning, services.StateRunning} {
+		if svcList[i].State != want {
+			t.Errorf("%s state = %s, want %s", svcList[i].Name, svcList[i].State, want)
+		}
+	}
+	if svcList[0].Probe == nil || svcList[0].Probe.Detail != "answered 503 Service Unavailable" {
+		t.Errorf("nginx probe = %+v, want the failing result", svcList[0].Probe)
+	}
+	if svcList[4].Probe != nil {
+		t.Errorf("plex probe = %+v, want none", svcList[4].Probe)
+	}
+}
+
+func TestServiceStatsHandler(t *testing.T) {
+	original := stateTracker
+	defer SetStateTracker(original)
+
+	at := time.Date(2025, 3, 2, 8, 30, 0, 0, time.UTC)
+	SetStateTracker(fakeStatsTracker{
+		fakeStateTracker: fakeStateTracker{},
+		host:             "nas",
+		samples:          map[string][]services.StatsSample{"nginx": {{Time: at, CPUPercent: 12.5, MemBytes: 1 << 20}}},
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/services/{host}/{name}/stats", ServiceStatsHandler)
+	get := func(path string, user *auth.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if user != nil {
+			req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, user))
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/api/services/nas/nginx/stats", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d: %s", w.Code, w.Body.String())
+	}
+	if want := `[{"t":"2025-03-02T08:30:00Z","cpu_pct":12.5,"mem_bytes":1048576}]`; strings.TrimSpace(w.Body.String()) != want {
+		t.Errorf("body = %s, want %s", w.Body.String(), want)
+	}
+
+	if body := strings.TrimSpace(get("/api/services/nas/redis/stats", nil).Body.String()); body != "[]" {
+		t.Errorf("service without samples: body = %s, want []", body)
+	}
+	if w := get("/api/services/pi/nginx/stats", nil); w.Code != http.StatusNotFound {
+		t.Errorf("host without sampling: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	user := &auth.User{ID: "user", AllowedServices: map[string][]string{"nas": {"redis"}}}
+	if w := get("/api/services/nas/nginx/stats", user); w.Code != http.StatusForbidden {
+		t.Errorf("service the user can't see: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	SetStateTracker(fakeStateTracker{})
+	if w := get("/api/services/nas/nginx/stats", nil); w.Code != http.StatusNotFound {
+		t.Errorf("tracker without stats: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestServiceFailureHandler(t *testing.T) {
+	registerFakeProvider(t, services.Capabilities{})
+	setupTestConfig(t, `{
+		"hosts": [
+			{"name": "fakehost", "address": "localhost", "systemd_services": ["backup.service"]},
+			{"name": "otherhost", "address": "localhost"}
+		],
+		"log_redaction": {"enabled": true, "rules": [{"name": "memory", "pattern": "memory"}]}
+	}`)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/services/{host}/{name}/failure", ServiceFailureHandler)
+	get := func(path string, user *auth.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if user != nil {
+			req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, user))
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/api/services/fakehost/broken/failure?source=fake", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d: %s", w.Code, w.Body.String())
+	}
+	if want := `{"result":"oom-kill","n_restarts":2,"logs":["out of [REDACTED:memory]"]}`; strings.TrimSpace(w.Body.String()) != want {
+		t.Errorf("body = %s, want %s", w.Body.String(), want)
+	}
+
+	if body := strings.TrimSpace(get("/api/services/fakehost/nginx/failure?source=fake", nil).Body.String()); body != "null" {
+		t.Errorf("service that isn't failed: body = %s, want null", body)
+	}
+	if w := get("/api/services/otherhost/broken/failure?source=fake", nil); w.Code != http.StatusNotFound {
+		t.Errorf("host without the source: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := get("/api/services/fakehost/broken/failure?source=nope", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown source: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := get("/api/services/nowhere/broken/failure?source=fake", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("host that isn't configured: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := get("/api/services/fakehost/x%3Brm%20-rf%20~/failure", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("name with shell characters: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := get("/api/services/fakehost/other.service/failure", nil); w.Code != http.StatusNotFound {
+		t.Errorf("unit that isn't configured: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	user := &auth.User{ID: "user", AllowedServices: map[string][]string{"fakehost": {"nginx"}}}
+	if w := get("/api/services/fakehost/broken/failure?source=fake", user); w.Code != http.StatusForbidden {
+		t.Errorf("service the user can't see: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+// TestMergeStateChanges tests that tracked times fill in only missing values.
+func TestMergeStateChanges(t *testing.T) {
+	providerTime := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	trackedTime := time.Date(2025, 3, 2, 8, 30, 0, 0, time.UTC)
+
+	svcList := []services.ServiceInfo{
+		{Name: "nginx", Host: "nas", Source: "docker", LastStateChange: &providerTime},
+		{Name: "core", Host: "ha", Source: "homeassistant"},
+		{Name: "untracked", Host: "nas", Source: "systemd"},
+	}
+	tracker := fakeStateTracker{
+		"nas:nginx": trackedTime,
+		"ha:core":   trackedTime,
+	}
+
+	mergeStateChanges(svcList, tracker)
+
+	if !svcList[0].LastStateChange.Equal(providerTime) {
+		t.Errorf("nginx LastStateChange = %v, want provider time %v", svcList[0].LastStateChange, providerTime)
+	}
+	if svcList[1].LastStateChange == nil || !svcList[1].LastStateChange.Equal(trackedTime) {
+		t.Errorf("core LastStateChange = %v, want tracked time %v", svcList[1].LastStateChange, trackedTime)
+	}
+	if svcList[2].LastStateChange != nil {
+		t.Errorf("untracked LastStateChange = %v, want nil", svcList[2].LastStateChange)
+	}
+
+	// A nil tracker leaves the list unchanged
+	mergeStateChanges(svcList, nil)
+}
+
+// TestServiceInfoJSON_LastStateChange tests the last_state_change field encoding.
+func TestServiceInfoJSON_LastStateChange(t *testing.T) {
+	since := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	data, _ := json.Marshal(services.ServiceInfo{Name: "nginx", LastStateChange: &since})
+	if !strings.Contains(string(data), `"last_state_change":"2025-03-01T12:00:00Z"`) {
+		t.Errorf("JSON = %s, want last_state_change in RFC 3339", data)
+	}
+
+	data, _ = json.Marshal(services.ServiceInfo{Name: "nginx"})
+	if strings.Contains(string(data), "last_state_change") {
+		t.Errorf("JSON = %s, want last_state_change omitted when unknown", data)
+	}
+}
+
+// TestAcquireProjectLock tests that Docker actions on the same project are serialized.
+func TestAcquireProjectLock(t *testing.T) {
+	noop := func(string, string) {}
+
+	// Containers without a project are not locked
+	release, err := acquireProjectLock(context.Background(), ServiceActionRequest{Host: "nas", ServiceName: "adhoc"}, "restart", noop)
+	if err != nil {
+		t.Fatalf("acquireProjectLock() without project = %v", err)
+	}
+	release()
+
+	req := ServiceActionRequest{Host: "nas", Project: "locktest", ServiceName: "web"}
+	release, err = acquireProjectLock(context.Background(), req, "restart", noop)
+	if err != nil {
+		t.Fatalf("acquireProjectLock() = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, err := acquireProjectLock(ctx, req, "stop", noop); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("acquireProjectLock() while busy = %v, want context.DeadlineExceeded", err)
+	}
+
+	release()
+	release, err = acquireProjectLock(context.Background(), req, "stop", noop)
+	if err != nil {
+		t.Fatalf("acquireProjectLock() after release = %v", err)
+	}
+	release()
+}
+
 // TestIndexHandler tests that root path serves correctly.
 func TestIndexHandler(t *testing.T) {
 	tests := []struct {
@@ -483,6 +873,7 @@ func TestServiceActionHandler_SSEHeaders(t *testing.T) {
 
 	cleanup := setupTestConfig(t, configJSON)
 	defer cleanup()
+	withContainerProject(t, "")
 
 	body := strings.NewReader(`{"container_name": "test-container", "service_name": "test", "source": "docker", "host": "localhost"}`)
 	req := httptest.NewRequest(http.MethodPost, "/api/services/start", body)
@@ -509,7 +900,7 @@ func TestServiceActionHandler_SSEHeaders(t *testing.T) {
 	}
 }
 
-// TestServiceActionHandler_UnknownSource tests that unknown source is rejected.
+// TestServiceActionHandler_UnknownSource tests that unknown source is rejected with 400.
 func TestServiceActionHandler_UnknownSource(t *testing.T) {
 	configJSON := `{
 		"hosts": [
@@ -532,15 +923,17 @@ func TestServiceActionHandler_UnknownSource(t *testing.T) {
 
 	ServiceActionHandler(w, req)
 
-	// Check SSE headers are set
-	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
-		t.Errorf("Content-Type = %v, want text/event-stream", ct)
+	// Rejected before the stream starts
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %v, want application/json", ct)
 	}
 
-	// Check response contains error about unknown source
 	responseBody := w.Body.String()
-	if !strings.Contains(responseBody, "Unknown service source") {
-		t.Errorf("Expected 'Unknown service source' in body, got: %s", responseBody)
+	if !strings.Contains(responseBody, "not a known service source") {
+		t.Errorf("Expected 'not a known service source' in body, got: %s", responseBody)
 	}
 }
 
@@ -559,6 +952,7 @@ func TestServiceActionHandler_ValidActions(t *testing.T) {
 
 	cleanup := setupTestConfig(t, configJSON)
 	defer cleanup()
+	withContainerProject(t, "")
 
 	actions := []string{"start", "stop", "restart"}
 	for _, action := range actions {
@@ -645,68 +1039,486 @@ func TestServiceActionHandler_ReadOnlyService(t *testing.T) {
 	})
 }
 
-// TestFindComposeFile tests the compose file detection function.
-func TestFindComposeFile(t *testing.T) {
-	tempDir := t.TempDir()
+// TestServiceActionHandler_SelfRequiresConfirmation tests that actions on the
+// dashboard's own unit need confirm_self and then run detached from the request.
+func TestServiceActionHandler_SelfRequiresConfirmation(t *testing.T) {
+	configJSON := `{
+		"hosts": [
+			{
+				"name": "nas",
+				"address": "localhost",
+				"systemd_services": ["nas-dashboard.service", "docker.service"],
+				"docker_compose_roots": []
+			}
+		]
+	}`
 
-	// Test with no compose file
-	result := findComposeFile(tempDir)
-	if result != "" {
-		t.Errorf("Expected empty string for empty dir, got: %s", result)
-	}
+	cleanup := setupTestConfig(t, configJSON)
+	defer cleanup()
 
-	// Test with docker-compose.yml
-	composePath := filepath.Join(tempDir, "docker-compose.yml")
-	if err := os.WriteFile(composePath, []byte("version: '3'"), 0644); err != nil {
-		t.Fatalf("Failed to create compose file: %v", err)
-	}
+	selfdetect.Set(selfdetect.Identity{Unit: "nas-dashboard.service"})
+	defer selfdetect.Set(selfdetect.Identity{})
+
+	// Record whether the action's context was cancelled along with the request
+	var actionCtxErr error
+	ran := false
+	original := actionPlanners["systemd"]
+	actionPlanners["systemd"] = planOf(func(ctx context.Context, sendEvent func(string, string)) error {
+		ran = true
+		actionCtxErr = ctx.Err()
+		return nil
+	})
+	defer func() { actionPlanners["systemd"] = original }()
 
-	result = findComposeFile(tempDir)
-	if result != composePath {
-		t.Errorf("Expected %s, got: %s", composePath, result)
-	}
+	t.Run("without confirmation", func(t *testing.T) {
+		ran = false
+		body := strings.NewReader(`{"container_name": "nas-dashboard.service", "service_name": "nas-dashboard.service", "source": "systemd", "host": "nas"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/services/restart", body)
+		w := httptest.NewRecorder()
 
-	// Remove and test with compose.yml
-	os.Remove(composePath)
-	composePath2 := filepath.Join(tempDir, "compose.yml")
-	if err := os.WriteFile(composePath2, []byte("version: '3'"), 0644); err != nil {
-		t.Fatalf("Failed to create compose file: %v", err)
-	}
+		ServiceActionHandler(w, req)
 
-	result = findComposeFile(tempDir)
-	if result != composePath2 {
-		t.Errorf("Expected %s, got: %s", composePath2, result)
-	}
+		if w.Code != http.StatusConflict {
+			t.Errorf("Status = %d, want %d", w.Code, http.StatusConflict)
+		}
+		if !strings.Contains(w.Body.String(), "confirm_self") {
+			t.Errorf("Expected confirm_self hint in body, got: %s", w.Body.String())
+		}
+		if ran {
+			t.Error("action ran without confirmation")
+		}
+	})
+
+	t.Run("with confirmation", func(t *testing.T) {
+		ran = false
+		body := strings.NewReader(`{"container_name": "nas-dashboard.service", "service_name": "nas-dashboard.service", "source": "systemd", "host": "nas", "confirm_self": true}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/services/restart", body)
+		ctx, cancel := context.WithCancel(req.Context())
+		cancel()
+		req = req.WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		ServiceActionHandler(w, req)
+
+		if !ran {
+			t.Fatal("action did not run")
+		}
+		if actionCtxErr != nil {
+			t.Errorf("action context error = %v, want detached from the request", actionCtxErr)
+		}
+		responseBody := w.Body.String()
+		if !strings.Contains(responseBody, "event: warning") || !strings.Contains(responseBody, "connection will drop") {
+			t.Errorf("Expected self warning in body, got: %s", responseBody)
+		}
+	})
+
+	t.Run("other services are not gated", func(t *testing.T) {
+		ran = false
+		body := strings.NewReader(`{"container_name": "docker.service", "service_name": "docker.service", "source": "systemd", "host": "nas"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/services/restart", body)
+		w := httptest.NewRecorder()
+
+		ServiceActionHandler(w, req)
+
+		if !ran {
+			t.Error("action on another service did not run")
+		}
+		if strings.Contains(w.Body.String(), "connection will drop") {
+			t.Errorf("unexpected self warning for another service: %s", w.Body.String())
+		}
+	})
 }
 
-// TestServiceActionRequest_JSONParsing tests JSON parsing of request body.
-func TestServiceActionRequest_JSONParsing(t *testing.T) {
-	jsonStr := `{
-		"container_name": "test-container",
-		"service_name": "test-service",
-		"source": "docker",
-		"host": "localhost",
-		"project": "myproject"
+// TestActionHistory_CapturesServiceAction tests that an action's stream is
+// recorded and can be read back, redacted, through the history endpoints.
+func TestActionHistory_CapturesServiceAction(t *testing.T) {
+	configJSON := `{
+		"hosts": [
+			{
+				"name": "testhost",
+				"address": "localhost",
+				"systemd_services": ["nginx.service", "backup.service"],
+				"docker_compose_roots": []
+			}
+		],
+		"log_redaction": {"enabled": true, "rules": [{"name": "password", "pattern": "hunter2"}]}
 	}`
 
-	var req ServiceActionRequest
-	if err := json.Unmarshal([]byte(jsonStr), &req); err != nil {
-		t.Fatalf("Failed to parse JSON: %v", err)
-	}
+	cleanup := setupTestConfig(t, configJSON)
+	defer cleanup()
 
-	if req.ContainerName != "test-container" {
-		t.Errorf("ContainerName = %s, want test-container", req.ContainerName)
+	original := actionHistory
+	SetActionHistory(actionhistory.NewStore(actionhistory.DefaultPerService, actionhistory.DefaultMaxOutput))
+	defer func() { actionHistory = original }()
+
+	originalAction := actionPlanners["systemd"]
+	actionPlanners["systemd"] = planOf(func(ctx context.Context, sendEvent func(string, string)) error {
+		sendEvent("status", "Executing restart on nginx.service...")
+		sendEvent("output", "logging in with hunter2")
+		return errors.New("unit nginx.service failed to start")
+	})
+	defer func() { actionPlanners["systemd"] = originalAction }()
+
+	body := strings.NewReader(`{"container_name": "nginx.service", "service_name": "nginx.service", "source": "systemd", "host": "testhost"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/services/restart", body)
+	ServiceActionHandler(httptest.NewRecorder(), req)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/services/{host}/{name}/actions", ActionHistoryHandler)
+	mux.HandleFunc("GET /api/services/{host}/{name}/actions/{id}/output", ActionOutputHandler)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/services/testhost/nginx.service/actions", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("list status = %d, want %d", w.Code, http.StatusOK)
 	}
-	if req.ServiceName != "test-service" {
-		t.Errorf("ServiceName = %s, want test-service", req.ServiceName)
+	var list []actionhistory.Record
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode list: %v", err)
 	}
-	if req.Source != "docker" {
-		t.Errorf("Source = %s, want docker", req.Source)
+	if len(list) != 1 || list[0].Action != "restart" || list[0].Outcome != actionhistory.OutcomeFailed {
+		t.Fatalf("list = %+v", list)
 	}
-	if req.Host != "localhost" {
-		t.Errorf("Host = %s, want localhost", req.Host)
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/services/testhost/nginx.service/actions/"+list[0].ID+"/output", nil))
+	var record actionhistory.Record
+	if err := json.NewDecoder(w.Body).Decode(&record); err != nil {
+		t.Fatalf("failed to decode output: %v", err)
 	}
-	if req.Project != "myproject" {
+	var events []string
+	for _, line := range record.Lines {
+		events = append(events, line.Event+": "+line.Message)
+	}
+	joined := strings.Join(events, "\n")
+	for _, want := range []string{"status: Executing restart on nginx.service...", "output: logging in with [REDACTED:password]", "error: unit nginx.service failed to start", "complete: failed"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("output missing %q:\n%s", want, joined)
+		}
+	}
+	if strings.Contains(joined, "hunter2") {
+		t.Errorf("output not redacted:\n%s", joined)
+	}
+
+	t.Run("unknown action", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/services/testhost/nginx.service/actions/999/output", nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Status = %d, want %d", w.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("scoped user cannot read other services", func(t *testing.T) {
+		scopedUser := auth.User{
+			ID:              "scoped-user",
+			AllowedServices: map[string][]string{"testhost": {"backup.service"}},
+		}
+		for _, path := range []string{
+			"/api/services/testhost/nginx.service/actions",
+			"/api/services/testhost/nginx.service/actions/" + list[0].ID + "/output",
+		} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, &scopedUser))
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+			if w.Code != http.StatusForbidden {
+				t.Errorf("%s: Status = %d, want %d", path, w.Code, http.StatusForbidden)
+			}
+		}
+	})
+
+	t.Run("output takes the logs permission", func(t *testing.T) {
+		user := auth.User{
+			ID:                 "household",
+			AllowedServices:    map[string][]string{"testhost": {"nginx.service"}},
+			ServicePermissions: map[string]map[string][]string{"testhost": {"nginx.service": {config.PermissionControl}}},
+		}
+		get := func(path string) int {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, &user))
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+			return w.Code
+		}
+		if code := get("/api/services/testhost/nginx.service/actions"); code != http.StatusOK {
+			t.Errorf("list without the logs permission: Status = %d, want %d", code, http.StatusOK)
+		}
+		if code := get("/api/services/testhost/nginx.service/actions/" + list[0].ID + "/output"); code != http.StatusForbidden {
+			t.Errorf("output without the logs permission: Status = %d, want %d", code, http.StatusForbidden)
+		}
+	})
+}
+
+// TestServiceActionHandler_DisplayNameIsNotAnIdentifier tests that actions are keyed by the
+// unit name, so a request using a service's display name cannot reach it.
+func TestServiceActionHandler_DisplayNameIsNotAnIdentifier(t *testing.T) {
+	configJSON := `{
+		"hosts": [
+			{
+				"name": "testhost",
+				"address": "localhost",
+				"systemd_services": ["nginx.service|name=WebServer", "nas-dashboard.service:ro|name=NAS Dashboard"],
+				"docker_compose_roots": []
+			}
+		]
+	}`
+
+	cleanup := setupTestConfig(t, configJSON)
+	defer cleanup()
+
+	scopedUser := auth.User{
+		ID:              "scoped-user",
+		Name:            "Scoped User",
+		AllowedServices: map[string][]string{"testhost": {"nginx.service"}},
+	}
+
+	t.Run("display name is rejected for scoped user", func(t *testing.T) {
+		body := strings.NewReader(`{"container_name": "WebServer", "service_name": "WebServer", "source": "systemd", "host": "testhost"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/services/restart", body)
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, &scopedUser))
+		w := httptest.NewRecorder()
+
+		ServiceActionHandler(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Status = %d, want %d", w.Code, http.StatusForbidden)
+		}
+	})
+
+	t.Run("read-only flag still applies with display name configured", func(t *testing.T) {
+		body := strings.NewReader(`{"container_name": "nas-dashboard.service", "service_name": "nas-dashboard.service", "source": "systemd", "host": "testhost"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/services/restart", body)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		ServiceActionHandler(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Status = %d, want %d", w.Code, http.StatusForbidden)
+		}
+		if !strings.Contains(w.Body.String(), "read-only") {
+			t.Errorf("Expected 'read-only' in body, got: %s", w.Body.String())
+		}
+	})
+}
+
+// TestServiceActionHandler_Timeout tests that a hung action is cut off at the configured action timeout.
+func TestServiceActionHandler_Timeout(t *testing.T) {
+	configJSON := `{
+		"hosts": [
+			{
+				"name": "localhost",
+				"address": "localhost",
+				"systemd_services": [],
+				"docker_compose_roots": []
+			}
+		],
+		"action_timeout": 1
+	}`
+
+	cleanup := setupTestConfig(t, configJSON)
+	defer cleanup()
+
+	// Replace the systemd action with a stub that blocks until its context is cancelled
+	original := actionPlanners["systemd"]
+	actionPlanners["systemd"] = planOf(func(ctx context.Context, sendEvent func(string, string)) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	defer func() { actionPlanners["systemd"] = original }()
+
+	body := strings.NewReader(`{"container_name": "hung.service", "service_name": "hung.service", "source": "systemd", "host": "localhost"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/services/stop", body)
+	w := httptest.NewRecorder()
+
+	start := time.Now()
+	ServiceActionHandler(w, req)
+	elapsed := time.Since(start)
+
+	if elapsed > 3*time.Second {
+		t.Errorf("Handler took %v, want about 1s", elapsed)
+	}
+
+	responseBody := w.Body.String()
+	if !strings.Contains(responseBody, "timed out") {
+		t.Errorf("Expected 'timed out' in body, got: %s", responseBody)
+	}
+	if !strings.Contains(responseBody, "data: failed") {
+		t.Errorf("Expected failed completion in body, got: %s", responseBody)
+	}
+}
+
+// TestActionTimeout tests that compose restarts get a longer budget than other actions.
+func TestActionTimeout(t *testing.T) {
+	cfg := &config.Config{ActionTimeout: 30, ComposeTimeout: 600}
+
+	if got := actionTimeout(cfg, "docker", "restart"); got != 600*time.Second {
+		t.Errorf("actionTimeout(docker, restart) = %v, want 10m0s", got)
+	}
+	if got := actionTimeout(cfg, "docker", "stop"); got != 30*time.Second {
+		t.Errorf("actionTimeout(docker, stop) = %v, want 30s", got)
+	}
+	if got := actionTimeout(cfg, "systemd", "restart"); got != 30*time.Second {
+		t.Errorf("actionTimeout(systemd, restart) = %v, want 30s", got)
+	}
+	if got := actionTimeout(nil, "systemd", "start"); got != 120*time.Second {
+		t.Errorf("actionTimeout(nil config) = %v, want 2m0s", got)
+	}
+}
+
+// TestCallProvider_Timeout tests that a provider blocking past its deadline is reported as unavailable.
+func TestCallProvider_Timeout(t *testing.T) {
+	start := time.Now()
+	err := callProvider(context.Background(), 50*time.Millisecond, "systemd", "remote", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	elapsed := time.Since(start)
+
+	if elapsed > time.Second {
+		t.Errorf("callProvider took %v, want about 50ms", elapsed)
+	}
+
+	var provErr *services.ProviderError
+	if !errors.As(err, &provErr) {
+		t.Fatalf("Expected *services.ProviderError, got %T: %v", err, err)
+	}
+	if !provErr.Timeout() {
+		t.Error("Expected Timeout() to be true")
+	}
+	if provErr.Provider != "systemd" || provErr.Host != "remote" {
+		t.Errorf("ProviderError = %+v, want provider=systemd host=remote", provErr)
+	}
+}
+
+// TestCallProvider_Error tests that provider errors are wrapped without being reported as timeouts.
+func TestCallProvider_Error(t *testing.T) {
+	sentinel := errors.New("connection refused")
+	err := callProvider(context.Background(), time.Second, "traefik", "nas", func(ctx context.Context) error {
+		return sentinel
+	})
+
+	if !errors.Is(err, sentinel) {
+		t.Errorf("Expected error to wrap sentinel, got: %v", err)
+	}
+	var provErr *services.ProviderError
+	if !errors.As(err, &provErr) || provErr.Timeout() {
+		t.Errorf("Expected non-timeout ProviderError, got: %v", err)
+	}
+
+	if err := callProvider(context.Background(), time.Second, "traefik", "nas", func(ctx context.Context) error { return nil }); err != nil {
+		t.Errorf("Expected nil error, got: %v", err)
+	}
+}
+
+func TestCallRemoteProvider_CircuitOpen(t *testing.T) {
+	resilience.Configure(1, time.Minute)
+	defer resilience.Configure(resilience.DefaultThreshold, resilience.DefaultCooldown)
+
+	host := &config.HostConfig{Name: "slowbox", Address: "192.168.1.50"}
+	calls := 0
+	failing := func(ctx context.Context) error {
+		calls++
+		return errors.New("ssh: connection reset by peer")
+	}
+
+	// First call retries, then trips the breaker
+	if err := callRemoteProvider(context.Background(), 5*time.Second, "systemd", host, failing); err == nil {
+		t.Fatal("Expected error from failing provider")
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 attempts (1 + 2 retries), got %d", calls)
+	}
+
+	// Second call fails fast with a circuit open error
+	calls = 0
+	err := callRemoteProvider(context.Background(), 5*time.Second, "systemd", host, failing)
+	if !errors.Is(err, resilience.ErrCircuitOpen) {
+		t.Fatalf("Expected circuit open error, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "circuit open") {
+		t.Errorf("Expected warning to mention circuit open, got: %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("Expected no calls while circuit is open, got %d", calls)
+	}
+}
+
+func TestCallRemoteProvider_LocalNotRetried(t *testing.T) {
+	host := &config.HostConfig{Name: "nas", Address: "localhost"}
+	calls := 0
+	callRemoteProvider(context.Background(), time.Second, "systemd", host, func(ctx context.Context) error {
+		calls++
+		return errors.New("failed")
+	})
+	if calls != 1 {
+		t.Errorf("Expected local provider to be called once, got %d", calls)
+	}
+}
+
+// TestFindComposeFile tests the compose file detection function.
+func TestFindComposeFile(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Test with no compose file
+	result := findComposeFile(tempDir)
+	if result != "" {
+		t.Errorf("Expected empty string for empty dir, got: %s", result)
+	}
+
+	// Test with docker-compose.yml
+	composePath := filepath.Join(tempDir, "docker-compose.yml")
+	if err := os.WriteFile(composePath, []byte("version: '3'"), 0644); err != nil {
+		t.Fatalf("Failed to create compose file: %v", err)
+	}
+
+	result = findComposeFile(tempDir)
+	if result != composePath {
+		t.Errorf("Expected %s, got: %s", composePath, result)
+	}
+
+	// Remove and test with compose.yml
+	os.Remove(composePath)
+	composePath2 := filepath.Join(tempDir, "compose.yml")
+	if err := os.WriteFile(composePath2, []byte("version: '3'"), 0644); err != nil {
+		t.Fatalf("Failed to create compose file: %v", err)
+	}
+
+	result = findComposeFile(tempDir)
+	if result != composePath2 {
+		t.Errorf("Expected %s, got: %s", composePath2, result)
+	}
+}
+
+// TestServiceActionRequest_JSONParsing tests JSON parsing of request body.
+func TestServiceActionRequest_JSONParsing(t *testing.T) {
+	jsonStr := `{
+		"container_name": "test-container",
+		"service_name": "test-service",
+		"source": "docker",
+		"host": "localhost",
+		"project": "myproject"
+	}`
+
+	var req ServiceActionRequest
+	if err := json.Unmarshal([]byte(jsonStr), &req); err != nil {
+		t.Fatalf("Failed to parse JSON: %v", err)
+	}
+
+	if req.ContainerName != "test-container" {
+		t.Errorf("ContainerName = %s, want test-container", req.ContainerName)
+	}
+	if req.ServiceName != "test-service" {
+		t.Errorf("ServiceName = %s, want test-service", req.ServiceName)
+	}
+	if req.Source != "docker" {
+		t.Errorf("Source = %s, want docker", req.Source)
+	}
+	if req.Host != "localhost" {
+		t.Errorf("Host = %s, want localhost", req.Host)
+	}
+	if req.Project != "myproject" {
 		t.Errorf("Project = %s, want myproject", req.Project)
 	}
 }
@@ -815,7 +1627,7 @@ func TestApplyPortRemaps(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := applyPortRemaps(tt.services, tt.remaps)
+			result := applyPortRemaps(tt.services, tt.remaps, false)
 
 			if tt.expectTarget == "" {
 				// No remap expected, verify no changes
@@ -904,7 +1716,7 @@ func TestApplyPortRemaps_MultipleRemaps(t *testing.T) {
 		{Port: 9117, TargetService: "jackett", SourceService: "gluetun"},
 	}
 
-	result := applyPortRemaps(svcList, remaps)
+	result := applyPortRemaps(svcList, remaps, false)
 
 	// Build a map for easier checking
 	svcMap := make(map[string]*services.ServiceInfo)
@@ -970,7 +1782,7 @@ func TestApplyPortRemaps_WithProtocolOverride(t *testing.T) {
 		{Port: 8080, TargetService: "qbittorrent", SourceService: "gluetun"},
 	}
 
-	result := applyPortRemaps(svcList, remaps)
+	result := applyPortRemaps(svcList, remaps, false)
 
 	// Build a map for easier checking
 	svcMap := make(map[string]*services.ServiceInfo)
@@ -1104,3 +1916,1173 @@ func TestLogFlushHandler_MissingContainerName(t *testing.T) {
 		t.Errorf("Expected 'container_name is required' in body, got: %s", w.Body.String())
 	}
 }
+
+// TestSelfTestHandler_MethodNotAllowed tests that only POST is accepted.
+func TestSelfTestHandler_MethodNotAllowed(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/selftest", nil)
+	w := httptest.NewRecorder()
+
+	SelfTestHandler(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Status code = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+// TestSelfTestHandler_RequiresAdmin tests that non-admin users are rejected.
+func TestSelfTestHandler_RequiresAdmin(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/selftest", nil)
+	w := httptest.NewRecorder()
+
+	// No user in context (nil user)
+	SelfTestHandler(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Status code = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	if !strings.Contains(w.Body.String(), "administrator privileges required") {
+		t.Errorf("Expected 'administrator privileges required' in body, got: %s", w.Body.String())
+	}
+}
+
+// TestRecreateHandler_RequiresAdmin tests that non-admin users cannot recreate containers.
+func TestRecreateHandler_RequiresAdmin(t *testing.T) {
+	body := strings.NewReader(`{"container_name": "test", "env_overrides": {"LOG_LEVEL": "debug"}}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/services/recreate", body)
+	w := httptest.NewRecorder()
+
+	RecreateHandler(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Status code = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+// TestRecreateHandler_ValidatesRequest tests that bad requests are rejected before anything is changed.
+func TestRecreateHandler_ValidatesRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid JSON", `{invalid`},
+		{"missing container", `{"env_overrides": {"LOG_LEVEL": "debug"}}`},
+		{"no overrides", `{"container_name": "test"}`},
+		{"invalid variable name", `{"container_name": "test", "env_overrides": {"LOG LEVEL": "debug"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/services/recreate", strings.NewReader(tt.body))
+			ctx := context.WithValue(req.Context(), authUserContextKey, &testAdminUser)
+			req = req.WithContext(ctx)
+			w := httptest.NewRecorder()
+
+			RecreateHandler(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Status code = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+// TestLinksHandler tests that links are filtered by the user's groups and host access.
+func TestLinksHandler(t *testing.T) {
+	configJSON := `{
+		"links": [
+			{"name": "Router", "url": "http://192.168.1.1"},
+			{"name": "Photos", "url": "https://photos.example.com", "allowed_groups": ["family"]}
+		],
+		"hosts": [
+			{"name": "nas", "address": "localhost", "links": [{"name": "Paperless", "url": "https://paperless.lan"}]},
+			{"name": "pi", "address": "192.168.1.20", "links": [{"name": "Pi-hole", "url": "http://192.168.1.20/admin"}]}
+		]
+	}`
+
+	cleanup := setupTestConfig(t, configJSON)
+	defer cleanup()
+
+	getLinks := func(t *testing.T, user *auth.User) []string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/links", nil)
+		if user != nil {
+			req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, user))
+		}
+		w := httptest.NewRecorder()
+		LinksHandler(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Status = %d, want %d", w.Code, http.StatusOK)
+		}
+		var links []config.Link
+		if err := json.NewDecoder(w.Body).Decode(&links); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		names := make([]string, len(links))
+		for i, link := range links {
+			names[i] = link.Name
+		}
+		return names
+	}
+
+	tests := []struct {
+		name string
+		user *auth.User
+		want []string
+	}{
+		{"no auth", nil, []string{"Router", "Photos", "Paperless", "Pi-hole"}},
+		{"admin", &auth.User{ID: "admin", IsAdmin: true, HasGlobalAccess: true}, []string{"Router", "Photos", "Paperless", "Pi-hole"}},
+		{"scoped user", &auth.User{ID: "scoped", AllowedServices: map[string][]string{"nas": {"docker.service"}}}, []string{"Router", "Paperless"}},
+		{"group member", &auth.User{ID: "family", Groups: []string{"family"}, AllowedServices: map[string][]string{"pi": {"pihole"}}}, []string{"Router", "Photos", "Pi-hole"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getLinks(t, tt.user)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("links = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("method not allowed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/links", nil)
+		w := httptest.NewRecorder()
+		LinksHandler(w, req)
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("Status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// TestStreamRegistry_Connections tests that SSE streams are listed, capped and
+// can be closed from the server side.
+func TestStreamRegistry_Connections(t *testing.T) {
+	orig := streamRegistry
+	SetStreamRegistry(streams.New(2, 3))
+	defer func() { streamRegistry = orig }()
+
+	users := map[string]*auth.User{
+		"alice": {ID: "alice", Email: "alice@test.com", HasGlobalAccess: true},
+		"bob":   {ID: "bob", Email: "bob@test.com", HasGlobalAccess: true},
+		"carol": {ID: "carol", Email: "carol@test.com", HasGlobalAccess: true},
+		"admin": {ID: "admin", Email: "admin@test.com", IsAdmin: true, HasGlobalAccess: true},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/logs/traefik", TraefikLogsHandler)
+	mux.HandleFunc("GET /api/connections", ConnectionsHandler)
+	mux.HandleFunc("DELETE /api/connections/{id}", CloseConnectionHandler)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, ok := users[r.Header.Get("X-Test-User")]; ok {
+			r = r.WithContext(context.WithValue(r.Context(), authUserContextKey, user))
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	do := func(t *testing.T, method, path, user string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Test-User", user)
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	// open starts a log stream and waits for its first event, so it is registered
+	open := func(t *testing.T, user, service string) *http.Response {
+		t.Helper()
+		resp := do(t, http.MethodGet, "/api/logs/traefik?host=nas&service="+service, user)
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			t.Fatalf("open stream for %s: Status = %d, want %d", user, resp.StatusCode, http.StatusOK)
+		}
+		if _, err := bufio.NewReader(resp.Body).ReadString('\n'); err != nil {
+			t.Fatalf("open stream for %s: %v", user, err)
+		}
+		return resp
+	}
+	list := func(t *testing.T) []streams.Info {
+		t.Helper()
+		resp := do(t, http.MethodGet, "/api/connections", "admin")
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("list: Status = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+		var infos []streams.Info
+		if err := json.NewDecoder(resp.Body).Decode(&infos); err != nil {
+			t.Fatal(err)
+		}
+		return infos
+	}
+
+	alice1 := open(t, "alice", "whoami")
+	defer alice1.Body.Close()
+	alice2 := open(t, "alice", "api")
+	defer alice2.Body.Close()
+
+	resp := do(t, http.MethodGet, "/api/logs/traefik?host=nas&service=more", "alice")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("third stream for alice: Status = %d, want %d", resp.StatusCode, http.StatusTooManyRequests)
+	}
+
+	bob := open(t, "bob", "whoami")
+	defer bob.Body.Close()
+
+	resp = do(t, http.MethodGet, "/api/logs/traefik?host=nas&service=whoami", "carol")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("stream over global limit: Status = %d, want %d", resp.StatusCode, http.StatusTooManyRequests)
+	}
+
+	infos := list(t)
+	if len(infos) != 3 {
+		t.Fatalf("listed %d connections, want 3: %+v", len(infos), infos)
+	}
+	first := infos[0]
+	if first.User != "alice@test.com" || first.Endpoint != "/api/logs/traefik" || first.Target != "nas/whoami" || first.Client == "" {
+		t.Errorf("first connection = %+v", first)
+	}
+
+	t.Run("non-admin cannot list or close", func(t *testing.T) {
+		for _, method := range []string{http.MethodGet, http.MethodDelete} {
+			path := "/api/connections"
+			if method == http.MethodDelete {
+				path += "/" + first.ID
+			}
+			resp := do(t, method, path, "bob")
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusForbidden {
+				t.Errorf("%s %s: Status = %d, want %d", method, path, resp.StatusCode, http.StatusForbidden)
+			}
+		}
+	})
+
+	t.Run("unknown connection", func(t *testing.T) {
+		resp := do(t, http.MethodDelete, "/api/connections/9999", "admin")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+		}
+	})
+
+	t.Run("close terminates the stream", func(t *testing.T) {
+		resp := do(t, http.MethodDelete, "/api/connections/"+first.ID, "admin")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("Status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+		}
+
+		// The handler returns, so the client sees the end of the stream
+		ended := make(chan error, 1)
+		go func() {
+			_, err := io.Copy(io.Discard, alice1.Body)
+			ended <- err
+		}()
+		select {
+		case <-ended:
+		case <-time.After(5 * time.Second):
+			t.Fatal("stream still open after being closed")
+		}
+
+		// The slot is freed once the handler has returned
+		deadline := time.Now().Add(5 * time.Second)
+		for len(list(t)) != 2 {
+			if time.Now().After(deadline) {
+				t.Fatalf("closed stream still listed: %+v", list(t))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		alice3 := open(t, "alice", "more")
+		alice3.Body.Close()
+	})
+
+	t.Run("client disconnect frees the slot", func(t *testing.T) {
+		bob.Body.Close()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			found := false
+			for _, info := range list(t) {
+				if info.User == "bob@test.com" {
+					found = true
+				}
+			}
+			if !found {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("disconnected stream still listed")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+// TestApplyTraefikURLs tests Traefik URL matching, label overrides and opt-outs.
+func TestApplyTraefikURLs(t *testing.T) {
+	mappings := map[string][]string{
+		"photos":            {"photos.example.com"},
+		"web-blog":          {"www.blog.example.com", "blog.example.com", "Blog.Example.com", "internal.blog.lan"},
+		"router-from-label": {"labelled.example.com"},
+		"wiki":              {"wrong-router.example.com"},
+	}
+
+	tests := []struct {
+		name string
+		svc  services.ServiceInfo
+		want []string
+	}{
+		{
+			name: "exact name",
+			svc:  services.ServiceInfo{Name: "photos", Project: "media"},
+			want: []string{"https://photos.example.com"},
+		},
+		{
+			name: "service-project pattern, deduplicated and sorted",
+			svc:  services.ServiceInfo{Name: "web", Project: "blog"},
+			want: []string{"https://blog.example.com", "https://internal.blog.lan"},
+		},
+		{
+			name: "traefik service name from labels wins",
+			svc:  services.ServiceInfo{Name: "photos", TraefikServiceName: "router-from-label"},
+			want: []string{"https://labelled.example.com"},
+		},
+		{
+			name: "url label overrides matching",
+			svc:  services.ServiceInfo{Name: "photos", TraefikURLs: []string{"https://pics.example.com"}, URLOverride: true},
+			want: []string{"https://pics.example.com"},
+		},
+		{
+			name: "opt-out skips name fallbacks",
+			svc:  services.ServiceInfo{Name: "wiki", TraefikIgnore: true},
+			want: nil,
+		},
+		{
+			name: "opt-out still uses the labelled traefik service",
+			svc:  services.ServiceInfo{Name: "wiki", TraefikServiceName: "router-from-label", TraefikIgnore: true},
+			want: []string{"https://labelled.example.com"},
+		},
+		{
+			name: "no match",
+			svc:  services.ServiceInfo{Name: "unknown", Project: "other", ContainerName: "unknown-1"},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := applyTraefikURLs([]services.ServiceInfo{tt.svc}, mappings, false)[0].TraefikURLs
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("TraefikURLs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTraefikCandidates tests the order of the Traefik names tried for a service.
+func TestTraefikCandidates(t *testing.T) {
+	svc := services.ServiceInfo{Name: "my_app", Project: "stack", ContainerName: "stack-my_app-1", TraefikServiceName: "custom"}
+	got := strings.Join(traefikCandidates(svc), ",")
+	want := "custom,my_app,my-app,my_app-stack,my-app-stack,stack-my_app-1,stack-my-app-1"
+	if got != want {
+		t.Errorf("traefikCandidates() = %s, want %s", got, want)
+	}
+
+	svc.TraefikIgnore = true
+	if got := strings.Join(traefikCandidates(svc), ","); got != "custom" {
+		t.Errorf("traefikCandidates() with ignore = %s, want custom", got)
+	}
+
+	systemdSvc := services.ServiceInfo{Name: "nginx.service", Project: "systemd"}
+	if got := strings.Join(traefikCandidates(systemdSvc), ","); got != "nginx.service" {
+		t.Errorf("traefikCandidates() for systemd = %s, want nginx.service", got)
+	}
+}
+
+// TestDedupeURLs tests that duplicate and www URLs collapse in a stable order.
+func TestDedupeURLs(t *testing.T) {
+	in := []string{"https://www.b.example.com", "https://b.example.com.", "https://A.example.com", "https://a.example.com", "https://www.c.example.com"}
+	got := dedupeURLs(in)
+	want := []string{"https://a.example.com", "https://b.example.com", "https://www.c.example.com"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("dedupeURLs() = %v, want %v", got, want)
+	}
+}
+
+// fakeProvider is a provider for a source registered only in tests.
+type fakeProvider struct {
+	host    string
+	actions *[]string
+	svcs    []services.ServiceInfo // returned by GetServices if set
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+func (p *fakeProvider) GetServices(ctx context.Context) ([]services.ServiceInfo, error) {
+	if p.svcs != nil {
+		return p.svcs, nil
+	}
+	return []services.ServiceInfo{{Name: "widget", Source: "fake", Host: p.host, State: "running"}}, nil
+}
+
+func (p *fakeProvider) GetService(name string) (services.Service, error) {
+	return &fakeService{name: name, host: p.host, actions: p.actions}, nil
+}
+
+func (p *fakeProvider) GetLogs(ctx context.Context, serviceName string, tailLines int, follow bool) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(fmt.Sprintf("last %d lines\n", tailLines))), nil
+}
+
+// fakeService records the actions run on it.
+type fakeService struct {
+	name     string
+	host     string
+	actions  *[]string
+	progress func(message string)
+}
+
+// GetInfo reports "stack-nginx-1" as the container of the compose service
+// nginx, and "infra" as hidden.
+func (s *fakeService) GetInfo(ctx context.Context) (services.ServiceInfo, error) {
+	info := services.ServiceInfo{Name: s.name, ContainerName: s.name, Source: "fake", Host: s.host, State: "running"}
+	switch s.name {
+	case "stack-nginx-1":
+		info.Name, info.Project = "nginx", "stack"
+	case "infra":
+		info.Hidden = true
+	}
+	return info, nil
+}
+
+func (s *fakeService) GetLogs(ctx context.Context, tailLines int, follow bool) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader("")), nil
+}
+
+func (s *fakeService) Start(ctx context.Context) error   { return s.record("start") }
+func (s *fakeService) Stop(ctx context.Context) error    { return s.record("stop") }
+func (s *fakeService) Restart(ctx context.Context) error { return s.record("restart") }
+func (s *fakeService) GetName() string                   { return s.name }
+func (s *fakeService) GetHost() string                   { return s.host }
+func (s *fakeService) GetSource() string                 { return "fake" }
+
+func (s *fakeService) SetProgress(progress func(message string)) { s.progress = progress }
+
+// Failure reports the service named "broken" as killed by the OOM killer.
+func (s *fakeService) Failure(ctx context.Context) (*services.FailureInfo, error) {
+	if s.name != "broken" {
+		return nil, nil
+	}
+	return &services.FailureInfo{Result: "oom-kill", NRestarts: 2, Logs: []string{"out of memory"}}, nil
+}
+
+func (s *fakeService) record(action string) error {
+	if s.progress != nil {
+		s.progress("running " + action)
+	}
+	*s.actions = append(*s.actions, action+" "+s.host+"/"+s.name)
+	return nil
+}
+
+// registerFakeProvider registers the "fake" source for hosts named "fakehost".
+func registerFakeProvider(t *testing.T, caps services.Capabilities) *[]string {
+	t.Helper()
+	var actions []string
+	services.Register(services.Registration{
+		Source: "fake",
+		Order:  100,
+		Configured: func(host *config.HostConfig) bool {
+			return host.Name == "fakehost"
+		},
+		Factory: func(cfg *config.Config, host *config.HostConfig) (services.Provider, error) {
+			return &fakeProvider{host: host.Name, actions: &actions}, nil
+		},
+		Capabilities: caps,
+	})
+	t.Cleanup(func() { services.Unregister("fake") })
+	return &actions
+}
+
+// TestBuiltinProvidersRegistered tests that the built-in sources are registered in collection order.
+func TestBuiltinProvidersRegistered(t *testing.T) {
+	var sources []string
+	for _, reg := range services.Registered() {
+		sources = append(sources, reg.Source)
+	}
+	if got := strings.Join(sources, ","); got != "docker,systemd,homeassistant,traefik" {
+		t.Errorf("Registered() = %s, want docker,systemd,homeassistant,traefik", got)
+	}
+
+	if reg, ok := services.Lookup("homeassistant-addon"); !ok || reg.Source != "homeassistant" {
+		t.Errorf("Lookup(homeassistant-addon) = %+v, %v", reg, ok)
+	}
+	if reg, _ := services.Lookup("docker"); !reg.LocalOnly || !reg.NeedsClose || !reg.Logs || !reg.Actions {
+		t.Errorf("docker capabilities = %+v", reg.Capabilities)
+	}
+	if reg, _ := services.Lookup("traefik"); reg.Factory != nil || reg.Logs || reg.Actions {
+		t.Errorf("traefik registration = %+v, want no provider, logs or actions", reg)
+	}
+}
+
+// TestGetAllServices_RegisteredProvider tests that a registered source is collected
+// on the hosts it is configured for without any handler changes.
+func TestGetAllServices_RegisteredProvider(t *testing.T) {
+	registerFakeProvider(t, services.Capabilities{Actions: true})
+
+	cfg := &config.Config{
+		Hosts: []config.HostConfig{
+			{Name: "fakehost", Address: "192.168.1.50"},
+			{Name: "otherhost", Address: "192.168.1.51"},
+		},
+	}
+
+	// The context is canceled so Docker is not contacted; the fake ignores it
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	svcList, err := getAllServices(ctx, cfg)
+	if err != nil {
+		t.Fatalf("getAllServices() = %v", err)
+	}
+	var fakes []services.ServiceInfo
+	for _, svc := range svcList {
+		if svc.Source == "fake" {
+			fakes = append(fakes, svc)
+		}
+	}
+	if len(fakes) != 1 {
+		t.Fatalf("collected %d fake services, want 1: %+v", len(fakes), fakes)
+	}
+	if fakes[0].Host != "fakehost" || fakes[0].HostIP != "192.168.1.50" || fakes[0].DisplayName != "widget" {
+		t.Errorf("fake service = %+v", fakes[0])
+	}
+}
+
+// TestGetAllServices_DisabledHost tests that no provider is created for a
+// disabled host.
+func TestGetAllServices_DisabledHost(t *testing.T) {
+	var created int
+	services.Register(services.Registration{
+		Source: "fake",
+		Order:  100,
+		Factory: func(cfg *config.Config, host *config.HostConfig) (services.Provider, error) {
+			created++
+			return &fakeProvider{host: host.Name}, nil
+		},
+	})
+	t.Cleanup(func() { services.Unregister("fake") })
+
+	disabled := false
+	cfg := &config.Config{
+		Hosts: []config.HostConfig{
+			{Name: "fakehost", Address: "192.168.1.50", Enabled: &disabled},
+			{Name: "otherhost", Address: "192.168.1.51"},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	svcList, err := getAllServices(ctx, cfg)
+	if err != nil {
+		t.Fatalf("getAllServices() = %v", err)
+	}
+	if created != 1 {
+		t.Errorf("created %d providers, want 1 for otherhost only", created)
+	}
+	for _, svc := range svcList {
+		if svc.Host == "fakehost" {
+			t.Errorf("collected %s from the disabled host", svc.Name)
+		}
+	}
+}
+
+// TestWithLastActions tests that services get their last dashboard action
+// and that Docker containers started since it finished are flagged.
+func TestWithLastActions(t *testing.T) {
+	history := actionhistory.NewStore(5, 1024)
+	discard := func(string, string) {}
+	history.Begin("nas", "redis", "docker", "restart", "alice").Wrap(discard)("complete", "success")
+	history.Begin("nas", "jellyfin", "docker", "stop", "bob").Wrap(discard)("complete", "success")
+	history.Begin("nas", "nginx.service", "systemd", "restart", "alice").Wrap(discard)("complete", "failed")
+	history.Begin("nas", "sonarr", "docker", "restart", "carol") // Still running
+	finished := time.Now()
+
+	before := finished.Add(-time.Minute)
+	after := finished.Add(time.Minute)
+	svcs := []services.ServiceInfo{
+		{Name: "redis", Host: "nas", Source: "docker", State: services.StateRunning, LastStateChange: &before},
+		{Name: "jellyfin", Host: "nas", Source: "docker", State: services.StateRunning, LastStateChange: &after},
+		{Name: "nginx.service", Host: "nas", Source: "systemd", State: services.StateRunning, LastStateChange: &after},
+		{Name: "sonarr", Host: "nas", Source: "docker", State: services.StateRunning, LastStateChange: &after},
+		{Name: "radarr", Host: "nas", Source: "docker", State: services.StateRunning, LastStateChange: &after},
+		{Name: "redis", Host: "pi", Source: "docker", State: services.StateStopped},
+	}
+	withLastActions(svcs, history)
+
+	tests := []struct {
+		service    int
+		action     string
+		user       string
+		result     string
+		externally bool
+	}{
+		{0, "restart", "alice", "success", false}, // Started by the restart
+		{1, "stop", "bob", "success", true},       // Stopped from the dashboard, started by something else
+		{2, "restart", "alice", "failed", false},  // Only Docker start times are compared
+		{3, "restart", "carol", "running", false}, // The restart may be what started it
+		{4, "", "", "", false},                    // Never acted on
+		{5, "", "", "", false},                    // Same name on another host
+	}
+	for _, tt := range tests {
+		svc := svcs[tt.service]
+		if tt.action == "" {
+			if svc.LastAction != nil || svc.ExternallyRestarted {
+				t.Errorf("%s/%s: LastAction = %+v, ExternallyRestarted = %v, want none", svc.Host, svc.Name, svc.LastAction, svc.ExternallyRestarted)
+			}
+			continue
+		}
+		got := svc.LastAction
+		if got == nil || got.Action != tt.action || got.User != tt.user || got.Result != tt.result || got.Time.IsZero() {
+			t.Errorf("%s/%s: LastAction = %+v, want %s by %s (%s)", svc.Host, svc.Name, got, tt.action, tt.user, tt.result)
+		}
+		if svc.ExternallyRestarted != tt.externally {
+			t.Errorf("%s/%s: ExternallyRestarted = %v, want %v", svc.Host, svc.Name, svc.ExternallyRestarted, tt.externally)
+		}
+	}
+}
+
+// TestServiceActionHandler_RegisteredProvider tests that actions on a registered
+// source go through its provider, and are refused if it doesn't support actions.
+func TestServiceActionHandler_RegisteredProvider(t *testing.T) {
+	configJSON := `{"hosts": [{"name": "fakehost", "address": "192.168.1.50"}]}`
+	cleanup := setupTestConfig(t, configJSON)
+	defer cleanup()
+
+	restart := func() string {
+		body := strings.NewReader(`{"container_name": "widget", "service_name": "widget", "source": "fake", "host": "fakehost"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/services/restart", body)
+		w := httptest.NewRecorder()
+		ServiceActionHandler(w, req)
+		return w.Body.String()
+	}
+
+	actions := registerFakeProvider(t, services.Capabilities{Actions: true})
+	if body := restart(); !strings.Contains(body, "data: success") {
+		t.Errorf("restart did not succeed: %s", body)
+	} else if !strings.Contains(body, "event: status\ndata: running restart\n") {
+		t.Errorf("restart progress was not streamed: %s", body)
+	}
+	if len(*actions) != 1 || (*actions)[0] != "restart fakehost/widget" {
+		t.Errorf("actions = %v, want [restart fakehost/widget]", *actions)
+	}
+
+	actions = registerFakeProvider(t, services.Capabilities{})
+	if body := restart(); !strings.Contains(body, "restart is not supported for fake services") || !strings.Contains(body, "data: failed") {
+		t.Errorf("restart without action support: %s", body)
+	}
+	if len(*actions) != 0 {
+		t.Errorf("actions = %v, want none", *actions)
+	}
+}
+
+// benchmarkServices returns n services on host, each publishing two ports.
+func benchmarkServices(host string, n int) []services.ServiceInfo {
+	svcs := make([]services.ServiceInfo, n)
+	for i := range svcs {
+		port := uint16(8000 + i)
+		svcs[i] = services.ServiceInfo{
+			Name:          fmt.Sprintf("app_%d", i),
+			ContainerName: fmt.Sprintf("media-app_%d-1", i),
+			Project:       "media",
+			Source:        "fake",
+			Host:          host,
+			State:         "running",
+			Ports: []services.PortInfo{
+				{HostPort: port, ContainerPort: 80, Protocol: "tcp"},
+				{HostPort: port + 1000, ContainerPort: 443, Protocol: "tcp"},
+			},
+		}
+	}
+	return svcs
+}
+
+// BenchmarkGetAllServices benchmarks collecting 120 services from a fake
+// source on three hosts, with the built-in sources unregistered so nothing
+// outside the process is contacted. It should stay within a few hundred
+// allocs/op; building port URLs with fmt and Traefik candidates with a set
+// per service took it over 3000.
+func BenchmarkGetAllServices(b *testing.B) {
+	for _, reg := range services.Registered() {
+		services.Unregister(reg.Source)
+		b.Cleanup(func() { services.Register(reg) })
+	}
+	hosts := []string{"alpha", "beta", "gamma"}
+	svcsByHost := make(map[string][]services.ServiceInfo)
+	for _, host := range hosts {
+		svcsByHost[host] = benchmarkServices(host, 40)
+	}
+	services.Register(services.Registration{
+		Source: "fake",
+		Factory: func(cfg *config.Config, host *config.HostConfig) (services.Provider, error) {
+			// Copy so services enriched by one iteration don't carry into the next
+			svcs := append([]services.ServiceInfo(nil), svcsByHost[host.Name]...)
+			return &fakeProvider{host: host.Name, svcs: svcs}, nil
+		},
+	})
+	b.Cleanup(func() { services.Unregister("fake") })
+
+	cfg := &config.Config{}
+	for i, host := range hosts {
+		cfg.Hosts = append(cfg.Hosts, config.HostConfig{Name: host, Address: fmt.Sprintf("192.168.1.%d", 10+i)})
+	}
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := getAllServices(ctx, cfg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkApplyPortRemaps benchmarks moving 10 ports between 120 services.
+// It should stay around 15 allocs/op; logging every successful remap took it
+// over 50.
+func BenchmarkApplyPortRemaps(b *testing.B) {
+	base := benchmarkServices("alpha", 120)
+	var remaps []services.PortRemap
+	for i := 0; i < 10; i++ {
+		remaps = append(remaps, services.PortRemap{
+			SourceService: base[i].Name,
+			TargetService: base[60+i].Name,
+			Port:          base[i].Ports[0].HostPort,
+		})
+	}
+	svcList := make([]services.ServiceInfo, len(base))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		copy(svcList, base)
+		applyPortRemaps(svcList, remaps, false)
+	}
+}
+
+// TestServicesHandler_IncludeHidden tests that hidden services are only returned
+// to admins that ask for them.
+func TestServicesHandler_IncludeHidden(t *testing.T) {
+	cleanup := setupTestConfig(t, `{"hosts": [{"name": "fakehost", "address": "192.168.1.50"}]}`)
+	defer cleanup()
+
+	services.Register(services.Registration{
+		Source: "fake",
+		Order:  100,
+		Configured: func(host *config.HostConfig) bool {
+			return host.Name == "fakehost"
+		},
+		Factory: func(cfg *config.Config, host *config.HostConfig) (services.Provider, error) {
+			return &fakeProvider{host: host.Name, svcs: []services.ServiceInfo{
+				{Name: "widget", Source: "fake", Host: host.Name, State: "running"},
+				{Name: "secret", Source: "fake", Host: host.Name, State: "running", Hidden: true},
+			}}, nil
+		},
+	})
+	t.Cleanup(func() { services.Unregister("fake") })
+
+	admin := auth.User{IsAdmin: true, HasGlobalAccess: true}
+	viewer := auth.User{HasGlobalAccess: true}
+
+	tests := []struct {
+		name       string
+		query      string
+		user       *auth.User
+		wantStatus int
+		want       string // names of the fake services returned
+	}{
+		{"default", "", &admin, http.StatusOK, "widget"},
+		{"admin", "?include_hidden=true", &admin, http.StatusOK, "secret,widget"},
+		{"auth disabled", "?include_hidden=true", nil, http.StatusOK, "secret,widget"},
+		{"non-admin", "?include_hidden=true", &viewer, http.StatusForbidden, ""},
+		{"non-admin default", "", &viewer, http.StatusOK, "widget"},
+		{"invalid", "?include_hidden=maybe", &admin, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The context is canceled so Docker is not contacted; the fake ignores it
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if tt.user != nil {
+				ctx = context.WithValue(ctx, authUserContextKey, tt.user)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/services"+tt.query, nil).WithContext(ctx)
+			w := httptest.NewRecorder()
+			ServicesHandler(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var svcList []services.ServiceInfo
+			if err := json.NewDecoder(w.Body).Decode(&svcList); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			var names []string
+			for _, svc := range svcList {
+				if svc.Source != "fake" {
+					continue
+				}
+				names = append(names, svc.Name)
+				if svc.Hidden != (svc.Name == "secret") {
+					t.Errorf("%s hidden = %v", svc.Name, svc.Hidden)
+				}
+			}
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Errorf("services = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestServiceActionHandler_HiddenService tests that only admins can act on hidden services.
+func TestServiceActionHandler_HiddenService(t *testing.T) {
+	cleanup := setupTestConfig(t, `{"hosts": [{"name": "fakehost", "address": "192.168.1.50"}]}`)
+	defer cleanup()
+	actions := registerFakeProvider(t, services.Capabilities{Actions: true})
+
+	saved := serviceHidden
+	serviceHidden = func(ctx context.Context, cfg *config.Config, source, host, containerName string) bool {
+		return containerName == "widget"
+	}
+	defer func() { serviceHidden = saved }()
+
+	restart := func(user *auth.User) *httptest.ResponseRecorder {
+		body := strings.NewReader(`{"container_name": "widget", "service_name": "widget", "source": "fake", "host": "fakehost"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/services/restart", body)
+		req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, user))
+		w := httptest.NewRecorder()
+		ServiceActionHandler(w, req)
+		return w
+	}
+
+	if w := restart(&auth.User{HasGlobalAccess: true}); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if len(*actions) != 0 {
+		t.Fatalf("non-admin ran actions: %v", *actions)
+	}
+
+	if w := restart(&auth.User{IsAdmin: true, HasGlobalAccess: true}); !strings.Contains(w.Body.String(), "data: success") {
+		t.Errorf("admin restart did not succeed: %s", w.Body.String())
+	}
+	if len(*actions) != 1 || (*actions)[0] != "restart fakehost/widget" {
+		t.Errorf("actions = %v, want [restart fakehost/widget]", *actions)
+	}
+}
+
+// TestDockerLogsHandler_HiddenService tests that non-admins cannot read the logs of hidden containers.
+func TestDockerLogsHandler_HiddenService(t *testing.T) {
+	saved := serviceHidden
+	serviceHidden = func(ctx context.Context, cfg *config.Config, source, host, containerName string) bool {
+		return source == "docker" && containerName == "secret"
+	}
+	defer func() { serviceHidden = saved }()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/logs?container=secret", nil)
+	req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, &auth.User{HasGlobalAccess: true}))
+	w := httptest.NewRecorder()
+	DockerLogsHandler(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+// TestProviderLogsHandler tests that logs of a registered source are streamed
+// through its provider, and that requests it cannot serve are refused.
+func TestProviderLogsHandler(t *testing.T) {
+	cleanup := setupTestConfig(t, `{"hosts": [{"name": "fakehost", "address": "192.168.1.50"}]}`)
+	defer cleanup()
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/logs/provider?"+query, nil)
+		w := httptest.NewRecorder()
+		ProviderLogsHandler(w, req)
+		return w
+	}
+
+	registerFakeProvider(t, services.Capabilities{Logs: true})
+	if w := get("source=fake&host=fakehost&service=widget"); w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("logs: status %d, content type %q: %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	if w := get("source=fake&host=fakehost"); w.Code != http.StatusBadRequest {
+		t.Errorf("missing service: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := get("source=fake&host=nowhere&service=widget"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown host: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	registerFakeProvider(t, services.Capabilities{})
+	if w := get("source=fake&host=fakehost&service=widget"); !strings.Contains(w.Body.String(), "logs are not supported for fake services") {
+		t.Errorf("logs without support: %s", w.Body.String())
+	}
+}
+
+// TestNetworksHandler_RequiresAdmin tests that only admins can list networks.
+func TestNetworksHandler_RequiresAdmin(t *testing.T) {
+	viewer := auth.User{HasGlobalAccess: true}
+	for name, user := range map[string]*auth.User{"auth disabled": nil, "non-admin": &viewer} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if user != nil {
+				ctx = context.WithValue(ctx, authUserContextKey, user)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/networks", nil).WithContext(ctx)
+			w := httptest.NewRecorder()
+			NetworksHandler(w, req)
+
+			if w.Code != http.StatusForbidden {
+				t.Errorf("Status code = %d, want %d", w.Code, http.StatusForbidden)
+			}
+		})
+	}
+}
+
+// TestNetworksHandler_RemoteHost tests that networks are only read on the local host.
+func TestNetworksHandler_RemoteHost(t *testing.T) {
+	cleanup := setupTestConfig(t, `{"hosts": [{"name": "nas", "address": "192.168.1.50"}]}`)
+	defer cleanup()
+
+	admin := auth.User{IsAdmin: true, HasGlobalAccess: true}
+	ctx := context.WithValue(context.Background(), authUserContextKey, &admin)
+	req := httptest.NewRequest(http.MethodGet, "/api/networks?host=nas", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	NetworksHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Status code = %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body.String())
+	}
+}
+
+// TestWithoutNetworkAddresses tests that container IPs are removed but network names kept.
+func TestWithoutNetworkAddresses(t *testing.T) {
+	networks := []services.NetworkAttachment{{Name: "media", IPAddress: "172.20.0.3"}}
+	svcList := withoutNetworkAddresses([]services.ServiceInfo{
+		{Name: "jellyfin", Networks: networks},
+		{Name: "sonarr"},
+	})
+
+	if got := svcList[0].Networks; len(got) != 1 || got[0].Name != "media" || got[0].IPAddress != "" {
+		t.Errorf("jellyfin networks = %+v, want media without an address", got)
+	}
+	if networks[0].IPAddress != "172.20.0.3" {
+		t.Error("withoutNetworkAddresses modified the provider's attachments")
+	}
+	if svcList[1].Networks != nil {
+		t.Errorf("sonarr networks = %+v, want none", svcList[1].Networks)
+	}
+}
+
+// fakeOrphanCleaner lists fixed orphans and records removals.
+type fakeOrphanCleaner struct {
+	orphans []docker.OrphanedContainer
+	removed []string
+}
+
+func (f *fakeOrphanCleaner) FindOrphans(ctx context.Context) ([]docker.OrphanedContainer, error) {
+	return f.orphans, nil
+}
+
+func (f *fakeOrphanCleaner) RemoveOrphans(ctx context.Context, ids []string, report func(docker.OrphanedContainer, error)) error {
+	for _, id := range ids {
+		found := false
+		for _, orphan := range f.orphans {
+			if orphan.ID == id {
+				found = true
+				f.removed = append(f.removed, id)
+				report(orphan, nil)
+			}
+		}
+		if !found {
+			report(docker.OrphanedContainer{ID: id}, docker.ErrNotOrphaned)
+		}
+	}
+	return nil
+}
+
+func useFakeOrphanCleaner(t *testing.T) *fakeOrphanCleaner {
+	t.Helper()
+	fake := &fakeOrphanCleaner{orphans: []docker.OrphanedContainer{
+		{ID: "abc123", ContainerName: "old-web-1", Project: "old", Service: "web", WorkingDir: "/srv/old"},
+	}}
+	saved := newOrphanCleaner
+	newOrphanCleaner = func(cfg *config.Config) (orphanCleaner, func(), error) {
+		return fake, func() {}, nil
+	}
+	t.Cleanup(func() { newOrphanCleaner = saved })
+	return fake
+}
+
+func cleanupRequest(user *auth.User, body string) *http.Request {
+	ctx := context.Background()
+	if user != nil {
+		ctx = context.WithValue(ctx, authUserContextKey, user)
+	}
+	return httptest.NewRequest(http.MethodPost, "/api/services/cleanup", strings.NewReader(body)).WithContext(ctx)
+}
+
+// TestCleanupHandler_RequiresAdmin tests that only admins can remove containers.
+func TestCleanupHandler_RequiresAdmin(t *testing.T) {
+	fake := useFakeOrphanCleaner(t)
+	viewer := auth.User{HasGlobalAccess: true}
+
+	w := httptest.NewRecorder()
+	CleanupHandler(w, cleanupRequest(&viewer, `{"confirm": true, "containers": ["abc123"]}`))
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Status code = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if len(fake.removed) != 0 {
+		t.Errorf("removed = %v, want none", fake.removed)
+	}
+}
+
+// TestCleanupHandler_ListsWithoutConfirm tests that nothing is removed without confirm.
+func TestCleanupHandler_ListsWithoutConfirm(t *testing.T) {
+	fake := useFakeOrphanCleaner(t)
+	admin := auth.User{IsAdmin: true, HasGlobalAccess: true}
+
+	for _, body := range []string{"", `{}`, `{"containers": ["abc123"]}`} {
+		w := httptest.NewRecorder()
+		CleanupHandler(w, cleanupRequest(&admin, body))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("body %q: status = %d, want %d: %s", body, w.Code, http.StatusOK, w.Body.String())
+		}
+		var orphans []docker.OrphanedContainer
+		if err := json.NewDecoder(w.Body).Decode(&orphans); err != nil {
+			t.Fatalf("body %q: failed to decode response: %v", body, err)
+		}
+		if len(orphans) != 1 || orphans[0].ContainerName != "old-web-1" {
+			t.Errorf("body %q: orphans = %+v", body, orphans)
+		}
+	}
+	if len(fake.removed) != 0 {
+		t.Errorf("removed = %v, want none", fake.removed)
+	}
+}
+
+// TestCleanupHandler_ConfirmRequiresContainers tests that confirm must name what to remove.
+func TestCleanupHandler_ConfirmRequiresContainers(t *testing.T) {
+	useFakeOrphanCleaner(t)
+	admin := auth.User{IsAdmin: true, HasGlobalAccess: true}
+
+	w := httptest.NewRecorder()
+	CleanupHandler(w, cleanupRequest(&admin, `{"confirm": true}`))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Status code = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+// TestCleanupHandler_Removes tests that confirmed removals are streamed and stale IDs skipped.
+func TestCleanupHandler_Removes(t *testing.T) {
+	fake := useFakeOrphanCleaner(t)
+	admin := auth.User{IsAdmin: true, HasGlobalAccess: true, Email: "admin@example.com"}
+
+	w := httptest.NewRecorder()
+	CleanupHandler(w, cleanupRequest(&admin, `{"confirm": true, "containers": ["abc123", "def456"]}`))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status code = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if len(fake.removed) != 1 || fake.removed[0] != "abc123" {
+		t.Errorf("removed = %v, want [abc123]", fake.removed)
+	}
+	body := w.Body.String()
+	for _, want := range []string{"event: status\ndata: Removed old-web-1", "event: warning\ndata: Skipped def456", "event: complete\ndata: success"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("response missing %q:\n%s", want, body)
+		}
+	}
+}
+
+// TestServicePermissions_Enforced tests that a group granting a service with
+// only some permissions lists it, but keeps its logs and actions to the
+// users granted those.
+func TestServicePermissions_Enforced(t *testing.T) {
+	cleanup := setupTestConfig(t, `{"hosts": [{"name": "fakehost", "address": "192.168.1.50"}]}`)
+	defer cleanup()
+	registerFakeProvider(t, services.Capabilities{Logs: true, Actions: true})
+	useExecutor(t, &recordingExecutor{})
+	origCache := servicesCache
+	servicesCache = newSnapshotCache()
+	defer func() { servicesCache = origCache }()
+
+	userWith := func(permissions ...string) *auth.User {
+		return &auth.User{
+			ID:                 "household",
+			AllowedServices:    map[string][]string{"fakehost": {"widget"}},
+			ServicePermissions: map[string]map[string][]string{"fakehost": {"widget": permissions}},
+		}
+	}
+	serve := func(handler http.HandlerFunc, req *http.Request, user *auth.User) *httptest.ResponseRecorder {
+		req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, user))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+	logs := func(user *auth.User) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/logs/provider?source=fake&host=fakehost&service=widget", nil)
+		return serve(ProviderLogsHandler, req, user).Code
+	}
+	control := func(user *auth.User) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/services/restart?dry_run=true",
+			strings.NewReader(`{"container_name": "widget", "service_name": "widget", "source": "fake", "host": "fakehost"}`))
+		return serve(ServiceActionHandler, req, user).Code
+	}
+
+	viewer := userWith("view")
+	body := serve(ServicesHandler, httptest.NewRequest(http.MethodGet, "/api/services", nil), viewer).Body.String()
+	if !strings.Contains(body, `"name":"widget"`) {
+		t.Errorf("services of a view-only user = %s, want widget listed", body)
+	}
+	if code := logs(viewer); code != http.StatusForbidden {
+		t.Errorf("logs with view only: status = %d, want %d", code, http.StatusForbidden)
+	}
+	if code := control(viewer); code != http.StatusForbidden {
+		t.Errorf("action with view only: status = %d, want %d", code, http.StatusForbidden)
+	}
+
+	reader := userWith("view", "logs")
+	if code := logs(reader); code != http.StatusOK {
+		t.Errorf("logs with logs: status = %d, want %d", code, http.StatusOK)
+	}
+	if code := control(reader); code != http.StatusForbidden {
+		t.Errorf("action with logs: status = %d, want %d", code, http.StatusForbidden)
+	}
+	for _, handler := range []struct {
+		name string
+		path string
+		fn   http.HandlerFunc
+	}{
+		{"systemd", "/api/logs/systemd?unit=widget&host=fakehost", SystemdLogsHandler},
+		{"docker", "/api/logs?container=widget&service=widget", DockerLogsHandler},
+		{"homeassistant", "/api/logs/homeassistant?service=widget&host=fakehost", HomeAssistantLogsHandler},
+		{"traefik", "/api/logs/traefik?service=widget&host=fakehost", TraefikLogsHandler},
+	} {
+		if code := serve(handler.fn, httptest.NewRequest(http.MethodGet, handler.path, nil), viewer).Code; code != http.StatusForbidden {
+			t.Errorf("%s logs with view only: status = %d, want %d", handler.name, code, http.StatusForbidden)
+		}
+	}
+
+	if code := control(userWith("view", "control")); code != http.StatusOK {
+		t.Errorf("action with control: status = %d, want %d", code, http.StatusOK)
+	}
+}
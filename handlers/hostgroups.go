@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"home_server_dashboard/auth"
+	"home_server_dashboard/config"
+	"home_server_dashboard/resilience"
+	"home_server_dashboard/services"
+	"home_server_dashboard/sshmux"
+)
+
+// HostReporter reports whether hosts answered their last poll. It is
+// implemented by the monitor.
+type HostReporter interface {
+	// HostReachable returns whether host was reachable when last polled, and
+	// false for known if it hasn't been polled.
+	HostReachable(host string) (reachable, known bool)
+}
+
+// HostGroup is a host and its services, as returned by
+// GET /api/services?group=host.
+type HostGroup struct {
+	Name string `json:"name"`
+	// Reachable is whether the host answered its last poll, or null if the
+	// monitor doesn't poll it.
+	Reachable        *bool `json:"reachable"`
+	HasDocker        bool  `json:"has_docker"`
+	HasSystemd       bool  `json:"has_systemd"`
+	HasHomeAssistant bool  `json:"has_homeassistant"`
+	TraefikEnabled   bool  `json:"traefik_enabled"`
+	// Platform is the host's operating system and architecture, or null
+	// until a provider on the host reported it.
+	Platform *services.Platform `json:"platform"`
+	// WakeCapable and RebootCapable are whether the dashboard can wake or
+	// reboot the host. It can do neither yet, so they are always false.
+	WakeCapable   bool `json:"wake_capable"`
+	RebootCapable bool `json:"reboot_capable"`
+	// Maintenance is the maintenance window the host is in, or null.
+	Maintenance *services.MaintenanceWindow `json:"maintenance"`
+	// Breaker is the circuit breaker for reads from the host. It stays
+	// closed for the local host, which is read directly.
+	Breaker resilience.Snapshot `json:"breaker"`
+	// SSH is the shared SSH connection to a remote host, left out until a
+	// command was run over it.
+	SSH      *sshmux.Stats          `json:"ssh,omitempty"`
+	Services []services.ServiceInfo `json:"services"`
+}
+
+// hostCapabilities fills in what the dashboard collects from host, whether
+// it is reachable and its maintenance window, from the config and reporter
+// (which may be nil).
+func hostCapabilities(cfg *config.Config, host *config.HostConfig, reporter HostReporter) HostGroup {
+	group := HostGroup{
+		Name: host.Name,
+		// Docker is read from the local socket only
+		HasDocker:        host.HasDocker() && host.Name == cfg.GetLocalHostName(),
+		HasSystemd:       host.HasSystemd(),
+		HasHomeAssistant: host.HasHomeAssistant(),
+		TraefikEnabled:   host.HasTraefik(),
+		Platform:         hostPlatforms.get(host.Name),
+		Breaker:          resilience.HostSnapshot(host.Name),
+		Services:         []services.ServiceInfo{},
+	}
+	if reporter != nil {
+		if reachable, known := reporter.HostReachable(host.Name); known {
+			group.Reachable = &reachable
+		}
+	}
+	if !host.IsLocal() {
+		if stats := sshmux.HostStats(host.GetSSHArgs(), host.GetSSHTarget()); stats.State != "" {
+			group.SSH = &stats
+		}
+	}
+	if scheduler, ok := reporter.(MaintenanceScheduler); ok {
+		if window, active := scheduler.Maintenance(host.Name); active {
+			group.Maintenance = &window
+		}
+	}
+	return group
+}
+
+// groupByHost groups svcList by host, in config order. Enabled hosts with no
+// services in svcList are included, so they don't disappear when all their
+// services are hidden or down; a user without global access only gets the
+// hosts they may access a service on. Services of hosts that aren't
+// configured, such as the local Docker host, come last.
+func groupByHost(cfg *config.Config, svcList []services.ServiceInfo, user *auth.User) []HostGroup {
+	reporter, _ := stateTracker.(HostReporter)
+
+	var groups []HostGroup
+	index := make(map[string]int)
+	for i := range cfg.Hosts {
+		host := &cfg.Hosts[i]
+		if !host.IsEnabled() || (user != nil && !user.CanAccessHost(host.Name)) {
+			continue
+		}
+		index[host.Name] = len(groups)
+		groups = append(groups, hostCapabilities(cfg, host, reporter))
+	}
+
+	for _, svc := range svcList {
+		i, ok := index[svc.Host]
+		if !ok {
+			i = len(groups)
+			index[svc.Host] = i
+			groups = append(groups, hostCapabilities(cfg, hostOrLocal(cfg, svc.Host), reporter))
+		}
+		groups[i].Services = append(groups[i].Services, svc)
+	}
+	return groups
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/config"
+	"home_server_dashboard/resilience"
+	"home_server_dashboard/services"
+)
+
+// fakeHostReporter is a fakeStateTracker that also reports host reachability.
+type fakeHostReporter struct {
+	fakeStateTracker
+	reachable map[string]bool
+}
+
+func (f fakeHostReporter) HostReachable(host string) (bool, bool) {
+	reachable, known := f.reachable[host]
+	return reachable, known
+}
+
+func hostGroupsConfig() *config.Config {
+	disabled := false
+	return &config.Config{Hosts: []config.HostConfig{
+		{Name: "nas", Address: "localhost", SystemdServices: []string{"nginx.service"}, Traefik: config.TraefikConfig{Enabled: true}},
+		{Name: "pi", Address: "192.168.1.20", HomeAssistant: &config.HomeAssistantConfig{LongLivedToken: "token"}},
+		{Name: "backup", Address: "192.168.1.30"},
+		{Name: "old", Address: "192.168.1.40", Enabled: &disabled},
+	}}
+}
+
+func TestGroupByHost_Capabilities(t *testing.T) {
+	original := stateTracker
+	defer SetStateTracker(original)
+	SetStateTracker(fakeHostReporter{reachable: map[string]bool{"nas": true, "pi": false}})
+
+	svcList := []services.ServiceInfo{
+		{Name: "nginx.service", Host: "nas"},
+		{Name: "homeassistant", Host: "pi"},
+		{Name: "jellyfin", Host: "nas"},
+	}
+	groups := groupByHost(hostGroupsConfig(), svcList, nil)
+
+	var names []string
+	for _, g := range groups {
+		names = append(names, g.Name)
+	}
+	if len(groups) != 3 {
+		t.Fatalf("groups = %v, want nas, pi and backup in config order", names)
+	}
+	nas, pi, backup := groups[0], groups[1], groups[2]
+
+	if nas.Name != "nas" || !nas.HasDocker || !nas.HasSystemd || nas.HasHomeAssistant || !nas.TraefikEnabled || len(nas.Services) != 2 {
+		t.Errorf("nas = %+v", nas)
+	}
+	if pi.HasDocker || pi.HasSystemd || !pi.HasHomeAssistant || pi.TraefikEnabled || len(pi.Services) != 1 {
+		t.Errorf("pi = %+v", pi)
+	}
+	if nas.Reachable == nil || !*nas.Reachable || pi.Reachable == nil || *pi.Reachable {
+		t.Errorf("reachable: nas = %v, pi = %v, want true and false", nas.Reachable, pi.Reachable)
+	}
+
+	// A host with nothing to show is still listed, with an empty list
+	if backup.Name != "backup" || backup.Services == nil || len(backup.Services) != 0 || backup.Reachable != nil {
+		t.Errorf("backup = %+v, want no services and unknown reachability", backup)
+	}
+	if nas.WakeCapable || nas.RebootCapable {
+		t.Errorf("nas = %+v, want neither wake nor reboot", nas)
+	}
+}
+
+func TestGroupByHost_ScopedUser(t *testing.T) {
+	user := &auth.User{ID: "guest", AllowedServices: map[string][]string{"pi": {"homeassistant"}}}
+	svcList := filterServicesForUser([]services.ServiceInfo{
+		{Name: "nginx.service", Host: "nas"},
+		{Name: "homeassistant", Host: "pi"},
+	}, user)
+
+	groups := groupByHost(hostGroupsConfig(), svcList, user)
+	if len(groups) != 1 || groups[0].Name != "pi" || len(groups[0].Services) != 1 {
+		t.Errorf("groups = %+v, want only pi", groups)
+	}
+
+	// An administrator sees every enabled host
+	if groups := groupByHost(hostGroupsConfig(), nil, &auth.User{ID: "admin", IsAdmin: true, HasGlobalAccess: true}); len(groups) != 3 {
+		t.Errorf("admin: %d groups, want 3", len(groups))
+	}
+}
+
+func TestGroupByHost_UnconfiguredHost(t *testing.T) {
+	cfg := &config.Config{Hosts: []config.HostConfig{{Name: "pi", Address: "192.168.1.20"}}}
+	groups := groupByHost(cfg, []services.ServiceInfo{{Name: "jellyfin", Host: "localhost"}}, nil)
+
+	if len(groups) != 2 || groups[1].Name != "localhost" || !groups[1].HasDocker || len(groups[1].Services) != 1 {
+		t.Errorf("groups = %+v, want the local Docker host after pi", groups)
+	}
+}
+
+func TestServicesHandler_InvalidGroup(t *testing.T) {
+	setupTestConfig(t, `{"hosts": []}`)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/services?group=service", nil)
+	w := httptest.NewRecorder()
+	ServicesHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestGroupByHost_Breaker(t *testing.T) {
+	resilience.Configure(1, time.Minute)
+	defer resilience.Configure(resilience.DefaultThreshold, resilience.DefaultCooldown)
+	resilience.Do(context.Background(), "pi", func(ctx context.Context) error {
+		return errors.New("ssh: connection reset")
+	})
+
+	groups := groupByHost(hostGroupsConfig(), nil, nil)
+	if len(groups) != 3 {
+		t.Fatalf("groups = %+v, want 3", groups)
+	}
+	if pi := groups[1]; pi.Breaker.State != resilience.StateOpen || pi.Breaker.RetryAt == nil {
+		t.Errorf("pi breaker = %+v, want open with a retry time", pi.Breaker)
+	}
+	if nas := groups[0]; nas.Breaker.State != resilience.StateClosed {
+		t.Errorf("nas breaker = %+v, want closed", nas.Breaker)
+	}
+
+	data, err := json.Marshal(groups[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"breaker":{"state":"open"`) {
+		t.Errorf("JSON = %s, want the breaker state", data)
+	}
+}
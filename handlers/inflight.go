@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/realip"
+	"home_server_dashboard/services"
+)
+
+// errActionCancelled is the cause of an action cancelled by an administrator.
+var errActionCancelled = errors.New("action cancelled")
+
+// inFlightActions tracks the service actions that are running, so an
+// administrator can cancel them.
+type inFlightActions struct {
+	mu      sync.Mutex
+	running map[services.Key]map[*context.CancelCauseFunc]struct{} // key: host and name
+}
+
+func newInFlightActions() *inFlightActions {
+	return &inFlightActions{running: make(map[services.Key]map[*context.CancelCauseFunc]struct{})}
+}
+
+// inFlight holds the actions started by ServiceActionHandler.
+var inFlight = newInFlightActions()
+
+// start registers an action on the service name of host. It returns a
+// context that is cancelled when the action is, and a function the caller
+// must call once the action is done.
+func (a *inFlightActions) start(ctx context.Context, host, name string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	key := services.Key{Host: host, Name: name}
+
+	a.mu.Lock()
+	if a.running[key] == nil {
+		a.running[key] = make(map[*context.CancelCauseFunc]struct{})
+	}
+	a.running[key][&cancel] = struct{}{}
+	a.mu.Unlock()
+
+	return ctx, func() {
+		a.mu.Lock()
+		delete(a.running[key], &cancel)
+		if len(a.running[key]) == 0 {
+			delete(a.running, key)
+		}
+		a.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// cancel cancels every running action on the service name of host with
+// cause, returning how many there were.
+func (a *inFlightActions) cancel(host, name string, cause error) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	running := a.running[services.Key{Host: host, Name: name}]
+	for cancel := range running {
+		(*cancel)(cause)
+	}
+	return len(running)
+}
+
+// CancelActionHandler handles POST /api/services/{host}/{name}/cancel requests.
+// It aborts the start/stop/restart actions running on a service, killing the
+// commands they run; their streams complete with "cancelled". Only
+// administrators may cancel actions.
+func CancelActionHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil || !user.IsAdmin {
+		http.Error(w, "Access denied: administrator privileges required to cancel actions", http.StatusForbidden)
+		return
+	}
+
+	host, name := r.PathValue("host"), r.PathValue("name")
+	owner := actionOwner(r.Context())
+	cancelled := inFlight.cancel(host, name, fmt.Errorf("%w by %s", errActionCancelled, owner))
+	if cancelled == 0 {
+		http.Error(w, "No action is running on this service", http.StatusNotFound)
+		return
+	}
+	log.Printf("Audit: user=%s ip=%s action=cancel target=%s/%s actions=%d", owner, realip.FromRequest(r), host, name, cancelled)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"cancelled": cancelled})
+}
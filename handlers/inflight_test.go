@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/config"
+	"home_server_dashboard/locks"
+	"home_server_dashboard/services"
+)
+
+// useSlowDocker puts a fake docker on PATH that starts a child sleeping for
+// a minute, writes the child's PID to the returned file and waits for it.
+func useSlowDocker(t *testing.T) string {
+	t.Helper()
+	bin := t.TempDir()
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+	script := "#!/bin/sh\nsleep 60 &\necho $! > " + pidFile + "\nwait\n"
+	if err := os.WriteFile(filepath.Join(bin, "docker"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return pidFile
+}
+
+// waitForChild returns the PID the fake docker wrote once it is there.
+func waitForChild(t *testing.T, pidFile string) int {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		data, err := os.ReadFile(pidFile)
+		if err != nil {
+			continue
+		}
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+			return pid
+		}
+	}
+	t.Fatal("fake docker did not start its child")
+	return 0
+}
+
+// assertKilled fails unless the process pid exits (or is left a zombie for
+// init to reap) within a few seconds.
+func assertKilled(t *testing.T, pid int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+		if err != nil {
+			return // Gone
+		}
+		// The state follows the parenthesized command name
+		if fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:])); len(fields) > 0 && fields[0] == "Z" {
+			return
+		}
+	}
+	t.Errorf("child process %d outlived the action", pid)
+}
+
+func TestComposeStep_TimeoutKillsProcessGroup(t *testing.T) {
+	pidFile := useSlowDocker(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	step := composeStep(t.TempDir(), "Running docker compose up -d web...", false, "up", "-d", "web")
+
+	start := time.Now()
+	if err := step.run(ctx, discardEvents); err == nil {
+		t.Fatal("run() = nil, want the timeout to fail the step")
+	}
+	if elapsed := time.Since(start); elapsed > commandWaitDelay {
+		t.Errorf("run() returned after %s, want soon after the timeout", elapsed)
+	}
+	assertKilled(t, waitForChild(t, pidFile))
+}
+
+// slowComposePlanner plans a locked compose step running the fake docker.
+func slowComposePlanner(ctx context.Context, cfg *config.Config, req ServiceActionRequest, action string, sendEvent func(string, string)) (*actionPlan, error) {
+	dir, err := os.MkdirTemp("", "compose")
+	if err != nil {
+		return nil, err
+	}
+	plan := &actionPlan{locked: true, steps: []plannedStep{composeStep(dir, "Running docker compose up...", false, "up", "-d", req.ServiceName)}}
+	plan.onClose(func() { os.RemoveAll(dir) })
+	return plan, nil
+}
+
+// startSlowAction runs a restart of widget with the slow compose planner
+// and returns a channel that yields the response once it completes.
+func startSlowAction(t *testing.T, body string) <-chan *httptest.ResponseRecorder {
+	t.Helper()
+	original := actionPlanners["fake"]
+	actionPlanners["fake"] = slowComposePlanner
+	t.Cleanup(func() {
+		if original == nil {
+			delete(actionPlanners, "fake")
+		} else {
+			actionPlanners["fake"] = original
+		}
+	})
+
+	result := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		req := httptest.NewRequest(http.MethodPost, "/api/services/restart", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		ServiceActionHandler(w, req)
+		result <- w
+	}()
+	return result
+}
+
+// assertUnlocked fails if the project lock of media on fakehost is held.
+func assertUnlocked(t *testing.T) {
+	t.Helper()
+	if holder, busy := locks.Default().Holder("fakehost", "media"); busy {
+		t.Errorf("project lock still held by %s", holder)
+	}
+}
+
+func TestServiceActionHandler_Cancel(t *testing.T) {
+	cleanup := setupTestConfig(t, `{"hosts": [{"name": "fakehost", "address": "192.168.1.50"}]}`)
+	defer cleanup()
+	registerFakeProvider(t, services.Capabilities{Actions: true})
+	pidFile := useSlowDocker(t)
+
+	result := startSlowAction(t, `{"service_name": "widget", "source": "fake", "host": "fakehost", "project": "media"}`)
+	child := waitForChild(t, pidFile)
+
+	cancelRequest := func(user *auth.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/services/fakehost/widget/cancel", nil)
+		req.SetPathValue("host", "fakehost")
+		req.SetPathValue("name", "widget")
+		if user != nil {
+			req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, user))
+		}
+		w := httptest.NewRecorder()
+		CancelActionHandler(w, req)
+		return w
+	}
+
+	if w := cancelRequest(&auth.User{Name: "bob"}); w.Code != http.StatusForbidden {
+		t.Errorf("cancel by a non-admin: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := cancelRequest(&auth.User{Name: "alice", IsAdmin: true}); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"cancelled":1`) {
+		t.Errorf("cancel: status %d, body %s", w.Code, w.Body.String())
+	}
+
+	select {
+	case w := <-result:
+		body := w.Body.String()
+		if !strings.Contains(body, "data: action cancelled by alice") || !strings.Contains(body, "event: complete\ndata: cancelled") {
+			t.Errorf("body = %s, want a cancelled completion", body)
+		}
+	case <-time.After(commandWaitDelay):
+		t.Fatal("action still running after it was cancelled")
+	}
+	assertKilled(t, child)
+	assertUnlocked(t)
+
+	if w := cancelRequest(&auth.User{Name: "alice", IsAdmin: true}); w.Code != http.StatusNotFound {
+		t.Errorf("cancel with nothing running: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestServiceActionHandler_RequestTimeoutKills(t *testing.T) {
+	cleanup := setupTestConfig(t, `{"hosts": [{"name": "fakehost", "address": "192.168.1.50"}]}`)
+	defer cleanup()
+	registerFakeProvider(t, services.Capabilities{Actions: true})
+	pidFile := useSlowDocker(t)
+
+	result := startSlowAction(t, `{"service_name": "widget", "source": "fake", "host": "fakehost", "project": "media", "timeout": 1}`)
+	child := waitForChild(t, pidFile)
+
+	select {
+	case w := <-result:
+		body := w.Body.String()
+		if !strings.Contains(body, "no response after 1s") || !strings.Contains(body, "event: complete\ndata: failed") {
+			t.Errorf("body = %s, want a timeout failure", body)
+		}
+	case <-time.After(time.Second + commandWaitDelay):
+		t.Fatal("action still running after its timeout")
+	}
+	assertKilled(t, child)
+	assertUnlocked(t)
+}
+
+func TestServiceActionRequest_ValidateTimeout(t *testing.T) {
+	cfg := &config.Config{Hosts: []config.HostConfig{{Name: "nas", Address: "localhost"}}}
+	req := ServiceActionRequest{ContainerName: "web", ServiceName: "web", Source: "docker", Host: "nas", Timeout: -1}
+	if err := req.Validate(cfg); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("Validate() = %v, want a timeout error", err)
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/services/docker"
+	"home_server_dashboard/testharness"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestServicesHandler_Integration collects the services of the test harness
+// through the real providers and compares the response with a golden file
+// per scenario. Run with -update to rewrite them.
+func TestServicesHandler_Integration(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		setup func(h *testharness.Harness)
+	}{
+		{name: "healthy"},
+		{name: "host_times_out", setup: func(h *testharness.Harness) { h.HostTimesOut(testharness.RemoteHost) }},
+		{name: "remap_missing_target", setup: func(h *testharness.Harness) { h.RemapToMissingTarget("gluetun", 8080, "transmission") }},
+		{name: "traefik_error", setup: func(h *testharness.Harness) { h.TraefikFails() }},
+		{name: "container_exited", setup: func(h *testharness.Harness) { h.Docker.Exit("jellyfin", 137) }},
+		{name: "grouped_by_host", query: "?group=host"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := testharness.New(t)
+			if tt.setup != nil {
+				tt.setup(h)
+			}
+			withHarness(t, h)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/services"+tt.query, nil)
+			w := httptest.NewRecorder()
+			ServicesHandler(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Status = %d: %s", w.Code, w.Body.String())
+			}
+
+			got := normalizeTimestamps(t, w.Body.Bytes())
+			golden := filepath.Join("testdata", "services_"+tt.name+".json")
+			if *update {
+				if err := os.MkdirAll("testdata", 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("reading golden file (run with -update to create it): %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("response differs from %s (run with -update to accept it):\n%s", golden, got)
+			}
+		})
+	}
+}
+
+// TestServicesHandler_Deterministic collects the same services from backends
+// listing containers, ports, remap labels and Traefik routes in opposite
+// orders, and expects byte-identical responses, so the ETag of unchanged
+// services doesn't change between collections.
+func TestServicesHandler_Deterministic(t *testing.T) {
+	collect := func(reversed bool) []byte {
+		h := testharness.New(t)
+		containers := []testharness.Container{
+			{Project: "arr", Service: "vpn", Image: "qmcgaw/gluetun:v3", Ports: []uint16{9696, 6881, 8989, 7878},
+				Labels: map[string]string{
+					docker.LabelRemapPortPrefix + ".9696": "prowlarr",
+					docker.LabelRemapPortPrefix + ".8989": "sonarr",
+					docker.LabelRemapPortPrefix + ".7878": "sonarr",
+				}},
+			{Project: "arr", Service: "sonarr", Image: "linuxserver/sonarr:4"},
+			{Project: "arr", Service: "prowlarr", Image: "linuxserver/prowlarr:1"},
+		}
+		hostnames := []string{"tv.home.lan", "sonarr.home.lan", "arr.home.lan"}
+		if reversed {
+			slices.Reverse(containers)
+			slices.Reverse(containers[len(containers)-1].Ports)
+			slices.Reverse(hostnames)
+		}
+		for _, c := range containers {
+			h.Docker.Add(c)
+		}
+		for _, hostname := range hostnames {
+			h.Traefik.Route("sonarr", hostname)
+		}
+		withHarness(t, h)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/services", nil)
+		w := httptest.NewRecorder()
+		ServicesHandler(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Status = %d: %s", w.Code, w.Body.String())
+		}
+		return normalizeTimestamps(t, w.Body.Bytes())
+	}
+
+	first := collect(false)
+	for range 3 {
+		if again := collect(true); !bytes.Equal(first, again) {
+			t.Fatalf("collections differ:\n%s\nthen:\n%s", first, again)
+		}
+	}
+}
+
+// withHarness makes the handlers collect services from h for a test.
+func withHarness(t *testing.T, h *testharness.Harness) {
+	t.Helper()
+	origCache, origConfig, origTracker := servicesCache, configSource, stateTracker
+	servicesCache = newSnapshotCache()
+	stateTracker = nil
+	traefikMappings.reset()
+	SetConfigSource(func() *config.Config { return h.Config })
+	SetSourceRegistry(h.Registry())
+	t.Cleanup(func() {
+		servicesCache = origCache
+		configSource = origConfig
+		stateTracker = origTracker
+		SetSourceRegistry(nil)
+	})
+}
+
+// normalizeTimestamps replaces the timestamps in a JSON response, which
+// depend on the clock and the time zone, with a placeholder, and indents it.
+func normalizeTimestamps(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("invalid JSON %s: %v", data, err)
+	}
+	var walk func(v any) any
+	walk = func(v any) any {
+		switch v := v.(type) {
+		case map[string]any:
+			for key, value := range v {
+				v[key] = walk(value)
+			}
+		case []any:
+			for i, value := range v {
+				v[i] = walk(value)
+			}
+		case string:
+			if _, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return "<timestamp>"
+			}
+		}
+		return v
+	}
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(walk(v)); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/config"
+	"home_server_dashboard/redact"
+	"home_server_dashboard/services"
+	"home_server_dashboard/services/systemd"
+)
+
+// maxLogTail is the most lines of history a log stream starts with. Larger
+// tails are clamped so a typo in a label can't send a whole log file.
+const maxLogTail = 10000
+
+// logParams are the tail and timestamps a logs request asked for. Zero
+// values mean the request left them to the service and global defaults.
+// structured asks for journal lines carrying their cursor, so the stream can
+// resume after them. noRedact asks for the lines without log_redaction
+// applied, which only admins may.
+type logParams struct {
+	tail       int
+	timestamps *bool
+	structured bool
+	noRedact   bool
+}
+
+// parseLogParams reads the optional tail, timestamps, structured and redact
+// query parameters of a logs request, e.g. tail=500&timestamps=false.
+func parseLogParams(query url.Values) (logParams, error) {
+	var params logParams
+	if value := query.Get("tail"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return logParams{}, fmt.Errorf("invalid tail %q: must be a positive number of lines", value)
+		}
+		params.tail = n
+	}
+	if value := query.Get("timestamps"); value != "" {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return logParams{}, fmt.Errorf("invalid timestamps %q: must be true or false", value)
+		}
+		params.timestamps = &b
+	}
+	if value := query.Get("structured"); value != "" {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return logParams{}, fmt.Errorf("invalid structured %q: must be true or false", value)
+		}
+		params.structured = b
+	}
+	if value := query.Get("redact"); value != "" {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return logParams{}, fmt.Errorf("invalid redact %q: must be true or false", value)
+		}
+		params.noRedact = !b
+	}
+	return params, nil
+}
+
+// redactor returns the redactor for the lines of a log stream, nil if they
+// are sent as they are. ok is false if user asked for the lines unredacted
+// but isn't an admin.
+func (p logParams) redactor(user *auth.User, cfg *config.Config) (r *redact.Redactor, ok bool) {
+	if !p.noRedact {
+		return cfg.LogRedactor(), true
+	}
+	return nil, canSeeHidden(user)
+}
+
+// options returns the options a log stream starts with. The request's own
+// parameters win over the service's settings, which win over the global
+// log_tail; timestamps are shown unless something turns them off. A tail
+// above maxLogTail is clamped, and the returned warning says so.
+func (p logParams) options(settings *services.LogSettings, cfg *config.Config) (systemd.LogOptions, string) {
+	opts := systemd.LogOptions{Tail: cfg.GetLogTail(), Follow: true}
+	if settings != nil {
+		if settings.Tail > 0 {
+			opts.Tail = settings.Tail
+		}
+		if settings.Timestamps != nil {
+			opts.NoTimestamps = !*settings.Timestamps
+		}
+	}
+	if p.tail > 0 {
+		opts.Tail = p.tail
+	}
+	if p.timestamps != nil {
+		opts.NoTimestamps = !*p.timestamps
+	}
+
+	var warning string
+	if opts.Tail > maxLogTail {
+		warning = fmt.Sprintf("A tail of %d lines is more than the maximum of %d; showing the last %d lines", opts.Tail, maxLogTail, maxLogTail)
+		opts.Tail = maxLogTail
+	}
+	return opts, warning
+}
+
+// serviceLogSettings returns the log settings of a service, or nil if it has
+// none or can't be found. Systemd units are read from their systemd_services
+// entry rather than asking systemd, which may mean a round trip over SSH.
+func serviceLogSettings(ctx context.Context, provider services.Provider, host *config.HostConfig, source, name string) *services.LogSettings {
+	if source == "systemd" {
+		spec, ok := host.GetServiceSpec(name)
+		if !ok {
+			return nil
+		}
+		return systemd.EntryFromSpec(spec).LogSettings
+	}
+	svc, err := provider.GetService(name)
+	if err != nil {
+		return nil
+	}
+	info, err := svc.GetInfo(ctx)
+	if err != nil {
+		return nil
+	}
+	return info.LogSettings
+}
+
+// openLogs opens the logs of a service with opts. Providers without
+// GetLogsWithOptions only get the tail.
+func openLogs(ctx context.Context, provider services.Provider, name string, opts systemd.LogOptions) (io.ReadCloser, error) {
+	if p, ok := provider.(journalProvider); ok {
+		return p.GetLogsWithOptions(ctx, name, opts)
+	}
+	return provider.GetLogs(ctx, name, opts.Tail, opts.Follow)
+}
+
+// sendLogWarning sends a warning event on a log stream, if there is one.
+func sendLogWarning(w http.ResponseWriter, flusher http.Flusher, warning string) {
+	if warning == "" {
+		return
+	}
+	fmt.Fprintf(w, "event: warning\ndata: %s\n\n", warning)
+	flusher.Flush()
+}
+
+// logResumeID returns the id of the last line a reconnecting viewer saw: the
+// Last-Event-ID header EventSource sends, or the cursor query parameter for
+// clients that open a new stream themselves. It is "" for a fresh stream.
+func logResumeID(r *http.Request) string {
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+	return r.URL.Query().Get("cursor")
+}
+
+// dockerLineID returns the id of a Docker log line, the nanoseconds since the
+// epoch of the timestamp Docker prefixes it with. Lines without a timestamp
+// have no id.
+func dockerLineID(line string) (int64, bool) {
+	stamp, _, _ := strings.Cut(line, " ")
+	t, err := time.Parse(time.RFC3339Nano, stamp)
+	if err != nil {
+		return 0, false
+	}
+	return t.UnixNano(), true
+}
+
+// sendLogLine sends a line of a log stream, with the id a reconnecting
+// viewer resumes after if it has one. Multi-line journal messages are sent
+// as one event of several data lines.
+func sendLogLine(w http.ResponseWriter, flusher http.Flusher, id, line string) {
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "data: %s\n\n", strings.ReplaceAll(line, "\n", "\ndata: "))
+	flusher.Flush()
+}
+
+// sendLogReset sends a reset event on a log stream that could not resume
+// where the viewer left off. The viewer clears what it shows, as the stream
+// starts over from the latest lines.
+func sendLogReset(w http.ResponseWriter, flusher http.Flusher, reason string) {
+	fmt.Fprintf(w, "event: reset\ndata: %s\n\n", reason)
+	flusher.Flush()
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/realip"
+	"home_server_dashboard/services"
+)
+
+// Limits of a maintenance window
+const (
+	defaultMaintenance = time.Hour
+	maxMaintenance     = 24 * time.Hour
+)
+
+// MaintenanceScheduler puts hosts in maintenance, holding back their
+// notifications until the window ends. It is implemented by the monitor.
+type MaintenanceScheduler interface {
+	// StartMaintenance puts host in maintenance for d, or moves the end of
+	// its running window to d from now.
+	StartMaintenance(host string, d time.Duration, user string) services.MaintenanceWindow
+	// EndMaintenance ends the window of host early, returning false if
+	// host isn't in maintenance.
+	EndMaintenance(host string) bool
+	// Maintenance returns the window host is in, if any.
+	Maintenance(host string) (services.MaintenanceWindow, bool)
+}
+
+// MaintenanceRequest is the body of POST /api/hosts/{name}/maintenance.
+type MaintenanceRequest struct {
+	// Duration is how long the window lasts, such as "30m" (default 1h,
+	// at most 24h).
+	Duration string `json:"duration"`
+}
+
+// maintenanceTarget returns the scheduler and the configured host a
+// maintenance request is about, writing the error response if it can't be
+// served. Only administrators may schedule maintenance.
+func maintenanceTarget(w http.ResponseWriter, r *http.Request) (MaintenanceScheduler, string, bool) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil || !user.IsAdmin {
+		http.Error(w, "Access denied: administrator privileges required to schedule maintenance", http.StatusForbidden)
+		return nil, "", false
+	}
+	scheduler, ok := stateTracker.(MaintenanceScheduler)
+	if !ok {
+		http.Error(w, "Maintenance windows need the monitor, which isn't running", http.StatusServiceUnavailable)
+		return nil, "", false
+	}
+	name := r.PathValue("name")
+	if configSource().GetHostByName(name) == nil {
+		http.Error(w, fmt.Sprintf("Unknown host: %s", name), http.StatusNotFound)
+		return nil, "", false
+	}
+	return scheduler, name, true
+}
+
+// StartMaintenanceHandler handles POST /api/hosts/{name}/maintenance.
+// Puts the host in maintenance for the requested duration: its events
+// still reach the dashboard, but notifiers skip them, and one summary of
+// what is still down is sent when the window ends. Posting again for a
+// host in maintenance moves the end of its window. Only administrators
+// may schedule maintenance.
+func StartMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	scheduler, host, ok := maintenanceTarget(w, r)
+	if !ok {
+		return
+	}
+
+	var req MaintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	d := defaultMaintenance
+	if req.Duration != "" {
+		parsed, err := time.ParseDuration(req.Duration)
+		if err != nil || parsed <= 0 || parsed > maxMaintenance {
+			http.Error(w, fmt.Sprintf("Invalid duration %q: must be a positive duration of at most %s", req.Duration, maxMaintenance), http.StatusBadRequest)
+			return
+		}
+		d = parsed
+	}
+
+	owner := actionOwner(r.Context())
+	window := scheduler.StartMaintenance(host, d, owner)
+	log.Printf("Audit: user=%s ip=%s action=maintenance-start host=%s duration=%s", owner, realip.FromRequest(r), host, d)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(window)
+}
+
+// EndMaintenanceHandler handles DELETE /api/hosts/{name}/maintenance.
+// Ends the host's maintenance window early, sending its summary now. Only
+// administrators may end maintenance.
+func EndMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	scheduler, host, ok := maintenanceTarget(w, r)
+	if !ok {
+		return
+	}
+	if !scheduler.EndMaintenance(host) {
+		http.Error(w, "Host is not in maintenance", http.StatusNotFound)
+		return
+	}
+	log.Printf("Audit: user=%s ip=%s action=maintenance-end host=%s", actionOwner(r.Context()), realip.FromRequest(r), host)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"ended": true})
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/services"
+)
+
+// fakeMaintenance is a fakeHostReporter that also schedules maintenance.
+type fakeMaintenance struct {
+	fakeHostReporter
+	windows map[string]services.MaintenanceWindow
+}
+
+func (f *fakeMaintenance) StartMaintenance(host string, d time.Duration, user string) services.MaintenanceWindow {
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	window := services.MaintenanceWindow{Host: host, Start: start, End: start.Add(d), User: user}
+	f.windows[host] = window
+	return window
+}
+
+func (f *fakeMaintenance) EndMaintenance(host string) bool {
+	_, ok := f.windows[host]
+	delete(f.windows, host)
+	return ok
+}
+
+func (f *fakeMaintenance) Maintenance(host string) (services.MaintenanceWindow, bool) {
+	window, ok := f.windows[host]
+	return window, ok
+}
+
+func TestMaintenanceHandlers(t *testing.T) {
+	cleanup := setupTestConfig(t, `{"hosts": [{"name": "nas", "address": "localhost"}]}`)
+	defer cleanup()
+	original := stateTracker
+	defer SetStateTracker(original)
+	scheduler := &fakeMaintenance{windows: make(map[string]services.MaintenanceWindow)}
+	SetStateTracker(scheduler)
+
+	admin := &auth.User{Name: "alice", IsAdmin: true}
+	request := func(method, host, body string, user *auth.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/hosts/"+host+"/maintenance", strings.NewReader(body))
+		req.SetPathValue("name", host)
+		if user != nil {
+			req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, user))
+		}
+		w := httptest.NewRecorder()
+		if method == http.MethodDelete {
+			EndMaintenanceHandler(w, req)
+		} else {
+			StartMaintenanceHandler(w, req)
+		}
+		return w
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		host     string
+		body     string
+		user     *auth.User
+		wantCode int
+	}{
+		{"non-admin", http.MethodPost, "nas", `{"duration": "1h"}`, &auth.User{Name: "bob"}, http.StatusForbidden},
+		{"unknown host", http.MethodPost, "nope", `{"duration": "1h"}`, admin, http.StatusNotFound},
+		{"bad duration", http.MethodPost, "nas", `{"duration": "soon"}`, admin, http.StatusBadRequest},
+		{"too long", http.MethodPost, "nas", `{"duration": "48h"}`, admin, http.StatusBadRequest},
+		{"end without a window", http.MethodDelete, "nas", "", admin, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := request(tt.method, tt.host, tt.body, tt.user); w.Code != tt.wantCode {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+		})
+	}
+
+	// No body starts the default hour
+	w := request(http.MethodPost, "nas", "", admin)
+	var window services.MaintenanceWindow
+	if err := json.NewDecoder(w.Body).Decode(&window); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("start: status %d, error %v", w.Code, err)
+	}
+	if window.User != "alice" || window.End.Sub(window.Start) != time.Hour {
+		t.Errorf("window = %+v, want alice's hour", window)
+	}
+
+	groups := groupByHost(configSource(), nil, nil)
+	if len(groups) != 1 || groups[0].Maintenance == nil || groups[0].Maintenance.User != "alice" {
+		t.Errorf("host groups = %+v, want nas with its window", groups)
+	}
+
+	if w := request(http.MethodDelete, "nas", "", admin); w.Code != http.StatusOK {
+		t.Errorf("end: status %d", w.Code)
+	}
+	if groups := groupByHost(configSource(), nil, nil); groups[0].Maintenance != nil {
+		t.Errorf("window still listed after it ended: %+v", groups[0].Maintenance)
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/services/docker"
+)
+
+// metricsWriter writes metrics in the Prometheus text exposition format.
+type metricsWriter struct {
+	w io.Writer
+}
+
+// family starts the metric name, of kind "gauge", "counter" or "histogram".
+func (m metricsWriter) family(name, kind, help string) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes one sample of name, labelled with the pairs in labels, e.g.
+// "host", "nas".
+func (m metricsWriter) sample(name string, value float64, labels ...string) {
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(labels[i])
+			b.WriteString("=")
+			b.WriteString(strconv.Quote(labels[i+1]))
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	b.WriteByte('\n')
+	io.WriteString(m.w, b.String())
+}
+
+// writeRuntimeMetrics writes the dashboard's latest resource use sample and
+// the limits it is alerted at.
+func writeRuntimeMetrics(m metricsWriter) {
+	reporter, _ := stateTracker.(RuntimeReporter)
+	if reporter == nil {
+		return
+	}
+	stats := reporter.Runtime()
+	if len(stats.Samples) > 0 {
+		latest := stats.Samples[len(stats.Samples)-1]
+		m.family("dashboard_goroutines", "gauge", "Goroutines running, as last sampled.")
+		m.sample("dashboard_goroutines", float64(latest.Goroutines))
+		m.family("dashboard_heap_bytes", "gauge", "Bytes of allocated heap objects, as last sampled.")
+		m.sample("dashboard_heap_bytes", float64(latest.HeapBytes))
+		if latest.OpenFDs >= 0 {
+			m.family("dashboard_open_fds", "gauge", "Files open, as last sampled.")
+			m.sample("dashboard_open_fds", float64(latest.OpenFDs))
+		}
+	}
+	m.family("dashboard_goroutines_limit", "gauge", "Goroutines alerted at, 0 if not alerted.")
+	m.sample("dashboard_goroutines_limit", float64(stats.MaxGoroutines))
+	m.family("dashboard_open_fds_limit", "gauge", "Open files alerted at, 0 if not alerted.")
+	m.sample("dashboard_open_fds_limit", float64(stats.MaxOpenFDs))
+	m.family("dashboard_runtime_alerts", "gauge", "Resource alerts raised and not yet cleared.")
+	m.sample("dashboard_runtime_alerts", float64(len(stats.Alerting)))
+}
+
+// writeCollectionMetrics writes how long the phases of the services
+// collections took, as the histograms the Server-Timing header and _timing
+// report one collection of.
+func writeCollectionMetrics(m metricsWriter) {
+	keys, histograms := phaseHistograms.snapshot()
+	if len(keys) == 0 {
+		return
+	}
+	const name = "dashboard_collection_phase_seconds"
+	m.family(name, "histogram", "How long each phase of the services collections took, by source on each host.")
+	for i, key := range keys {
+		labels := []string{"phase", key.Phase}
+		if key.Host != "" {
+			labels = append(labels, "host", key.Host)
+		}
+		h := histograms[i]
+		var cumulative uint64
+		for j, bound := range phaseBuckets {
+			cumulative += h.buckets[j]
+			m.sample(name+"_bucket", float64(cumulative), append(slices.Clone(labels), "le", strconv.FormatFloat(bound, 'g', -1, 64))...)
+		}
+		m.sample(name+"_bucket", float64(h.count), append(slices.Clone(labels), "le", "+Inf")...)
+		m.sample(name+"_sum", h.sum, labels...)
+		m.sample(name+"_count", float64(h.count), labels...)
+	}
+}
+
+// writeCacheMetrics writes how many events invalidated the services cache
+// and how many refreshes they were coalesced into.
+func writeCacheMetrics(m metricsWriter) {
+	invalidations, refreshes := servicesCache.counters()
+	m.family("dashboard_services_invalidations_total", "counter", "Events received that may have changed the services.")
+	m.sample("dashboard_services_invalidations_total", float64(invalidations))
+	m.family("dashboard_services_refreshes_total", "counter", "Background refreshes of the services the events were coalesced into.")
+	m.sample("dashboard_services_refreshes_total", float64(refreshes))
+}
+
+// writeInspectCacheMetrics writes how the Docker container inspects on each
+// host were answered.
+func writeInspectCacheMetrics(m metricsWriter) {
+	stats := docker.AllInspectCacheStats()
+	if len(stats) == 0 {
+		return
+	}
+	hosts := slices.Sorted(maps.Keys(stats))
+	m.family("dashboard_inspect_cache_hits_total", "counter", "Container inspects answered from the cache or by an inspect already running, by host.")
+	for _, host := range hosts {
+		m.sample("dashboard_inspect_cache_hits_total", float64(stats[host].Hits), "host", host)
+	}
+	m.family("dashboard_inspect_cache_misses_total", "counter", "Container inspects made against Docker, by host.")
+	for _, host := range hosts {
+		m.sample("dashboard_inspect_cache_misses_total", float64(stats[host].Misses), "host", host)
+	}
+}
+
+// writeAuthMetrics writes how many sessions and pending logins were dropped
+// from the login stores to stay within their limits, if authentication is
+// on.
+func writeAuthMetrics(m metricsWriter) {
+	if authStores == nil {
+		return
+	}
+	stats := authStores.StoreStats()
+	m.family("dashboard_session_evictions_total", "counter", "Login sessions dropped to stay within max_sessions.")
+	m.sample("dashboard_session_evictions_total", float64(stats.SessionEvictions))
+	m.family("dashboard_login_state_evictions_total", "counter", "Pending OIDC logins dropped to stay within max_login_states.")
+	m.sample("dashboard_login_state_evictions_total", float64(stats.StateEvictions))
+}
+
+// MetricsHandler handles GET /api/metrics requests.
+// Returns the dashboard's own metrics in the Prometheus text format: its
+// resource use, how long its collections take, how often the services are
+// refreshed, how often container inspects are answered from the cache and
+// how many logins were dropped from the login stores.
+// Only administrators may read them.
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if !canSeeHidden(user) {
+		http.Error(w, "Access denied: administrator privileges required for metrics", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	m := metricsWriter{w: w}
+	writeRuntimeMetrics(m)
+	writeCollectionMetrics(m)
+	writeCacheMetrics(m)
+	writeInspectCacheMetrics(m)
+	writeAuthMetrics(m)
+}
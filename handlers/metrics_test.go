@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/monitor"
+	"home_server_dashboard/services/docker"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	original := stateTracker
+	defer SetStateTracker(original)
+	SetStateTracker(fakeRuntimeReporter{stats: monitor.RuntimeStats{
+		Samples: []monitor.RuntimeSample{
+			{Goroutines: 40, HeapBytes: 1 << 19, OpenFDs: 12},
+			{Goroutines: 42, HeapBytes: 1 << 20, OpenFDs: -1},
+		},
+		MaxGoroutines: 1000,
+		Alerting:      []string{"goroutines growth"},
+	}})
+
+	w := getDebug(MetricsHandler, "/api/metrics", &auth.User{ID: "admin", IsAdmin: true})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d: %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		"# TYPE dashboard_goroutines gauge\ndashboard_goroutines 42\n",
+		"dashboard_heap_bytes 1.048576e+06\n",
+		"dashboard_goroutines_limit 1000\n",
+		"dashboard_runtime_alerts 1\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics lack %q:\n%s", want, body)
+		}
+	}
+	// Open files that can't be counted are left out
+	if strings.Contains(body, "dashboard_open_fds ") {
+		t.Errorf("metrics have the open files that couldn't be counted:\n%s", body)
+	}
+
+	if w := getDebug(MetricsHandler, "/api/metrics", nil); w.Code != http.StatusOK {
+		t.Errorf("without authentication: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := getDebug(MetricsHandler, "/api/metrics", &auth.User{ID: "user"}); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestMetricsWriter_Labels(t *testing.T) {
+	var b strings.Builder
+	m := metricsWriter{w: &b}
+	m.sample("dashboard_x", 1.5, "host", `n"as`, "source", "docker")
+	if want := "dashboard_x{host=\"n\\\"as\",source=\"docker\"} 1.5\n"; b.String() != want {
+		t.Errorf("sample = %q, want %q", b.String(), want)
+	}
+}
+
+func TestWriteCollectionMetrics(t *testing.T) {
+	original := phaseHistograms
+	defer func() { phaseHistograms = original }()
+	phaseHistograms = &phaseHistogramSet{}
+	phaseHistograms.observe("docker", "nas", 20*time.Millisecond)
+	phaseHistograms.observe("docker", "nas", 2*time.Second)
+	phaseHistograms.observe("collection", "", 3*time.Second)
+
+	var b strings.Builder
+	writeCollectionMetrics(metricsWriter{w: &b})
+	body := b.String()
+	for _, want := range []string{
+		"# TYPE dashboard_collection_phase_seconds histogram\n",
+		`dashboard_collection_phase_seconds_bucket{phase="docker",host="nas",le="0.01"} 0` + "\n",
+		`dashboard_collection_phase_seconds_bucket{phase="docker",host="nas",le="0.025"} 1` + "\n",
+		`dashboard_collection_phase_seconds_bucket{phase="docker",host="nas",le="2.5"} 2` + "\n",
+		`dashboard_collection_phase_seconds_bucket{phase="docker",host="nas",le="+Inf"} 2` + "\n",
+		`dashboard_collection_phase_seconds_sum{phase="docker",host="nas"} 2.02` + "\n",
+		`dashboard_collection_phase_seconds_count{phase="docker",host="nas"} 2` + "\n",
+		`dashboard_collection_phase_seconds_count{phase="collection"} 1` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics lack %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestWriteInspectCacheMetrics(t *testing.T) {
+	// Observing an event creates the host's inspect cache
+	docker.InvalidateInspectCache("metrics-host")
+
+	var b strings.Builder
+	writeInspectCacheMetrics(metricsWriter{w: &b})
+	body := b.String()
+	for _, want := range []string{
+		"# TYPE dashboard_inspect_cache_hits_total counter\n",
+		`dashboard_inspect_cache_hits_total{host="metrics-host"} 0` + "\n",
+		"# TYPE dashboard_inspect_cache_misses_total counter\n",
+		`dashboard_inspect_cache_misses_total{host="metrics-host"} 0` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics lack %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestWriteAuthMetrics(t *testing.T) {
+	defer SetAuthStores(authStores)
+
+	SetAuthStores(nil)
+	var b strings.Builder
+	writeAuthMetrics(metricsWriter{w: &b})
+	if b.Len() != 0 {
+		t.Errorf("metrics without authentication:\n%s", b.String())
+	}
+
+	SetAuthStores(fakeAuthStores{SessionEvictions: 7, StateEvictions: 250})
+	w := getDebug(MetricsHandler, "/api/metrics", &auth.User{ID: "admin", IsAdmin: true})
+	body := w.Body.String()
+	for _, want := range []string{
+		"# TYPE dashboard_session_evictions_total counter\ndashboard_session_evictions_total 7\n",
+		"# TYPE dashboard_login_state_evictions_total counter\ndashboard_login_state_evictions_total 250\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics lack %q:\n%s", want, body)
+		}
+	}
+}
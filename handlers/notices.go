@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/notices"
+	"home_server_dashboard/realip"
+)
+
+// noticeBoard keeps the notices shown to administrators (replaced by the
+// server package)
+var noticeBoard = notices.NewStore(notices.DefaultExpiryDays)
+
+// SetNotices sets the store that notices are posted to and read from.
+func SetNotices(store *notices.Store) {
+	if store != nil {
+		noticeBoard = store
+	}
+}
+
+// providerNoticeKey returns the key of the notice about collecting the
+// services of source on host failing.
+func providerNoticeKey(source, hostName string) string {
+	return "provider:" + source + ":" + hostName
+}
+
+// reportCollection posts a notice if collecting the services of source on
+// host failed with err, or resolves it if collecting succeeded. Failures
+// because the request went away aren't the provider's.
+func reportCollection(ctx context.Context, source, hostName string, err error) {
+	switch {
+	case err == nil:
+		noticeBoard.Resolve(providerNoticeKey(source, hostName))
+	case ctx.Err() == nil:
+		noticeBoard.Post(notices.SeverityWarning, "provider", providerNoticeKey(source, hostName), err.Error())
+	}
+}
+
+// noticeList is the response of GET /api/notices.
+type noticeList struct {
+	Count   int              `json:"count"`
+	Notices []notices.Notice `json:"notices"`
+}
+
+// NoticesHandler handles GET /api/notices requests.
+// Returns the notices that are neither dismissed nor expired, the most
+// recently posted first, with their count for a badge. Only administrators
+// may read them.
+func NoticesHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil || !user.IsAdmin {
+		http.Error(w, "Access denied: administrator privileges required to view notices", http.StatusForbidden)
+		return
+	}
+
+	list := noticeBoard.Active()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(noticeList{Count: len(list), Notices: list})
+}
+
+// DismissNoticeHandler handles POST /api/notices/{id}/dismiss requests.
+// Hides the notice until its condition clears and recurs. Only
+// administrators may dismiss notices.
+func DismissNoticeHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil || !user.IsAdmin {
+		http.Error(w, "Access denied: administrator privileges required to dismiss notices", http.StatusForbidden)
+		return
+	}
+
+	id := r.PathValue("id")
+	if !noticeBoard.Dismiss(id) {
+		http.Error(w, "No such notice", http.StatusNotFound)
+		return
+	}
+	log.Printf("Audit: user=%s ip=%s action=dismiss-notice id=%s", actionOwner(r.Context()), realip.FromRequest(r), id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"dismissed": true})
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/notices"
+)
+
+// withNoticeBoard serves a fresh notices store for the test.
+func withNoticeBoard(t *testing.T) *notices.Store {
+	t.Helper()
+	original := noticeBoard
+	board := notices.NewStore(notices.DefaultExpiryDays)
+	SetNotices(board)
+	t.Cleanup(func() { noticeBoard = original })
+	return board
+}
+
+// serveNotices runs handler for a request to path as user.
+func serveNotices(handler http.HandlerFunc, method, path string, user *auth.User) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/notices", handler)
+	mux.HandleFunc("POST /api/notices/{id}/dismiss", handler)
+	req := httptest.NewRequest(method, path, nil)
+	if user != nil {
+		req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, user))
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
+
+func TestNoticesHandler(t *testing.T) {
+	board := withNoticeBoard(t)
+	admin := &auth.User{IsAdmin: true}
+
+	board.Post(notices.SeverityWarning, "provider", "provider:docker:nas", "connection refused")
+	board.Post(notices.SeverityWarning, "provider", "provider:docker:nas", "connection refused")
+
+	w := serveNotices(NoticesHandler, http.MethodGet, "/api/notices", admin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d: %s", w.Code, w.Body.String())
+	}
+	var got noticeList
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Count != 1 || len(got.Notices) != 1 || got.Notices[0].Count != 2 {
+		t.Fatalf("notices = %+v", got)
+	}
+	id := got.Notices[0].ID
+
+	// Dismissing hides it; an unknown notice can't be dismissed
+	if w := serveNotices(DismissNoticeHandler, http.MethodPost, "/api/notices/"+id+"/dismiss", admin); w.Code != http.StatusOK {
+		t.Errorf("dismiss: Status = %d: %s", w.Code, w.Body.String())
+	}
+	if list := board.Active(); len(list) != 0 {
+		t.Errorf("notices after dismissing = %+v", list)
+	}
+	if w := serveNotices(DismissNoticeHandler, http.MethodPost, "/api/notices/unknown/dismiss", admin); w.Code != http.StatusNotFound {
+		t.Errorf("unknown notice: Status = %d, want 404", w.Code)
+	}
+
+	// Only administrators may see or dismiss notices
+	viewer := &auth.User{AllowedServices: map[string][]string{"nas": {"sonarr"}}}
+	if w := serveNotices(NoticesHandler, http.MethodGet, "/api/notices", viewer); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin list: Status = %d, want 403", w.Code)
+	}
+	if w := serveNotices(DismissNoticeHandler, http.MethodPost, "/api/notices/"+id+"/dismiss", viewer); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin dismiss: Status = %d, want 403", w.Code)
+	}
+}
+
+func TestReportCollection(t *testing.T) {
+	board := withNoticeBoard(t)
+	ctx := context.Background()
+
+	reportCollection(ctx, "docker", "nas", errors.New("failed to list containers: connection refused"))
+	list := board.Active()
+	if len(list) != 1 || list[0].Key != "provider:docker:nas" || list[0].Component != "provider" {
+		t.Fatalf("notices after a failure = %+v", list)
+	}
+
+	// Requests that went away aren't blamed on the provider
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	reportCollection(cancelled, "systemd", "nas", context.Canceled)
+	if list := board.Active(); len(list) != 1 {
+		t.Errorf("notices after a cancelled request = %+v", list)
+	}
+
+	reportCollection(ctx, "docker", "nas", nil)
+	if list := board.Active(); len(list) != 0 {
+		t.Errorf("notices after collecting again = %+v", list)
+	}
+}
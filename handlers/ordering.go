@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"sort"
+
+	"home_server_dashboard/services"
+)
+
+// sortServices puts the services, and the ports and Traefik URLs of each, in
+// a fixed order, so collections of the same services encode to the same JSON
+// whatever order the providers, Docker and label maps returned them in. That
+// keeps the ETag of unchanged services the same and the UI from reordering
+// them between refreshes. Services are ordered by host, project and name,
+// ports by host port and protocol, and Traefik URLs lexically.
+func sortServices(svcList []services.ServiceInfo) {
+	sort.SliceStable(svcList, func(i, j int) bool {
+		a, b := svcList[i], svcList[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Source < b.Source
+	})
+	for i := range svcList {
+		sortPorts(svcList[i].Ports)
+		sort.Strings(svcList[i].TraefikURLs)
+	}
+}
+
+// sortPorts orders ports by host port and protocol. Remapped ports, which
+// may share a host port with one the service exposes itself, come after it,
+// ordered by the service exposing them.
+func sortPorts(ports []services.PortInfo) {
+	sort.SliceStable(ports, func(i, j int) bool {
+		a, b := ports[i], ports[j]
+		if a.HostPort != b.HostPort {
+			return a.HostPort < b.HostPort
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		if a.SourceService != b.SourceService {
+			return a.SourceService < b.SourceService
+		}
+		return a.ContainerPort < b.ContainerPort
+	})
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"home_server_dashboard/notices"
+	"home_server_dashboard/services"
+)
+
+// platformCache holds the platform of each host as its providers reported
+// it. A host's platform doesn't change, so a provider is asked only until
+// one answers.
+type platformCache struct {
+	mu     sync.Mutex
+	byHost map[string]services.Platform
+}
+
+// hostPlatforms are the platforms of the hosts services were collected from.
+var hostPlatforms = &platformCache{byHost: make(map[string]services.Platform)}
+
+// get returns the platform of hostName, or nil if it isn't known.
+func (c *platformCache) get(hostName string) *services.Platform {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	platform, ok := c.byHost[hostName]
+	if !ok {
+		return nil
+	}
+	return &platform
+}
+
+// record asks provider for the platform of hostName, within timeout, if it
+// isn't known yet and the provider can tell. Failing to get it only leaves
+// it unknown.
+func (c *platformCache) record(ctx context.Context, hostName string, provider services.Provider, timeout time.Duration) {
+	platforms, ok := provider.(services.PlatformProvider)
+	if !ok || c.get(hostName) != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	platform, err := platforms.Platform(ctx)
+	if err != nil || !platform.Known() {
+		return
+	}
+	c.mu.Lock()
+	c.byHost[hostName] = platform
+	c.mu.Unlock()
+}
+
+// archNoticeKey returns the key of the notice about svc's image being built
+// for another architecture than its host's, from the service's key so that
+// services in different projects don't share a notice.
+func archNoticeKey(svc services.ServiceInfo) string {
+	return "arch:" + services.KeyOf(svc).String()
+}
+
+// reportArchMismatches posts a notice for each Docker service whose image
+// its host can't run, and resolves the notices of the others.
+func reportArchMismatches(svcList []services.ServiceInfo) {
+	for _, svc := range svcList {
+		if svc.Source != "docker" {
+			continue
+		}
+		if !svc.ArchMismatch {
+			noticeBoard.Resolve(archNoticeKey(svc))
+			continue
+		}
+		hostPlatform := "another platform"
+		if platform := hostPlatforms.get(svc.Host); platform != nil {
+			hostPlatform = platform.String()
+		}
+		noticeBoard.Post(notices.SeverityError, "docker", archNoticeKey(svc),
+			fmt.Sprintf("%s on %s uses an image built for %s, but the host is %s; it will fail with \"exec format error\"", svc.ContainerName, svc.Host, svc.ImagePlatform, hostPlatform))
+	}
+}
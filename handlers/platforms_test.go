@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/notices"
+	"home_server_dashboard/services"
+)
+
+// platformProvider is a fake provider that reports a platform, counting how
+// often it is asked.
+type platformProvider struct {
+	fakeProvider
+	platform services.Platform
+	err      error
+	calls    int
+}
+
+func (p *platformProvider) Platform(ctx context.Context) (services.Platform, error) {
+	p.calls++
+	return p.platform, p.err
+}
+
+// withHostPlatforms starts the test with no host platforms known.
+func withHostPlatforms(t *testing.T) *platformCache {
+	t.Helper()
+	original := hostPlatforms
+	hostPlatforms = &platformCache{byHost: make(map[string]services.Platform)}
+	t.Cleanup(func() { hostPlatforms = original })
+	return hostPlatforms
+}
+
+func TestPlatformCache_Record(t *testing.T) {
+	cache := withHostPlatforms(t)
+
+	// Providers that can't tell are skipped, and failures leave it unknown
+	cache.record(context.Background(), "pi", &fakeProvider{host: "pi"}, time.Second)
+	failing := &platformProvider{err: errors.New("uname failed")}
+	cache.record(context.Background(), "pi", failing, time.Second)
+	if got := cache.get("pi"); got != nil {
+		t.Fatalf("platform = %v, want unknown", got)
+	}
+
+	provider := &platformProvider{platform: services.NewPlatform("Linux", "aarch64", "")}
+	cache.record(context.Background(), "pi", provider, time.Second)
+	cache.record(context.Background(), "pi", provider, time.Second)
+	if got := cache.get("pi"); got == nil || got.String() != "linux/arm64" {
+		t.Errorf("platform = %v, want linux/arm64", got)
+	}
+	if provider.calls != 1 {
+		t.Errorf("provider asked %d times, want once", provider.calls)
+	}
+}
+
+func TestGroupByHost_Platform(t *testing.T) {
+	cache := withHostPlatforms(t)
+	cache.record(context.Background(), "nas", &platformProvider{platform: services.NewPlatform("linux", "x86_64", "")}, time.Second)
+
+	cfg := &config.Config{Hosts: []config.HostConfig{
+		{Name: "nas", Address: "localhost"},
+		{Name: "pi", Address: "192.168.1.20"},
+	}}
+	groups := groupByHost(cfg, nil, nil)
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups", len(groups))
+	}
+	if groups[0].Platform == nil || groups[0].Platform.Architecture != "amd64" {
+		t.Errorf("nas platform = %v, want amd64", groups[0].Platform)
+	}
+	if groups[1].Platform != nil {
+		t.Errorf("pi platform = %v, want unknown", groups[1].Platform)
+	}
+}
+
+func TestReportArchMismatches(t *testing.T) {
+	board := withNoticeBoard(t)
+	cache := withHostPlatforms(t)
+	cache.record(context.Background(), "pi", &platformProvider{platform: services.NewPlatform("linux", "aarch64", "")}, time.Second)
+
+	svcList := []services.ServiceInfo{
+		{Name: "web", ContainerName: "media-web-1", Source: "docker", Host: "pi", Project: "media", ImagePlatform: "linux/amd64", ArchMismatch: true},
+		{Name: "db", ContainerName: "media-db-1", Source: "docker", Host: "pi", ImagePlatform: "linux/arm64"},
+		{Name: "nginx.service", ContainerName: "nginx.service", Source: "systemd", Host: "pi"},
+	}
+	reportArchMismatches(svcList)
+
+	list := board.Active()
+	if len(list) != 1 {
+		t.Fatalf("notices = %+v, want one for the mismatched image", list)
+	}
+	got := list[0]
+	if got.Severity != notices.SeverityError || got.Key != "arch:pi/docker/media/web" ||
+		!strings.Contains(got.Message, "media-web-1 on pi uses an image built for linux/amd64, but the host is linux/arm64") {
+		t.Errorf("notice = %+v", got)
+	}
+
+	// Once the image is replaced with one for the host, the notice goes
+	svcList[0].ImagePlatform, svcList[0].ArchMismatch = "linux/arm64", false
+	reportArchMismatches(svcList)
+	if list := board.Active(); len(list) != 0 {
+		t.Errorf("notices after fixing the image = %+v", list)
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"home_server_dashboard/config"
+	"home_server_dashboard/services"
+)
+
+// Aggregate states of a compose project.
+const (
+	projectRunning  = "running"  // Every service that should run does
+	projectDegraded = "degraded" // Some services that should run don't
+	projectStopped  = "stopped"  // None of its services run
+)
+
+// ProjectGroup is a compose project and its services, as returned by
+// GET /api/services?group=project.
+type ProjectGroup struct {
+	Host  string `json:"host"`
+	Name  string `json:"name"`
+	State string `json:"state"` // "running", "degraded" or "stopped"
+	// Running, Stopped and NotEnabled count the services by state. Services
+	// whose compose profile isn't active are not enabled, and don't make
+	// the project degraded.
+	Running    int                    `json:"running"`
+	Stopped    int                    `json:"stopped"`
+	NotEnabled int                    `json:"not_enabled"`
+	Services   []services.ServiceInfo `json:"services"`
+	// Links are the configured links whose group is the project, from the
+	// project's host or global.
+	Links []config.Link `json:"links"`
+}
+
+// count adds svc to the project's counts.
+func (g *ProjectGroup) count(svc services.ServiceInfo) {
+	switch {
+	case svc.State.Up():
+		g.Running++
+	case svc.State == services.StateNotEnabled:
+		g.NotEnabled++
+	default:
+		g.Stopped++
+	}
+}
+
+// projectState returns the aggregate state of a project with the given
+// counts.
+func projectState(running, stopped int) string {
+	switch {
+	case running == 0:
+		return projectStopped
+	case stopped > 0:
+		return projectDegraded
+	}
+	return projectRunning
+}
+
+// groupByProject groups the Docker services of svcList by host and compose
+// project, in the order the projects first appear. Other services are left
+// out.
+func groupByProject(svcList []services.ServiceInfo) []ProjectGroup {
+	groups := []ProjectGroup{}
+	index := make(map[services.Key]int)
+	for _, svc := range svcList {
+		if svc.Source != "docker" || svc.Project == "" {
+			continue
+		}
+		key := services.Key{Host: svc.Host, Name: svc.Project}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, ProjectGroup{Host: svc.Host, Name: svc.Project, Links: []config.Link{}})
+		}
+		groups[i].count(svc)
+		groups[i].Services = append(groups[i].Services, svc)
+	}
+	for i := range groups {
+		groups[i].State = projectState(groups[i].Running, groups[i].Stopped)
+	}
+	return groups
+}
+
+// placeLinks adds each of links to the projects in groups its group names:
+// a host's link to the project on that host, a global link to the project
+// on every host. The links that belong with no project, because they have
+// no group or their project has no services shown, are returned.
+func placeLinks(groups []ProjectGroup, links []config.Link) []config.Link {
+	rest := []config.Link{}
+	for _, link := range links {
+		placed := false
+		if link.Group != "" {
+			for i := range groups {
+				if groups[i].Name == link.Group && (link.Host == "" || link.Host == groups[i].Host) {
+					groups[i].Links = append(groups[i].Links, link)
+					placed = true
+				}
+			}
+		}
+		if !placed {
+			rest = append(rest, link)
+		}
+	}
+	return rest
+}
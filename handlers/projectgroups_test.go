@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/config"
+	"home_server_dashboard/services"
+)
+
+func TestGroupByProject(t *testing.T) {
+	svc := func(host, project, name string, state services.State) services.ServiceInfo {
+		return services.ServiceInfo{Name: name, Host: host, Project: project, Source: "docker", State: state}
+	}
+	svcList := []services.ServiceInfo{
+		svc("nas", "media", "web", services.StateRunning),
+		svc("nas", "media", "db", services.StateRunning),
+		svc("nas", "media", "adminer", services.StateNotEnabled),
+		svc("nas", "arr", "sonarr", services.StateUnhealthy),
+		svc("nas", "arr", "radarr", services.StateStopped),
+		svc("nas", "tools", "profiler", services.StateNotEnabled),
+		svc("pi", "media", "web", services.StateStopped),
+		{Name: "nginx.service", Host: "nas", Project: "systemd", Source: "systemd", State: services.StateStopped},
+	}
+
+	groups := groupByProject(svcList)
+	want := []struct {
+		host, name, state            string
+		running, stopped, notEnabled int
+	}{
+		// A service whose profile isn't active doesn't degrade its project
+		{"nas", "media", projectRunning, 2, 0, 1},
+		{"nas", "arr", projectDegraded, 1, 1, 0},
+		{"nas", "tools", projectStopped, 0, 0, 1},
+		{"pi", "media", projectStopped, 0, 1, 0},
+	}
+	if len(groups) != len(want) {
+		t.Fatalf("groups = %+v, want %d", groups, len(want))
+	}
+	for i, w := range want {
+		g := groups[i]
+		if g.Host != w.host || g.Name != w.name || g.State != w.state || g.Running != w.running || g.Stopped != w.stopped || g.NotEnabled != w.notEnabled {
+			t.Errorf("groups[%d] = %s/%s %s (%d running, %d stopped, %d not enabled), want %+v",
+				i, g.Host, g.Name, g.State, g.Running, g.Stopped, g.NotEnabled, w)
+		}
+		if len(g.Services) != g.Running+g.Stopped+g.NotEnabled {
+			t.Errorf("groups[%d] has %d services", i, len(g.Services))
+		}
+	}
+
+	if groups := groupByProject(nil); groups == nil || len(groups) != 0 {
+		t.Errorf("groupByProject(nil) = %#v, want an empty list", groups)
+	}
+}
+
+func TestPlaceLinks(t *testing.T) {
+	groups := groupByProject([]services.ServiceInfo{
+		{Name: "immich-server", Host: "nas", Project: "immich", Source: "docker", State: services.StateRunning},
+		{Name: "immich-server", Host: "pi", Project: "immich", Source: "docker", State: services.StateRunning},
+		{Name: "sonarr", Host: "nas", Project: "arr", Source: "docker", State: services.StateRunning},
+	})
+	link := func(name, host, group string) config.Link {
+		return config.Link{LinkConfig: config.LinkConfig{Name: name, URL: "https://" + name + ".lan", Group: group}, Host: host}
+	}
+
+	rest := placeLinks(groups, []config.Link{
+		link("photos", "", "immich"),     // Global: every host's immich
+		link("pi-admin", "pi", "immich"), // Only pi's immich
+		link("router", "", ""),           // No group
+		link("plex", "", "media"),        // No such project shown
+	})
+
+	linkNames := func(links []config.Link) []string {
+		names := []string{}
+		for _, l := range links {
+			names = append(names, l.Name)
+		}
+		return names
+	}
+	want := map[string][]string{
+		"nas/immich": {"photos"},
+		"pi/immich":  {"photos", "pi-admin"},
+		"nas/arr":    {},
+	}
+	for _, g := range groups {
+		if got := linkNames(g.Links); !slices.Equal(got, want[g.Host+"/"+g.Name]) {
+			t.Errorf("%s/%s links = %v, want %v", g.Host, g.Name, got, want[g.Host+"/"+g.Name])
+		}
+	}
+	if got := linkNames(rest); !slices.Equal(got, []string{"router", "plex"}) {
+		t.Errorf("rest = %v, want router and plex", got)
+	}
+}
+
+func TestServicesHandler_ProjectLinks(t *testing.T) {
+	cfg := &config.Config{
+		ServicesPollTimeout: 1,
+		Links:               []config.LinkConfig{{Name: "Photos", URL: "https://photos.lan", Group: "immich", AllowedGroups: []string{"family"}}},
+		Hosts: []config.HostConfig{{Name: "nas", Address: "localhost", Links: []config.LinkConfig{
+			{Name: "Immich admin", URL: "https://immich.lan/admin", Group: "immich"},
+			{Name: "NAS", URL: "https://nas.lan"},
+		}}},
+	}
+	origCache, origConfig := servicesCache, configSource
+	servicesCache = newSnapshotCache()
+	servicesCache.collect = func(ctx context.Context, cfg *config.Config) ([]services.ServiceInfo, error) {
+		return []services.ServiceInfo{{Name: "immich-server", Host: "nas", Project: "immich", Source: "docker", State: services.StateRunning}}, nil
+	}
+	SetConfigSource(func() *config.Config { return cfg })
+	t.Cleanup(func() {
+		servicesCache = origCache
+		configSource = origConfig
+	})
+
+	get := func(user *auth.User) (projects []ProjectGroup, rest []config.Link) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/services?group=project", nil)
+		req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, user))
+		w := httptest.NewRecorder()
+		ServicesHandler(w, req)
+		var body struct {
+			Projects []ProjectGroup `json:"projects"`
+			Links    []config.Link  `json:"links"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Status = %d, body %s: %v", w.Code, w.Body.String(), err)
+		}
+		if len(body.Projects) != 1 || body.Links == nil {
+			t.Fatalf("body = %s, want one project and the links", w.Body.String())
+		}
+		return body.Projects, body.Links
+	}
+
+	projects, rest := get(&auth.User{ID: "alice", Groups: []string{"family"}, AllowedServices: map[string][]string{"nas": {"immich-server"}}})
+	if len(projects[0].Links) != 2 || projects[0].Links[0].Name != "Photos" || projects[0].Links[1].Name != "Immich admin" {
+		t.Errorf("alice's immich links = %+v, want Photos and Immich admin", projects[0].Links)
+	}
+	if len(rest) != 1 || rest[0].Name != "NAS" {
+		t.Errorf("alice's other links = %+v, want NAS", rest)
+	}
+
+	// Links are filtered for the user like /api/links
+	projects, _ = get(&auth.User{ID: "bob", AllowedServices: map[string][]string{"nas": {"immich-server"}}})
+	if len(projects[0].Links) != 1 || projects[0].Links[0].Name != "Immich admin" {
+		t.Errorf("bob's immich links = %+v, want only Immich admin", projects[0].Links)
+	}
+}
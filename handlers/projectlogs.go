@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/events"
+	"home_server_dashboard/services"
+	"home_server_dashboard/services/docker"
+	"home_server_dashboard/services/systemd"
+)
+
+// projectLogColors is how many colors the log viewer has for the services
+// of a project.
+const projectLogColors = 8
+
+// projectLister lists the containers of a compose project. It is
+// implemented by the Docker provider.
+type projectLister interface {
+	ProjectContainers(ctx context.Context, project string) ([]docker.ProjectContainer, error)
+}
+
+// projectLogLine is a line of a project's logs, as sent with format=json.
+type projectLogLine struct {
+	Service   string `json:"service"`
+	Container string `json:"container"`
+	Color     int    `json:"color"` // 0 to projectLogColors-1, the same for every line of a service
+	Line      string `json:"line"`
+}
+
+// serviceColor returns the color the lines of service are shown in, so
+// each service keeps its color across streams and reloads.
+func serviceColor(service string) int {
+	h := fnv.New32a()
+	h.Write([]byte(service))
+	return int(h.Sum32() % projectLogColors)
+}
+
+// logMerger interleaves the logs of several containers in the order their
+// lines arrive, reading each container's logs in its own goroutine.
+type logMerger struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	provider services.Provider
+	opts     systemd.LogOptions
+	lines    chan projectLogLine
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	streams map[string]io.ReadCloser // Open streams by container name
+}
+
+// newLogMerger creates a merger reading logs from provider with opts until
+// ctx is done or it is closed.
+func newLogMerger(ctx context.Context, provider services.Provider, opts systemd.LogOptions) *logMerger {
+	ctx, cancel := context.WithCancel(ctx)
+	return &logMerger{
+		ctx:      ctx,
+		cancel:   cancel,
+		provider: provider,
+		opts:     opts,
+		lines:    make(chan projectLogLine),
+		streams:  make(map[string]io.ReadCloser),
+	}
+}
+
+// add starts following the logs of ctr, unless they are followed already.
+// A non-zero since starts them at that time instead of the tail, so a
+// container that joins mid-stream is shown from its start.
+func (m *logMerger) add(ctr docker.ProjectContainer, since time.Time) error {
+	if m.following(ctr.Name) {
+		return nil
+	}
+
+	opts := m.opts
+	if !since.IsZero() {
+		opts.Since = since
+	}
+	logs, err := openLogs(m.ctx, m.provider, ctr.Name, opts)
+	if errors.Is(err, systemd.ErrCannotResume) {
+		opts.Since = time.Time{}
+		logs, err = openLogs(m.ctx, m.provider, ctr.Name, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if m.ctx.Err() != nil {
+		m.mu.Unlock()
+		logs.Close()
+		return m.ctx.Err()
+	}
+	m.streams[ctr.Name] = logs
+	m.wg.Add(1)
+	m.mu.Unlock()
+
+	go m.follow(ctr, logs)
+	return nil
+}
+
+// following reports whether the logs of the named container are open.
+func (m *logMerger) following(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.streams[name]
+	return ok
+}
+
+// follow sends the lines of ctr's logs until they end, as they do when the
+// container stops, or the merger is closed.
+func (m *logMerger) follow(ctr docker.ProjectContainer, logs io.ReadCloser) {
+	defer m.wg.Done()
+	defer m.release(ctr.Name, logs)
+
+	color := serviceColor(ctr.Service)
+	scanner := bufio.NewScanner(logs)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		select {
+		case m.lines <- projectLogLine{Service: ctr.Service, Container: ctr.Name, Color: color, Line: line}:
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// release closes logs and forgets them, unless close did already.
+func (m *logMerger) release(name string, logs io.ReadCloser) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.streams[name] == logs {
+		delete(m.streams, name)
+		logs.Close()
+	}
+}
+
+// close closes every stream and waits for their goroutines to end.
+func (m *logMerger) close() {
+	m.mu.Lock()
+	m.cancel()
+	for name, logs := range m.streams {
+		logs.Close()
+		delete(m.streams, name)
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+}
+
+// ProjectLogsHandler handles GET /api/logs/project requests.
+// Streams the logs of every container of a compose project as one SSE
+// stream, like docker compose logs -f: each line is prefixed with its
+// service, or sent as JSON with the service's color with format=json.
+// Containers that start while the stream is open, after a scale-up or a
+// recreate, are added to it. Only the services the user may view logs of
+// are included.
+func ProjectLogsHandler(w http.ResponseWriter, r *http.Request) {
+	project := r.URL.Query().Get("project")
+	if project == "" {
+		http.Error(w, "project parameter required", http.StatusBadRequest)
+		return
+	}
+	params, err := parseLogParams(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var asJSON bool
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "text":
+	case "json":
+		asJSON = true
+	default:
+		http.Error(w, fmt.Sprintf("invalid format %q: must be text or json", format), http.StatusBadRequest)
+		return
+	}
+
+	cfg := configSource()
+	localHostName := "localhost"
+	if cfg != nil {
+		localHostName = cfg.GetLocalHostName()
+	}
+	hostName := r.URL.Query().Get("host")
+	if hostName == "" {
+		hostName = localHostName
+	}
+	host := hostOrLocal(cfg, hostName)
+	if !host.IsLocal() {
+		http.Error(w, fmt.Sprintf("Project logs are only available for the local host, not %s", hostName), http.StatusBadRequest)
+		return
+	}
+
+	user := auth.GetUserFromContext(r.Context())
+	redactor, ok := params.redactor(user, cfg)
+	if !ok {
+		http.Error(w, "Access denied: only admins may view logs unredacted", http.StatusForbidden)
+		return
+	}
+
+	dockerProvider, closeProvider, err := logProvider(cfg, "docker", host)
+	defer closeProvider()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	lister, ok := dockerProvider.(projectLister)
+	if !ok {
+		http.Error(w, "Project logs are not supported by this Docker provider", http.StatusNotImplemented)
+		return
+	}
+
+	// viewable lists the project's containers the user may view logs of
+	viewable := func(ctx context.Context) ([]docker.ProjectContainer, int, error) {
+		containers, err := lister.ProjectContainers(ctx, project)
+		if err != nil {
+			return nil, 0, err
+		}
+		var result []docker.ProjectContainer
+		for _, ctr := range containers {
+			if user != nil && !user.CanViewLogs(hostName, ctr.Service) {
+				continue
+			}
+			if ctr.Hidden && !canSeeHidden(user) {
+				continue
+			}
+			result = append(result, ctr)
+		}
+		return result, len(containers), nil
+	}
+	containers, total, err := viewable(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list project containers: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if total == 0 {
+		http.Error(w, fmt.Sprintf("No containers found for project %s", project), http.StatusNotFound)
+		return
+	}
+	if len(containers) == 0 {
+		http.Error(w, "Access denied: you do not have permission to view logs for this project", http.StatusForbidden)
+		return
+	}
+
+	// Subscribe before opening the streams, so a container starting in
+	// between isn't missed; add skips the ones already followed
+	started := make(chan *events.ContainerStartedEvent, 16)
+	if bus := currentEventBus(); bus != nil {
+		sub := bus.Subscribe(events.ContainerStarted, func(e events.Event) {
+			if e, ok := e.(*events.ContainerStartedEvent); ok && e.Host == hostName && e.Project == project {
+				select {
+				case started <- e:
+				default:
+				}
+			}
+		})
+		defer sub.Unsubscribe()
+	}
+
+	r, done, ok := trackStream(w, r, hostName+"/"+project)
+	if !ok {
+		return
+	}
+	defer done()
+
+	// Set headers for SSE
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ctx := r.Context()
+	logOpts, warning := params.options(nil, cfg)
+	sendLogWarning(w, flusher, warning)
+
+	merger := newLogMerger(ctx, dockerProvider, logOpts)
+	defer merger.close()
+	for _, ctr := range containers {
+		if err := merger.add(ctr, time.Time{}); err != nil {
+			sendLogWarning(w, flusher, fmt.Sprintf("Cannot stream the logs of %s: %v", ctr.Name, err))
+		}
+	}
+	// A quiet project may send nothing for a while; the viewer knows the
+	// stream is open from the headers
+	flusher.Flush()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case line := <-merger.lines:
+			line.Line = redactor.Line(line.Line)
+			if asJSON {
+				data, _ := json.Marshal(line)
+				sendLogLine(w, flusher, "", string(data))
+			} else {
+				sendLogLine(w, flusher, "", line.Service+" | "+line.Line)
+			}
+		case e := <-started:
+			// The container's name and permissions come from a fresh
+			// listing, as the event doesn't carry its labels
+			containers, _, err := viewable(ctx)
+			if err != nil {
+				log.Printf("Project logs: failed to list containers of %s on %s: %v", project, hostName, err)
+				continue
+			}
+			for _, ctr := range containers {
+				if ctr.Name != e.ContainerName {
+					continue
+				}
+				if err := merger.add(ctr, e.StartedAt); err != nil {
+					sendLogWarning(w, flusher, fmt.Sprintf("Cannot stream the logs of %s: %v", ctr.Name, err))
+				}
+			}
+		}
+	}
+}
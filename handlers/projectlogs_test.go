@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/config"
+	"home_server_dashboard/events"
+	"home_server_dashboard/services"
+	"home_server_dashboard/services/docker"
+	"home_server_dashboard/services/systemd"
+	"home_server_dashboard/testharness"
+)
+
+// fakeLogStream is a container's log stream the test writes lines to.
+type fakeLogStream struct {
+	*io.PipeReader
+	w      *io.PipeWriter
+	opts   systemd.LogOptions
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (s *fakeLogStream) Close() error {
+	s.once.Do(func() { close(s.closed) })
+	return s.PipeReader.Close()
+}
+
+// projectProvider is a Docker provider for a compose project whose
+// containers' logs are pipes the test writes to.
+type projectProvider struct {
+	fakeProvider
+	mu         sync.Mutex
+	containers []docker.ProjectContainer
+	streams    map[string]*fakeLogStream
+	opened     chan string // Names of the containers whose logs are opened
+}
+
+func newProjectProvider(containers ...docker.ProjectContainer) *projectProvider {
+	return &projectProvider{
+		containers: containers,
+		streams:    make(map[string]*fakeLogStream),
+		opened:     make(chan string, 16),
+	}
+}
+
+func (p *projectProvider) ProjectContainers(ctx context.Context, project string) ([]docker.ProjectContainer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if project != "media" {
+		return nil, nil
+	}
+	return append([]docker.ProjectContainer{}, p.containers...), nil
+}
+
+func (p *projectProvider) GetLogsWithOptions(ctx context.Context, name string, opts systemd.LogOptions) (io.ReadCloser, error) {
+	r, w := io.Pipe()
+	stream := &fakeLogStream{PipeReader: r, w: w, opts: opts, closed: make(chan struct{})}
+	p.mu.Lock()
+	p.streams[name] = stream
+	p.mu.Unlock()
+	p.opened <- name
+	return stream, nil
+}
+
+// start adds a container to the project.
+func (p *projectProvider) start(ctr docker.ProjectContainer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.containers = append(p.containers, ctr)
+}
+
+// stream returns the log stream of the named container.
+func (p *projectProvider) stream(name string) *fakeLogStream {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.streams[name]
+}
+
+// waitOpened waits for the logs of the named containers to be opened.
+func (p *projectProvider) waitOpened(t *testing.T, names ...string) {
+	t.Helper()
+	want := make(map[string]bool)
+	for _, name := range names {
+		want[name] = true
+	}
+	for len(want) > 0 {
+		select {
+		case name := <-p.opened:
+			delete(want, name)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("logs of %v not opened", want)
+		}
+	}
+}
+
+// withProjectProvider serves p as the Docker provider of the local host and
+// returns the bus the handlers listen to.
+func withProjectProvider(t *testing.T, p *projectProvider) *events.Bus {
+	t.Helper()
+	origConfig := configSource
+	SetConfigSource(func() *config.Config {
+		return &config.Config{Hosts: []config.HostConfig{
+			{Name: "server", Address: "localhost"},
+			{Name: "nas", Address: "192.168.1.20"},
+		}}
+	})
+	SetSourceRegistry(testharness.Registry{{
+		Source:       "docker",
+		Factory:      func(cfg *config.Config, host *config.HostConfig) (services.Provider, error) { return p, nil },
+		Capabilities: services.Capabilities{Logs: true},
+	}})
+	bus := events.NewBus(false)
+	SetEventBus(bus)
+	t.Cleanup(func() {
+		configSource = origConfig
+		SetSourceRegistry(nil)
+		SetEventBus(nil)
+	})
+	return bus
+}
+
+// openProjectLogs streams path from ProjectLogsHandler as user, and returns
+// the response and a channel of the data lines it sends.
+func openProjectLogs(t *testing.T, path string, user *auth.User) (*http.Response, <-chan string) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user != nil {
+			r = r.WithContext(context.WithValue(r.Context(), authUserContextKey, user))
+		}
+		ProjectLogsHandler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	lines := make(chan string, 16)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				lines <- data
+			}
+		}
+	}()
+	return resp, lines
+}
+
+// nextLine returns the next data line sent.
+func nextLine(t *testing.T, lines <-chan string) string {
+	t.Helper()
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(2 * time.Second):
+		t.Fatal("no line sent")
+		return ""
+	}
+}
+
+func TestProjectLogsHandler(t *testing.T) {
+	p := newProjectProvider(
+		docker.ProjectContainer{Name: "media-db-1", Service: "db"},
+		docker.ProjectContainer{Name: "media-web-1", Service: "web"},
+		docker.ProjectContainer{Name: "media-worker-1", Service: "worker"},
+	)
+	bus := withProjectProvider(t, p)
+	subscribed := bus.HandlerCount()
+
+	resp, lines := openProjectLogs(t, "/api/logs/project?project=media", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Status = %d", resp.StatusCode)
+	}
+	p.waitOpened(t, "media-db-1", "media-web-1", "media-worker-1")
+
+	// Merge: every service's lines come through the one stream, prefixed
+	// with the service
+	for _, tt := range []struct{ container, line, want string }{
+		{"media-web-1", "GET / 200\n", "web | GET / 200"},
+		{"media-db-1", "checkpoint complete\r\n", "db | checkpoint complete"},
+		{"media-worker-1", "job 1 done\n", "worker | job 1 done"},
+		{"media-web-1", "GET /favicon.ico 404\n", "web | GET /favicon.ico 404"},
+	} {
+		io.WriteString(p.stream(tt.container).w, tt.line)
+		if got := nextLine(t, lines); got != tt.want {
+			t.Errorf("line = %q, want %q", got, tt.want)
+		}
+	}
+
+	// Late join: a container of another project is left out, and one
+	// scaled up in this one is added from when it started
+	bus.Publish(events.NewContainerStartedEvent("server", "other-web-1", "other", "web", time.Now()))
+	startedAt := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	p.start(docker.ProjectContainer{Name: "media-worker-2", Service: "worker"})
+	bus.Publish(events.NewContainerStartedEvent("server", "media-worker-2", "media", "worker", startedAt))
+	p.waitOpened(t, "media-worker-2")
+	if since := p.stream("media-worker-2").opts.Since; !since.Equal(startedAt) {
+		t.Errorf("late joiner opened since %v, want %v", since, startedAt)
+	}
+	io.WriteString(p.stream("media-worker-2").w, "job 2 done\n")
+	if got := nextLine(t, lines); got != "worker | job 2 done" {
+		t.Errorf("late joiner's line = %q", got)
+	}
+	if s := p.stream("other-web-1"); s != nil {
+		t.Error("opened the logs of another project's container")
+	}
+
+	// A stopped container's stream ends on its own; the others go on
+	p.stream("media-db-1").w.Close()
+	select {
+	case <-p.stream("media-db-1").closed:
+	case <-time.After(2 * time.Second):
+		t.Error("ended stream of media-db-1 not closed")
+	}
+	io.WriteString(p.stream("media-web-1").w, "still here\n")
+	if got := nextLine(t, lines); got != "web | still here" {
+		t.Errorf("line after a stream ended = %q", got)
+	}
+
+	// Cleanup: disconnecting closes every stream and the subscription
+	resp.Body.Close()
+	for _, name := range []string{"media-web-1", "media-worker-1", "media-worker-2"} {
+		select {
+		case <-p.stream(name).closed:
+		case <-time.After(2 * time.Second):
+			t.Errorf("stream of %s not closed after disconnecting", name)
+		}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for bus.HandlerCount() != subscribed && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := bus.HandlerCount(); got != subscribed {
+		t.Errorf("%d handlers subscribed after disconnecting, want %d", got, subscribed)
+	}
+}
+
+func TestProjectLogsHandler_JSON(t *testing.T) {
+	p := newProjectProvider(docker.ProjectContainer{Name: "media-web-1", Service: "web"})
+	withProjectProvider(t, p)
+
+	_, lines := openProjectLogs(t, "/api/logs/project?project=media&format=json", nil)
+	p.waitOpened(t, "media-web-1")
+	io.WriteString(p.stream("media-web-1").w, "GET / 200\n")
+
+	var got projectLogLine
+	if err := json.Unmarshal([]byte(nextLine(t, lines)), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := projectLogLine{Service: "web", Container: "media-web-1", Color: serviceColor("web"), Line: "GET / 200"}
+	if got != want {
+		t.Errorf("line = %+v, want %+v", got, want)
+	}
+}
+
+func TestProjectLogsHandler_Permissions(t *testing.T) {
+	p := newProjectProvider(
+		docker.ProjectContainer{Name: "media-db-1", Service: "db", Hidden: true},
+		docker.ProjectContainer{Name: "media-web-1", Service: "web"},
+		docker.ProjectContainer{Name: "media-worker-1", Service: "worker"},
+	)
+	withProjectProvider(t, p)
+
+	// Only the services the user may view are streamed
+	viewer := &auth.User{AllowedServices: map[string][]string{"server": {"web", "db"}}}
+	_, lines := openProjectLogs(t, "/api/logs/project?project=media", viewer)
+	p.waitOpened(t, "media-web-1")
+	io.WriteString(p.stream("media-web-1").w, "GET / 200\n")
+	if got := nextLine(t, lines); got != "web | GET / 200" {
+		t.Errorf("line = %q", got)
+	}
+	if p.stream("media-db-1") != nil || p.stream("media-worker-1") != nil {
+		t.Error("opened the logs of a hidden or forbidden service")
+	}
+
+	tests := []struct {
+		name string
+		path string
+		user *auth.User
+		want int
+	}{
+		{"no viewable services", "/api/logs/project?project=media", &auth.User{AllowedServices: map[string][]string{"server": {"db"}}}, http.StatusForbidden},
+		{"unknown project", "/api/logs/project?project=other", nil, http.StatusNotFound},
+		{"no project", "/api/logs/project", nil, http.StatusBadRequest},
+		{"bad format", "/api/logs/project?project=media&format=xml", nil, http.StatusBadRequest},
+		{"remote host", "/api/logs/project?project=media&host=nas", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.user != nil {
+				req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, tt.user))
+			}
+			w := httptest.NewRecorder()
+			ProjectLogsHandler(w, req)
+			if w.Code != tt.want {
+				t.Errorf("Status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestServiceColor(t *testing.T) {
+	for _, service := range []string{"web", "db", "worker", ""} {
+		if c := serviceColor(service); c < 0 || c >= projectLogColors || c != serviceColor(service) {
+			t.Errorf("serviceColor(%q) = %d", service, c)
+		}
+	}
+}
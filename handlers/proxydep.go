@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/realip"
+	"home_server_dashboard/services"
+)
+
+// requestHostName returns the host name the client asked for, lower-case
+// and without the port. Behind a trusted proxy it is the forwarded host.
+func requestHostName(r *http.Request) string {
+	return bareHost(realip.Host(r))
+}
+
+// bareHost returns host without its port or IPv6 brackets, lower-case.
+func bareHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
+
+// urlHostName returns the host name of rawURL, lower-case, or "" if it has none.
+func urlHostName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// servesHost reports whether one of svc's Traefik URLs is for host.
+func servesHost(svc services.ServiceInfo, host string) bool {
+	for _, u := range svc.TraefikURLs {
+		if urlHostName(u) == host {
+			return true
+		}
+	}
+	return false
+}
+
+// viaReverseProxy reports whether a request for host came through a
+// reverse proxy: host is the dashboard's service_url, or one that Traefik
+// routes to one of svcList. Requests for an address or a local name reach
+// the dashboard directly.
+func viaReverseProxy(cfg *config.Config, svcList []services.ServiceInfo, host string) bool {
+	if host == "" {
+		return false
+	}
+	if cfg != nil && cfg.OIDC != nil && urlHostName(cfg.OIDC.ServiceURL) == host {
+		return true
+	}
+	for _, svc := range svcList {
+		if servesHost(svc, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// isProxyDependency reports whether a request for host, which proxied says
+// came through a reverse proxy, depends on svc: Traefik routes host to it,
+// or it is the Traefik of its host while the request was proxied.
+func isProxyDependency(cfg *config.Config, svc services.ServiceInfo, host string, proxied bool) bool {
+	if host != "" && servesHost(svc, host) {
+		return true
+	}
+	if !proxied || cfg == nil {
+		return false
+	}
+	hostCfg := cfg.GetHostByName(svc.Host)
+	if hostCfg == nil {
+		return false
+	}
+	proxy := hostCfg.GetTraefikService()
+	return proxy != "" && (svc.Name == proxy || svc.ContainerName == proxy)
+}
+
+// markProxyDependencies sets ProxyDependency on the services a request for
+// host came through. The dashboard itself is flagged with IsSelf instead.
+func markProxyDependencies(cfg *config.Config, svcList []services.ServiceInfo, host string) {
+	proxied := viaReverseProxy(cfg, svcList, host)
+	for i := range svcList {
+		if !svcList[i].IsSelf && isProxyDependency(cfg, svcList[i], host, proxied) {
+			svcList[i].ProxyDependency = true
+		}
+	}
+}
+
+// actionOnProxy reports whether an action asked for by r targets a service
+// the request came through, judged from the services last collected, even
+// if a state change invalidated them since. It is false if none were
+// collected yet.
+func actionOnProxy(r *http.Request, cfg *config.Config, req ServiceActionRequest) bool {
+	snapshot := servicesCache.last()
+	if snapshot == nil {
+		return false
+	}
+	host := requestHostName(r)
+	proxied := viaReverseProxy(cfg, snapshot.services, host)
+	for _, svc := range snapshot.services {
+		if svc.Host == req.Host && svc.Source == req.Source && (svc.Name == req.ServiceName || svc.ContainerName == req.ContainerName) {
+			return isProxyDependency(cfg, svc, host, proxied)
+		}
+	}
+	return false
+}
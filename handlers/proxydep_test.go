@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/realip"
+	"home_server_dashboard/services"
+)
+
+// proxyTestServices are a Traefik container and the services it routes.
+func proxyTestServices() []services.ServiceInfo {
+	return []services.ServiceInfo{
+		{Name: "traefik", ContainerName: "traefik", Source: "docker", Host: "nas"},
+		{Name: "dashboard", ContainerName: "dashboard", Source: "docker", Host: "nas", TraefikURLs: []string{"https://home.example.com"}, IsSelf: true},
+		{Name: "authelia", ContainerName: "authelia", Source: "docker", Host: "nas", TraefikURLs: []string{"https://home.example.com", "https://auth.example.com"}},
+		{Name: "sonarr", ContainerName: "sonarr", Source: "docker", Host: "nas", TraefikURLs: []string{"https://sonarr.example.com"}},
+	}
+}
+
+func proxyTestConfig() *config.Config {
+	return &config.Config{Hosts: []config.HostConfig{
+		{Name: "nas", Address: "localhost", Traefik: config.TraefikConfig{Enabled: true}},
+	}}
+}
+
+// flagged returns the names of the services flagged as a proxy dependency.
+func flagged(svcList []services.ServiceInfo) []string {
+	var names []string
+	for _, svc := range svcList {
+		if svc.ProxyDependency {
+			names = append(names, svc.Name)
+		}
+	}
+	return names
+}
+
+func TestMarkProxyDependencies(t *testing.T) {
+	realip.Configure([]netip.Prefix{netip.MustParsePrefix("172.18.0.0/16")})
+	t.Cleanup(func() { realip.Configure(nil) })
+
+	tests := []struct {
+		name          string
+		host          string
+		forwardedHost string
+		remoteAddr    string
+		want          []string
+	}{
+		{"routed host", "home.example.com", "", "", []string{"traefik", "authelia"}},
+		{"with a port", "home.example.com:443", "", "", []string{"traefik", "authelia"}},
+		{"upper case", "Home.Example.COM", "", "", []string{"traefik", "authelia"}},
+		{"another routed host", "auth.example.com", "", "", []string{"traefik", "authelia"}},
+		{"forwarded by a trusted proxy", "dashboard:9001", "home.example.com", "172.18.0.5:40000", []string{"traefik", "authelia"}},
+		{"forwarded by an untrusted client", "192.168.1.10:9001", "home.example.com", "192.168.1.50:40000", nil},
+		{"by address", "192.168.1.10:9001", "", "", nil},
+		{"by IPv6 address", "[fd00::10]:9001", "", "", nil},
+		{"local name", "nas.local:9001", "", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/services", nil)
+			r.Host = tt.host
+			if tt.forwardedHost != "" {
+				r.Header.Set("X-Forwarded-Host", tt.forwardedHost)
+			}
+			if tt.remoteAddr != "" {
+				r.RemoteAddr = tt.remoteAddr
+			}
+			svcList := proxyTestServices()
+			markProxyDependencies(proxyTestConfig(), svcList, requestHostName(r))
+			if got := flagged(svcList); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("flagged %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkProxyDependencies_Config(t *testing.T) {
+	// The dashboard's service_url counts as proxied even if no service
+	// lists it, and the proxy service can be named
+	cfg := &config.Config{
+		OIDC: &config.OIDCConfig{ServiceURL: "https://dash.example.com"},
+		Hosts: []config.HostConfig{
+			{Name: "nas", Address: "localhost", Traefik: config.TraefikConfig{Enabled: true, Service: "edge"}},
+		},
+	}
+	svcList := []services.ServiceInfo{
+		{Name: "traefik", Host: "nas"},
+		{Name: "proxy", ContainerName: "edge", Host: "nas"},
+	}
+	markProxyDependencies(cfg, svcList, "dash.example.com")
+	if got := flagged(svcList); len(got) != 1 || got[0] != "proxy" {
+		t.Errorf("flagged %v, want the configured proxy", got)
+	}
+
+	// Without Traefik on the host, there is no proxy to flag
+	cfg.Hosts[0].Traefik.Enabled = false
+	svcList[1].ProxyDependency = false
+	markProxyDependencies(cfg, svcList, "dash.example.com")
+	if got := flagged(svcList); len(got) != 0 {
+		t.Errorf("flagged %v without Traefik", got)
+	}
+}
+
+func TestServiceActionHandler_ProxyDependency(t *testing.T) {
+	configJSON := `{
+		"hosts": [
+			{
+				"name": "nas",
+				"address": "localhost",
+				"traefik": {"enabled": true},
+				"systemd_services": [],
+				"docker_compose_roots": []
+			}
+		]
+	}`
+	cleanup := setupTestConfig(t, configJSON)
+	defer cleanup()
+	withContainerProject(t, "")
+
+	original := servicesCache
+	servicesCache = newSnapshotCache()
+	defer func() { servicesCache = original }()
+	servicesCache.store(servicesCache.current(), nil, nil, proxyTestServices())
+	// A state change since the collection doesn't hide the proxy
+	servicesCache.invalidate()
+
+	// Record whether the action's context was cancelled along with the request
+	var actionCtxErr error
+	ran := false
+	originalPlanner := actionPlanners["docker"]
+	actionPlanners["docker"] = planOf(func(ctx context.Context, sendEvent func(string, string)) error {
+		ran = true
+		actionCtxErr = ctx.Err()
+		return nil
+	})
+	defer func() { actionPlanners["docker"] = originalPlanner }()
+
+	// act restarts service through a connection for host that drops before
+	// the action runs
+	act := func(service, host string) string {
+		ran, actionCtxErr = false, nil
+		body := strings.NewReader(`{"container_name": "` + service + `", "service_name": "` + service + `", "source": "docker", "host": "nas"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/services/restart", body)
+		req.Host = host
+		ctx, cancel := context.WithCancel(req.Context())
+		cancel()
+		req = req.WithContext(ctx)
+		w := httptest.NewRecorder()
+		ServiceActionHandler(w, req)
+		if !ran {
+			t.Fatalf("restart of %s did not run: %s", service, w.Body.String())
+		}
+		return w.Body.String()
+	}
+
+	body := act("traefik", "home.example.com")
+	if actionCtxErr != nil {
+		t.Errorf("action context error = %v, want detached from the request", actionCtxErr)
+	}
+	if !strings.Contains(body, "event: warning\ndata: This connection goes through traefik, so the restart will interrupt it; the restart will continue in the background") {
+		t.Errorf("Expected the proxy warning, got: %s", body)
+	}
+
+	// Reached directly, the proxy is like any other service
+	body = act("traefik", "192.168.1.10:9001")
+	if actionCtxErr == nil {
+		t.Error("action context not cancelled with the request")
+	}
+	if strings.Contains(body, "This connection goes through") {
+		t.Errorf("unexpected proxy warning: %s", body)
+	}
+
+	// Services the request doesn't go through aren't detached either
+	body = act("sonarr", "home.example.com")
+	if actionCtxErr == nil || strings.Contains(body, "This connection goes through") {
+		t.Errorf("sonarr: context error = %v, body: %s", actionCtxErr, body)
+	}
+}
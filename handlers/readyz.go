@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/connlimit"
+	"home_server_dashboard/monitor"
+	"home_server_dashboard/resilience"
+	"home_server_dashboard/version"
+)
+
+// ReadinessReporter reports whether the event sources the dashboard
+// watches are connected. It is implemented by the monitor.
+type ReadinessReporter interface {
+	Readiness() []monitor.Capability
+}
+
+// readyzResponse is the response of GET /readyz.
+type readyzResponse struct {
+	Ready        bool                 `json:"ready"`
+	Version      version.Info         `json:"version"`
+	Capabilities []monitor.Capability `json:"capabilities"`
+	Warmup       *WarmupStatus        `json:"warmup,omitempty"`
+	Hosts        []readyzHost         `json:"hosts"`
+}
+
+// readyzHost is the circuit breaker of a configured host and the SSH
+// sessions open to it in GET /readyz.
+type readyzHost struct {
+	Name     string              `json:"name"`
+	Breaker  resilience.Snapshot `json:"breaker"`
+	SSHInUse int                 `json:"ssh_in_use"`
+}
+
+// readyzHosts returns the breaker and SSH sessions in use of every enabled
+// host in cfg, in config order. Local hosts are read directly, so their
+// breaker stays closed and they use no sessions.
+func readyzHosts(cfg *config.Config) []readyzHost {
+	hosts := []readyzHost{}
+	if cfg == nil {
+		return hosts
+	}
+	for _, host := range cfg.Hosts {
+		if !host.IsEnabled() {
+			continue
+		}
+		hosts = append(hosts, readyzHost{
+			Name:     host.Name,
+			Breaker:  resilience.HostSnapshot(host.Name),
+			SSHInUse: connlimit.Default().InUse(host.Address),
+		})
+	}
+	return hosts
+}
+
+// ReadyzHandler handles GET /readyz requests.
+// Returns 200 once every event source the monitor watches is ready or
+// disabled, and 503 while any is still initializing or has failed, with
+// the phase of each. Without a monitor there is nothing to wait for. The
+// startup warm-up is reported too, and holds up readiness until it ends if
+// configured to. The build information of the running binary is included,
+// and so are the circuit breaker and SSH sessions in use of each
+// host, which don't affect readiness. This endpoint is public, for health checks.
+func ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp := readyzResponse{Ready: true, Version: version.Get(), Capabilities: []monitor.Capability{}}
+	if reporter, ok := stateTracker.(ReadinessReporter); ok {
+		resp.Capabilities = reporter.Readiness()
+	}
+	for _, c := range resp.Capabilities {
+		if c.Phase != monitor.PhaseReady && c.Phase != monitor.PhaseDisabled {
+			resp.Ready = false
+		}
+	}
+	if w := currentWarmup(); w != nil {
+		status, holding := w.report()
+		resp.Warmup = &status
+		if holding {
+			resp.Ready = false
+		}
+	}
+
+	resp.Hosts = readyzHosts(configSource())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if !resp.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"home_server_dashboard/connlimit"
+	"home_server_dashboard/monitor"
+	"home_server_dashboard/resilience"
+	"home_server_dashboard/version"
+)
+
+// fakeReadinessReporter is a fakeStateTracker that also reports the
+// readiness of its event sources.
+type fakeReadinessReporter struct {
+	fakeStateTracker
+	caps []monitor.Capability
+}
+
+func (f fakeReadinessReporter) Readiness() []monitor.Capability {
+	return f.caps
+}
+
+func TestReadyzHandler(t *testing.T) {
+	original := stateTracker
+	defer SetStateTracker(original)
+
+	tests := []struct {
+		name    string
+		tracker StateTracker
+		want    int
+	}{
+		{"initializing", fakeReadinessReporter{caps: []monitor.Capability{
+			{Name: monitor.CapabilityDockerEvents, Phase: monitor.PhaseInitializing, Attempts: 2},
+			{Name: monitor.CapabilitySystemdDBus, Phase: monitor.PhaseReady, Attempts: 1},
+		}}, http.StatusServiceUnavailable},
+		{"failed", fakeReadinessReporter{caps: []monitor.Capability{
+			{Name: monitor.CapabilityDockerEvents, Phase: monitor.PhaseFailed, Attempts: 9},
+		}}, http.StatusServiceUnavailable},
+		{"ready", fakeReadinessReporter{caps: []monitor.Capability{
+			{Name: monitor.CapabilityDockerEvents, Phase: monitor.PhaseReady, Attempts: 4},
+			{Name: monitor.CapabilitySystemdDBus, Phase: monitor.PhaseDisabled, Attempts: 1},
+		}}, http.StatusOK},
+		{"no monitor", fakeStateTracker{}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetStateTracker(tt.tracker)
+			w := getDebug(ReadyzHandler, "/readyz", nil)
+			if w.Code != tt.want {
+				t.Errorf("Status = %d, want %d", w.Code, tt.want)
+			}
+			var resp readyzResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Ready != (tt.want == http.StatusOK) {
+				t.Errorf("ready = %v with status %d", resp.Ready, w.Code)
+			}
+			if resp.Version.Version != version.Version || resp.Version.GoVersion == "" {
+				t.Errorf("version = %+v, want the running binary's", resp.Version)
+			}
+			if reporter, ok := tt.tracker.(fakeReadinessReporter); ok && len(resp.Capabilities) != len(reporter.caps) {
+				t.Errorf("capabilities = %+v", resp.Capabilities)
+			}
+		})
+	}
+}
+
+func TestReadyzHandler_HostBreakers(t *testing.T) {
+	original := stateTracker
+	defer SetStateTracker(original)
+	SetStateTracker(fakeStateTracker{})
+	defer SetConfigSource(nil)
+	SetConfigSource(hostGroupsConfig)
+
+	resilience.Configure(1, time.Minute)
+	defer resilience.Configure(resilience.DefaultThreshold, resilience.DefaultCooldown)
+	resilience.Do(context.Background(), "pi", func(ctx context.Context) error {
+		return errors.New("ssh: connection reset")
+	})
+
+	release, err := connlimit.Default().Acquire(context.Background(), "192.168.1.30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	w := getDebug(ReadyzHandler, "/readyz", nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("Status = %d, want an open breaker not to affect readiness", w.Code)
+	}
+	var resp struct {
+		Hosts []struct {
+			Name     string `json:"name"`
+			SSHInUse *int   `json:"ssh_in_use"`
+			Breaker  *struct {
+				State   resilience.State `json:"state"`
+				RetryAt *time.Time       `json:"retry_at"`
+			} `json:"breaker"`
+		} `json:"hosts"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Hosts) != 3 {
+		t.Fatalf("hosts = %+v, want the 3 enabled hosts", resp.Hosts)
+	}
+	for _, host := range resp.Hosts {
+		if host.Breaker == nil || host.SSHInUse == nil {
+			t.Fatalf("%s has no breaker or SSH sessions", host.Name)
+		}
+		wantInUse := 0
+		if host.Name == "backup" {
+			wantInUse = 1
+		}
+		if *host.SSHInUse != wantInUse {
+			t.Errorf("%s ssh_in_use = %d, want %d", host.Name, *host.SSHInUse, wantInUse)
+		}
+		switch host.Name {
+		case "pi":
+			if host.Breaker.State != resilience.StateOpen || host.Breaker.RetryAt == nil {
+				t.Errorf("pi breaker = %+v, want open with a retry time", host.Breaker)
+			}
+		default:
+			if host.Breaker.State != resilience.StateClosed {
+				t.Errorf("%s breaker = %+v, want closed", host.Name, host.Breaker)
+			}
+		}
+	}
+}
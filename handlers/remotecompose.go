@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/connlimit"
+	"home_server_dashboard/services/systemd"
+	"home_server_dashboard/sshmux"
+)
+
+// runSSH runs command in the shell of host over SSH, with the host's SSH
+// settings, under its SSH connection limit and over its shared connection if
+// there is one, writing the command's output to stdout and stderr (replaced
+// in tests). It fails with sshmux.ErrConnectionLost if ssh itself failed.
+var runSSH = func(ctx context.Context, host *config.HostConfig, command string, stdout, stderr io.Writer) error {
+	release, err := connlimit.Acquire(ctx, host.Address)
+	if err != nil {
+		return fmt.Errorf("waiting for SSH slot: %w", err)
+	}
+	defer release()
+
+	target := host.GetSSHTarget()
+	args := append(sshmux.Args(ctx, host.GetSSHArgs(), target), host.GetSSHArgs()...)
+	args = append(args, target, command)
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	killGroupOnCancel(cmd)
+	err = cmd.Run()
+	// ssh exits 255 when it, not the command, failed
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 255 {
+		sshmux.Lost(host.GetSSHArgs(), target)
+		return sshmux.ConnectionLost(err)
+	}
+	return err
+}
+
+// shellCommand joins args into a command line for a POSIX shell.
+func shellCommand(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = systemd.ShellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// remoteDeadline returns command, run over SSH under ctx, bounded by what
+// is left of ctx's deadline on the remote host too. Killing ssh when ctx
+// ends doesn't stop a command without a terminal there, so a hung docker
+// would go on after the action ended and its project lock was released.
+func remoteDeadline(ctx context.Context, command []string) []string {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return command
+	}
+	secs := max(int(math.Ceil(time.Until(deadline).Seconds())), 1)
+	return append([]string{"timeout", "-s", "KILL", strconv.Itoa(secs)}, command...)
+}
+
+// remoteComposeCommand returns the shell command that runs
+// "docker compose <args>" in dir, bounded by ctx's deadline (see
+// remoteDeadline).
+func remoteComposeCommand(ctx context.Context, dir string, args []string) string {
+	return "cd " + systemd.ShellQuote(dir) + " && " + shellCommand(remoteDeadline(ctx, append([]string{"docker", "compose"}, args...))...)
+}
+
+// remoteComposeStep is composeStep for a project on a remote host: the
+// command runs in dir there over SSH.
+func remoteComposeStep(host *config.HostConfig, dir, status string, tolerateFailure bool, args ...string) plannedStep {
+	return plannedStep{
+		Description: fmt.Sprintf("docker compose %s (in %s on %s)", strings.Join(args, " "), dir, host.Name),
+		run: func(ctx context.Context, sendEvent func(string, string)) error {
+			sendEvent("status", status)
+			var output bytes.Buffer
+			err := runSSH(ctx, host, remoteComposeCommand(ctx, dir, args), &output, &output)
+			return reportComposeOutput(args, output.Bytes(), err, tolerateFailure, sendEvent)
+		},
+	}
+}
+
+// remoteComposeDir returns the directory of a compose project on a remote
+// host, or "" if it can't be found. Like on the local host, the directory
+// the container was brought up from comes first, then the host's
+// docker_compose_roots (as paths on the host): a root named after the
+// project, or the project's directory in a root. Only directories with a
+// compose file are picked. docker_compose_roots_glob is not searched, as
+// its patterns are matched against the local file system.
+func remoteComposeDir(ctx context.Context, host *config.HostConfig, containerName, project string) string {
+	var candidates []string
+	if containerName != "" {
+		var stdout bytes.Buffer
+		format := fmt.Sprintf("{{index .Config.Labels %q}}", "com.docker.compose.project.working_dir")
+		if err := runSSH(ctx, host, shellCommand("docker", "inspect", "--format", format, containerName), &stdout, io.Discard); err == nil {
+			if dir := strings.TrimSpace(stdout.String()); path.IsAbs(dir) {
+				candidates = append(candidates, dir)
+			}
+		}
+	}
+	project = config.NormalizeProjectName(project)
+	if project != "" {
+		for _, root := range host.DockerComposeRoots {
+			if config.NormalizeProjectName(path.Base(root)) == project {
+				candidates = append(candidates, root)
+			}
+			candidates = append(candidates, path.Join(root, project))
+		}
+	}
+	candidates = slices.Compact(candidates)
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	// Print the first candidate with a compose file
+	script := fmt.Sprintf(`for d in %s; do for f in %s; do if [ -f "$d/$f" ]; then echo "$d"; exit 0; fi; done; done; exit 1`,
+		shellCommand(candidates...), shellCommand(config.ComposeFileNames...))
+	var stdout bytes.Buffer
+	if err := runSSH(ctx, host, script, &stdout, io.Discard); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(stdout.String())
+}
+
+// planRemoteComposeRestart plans docker compose down/up for a service of a
+// remote host, run over SSH in the project's directory there (see
+// remoteComposeDir). The profile, if any, is checked by compose on the host
+// rather than beforehand. If the project isn't found, the container is
+// restarted with docker restart instead.
+func planRemoteComposeRestart(ctx context.Context, host *config.HostConfig, req ServiceActionRequest, sendEvent func(string, string)) (*actionPlan, error) {
+	composeRoot := remoteComposeDir(ctx, host, req.ContainerName, req.Project)
+	if composeRoot == "" {
+		if req.Profile != "" {
+			return nil, fmt.Errorf("cannot restart with profile %q: the compose project of %s was not found on %s", req.Profile, req.ServiceName, host.Name)
+		}
+		sendEvent("status", fmt.Sprintf("Could not find docker-compose root on %s, falling back to simple restart...", host.Name))
+		plan := &actionPlan{locked: true}
+		plan.add(fmt.Sprintf("docker restart %s (on %s)", req.ContainerName, host.Name), func(ctx context.Context, sendEvent func(string, string)) error {
+			sendEvent("status", fmt.Sprintf("Executing restart on container %s on %s...", req.ContainerName, host.Name))
+			var output bytes.Buffer
+			if err := runSSH(ctx, host, shellCommand(remoteDeadline(ctx, []string{"docker", "restart", req.ContainerName})...), &output, &output); err != nil {
+				return fmt.Errorf("failed to restart container: %s - %w", strings.TrimSpace(output.String()), err)
+			}
+			return nil
+		})
+		return plan, nil
+	}
+
+	sendEvent("status", fmt.Sprintf("Found compose root on %s: %s", host.Name, composeRoot))
+	var profileArgs []string
+	if req.Profile != "" {
+		profileArgs = []string{"--profile", req.Profile}
+	}
+	down := settleAfter(remoteComposeStep(host, composeRoot, fmt.Sprintf("Running docker compose down for %s on %s...", req.ServiceName, host.Name), true, slices.Concat(profileArgs, []string{"down", req.ServiceName})...))
+	up := remoteComposeStep(host, composeRoot, fmt.Sprintf("Running docker compose up -d for %s on %s...", req.ServiceName, host.Name), false, slices.Concat(profileArgs, []string{"up", "-d", req.ServiceName})...)
+	return &actionPlan{locked: true, steps: []plannedStep{down, up}}, nil
+}
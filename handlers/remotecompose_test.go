@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"home_server_dashboard/config"
+)
+
+// fakeSSH answers remote commands from a table, recording the host and
+// command line of each.
+type fakeSSH struct {
+	commands []string
+	// answer returns the stdout, stderr and error of a command.
+	answer func(command string) (stdout, stderr string, err error)
+}
+
+// useSSH replaces runSSH with f for the rest of the test.
+func useSSH(t *testing.T, f *fakeSSH) {
+	t.Helper()
+	original := runSSH
+	runSSH = func(ctx context.Context, host *config.HostConfig, command string, stdout, stderr io.Writer) error {
+		f.commands = append(f.commands, host.GetSSHTarget()+": "+command)
+		out, errOut, err := f.answer(command)
+		io.WriteString(stdout, out)
+		io.WriteString(stderr, errOut)
+		return err
+	}
+	t.Cleanup(func() { runSSH = original })
+}
+
+const inspectWorkingDir = `docker inspect --format '{{index .Config.Labels "com.docker.compose.project.working_dir"}}' media-sonarr-1`
+
+func remoteComposeConfig() *config.Config {
+	return &config.Config{Hosts: []config.HostConfig{
+		{Name: "nas", Address: "localhost"},
+		{Name: "pi", Address: "192.168.1.20", SSHConfig: &config.SSHConfig{Username: "admin"}, DockerComposeRoots: []string{"/opt/stacks", "/srv/media"}},
+	}}
+}
+
+// TestPlanDockerAction_RemoteComposeRestart tests that restarting a service
+// of a remote host runs compose down and up there over SSH, in the project
+// directory found from the container's label.
+func TestPlanDockerAction_RemoteComposeRestart(t *testing.T) {
+	ssh := &fakeSSH{answer: func(command string) (string, string, error) {
+		switch {
+		case command == inspectWorkingDir:
+			return "/home/admin/media\n", "", nil
+		case strings.HasPrefix(command, "for d in"):
+			return "/home/admin/media\n", "", nil
+		case strings.Contains(command, " down "):
+			return "", "Container media-sonarr-1 Stopped\n", nil
+		default:
+			return "", "Container media-sonarr-1 Started\n", nil
+		}
+	}}
+	useSSH(t, ssh)
+	req := ServiceActionRequest{ContainerName: "media-sonarr-1", ServiceName: "sonarr", Source: "docker", Host: "pi", Project: "media"}
+
+	var events []string
+	plan, err := planDockerAction(context.Background(), remoteComposeConfig(), req, "restart", func(eventType, message string) {
+		events = append(events, message)
+	})
+	if err != nil {
+		t.Fatalf("planDockerAction() error = %v", err)
+	}
+	want := []string{
+		"docker compose down sonarr (in /home/admin/media on pi)",
+		"docker compose up -d sonarr (in /home/admin/media on pi)",
+	}
+	if got := stepDescriptions(plan); !reflect.DeepEqual(got, want) {
+		t.Errorf("steps = %q, want %q", got, want)
+	}
+	if !plan.locked {
+		t.Error("plan does not take the project lock")
+	}
+
+	if err := executePlan(context.Background(), req, "restart", plan, stepRunner{}, func(eventType, message string) {
+		events = append(events, message)
+	}); err != nil {
+		t.Fatalf("executePlan() error = %v", err)
+	}
+
+	wantCommands := []string{
+		"admin@192.168.1.20: " + inspectWorkingDir,
+		`admin@192.168.1.20: for d in /home/admin/media /opt/stacks/media /srv/media /srv/media/media; do for f in docker-compose.yml docker-compose.yaml compose.yml compose.yaml; do if [ -f "$d/$f" ]; then echo "$d"; exit 0; fi; done; done; exit 1`,
+		"admin@192.168.1.20: cd /home/admin/media && docker compose down sonarr",
+		"admin@192.168.1.20: cd /home/admin/media && docker compose up -d sonarr",
+	}
+	if !reflect.DeepEqual(ssh.commands, wantCommands) {
+		t.Errorf("commands = %q\nwant %q", ssh.commands, wantCommands)
+	}
+	wantEvents := []string{
+		"Found compose root on pi: /home/admin/media",
+		"Running docker compose down for sonarr on pi...",
+		"Container media-sonarr-1 Stopped",
+		"Running docker compose up -d for sonarr on pi...",
+		"Container media-sonarr-1 Started",
+	}
+	if got := withoutLockEvents(events); !reflect.DeepEqual(got, wantEvents) {
+		t.Errorf("events = %q\nwant %q", got, wantEvents)
+	}
+}
+
+// withoutLockEvents drops the project lock's events, which aren't what
+// these tests are about.
+func withoutLockEvents(events []string) []string {
+	var kept []string
+	for _, event := range events {
+		if !strings.Contains(event, "lock") {
+			kept = append(kept, event)
+		}
+	}
+	return kept
+}
+
+// TestPlanRemoteComposeRestart_Output tests how the output of remote
+// compose commands is reported: a failing down only reports it, and a
+// failing up fails the restart with it.
+func TestPlanRemoteComposeRestart_Output(t *testing.T) {
+	ssh := &fakeSSH{answer: func(command string) (string, string, error) {
+		switch {
+		case command == inspectWorkingDir:
+			return "", "Error: No such object: media-sonarr-1\n", errors.New("exit status 1")
+		case strings.HasPrefix(command, "for d in"):
+			return "/srv/media\n", "", nil
+		case strings.Contains(command, " down "):
+			return "", "no such service: sonarr\n", errors.New("exit status 1")
+		default:
+			return "", "port is already allocated\n", errors.New("exit status 1")
+		}
+	}}
+	useSSH(t, ssh)
+	host := remoteComposeConfig().GetHostByName("pi")
+	req := ServiceActionRequest{ContainerName: "media-sonarr-1", ServiceName: "sonarr", Source: "docker", Host: "pi", Project: "Media", Profile: "debug"}
+
+	plan, err := planRemoteComposeRestart(context.Background(), host, req, discardEvents)
+	if err != nil {
+		t.Fatalf("planRemoteComposeRestart() error = %v", err)
+	}
+	var events []string
+	sendEvent := func(eventType, message string) { events = append(events, message) }
+	if err := plan.steps[0].run(context.Background(), sendEvent); err != nil {
+		t.Errorf("down error = %v, want its failure only reported", err)
+	}
+	if want := "docker compose down output: no such service: sonarr"; events[len(events)-1] != want {
+		t.Errorf("down events = %q, want %q last", events, want)
+	}
+	err = plan.steps[1].run(context.Background(), sendEvent)
+	if err == nil || !strings.Contains(err.Error(), "docker compose up failed: port is already allocated") {
+		t.Errorf("up error = %v", err)
+	}
+	if last := ssh.commands[len(ssh.commands)-1]; last != "admin@192.168.1.20: cd /srv/media && docker compose --profile debug up -d sonarr" {
+		t.Errorf("up command = %q", last)
+	}
+}
+
+// TestPlanRemoteComposeRestart_NotFound tests that a remote service whose
+// project isn't found is restarted with docker restart, unless a profile is
+// asked for.
+func TestPlanRemoteComposeRestart_NotFound(t *testing.T) {
+	ssh := &fakeSSH{answer: func(command string) (string, string, error) {
+		if strings.HasPrefix(command, "docker restart") {
+			return "", "", nil
+		}
+		return "", "", errors.New("exit status 1")
+	}}
+	useSSH(t, ssh)
+	host := remoteComposeConfig().GetHostByName("pi")
+	req := ServiceActionRequest{ContainerName: "my app", ServiceName: "app", Source: "docker", Host: "pi", Project: "misc"}
+
+	plan, err := planRemoteComposeRestart(context.Background(), host, req, discardEvents)
+	if err != nil {
+		t.Fatalf("planRemoteComposeRestart() error = %v", err)
+	}
+	if got, want := stepDescriptions(plan), []string{"docker restart my app (on pi)"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("steps = %q, want %q", got, want)
+	}
+	if err := plan.steps[0].run(context.Background(), discardEvents); err != nil {
+		t.Fatalf("restart error = %v", err)
+	}
+	if last := ssh.commands[len(ssh.commands)-1]; last != "admin@192.168.1.20: docker restart 'my app'" {
+		t.Errorf("restart command = %q", last)
+	}
+
+	req.Profile = "debug"
+	if _, err := planRemoteComposeRestart(context.Background(), host, req, discardEvents); err == nil {
+		t.Error("planRemoteComposeRestart() with a profile succeeded without a project")
+	}
+}
+
+func TestRemoteComposeCommand(t *testing.T) {
+	got := remoteComposeCommand(context.Background(), "/srv/my stacks/media", []string{"--profile", "debug", "up", "-d", "sonarr"})
+	want := fmt.Sprintf("cd %s && docker compose --profile debug up -d sonarr", `'/srv/my stacks/media'`)
+	if got != want {
+		t.Errorf("remoteComposeCommand() = %q, want %q", got, want)
+	}
+}
+
+// TestRemoteComposeStep_Deadline tests that remote compose commands and
+// docker restarts are killed on the host when the action's deadline
+// passes, not only the local ssh.
+func TestRemoteComposeStep_Deadline(t *testing.T) {
+	ssh := &fakeSSH{answer: func(command string) (string, string, error) { return "", "", nil }}
+	useSSH(t, ssh)
+	host := remoteComposeConfig().GetHostByName("pi")
+	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	defer cancel()
+
+	step := remoteComposeStep(host, "/srv/media", "up", false, "up", "-d", "sonarr")
+	if err := step.run(ctx, discardEvents); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ssh.commands[0], "admin@192.168.1.20: cd /srv/media && timeout -s KILL 90 docker compose up -d sonarr"; got != want {
+		t.Errorf("command = %q, want %q", got, want)
+	}
+
+	ssh.commands = nil
+	req := ServiceActionRequest{ContainerName: "media-sonarr-1", ServiceName: "sonarr", Source: "docker", Host: "pi", Project: "misc"}
+	ssh.answer = func(command string) (string, string, error) {
+		if strings.Contains(command, "docker restart") {
+			return "", "", nil
+		}
+		return "", "", errors.New("exit status 1")
+	}
+	plan, err := planRemoteComposeRestart(ctx, host, req, discardEvents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plan.steps[0].run(ctx, discardEvents); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ssh.commands[len(ssh.commands)-1], "admin@192.168.1.20: timeout -s KILL 90 docker restart media-sonarr-1"; got != want {
+		t.Errorf("command = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// allowedElements are the elements rendered markdown may contain, with the
+// attributes each may keep. Attributes in allowedGlobalAttrs are kept on all
+// of them. Everything else is dropped, keeping the text inside.
+var allowedElements = map[string][]string{
+	"a":          {"href", "title", "target"},
+	"img":        {"src", "alt", "title"},
+	"p":          nil,
+	"br":         nil,
+	"hr":         nil,
+	"h1":         nil,
+	"h2":         nil,
+	"h3":         nil,
+	"h4":         nil,
+	"h5":         nil,
+	"h6":         nil,
+	"strong":     nil,
+	"em":         nil,
+	"b":          nil,
+	"i":          nil,
+	"del":        nil,
+	"s":          nil,
+	"sup":        nil,
+	"sub":        nil,
+	"code":       nil,
+	"pre":        nil,
+	"kbd":        nil,
+	"blockquote": nil,
+	"ul":         nil,
+	"ol":         {"start"},
+	"li":         nil,
+	"dl":         nil,
+	"dt":         nil,
+	"dd":         nil,
+	"table":      nil,
+	"caption":    nil,
+	"thead":      nil,
+	"tbody":      nil,
+	"tfoot":      nil,
+	"tr":         nil,
+	"th":         {"align", "colspan", "rowspan"},
+	"td":         {"align", "colspan", "rowspan"},
+	"div":        nil,
+	"span":       nil,
+}
+
+// allowedGlobalAttrs are kept on every allowed element: id for heading
+// anchors and class for the language of code blocks.
+var allowedGlobalAttrs = []string{"id", "class"}
+
+// droppedWithContent are elements whose content is dropped along with them,
+// as it isn't text meant to be shown. They must not be void elements, whose
+// content would run to the end of the document.
+var droppedWithContent = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"iframe":   true,
+	"object":   true,
+	"noscript": true,
+	"template": true,
+	"textarea": true,
+	"title":    true,
+	"svg":      true,
+	"math":     true,
+}
+
+// safeURL reports whether a link or image URL may be kept: a relative URL,
+// a fragment, or one with an http, https or mailto scheme.
+func safeURL(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+// sanitizeAttrs returns the attributes of an allowed element that may be
+// kept. Links opening a new tab get rel="noopener noreferrer".
+func sanitizeAttrs(tag string, attrs []html.Attribute) []html.Attribute {
+	var kept []html.Attribute
+	newTab := false
+	for _, attr := range attrs {
+		if attr.Namespace != "" {
+			continue
+		}
+		name := attr.Key
+		if !slices.Contains(allowedGlobalAttrs, name) && !slices.Contains(allowedElements[tag], name) {
+			continue
+		}
+		switch name {
+		case "href", "src":
+			if !safeURL(attr.Val) {
+				continue
+			}
+		case "target":
+			if attr.Val != "_blank" {
+				continue
+			}
+			newTab = true
+		}
+		kept = append(kept, html.Attribute{Key: name, Val: attr.Val})
+	}
+	if newTab {
+		kept = append(kept, html.Attribute{Key: "rel", Val: "noopener noreferrer"})
+	}
+	return kept
+}
+
+// sanitizeHTML strips from rendered markdown everything that could run
+// script in the page showing it: elements other than those markdown
+// produces, event handler and style attributes, and links to javascript:
+// and other non-web URLs. Text is kept, and comments are dropped. Any
+// markdown the dashboard shows as HTML goes through it, as markdown passes
+// raw HTML through.
+func sanitizeHTML(in []byte) []byte {
+	var out bytes.Buffer
+	z := html.NewTokenizer(bytes.NewReader(in))
+	skip := "" // Element whose content is being dropped
+	depth := 0 // Nesting of skip inside itself
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			// io.EOF, as reading from memory doesn't fail
+			return out.Bytes()
+		}
+		token := z.Token()
+		if skip != "" {
+			switch {
+			case tt == html.StartTagToken && token.Data == skip:
+				depth++
+			case tt == html.EndTagToken && token.Data == skip:
+				if depth == 0 {
+					skip = ""
+				} else {
+					depth--
+				}
+			}
+			continue
+		}
+
+		switch tt {
+		case html.TextToken:
+			out.WriteString(html.EscapeString(token.Data))
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if droppedWithContent[token.Data] {
+				if tt == html.StartTagToken {
+					skip, depth = token.Data, 0
+				}
+				continue
+			}
+			if _, ok := allowedElements[token.Data]; !ok {
+				continue
+			}
+			token.Attr = sanitizeAttrs(token.Data, token.Attr)
+			out.WriteString(token.String())
+		case html.EndTagToken:
+			if _, ok := allowedElements[token.Data]; ok {
+				out.WriteString(token.String())
+			}
+		}
+		// Comments and doctypes are dropped
+	}
+}
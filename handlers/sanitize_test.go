@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown_Sanitized(t *testing.T) {
+	content := strings.Join([]string{
+		"# Query Language",
+		"",
+		"<script>alert('doc')</script>",
+		"",
+		"<img src=x onerror=alert(1)>",
+		"",
+		`<p onclick="alert(1)" style="color:red">styled</p>`,
+		"",
+		"[bad](javascript:alert(1)) [encoded](&#106;avascript:alert(1)) [data](data:text/html,x)",
+		"",
+		`<a href="JaVaScRiPt:alert(1)">mixed case</a> <a href=" javascript:alert(1)">spaced</a>`,
+		"",
+		"<iframe src=https://evil.example.com>fallback</iframe><!-- hidden --><svg><script>alert(2)</script></svg>",
+		"",
+		"<embed src=x.swf>after embed",
+	}, "\n")
+	got := string(renderMarkdown([]byte(content)))
+
+	for _, bad := range []string{"<script", "alert(", "onerror", "onclick", "style=", "javascript", "data:", "<iframe", "<svg", "<embed", "hidden"} {
+		if strings.Contains(strings.ToLower(got), strings.ToLower(bad)) {
+			t.Errorf("rendered markdown contains %q:\n%s", bad, got)
+		}
+	}
+	for _, want := range []string{`<h1 id="query-language">Query Language</h1>`, "<p>styled</p>", "mixed case", "after embed", `<img src="x">`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rendered markdown lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestRenderMarkdown_KeepsFormatting(t *testing.T) {
+	content := strings.Join([]string{
+		"## Pipes & Bangs",
+		"",
+		"| Operator | Meaning |",
+		"|:---------|--------:|",
+		"| `!`      | not     |",
+		"",
+		"```go",
+		`if a < b && c > "d" {`,
+		"}",
+		"```",
+		"",
+		"See [the docs](https://example.com/docs), [below](#pipes-bangs) or [a doc](/api/docs/other). ~~Old~~ **bold** *em*",
+		"",
+		"> quoted",
+		"",
+		"3. three",
+		"- item",
+	}, "\n")
+	got := string(renderMarkdown([]byte(content)))
+
+	for _, want := range []string{
+		`<h2 id="pipes-bangs">Pipes &amp; Bangs</h2>`,
+		`<th align="left">Operator</th>`,
+		`<td align="right">not</td>`,
+		"<td align=\"left\"><code>!</code></td>",
+		`<pre><code class="language-go">if a &lt; b &amp;&amp; c &gt; &#34;d&#34; {`,
+		`<a href="https://example.com/docs" target="_blank" rel="noopener noreferrer">the docs</a>`,
+		`<a href="#pipes-bangs"`,
+		`<a href="/api/docs/other"`,
+		"<del>Old</del> <strong>bold</strong> <em>em</em>",
+		"<blockquote>\n<p>quoted</p>\n</blockquote>",
+		"<ol>\n<li>three</li>",
+		"<li>item</li>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rendered markdown lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	tests := map[string]bool{
+		"https://example.com":       true,
+		"http://example.com":        true,
+		"mailto:admin@example.com":  true,
+		"/api/docs/other":           true,
+		"#section":                  true,
+		"other.md":                  true,
+		"javascript:alert(1)":       false,
+		"JAVASCRIPT:alert(1)":       false,
+		"java\tscript:alert(1)":     false,
+		"vbscript:msgbox(1)":        false,
+		"data:text/html;base64,PHN": false,
+	}
+	for raw, want := range tests {
+		if got := safeURL(raw); got != want {
+			t.Errorf("safeURL(%q) = %v, want %v", raw, got, want)
+		}
+	}
+}
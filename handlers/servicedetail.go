@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/services"
+)
+
+// pathService returns the service named by the host and name of the request
+// path, from the source in its source query parameter (defaultSource if
+// none), and a function closing its provider. The host must be configured,
+// or be the local host. If the path is invalid or the service can't be
+// found, it writes the error and returns false.
+func pathService(w http.ResponseWriter, r *http.Request, defaultSource string) (services.Service, services.Registration, func(), bool) {
+	host, name := r.PathValue("host"), r.PathValue("name")
+	cfg := configSource()
+	if err := validateHost(cfg, host); err != nil {
+		writeRequestError(w, err)
+		return nil, services.Registration{}, nil, false
+	}
+	if err := validateName("name", name, true); err != nil {
+		writeRequestError(w, err)
+		return nil, services.Registration{}, nil, false
+	}
+	source := r.URL.Query().Get("source")
+	if source == "" {
+		source = defaultSource
+	}
+	reg, ok := sourceRegistry.Lookup(source)
+	if !ok {
+		http.Error(w, "Unknown service source: "+source, http.StatusBadRequest)
+		return nil, reg, nil, false
+	}
+	hostConfig := hostOrLocal(cfg, host)
+	if reg.Configured != nil && !reg.Configured(hostConfig) {
+		http.Error(w, fmt.Sprintf("%s is not configured on host %s", reg.Source, host), http.StatusNotFound)
+		return nil, reg, nil, false
+	}
+	provider, closeProvider, err := newProvider(cfg, reg, hostConfig)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, reg, nil, false
+	}
+	if provider == nil {
+		closeProvider()
+		http.Error(w, fmt.Sprintf("%s is not configured on host %s", reg.Source, host), http.StatusNotFound)
+		return nil, reg, nil, false
+	}
+	svc, err := provider.GetService(name)
+	if err != nil {
+		closeProvider()
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, reg, nil, false
+	}
+	return svc, reg, closeProvider, true
+}
+
+// configComparer is implemented by services that can compare the config
+// their compose file declares with the config they run with, such as Docker
+// containers.
+type configComparer interface {
+	CompareConfig(ctx context.Context) (*services.ConfigComparison, error)
+}
+
+// ServiceConfigHandler handles GET /api/services/{host}/{name}/config
+// requests. Returns the ports, restart policy and profiles the service's
+// compose file declares next to the ports and restart policy its container
+// runs with, and where they differ. The source query parameter picks the
+// provider (default "docker"), and name is its container name. Access is
+// checked against the service the container runs, and hidden services are
+// only shown to admins.
+func ServiceConfigHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	svc, reg, closeProvider, ok := pathService(w, r, "docker")
+	if !ok {
+		return
+	}
+	defer closeProvider()
+
+	ctx, cancel := context.WithTimeout(r.Context(), configSource().GetServicesTimeout())
+	defer cancel()
+	info, err := svc.GetInfo(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to inspect service: %v", err), http.StatusBadGateway)
+		return
+	}
+	if user != nil && !user.CanAccessService(info.Host, info.Name) {
+		http.Error(w, "Access denied: you do not have permission to view this service", http.StatusForbidden)
+		return
+	}
+	if info.Hidden && !canSeeHidden(user) {
+		http.Error(w, "Access denied: you do not have permission to view this service", http.StatusForbidden)
+		return
+	}
+
+	comparer, ok := svc.(configComparer)
+	if !ok {
+		http.Error(w, "Compose config is not available for "+reg.Source+" services", http.StatusNotFound)
+		return
+	}
+	comparison, err := comparer.CompareConfig(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to compare config: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comparison)
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/services"
+)
+
+func (s *fakeService) CompareConfig(ctx context.Context) (*services.ConfigComparison, error) {
+	return &services.ConfigComparison{
+		Intended:      &services.IntendedConfig{Ports: []string{"8080:80/tcp"}, RestartPolicy: "always"},
+		Actual:        services.RuntimeConfig{Ports: []string{"8080:80/tcp"}, RestartPolicy: "no"},
+		Discrepancies: []string{"restart policy is always in the compose file but no on the container"},
+	}, nil
+}
+
+func TestServiceConfigHandler(t *testing.T) {
+	registerFakeProvider(t, services.Capabilities{})
+	setupTestConfig(t, `{"hosts": [{"name": "fakehost", "address": "localhost"}]}`)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/services/{host}/{name}/config", ServiceConfigHandler)
+	get := func(path string, user *auth.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if user != nil {
+			req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, user))
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/api/services/fakehost/nginx/config?source=fake", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d: %s", w.Code, w.Body.String())
+	}
+	want := `{"intended":{"ports":["8080:80/tcp"],"restart_policy":"always"},"actual":{"ports":["8080:80/tcp"],"restart_policy":"no"},"discrepancies":["restart policy is always in the compose file but no on the container"]}`
+	if got := strings.TrimSpace(w.Body.String()); got != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+
+	if w := get("/api/services/otherhost/nginx/config?source=fake", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown host: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := get("/api/services/fakehost/ngi%20nx/config?source=fake", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid name: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := get("/api/services/fakehost/nginx/config?source=nope", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown source: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	user := &auth.User{ID: "user", AllowedServices: map[string][]string{"fakehost": {"redis"}}}
+	if w := get("/api/services/fakehost/nginx/config?source=fake", user); w.Code != http.StatusForbidden {
+		t.Errorf("service the user can't see: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	// Access follows the compose service the container runs, not its name
+	user = &auth.User{ID: "user", AllowedServices: map[string][]string{"fakehost": {"nginx", "infra"}}}
+	if w := get("/api/services/fakehost/stack-nginx-1/config?source=fake", user); w.Code != http.StatusOK {
+		t.Errorf("container of a service the user can see: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	user = &auth.User{ID: "user", AllowedServices: map[string][]string{"fakehost": {"stack-nginx-1"}}}
+	if w := get("/api/services/fakehost/stack-nginx-1/config?source=fake", user); w.Code != http.StatusForbidden {
+		t.Errorf("container of a service the user can't see: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	// Hidden services are only shown to admins
+	user = &auth.User{ID: "user", AllowedServices: map[string][]string{"fakehost": {"infra"}}}
+	if w := get("/api/services/fakehost/infra/config?source=fake", user); w.Code != http.StatusForbidden {
+		t.Errorf("hidden service for a non-admin: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := get("/api/services/fakehost/infra/config?source=fake", &auth.User{ID: "admin", IsAdmin: true, HasGlobalAccess: true}); w.Code != http.StatusOK {
+		t.Errorf("hidden service for an admin: status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
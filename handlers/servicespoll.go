@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/config"
+	"home_server_dashboard/events"
+	"home_server_dashboard/services"
+)
+
+// servicesMaxAge is how long collected services are served to
+// GET /api/services and its long-pollers before they are collected again. Changes the monitor reports invalidate
+// them sooner; this catches the ones it doesn't, such as new ports.
+const servicesMaxAge = 30 * time.Second
+
+// servicesCoalesceWindow is how long the cache waits after an event before
+// refreshing the services, so a burst of events, such as the containers of a
+// rebooting host stopping and starting, causes one refresh rather than one
+// collection each.
+const servicesCoalesceWindow = 2 * time.Second
+
+// servicesSnapshot is one collection of the services.
+type servicesSnapshot struct {
+	services  []services.ServiceInfo
+	collected time.Time
+	cfg       *config.Config // the configuration the services were collected with
+	timing    []timingPhase  // the phases of the collection, for Server-Timing
+}
+
+// newServicesSnapshot copies svcList into a snapshot.
+func newServicesSnapshot(svcList []services.ServiceInfo, now time.Time) *servicesSnapshot {
+	return &servicesSnapshot{
+		services:  slices.Clone(svcList),
+		collected: now,
+	}
+}
+
+// servicesETag returns the ETag of body, the services encoded for one user
+// and query. It is a hash of what that user is sent, so unchanged responses
+// keep their ETag, and changes to services the user can't see, or to
+// another grouping, don't change it.
+func servicesETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// snapshotCache holds the last services collected, so concurrent
+// long-pollers share one collection instead of each starting their own.
+// An invalidation drops it and wakes the pollers waiting for a change.
+// Events are coalesced instead: the ones within the window of the first
+// cause one refresh in the background, and the snapshot is served
+// meanwhile, until the refresh replaces it and wakes the pollers.
+type snapshotCache struct {
+	mu         sync.Mutex
+	generation uint64            // bumped by every invalidation
+	snapshot   *servicesSnapshot // collected in this generation or refreshed in it, nil if none
+	latest     *servicesSnapshot // the last collection, kept across invalidations
+	collecting chan struct{}     // closed when the running collection ends, nil if none runs
+	changed    chan struct{}     // closed by the next invalidation or refresh
+	pending    *time.Timer       // runs the coalesced refresh, nil if none is due
+
+	invalidations uint64 // events received
+	refreshes     uint64 // coalesced refreshes started
+
+	maxAge  time.Duration
+	window  time.Duration
+	now     func() time.Time
+	collect func(ctx context.Context, cfg *config.Config) ([]services.ServiceInfo, error)
+}
+
+func newSnapshotCache() *snapshotCache {
+	return &snapshotCache{
+		changed: make(chan struct{}),
+		maxAge:  servicesMaxAge,
+		window:  servicesCoalesceWindow,
+		now:     time.Now,
+		collect: getAllServices,
+	}
+}
+
+// servicesCache is the snapshot shared by GET /api/services and its long-poll.
+var servicesCache = newSnapshotCache()
+
+// servicesEventSubs are the subscriptions SetEventBus made, and eventBus
+// the bus it was given, which project log streams subscribe to for the
+// containers that start while they run.
+var (
+	servicesEventMu   sync.Mutex
+	servicesEventSubs []*events.Subscription
+	eventBus          *events.Bus
+)
+
+// SetEventBus makes the service and host events, and Docker resource
+// changes, published on bus refresh the collected services, releasing the
+// long-polls waiting for them to change. Events are coalesced (see
+// snapshotCache). Nil stops listening.
+func SetEventBus(bus *events.Bus) {
+	servicesEventMu.Lock()
+	defer servicesEventMu.Unlock()
+
+	for _, sub := range servicesEventSubs {
+		sub.Unsubscribe()
+	}
+	servicesEventSubs = nil
+	eventBus = bus
+	if bus == nil {
+		return
+	}
+	invalidate := func(events.Event) { servicesCache.invalidateSoon() }
+	servicesEventSubs = append(bus.SubscribeAll(invalidate), bus.Subscribe(events.DockerResourceChanged, invalidate))
+}
+
+// InvalidateServices marks the collected services stale and releases the
+// long-polls waiting for them to change.
+func InvalidateServices() {
+	servicesCache.invalidate()
+}
+
+func (c *snapshotCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invalidateLocked()
+}
+
+// invalidateLocked drops the snapshot. Called with c.mu held.
+func (c *snapshotCache) invalidateLocked() {
+	c.generation++
+	c.snapshot = nil
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// invalidateSoon counts an event that may have changed the services, and
+// schedules a refresh at the end of the window unless one is due already.
+func (c *snapshotCache) invalidateSoon() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invalidations++
+	if c.pending == nil {
+		c.pending = time.AfterFunc(c.window, c.refresh)
+	}
+}
+
+// refresh collects the services in the background, serving the snapshot
+// meanwhile, and replaces it with the new collection, waking the pollers.
+// While another collection runs, which may have started before the events,
+// the refresh waits for another window. Without a snapshot to serve, the
+// cache is invalidated as before.
+func (c *snapshotCache) refresh() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = nil
+	if c.collecting != nil {
+		c.pending = time.AfterFunc(c.window, c.refresh)
+		return
+	}
+	if c.snapshot == nil {
+		c.invalidateLocked()
+		return
+	}
+
+	c.refreshes++
+	c.generation++
+	done := make(chan struct{})
+	c.collecting = done
+	generation, cfg := c.generation, c.snapshot.cfg
+	go func() {
+		timing := newCollectionTiming()
+		svcList, err := c.collect(withCollectionTiming(context.Background(), timing), cfg)
+		if err == nil {
+			c.store(generation, cfg, timing, svcList)
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.collecting = nil
+		close(done)
+		if generation != c.generation {
+			return // Invalidated meanwhile, which woke the pollers
+		}
+		if err != nil {
+			// The pollers collect themselves, and report the error
+			log.Printf("Warning: refreshing the services failed: %v", err)
+			c.snapshot = nil
+		}
+		close(c.changed)
+		c.changed = make(chan struct{})
+	}()
+}
+
+// counters returns the events received and the refreshes they caused.
+func (c *snapshotCache) counters() (invalidations, refreshes uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.invalidations, c.refreshes
+}
+
+// peek returns the current snapshot, however old, or nil if there is none.
+// It never collects.
+func (c *snapshotCache) peek() *servicesSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.snapshot
+}
+
+// current returns the generation to store a collection started now under.
+func (c *snapshotCache) current() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// store keeps svcList, collected with cfg in generation and timed by
+// timing, as the snapshot unless it was invalidated since, and returns its
+// snapshot.
+func (c *snapshotCache) store(generation uint64, cfg *config.Config, timing *collectionTiming, svcList []services.ServiceInfo) *servicesSnapshot {
+	snapshot := newServicesSnapshot(svcList, c.now())
+	snapshot.cfg = cfg
+	if timing != nil {
+		snapshot.timing = slices.Clone(timing.phases)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation == c.generation {
+		c.snapshot = snapshot
+	}
+	c.latest = snapshot
+	return snapshot
+}
+
+// last returns the last collection, even if invalidated since, or nil if
+// none was made yet. It never collects.
+func (c *snapshotCache) last() *servicesSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.latest
+}
+
+// fresh reports whether the current snapshot was collected with cfg and is
+// young enough to serve. Called with c.mu held.
+func (c *snapshotCache) fresh(cfg *config.Config) bool {
+	return c.snapshot != nil && c.snapshot.cfg == cfg && c.now().Sub(c.snapshot.collected) < c.maxAge
+}
+
+// get returns the current snapshot, collecting the services if there is
+// none, it is too old or the configuration was reloaded since, and a channel
+// closed once it is invalidated. Only one collection runs at a time; other
+// callers wait for it and share its result. A collection is timed into the
+// timing ctx carries, or into one of its own, so the snapshot has the
+// phases of the collection that made it.
+func (c *snapshotCache) get(ctx context.Context, cfg *config.Config) (*servicesSnapshot, <-chan struct{}, error) {
+	for {
+		c.mu.Lock()
+		if c.fresh(cfg) {
+			snapshot, changed := c.snapshot, c.changed
+			c.mu.Unlock()
+			return snapshot, changed, nil
+		}
+		if running := c.collecting; running != nil {
+			c.mu.Unlock()
+			select {
+			case <-running:
+				continue
+			case <-ctx.Done():
+				return nil, nil, ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		c.collecting = done
+		generation, changed := c.generation, c.changed
+		c.mu.Unlock()
+
+		timing := timingFrom(ctx)
+		if timing == nil {
+			timing = newCollectionTiming()
+			ctx = withCollectionTiming(ctx, timing)
+		}
+		// The other pollers wait on this collection, so it isn't cut short
+		// when this one goes away; each provider call has its own deadline
+		svcList, err := c.collect(context.WithoutCancel(ctx), cfg)
+
+		c.mu.Lock()
+		c.collecting = nil
+		close(done)
+		c.mu.Unlock()
+		if err != nil {
+			return nil, nil, err
+		}
+		// An invalidation during the collection has closed changed already,
+		// so the caller collects again rather than wait on stale services
+		return c.store(generation, cfg, timing, svcList), changed, nil
+	}
+}
+
+// ServicesPollHandler handles GET /api/services/poll?etag=<etag> requests,
+// a long-poll for clients that can't use SSE or WebSockets. It returns the
+// services as GET /api/services does, with their ETag, once what the user
+// is sent for the query differs from the response etag names, waiting up to
+// services_poll_timeout for it to change. If it doesn't, it returns 304 Not
+// Modified. Without etag it returns the services right away.
+func ServicesPollHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := configSource()
+	if cfg == nil {
+		http.Error(w, "Configuration not loaded", http.StatusInternalServerError)
+		return
+	}
+
+	user := auth.GetUserFromContext(r.Context())
+	query, ok := parseServicesQuery(w, r, user)
+	if !ok {
+		return
+	}
+	etag := r.URL.Query().Get("etag")
+	if etag == "" {
+		etag = r.Header.Get("If-None-Match")
+	}
+	etag = strings.Trim(etag, `"`)
+
+	deadline := time.NewTimer(cfg.GetServicesPollTimeout())
+	defer deadline.Stop()
+
+	for {
+		snapshot, changed, err := servicesCache.get(r.Context(), cfg)
+		if err != nil {
+			if r.Context().Err() == nil {
+				http.Error(w, fmt.Sprintf("Error getting services: %v", err), http.StatusInternalServerError)
+			}
+			return
+		}
+		// Only a change to what this user is sent ends the wait
+		body := encodeServices(cfg, slices.Clone(snapshot.services), user, query)
+		current := servicesETag(body)
+		if strings.Trim(current, `"`) != etag {
+			w.Header().Set("ETag", current)
+			w.Header().Set("Cache-Control", "no-cache")
+			writeServicesBody(w, body, user, query)
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			w.Header().Set("ETag", current)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// currentEventBus returns the bus SetEventBus was given, or nil.
+func currentEventBus() *events.Bus {
+	servicesEventMu.Lock()
+	defer servicesEventMu.Unlock()
+	return eventBus
+}
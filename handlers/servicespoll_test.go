@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/config"
+	"home_server_dashboard/events"
+	"home_server_dashboard/services"
+)
+
+// fakeCollection is what a test's services cache collects.
+type fakeCollection struct {
+	mu       sync.Mutex
+	services []services.ServiceInfo
+	calls    atomic.Int32
+}
+
+func (f *fakeCollection) collect(ctx context.Context, cfg *config.Config) ([]services.ServiceInfo, error) {
+	f.calls.Add(1)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]services.ServiceInfo(nil), f.services...), nil
+}
+
+func (f *fakeCollection) setState(state services.State) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.services[0].State = state
+}
+
+// withFakeServices replaces the services cache and config for a test; the
+// long-poll gives up after a second.
+func withFakeServices(t *testing.T) *fakeCollection {
+	t.Helper()
+	fake := &fakeCollection{services: []services.ServiceInfo{
+		{Name: "jellyfin", Host: "nas", Source: "docker", State: services.StateRunning},
+	}}
+	origCache, origConfig := servicesCache, configSource
+	servicesCache = newSnapshotCache()
+	servicesCache.collect = fake.collect
+	cfg := &config.Config{ServicesPollTimeout: 1}
+	SetConfigSource(func() *config.Config { return cfg })
+	t.Cleanup(func() {
+		servicesCache = origCache
+		configSource = origConfig
+	})
+	return fake
+}
+
+func pollServices(etag string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/services/poll?etag="+etag, nil)
+	w := httptest.NewRecorder()
+	ServicesPollHandler(w, req)
+	return w
+}
+
+func TestServicesPollHandler_NoETagReturnsServices(t *testing.T) {
+	fake := withFakeServices(t)
+
+	w := pollServices("")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("ETag") == "" {
+		t.Error("response has no ETag")
+	}
+	// A second poll for whatever is current is served from the snapshot
+	if again := pollServices(""); again.Header().Get("ETag") != w.Header().Get("ETag") {
+		t.Errorf("ETag changed from %s to %s without a change", w.Header().Get("ETag"), again.Header().Get("ETag"))
+	}
+	if n := fake.calls.Load(); n != 1 {
+		t.Errorf("services collected %d times, want once", n)
+	}
+}
+
+func TestServicesPollHandler_ChangeReleasesAllPollers(t *testing.T) {
+	fake := withFakeServices(t)
+	etag := pollServices("").Header().Get("ETag")
+
+	results := make([]*httptest.ResponseRecorder, 2)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = pollServices(etag)
+		}(i)
+	}
+
+	// Give both pollers time to start waiting, then change the services
+	time.Sleep(100 * time.Millisecond)
+	fake.setState(services.StateStopped)
+	InvalidateServices()
+	wg.Wait()
+
+	for i, w := range results {
+		if w.Code != http.StatusOK {
+			t.Fatalf("poller %d: status = %d, want 200", i, w.Code)
+		}
+		if got := w.Header().Get("ETag"); got == etag || got == "" {
+			t.Errorf("poller %d: ETag = %q, want a new one", i, got)
+		}
+	}
+	if results[0].Body.String() != results[1].Body.String() {
+		t.Errorf("pollers got different bodies:\n%s\n%s", results[0].Body, results[1].Body)
+	}
+	if results[0].Header().Get("ETag") != results[1].Header().Get("ETag") {
+		t.Errorf("pollers got different ETags")
+	}
+	// The initial collection and the one after the change, shared by both
+	if n := fake.calls.Load(); n != 2 {
+		t.Errorf("services collected %d times, want 2", n)
+	}
+}
+
+// TestServicesPollHandler_ScopedUser tests that the ETag is of what the
+// user is sent: a change to a service the user can't see doesn't end the
+// poll, and another grouping has another ETag.
+func TestServicesPollHandler_ScopedUser(t *testing.T) {
+	fake := withFakeServices(t)
+	fake.services = append(fake.services, services.ServiceInfo{Name: "vault", Host: "nas", Source: "docker", State: services.StateRunning})
+	user := &auth.User{AllowedServices: map[string][]string{"nas": {"jellyfin"}}}
+	poll := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/services/poll?"+query, nil)
+		req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, user))
+		w := httptest.NewRecorder()
+		ServicesPollHandler(w, req)
+		return w
+	}
+
+	first := poll("")
+	etag := first.Header().Get("ETag")
+	if strings.Contains(first.Body.String(), "vault") {
+		t.Fatalf("body has a service out of scope: %s", first.Body)
+	}
+	if grouped := poll("group=host").Header().Get("ETag"); grouped == etag {
+		t.Errorf("ETag grouped by host = %q, the same as the plain list", grouped)
+	}
+
+	// Only vault changes, which the user can't see
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		fake.mu.Lock()
+		fake.services[1].State = services.StateStopped
+		fake.mu.Unlock()
+		InvalidateServices()
+	}()
+	w := poll("etag=" + etag)
+	if w.Code != http.StatusNotModified || w.Header().Get("ETag") != etag {
+		t.Errorf("poll = %d %q, want 304 %q: a change out of scope ended it", w.Code, w.Header().Get("ETag"), etag)
+	}
+}
+
+func TestServicesPollHandler_NotModifiedAtDeadline(t *testing.T) {
+	fake := withFakeServices(t)
+	etag := pollServices("").Header().Get("ETag")
+
+	// An invalidation that changes nothing doesn't end the poll
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		InvalidateServices()
+	}()
+
+	start := time.Now()
+	w := pollServices(etag)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("Status = %d, want 304", w.Code)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("poll returned after %v, before its deadline", elapsed)
+	}
+	if got := w.Header().Get("ETag"); got != etag {
+		t.Errorf("ETag = %q, want %q", got, etag)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("304 has a body: %s", w.Body)
+	}
+	if n := fake.calls.Load(); n != 2 {
+		t.Errorf("services collected %d times, want 2", n)
+	}
+}
+
+func TestServicesPollHandler_UnquotedETag(t *testing.T) {
+	withFakeServices(t)
+	etag := pollServices("").Header().Get("ETag")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/services/poll?etag="+etag[1:len(etag)-1], nil)
+	ctx, cancel := context.WithTimeout(req.Context(), 100*time.Millisecond)
+	defer cancel()
+	w := httptest.NewRecorder()
+	ServicesPollHandler(w, req.WithContext(ctx))
+	if w.Body.Len() != 0 {
+		t.Error("unquoted ETag of the current services didn't wait for a change")
+	}
+}
+
+// waitForCalls waits until fake has collected n times.
+func waitForCalls(t *testing.T, fake *fakeCollection, n int32) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for fake.calls.Load() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("collected %d times, want %d", fake.calls.Load(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSetEventBus_InvalidatesServices(t *testing.T) {
+	fake := withFakeServices(t)
+	servicesCache.window = 10 * time.Millisecond
+	bus := events.NewBus(false)
+	SetEventBus(bus)
+	defer SetEventBus(nil)
+
+	pollServices("")
+	bus.Publish(events.NewServiceStateChangedEvent("nas", "jellyfin", "docker", "running", "stopped", "Exited (0)"))
+	waitForCalls(t, fake, 2)
+
+	// Unsubscribed buses no longer invalidate
+	SetEventBus(nil)
+	bus.Publish(events.NewDockerResourceChangedEvent("nas", events.ResourceImage, "delete", "sha256:abc"))
+	time.Sleep(50 * time.Millisecond)
+	if calls := fake.calls.Load(); calls != 2 {
+		t.Errorf("collected %d times after an event of a replaced bus, want 2", calls)
+	}
+}
+
+// TestSetEventBus_CoalescesBursts tests that a burst of events causes one
+// refresh in the background, with the stale services served meanwhile, and
+// that the pollers are woken by it.
+func TestSetEventBus_CoalescesBursts(t *testing.T) {
+	fake := withFakeServices(t)
+	servicesCache.window = 100 * time.Millisecond
+	bus := events.NewBus(false)
+	SetEventBus(bus)
+	defer SetEventBus(nil)
+
+	etag := pollServices("").Header().Get("ETag")
+	poll := make(chan *httptest.ResponseRecorder, 1)
+	go func() { poll <- pollServices(etag) }()
+
+	// A host rebooting: each container stops, then starts again
+	fake.setState(services.StateStopped)
+	for i := 0; i < 30; i++ {
+		bus.Publish(events.NewServiceStateChangedEvent("nas", "jellyfin", "docker", "running", "stopped", "Exited (0)"))
+		bus.Publish(events.NewServiceStateChangedEvent("nas", "jellyfin", "docker", "stopped", "running", "Up"))
+	}
+
+	// Until the refresh, the stale services are served without collecting
+	w := httptest.NewRecorder()
+	ServicesHandler(w, httptest.NewRequest(http.MethodGet, "/api/services", nil))
+	if got := w.Header().Get("ETag"); got != etag {
+		t.Errorf("ETag during the burst = %q, want the stale %q", got, etag)
+	}
+	if calls := fake.calls.Load(); calls != 1 {
+		t.Errorf("collected %d times during the burst, want once", calls)
+	}
+
+	select {
+	case w := <-poll:
+		if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+			t.Errorf("poll after the refresh = %d %q, want the refreshed services", w.Code, w.Header().Get("ETag"))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the refresh didn't wake the poller")
+	}
+	time.Sleep(3 * servicesCache.window)
+	if calls := fake.calls.Load(); calls != 2 {
+		t.Errorf("collected %d times for the burst, want one refresh", calls)
+	}
+
+	var metrics strings.Builder
+	writeCacheMetrics(metricsWriter{w: &metrics})
+	for _, want := range []string{"dashboard_services_invalidations_total 60\n", "dashboard_services_refreshes_total 1\n"} {
+		if !strings.Contains(metrics.String(), want) {
+			t.Errorf("metrics lack %q:\n%s", want, metrics.String())
+		}
+	}
+}
+
+// TestServicesHandler_ServesSnapshot tests that /api/services shares the
+// snapshot of the long-poll, and collects again once it is invalidated or
+// the configuration is reloaded.
+func TestServicesHandler_ServesSnapshot(t *testing.T) {
+	fake := withFakeServices(t)
+	get := func() {
+		w := httptest.NewRecorder()
+		ServicesHandler(w, httptest.NewRequest(http.MethodGet, "/api/services", nil))
+		if w.Code != http.StatusOK || w.Header().Get("ETag") == "" {
+			t.Fatalf("status %d, ETag %q: %s", w.Code, w.Header().Get("ETag"), w.Body.String())
+		}
+	}
+
+	get()
+	pollServices("")
+	get()
+	if calls := fake.calls.Load(); calls != 1 {
+		t.Errorf("collected %d times, want once for both endpoints", calls)
+	}
+
+	InvalidateServices()
+	get()
+	if calls := fake.calls.Load(); calls != 2 {
+		t.Errorf("collected %d times after an invalidation, want 2", calls)
+	}
+
+	reloaded := &config.Config{ServicesPollTimeout: 1}
+	SetConfigSource(func() *config.Config { return reloaded })
+	get()
+	if calls := fake.calls.Load(); calls != 3 {
+		t.Errorf("collected %d times after a reload, want 3", calls)
+	}
+}
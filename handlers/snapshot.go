@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/services"
+)
+
+// servicesSnapshotEnvelope is the services embedded in the dashboard page
+// for its first paint. Loading is set, with no services, when none were
+// collected yet; the page then waits for /api/services as before. Warming
+// is set while the startup warm-up runs, when the services may be only
+// those of the hosts it has collected so far.
+type servicesSnapshotEnvelope struct {
+	Services    []services.ServiceInfo `json:"services"`
+	ETag        string                 `json:"etag,omitempty"`
+	CollectedAt *time.Time             `json:"collected_at,omitempty"`
+	Loading     bool                   `json:"loading"`
+	Warming     bool                   `json:"warming"`
+}
+
+// servicesSnapshotEnvelopeFor returns the envelope of the last services
+// collected that the user of r may see, without collecting them. Before the
+// first collection is stored, it has the services the warm-up collected so
+// far, if any.
+func servicesSnapshotEnvelopeFor(r *http.Request) servicesSnapshotEnvelope {
+	envelope := servicesSnapshotEnvelope{Services: []services.ServiceInfo{}, Loading: true}
+	warming, partial := currentWarmup().warming()
+	envelope.Warming = warming
+	cfg := configSource()
+	snapshot := servicesCache.last()
+	if cfg == nil || (snapshot == nil && len(partial) == 0) {
+		return envelope
+	}
+
+	svcList := partial
+	if snapshot != nil {
+		svcList = slices.Clone(snapshot.services)
+		envelope.CollectedAt = &snapshot.collected
+	}
+	user := auth.GetUserFromContext(r.Context())
+	query := servicesQuery{requestHost: requestHostName(r)}
+	if svcList = visibleServices(cfg, svcList, user, query); svcList != nil {
+		envelope.Services = svcList
+	}
+	if snapshot != nil {
+		// The ETag /api/services sends for the same list
+		var body bytes.Buffer
+		json.NewEncoder(&body).Encode(svcList)
+		envelope.ETag = servicesETag(body.Bytes())
+	}
+	envelope.Loading = false
+	return envelope
+}
+
+// withServicesSnapshot returns page with the services envelope for r
+// embedded in a JSON script tag, #services-snapshot, ahead of </head>. The
+// JSON encoder escapes <, > and &, so no service field can close the tag.
+func withServicesSnapshot(page []byte, r *http.Request) []byte {
+	i := bytes.Index(page, []byte("</head>"))
+	if i < 0 {
+		return page
+	}
+	data, err := json.Marshal(servicesSnapshotEnvelopeFor(r))
+	if err != nil {
+		return page
+	}
+
+	var b bytes.Buffer
+	b.Grow(len(page) + len(data) + 80)
+	b.Write(page[:i])
+	b.WriteString(`<script id="services-snapshot" type="application/json">`)
+	b.Write(data)
+	b.WriteString("</script>\n")
+	b.Write(page[i:])
+	return b.Bytes()
+}
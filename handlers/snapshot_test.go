@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/services"
+)
+
+// snapshotScript matches the services snapshot embedded in the page.
+var snapshotScript = regexp.MustCompile(`<script id="services-snapshot" type="application/json">(.*?)</script>`)
+
+// getIndex serves the dashboard page to user, returning the page and the
+// snapshot embedded in it.
+func getIndex(t *testing.T, user *auth.User) (string, servicesSnapshotEnvelope) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if user != nil {
+		req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, user))
+	}
+	w := httptest.NewRecorder()
+	IndexHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", cc)
+	}
+
+	page := w.Body.String()
+	match := snapshotScript.FindStringSubmatch(page)
+	if match == nil {
+		t.Fatalf("no services snapshot in page:\n%s", page)
+	}
+	var envelope servicesSnapshotEnvelope
+	if err := json.Unmarshal([]byte(match[1]), &envelope); err != nil {
+		t.Fatalf("snapshot isn't JSON: %v\n%s", err, match[1])
+	}
+	return page, envelope
+}
+
+// withIndexPage serves a minimal dashboard page for a test.
+func withIndexPage(t *testing.T) {
+	original := embeddedStaticFS
+	embeddedStaticFS = fstest.MapFS{
+		"index.html": {Data: []byte("<!DOCTYPE html>\n<html><head><title>Dashboard</title></head><body></body></html>\n")},
+	}
+	t.Cleanup(func() { embeddedStaticFS = original })
+}
+
+func TestIndexHandler_ColdStart(t *testing.T) {
+	withFakeServices(t)
+	withIndexPage(t)
+
+	page, envelope := getIndex(t, nil)
+	if !envelope.Loading || envelope.Services == nil || len(envelope.Services) != 0 {
+		t.Errorf("snapshot = %+v, want an empty loading envelope", envelope)
+	}
+	if !strings.Contains(page, `"services":[]`) {
+		t.Errorf("services aren't an empty list: %s", page)
+	}
+	if !strings.Contains(page, "</script>\n</head>") {
+		t.Errorf("snapshot isn't ahead of </head>: %s", page)
+	}
+}
+
+func TestIndexHandler_SnapshotPerUser(t *testing.T) {
+	fake := withFakeServices(t)
+	withIndexPage(t)
+	fake.services = []services.ServiceInfo{
+		{Name: "jellyfin", Host: "nas", Source: "docker", State: services.StateRunning},
+		{Name: "vaultwarden", Host: "nas", Source: "docker", State: services.StateRunning},
+	}
+	if w := pollServices(""); w.Code != http.StatusOK {
+		t.Fatalf("collecting the services: status %d", w.Code)
+	}
+	// The page shows the last collection even after an invalidation
+	InvalidateServices()
+
+	_, envelope := getIndex(t, nil)
+	if envelope.Loading || len(envelope.Services) != 2 || envelope.ETag == "" || envelope.CollectedAt == nil {
+		t.Errorf("snapshot = %+v, want both services", envelope)
+	}
+
+	guest := &auth.User{Name: "guest", AllowedServices: map[string][]string{"nas": {"jellyfin"}}}
+	_, envelope = getIndex(t, guest)
+	if len(envelope.Services) != 1 || envelope.Services[0].Name != "jellyfin" {
+		t.Errorf("guest's snapshot = %+v, want just jellyfin", envelope.Services)
+	}
+
+	nobody := &auth.User{Name: "nobody"}
+	if _, envelope = getIndex(t, nobody); envelope.Loading || envelope.Services == nil || len(envelope.Services) != 0 {
+		t.Errorf("snapshot of a user without services = %+v, want an empty list", envelope)
+	}
+}
+
+func TestIndexHandler_EscapesSnapshot(t *testing.T) {
+	fake := withFakeServices(t)
+	withIndexPage(t)
+	name := `</script><script>alert("x")</script><!--`
+	fake.services[0].Name = name
+	pollServices("")
+
+	page, envelope := getIndex(t, nil)
+	if strings.Count(page, "</script>") != 1 {
+		t.Errorf("a service name closed the snapshot tag: %s", page)
+	}
+	if strings.Contains(page, "<!--") {
+		t.Errorf("a service name opened a comment: %s", page)
+	}
+	if !strings.Contains(page, `\u003c/script\u003e`) {
+		t.Errorf("page lacks the escaped name: %s", page)
+	}
+	if len(envelope.Services) != 1 || envelope.Services[0].Name != name {
+		t.Errorf("snapshot services = %+v, want the name back intact", envelope.Services)
+	}
+}
+
+func TestWithServicesSnapshot_NoHead(t *testing.T) {
+	withFakeServices(t)
+	page := []byte("<p>no head</p>")
+	if got := withServicesSnapshot(page, httptest.NewRequest(http.MethodGet, "/", nil)); string(got) != string(page) {
+		t.Errorf("page without </head> changed: %s", got)
+	}
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// collectionTiming records how long the phases of a services request took:
+// each source on each host, each Traefik fetch, and the steps after them.
+// Every phase also goes into the phase histograms of /api/metrics. A nil
+// *collectionTiming records only into those, so collections that aren't
+// timed don't pay for a list of phases. It is not safe for concurrent use; the
+// phases of a collection run one after another.
+type collectionTiming struct {
+	start  time.Time
+	phases []timingPhase
+}
+
+// timingPhase is one timed phase, on a host or of the whole request.
+type timingPhase struct {
+	Name     string        `json:"name"`
+	Host     string        `json:"host,omitempty"`
+	Duration time.Duration `json:"-"`
+	MS       float64       `json:"ms"`
+}
+
+// timingReport is the _timing object of a services response.
+type timingReport struct {
+	TotalMS float64       `json:"total_ms"`
+	Phases  []timingPhase `json:"phases"`
+}
+
+func newCollectionTiming() *collectionTiming {
+	return &collectionTiming{start: time.Now()}
+}
+
+type timingContextKey struct{}
+
+// withCollectionTiming returns ctx carrying t, for getAllServices to record
+// its phases in.
+func withCollectionTiming(ctx context.Context, t *collectionTiming) context.Context {
+	return context.WithValue(ctx, timingContextKey{}, t)
+}
+
+// timingFrom returns the timing carried by ctx, or nil if it isn't timed.
+func timingFrom(ctx context.Context) *collectionTiming {
+	t, _ := ctx.Value(timingContextKey{}).(*collectionTiming)
+	return t
+}
+
+// observe records that the phase name, on host if it isn't "", ran from
+// start until now.
+func (t *collectionTiming) observe(name, host string, start time.Time) {
+	d := time.Since(start)
+	phaseHistograms.observe(name, host, d)
+	if t == nil {
+		return
+	}
+	t.phases = append(t.phases, timingPhase{Name: name, Host: host, Duration: d, MS: milliseconds(d)})
+}
+
+// report returns the phases recorded so far and the time since the request
+// started.
+func (t *collectionTiming) report() timingReport {
+	return timingReport{TotalMS: milliseconds(time.Since(t.start)), Phases: t.phases}
+}
+
+// header returns the phases as a Server-Timing header value, ending with
+// the total, e.g. `docker-nas;desc="docker on nas";dur=12.4, total;dur=15.0`.
+// Unless perHost is set, the phases on each host are summed by name, e.g.
+// `docker;desc="docker";dur=20.1`, so the header doesn't name the hosts to
+// users who may not see them all.
+func (t *collectionTiming) header(perHost bool) string {
+	phases := t.phases
+	if !perHost {
+		phases = sumHostPhases(phases)
+	}
+	var b strings.Builder
+	for _, phase := range phases {
+		name, desc := phase.Name, phase.Name
+		if phase.Host != "" {
+			name += "-" + serverTimingToken(phase.Host)
+			desc += " on " + phase.Host
+		}
+		b.WriteString(name)
+		b.WriteString(";desc=")
+		b.WriteString(strconv.Quote(desc))
+		b.WriteString(";dur=")
+		b.WriteString(formatMilliseconds(phase.Duration))
+		b.WriteString(", ")
+	}
+	b.WriteString("total;dur=")
+	b.WriteString(formatMilliseconds(time.Since(t.start)))
+	return b.String()
+}
+
+// sumHostPhases returns phases with those on a host summed by name, in the
+// place of the first of them.
+func sumHostPhases(phases []timingPhase) []timingPhase {
+	summed := make([]timingPhase, 0, len(phases))
+	index := make(map[string]int)
+	for _, phase := range phases {
+		if phase.Host == "" {
+			summed = append(summed, phase)
+			continue
+		}
+		if i, ok := index[phase.Name]; ok {
+			summed[i].Duration += phase.Duration
+			summed[i].MS = milliseconds(summed[i].Duration)
+			continue
+		}
+		index[phase.Name] = len(summed)
+		phase.Host = ""
+		summed = append(summed, phase)
+	}
+	return summed
+}
+
+// serverTimingToken replaces the characters of s a Server-Timing metric
+// name can't hold with underscores.
+func serverTimingToken(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.', r == '_':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// milliseconds returns d in milliseconds, to a tenth.
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()/100) / 10
+}
+
+func formatMilliseconds(d time.Duration) string {
+	return strconv.FormatFloat(milliseconds(d), 'f', 1, 64)
+}
+
+// phaseBuckets are the upper bounds, in seconds, of the phase histograms'
+// buckets.
+var phaseBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// phaseKey names a phase histogram: a phase, on a host if it isn't "".
+type phaseKey struct {
+	Phase string
+	Host  string
+}
+
+// phaseHistogram counts how long a phase took, in phaseBuckets.
+type phaseHistogram struct {
+	buckets []uint64 // Observations in each bucket, not cumulative; the last is past every bound
+	sum     float64  // Seconds
+	count   uint64
+}
+
+// phaseHistogramSet holds the histograms of every phase of the collections
+// since the start, shared by every collection whether it is timed or not.
+type phaseHistogramSet struct {
+	mu         sync.Mutex
+	histograms map[phaseKey]*phaseHistogram
+}
+
+var phaseHistograms = &phaseHistogramSet{}
+
+// observe adds that the phase name, on host if it isn't "", took d.
+func (s *phaseHistogramSet) observe(name, host string, d time.Duration) {
+	key := phaseKey{Phase: name, Host: host}
+	seconds := d.Seconds()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.histograms[key]
+	if h == nil {
+		if s.histograms == nil {
+			s.histograms = make(map[phaseKey]*phaseHistogram)
+		}
+		h = &phaseHistogram{buckets: make([]uint64, len(phaseBuckets)+1)}
+		s.histograms[key] = h
+	}
+	i, _ := slices.BinarySearch(phaseBuckets, seconds)
+	h.buckets[i]++
+	h.sum += seconds
+	h.count++
+}
+
+// snapshot returns a copy of every histogram, sorted by phase then host.
+func (s *phaseHistogramSet) snapshot() ([]phaseKey, []phaseHistogram) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]phaseKey, 0, len(s.histograms))
+	for key := range s.histograms {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b phaseKey) int {
+		if c := strings.Compare(a.Phase, b.Phase); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Host, b.Host)
+	})
+	histograms := make([]phaseHistogram, len(keys))
+	for i, key := range keys {
+		h := s.histograms[key]
+		histograms[i] = phaseHistogram{buckets: slices.Clone(h.buckets), sum: h.sum, count: h.count}
+	}
+	return keys, histograms
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/config"
+	"home_server_dashboard/services"
+)
+
+func TestCollectionTiming_Header(t *testing.T) {
+	timing := &collectionTiming{start: time.Now(), phases: []timingPhase{
+		{Name: "docker", Host: "nas", Duration: 12340 * time.Microsecond},
+		{Name: "systemd", Host: "media box", Duration: 3 * time.Millisecond},
+		{Name: "remap", Duration: 50 * time.Microsecond},
+	}}
+	header := timing.header(true)
+	want := `^docker-nas;desc="docker on nas";dur=12\.3, systemd-media_box;desc="systemd on media box";dur=3\.0, remap;desc="remap";dur=0\.0, total;dur=\d+\.\d$`
+	if !regexp.MustCompile(want).MatchString(header) {
+		t.Errorf("header = %s, want it to match %s", header, want)
+	}
+
+	// Without the hosts, the phases on each are summed by name
+	timing.phases = append(timing.phases, timingPhase{Name: "docker", Host: "pi", Duration: 7 * time.Millisecond})
+	header = timing.header(false)
+	want = `^docker;desc="docker";dur=19\.3, systemd;desc="systemd";dur=3\.0, remap;desc="remap";dur=0\.0, total;dur=\d+\.\d$`
+	if !regexp.MustCompile(want).MatchString(header) {
+		t.Errorf("header without hosts = %s, want it to match %s", header, want)
+	}
+
+	// Untimed collections record nothing
+	var untimed *collectionTiming
+	untimed.observe("docker", "nas", time.Now())
+	if timingFrom(context.Background()) != nil {
+		t.Error("timing found in a context without any")
+	}
+}
+
+// slowProvider is a fakeProvider that takes delay to list its services.
+type slowProvider struct {
+	fakeProvider
+	delay time.Duration
+}
+
+func (p *slowProvider) GetServices(ctx context.Context) ([]services.ServiceInfo, error) {
+	time.Sleep(p.delay)
+	return p.fakeProvider.GetServices(ctx)
+}
+
+// TestServicesHandler_ServerTiming tests that a services request reports how
+// long each host took, in the Server-Timing header and, for admins asking
+// for it, in the body.
+func TestServicesHandler_ServerTiming(t *testing.T) {
+	cleanup := setupTestConfig(t, `{"hosts": [{"name": "fakehost", "address": "192.168.1.50"}, {"name": "slowhost", "address": "192.168.1.51"}]}`)
+	defer cleanup()
+
+	delays := map[string]time.Duration{"fakehost": 5 * time.Millisecond, "slowhost": 40 * time.Millisecond}
+	services.Register(services.Registration{
+		Source: "fake",
+		Order:  100,
+		Configured: func(host *config.HostConfig) bool {
+			return delays[host.Name] != 0
+		},
+		Factory: func(cfg *config.Config, host *config.HostConfig) (services.Provider, error) {
+			return &slowProvider{fakeProvider: fakeProvider{host: host.Name}, delay: delays[host.Name]}, nil
+		},
+	})
+	t.Cleanup(func() { services.Unregister("fake") })
+
+	get := func(query string, user *auth.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/services"+query, nil)
+		if user != nil {
+			req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, user))
+		}
+		w := httptest.NewRecorder()
+		ServicesHandler(w, req)
+		return w
+	}
+
+	w := get("", nil)
+	header := w.Header().Get("Server-Timing")
+	for host, delay := range delays {
+		match := regexp.MustCompile(`fake-` + host + `;desc="fake on ` + host + `";dur=([0-9.]+)`).FindStringSubmatch(header)
+		if match == nil {
+			t.Errorf("Server-Timing has no entry for %s: %s", host, header)
+			continue
+		}
+		if ms, _ := strconv.ParseFloat(match[1], 64); ms < float64(delay.Milliseconds()) {
+			t.Errorf("%s took %sms, want at least its %v delay", host, match[1], delay)
+		}
+	}
+	for _, phase := range []string{"remap;", "traefik-urls;", "enrich;", "filter;", "total;dur="} {
+		if !strings.Contains(header, phase) {
+			t.Errorf("Server-Timing missing %q: %s", phase, header)
+		}
+	}
+	var list []services.ServiceInfo
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Errorf("body without debug_timing is not a plain list: %v", err)
+	}
+
+	t.Run("hosts only for admins", func(t *testing.T) {
+		header := get("", &auth.User{ID: "viewer", HasGlobalAccess: true}).Header().Get("Server-Timing")
+		if strings.Contains(header, "fakehost") || strings.Contains(header, "slowhost") || !strings.Contains(header, `fake;desc="fake"`) {
+			t.Errorf("Server-Timing for a non-admin = %s, want the hosts summed", header)
+		}
+	})
+
+	t.Run("debug_timing", func(t *testing.T) {
+		w := get("?debug_timing=true", nil)
+		var body struct {
+			Services []services.ServiceInfo `json:"services"`
+			Timing   timingReport           `json:"_timing"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode body: %v", err)
+		}
+		if len(body.Services) < 2 {
+			t.Errorf("services = %+v, want both fake hosts", body.Services)
+		}
+		hosts := make(map[string]float64)
+		for _, phase := range body.Timing.Phases {
+			if phase.Name == "fake" {
+				hosts[phase.Host] = phase.MS
+			}
+		}
+		if len(hosts) != 2 || hosts["slowhost"] < 40 || body.Timing.TotalMS < hosts["slowhost"] {
+			t.Errorf("_timing = %+v, want both hosts within the total", body.Timing)
+		}
+	})
+
+	t.Run("debug_timing grouped", func(t *testing.T) {
+		body := get("?debug_timing=true&group=host", nil).Body.String()
+		if !strings.Contains(body, `"hosts":`) || !strings.Contains(body, `"_timing":`) {
+			t.Errorf("body = %s, want hosts and _timing", body)
+		}
+	})
+
+	t.Run("debug_timing needs an admin", func(t *testing.T) {
+		if w := get("?debug_timing=true", &auth.User{ID: "viewer", HasGlobalAccess: true}); w.Code != http.StatusForbidden {
+			t.Errorf("Status = %d, want %d", w.Code, http.StatusForbidden)
+		}
+		if w := get("?debug_timing=sometimes", nil); w.Code != http.StatusBadRequest {
+			t.Errorf("Status = %d, want %d", w.Code, http.StatusBadRequest)
+		}
+	})
+}
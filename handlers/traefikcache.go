@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"home_server_dashboard/config"
+)
+
+// traefikMappingsMaxAge is how long a host's Traefik service->hostnames
+// mappings are reused before its API is asked again. Routers change far
+// less often than containers, and each fetch from a remote host goes
+// through an SSH tunnel.
+const traefikMappingsMaxAge = servicesMaxAge
+
+// traefikMappingsCache keeps the last Traefik mappings fetched from each
+// host, so collections in quick succession, such as the first page load
+// after the startup warm-up, don't fetch them again.
+type traefikMappingsCache struct {
+	mu      sync.Mutex
+	entries map[string]traefikMappingsEntry
+	now     func() time.Time
+}
+
+// traefikMappingsEntry is the mappings of one host and when they were fetched.
+type traefikMappingsEntry struct {
+	mappings map[string][]string
+	fetched  time.Time
+}
+
+var traefikMappings = &traefikMappingsCache{entries: make(map[string]traefikMappingsEntry), now: time.Now}
+
+// traefikMappingsKey identifies the Traefik API of host, so a changed
+// address or port isn't served the mappings of the old one.
+func traefikMappingsKey(host *config.HostConfig) string {
+	return host.Name + "|" + host.Address + "|" + strconv.Itoa(host.Traefik.APIPort)
+}
+
+// get returns the mappings of host fetched less than traefikMappingsMaxAge
+// ago, if any.
+func (c *traefikMappingsCache) get(host *config.HostConfig) (map[string][]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[traefikMappingsKey(host)]
+	if !ok || c.now().Sub(entry.fetched) >= traefikMappingsMaxAge {
+		return nil, false
+	}
+	return entry.mappings, true
+}
+
+// put keeps mappings, just fetched from host. They are only read afterwards.
+func (c *traefikMappingsCache) put(host *config.HostConfig, mappings map[string][]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[traefikMappingsKey(host)] = traefikMappingsEntry{mappings: mappings, fetched: c.now()}
+}
+
+// reset forgets every host's mappings.
+func (c *traefikMappingsCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"home_server_dashboard/services"
+	"home_server_dashboard/testharness"
+)
+
+// TestEnrichWithTraefikURLs_ReusesMappings tests that a host's Traefik
+// mappings are fetched once per traefikMappingsMaxAge.
+func TestEnrichWithTraefikURLs_ReusesMappings(t *testing.T) {
+	h := testharness.New(t)
+	withHarness(t, h)
+	now := time.Now()
+	traefikMappings.now = func() time.Time { return now }
+	t.Cleanup(func() { traefikMappings.now = time.Now })
+
+	urls := func() []string {
+		svcs := enrichWithTraefikURLs(context.Background(), h.Config, []services.ServiceInfo{
+			{Name: "sonarr", Host: testharness.ServerHost, Source: "docker"},
+		})
+		return svcs[0].TraefikURLs
+	}
+
+	if got := urls(); len(got) != 0 {
+		t.Fatalf("TraefikURLs = %v, want none before the route exists", got)
+	}
+	h.Traefik.Route("sonarr", "sonarr.home.lan")
+	if got := urls(); len(got) != 0 {
+		t.Errorf("TraefikURLs = %v, want the mappings fetched a moment ago", got)
+	}
+
+	now = now.Add(traefikMappingsMaxAge)
+	if got := urls(); !slices.Equal(got, []string{"https://sonarr.home.lan"}) {
+		t.Errorf("TraefikURLs = %v, want the route fetched once the mappings expired", got)
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"home_server_dashboard/auth"
+)
+
+// Defaults of the usage stats query.
+const (
+	defaultUsageDays  = 7
+	defaultUsageLimit = 10
+)
+
+// positiveParam reads an optional positive number from query, returning def
+// if it is absent.
+func positiveParam(query url.Values, name string, def int) (int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a positive number", name, value)
+	}
+	return n, nil
+}
+
+// UsageStatsHandler handles GET /api/stats/services requests.
+// Returns the services whose logs are watched and that are acted on most,
+// ranked by each metric over the last "days" days (default 7, today
+// included) and limited to "limit" services each (default 10). Only
+// administrators may read them.
+func UsageStatsHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil || !user.IsAdmin {
+		http.Error(w, "Access denied: administrator privileges required to view usage stats", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	days, err := positiveParam(query, "days", defaultUsageDays)
+	if err == nil && days > usageStats.RetentionDays() {
+		err = fmt.Errorf("invalid days %d: only %d days are kept", days, usageStats.RetentionDays())
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, err := positiveParam(query, "limit", defaultUsageLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usageStats.Top(days, limit))
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/streams"
+	"home_server_dashboard/usagestats"
+)
+
+func usageRequest(query string, user *auth.User) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/services"+query, nil)
+	if user != nil {
+		req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, user))
+	}
+	w := httptest.NewRecorder()
+	UsageStatsHandler(w, req)
+	return w
+}
+
+func TestUsageStatsHandler(t *testing.T) {
+	orig := usageStats
+	defer func() { usageStats = orig }()
+	store := usagestats.NewStore(30)
+	SetUsageStats(store)
+
+	for i := 0; i < 3; i++ {
+		store.StreamOpened(streams.Info{Endpoint: "/api/logs", Target: "nas/jellyfin"})
+	}
+	store.StreamOpened(streams.Info{Endpoint: "/api/logs/systemd", Target: "nas/nginx.service"})
+
+	admin := &auth.User{ID: "admin", IsAdmin: true, HasGlobalAccess: true}
+	w := usageRequest("?days=1&limit=1", admin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d: %s", w.Code, w.Body.String())
+	}
+	var report usagestats.Report
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if got := report.Top[usagestats.MetricStreamOpens]; report.Days != 1 || len(got) != 1 || got[0].Service != "jellyfin" || got[0].Value != 3 {
+		t.Errorf("report = %+v, want jellyfin alone with 3 opens", report)
+	}
+
+	for _, query := range []string{"?days=0", "?days=31", "?days=week", "?limit=-1"} {
+		if w := usageRequest(query, admin); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+	if w := usageRequest("", &auth.User{ID: "user", HasGlobalAccess: true}); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"home_server_dashboard/config"
+)
+
+// maxNameLength is the longest service, container, project or host name a
+// request body may carry.
+const maxNameLength = 256
+
+// RequestError reports a field of a request body that failed validation.
+// It is sent to the client as JSON with status 400.
+type RequestError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"error"`
+}
+
+func (e *RequestError) Error() string {
+	return e.Message
+}
+
+// fieldError returns a RequestError whose message starts with the field name,
+// e.g. "container_name is required".
+func fieldError(field, format string, args ...any) *RequestError {
+	return &RequestError{Field: field, Message: field + " " + fmt.Sprintf(format, args...)}
+}
+
+// writeRequestError responds 400 with err as JSON. Errors other than
+// RequestError are sent as their message.
+func writeRequestError(w http.ResponseWriter, err error) {
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) {
+		reqErr = &RequestError{Message: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(reqErr)
+}
+
+// isNameChar reports whether c may appear in a name. Besides letters, digits,
+// dots, underscores and hyphens, names may contain @ for systemd template
+// units (wg-quick@wg0.service) and Traefik provider suffixes (api@internal).
+func isNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '.' || c == '_' || c == '-' || c == '@'
+}
+
+// validateName checks that a name is short and made of name characters.
+// Empty names are only accepted if the field is optional.
+func validateName(field, value string, required bool) error {
+	if value == "" {
+		if required {
+			return fieldError(field, "is required")
+		}
+		return nil
+	}
+	if len(value) > maxNameLength {
+		return fieldError(field, "is longer than %d characters", maxNameLength)
+	}
+	for i := 0; i < len(value); i++ {
+		if !isNameChar(value[i]) {
+			return fieldError(field, "contains %q; only letters, digits and . _ - @ are allowed", value[i])
+		}
+	}
+	return nil
+}
+
+// validateHost checks that host names a configured host. The local host name
+// is accepted without a host entry, as Docker runs there regardless.
+func validateHost(cfg *config.Config, host string) error {
+	if err := validateName("host", host, true); err != nil {
+		return err
+	}
+	if cfg == nil {
+		return fieldError("host", "configuration not loaded")
+	}
+	if cfg.GetHostByName(host) == nil && host != cfg.GetLocalHostName() {
+		return fieldError("host", "%q is not a configured host", host)
+	}
+	return nil
+}
+
+// knownSource reports whether services from source can be acted on.
+func knownSource(source string) bool {
+	if _, ok := actionPlanners[source]; ok {
+		return true
+	}
+	_, ok := sourceRegistry.Lookup(source)
+	return ok
+}
+
+// Validate checks the request before it is acted on: the source must be
+// known, the host configured, and every name short and free of characters
+// that don't belong in a name. Docker actions also need the container name.
+func (req ServiceActionRequest) Validate(cfg *config.Config) error {
+	if req.Source == "" {
+		return fieldError("source", "is required")
+	}
+	if err := validateName("source", req.Source, true); err != nil {
+		return err
+	}
+	if !knownSource(req.Source) {
+		return fieldError("source", "%q is not a known service source", req.Source)
+	}
+	if err := validateName("service_name", req.ServiceName, true); err != nil {
+		return err
+	}
+	if err := validateName("container_name", req.ContainerName, req.Source == "docker"); err != nil {
+		return err
+	}
+	if err := validateName("project", req.Project, false); err != nil {
+		return err
+	}
+	if err := validateName("profile", req.Profile, false); err != nil {
+		return err
+	}
+	if req.Profile != "" && req.Source != "docker" {
+		return fieldError("profile", "is only supported for Docker services")
+	}
+	if req.Timeout < 0 {
+		return fieldError("timeout", "must not be negative")
+	}
+	if err := validateName("expected_container_id", req.ExpectedContainerID, false); err != nil {
+		return err
+	}
+	if req.ExpectedContainerID != "" && req.Source != "docker" {
+		return fieldError("expected_container_id", "is only supported for Docker services")
+	}
+	return validateHost(cfg, req.Host)
+}
+
+// Validate checks the container and service names of a flush request. The
+// host is optional since logs are only flushed on the local host.
+func (req LogFlushRequest) Validate(cfg *config.Config) error {
+	if err := validateName("container_name", req.ContainerName, true); err != nil {
+		return err
+	}
+	if err := validateName("service_name", req.ServiceName, false); err != nil {
+		return err
+	}
+	if req.Host == "" {
+		return nil
+	}
+	return validateHost(cfg, req.Host)
+}
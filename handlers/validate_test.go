@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/config"
+)
+
+func validateTestConfig() *config.Config {
+	return &config.Config{Hosts: []config.HostConfig{{Name: "nas", Address: "192.168.1.10"}}}
+}
+
+func TestServiceActionRequest_Validate(t *testing.T) {
+	cfg := validateTestConfig()
+	valid := ServiceActionRequest{ContainerName: "web-1", ServiceName: "web", Source: "docker", Project: "site", Host: "nas"}
+
+	tests := []struct {
+		name      string
+		modify    func(req *ServiceActionRequest)
+		wantField string
+	}{
+		{"valid", func(req *ServiceActionRequest) {}, ""},
+		{"systemd template unit", func(req *ServiceActionRequest) {
+			req.Source, req.ServiceName, req.ContainerName = "systemd", "wg-quick@wg0.service", ""
+		}, ""},
+		{"local host without entry", func(req *ServiceActionRequest) { req.Host = "localhost" }, ""},
+		{"missing source", func(req *ServiceActionRequest) { req.Source = "" }, "source"},
+		{"unknown source", func(req *ServiceActionRequest) { req.Source = "podman" }, "source"},
+		{"missing service name", func(req *ServiceActionRequest) { req.ServiceName = "" }, "service_name"},
+		{"service name too long", func(req *ServiceActionRequest) { req.ServiceName = strings.Repeat("a", maxNameLength+1) }, "service_name"},
+		{"service name with newline", func(req *ServiceActionRequest) { req.ServiceName = "web\nInjected: true" }, "service_name"},
+		{"service name with path", func(req *ServiceActionRequest) { req.ServiceName = "../../etc/passwd" }, "service_name"},
+		{"docker without container name", func(req *ServiceActionRequest) { req.ContainerName = "" }, "container_name"},
+		{"container name with space", func(req *ServiceActionRequest) { req.ContainerName = "web 1" }, "container_name"},
+		{"project with semicolon", func(req *ServiceActionRequest) { req.Project = "site;rm" }, "project"},
+		{"profile", func(req *ServiceActionRequest) { req.Profile = "debug" }, ""},
+		{"profile with flag", func(req *ServiceActionRequest) { req.Profile = "--file=/etc/x" }, "profile"},
+		{"profile of a systemd unit", func(req *ServiceActionRequest) {
+			req.Source, req.ServiceName, req.ContainerName, req.Profile = "systemd", "nginx.service", "", "debug"
+		}, "profile"},
+		{"expected container ID", func(req *ServiceActionRequest) { req.ExpectedContainerID = "4f2a9c0d1e3b" }, ""},
+		{"expected container ID with space", func(req *ServiceActionRequest) { req.ExpectedContainerID = "4f2a 9c0d" }, "expected_container_id"},
+		{"expected container ID of a systemd unit", func(req *ServiceActionRequest) {
+			req.Source, req.ServiceName, req.ContainerName, req.ExpectedContainerID = "systemd", "nginx.service", "", "4f2a9c0d1e3b"
+		}, "expected_container_id"},
+		{"missing host", func(req *ServiceActionRequest) { req.Host = "" }, "host"},
+		{"unknown host", func(req *ServiceActionRequest) { req.Host = "pi" }, "host"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid
+			tt.modify(&req)
+			err := req.Validate(cfg)
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			reqErr, ok := err.(*RequestError)
+			if !ok {
+				t.Fatalf("Validate() = %v, want *RequestError", err)
+			}
+			if reqErr.Field != tt.wantField {
+				t.Errorf("Field = %q, want %q (%v)", reqErr.Field, tt.wantField, err)
+			}
+		})
+	}
+}
+
+func TestServiceActionRequest_ValidateWithoutConfig(t *testing.T) {
+	req := ServiceActionRequest{ContainerName: "web-1", ServiceName: "web", Source: "docker", Host: "nas"}
+	if err := req.Validate(nil); err == nil {
+		t.Error("Validate(nil) = nil, want error")
+	}
+}
+
+func TestLogFlushRequest_Validate(t *testing.T) {
+	cfg := validateTestConfig()
+
+	tests := []struct {
+		name      string
+		req       LogFlushRequest
+		wantField string
+	}{
+		{"container only", LogFlushRequest{ContainerName: "web-1"}, ""},
+		{"with service and host", LogFlushRequest{ContainerName: "web-1", ServiceName: "web", Host: "nas"}, ""},
+		{"missing container name", LogFlushRequest{ServiceName: "web"}, "container_name"},
+		{"container name too long", LogFlushRequest{ContainerName: strings.Repeat("a", maxNameLength+1)}, "container_name"},
+		{"service name with slash", LogFlushRequest{ContainerName: "web-1", ServiceName: "a/b"}, "service_name"},
+		{"unknown host", LogFlushRequest{ContainerName: "web-1", Host: "pi"}, "host"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate(cfg)
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			reqErr, ok := err.(*RequestError)
+			if !ok {
+				t.Fatalf("Validate() = %v, want *RequestError", err)
+			}
+			if reqErr.Field != tt.wantField {
+				t.Errorf("Field = %q, want %q (%v)", reqErr.Field, tt.wantField, err)
+			}
+		})
+	}
+}
+
+func TestServiceActionHandler_InvalidRequestIsJSON(t *testing.T) {
+	cleanup := setupTestConfig(t, `{"hosts": [{"name": "nas", "address": "192.168.1.10"}]}`)
+	defer cleanup()
+
+	admin := auth.User{ID: "admin", Name: "Admin", IsAdmin: true}
+	body := strings.NewReader(`{"container_name": "web-1", "service_name": "web", "source": "docker", "host": "pi"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/services/restart", body)
+	req = req.WithContext(context.WithValue(req.Context(), authUserContextKey, &admin))
+	w := httptest.NewRecorder()
+
+	ServiceActionHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var reqErr RequestError
+	if err := json.Unmarshal(w.Body.Bytes(), &reqErr); err != nil {
+		t.Fatalf("Failed to decode body %q: %v", w.Body.String(), err)
+	}
+	if reqErr.Field != "host" || !strings.Contains(reqErr.Message, `"pi"`) {
+		t.Errorf("RequestError = %+v, want the unknown host reported", reqErr)
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"home_server_dashboard/services"
+)
+
+// WarmupPhase is how far the startup warm-up got.
+type WarmupPhase string
+
+const (
+	WarmupWarming  WarmupPhase = "warming"   // The first collection is running
+	WarmupDone     WarmupPhase = "done"      // The first collection finished
+	WarmupTimedOut WarmupPhase = "timed_out" // The first collection took longer than the warm-up timeout
+)
+
+// WarmupStatus is the state of the startup warm-up, reported in /readyz.
+type WarmupStatus struct {
+	Phase    WarmupPhase `json:"phase"`
+	Started  time.Time   `json:"started"`
+	Finished *time.Time  `json:"finished,omitempty"`
+	Services int         `json:"services"` // Services collected so far
+}
+
+// warmup is the first collection of the services, run at startup so the
+// first page load finds them collected instead of paying for every
+// provider, and the connections to the remote hosts made.
+type warmup struct {
+	mu         sync.Mutex
+	status     WarmupStatus
+	partial    []services.ServiceInfo // Collected so far, until the collection is stored
+	gatesReady bool                   // /readyz waits for the warm-up to end
+	done       chan struct{}
+}
+
+// DiscoverySeeder takes the services the warm-up collected as discovered,
+// so it knows them before its own first polls. It is implemented by the
+// monitor.
+type DiscoverySeeder interface {
+	Seed(svcs []services.ServiceInfo)
+}
+
+// startupWarmup is the warm-up started by StartWarmup, nil if none was.
+var (
+	warmupMu      sync.Mutex
+	startupWarmup *warmup
+)
+
+type warmupContextKey struct{}
+
+// warmupFrom returns the warm-up collecting in ctx, or nil if it isn't one.
+func warmupFrom(ctx context.Context) *warmup {
+	w, _ := ctx.Value(warmupContextKey{}).(*warmup)
+	return w
+}
+
+// currentWarmup returns the warm-up started, or nil if none was.
+func currentWarmup() *warmup {
+	warmupMu.Lock()
+	defer warmupMu.Unlock()
+	return startupWarmup
+}
+
+// StartWarmup collects the services once in the background to fill the
+// services cache, the Traefik mappings cache and the monitor's discovery
+// (see DiscoverySeeder), and returns a channel closed when the warm-up ends: once
+// the collection finishes, or after timeout if it takes longer. A host that
+// doesn't answer can't hold it up past the timeout; the collection goes on
+// and is stored when it finishes. Until the warm-up ends, the dashboard
+// page carries the services collected so far, flagged as warming. If
+// gatesReady is set, /readyz answers 503 until it ends.
+func StartWarmup(timeout time.Duration, gatesReady bool) <-chan struct{} {
+	w := &warmup{
+		status:     WarmupStatus{Phase: WarmupWarming, Started: time.Now()},
+		gatesReady: gatesReady,
+		done:       make(chan struct{}),
+	}
+	warmupMu.Lock()
+	startupWarmup = w
+	warmupMu.Unlock()
+
+	cache, cfg := servicesCache, configSource()
+	seeder, _ := stateTracker.(DiscoverySeeder)
+	collected := make(chan error, 1)
+	go func() {
+		if cfg == nil {
+			collected <- errors.New("configuration not loaded")
+			return
+		}
+		ctx := context.WithValue(context.Background(), warmupContextKey{}, w)
+		snapshot, _, err := cache.get(ctx, cfg)
+		// The collection is stored now, so the pieces of it aren't needed
+		w.mu.Lock()
+		w.partial = nil
+		w.mu.Unlock()
+		if err == nil && seeder != nil {
+			seeder.Seed(slices.Clone(snapshot.services))
+		}
+		collected <- err
+	}()
+	go func() {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case err := <-collected:
+			if err != nil {
+				log.Printf("Warning: warm-up collection failed: %v", err)
+			}
+			w.finish(WarmupDone)
+		case <-timer.C:
+			log.Printf("Warning: warm-up still collecting services after %v; serving without it", timeout)
+			w.finish(WarmupTimedOut)
+		}
+	}()
+	return w.done
+}
+
+// add keeps svcs, just collected from one host, until the collection is
+// stored. Safe to call on a nil warm-up.
+func (w *warmup) add(svcs []services.ServiceInfo) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.partial = append(w.partial, svcs...)
+	w.status.Services = len(w.partial)
+}
+
+// finish ends the warm-up in phase.
+func (w *warmup) finish(phase WarmupPhase) {
+	w.mu.Lock()
+	now := time.Now()
+	w.status.Phase = phase
+	w.status.Finished = &now
+	w.mu.Unlock()
+	close(w.done)
+}
+
+// warming reports whether the warm-up is running, and the services its
+// collection has collected so far if it isn't stored yet. Safe to call on a
+// nil warm-up.
+func (w *warmup) warming() (bool, []services.ServiceInfo) {
+	if w == nil {
+		return false, nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status.Phase == WarmupWarming, slices.Clone(w.partial)
+}
+
+// report returns the warm-up's status and whether it holds up readiness.
+func (w *warmup) report() (WarmupStatus, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status, w.gatesReady && w.status.Phase == WarmupWarming
+}
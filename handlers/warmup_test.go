@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/services"
+)
+
+// slowCollection hands the services of "nas" to the warm-up at once and
+// returns those of "pi" too once released.
+type slowCollection struct {
+	release chan struct{}
+}
+
+func (s *slowCollection) collect(ctx context.Context, cfg *config.Config) ([]services.ServiceInfo, error) {
+	nas := []services.ServiceInfo{{Name: "jellyfin", Host: "nas", Source: "docker", State: services.StateRunning}}
+	warmupFrom(ctx).add(nas)
+	<-s.release
+	pi := []services.ServiceInfo{{Name: "pihole", Host: "pi", Source: "docker", State: services.StateRunning}}
+	warmupFrom(ctx).add(pi)
+	return append(nas, pi...), nil
+}
+
+// withSlowWarmup replaces the services cache with a slow collection and
+// forgets the warm-up after the test.
+func withSlowWarmup(t *testing.T) *slowCollection {
+	t.Helper()
+	withFakeServices(t)
+	slow := &slowCollection{release: make(chan struct{})}
+	servicesCache.collect = slow.collect
+	t.Cleanup(func() {
+		warmupMu.Lock()
+		startupWarmup = nil
+		warmupMu.Unlock()
+	})
+	return slow
+}
+
+// readyz returns the status and response of GET /readyz.
+func readyz(t *testing.T) (int, readyzResponse) {
+	t.Helper()
+	w := getDebug(ReadyzHandler, "/readyz", nil)
+	var resp readyzResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, resp
+}
+
+// waitForPartial waits until the warm-up has collected n services.
+func waitForPartial(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, partial := currentWarmup().warming(); len(partial) == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the warm-up didn't collect %d services", n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStartWarmup(t *testing.T) {
+	slow := withSlowWarmup(t)
+	withIndexPage(t)
+	original := stateTracker
+	defer SetStateTracker(original)
+	SetStateTracker(nil)
+
+	done := StartWarmup(time.Minute, true)
+	waitForPartial(t, 1)
+
+	// The page has the services of the hosts collected so far
+	_, envelope := getIndex(t, nil)
+	if !envelope.Warming || envelope.Loading || len(envelope.Services) != 1 || envelope.Services[0].Host != "nas" {
+		t.Errorf("snapshot while warming = %+v, want the services of nas", envelope)
+	}
+	code, resp := readyz(t)
+	if code != http.StatusServiceUnavailable || resp.Warmup == nil || resp.Warmup.Phase != WarmupWarming || resp.Warmup.Services != 1 {
+		t.Errorf("/readyz while warming = %d %+v, want 503 warming", code, resp)
+	}
+
+	close(slow.release)
+	<-done
+	_, envelope = getIndex(t, nil)
+	if envelope.Warming || len(envelope.Services) != 2 || envelope.ETag == "" {
+		t.Errorf("snapshot after the warm-up = %+v, want every service collected", envelope)
+	}
+	code, resp = readyz(t)
+	if code != http.StatusOK || resp.Warmup.Phase != WarmupDone || resp.Warmup.Finished == nil {
+		t.Errorf("/readyz after the warm-up = %d %+v, want 200 done", code, resp)
+	}
+}
+
+// seedingTracker records the services the warm-up seeds it with.
+type seedingTracker struct {
+	mu     sync.Mutex
+	seeded []services.ServiceInfo
+}
+
+func (s *seedingTracker) LastStateChange(host, serviceName string) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (s *seedingTracker) Seed(svcs []services.ServiceInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seeded = append(s.seeded, svcs...)
+}
+
+// TestServicesHandler_Warming tests that /api/services answers during the
+// warm-up with the services collected so far, flagged as warming, without
+// waiting for the collection, and from the stored collection afterwards.
+func TestServicesHandler_Warming(t *testing.T) {
+	slow := withSlowWarmup(t)
+	original := stateTracker
+	defer SetStateTracker(original)
+	tracker := &seedingTracker{}
+	SetStateTracker(tracker)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ServicesHandler(w, httptest.NewRequest(http.MethodGet, "/api/services"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body.String())
+		}
+		return w
+	}
+
+	done := StartWarmup(time.Minute, false)
+	waitForPartial(t, 1)
+
+	var warming struct {
+		Services []services.ServiceInfo `json:"services"`
+		Warming  bool                   `json:"warming"`
+	}
+	if err := json.Unmarshal(get("").Body.Bytes(), &warming); err != nil {
+		t.Fatal(err)
+	}
+	if !warming.Warming || len(warming.Services) != 1 || warming.Services[0].Host != "nas" {
+		t.Errorf("services while warming = %+v, want the services of nas flagged as warming", warming)
+	}
+	var grouped map[string]any
+	if err := json.Unmarshal(get("?group=host").Body.Bytes(), &grouped); err != nil {
+		t.Fatal(err)
+	}
+	if grouped["warming"] != true || grouped["hosts"] == nil {
+		t.Errorf("grouped services while warming = %v, want hosts flagged as warming", grouped)
+	}
+
+	close(slow.release)
+	<-done
+	var list []services.ServiceInfo
+	if err := json.Unmarshal(get("").Body.Bytes(), &list); err != nil {
+		t.Fatalf("services after the warm-up aren't a plain list: %v", err)
+	}
+	if len(list) != 2 {
+		t.Errorf("services after the warm-up = %+v, want every service collected", list)
+	}
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	if len(tracker.seeded) != 2 {
+		t.Errorf("monitor seeded with %+v, want the warm-up's collection", tracker.seeded)
+	}
+}
+
+func TestStartWarmup_TimesOut(t *testing.T) {
+	slow := withSlowWarmup(t)
+	withIndexPage(t)
+	original := stateTracker
+	defer SetStateTracker(original)
+	SetStateTracker(nil)
+
+	<-StartWarmup(50*time.Millisecond, true)
+
+	// A host that doesn't answer holds up neither readiness nor the flag
+	code, resp := readyz(t)
+	if code != http.StatusOK || resp.Warmup.Phase != WarmupTimedOut {
+		t.Errorf("/readyz after the timeout = %d %+v, want 200 timed out", code, resp)
+	}
+	_, envelope := getIndex(t, nil)
+	if envelope.Warming || len(envelope.Services) != 1 {
+		t.Errorf("snapshot after the timeout = %+v, want the services collected so far", envelope)
+	}
+
+	// The collection is stored when it finishes
+	close(slow.release)
+	deadline := time.Now().Add(2 * time.Second)
+	for servicesCache.last() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("the collection wasn't stored")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, envelope = getIndex(t, nil); len(envelope.Services) != 2 {
+		t.Errorf("snapshot after the collection = %+v, want every service", envelope)
+	}
+}
+
+func TestReadyzHandler_WarmupNotGating(t *testing.T) {
+	slow := withSlowWarmup(t)
+	defer close(slow.release)
+	original := stateTracker
+	defer SetStateTracker(original)
+	SetStateTracker(nil)
+
+	StartWarmup(time.Minute, false)
+	if code, resp := readyz(t); code != http.StatusOK || resp.Warmup == nil || resp.Warmup.Phase != WarmupWarming {
+		t.Errorf("/readyz = %d %+v, want 200 while warming", code, resp)
+	}
+}
@@ -0,0 +1,74 @@
+// Package httpclient builds the HTTP clients the dashboard connects to other
+// services' APIs with, so they all trust the configured CAs and go through
+// the configured proxy. Clients with the same outbound settings share one
+// transport, and with it their idle connections.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+
+	"home_server_dashboard/config"
+)
+
+// transportKey identifies the outbound settings of a shared transport.
+type transportKey struct {
+	rootCAs  *x509.CertPool
+	proxy    string
+	insecure bool
+}
+
+var (
+	transportsMu sync.Mutex
+	transports   = make(map[transportKey]*http.Transport)
+)
+
+// New returns a client with the outbound settings out, whose requests time
+// out after timeout (0 for none).
+func New(out config.Outbound, timeout time.Duration) *http.Client {
+	return &http.Client{Transport: Transport(out), Timeout: timeout}
+}
+
+// Transport returns the transport for the outbound settings out: it trusts
+// out's CAs (or the system's), connects through out's proxy (or the one in
+// the environment) and skips certificate checks only if out is insecure.
+// The transport is shared and must not be modified.
+func Transport(out config.Outbound) *http.Transport {
+	key := transportKey{rootCAs: out.RootCAs, insecure: out.Insecure}
+	if out.ProxyURL != nil {
+		key.proxy = out.ProxyURL.String()
+	}
+
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	if t, ok := transports[key]; ok {
+		return t
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = proxyFunc(key.proxy)
+	if out.RootCAs != nil || out.Insecure {
+		t.TLSClientConfig = &tls.Config{RootCAs: out.RootCAs, InsecureSkipVerify: out.Insecure}
+	}
+	transports[key] = t
+	return t
+}
+
+// proxyFunc returns the proxy selection of a transport going through proxy,
+// or through the proxy in the environment if proxy is empty. Either way,
+// loopback addresses and the hosts in NO_PROXY are reached directly.
+func proxyFunc(proxy string) func(*http.Request) (*url.URL, error) {
+	if proxy == "" {
+		return http.ProxyFromEnvironment
+	}
+	cfg := httpproxy.Config{HTTPProxy: proxy, HTTPSProxy: proxy, NoProxy: httpproxy.FromEnvironment().NoProxy}
+	selectProxy := cfg.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return selectProxy(req.URL)
+	}
+}
@@ -0,0 +1,182 @@
+package httpclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"home_server_dashboard/config"
+)
+
+// newTestCA returns a CA pool and a TLS server whose certificate, for
+// ha.internal and 127.0.0.1, the CA signed.
+func newTestCA(t *testing.T) (*x509.CertPool, *httptest.Server) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Home CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "ha.internal"},
+		DNSNames:     []string{"ha.internal"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, caCert, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{leafDER}, PrivateKey: leafKey}}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+	return pool, srv
+}
+
+// connectProxy is an HTTP proxy that tunnels CONNECT requests to target,
+// recording the address each asked for.
+type connectProxy struct {
+	target string
+	mu     sync.Mutex
+	hosts  []string
+}
+
+func (p *connectProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+		return
+	}
+	p.mu.Lock()
+	p.hosts = append(p.hosts, r.Host)
+	p.mu.Unlock()
+
+	upstream, err := net.Dial("tcp", p.target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	go func() {
+		io.Copy(upstream, buf)
+		upstream.Close()
+	}()
+	io.Copy(conn, upstream)
+	conn.Close()
+}
+
+func (p *connectProxy) connected() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.hosts...)
+}
+
+// get fetches url with client, returning the body.
+func get(client *http.Client, url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestNew_CustomCA(t *testing.T) {
+	pool, srv := newTestCA(t)
+
+	if _, err := get(New(config.Outbound{}, 5*time.Second), srv.URL); err == nil {
+		t.Error("system CAs trusted a certificate of the test CA")
+	}
+	if body, err := get(New(config.Outbound{RootCAs: pool}, 5*time.Second), srv.URL); err != nil || body != "ok" {
+		t.Errorf("with the test CA: body %q, error %v", body, err)
+	}
+	if body, err := get(New(config.Outbound{Insecure: true}, 5*time.Second), srv.URL); err != nil || body != "ok" {
+		t.Errorf("insecure: body %q, error %v", body, err)
+	}
+}
+
+func TestNew_Proxy(t *testing.T) {
+	pool, srv := newTestCA(t)
+	proxy := &connectProxy{target: srv.Listener.Addr().String()}
+	proxySrv := httptest.NewServer(proxy)
+	defer proxySrv.Close()
+	proxyURL, _ := url.Parse(proxySrv.URL)
+
+	client := New(config.Outbound{RootCAs: pool, ProxyURL: proxyURL}, 5*time.Second)
+	if body, err := get(client, "https://ha.internal:8123/api/"); err != nil || body != "ok" {
+		t.Fatalf("through the proxy: body %q, error %v", body, err)
+	}
+	if got := proxy.connected(); len(got) != 1 || got[0] != "ha.internal:8123" {
+		t.Errorf("proxy saw CONNECT %q, want ha.internal:8123", got)
+	}
+
+	// Loopback addresses are reached directly
+	if _, err := get(client, srv.URL); err != nil {
+		t.Fatalf("loopback request: %v", err)
+	}
+	if got := proxy.connected(); len(got) != 1 {
+		t.Errorf("loopback request went through the proxy: %q", got)
+	}
+}
+
+func TestTransport_Shared(t *testing.T) {
+	pool := x509.NewCertPool()
+	proxyURL, _ := url.Parse("http://proxy.lan:3128")
+	a := Transport(config.Outbound{RootCAs: pool, ProxyURL: proxyURL})
+	b := Transport(config.Outbound{RootCAs: pool, ProxyURL: &url.URL{Scheme: "http", Host: "proxy.lan:3128"}})
+	if a != b {
+		t.Error("same settings got different transports")
+	}
+	if c := Transport(config.Outbound{RootCAs: pool}); c == a {
+		t.Error("different settings share a transport")
+	}
+}
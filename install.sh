@@ -85,7 +85,10 @@ fi
 
 log_info "Compiling ${BINARY_NAME}..."
 go generate ./...
-go build -o "${BINARY_NAME}" .
+VERSION=$(git describe --tags --always 2>/dev/null || echo "dev")
+COMMIT=$(git rev-parse --short HEAD 2>/dev/null || echo "unknown")
+BUILD_DATE=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+go build -ldflags "-X home_server_dashboard/version.Version=${VERSION} -X home_server_dashboard/version.Commit=${COMMIT} -X home_server_dashboard/version.BuildDate=${BUILD_DATE}" -o "${BINARY_NAME}" .
 
 # Step 3: Install the binary
 log_info "Installing binary to ${BINARY_PATH}..."
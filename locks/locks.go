@@ -0,0 +1,205 @@
+// Package locks serializes operations on the same Docker Compose project.
+// Interleaving two compose commands on one project (a down from one operation
+// and an up from another) can leave the stack half torn down, so every path
+// that runs compose commands takes the project lock first. Operations on
+// different projects proceed in parallel.
+package locks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"home_server_dashboard/services"
+)
+
+// Defaults.
+const (
+	// DefaultMaxWait is how long an operation waits for a busy project before giving up.
+	DefaultMaxWait = 60 * time.Second
+	// progressInterval is how often waiters are told the project is still busy.
+	progressInterval = 5 * time.Second
+)
+
+// Holder describes the operation holding a project lock.
+type Holder struct {
+	Operation string    `json:"operation"` // e.g., "restart"
+	Owner     string    `json:"owner"`     // who started it (user name, "scheduler", ...)
+	Since     time.Time `json:"since"`     // when the lock was acquired
+}
+
+// String returns a description like "restart by alice".
+func (h Holder) String() string {
+	if h.Owner == "" {
+		return h.Operation
+	}
+	return h.Operation + " by " + h.Owner
+}
+
+// BusyError is returned when a project stays locked for longer than the max wait.
+type BusyError struct {
+	Host    string
+	Project string
+	Holder  Holder
+}
+
+func (e *BusyError) Error() string {
+	return fmt.Sprintf("project %s on %s is busy: %s has been running for %s",
+		e.Project, e.Host, e.Holder, time.Since(e.Holder.Since).Round(time.Second))
+}
+
+// WaitFunc is called periodically while waiting for a busy project.
+type WaitFunc func(holder Holder, elapsed time.Duration)
+
+// held is a lock currently held on a project.
+type held struct {
+	holder Holder
+	done   chan struct{} // closed on release
+}
+
+// ProjectLocks is a set of per-project locks keyed by host and project.
+type ProjectLocks struct {
+	mu       sync.Mutex
+	projects map[services.Key]*held // key: host and project
+	maxWait  time.Duration
+	progress time.Duration
+}
+
+// New creates a ProjectLocks where waiters give up after maxWait.
+// Non-positive values use DefaultMaxWait.
+func New(maxWait time.Duration) *ProjectLocks {
+	if maxWait <= 0 {
+		maxWait = DefaultMaxWait
+	}
+	return &ProjectLocks{
+		projects: make(map[services.Key]*held),
+		maxWait:  maxWait,
+		progress: progressInterval,
+	}
+}
+
+// SetMaxWait changes how long waiters wait for a busy project.
+// Non-positive values use DefaultMaxWait.
+func (l *ProjectLocks) SetMaxWait(maxWait time.Duration) {
+	if maxWait <= 0 {
+		maxWait = DefaultMaxWait
+	}
+	l.mu.Lock()
+	l.maxWait = maxWait
+	l.mu.Unlock()
+}
+
+func projectKey(host, project string) services.Key {
+	return services.Key{Host: host, Project: project}
+}
+
+// Acquire waits for the lock on project and returns a release function that
+// must be called exactly once when the operation ends (use defer so a panic
+// still releases it). While the project is busy, onWait (if non-nil) is called
+// every few seconds. Returns a *BusyError if the project is still busy after
+// the max wait, or ctx's error if ctx is done first.
+func (l *ProjectLocks) Acquire(ctx context.Context, host, project, operation, owner string, onWait WaitFunc) (func(), error) {
+	key := projectKey(host, project)
+
+	l.mu.Lock()
+	deadline := time.NewTimer(l.maxWait)
+	l.mu.Unlock()
+	defer deadline.Stop()
+
+	ticker := time.NewTicker(l.progress)
+	defer ticker.Stop()
+
+	for {
+		l.mu.Lock()
+		current, busy := l.projects[key]
+		if !busy {
+			h := &held{
+				holder: Holder{Operation: operation, Owner: owner, Since: time.Now()},
+				done:   make(chan struct{}),
+			}
+			l.projects[key] = h
+			l.mu.Unlock()
+			return l.releaseFunc(key, h), nil
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-current.done:
+			// Released; loop to take it unless another waiter got there first
+		case <-ticker.C:
+			if onWait != nil {
+				onWait(current.holder, time.Since(current.holder.Since))
+			}
+		case <-deadline.C:
+			return nil, &BusyError{Host: host, Project: project, Holder: current.holder}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// releaseFunc returns an idempotent release for h.
+func (l *ProjectLocks) releaseFunc(key services.Key, h *held) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			if l.projects[key] == h {
+				delete(l.projects, key)
+			}
+			l.mu.Unlock()
+			close(h.done)
+		})
+	}
+}
+
+// Holder returns the operation holding the lock on project, if any.
+func (l *ProjectLocks) Holder(host, project string) (Holder, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h, ok := l.projects[projectKey(host, project)]
+	if !ok {
+		return Holder{}, false
+	}
+	return h.holder, true
+}
+
+// Holders returns the operations currently holding project locks, keyed by
+// host and project.
+func (l *ProjectLocks) Holders() map[services.Key]Holder {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	holders := make(map[services.Key]Holder, len(l.projects))
+	for key, h := range l.projects {
+		holders[key] = h.holder
+	}
+	return holders
+}
+
+// IsBusy reports whether err means a project stayed locked past the max wait.
+func IsBusy(err error) bool {
+	var busy *BusyError
+	return errors.As(err, &busy)
+}
+
+// defaultLocks is shared by every path that runs compose commands.
+var defaultLocks = New(DefaultMaxWait)
+
+// Configure sets how long operations wait for a busy project on the default locks.
+func Configure(maxWait time.Duration) {
+	defaultLocks.SetMaxWait(maxWait)
+}
+
+// Default returns the shared project locks.
+func Default() *ProjectLocks {
+	return defaultLocks
+}
+
+// AcquireProject waits for the lock on project using the default locks.
+func AcquireProject(ctx context.Context, host, project, operation, owner string, onWait WaitFunc) (func(), error) {
+	return defaultLocks.Acquire(ctx, host, project, operation, owner, onWait)
+}
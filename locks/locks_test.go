@@ -0,0 +1,178 @@
+package locks
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAcquire_ContendingSameProject(t *testing.T) {
+	l := New(time.Second)
+
+	release, err := l.Acquire(context.Background(), "nas", "media", "restart", "cron", nil)
+	if err != nil {
+		t.Fatalf("Acquire() = %v", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		r, err := l.Acquire(context.Background(), "nas", "media", "stop", "alice", nil)
+		if err != nil {
+			t.Errorf("second Acquire() = %v", err)
+			return
+		}
+		r()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second operation acquired the lock while the first held it")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("second operation did not acquire the lock after release")
+	}
+}
+
+func TestAcquire_DifferentProjectsRunInParallel(t *testing.T) {
+	l := New(time.Second)
+
+	var wg sync.WaitGroup
+	var concurrent, peak atomic.Int32
+	for _, project := range []string{"media", "monitoring", "web"} {
+		wg.Add(1)
+		go func(project string) {
+			defer wg.Done()
+			release, err := l.Acquire(context.Background(), "nas", project, "restart", "", nil)
+			if err != nil {
+				t.Errorf("Acquire(%s) = %v", project, err)
+				return
+			}
+			defer release()
+
+			n := concurrent.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			concurrent.Add(-1)
+		}(project)
+	}
+	wg.Wait()
+
+	if peak.Load() != 3 {
+		t.Errorf("peak concurrent operations = %d, want 3", peak.Load())
+	}
+}
+
+func TestAcquire_SameProjectNameOnDifferentHosts(t *testing.T) {
+	l := New(50 * time.Millisecond)
+
+	r1, _ := l.Acquire(context.Background(), "nas", "media", "restart", "", nil)
+	defer r1()
+
+	r2, err := l.Acquire(context.Background(), "pi", "media", "restart", "", nil)
+	if err != nil {
+		t.Fatalf("Acquire() on another host = %v, want nil", err)
+	}
+	r2()
+}
+
+func TestHolder(t *testing.T) {
+	l := New(50 * time.Millisecond)
+	if _, busy := l.Holder("nas", "media"); busy {
+		t.Fatal("Holder() reports a lock nobody holds")
+	}
+
+	release, _ := l.Acquire(context.Background(), "nas", "media", "restart", "alice", nil)
+	if h, busy := l.Holder("nas", "media"); !busy || h.String() != "restart by alice" {
+		t.Errorf("Holder() = %v, %v, want restart by alice", h, busy)
+	}
+	release()
+	if _, busy := l.Holder("nas", "media"); busy {
+		t.Error("Holder() reports a released lock")
+	}
+}
+
+func TestAcquire_ReportsProgressAndGivesUp(t *testing.T) {
+	l := New(80 * time.Millisecond)
+	l.progress = 20 * time.Millisecond
+
+	release, _ := l.Acquire(context.Background(), "nas", "media", "restart", "cron", nil)
+	defer release()
+
+	var waits []Holder
+	_, err := l.Acquire(context.Background(), "nas", "media", "stop", "alice", func(h Holder, elapsed time.Duration) {
+		waits = append(waits, h)
+	})
+
+	var busy *BusyError
+	if !errors.As(err, &busy) {
+		t.Fatalf("Acquire() = %v, want *BusyError", err)
+	}
+	if !IsBusy(err) {
+		t.Error("IsBusy() = false, want true")
+	}
+	if busy.Holder.String() != "restart by cron" {
+		t.Errorf("Holder = %q, want restart by cron", busy.Holder)
+	}
+	if len(waits) == 0 {
+		t.Fatal("expected progress callbacks while waiting")
+	}
+	if waits[0].Operation != "restart" || waits[0].Owner != "cron" {
+		t.Errorf("progress holder = %+v, want restart by cron", waits[0])
+	}
+}
+
+func TestAcquire_HonorsContext(t *testing.T) {
+	l := New(time.Minute)
+
+	release, _ := l.Acquire(context.Background(), "nas", "media", "restart", "", nil)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, "nas", "media", "stop", "", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire() = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestRelease_OnPanicAndIdempotent(t *testing.T) {
+	l := New(50 * time.Millisecond)
+
+	func() {
+		defer func() { recover() }()
+		release, _ := l.Acquire(context.Background(), "nas", "media", "restart", "", nil)
+		defer release()
+		panic("compose exploded")
+	}()
+
+	if len(l.Holders()) != 0 {
+		t.Fatalf("Holders() = %v, want none after panic", l.Holders())
+	}
+
+	release, err := l.Acquire(context.Background(), "nas", "media", "restart", "", nil)
+	if err != nil {
+		t.Fatalf("Acquire() after panic = %v", err)
+	}
+	release()
+	release() // second release is a no-op
+
+	r, err := l.Acquire(context.Background(), "nas", "media", "restart", "", nil)
+	if err != nil {
+		t.Fatalf("Acquire() after double release = %v", err)
+	}
+	r()
+}
@@ -2,28 +2,79 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
 	"os/user"
+	"strings"
 	"syscall"
 	"time"
 
+	"home_server_dashboard/actionhistory"
 	"home_server_dashboard/auth"
 	"home_server_dashboard/config"
+	"home_server_dashboard/connlimit"
+	"home_server_dashboard/eventexport"
 	"home_server_dashboard/events"
+	"home_server_dashboard/httpclient"
+	"home_server_dashboard/locks"
+	"home_server_dashboard/mdns"
 	"home_server_dashboard/monitor"
+	"home_server_dashboard/notices"
 	"home_server_dashboard/notifiers"
 	"home_server_dashboard/notifiers/gotify"
 	"home_server_dashboard/polkit"
+	"home_server_dashboard/realip"
+	"home_server_dashboard/resilience"
+	"home_server_dashboard/selfdetect"
+	"home_server_dashboard/selftest"
 	"home_server_dashboard/server"
+	_ "home_server_dashboard/services/demo" // Registers the demo source
+	"home_server_dashboard/services/docker"
+	"home_server_dashboard/sshmux"
+	"home_server_dashboard/streams"
 	"home_server_dashboard/sudoers"
+	"home_server_dashboard/usagestats"
+	"home_server_dashboard/version"
 	"home_server_dashboard/websocket"
 )
 
+// detectSelf works out which systemd unit or Docker container the dashboard
+// runs in. A container ID is only kept once Docker confirms it and gives its name.
+func detectSelf(cfg *config.Config) selfdetect.Identity {
+	self := selfdetect.Detect()
+	if self.Unit != "" {
+		log.Printf("Running as systemd unit %s", self.Unit)
+	}
+	if self.ContainerID == "" {
+		return self
+	}
+
+	dockerProvider, err := docker.NewProvider(cfg.GetLocalHostName())
+	if err != nil {
+		self.ContainerID = ""
+		return self
+	}
+	defer dockerProvider.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	name, err := dockerProvider.ContainerNameByID(ctx, self.ContainerID)
+	if err != nil {
+		self.ContainerID = ""
+		return self
+	}
+	self.ContainerName = name
+	log.Printf("Running in Docker container %s", name)
+	return self
+}
+
 // getConfigPath returns the configuration file path.
 // Priority: CONFIG_PATH env var > default "services.json" in current directory
 func getConfigPath() string {
@@ -33,17 +84,74 @@ func getConfigPath() string {
 	return "services.json"
 }
 
+// hashPassword implements the hash-password subcommand: it reads a password
+// from the first line of stdin and prints the local users file line of the
+// user named in args.
+func hashPassword(args []string) {
+	fs := flag.NewFlagSet("hash-password", flag.ExitOnError)
+	groups := fs.String("groups", "", "Comma-separated groups of the user, such as the OIDC admin_group or groups configured under oidc.groups")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s hash-password [-groups group1,group2] <username> < password\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		log.Fatalf("Failed to read the password: %v", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		log.Fatalf("No password on stdin")
+	}
+
+	var groupList []string
+	for _, group := range strings.Split(*groups, ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groupList = append(groupList, group)
+		}
+	}
+	line, err := auth.HashPassword(fs.Arg(0), password, groupList)
+	if err != nil {
+		log.Fatalf("Failed to hash the password: %v", err)
+	}
+	fmt.Println(line)
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "hash-password" {
+		hashPassword(os.Args[2:])
+		return
+	}
+
 	// Parse command line flags
 	generateSudoersFlag := flag.Bool("generate-sudoers", false, "Generate sudoers configuration for remote systemd services and exit")
 	generatePolkitFlag := flag.Bool("generate-polkit", false, "Generate polkit rules for local systemd services and exit")
 	authUser := flag.String("user", "", "Username for sudoers/polkit files (defaults to current user)")
+	versionFlag := flag.Bool("version", false, "Print version information and exit")
+	selfTestFlag := flag.Bool("self-test", false, "Check every configured integration, print a pass/fail table and exit")
+	demoFlag := flag.Bool("demo", false, "Run with synthetic demo services instead of the configuration file")
+	eventsStdoutFlag := flag.Bool("events-stdout", false, "Write every event to stdout as a line of JSON")
 	flag.Parse()
 
+	// Handle version output
+	if *versionFlag {
+		fmt.Println(version.Get())
+		os.Exit(0)
+	}
+
 	// Load configuration
 	configPath := getConfigPath()
-	cfg, err := config.Load(configPath)
-	if err != nil {
+	var cfg *config.Config
+	var err error
+	if *demoFlag {
+		configPath = "built-in demo configuration"
+		cfg = config.Demo()
+	} else if cfg, err = config.Load(configPath); err != nil {
 		log.Fatalf("Failed to load configuration from %s: %v", configPath, err)
 	}
 
@@ -65,7 +173,7 @@ func main() {
 			hosts[i] = polkit.HostServices{
 				Name:     host.Name,
 				Address:  host.Address,
-				Services: host.SystemdServices,
+				Services: host.GetSystemdServiceNames(),
 			}
 		}
 
@@ -79,9 +187,10 @@ func main() {
 		hosts := make([]sudoers.HostServices, len(cfg.Hosts))
 		for i, host := range cfg.Hosts {
 			hosts[i] = sudoers.HostServices{
-				Name:     host.Name,
-				Address:  host.Address,
-				Services: host.SystemdServices,
+				Name:        host.Name,
+				Address:     host.Address,
+				Services:    host.GetSystemdServiceNames(),
+				JournalSudo: host.GetJournalAccess() == config.JournalAccessSudo,
 			}
 		}
 
@@ -89,14 +198,62 @@ func main() {
 		os.Exit(0)
 	}
 
+	log.Printf("Home Server Dashboard %s", version.Get())
 	log.Printf("Loaded config from %s with %d hosts", configPath, len(cfg.Hosts))
+	if keys := cfg.SecretKeys(); len(keys) > 0 {
+		log.Printf("Loaded %d values from %s", len(keys), config.SecretsPath(configPath))
+	}
+
+	// Configure circuit breakers for remote host calls
+	resilience.Configure(cfg.GetCircuitThreshold(), cfg.GetCircuitCooldown())
+
+	// Limit concurrent SSH sessions so remote sshd rate limits aren't hit
+	connlimit.Configure(cfg.GetSSHMaxSessionsPerHost(), cfg.GetSSHMaxSessions())
+
+	// Bound how long compose operations wait for another operation on the same project
+	locks.Configure(cfg.GetComposeLockWait())
+
+	// Handle self-test: exit non-zero if any integration check fails
+	if *selfTestFlag {
+		report := selftest.Run(context.Background(), selftest.BuildChecks(cfg), selftest.DefaultTimeout)
+		report.SecretKeys = cfg.SecretKeys()
+		report.ComposeRoots = selftest.ComposeRoots(cfg)
+		report.WriteTable(os.Stdout)
+		if !report.OK() {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// Run the SSH commands to each remote host over one shared connection
+	if cfg.GetSSHMultiplexing() {
+		if mux, err := sshmux.New(sshmux.SocketDir(), sshmux.DefaultIdle); err != nil {
+			log.Printf("Warning: SSH connections won't be shared: %v", err)
+		} else {
+			sshmux.Configure(mux)
+		}
+	}
+
+	// Honor forwarded client addresses and hosts only from trusted reverse proxies
+	realip.Configure(cfg.GetTrustedProxies())
+
+	// Find the dashboard's own unit or container so acting on it can be guarded
+	selfdetect.Set(detectSelf(cfg))
+
+	// Keep the problems found from here on for administrators to see
+	board := notices.NewStore(cfg.GetNoticeExpiryDays())
 
 	// Validate group configurations (log warnings for non-existent services)
-	cfg.ValidateGroupConfigs()
+	notices.PostAll(board, notices.SeverityWarning, "config", cfg.ValidateGroupConfigs())
 
 	// Create server config with embedded filesystems
 	serverCfg := server.DefaultConfig()
 	serverCfg.Port = fmt.Sprintf(":%d", cfg.GetPort())
+	serverCfg.ConfigPath = configPath
+	if *demoFlag {
+		serverCfg.ConfigPath = "" // Nothing to edit
+	}
+	serverCfg.AllowedOrigins = cfg.GetAllowedOrigins()
 	staticFS, err := getStaticFS()
 	if err != nil {
 		log.Fatalf("Failed to get embedded static filesystem: %v", err)
@@ -114,7 +271,11 @@ func main() {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
+		ctx = auth.WithHTTPClient(ctx, httpclient.New(cfg.OutboundFor(nil), 30*time.Second))
 		authProvider, err := auth.NewProvider(ctx, cfg.OIDC, cfg.Local)
+		if errors.Is(err, auth.ErrUsersFile) {
+			log.Fatalf("Failed to initialize local authentication: %v", err)
+		}
 		if err != nil {
 			log.Fatalf("Failed to initialize OIDC provider: %v", err)
 		}
@@ -123,6 +284,12 @@ func main() {
 		if cfg.Local != nil && cfg.Local.Admins != "" {
 			log.Printf("Local authentication configured for admins: %s", cfg.Local.Admins)
 		}
+		if cfg.Local != nil && cfg.Local.AdminGroup != "" {
+			log.Printf("Local authentication configured for members of group: %s", cfg.Local.AdminGroup)
+		}
+		if cfg.Local != nil && cfg.Local.UsersFile != "" {
+			log.Printf("Local authentication configured for users of %s", cfg.Local.UsersFile)
+		}
 	} else {
 		log.Printf("OIDC authentication not configured, running without authentication")
 	}
@@ -134,9 +301,32 @@ func main() {
 	wsHub := websocket.NewHub(eventBus)
 	wsHub.Start()
 	serverCfg.WebSocketHub = wsHub
+	serverCfg.Events = eventBus
+
+	// Mirror the events to other processes as JSON lines, if asked to
+	var exportSinks []eventexport.Sink
+	var stdoutEvents *eventexport.Writer
+	if *eventsStdoutFlag {
+		stdoutEvents = eventexport.NewWriter(os.Stdout)
+		exportSinks = append(exportSinks, stdoutEvents)
+	}
+	var eventSocket *eventexport.Socket
+	if cfg.EventExport != nil && cfg.EventExport.Socket != "" {
+		mode, _ := cfg.EventExport.GetSocketMode() // Checked when the config was loaded
+		socket, err := eventexport.Listen(cfg.EventExport.Socket, mode)
+		if err != nil {
+			log.Printf("Warning: events will not be exported: %v", err)
+			board.Post(notices.SeverityWarning, "startup", "startup:event_export", fmt.Sprintf("Events will not be exported: %v", err))
+		} else {
+			eventSocket = socket
+			exportSinks = append(exportSinks, socket)
+			log.Printf("Exporting events on %s", cfg.EventExport.Socket)
+		}
+	}
+	eventExporter := eventexport.Start(eventBus, exportSinks...)
 
 	// Initialize notifier manager
-	notifierMgr := notifiers.NewManager(eventBus)
+	notifierMgr := notifiers.NewManager(eventBus, notifiers.WithDigest(cfg.NotificationDigest.GetWindow(), cfg.NotificationDigest.GetThreshold()))
 
 	// Register Gotify notifier if configured
 	if gotifyNotifier := gotify.New(cfg.Gotify); gotifyNotifier != nil {
@@ -147,12 +337,90 @@ func main() {
 	}
 
 	// Initialize service monitor
-	serviceMonitor := monitor.New(cfg, eventBus)
+	serviceMonitor := monitor.New(cfg, eventBus, monitor.WithNotices(board))
 	serviceMonitor.Start()
+	serverCfg.StateTracker = serviceMonitor
+
+	// Keep the output of recent service actions, on disk if configured
+	history := actionhistory.NewStore(actionhistory.DefaultPerService, actionhistory.DefaultMaxOutput)
+	if cfg.ActionHistoryPath != "" {
+		opened, err := actionhistory.Open(cfg.ActionHistoryPath, actionhistory.DefaultPerService, actionhistory.DefaultMaxOutput)
+		if err != nil {
+			log.Printf("Warning: action history will not be saved: %v", err)
+			board.Post(notices.SeverityWarning, "startup", "startup:action_history", fmt.Sprintf("Action history will not be saved: %v", err))
+		} else {
+			history = opened
+			log.Printf("Saving action history to %s", cfg.ActionHistoryPath)
+		}
+	}
+	serverCfg.ActionHistory = history
+
+	// Forget the actions of services the monitor no longer sees
+	eventBus.Subscribe(events.ServiceRemoved, func(e events.Event) {
+		removed := e.(*events.ServiceRemovedEvent)
+		history.Forget(removed.Host, removed.ServiceName)
+	})
+
+	// Drop cached image details when images are pulled or removed outside
+	// the dashboard; a pull names a reference rather than an image ID
+	eventBus.Subscribe(events.DockerResourceChanged, func(e events.Event) {
+		changed := e.(*events.DockerResourceChangedEvent)
+		if changed.Resource != events.ResourceImage {
+			return
+		}
+		imageID := changed.ID
+		if changed.Action == "pull" {
+			imageID = ""
+		}
+		docker.InvalidateImageCache(changed.Host, imageID)
+	})
+
+	serverCfg.Streams = streams.New(cfg.GetMaxStreamsPerUser(), cfg.GetMaxStreams())
+
+	// Count log streams and actions per service, on disk if configured
+	usage := usagestats.NewStore(usagestats.DefaultRetentionDays)
+	if cfg.UsageStatsPath != "" {
+		opened, err := usagestats.Open(cfg.UsageStatsPath, usagestats.DefaultRetentionDays)
+		if err != nil {
+			log.Printf("Warning: usage stats will not be saved: %v", err)
+			board.Post(notices.SeverityWarning, "startup", "startup:usage_stats", fmt.Sprintf("Usage stats will not be saved: %v", err))
+		} else {
+			usage = opened
+			log.Printf("Saving usage stats to %s", cfg.UsageStatsPath)
+		}
+	}
+	history.OnFinish(usage.ActionFinished)
+	serverCfg.Streams.SetObserver(usage)
+	usage.Start(usagestats.DefaultFlushInterval)
+	serverCfg.UsageStats = usage
+	serverCfg.Notices = board
+	serverCfg.WarmupTimeout = cfg.GetWarmupTimeout()
+	serverCfg.WaitForWarmup = cfg.ReadyAfterWarmup
 
 	// Create and start server
 	srv := server.New(serverCfg)
 
+	// Advertise the dashboard on the LAN if configured
+	var advertiser *mdns.Advertiser
+	if cfg.AdvertiseMDNS {
+		hostname, _ := os.Hostname()
+		serviceURL := ""
+		if cfg.OIDC != nil {
+			serviceURL = cfg.OIDC.ServiceURL
+		}
+		advertiser, err = mdns.Start(mdns.Options{
+			Hostname:   hostname,
+			ListenAddr: serverCfg.Port,
+			ServiceURL: serviceURL,
+			Version:    version.Version,
+		}, mdns.NewResponder(hostname))
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		} else if advertiser != nil {
+			log.Printf("Advertising the dashboard with mDNS")
+		}
+	}
+
 	// Handle graceful shutdown
 	go func() {
 		sigCh := make(chan os.Signal, 1)
@@ -161,15 +429,38 @@ func main() {
 
 		log.Println("Shutting down...")
 
+		// Withdraw the mDNS advertisement before the server goes away
+		advertiser.Stop()
+
 		// Stop monitor first to prevent new events
 		serviceMonitor.Stop()
 
+		// Save the usage counted since the last flush
+		usage.Stop()
+
 		// Stop WebSocket hub
 		wsHub.Stop()
 
 		// Close notifier manager
 		notifierMgr.Close()
 
+		// Stop exporting events, writing out those already queued
+		eventExporter.Stop()
+		if eventSocket != nil {
+			eventSocket.Close()
+		}
+		if stdoutEvents != nil {
+			stdoutEvents.Close()
+		}
+
+		// Stop cleaning up the login stores
+		if authProvider, ok := serverCfg.AuthProvider.(*auth.Provider); ok {
+			authProvider.Close()
+		}
+
+		// Close the shared SSH connections
+		sshmux.Default().Close()
+
 		os.Exit(0)
 	}()
 
@@ -0,0 +1,134 @@
+// Package mdns advertises the dashboard on the local network with multicast
+// DNS service discovery (DNS-SD), so phones and laptops can find it without
+// remembering its address and port, and other instances can find each other.
+package mdns
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Service types the dashboard is advertised as.
+const (
+	// HTTPService is the type browsers and phone apps look for web servers by.
+	HTTPService = "_http._tcp"
+	// DashboardService is the type other dashboard instances look for.
+	DashboardService = "_home-server-dashboard._tcp"
+)
+
+// maxTXTLength is the longest string a TXT record can hold.
+const maxTXTLength = 255
+
+// Service is a DNS-SD service instance.
+type Service struct {
+	Instance string   // Instance name, e.g. "Home Server Dashboard on nas"
+	Type     string   // Service type, e.g. "_http._tcp"
+	Port     int      // Port the service listens on
+	Text     []string // TXT record strings, "key=value"
+}
+
+// Responder publishes services on the local network. It is replaced in
+// tests.
+type Responder interface {
+	// Register announces services and starts answering queries for them.
+	Register(services []Service) error
+	// Shutdown withdraws the services and stops answering queries.
+	Shutdown() error
+}
+
+// Options describe the dashboard to advertise.
+type Options struct {
+	Hostname   string // Name of this machine, which the instance is named after
+	ListenAddr string // Address the HTTP server listens on, e.g. ":9001"
+	ServiceURL string // Public URL of the dashboard, if known
+	Version    string // Version of the dashboard, for other instances
+}
+
+// Services returns the services to advertise for opts. It returns none if
+// the server only listens on a loopback address, which no other machine
+// could reach.
+func Services(opts Options) ([]Service, error) {
+	host, portText, err := net.SplitHostPort(opts.ListenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen address %q: %w", opts.ListenAddr, err)
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil || port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid listen address %q: bad port", opts.ListenAddr)
+	}
+	if isLoopback(host) {
+		return nil, nil
+	}
+
+	instance := "Home Server Dashboard"
+	if hostname, _, _ := strings.Cut(opts.Hostname, "."); hostname != "" {
+		instance += " on " + hostname
+	}
+	text := TXTRecords(opts.ServiceURL)
+	dashboardText := append(append([]string(nil), text...), "version="+opts.Version)
+	return []Service{
+		{Instance: instance, Type: HTTPService, Port: port, Text: text},
+		{Instance: instance, Type: DashboardService, Port: port, Text: dashboardText},
+	}, nil
+}
+
+// isLoopback reports whether host is a loopback name or address.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// TXTRecords returns the TXT strings of the dashboard: the path to open and,
+// if serviceURL is set, its host name and the URL itself. A URL too long for
+// a TXT string is left out.
+func TXTRecords(serviceURL string) []string {
+	text := []string{"path=/"}
+	u, err := url.Parse(serviceURL)
+	if serviceURL == "" || err != nil || u.Host == "" {
+		return text
+	}
+	text = append(text, "host="+u.Hostname())
+	if entry := "url=" + serviceURL; len(entry) <= maxTXTLength {
+		text = append(text, entry)
+	}
+	return text
+}
+
+// Advertiser keeps the dashboard advertised until it is stopped.
+type Advertiser struct {
+	responder Responder
+	stopOnce  sync.Once
+	stopErr   error
+}
+
+// Start advertises the services of opts with responder. It returns a nil
+// Advertiser if there is nothing to advertise.
+func Start(opts Options, responder Responder) (*Advertiser, error) {
+	services, err := Services(opts)
+	if err != nil || len(services) == 0 {
+		return nil, err
+	}
+	if err := responder.Register(services); err != nil {
+		return nil, fmt.Errorf("failed to advertise the dashboard: %w", err)
+	}
+	return &Advertiser{responder: responder}, nil
+}
+
+// Stop withdraws the advertisement. It is safe to call on a nil Advertiser
+// and more than once.
+func (a *Advertiser) Stop() error {
+	if a == nil {
+		return nil
+	}
+	a.stopOnce.Do(func() {
+		a.stopErr = a.responder.Shutdown()
+	})
+	return a.stopErr
+}
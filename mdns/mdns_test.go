@@ -0,0 +1,137 @@
+package mdns
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTXTRecords(t *testing.T) {
+	tests := []struct {
+		serviceURL string
+		want       []string
+	}{
+		{"", []string{"path=/"}},
+		{"https://dash.example.com", []string{"path=/", "host=dash.example.com", "url=https://dash.example.com"}},
+		{"https://dash.example.com:8443/home", []string{"path=/", "host=dash.example.com", "url=https://dash.example.com:8443/home"}},
+		{"not a url", []string{"path=/"}},
+		// Too long for a TXT string: the host name is still there
+		{"https://dash.example.com/" + strings.Repeat("a", 300), []string{"path=/", "host=dash.example.com"}},
+	}
+
+	for _, tt := range tests {
+		if got := TXTRecords(tt.serviceURL); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("TXTRecords(%.40q) = %q, want %q", tt.serviceURL, got, tt.want)
+		}
+	}
+}
+
+func TestServices(t *testing.T) {
+	svcs, err := Services(Options{Hostname: "nas", ListenAddr: ":9001", ServiceURL: "https://dash.example.com", Version: "1.2.0"})
+	if err != nil {
+		t.Fatalf("Services() = %v", err)
+	}
+	if len(svcs) != 2 || svcs[0].Type != HTTPService || svcs[1].Type != DashboardService {
+		t.Fatalf("Services() = %+v, want the HTTP and dashboard services", svcs)
+	}
+	for _, svc := range svcs {
+		if svc.Instance != "Home Server Dashboard on nas" || svc.Port != 9001 {
+			t.Errorf("service = %+v", svc)
+		}
+	}
+	if want := []string{"path=/", "host=dash.example.com", "url=https://dash.example.com"}; !reflect.DeepEqual(svcs[0].Text, want) {
+		t.Errorf("HTTP TXT = %q, want %q", svcs[0].Text, want)
+	}
+	if got := svcs[1].Text[len(svcs[1].Text)-1]; got != "version=1.2.0" {
+		t.Errorf("dashboard TXT ends with %q, want the version", got)
+	}
+}
+
+func TestServices_ListenAddress(t *testing.T) {
+	tests := []struct {
+		addr      string
+		advertise bool
+		wantErr   bool
+	}{
+		{":9001", true, false},
+		{"0.0.0.0:9001", true, false},
+		{"192.168.1.10:9001", true, false},
+		{"[::]:9001", true, false},
+		{"127.0.0.1:9001", false, false},
+		{"[::1]:9001", false, false},
+		{"localhost:9001", false, false},
+		{"9001", false, true},
+		{":0", false, true},
+	}
+
+	for _, tt := range tests {
+		svcs, err := Services(Options{ListenAddr: tt.addr})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Services(%s) error = %v, want error %v", tt.addr, err, tt.wantErr)
+		}
+		if (len(svcs) > 0) != tt.advertise {
+			t.Errorf("Services(%s) = %d services, want advertised %v", tt.addr, len(svcs), tt.advertise)
+		}
+	}
+}
+
+// fakeResponder records what it is asked to do.
+type fakeResponder struct {
+	registered  []Service
+	registerErr error
+	shutdowns   int
+}
+
+func (f *fakeResponder) Register(services []Service) error {
+	if f.registerErr != nil {
+		return f.registerErr
+	}
+	f.registered = services
+	return nil
+}
+
+func (f *fakeResponder) Shutdown() error {
+	f.shutdowns++
+	return nil
+}
+
+func TestAdvertiser_Lifecycle(t *testing.T) {
+	responder := &fakeResponder{}
+	advertiser, err := Start(Options{Hostname: "nas", ListenAddr: ":9001"}, responder)
+	if err != nil || advertiser == nil {
+		t.Fatalf("Start() = %v, %v", advertiser, err)
+	}
+	if len(responder.registered) != 2 {
+		t.Errorf("registered %d services, want 2", len(responder.registered))
+	}
+
+	// Stopping twice withdraws it once
+	advertiser.Stop()
+	advertiser.Stop()
+	if responder.shutdowns != 1 {
+		t.Errorf("Shutdown() called %d times, want once", responder.shutdowns)
+	}
+}
+
+func TestAdvertiser_Loopback(t *testing.T) {
+	responder := &fakeResponder{}
+	advertiser, err := Start(Options{ListenAddr: "127.0.0.1:9001"}, responder)
+	if err != nil || advertiser != nil {
+		t.Fatalf("Start() = %v, %v, want nothing advertised", advertiser, err)
+	}
+	if responder.registered != nil {
+		t.Errorf("registered %+v on loopback", responder.registered)
+	}
+	// A nil Advertiser can be stopped like any other
+	if err := advertiser.Stop(); err != nil {
+		t.Errorf("Stop() = %v", err)
+	}
+}
+
+func TestAdvertiser_RegisterFails(t *testing.T) {
+	responder := &fakeResponder{registerErr: errors.New("address in use")}
+	if _, err := Start(Options{ListenAddr: ":9001"}, responder); err == nil {
+		t.Error("Start() = nil error, want the responder's error")
+	}
+}
@@ -0,0 +1,384 @@
+package mdns
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/ipv4"
+)
+
+// Record lifetimes, as RFC 6762 recommends: records naming a host live
+// shorter than the others, since addresses change more often.
+const (
+	hostTTL    = 120  // A and SRV records, in seconds
+	serviceTTL = 4500 // PTR and TXT records, in seconds
+	// legacyTTL caps the lifetimes in answers to plain DNS resolvers,
+	// which don't see the goodbye when the dashboard stops.
+	legacyTTL = 10
+)
+
+// cacheFlush marks a record as the only one of its name and type, so
+// receivers replace what they have cached. In a question the same bit asks
+// for a unicast answer.
+const cacheFlush = 1 << 15
+
+// mdnsPort is the port mDNS queries come from; legacy resolvers use others.
+const mdnsPort = 5353
+
+// groupAddr is the IPv4 mDNS multicast group.
+var groupAddr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: mdnsPort}
+
+// servicesName is the name that lists every service type on the network.
+const servicesName = "_services._dns-sd._udp.local."
+
+// zone is the records a responder answers queries with.
+type zone struct {
+	host      dnsmessage.Name
+	addresses []dnsmessage.Resource // A records of the host
+	types     []dnsmessage.Resource // PTR records of servicesName
+	services  []serviceRecords
+}
+
+// serviceRecords are the records of one service instance.
+type serviceRecords struct {
+	ptr, srv, txt dnsmessage.Resource
+}
+
+// newZone builds the records of services on hostname, which has the
+// addresses ips.
+func newZone(hostname string, ips []net.IP, services []Service) (*zone, error) {
+	host, err := dnsName(hostname + ".local.")
+	if err != nil {
+		return nil, err
+	}
+	z := &zone{host: host}
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			z.addresses = append(z.addresses, dnsmessage.Resource{
+				Header: header(host, dnsmessage.TypeA, hostTTL, true),
+				Body:   &dnsmessage.AResource{A: [4]byte(ip4)},
+			})
+		}
+	}
+
+	seenTypes := make(map[string]bool)
+	for _, svc := range services {
+		typeName, err := dnsName(svc.Type + ".local.")
+		if err != nil {
+			return nil, err
+		}
+		instance, err := dnsName(strings.ReplaceAll(svc.Instance, ".", "-") + "." + svc.Type + ".local.")
+		if err != nil {
+			return nil, err
+		}
+		if !seenTypes[svc.Type] {
+			seenTypes[svc.Type] = true
+			z.types = append(z.types, dnsmessage.Resource{
+				Header: header(dnsmessage.MustNewName(servicesName), dnsmessage.TypePTR, serviceTTL, false),
+				Body:   &dnsmessage.PTRResource{PTR: typeName},
+			})
+		}
+		z.services = append(z.services, serviceRecords{
+			ptr: dnsmessage.Resource{
+				Header: header(typeName, dnsmessage.TypePTR, serviceTTL, false),
+				Body:   &dnsmessage.PTRResource{PTR: instance},
+			},
+			srv: dnsmessage.Resource{
+				Header: header(instance, dnsmessage.TypeSRV, hostTTL, true),
+				Body:   &dnsmessage.SRVResource{Port: uint16(svc.Port), Target: host},
+			},
+			txt: dnsmessage.Resource{
+				Header: header(instance, dnsmessage.TypeTXT, serviceTTL, true),
+				Body:   &dnsmessage.TXTResource{TXT: svc.Text},
+			},
+		})
+	}
+	return z, nil
+}
+
+// dnsName converts name to a DNS name, checking its length.
+func dnsName(name string) (dnsmessage.Name, error) {
+	n, err := dnsmessage.NewName(name)
+	if err != nil {
+		return n, fmt.Errorf("invalid mDNS name %q: %w", name, err)
+	}
+	return n, nil
+}
+
+// header returns a resource header of the internet class.
+func header(name dnsmessage.Name, typ dnsmessage.Type, ttl uint32, unique bool) dnsmessage.ResourceHeader {
+	class := dnsmessage.ClassINET
+	if unique {
+		class |= cacheFlush
+	}
+	return dnsmessage.ResourceHeader{Name: name, Type: typ, Class: class, TTL: ttl}
+}
+
+// answer returns the records answering questions and the additional records
+// that save the asker further queries: the SRV, TXT and address records of
+// the services it browses for.
+func (z *zone) answer(questions []dnsmessage.Question) (answers, additionals []dnsmessage.Resource) {
+	var set recordSet
+	asks := func(q dnsmessage.Question, name dnsmessage.Name, typ dnsmessage.Type) bool {
+		return (q.Type == typ || q.Type == dnsmessage.TypeALL) && strings.EqualFold(q.Name.String(), name.String())
+	}
+
+	for _, q := range questions {
+		for _, t := range z.types {
+			if asks(q, t.Header.Name, dnsmessage.TypePTR) {
+				set.add(&answers, t)
+			}
+		}
+		for _, svc := range z.services {
+			if asks(q, svc.ptr.Header.Name, dnsmessage.TypePTR) {
+				set.add(&answers, svc.ptr)
+				set.add(&additionals, svc.srv, svc.txt)
+				set.add(&additionals, z.addresses...)
+			}
+			if asks(q, svc.srv.Header.Name, dnsmessage.TypeSRV) {
+				set.add(&answers, svc.srv)
+				set.add(&additionals, z.addresses...)
+			}
+			if asks(q, svc.txt.Header.Name, dnsmessage.TypeTXT) {
+				set.add(&answers, svc.txt)
+			}
+		}
+		if asks(q, z.host, dnsmessage.TypeA) {
+			set.add(&answers, z.addresses...)
+		}
+	}
+	return answers, additionals
+}
+
+// all returns every record, to announce them.
+func (z *zone) all() []dnsmessage.Resource {
+	records := append([]dnsmessage.Resource(nil), z.types...)
+	for _, svc := range z.services {
+		records = append(records, svc.ptr, svc.srv, svc.txt)
+	}
+	return append(records, z.addresses...)
+}
+
+// recordSet keeps a record from being added to a response twice.
+type recordSet map[string]bool
+
+func (s *recordSet) add(list *[]dnsmessage.Resource, records ...dnsmessage.Resource) {
+	if *s == nil {
+		*s = make(recordSet)
+	}
+	for _, r := range records {
+		key := r.GoString()
+		if !(*s)[key] {
+			(*s)[key] = true
+			*list = append(*list, r)
+		}
+	}
+}
+
+// respond returns the response to the mDNS message query, or nil if it
+// isn't a query this zone answers. Legacy queries, from ports other than
+// 5353, get a plain DNS response that repeats the question.
+func (z *zone) respond(query []byte, legacy bool) []byte {
+	var p dnsmessage.Parser
+	h, err := p.Start(query)
+	if err != nil || h.Response {
+		return nil
+	}
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return nil
+	}
+	for i := range questions {
+		questions[i].Class &^= cacheFlush
+	}
+	answers, additionals := z.answer(questions)
+	if len(answers) == 0 {
+		return nil
+	}
+
+	msg := dnsmessage.Message{
+		Header:      dnsmessage.Header{Response: true, Authoritative: true},
+		Answers:     answers,
+		Additionals: additionals,
+	}
+	if legacy {
+		msg.Header.ID = h.ID
+		msg.Questions = questions
+		for _, list := range [][]dnsmessage.Resource{msg.Answers, msg.Additionals} {
+			for i := range list {
+				list[i].Header.Class &^= cacheFlush
+				list[i].Header.TTL = min(list[i].Header.TTL, legacyTTL)
+			}
+		}
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil
+	}
+	return packed
+}
+
+// announcement returns an unsolicited response carrying every record, with
+// their lifetimes set to ttl if it isn't negative. A ttl of 0 says goodbye.
+func (z *zone) announcement(ttl int) ([]byte, error) {
+	records := z.all()
+	if ttl >= 0 {
+		for i := range records {
+			records[i].Header.TTL = uint32(ttl)
+		}
+	}
+	msg := dnsmessage.Message{
+		Header:  dnsmessage.Header{Response: true, Authoritative: true},
+		Answers: records,
+	}
+	return msg.Pack()
+}
+
+// multicastResponder is a minimal mDNS responder (RFC 6762) on the IPv4
+// multicast group. It announces its services when registered, answers
+// queries for them and says goodbye when shut down.
+type multicastResponder struct {
+	hostname string
+
+	mu   sync.Mutex
+	conn *net.UDPConn
+	zone *zone
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewResponder returns a Responder that advertises services on the local
+// network with multicast DNS, naming this machine hostname.local.
+func NewResponder(hostname string) Responder {
+	hostname, _, _ = strings.Cut(hostname, ".")
+	return &multicastResponder{hostname: hostname}
+}
+
+// Register implements Responder.
+func (r *multicastResponder) Register(services []Service) error {
+	ips, err := localAddresses()
+	if err != nil {
+		return err
+	}
+	if len(ips) == 0 {
+		return errors.New("no network interface with an IPv4 address")
+	}
+	z, err := newZone(r.hostname, ips, services)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, groupAddr)
+	if err != nil {
+		return fmt.Errorf("failed to join the mDNS group: %w", err)
+	}
+	// mDNS packets are sent with a TTL of 255 (RFC 6762 section 11)
+	ipv4.NewPacketConn(conn).SetMulticastTTL(255)
+
+	r.mu.Lock()
+	r.conn, r.zone, r.done = conn, z, make(chan struct{})
+	r.mu.Unlock()
+
+	r.wg.Add(2)
+	go r.serve(conn, z)
+	go r.announce(conn, z, r.done)
+	return nil
+}
+
+// serve answers the queries received on conn until it is closed.
+func (r *multicastResponder) serve(conn *net.UDPConn, z *zone) {
+	defer r.wg.Done()
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		legacy := src.Port != mdnsPort
+		response := z.respond(buf[:n], legacy)
+		if response == nil {
+			continue
+		}
+		dest := groupAddr
+		if legacy {
+			dest = src
+		}
+		conn.WriteToUDP(response, dest)
+	}
+}
+
+// announce sends the records twice, a second apart, as RFC 6762 asks.
+func (r *multicastResponder) announce(conn *net.UDPConn, z *zone, done <-chan struct{}) {
+	defer r.wg.Done()
+	packed, err := z.announcement(-1)
+	if err != nil {
+		log.Printf("mDNS: failed to build announcement: %v", err)
+		return
+	}
+	for i := 0; i < 2; i++ {
+		if i > 0 {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Second):
+			}
+		}
+		conn.WriteToUDP(packed, groupAddr)
+	}
+}
+
+// Shutdown implements Responder.
+func (r *multicastResponder) Shutdown() error {
+	r.mu.Lock()
+	conn, z, done := r.conn, r.zone, r.done
+	r.conn = nil
+	r.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+
+	close(done)
+	if goodbye, err := z.announcement(0); err == nil {
+		conn.WriteToUDP(goodbye, groupAddr)
+	}
+	err := conn.Close()
+	r.wg.Wait()
+	return err
+}
+
+// localAddresses returns the IPv4 addresses of the network interfaces that
+// are up and can multicast. Loopback interfaces and Docker's bridges are
+// left out, since other machines can't reach the dashboard through them.
+func localAddresses() ([]net.IP, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IP
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if strings.HasPrefix(iface.Name, "docker") || strings.HasPrefix(iface.Name, "br-") || strings.HasPrefix(iface.Name, "veth") {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+				ips = append(ips, ipNet.IP.To4())
+			}
+		}
+	}
+	return ips, nil
+}
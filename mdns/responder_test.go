@@ -0,0 +1,139 @@
+package mdns
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func testZone(t *testing.T) *zone {
+	t.Helper()
+	svcs, err := Services(Options{Hostname: "nas", ListenAddr: ":9001", ServiceURL: "https://dash.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	z, err := newZone("nas", []net.IP{net.ParseIP("192.168.1.10")}, svcs)
+	if err != nil {
+		t.Fatalf("newZone() = %v", err)
+	}
+	return z
+}
+
+// query packs an mDNS query for name and type, from the mDNS port unless legacy.
+func query(t *testing.T, name string, typ dnsmessage.Type) []byte {
+	t.Helper()
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: 42},
+		Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName(name), Type: typ, Class: dnsmessage.ClassINET | cacheFlush}},
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packed
+}
+
+func parse(t *testing.T, packed []byte) dnsmessage.Message {
+	t.Helper()
+	var msg dnsmessage.Message
+	if err := msg.Unpack(packed); err != nil {
+		t.Fatalf("Unpack() = %v", err)
+	}
+	return msg
+}
+
+func TestZone_RespondBrowse(t *testing.T) {
+	z := testZone(t)
+
+	msg := parse(t, z.respond(query(t, "_http._tcp.local.", dnsmessage.TypePTR), false))
+	if !msg.Header.Response || msg.Header.ID != 0 || len(msg.Questions) != 0 {
+		t.Errorf("header = %+v, questions = %d, want a multicast response", msg.Header, len(msg.Questions))
+	}
+	if len(msg.Answers) != 1 {
+		t.Fatalf("answers = %v, want the PTR", msg.Answers)
+	}
+	if ptr := msg.Answers[0].Body.(*dnsmessage.PTRResource); ptr.PTR.String() != "Home Server Dashboard on nas._http._tcp.local." {
+		t.Errorf("PTR = %s", ptr.PTR)
+	}
+
+	// The SRV, TXT and address come along so the browser needn't ask
+	types := make(map[dnsmessage.Type]dnsmessage.Resource)
+	for _, r := range msg.Additionals {
+		types[r.Header.Type] = r
+	}
+	srv, ok := types[dnsmessage.TypeSRV].Body.(*dnsmessage.SRVResource)
+	if !ok || srv.Port != 9001 || srv.Target.String() != "nas.local." {
+		t.Errorf("SRV = %+v", types[dnsmessage.TypeSRV].Body)
+	}
+	txt, ok := types[dnsmessage.TypeTXT].Body.(*dnsmessage.TXTResource)
+	if !ok || len(txt.TXT) != 3 || txt.TXT[1] != "host=dash.example.com" {
+		t.Errorf("TXT = %+v", types[dnsmessage.TypeTXT].Body)
+	}
+	if a, ok := types[dnsmessage.TypeA].Body.(*dnsmessage.AResource); !ok || net.IP(a.A[:]).String() != "192.168.1.10" {
+		t.Errorf("A = %+v", types[dnsmessage.TypeA].Body)
+	}
+	if len(msg.Additionals) != 3 {
+		t.Errorf("additionals = %d, want SRV, TXT and A once each", len(msg.Additionals))
+	}
+}
+
+func TestZone_RespondOtherQueries(t *testing.T) {
+	z := testZone(t)
+
+	// Service type enumeration lists both types
+	msg := parse(t, z.respond(query(t, servicesName, dnsmessage.TypePTR), false))
+	if len(msg.Answers) != 2 {
+		t.Errorf("service types = %v, want 2", msg.Answers)
+	}
+
+	// Host names are matched regardless of case
+	msg = parse(t, z.respond(query(t, "NAS.local.", dnsmessage.TypeA), false))
+	if len(msg.Answers) != 1 || msg.Answers[0].Header.Type != dnsmessage.TypeA {
+		t.Errorf("A answers = %v", msg.Answers)
+	}
+
+	// Names and types this responder doesn't own get no response
+	if got := z.respond(query(t, "printer.local.", dnsmessage.TypeA), false); got != nil {
+		t.Errorf("respond(printer.local.) = %d bytes, want none", len(got))
+	}
+	if got := z.respond(query(t, "nas.local.", dnsmessage.TypeAAAA), false); got != nil {
+		t.Errorf("respond(AAAA) = %d bytes, want none", len(got))
+	}
+}
+
+func TestZone_RespondLegacy(t *testing.T) {
+	z := testZone(t)
+
+	msg := parse(t, z.respond(query(t, "nas.local.", dnsmessage.TypeA), true))
+	if msg.Header.ID != 42 || len(msg.Questions) != 1 {
+		t.Errorf("header = %+v, questions = %d, want the query's ID and question", msg.Header, len(msg.Questions))
+	}
+	if h := msg.Answers[0].Header; h.TTL > legacyTTL || h.Class != dnsmessage.ClassINET {
+		t.Errorf("answer header = %+v, want a short TTL and no cache-flush bit", h)
+	}
+}
+
+func TestZone_Goodbye(t *testing.T) {
+	z := testZone(t)
+
+	packed, err := z.announcement(0)
+	if err != nil {
+		t.Fatalf("announcement() = %v", err)
+	}
+	msg := parse(t, packed)
+	if len(msg.Answers) != len(z.all()) {
+		t.Errorf("goodbye has %d records, want %d", len(msg.Answers), len(z.all()))
+	}
+	for _, r := range msg.Answers {
+		if r.Header.TTL != 0 {
+			t.Errorf("%s %s TTL = %d, want 0", r.Header.Name, r.Header.Type, r.Header.TTL)
+		}
+	}
+}
+
+func TestMulticastResponder_ShutdownWithoutRegister(t *testing.T) {
+	if err := NewResponder("nas").Shutdown(); err != nil {
+		t.Errorf("Shutdown() = %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package monitor
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"home_server_dashboard/events"
+	"home_server_dashboard/services"
+)
+
+// intentTTL is how long the monitor waits for a service to make the
+// transition an action from the dashboard asked for.
+const intentTTL = 5 * time.Minute
+
+// ActionIntent records that a user asked the dashboard to stop or restart a
+// service, so the transition that follows isn't reported as a failure.
+type ActionIntent struct {
+	Host      string    `json:"host"`
+	Service   string    `json:"service"`
+	Action    string    `json:"action"`     // "stop" or "restart"
+	User      string    `json:"user"`       // Who asked for it
+	ExpiresAt time.Time `json:"expires_at"` // When an unfulfilled intent is dropped
+	// Fulfilled is set once the service went down. A fulfilled stop lasts
+	// until the service starts again; a restart until it is back up.
+	Fulfilled bool `json:"fulfilled"`
+}
+
+// intentVerdict is what updateServiceState does with a transition.
+type intentVerdict int
+
+const (
+	intentNone     intentVerdict = iota // Not expected: report as usual
+	intentExpected                      // Expected stop: report quietly
+	intentSwallow                       // Part of an expected restart: don't report
+)
+
+// ExpectAction records that user is about to stop or restart a service, so
+// the service going down is not notified about. Other actions are ignored.
+// Implements handlers.IntentRecorder.
+func (m *Monitor) ExpectAction(host, serviceName, action, user string) {
+	if action != "stop" && action != "restart" {
+		return
+	}
+	key := serviceKey(host, serviceName)
+
+	m.intentMu.Lock()
+	defer m.intentMu.Unlock()
+	m.intents[key] = &ActionIntent{
+		Host:      host,
+		Service:   serviceName,
+		Action:    action,
+		User:      user,
+		ExpiresAt: m.now().Add(intentTTL),
+	}
+	log.Printf("Monitor: expecting %s of %s on %s (by %s)", action, serviceName, host, user)
+}
+
+// CancelExpectedAction drops the intent recorded for a service, e.g. because
+// the action failed. Implements handlers.IntentRecorder.
+func (m *Monitor) CancelExpectedAction(host, serviceName string) {
+	m.intentMu.Lock()
+	defer m.intentMu.Unlock()
+	delete(m.intents, serviceKey(host, serviceName))
+}
+
+// Intents returns the recorded intents that are still in effect, sorted by
+// host and service. Implements handlers.IntentReporter.
+func (m *Monitor) Intents() []ActionIntent {
+	m.intentMu.Lock()
+	defer m.intentMu.Unlock()
+
+	now := m.now()
+	result := []ActionIntent{}
+	for key, intent := range m.intents {
+		if intentExpired(intent, now) {
+			delete(m.intents, key)
+			continue
+		}
+		result = append(result, *intent)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Host != result[j].Host {
+			return result[i].Host < result[j].Host
+		}
+		return result[i].Service < result[j].Service
+	})
+	return result
+}
+
+// intentExpired returns true if intent no longer applies at now. A fulfilled
+// stop doesn't expire, since the service stays down until something starts it.
+func intentExpired(intent *ActionIntent, now time.Time) bool {
+	if intent.Action == "stop" && intent.Fulfilled {
+		return false
+	}
+	return !now.Before(intent.ExpiresAt)
+}
+
+// matchIntent decides how a transition of the service key is reported given
+// the intent recorded for it, and updates the intent. For an expected stop it
+// also returns the status to show instead of the provider's. Called with
+// m.mu held.
+//
+// A stop is fulfilled by the service going down and cleared by it coming up
+// again, expected or not. A restart swallows every transition until the
+// service is running again, which clears it.
+func (m *Monitor) matchIntent(key services.Key, newState services.State) (intentVerdict, string) {
+	m.intentMu.Lock()
+	defer m.intentMu.Unlock()
+
+	intent, ok := m.intents[key]
+	if !ok {
+		return intentNone, ""
+	}
+	if intentExpired(intent, m.now()) {
+		delete(m.intents, key)
+		return intentNone, ""
+	}
+
+	switch intent.Action {
+	case "stop":
+		if newState.Up() && newState != services.StateStopping {
+			delete(m.intents, key)
+			return intentNone, ""
+		}
+		if newState.Up() {
+			return intentExpected, "" // Still stopping
+		}
+		intent.Fulfilled = true
+		return intentExpected, "stopped (by " + intent.User + ")"
+	case "restart":
+		if newState.Transitional() || !newState.Up() {
+			if !newState.Up() {
+				intent.Fulfilled = true
+			}
+			return intentSwallow, ""
+		}
+		delete(m.intents, key)
+		if newState == services.StateRunning {
+			return intentSwallow, ""
+		}
+		// Back up but not well: worth reporting
+		return intentNone, ""
+	}
+	return intentNone, ""
+}
+
+// stoppedStatus returns the status of a service that was stopped on purpose
+// and hasn't come back, or "" if it wasn't.
+func (m *Monitor) stoppedStatus(key services.Key) string {
+	m.intentMu.Lock()
+	defer m.intentMu.Unlock()
+
+	if intent, ok := m.intents[key]; ok && intent.Action == "stop" && intent.Fulfilled {
+		return "stopped (by " + intent.User + ")"
+	}
+	return ""
+}
+
+// consumeRestartIntent clears an expected restart of key, returning true if
+// there was one. Used for Docker restarts fast enough that the container
+// never appeared to go down.
+func (m *Monitor) consumeRestartIntent(key services.Key) bool {
+	m.intentMu.Lock()
+	defer m.intentMu.Unlock()
+
+	intent, ok := m.intents[key]
+	if !ok || intent.Action != "restart" || intentExpired(intent, m.now()) {
+		return false
+	}
+	delete(m.intents, key)
+	return true
+}
+
+// queueRestartNotification holds back the down transition of an expected
+// restart until the intent expires, so a service that doesn't come back is
+// still reported.
+func (m *Monitor) queueRestartNotification(key services.Key, event *events.ServiceStateChangedEvent) {
+	m.intentMu.Lock()
+	expires := m.now().Add(intentTTL)
+	if intent, ok := m.intents[key]; ok {
+		expires = intent.ExpiresAt
+	}
+	m.intentMu.Unlock()
+
+	m.pendingMu.Lock()
+	defer m.pendingMu.Unlock()
+	if pending, exists := m.pendingNotifications[key]; exists && !pending.Cancelled {
+		return // Keep the first down transition
+	}
+	m.pendingNotifications[key] = &PendingNotification{Event: event, ExpiresAt: expires}
+}
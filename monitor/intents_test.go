@@ -0,0 +1,168 @@
+package monitor
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/events"
+	"home_server_dashboard/services"
+)
+
+// newIntentTestMonitor creates a monitor with nginx already discovered as
+// running and a clock the test moves.
+func newIntentTestMonitor() (*Monitor, *eventRecorder, *time.Time) {
+	bus := events.NewBus(false)
+	m := New(&config.Config{}, bus, WithSkipFirstEvent(false), WithRestartDebounce(0))
+	clock := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return clock }
+	m.updateServiceState(services.ServiceInfo{Name: "nginx", Host: "nas", Source: "docker", State: services.StateRunning, Status: "Up"})
+	return m, recordEvents(bus), &clock
+}
+
+// transitions formats the state changes rec saw, marking the ones that notify.
+func transitions(rec *eventRecorder) []string {
+	var got []string
+	for _, e := range rec.all() {
+		switch e := e.(type) {
+		case *events.ServiceStateChangedEvent:
+			got = append(got, fmt.Sprintf("%s→%s quiet=%v", e.PreviousState, e.CurrentState, e.Quiet))
+		case *events.ServiceRestartedEvent:
+			got = append(got, "restarted")
+		}
+	}
+	return got
+}
+
+// setNginxState reports nginx in state with status.
+func setNginxState(m *Monitor, state services.State, status string) {
+	m.updateServiceState(services.ServiceInfo{Name: "nginx", Host: "nas", Source: "docker", State: state, Status: status})
+}
+
+func TestIntent_StopIsNotNotified(t *testing.T) {
+	m, rec, _ := newIntentTestMonitor()
+
+	m.ExpectAction("nas", "nginx", "stop", "alice")
+	setNginxState(m, services.StateStopping, "stopping")
+	setNginxState(m, services.StateStopped, "Exited (0)")
+
+	want := []string{"running→stopping quiet=true", "stopping→stopped quiet=true"}
+	if got := transitions(rec); !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+	if state, _ := m.GetServiceState("nas", "nginx"); state.Status != "stopped (by alice)" {
+		t.Errorf("status = %q, want stopped (by alice)", state.Status)
+	}
+
+	// Polls keep the status while it stays down
+	setNginxState(m, services.StateStopped, "Exited (0) 5 minutes ago")
+	if state, _ := m.GetServiceState("nas", "nginx"); state.Status != "stopped (by alice)" {
+		t.Errorf("status after a poll = %q, want stopped (by alice)", state.Status)
+	}
+	if got := m.Intents(); len(got) != 1 || !got[0].Fulfilled || got[0].User != "alice" {
+		t.Errorf("Intents() = %+v, want alice's fulfilled stop", got)
+	}
+
+	// Starting again clears it, and a later crash alerts
+	setNginxState(m, services.StateRunning, "Up")
+	if got := m.Intents(); len(got) != 0 {
+		t.Errorf("Intents() after a start = %+v, want none", got)
+	}
+	setNginxState(m, services.StateStopped, "Exited (1)")
+	if got := transitions(rec); got[len(got)-1] != "running→stopped quiet=false" {
+		t.Errorf("crash after the stop: events = %q", got)
+	}
+}
+
+func TestIntent_CrashWithoutIntentIsNotified(t *testing.T) {
+	m, rec, clock := newIntentTestMonitor()
+
+	// Another service's intent doesn't cover nginx
+	m.ExpectAction("nas", "redis", "stop", "alice")
+	setNginxState(m, services.StateStopped, "Exited (137)")
+
+	want := []string{"running→stopped quiet=false"}
+	if got := transitions(rec); !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+	if state, _ := m.GetServiceState("nas", "nginx"); state.Status != "Exited (137)" {
+		t.Errorf("status = %q, want the provider's", state.Status)
+	}
+
+	// Nor does an intent that expired before the service went down
+	setNginxState(m, services.StateRunning, "Up")
+	m.ExpectAction("nas", "nginx", "stop", "alice")
+	*clock = clock.Add(intentTTL)
+	setNginxState(m, services.StateStopped, "Exited (137)")
+	if got := transitions(rec); got[len(got)-1] != "running→stopped quiet=false" {
+		t.Errorf("events after the intent expired = %q", got)
+	}
+}
+
+func TestIntent_RestartSwallowsDownAndUp(t *testing.T) {
+	m, rec, _ := newIntentTestMonitor()
+
+	m.ExpectAction("nas", "nginx", "restart", "alice")
+	m.handleDockerEvent("nas", dockerEvent("die", "nginx", map[string]string{"exitCode": "0"}))
+	m.handleDockerEvent("nas", dockerEvent("start", "nginx", nil))
+
+	if got := transitions(rec); len(got) != 0 {
+		t.Errorf("events = %q, want none", got)
+	}
+	if state, _ := m.GetServiceState("nas", "nginx"); state.State != services.StateRunning {
+		t.Errorf("state = %s, want running", state.State)
+	}
+	if got := m.Intents(); len(got) != 0 {
+		t.Errorf("Intents() after the restart = %+v, want none", got)
+	}
+	if n := m.GetPendingNotificationCount(); n != 0 {
+		t.Errorf("%d pending notifications, want none", n)
+	}
+}
+
+func TestIntent_RestartWithinDebounce(t *testing.T) {
+	m, rec := newDockerTestMonitor(time.Second)
+
+	m.ExpectAction("nas", "nginx", "restart", "alice")
+	m.handleDockerEvent("nas", dockerEvent("die", "nginx", map[string]string{"exitCode": "0"}))
+	m.handleDockerEvent("nas", dockerEvent("start", "nginx", nil))
+	m.handleDockerEvent("nas", dockerEvent("restart", "nginx", nil))
+
+	if got := transitions(rec); len(got) != 0 {
+		t.Errorf("events = %q, want none", got)
+	}
+}
+
+func TestIntent_RestartThatStaysDownIsNotified(t *testing.T) {
+	m, rec, clock := newIntentTestMonitor()
+
+	m.ExpectAction("nas", "nginx", "restart", "alice")
+	setNginxState(m, services.StateStopped, "Exited (1)")
+	m.checkPendingNotifications()
+	if got := transitions(rec); len(got) != 0 {
+		t.Fatalf("events before the intent expired = %q, want none", got)
+	}
+
+	*clock = clock.Add(intentTTL + time.Second)
+	m.checkPendingNotifications()
+	want := []string{"running→stopped quiet=false"}
+	if got := transitions(rec); !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestIntent_ForgottenWithService(t *testing.T) {
+	m, _, _ := newIntentTestMonitor()
+
+	m.ExpectAction("nas", "nginx", "stop", "alice")
+	m.ExpectAction("nas", "nginx", "start", "alice") // Ignored
+	if got := m.Intents(); len(got) != 1 || got[0].Action != "stop" {
+		t.Fatalf("Intents() = %+v, want one stop", got)
+	}
+	m.forgetService(serviceKey("nas", "nginx"))
+	if got := m.Intents(); len(got) != 0 {
+		t.Errorf("Intents() after forgetting the service = %+v, want none", got)
+	}
+}
@@ -0,0 +1,168 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"home_server_dashboard/events"
+	"home_server_dashboard/notices"
+	"home_server_dashboard/services"
+)
+
+// maintenanceWindow is a running maintenance window of a host.
+type maintenanceWindow struct {
+	services.MaintenanceWindow
+	upAtStart []string    // Services of the host that were up when it started
+	timer     *time.Timer // Ends the window
+}
+
+// maintenanceNoticeKey is the key of the notice about a host's maintenance.
+func maintenanceNoticeKey(host string) string {
+	return "maintenance:" + host
+}
+
+// StartMaintenance puts host in maintenance for d, or moves the end of its
+// running window to d from now. Until the window ends, the events of the
+// host are tagged so notifiers skip them; when it ends, one summary of what
+// is still down is published. Implements handlers.MaintenanceScheduler.
+func (m *Monitor) StartMaintenance(host string, d time.Duration, user string) services.MaintenanceWindow {
+	now := m.now()
+	up := m.servicesUp(host)
+
+	m.maintMu.Lock()
+	w, ok := m.maintenance[host]
+	if ok {
+		w.timer.Stop()
+	} else {
+		w = &maintenanceWindow{MaintenanceWindow: services.MaintenanceWindow{Host: host, Start: now}, upAtStart: up}
+		m.maintenance[host] = w
+	}
+	w.End = now.Add(d)
+	w.User = user
+	w.timer = time.AfterFunc(d, func() { m.endMaintenance(w) })
+	window := w.MaintenanceWindow
+	m.maintMu.Unlock()
+
+	if m.notices != nil {
+		m.notices.Post(notices.SeverityInfo, "monitor", maintenanceNoticeKey(host),
+			fmt.Sprintf("%s is in maintenance until %s (started by %s); its notifications are held back", host, window.End.Local().Format("15:04"), user))
+	}
+	log.Printf("Monitor: %s in maintenance until %s (by %s)", host, window.End.Format(time.RFC3339), user)
+	return window
+}
+
+// EndMaintenance ends the maintenance window of host early. It returns
+// false if host isn't in maintenance. Implements handlers.MaintenanceScheduler.
+func (m *Monitor) EndMaintenance(host string) bool {
+	m.maintMu.Lock()
+	w, ok := m.maintenance[host]
+	m.maintMu.Unlock()
+	if !ok {
+		return false
+	}
+	m.endMaintenance(w)
+	return true
+}
+
+// Maintenance returns the maintenance window host is in, if any.
+// Implements handlers.MaintenanceScheduler.
+func (m *Monitor) Maintenance(host string) (services.MaintenanceWindow, bool) {
+	m.maintMu.Lock()
+	defer m.maintMu.Unlock()
+	w, ok := m.maintenance[host]
+	if !ok {
+		return services.MaintenanceWindow{}, false
+	}
+	return w.MaintenanceWindow, true
+}
+
+// inMaintenance reports whether host is in a maintenance window.
+func (m *Monitor) inMaintenance(host string) bool {
+	m.maintMu.Lock()
+	defer m.maintMu.Unlock()
+	_, ok := m.maintenance[host]
+	return ok
+}
+
+// publish publishes event, tagged as happening during maintenance if it
+// comes from a host in a maintenance window.
+func (m *Monitor) publish(event events.Event) {
+	if host := events.HostOf(event); host != "" && m.inMaintenance(host) {
+		if tagged, ok := event.(events.MaintenanceTagger); ok {
+			tagged.MarkMaintenance()
+		}
+	}
+	m.bus.Publish(event)
+}
+
+// servicesUp returns the services of host that are up.
+func (m *Monitor) servicesUp(host string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var up []string
+	for key, state := range m.serviceStates {
+		if key.Host == host && state.State.Up() {
+			up = append(up, key.Name)
+		}
+	}
+	return up
+}
+
+// endMaintenance ends window w, unless it already ended, and publishes what
+// is still down after it: the services that were up when it started and
+// are down now, except those stopped from the dashboard, and whether the
+// host is unreachable.
+func (m *Monitor) endMaintenance(w *maintenanceWindow) {
+	m.maintMu.Lock()
+	if m.maintenance[w.Host] != w {
+		m.maintMu.Unlock()
+		return
+	}
+	delete(m.maintenance, w.Host)
+	w.timer.Stop()
+	m.maintMu.Unlock()
+
+	var down []string
+	m.mu.RLock()
+	for _, name := range w.upAtStart {
+		if state, ok := m.serviceStates[serviceKey(w.Host, name)]; ok && !state.State.Up() {
+			down = append(down, name)
+		}
+	}
+	unreachable := m.hostUnreachable(w.Host)
+	m.mu.RUnlock()
+
+	var stillDown []string
+	m.intentMu.Lock()
+	for _, name := range down {
+		if intent, ok := m.intents[serviceKey(w.Host, name)]; ok && intent.Action == "stop" && !intentExpired(intent, m.now()) {
+			continue
+		}
+		stillDown = append(stillDown, name)
+	}
+	m.intentMu.Unlock()
+	sort.Strings(stillDown)
+
+	if m.notices != nil {
+		m.notices.Resolve(maintenanceNoticeKey(w.Host))
+	}
+	event := events.NewMaintenanceEndedEvent(w.Host, w.Start, stillDown, unreachable)
+	m.bus.Publish(event)
+	if event.AllClear() {
+		log.Printf("Monitor: maintenance of %s ended, everything is back", w.Host)
+	} else {
+		log.Printf("Monitor: maintenance of %s ended, still down: %v (host unreachable: %v)", w.Host, stillDown, unreachable)
+	}
+}
+
+// stopMaintenanceTimers stops the timers ending maintenance windows, when
+// the monitor stops.
+func (m *Monitor) stopMaintenanceTimers() {
+	m.maintMu.Lock()
+	defer m.maintMu.Unlock()
+	for _, w := range m.maintenance {
+		w.timer.Stop()
+	}
+}
@@ -0,0 +1,132 @@
+package monitor
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"home_server_dashboard/events"
+	"home_server_dashboard/services"
+)
+
+// maintenanceEnded returns the maintenance summaries rec saw.
+func maintenanceEnded(rec *eventRecorder) []*events.MaintenanceEndedEvent {
+	var ended []*events.MaintenanceEndedEvent
+	for _, e := range rec.all() {
+		if e, ok := e.(*events.MaintenanceEndedEvent); ok {
+			ended = append(ended, e)
+		}
+	}
+	return ended
+}
+
+func TestMaintenance_TagsHostEvents(t *testing.T) {
+	m, rec, _ := newIntentTestMonitor()
+	m.updateServiceState(services.ServiceInfo{Name: "web", Host: "pi", Source: "docker", State: services.StateRunning, Status: "Up"})
+	m.mu.Lock()
+	m.hostStates["nas"] = HostState{Reachable: true}
+	m.mu.Unlock()
+
+	window := m.StartMaintenance("nas", time.Hour, "alice")
+	if window.Host != "nas" || window.User != "alice" || window.End.Sub(window.Start) != time.Hour {
+		t.Errorf("StartMaintenance() = %+v", window)
+	}
+	setNginxState(m, services.StateStopped, "Exited (1)")
+	m.handleHostError("nas", "connection refused")
+	m.updateServiceState(services.ServiceInfo{Name: "web", Host: "pi", Source: "docker", State: services.StateStopped, Status: "Exited (1)"})
+
+	var tagged []string
+	for _, e := range rec.all() {
+		tagged = append(tagged, fmt.Sprintf("%s@%s maintenance=%v", e.Type(), events.HostOf(e), events.InMaintenance(e)))
+	}
+	want := []string{
+		"service_state_changed@nas maintenance=true",
+		"host_unreachable@nas maintenance=true",
+		"service_state_changed@pi maintenance=false",
+	}
+	if !reflect.DeepEqual(tagged, want) {
+		t.Errorf("events = %q, want %q", tagged, want)
+	}
+	if _, ok := m.Maintenance("pi"); ok {
+		t.Error("Maintenance(pi) found a window on a host not in maintenance")
+	}
+	m.stopMaintenanceTimers()
+}
+
+func TestMaintenance_SummaryWhenEnded(t *testing.T) {
+	m, rec, _ := newIntentTestMonitor()
+	for _, name := range []string{"sonarr", "radarr"} {
+		m.updateServiceState(services.ServiceInfo{Name: name, Host: "nas", Source: "docker", State: services.StateRunning, Status: "Up"})
+	}
+	m.updateServiceState(services.ServiceInfo{Name: "backup", Host: "nas", Source: "docker", State: services.StateStopped, Status: "Exited (0)"})
+
+	m.StartMaintenance("nas", time.Hour, "alice")
+	// Everything goes down; only nginx comes back
+	for _, name := range []string{"nginx", "sonarr", "radarr"} {
+		m.updateServiceState(services.ServiceInfo{Name: name, Host: "nas", Source: "docker", State: services.StateStopped, Status: "Exited (1)"})
+	}
+	setNginxState(m, services.StateRunning, "Up")
+
+	if !m.EndMaintenance("nas") {
+		t.Fatal("EndMaintenance() = false for a host in maintenance")
+	}
+	if m.EndMaintenance("nas") {
+		t.Error("EndMaintenance() = true for a window that already ended")
+	}
+	ended := maintenanceEnded(rec)
+	if len(ended) != 1 {
+		t.Fatalf("got %d maintenance summaries, want 1", len(ended))
+	}
+	// backup was already down when the window started
+	if want := []string{"radarr", "sonarr"}; !reflect.DeepEqual(ended[0].StillDown, want) || ended[0].Unreachable {
+		t.Errorf("summary = %+v, want %q still down", ended[0], want)
+	}
+
+	// Events after the window notify again
+	setNginxState(m, services.StateStopped, "Exited (1)")
+	all := rec.all()
+	if events.InMaintenance(all[len(all)-1]) {
+		t.Error("event after the window ended is tagged as maintenance")
+	}
+}
+
+func TestMaintenance_EndsByItself(t *testing.T) {
+	m, rec, _ := newIntentTestMonitor()
+	m.StartMaintenance("nas", 10*time.Millisecond, "alice")
+	m.handleHostError("nas", "connection refused")
+	// Extending the window keeps what was up when it started
+	m.StartMaintenance("nas", 20*time.Millisecond, "bob")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(maintenanceEnded(rec)) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	ended := maintenanceEnded(rec)
+	if len(ended) != 1 {
+		t.Fatalf("got %d maintenance summaries, want 1", len(ended))
+	}
+	if !ended[0].Unreachable || ended[0].AllClear() {
+		t.Errorf("summary = %+v, want the host reported unreachable", ended[0])
+	}
+	if _, ok := m.Maintenance("nas"); ok {
+		t.Error("window still active after it ended")
+	}
+}
+
+func TestMaintenance_StoppedFromDashboardIsNotStillDown(t *testing.T) {
+	m, rec, _ := newIntentTestMonitor()
+	m.StartMaintenance("nas", time.Hour, "alice")
+
+	m.ExpectAction("nas", "nginx", "stop", "alice")
+	setNginxState(m, services.StateStopped, "Exited (0)")
+	m.EndMaintenance("nas")
+
+	// The stop was quiet before the window tagged it, and isn't in the summary
+	if got := transitions(rec); !reflect.DeepEqual(got, []string{"running→stopped quiet=true"}) {
+		t.Errorf("events = %q", got)
+	}
+	if ended := maintenanceEnded(rec); len(ended) != 1 || !ended[0].AllClear() {
+		t.Errorf("summary = %+v, want all clear", ended)
+	}
+}
@@ -7,8 +7,13 @@ package monitor
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/coreos/go-systemd/v22/dbus"
@@ -18,23 +23,45 @@ import (
 	containerAPI "github.com/docker/docker/api/types/container"
 
 	"home_server_dashboard/config"
+	"home_server_dashboard/connlimit"
 	"home_server_dashboard/events"
+	"home_server_dashboard/notices"
+	"home_server_dashboard/resilience"
 	"home_server_dashboard/services"
+	"home_server_dashboard/services/docker"
 	"home_server_dashboard/services/homeassistant"
 	"home_server_dashboard/services/systemd"
 	"home_server_dashboard/services/watchtower"
+	"home_server_dashboard/sshmux"
 )
 
 // ServiceState tracks the last known state of a service.
 type ServiceState struct {
-	State  string // "running", "stopped", "unknown"
-	Status string // Human-readable status
+	State           services.State // Normalized state, e.g. "running", "starting", "unknown"
+	Status          string         // Human-readable status
+	Health          string         // Health check result ("healthy", "unhealthy", "starting"), empty if unknown
+	LastStateChange time.Time      // When the service entered State
+	Source          string         // Source that reports the service, e.g. "docker"
+	LastSeen        time.Time      // When a discovery, poll or event last reported the service
+
+	// LastKnownState is the last state other than "unknown" the service was
+	// in, so that coming back from "unknown" to it isn't reported as a change.
+	// Empty if it has never been known.
+	LastKnownState services.State
 }
 
 // HostState tracks whether a host is reachable.
 type HostState struct {
 	Reachable bool
 	LastError string
+	Circuit   resilience.Snapshot // Circuit breaker state for remote reads
+	SSHInUse  int                 // SSH sessions currently open to the host
+	SSH       sshmux.Stats        // Shared SSH connection to the host
+
+	// PollInterval is the host's effective poll interval, longer than
+	// configured while it is backing off after failures (zero if not polled).
+	PollInterval time.Duration
+	NextPoll     time.Time // When the host is next polled (zero if not polled)
 }
 
 // PendingNotification tracks a service state change that is pending notification.
@@ -45,6 +72,19 @@ type PendingNotification struct {
 	Cancelled bool
 }
 
+// pendingDockerStop is a container stop that hasn't been applied yet, in case
+// the container comes straight back up (e.g., via its restart policy).
+type pendingDockerStop struct {
+	timer  *time.Timer
+	reason string // Status to report if the container stays down
+}
+
+// containerExit is how a container last exited, as reported by its die event.
+type containerExit struct {
+	code int
+	at   time.Time
+}
+
 // Monitor watches services and emits events when states change.
 // It uses Docker events API and systemd D-Bus signals for local services,
 // and falls back to polling for remote hosts.
@@ -52,28 +92,88 @@ type Monitor struct {
 	cfg            *config.Config
 	bus            *events.Bus
 	pollInterval   time.Duration
-	serviceStates  map[string]ServiceState // key: "host:servicename"
-	hostStates     map[string]HostState    // key: hostname
+	serviceStates  map[services.Key]ServiceState // key: host and name
+	hostStates     map[string]HostState          // key: hostname
 	mu             sync.RWMutex
-	stopCh         chan struct{}
 	wg             sync.WaitGroup
 	running        bool
 	skipFirstEvent bool // Don't emit events for initial state discovery
-
-	// Event source connections
-	dockerClient *client.Client
+	pruneAfter     int  // Poll intervals a service may go unreported before it is forgotten
+	now            func() time.Time
+
+	// ctx is the base context of every monitor operation. It is created by
+	// Start and cancelled by Stop so in-flight polls and discovery abort.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// sched runs the periodic work at staggered phases. It is created by
+	// Start from scheduleSeed, 0 for a random one.
+	sched        *scheduler
+	scheduleSeed int64
+
+	// Per-host poll functions and how long polling waits after Start
+	// (replaced in tests)
+	remotePoll     func(context.Context, *config.HostConfig) error
+	haPoll         func(context.Context, *config.HostConfig) error
+	providerPoll   func(context.Context, *config.HostConfig) error
+	pollStartDelay time.Duration
+
+	// Per-host poll schedules with backoff for unreachable hosts
+	remoteSchedule   *pollScheduler
+	haSchedule       *pollScheduler
+	providerSchedule *pollScheduler
+
+	// Event source connections, retried in the background until they
+	// succeed, first after initBackoff (replaced in tests). The Docker
+	// client is also used by the probe scheduler while it is replaced.
+	dockerClient atomic.Pointer[client.Client]
 	dbusConn     *dbus.Conn
+	readiness    *readiness
+	initBackoff  time.Duration
+	initGrace    time.Duration
+	notices      notices.Poster // Told of capabilities that fail (nil if none)
 
 	// Watchtower integration
-	watchtowerClients    map[string]*watchtower.Client       // key: hostname
-	pendingNotifications map[string]*PendingNotification     // key: "host:servicename"
+	watchtowerClients    map[string]*watchtower.Client         // key: hostname
+	pendingNotifications map[services.Key]*PendingNotification // key: host and name
 	pendingMu            sync.Mutex
+
+	// Stops and restarts asked for from the dashboard
+	intents  map[services.Key]*ActionIntent // key: host and name
+	intentMu sync.Mutex
+
+	// Docker restart debouncing
+	restartDebounce time.Duration
+	dockerStops     map[services.Key]*pendingDockerStop // key: host and name
+	oomKilled       map[services.Key]bool               // key: host and name
+	lastRestart     map[services.Key]time.Time          // key: host and name
+	lastExits       map[services.Key]containerExit      // key: host and name
+	dockerMu        sync.Mutex
+
+	// Container stats sampler of the local host (nil if not enabled)
+	stats *statsSampler
+
+	// Maintenance windows of hosts
+	maintenance map[string]*maintenanceWindow // key: hostname
+	maintMu     sync.Mutex
+
+	// Custom health probes, and how the services to probe are found
+	// (replaced in tests)
+	probes       *prober
+	probeTargets func(context.Context) []probeTarget
+
+	// The dashboard's own resource use
+	self *selfMonitor
 }
 
+// pendingNotificationsInterval is how often expired pending notifications
+// are published.
+const pendingNotificationsInterval = 5 * time.Second
+
 // Option is a functional option for configuring the monitor.
 type Option func(*Monitor)
 
-// WithPollInterval sets the polling interval for remote hosts.
+// WithPollInterval sets the polling interval for hosts that don't set their own.
 func WithPollInterval(d time.Duration) Option {
 	return func(m *Monitor) {
 		m.pollInterval = d
@@ -88,18 +188,55 @@ func WithSkipFirstEvent(skip bool) Option {
 	}
 }
 
+// WithRestartDebounce sets how long a Docker container stop is held back in
+// case the container restarts. Zero reports stops immediately.
+func WithRestartDebounce(d time.Duration) Option {
+	return func(m *Monitor) {
+		m.restartDebounce = d
+	}
+}
+
+// WithScheduleSeed seeds the jitter of the periodic work, so the loops run
+// at the same phases on every start. 0, the default, seeds it randomly.
+func WithScheduleSeed(seed int64) Option {
+	return func(m *Monitor) {
+		m.scheduleSeed = seed
+	}
+}
+
+// WithNotices reports the event sources that still fail after the startup
+// grace period to p, and resolves them once they connect.
+func WithNotices(p notices.Poster) Option {
+	return func(m *Monitor) {
+		m.notices = p
+	}
+}
+
 // New creates a new service monitor.
 func New(cfg *config.Config, bus *events.Bus, opts ...Option) *Monitor {
 	m := &Monitor{
 		cfg:                  cfg,
 		bus:                  bus,
-		pollInterval:         60 * time.Second, // Polling fallback for remote hosts
-		serviceStates:        make(map[string]ServiceState),
+		pollInterval:         cfg.GetPollInterval(), // Polling fallback for remote hosts
+		now:                  time.Now,
+		pruneAfter:           cfg.GetServicePruneAfter(),
+		serviceStates:        make(map[services.Key]ServiceState),
 		hostStates:           make(map[string]HostState),
-		stopCh:               make(chan struct{}),
+		ctx:                  context.Background(),
+		cancel:               func() {},
 		skipFirstEvent:       true, // Don't alert on initial discovery
 		watchtowerClients:    make(map[string]*watchtower.Client),
-		pendingNotifications: make(map[string]*PendingNotification),
+		pendingNotifications: make(map[services.Key]*PendingNotification),
+		intents:              make(map[services.Key]*ActionIntent),
+		restartDebounce:      cfg.GetDockerRestartDebounce(),
+		dockerStops:          make(map[services.Key]*pendingDockerStop),
+		oomKilled:            make(map[services.Key]bool),
+		lastRestart:          make(map[services.Key]time.Time),
+		lastExits:            make(map[services.Key]containerExit),
+		pollStartDelay:       2 * time.Second, // Let initial discovery finish first
+		readiness:            &readiness{},
+		initBackoff:          defaultInitBackoff,
+		initGrace:            initGracePeriod,
 	}
 
 	// Initialize Watchtower clients for hosts that have it configured
@@ -118,6 +255,25 @@ func New(cfg *config.Config, bus *events.Bus, opts ...Option) *Monitor {
 		opt(m)
 	}
 
+	if localHost := m.getLocalHostConfig(); localHost.HasStats() {
+		m.stats = newStatsSampler(localHost.Name, localHost.GetStatsInterval())
+	}
+
+	m.maintenance = make(map[string]*maintenanceWindow)
+	m.probes = newProber()
+	m.probeTargets = m.collectProbeTargets
+
+	m.self = newSelfMonitor(cfg.GetLocalHostName(), cfg.SelfMonitor, bus.Publish)
+
+	m.remotePoll = m.pollRemoteHost
+	m.haPoll = m.pollHomeAssistantHost
+	m.providerPoll = m.pollProviderHost
+
+	clock := func() time.Time { return m.now() }
+	m.remoteSchedule = newPollScheduler(clock, defaultMaxPollBackoff)
+	m.haSchedule = newPollScheduler(clock, defaultMaxPollBackoff)
+	m.providerSchedule = newPollScheduler(clock, defaultMaxPollBackoff)
+
 	return m
 }
 
@@ -129,18 +285,14 @@ func (m *Monitor) Start() {
 		return
 	}
 	m.running = true
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	m.sched = newScheduler(m.ctx, m.scheduleSeed)
 	m.mu.Unlock()
 
-	// Initialize event sources
-	m.initDockerEvents()
-	m.initSystemdEvents()
-
-	// Start event watchers
-	m.wg.Add(1)
-	go m.watchDockerEvents()
-
-	m.wg.Add(1)
-	go m.watchSystemdEvents()
+	// Connect to the event sources and start watching them once they are
+	// up, which at boot may be after the dashboard
+	m.whenReady(CapabilityDockerEvents, m.initDockerEvents, m.watchDockerEvents)
+	m.whenReady(CapabilitySystemdDBus, m.initSystemdEvents, m.watchSystemdEvents)
 
 	// Start polling for remote hosts (no native events available via SSH)
 	if m.hasRemoteHosts() {
@@ -154,14 +306,36 @@ func (m *Monitor) Start() {
 		go m.pollHomeAssistantHosts()
 	}
 
-	// Start pending notification processor (for Watchtower integration)
-	if len(m.watchtowerClients) > 0 {
+	// Start polling registered sources that have no event watcher
+	if m.hasProviderHosts() {
+		m.wg.Add(1)
+		go m.pollProviderHosts()
+	}
+
+	// Forget services that are no longer reported
+	m.sched.every(m.pollInterval, m.pruneStaleServices)
+
+	// Sample container CPU and memory use for the sparklines
+	if m.stats != nil {
 		m.wg.Add(1)
-		go m.processPendingNotifications()
+		go m.sampleStats()
+	}
+
+	// Run the custom health probes of services, each at its own interval
+	if m.hasProbes() {
+		m.sched.every(config.MinProbeInterval, m.probeServices)
 	}
 
-	log.Printf("Service monitor started (Docker events: %v, systemd D-Bus: %v, remote polling: %v, HA polling: %v, watchtower hosts: %d)",
-		m.dockerClient != nil, m.dbusConn != nil, m.hasRemoteHosts(), m.hasHomeAssistantHosts(), len(m.watchtowerClients))
+	// Publish the pending notifications that expired (for Watchtower
+	// updates and expected restarts)
+	m.sched.every(pendingNotificationsInterval, m.checkPendingNotifications)
+
+	// Watch the dashboard's own goroutines and open files for leaks
+	m.self.sample()
+	m.sched.every(selfMonitorInterval, m.self.sample)
+
+	log.Printf("Service monitor started (remote polling: %v, HA polling: %v, provider polling: %v, watchtower hosts: %d, stats sampling: %v)",
+		m.hasRemoteHosts(), m.hasHomeAssistantHosts(), m.hasProviderHosts(), len(m.watchtowerClients), m.stats != nil)
 }
 
 // Stop stops the monitor and waits for it to finish.
@@ -174,13 +348,15 @@ func (m *Monitor) Stop() {
 	m.running = false
 	m.mu.Unlock()
 
-	close(m.stopCh)
+	m.cancel()
+	m.sched.stop()
 	m.wg.Wait()
+	m.stopDockerTimers()
+	m.stopMaintenanceTimers()
 
 	// Clean up connections
-	if m.dockerClient != nil {
-		m.dockerClient.Close()
-		m.dockerClient = nil
+	if cli := m.dockerClient.Swap(nil); cli != nil {
+		cli.Close()
 	}
 	if m.dbusConn != nil {
 		m.dbusConn.Close()
@@ -191,107 +367,194 @@ func (m *Monitor) Stop() {
 }
 
 // initDockerEvents initializes the Docker client for event watching.
-func (m *Monitor) initDockerEvents() {
+func (m *Monitor) initDockerEvents() error {
+	if localHost := m.getLocalHostConfig(); localHost != nil && !localHost.HasDocker() {
+		log.Printf("Monitor: Docker is disabled on %s, not watching its events", localHost.Name)
+		return errDisabled
+	}
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
-		log.Printf("Monitor: failed to create Docker client for events: %v", err)
-		return
+		return fmt.Errorf("failed to create Docker client for events: %w", err)
 	}
 
 	// Test connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
 	defer cancel()
 	if _, err := cli.Ping(ctx); err != nil {
-		log.Printf("Monitor: Docker not available for events: %v", err)
 		cli.Close()
-		return
+		return fmt.Errorf("Docker not available for events: %w", err)
 	}
 
-	m.dockerClient = cli
+	if old := m.dockerClient.Swap(cli); old != nil {
+		old.Close()
+	}
+	return nil
 }
 
-// initSystemdEvents initializes the D-Bus connection for systemd event watching.
-func (m *Monitor) initSystemdEvents() {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// initSystemdEvents initializes the D-Bus connection for systemd event
+// watching. It is disabled without local systemd services to watch.
+func (m *Monitor) initSystemdEvents() error {
+	if localHost := m.getLocalHostConfig(); localHost == nil || !localHost.HasSystemd() {
+		log.Printf("Monitor: no local systemd services configured")
+		return errDisabled
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
 	defer cancel()
 
 	conn, err := dbus.NewSystemConnectionContext(ctx)
 	if err != nil {
-		log.Printf("Monitor: failed to connect to systemd D-Bus for events: %v", err)
-		return
+		return fmt.Errorf("failed to connect to systemd D-Bus for events: %w", err)
 	}
 
 	m.dbusConn = conn
+	return nil
 }
 
 // watchDockerEvents watches Docker events and emits state change events.
+// It runs once the Docker client is initialized.
 func (m *Monitor) watchDockerEvents() {
-	defer m.wg.Done()
-
-	if m.dockerClient == nil {
-		log.Printf("Monitor: Docker events not available, skipping Docker watch")
-		return
-	}
-
 	localHostName := m.cfg.GetLocalHostName()
 
-	// Create filter for container events
-	filterArgs := filters.NewArgs()
-	filterArgs.Add("type", "container")
-	filterArgs.Add("event", "start")
-	filterArgs.Add("event", "stop")
-	filterArgs.Add("event", "die")
-	filterArgs.Add("event", "kill")
-	filterArgs.Add("event", "pause")
-	filterArgs.Add("event", "unpause")
-
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	filterArgs := dockerEventFilter()
 
-	// Start listening for events
-	eventsChan, errChan := m.dockerClient.Events(ctx, dockerEvents.ListOptions{Filters: filterArgs})
+	// Start listening for events; the stream ends when the monitor stops
+	ctx := m.ctx
+	eventsChan, errChan := m.dockerClient.Load().Events(ctx, dockerEvents.ListOptions{Filters: filterArgs})
 
-	log.Printf("Monitor: watching Docker events for container state changes")
+	log.Printf("Monitor: watching Docker events for container state changes and image and volume changes")
 
 	// Do initial discovery
 	m.discoverDockerServices(localHostName)
 
+	// Events don't report deleted containers, so rediscover every poll
+	// interval to keep the services that still exist from going stale
+	resync := m.sched.tick(m.pollInterval)
+
 	for {
 		select {
-		case <-m.stopCh:
+		case <-m.ctx.Done():
 			return
+		case <-resync:
+			m.discoverDockerServices(localHostName)
 		case err := <-errChan:
+			if m.stopping() {
+				return
+			}
 			if err != nil {
 				log.Printf("Monitor: Docker events error: %v", err)
 				m.handleHostError(localHostName, "Docker events: "+err.Error())
-				// Try to reconnect after a delay
-				select {
-				case <-m.stopCh:
+				// Reconnect once Docker is back, however long that takes
+				if !m.retryInit(CapabilityDockerEvents, m.initDockerEvents, time.Time{}) {
 					return
-				case <-time.After(10 * time.Second):
-					m.initDockerEvents()
-					if m.dockerClient != nil {
-						eventsChan, errChan = m.dockerClient.Events(ctx, dockerEvents.ListOptions{Filters: filterArgs})
-						m.handleHostSuccess(localHostName)
-					}
 				}
+				eventsChan, errChan = m.dockerClient.Load().Events(ctx, dockerEvents.ListOptions{Filters: filterArgs})
+				m.handleHostSuccess(localHostName)
+				m.discoverDockerServices(localHostName)
 			}
 		case event := <-eventsChan:
-			m.handleDockerEvent(localHostName, event)
+			m.dispatchDockerEvent(localHostName, event)
 		}
 	}
 }
 
+// Docker event actions the monitor handles, by event type.
+var (
+	containerEventActions = []string{"start", "stop", "die", "pause", "unpause", "restart", "oom", "health_status"}
+	imageEventActions     = []string{"pull", "delete"}
+	volumeEventActions    = []string{"create", "destroy"}
+)
+
+// dockerEventFilter returns the filter for the Docker events the monitor
+// handles: container state changes, and the image and volume changes that
+// make cached image details stale. Docker matches any of the types with any
+// of the actions, so dispatchDockerEvent checks the pairs again.
+func dockerEventFilter() filters.Args {
+	filterArgs := filters.NewArgs()
+	for eventType, actions := range map[dockerEvents.Type][]string{
+		dockerEvents.ContainerEventType: containerEventActions,
+		dockerEvents.ImageEventType:     imageEventActions,
+		dockerEvents.VolumeEventType:    volumeEventActions,
+	} {
+		filterArgs.Add("type", string(eventType))
+		for _, action := range actions {
+			filterArgs.Add("event", action) // "health_status" matches "health_status: healthy" etc.
+		}
+	}
+	return filterArgs
+}
+
+// hasEventAction reports whether action is one of actions. Health status
+// actions carry the status after a colon.
+func hasEventAction(actions []string, action string) bool {
+	for _, a := range actions {
+		if action == a || strings.HasPrefix(action, a+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatchDockerEvent routes a Docker event to its handler. Events of other
+// types, or with actions the filter only let through for another type (such
+// as a container "create"), are ignored.
+func (m *Monitor) dispatchDockerEvent(hostName string, event dockerEvents.Message) {
+	action := string(event.Action)
+	switch event.Type {
+	case dockerEvents.ContainerEventType:
+		docker.ObserveContainerEvent(hostName, event.Actor.ID, event.Actor.Attributes["name"], action)
+		if hasEventAction(containerEventActions, action) {
+			m.handleDockerEvent(hostName, event)
+		}
+		if action == "start" {
+			m.publishContainerStarted(hostName, event)
+		}
+	case dockerEvents.ImageEventType:
+		if hasEventAction(imageEventActions, action) {
+			m.handleDockerResourceEvent(hostName, events.ResourceImage, event)
+		}
+	case dockerEvents.VolumeEventType:
+		if hasEventAction(volumeEventActions, action) {
+			m.handleDockerResourceEvent(hostName, events.ResourceVolume, event)
+		}
+	}
+}
+
+// publishContainerStarted publishes the start of a compose container, so log
+// streams following its project can add it.
+func (m *Monitor) publishContainerStarted(hostName string, event dockerEvents.Message) {
+	attrs := event.Actor.Attributes
+	project, service := attrs["com.docker.compose.project"], attrs["com.docker.compose.service"]
+	if project == "" || service == "" {
+		return
+	}
+	m.bus.Publish(events.NewContainerStartedEvent(hostName, attrs["name"], project, service, time.Unix(0, event.TimeNano)))
+}
+
+// handleDockerResourceEvent publishes an image or volume change so the
+// caches built from them are dropped; nothing is recomputed here.
+func (m *Monitor) handleDockerResourceEvent(hostName, resource string, event dockerEvents.Message) {
+	id := event.Actor.ID
+	if id == "" {
+		id = event.Actor.Attributes["name"]
+	}
+	m.bus.Publish(events.NewDockerResourceChangedEvent(hostName, resource, string(event.Action), id))
+}
+
 // discoverDockerServices does initial discovery of Docker services.
 func (m *Monitor) discoverDockerServices(hostName string) {
-	if m.dockerClient == nil {
+	cli := m.dockerClient.Load()
+	if cli == nil {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
 	defer cancel()
 
-	containers, err := m.dockerClient.ContainerList(ctx, containerAPI.ListOptions{All: true})
+	containers, err := cli.ContainerList(ctx, containerAPI.ListOptions{All: true})
+	if m.stopping() {
+		return
+	}
 	if err != nil {
 		log.Printf("Monitor: failed to list Docker containers: %v", err)
 		m.handleHostError(hostName, "Docker list: "+err.Error())
@@ -307,16 +570,11 @@ func (m *Monitor) discoverDockerServices(hostName string) {
 			continue // Skip non-compose containers
 		}
 
-		state := "stopped"
-		if container.State == "running" {
-			state = "running"
-		}
-
 		m.updateServiceState(services.ServiceInfo{
 			Name:   serviceName,
 			Host:   hostName,
 			Source: "docker",
-			State:  state,
+			State:  docker.MapState(container.State, docker.HealthFromStatus(container.Status)),
 			Status: container.Status,
 		})
 	}
@@ -325,53 +583,228 @@ func (m *Monitor) discoverDockerServices(hostName string) {
 }
 
 // handleDockerEvent processes a Docker event and emits state change events.
+// Stops are held back for the restart debounce window so that a container
+// restarted by its restart policy (die followed by start) is reported as a
+// single restart instead of a down/up pair.
 func (m *Monitor) handleDockerEvent(hostName string, event dockerEvents.Message) {
 	// Get service name from labels
 	serviceName := event.Actor.Attributes["com.docker.compose.service"]
 	if serviceName == "" {
 		return // Skip non-compose containers
 	}
+	key := serviceKey(hostName, serviceName)
+	action := string(event.Action)
+
+	switch {
+	case strings.HasPrefix(action, "health_status"):
+		health := strings.TrimSpace(strings.TrimPrefix(action, "health_status:"))
+		m.updateServiceHealth(hostName, serviceName, health)
+	case action == "oom":
+		// A die follows; remember why so the stop or restart can say so
+		m.dockerMu.Lock()
+		m.oomKilled[key] = true
+		m.dockerMu.Unlock()
+	case action == "die":
+		reason := "exited"
+		if code := event.Actor.Attributes["exitCode"]; code != "" {
+			reason = "exit code " + code
+			m.recordExit(key, code, event.TimeNano)
+		}
+		m.deferDockerStop(hostName, serviceName, reason)
+	case action == "stop":
+		m.deferDockerStop(hostName, serviceName, "stopped")
+	case action == "start" || action == "unpause":
+		m.dockerMu.Lock()
+		delete(m.lastExits, key)
+		m.dockerMu.Unlock()
+		if reason, ok := m.cancelDockerStop(key); ok {
+			m.publishRestart(hostName, serviceName, reason)
+		}
+		m.updateServiceState(services.ServiceInfo{
+			Name:   serviceName,
+			Host:   hostName,
+			Source: "docker",
+			State:  services.StateRunning,
+			Status: action,
+		})
+	case action == "pause":
+		m.updateServiceState(services.ServiceInfo{
+			Name:   serviceName,
+			Host:   hostName,
+			Source: "docker",
+			State:  services.StatePaused,
+			Status: action,
+		})
+	case action == "restart":
+		// `docker restart` emits stop/die/start before restart; only report
+		// it if the start didn't already
+		m.dockerMu.Lock()
+		recent := time.Since(m.lastRestart[key]) < m.restartDebounce
+		m.dockerMu.Unlock()
+		if !recent {
+			m.publishRestart(hostName, serviceName, "restart")
+		}
+	}
+}
 
-	var newState string
-	switch event.Action {
-	case "start", "unpause":
-		newState = "running"
-	case "stop", "die", "kill", "pause":
-		newState = "stopped"
-	default:
-		return // Ignore other events
+// recordExit remembers the exit code from a die event so listings can show
+// it before the container is inspected again. Unparseable codes are ignored.
+func (m *Monitor) recordExit(key services.Key, code string, timeNano int64) {
+	exitCode, err := strconv.Atoi(code)
+	if err != nil {
+		return
 	}
+	at := m.now()
+	if timeNano > 0 {
+		at = time.Unix(0, timeNano)
+	}
+	m.dockerMu.Lock()
+	m.lastExits[key] = containerExit{code: exitCode, at: at}
+	m.dockerMu.Unlock()
+}
+
+// LastExit returns the exit code and time of a container's last die event.
+// Nothing is returned once the container has started again. Implements
+// handlers.ExitTracker.
+func (m *Monitor) LastExit(host, serviceName string) (int, time.Time, bool) {
+	m.dockerMu.Lock()
+	defer m.dockerMu.Unlock()
+
+	exit, ok := m.lastExits[serviceKey(host, serviceName)]
+	return exit.code, exit.at, ok
+}
 
-	status := string(event.Action)
+// deferDockerStop records a container stop and applies it once the restart
+// debounce window passes without a start. Repeated stop events (die then
+// stop) keep the first timer and reason.
+func (m *Monitor) deferDockerStop(hostName, serviceName, reason string) {
+	key := serviceKey(hostName, serviceName)
 
+	m.dockerMu.Lock()
+	if m.oomKilled[key] {
+		reason = "out of memory"
+	}
+	if _, exists := m.dockerStops[key]; exists {
+		m.dockerMu.Unlock()
+		return
+	}
+	if m.restartDebounce <= 0 {
+		delete(m.oomKilled, key)
+		m.dockerMu.Unlock()
+		m.applyDockerStop(hostName, serviceName, reason)
+		return
+	}
+
+	pending := &pendingDockerStop{reason: reason}
+	pending.timer = time.AfterFunc(m.restartDebounce, func() {
+		m.dockerMu.Lock()
+		if m.dockerStops[key] != pending {
+			m.dockerMu.Unlock()
+			return // Cancelled by a start or Stop()
+		}
+		delete(m.dockerStops, key)
+		delete(m.oomKilled, key)
+		m.dockerMu.Unlock()
+
+		m.applyDockerStop(hostName, serviceName, pending.reason)
+	})
+	m.dockerStops[key] = pending
+	m.dockerMu.Unlock()
+}
+
+// cancelDockerStop cancels a pending stop for key, returning its reason and
+// whether one was pending.
+func (m *Monitor) cancelDockerStop(key services.Key) (string, bool) {
+	m.dockerMu.Lock()
+	defer m.dockerMu.Unlock()
+
+	pending, exists := m.dockerStops[key]
+	if !exists {
+		return "", false
+	}
+	pending.timer.Stop()
+	delete(m.dockerStops, key)
+	delete(m.oomKilled, key)
+	m.lastRestart[key] = time.Now()
+	return pending.reason, true
+}
+
+// applyDockerStop marks a container as stopped.
+func (m *Monitor) applyDockerStop(hostName, serviceName, reason string) {
 	m.updateServiceState(services.ServiceInfo{
 		Name:   serviceName,
 		Host:   hostName,
 		Source: "docker",
-		State:  newState,
-		Status: status,
+		State:  services.StateStopped,
+		Status: reason,
 	})
 }
 
-// watchSystemdEvents watches systemd D-Bus signals for unit state changes.
-func (m *Monitor) watchSystemdEvents() {
-	defer m.wg.Done()
-
-	if m.dbusConn == nil {
-		log.Printf("Monitor: systemd D-Bus not available, skipping systemd watch")
+// publishRestart emits a ServiceRestartedEvent unless initial discovery is in progress.
+func (m *Monitor) publishRestart(hostName, serviceName, reason string) {
+	m.mu.RLock()
+	skipFirst := m.skipFirstEvent
+	m.mu.RUnlock()
+	if skipFirst {
+		return
+	}
+	if m.consumeRestartIntent(serviceKey(hostName, serviceName)) {
+		log.Printf("Monitor: service restarted (expected) - %s on %s (%s)", serviceName, hostName, reason)
 		return
 	}
 
-	// Get local host config
-	localHost := m.getLocalHostConfig()
-	if localHost == nil || len(localHost.SystemdServices) == 0 {
-		log.Printf("Monitor: no local systemd services configured")
+	m.publish(events.NewServiceRestartedEvent(hostName, serviceName, "docker", reason))
+	log.Printf("Monitor: service restarted - %s on %s (%s)", serviceName, hostName, reason)
+}
+
+// stopDockerTimers cancels all pending Docker stops.
+func (m *Monitor) stopDockerTimers() {
+	m.dockerMu.Lock()
+	defer m.dockerMu.Unlock()
+
+	for key, pending := range m.dockerStops {
+		pending.timer.Stop()
+		delete(m.dockerStops, key)
+	}
+}
+
+// updateServiceHealth records a health check result and emits an event if it changed.
+// The first result for a healthy service is not reported, since nothing changed
+// from the user's point of view.
+func (m *Monitor) updateServiceHealth(hostName, serviceName, health string) {
+	key := serviceKey(hostName, serviceName)
+
+	m.mu.Lock()
+	state, exists := m.serviceStates[key]
+	if !exists {
+		state = ServiceState{State: services.StateRunning, Status: "health_status", LastStateChange: time.Now(), Source: "docker", LastKnownState: services.StateRunning}
+	}
+	previous := state.Health
+	state.Health = health
+	state.LastSeen = m.now()
+	m.serviceStates[key] = state
+	skipFirst := m.skipFirstEvent
+	m.mu.Unlock()
+
+	if previous == health || skipFirst {
 		return
 	}
+	if previous == "" && health != "unhealthy" {
+		return
+	}
+
+	m.publish(events.NewServiceHealthChangedEvent(hostName, serviceName, "docker", previous, health))
+	log.Printf("Monitor: service health change - %s on %s: %s → %s", serviceName, hostName, previous, health)
+}
+
+// watchSystemdEvents watches systemd D-Bus signals for unit state changes.
+// It runs once the D-Bus connection is initialized.
+func (m *Monitor) watchSystemdEvents() {
+	localHost := m.getLocalHostConfig()
 
 	// Build set of units to watch
 	watchUnits := make(map[string]bool)
-	for _, unit := range localHost.SystemdServices {
+	for _, unit := range localHost.GetSystemdServiceNames() {
 		watchUnits[unit] = true
 	}
 
@@ -396,7 +829,7 @@ func (m *Monitor) watchSystemdEvents() {
 
 	for {
 		select {
-		case <-m.stopCh:
+		case <-m.ctx.Done():
 			return
 		case err := <-errCh:
 			if err != nil {
@@ -421,10 +854,13 @@ func (m *Monitor) discoverSystemdServices(hostName string, watchUnits map[string
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
 	defer cancel()
 
 	units, err := m.dbusConn.ListUnitsContext(ctx)
+	if m.stopping() {
+		return
+	}
 	if err != nil {
 		log.Printf("Monitor: failed to list systemd units: %v", err)
 		m.handleHostError(hostName, "systemd list: "+err.Error())
@@ -438,16 +874,11 @@ func (m *Monitor) discoverSystemdServices(hostName string, watchUnits map[string
 			continue
 		}
 
-		state := "stopped"
-		if unit.ActiveState == "active" {
-			state = "running"
-		}
-
 		m.updateServiceState(services.ServiceInfo{
 			Name:   unit.Name,
 			Host:   hostName,
 			Source: "systemd",
-			State:  state,
+			State:  systemd.MapState(unit.ActiveState, unit.SubState),
 			Status: unit.ActiveState + " (" + unit.SubState + ")",
 		})
 	}
@@ -457,18 +888,13 @@ func (m *Monitor) discoverSystemdServices(hostName string, watchUnits map[string
 
 // handleSystemdUpdate processes a systemd unit state update.
 // SubState values for services: running, dead, exited, failed, auto-restart, etc.
+// Updates carry no ActiveState, so the SubState alone decides the state.
 func (m *Monitor) handleSystemdUpdate(hostName string, update *dbus.SubStateUpdate) {
-	state := "stopped"
-	// SubState "running" means the service is active and running
-	if update.SubState == "running" {
-		state = "running"
-	}
-
 	m.updateServiceState(services.ServiceInfo{
 		Name:   update.UnitName,
 		Host:   hostName,
 		Source: "systemd",
-		State:  state,
+		State:  systemd.MapState("", update.SubState),
 		Status: update.SubState,
 	})
 }
@@ -478,61 +904,125 @@ func (m *Monitor) pollRemoteHosts() {
 	defer m.wg.Done()
 
 	// Wait for initial discovery to complete before polling
-	time.Sleep(2 * time.Second)
-
-	ticker := time.NewTicker(m.pollInterval)
-	defer ticker.Stop()
-
-	// Initial poll
-	m.pollRemote()
-
-	for {
-		select {
-		case <-m.stopCh:
-			return
-		case <-ticker.C:
-			m.pollRemote()
-		}
+	if !m.sleep(m.pollStartDelay) {
+		return
 	}
+
+	m.pollLoop(m.remoteSchedule, m.pollRemote)
 }
 
-// pollRemote fetches current service states from remote hosts.
+// pollRemote fetches current service states from the remote hosts that are due.
 func (m *Monitor) pollRemote() {
-	ctx, cancel := context.WithTimeout(context.Background(), m.pollInterval/2)
-	defer cancel()
+	for i := range m.cfg.Hosts {
+		host := &m.cfg.Hosts[i]
 
-	for _, host := range m.cfg.Hosts {
 		// Skip local host - it uses native events
 		if host.IsLocal() {
 			continue
 		}
 
-		if len(host.SystemdServices) == 0 {
+		if !host.HasSystemd() {
 			continue
 		}
 
-		systemdProvider := systemd.NewProvider(host.Name, host.Address, host.SystemdServices)
-		systemdServices, err := systemdProvider.GetServices(ctx)
-		if err != nil {
-			log.Printf("Monitor: failed to poll remote host %s: %v", host.Name, err)
-			m.handleHostError(host.Name, err.Error())
-			continue
-		}
+		m.pollHost(m.remoteSchedule, host, m.remotePoll)
+	}
 
-		m.handleHostSuccess(host.Name)
+	m.markDiscoveryComplete()
+}
+
+// pollRemoteHost fetches the systemd service states of one remote host.
+func (m *Monitor) pollRemoteHost(ctx context.Context, host *config.HostConfig) error {
+	systemdProvider := systemd.NewProviderForHost(host)
+	var systemdServices []services.ServiceInfo
+	err := resilience.Do(ctx, host.Name, func(ctx context.Context) error {
+		var err error
+		systemdServices, err = systemdProvider.GetServices(ctx)
+		return err
+	})
+	if m.stopping() {
+		return err
+	}
+	if err != nil {
+		log.Printf("Monitor: failed to poll remote host %s: %v", host.Name, err)
+		m.handleHostError(host.Name, err.Error())
+		return err
+	}
+
+	m.handleHostSuccess(host.Name)
 
-		for _, svc := range systemdServices {
-			m.updateServiceState(svc)
+	for _, svc := range systemdServices {
+		m.updateServiceState(svc)
+	}
+	return nil
+}
+
+// pollHost polls host with poll if its schedule says it is due, and schedules
+// the next poll based on the outcome. Each poll may take up to half the
+// host's interval, and is abandoned without affecting the schedule if the
+// monitor stops meanwhile.
+func (m *Monitor) pollHost(sched *pollScheduler, host *config.HostConfig, poll func(context.Context, *config.HostConfig) error) {
+	interval := host.GetPollInterval(m.pollInterval)
+	if m.stopping() || !sched.due(host.Name, interval) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, interval/2)
+	defer cancel()
+
+	err := poll(ctx, host)
+	if m.stopping() {
+		return
+	}
+	before, after := sched.record(host.Name, err)
+	switch {
+	case after > before:
+		log.Printf("Monitor: %s is unreachable, next poll in %s", host.Name, after)
+	case err == nil && before > after:
+		log.Printf("Monitor: %s is reachable again, polling every %s", host.Name, after)
+	}
+}
+
+// stopping reports whether Stop has been called. Errors seen while stopping
+// come from the cancelled context, not from the hosts, and aren't reported.
+func (m *Monitor) stopping() bool {
+	return m.ctx.Err() != nil
+}
+
+// pollLoop runs poll, which polls the hosts that are due on sched, until the
+// monitor stops. The first pass runs at once so discovery isn't delayed; the
+// second waits the loop's offset besides, which moves its polls off the
+// phase of the other loops for good.
+func (m *Monitor) pollLoop(sched *pollScheduler, poll func()) {
+	offset := m.sched.offset(m.pollInterval)
+	for {
+		poll()
+
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-time.After(sched.untilNext(m.pollInterval) + offset):
 		}
+		offset = 0
 	}
+}
 
-	m.markDiscoveryComplete()
+// sleep waits for d, returning false if the monitor stops first.
+func (m *Monitor) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-m.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
 }
 
 // hasRemoteHosts returns true if there are remote hosts configured.
 func (m *Monitor) hasRemoteHosts() bool {
 	for _, host := range m.cfg.Hosts {
-		if !host.IsLocal() && len(host.SystemdServices) > 0 {
+		if !host.IsLocal() && host.HasSystemd() {
 			return true
 		}
 	}
@@ -562,14 +1052,52 @@ func (m *Monitor) markDiscoveryComplete() {
 // updateServiceState checks if a service state changed and emits an event if so.
 // For Docker services on hosts with Watchtower configured, it will delay
 // "stopped" notifications to avoid false positives during container updates.
+// Stops asked for from the dashboard are reported quietly with who asked for
+// them, and the transitions of a restart asked for from it not at all.
 func (m *Monitor) updateServiceState(svc services.ServiceInfo) {
-	key := svc.Host + ":" + svc.Name
+	key := serviceKey(svc.Host, svc.Name)
 
 	m.mu.Lock()
 	oldState, exists := m.serviceStates[key]
 	newState := ServiceState{
-		State:  svc.State,
-		Status: svc.Status,
+		State:           svc.State,
+		Status:          svc.Status,
+		Health:          oldState.Health,
+		LastStateChange: oldState.LastStateChange,
+		Source:          svc.Source,
+		LastSeen:        m.now(),
+		LastKnownState:  oldState.LastKnownState,
+	}
+	if svc.State != services.StateUnknown {
+		newState.LastKnownState = svc.State
+	}
+
+	// Seed the change time from the provider on discovery (Docker StartedAt,
+	// systemd StateChangeTimestamp), falling back to now for providers that
+	// don't report one. Transitions we observe are stamped with now.
+	if !exists {
+		if svc.LastStateChange != nil {
+			newState.LastStateChange = *svc.LastStateChange
+		} else {
+			newState.LastStateChange = time.Now()
+		}
+	} else if oldState.State != newState.State {
+		newState.LastStateChange = time.Now()
+		// Health checks restart with the container
+		newState.Health = ""
+	}
+
+	verdict := intentNone
+	if exists && oldState.State != newState.State {
+		var status string
+		if verdict, status = m.matchIntent(key, newState.State); status != "" {
+			newState.Status = status
+		}
+	} else if exists && !newState.State.Up() {
+		// Keep saying who stopped it while it stays down
+		if status := m.stoppedStatus(key); status != "" {
+			newState.Status = status
+		}
 	}
 
 	// Update stored state
@@ -585,20 +1113,37 @@ func (m *Monitor) updateServiceState(svc services.ServiceInfo) {
 				svc.Host,
 				svc.Name,
 				svc.Source,
-				oldState.State,
-				newState.State,
+				string(oldState.State),
+				string(newState.State),
 				newState.Status,
 			)
-
-			// Check if we should delay this notification for Watchtower
-			if m.shouldDelayNotification(svc, oldState.State, newState.State) {
+			event.Quiet = !shouldAlert(oldState.State, oldState.LastKnownState, newState.State)
+
+			switch {
+			case verdict == intentSwallow:
+				// Part of a restart from the dashboard: hold back a down
+				// transition in case the service doesn't come back
+				if newState.State.Up() {
+					m.cancelPendingNotification(key)
+				} else {
+					m.queueRestartNotification(key, event)
+				}
+				log.Printf("Monitor: service state change (expected restart) - %s on %s: %s → %s",
+					svc.Name, svc.Host, oldState.State, newState.State)
+			case verdict == intentExpected:
+				event.Quiet = true
+				m.publish(event)
+				log.Printf("Monitor: service state change (expected stop) - %s on %s: %s → %s",
+					svc.Name, svc.Host, oldState.State, newState.State)
+			case m.shouldDelayNotification(svc, oldState.State, newState.State):
+				// Delay this notification for Watchtower
 				m.queuePendingNotification(key, event)
 				log.Printf("Monitor: service state change (pending) - %s on %s: %s → %s (waiting for Watchtower timeout)",
 					svc.Name, svc.Host, oldState.State, newState.State)
-			} else {
+			default:
 				// Check if this service came back up - cancel any pending notification
 				m.cancelPendingNotification(key)
-				m.bus.Publish(event)
+				m.publish(event)
 				log.Printf("Monitor: service state change - %s on %s: %s → %s",
 					svc.Name, svc.Host, oldState.State, newState.State)
 			}
@@ -609,6 +1154,140 @@ func (m *Monitor) updateServiceState(svc services.ServiceInfo) {
 	}
 }
 
+// Seed records the services of a collection made outside the monitor, such
+// as the startup warm-up's, as discovered, so the monitor starts out knowing
+// them instead of waiting for its first polls. Only services the monitor
+// polls with the same providers are taken: systemd units of remote hosts
+// and the polled registered sources. Services it knows already are left to
+// its own polls and events, so seeding never publishes an event.
+// Implements handlers.DiscoverySeeder.
+func (m *Monitor) Seed(svcs []services.ServiceInfo) {
+	now := m.now()
+	seeded := 0
+
+	m.mu.Lock()
+	for _, svc := range svcs {
+		key := serviceKey(svc.Host, svc.Name)
+		if _, known := m.serviceStates[key]; known || !m.polls(svc) {
+			continue
+		}
+		state := ServiceState{
+			State:           svc.State,
+			Status:          svc.Status,
+			LastStateChange: now,
+			Source:          svc.Source,
+			LastSeen:        now,
+		}
+		if svc.LastStateChange != nil {
+			state.LastStateChange = *svc.LastStateChange
+		}
+		if svc.State != services.StateUnknown {
+			state.LastKnownState = svc.State
+		}
+		m.serviceStates[key] = state
+		seeded++
+	}
+	m.mu.Unlock()
+
+	if seeded > 0 {
+		log.Printf("Monitor: seeded %d services from a collection", seeded)
+	}
+}
+
+// polls reports whether the monitor polls svc with the provider that
+// collected it.
+func (m *Monitor) polls(svc services.ServiceInfo) bool {
+	host := m.cfg.GetHostByName(svc.Host)
+	if !host.IsEnabled() {
+		return false
+	}
+	if svc.Source == "systemd" {
+		return !host.IsLocal() && host.HasSystemd()
+	}
+	for _, reg := range pollSources(host) {
+		if reg.Source == svc.Source {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneStaleServices forgets the services of hosts removed from the config
+// at once, and other services once they haven't been reported for pruneAfter
+// poll intervals of their host. Services of unreachable hosts are kept, since
+// nothing can be seen there, and so are configured systemd units, which
+// exist as long as they're configured but are only reported when they change.
+// Neither applies once the host or its systemd collection is disabled, so
+// their services age out like any other that stopped being reported.
+// A ServiceRemovedEvent is published for each forgotten service, and a
+// service later reported under the same name is treated as new.
+func (m *Monitor) pruneStaleServices() {
+	now := m.now()
+	var removed []*events.ServiceRemovedEvent
+
+	m.mu.Lock()
+	for key, state := range m.serviceStates {
+		hostName, serviceName := key.Host, key.Name
+		host := m.cfg.GetHostByName(hostName)
+
+		var reason string
+		switch {
+		case host == nil:
+			reason = "host removed from config"
+		case host.IsEnabled() && m.hostUnreachable(hostName):
+			continue
+		case state.Source == "systemd" && host.HasSystemd() && slices.Contains(host.GetSystemdServiceNames(), serviceName):
+			continue
+		case now.Sub(state.LastSeen) > time.Duration(m.pruneAfter)*host.GetPollInterval(m.pollInterval):
+			reason = "no longer reported"
+		default:
+			continue
+		}
+
+		delete(m.serviceStates, key)
+		removed = append(removed, events.NewServiceRemovedEvent(hostName, serviceName, state.Source, reason))
+	}
+	m.mu.Unlock()
+
+	for _, event := range removed {
+		m.forgetService(serviceKey(event.Host, event.ServiceName))
+		m.bus.Publish(event)
+		log.Printf("Monitor: forgot service %s on %s (%s)", event.ServiceName, event.Host, event.Reason)
+	}
+}
+
+// hostUnreachable returns true if host is known to be unreachable. Called with m.mu held.
+func (m *Monitor) hostUnreachable(host string) bool {
+	state, ok := m.hostStates[host]
+	return ok && !state.Reachable
+}
+
+// forgetService drops what the monitor tracks about a service besides its state.
+func (m *Monitor) forgetService(key services.Key) {
+	m.dockerMu.Lock()
+	if pending, ok := m.dockerStops[key]; ok {
+		pending.timer.Stop()
+		delete(m.dockerStops, key)
+	}
+	delete(m.oomKilled, key)
+	delete(m.lastRestart, key)
+	delete(m.lastExits, key)
+	m.dockerMu.Unlock()
+
+	m.pendingMu.Lock()
+	delete(m.pendingNotifications, key)
+	m.pendingMu.Unlock()
+
+	m.intentMu.Lock()
+	delete(m.intents, key)
+	m.intentMu.Unlock()
+
+	if m.stats != nil {
+		m.stats.forget(key)
+	}
+	m.probes.forget(key)
+}
+
 // handleHostError handles a host becoming unreachable.
 func (m *Monitor) handleHostError(host, reason string) {
 	m.mu.Lock()
@@ -619,7 +1298,7 @@ func (m *Monitor) handleHostError(host, reason string) {
 
 	if exists && oldState.Reachable && !m.skipFirstEvent {
 		event := events.NewHostUnreachableEvent(host, reason)
-		m.bus.Publish(event)
+		m.publish(event)
 		log.Printf("Monitor: host unreachable - %s: %s", host, reason)
 	}
 }
@@ -634,29 +1313,98 @@ func (m *Monitor) handleHostSuccess(host string) {
 
 	if exists && !oldState.Reachable && !m.skipFirstEvent {
 		event := events.NewHostRecoveredEvent(host)
-		m.bus.Publish(event)
+		m.publish(event)
 		log.Printf("Monitor: host recovered - %s", host)
 	}
 }
 
+// serviceKey returns the key the monitor tracks a service by. Services are
+// told apart by host and name only, as events and polls name them.
+func serviceKey(host, serviceName string) services.Key {
+	return services.Key{Host: host, Name: serviceName}
+}
+
 // GetServiceState returns the current known state of a service.
 func (m *Monitor) GetServiceState(host, serviceName string) (ServiceState, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	state, exists := m.serviceStates[host+":"+serviceName]
+	state, exists := m.serviceStates[serviceKey(host, serviceName)]
 	return state, exists
 }
 
+// LastStateChange returns when a service last changed state, as tracked by
+// the monitor. Implements handlers.StateTracker.
+func (m *Monitor) LastStateChange(host, serviceName string) (time.Time, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	state, exists := m.serviceStates[serviceKey(host, serviceName)]
+	if !exists || state.LastStateChange.IsZero() {
+		return time.Time{}, false
+	}
+	return state.LastStateChange, true
+}
+
 // GetHostState returns the current known state of a host.
 func (m *Monitor) GetHostState(host string) (HostState, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	state, exists := m.hostStates[host]
+	if exists {
+		m.addHostDiagnostics(host, &state)
+	}
 	return state, exists
 }
 
+// HostReachable returns whether host was reachable when last polled, and
+// false for known if it hasn't been polled.
+func (m *Monitor) HostReachable(host string) (reachable, known bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	state, known := m.hostStates[host]
+	return state.Reachable, known
+}
+
+// addHostDiagnostics fills in the circuit breaker, SSH sessions and poll
+// schedule for host. A host polled by more than one loop reports the schedule
+// that polls it next.
+func (m *Monitor) addHostDiagnostics(host string, state *HostState) {
+	state.Circuit = resilience.HostSnapshot(host)
+	if hostCfg := m.cfg.GetHostByName(host); hostCfg != nil {
+		state.SSHInUse = connlimit.Default().InUse(hostCfg.Address)
+		if !hostCfg.IsLocal() {
+			state.SSH = sshmux.HostStats(hostCfg.GetSSHArgs(), hostCfg.GetSSHTarget())
+		}
+	}
+	for _, sched := range []*pollScheduler{m.remoteSchedule, m.haSchedule, m.providerSchedule} {
+		hs, ok := sched.get(host)
+		if !ok {
+			continue
+		}
+		if state.NextPoll.IsZero() || hs.nextPoll.Before(state.NextPoll) {
+			state.PollInterval = hs.current
+			state.NextPoll = hs.nextPoll
+		}
+	}
+}
+
+// HostStates returns a snapshot of all tracked hosts, including their circuit
+// breaker state and open SSH sessions.
+func (m *Monitor) HostStates() map[string]HostState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make(map[string]HostState, len(m.hostStates))
+	for host, state := range m.hostStates {
+		m.addHostDiagnostics(host, &state)
+		result[host] = state
+	}
+	return result
+}
+
 // ServiceCount returns the number of tracked services.
 func (m *Monitor) ServiceCount() int {
 	m.mu.RLock()
@@ -686,80 +1434,98 @@ func (m *Monitor) pollHomeAssistantHosts() {
 	defer m.wg.Done()
 
 	// Wait for initial discovery to complete before polling
-	time.Sleep(2 * time.Second)
-
-	ticker := time.NewTicker(m.pollInterval)
-	defer ticker.Stop()
-
-	// Initial poll
-	m.pollHomeAssistant()
-
-	for {
-		select {
-		case <-m.stopCh:
-			return
-		case <-ticker.C:
-			m.pollHomeAssistant()
-		}
+	if !m.sleep(m.pollStartDelay) {
+		return
 	}
+
+	m.pollLoop(m.haSchedule, m.pollHomeAssistant)
 }
 
-// pollHomeAssistant fetches current health status from Home Assistant instances.
+// pollHomeAssistant fetches current health status from the Home Assistant instances that are due.
 func (m *Monitor) pollHomeAssistant() {
-	ctx, cancel := context.WithTimeout(context.Background(), m.pollInterval/2)
-	defer cancel()
-
-	for _, host := range m.cfg.Hosts {
+	for i := range m.cfg.Hosts {
+		host := &m.cfg.Hosts[i]
 		if !host.HasHomeAssistant() {
 			continue
 		}
 
-		haProvider, err := homeassistant.NewProvider(&host)
-		if err != nil {
-			log.Printf("Monitor: failed to create Home Assistant provider for %s: %v", host.Name, err)
-			m.handleHostError(host.Name, err.Error())
-			continue
-		}
-		if haProvider == nil {
-			continue
-		}
-		defer haProvider.Close()
+		m.pollHost(m.haSchedule, host, m.haPoll)
+	}
 
-		state, status, err := haProvider.CheckHealth(ctx)
-		if err != nil {
-			log.Printf("Monitor: Home Assistant on %s is unreachable: %v", host.Name, err)
-			m.handleHostError(host.Name, err.Error())
-		} else {
-			m.handleHostSuccess(host.Name)
-		}
+	m.markDiscoveryComplete()
+}
 
-		// Update service state
-		svc := services.ServiceInfo{
-			Name:   "homeassistant",
-			Host:   host.Name,
-			State:  state,
-			Status: status,
-			Source: "homeassistant",
-		}
-		m.updateServiceState(svc)
+// pollHomeAssistantHost fetches the health status of one Home Assistant instance.
+func (m *Monitor) pollHomeAssistantHost(ctx context.Context, host *config.HostConfig) error {
+	haProvider, err := homeassistant.NewProvider(host)
+	if err != nil {
+		log.Printf("Monitor: failed to create Home Assistant provider for %s: %v", host.Name, err)
+		m.handleHostError(host.Name, err.Error())
+		return err
+	}
+	if haProvider == nil {
+		return nil
 	}
+	defer haProvider.Close()
 
-	m.markDiscoveryComplete()
+	state, status, err := haProvider.CheckHealth(ctx)
+	if m.stopping() {
+		return err
+	}
+	if err != nil {
+		log.Printf("Monitor: Home Assistant on %s is unreachable: %v", host.Name, err)
+		m.handleHostError(host.Name, err.Error())
+	} else {
+		m.handleHostSuccess(host.Name)
+	}
+
+	// Update service state
+	svc := services.ServiceInfo{
+		Name:   "homeassistant",
+		Host:   host.Name,
+		State:  state,
+		Status: status,
+		Source: "homeassistant",
+	}
+	m.updateServiceState(svc)
+	return err
+}
+
+// shouldAlert decides whether a state change is worth notifying about.
+// Transitional and indeterminate states are passed through on the way
+// somewhere else, so changes into them are quiet and only where the service
+// ends up is reported: a slow startup is quiet, but a start that fails
+// (starting → stopped) alerts. Leaving "unknown" alerts if the service ends
+// up in a different state than lastKnown, the one it was in before, so a
+// provider that answers again after failing isn't reported as a recovery.
+// Without a last known state, it only alerts if the service turns out to be
+// down, since we can't tell whether anything changed.
+func shouldAlert(oldState, lastKnown, newState services.State) bool {
+	if newState.Transitional() || newState == services.StateUnknown {
+		return false
+	}
+	if oldState == services.StateUnknown {
+		if lastKnown != "" {
+			return newState != lastKnown
+		}
+		return !newState.Up()
+	}
+	return true
 }
 
 // shouldDelayNotification determines if a service state change should be delayed.
 // Returns true if:
 // - The service is a Docker service
 // - The host has Watchtower configured
-// - The service went from "running" to "stopped"
-func (m *Monitor) shouldDelayNotification(svc services.ServiceInfo, oldState, newState string) bool {
+// - The service went from up (e.g., "running") to "stopped"
+func (m *Monitor) shouldDelayNotification(svc services.ServiceInfo, oldState, newState services.State) bool {
 	// Only delay Docker service stopped notifications
 	if svc.Source != "docker" {
 		return false
 	}
 
-	// Only delay running -> stopped transitions
-	if oldState != "running" || newState != "stopped" {
+	// Only delay up -> stopped transitions
+	if !oldState.Up() || newState != services.StateStopped {
 		return false
 	}
 
@@ -769,7 +1535,7 @@ func (m *Monitor) shouldDelayNotification(svc services.ServiceInfo, oldState, ne
 }
 
 // queuePendingNotification adds a notification to the pending queue.
-func (m *Monitor) queuePendingNotification(key string, event *events.ServiceStateChangedEvent) {
+func (m *Monitor) queuePendingNotification(key services.Key, event *events.ServiceStateChangedEvent) {
 	m.pendingMu.Lock()
 	defer m.pendingMu.Unlock()
 
@@ -789,42 +1555,25 @@ func (m *Monitor) queuePendingNotification(key string, event *events.ServiceStat
 
 // cancelPendingNotification cancels a pending notification for a service.
 // This is called when the service comes back up before the timeout expires.
-func (m *Monitor) cancelPendingNotification(key string) {
+func (m *Monitor) cancelPendingNotification(key services.Key) {
 	m.pendingMu.Lock()
 	defer m.pendingMu.Unlock()
 
 	if pending, exists := m.pendingNotifications[key]; exists {
 		if !pending.Cancelled {
-			log.Printf("Monitor: cancelled pending notification for %s (service recovered)", key)
+			log.Printf("Monitor: cancelled pending notification for %s on %s (service recovered)", key.Name, key.Host)
 			pending.Cancelled = true
 		}
 	}
 }
 
-// processPendingNotifications runs in the background and processes expired pending notifications.
-func (m *Monitor) processPendingNotifications() {
-	defer m.wg.Done()
-
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-m.stopCh:
-			return
-		case <-ticker.C:
-			m.checkPendingNotifications()
-		}
-	}
-}
-
 // checkPendingNotifications checks for expired pending notifications and publishes them.
 func (m *Monitor) checkPendingNotifications() {
 	m.pendingMu.Lock()
 	defer m.pendingMu.Unlock()
 
-	now := time.Now()
-	toDelete := []string{}
+	now := m.now()
+	toDelete := []services.Key{}
 
 	for key, pending := range m.pendingNotifications {
 		// Check if notification was cancelled (service came back up)
@@ -840,13 +1589,13 @@ func (m *Monitor) checkPendingNotifications() {
 			currentState, exists := m.serviceStates[key]
 			m.mu.RUnlock()
 
-			if exists && currentState.State == "running" {
+			if exists && currentState.State.Up() {
 				// Service is back up - don't send notification
-				log.Printf("Monitor: pending notification for %s cancelled (service is now running)", key)
+				log.Printf("Monitor: pending notification for %s on %s cancelled (service is now running)", key.Name, key.Host)
 			} else {
 				// Service is still down - send the notification
-				log.Printf("Monitor: sending delayed notification for %s (timeout expired, service still down)", key)
-				m.bus.Publish(pending.Event)
+				log.Printf("Monitor: sending delayed notification for %s on %s (timeout expired, service still down)", key.Name, key.Host)
+				m.publish(pending.Event)
 			}
 			toDelete = append(toDelete, key)
 		}
@@ -1,13 +1,21 @@
 package monitor
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	dockerEvents "github.com/docker/docker/api/types/events"
+
 	"home_server_dashboard/config"
+	"home_server_dashboard/connlimit"
 	"home_server_dashboard/events"
+	"home_server_dashboard/resilience"
 	"home_server_dashboard/services"
 )
 
@@ -60,8 +68,8 @@ func TestServiceStateTracking(t *testing.T) {
 
 	// Add some state manually (simulating poll)
 	m.mu.Lock()
-	m.serviceStates["nas:traefik"] = ServiceState{State: "running", Status: "Up"}
-	m.serviceStates["nas:nginx"] = ServiceState{State: "stopped", Status: "Exited (0)"}
+	m.serviceStates[serviceKey("nas", "traefik")] = ServiceState{State: "running", Status: "Up"}
+	m.serviceStates[serviceKey("nas", "nginx")] = ServiceState{State: "stopped", Status: "Exited (0)"}
 	m.mu.Unlock()
 
 	if m.ServiceCount() != 2 {
@@ -84,6 +92,99 @@ func TestServiceStateTracking(t *testing.T) {
 	}
 }
 
+func TestLastStateChange_SeededOnDiscovery(t *testing.T) {
+	m := New(&config.Config{}, events.NewBus(false))
+
+	started := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	m.updateServiceState(services.ServiceInfo{
+		Name: "nginx", Host: "nas", Source: "docker", State: "running",
+		LastStateChange: &started,
+	})
+
+	got, ok := m.LastStateChange("nas", "nginx")
+	if !ok || !got.Equal(started) {
+		t.Errorf("LastStateChange() = %v, %v; want provider time %v", got, ok, started)
+	}
+
+	// Providers without a timestamp (Home Assistant) are seeded with the poll time
+	before := time.Now()
+	m.updateServiceState(services.ServiceInfo{
+		Name: "core", Host: "ha", Source: "homeassistant", State: "running",
+	})
+	got, ok = m.LastStateChange("ha", "core")
+	if !ok || got.Before(before) {
+		t.Errorf("LastStateChange() = %v, %v; want poll time >= %v", got, ok, before)
+	}
+
+	if _, ok := m.LastStateChange("nas", "missing"); ok {
+		t.Error("expected no LastStateChange for unknown service")
+	}
+}
+
+// TestSeed tests that a collection seeds the services the monitor polls
+// and doesn't know yet, without publishing events.
+func TestSeed(t *testing.T) {
+	cfg := &config.Config{Hosts: []config.HostConfig{
+		{Name: "nas", Address: "localhost", SystemdServices: []string{"nginx.service"}},
+		{Name: "pi", Address: "192.168.1.20", SystemdServices: []string{"pihole-FTL.service", "nginx.service"}},
+	}}
+	bus := events.NewBus(false)
+	m := New(cfg, bus, WithSkipFirstEvent(false))
+	var published int
+	bus.SubscribeAll(func(events.Event) { published++ })
+
+	m.updateServiceState(services.ServiceInfo{Name: "nginx.service", Host: "pi", Source: "systemd", State: services.StateRunning})
+	m.Seed([]services.ServiceInfo{
+		{Name: "pihole-FTL.service", Host: "pi", Source: "systemd", State: services.StateRunning},
+		{Name: "nginx.service", Host: "pi", Source: "systemd", State: services.StateStopped},
+		{Name: "nginx.service", Host: "nas", Source: "systemd", State: services.StateRunning},
+		{Name: "jellyfin", Host: "nas", Source: "docker", State: services.StateRunning},
+		{Name: "app", Host: "pi", Source: "traefik", State: services.StateRunning},
+	})
+
+	if state, ok := m.GetServiceState("pi", "pihole-FTL.service"); !ok || state.State != services.StateRunning {
+		t.Errorf("remote unit = %+v, %v; want seeded running", state, ok)
+	}
+	if state, _ := m.GetServiceState("pi", "nginx.service"); state.State != services.StateRunning {
+		t.Errorf("known unit = %+v, want its own state kept", state)
+	}
+	// Local units and containers come from the monitor's own event sources,
+	// and Traefik-only services aren't polled at all
+	for _, key := range []services.Key{{Host: "nas", Name: "nginx.service"}, {Host: "nas", Name: "jellyfin"}, {Host: "pi", Name: "app"}} {
+		if _, ok := m.GetServiceState(key.Host, key.Name); ok {
+			t.Errorf("%s seeded, want it left to the monitor", key)
+		}
+	}
+	if published != 0 {
+		t.Errorf("seeding published %d events, want none", published)
+	}
+}
+
+func TestLastStateChange_UpdatedOnTransition(t *testing.T) {
+	m := New(&config.Config{}, events.NewBus(false))
+
+	started := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc := services.ServiceInfo{
+		Name: "nginx", Host: "nas", Source: "docker", State: "running",
+		LastStateChange: &started,
+	}
+	m.updateServiceState(svc)
+
+	// Same state on a later poll keeps the original time
+	m.updateServiceState(svc)
+	if got, _ := m.LastStateChange("nas", "nginx"); !got.Equal(started) {
+		t.Errorf("LastStateChange() after unchanged poll = %v, want %v", got, started)
+	}
+
+	before := time.Now()
+	svc.State = "stopped"
+	m.updateServiceState(svc)
+	got, _ := m.LastStateChange("nas", "nginx")
+	if got.Before(before) {
+		t.Errorf("LastStateChange() after transition = %v, want >= %v", got, before)
+	}
+}
+
 func TestHostStateTracking(t *testing.T) {
 	cfg := &config.Config{}
 	bus := events.NewBus(false)
@@ -123,6 +224,79 @@ func TestHostStateTracking(t *testing.T) {
 	if state.LastError != "timeout" {
 		t.Errorf("expected error 'timeout', got '%s'", state.LastError)
 	}
+
+	if reachable, known := m.HostReachable("remote"); reachable || !known {
+		t.Errorf("HostReachable(remote) = %v, %v, want false, true", reachable, known)
+	}
+	if _, known := m.HostReachable("unpolled"); known {
+		t.Error("HostReachable(unpolled) known, want unknown")
+	}
+}
+
+func TestHostStates_IncludesCircuitState(t *testing.T) {
+	resilience.Configure(1, time.Minute)
+	defer resilience.Configure(resilience.DefaultThreshold, resilience.DefaultCooldown)
+
+	cfg := &config.Config{}
+	bus := events.NewBus(false)
+	m := New(cfg, bus)
+
+	m.mu.Lock()
+	m.hostStates["flaky"] = HostState{Reachable: false, LastError: "ssh: connection reset"}
+	m.hostStates["nas"] = HostState{Reachable: true}
+	m.mu.Unlock()
+
+	// Trip the breaker for the flaky host
+	resilience.Do(context.Background(), "flaky", func(ctx context.Context) error {
+		return errors.New("ssh: connection reset")
+	})
+
+	states := m.HostStates()
+	if len(states) != 2 {
+		t.Fatalf("expected 2 hosts, got %d", len(states))
+	}
+	if states["flaky"].Circuit.State != resilience.StateOpen {
+		t.Errorf("expected flaky circuit open, got %s", states["flaky"].Circuit.State)
+	}
+	if states["nas"].Circuit.State != resilience.StateClosed {
+		t.Errorf("expected nas circuit closed, got %s", states["nas"].Circuit.State)
+	}
+
+	state, _ := m.GetHostState("flaky")
+	if state.Circuit.State != resilience.StateOpen {
+		t.Errorf("expected GetHostState circuit open, got %s", state.Circuit.State)
+	}
+}
+
+func TestHostStates_IncludesSSHSessions(t *testing.T) {
+	connlimit.Configure(2, 8)
+	defer connlimit.Configure(connlimit.DefaultPerHost, connlimit.DefaultGlobal)
+
+	cfg := &config.Config{
+		Hosts: []config.HostConfig{
+			{Name: "pi", Address: "192.168.1.20"},
+		},
+	}
+	m := New(cfg, events.NewBus(false))
+
+	m.mu.Lock()
+	m.hostStates["pi"] = HostState{Reachable: true}
+	m.mu.Unlock()
+
+	release, err := connlimit.Acquire(context.Background(), "192.168.1.20")
+	if err != nil {
+		t.Fatalf("Acquire() = %v", err)
+	}
+
+	if got := m.HostStates()["pi"].SSHInUse; got != 1 {
+		t.Errorf("expected 1 SSH session in use, got %d", got)
+	}
+
+	release()
+	state, _ := m.GetHostState("pi")
+	if state.SSHInUse != 0 {
+		t.Errorf("expected 0 SSH sessions after release, got %d", state.SSHInUse)
+	}
 }
 
 func TestEventEmissionOnStateChange(t *testing.T) {
@@ -141,19 +315,19 @@ func TestEventEmissionOnStateChange(t *testing.T) {
 
 	// Simulate first state
 	m.mu.Lock()
-	m.serviceStates["nas:traefik"] = ServiceState{State: "running", Status: "Up"}
+	m.serviceStates[serviceKey("nas", "traefik")] = ServiceState{State: "running", Status: "Up"}
 	m.mu.Unlock()
 
 	// Simulate state change (would happen via updateServiceState in real poll)
 	// We need to manually trigger the event emission logic
 	m.mu.Lock()
-	oldState := m.serviceStates["nas:traefik"]
+	oldState := m.serviceStates[serviceKey("nas", "traefik")]
 	newState := ServiceState{State: "stopped", Status: "Exited (1)"}
-	m.serviceStates["nas:traefik"] = newState
+	m.serviceStates[serviceKey("nas", "traefik")] = newState
 
 	event := events.NewServiceStateChangedEvent(
 		"nas", "traefik", "docker",
-		oldState.State, newState.State, newState.Status,
+		string(oldState.State), string(newState.State), newState.Status,
 	)
 	m.mu.Unlock()
 
@@ -288,6 +462,57 @@ func TestStartStop(t *testing.T) {
 	}
 }
 
+// TestStop_AbortsInFlightPoll tests that Stop cancels a hung poll instead of
+// waiting for its timeout, and that the aborted poll isn't reported as a host failure.
+func TestStop_AbortsInFlightPoll(t *testing.T) {
+	cfg := &config.Config{
+		Hosts: []config.HostConfig{
+			{Name: "remote", Address: "192.168.1.20", SystemdServices: []string{"nginx.service"}},
+		},
+	}
+	bus := events.NewBus(false)
+	m := New(cfg, bus, WithPollInterval(time.Hour))
+	m.pollStartDelay = 0
+
+	started := make(chan struct{})
+	pollErr := make(chan error, 1)
+	m.remotePoll = func(ctx context.Context, host *config.HostConfig) error {
+		close(started)
+		<-ctx.Done()
+		pollErr <- ctx.Err()
+		return ctx.Err()
+	}
+
+	m.Start()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		m.Stop()
+		t.Fatal("remote poll never started")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		m.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop did not return while a poll was in flight")
+	}
+
+	if err := <-pollErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("poll context error = %v, want context.Canceled", err)
+	}
+	if state, ok := m.GetHostState("remote"); ok && !state.Reachable {
+		t.Errorf("host marked unreachable after Stop: %+v", state)
+	}
+	if sched, ok := m.remoteSchedule.get("remote"); ok && sched.failures != 0 {
+		t.Errorf("aborted poll counted as a failure: %+v", sched)
+	}
+}
+
 func TestMultipleStartsAreSafe(t *testing.T) {
 	cfg := &config.Config{}
 	bus := events.NewBus(false)
@@ -342,8 +567,8 @@ func TestShouldDelayNotification(t *testing.T) {
 	tests := []struct {
 		name     string
 		svc      services.ServiceInfo
-		oldState string
-		newState string
+		oldState services.State
+		newState services.State
 		expected bool
 	}{
 		{
@@ -367,6 +592,20 @@ func TestShouldDelayNotification(t *testing.T) {
 			newState: "stopped",
 			expected: false, // Only Docker services are delayed
 		},
+		{
+			name:     "Docker unhealthy stopped on host with Watchtower",
+			svc:      services.ServiceInfo{Name: "traefik", Host: "nas", Source: "docker"},
+			oldState: "unhealthy",
+			newState: "stopped",
+			expected: true,
+		},
+		{
+			name:     "Docker paused stopped on host with Watchtower",
+			svc:      services.ServiceInfo{Name: "traefik", Host: "nas", Source: "docker"},
+			oldState: "paused",
+			newState: "stopped",
+			expected: false, // Watchtower doesn't stop paused containers
+		},
 		{
 			name:     "Docker stopped on host without Watchtower",
 			svc:      services.ServiceInfo{Name: "nginx", Host: "remote", Source: "docker"},
@@ -386,6 +625,120 @@ func TestShouldDelayNotification(t *testing.T) {
 	}
 }
 
+func TestShouldAlert(t *testing.T) {
+	tests := []struct {
+		oldState services.State
+		newState services.State
+		expected bool
+	}{
+		// Into transitional or indeterminate states: quiet
+		{services.StateStopped, services.StateStarting, false},
+		{services.StateRunning, services.StateStarting, false},
+		{services.StateRunning, services.StateStopping, false},
+		{services.StateRunning, services.StateUnknown, false},
+		{services.StateStopped, services.StateUnknown, false},
+
+		// Where a service ends up: alert
+		{services.StateStarting, services.StateRunning, true},
+		{services.StateStarting, services.StateStopped, true},
+		{services.StateStarting, services.StateUnhealthy, true},
+		{services.StateStopping, services.StateStopped, true},
+		{services.StateRunning, services.StateStopped, true},
+		{services.StateStopped, services.StateRunning, true},
+		{services.StateRunning, services.StateUnhealthy, true},
+		{services.StateUnhealthy, services.StateRunning, true},
+		{services.StateRunning, services.StatePaused, true},
+		{services.StatePaused, services.StateRunning, true},
+
+		// Leaving unknown never known before: only if the service turns out to be down
+		{services.StateUnknown, services.StateRunning, false},
+		{services.StateUnknown, services.StateUnhealthy, false},
+		{services.StateUnknown, services.StateStopped, true},
+		{services.StateUnknown, services.StatePaused, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.oldState)+"->"+string(tt.newState), func(t *testing.T) {
+			if got := shouldAlert(tt.oldState, "", tt.newState); got != tt.expected {
+				t.Errorf("shouldAlert(%s, \"\", %s) = %v, want %v", tt.oldState, tt.newState, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestShouldAlert_LastKnownState(t *testing.T) {
+	tests := []struct {
+		lastKnown services.State
+		newState  services.State
+		expected  bool
+	}{
+		// Back where it was before the provider failed: nothing happened
+		{services.StateRunning, services.StateRunning, false},
+		{services.StateStopped, services.StateStopped, false},
+		// Somewhere else: it changed while we couldn't tell
+		{services.StateStopped, services.StateRunning, true},
+		{services.StateRunning, services.StateStopped, true},
+		{services.StateRunning, services.StateUnhealthy, true},
+		// Still not where it ends up
+		{services.StateRunning, services.StateStarting, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.lastKnown)+"->unknown->"+string(tt.newState), func(t *testing.T) {
+			if got := shouldAlert(services.StateUnknown, tt.lastKnown, tt.newState); got != tt.expected {
+				t.Errorf("shouldAlert(unknown, %s, %s) = %v, want %v", tt.lastKnown, tt.newState, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUpdateServiceState_QuietTransitions(t *testing.T) {
+	bus := events.NewBus(false)
+	m := New(&config.Config{}, bus, WithSkipFirstEvent(false))
+	rec := recordEvents(bus)
+
+	// A slow start that fails: stopped → starting → stopped
+	for _, state := range []services.State{services.StateStopped, services.StateStarting, services.StateStopped} {
+		m.updateServiceState(services.ServiceInfo{Name: "db", Host: "nas", Source: "systemd", State: state})
+	}
+
+	var got []string
+	for _, e := range rec.all() {
+		if sc, ok := e.(*events.ServiceStateChangedEvent); ok {
+			got = append(got, fmt.Sprintf("%s→%s quiet=%v", sc.PreviousState, sc.CurrentState, sc.Quiet))
+		}
+	}
+	want := []string{"stopped→starting quiet=true", "starting→stopped quiet=false"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestUpdateServiceState_ProviderErrorIsNotARecovery(t *testing.T) {
+	bus := events.NewBus(false)
+	m := New(&config.Config{}, bus, WithSkipFirstEvent(false))
+	rec := recordEvents(bus)
+
+	// The provider fails and answers again with the service as it was
+	for _, state := range []services.State{services.StateRunning, services.StateUnknown, services.StateRunning} {
+		m.updateServiceState(services.ServiceInfo{Name: "homeassistant", Host: "nas", Source: "homeassistant", State: state})
+	}
+
+	var got []string
+	for _, e := range rec.all() {
+		if sc, ok := e.(*events.ServiceStateChangedEvent); ok {
+			got = append(got, fmt.Sprintf("%s→%s quiet=%v", sc.PreviousState, sc.CurrentState, sc.Quiet))
+		}
+	}
+	want := []string{"running→unknown quiet=true", "unknown→running quiet=true"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+	if last := m.serviceStates[serviceKey("nas", "homeassistant")].LastKnownState; last != services.StateRunning {
+		t.Errorf("LastKnownState = %q, want running", last)
+	}
+}
+
 func TestPendingNotificationQueue(t *testing.T) {
 	cfg := &config.Config{
 		Hosts: []config.HostConfig{
@@ -407,7 +760,7 @@ func TestPendingNotificationQueue(t *testing.T) {
 
 	// Queue a pending notification
 	event := events.NewServiceStateChangedEvent("nas", "traefik", "docker", "running", "stopped", "Exited (0)")
-	m.queuePendingNotification("nas:traefik", event)
+	m.queuePendingNotification(serviceKey("nas", "traefik"), event)
 
 	// Check it's pending
 	if m.GetPendingNotificationCount() != 1 {
@@ -415,7 +768,7 @@ func TestPendingNotificationQueue(t *testing.T) {
 	}
 
 	// Cancel the notification
-	m.cancelPendingNotification("nas:traefik")
+	m.cancelPendingNotification(serviceKey("nas", "traefik"))
 
 	// Check it's cancelled (count should be 0)
 	if m.GetPendingNotificationCount() != 0 {
@@ -453,12 +806,12 @@ func TestPendingNotificationExpiry(t *testing.T) {
 
 	// Set service state as stopped so the notification will be sent
 	m.mu.Lock()
-	m.serviceStates["nas:traefik"] = ServiceState{State: "stopped", Status: "Exited (0)"}
+	m.serviceStates[serviceKey("nas", "traefik")] = ServiceState{State: "stopped", Status: "Exited (0)"}
 	m.mu.Unlock()
 
 	// Queue a pending notification with 1 second timeout
 	event := events.NewServiceStateChangedEvent("nas", "traefik", "docker", "running", "stopped", "Exited (0)")
-	m.queuePendingNotification("nas:traefik", event)
+	m.queuePendingNotification(serviceKey("nas", "traefik"), event)
 
 	// Wait for notification to expire
 	time.Sleep(1500 * time.Millisecond)
@@ -511,16 +864,16 @@ func TestPendingNotificationRecovery(t *testing.T) {
 
 	// Queue a pending notification
 	event := events.NewServiceStateChangedEvent("nas", "traefik", "docker", "running", "stopped", "Exited (0)")
-	m.queuePendingNotification("nas:traefik", event)
+	m.queuePendingNotification(serviceKey("nas", "traefik"), event)
 
 	// Simulate service coming back up
 	m.mu.Lock()
-	m.serviceStates["nas:traefik"] = ServiceState{State: "running", Status: "Up"}
+	m.serviceStates[serviceKey("nas", "traefik")] = ServiceState{State: "running", Status: "Up"}
 	m.mu.Unlock()
 
 	// Expire the notification
 	m.pendingMu.Lock()
-	if pending, exists := m.pendingNotifications["nas:traefik"]; exists {
+	if pending, exists := m.pendingNotifications[serviceKey("nas", "traefik")]; exists {
 		pending.ExpiresAt = time.Now().Add(-1 * time.Second)
 	}
 	m.pendingMu.Unlock()
@@ -567,3 +920,399 @@ func TestWatchtowerClientInitialization(t *testing.T) {
 		t.Error("expected watchtower client for 'nas'")
 	}
 }
+
+// dockerEvent builds a synthetic Docker event for a compose service.
+func dockerEvent(action, service string, attrs map[string]string) dockerEvents.Message {
+	attributes := map[string]string{"com.docker.compose.service": service}
+	for k, v := range attrs {
+		attributes[k] = v
+	}
+	return dockerEvents.Message{
+		Type:   dockerEvents.ContainerEventType,
+		Action: dockerEvents.Action(action),
+		Actor:  dockerEvents.Actor{Attributes: attributes},
+	}
+}
+
+// eventRecorder collects events published on a bus.
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+func recordEvents(bus *events.Bus) *eventRecorder {
+	r := &eventRecorder{}
+	record := func(e events.Event) {
+		r.mu.Lock()
+		r.events = append(r.events, e)
+		r.mu.Unlock()
+	}
+	bus.SubscribeAll(record)
+	// Notifiers have no use for resource changes, so SubscribeAll leaves them out
+	bus.Subscribe(events.DockerResourceChanged, record)
+	return r
+}
+
+func (r *eventRecorder) all() []events.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]events.Event(nil), r.events...)
+}
+
+// newDockerTestMonitor creates a monitor with nginx already discovered as running.
+func newDockerTestMonitor(debounce time.Duration) (*Monitor, *eventRecorder) {
+	bus := events.NewBus(false)
+	m := New(&config.Config{}, bus, WithSkipFirstEvent(false), WithRestartDebounce(debounce))
+	m.updateServiceState(services.ServiceInfo{Name: "nginx", Host: "nas", Source: "docker", State: "running", Status: "Up"})
+	return m, recordEvents(bus)
+}
+
+func TestHandleDockerEvent_FastRestartIsSingleEvent(t *testing.T) {
+	m, rec := newDockerTestMonitor(time.Second)
+
+	m.handleDockerEvent("nas", dockerEvent("die", "nginx", map[string]string{"exitCode": "137"}))
+	m.handleDockerEvent("nas", dockerEvent("start", "nginx", nil))
+	m.handleDockerEvent("nas", dockerEvent("restart", "nginx", nil))
+
+	got := rec.all()
+	if len(got) != 1 {
+		t.Fatalf("expected 1 event, got %d: %+v", len(got), got)
+	}
+	restarted, ok := got[0].(*events.ServiceRestartedEvent)
+	if !ok {
+		t.Fatalf("expected ServiceRestartedEvent, got %T", got[0])
+	}
+	if restarted.ServiceName != "nginx" || restarted.Reason != "exit code 137" {
+		t.Errorf("unexpected restart event: %+v", restarted)
+	}
+	if state, _ := m.GetServiceState("nas", "nginx"); state.State != "running" {
+		t.Errorf("state = %s, want running", state.State)
+	}
+}
+
+func TestHandleDockerEvent_DieAloneReportsStop(t *testing.T) {
+	m, rec := newDockerTestMonitor(20 * time.Millisecond)
+
+	m.handleDockerEvent("nas", dockerEvent("die", "nginx", map[string]string{"exitCode": "1"}))
+	if len(rec.all()) != 0 {
+		t.Fatal("stop should be held back during the debounce window")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	got := rec.all()
+	if len(got) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(got))
+	}
+	changed, ok := got[0].(*events.ServiceStateChangedEvent)
+	if !ok {
+		t.Fatalf("expected ServiceStateChangedEvent, got %T", got[0])
+	}
+	if changed.PreviousState != "running" || changed.CurrentState != "stopped" || changed.Status != "exit code 1" {
+		t.Errorf("unexpected state change: %+v", changed)
+	}
+}
+
+func TestHandleDockerEvent_OOMReason(t *testing.T) {
+	m, rec := newDockerTestMonitor(time.Second)
+
+	m.handleDockerEvent("nas", dockerEvent("oom", "nginx", nil))
+	m.handleDockerEvent("nas", dockerEvent("die", "nginx", map[string]string{"exitCode": "137"}))
+	m.handleDockerEvent("nas", dockerEvent("start", "nginx", nil))
+
+	got := rec.all()
+	if len(got) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(got))
+	}
+	if restarted := got[0].(*events.ServiceRestartedEvent); restarted.Reason != "out of memory" {
+		t.Errorf("Reason = %q, want out of memory", restarted.Reason)
+	}
+}
+
+func TestHandleDockerEvent_RecordsExitCode(t *testing.T) {
+	m, _ := newDockerTestMonitor(time.Second)
+	died := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	event := dockerEvent("die", "nginx", map[string]string{"exitCode": "137"})
+	event.TimeNano = died.UnixNano()
+	m.handleDockerEvent("nas", event)
+
+	code, at, ok := m.LastExit("nas", "nginx")
+	if !ok || code != 137 || !at.Equal(died) {
+		t.Errorf("LastExit() = %d, %v, %v; want 137, %v, true", code, at, ok, died)
+	}
+
+	// A die without a usable code leaves nothing behind
+	m.handleDockerEvent("nas", dockerEvent("die", "redis", map[string]string{"exitCode": "oops"}))
+	if _, _, ok := m.LastExit("nas", "redis"); ok {
+		t.Error("LastExit() recorded an unparseable exit code")
+	}
+
+	// Starting again clears the exit
+	m.handleDockerEvent("nas", dockerEvent("start", "nginx", nil))
+	if _, _, ok := m.LastExit("nas", "nginx"); ok {
+		t.Error("LastExit() still set after start")
+	}
+}
+
+func TestHandleDockerEvent_ManualRestart(t *testing.T) {
+	m, rec := newDockerTestMonitor(time.Second)
+
+	// A restart with no preceding stop (e.g., missed events) is still reported once
+	m.handleDockerEvent("nas", dockerEvent("restart", "nginx", nil))
+
+	got := rec.all()
+	if len(got) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(got))
+	}
+	if restarted := got[0].(*events.ServiceRestartedEvent); restarted.Reason != "restart" {
+		t.Errorf("Reason = %q, want restart", restarted.Reason)
+	}
+}
+
+func TestHandleDockerEvent_HealthTransitions(t *testing.T) {
+	m, rec := newDockerTestMonitor(time.Second)
+
+	m.handleDockerEvent("nas", dockerEvent("health_status: healthy", "nginx", nil))
+	m.handleDockerEvent("nas", dockerEvent("health_status: healthy", "nginx", nil))
+	m.handleDockerEvent("nas", dockerEvent("health_status: unhealthy", "nginx", nil))
+	m.handleDockerEvent("nas", dockerEvent("health_status: healthy", "nginx", nil))
+
+	got := rec.all()
+	if len(got) != 2 {
+		t.Fatalf("expected 2 health events (first healthy is not a change), got %d", len(got))
+	}
+	want := [][2]string{{"healthy", "unhealthy"}, {"unhealthy", "healthy"}}
+	for i, e := range got {
+		health, ok := e.(*events.ServiceHealthChangedEvent)
+		if !ok {
+			t.Fatalf("event %d: expected ServiceHealthChangedEvent, got %T", i, e)
+		}
+		if health.PreviousHealth != want[i][0] || health.CurrentHealth != want[i][1] {
+			t.Errorf("event %d: %s → %s, want %s → %s", i, health.PreviousHealth, health.CurrentHealth, want[i][0], want[i][1])
+		}
+	}
+
+	if state, _ := m.GetServiceState("nas", "nginx"); state.Health != "healthy" {
+		t.Errorf("Health = %q, want healthy", state.Health)
+	}
+}
+
+func TestStopDockerTimers(t *testing.T) {
+	m, rec := newDockerTestMonitor(20 * time.Millisecond)
+
+	m.handleDockerEvent("nas", dockerEvent("die", "nginx", nil))
+	m.stopDockerTimers()
+	time.Sleep(60 * time.Millisecond)
+
+	if got := rec.all(); len(got) != 0 {
+		t.Errorf("expected no events after timers were stopped, got %d", len(got))
+	}
+	if state, _ := m.GetServiceState("nas", "nginx"); state.State != "running" {
+		t.Errorf("state = %s, want running", state.State)
+	}
+}
+
+func TestPruneStaleServices(t *testing.T) {
+	cfg := &config.Config{
+		Hosts: []config.HostConfig{
+			{Name: "nas", Address: "192.168.1.10", SystemdServices: []string{"backup.service"}},
+		},
+	}
+	bus := events.NewBus(false)
+	m := New(cfg, bus, WithSkipFirstEvent(false))
+	rec := recordEvents(bus)
+	clock := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return clock }
+
+	report := func(host, name, source string, state services.State) {
+		m.updateServiceState(services.ServiceInfo{Name: name, Host: host, Source: source, State: state})
+	}
+	report("nas", "nginx", "docker", services.StateRunning)
+	report("nas", "redis", "docker", services.StateRunning)
+	report("nas", "backup.service", "systemd", services.StateStopped)
+	report("old", "app", "docker", services.StateRunning) // Host no longer configured
+	m.recordExit(serviceKey("nas", "redis"), "1", clock.UnixNano())
+
+	// The redis container disappears between discovery cycles
+	clock = clock.Add(3 * time.Minute)
+	report("nas", "nginx", "docker", services.StateRunning)
+	clock = clock.Add(3 * time.Minute)
+	m.pruneStaleServices()
+
+	removed := map[string]string{}
+	for _, e := range rec.all() {
+		if r, ok := e.(*events.ServiceRemovedEvent); ok {
+			removed[r.Host+":"+r.ServiceName] = r.Reason
+		}
+	}
+	want := map[string]string{"nas:redis": "no longer reported", "old:app": "host removed from config"}
+	if !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+	if got := m.ServiceCount(); got != 2 {
+		t.Errorf("ServiceCount() = %d, want 2 (nginx and the configured unit)", got)
+	}
+	if _, _, ok := m.LastExit("nas", "redis"); ok {
+		t.Error("exit of the forgotten container is still tracked")
+	}
+
+	// A container later reusing the name is new, not a state change
+	report("nas", "redis", "docker", services.StateStopped)
+	for _, e := range rec.all() {
+		if sc, ok := e.(*events.ServiceStateChangedEvent); ok && sc.ServiceName == "redis" {
+			t.Errorf("reused name reported as a state change: %+v", sc)
+		}
+	}
+
+	// Nothing is forgotten while the host is unreachable
+	m.handleHostError("nas", "timeout")
+	clock = clock.Add(time.Hour)
+	m.pruneStaleServices()
+	if got := m.ServiceCount(); got != 3 {
+		t.Errorf("ServiceCount() on an unreachable host = %d, want 3", got)
+	}
+}
+
+// TestPruneStaleServices_Disabled tests that the services of a disabled host
+// or systemd collection are kept until they age out rather than at once.
+func TestPruneStaleServices_Disabled(t *testing.T) {
+	disabled := false
+	tests := []struct {
+		name        string
+		unreachable bool // The host was unreachable when it was disabled
+		toggle      func(h *config.HostConfig)
+	}{
+		{"host", true, func(h *config.HostConfig) { h.Enabled = &disabled }},
+		{"systemd", false, func(h *config.HostConfig) { h.Systemd = &config.IntegrationToggle{Enabled: &disabled} }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Hosts: []config.HostConfig{
+					{Name: "nas", Address: "192.168.1.10", SystemdServices: []string{"backup.service"}},
+				},
+			}
+			m := New(cfg, events.NewBus(false), WithSkipFirstEvent(false))
+			clock := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+			m.now = func() time.Time { return clock }
+
+			m.updateServiceState(services.ServiceInfo{Name: "backup.service", Host: "nas", Source: "systemd", State: services.StateStopped})
+			if tt.unreachable {
+				m.handleHostError("nas", "timeout")
+			}
+			tt.toggle(&cfg.Hosts[0])
+
+			m.pruneStaleServices()
+			if got := m.ServiceCount(); got != 1 {
+				t.Fatalf("ServiceCount() right after disabling = %d, want 1", got)
+			}
+
+			clock = clock.Add(time.Hour)
+			m.pruneStaleServices()
+			if got := m.ServiceCount(); got != 0 {
+				t.Errorf("ServiceCount() once stale = %d, want 0", got)
+			}
+		})
+	}
+}
+
+func TestDockerEventFilter(t *testing.T) {
+	f := dockerEventFilter()
+	for _, eventType := range []string{"container", "image", "volume"} {
+		if !f.ExactMatch("type", eventType) {
+			t.Errorf("filter doesn't include type %s", eventType)
+		}
+	}
+	for _, action := range []string{"start", "die", "health_status", "pull", "delete", "create", "destroy"} {
+		if !f.ExactMatch("event", action) {
+			t.Errorf("filter doesn't include event %s", action)
+		}
+	}
+}
+
+func TestDispatchDockerEvent_ImageAndVolume(t *testing.T) {
+	m, rec := newDockerTestMonitor(time.Second)
+
+	m.dispatchDockerEvent("nas", dockerEvents.Message{Type: dockerEvents.ImageEventType, Action: "pull", Actor: dockerEvents.Actor{ID: "nginx:latest"}})
+	m.dispatchDockerEvent("nas", dockerEvents.Message{Type: dockerEvents.ImageEventType, Action: "delete", Actor: dockerEvents.Actor{ID: "sha256:abc"}})
+	m.dispatchDockerEvent("nas", dockerEvents.Message{Type: dockerEvents.VolumeEventType, Action: "destroy", Actor: dockerEvents.Actor{ID: "media_config"}})
+	// Ignored: actions only let through for other types, and unknown types
+	m.dispatchDockerEvent("nas", dockerEvents.Message{Type: dockerEvents.ImageEventType, Action: "tag", Actor: dockerEvents.Actor{ID: "sha256:abc"}})
+	m.dispatchDockerEvent("nas", dockerEvents.Message{Type: dockerEvents.VolumeEventType, Action: "start", Actor: dockerEvents.Actor{ID: "media_config"}})
+	m.dispatchDockerEvent("nas", dockerEvents.Message{Type: dockerEvents.NetworkEventType, Action: "destroy", Actor: dockerEvents.Actor{ID: "media_default"}})
+
+	got := rec.all()
+	want := []events.DockerResourceChangedEvent{
+		{Host: "nas", Resource: events.ResourceImage, Action: "pull", ID: "nginx:latest"},
+		{Host: "nas", Resource: events.ResourceImage, Action: "delete", ID: "sha256:abc"},
+		{Host: "nas", Resource: events.ResourceVolume, Action: "destroy", ID: "media_config"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d events, got %d: %+v", len(want), len(got), got)
+	}
+	for i, e := range got {
+		changed, ok := e.(*events.DockerResourceChangedEvent)
+		if !ok {
+			t.Fatalf("event %d = %T, want only resource changes", i, e)
+		}
+		if changed.Host != want[i].Host || changed.Resource != want[i].Resource || changed.Action != want[i].Action || changed.ID != want[i].ID {
+			t.Errorf("event %d = %+v, want %+v", i, changed, want[i])
+		}
+	}
+
+	// The services are left alone
+	if state, ok := m.GetServiceState("nas", "nginx"); !ok || state.State != services.StateRunning {
+		t.Errorf("nginx state = %+v, want running", state)
+	}
+}
+
+func TestDispatchDockerEvent_ContainerStarted(t *testing.T) {
+	m, _ := newDockerTestMonitor(time.Second)
+	var started []*events.ContainerStartedEvent
+	m.bus.Subscribe(events.ContainerStarted, func(e events.Event) {
+		started = append(started, e.(*events.ContainerStartedEvent))
+	})
+
+	start := dockerEvent("start", "worker", map[string]string{"name": "media-worker-2", "com.docker.compose.project": "media"})
+	start.TimeNano = time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC).UnixNano()
+	m.dispatchDockerEvent("nas", start)
+	// Not a compose container
+	m.dispatchDockerEvent("nas", dockerEvent("start", "", map[string]string{"name": "standalone"}))
+
+	if len(started) != 1 {
+		t.Fatalf("expected 1 container started event, got %d: %+v", len(started), started)
+	}
+	got := started[0]
+	if got.Host != "nas" || got.ContainerName != "media-worker-2" || got.Project != "media" || got.Service != "worker" {
+		t.Errorf("event = %+v", got)
+	}
+	if !got.StartedAt.Equal(time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)) {
+		t.Errorf("StartedAt = %v", got.StartedAt)
+	}
+}
+
+func TestDispatchDockerEvent_ContainerUnchanged(t *testing.T) {
+	m, rec := newDockerTestMonitor(time.Second)
+
+	// A container create or destroy gets through the broader filter but isn't handled
+	m.dispatchDockerEvent("nas", dockerEvent("create", "nginx", nil))
+	m.dispatchDockerEvent("nas", dockerEvent("destroy", "nginx", nil))
+	if got := rec.all(); len(got) != 0 {
+		t.Fatalf("expected no events, got %+v", got)
+	}
+
+	m.dispatchDockerEvent("nas", dockerEvent("pause", "nginx", nil))
+	m.dispatchDockerEvent("nas", dockerEvent("health_status: unhealthy", "nginx", nil))
+	got := rec.all()
+	if len(got) != 2 {
+		t.Fatalf("expected the pause and health change, got %d: %+v", len(got), got)
+	}
+	if changed, ok := got[0].(*events.ServiceStateChangedEvent); !ok || changed.CurrentState != "paused" {
+		t.Errorf("event 0 = %+v, want the pause", got[0])
+	}
+	if _, ok := got[1].(*events.ServiceHealthChangedEvent); !ok {
+		t.Errorf("event 1 = %T, want a health change", got[1])
+	}
+}
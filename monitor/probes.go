@@ -0,0 +1,378 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	containerAPI "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/events"
+	"home_server_dashboard/httpclient"
+	"home_server_dashboard/selfdetect"
+	"home_server_dashboard/services"
+	"home_server_dashboard/services/docker"
+)
+
+// maxProbeWorkers is how many health probes run at once.
+const maxProbeWorkers = 4
+
+// probeTarget is a service with a custom health probe.
+type probeTarget struct {
+	key    services.Key // Host, source, project and name, so same-named services of two projects are probed apart
+	source string       // "docker" or "systemd"
+	spec   config.ProbeSpec
+	url    string // URL to probe, empty if the service can't be probed
+	reason string // Why the service can't be probed
+	// outbound is how the probe connects: the outbound settings of the
+	// service's host
+	outbound config.Outbound
+}
+
+// prober runs the custom health probes of services, each every interval of
+// its own, and keeps their last results.
+type prober struct {
+	workers int
+	now     func() time.Time
+
+	mu      sync.Mutex
+	results map[services.Key]services.ProbeResult // key: the target's key
+	nextRun map[services.Key]time.Time            // key: the target's key
+}
+
+// newProber creates a prober.
+func newProber() *prober {
+	return &prober{
+		workers: maxProbeWorkers,
+		now:     time.Now,
+		results: make(map[services.Key]services.ProbeResult),
+		nextRun: make(map[services.Key]time.Time),
+	}
+}
+
+// due returns the targets whose probe should run now and schedules their
+// next run. A target seen for the first time is due immediately.
+func (p *prober) due(targets []probeTarget) []probeTarget {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	var due []probeTarget
+	for _, target := range targets {
+		if next, ok := p.nextRun[target.key]; ok && now.Before(next) {
+			continue
+		}
+		p.nextRun[target.key] = now.Add(target.spec.Interval)
+		due = append(due, target)
+	}
+	return due
+}
+
+// retain forgets the results of services that are no longer probed, such as
+// stopped ones.
+func (p *prober) retain(targets []probeTarget) {
+	keep := make(map[services.Key]bool, len(targets))
+	for _, target := range targets {
+		keep[target.key] = true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key := range p.nextRun {
+		if !keep[key] {
+			delete(p.nextRun, key)
+			delete(p.results, key)
+		}
+	}
+}
+
+// run probes targets, at most workers at a time, records the results and
+// passes each to report with the result before it, if there was one.
+// Results of probes cut short by ctx are dropped.
+func (p *prober) run(ctx context.Context, targets []probeTarget, report func(target probeTarget, previous *services.ProbeResult, result services.ProbeResult)) {
+	jobs := make(chan probeTarget)
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers && i < len(targets); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for target := range jobs {
+				result := p.check(ctx, target)
+				if ctx.Err() != nil {
+					continue
+				}
+				p.mu.Lock()
+				previous, ok := p.results[target.key]
+				p.results[target.key] = result
+				p.mu.Unlock()
+				if ok {
+					report(target, &previous, result)
+				} else {
+					report(target, nil, result)
+				}
+			}
+		}()
+	}
+	for _, target := range targets {
+		select {
+		case jobs <- target:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// check runs the probe of one target: a GET of its URL that must answer
+// with the status the probe expects within its timeout.
+func (p *prober) check(ctx context.Context, target probeTarget) services.ProbeResult {
+	result := services.ProbeResult{Status: services.ProbeFailing, CheckedAt: p.now()}
+	if target.url == "" {
+		result.Status, result.Detail = services.ProbeUnprobeable, target.reason
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, target.spec.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.url, nil)
+	if err != nil {
+		result.Detail = err.Error()
+		return result
+	}
+	resp, err := probeClient(target.outbound).Do(req)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			result.Detail = fmt.Sprintf("timed out after %s", target.spec.Timeout)
+		} else {
+			result.Detail = probeError(err)
+		}
+		return result
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if !target.spec.Passes(resp.StatusCode) {
+		result.Detail = "answered " + resp.Status
+		return result
+	}
+	result.Status = services.ProbePassing
+	return result
+}
+
+// probeClient returns the client probing with outbound settings out.
+// Redirects aren't followed, so a probe sees the status its URL answers with.
+func probeClient(out config.Outbound) *http.Client {
+	return &http.Client{
+		Transport:     httpclient.Transport(out),
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
+
+// probeError returns the cause of a failed request without the method and
+// URL the client wraps it in.
+func probeError(err error) string {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// result returns the last probe result of a service.
+func (p *prober) result(key services.Key) (services.ProbeResult, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	result, ok := p.results[key]
+	return result, ok
+}
+
+// forget drops the probe results of the services with the host and name of
+// key, whatever their source and project.
+func (p *prober) forget(key services.Key) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for probed := range p.nextRun {
+		if probed.Host == key.Host && probed.Name == key.Name {
+			delete(p.results, probed)
+			delete(p.nextRun, probed)
+		}
+	}
+}
+
+// hasProbes reports whether any service may have a health probe: a compose
+// container on the local host, which sets one with labels, or a systemd
+// service configured with one.
+func (m *Monitor) hasProbes() bool {
+	if m.getLocalHostConfig().HasDocker() {
+		return true
+	}
+	for i := range m.cfg.Hosts {
+		host := &m.cfg.Hosts[i]
+		if !host.HasSystemd() {
+			continue
+		}
+		for _, spec := range host.GetServiceSpecs() {
+			if spec.Probe != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// collectProbeTargets returns the running services that have a health
+// probe: compose containers on the local host with probe labels and
+// systemd services configured with one.
+func (m *Monitor) collectProbeTargets(ctx context.Context) []probeTarget {
+	var targets []probeTarget
+	if local := m.getLocalHostConfig(); local.HasDocker() {
+		if cli := m.dockerClient.Load(); cli != nil {
+			containerized := selfdetect.Get().ContainerID != ""
+			targets = append(targets, dockerProbeTargets(ctx, cli, local, m.cfg.OutboundFor(local), containerized)...)
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for i := range m.cfg.Hosts {
+		host := &m.cfg.Hosts[i]
+		if !host.HasSystemd() {
+			continue
+		}
+		for _, spec := range host.GetServiceSpecs() {
+			if spec.Probe == nil || m.serviceStates[serviceKey(host.Name, spec.UnitName)].State != services.StateRunning {
+				continue
+			}
+			key := services.Key{Host: host.Name, Source: "systemd", Name: spec.UnitName}
+			targets = append(targets, probeTarget{key: key, source: "systemd", spec: *spec.Probe, url: spec.Probe.URL, outbound: m.cfg.OutboundFor(host)})
+		}
+	}
+	return targets
+}
+
+// dockerProbeTargets returns the running compose containers of the local
+// host with probe labels, listed with cli. Containers with invalid labels
+// or whose probe port isn't published are returned without a URL, so they
+// are reported as unprobeable.
+//
+// Ports published on all addresses are probed on the loopback address, or
+// if the dashboard is containerized, where the loopback address is its own
+// container's, on the gateway of the probed container's network, an
+// address of the Docker host.
+func dockerProbeTargets(ctx context.Context, cli containerLister, host *config.HostConfig, outbound config.Outbound, containerized bool) []probeTarget {
+	hostName := host.Name
+	containers, err := cli.ContainerList(ctx, containerAPI.ListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", "com.docker.compose.project"),
+			filters.Arg("label", docker.LabelProbeURL),
+			filters.Arg("status", "running"),
+		),
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Monitor: failed to list containers with health probes on %s: %v", hostName, err)
+		}
+		return nil
+	}
+
+	var targets []probeTarget
+	for _, ctr := range containers {
+		serviceName := ctr.Labels["com.docker.compose.service"]
+		if serviceName == "" {
+			continue
+		}
+		key := services.Key{Host: hostName, Source: "docker", Project: ctr.Labels["com.docker.compose.project"], Name: serviceName}
+		target := probeTarget{key: key, source: "docker", outbound: outbound}
+		spec, err := docker.ProbeFromLabels(ctr.Labels)
+		if err != nil {
+			target.spec = config.ProbeSpec{Interval: config.DefaultProbeInterval}
+			target.reason = err.Error()
+			targets = append(targets, target)
+			continue
+		}
+		target.spec = *spec
+		wildcardHost := "127.0.0.1"
+		if containerized {
+			wildcardHost = docker.HostGateway(ctr)
+		}
+		if resolved, ok := docker.ResolveProbeURL(spec.URL, ctr.Ports, wildcardHost); ok {
+			target.url = resolved
+		} else {
+			target.reason = "the probe's container port isn't published"
+			if containerized && wildcardHost == "" {
+				target.reason += " on an address the dashboard's container can reach"
+			}
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// probeServices runs the health probes that are due.
+func (m *Monitor) probeServices() {
+	ctx, cancel := context.WithTimeout(m.ctx, config.MinProbeInterval)
+	targets := m.probeTargets(ctx)
+	cancel()
+	if m.ctx.Err() != nil {
+		return
+	}
+	m.probes.retain(targets)
+	m.probes.run(m.ctx, m.probes.due(targets), m.reportProbe)
+}
+
+// probeHealth is the health a probe result reports, as health checks name
+// it, or "" if the service couldn't be probed.
+func probeHealth(result *services.ProbeResult) string {
+	if result == nil {
+		return ""
+	}
+	switch result.Status {
+	case services.ProbePassing:
+		return "healthy"
+	case services.ProbeFailing:
+		return "unhealthy"
+	}
+	return ""
+}
+
+// reportProbe emits a health change event when a probe starts or stops
+// failing, as updateServiceHealth does for health checks. The first result
+// of a passing probe is not reported, and neither are unprobeable services.
+func (m *Monitor) reportProbe(target probeTarget, previous *services.ProbeResult, result services.ProbeResult) {
+	m.mu.RLock()
+	skipFirst := m.skipFirstEvent
+	m.mu.RUnlock()
+
+	before, health := probeHealth(previous), probeHealth(&result)
+	if health == "" || before == health || skipFirst {
+		return
+	}
+	if before == "" && health != "unhealthy" {
+		return
+	}
+
+	host, name := target.key.Host, target.key.Name
+	m.publish(events.NewServiceHealthChangedEvent(host, name, target.source, before, health))
+	log.Printf("Monitor: service probe health change - %s on %s: %s → %s (%s)", name, host, before, health, result.Detail)
+}
+
+// ProbeResult returns the last result of the custom health probe of the
+// service with key, by its host, source, project and name. It returns false
+// if the service has no probe or it hasn't run yet. Implements
+// handlers.ProbeReporter.
+func (m *Monitor) ProbeResult(key services.Key) (services.ProbeResult, bool) {
+	return m.probes.result(key)
+}
+
+// containerLister lists containers, as the Docker client does.
+type containerLister interface {
+	ContainerList(ctx context.Context, options containerAPI.ListOptions) ([]containerAPI.Summary, error)
+}
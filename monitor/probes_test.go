@@ -0,0 +1,277 @@
+package monitor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	containerAPI "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/events"
+	"home_server_dashboard/services"
+	"home_server_dashboard/services/docker"
+)
+
+// probeTo returns a target probing url with spec's timeout.
+func probeTo(name, url string, spec config.ProbeSpec) probeTarget {
+	return probeTarget{key: serviceKey("nas", name), source: "docker", spec: spec, url: url}
+}
+
+func TestProberCheck(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	spec := config.ProbeSpec{Interval: time.Minute, Timeout: time.Second}
+	short := config.ProbeSpec{Interval: time.Minute, Timeout: 50 * time.Millisecond}
+	expects503 := config.ProbeSpec{Interval: time.Minute, Timeout: time.Second, ExpectStatus: http.StatusServiceUnavailable}
+
+	tests := []struct {
+		name       string
+		target     probeTarget
+		wantStatus string
+		wantDetail string
+	}{
+		{"pass", probeTo("web", ok.URL, spec), services.ProbePassing, ""},
+		{"wrong status", probeTo("web", down.URL, spec), services.ProbeFailing, "answered 503 Service Unavailable"},
+		{"expected status", probeTo("web", down.URL, expects503), services.ProbePassing, ""},
+		{"timeout", probeTo("web", slow.URL, short), services.ProbeFailing, "timed out after 50ms"},
+		{"unpublished port", probeTarget{key: serviceKey("nas", "web"), spec: spec, reason: "the probe's container port isn't published"}, services.ProbeUnprobeable, "the probe's container port isn't published"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newProber().check(context.Background(), tt.target)
+			if got.Status != tt.wantStatus || got.Detail != tt.wantDetail {
+				t.Errorf("check() = %q (%q), want %q (%q)", got.Status, got.Detail, tt.wantStatus, tt.wantDetail)
+			}
+			if got.CheckedAt.IsZero() {
+				t.Error("CheckedAt not set")
+			}
+		})
+	}
+}
+
+func TestProberCheck_RedirectNotFollowed(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer target.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	defer redirect.Close()
+
+	got := newProber().check(context.Background(), probeTo("web", redirect.URL, config.ProbeSpec{Interval: time.Minute, Timeout: time.Second}))
+	if got.Status != services.ProbePassing {
+		t.Errorf("check() = %q (%q), want the redirect to pass", got.Status, got.Detail)
+	}
+}
+
+func TestProberRun_LimitsConcurrency(t *testing.T) {
+	var running, maxRun atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			max := maxRun.Load()
+			if n <= max || maxRun.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	p := newProber()
+	p.workers = 2
+	var targets []probeTarget
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		targets = append(targets, probeTo(name, srv.URL, config.ProbeSpec{Interval: time.Minute, Timeout: time.Second}))
+	}
+
+	var mu sync.Mutex
+	reported := 0
+	p.run(context.Background(), targets, func(probeTarget, *services.ProbeResult, services.ProbeResult) {
+		mu.Lock()
+		reported++
+		mu.Unlock()
+	})
+
+	if reported != 5 {
+		t.Errorf("reported %d results, want 5", reported)
+	}
+	if got := maxRun.Load(); got > 2 {
+		t.Errorf("%d probes ran at once, want at most 2", got)
+	}
+	if result, ok := p.result(serviceKey("nas", "c")); !ok || result.Status != services.ProbePassing {
+		t.Errorf("result(c) = %+v, %v", result, ok)
+	}
+}
+
+func TestProberDue(t *testing.T) {
+	clock := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	p := newProber()
+	p.now = func() time.Time { return clock }
+	fast := probeTo("fast", "http://127.0.0.1:1/", config.ProbeSpec{Interval: 10 * time.Second})
+	slow := probeTo("slow", "http://127.0.0.1:1/", config.ProbeSpec{Interval: time.Minute})
+	targets := []probeTarget{fast, slow}
+
+	if got := p.due(targets); len(got) != 2 {
+		t.Fatalf("first due() = %d targets, want 2", len(got))
+	}
+	clock = clock.Add(5 * time.Second)
+	if got := p.due(targets); len(got) != 0 {
+		t.Errorf("due() after 5s = %d targets, want 0", len(got))
+	}
+	clock = clock.Add(5 * time.Second)
+	if got := p.due(targets); len(got) != 1 || got[0].key.Name != "fast" {
+		t.Errorf("due() after 10s = %+v, want fast only", got)
+	}
+
+	p.results[slow.key] = services.ProbeResult{Status: services.ProbePassing}
+	p.retain([]probeTarget{fast})
+	if _, ok := p.result(slow.key); ok {
+		t.Error("result of a service no longer probed was kept")
+	}
+	if got := p.due([]probeTarget{slow}); len(got) != 1 {
+		t.Error("a service probed again wasn't due immediately")
+	}
+}
+
+func TestMonitorProbeServices(t *testing.T) {
+	healthy := atomic.Bool{}
+	healthy.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	bus := events.NewBus(false)
+	var mu sync.Mutex
+	var received []*events.ServiceHealthChangedEvent
+	bus.Subscribe(events.ServiceHealthChanged, func(event events.Event) {
+		mu.Lock()
+		received = append(received, event.(*events.ServiceHealthChangedEvent))
+		mu.Unlock()
+	})
+
+	m := New(&config.Config{Hosts: []config.HostConfig{{Name: "nas", Address: "localhost"}}}, bus, WithSkipFirstEvent(false))
+	m.ctx = context.Background()
+	clock := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	m.probes.now = func() time.Time { return clock }
+	spec := config.ProbeSpec{URL: srv.URL, Interval: 10 * time.Second, Timeout: time.Second}
+	m.probeTargets = func(context.Context) []probeTarget {
+		return []probeTarget{
+			{key: services.Key{Host: "nas", Source: "docker", Project: "site", Name: "web"}, source: "docker", spec: spec, url: srv.URL},
+			{key: services.Key{Host: "nas", Source: "docker", Project: "site", Name: "db"}, source: "docker", spec: spec, reason: "the probe's container port isn't published"},
+		}
+	}
+
+	step := func() {
+		m.probeServices()
+		clock = clock.Add(spec.Interval)
+	}
+	step() // Passing at first, not reported
+	healthy.Store(false)
+	step()
+	step() // Still failing, not reported again
+	healthy.Store(true)
+	step()
+
+	mu.Lock()
+	defer mu.Unlock()
+	var got []string
+	for _, event := range received {
+		got = append(got, event.ServiceName+":"+event.PreviousHealth+"→"+event.CurrentHealth)
+	}
+	if want := "web:healthy→unhealthy web:unhealthy→healthy"; strings.Join(got, " ") != want {
+		t.Errorf("events = %v, want %s", got, want)
+	}
+	if result, ok := m.ProbeResult(services.Key{Host: "nas", Source: "docker", Project: "site", Name: "db"}); !ok || result.Status != services.ProbeUnprobeable {
+		t.Errorf("ProbeResult(nas, db) = %+v, %v, want unprobeable", result, ok)
+	}
+
+	m.forgetService(serviceKey("nas", "web"))
+	if _, ok := m.ProbeResult(services.Key{Host: "nas", Source: "docker", Project: "site", Name: "web"}); ok {
+		t.Error("probe result kept after the service was forgotten")
+	}
+}
+
+func TestCollectProbeTargets_Systemd(t *testing.T) {
+	cfg := &config.Config{
+		Hosts: []config.HostConfig{
+			{Name: "pi", Address: "192.168.1.20", SystemdServices: []string{
+				"zunesync.service|probe=http://192.168.1.20:8080/health",
+				"backup.service|probe=http://192.168.1.20:9090/",
+				"docker.service",
+			}},
+		},
+	}
+	m := New(cfg, events.NewBus(false))
+	m.updateServiceState(services.ServiceInfo{Name: "zunesync.service", Host: "pi", Source: "systemd", State: services.StateRunning})
+	m.updateServiceState(services.ServiceInfo{Name: "backup.service", Host: "pi", Source: "systemd", State: services.StateStopped})
+	m.updateServiceState(services.ServiceInfo{Name: "docker.service", Host: "pi", Source: "systemd", State: services.StateRunning})
+
+	targets := m.collectProbeTargets(context.Background())
+	if len(targets) != 1 || targets[0].key != (services.Key{Host: "pi", Source: "systemd", Name: "zunesync.service"}) || targets[0].url != "http://192.168.1.20:8080/health" || targets[0].source != "systemd" {
+		t.Errorf("collectProbeTargets() = %+v, want the running zunesync.service only", targets)
+	}
+	if !m.hasProbes() {
+		t.Error("hasProbes() = false with a systemd probe configured")
+	}
+}
+
+func TestDockerProbeTargets(t *testing.T) {
+	probed := func(id, project, ip string) containerAPI.Summary {
+		return containerAPI.Summary{
+			ID: id,
+			Labels: map[string]string{
+				"com.docker.compose.project": project,
+				"com.docker.compose.service": "web",
+				docker.LabelProbeURL:         "http://container:8080/health",
+			},
+			Ports: []containerAPI.Port{{IP: ip, PrivatePort: 8080, PublicPort: 18080, Type: "tcp"}},
+			NetworkSettings: &containerAPI.NetworkSettingsSummary{Networks: map[string]*network.EndpointSettings{
+				project + "_default": {Gateway: "172.18.0.1"},
+			}},
+		}
+	}
+	client := &fakeStatsClient{containers: []containerAPI.Summary{probed("a", "blog", "0.0.0.0"), probed("b", "shop", "192.168.1.10")}}
+	host := &config.HostConfig{Name: "nas", Address: "localhost"}
+
+	targets := dockerProbeTargets(context.Background(), client, host, config.Outbound{}, false)
+	if len(targets) != 2 || targets[0].key == targets[1].key {
+		t.Fatalf("dockerProbeTargets() = %+v, want a target per project", targets)
+	}
+	if want := (services.Key{Host: "nas", Source: "docker", Project: "blog", Name: "web"}); targets[0].key != want {
+		t.Errorf("key = %+v, want %+v", targets[0].key, want)
+	}
+	if targets[0].url != "http://127.0.0.1:18080/health" || targets[1].url != "http://192.168.1.10:18080/health" {
+		t.Errorf("URLs = %s, %s", targets[0].url, targets[1].url)
+	}
+
+	// In a container, the loopback address is the dashboard's own
+	targets = dockerProbeTargets(context.Background(), client, host, config.Outbound{}, true)
+	if targets[0].url != "http://172.18.0.1:18080/health" {
+		t.Errorf("URL from a container = %s, want the Docker host's gateway address", targets[0].url)
+	}
+}
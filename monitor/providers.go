@@ -0,0 +1,98 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/services"
+)
+
+// pollSources returns the registered sources the monitor polls on host:
+// those with Capabilities.Poll that are configured there.
+func pollSources(host *config.HostConfig) []services.Registration {
+	var regs []services.Registration
+	for _, reg := range services.Registered() {
+		if reg.Poll && reg.Factory != nil && reg.IsConfigured(host) {
+			regs = append(regs, reg)
+		}
+	}
+	return regs
+}
+
+// hasProviderHosts returns true if any host has a polled source.
+func (m *Monitor) hasProviderHosts() bool {
+	for i := range m.cfg.Hosts {
+		if len(pollSources(&m.cfg.Hosts[i])) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// pollProviderHosts polls the registered sources that have no event watcher.
+func (m *Monitor) pollProviderHosts() {
+	defer m.wg.Done()
+
+	// Wait for initial discovery to complete before polling
+	if !m.sleep(m.pollStartDelay) {
+		return
+	}
+
+	m.pollLoop(m.providerSchedule, m.pollProviders)
+}
+
+// pollProviders fetches current service states from the hosts with polled sources that are due.
+func (m *Monitor) pollProviders() {
+	for i := range m.cfg.Hosts {
+		host := &m.cfg.Hosts[i]
+		if len(pollSources(host)) == 0 {
+			continue
+		}
+
+		m.pollHost(m.providerSchedule, host, m.providerPoll)
+	}
+
+	m.markDiscoveryComplete()
+}
+
+// pollProviderHost fetches the services of every polled source on one host.
+func (m *Monitor) pollProviderHost(ctx context.Context, host *config.HostConfig) error {
+	var errs []error
+	for _, reg := range pollSources(host) {
+		svcList, err := pollSource(ctx, m.cfg, reg, host)
+		if m.stopping() {
+			return err
+		}
+		if err != nil {
+			log.Printf("Monitor: failed to poll %s on %s: %v", reg.Source, host.Name, err)
+			errs = append(errs, err)
+			continue
+		}
+		for _, svc := range svcList {
+			m.updateServiceState(svc)
+		}
+	}
+
+	err := errors.Join(errs...)
+	if err != nil {
+		m.handleHostError(host.Name, err.Error())
+	} else {
+		m.handleHostSuccess(host.Name)
+	}
+	return err
+}
+
+// pollSource creates the provider of reg for host and lists its services.
+func pollSource(ctx context.Context, cfg *config.Config, reg services.Registration, host *config.HostConfig) ([]services.ServiceInfo, error) {
+	provider, err := reg.Factory(cfg, host)
+	if err != nil || provider == nil {
+		return nil, err
+	}
+	if closer, ok := provider.(io.Closer); ok && reg.NeedsClose {
+		defer closer.Close()
+	}
+	return provider.GetServices(ctx)
+}
@@ -0,0 +1,120 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/events"
+	"home_server_dashboard/services"
+)
+
+// polledProvider is a provider for a polled source registered only in tests.
+type polledProvider struct {
+	svcs []services.ServiceInfo
+	err  error
+}
+
+func (p *polledProvider) Name() string { return "polled" }
+
+func (p *polledProvider) GetServices(ctx context.Context) ([]services.ServiceInfo, error) {
+	return p.svcs, p.err
+}
+
+func (p *polledProvider) GetService(name string) (services.Service, error) {
+	return nil, errors.New("not supported")
+}
+
+func (p *polledProvider) GetLogs(ctx context.Context, serviceName string, tailLines int, follow bool) (io.ReadCloser, error) {
+	return nil, errors.New("not supported")
+}
+
+// registerPolledSource registers the "polled" source for hosts named "polledhost".
+func registerPolledSource(t *testing.T, provider *polledProvider) {
+	t.Helper()
+	services.Register(services.Registration{
+		Source: "polled",
+		Order:  100,
+		Configured: func(host *config.HostConfig) bool {
+			return host.Name == "polledhost"
+		},
+		Factory: func(cfg *config.Config, host *config.HostConfig) (services.Provider, error) {
+			return provider, nil
+		},
+		Capabilities: services.Capabilities{Poll: true},
+	})
+	t.Cleanup(func() { services.Unregister("polled") })
+}
+
+func TestPollProviderHost(t *testing.T) {
+	provider := &polledProvider{svcs: []services.ServiceInfo{
+		{Name: "widget", Host: "polledhost", Source: "polled", State: "running", Status: "Up"},
+	}}
+	registerPolledSource(t, provider)
+
+	cfg := &config.Config{
+		Hosts: []config.HostConfig{
+			{Name: "polledhost", Address: "192.168.1.50"},
+			{Name: "other", Address: "192.168.1.51"},
+		},
+	}
+	bus := events.NewBus(false)
+	m := New(cfg, bus, WithSkipFirstEvent(false))
+	rec := recordEvents(bus)
+
+	if !m.hasProviderHosts() {
+		t.Fatal("hasProviderHosts() = false with a polled source configured")
+	}
+	if got := pollSources(&cfg.Hosts[1]); len(got) != 0 {
+		t.Errorf("pollSources(other) = %v, want none", got)
+	}
+
+	if err := m.pollProviderHost(context.Background(), &cfg.Hosts[0]); err != nil {
+		t.Fatalf("pollProviderHost() = %v", err)
+	}
+	if state, ok := m.GetServiceState("polledhost", "widget"); !ok || state.State != "running" {
+		t.Errorf("widget state = %+v, %v", state, ok)
+	}
+
+	// A state change is published like any other source's
+	provider.svcs[0].State = "stopped"
+	provider.svcs[0].Status = "Exited (1)"
+	m.pollProviderHost(context.Background(), &cfg.Hosts[0])
+	var changed int
+	for _, e := range rec.all() {
+		if sc, ok := e.(*events.ServiceStateChangedEvent); ok && sc.ServiceName == "widget" && sc.CurrentState == "stopped" {
+			changed++
+		}
+	}
+	if changed != 1 {
+		t.Errorf("got %d stop events for widget, want 1: %+v", changed, rec.all())
+	}
+
+	// Failures mark the host unreachable
+	provider.err = errors.New("boom")
+	if err := m.pollProviderHost(context.Background(), &cfg.Hosts[0]); err == nil {
+		t.Fatal("pollProviderHost() succeeded with a failing provider")
+	}
+	if state, ok := m.GetHostState("polledhost"); !ok || state.Reachable {
+		t.Errorf("host state after failure = %+v, %v", state, ok)
+	}
+}
+
+func TestPollSources_DisabledHost(t *testing.T) {
+	registerPolledSource(t, &polledProvider{})
+
+	disabled := false
+	cfg := &config.Config{
+		Hosts: []config.HostConfig{{Name: "polledhost", Address: "192.168.1.50", Enabled: &disabled}},
+	}
+	m := New(cfg, events.NewBus(false), WithSkipFirstEvent(false))
+
+	if got := pollSources(&cfg.Hosts[0]); len(got) != 0 {
+		t.Errorf("pollSources() on a disabled host = %v, want none", got)
+	}
+	if m.hasProviderHosts() {
+		t.Error("hasProviderHosts() = true with the only host disabled")
+	}
+}
@@ -0,0 +1,151 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"home_server_dashboard/notices"
+)
+
+// Readiness phases of a capability.
+const (
+	PhaseInitializing = "initializing" // Not ready yet, within the startup grace period
+	PhaseReady        = "ready"
+	PhaseFailed       = "failed"   // Not ready after the startup grace period, or lost since; still retried
+	PhaseDisabled     = "disabled" // Turned off in the config
+)
+
+// Capabilities the monitor initializes in the background.
+const (
+	CapabilityDockerEvents = "docker_events"
+	CapabilitySystemdDBus  = "systemd_dbus"
+)
+
+// Retry timing of capability initialization: the first retry waits
+// initBackoff, doubling up to maxInitBackoff. A capability is reported as
+// initializing for initGracePeriod after Start, so a dashboard started at
+// boot before dockerd isn't reported as failed.
+const (
+	defaultInitBackoff = time.Second
+	maxInitBackoff     = time.Minute
+	initGracePeriod    = 2 * time.Minute
+)
+
+// errDisabled is returned by an init function whose capability is turned
+// off in the config. It isn't retried.
+var errDisabled = errors.New("disabled")
+
+// Capability is the readiness of an event source the monitor connects to.
+type Capability struct {
+	Name     string    `json:"name"`
+	Phase    string    `json:"phase"`
+	Attempts int       `json:"attempts"` // Initialization attempts, counting the one that succeeded
+	Since    time.Time `json:"since"`    // When it entered the phase
+}
+
+// readiness keeps the capabilities in the order they were registered.
+type readiness struct {
+	mu   sync.Mutex
+	caps []*Capability
+}
+
+// set records the phase and attempts of the named capability, registering
+// it if it is new.
+func (r *readiness) set(name, phase string, attempts int, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.caps {
+		if c.Name == name {
+			if c.Phase != phase {
+				c.Phase, c.Since = phase, now
+			}
+			c.Attempts = attempts
+			return
+		}
+	}
+	r.caps = append(r.caps, &Capability{Name: name, Phase: phase, Attempts: attempts, Since: now})
+}
+
+// list returns a copy of the capabilities.
+func (r *readiness) list() []Capability {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	caps := make([]Capability, len(r.caps))
+	for i, c := range r.caps {
+		caps[i] = *c
+	}
+	return caps
+}
+
+// capabilityNoticeKey returns the key of the notice about the named
+// capability failing.
+func capabilityNoticeKey(name string) string {
+	return "monitor:" + name
+}
+
+// whenReady initializes the named capability in the background, retrying
+// until init succeeds, and then runs run, the loop that depends on it.
+func (m *Monitor) whenReady(name string, init func() error, run func()) {
+	m.readiness.set(name, PhaseInitializing, 0, m.now())
+	graceEnd := m.now().Add(m.initGrace)
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		if m.retryInit(name, init, graceEnd) {
+			run()
+		}
+	}()
+}
+
+// retryInit runs init until it succeeds, waiting longer after each failure,
+// and records the progress as the readiness of the named capability: while
+// failing, it is initializing until graceEnd and failed after. It returns
+// false if the monitor stopped first or the capability is disabled.
+func (m *Monitor) retryInit(name string, init func() error, graceEnd time.Time) bool {
+	delay := m.initBackoff
+	for attempt := 1; ; attempt++ {
+		err := init()
+		if err == nil {
+			m.readiness.set(name, PhaseReady, attempt, m.now())
+			if m.notices != nil {
+				m.notices.Resolve(capabilityNoticeKey(name))
+			}
+			if attempt > 1 {
+				log.Printf("Monitor: %s ready after %d attempts", name, attempt)
+			}
+			return true
+		}
+		if errors.Is(err, errDisabled) {
+			m.readiness.set(name, PhaseDisabled, attempt, m.now())
+			return false
+		}
+
+		phase := PhaseInitializing
+		if !m.now().Before(graceEnd) {
+			phase = PhaseFailed
+			if m.notices != nil {
+				m.notices.Post(notices.SeverityError, "monitor", capabilityNoticeKey(name),
+					fmt.Sprintf("%s is not ready after %d attempts: %v", name, attempt, err))
+			}
+		}
+		m.readiness.set(name, phase, attempt, m.now())
+		log.Printf("Monitor: %s not ready (attempt %d), retrying in %s: %v", name, attempt, delay, err)
+
+		select {
+		case <-m.ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxInitBackoff)
+	}
+}
+
+// Readiness returns the readiness of the event sources the monitor
+// connects to, in the order they are started. Implements
+// handlers.ReadinessReporter.
+func (m *Monitor) Readiness() []Capability {
+	return m.readiness.list()
+}
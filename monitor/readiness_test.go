@@ -0,0 +1,178 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/events"
+	"home_server_dashboard/notices"
+)
+
+// newReadinessTestMonitor returns a started-looking monitor that retries
+// capabilities right away.
+func newReadinessTestMonitor(t *testing.T) *Monitor {
+	t.Helper()
+	m := New(&config.Config{}, events.NewBus(false))
+	m.initBackoff = time.Millisecond
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		m.cancel()
+		m.wg.Wait()
+	})
+	return m
+}
+
+// flakyInit returns an init function failing the first failures calls, and
+// a channel receiving the number of each call.
+func flakyInit(failures int) (func() error, <-chan int) {
+	calls := make(chan int, failures+1)
+	n := 0
+	return func() error {
+		n++
+		calls <- n
+		if n <= failures {
+			return errors.New("connection refused")
+		}
+		return nil
+	}, calls
+}
+
+// capability returns the readiness of the named capability.
+func capability(t *testing.T, m *Monitor, name string) Capability {
+	t.Helper()
+	for _, c := range m.Readiness() {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("no capability %s in %+v", name, m.Readiness())
+	return Capability{}
+}
+
+// waitFor waits for done to be closed.
+func waitFor(t *testing.T, done <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func TestWhenReady_StartsAfterTransientFailures(t *testing.T) {
+	m := newReadinessTestMonitor(t)
+	init, calls := flakyInit(3)
+	// The phase is checked while each retry runs, before it succeeds
+	phases := make(chan Capability, 4)
+	started := make(chan struct{})
+	m.whenReady(CapabilityDockerEvents, func() error {
+		phases <- capability(t, m, CapabilityDockerEvents)
+		return init()
+	}, func() { close(started) })
+
+	waitFor(t, started, "the watcher to start")
+	if n := len(calls); n != 4 {
+		t.Errorf("init called %d times, want 4", n)
+	}
+	close(phases)
+	attempts := 0
+	for c := range phases {
+		if c.Phase != PhaseInitializing || c.Attempts != attempts {
+			t.Errorf("before attempt %d: %+v, want initializing after %d attempts", attempts+1, c, attempts)
+		}
+		attempts++
+	}
+	if c := capability(t, m, CapabilityDockerEvents); c.Phase != PhaseReady || c.Attempts != 4 {
+		t.Errorf("after starting: %+v, want ready after 4 attempts", c)
+	}
+}
+
+func TestWhenReady_FailedAfterGracePeriod(t *testing.T) {
+	m := newReadinessTestMonitor(t)
+	m.initGrace = 0
+	m.initBackoff = time.Hour // Stays failed until the test ends
+	init, calls := flakyInit(1)
+	started := make(chan struct{})
+	m.whenReady(CapabilitySystemdDBus, init, func() { close(started) })
+
+	<-calls
+	deadline := time.Now().Add(2 * time.Second)
+	for capability(t, m, CapabilitySystemdDBus).Phase != PhaseFailed && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if c := capability(t, m, CapabilitySystemdDBus); c.Phase != PhaseFailed || c.Attempts != 1 {
+		t.Errorf("after the grace period: %+v, want failed after 1 attempt", c)
+	}
+	select {
+	case <-started:
+		t.Error("watcher started without its dependency")
+	default:
+	}
+
+	// Stopping the monitor ends the retries without starting the watcher
+	m.cancel()
+	m.wg.Wait()
+	select {
+	case <-started:
+		t.Error("watcher started after stopping")
+	default:
+	}
+}
+
+func TestWhenReady_Disabled(t *testing.T) {
+	m := newReadinessTestMonitor(t)
+	calls := 0
+	m.whenReady(CapabilitySystemdDBus, func() error {
+		calls++
+		return errDisabled
+	}, func() { t.Error("watcher started for a disabled capability") })
+	m.wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("init called %d times, want 1", calls)
+	}
+	if c := capability(t, m, CapabilitySystemdDBus); c.Phase != PhaseDisabled {
+		t.Errorf("capability = %+v, want disabled", c)
+	}
+}
+
+func TestReadiness_Order(t *testing.T) {
+	m := newReadinessTestMonitor(t)
+	m.whenReady(CapabilityDockerEvents, func() error { return nil }, func() {})
+	m.whenReady(CapabilitySystemdDBus, func() error { return errDisabled }, func() {})
+	m.wg.Wait()
+
+	caps := m.Readiness()
+	if len(caps) != 2 || caps[0].Name != CapabilityDockerEvents || caps[1].Name != CapabilitySystemdDBus {
+		t.Errorf("Readiness() = %+v", caps)
+	}
+}
+
+func TestWhenReady_Notices(t *testing.T) {
+	m := newReadinessTestMonitor(t)
+	m.initGrace = 0
+	board := notices.NewStore(notices.DefaultExpiryDays)
+	m.notices = board
+
+	// A capability failing after the grace period is posted, and resolved
+	// once it connects
+	init, _ := flakyInit(2)
+	failures := make(chan []notices.Notice, 3)
+	started := make(chan struct{})
+	m.whenReady(CapabilityDockerEvents, func() error {
+		failures <- board.Active()
+		return init()
+	}, func() { close(started) })
+	waitFor(t, started, "the watcher to start")
+
+	<-failures
+	if list := <-failures; len(list) != 1 || list[0].Key != "monitor:docker_events" || list[0].Severity != notices.SeverityError {
+		t.Errorf("notices while failing = %+v", list)
+	}
+	if list := board.Active(); len(list) != 0 {
+		t.Errorf("notices once ready = %+v", list)
+	}
+}
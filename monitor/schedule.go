@@ -0,0 +1,121 @@
+package monitor
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultMaxPollBackoff caps how far polling of an unreachable host backs off.
+const defaultMaxPollBackoff = 15 * time.Minute
+
+// hostSchedule tracks when a host is next polled.
+type hostSchedule struct {
+	interval time.Duration // Configured poll interval
+	current  time.Duration // Effective interval, grows while the host keeps failing
+	nextPoll time.Time
+	failures int // Consecutive failed polls
+}
+
+// pollScheduler keeps per-host poll times for one poll loop. A host that
+// fails has its interval doubled after each failure, up to maxBackoff, and
+// returns to its configured interval on the next successful poll.
+type pollScheduler struct {
+	mu         sync.Mutex
+	now        func() time.Time
+	maxBackoff time.Duration
+	hosts      map[string]*hostSchedule // key: hostname
+}
+
+// newPollScheduler creates a scheduler using now as its clock.
+func newPollScheduler(now func() time.Time, maxBackoff time.Duration) *pollScheduler {
+	return &pollScheduler{
+		now:        now,
+		maxBackoff: maxBackoff,
+		hosts:      make(map[string]*hostSchedule),
+	}
+}
+
+// due reports whether host should be polled now. A host seen for the first
+// time is due immediately. A changed interval applies from the next poll
+// unless the host is backing off.
+func (s *pollScheduler) due(host string, interval time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sched, ok := s.hosts[host]
+	if !ok {
+		s.hosts[host] = &hostSchedule{interval: interval, current: interval}
+		return true
+	}
+	if sched.interval != interval {
+		sched.interval = interval
+		if sched.failures == 0 {
+			sched.current = interval
+		}
+	}
+	return !s.now().Before(sched.nextPoll)
+}
+
+// record schedules the next poll of host after a poll finished with err.
+// It returns the effective interval before and after the poll.
+func (s *pollScheduler) record(host string, err error) (before, after time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sched, ok := s.hosts[host]
+	if !ok {
+		return 0, 0
+	}
+	before = sched.current
+
+	if err == nil {
+		sched.failures = 0
+		sched.current = sched.interval
+	} else {
+		sched.failures++
+		limit := s.maxBackoff
+		if limit < sched.interval {
+			limit = sched.interval
+		}
+		sched.current *= 2
+		if sched.current > limit {
+			sched.current = limit
+		}
+	}
+	sched.nextPoll = s.now().Add(sched.current)
+	return before, sched.current
+}
+
+// untilNext returns how long until the next host is due, or fallback if no
+// hosts have been scheduled yet.
+func (s *pollScheduler) untilNext(fallback time.Duration) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.hosts) == 0 {
+		return fallback
+	}
+	var next time.Time
+	for _, sched := range s.hosts {
+		if next.IsZero() || sched.nextPoll.Before(next) {
+			next = sched.nextPoll
+		}
+	}
+	wait := next.Sub(s.now())
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// get returns a copy of the schedule for host.
+func (s *pollScheduler) get(host string) (hostSchedule, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sched, ok := s.hosts[host]
+	if !ok {
+		return hostSchedule{}, false
+	}
+	return *sched, true
+}
@@ -0,0 +1,178 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/events"
+)
+
+// fakeClock is a manually advanced clock for driving poll schedules.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestPollScheduler_BackoffAndRecovery(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	s := newPollScheduler(clock.now, 15*time.Minute)
+	interval := 2 * time.Minute
+	errDown := errors.New("connection refused")
+
+	if !s.due("pi", interval) {
+		t.Fatal("new host should be due immediately")
+	}
+	if _, after := s.record("pi", nil); after != interval {
+		t.Errorf("interval after success = %v, want %v", after, interval)
+	}
+	if s.due("pi", interval) {
+		t.Error("host should not be due right after a poll")
+	}
+
+	// Each failure doubles the interval until it reaches the cap
+	want := []time.Duration{4 * time.Minute, 8 * time.Minute, 15 * time.Minute, 15 * time.Minute}
+	for i, w := range want {
+		clock.advance(time.Hour)
+		if !s.due("pi", interval) {
+			t.Fatalf("failure %d: host should be due after its interval", i+1)
+		}
+		_, after := s.record("pi", errDown)
+		if after != w {
+			t.Errorf("failure %d: interval = %v, want %v", i+1, after, w)
+		}
+	}
+
+	// The host isn't polled again until the backed-off interval has passed
+	clock.advance(14 * time.Minute)
+	if s.due("pi", interval) {
+		t.Error("host polled before its backoff expired")
+	}
+	clock.advance(time.Minute)
+	if !s.due("pi", interval) {
+		t.Error("host not due once its backoff expired")
+	}
+
+	// A success resets to the configured interval
+	before, after := s.record("pi", nil)
+	if before != 15*time.Minute || after != interval {
+		t.Errorf("recovery = %v -> %v, want 15m0s -> %v", before, after, interval)
+	}
+	if hs, _ := s.get("pi"); hs.failures != 0 || !hs.nextPoll.Equal(clock.now().Add(interval)) {
+		t.Errorf("schedule after recovery = %+v", hs)
+	}
+}
+
+func TestPollScheduler_LongIntervalIsNotCapped(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	s := newPollScheduler(clock.now, 15*time.Minute)
+
+	s.due("pi", time.Hour)
+	if _, after := s.record("pi", errors.New("down")); after != time.Hour {
+		t.Errorf("interval = %v, want the configured 1h0m0s", after)
+	}
+}
+
+func TestPollScheduler_IntervalChange(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	s := newPollScheduler(clock.now, 15*time.Minute)
+
+	s.due("nas", time.Minute)
+	s.record("nas", nil)
+	clock.advance(time.Minute)
+	s.due("nas", 30*time.Second)
+	if _, after := s.record("nas", nil); after != 30*time.Second {
+		t.Errorf("interval = %v, want the new 30s", after)
+	}
+}
+
+func TestPollScheduler_UntilNext(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	s := newPollScheduler(clock.now, 15*time.Minute)
+
+	if got := s.untilNext(time.Minute); got != time.Minute {
+		t.Errorf("untilNext() with no hosts = %v, want fallback", got)
+	}
+
+	s.due("pi", 5*time.Minute)
+	s.record("pi", nil)
+	s.due("nas", 30*time.Second)
+	s.record("nas", nil)
+
+	if got := s.untilNext(time.Minute); got != 30*time.Second {
+		t.Errorf("untilNext() = %v, want 30s", got)
+	}
+	clock.advance(time.Minute)
+	if got := s.untilNext(time.Minute); got != 0 {
+		t.Errorf("untilNext() when overdue = %v, want 0", got)
+	}
+}
+
+func TestPollHost_PerHostScheduleInSnapshot(t *testing.T) {
+	cfg := &config.Config{
+		PollInterval: 30,
+		Hosts: []config.HostConfig{
+			{Name: "nas", Address: "192.168.1.10"},
+			{Name: "pi", Address: "192.168.1.20", PollInterval: 300},
+		},
+	}
+	m := New(cfg, events.NewBus(false))
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	m.now = clock.now
+
+	piDown := true
+	polls := map[string]int{}
+	poll := func(ctx context.Context, host *config.HostConfig) error {
+		polls[host.Name]++
+		if host.Name == "pi" && piDown {
+			m.handleHostError(host.Name, "no route to host")
+			return errors.New("no route to host")
+		}
+		m.handleHostSuccess(host.Name)
+		return nil
+	}
+	pollAll := func() {
+		for i := range cfg.Hosts {
+			m.pollHost(m.remoteSchedule, &cfg.Hosts[i], poll)
+		}
+	}
+
+	// Drive the scheduler for 30 minutes in 30 second steps
+	for i := 0; i < 60; i++ {
+		pollAll()
+		clock.advance(30 * time.Second)
+	}
+
+	if polls["nas"] != 60 {
+		t.Errorf("nas polled %d times, want 60", polls["nas"])
+	}
+	// pi fails at 0, then backs off 10m and 15m: polled at 0, 10m and 25m
+	if polls["pi"] != 3 {
+		t.Errorf("pi polled %d times while down, want 3", polls["pi"])
+	}
+
+	states := m.HostStates()
+	if got := states["nas"].PollInterval; got != 30*time.Second {
+		t.Errorf("nas PollInterval = %v, want 30s", got)
+	}
+	pi := states["pi"]
+	if pi.PollInterval != 15*time.Minute || pi.Reachable {
+		t.Errorf("pi state = %+v, want unreachable with 15m interval", pi)
+	}
+	if want := time.Unix(1700000000, 0).Add(40 * time.Minute); !pi.NextPoll.Equal(want) {
+		t.Errorf("pi NextPoll = %v, want %v", pi.NextPoll, want)
+	}
+
+	// Once pi recovers it goes back to its own interval
+	piDown = false
+	clock.t = pi.NextPoll
+	pollAll()
+	if got := m.HostStates()["pi"].PollInterval; got != 5*time.Minute {
+		t.Errorf("pi PollInterval after recovery = %v, want 5m0s", got)
+	}
+}
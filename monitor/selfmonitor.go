@@ -0,0 +1,220 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/events"
+)
+
+// selfMonitorInterval is how often the dashboard samples its own resource use.
+const selfMonitorInterval = time.Minute
+
+// selfMonitorHistory is how many samples are kept at least, for
+// RuntimeStats, whatever the trend window.
+const selfMonitorHistory = 60
+
+// minTrendRise is how much a resource must grow over the trend window to be
+// alerted, so a dashboard settling in after a start isn't.
+const minTrendRise = 50
+
+// minTrendCorrelation is how closely the samples in the trend window must
+// follow a rising line. Steady leaks come close to 1; load that comes and
+// goes, such as log streams being opened and closed, doesn't.
+const minTrendCorrelation = 0.9
+
+// RuntimeSample is the dashboard's own resource use at one time.
+type RuntimeSample struct {
+	Time       time.Time `json:"time"`
+	Goroutines int       `json:"goroutines"`
+	HeapBytes  uint64    `json:"heap_bytes"`
+	OpenFDs    int       `json:"open_fds"` // -1 where /proc/self/fd can't be read
+}
+
+// RuntimeStats is the dashboard's recent resource use, oldest sample first,
+// and the limits it is alerted at (0 if not alerted).
+type RuntimeStats struct {
+	Samples       []RuntimeSample `json:"samples"`
+	MaxGoroutines int             `json:"max_goroutines"`
+	MaxOpenFDs    int             `json:"max_open_fds"`
+	TrendWindow   int             `json:"trend_window_minutes"`
+	Alerting      []string        `json:"alerting"` // Alerts raised and not yet cleared, e.g. "goroutines limit"
+}
+
+// readRuntime samples the dashboard's goroutines, heap and open files.
+func readRuntime() RuntimeSample {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	return RuntimeSample{
+		Time:       time.Now(),
+		Goroutines: runtime.NumGoroutine(),
+		HeapBytes:  mem.HeapAlloc,
+		OpenFDs:    countOpenFDs(),
+	}
+}
+
+// countOpenFDs returns how many files the process has open, or -1 on
+// systems without /proc/self/fd.
+func countOpenFDs() int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	return len(entries) - 1 // Less the descriptor ReadDir itself opened
+}
+
+// selfMonitor keeps the dashboard's recent resource use and raises an alert
+// when goroutines or open files pass their limit or grow steadily, as leaked
+// streams and SSH sessions make them. Each alert is raised once and again
+// only after it cleared.
+type selfMonitor struct {
+	host          string
+	maxGoroutines int // 0 if not alerted
+	maxOpenFDs    int // 0 if not alerted
+	window        int // Samples the trend is checked over, 0 if not checked
+	read          func() RuntimeSample
+	publish       func(events.Event)
+
+	mu       sync.Mutex
+	samples  []RuntimeSample // Oldest first
+	size     int             // Samples kept
+	alerting map[string]bool // Alerts raised, e.g. "goroutines limit"
+}
+
+// newSelfMonitor creates a self monitor for the dashboard on host, alerting
+// at the limits in cfg.
+func newSelfMonitor(host string, cfg *config.SelfMonitorConfig, publish func(events.Event)) *selfMonitor {
+	window := int(cfg.GetTrendWindow() / selfMonitorInterval)
+	return &selfMonitor{
+		host:          host,
+		maxGoroutines: cfg.GetMaxGoroutines(),
+		maxOpenFDs:    cfg.GetMaxOpenFDs(),
+		window:        window,
+		read:          readRuntime,
+		publish:       publish,
+		size:          max(window, selfMonitorHistory),
+		alerting:      make(map[string]bool),
+	}
+}
+
+// sample records the current resource use and raises the alerts it calls for.
+func (s *selfMonitor) sample() {
+	sample := s.read()
+
+	s.mu.Lock()
+	s.samples = append(s.samples, sample)
+	if len(s.samples) > s.size {
+		s.samples = s.samples[len(s.samples)-s.size:]
+	}
+	var alerts []events.Event
+	alerts = s.check(alerts, events.ResourceGoroutines, "goroutines", sample.Goroutines, s.maxGoroutines, func(r RuntimeSample) int { return r.Goroutines })
+	if sample.OpenFDs >= 0 {
+		alerts = s.check(alerts, events.ResourceOpenFDs, "open files", sample.OpenFDs, s.maxOpenFDs, func(r RuntimeSample) int { return r.OpenFDs })
+	}
+	s.mu.Unlock()
+
+	for _, alert := range alerts {
+		log.Printf("Monitor: %s", alert.(*events.DashboardResourceAlertEvent).Reason)
+		s.publish(alert)
+	}
+}
+
+// check appends the alerts resource calls for to alerts: one if value is
+// above limit, and one if it grew steadily over the trend window. noun names
+// the resource in the alert, and count reads it from a sample. The caller
+// holds s.mu.
+func (s *selfMonitor) check(alerts []events.Event, resource, noun string, value, limit int, count func(RuntimeSample) int) []events.Event {
+	over := limit > 0 && value > limit
+	if s.raise(resource+" limit", over) {
+		reason := fmt.Sprintf("The dashboard has %d %s, above the limit of %d", value, noun, limit)
+		alerts = append(alerts, events.NewDashboardResourceAlertEvent(s.host, resource, value, limit, reason))
+	}
+
+	var series []float64
+	if s.window > 1 && len(s.samples) >= s.window {
+		series = make([]float64, 0, s.window)
+		for _, sample := range s.samples[len(s.samples)-s.window:] {
+			series = append(series, float64(count(sample)))
+		}
+	}
+	if s.raise(resource+" growth", risingTrend(series, minTrendRise)) {
+		reason := fmt.Sprintf("The dashboard's %s grew steadily from %d to %d over the last %s",
+			noun, int(series[0]), value, time.Duration(s.window)*selfMonitorInterval)
+		alerts = append(alerts, events.NewDashboardResourceAlertEvent(s.host, resource, value, 0, reason))
+	}
+	return alerts
+}
+
+// raise records whether the alert named name holds now, and returns true if
+// it just started to. The caller holds s.mu.
+func (s *selfMonitor) raise(name string, holds bool) bool {
+	was := s.alerting[name]
+	if holds {
+		s.alerting[name] = true
+	} else {
+		delete(s.alerting, name)
+	}
+	return holds && !was
+}
+
+// stats returns the samples kept and the limits alerted at.
+func (s *selfMonitor) stats() RuntimeStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := RuntimeStats{
+		Samples:       append([]RuntimeSample{}, s.samples...),
+		MaxGoroutines: s.maxGoroutines,
+		MaxOpenFDs:    s.maxOpenFDs,
+		TrendWindow:   int(time.Duration(s.window) * selfMonitorInterval / time.Minute),
+		Alerting:      []string{},
+	}
+	for name := range s.alerting {
+		stats.Alerting = append(stats.Alerting, name)
+	}
+	slices.Sort(stats.Alerting)
+	return stats
+}
+
+// risingTrend reports whether series, samples taken at a steady interval,
+// rises along a line: the least-squares line through it rises by at least
+// minRise from the first sample to the last, and the samples correlate with
+// it by at least minTrendCorrelation. Series of fewer than three samples
+// never do.
+func risingTrend(series []float64, minRise float64) bool {
+	n := float64(len(series))
+	if n < 3 {
+		return false
+	}
+	var sumX, sumY float64
+	for i, y := range series {
+		sumX += float64(i)
+		sumY += y
+	}
+	meanX, meanY := sumX/n, sumY/n
+	var covXY, varX, varY float64
+	for i, y := range series {
+		dx, dy := float64(i)-meanX, y-meanY
+		covXY += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varY == 0 {
+		return false // Flat
+	}
+	slope := covXY / varX
+	correlation := covXY / math.Sqrt(varX*varY)
+	return slope*(n-1) >= minRise && correlation >= minTrendCorrelation
+}
+
+// Runtime returns the dashboard's recent resource use and the limits it is
+// alerted at. Implements handlers.RuntimeReporter.
+func (m *Monitor) Runtime() RuntimeStats {
+	return m.self.stats()
+}
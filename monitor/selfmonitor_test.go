@@ -0,0 +1,194 @@
+package monitor
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/events"
+)
+
+func TestRisingTrend(t *testing.T) {
+	// series builds n samples from f of the sample index
+	series := func(n int, f func(i int) float64) []float64 {
+		s := make([]float64, n)
+		for i := range s {
+			s[i] = f(i)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name   string
+		series []float64
+		want   bool
+	}{
+		{"steady leak", series(60, func(i int) float64 { return 100 + 4*float64(i) }), true},
+		{"leak with noise", series(60, func(i int) float64 { return 100 + 3*float64(i) + 10*math.Sin(float64(i)) }), true},
+		{"leak in steps", series(60, func(i int) float64 { return 100 + 20*float64(i/10) }), true},
+		{"flat", series(60, func(i int) float64 { return 120 }), false},
+		{"noisy but flat", series(60, func(i int) float64 { return 120 + 30*math.Sin(float64(i)) }), false},
+		{"streams opened and closed", series(60, func(i int) float64 { return 100 + float64(60*((i/5)%2)) }), false},
+		{"rising too little", series(60, func(i int) float64 { return 100 + 0.5*float64(i) }), false},
+		{"one spike at the end", series(60, func(i int) float64 {
+			if i == 59 {
+				return 400
+			}
+			return 100
+		}), false},
+		{"shrinking", series(60, func(i int) float64 { return 400 - 4*float64(i) }), false},
+		{"too short", []float64{100, 500}, false},
+		{"empty", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := risingTrend(tt.series, minTrendRise); got != tt.want {
+				t.Errorf("risingTrend() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeRuntime is a selfMonitor whose samples are set by the test and whose
+// alerts are recorded.
+type fakeRuntime struct {
+	*selfMonitor
+	next   RuntimeSample
+	alerts []*events.DashboardResourceAlertEvent
+}
+
+func newFakeRuntime(cfg *config.SelfMonitorConfig) *fakeRuntime {
+	f := &fakeRuntime{}
+	f.selfMonitor = newSelfMonitor("nas", cfg, func(e events.Event) {
+		f.alerts = append(f.alerts, e.(*events.DashboardResourceAlertEvent))
+	})
+	f.read = func() RuntimeSample { return f.next }
+	return f
+}
+
+// sampleWith samples goroutines and fds as the current resource use.
+func (f *fakeRuntime) sampleWith(goroutines, fds int) {
+	f.next = RuntimeSample{Goroutines: goroutines, OpenFDs: fds}
+	f.sample()
+}
+
+func TestSelfMonitor_Limits(t *testing.T) {
+	f := newFakeRuntime(&config.SelfMonitorConfig{MaxGoroutines: 400, MaxOpenFDs: 100, TrendWindow: -1})
+
+	f.sampleWith(300, 50)
+	if len(f.alerts) != 0 {
+		t.Fatalf("alerts below the limits: %+v", f.alerts)
+	}
+
+	f.sampleWith(412, 50)
+	if len(f.alerts) != 1 {
+		t.Fatalf("got %d alerts above the goroutine limit, want 1", len(f.alerts))
+	}
+	alert := f.alerts[0]
+	if alert.Host != "nas" || alert.Resource != events.ResourceGoroutines || alert.Value != 412 || alert.Limit != 400 {
+		t.Errorf("alert = %+v", alert)
+	}
+	if alert.Reason != "The dashboard has 412 goroutines, above the limit of 400" {
+		t.Errorf("Reason = %q", alert.Reason)
+	}
+
+	// Still above: not raised again until it clears
+	f.sampleWith(450, 50)
+	if len(f.alerts) != 1 {
+		t.Errorf("got %d alerts while staying above the limit, want 1", len(f.alerts))
+	}
+	if got := f.stats().Alerting; !reflect.DeepEqual(got, []string{"goroutines limit"}) {
+		t.Errorf("Alerting = %v", got)
+	}
+	f.sampleWith(350, 50)
+	f.sampleWith(420, 50)
+	if len(f.alerts) != 2 {
+		t.Errorf("got %d alerts after clearing and passing the limit again, want 2", len(f.alerts))
+	}
+
+	// Open files are alerted on their own
+	f.sampleWith(350, 120)
+	if len(f.alerts) != 3 || f.alerts[2].Resource != events.ResourceOpenFDs || f.alerts[2].Value != 120 {
+		t.Errorf("open files alert: %+v", f.alerts[len(f.alerts)-1])
+	}
+
+	// Without /proc/self/fd, open files are never alerted
+	f.sampleWith(350, -1)
+	f.sampleWith(350, 120)
+	if len(f.alerts) != 3 {
+		t.Errorf("got %d alerts after an unreadable sample, want 3", len(f.alerts))
+	}
+}
+
+func TestSelfMonitor_LimitsDisabled(t *testing.T) {
+	f := newFakeRuntime(&config.SelfMonitorConfig{MaxGoroutines: -1, MaxOpenFDs: -1, TrendWindow: -1})
+	f.sampleWith(100000, 100000)
+	if len(f.alerts) != 0 {
+		t.Errorf("alerts with the limits disabled: %+v", f.alerts)
+	}
+}
+
+func TestSelfMonitor_Growth(t *testing.T) {
+	f := newFakeRuntime(&config.SelfMonitorConfig{TrendWindow: 10})
+
+	// Leaking 10 goroutines a minute is alerted once the window is full
+	for i := range 9 {
+		f.sampleWith(100+10*i, 20)
+	}
+	if len(f.alerts) != 0 {
+		t.Fatalf("alerts before the window is full: %+v", f.alerts)
+	}
+	f.sampleWith(190, 20)
+	if len(f.alerts) != 1 {
+		t.Fatalf("got %d alerts after 10 minutes of growth, want 1", len(f.alerts))
+	}
+	alert := f.alerts[0]
+	if alert.Resource != events.ResourceGoroutines || alert.Value != 190 || alert.Limit != 0 {
+		t.Errorf("alert = %+v", alert)
+	}
+	if !strings.Contains(alert.Reason, "grew steadily from 100 to 190 over the last 10m0s") {
+		t.Errorf("Reason = %q", alert.Reason)
+	}
+
+	// Growing on isn't raised again
+	f.sampleWith(200, 20)
+	if len(f.alerts) != 1 {
+		t.Errorf("got %d alerts while growing on, want 1", len(f.alerts))
+	}
+
+	// Leveling off clears it
+	for range 10 {
+		f.sampleWith(200, 20)
+	}
+	if got := f.stats().Alerting; len(got) != 0 {
+		t.Errorf("Alerting = %v after leveling off", got)
+	}
+}
+
+func TestSelfMonitor_KeepsHistory(t *testing.T) {
+	f := newFakeRuntime(nil)
+	for i := range selfMonitorHistory + 5 {
+		f.sampleWith(i, 10)
+	}
+	stats := f.stats()
+	if len(stats.Samples) != selfMonitorHistory || stats.Samples[0].Goroutines != 5 {
+		t.Errorf("kept %d samples from %d, want %d from 5", len(stats.Samples), stats.Samples[0].Goroutines, selfMonitorHistory)
+	}
+	if stats.MaxGoroutines != 1000 || stats.MaxOpenFDs != 1000 || stats.TrendWindow != 60 {
+		t.Errorf("limits = %d, %d, %d, want the defaults", stats.MaxGoroutines, stats.MaxOpenFDs, stats.TrendWindow)
+	}
+}
+
+func TestReadRuntime(t *testing.T) {
+	sample := readRuntime()
+	if sample.Goroutines < 1 || sample.HeapBytes == 0 {
+		t.Errorf("readRuntime() = %+v", sample)
+	}
+	if time.Since(sample.Time) > time.Minute {
+		t.Errorf("Time = %v", sample.Time)
+	}
+}
@@ -0,0 +1,111 @@
+package monitor
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// goldenRatioConjugate spaces the phases of loops sharing an interval: each
+// loop starts this fraction of the interval after the previous one, modulo
+// the interval, which keeps any number of loops well apart.
+const goldenRatioConjugate = 0.6180339887498949
+
+// maxJitter is the largest random part of a loop's phase, as a fraction of
+// its interval.
+const maxJitter = 0.05
+
+// scheduler runs the monitor's periodic work. Loops started together with
+// the same interval would fire together on every tick, hitting the hosts and
+// the monitor's locks at once, so each loop starts at its own phase into its
+// interval, a slot spaced from the other loops of that interval plus some
+// jitter. A seeded scheduler picks the same phases on every run.
+type scheduler struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu    sync.Mutex
+	rand  *rand.Rand
+	slots map[time.Duration]int // phases handed out so far, by interval
+}
+
+// newScheduler creates a scheduler whose loops run until parent is done or
+// stop is called. A seed of 0 seeds the jitter randomly.
+func newScheduler(parent context.Context, seed int64) *scheduler {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	return &scheduler{
+		ctx:    ctx,
+		cancel: cancel,
+		rand:   rand.New(rand.NewSource(seed)),
+		slots:  make(map[time.Duration]int),
+	}
+}
+
+// offset returns the phase of a new loop running every interval: how long
+// after the loops start it should first run.
+func (s *scheduler) offset(interval time.Duration) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slot := s.slots[interval]
+	s.slots[interval]++
+	phase := float64(slot)*goldenRatioConjugate + s.rand.Float64()*maxJitter
+	phase -= math.Floor(phase)
+	return time.Duration(phase * float64(interval))
+}
+
+// every runs task every interval, starting at the loop's offset, until the
+// scheduler stops. Runs missed while task is still busy are skipped.
+func (s *scheduler) every(interval time.Duration, task func()) {
+	offset := s.offset(interval)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		timer := time.NewTimer(offset)
+		defer timer.Stop()
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			task()
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// tick is every for loops that select on other channels too: the returned
+// channel receives whenever the task would run. A tick not yet received
+// when the next one is due is dropped.
+func (s *scheduler) tick(interval time.Duration) <-chan struct{} {
+	c := make(chan struct{}, 1)
+	s.every(interval, func() {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	})
+	return c
+}
+
+// stop stops every loop and waits for the running tasks to return.
+func (s *scheduler) stop() {
+	s.cancel()
+	s.wg.Wait()
+}
@@ -0,0 +1,93 @@
+package monitor
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler_StaggersAndStops(t *testing.T) {
+	s := newScheduler(context.Background(), 42)
+	start := time.Now()
+
+	var mu sync.Mutex
+	first := make([]time.Duration, 5)
+	runs := 0
+	for i := range first {
+		s.every(time.Second, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if first[i] == 0 {
+				first[i] = time.Since(start)
+			}
+			runs++
+		})
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		fired := !slices.Contains(first, 0)
+		mu.Unlock()
+		if fired {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("not every task ran within 2s")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	sorted := slices.Clone(first)
+	mu.Unlock()
+	slices.Sort(sorted)
+	if sorted[len(sorted)-1] > time.Second+100*time.Millisecond {
+		t.Errorf("first runs = %v, want all within the first interval", first)
+	}
+	for i := 1; i < len(sorted); i++ {
+		if gap := sorted[i] - sorted[i-1]; gap < 50*time.Millisecond {
+			t.Errorf("first runs = %v, want them at least 50ms apart", first)
+			break
+		}
+	}
+
+	stopped := time.Now()
+	s.stop()
+	if d := time.Since(stopped); d > 100*time.Millisecond {
+		t.Errorf("stop took %v", d)
+	}
+	mu.Lock()
+	before := runs
+	mu.Unlock()
+	time.Sleep(1100 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if runs != before {
+		t.Errorf("tasks ran %d more times after stop", runs-before)
+	}
+}
+
+func TestScheduler_SeedIsDeterministic(t *testing.T) {
+	offsets := func(seed int64) []time.Duration {
+		s := newScheduler(context.Background(), seed)
+		defer s.stop()
+		var got []time.Duration
+		for range 5 {
+			got = append(got, s.offset(time.Minute))
+		}
+		return got
+	}
+
+	a, b := offsets(7), offsets(7)
+	if !slices.Equal(a, b) {
+		t.Errorf("offsets with the same seed = %v and %v, want equal", a, b)
+	}
+	for _, offset := range a {
+		if offset < 0 || offset >= time.Minute {
+			t.Errorf("offset %v outside the interval", offset)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	containerAPI "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+
+	"home_server_dashboard/services"
+)
+
+// statsWindow is how much history the stats of a container cover.
+const statsWindow = 10 * time.Minute
+
+// maxStatsWorkers is how many containers are sampled at once.
+const maxStatsWorkers = 4
+
+// statsClient is the part of the Docker client the stats sampler uses.
+type statsClient interface {
+	ContainerList(ctx context.Context, options containerAPI.ListOptions) ([]containerAPI.Summary, error)
+	ContainerStatsOneShot(ctx context.Context, containerID string) (containerAPI.StatsResponseReader, error)
+}
+
+// statsRing holds the latest samples of a container, overwriting the oldest
+// once full, and the CPU counters of its last reading.
+type statsRing struct {
+	samples []services.StatsSample
+	next    int  // Index the next sample is written to
+	full    bool // True once samples has wrapped around
+
+	// CPU counters of the last reading, which the next CPU% is measured from
+	prevCPU    uint64
+	prevSystem uint64
+	hasPrev    bool
+}
+
+func newStatsRing(size int) *statsRing {
+	return &statsRing{samples: make([]services.StatsSample, size)}
+}
+
+// add appends a sample, dropping the oldest if the ring is full.
+func (r *statsRing) add(sample services.StatsSample) {
+	r.samples[r.next] = sample
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// series returns the samples oldest first.
+func (r *statsRing) series() []services.StatsSample {
+	if !r.full {
+		return append([]services.StatsSample{}, r.samples[:r.next]...)
+	}
+	series := make([]services.StatsSample, 0, len(r.samples))
+	series = append(series, r.samples[r.next:]...)
+	return append(series, r.samples[:r.next]...)
+}
+
+// cpuPercent returns the CPU use between two readings the way docker stats
+// does: the container's share of the system's CPU time times the number of
+// CPUs, so two busy cores read 200. It returns false if the counters didn't
+// advance, e.g. because the container restarted in between.
+func cpuPercent(prevCPU, prevSystem uint64, cur containerAPI.CPUStats) (float64, bool) {
+	if cur.CPUUsage.TotalUsage < prevCPU || cur.SystemUsage <= prevSystem {
+		return 0, false
+	}
+	cpus := float64(cur.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(cur.CPUUsage.PercpuUsage))
+	}
+	if cpus == 0 {
+		cpus = 1
+	}
+	cpuDelta := float64(cur.CPUUsage.TotalUsage - prevCPU)
+	systemDelta := float64(cur.SystemUsage - prevSystem)
+	return cpuDelta / systemDelta * cpus * 100, true
+}
+
+// memoryUsage returns the memory a container uses without its page cache,
+// as docker stats reports it (cgroup v2 reports inactive_file, v1
+// total_inactive_file).
+func memoryUsage(mem containerAPI.MemoryStats) uint64 {
+	cache := mem.Stats["inactive_file"]
+	if v, ok := mem.Stats["total_inactive_file"]; ok {
+		cache = v
+	}
+	if cache > mem.Usage {
+		return mem.Usage
+	}
+	return mem.Usage - cache
+}
+
+// statsSampler samples the CPU and memory use of the running compose
+// containers of one host and keeps statsWindow of samples per service in
+// memory.
+type statsSampler struct {
+	host     string
+	interval time.Duration
+	size     int // Samples kept per service
+	workers  int
+	client   statsClient
+	now      func() time.Time
+
+	mu    sync.Mutex
+	rings map[services.Key]*statsRing // key: host and name
+}
+
+// newStatsSampler creates a sampler for host that samples every interval.
+func newStatsSampler(host string, interval time.Duration) *statsSampler {
+	size := int((statsWindow + interval - 1) / interval)
+	if size < 2 {
+		size = 2
+	}
+	return &statsSampler{
+		host:     host,
+		interval: interval,
+		size:     size,
+		workers:  maxStatsWorkers,
+		now:      time.Now,
+		rings:    make(map[services.Key]*statsRing),
+	}
+}
+
+// sample takes one reading of every running compose container, at most
+// workers at a time.
+func (s *statsSampler) sample(ctx context.Context) error {
+	containers, err := s.client.ContainerList(ctx, containerAPI.ListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", "com.docker.compose.project"),
+			filters.Arg("status", "running"),
+		),
+	})
+	if err != nil {
+		return err
+	}
+
+	jobs := make(chan containerAPI.Summary)
+	var wg sync.WaitGroup
+	for i := 0; i < s.workers && i < len(containers); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctr := range jobs {
+				s.sampleContainer(ctx, ctr)
+			}
+		}()
+	}
+	for _, ctr := range containers {
+		if ctr.State != "running" {
+			continue
+		}
+		select {
+		case jobs <- ctr:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return ctx.Err()
+}
+
+// sampleContainer reads the stats of one container and records them.
+func (s *statsSampler) sampleContainer(ctx context.Context, ctr containerAPI.Summary) {
+	serviceName := ctr.Labels["com.docker.compose.service"]
+	if serviceName == "" {
+		return
+	}
+
+	resp, err := s.client.ContainerStatsOneShot(ctx, ctr.ID)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Monitor: failed to read stats of %s: %v", serviceName, err)
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	var stats containerAPI.StatsResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&stats); err != nil {
+		log.Printf("Monitor: failed to decode stats of %s: %v", serviceName, err)
+		return
+	}
+	s.record(serviceKey(s.host, serviceName), stats)
+}
+
+// record adds a reading to the ring of a service. The first reading only
+// sets the CPU counters, since CPU% needs two of them.
+func (s *statsSampler) record(key services.Key, stats containerAPI.StatsResponse) {
+	at := stats.Read
+	if at.IsZero() {
+		at = s.now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ring, ok := s.rings[key]
+	if !ok {
+		ring = newStatsRing(s.size)
+		s.rings[key] = ring
+	}
+	if ring.hasPrev {
+		if cpu, ok := cpuPercent(ring.prevCPU, ring.prevSystem, stats.CPUStats); ok {
+			ring.add(services.StatsSample{Time: at, CPUPercent: cpu, MemBytes: memoryUsage(stats.MemoryStats)})
+		}
+	}
+	ring.prevCPU = stats.CPUStats.CPUUsage.TotalUsage
+	ring.prevSystem = stats.CPUStats.SystemUsage
+	ring.hasPrev = true
+}
+
+// series returns the samples of a service, oldest first.
+func (s *statsSampler) series(key services.Key) []services.StatsSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ring, ok := s.rings[key]
+	if !ok {
+		return []services.StatsSample{}
+	}
+	return ring.series()
+}
+
+// forget drops the samples of a service.
+func (s *statsSampler) forget(key services.Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.rings, key)
+}
+
+// sampleStats samples container stats every interval until the monitor stops.
+func (m *Monitor) sampleStats() {
+	defer m.wg.Done()
+
+	if m.stats.client == nil {
+		cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+		if err != nil {
+			log.Printf("Monitor: failed to create Docker client for stats: %v", err)
+			return
+		}
+		m.stats.client = cli
+		defer func() {
+			cli.Close()
+			m.stats.client = nil
+		}()
+	}
+
+	tick := m.sched.tick(m.stats.interval)
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-tick:
+		}
+		ctx, cancel := context.WithTimeout(m.ctx, m.stats.interval)
+		if err := m.stats.sample(ctx); err != nil && m.ctx.Err() == nil {
+			log.Printf("Monitor: failed to sample container stats on %s: %v", m.stats.host, err)
+		}
+		cancel()
+	}
+}
+
+// Stats returns the recent CPU and memory samples of a service, oldest
+// first. It returns false if stats aren't sampled on the service's host.
+// Implements handlers.StatsTracker.
+func (m *Monitor) Stats(host, serviceName string) ([]services.StatsSample, bool) {
+	if m.stats == nil || m.stats.host != host {
+		return nil, false
+	}
+	return m.stats.series(serviceKey(host, serviceName)), true
+}
@@ -0,0 +1,266 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	containerAPI "github.com/docker/docker/api/types/container"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/events"
+	"home_server_dashboard/services"
+)
+
+// fakeStatsClient lists fixed containers and answers each stats call with
+// counters that grow by one CPU-second per call, tracking how many calls
+// run at once.
+type fakeStatsClient struct {
+	containers []containerAPI.Summary
+	delay      time.Duration
+
+	mu      sync.Mutex
+	calls   map[string]int // container ID -> stats calls
+	running atomic.Int32
+	maxRun  atomic.Int32
+}
+
+func (f *fakeStatsClient) ContainerList(ctx context.Context, options containerAPI.ListOptions) ([]containerAPI.Summary, error) {
+	return f.containers, nil
+}
+
+func (f *fakeStatsClient) ContainerStatsOneShot(ctx context.Context, containerID string) (containerAPI.StatsResponseReader, error) {
+	n := f.running.Add(1)
+	defer f.running.Add(-1)
+	for {
+		max := f.maxRun.Load()
+		if n <= max || f.maxRun.CompareAndSwap(max, n) {
+			break
+		}
+	}
+	time.Sleep(f.delay)
+
+	f.mu.Lock()
+	f.calls[containerID]++
+	call := uint64(f.calls[containerID])
+	f.mu.Unlock()
+
+	// Half of one of two CPUs busy: 0.5s of container time per 2s of system time
+	body, _ := json.Marshal(containerAPI.StatsResponse{
+		CPUStats: containerAPI.CPUStats{
+			CPUUsage:    containerAPI.CPUUsage{TotalUsage: call * 5e8},
+			SystemUsage: call * 2e9,
+			OnlineCPUs:  2,
+		},
+		MemoryStats: containerAPI.MemoryStats{Usage: 300 << 20, Stats: map[string]uint64{"inactive_file": 100 << 20}},
+	})
+	return containerAPI.StatsResponseReader{Body: io.NopCloser(strings.NewReader(string(body)))}, nil
+}
+
+// composeContainers returns n running compose containers named svc0, svc1, ...
+func composeContainers(n int) []containerAPI.Summary {
+	var containers []containerAPI.Summary
+	for i := 0; i < n; i++ {
+		containers = append(containers, containerAPI.Summary{
+			ID:     fmt.Sprintf("id%d", i),
+			State:  "running",
+			Labels: map[string]string{"com.docker.compose.project": "media", "com.docker.compose.service": fmt.Sprintf("svc%d", i)},
+		})
+	}
+	return containers
+}
+
+func TestStatsRing(t *testing.T) {
+	ring := newStatsRing(3)
+	if got := ring.series(); got == nil || len(got) != 0 {
+		t.Errorf("series() of an empty ring = %#v, want an empty slice", got)
+	}
+
+	at := func(i int) services.StatsSample {
+		return services.StatsSample{Time: time.Unix(int64(i), 0), CPUPercent: float64(i)}
+	}
+	cpus := func() []float64 {
+		var got []float64
+		for _, s := range ring.series() {
+			got = append(got, s.CPUPercent)
+		}
+		return got
+	}
+
+	ring.add(at(1))
+	ring.add(at(2))
+	if got := fmt.Sprint(cpus()); got != "[1 2]" {
+		t.Errorf("series() = %s, want [1 2]", got)
+	}
+	ring.add(at(3))
+	ring.add(at(4))
+	ring.add(at(5))
+	if got := fmt.Sprint(cpus()); got != "[3 4 5]" {
+		t.Errorf("series() after wrapping = %s, want [3 4 5]", got)
+	}
+}
+
+func TestCPUPercent(t *testing.T) {
+	cur := containerAPI.CPUStats{
+		CPUUsage:    containerAPI.CPUUsage{TotalUsage: 3e9},
+		SystemUsage: 14e9,
+		OnlineCPUs:  4,
+	}
+	// 1s of container time per 4s of system time on 4 CPUs is one busy core
+	if got, ok := cpuPercent(2e9, 10e9, cur); !ok || math.Abs(got-100) > 1e-9 {
+		t.Errorf("cpuPercent() = %v, %v, want 100", got, ok)
+	}
+
+	// Without online_cpus the per-CPU counters give the count
+	cur.OnlineCPUs = 0
+	cur.CPUUsage.PercpuUsage = []uint64{1, 1}
+	if got, _ := cpuPercent(2e9, 10e9, cur); math.Abs(got-50) > 1e-9 {
+		t.Errorf("cpuPercent() with per-CPU counters = %v, want 50", got)
+	}
+
+	// Counters reset by a restart give no reading
+	if _, ok := cpuPercent(5e9, 10e9, cur); ok {
+		t.Error("cpuPercent() with a lower container counter = ok, want false")
+	}
+	if _, ok := cpuPercent(2e9, 14e9, cur); ok {
+		t.Error("cpuPercent() without system time passing = ok, want false")
+	}
+}
+
+func TestMemoryUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		mem  containerAPI.MemoryStats
+		want uint64
+	}{
+		{"cgroup v2", containerAPI.MemoryStats{Usage: 500, Stats: map[string]uint64{"inactive_file": 200}}, 300},
+		{"cgroup v1", containerAPI.MemoryStats{Usage: 500, Stats: map[string]uint64{"total_inactive_file": 100, "inactive_file": 200}}, 400},
+		{"no stats", containerAPI.MemoryStats{Usage: 500}, 500},
+		{"cache above usage", containerAPI.MemoryStats{Usage: 100, Stats: map[string]uint64{"inactive_file": 200}}, 100},
+	}
+	for _, tt := range tests {
+		if got := memoryUsage(tt.mem); got != tt.want {
+			t.Errorf("%s: memoryUsage() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStatsSampler_Sample(t *testing.T) {
+	fake := &fakeStatsClient{containers: composeContainers(2), calls: map[string]int{}}
+	fake.containers = append(fake.containers,
+		containerAPI.Summary{ID: "stopped", State: "exited", Labels: map[string]string{"com.docker.compose.service": "old"}},
+		containerAPI.Summary{ID: "plain", State: "running"}, // Not a compose container
+	)
+	s := newStatsSampler("nas", 30*time.Second)
+	s.client = fake
+
+	// The first reading only sets the CPU counters
+	if err := s.sample(context.Background()); err != nil {
+		t.Fatalf("sample() = %v", err)
+	}
+	if got := s.series(serviceKey("nas", "svc0")); len(got) != 0 {
+		t.Errorf("series() after one reading = %+v, want none", got)
+	}
+
+	s.sample(context.Background())
+	got := s.series(serviceKey("nas", "svc0"))
+	if len(got) != 1 {
+		t.Fatalf("series() after two readings = %+v, want one sample", got)
+	}
+	if math.Abs(got[0].CPUPercent-50) > 1e-9 || got[0].MemBytes != 200<<20 {
+		t.Errorf("sample = %+v, want 50%% CPU and 200 MiB", got[0])
+	}
+
+	if fake.calls["stopped"] != 0 {
+		t.Error("stopped container was sampled")
+	}
+	if len(s.series(serviceKey("nas", "old"))) != 0 {
+		t.Error("stopped container has samples")
+	}
+}
+
+func TestStatsSampler_BoundsConcurrency(t *testing.T) {
+	fake := &fakeStatsClient{containers: composeContainers(20), delay: 5 * time.Millisecond, calls: map[string]int{}}
+	s := newStatsSampler("nas", 30*time.Second)
+	s.client = fake
+	s.workers = 3
+
+	if err := s.sample(context.Background()); err != nil {
+		t.Fatalf("sample() = %v", err)
+	}
+	if got := fake.maxRun.Load(); got > 3 {
+		t.Errorf("%d stats calls ran at once, want at most 3", got)
+	}
+	if got := fake.maxRun.Load(); got < 2 {
+		t.Errorf("%d stats calls ran at once, want the workers to run in parallel", got)
+	}
+	if len(fake.calls) != 20 {
+		t.Errorf("sampled %d containers, want 20", len(fake.calls))
+	}
+}
+
+func TestNewStatsSampler_Window(t *testing.T) {
+	if got := newStatsSampler("nas", 30*time.Second).size; got != 20 {
+		t.Errorf("size at 30s = %d, want 20", got)
+	}
+	if got := newStatsSampler("nas", 7*time.Minute).size; got != 2 {
+		t.Errorf("size at 7m = %d, want 2", got)
+	}
+}
+
+func TestMonitorStats(t *testing.T) {
+	cfg := &config.Config{
+		Hosts: []config.HostConfig{
+			{Name: "nas", Address: "localhost", Stats: &config.StatsConfig{Enabled: true, Interval: 60}},
+			{Name: "pi", Address: "192.168.1.20", Stats: &config.StatsConfig{Enabled: true}},
+		},
+	}
+	m := New(cfg, events.NewBus(false), WithSkipFirstEvent(false))
+	if m.stats == nil || m.stats.interval != time.Minute {
+		t.Fatalf("stats sampler = %+v, want one sampling every minute", m.stats)
+	}
+	clock := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return clock }
+
+	fake := &fakeStatsClient{containers: composeContainers(1), calls: map[string]int{}}
+	m.stats.client = fake
+	m.updateServiceState(services.ServiceInfo{Name: "svc0", Host: "nas", Source: "docker", State: services.StateRunning})
+	m.stats.sample(context.Background())
+	m.stats.sample(context.Background())
+
+	if got, ok := m.Stats("nas", "svc0"); !ok || len(got) != 1 {
+		t.Errorf("Stats(nas, svc0) = %+v, %v", got, ok)
+	}
+	if got, ok := m.Stats("nas", "unknown"); !ok || got == nil || len(got) != 0 {
+		t.Errorf("Stats(nas, unknown) = %#v, %v, want an empty series", got, ok)
+	}
+	// Only the local host's containers are sampled
+	if _, ok := m.Stats("pi", "svc0"); ok {
+		t.Error("Stats(pi, svc0) = ok, want false for a remote host")
+	}
+
+	// The ring goes when the stale GC forgets the service
+	clock = clock.Add(time.Hour)
+	m.pruneStaleServices()
+	if got, _ := m.Stats("nas", "svc0"); len(got) != 0 {
+		t.Errorf("Stats() after the service was forgotten = %+v, want none", got)
+	}
+}
+
+func TestMonitorStats_Disabled(t *testing.T) {
+	cfg := &config.Config{Hosts: []config.HostConfig{{Name: "nas", Address: "localhost"}}}
+	m := New(cfg, events.NewBus(false))
+	if m.stats != nil {
+		t.Error("stats sampler created without stats enabled")
+	}
+	if _, ok := m.Stats("nas", "svc0"); ok {
+		t.Error("Stats() = ok without stats enabled")
+	}
+}
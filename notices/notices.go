@@ -0,0 +1,156 @@
+// Package notices keeps the problems worth an administrator's attention that
+// would otherwise only be logged, such as startup warnings, event sources
+// that can't connect and hosts that fail to report their services, so they
+// don't scroll away in the journal. Components post notices under a stable
+// key; repeats of a notice are counted rather than listed again.
+package notices
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultExpiryDays is how many days a notice is kept after it was last
+// posted.
+const DefaultExpiryDays = 7
+
+// Severities of a notice.
+const (
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+)
+
+// Notice is a problem reported by a component, with how often it was
+// reported since it first was.
+type Notice struct {
+	ID        string    `json:"id"`  // Derived from Key, so it is the same across restarts
+	Key       string    `json:"key"` // Identifies the condition, e.g. "provider:docker:nas"
+	Severity  string    `json:"severity"`
+	Component string    `json:"component"`
+	Message   string    `json:"message"` // As last posted
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Count     int       `json:"count"`
+}
+
+// Poster is how components report notices. Post reports the condition
+// identified by key; Resolve reports that it cleared, so a later Post shows
+// it again even if it was dismissed.
+type Poster interface {
+	Post(severity, component, key, message string)
+	Resolve(key string)
+}
+
+// PostAll posts each of messages, keyed by component and the message itself,
+// so each distinct message is its own notice.
+func PostAll(p Poster, severity, component string, messages []string) {
+	for _, message := range messages {
+		p.Post(severity, component, component+":"+message, message)
+	}
+}
+
+// entry is a notice and whether it was dismissed.
+type entry struct {
+	Notice
+	dismissed bool
+}
+
+// Store keeps the notices in memory. It is safe for concurrent use.
+type Store struct {
+	mu      sync.Mutex
+	entries map[string]*entry // By key
+	expiry  time.Duration
+	now     func() time.Time
+}
+
+// NewStore creates a store that forgets a notice expiryDays after it was
+// last posted.
+func NewStore(expiryDays int) *Store {
+	if expiryDays <= 0 {
+		expiryDays = DefaultExpiryDays
+	}
+	return &Store{
+		entries: make(map[string]*entry),
+		expiry:  time.Duration(expiryDays) * 24 * time.Hour,
+		now:     time.Now,
+	}
+}
+
+// noticeID returns the ID of the notice with key.
+func noticeID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Post records the condition identified by key. A repeat updates the
+// notice's severity and message and counts it; a dismissed notice stays
+// dismissed until the condition is resolved.
+func (s *Store) Post(severity, component, key, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	e, ok := s.entries[key]
+	if !ok || s.expired(e, now) {
+		e = &entry{Notice: Notice{ID: noticeID(key), Key: key, FirstSeen: now}}
+		s.entries[key] = e
+	}
+	e.Severity, e.Component, e.Message = severity, component, message
+	e.LastSeen = now
+	e.Count++
+}
+
+// Resolve forgets the condition identified by key, if it was posted.
+func (s *Store) Resolve(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
+
+// Dismiss hides the notice with id until its condition recurs. It reports
+// whether there was such a notice.
+func (s *Store) Dismiss(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if e.ID == id && !s.expired(e, s.now()) {
+			e.dismissed = true
+			return true
+		}
+	}
+	return false
+}
+
+// Active returns the notices that are neither dismissed nor expired, the
+// most recently posted first. Expired notices are forgotten.
+func (s *Store) Active() []Notice {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	list := []Notice{}
+	for key, e := range s.entries {
+		if s.expired(e, now) {
+			delete(s.entries, key)
+			continue
+		}
+		if !e.dismissed {
+			list = append(list, e.Notice)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].LastSeen.Equal(list[j].LastSeen) {
+			return list[i].LastSeen.After(list[j].LastSeen)
+		}
+		return list[i].Key < list[j].Key
+	})
+	return list
+}
+
+// expired reports whether e was last posted longer ago than the store
+// keeps notices.
+func (s *Store) expired(e *entry, now time.Time) bool {
+	return now.Sub(e.LastSeen) > s.expiry
+}
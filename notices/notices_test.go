@@ -0,0 +1,124 @@
+package notices
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestStore returns a store whose clock the test sets through the
+// returned pointer.
+func newTestStore(expiryDays int) (*Store, *time.Time) {
+	s := NewStore(expiryDays)
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func TestStore_DedupAndCount(t *testing.T) {
+	s, now := newTestStore(7)
+	start := *now
+
+	s.Post(SeverityWarning, "provider", "provider:docker:nas", "failed to list containers: timeout")
+	*now = now.Add(time.Minute)
+	s.Post(SeverityError, "provider", "provider:docker:nas", "failed to list containers: connection refused")
+	s.Post(SeverityWarning, "config", "config:group:media", "OIDC group 'media' references non-existent host 'old'")
+
+	list := s.Active()
+	if len(list) != 2 {
+		t.Fatalf("Active() returned %d notices, want 2: %+v", len(list), list)
+	}
+	var got Notice
+	for _, n := range list {
+		if n.Key == "provider:docker:nas" {
+			got = n
+		}
+	}
+	if got.Count != 2 || got.Severity != SeverityError || got.Message != "failed to list containers: connection refused" {
+		t.Errorf("deduped notice = %+v", got)
+	}
+	if !got.FirstSeen.Equal(start) || !got.LastSeen.Equal(start.Add(time.Minute)) {
+		t.Errorf("first seen %v, last seen %v", got.FirstSeen, got.LastSeen)
+	}
+	if got.ID == "" || got.ID != noticeID("provider:docker:nas") {
+		t.Errorf("ID = %q, want one derived from the key", got.ID)
+	}
+}
+
+func TestStore_Order(t *testing.T) {
+	s, now := newTestStore(7)
+	s.Post(SeverityWarning, "a", "a", "first")
+	*now = now.Add(time.Second)
+	s.Post(SeverityWarning, "b", "b", "second")
+	*now = now.Add(time.Second)
+	s.Post(SeverityWarning, "a", "a", "first again")
+
+	list := s.Active()
+	if len(list) != 2 || list[0].Key != "a" || list[1].Key != "b" {
+		t.Errorf("Active() = %+v, want the most recently posted first", list)
+	}
+}
+
+func TestStore_DismissAndRecur(t *testing.T) {
+	s, _ := newTestStore(7)
+	const key = "monitor:docker_events"
+	s.Post(SeverityError, "monitor", key, "docker_events not ready")
+	id := s.Active()[0].ID
+
+	if !s.Dismiss(id) {
+		t.Fatal("Dismiss() did not find the notice")
+	}
+	if list := s.Active(); len(list) != 0 {
+		t.Fatalf("Active() after dismissing = %+v", list)
+	}
+	if s.Dismiss("unknown") {
+		t.Error("Dismiss() found an unknown notice")
+	}
+
+	// While the condition persists, the notice stays dismissed
+	s.Post(SeverityError, "monitor", key, "docker_events not ready")
+	if list := s.Active(); len(list) != 0 {
+		t.Fatalf("Active() while the condition persists = %+v", list)
+	}
+
+	// Once it clears and recurs, it is shown again, counted afresh
+	s.Resolve(key)
+	s.Post(SeverityError, "monitor", key, "docker_events not ready")
+	list := s.Active()
+	if len(list) != 1 || list[0].ID != id || list[0].Count != 1 {
+		t.Errorf("Active() after recurring = %+v, want the notice again with count 1", list)
+	}
+}
+
+func TestStore_Expiry(t *testing.T) {
+	s, now := newTestStore(2)
+	s.Post(SeverityWarning, "config", "old", "posted once")
+	s.Post(SeverityWarning, "provider", "recurring", "posted daily")
+	s.Post(SeverityWarning, "provider", "dismissed", "dismissed")
+	s.Dismiss(noticeID("dismissed"))
+
+	for day := 0; day < 3; day++ {
+		*now = now.Add(24 * time.Hour)
+		s.Post(SeverityWarning, "provider", "recurring", "posted daily")
+	}
+
+	list := s.Active()
+	if len(list) != 1 || list[0].Key != "recurring" || list[0].Count != 4 {
+		t.Errorf("Active() = %+v, want only the recurring notice", list)
+	}
+	if s.Dismiss(noticeID("old")) {
+		t.Error("Dismiss() found an expired notice")
+	}
+
+	// An expired dismissed notice is forgotten, so a repeat is shown
+	s.Post(SeverityWarning, "provider", "dismissed", "dismissed")
+	list = s.Active()
+	if len(list) != 2 {
+		t.Errorf("Active() = %+v, want the expired dismissed notice back", list)
+	}
+}
+
+func TestNewStore_DefaultExpiry(t *testing.T) {
+	if s := NewStore(0); s.expiry != DefaultExpiryDays*24*time.Hour {
+		t.Errorf("expiry = %v", s.expiry)
+	}
+}
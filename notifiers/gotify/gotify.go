@@ -4,7 +4,6 @@ package gotify
 import (
 	"fmt"
 	"log"
-	"net/http"
 	"net/url"
 	"strings"
 	"time"
@@ -17,6 +16,8 @@ import (
 
 	"home_server_dashboard/config"
 	"home_server_dashboard/events"
+	"home_server_dashboard/httpclient"
+	"home_server_dashboard/services"
 )
 
 // Priority levels for Gotify messages.
@@ -42,7 +43,8 @@ type Notifier struct {
 	hostname string // kept for testing/logging
 }
 
-// New creates a new Gotify notifier from configuration.
+// New creates a new Gotify notifier from configuration, connecting with the
+// global outbound settings.
 // Returns nil if Gotify is not configured or disabled.
 func New(cfg *config.GotifyConfig) *Notifier {
 	if cfg == nil || !cfg.IsValid() {
@@ -56,9 +58,7 @@ func New(cfg *config.GotifyConfig) *Notifier {
 		return nil
 	}
 
-	httpClient := &http.Client{
-		Timeout: 10 * time.Second,
-	}
+	httpClient := httpclient.New(config.Get().OutboundFor(nil), 10*time.Second)
 
 	return &Notifier{
 		client:   gotify.NewClient(parsedURL, httpClient),
@@ -93,6 +93,18 @@ func (n *Notifier) formatEvent(event events.Event) *Message {
 		return n.formatHostUnreachable(e)
 	case *events.HostRecoveredEvent:
 		return n.formatHostRecovered(e)
+	case *events.ServiceRestartedEvent:
+		return n.formatServiceRestarted(e)
+	case *events.ServiceHealthChangedEvent:
+		return n.formatServiceHealthChanged(e)
+	case *events.ServiceRemovedEvent:
+		return n.formatServiceRemoved(e)
+	case *events.DashboardResourceAlertEvent:
+		return n.formatDashboardResourceAlert(e)
+	case *events.MaintenanceEndedEvent:
+		return n.formatMaintenanceEnded(e)
+	case *events.DigestEvent:
+		return n.formatDigest(e)
 	default:
 		return nil
 	}
@@ -102,20 +114,25 @@ func (n *Notifier) formatEvent(event events.Event) *Message {
 func (n *Notifier) formatServiceStateChanged(e *events.ServiceStateChangedEvent) *Message {
 	var priority int
 	var emoji string
+	previous, current := services.State(e.PreviousState), services.State(e.CurrentState)
 
 	switch {
-	case e.CurrentState == "running" && e.PreviousState == "stopped":
+	case current == services.StateRunning && (previous == services.StateStopped || previous == services.StateStarting):
 		// Service started - informational
 		priority = PriorityNormal
 		emoji = "🟢"
-	case e.CurrentState == "stopped" && e.PreviousState == "running":
-		// Service stopped - important
+	case current == services.StateStopped && previous.Up():
+		// Service stopped, or failed to start - important
 		priority = PriorityHigh
 		emoji = "🔴"
-	case e.CurrentState == "stopped":
+	case current == services.StateStopped:
 		// Service is stopped but wasn't running before (first detection)
 		priority = PriorityNormal
 		emoji = "🔴"
+	case current == services.StateUnhealthy:
+		// Service is up but broken - important
+		priority = PriorityHigh
+		emoji = "🟠"
 	default:
 		// Other state changes
 		priority = PriorityLow
@@ -151,6 +168,168 @@ func (n *Notifier) formatHostRecovered(e *events.HostRecoveredEvent) *Message {
 	}
 }
 
+// formatServiceRestarted formats a service restarted event.
+func (n *Notifier) formatServiceRestarted(e *events.ServiceRestartedEvent) *Message {
+	return &Message{
+		Title:    fmt.Sprintf("🔁 %s on %s restarted", e.ServiceName, e.Host),
+		Message:  fmt.Sprintf("%s (%s)", e.Reason, e.Source),
+		Priority: PriorityHigh,
+	}
+}
+
+// formatServiceHealthChanged formats a service health changed event.
+func (n *Notifier) formatServiceHealthChanged(e *events.ServiceHealthChangedEvent) *Message {
+	priority := PriorityNormal
+	emoji := "💚"
+	if e.CurrentHealth == "unhealthy" {
+		priority = PriorityHigh
+		emoji = "🟠"
+	}
+
+	previous := e.PreviousHealth
+	if previous == "" {
+		previous = "unknown"
+	}
+
+	return &Message{
+		Title:    fmt.Sprintf("%s %s on %s is %s", emoji, e.ServiceName, e.Host, e.CurrentHealth),
+		Message:  fmt.Sprintf("%s → %s (%s)", previous, e.CurrentHealth, e.Source),
+		Priority: priority,
+	}
+}
+
+// formatServiceRemoved formats a service removed event.
+func (n *Notifier) formatServiceRemoved(e *events.ServiceRemovedEvent) *Message {
+	return &Message{
+		Title:    fmt.Sprintf("🗑️ %s on %s removed", e.ServiceName, e.Host),
+		Message:  fmt.Sprintf("%s (%s)", e.Reason, e.Source),
+		Priority: PriorityLow,
+	}
+}
+
+// formatDashboardResourceAlert formats a dashboard resource alert event.
+func (n *Notifier) formatDashboardResourceAlert(e *events.DashboardResourceAlertEvent) *Message {
+	return &Message{
+		Title:    fmt.Sprintf("⚠️ Dashboard on %s: %s", e.Host, strings.ReplaceAll(e.Resource, "_", " ")),
+		Message:  e.Reason,
+		Priority: PriorityHigh,
+	}
+}
+
+// formatMaintenanceEnded formats the end of a host's maintenance window as
+// one summary of what is still down, or nil if everything came back.
+func (n *Notifier) formatMaintenanceEnded(e *events.MaintenanceEndedEvent) *Message {
+	if e.AllClear() {
+		return nil
+	}
+	var lines []string
+	if e.Unreachable {
+		lines = append(lines, "Host is unreachable")
+	}
+	if len(e.StillDown) > 0 {
+		lines = append(lines, fmt.Sprintf("Still down: %s", strings.Join(e.StillDown, ", ")))
+	}
+	return &Message{
+		Title:    fmt.Sprintf("🛠️ %s still down after maintenance", e.Host),
+		Message:  strings.Join(lines, "\n"),
+		Priority: PriorityHigh,
+	}
+}
+
+// formatDigest formats a burst of events of one kind as one message, such as
+// "🔴 7 services went down across 2 hosts", listing the services of each
+// host. It has the highest priority of the events' own messages.
+func (n *Notifier) formatDigest(e *events.DigestEvent) *Message {
+	var priority int
+	byHost := make(map[string][]string)
+	for _, event := range e.Events {
+		msg := n.formatEvent(event)
+		if msg == nil {
+			continue
+		}
+		priority = max(priority, msg.Priority)
+		host := events.HostOf(event)
+		byHost[host] = append(byHost[host], digestItem(event))
+	}
+	if len(byHost) == 0 {
+		return nil
+	}
+
+	hosts := e.Hosts()
+	lines := make([]string, 0, len(hosts))
+	count := 0
+	for _, host := range hosts {
+		items := byHost[host]
+		count += len(items)
+		if e.Kind == events.HostUnreachable || e.Kind == events.HostRecovered {
+			lines = append(lines, strings.Join(items, "\n"))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s: %s", host, strings.Join(items, ", ")))
+		}
+	}
+
+	title := digestSummary(e.Kind, e.Outcome, count)
+	switch {
+	case e.Kind == events.HostUnreachable || e.Kind == events.HostRecovered:
+	case len(hosts) == 1:
+		title += " on " + hosts[0]
+	default:
+		title += fmt.Sprintf(" across %d hosts", len(hosts))
+	}
+	return &Message{
+		Title:    title,
+		Message:  strings.Join(lines, "\n"),
+		Priority: priority,
+	}
+}
+
+// digestSummary describes count events of kind that changed to outcome,
+// e.g. "🔴 7 services went down".
+func digestSummary(kind events.EventType, outcome string, count int) string {
+	switch kind {
+	case events.ServiceStateChanged:
+		switch services.State(outcome) {
+		case services.StateStopped:
+			return fmt.Sprintf("🔴 %d services went down", count)
+		case services.StateRunning:
+			return fmt.Sprintf("🟢 %d services came up", count)
+		case services.StateUnhealthy:
+			return fmt.Sprintf("🟠 %d services are unhealthy", count)
+		}
+		return fmt.Sprintf("🔄 %d services are %s", count, outcome)
+	case events.ServiceHealthChanged:
+		if outcome == "unhealthy" {
+			return fmt.Sprintf("🟠 %d services are unhealthy", count)
+		}
+		return fmt.Sprintf("💚 %d services are %s", count, outcome)
+	case events.ServiceRestarted:
+		return fmt.Sprintf("🔁 %d services restarted", count)
+	case events.HostUnreachable:
+		return fmt.Sprintf("🚨 %d hosts unreachable", count)
+	case events.HostRecovered:
+		return fmt.Sprintf("✅ %d hosts recovered", count)
+	}
+	return fmt.Sprintf("🔔 %d %s events", count, strings.ReplaceAll(string(kind), "_", " "))
+}
+
+// digestItem names what event is about in a digest: the service, or for
+// reachability events the host with the reason it is unreachable.
+func digestItem(event events.Event) string {
+	switch e := event.(type) {
+	case *events.ServiceStateChangedEvent:
+		return e.ServiceName
+	case *events.ServiceRestartedEvent:
+		return e.ServiceName
+	case *events.ServiceHealthChangedEvent:
+		return e.ServiceName
+	case *events.HostUnreachableEvent:
+		return fmt.Sprintf("%s: %s", e.Host, e.Reason)
+	case *events.HostRecoveredEvent:
+		return e.Host
+	}
+	return string(event.Type())
+}
+
 // send sends a message to Gotify using the official API client.
 func (n *Notifier) send(msg *Message) error {
 	params := message.NewCreateMessageParams()
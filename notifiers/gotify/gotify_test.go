@@ -5,7 +5,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gotify/go-api-client/v2/gotify"
 	"github.com/gotify/go-api-client/v2/models"
@@ -177,6 +179,41 @@ func TestNotify_ServiceStopped_HighPriority(t *testing.T) {
 	}
 }
 
+func TestFormatServiceStateChanged_Priority(t *testing.T) {
+	n := &Notifier{hostname: "dashboard"}
+
+	tests := []struct {
+		previous string
+		current  string
+		priority int
+		emoji    string
+	}{
+		{"stopped", "running", PriorityNormal, "🟢"},
+		{"starting", "running", PriorityNormal, "🟢"},
+		{"running", "stopped", PriorityHigh, "🔴"},
+		{"starting", "stopped", PriorityHigh, "🔴"},
+		{"stopping", "stopped", PriorityHigh, "🔴"},
+		{"unhealthy", "stopped", PriorityHigh, "🔴"},
+		{"paused", "stopped", PriorityNormal, "🔴"},
+		{"unknown", "stopped", PriorityNormal, "🔴"},
+		{"running", "unhealthy", PriorityHigh, "🟠"},
+		{"unhealthy", "running", PriorityLow, "🔄"},
+		{"running", "paused", PriorityLow, "🔄"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.previous+"->"+tt.current, func(t *testing.T) {
+			msg := n.formatServiceStateChanged(events.NewServiceStateChangedEvent("nas", "db", "docker", tt.previous, tt.current, "status"))
+			if msg.Priority != tt.priority {
+				t.Errorf("priority = %d, want %d", msg.Priority, tt.priority)
+			}
+			if !strings.HasPrefix(msg.Title, tt.emoji) {
+				t.Errorf("title = %q, want prefix %q", msg.Title, tt.emoji)
+			}
+		})
+	}
+}
+
 func TestNotify_HostUnreachable(t *testing.T) {
 	var receivedMsg *models.MessageExternal
 
@@ -221,6 +258,143 @@ func TestNotify_HostRecovered(t *testing.T) {
 	}
 }
 
+func TestNotify_ServiceRestarted(t *testing.T) {
+	var receivedMsg *models.MessageExternal
+
+	n, server := newTestNotifier(t, func(msg *models.MessageExternal) {
+		receivedMsg = msg
+	})
+	defer server.Close()
+
+	event := events.NewServiceRestartedEvent("nas", "traefik", "docker", "out of memory")
+	if err := n.Notify(event); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if receivedMsg == nil {
+		t.Fatal("expected message to be sent")
+	}
+	if receivedMsg.Priority != PriorityHigh {
+		t.Errorf("expected priority %d for restart, got %d", PriorityHigh, receivedMsg.Priority)
+	}
+	if receivedMsg.Message != "out of memory (docker)" {
+		t.Errorf("unexpected message %q", receivedMsg.Message)
+	}
+}
+
+func TestNotify_ServiceHealthChanged(t *testing.T) {
+	var receivedMsg *models.MessageExternal
+
+	n, server := newTestNotifier(t, func(msg *models.MessageExternal) {
+		receivedMsg = msg
+	})
+	defer server.Close()
+
+	n.Notify(events.NewServiceHealthChangedEvent("nas", "traefik", "docker", "healthy", "unhealthy"))
+	if receivedMsg == nil {
+		t.Fatal("expected message to be sent")
+	}
+	if receivedMsg.Priority != PriorityHigh {
+		t.Errorf("expected priority %d for unhealthy, got %d", PriorityHigh, receivedMsg.Priority)
+	}
+
+	n.Notify(events.NewServiceHealthChangedEvent("nas", "traefik", "docker", "unhealthy", "healthy"))
+	if receivedMsg.Priority != PriorityNormal {
+		t.Errorf("expected priority %d for recovery, got %d", PriorityNormal, receivedMsg.Priority)
+	}
+}
+
+func TestNotify_ServiceRemoved(t *testing.T) {
+	var receivedMsg *models.MessageExternal
+
+	n, server := newTestNotifier(t, func(msg *models.MessageExternal) {
+		receivedMsg = msg
+	})
+	defer server.Close()
+
+	n.Notify(events.NewServiceRemovedEvent("nas", "redis", "docker", "no longer reported"))
+	if receivedMsg == nil {
+		t.Fatal("expected message to be sent")
+	}
+	if receivedMsg.Priority != PriorityLow {
+		t.Errorf("expected priority %d for removal, got %d", PriorityLow, receivedMsg.Priority)
+	}
+}
+
+func TestNotify_DashboardResourceAlert(t *testing.T) {
+	var receivedMsg *models.MessageExternal
+
+	n, server := newTestNotifier(t, func(msg *models.MessageExternal) {
+		receivedMsg = msg
+	})
+	defer server.Close()
+
+	n.Notify(events.NewDashboardResourceAlertEvent("nas", events.ResourceOpenFDs, 1200, 1000, "1200 open files, above the limit of 1000"))
+	if receivedMsg == nil {
+		t.Fatal("expected message to be sent")
+	}
+	if !strings.Contains(receivedMsg.Title, "nas: open fds") || receivedMsg.Message != "1200 open files, above the limit of 1000" {
+		t.Errorf("unexpected message %q: %q", receivedMsg.Title, receivedMsg.Message)
+	}
+	if receivedMsg.Priority != PriorityHigh {
+		t.Errorf("expected priority %d for a resource alert, got %d", PriorityHigh, receivedMsg.Priority)
+	}
+}
+
+func TestFormatMaintenanceEnded(t *testing.T) {
+	n := &Notifier{hostname: "dashboard"}
+
+	if msg := n.formatMaintenanceEnded(events.NewMaintenanceEndedEvent("nas", time.Now(), nil, false)); msg != nil {
+		t.Errorf("all clear = %+v, want no notification", msg)
+	}
+
+	msg := n.formatMaintenanceEnded(events.NewMaintenanceEndedEvent("nas", time.Now(), []string{"db", "sonarr"}, true))
+	if msg == nil || msg.Title != "🛠️ nas still down after maintenance" || msg.Priority != PriorityHigh {
+		t.Fatalf("message = %+v", msg)
+	}
+	if want := "Host is unreachable\nStill down: db, sonarr"; msg.Message != want {
+		t.Errorf("message body = %q, want %q", msg.Message, want)
+	}
+}
+
+func TestFormatDigest(t *testing.T) {
+	n := &Notifier{hostname: "dashboard"}
+
+	down := func(host, name string) events.Event {
+		return events.NewServiceStateChangedEvent(host, name, "docker", "running", "stopped", "Exited (1)")
+	}
+	digest := events.NewDigestEvent(events.ServiceStateChanged, "stopped", []events.Event{
+		down("nas", "sonarr"), down("pi", "pihole"), down("nas", "jellyfin"),
+		down("nas", "radarr"), down("pi", "unbound"), down("nas", "prowlarr"), down("nas", "bazarr"),
+	})
+	msg := n.formatDigest(digest)
+	if msg == nil || msg.Title != "🔴 7 services went down across 2 hosts" || msg.Priority != PriorityHigh {
+		t.Fatalf("message = %+v", msg)
+	}
+	if want := "nas: sonarr, jellyfin, radarr, prowlarr, bazarr\npi: pihole, unbound"; msg.Message != want {
+		t.Errorf("message body = %q, want %q", msg.Message, want)
+	}
+
+	up := func(host, name string) events.Event {
+		return events.NewServiceStateChangedEvent(host, name, "docker", "stopped", "running", "Up")
+	}
+	msg = n.formatDigest(events.NewDigestEvent(events.ServiceStateChanged, "running", []events.Event{up("nas", "a"), up("nas", "b"), up("nas", "c"), up("nas", "d")}))
+	if msg == nil || msg.Title != "🟢 4 services came up on nas" || msg.Priority != PriorityNormal {
+		t.Errorf("recovery digest = %+v", msg)
+	}
+
+	msg = n.formatDigest(events.NewDigestEvent(events.HostUnreachable, "", []events.Event{
+		events.NewHostUnreachableEvent("pi", "timeout"),
+		events.NewHostUnreachableEvent("nas", "no route to host"),
+	}))
+	if msg == nil || msg.Title != "🚨 2 hosts unreachable" || msg.Priority != PriorityMax {
+		t.Fatalf("hosts digest = %+v", msg)
+	}
+	if want := "nas: no route to host\npi: timeout"; msg.Message != want {
+		t.Errorf("hosts digest body = %q, want %q", msg.Message, want)
+	}
+}
+
 func TestNotify_ServerError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -2,6 +2,9 @@
 package notifiers
 
 import (
+	"sync"
+	"time"
+
 	"home_server_dashboard/events"
 )
 
@@ -23,13 +26,51 @@ type Manager struct {
 	notifiers []Notifier
 	bus       *events.Bus
 	subs      []*events.Subscription
+
+	// Digests: events of a kind are held back for digestWindow, and more
+	// than digestThreshold of them are sent as one events.DigestEvent.
+	digestWindow    time.Duration
+	digestThreshold int
+	mu              sync.Mutex
+	pending         map[digestKey]*digestGroup
+}
+
+// digestKey is the kind of events combined into a digest: their type and the
+// state or health they changed to.
+type digestKey struct {
+	kind    events.EventType
+	outcome string
+}
+
+// digestGroup is the events of a kind held back in the current window.
+type digestGroup struct {
+	events []events.Event
+	timer  *time.Timer
+}
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithDigest holds service and host events back for window and sends more
+// than threshold of the same kind as one digest. A single event is never
+// held back longer than window. A window of 0 sends every event straight
+// away.
+func WithDigest(window time.Duration, threshold int) Option {
+	return func(m *Manager) {
+		m.digestWindow = window
+		m.digestThreshold = threshold
+	}
 }
 
 // NewManager creates a new notifier manager that listens to the event bus.
-func NewManager(bus *events.Bus) *Manager {
+func NewManager(bus *events.Bus, opts ...Option) *Manager {
 	m := &Manager{
 		notifiers: make([]Notifier, 0),
 		bus:       bus,
+		pending:   make(map[digestKey]*digestGroup),
+	}
+	for _, opt := range opts {
+		opt(m)
 	}
 
 	// Subscribe to all events
@@ -44,7 +85,24 @@ func (m *Manager) Register(notifier Notifier) {
 }
 
 // handleEvent is called for each event and routes it to all registered notifiers.
+// Quiet state changes and events from a host in maintenance are still
+// published for the UI but not notified.
 func (m *Manager) handleEvent(event events.Event) {
+	if e, ok := event.(*events.ServiceStateChangedEvent); ok && e.Quiet {
+		return
+	}
+	if events.InMaintenance(event) {
+		return
+	}
+	if m.digestWindow > 0 && events.HostOf(event) != "" {
+		m.holdBack(event)
+		return
+	}
+	m.notify(event)
+}
+
+// notify sends event to all registered notifiers.
+func (m *Manager) notify(event events.Event) {
 	for _, notifier := range m.notifiers {
 		if err := notifier.Notify(event); err != nil {
 			// Log but don't fail - notifications are best-effort
@@ -54,13 +112,74 @@ func (m *Manager) handleEvent(event events.Event) {
 	}
 }
 
-// Close unsubscribes from the event bus and closes all notifiers.
+// kindOf returns the digest kind of event.
+func kindOf(event events.Event) digestKey {
+	key := digestKey{kind: event.Type()}
+	switch e := event.(type) {
+	case *events.ServiceStateChangedEvent:
+		key.outcome = e.CurrentState
+	case *events.ServiceHealthChangedEvent:
+		key.outcome = e.CurrentHealth
+	}
+	return key
+}
+
+// holdBack adds event to the events of its kind in the current window,
+// starting the window if it is the first.
+func (m *Manager) holdBack(event events.Event) {
+	key := kindOf(event)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	group, ok := m.pending[key]
+	if !ok {
+		group = &digestGroup{}
+		group.timer = time.AfterFunc(m.digestWindow, func() { m.flush(key) })
+		m.pending[key] = group
+	}
+	group.events = append(group.events, event)
+}
+
+// flush sends the events of kind held back in the window that just ended:
+// one by one up to the threshold, as a digest above it.
+func (m *Manager) flush(key digestKey) {
+	m.mu.Lock()
+	group, ok := m.pending[key]
+	delete(m.pending, key)
+	m.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	if len(group.events) > m.digestThreshold {
+		m.notify(events.NewDigestEvent(key.kind, key.outcome, group.events))
+		return
+	}
+	for _, event := range group.events {
+		m.notify(event)
+	}
+}
+
+// Close unsubscribes from the event bus, sends the events still held back
+// and closes all notifiers.
 func (m *Manager) Close() error {
 	// Unsubscribe from events
 	for _, sub := range m.subs {
 		sub.Unsubscribe()
 	}
 
+	// End the current windows early rather than drop their events
+	m.mu.Lock()
+	var keys []digestKey
+	for key, group := range m.pending {
+		if group.timer.Stop() {
+			keys = append(keys, key)
+		}
+	}
+	m.mu.Unlock()
+	for _, key := range keys {
+		m.flush(key)
+	}
+
 	// Close all notifiers
 	var lastErr error
 	for _, notifier := range m.notifiers {
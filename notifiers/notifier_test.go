@@ -1,8 +1,11 @@
 package notifiers
 
 import (
+	"fmt"
+	"strings"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"home_server_dashboard/events"
 )
@@ -65,6 +68,44 @@ func TestManagerRoutesEventsToNotifiers(t *testing.T) {
 	}
 }
 
+func TestManagerSkipsQuietStateChanges(t *testing.T) {
+	bus := events.NewBus(false)
+	manager := NewManager(bus)
+	defer manager.Close()
+
+	mock := &mockNotifier{name: "mock"}
+	manager.Register(mock)
+
+	event := events.NewServiceStateChangedEvent("nas", "db", "systemd", "stopped", "starting", "activating (start)")
+	event.Quiet = true
+	bus.Publish(event)
+
+	if got := atomic.LoadInt32(&mock.callCount); got != 0 {
+		t.Errorf("quiet state change was notified %d times", got)
+	}
+}
+
+func TestManagerSkipsMaintenanceEvents(t *testing.T) {
+	bus := events.NewBus(false)
+	manager := NewManager(bus)
+	defer manager.Close()
+
+	mock := &mockNotifier{name: "mock"}
+	manager.Register(mock)
+
+	down := events.NewHostUnreachableEvent("nas", "timeout")
+	down.MarkMaintenance()
+	bus.Publish(down)
+	if got := atomic.LoadInt32(&mock.callCount); got != 0 {
+		t.Errorf("event during maintenance was notified %d times", got)
+	}
+
+	bus.Publish(events.NewMaintenanceEndedEvent("nas", time.Now(), []string{"db"}, false))
+	if got := atomic.LoadInt32(&mock.callCount); got != 1 {
+		t.Errorf("end of maintenance was notified %d times, want 1", got)
+	}
+}
+
 func TestManagerReceivesAllEventTypes(t *testing.T) {
 	bus := events.NewBus(false)
 	manager := NewManager(bus)
@@ -100,3 +141,187 @@ func TestManagerCloseUnsubscribes(t *testing.T) {
 		t.Errorf("expected 0 calls after close, got %d", mock.callCount)
 	}
 }
+
+// recordingNotifier passes the events it is sent to a channel.
+type recordingNotifier struct {
+	sent chan events.Event
+}
+
+func newRecordingNotifier() *recordingNotifier {
+	return &recordingNotifier{sent: make(chan events.Event, 100)}
+}
+
+func (r *recordingNotifier) Name() string { return "recording" }
+
+func (r *recordingNotifier) Notify(event events.Event) error {
+	r.sent <- event
+	return nil
+}
+
+func (r *recordingNotifier) Close() error { return nil }
+
+// next returns the next event sent, failing the test if none is within wait.
+func (r *recordingNotifier) next(t *testing.T, wait time.Duration) events.Event {
+	t.Helper()
+	select {
+	case event := <-r.sent:
+		return event
+	case <-time.After(wait):
+		t.Fatalf("nothing sent within %v", wait)
+		return nil
+	}
+}
+
+// none fails the test if anything is sent within wait.
+func (r *recordingNotifier) none(t *testing.T, wait time.Duration) {
+	t.Helper()
+	select {
+	case event := <-r.sent:
+		t.Fatalf("sent %T %+v, want nothing", event, event)
+	case <-time.After(wait):
+	}
+}
+
+const testDigestWindow = 100 * time.Millisecond
+
+// newDigestManager returns a manager combining more than 3 events of a kind
+// within testDigestWindow, and the notifier it sends to.
+func newDigestManager(t *testing.T) (*events.Bus, *Manager, *recordingNotifier) {
+	t.Helper()
+	bus := events.NewBus(false)
+	manager := NewManager(bus, WithDigest(testDigestWindow, 3))
+	t.Cleanup(func() { manager.Close() })
+	recorder := newRecordingNotifier()
+	manager.Register(recorder)
+	return bus, manager, recorder
+}
+
+func serviceDown(host, name string) *events.ServiceStateChangedEvent {
+	return events.NewServiceStateChangedEvent(host, name, "docker", "running", "stopped", "Exited (1)")
+}
+
+func TestManagerDigestsBursts(t *testing.T) {
+	bus, _, recorder := newDigestManager(t)
+
+	// The network drops: services on two hosts go down, one host is unreachable
+	names := []string{"nas/sonarr", "pi/pihole", "nas/radarr", "nas/jellyfin", "pi/unbound", "nas/bazarr", "nas/prowlarr"}
+	for _, name := range names {
+		host, service, _ := strings.Cut(name, "/")
+		bus.Publish(serviceDown(host, service))
+	}
+	bus.Publish(events.NewHostUnreachableEvent("remote", "timeout"))
+	recorder.none(t, testDigestWindow/2)
+
+	var digest *events.DigestEvent
+	var unreachable *events.HostUnreachableEvent
+	for i := 0; i < 2; i++ {
+		switch e := recorder.next(t, testDigestWindow).(type) {
+		case *events.DigestEvent:
+			digest = e
+		case *events.HostUnreachableEvent:
+			unreachable = e
+		default:
+			t.Fatalf("sent %T, want a digest and the unreachable host", e)
+		}
+	}
+	recorder.none(t, testDigestWindow)
+
+	if digest == nil || unreachable == nil {
+		t.Fatalf("digest = %v, unreachable = %v", digest, unreachable)
+	}
+	if digest.Kind != events.ServiceStateChanged || digest.Outcome != "stopped" || len(digest.Events) != len(names) {
+		t.Errorf("digest = %s/%s of %d events, want stopped services of %d", digest.Kind, digest.Outcome, len(digest.Events), len(names))
+	}
+	for i, event := range digest.Events {
+		e := event.(*events.ServiceStateChangedEvent)
+		if got := e.Host + "/" + e.ServiceName; got != names[i] {
+			t.Errorf("digest event %d = %s, want %s", i, got, names[i])
+		}
+	}
+	if hosts := digest.Hosts(); len(hosts) != 2 || hosts[0] != "nas" || hosts[1] != "pi" {
+		t.Errorf("digest hosts = %v, want [nas pi]", hosts)
+	}
+}
+
+func TestManagerDigestThreshold(t *testing.T) {
+	bus, _, recorder := newDigestManager(t)
+
+	// Up to the threshold, events are sent one by one
+	for _, name := range []string{"a", "b", "c"} {
+		bus.Publish(serviceDown("nas", name))
+	}
+	for _, want := range []string{"a", "b", "c"} {
+		e, ok := recorder.next(t, 2*testDigestWindow).(*events.ServiceStateChangedEvent)
+		if !ok || e.ServiceName != want {
+			t.Fatalf("sent %+v, want %s on its own", e, want)
+		}
+	}
+
+	// Recoveries are a kind of their own
+	for _, name := range []string{"a", "b", "c", "d"} {
+		bus.Publish(events.NewServiceStateChangedEvent("nas", name, "docker", "stopped", "running", "Up"))
+	}
+	bus.Publish(serviceDown("nas", "e"))
+	got := map[string]events.Event{}
+	for i := 0; i < 2; i++ {
+		event := recorder.next(t, 2*testDigestWindow)
+		got[fmt.Sprintf("%T", event)] = event
+	}
+	digest, ok := got["*events.DigestEvent"].(*events.DigestEvent)
+	if !ok || digest.Outcome != "running" || len(digest.Events) != 4 {
+		t.Errorf("sent %v, want a digest of the 4 recoveries", got)
+	}
+	if _, ok := got["*events.ServiceStateChangedEvent"]; !ok {
+		t.Errorf("sent %v, want the lone stop on its own", got)
+	}
+}
+
+func TestManagerDigestLatency(t *testing.T) {
+	bus, _, recorder := newDigestManager(t)
+
+	// An isolated event is held back for the window and no longer
+	start := time.Now()
+	bus.Publish(serviceDown("nas", "sonarr"))
+	if _, ok := recorder.next(t, 5*testDigestWindow).(*events.ServiceStateChangedEvent); !ok {
+		t.Fatal("isolated event not sent on its own")
+	}
+	if elapsed := time.Since(start); elapsed < testDigestWindow || elapsed > testDigestWindow+70*time.Millisecond {
+		t.Errorf("isolated event sent after %v, want the window of %v", elapsed, testDigestWindow)
+	}
+
+	// A burst is sent at the end of the window its first event started,
+	// however long it goes on
+	start = time.Now()
+	for i := 0; i < 4; i++ {
+		bus.Publish(serviceDown("nas", fmt.Sprint("svc", i)))
+		time.Sleep(testDigestWindow / 5)
+	}
+	if _, ok := recorder.next(t, 5*testDigestWindow).(*events.DigestEvent); !ok {
+		t.Fatal("burst not sent as a digest")
+	}
+	if elapsed := time.Since(start); elapsed > testDigestWindow+70*time.Millisecond {
+		t.Errorf("digest sent after %v, want within the window of %v", elapsed, testDigestWindow)
+	}
+}
+
+func TestManagerDigestSendsOtherEventsStraightAway(t *testing.T) {
+	bus, _, recorder := newDigestManager(t)
+
+	bus.Publish(events.NewServiceRemovedEvent("nas", "old", "docker", "no longer reported"))
+	if _, ok := recorder.next(t, testDigestWindow/2).(*events.ServiceRemovedEvent); !ok {
+		t.Error("removed service held back")
+	}
+}
+
+func TestManagerCloseSendsHeldBackEvents(t *testing.T) {
+	bus := events.NewBus(false)
+	manager := NewManager(bus, WithDigest(time.Hour, 3))
+	recorder := newRecordingNotifier()
+	manager.Register(recorder)
+
+	bus.Publish(serviceDown("nas", "sonarr"))
+	manager.Close()
+	if _, ok := recorder.next(t, testDigestWindow).(*events.ServiceStateChangedEvent); !ok {
+		t.Error("held-back event dropped on close")
+	}
+}
@@ -0,0 +1,129 @@
+// Package realip determines the client address and requested host of a
+// request that may have passed through reverse proxies. Forwarded headers are
+// only honored when the immediate peer is a configured trusted proxy, so a
+// client connecting directly can't spoof its address or the host it used.
+package realip
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+// Resolver resolves client addresses and hosts using a set of trusted proxies.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// New creates a Resolver that trusts forwarded headers from peers in the given prefixes.
+func New(trusted []netip.Prefix) *Resolver {
+	return &Resolver{trusted: trusted}
+}
+
+// isTrusted reports whether addr belongs to a trusted proxy.
+func (res *Resolver) isTrusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range res.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// FromRequest returns the client IP address for r. When the immediate peer is a
+// trusted proxy, X-Forwarded-For is walked from the right, skipping trusted
+// proxies, and the first untrusted address is the client. Otherwise the peer
+// address is returned and forwarded headers are ignored.
+func (res *Resolver) FromRequest(r *http.Request) string {
+	peer, ok := parseAddr(r.RemoteAddr)
+	if !ok {
+		return r.RemoteAddr
+	}
+	if !res.isTrusted(peer) {
+		return peer.String()
+	}
+
+	hops := forwardedValues(r.Header.Values("X-Forwarded-For"))
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseAddr(hops[i])
+		if !ok {
+			// A malformed entry can't be attributed; stop at the last known hop
+			break
+		}
+		client = addr
+		if !res.isTrusted(addr) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// Host returns the host the client requested. When the immediate peer is a
+// trusted proxy, the value it set in X-Forwarded-Host (the rightmost one) is
+// used instead of the Host header, which the proxy may have rewritten.
+func (res *Resolver) Host(r *http.Request) string {
+	if peer, ok := parseAddr(r.RemoteAddr); ok && res.isTrusted(peer) {
+		if hosts := forwardedValues(r.Header.Values("X-Forwarded-Host")); len(hosts) > 0 {
+			return hosts[len(hosts)-1]
+		}
+	}
+	return r.Host
+}
+
+// forwardedValues splits comma-separated header values into trimmed entries.
+func forwardedValues(headers []string) []string {
+	var values []string
+	for _, header := range headers {
+		for _, value := range strings.Split(header, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// parseAddr parses an IP address with or without a port.
+func parseAddr(s string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+var (
+	defaultMu       sync.RWMutex
+	defaultResolver = New(nil)
+)
+
+// Configure replaces the trusted proxies used by FromRequest and Host.
+func Configure(trusted []netip.Prefix) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultResolver = New(trusted)
+}
+
+// Default returns the shared resolver.
+func Default() *Resolver {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultResolver
+}
+
+// FromRequest returns the client IP address for r using the shared resolver.
+func FromRequest(r *http.Request) string {
+	return Default().FromRequest(r)
+}
+
+// Host returns the host the client requested using the shared resolver.
+func Host(r *http.Request) string {
+	return Default().Host(r)
+}
@@ -0,0 +1,148 @@
+package realip
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func testResolver() *Resolver {
+	return New([]netip.Prefix{
+		netip.MustParsePrefix("172.18.0.0/16"),
+		netip.MustParsePrefix("10.0.0.1/32"),
+		netip.MustParsePrefix("fd00::/64"),
+	})
+}
+
+func TestFromRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		want       string
+	}{
+		{
+			name:       "direct client without headers",
+			remoteAddr: "192.168.1.50:51234",
+			want:       "192.168.1.50",
+		},
+		{
+			name:       "untrusted peer headers are ignored",
+			remoteAddr: "192.168.1.50:51234",
+			xff:        []string{"1.2.3.4"},
+			want:       "192.168.1.50",
+		},
+		{
+			name:       "trusted proxy with single hop",
+			remoteAddr: "172.18.0.2:40000",
+			xff:        []string{"192.168.1.50"},
+			want:       "192.168.1.50",
+		},
+		{
+			name:       "client-supplied entries left of the real client are ignored",
+			remoteAddr: "172.18.0.2:40000",
+			xff:        []string{"6.6.6.6, 192.168.1.50"},
+			want:       "192.168.1.50",
+		},
+		{
+			name:       "multi-hop through trusted proxies",
+			remoteAddr: "172.18.0.2:40000",
+			xff:        []string{"203.0.113.7, 10.0.0.1", "172.18.0.9"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "untrusted hop stops the walk",
+			remoteAddr: "172.18.0.2:40000",
+			xff:        []string{"203.0.113.7, 198.51.100.1, 10.0.0.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "all hops trusted yields leftmost",
+			remoteAddr: "172.18.0.2:40000",
+			xff:        []string{"172.18.0.5, 10.0.0.1"},
+			want:       "172.18.0.5",
+		},
+		{
+			name:       "trusted proxy without header",
+			remoteAddr: "172.18.0.2:40000",
+			want:       "172.18.0.2",
+		},
+		{
+			name:       "malformed hop stops at last known address",
+			remoteAddr: "172.18.0.2:40000",
+			xff:        []string{"192.168.1.50, garbage"},
+			want:       "172.18.0.2",
+		},
+		{
+			name:       "IPv6 peer and hop with port",
+			remoteAddr: "[fd00::2]:40000",
+			xff:        []string{"[2001:db8::1]:1234"},
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "IPv4-mapped peer",
+			remoteAddr: "[::ffff:172.18.0.2]:40000",
+			xff:        []string{"192.168.1.50"},
+			want:       "192.168.1.50",
+		},
+	}
+
+	res := testResolver()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := res.FromRequest(r); got != tt.want {
+				t.Errorf("FromRequest() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHost(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xfh        string
+		want       string
+	}{
+		{"no header", "172.18.0.2:40000", "", "dashboard.example.com"},
+		{"trusted proxy", "172.18.0.2:40000", "nas.local:9001", "nas.local:9001"},
+		{"trusted proxy chain uses rightmost", "172.18.0.2:40000", "evil.example, nas.local", "nas.local"},
+		{"untrusted peer is ignored", "192.168.1.50:5000", "nas.local", "dashboard.example.com"},
+	}
+
+	res := testResolver()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://dashboard.example.com/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.xfh != "" {
+				r.Header.Set("X-Forwarded-Host", tt.xfh)
+			}
+			if got := res.Host(r); got != tt.want {
+				t.Errorf("Host() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	t.Cleanup(func() { Configure(nil) })
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "172.18.0.2:40000"
+	r.Header.Set("X-Forwarded-For", "192.168.1.50")
+
+	if got := FromRequest(r); got != "172.18.0.2" {
+		t.Errorf("FromRequest() with no trusted proxies = %q, want peer address", got)
+	}
+
+	Configure([]netip.Prefix{netip.MustParsePrefix("172.18.0.0/16")})
+	if got := FromRequest(r); got != "192.168.1.50" {
+		t.Errorf("FromRequest() with trusted proxy = %q, want forwarded address", got)
+	}
+}
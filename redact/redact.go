@@ -0,0 +1,123 @@
+// Package redact masks secrets, such as API keys and bearer tokens, in log
+// lines before they leave the dashboard. The rules are combined into one
+// regular expression, so a line is scanned once however many rules there
+// are, in time linear in its length.
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Rule is a named pattern whose matches are replaced with
+// "[REDACTED:<name>]". If the pattern has a group named "secret", only that
+// group is replaced, so `token=(?P<secret>\w+)` keeps "token=" readable.
+type Rule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// DefaultRules redact common token formats.
+var DefaultRules = []Rule{
+	{Name: "aws_access_key", Pattern: `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{Name: "aws_secret_key", Pattern: `(?i)aws_secret_access_key["']?\s*[:=]\s*["']?(?P<secret>[A-Za-z0-9/+]{40})`},
+	{Name: "bearer_token", Pattern: `(?i)\bbearer\s+(?P<secret>[A-Za-z0-9\-._~+/]+=*)`},
+	{Name: "hex", Pattern: `\b[0-9a-fA-F]{32,}\b`},
+}
+
+// Redactor replaces the matches of its rules in log lines. A nil Redactor
+// leaves lines as they are.
+type Redactor struct {
+	re    *regexp.Regexp
+	rules []compiledRule
+}
+
+// compiledRule locates a rule's match in the submatches of the combined
+// expression.
+type compiledRule struct {
+	replacement string
+	group       int // Group of the whole rule
+	secret      int // Group of its secret, or group if it has none
+}
+
+// New compiles rules into a Redactor. Where matches of several rules
+// overlap, the one starting first is redacted, and of those starting at the
+// same place, the rule listed first.
+func New(rules []Rule) (*Redactor, error) {
+	r := &Redactor{}
+	alternatives := make([]string, 0, len(rules))
+	seen := make(map[string]bool, len(rules))
+	group := 1
+	var errs []error
+	for _, rule := range rules {
+		if rule.Name == "" {
+			errs = append(errs, fmt.Errorf("redaction rule %q has no name", rule.Pattern))
+			continue
+		}
+		if seen[rule.Name] {
+			errs = append(errs, fmt.Errorf("redaction rule %q is defined twice", rule.Name))
+			continue
+		}
+		seen[rule.Name] = true
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("redaction rule %q: %w", rule.Name, err))
+			continue
+		}
+		if re.MatchString("") {
+			errs = append(errs, fmt.Errorf("redaction rule %q matches an empty string", rule.Name))
+			continue
+		}
+
+		compiled := compiledRule{replacement: "[REDACTED:" + rule.Name + "]", group: group, secret: group}
+		if i := re.SubexpIndex("secret"); i > 0 {
+			compiled.secret = group + i
+		}
+		r.rules = append(r.rules, compiled)
+		alternatives = append(alternatives, "("+rule.Pattern+")")
+		group += 1 + re.NumSubexp()
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	if len(alternatives) == 0 {
+		return nil, nil
+	}
+	r.re = regexp.MustCompile(strings.Join(alternatives, "|"))
+	return r, nil
+}
+
+// Line returns line with the matches of every rule redacted.
+func (r *Redactor) Line(line string) string {
+	if r == nil {
+		return line
+	}
+	matches := r.re.FindAllStringSubmatchIndex(line, -1)
+	if matches == nil {
+		return line
+	}
+
+	var b strings.Builder
+	b.Grow(len(line))
+	last := 0
+	for _, m := range matches {
+		for _, rule := range r.rules {
+			if m[2*rule.group] < 0 {
+				continue
+			}
+			start, end := m[2*rule.secret], m[2*rule.secret+1]
+			if start < 0 {
+				// An optional secret group that didn't take part
+				start, end = m[2*rule.group], m[2*rule.group+1]
+			}
+			b.WriteString(line[last:start])
+			b.WriteString(rule.replacement)
+			last = end
+			break
+		}
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
@@ -0,0 +1,340 @@
+// Package resilience provides retry and circuit-breaker helpers for calls to
+// remote hosts. Idempotent reads (service lists, log connects, Traefik
+// mappings) are retried with jittered backoff, and each host has a circuit
+// breaker that fails calls fast after repeated failures so a flaky host does
+// not stall every refresh. Actions (start/stop/restart) are never retried.
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when a call is rejected because the host's
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// Clock abstracts time so the breaker and backoff can be tested deterministically.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// State is the state of a circuit breaker.
+type State string
+
+const (
+	StateClosed   State = "closed"    // Calls pass through
+	StateOpen     State = "open"      // Calls fail fast until the cool-down expires
+	StateHalfOpen State = "half-open" // A single probe call is allowed through
+)
+
+// Snapshot is a point-in-time view of a circuit breaker.
+type Snapshot struct {
+	State     State      `json:"state"`
+	Failures  int        `json:"failures"`             // Consecutive failures
+	LastError string     `json:"last_error,omitempty"` // Most recent failure
+	RetryAt   *time.Time `json:"retry_at,omitempty"`   // When an open breaker allows a probe
+}
+
+// Breaker is a consecutive-failure circuit breaker for a single host.
+// After threshold consecutive failures it opens and rejects calls with
+// ErrCircuitOpen until cooldown has elapsed, then lets one probe through.
+// A successful probe closes the breaker; a failed probe re-opens it.
+type Breaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	clock     Clock
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	lastErr  string
+	probing  bool
+}
+
+// NewBreaker creates a closed circuit breaker. A nil clock uses the real clock.
+func NewBreaker(name string, threshold int, cooldown time.Duration, clock Clock) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &Breaker{
+		name:      name,
+		threshold: threshold,
+		cooldown:  cooldown,
+		clock:     clock,
+		state:     StateClosed,
+	}
+}
+
+// Allow reports whether a call may proceed. It returns an error wrapping
+// ErrCircuitOpen if the breaker is open or a half-open probe is in flight.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		retryAt := b.openedAt.Add(b.cooldown)
+		if now := b.clock.Now(); now.Before(retryAt) {
+			return fmt.Errorf("%w for %s (retry in %s)", ErrCircuitOpen, b.name, retryAt.Sub(now).Round(time.Second))
+		}
+		b.state = StateHalfOpen
+		b.probing = true
+		return nil
+	case StateHalfOpen:
+		if b.probing {
+			return fmt.Errorf("%w for %s (probe in progress)", ErrCircuitOpen, b.name)
+		}
+		b.probing = true
+		return nil
+	}
+	return nil
+}
+
+// Success records a successful call and closes the breaker.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = StateClosed
+	b.failures = 0
+	b.lastErr = ""
+	b.probing = false
+}
+
+// Failure records a failed call, opening the breaker if the threshold is
+// reached or a half-open probe failed.
+func (b *Breaker) Failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if err != nil {
+		b.lastErr = err.Error()
+	}
+	b.probing = false
+
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		b.state = StateOpen
+		b.openedAt = b.clock.Now()
+	}
+}
+
+// Snapshot returns the current breaker state.
+func (b *Breaker) Snapshot() Snapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := Snapshot{
+		State:     b.state,
+		Failures:  b.failures,
+		LastError: b.lastErr,
+	}
+	if b.state == StateOpen {
+		retryAt := b.openedAt.Add(b.cooldown)
+		s.RetryAt = &retryAt
+	}
+	return s
+}
+
+// RetryPolicy controls how idempotent reads are retried.
+type RetryPolicy struct {
+	Retries   int           // Retries after the first attempt
+	BaseDelay time.Duration // Backoff before the first retry, doubled each retry
+	MaxDelay  time.Duration // Upper bound on a single backoff
+}
+
+// DefaultRetryPolicy retries twice with 200ms, then 400ms base backoff.
+var DefaultRetryPolicy = RetryPolicy{
+	Retries:   2,
+	BaseDelay: 200 * time.Millisecond,
+	MaxDelay:  2 * time.Second,
+}
+
+// backoff returns the jittered delay before retry number attempt (0-based).
+// The delay is drawn uniformly from [d/2, d) where d is the exponential backoff,
+// so concurrent callers don't retry in lockstep.
+func (p RetryPolicy) backoff(attempt int, rnd func() float64) time.Duration {
+	d := p.BaseDelay << attempt
+	if p.MaxDelay > 0 && (d > p.MaxDelay || d <= 0) {
+		d = p.MaxDelay
+	}
+	half := d / 2
+	return half + time.Duration(rnd()*float64(half))
+}
+
+// Retry calls fn until it succeeds, the retries are exhausted, or ctx is done.
+// Errors wrapping ErrCircuitOpen or a context error are not retried.
+func Retry(ctx context.Context, policy RetryPolicy, clock Clock, rnd func() float64, fn func(ctx context.Context) error) error {
+	if clock == nil {
+		clock = realClock{}
+	}
+	if rnd == nil {
+		rnd = rand.Float64
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = fn(ctx)
+		if err == nil || !retryable(ctx, err) || attempt >= policy.Retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-clock.After(policy.backoff(attempt, rnd)):
+		}
+	}
+}
+
+// retryable reports whether a failed call is worth retrying.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	return true
+}
+
+// Registry holds one circuit breaker per host.
+type Registry struct {
+	threshold int
+	cooldown  time.Duration
+	retry     RetryPolicy
+	clock     Clock
+	rnd       func() float64
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+// NewRegistry creates a registry whose breakers open after threshold
+// consecutive failures and stay open for cooldown. A nil clock uses the real clock.
+func NewRegistry(threshold int, cooldown time.Duration, retry RetryPolicy, clock Clock) *Registry {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &Registry{
+		threshold: threshold,
+		cooldown:  cooldown,
+		retry:     retry,
+		clock:     clock,
+		breakers:  make(map[string]*Breaker),
+	}
+}
+
+// Breaker returns the circuit breaker for host, creating it if needed.
+func (r *Registry) Breaker(host string) *Breaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.breakers[host]
+	if !ok {
+		b = NewBreaker(host, r.threshold, r.cooldown, r.clock)
+		r.breakers[host] = b
+	}
+	return b
+}
+
+// Do runs an idempotent read against host through its circuit breaker,
+// retrying transient failures. The whole call, including retries, counts as
+// one success or failure for the breaker. A call abandoned because the caller
+// went away (context canceled) is not counted.
+func (r *Registry) Do(ctx context.Context, host string, fn func(ctx context.Context) error) error {
+	b := r.Breaker(host)
+	if err := b.Allow(); err != nil {
+		return err
+	}
+
+	err := Retry(ctx, r.retry, r.clock, r.rnd, fn)
+	switch {
+	case err == nil:
+		b.Success()
+	case errors.Is(ctx.Err(), context.Canceled):
+		// Caller gave up; release a half-open probe without judging the host
+		b.mu.Lock()
+		b.probing = false
+		b.mu.Unlock()
+	default:
+		b.Failure(err)
+	}
+	return err
+}
+
+// Snapshot returns the state of every breaker, keyed by host.
+func (r *Registry) Snapshot() map[string]Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make(map[string]Snapshot, len(r.breakers))
+	for host, b := range r.breakers {
+		result[host] = b.Snapshot()
+	}
+	return result
+}
+
+// Default breaker settings.
+const (
+	DefaultThreshold = 3
+	DefaultCooldown  = 30 * time.Second
+)
+
+var (
+	defaultMu       sync.RWMutex
+	defaultRegistry = NewRegistry(DefaultThreshold, DefaultCooldown, DefaultRetryPolicy, nil)
+)
+
+// Configure replaces the default registry with one using the given breaker
+// settings. Existing breaker state is discarded.
+func Configure(threshold int, cooldown time.Duration) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultRegistry = NewRegistry(threshold, cooldown, DefaultRetryPolicy, nil)
+}
+
+func getDefault() *Registry {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultRegistry
+}
+
+// Do runs fn against host using the default registry. See Registry.Do.
+func Do(ctx context.Context, host string, fn func(ctx context.Context) error) error {
+	return getDefault().Do(ctx, host, fn)
+}
+
+// HostSnapshot returns the default registry's breaker state for host.
+// Hosts that have never been called report a closed breaker.
+func HostSnapshot(host string) Snapshot {
+	r := getDefault()
+	r.mu.Lock()
+	b, ok := r.breakers[host]
+	r.mu.Unlock()
+	if !ok {
+		return Snapshot{State: StateClosed}
+	}
+	return b.Snapshot()
+}
+
+// AllSnapshots returns the default registry's breaker state for all hosts.
+func AllSnapshots() map[string]Snapshot {
+	return getDefault().Snapshot()
+}
@@ -0,0 +1,286 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock. After fires immediately and records
+// the requested delay so tests can assert on backoff without sleeping.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	delays []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	c.delays = append(c.delays, d)
+	c.now = c.now.Add(d)
+	now := c.now
+	c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	ch <- now
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+var errBoom = errors.New("boom")
+
+func TestBreaker_OpensAfterThreshold(t *testing.T) {
+	clock := newFakeClock()
+	b := NewBreaker("nas", 3, 30*time.Second, clock)
+
+	for i := 0; i < 2; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Allow() before threshold returned %v", err)
+		}
+		b.Failure(errBoom)
+	}
+	if got := b.Snapshot().State; got != StateClosed {
+		t.Fatalf("state after 2 failures = %s, want closed", got)
+	}
+
+	b.Failure(errBoom)
+	snap := b.Snapshot()
+	if snap.State != StateOpen {
+		t.Fatalf("state after 3 failures = %s, want open", snap.State)
+	}
+	if snap.LastError != "boom" {
+		t.Errorf("LastError = %q, want boom", snap.LastError)
+	}
+	if snap.RetryAt == nil || !snap.RetryAt.Equal(clock.Now().Add(30*time.Second)) {
+		t.Errorf("RetryAt = %v, want %v", snap.RetryAt, clock.Now().Add(30*time.Second))
+	}
+
+	err := b.Allow()
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow() while open = %v, want ErrCircuitOpen", err)
+	}
+}
+
+func TestBreaker_SuccessResetsFailures(t *testing.T) {
+	b := NewBreaker("nas", 2, time.Minute, newFakeClock())
+
+	b.Failure(errBoom)
+	b.Success()
+	b.Failure(errBoom)
+
+	if got := b.Snapshot().State; got != StateClosed {
+		t.Errorf("state = %s, want closed (failures were not consecutive)", got)
+	}
+}
+
+func TestBreaker_HalfOpenProbe(t *testing.T) {
+	clock := newFakeClock()
+	b := NewBreaker("nas", 1, 10*time.Second, clock)
+
+	b.Failure(errBoom)
+	clock.Advance(10 * time.Second)
+
+	// First call after cool-down is the probe
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe Allow() = %v, want nil", err)
+	}
+	if got := b.Snapshot().State; got != StateHalfOpen {
+		t.Fatalf("state = %s, want half-open", got)
+	}
+
+	// Concurrent calls fail fast while the probe is in flight
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second Allow() during probe = %v, want ErrCircuitOpen", err)
+	}
+
+	// A failed probe re-opens the breaker for a fresh cool-down
+	b.Failure(errBoom)
+	if got := b.Snapshot().State; got != StateOpen {
+		t.Fatalf("state after failed probe = %s, want open", got)
+	}
+	clock.Advance(5 * time.Second)
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow() during new cool-down = %v, want ErrCircuitOpen", err)
+	}
+
+	// A successful probe closes it
+	clock.Advance(5 * time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("second probe Allow() = %v, want nil", err)
+	}
+	b.Success()
+	snap := b.Snapshot()
+	if snap.State != StateClosed || snap.Failures != 0 || snap.RetryAt != nil {
+		t.Errorf("snapshot after successful probe = %+v, want closed with no failures", snap)
+	}
+}
+
+func TestRetry_SucceedsAfterTransientFailures(t *testing.T) {
+	clock := newFakeClock()
+	policy := RetryPolicy{Retries: 2, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	calls := 0
+	err := Retry(context.Background(), policy, clock, func() float64 { return 0.5 }, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errBoom
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Retry() = %v, want nil", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+
+	// Backoff doubles; jitter of 0.5 picks the midpoint of [d/2, d)
+	want := []time.Duration{75 * time.Millisecond, 150 * time.Millisecond}
+	if len(clock.delays) != len(want) {
+		t.Fatalf("delays = %v, want %v", clock.delays, want)
+	}
+	for i := range want {
+		if clock.delays[i] != want[i] {
+			t.Errorf("delay[%d] = %v, want %v", i, clock.delays[i], want[i])
+		}
+	}
+}
+
+func TestRetry_GivesUpAfterRetries(t *testing.T) {
+	clock := newFakeClock()
+	policy := RetryPolicy{Retries: 2, BaseDelay: 100 * time.Millisecond}
+
+	calls := 0
+	err := Retry(context.Background(), policy, clock, func() float64 { return 0 }, func(ctx context.Context) error {
+		calls++
+		return errBoom
+	})
+
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("Retry() = %v, want errBoom", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3 (1 attempt + 2 retries)", calls)
+	}
+}
+
+func TestRetry_DoesNotRetryCircuitOpenOrDeadline(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+	}{
+		{"circuit open", ErrCircuitOpen},
+		{"deadline", context.DeadlineExceeded},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			err := Retry(context.Background(), DefaultRetryPolicy, newFakeClock(), nil, func(ctx context.Context) error {
+				calls++
+				return tc.err
+			})
+			if !errors.Is(err, tc.err) {
+				t.Errorf("Retry() = %v, want %v", err, tc.err)
+			}
+			if calls != 1 {
+				t.Errorf("calls = %d, want 1", calls)
+			}
+		})
+	}
+}
+
+func TestBackoff_JitterBounds(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 200 * time.Millisecond, MaxDelay: 500 * time.Millisecond}
+
+	tests := []struct {
+		attempt int
+		rnd     float64
+		want    time.Duration
+	}{
+		{0, 0, 100 * time.Millisecond},
+		{0, 0.999, 199900 * time.Microsecond},
+		{1, 0, 200 * time.Millisecond},
+		{2, 0, 250 * time.Millisecond}, // 800ms capped to 500ms
+	}
+	for _, tt := range tests {
+		got := policy.backoff(tt.attempt, func() float64 { return tt.rnd })
+		if got != tt.want {
+			t.Errorf("backoff(%d, %v) = %v, want %v", tt.attempt, tt.rnd, got, tt.want)
+		}
+	}
+}
+
+func TestRegistry_DoOpensBreakerPerHost(t *testing.T) {
+	clock := newFakeClock()
+	r := NewRegistry(2, time.Minute, RetryPolicy{Retries: 1, BaseDelay: time.Millisecond}, clock)
+
+	calls := 0
+	failing := func(ctx context.Context) error {
+		calls++
+		return errBoom
+	}
+
+	// Each Do counts once toward the breaker even though it retries
+	for i := 0; i < 2; i++ {
+		if err := r.Do(context.Background(), "slow", failing); !errors.Is(err, errBoom) {
+			t.Fatalf("Do() = %v, want errBoom", err)
+		}
+	}
+	if calls != 4 {
+		t.Errorf("calls = %d, want 4", calls)
+	}
+
+	calls = 0
+	err := r.Do(context.Background(), "slow", failing)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Do() with open breaker = %v, want ErrCircuitOpen", err)
+	}
+	if calls != 0 {
+		t.Errorf("fn called %d times with open breaker, want 0", calls)
+	}
+
+	// Other hosts are unaffected
+	if err := r.Do(context.Background(), "fast", func(ctx context.Context) error { return nil }); err != nil {
+		t.Errorf("Do() on healthy host = %v, want nil", err)
+	}
+
+	snaps := r.Snapshot()
+	if snaps["slow"].State != StateOpen {
+		t.Errorf("slow state = %s, want open", snaps["slow"].State)
+	}
+	if snaps["fast"].State != StateClosed {
+		t.Errorf("fast state = %s, want closed", snaps["fast"].State)
+	}
+}
+
+func TestRegistry_DoIgnoresCanceledCaller(t *testing.T) {
+	r := NewRegistry(1, time.Minute, RetryPolicy{}, newFakeClock())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := r.Do(ctx, "nas", func(ctx context.Context) error { return ctx.Err() })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Do() = %v, want context.Canceled", err)
+	}
+	if got := r.Snapshot()["nas"].State; got != StateClosed {
+		t.Errorf("state = %s, want closed (canceled calls don't count)", got)
+	}
+}
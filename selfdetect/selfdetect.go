@@ -0,0 +1,131 @@
+// Package selfdetect works out which configured service is the dashboard
+// itself, so actions that would restart or stop it can be flagged before the
+// connection that started them drops.
+package selfdetect
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"home_server_dashboard/services"
+)
+
+// Identity describes where the running dashboard lives.
+type Identity struct {
+	// Unit is the systemd unit the dashboard runs in (empty if not run by systemd).
+	Unit string
+	// ContainerID is the Docker container ID the dashboard runs in, if any.
+	// It may be a short ID when it was taken from the hostname.
+	ContainerID string
+	// ContainerName is the resolved name of the container (set once the ID has
+	// been looked up through Docker).
+	ContainerName string
+}
+
+// containerIDPattern matches a full Docker container ID.
+var containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// shortIDPattern matches the short container ID Docker uses as the default hostname.
+var shortIDPattern = regexp.MustCompile(`^[0-9a-f]{12,64}$`)
+
+// unitSuffix is the suffix of the systemd units the dashboard can run as.
+const unitSuffix = ".service"
+
+// ParseCgroup extracts the systemd unit and Docker container ID from the
+// contents of /proc/self/cgroup. Both cgroup v1 ("12:memory:/docker/<id>")
+// and v2 ("0::/system.slice/docker-<id>.scope") layouts are recognized. The
+// unit is the innermost ".service" component of the path, which also works
+// for user services under user@.service.
+func ParseCgroup(data string) (unit, containerID string) {
+	for _, line := range strings.Split(data, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		path := parts[2]
+
+		if containerID == "" {
+			containerID = containerIDPattern.FindString(path)
+		}
+
+		components := strings.Split(path, "/")
+		for i := len(components) - 1; i >= 0; i-- {
+			component := components[i]
+			if strings.HasSuffix(component, unitSuffix) && !strings.HasPrefix(component, "user@") {
+				if unit == "" {
+					unit = component
+				}
+				break
+			}
+		}
+	}
+	return unit, containerID
+}
+
+// Detect inspects the process environment. A unit is only reported when
+// systemd started the process (INVOCATION_ID is set), since a dashboard run
+// from a shell also sits inside some session's cgroup. When the cgroup
+// namespace hides the container ID, a hostname that looks like a container ID
+// is used instead; callers must confirm it through Docker before trusting it.
+func Detect() Identity {
+	data, _ := os.ReadFile("/proc/self/cgroup")
+	return detect(string(data), os.Getenv("INVOCATION_ID"), os.Getenv("HOSTNAME"))
+}
+
+// detect is Detect with its inputs passed in.
+func detect(cgroup, invocationID, hostname string) Identity {
+	unit, containerID := ParseCgroup(cgroup)
+
+	var id Identity
+	if invocationID != "" {
+		id.Unit = unit
+	}
+	id.ContainerID = containerID
+	if id.ContainerID == "" && shortIDPattern.MatchString(hostname) {
+		id.ContainerID = hostname
+	}
+	return id
+}
+
+// Matches reports whether the service on the given source and host is the
+// dashboard itself. Units are matched on the local host only; container names
+// are unique to the local Docker daemon.
+func (id Identity) Matches(source, host, name, localHost string) bool {
+	switch source {
+	case "systemd":
+		return id.Unit != "" && host == localHost && name == id.Unit
+	case "docker":
+		return id.ContainerName != "" && name == id.ContainerName
+	}
+	return false
+}
+
+// Mark sets IsSelf on the services that are the dashboard itself.
+func (id Identity) Mark(svcs []services.ServiceInfo, localHost string) {
+	for i := range svcs {
+		if id.Matches(svcs[i].Source, svcs[i].Host, svcs[i].ContainerName, localHost) {
+			svcs[i].IsSelf = true
+		}
+	}
+}
+
+var (
+	currentMu sync.RWMutex
+	current   Identity
+)
+
+// Set records the detected identity for the rest of the process.
+func Set(id Identity) {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	current = id
+}
+
+// Get returns the identity recorded with Set.
+func Get() Identity {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current
+}
@@ -0,0 +1,86 @@
+package selfdetect
+
+import (
+	"testing"
+
+	"home_server_dashboard/services"
+)
+
+const testContainerID = "3f4e9c2b1a0d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f"
+
+func TestParseCgroup(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		wantUnit      string
+		wantContainer string
+	}{
+		{"cgroup v2 system service", "0::/system.slice/nas-dashboard.service\n", "nas-dashboard.service", ""},
+		{"cgroup v2 user service", "0::/user.slice/user-1000.slice/user@1000.service/app.slice/nas-dashboard.service\n", "nas-dashboard.service", ""},
+		{"cgroup v2 docker with systemd driver", "0::/system.slice/docker-" + testContainerID + ".scope\n", "", testContainerID},
+		{"cgroup v1 docker", "12:memory:/docker/" + testContainerID + "\n11:cpu,cpuacct:/docker/" + testContainerID + "\n", "", testContainerID},
+		{"cgroup v1 systemd", "12:pids:/system.slice/nas-dashboard.service\n1:name=systemd:/system.slice/nas-dashboard.service\n", "nas-dashboard.service", ""},
+		{"private cgroup namespace", "0::/\n", "", ""},
+		{"login session", "0::/user.slice/user-1000.slice/session-4.scope\n", "", ""},
+		{"user manager itself is not the unit", "0::/user.slice/user-1000.slice/user@1000.service/init.scope\n", "", ""},
+		{"empty", "", "", ""},
+		{"garbage", "not a cgroup line\n", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unit, containerID := ParseCgroup(tt.data)
+			if unit != tt.wantUnit {
+				t.Errorf("unit = %q, want %q", unit, tt.wantUnit)
+			}
+			if containerID != tt.wantContainer {
+				t.Errorf("containerID = %q, want %q", containerID, tt.wantContainer)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name         string
+		cgroup       string
+		invocationID string
+		hostname     string
+		want         Identity
+	}{
+		{"started by systemd", "0::/system.slice/nas-dashboard.service\n", "abc123", "nas", Identity{Unit: "nas-dashboard.service"}},
+		{"unit ignored without invocation ID", "0::/system.slice/nas-dashboard.service\n", "", "nas", Identity{}},
+		{"container from cgroup", "0::/system.slice/docker-" + testContainerID + ".scope\n", "", "dashboard", Identity{ContainerID: testContainerID}},
+		{"container from hostname", "0::/\n", "", "3f4e9c2b1a0d", Identity{ContainerID: "3f4e9c2b1a0d"}},
+		{"plain hostname is not a container", "0::/\n", "", "nas", Identity{}},
+		{"short hex hostname is not a container", "0::/\n", "", "cafe", Identity{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detect(tt.cgroup, tt.invocationID, tt.hostname); got != tt.want {
+				t.Errorf("detect() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMark(t *testing.T) {
+	id := Identity{Unit: "nas-dashboard.service", ContainerName: "dashboard"}
+	svcs := []services.ServiceInfo{
+		{ContainerName: "nas-dashboard.service", Source: "systemd", Host: "nas"},
+		{ContainerName: "nas-dashboard.service", Source: "systemd", Host: "remote"},
+		{ContainerName: "dashboard", Source: "docker", Host: "nas"},
+		{ContainerName: "jellyfin", Source: "docker", Host: "nas"},
+		{ContainerName: "dashboard", Source: "traefik", Host: "nas"},
+	}
+
+	id.Mark(svcs, "nas")
+
+	want := []bool{true, false, true, false, false}
+	for i, svc := range svcs {
+		if svc.IsSelf != want[i] {
+			t.Errorf("svcs[%d] (%s on %s) IsSelf = %v, want %v", i, svc.ContainerName, svc.Host, svc.IsSelf, want[i])
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package selftest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/httpclient"
+	"home_server_dashboard/services/docker"
+	"home_server_dashboard/services/homeassistant"
+	"home_server_dashboard/services/systemd"
+	"home_server_dashboard/services/traefik"
+	"home_server_dashboard/services/watchtower"
+)
+
+// BuildChecks returns the checks for every integration configured in cfg.
+// Integrations that are turned off get a disabled check instead, so the
+// report shows them without probing them.
+// Clients are created when a check runs, so building the list has no side effects.
+func BuildChecks(cfg *config.Config) []Check {
+	if cfg == nil {
+		return nil
+	}
+
+	var checks []Check
+
+	// Docker is only queried on the host running the dashboard
+	localHostName := cfg.GetLocalHostName()
+	if localHost := cfg.GetHostByName(localHostName); localHost == nil || localHost.HasDocker() {
+		checks = append(checks,
+			Check{Host: localHostName, Integration: "docker", Name: "ping", Run: withDocker(localHostName, (*docker.Provider).Ping)},
+			Check{Host: localHostName, Integration: "docker", Name: "list containers", Run: withDocker(localHostName, (*docker.Provider).CheckListContainers)},
+		)
+	}
+
+	for i := range cfg.Hosts {
+		host := cfg.Hosts[i]
+		for _, integration := range host.DisabledIntegrations() {
+			checks = append(checks, Check{Host: host.Name, Integration: integration, Name: "collection", Disabled: true})
+		}
+		if !host.IsEnabled() {
+			continue
+		}
+
+		if host.HasSystemd() {
+			checks = append(checks, systemdChecks(&host)...)
+		}
+
+		if host.HasTraefik() {
+			checks = append(checks, Check{Host: host.Name, Integration: "traefik", Name: "api", Run: traefikCheck(&host, cfg.OutboundFor(&host))})
+		}
+
+		if host.HasHomeAssistant() {
+			checkHealth := func(p *homeassistant.Provider, ctx context.Context) error {
+				_, _, err := p.CheckHealth(ctx)
+				return err
+			}
+			checks = append(checks, Check{Host: host.Name, Integration: "homeassistant", Name: "health", Run: withHomeAssistant(&host, checkHealth)})
+			if host.HasSupervisorAPI() {
+				checks = append(checks, Check{Host: host.Name, Integration: "homeassistant", Name: "supervisor ping", Run: withHomeAssistant(&host, (*homeassistant.Provider).PingSupervisor)})
+			}
+		}
+
+		if host.HasWatchtower() {
+			checks = append(checks, Check{Host: host.Name, Integration: "watchtower", Name: "metrics", Run: func(ctx context.Context) error {
+				client := watchtower.NewClient(&host)
+				if client == nil {
+					return fmt.Errorf("watchtower token is not configured")
+				}
+				_, err := client.GetMetrics(ctx)
+				return err
+			}})
+		}
+	}
+
+	return checks
+}
+
+// ComposeRoots returns the effective compose roots of each host that expands
+// docker_compose_roots_glob, so the report shows what the patterns matched.
+func ComposeRoots(cfg *config.Config) map[string][]string {
+	if cfg == nil {
+		return nil
+	}
+	var roots map[string][]string
+	for i := range cfg.Hosts {
+		host := &cfg.Hosts[i]
+		if len(host.DockerComposeRootsGlob) == 0 {
+			continue
+		}
+		if roots == nil {
+			roots = make(map[string][]string)
+		}
+		roots[host.Name] = host.ComposeRoots()
+	}
+	return roots
+}
+
+// withDocker wraps a Docker provider check so the client is created and closed per run.
+func withDocker(hostName string, fn func(*docker.Provider, context.Context) error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		provider, err := docker.NewProvider(hostName)
+		if err != nil {
+			return err
+		}
+		defer provider.Close()
+		return fn(provider, ctx)
+	}
+}
+
+// withHomeAssistant wraps a Home Assistant provider check so the client is created and closed per run.
+func withHomeAssistant(host *config.HostConfig, fn func(*homeassistant.Provider, context.Context) error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		provider, err := homeassistant.NewProvider(host)
+		if err != nil {
+			return err
+		}
+		defer provider.Close()
+		return fn(provider, ctx)
+	}
+}
+
+// systemdChecks returns the SSH, journal and per-unit checks for a host.
+func systemdChecks(host *config.HostConfig) []Check {
+	specs := host.GetServiceSpecs()
+	if len(specs) == 0 {
+		return nil
+	}
+
+	provider := systemd.NewProviderForHost(host)
+
+	var checks []Check
+	if !provider.IsLocal() {
+		checks = append(checks, Check{Host: host.Name, Integration: "ssh", Name: "login", Run: provider.CheckSSH})
+	}
+	journalCheck := "journalctl"
+	if host.GetJournalAccess() == config.JournalAccessSudo {
+		journalCheck = "sudo journalctl"
+	}
+	checks = append(checks, Check{Host: host.Name, Integration: "systemd", Name: journalCheck, Run: provider.CheckJournal})
+	for _, spec := range specs {
+		unitName := spec.UnitName
+		checks = append(checks, Check{Host: host.Name, Integration: "systemd", Name: "unit " + unitName, Run: func(ctx context.Context) error {
+			return provider.CheckUnit(ctx, unitName)
+		}})
+	}
+	return checks
+}
+
+// traefikCheck returns a check that queries the Traefik API with the host's
+// outbound settings, tunnelling over SSH for remote hosts.
+func traefikCheck(host *config.HostConfig, outbound config.Outbound) func(context.Context) error {
+	var sshConfig *traefik.SSHConfig
+	if host.SSHConfig != nil {
+		sshConfig = &traefik.SSHConfig{
+			Username: host.SSHConfig.Username,
+			Port:     host.SSHConfig.Port,
+		}
+	}
+	return func(ctx context.Context) error {
+		client := traefik.NewClient(host.Name, host.Address, host.Traefik.APIPort, sshConfig, httpclient.New(outbound, 10*time.Second))
+		defer client.Close()
+		return client.CheckAPI(ctx)
+	}
+}
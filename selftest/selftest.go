@@ -0,0 +1,242 @@
+// Package selftest runs a real check against every configured integration
+// and reports which ones work, with a hint for the common failures.
+package selftest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// DefaultTimeout is how long a single check may run before it is failed.
+const DefaultTimeout = 10 * time.Second
+
+// Check is a single integration check against one host.
+type Check struct {
+	// Host is the configured host name the check runs against.
+	Host string
+	// Integration is the integration being checked (e.g., "docker", "systemd").
+	Integration string
+	// Name describes what the check does (e.g., "ping", "unit nginx.service").
+	Name string
+	// Run performs the check, returning nil on success.
+	Run func(ctx context.Context) error
+	// Disabled marks an integration that is configured but turned off. It
+	// is reported without running.
+	Disabled bool
+}
+
+// Result is the outcome of a single check.
+type Result struct {
+	Host        string `json:"host"`
+	Integration string `json:"integration"`
+	Check       string `json:"check"`
+	Passed      bool   `json:"passed"`
+	Disabled    bool   `json:"disabled,omitempty"`
+	Error       string `json:"error,omitempty"`
+	Hint        string `json:"hint,omitempty"`
+	DurationMS  int64  `json:"duration_ms"`
+}
+
+// Report is the outcome of a self-test run.
+type Report struct {
+	Results []Result `json:"results"`
+	Passed  int      `json:"passed"`
+	Failed  int      `json:"failed"`
+	// Disabled counts the integrations that are turned off in the config.
+	Disabled int `json:"disabled,omitempty"`
+	// SecretKeys lists the config keys loaded from the encrypted secrets file.
+	SecretKeys []string `json:"secret_keys,omitempty"`
+	// ComposeRoots lists the effective compose roots of each host with
+	// docker_compose_roots_glob set, keyed by host name.
+	ComposeRoots map[string][]string `json:"compose_roots,omitempty"`
+}
+
+// OK reports whether every check passed.
+func (r *Report) OK() bool {
+	return r.Failed == 0
+}
+
+// Run executes the checks and assembles a report. Hosts are checked
+// concurrently while checks for the same host run in order, so a broken host
+// doesn't slow down the others and per-host SSH limits aren't exceeded.
+// Results are returned in the order the checks were given.
+func Run(ctx context.Context, checks []Check, timeout time.Duration) *Report {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	results := make([]Result, len(checks))
+
+	// Group check indexes by host, preserving order
+	byHost := make(map[string][]int)
+	var hosts []string
+	for i, c := range checks {
+		if _, ok := byHost[c.Host]; !ok {
+			hosts = append(hosts, c.Host)
+		}
+		byHost[c.Host] = append(byHost[c.Host], i)
+	}
+
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(indexes []int) {
+			defer wg.Done()
+			for _, i := range indexes {
+				results[i] = runCheck(ctx, checks[i], timeout)
+			}
+		}(byHost[host])
+	}
+	wg.Wait()
+
+	report := &Report{Results: results}
+	for _, r := range results {
+		switch {
+		case r.Disabled:
+			report.Disabled++
+		case r.Passed:
+			report.Passed++
+		default:
+			report.Failed++
+		}
+	}
+	return report
+}
+
+// runCheck runs a single check with its own timeout.
+func runCheck(ctx context.Context, c Check, timeout time.Duration) Result {
+	result := Result{
+		Host:        c.Host,
+		Integration: c.Integration,
+		Check:       c.Name,
+	}
+	if c.Disabled {
+		result.Disabled = true
+		return result
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.Run(checkCtx)
+	result.DurationMS = time.Since(start).Milliseconds()
+
+	if err == nil && checkCtx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("check timed out after %s", timeout)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.Hint = Hint(err)
+		return result
+	}
+	result.Passed = true
+	return result
+}
+
+// hints maps error text fragments to suggestions. Entries are matched in
+// order, so more specific fragments come first.
+var hints = []struct {
+	fragments []string
+	hint      string
+}{
+	{[]string{"docker.sock", "permission denied"}, "add the dashboard user to the docker group and restart the service"},
+	{[]string{"cannot connect to the docker daemon"}, "check that Docker is running and /var/run/docker.sock exists"},
+	{[]string{"host key verification failed"}, "connect once with ssh to accept the host key into known_hosts"},
+	{[]string{"publickey"}, "install the dashboard user's SSH key on the host (ssh-copy-id)"},
+	{[]string{"interactive authentication required"}, "install polkit rules for local units (--generate-polkit)"},
+	{[]string{"org.freedesktop.dbus.error.accessdenied"}, "install polkit rules for local units (--generate-polkit)"},
+	{[]string{"sudo", "password is required"}, "install the sudoers rules on the remote host (--generate-sudoers)"},
+	{[]string{"unit", "not found"}, "check the unit name in systemd_services; it is not loaded on the host"},
+	{[]string{"executable file not found"}, "install the missing command on the host running the dashboard"},
+	{[]string{"command not found"}, "install the missing command on the target host"},
+	{[]string{"supervisor_token"}, "run the SSH add-on with protection mode disabled or set a supervisor token"},
+	{[]string{"no such host"}, "check the host address; the name does not resolve"},
+	{[]string{"connection refused"}, "check that the service is running and listening on the configured port"},
+	{[]string{"no route to host"}, "check that the host is up and reachable from the dashboard"},
+	{[]string{"status 401"}, "check the configured API token"},
+	{[]string{"unauthorized"}, "check the configured API token"},
+	{[]string{"status 403"}, "the token is valid but lacks permission for this API"},
+	{[]string{"timed out"}, "the host did not respond in time; check that it is reachable"},
+	{[]string{"deadline exceeded"}, "the host did not respond in time; check that it is reachable"},
+	{[]string{"i/o timeout"}, "the host did not respond in time; check that it is reachable"},
+}
+
+// Hint returns a suggestion for fixing a failed check, or an empty string
+// if the error isn't recognized.
+func Hint(err error) string {
+	if err == nil {
+		return ""
+	}
+	msg := strings.ToLower(err.Error())
+	for _, h := range hints {
+		matched := true
+		for _, fragment := range h.fragments {
+			if !strings.Contains(msg, fragment) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return h.hint
+		}
+	}
+	return ""
+}
+
+// WriteTable writes the report as a human-readable table followed by a summary line.
+func (r *Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "HOST\tINTEGRATION\tCHECK\tRESULT\tDURATION")
+	for _, res := range r.Results {
+		status := "PASS"
+		if res.Disabled {
+			status = "DISABLED"
+		} else if !res.Passed {
+			status = "FAIL"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%dms\n", res.Host, res.Integration, res.Check, status, res.DurationMS)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, res := range r.Results {
+		if res.Passed || res.Disabled {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s %s %s: %s\n", res.Host, res.Integration, res.Check, res.Error)
+		if res.Hint != "" {
+			fmt.Fprintf(w, "  hint: %s\n", res.Hint)
+		}
+	}
+
+	if len(r.SecretKeys) > 0 {
+		fmt.Fprintf(w, "\nFrom encrypted secrets: %s\n", strings.Join(r.SecretKeys, ", "))
+	}
+
+	if len(r.ComposeRoots) > 0 {
+		fmt.Fprintln(w)
+		for _, host := range slices.Sorted(maps.Keys(r.ComposeRoots)) {
+			roots := strings.Join(r.ComposeRoots[host], ", ")
+			if roots == "" {
+				roots = "(none)"
+			}
+			fmt.Fprintf(w, "Compose roots of %s: %s\n", host, roots)
+		}
+	}
+
+	if r.Disabled > 0 {
+		_, err := fmt.Fprintf(w, "\n%d passed, %d failed, %d disabled\n", r.Passed, r.Failed, r.Disabled)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d passed, %d failed\n", r.Passed, r.Failed)
+	return err
+}
@@ -0,0 +1,323 @@
+package selftest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"home_server_dashboard/config"
+)
+
+func passing(ctx context.Context) error { return nil }
+
+func failing(msg string) func(context.Context) error {
+	return func(ctx context.Context) error { return errors.New(msg) }
+}
+
+func TestRun_AssemblesReportInOrder(t *testing.T) {
+	checks := []Check{
+		{Host: "nas", Integration: "docker", Name: "ping", Run: passing},
+		{Host: "pi", Integration: "ssh", Name: "login", Run: failing("ssh failed: exit status 255: Permission denied (publickey).")},
+		{Host: "nas", Integration: "systemd", Name: "journalctl", Run: passing},
+		{Host: "pi", Integration: "systemd", Name: "unit nginx.service", Run: failing("unit nginx.service not found")},
+	}
+
+	report := Run(context.Background(), checks, time.Second)
+
+	if report.Passed != 2 || report.Failed != 2 {
+		t.Fatalf("Passed/Failed = %d/%d, want 2/2", report.Passed, report.Failed)
+	}
+	if report.OK() {
+		t.Error("OK() = true with failures")
+	}
+	if len(report.Results) != len(checks) {
+		t.Fatalf("len(Results) = %d, want %d", len(report.Results), len(checks))
+	}
+	for i, c := range checks {
+		got := report.Results[i]
+		if got.Host != c.Host || got.Integration != c.Integration || got.Check != c.Name {
+			t.Errorf("Results[%d] = %s/%s/%s, want %s/%s/%s", i, got.Host, got.Integration, got.Check, c.Host, c.Integration, c.Name)
+		}
+	}
+
+	ssh := report.Results[1]
+	if ssh.Passed {
+		t.Error("ssh check passed, want failure")
+	}
+	if !strings.Contains(ssh.Error, "publickey") {
+		t.Errorf("ssh Error = %q, want the command output", ssh.Error)
+	}
+	if !strings.Contains(ssh.Hint, "ssh-copy-id") {
+		t.Errorf("ssh Hint = %q, want ssh-copy-id suggestion", ssh.Hint)
+	}
+	if report.Results[0].Error != "" || report.Results[0].Hint != "" {
+		t.Errorf("passing check has Error=%q Hint=%q", report.Results[0].Error, report.Results[0].Hint)
+	}
+}
+
+func TestRun_AllPassed(t *testing.T) {
+	report := Run(context.Background(), []Check{
+		{Host: "nas", Integration: "docker", Name: "ping", Run: passing},
+	}, time.Second)
+	if !report.OK() {
+		t.Errorf("OK() = false, results: %+v", report.Results)
+	}
+}
+
+func TestRun_TimeoutFailsCheck(t *testing.T) {
+	hung := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	start := time.Now()
+	report := Run(context.Background(), []Check{
+		{Host: "pi", Integration: "traefik", Name: "api", Run: hung},
+	}, 20*time.Millisecond)
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Run took %v, timeout was not applied", elapsed)
+	}
+	r := report.Results[0]
+	if r.Passed {
+		t.Fatal("hung check passed")
+	}
+	if !strings.Contains(r.Hint, "did not respond in time") {
+		t.Errorf("Hint = %q, want timeout suggestion", r.Hint)
+	}
+}
+
+func TestRun_HostsRunConcurrentlyChecksSequentially(t *testing.T) {
+	var running, maxPerHost atomic.Int32
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+
+	blocking := func(ctx context.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+	perHost := func(ctx context.Context) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		if n > maxPerHost.Load() {
+			maxPerHost.Store(n)
+		}
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}
+
+	done := make(chan *Report)
+	go func() {
+		done <- Run(context.Background(), []Check{
+			{Host: "a", Integration: "x", Name: "block", Run: blocking},
+			{Host: "b", Integration: "x", Name: "block", Run: blocking},
+		}, time.Second)
+	}()
+
+	// Both hosts must start before either finishes
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("hosts were not checked concurrently")
+		}
+	}
+	close(release)
+	<-done
+
+	Run(context.Background(), []Check{
+		{Host: "a", Integration: "x", Name: "1", Run: perHost},
+		{Host: "a", Integration: "x", Name: "2", Run: perHost},
+		{Host: "a", Integration: "x", Name: "3", Run: perHost},
+	}, time.Second)
+	if maxPerHost.Load() != 1 {
+		t.Errorf("max concurrent checks for one host = %d, want 1", maxPerHost.Load())
+	}
+}
+
+func TestHint(t *testing.T) {
+	tests := []struct {
+		err  string
+		want string
+	}{
+		{"docker ping failed: permission denied while trying to connect to the Docker daemon socket at unix:///var/run/docker.sock", "docker group"},
+		{"ssh failed: exit status 255: Host key verification failed.", "known_hosts"},
+		{"ssh failed: exit status 255: user@pi: Permission denied (publickey,password).", "ssh-copy-id"},
+		{"ssh failed: exit status 255: ssh: Could not resolve hostname pi: No such host", "does not resolve"},
+		{"failed to fetch overview: dial tcp 127.0.0.1:8080: connect: connection refused", "listening"},
+		{"Watchtower API returned status 401: Unauthorized", "API token"},
+		{"Home Assistant API returned status 403", "lacks permission"},
+		{"Interactive authentication required.", "--generate-polkit"},
+		{"unit nginx.service not found", "systemd_services"},
+		{"journalctl failed: exec: \"journalctl\": executable file not found in $PATH", "install the missing command"},
+		{"context deadline exceeded", "did not respond in time"},
+		{"something else entirely", ""},
+	}
+
+	for _, tt := range tests {
+		got := Hint(errors.New(tt.err))
+		if tt.want == "" {
+			if got != "" {
+				t.Errorf("Hint(%q) = %q, want none", tt.err, got)
+			}
+			continue
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("Hint(%q) = %q, want it to mention %q", tt.err, got, tt.want)
+		}
+	}
+
+	if Hint(nil) != "" {
+		t.Error("Hint(nil) should be empty")
+	}
+}
+
+func TestRun_DisabledCheck(t *testing.T) {
+	report := Run(context.Background(), []Check{
+		{Host: "nas", Integration: "docker", Name: "ping", Run: passing},
+		{Host: "pi", Integration: "watchtower", Name: "collection", Disabled: true},
+	}, time.Second)
+
+	if report.Passed != 1 || report.Failed != 0 || report.Disabled != 1 || !report.OK() {
+		t.Errorf("report = %d passed, %d failed, %d disabled, want 1, 0, 1", report.Passed, report.Failed, report.Disabled)
+	}
+	if res := report.Results[1]; !res.Disabled || res.Passed || res.Error != "" {
+		t.Errorf("disabled result = %+v", res)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteTable(&buf); err != nil {
+		t.Fatalf("WriteTable() = %v", err)
+	}
+	for _, want := range []string{"DISABLED", "1 passed, 0 failed, 1 disabled"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	report := &Report{
+		Results: []Result{
+			{Host: "nas", Integration: "docker", Check: "ping", Passed: true, DurationMS: 3},
+			{Host: "pi", Integration: "ssh", Check: "login", Error: "Permission denied (publickey)", Hint: "install the key", DurationMS: 120},
+		},
+		Passed: 1,
+		Failed: 1,
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteTable(&buf); err != nil {
+		t.Fatalf("WriteTable() = %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{"HOST", "PASS", "FAIL", "Permission denied (publickey)", "hint: install the key", "1 passed, 1 failed"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestBuildChecks(t *testing.T) {
+	cfg := &config.Config{
+		Hosts: []config.HostConfig{
+			{
+				Name:            "nas",
+				Address:         "localhost",
+				SystemdServices: []string{"nginx.service", "backup.timer"},
+			},
+			{
+				Name:            "pi",
+				Address:         "192.168.1.20",
+				SystemdServices: []string{"pihole-FTL.service"},
+				Traefik:         config.TraefikConfig{Enabled: true},
+			},
+		},
+	}
+
+	var names []string
+	for _, c := range BuildChecks(cfg) {
+		if c.Run == nil {
+			t.Errorf("check %s/%s/%s has no Run func", c.Host, c.Integration, c.Name)
+		}
+		names = append(names, c.Host+"/"+c.Integration+"/"+c.Name)
+	}
+
+	want := []string{
+		"nas/docker/ping",
+		"nas/docker/list containers",
+		"nas/systemd/journalctl",
+		"nas/systemd/unit nginx.service",
+		"nas/systemd/unit backup.timer",
+		"pi/ssh/login",
+		"pi/systemd/journalctl",
+		"pi/systemd/unit pihole-FTL.service",
+		"pi/traefik/api",
+	}
+	if strings.Join(names, "\n") != strings.Join(want, "\n") {
+		t.Errorf("BuildChecks() =\n%s\nwant\n%s", strings.Join(names, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Disabled integrations are reported, not probed
+	off := false
+	cfg.Hosts[0].Docker = &config.IntegrationToggle{Enabled: &off}
+	cfg.Hosts[1].Enabled = &off
+	names = nil
+	for _, c := range BuildChecks(cfg) {
+		if c.Disabled != (c.Run == nil) {
+			t.Errorf("check %s/%s/%s: Disabled = %v with Run set = %v", c.Host, c.Integration, c.Name, c.Disabled, c.Run != nil)
+		}
+		names = append(names, c.Host+"/"+c.Integration+"/"+c.Name)
+	}
+	want = []string{
+		"nas/docker/collection",
+		"nas/systemd/journalctl",
+		"nas/systemd/unit nginx.service",
+		"nas/systemd/unit backup.timer",
+		"pi/host/collection",
+	}
+	if strings.Join(names, "\n") != strings.Join(want, "\n") {
+		t.Errorf("BuildChecks() with disabled integrations =\n%s\nwant\n%s", strings.Join(names, "\n"), strings.Join(want, "\n"))
+	}
+
+	if BuildChecks(nil) != nil {
+		t.Error("BuildChecks(nil) should return nil")
+	}
+}
+
+func TestComposeRoots(t *testing.T) {
+	apps := t.TempDir()
+	if err := os.Mkdir(filepath.Join(apps, "media"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(apps, "media", "compose.yaml"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Hosts: []config.HostConfig{
+		{Name: "nas", Address: "localhost", DockerComposeRoots: []string{"/opt/stacks"}, DockerComposeRootsGlob: []string{filepath.Join(apps, "*")}},
+		{Name: "pi", Address: "192.168.1.20", DockerComposeRoots: []string{"/opt/stacks"}},
+	}}
+
+	report := &Report{ComposeRoots: ComposeRoots(cfg)}
+	if len(report.ComposeRoots) != 1 {
+		t.Fatalf("ComposeRoots() = %v, want only the host with a glob", report.ComposeRoots)
+	}
+
+	var buf bytes.Buffer
+	report.WriteTable(&buf)
+	if want := "Compose roots of nas: /opt/stacks, " + filepath.Join(apps, "media"); !strings.Contains(buf.String(), want) {
+		t.Errorf("output missing %q:\n%s", want, buf.String())
+	}
+
+	if ComposeRoots(nil) != nil {
+		t.Error("ComposeRoots(nil) should return nil")
+	}
+}
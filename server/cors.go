@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/realip"
+)
+
+// corsMaxAge is how long (in seconds) browsers may cache a preflight response.
+const corsMaxAge = "600"
+
+// corsMiddleware applies the dashboard's CORS policy. Same-origin requests and
+// requests without an Origin header pass through untouched. Allowed origins are
+// echoed back with credentials enabled; other origins get no CORS headers, so
+// browsers refuse to expose the response. Preflight (OPTIONS) requests are
+// answered here, before authentication, since browsers send them without cookies.
+func corsMiddleware(allowedOrigins []string, next http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		if normalized, ok := config.NormalizeOrigin(origin); ok {
+			allowed[normalized] = true
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || isSameOrigin(origin, r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Responses differ by Origin, so caches must key on it
+		w.Header().Add("Vary", "Origin")
+
+		normalized, ok := config.NormalizeOrigin(origin)
+		isAllowed := ok && allowed[normalized]
+		isPreflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if isPreflight {
+			if !isAllowed {
+				http.Error(w, "Origin not allowed", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if isAllowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isSameOrigin reports whether the Origin header names the host the request was sent to.
+// The scheme is ignored because TLS is usually terminated by a reverse proxy.
+func isSameOrigin(origin string, r *http.Request) bool {
+	_, host, ok := strings.Cut(origin, "://")
+	return ok && strings.EqualFold(host, realip.Host(r))
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newCORSTestHandler() (http.Handler, *bool) {
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	})
+	return corsMiddleware([]string{"https://homepage.example.com"}, next), &called
+}
+
+func TestCORS_AllowedOrigin(t *testing.T) {
+	handler, called := newCORSTestHandler()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/services", nil)
+	req.Host = "dashboard.example.com"
+	req.Header.Set("Origin", "https://homepage.example.com")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if !*called {
+		t.Fatal("expected request to reach the handler")
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://homepage.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want the request origin", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Access-Control-Allow-Credentials = %q, want true", got)
+	}
+	if got := w.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("Vary = %q, want Origin", got)
+	}
+}
+
+func TestCORS_DisallowedOrigin(t *testing.T) {
+	handler, called := newCORSTestHandler()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/services", nil)
+	req.Host = "dashboard.example.com"
+	req.Header.Set("Origin", "https://evil.example.com")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if !*called {
+		t.Fatal("expected request to reach the handler (the browser enforces CORS)")
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want none", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials = %q, want none", got)
+	}
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	t.Run("allowed origin", func(t *testing.T) {
+		handler, called := newCORSTestHandler()
+
+		req := httptest.NewRequest(http.MethodOptions, "/api/services/restart", nil)
+		req.Host = "dashboard.example.com"
+		req.Header.Set("Origin", "https://homepage.example.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("Access-Control-Request-Headers", "content-type")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if *called {
+			t.Error("preflight should be answered by the middleware")
+		}
+		if w.Code != http.StatusNoContent {
+			t.Errorf("Status = %d, want %d", w.Code, http.StatusNoContent)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://homepage.example.com" {
+			t.Errorf("Access-Control-Allow-Origin = %q, want the request origin", got)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST, OPTIONS" {
+			t.Errorf("Access-Control-Allow-Methods = %q", got)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type" {
+			t.Errorf("Access-Control-Allow-Headers = %q", got)
+		}
+	})
+
+	t.Run("disallowed origin", func(t *testing.T) {
+		handler, called := newCORSTestHandler()
+
+		req := httptest.NewRequest(http.MethodOptions, "/api/services/restart", nil)
+		req.Host = "dashboard.example.com"
+		req.Header.Set("Origin", "https://evil.example.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if *called {
+			t.Error("disallowed preflight should not reach the handler")
+		}
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Status = %d, want %d", w.Code, http.StatusForbidden)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("Access-Control-Allow-Origin = %q, want none", got)
+		}
+	})
+}
+
+func TestCORS_SameOrigin(t *testing.T) {
+	tests := []struct {
+		name   string
+		origin string
+		host   string
+	}{
+		{"no origin header", "", "dashboard.example.com"},
+		{"same host behind TLS proxy", "https://dashboard.example.com", "dashboard.example.com"},
+		{"same host and port", "http://192.168.1.8:9001", "192.168.1.8:9001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, called := newCORSTestHandler()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/services/restart", nil)
+			req.Host = tt.host
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if !*called {
+				t.Fatal("expected request to reach the handler")
+			}
+			for _, h := range []string{"Access-Control-Allow-Origin", "Access-Control-Allow-Credentials", "Vary"} {
+				if got := w.Header().Get(h); got != "" {
+					t.Errorf("%s = %q, want none for same-origin requests", h, got)
+				}
+			}
+		})
+	}
+}
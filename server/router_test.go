@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/config"
+	"home_server_dashboard/services"
+)
+
+// fakeAuth lets requests through whose bearer token names one of its users.
+type fakeAuth struct {
+	users map[string]*auth.User // token -> user
+}
+
+func (a *fakeAuth) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := a.users[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), auth.UserContextKey, user)))
+	})
+}
+
+func (a *fakeAuth) LoginHandler(w http.ResponseWriter, r *http.Request)    {}
+func (a *fakeAuth) CallbackHandler(w http.ResponseWriter, r *http.Request) {}
+func (a *fakeAuth) LogoutHandler(w http.ResponseWriter, r *http.Request)   {}
+func (a *fakeAuth) StatusHandler(w http.ResponseWriter, r *http.Request)   {}
+
+// fakeSources is a registry holding only its own registrations.
+type fakeSources []services.Registration
+
+func (s fakeSources) Lookup(source string) (services.Registration, bool) {
+	for _, reg := range s {
+		if reg.Source == source {
+			return reg, true
+		}
+	}
+	return services.Registration{}, false
+}
+
+func (s fakeSources) Registered() []services.Registration { return s }
+
+// fakeProvider serves a fixed list of services with one line of logs each.
+type fakeProvider struct {
+	host  string
+	names []string
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+func (p *fakeProvider) GetServices(ctx context.Context) ([]services.ServiceInfo, error) {
+	var svcs []services.ServiceInfo
+	for _, name := range p.names {
+		svcs = append(svcs, services.ServiceInfo{Name: name, ContainerName: name, Source: "fake", Host: p.host, State: "running"})
+	}
+	return svcs, nil
+}
+
+func (p *fakeProvider) GetService(name string) (services.Service, error) {
+	return nil, fmt.Errorf("service %s has no actions", name)
+}
+
+func (p *fakeProvider) GetLogs(ctx context.Context, serviceName string, tailLines int, follow bool) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(fmt.Sprintf("%s started (last %d lines)\n", serviceName, tailLines))), nil
+}
+
+// newTestRouter builds the full routing table on fakes: a host "nas" with
+// the services web and db, an admin and a user who may only see web.
+func newTestRouter(t *testing.T) http.Handler {
+	t.Helper()
+	cfg := &config.Config{LogTail: 50, Hosts: []config.HostConfig{{Name: "nas", Address: "192.168.1.50"}}}
+	sources := fakeSources{{
+		Source: "fake",
+		Factory: func(cfg *config.Config, host *config.HostConfig) (services.Provider, error) {
+			return &fakeProvider{host: host.Name, names: []string{"web", "db"}}, nil
+		},
+		Capabilities: services.Capabilities{Logs: true},
+	}}
+	authenticator := &fakeAuth{users: map[string]*auth.User{
+		"admin-token": {ID: "admin", IsAdmin: true, HasGlobalAccess: true},
+		"user-token":  {ID: "user", AllowedServices: map[string][]string{"nas": {"web"}}},
+	}}
+
+	s := New(&Config{
+		StaticDir:    t.TempDir(),
+		AuthProvider: authenticator,
+		Settings:     func() *config.Config { return cfg },
+		Sources:      sources,
+	})
+	// Leave the package defaults to the other tests
+	t.Cleanup(func() { New(nil) })
+	return s.Handler()
+}
+
+// get requests path from router with token, if any.
+func get(router http.Handler, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRouter_AuthMiddleware(t *testing.T) {
+	router := newTestRouter(t)
+
+	for _, path := range []string{"/api/services", "/api/logs/provider?source=fake&host=nas&service=web", "/"} {
+		if w := get(router, path, ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s without a token: status %d, want %d", path, w.Code, http.StatusUnauthorized)
+		}
+		if w := get(router, path, "wrong"); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s with a wrong token: status %d, want %d", path, w.Code, http.StatusUnauthorized)
+		}
+	}
+
+	// Public routes skip the middleware
+	if w := get(router, "/api/version", ""); w.Code != http.StatusOK {
+		t.Errorf("/api/version: status %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := get(router, "/readyz", ""); w.Code != http.StatusOK {
+		t.Errorf("/readyz: status %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestRouter_Services(t *testing.T) {
+	router := newTestRouter(t)
+
+	names := func(token string) []string {
+		t.Helper()
+		w := get(router, "/api/services", token)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body.String())
+		}
+		var svcs []services.ServiceInfo
+		if err := json.Unmarshal(w.Body.Bytes(), &svcs); err != nil {
+			t.Fatalf("Failed to decode %q: %v", w.Body.String(), err)
+		}
+		var got []string
+		for _, svc := range svcs {
+			got = append(got, svc.Host+"/"+svc.Name)
+		}
+		return got
+	}
+
+	if got := strings.Join(names("admin-token"), ","); got != "nas/db,nas/web" {
+		t.Errorf("admin sees %s, want nas/db,nas/web", got)
+	}
+	if got := strings.Join(names("user-token"), ","); got != "nas/web" {
+		t.Errorf("user sees %s, want nas/web", got)
+	}
+}
+
+func TestRouter_LogStream(t *testing.T) {
+	router := newTestRouter(t)
+
+	w := get(router, "/api/logs/provider?source=fake&host=nas&service=web", "user-token")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status %d, content type %q: %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	// The tail comes from the injected config
+	if body := w.Body.String(); body != "data: web started (last 50 lines)\n\n" {
+		t.Errorf("body = %q", body)
+	}
+
+	if w := get(router, "/api/logs/provider?source=fake&host=nas&service=db", "user-token"); w.Code != http.StatusForbidden {
+		t.Errorf("logs of a service the user can't see: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
@@ -4,22 +4,54 @@ package server
 import (
 	"io/fs"
 	"log"
+	"net"
 	"net/http"
+	"time"
 
+	"home_server_dashboard/actionhistory"
 	"home_server_dashboard/auth"
+	"home_server_dashboard/config"
+	"home_server_dashboard/events"
 	"home_server_dashboard/handlers"
+	"home_server_dashboard/notices"
+	"home_server_dashboard/streams"
+	"home_server_dashboard/usagestats"
 	"home_server_dashboard/websocket"
 )
 
-// Config holds server configuration options.
+// Authenticator guards the protected routes and serves the login flow.
+// It is implemented by *auth.Provider.
+type Authenticator interface {
+	// Middleware lets authenticated requests through to next, with the
+	// user in their context, and turns the others away.
+	Middleware(next http.Handler) http.Handler
+	LoginHandler(w http.ResponseWriter, r *http.Request)
+	CallbackHandler(w http.ResponseWriter, r *http.Request)
+	LogoutHandler(w http.ResponseWriter, r *http.Request)
+	StatusHandler(w http.ResponseWriter, r *http.Request)
+}
+
+// Config holds server configuration options and the dependencies the
+// routes are built on.
 type Config struct {
-	Port         string
-	StaticDir    string           // Deprecated: use StaticFS instead
-	ConfigPath   string
-	StaticFS     fs.FS            // Embedded static filesystem
-	DocsFS       fs.FS            // Embedded docs filesystem
-	AuthProvider *auth.Provider   // OIDC auth provider (nil if auth disabled)
-	WebSocketHub *websocket.Hub   // WebSocket hub for real-time updates
+	Port           string
+	StaticDir      string                  // Deprecated: use StaticFS instead
+	ConfigPath     string                  // Configuration file the config editor replaces (empty turns it off)
+	StaticFS       fs.FS                   // Embedded static filesystem
+	DocsFS         fs.FS                   // Embedded docs filesystem
+	AuthProvider   Authenticator           // OIDC auth provider (nil if auth disabled)
+	WebSocketHub   *websocket.Hub          // WebSocket hub for real-time updates
+	Events         *events.Bus             // Bus whose events release the services long-poll (nil if none)
+	StateTracker   handlers.StateTracker   // Source of service state-change times (nil if none)
+	AllowedOrigins []string                // Origins allowed to make credentialed cross-origin requests
+	ActionHistory  *actionhistory.Store    // Store for service action output (nil keeps an in-memory default)
+	Streams        *streams.Registry       // Registry of open SSE streams (nil keeps a default with the default limits)
+	UsageStats     *usagestats.Store       // Counts of log streams and actions per service (nil keeps an in-memory default)
+	Notices        *notices.Store          // Notices shown to administrators (nil keeps an in-memory default)
+	Settings       func() *config.Config   // Source of the current config (nil uses config.Get)
+	Sources        handlers.SourceRegistry // Registry of service sources (nil uses the services package's registry)
+	WarmupTimeout  time.Duration           // How long the services collected at startup are waited for (0 skips the warm-up)
+	WaitForWarmup  bool                    // /readyz answers 503 until the warm-up ends
 }
 
 // DefaultConfig returns the default server configuration.
@@ -43,82 +75,154 @@ func New(cfg *Config) *Server {
 		cfg = DefaultConfig()
 	}
 
-	s := &Server{
+	return &Server{
 		config: cfg,
-		mux:    http.NewServeMux(),
+		mux:    NewRouter(cfg),
 	}
-
-	s.setupRoutes()
-	return s
 }
 
-// setupRoutes configures all HTTP routes.
-func (s *Server) setupRoutes() {
+// NewRouter builds the routing table for cfg, handing its dependencies to
+// the handlers. It doesn't apply the CORS policy; see Server.Handler.
+func NewRouter(cfg *Config) *http.ServeMux {
+	mux := http.NewServeMux()
+
 	// Serve static files from embedded filesystem (always public for login page styling)
-	if s.config.StaticFS != nil {
-		fs := http.FileServer(http.FS(s.config.StaticFS))
-		s.mux.Handle("/static/", http.StripPrefix("/static/", fs))
+	if cfg.StaticFS != nil {
+		fs := http.FileServer(http.FS(cfg.StaticFS))
+		mux.Handle("/static/", http.StripPrefix("/static/", fs))
 	} else {
 		// Fallback to filesystem for development
-		fs := http.FileServer(http.Dir(s.config.StaticDir))
-		s.mux.Handle("/static/", http.StripPrefix("/static/", fs))
+		fs := http.FileServer(http.Dir(cfg.StaticDir))
+		mux.Handle("/static/", http.StripPrefix("/static/", fs))
 	}
 
-	// Set the embedded filesystems for handlers
-	handlers.SetEmbeddedFS(s.config.StaticFS, s.config.DocsFS)
+	// Hand the dependencies to the handlers
+	handlers.SetEmbeddedFS(cfg.StaticFS, cfg.DocsFS)
+	handlers.SetStateTracker(cfg.StateTracker)
+	handlers.SetActionHistory(cfg.ActionHistory)
+	handlers.SetStreamRegistry(cfg.Streams)
+	handlers.SetUsageStats(cfg.UsageStats)
+	handlers.SetNotices(cfg.Notices)
+	handlers.SetConfigSource(cfg.Settings)
+	handlers.SetSourceRegistry(cfg.Sources)
+	handlers.SetConfigPath(cfg.ConfigPath)
+	handlers.SetEventBus(cfg.Events)
+	stores, _ := cfg.AuthProvider.(handlers.AuthStoreReporter)
+	handlers.SetAuthStores(stores)
 
 	// Auth routes (always public)
-	if s.config.AuthProvider != nil {
-		s.mux.HandleFunc("/login", s.config.AuthProvider.LoginHandler)
-		s.mux.HandleFunc("/oidc/callback", s.config.AuthProvider.CallbackHandler)
-		s.mux.HandleFunc("/logout", s.config.AuthProvider.LogoutHandler)
-		s.mux.HandleFunc("/auth/status", s.config.AuthProvider.StatusHandler)
+	if cfg.AuthProvider != nil {
+		mux.HandleFunc("/login", cfg.AuthProvider.LoginHandler)
+		mux.HandleFunc("/oidc/callback", cfg.AuthProvider.CallbackHandler)
+		mux.HandleFunc("/logout", cfg.AuthProvider.LogoutHandler)
+		mux.HandleFunc("/auth/status", cfg.AuthProvider.StatusHandler)
 	} else {
 		// When auth is disabled, provide a status endpoint that says so
-		s.mux.HandleFunc("/auth/status", auth.NoAuthStatusHandler)
+		mux.HandleFunc("/auth/status", auth.NoAuthStatusHandler)
 	}
 
+	// Build information (always public)
+	mux.HandleFunc("/api/version", handlers.VersionHandler)
+
+	// Readiness of the monitored event sources (always public, for health checks)
+	mux.HandleFunc("/readyz", handlers.ReadyzHandler)
+
 	// Create middleware wrapper for protected routes
 	protect := func(h http.HandlerFunc) http.HandlerFunc {
-		if s.config.AuthProvider != nil {
+		if cfg.AuthProvider != nil {
 			return func(w http.ResponseWriter, r *http.Request) {
-				s.config.AuthProvider.Middleware(http.HandlerFunc(h)).ServeHTTP(w, r)
+				cfg.AuthProvider.Middleware(http.HandlerFunc(h)).ServeHTTP(w, r)
 			}
 		}
 		return h
 	}
 
 	// Serve index.html at root (protected)
-	s.mux.HandleFunc("/", protect(handlers.IndexHandler))
+	mux.HandleFunc("/", protect(handlers.IndexHandler))
 
 	// API endpoints (protected)
-	s.mux.HandleFunc("/api/services", protect(handlers.ServicesHandler))
-	s.mux.HandleFunc("/api/logs", protect(handlers.DockerLogsHandler))
-	s.mux.HandleFunc("/api/logs/systemd", protect(handlers.SystemdLogsHandler))
-	s.mux.HandleFunc("/api/logs/traefik", protect(handlers.TraefikLogsHandler))
-	s.mux.HandleFunc("/api/logs/homeassistant", protect(handlers.HomeAssistantLogsHandler))
-	s.mux.HandleFunc("/api/logs/flush", protect(handlers.LogFlushHandler))
-	s.mux.HandleFunc("/api/bangAndPipeToRegex", protect(handlers.BangAndPipeHandler))
-	s.mux.HandleFunc("/api/docs/bangandpipe", protect(handlers.BangAndPipeDocsHandler))
+	mux.HandleFunc("/api/services", protect(handlers.ServicesHandler))
+	mux.HandleFunc("GET /api/services/poll", protect(handlers.ServicesPollHandler))
+	mux.HandleFunc("/api/links", protect(handlers.LinksHandler))
+	mux.HandleFunc("GET /api/discovery", protect(handlers.DiscoveryHandler))
+	mux.HandleFunc("/api/logs", protect(handlers.DockerLogsHandler))
+	mux.HandleFunc("/api/logs/systemd", protect(handlers.SystemdLogsHandler))
+	mux.HandleFunc("/api/logs/traefik", protect(handlers.TraefikLogsHandler))
+	mux.HandleFunc("/api/logs/homeassistant", protect(handlers.HomeAssistantLogsHandler))
+	mux.HandleFunc("/api/logs/provider", protect(handlers.ProviderLogsHandler))
+	mux.HandleFunc("/api/logs/project", protect(handlers.ProjectLogsHandler))
+	mux.HandleFunc("/api/logs/flush", protect(handlers.RequireConfirmation(handlers.LogFlushConfirmation, handlers.LogFlushHandler)))
+	mux.HandleFunc("/api/bangAndPipeToRegex", protect(handlers.BangAndPipeHandler))
+	mux.HandleFunc("GET /api/docs", protect(handlers.DocsIndexHandler))
+	mux.HandleFunc("GET /api/docs/bangandpipe", protect(handlers.BangAndPipeDocsHandler))
+	mux.HandleFunc("GET /api/docs/{slug}", protect(handlers.DocsHandler))
+	mux.HandleFunc("/api/selftest", protect(handlers.SelfTestHandler))
+	mux.HandleFunc("GET /api/connections", protect(handlers.ConnectionsHandler))
+	mux.HandleFunc("DELETE /api/connections/{id}", protect(handlers.CloseConnectionHandler))
+	mux.HandleFunc("GET /api/networks", protect(handlers.NetworksHandler))
+	mux.HandleFunc("GET /api/debug/runtime", protect(handlers.RuntimeHandler))
+	mux.HandleFunc("GET /api/debug/goroutines", protect(handlers.GoroutinesHandler))
+	mux.HandleFunc("GET /api/debug/auth", protect(handlers.AuthStoresHandler))
+	mux.HandleFunc("GET /api/debug/intents", protect(handlers.IntentsHandler))
+	mux.HandleFunc("GET /api/metrics", protect(handlers.MetricsHandler))
+	mux.HandleFunc("GET /api/auth/access-preview", protect(handlers.AccessPreviewHandler))
+	mux.HandleFunc("GET /api/config", protect(handlers.ConfigHandler))
+	mux.HandleFunc("POST /api/config/validate", protect(handlers.ConfigValidateHandler))
+	mux.HandleFunc("POST /api/config/apply", protect(handlers.ConfigApplyHandler))
 
 	// Service control actions (start/stop/restart) (protected)
-	s.mux.HandleFunc("/api/services/start", protect(handlers.ServiceActionHandler))
-	s.mux.HandleFunc("/api/services/stop", protect(handlers.ServiceActionHandler))
-	s.mux.HandleFunc("/api/services/restart", protect(handlers.ServiceActionHandler))
+	mux.HandleFunc("/api/services/start", protect(handlers.ServiceActionHandler))
+	mux.HandleFunc("/api/services/stop", protect(handlers.ServiceActionHandler))
+	mux.HandleFunc("/api/services/restart", protect(handlers.ServiceActionHandler))
+	mux.HandleFunc("/api/services/recreate", protect(handlers.RecreateHandler))
+	mux.HandleFunc("/api/services/cleanup", protect(handlers.RequireConfirmation(handlers.CleanupConfirmation, handlers.CleanupHandler)))
+	mux.HandleFunc("GET /api/services/{host}/{name}/actions", protect(handlers.ActionHistoryHandler))
+	mux.HandleFunc("GET /api/services/{host}/{name}/actions/{id}/output", protect(handlers.ActionOutputHandler))
+	mux.HandleFunc("GET /api/services/{host}/{name}/stats", protect(handlers.ServiceStatsHandler))
+	mux.HandleFunc("GET /api/stats/services", protect(handlers.UsageStatsHandler))
+	mux.HandleFunc("GET /api/notices", protect(handlers.NoticesHandler))
+	mux.HandleFunc("POST /api/notices/{id}/dismiss", protect(handlers.DismissNoticeHandler))
+	mux.HandleFunc("POST /api/hosts/{name}/maintenance", protect(handlers.StartMaintenanceHandler))
+	mux.HandleFunc("DELETE /api/hosts/{name}/maintenance", protect(handlers.EndMaintenanceHandler))
+	mux.HandleFunc("GET /api/services/{host}/{name}/failure", protect(handlers.ServiceFailureHandler))
+	mux.HandleFunc("GET /api/services/{host}/{name}/config", protect(handlers.ServiceConfigHandler))
+	mux.HandleFunc("POST /api/services/{host}/{name}/cancel", protect(handlers.CancelActionHandler))
 
 	// WebSocket endpoint for real-time updates (protected)
-	if s.config.WebSocketHub != nil {
-		s.mux.HandleFunc("/ws", protect(s.config.WebSocketHub.Handler()))
+	if cfg.WebSocketHub != nil {
+		// Only forward events about services the connected user can see
+		cfg.WebSocketHub.SetViewerFunc(func(r *http.Request) websocket.Viewer {
+			if user := auth.GetUserFromContext(r.Context()); user != nil {
+				return user
+			}
+			return nil
+		})
+		mux.HandleFunc("/ws", protect(cfg.WebSocketHub.Handler()))
 	}
+
+	return mux
 }
 
-// Handler returns the HTTP handler for the server.
+// Handler returns the HTTP handler for the server, with the CORS policy applied.
 func (s *Server) Handler() http.Handler {
-	return s.mux
+	return corsMiddleware(s.config.AllowedOrigins, s.mux)
 }
 
 // ListenAndServe starts the HTTP server.
 func (s *Server) ListenAndServe() error {
 	log.Printf("Starting server on %s", s.config.Port)
-	return http.ListenAndServe(s.config.Port, s.mux)
+	listener, err := net.Listen("tcp", s.config.Port)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve serves HTTP on listener, warming up the services cache in the
+// background if configured to, so requests are served while it runs.
+func (s *Server) Serve(listener net.Listener) error {
+	if s.config.WarmupTimeout > 0 {
+		handlers.StartWarmup(s.config.WarmupTimeout, s.config.WaitForWarmup)
+	}
+	return http.Serve(listener, s.Handler())
 }
@@ -1,9 +1,20 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"testing"
+	"testing/fstest"
+	"time"
+
+	"home_server_dashboard/auth"
+	"home_server_dashboard/config"
+	"home_server_dashboard/handlers"
+	"home_server_dashboard/services"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -91,6 +102,19 @@ func TestServer_RoutesRegistered(t *testing.T) {
 	}
 }
 
+func TestServer_VersionRoutePublic(t *testing.T) {
+	s := New(nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/version", nil)
+	w := httptest.NewRecorder()
+
+	s.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for /api/version, got %d", w.Code)
+	}
+}
+
 func TestServer_StaticFilesRoute(t *testing.T) {
 	s := New(nil)
 
@@ -106,3 +130,112 @@ func TestServer_StaticFilesRoute(t *testing.T) {
 		t.Logf("Status = %d (expected 404 for non-existent file)", w.Code)
 	}
 }
+
+// slowProvider is a fakeProvider whose services take until release is
+// closed to be collected.
+type slowProvider struct {
+	fakeProvider
+	release chan struct{}
+}
+
+func (p *slowProvider) GetServices(ctx context.Context) ([]services.ServiceInfo, error) {
+	select {
+	case <-p.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return p.fakeProvider.GetServices(ctx)
+}
+
+func TestServer_ServesWhileWarmingUp(t *testing.T) {
+	release := make(chan struct{})
+	cfg := &config.Config{Hosts: []config.HostConfig{{Name: "nas"}, {Name: "pi"}}}
+	sources := fakeSources{{
+		Source: "fake",
+		Factory: func(cfg *config.Config, host *config.HostConfig) (services.Provider, error) {
+			if host.Name == "pi" {
+				return &slowProvider{fakeProvider{host: "pi", names: []string{"pihole"}}, release}, nil
+			}
+			return &fakeProvider{host: host.Name, names: []string{"web"}}, nil
+		},
+	}}
+	s := New(&Config{
+		StaticFS: fstest.MapFS{"index.html": {Data: []byte("<html><head></head><body></body></html>")}},
+		AuthProvider: &fakeAuth{users: map[string]*auth.User{
+			"admin-token": {ID: "admin", IsAdmin: true, HasGlobalAccess: true},
+		}},
+		Settings:      func() *config.Config { return cfg },
+		Sources:       sources,
+		WarmupTimeout: time.Minute,
+		WaitForWarmup: true,
+	})
+	t.Cleanup(func() { New(nil) })
+	// Start cold, without the services the other tests collected
+	handlers.InvalidateServices()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(listener)
+	t.Cleanup(func() { listener.Close() })
+	base := "http://" + listener.Addr().String()
+
+	type readyz struct {
+		Ready  bool
+		Warmup struct{ Phase string }
+	}
+	getReadyz := func() (int, readyz) {
+		t.Helper()
+		resp, err := http.Get(base + "/readyz")
+		if err != nil {
+			t.Fatalf("the server isn't serving: %v", err)
+		}
+		defer resp.Body.Close()
+		var body readyz
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+	snapshot := regexp.MustCompile(`"warming":(true|false)`)
+	warming := func() string {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, base+"/", nil)
+		req.Header.Set("Authorization", "Bearer admin-token")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var page [4096]byte
+		n, _ := resp.Body.Read(page[:])
+		match := snapshot.FindSubmatch(page[:n])
+		if match == nil {
+			t.Fatalf("no warming flag in the page: %s", page[:n])
+		}
+		return string(match[1])
+	}
+
+	// pi holds the warm-up up, but requests are served meanwhile
+	if code, body := getReadyz(); code != http.StatusServiceUnavailable || body.Warmup.Phase != "warming" {
+		t.Errorf("/readyz while warming = %d %+v, want 503 warming", code, body)
+	}
+	if flag := warming(); flag != "true" {
+		t.Errorf("warming = %s while pi is collected, want true", flag)
+	}
+
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		code, body := getReadyz()
+		if code == http.StatusOK && body.Ready && body.Warmup.Phase == "done" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("/readyz = %d %+v, want ready once warmed up", code, body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if flag := warming(); flag != "false" {
+		t.Errorf("warming = %s after the warm-up, want false", flag)
+	}
+}
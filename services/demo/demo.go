@@ -0,0 +1,361 @@
+// Package demo provides synthetic services for trying the dashboard without
+// Docker, systemd or Home Assistant, e.g. for frontend work or screenshots.
+//
+// Every host with "demo" set gets services picked from a fixed catalog. Their
+// states, ports and URLs come from a random source seeded with the host name,
+// so a host always starts out the same. Every so often a service changes
+// state, which gives the monitor, events and notifications something to
+// report. Actions take a moment and now and then fail, and logs stream
+// generated lines.
+package demo
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"home_server_dashboard/services"
+)
+
+// Timing of the simulation.
+const (
+	// flipInterval is how often a service changes state on its own.
+	flipInterval = 90 * time.Second
+	// minActionDelay and maxActionDelay bound how long an action takes.
+	minActionDelay = 300 * time.Millisecond
+	maxActionDelay = 1500 * time.Millisecond
+	// actionFailureRate is the share of actions that fail.
+	actionFailureRate = 0.1
+)
+
+// template is a catalog entry that demo services are created from.
+type template struct {
+	name        string
+	project     string
+	image       string
+	port        uint16 // 0 for services without a published port
+	description string
+	traefik     bool // reachable through a Traefik URL
+}
+
+// catalog lists the services a demo host can run.
+var catalog = []template{
+	{"jellyfin", "media", "jellyfin/jellyfin:10.9", 8096, "Media server", true},
+	{"sonarr", "media", "lscr.io/linuxserver/sonarr:4", 8989, "TV series manager", true},
+	{"radarr", "media", "lscr.io/linuxserver/radarr:5", 7878, "Movie manager", true},
+	{"qbittorrent", "downloads", "lscr.io/linuxserver/qbittorrent:4.6", 8080, "Torrent client", false},
+	{"gluetun", "downloads", "qmcgaw/gluetun:v3", 0, "VPN gateway", false},
+	{"grafana", "monitoring", "grafana/grafana:11.1", 3000, "Dashboards", true},
+	{"prometheus", "monitoring", "prom/prometheus:v2.53", 9090, "Metrics database", false},
+	{"node-exporter", "monitoring", "prom/node-exporter:v1.8", 9100, "Host metrics exporter", false},
+	{"postgres", "apps", "postgres:16", 0, "Database", false},
+	{"redis", "apps", "redis:7.2", 0, "Cache", false},
+	{"nextcloud", "apps", "nextcloud:29", 8081, "File sync and share", true},
+	{"pihole", "network", "pihole/pihole:2024.07.0", 8053, "DNS ad blocker", true},
+}
+
+// exitCodes are the exit codes stopped services report.
+var exitCodes = []int{0, 0, 1, 137, 143}
+
+// service is the simulated state of one demo service.
+type service struct {
+	template
+	running  bool
+	exitCode int
+	since    time.Time
+}
+
+// world is the simulated state of one demo host. It is shared by every
+// provider created for the host, since providers are created per request.
+type world struct {
+	mu       sync.Mutex
+	host     string
+	rng      *rand.Rand
+	now      func() time.Time
+	services []*service // sorted by name
+	nextFlip time.Time
+
+	minDelay, maxDelay time.Duration
+	failureRate        float64
+}
+
+// seedFor derives the seed of a host from its name.
+func seedFor(host string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(host))
+	return int64(h.Sum64())
+}
+
+// newWorld creates the services of host from seed, starting at now().
+func newWorld(host string, seed int64, now func() time.Time) *world {
+	w := &world{
+		host:        host,
+		rng:         rand.New(rand.NewSource(seed)),
+		now:         now,
+		minDelay:    minActionDelay,
+		maxDelay:    maxActionDelay,
+		failureRate: actionFailureRate,
+	}
+
+	start := now()
+	for _, i := range w.rng.Perm(len(catalog))[:6+w.rng.Intn(len(catalog)-5)] {
+		svc := &service{
+			template: catalog[i],
+			running:  w.rng.Float64() < 0.85,
+			since:    start.Add(-time.Duration(1+w.rng.Intn(72*60)) * time.Minute),
+		}
+		if !svc.running {
+			svc.exitCode = exitCodes[w.rng.Intn(len(exitCodes))]
+		}
+		w.services = append(w.services, svc)
+	}
+	sort.Slice(w.services, func(i, j int) bool { return w.services[i].name < w.services[j].name })
+	w.nextFlip = start.Add(flipInterval)
+	return w
+}
+
+var (
+	worldsMu sync.Mutex
+	worlds   = make(map[string]*world) // key: host name
+)
+
+// worldFor returns the world of host, creating it on first use.
+func worldFor(host string) *world {
+	worldsMu.Lock()
+	defer worldsMu.Unlock()
+
+	w, ok := worlds[host]
+	if !ok {
+		w = newWorld(host, seedFor(host), time.Now)
+		worlds[host] = w
+	}
+	return w
+}
+
+// advance applies the state changes that were due by now. Called with w.mu held.
+func (w *world) advance() {
+	now := w.now()
+	if now.Sub(w.nextFlip) > 10*flipInterval {
+		// Nobody looked for a while; don't replay every missed change
+		w.nextFlip = now
+	}
+	for !now.Before(w.nextFlip) {
+		svc := w.services[w.rng.Intn(len(w.services))]
+		w.setRunning(svc, !svc.running, w.nextFlip)
+		w.nextFlip = w.nextFlip.Add(flipInterval)
+	}
+}
+
+// setRunning changes the state of svc at the given time. Called with w.mu held.
+func (w *world) setRunning(svc *service, running bool, at time.Time) {
+	if svc.running == running {
+		return
+	}
+	svc.running = running
+	svc.since = at
+	if !running {
+		svc.exitCode = exitCodes[w.rng.Intn(len(exitCodes))]
+	}
+}
+
+// find returns the service called name. Called with w.mu held.
+func (w *world) find(name string) *service {
+	for _, svc := range w.services {
+		if svc.name == name {
+			return svc
+		}
+	}
+	return nil
+}
+
+// snapshot returns the current state of every service.
+func (w *world) snapshot() []services.ServiceInfo {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.advance()
+	result := make([]services.ServiceInfo, 0, len(w.services))
+	for _, svc := range w.services {
+		result = append(result, w.info(svc))
+	}
+	return result
+}
+
+// info describes svc. Called with w.mu held.
+func (w *world) info(svc *service) services.ServiceInfo {
+	since := svc.since
+	info := services.ServiceInfo{
+		Name:            svc.name,
+		Project:         svc.project,
+		ContainerName:   svc.project + "-" + svc.name + "-1",
+		Image:           svc.image,
+		Source:          "demo",
+		Host:            w.host,
+		Description:     svc.description,
+		TraefikURLs:     []string{},
+		LastStateChange: &since,
+		StateSince:      &since,
+	}
+	if svc.running {
+		info.State = "running"
+		info.Status = "Up"
+		info.StartedAt = &since
+	} else {
+		code := svc.exitCode
+		info.State = "stopped"
+		info.Status = fmt.Sprintf("Exited (%d)", code)
+		info.ExitCode = &code
+		info.FinishedAt = &since
+	}
+	if svc.port != 0 {
+		info.Ports = []services.PortInfo{{HostPort: svc.port, ContainerPort: svc.port, Protocol: "tcp"}}
+	}
+	if svc.traefik {
+		info.TraefikURLs = []string{fmt.Sprintf("https://%s.%s.demo.example", svc.name, w.host)}
+		info.URLOverride = true
+	}
+	return info
+}
+
+// act runs a simulated action on the service called name. It takes a random
+// time between w.minDelay and w.maxDelay and fails at w.failureRate.
+func (w *world) act(ctx context.Context, name, action string) error {
+	w.mu.Lock()
+	svc := w.find(name)
+	delay := w.minDelay
+	if spread := w.maxDelay - w.minDelay; spread > 0 {
+		delay += time.Duration(w.rng.Int63n(int64(spread)))
+	}
+	failed := w.rng.Float64() < w.failureRate
+	w.mu.Unlock()
+	if svc == nil {
+		return fmt.Errorf("demo service %s not found on %s", name, w.host)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+	if failed {
+		return fmt.Errorf("simulated failure: %s did not %s", name, action)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.advance()
+	now := w.now()
+	switch action {
+	case "start":
+		w.setRunning(svc, true, now)
+	case "stop":
+		w.setRunning(svc, false, now)
+		svc.exitCode = 0
+	case "restart":
+		svc.running = true
+		svc.since = now
+	}
+	return nil
+}
+
+// Provider serves the demo services of one host.
+type Provider struct {
+	world *world
+}
+
+// NewProvider creates a provider for the demo services of host.
+func NewProvider(host string) *Provider {
+	return &Provider{world: worldFor(host)}
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string {
+	return "demo"
+}
+
+// GetServices returns the demo services of the host.
+func (p *Provider) GetServices(ctx context.Context) ([]services.ServiceInfo, error) {
+	return p.world.snapshot(), nil
+}
+
+// GetService returns the demo service called name.
+func (p *Provider) GetService(name string) (services.Service, error) {
+	p.world.mu.Lock()
+	svc := p.world.find(name)
+	p.world.mu.Unlock()
+	if svc == nil {
+		return nil, fmt.Errorf("demo service %s not found on %s", name, p.world.host)
+	}
+	return &Service{world: p.world, name: name}, nil
+}
+
+// GetLogs streams generated log lines of the demo service called name.
+func (p *Provider) GetLogs(ctx context.Context, serviceName string, tailLines int, follow bool) (io.ReadCloser, error) {
+	svc, err := p.GetService(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	return svc.GetLogs(ctx, tailLines, follow)
+}
+
+// Service is a single demo service.
+type Service struct {
+	world *world
+	name  string
+}
+
+// GetInfo returns the current state of the service.
+func (s *Service) GetInfo(ctx context.Context) (services.ServiceInfo, error) {
+	s.world.mu.Lock()
+	defer s.world.mu.Unlock()
+
+	s.world.advance()
+	svc := s.world.find(s.name)
+	if svc == nil {
+		return services.ServiceInfo{}, fmt.Errorf("demo service %s not found on %s", s.name, s.world.host)
+	}
+	return s.world.info(svc), nil
+}
+
+// GetLogs streams generated log lines: tailLines lines of history and, with
+// follow, a new line every second or so until ctx is done or the stream is closed.
+func (s *Service) GetLogs(ctx context.Context, tailLines int, follow bool) (io.ReadCloser, error) {
+	return streamLogs(ctx, s.name, seedFor(s.world.host+"/"+s.name), tailLines, follow, logInterval), nil
+}
+
+// Start starts the service.
+func (s *Service) Start(ctx context.Context) error {
+	return s.world.act(ctx, s.name, "start")
+}
+
+// Stop stops the service.
+func (s *Service) Stop(ctx context.Context) error {
+	return s.world.act(ctx, s.name, "stop")
+}
+
+// Restart restarts the service.
+func (s *Service) Restart(ctx context.Context) error {
+	return s.world.act(ctx, s.name, "restart")
+}
+
+// GetName returns the service name.
+func (s *Service) GetName() string {
+	return s.name
+}
+
+// GetHost returns the host name.
+func (s *Service) GetHost() string {
+	return s.world.host
+}
+
+// GetSource returns "demo".
+func (s *Service) GetSource() string {
+	return "demo"
+}
@@ -0,0 +1,179 @@
+package demo
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock is a settable clock for worlds under test.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTestWorld(host string) (*world, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)}
+	w := newWorld(host, seedFor(host), clock.now)
+	w.minDelay, w.maxDelay = 0, 0
+	return w, clock
+}
+
+func TestNewWorld_Deterministic(t *testing.T) {
+	a, _ := newTestWorld("demo-nas")
+	b, _ := newTestWorld("demo-nas")
+	if !reflect.DeepEqual(a.snapshot(), b.snapshot()) {
+		t.Error("worlds with the same seed differ")
+	}
+
+	other, _ := newTestWorld("demo-pi")
+	if reflect.DeepEqual(a.snapshot(), other.snapshot()) {
+		t.Error("worlds of different hosts are identical")
+	}
+
+	svcList := a.snapshot()
+	if len(svcList) < 6 || len(svcList) > len(catalog) {
+		t.Fatalf("got %d services, want 6 to %d", len(svcList), len(catalog))
+	}
+	for _, svc := range svcList {
+		if svc.Source != "demo" || svc.Host != "demo-nas" || svc.LastStateChange == nil {
+			t.Errorf("service = %+v", svc)
+		}
+		if (svc.State == "stopped") != (svc.ExitCode != nil) {
+			t.Errorf("%s: state %s with exit code %v", svc.Name, svc.State, svc.ExitCode)
+		}
+	}
+}
+
+func TestWorld_FlipsState(t *testing.T) {
+	a, clockA := newTestWorld("demo-nas")
+	b, clockB := newTestWorld("demo-nas")
+	before := a.snapshot()
+
+	clockA.t = clockA.t.Add(flipInterval)
+	clockB.t = clockB.t.Add(flipInterval)
+	after := a.snapshot()
+
+	changed := 0
+	for i := range before {
+		if before[i].State != after[i].State {
+			changed++
+			if !after[i].LastStateChange.Equal(clockA.t) {
+				t.Errorf("%s changed at %v, want %v", after[i].Name, after[i].LastStateChange, clockA.t)
+			}
+		}
+	}
+	if changed != 1 {
+		t.Errorf("%d services changed state after one interval, want 1", changed)
+	}
+	if !reflect.DeepEqual(after, b.snapshot()) {
+		t.Error("state changes are not deterministic")
+	}
+}
+
+func TestWorld_Actions(t *testing.T) {
+	w, _ := newTestWorld("demo-nas")
+	w.failureRate = 0
+	svc := &Service{world: w, name: w.services[0].name}
+	ctx := context.Background()
+
+	if err := svc.Stop(ctx); err != nil {
+		t.Fatalf("Stop() = %v", err)
+	}
+	if info, _ := svc.GetInfo(ctx); info.State != "stopped" || *info.ExitCode != 0 {
+		t.Errorf("after stop: state %s, exit code %v", info.State, info.ExitCode)
+	}
+	if err := svc.Start(ctx); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	if info, _ := svc.GetInfo(ctx); info.State != "running" {
+		t.Errorf("after start: state %s", info.State)
+	}
+
+	w.failureRate = 1
+	if err := svc.Stop(ctx); err == nil || !strings.Contains(err.Error(), "simulated failure") {
+		t.Errorf("failing Stop() = %v", err)
+	}
+	if info, _ := svc.GetInfo(ctx); info.State != "running" {
+		t.Errorf("failed stop changed state to %s", info.State)
+	}
+
+	w.failureRate = 0
+	w.minDelay, w.maxDelay = time.Hour, time.Hour
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := svc.Restart(cancelled); !errors.Is(err, context.Canceled) {
+		t.Errorf("Restart() with cancelled context = %v", err)
+	}
+
+	if _, err := (&Provider{world: w}).GetService("missing"); err == nil {
+		t.Error("GetService(missing) succeeded")
+	}
+}
+
+func TestStreamLogs_Tail(t *testing.T) {
+	read := func() []string {
+		logs := streamLogs(context.Background(), "jellyfin", 42, 5, false, time.Second)
+		defer logs.Close()
+		data, err := io.ReadAll(logs)
+		if err != nil {
+			t.Fatalf("ReadAll() = %v", err)
+		}
+		return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+
+	lines := read()
+	if len(lines) != 5 {
+		t.Fatalf("got %d lines, want 5: %q", len(lines), lines)
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, "[jellyfin]") {
+			t.Errorf("line %q does not name the service", line)
+		}
+	}
+
+	// Messages depend only on the seed
+	strip := func(lines []string) []string {
+		var messages []string
+		for _, line := range lines {
+			_, message, _ := strings.Cut(line, " ")
+			messages = append(messages, message)
+		}
+		return messages
+	}
+	if again := read(); !reflect.DeepEqual(strip(lines), strip(again)) {
+		t.Errorf("log messages differ between streams:\n%q\n%q", lines, again)
+	}
+}
+
+func TestStreamLogs_EndsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	logs := streamLogs(ctx, "grafana", 1, 2, true, time.Millisecond)
+	defer logs.Close()
+
+	reader := bufio.NewReader(logs)
+	for i := 0; i < 5; i++ {
+		if _, err := reader.ReadString('\n'); err != nil {
+			t.Fatalf("ReadString() = %v", err)
+		}
+	}
+	cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(io.Discard, reader)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("stream ended with %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not end after cancel")
+	}
+}
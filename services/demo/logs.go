@@ -0,0 +1,71 @@
+package demo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+)
+
+// logInterval is how often a followed log stream emits a line.
+const logInterval = time.Second
+
+// logLevels and logMessages are combined into generated log lines.
+var (
+	logLevels   = []string{"INFO", "INFO", "INFO", "INFO", "DEBUG", "WARN", "ERROR"}
+	logMessages = []string{
+		"handled request GET /api/health in %dms",
+		"handled request GET / in %dms",
+		"scheduled job finished in %dms",
+		"cache hit ratio %d%%",
+		"connected clients: %d",
+		"background sync took %dms",
+		"slow query took %dms",
+		"retrying upstream connection (attempt %d)",
+	}
+)
+
+// logLine generates one log line of service at t.
+func logLine(rng *rand.Rand, service string, t time.Time) string {
+	level := logLevels[rng.Intn(len(logLevels))]
+	message := fmt.Sprintf(logMessages[rng.Intn(len(logMessages))], 1+rng.Intn(500))
+	return fmt.Sprintf("%s %-5s [%s] %s\n", t.UTC().Format(time.RFC3339), level, service, message)
+}
+
+// streamLogs returns a stream of generated log lines of service: tailLines
+// lines of history, then with follow one line every interval. The stream ends
+// when ctx is done or the reader is closed.
+func streamLogs(ctx context.Context, service string, seed int64, tailLines int, follow bool, interval time.Duration) io.ReadCloser {
+	pr, pw := io.Pipe()
+	// Closing the writer also unblocks a write nobody is reading
+	stop := context.AfterFunc(ctx, func() { pw.CloseWithError(ctx.Err()) })
+	go func() {
+		defer stop()
+		rng := rand.New(rand.NewSource(seed))
+		now := time.Now()
+		for i := tailLines; i > 0; i-- {
+			if _, err := io.WriteString(pw, logLine(rng, service, now.Add(-time.Duration(i)*interval))); err != nil {
+				return // Reader closed
+			}
+		}
+		if !follow {
+			pw.Close()
+			return
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case t := <-ticker.C:
+				if _, err := io.WriteString(pw, logLine(rng, service, t)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return pr
+}
@@ -0,0 +1,20 @@
+package demo
+
+import (
+	"home_server_dashboard/config"
+	"home_server_dashboard/services"
+)
+
+func init() {
+	services.Register(services.Registration{
+		Source: "demo",
+		Order:  90,
+		Configured: func(host *config.HostConfig) bool {
+			return host.Demo
+		},
+		Factory: func(cfg *config.Config, host *config.HostConfig) (services.Provider, error) {
+			return NewProvider(host.Name), nil
+		},
+		Capabilities: services.Capabilities{Logs: true, Actions: true, Poll: true},
+	})
+}
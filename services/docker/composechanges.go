@@ -0,0 +1,72 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"home_server_dashboard/config"
+)
+
+// composeConfigFilesLabel is set by compose to the comma-separated compose
+// files the project was brought up from.
+const composeConfigFilesLabel = "com.docker.compose.project.config_files"
+
+// composeFiles returns the compose files a container was created from: the
+// files in its labels, relative ones resolved against the project's working
+// directory, or else the compose file compose finds in that directory. It
+// returns nil if neither label is set or no compose file is found.
+func composeFiles(labels map[string]string) []string {
+	dir := labels[composeWorkingDirLabel]
+	var files []string
+	for _, file := range strings.Split(labels[composeConfigFilesLabel], ",") {
+		file = strings.TrimSpace(file)
+		if file == "" {
+			continue
+		}
+		if !filepath.IsAbs(file) {
+			if dir == "" {
+				continue
+			}
+			file = filepath.Join(dir, file)
+		}
+		files = append(files, file)
+	}
+	if len(files) > 0 || dir == "" {
+		return files
+	}
+	for _, name := range config.ComposeFileNames {
+		file := filepath.Join(dir, name)
+		if _, err := os.Stat(file); err == nil {
+			return []string{file}
+		}
+	}
+	return nil
+}
+
+// composeChangedSince reports whether a compose file of the container was
+// modified after created, the Unix time the container was created, so the
+// running container may no longer match it and needs another up. Compose
+// compares a hash of the service's resolved config instead, which would need
+// a full compose-spec parser; comparing times also flags edits to other
+// services of the project and edits that were undone. Files that can't be
+// read are treated as unchanged.
+func composeChangedSince(labels map[string]string, created int64) bool {
+	files := composeFiles(labels)
+	if len(files) == 0 {
+		return false
+	}
+	changed := false
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return false
+		}
+		// Created is in whole seconds, so edits in the second the container
+		// was created are missed rather than flagged
+		if info.ModTime().Unix() > created {
+			changed = true
+		}
+	}
+	return changed
+}
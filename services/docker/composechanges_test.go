@@ -0,0 +1,78 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeComposeFile writes a compose file into dir, last modified at modified.
+func writeComposeFile(t *testing.T, dir, name string, modified time.Time) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	data := []byte("services:\n  web:\n    image: nginx:1.27\n    environment:\n      - MODE=prod\n")
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestComposeFiles(t *testing.T) {
+	dir := t.TempDir()
+	compose := writeComposeFile(t, dir, "compose.yaml", time.Now())
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   []string
+	}{
+		{"from labels", map[string]string{composeConfigFilesLabel: "/srv/a/compose.yml,/srv/a/compose.override.yml"}, []string{"/srv/a/compose.yml", "/srv/a/compose.override.yml"}},
+		{"relative to working dir", map[string]string{composeConfigFilesLabel: "compose.yaml", composeWorkingDirLabel: dir}, []string{compose}},
+		{"found in working dir", map[string]string{composeWorkingDirLabel: dir}, []string{compose}},
+		{"no compose file in working dir", map[string]string{composeWorkingDirLabel: t.TempDir()}, nil},
+		{"no labels", map[string]string{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := composeFiles(tt.labels); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("composeFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComposeChangedSince(t *testing.T) {
+	created := time.Unix(1700000000, 0)
+	dir := t.TempDir()
+	unchanged := writeComposeFile(t, dir, "compose.yaml", created.Add(-time.Hour))
+	edited := writeComposeFile(t, dir, "compose.override.yaml", created.Add(time.Hour))
+	sameSecond := writeComposeFile(t, dir, "compose.same.yaml", created.Add(500*time.Millisecond))
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   bool
+	}{
+		{"file older than container", map[string]string{composeConfigFilesLabel: unchanged}, false},
+		{"file edited after container", map[string]string{composeConfigFilesLabel: edited}, true},
+		{"one of several files edited", map[string]string{composeConfigFilesLabel: unchanged + "," + edited}, true},
+		{"edited in the second it was created", map[string]string{composeConfigFilesLabel: sameSecond}, false},
+		{"file missing", map[string]string{composeConfigFilesLabel: filepath.Join(dir, "gone.yaml")}, false},
+		{"one file missing", map[string]string{composeConfigFilesLabel: edited + "," + filepath.Join(dir, "gone.yaml")}, false},
+		{"no compose labels", map[string]string{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := composeChangedSince(tt.labels, created.Unix()); got != tt.want {
+				t.Errorf("composeChangedSince() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
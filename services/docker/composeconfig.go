@@ -0,0 +1,280 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"gopkg.in/yaml.v3"
+
+	"home_server_dashboard/services"
+)
+
+// composeService is the part of a service definition in a compose file that
+// is compared with its container.
+type composeService struct {
+	Ports    []any    `yaml:"ports"` // Short syntax strings or numbers, or long syntax maps
+	Restart  string   `yaml:"restart"`
+	Profiles []string `yaml:"profiles"`
+}
+
+// ReadIntendedConfig reads the config the compose files of a project declare
+// for service, merging later files into earlier ones as compose does: ports
+// are added, while restart and profiles are replaced. It returns nil without
+// an error if no file declares the service. Ports set from variables are
+// compared as written, since the variables aren't known here.
+func ReadIntendedConfig(files []string, service string) (*services.IntendedConfig, error) {
+	var intended *services.IntendedConfig
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var compose struct {
+			Services map[string]composeService `yaml:"services"`
+		}
+		if err := yaml.Unmarshal(data, &compose); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		svc, ok := compose.Services[service]
+		if !ok {
+			continue
+		}
+		if intended == nil {
+			intended = &services.IntendedConfig{Ports: []string{}, RestartPolicy: "no"}
+		}
+		for _, entry := range svc.Ports {
+			ports, err := composePorts(entry)
+			if err != nil {
+				return nil, fmt.Errorf("%s: service %s: %w", file, service, err)
+			}
+			intended.Ports = append(intended.Ports, ports...)
+		}
+		if svc.Restart != "" {
+			intended.RestartPolicy = svc.Restart
+		}
+		if svc.Profiles != nil {
+			intended.Profiles = svc.Profiles
+		}
+	}
+	if intended != nil {
+		slices.Sort(intended.Ports)
+		intended.Ports = slices.Compact(intended.Ports)
+	}
+	return intended, nil
+}
+
+// composePorts returns the ports of one entry of a service's ports, in the
+// form of services.IntendedConfig. A range is returned port by port.
+func composePorts(entry any) ([]string, error) {
+	switch entry := entry.(type) {
+	case int:
+		return []string{fmt.Sprintf("%d/tcp", entry)}, nil
+	case string:
+		if strings.Contains(entry, "$") {
+			return []string{entry}, nil
+		}
+		return parseShortPort(entry)
+	case map[string]any:
+		// Long syntax
+		protocol := "tcp"
+		if p, ok := entry["protocol"].(string); ok && p != "" {
+			protocol = p
+		}
+		hostIP, _ := entry["host_ip"].(string)
+		var published, target string
+		if value, ok := entry["published"]; ok && value != nil {
+			published = fmt.Sprint(value)
+		}
+		if value, ok := entry["target"]; ok && value != nil {
+			target = fmt.Sprint(value)
+		}
+		return portMappings(hostIP, published, target, protocol)
+	}
+	return nil, fmt.Errorf("unsupported port entry %v", entry)
+}
+
+// parseShortPort parses a port in the short syntax:
+// [[host_ip:][host_port]:]container_port[/protocol], where the ports may be
+// ranges and an IPv6 host_ip is in brackets.
+func parseShortPort(entry string) ([]string, error) {
+	spec, protocol, ok := strings.Cut(entry, "/")
+	if !ok {
+		protocol = "tcp"
+	}
+	var hostIP string
+	if strings.HasPrefix(spec, "[") {
+		end := strings.Index(spec, "]:")
+		if end < 0 {
+			return nil, fmt.Errorf("invalid port %q", entry)
+		}
+		hostIP, spec = spec[1:end], spec[end+2:]
+	}
+	parts := strings.Split(spec, ":")
+	switch {
+	case len(parts) == 1:
+		return portMappings(hostIP, "", parts[0], protocol)
+	case len(parts) == 2:
+		return portMappings(hostIP, parts[0], parts[1], protocol)
+	case len(parts) == 3 && hostIP == "":
+		return portMappings(parts[0], parts[1], parts[2], protocol)
+	}
+	return nil, fmt.Errorf("invalid port %q", entry)
+}
+
+// portMappings returns the mappings of the host port range published to the
+// container port range target. An empty published lets Docker pick the host
+// ports.
+func portMappings(hostIP, published, target, protocol string) ([]string, error) {
+	targetFrom, targetTo, err := portRange(target)
+	if err != nil || target == "" {
+		return nil, fmt.Errorf("invalid container port %q", target)
+	}
+	if published == "" {
+		var ports []string
+		for port := targetFrom; port <= targetTo; port++ {
+			ports = append(ports, formatPort(hostIP, "", port, protocol))
+		}
+		return ports, nil
+	}
+	publishedFrom, publishedTo, err := portRange(published)
+	if err != nil {
+		return nil, fmt.Errorf("invalid host port %q", published)
+	}
+	if publishedTo-publishedFrom != targetTo-targetFrom {
+		if targetFrom != targetTo {
+			return nil, fmt.Errorf("host ports %s don't match container ports %s", published, target)
+		}
+		// Any one port of the host range is used
+		return []string{formatPort(hostIP, published, targetFrom, protocol)}, nil
+	}
+	var ports []string
+	for i := 0; i <= targetTo-targetFrom; i++ {
+		ports = append(ports, formatPort(hostIP, strconv.Itoa(publishedFrom+i), targetFrom+i, protocol))
+	}
+	return ports, nil
+}
+
+// portRange parses a port or a range of ports such as "8000-8010".
+func portRange(value string) (from, to int, err error) {
+	first, last, isRange := strings.Cut(value, "-")
+	if from, err = strconv.Atoi(first); err != nil {
+		return 0, 0, err
+	}
+	to = from
+	if isRange {
+		if to, err = strconv.Atoi(last); err != nil {
+			return 0, 0, err
+		}
+	}
+	if from < 0 || to < from || to > 65535 {
+		return 0, 0, fmt.Errorf("invalid port range %q", value)
+	}
+	return from, to, nil
+}
+
+// formatPort formats a port mapping in the form of services.IntendedConfig.
+// Binding to all addresses is the same as binding to none.
+func formatPort(hostIP, hostPort string, containerPort int, protocol string) string {
+	port := fmt.Sprintf("%d/%s", containerPort, protocol)
+	if hostPort != "" {
+		port = hostPort + ":" + port
+	}
+	if hostIP != "" && hostIP != "0.0.0.0" && hostIP != "::" {
+		if strings.Contains(hostIP, ":") {
+			hostIP = "[" + hostIP + "]"
+		}
+		port = hostIP + ":" + port
+	}
+	return port
+}
+
+// runtimeConfig returns the ports and restart policy a container was created
+// with. The ports are its configured bindings rather than those in use, so a
+// stopped container has them too and a port Docker picked matches a
+// declaration without a host port.
+func runtimeConfig(inspect container.InspectResponse) services.RuntimeConfig {
+	actual := services.RuntimeConfig{Ports: []string{}, RestartPolicy: "no"}
+	if inspect.HostConfig == nil {
+		return actual
+	}
+	for port, bindings := range inspect.HostConfig.PortBindings {
+		for _, binding := range bindings {
+			actual.Ports = append(actual.Ports, formatPort(binding.HostIP, binding.HostPort, port.Int(), port.Proto()))
+		}
+	}
+	slices.Sort(actual.Ports)
+	actual.Ports = slices.Compact(actual.Ports)
+
+	policy := inspect.HostConfig.RestartPolicy
+	if policy.Name != "" {
+		actual.RestartPolicy = string(policy.Name)
+	}
+	if policy.Name == container.RestartPolicyOnFailure && policy.MaximumRetryCount > 0 {
+		actual.RestartPolicy = fmt.Sprintf("%s:%d", policy.Name, policy.MaximumRetryCount)
+	}
+	return actual
+}
+
+// configDiscrepancies lists where the intended config differs from actual,
+// in words.
+func configDiscrepancies(intended *services.IntendedConfig, actual services.RuntimeConfig) []string {
+	discrepancies := []string{}
+	for _, port := range intended.Ports {
+		if !slices.Contains(actual.Ports, port) {
+			discrepancies = append(discrepancies, fmt.Sprintf("port %s is declared but not published", port))
+		}
+	}
+	for _, port := range actual.Ports {
+		if !slices.Contains(intended.Ports, port) {
+			discrepancies = append(discrepancies, fmt.Sprintf("port %s is published but not declared", port))
+		}
+	}
+	if intended.RestartPolicy != actual.RestartPolicy {
+		discrepancies = append(discrepancies, fmt.Sprintf("restart policy is %s in the compose file but %s on the container", intended.RestartPolicy, actual.RestartPolicy))
+	}
+	return discrepancies
+}
+
+// CompareConfig compares the config the container's compose files declare
+// for its service with the config the container runs with. The intended
+// config is left out if the container wasn't created by compose or its files
+// can't be read.
+func (s *DockerService) CompareConfig(ctx context.Context) (*services.ConfigComparison, error) {
+	inspect, err := s.inspects.inspect(ctx, s.client, s.containerName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	var labels map[string]string
+	if inspect.Config != nil {
+		labels = inspect.Config.Labels
+	}
+
+	comparison := &services.ConfigComparison{Actual: runtimeConfig(inspect), Discrepancies: []string{}}
+	service := labels["com.docker.compose.service"]
+	comparison.Files = composeFiles(labels)
+	if service == "" || len(comparison.Files) == 0 {
+		return comparison, nil
+	}
+	intended, err := ReadIntendedConfig(comparison.Files, service)
+	if err != nil {
+		// Only what the container runs with can be shown
+		return comparison, nil
+	}
+	if intended == nil {
+		names := make([]string, len(comparison.Files))
+		for i, file := range comparison.Files {
+			names[i] = filepath.Base(file)
+		}
+		comparison.Discrepancies = append(comparison.Discrepancies, fmt.Sprintf("service %s is not declared in %s", service, strings.Join(names, ", ")))
+		return comparison, nil
+	}
+	comparison.Intended = intended
+	comparison.Discrepancies = configDiscrepancies(intended, comparison.Actual)
+	return comparison, nil
+}
@@ -0,0 +1,163 @@
+package docker
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+
+	"home_server_dashboard/services"
+)
+
+// composeConfigFixture is a compose file with services declaring ports in
+// the short and long syntax, restart policies and a profile.
+const composeConfigFixture = "testdata/composeconfig/compose.yaml"
+
+func TestReadIntendedConfig(t *testing.T) {
+	for service, want := range map[string]*services.IntendedConfig{
+		"web":    {Ports: []string{"127.0.0.1:8443:443/tcp", "8080:80/tcp"}, RestartPolicy: "unless-stopped"},
+		"dns":    {Ports: []string{"53:53/udp", "9153/tcp"}, RestartPolicy: "always"},
+		"worker": {Ports: []string{}, RestartPolicy: "on-failure:3", Profiles: []string{"jobs"}},
+		"gone":   nil,
+	} {
+		got, err := ReadIntendedConfig([]string{composeConfigFixture}, service)
+		if err != nil {
+			t.Fatalf("ReadIntendedConfig(%s) = %v", service, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ReadIntendedConfig(%s) = %+v, want %+v", service, got, want)
+		}
+	}
+}
+
+func TestComposePorts(t *testing.T) {
+	tests := []struct {
+		entry   any
+		want    []string
+		wantErr bool
+	}{
+		{80, []string{"80/tcp"}, false},
+		{"3000", []string{"3000/tcp"}, false},
+		{"8000-8001:80-81", []string{"8000:80/tcp", "8001:81/tcp"}, false},
+		{"0.0.0.0:8080:80", []string{"8080:80/tcp"}, false},
+		{"127.0.0.1::80", []string{"127.0.0.1:80/tcp"}, false},
+		{"[::1]:8080:80/udp", []string{"[::1]:8080:80/udp"}, false},
+		{"${WEB_PORT:-8080}:80", []string{"${WEB_PORT:-8080}:80"}, false},
+		{map[string]any{"target": 80, "published": "8080", "host_ip": "192.168.1.2"}, []string{"192.168.1.2:8080:80/tcp"}, false},
+		{"8000-8002:80-81", nil, true},
+		{"web:80", nil, true},
+		{map[string]any{"published": 8080}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := composePorts(tt.entry)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("composePorts(%v) = %q, %v; want %q, error %v", tt.entry, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// configuredContainer inspects as a container of a compose service with the
+// given port bindings and restart policy.
+type configuredContainer struct {
+	client.APIClient
+	labels   map[string]string
+	bindings nat.PortMap
+	restart  container.RestartPolicy
+}
+
+func (f *configuredContainer) ContainerInspect(ctx context.Context, name string) (container.InspectResponse, error) {
+	return container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			ID:         "abc123",
+			Name:       "/" + name,
+			State:      &container.State{Status: "running"},
+			HostConfig: &container.HostConfig{PortBindings: f.bindings, RestartPolicy: f.restart},
+		},
+		Config: &container.Config{Labels: f.labels},
+	}, nil
+}
+
+func TestDockerService_CompareConfig(t *testing.T) {
+	file, err := filepath.Abs(composeConfigFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := func(service string) map[string]string {
+		return map[string]string{"com.docker.compose.service": service, composeConfigFilesLabel: file}
+	}
+	webBindings := nat.PortMap{
+		"80/tcp":  {{HostIP: "0.0.0.0", HostPort: "8080"}},
+		"443/tcp": {{HostIP: "127.0.0.1", HostPort: "8443"}},
+	}
+	unlessStopped := container.RestartPolicy{Name: container.RestartPolicyUnlessStopped}
+
+	tests := []struct {
+		name          string
+		cli           *configuredContainer
+		wantIntended  bool
+		discrepancies []string
+	}{
+		{
+			name:          "matching",
+			cli:           &configuredContainer{labels: labels("web"), bindings: webBindings, restart: unlessStopped},
+			wantIntended:  true,
+			discrepancies: []string{},
+		},
+		{
+			name: "differing ports",
+			cli: &configuredContainer{labels: labels("web"), restart: unlessStopped, bindings: nat.PortMap{
+				"80/tcp":  {{HostIP: "0.0.0.0", HostPort: "8081"}},
+				"443/tcp": {{HostIP: "127.0.0.1", HostPort: "8443"}},
+			}},
+			wantIntended: true,
+			discrepancies: []string{
+				"port 8080:80/tcp is declared but not published",
+				"port 8081:80/tcp is published but not declared",
+			},
+		},
+		{
+			name:          "differing restart policy",
+			cli:           &configuredContainer{labels: labels("web"), bindings: webBindings, restart: container.RestartPolicy{Name: container.RestartPolicyOnFailure, MaximumRetryCount: 5}},
+			wantIntended:  true,
+			discrepancies: []string{"restart policy is unless-stopped in the compose file but on-failure:5 on the container"},
+		},
+		{
+			name: "random host port",
+			cli: &configuredContainer{labels: labels("dns"), restart: container.RestartPolicy{Name: container.RestartPolicyAlways}, bindings: nat.PortMap{
+				"53/udp":   {{HostPort: "53"}},
+				"9153/tcp": {{}},
+			}},
+			wantIntended:  true,
+			discrepancies: []string{},
+		},
+		{
+			name:          "missing from the file",
+			cli:           &configuredContainer{labels: labels("cache"), bindings: nat.PortMap{"6379/tcp": {{HostPort: "6379"}}}},
+			discrepancies: []string{"service cache is not declared in compose.yaml"},
+		},
+		{
+			name:          "file not found",
+			cli:           &configuredContainer{labels: map[string]string{"com.docker.compose.service": "web", composeConfigFilesLabel: filepath.Join(t.TempDir(), "compose.yaml")}},
+			discrepancies: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &DockerService{containerName: "media-" + tt.name, hostName: "nas", client: tt.cli}
+			got, err := svc.CompareConfig(context.Background())
+			if err != nil {
+				t.Fatalf("CompareConfig() = %v", err)
+			}
+			if (got.Intended != nil) != tt.wantIntended {
+				t.Errorf("Intended = %+v, want it present: %v", got.Intended, tt.wantIntended)
+			}
+			if !reflect.DeepEqual(got.Discrepancies, tt.discrepancies) {
+				t.Errorf("Discrepancies = %q, want %q", got.Discrepancies, tt.discrepancies)
+			}
+		})
+	}
+}
@@ -6,14 +6,20 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	cerrdefs "github.com/containerd/errdefs"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 
 	"home_server_dashboard/services"
+	"home_server_dashboard/services/systemd"
 )
 
 // Docker label constants for dashboard configuration
@@ -22,20 +28,47 @@ const (
 	LabelPrefix = "home.server.dashboard"
 	// LabelDescription is the label for service description
 	LabelDescription = LabelPrefix + ".description"
+	// LabelDisplayName is the label for a friendly name shown instead of the compose service name
+	LabelDisplayName = LabelPrefix + ".name"
 	// LabelHidden is the label to hide an entire service from the dashboard
 	LabelHidden = LabelPrefix + ".hidden"
+	// LabelDrifted is set by the dashboard on containers it recreated outside compose
+	LabelDrifted = LabelPrefix + ".drifted"
 	// LabelPortsPrefix is the prefix for port-specific labels
 	LabelPortsPrefix = LabelPrefix + ".ports"
 	// LabelPortsHidden is the label for a comma-separated list of hidden port numbers
 	LabelPortsHidden = LabelPortsPrefix + ".hidden"
 	// LabelRemapPortPrefix is the prefix for port remapping labels (home.server.dashboard.remapport.<port>=<service>)
 	LabelRemapPortPrefix = LabelPrefix + ".remapport"
+	// LabelURL is the label for a comma-separated list of URLs shown instead of the Traefik-matched ones
+	LabelURL = LabelPrefix + ".url"
+	// LabelTraefikIgnore is the label to match a service to Traefik only by the name in its traefik labels
+	LabelTraefikIgnore = LabelPrefix + ".traefik.ignore"
+	// LabelIcon is the label for the icon other dashboards show for the service, e.g. "sonarr.png"
+	LabelIcon = LabelPrefix + ".icon"
+	// LabelLogsTail is the label for how many lines of history the log viewer shows, e.g. "500"
+	LabelLogsTail = LabelPrefix + ".logs.tail"
+	// LabelLogsTimestamps is the label to leave timestamps out of log lines ("false")
+	LabelLogsTimestamps = LabelPrefix + ".logs.timestamps"
+	// LabelProbeURL is the label for the URL of a custom health probe, e.g. "http://container:8080/health"
+	LabelProbeURL = LabelPrefix + ".probe.url"
+	// LabelProbeInterval is the label for how often the probe runs, in seconds or as a duration
+	LabelProbeInterval = LabelPrefix + ".probe.interval"
+	// LabelProbeTimeout is the label for how long the probe may take, in seconds or as a duration
+	LabelProbeTimeout = LabelPrefix + ".probe.timeout"
+	// LabelProbeExpectStatus is the label for the HTTP status the probe expects instead of any 2xx or 3xx
+	LabelProbeExpectStatus = LabelPrefix + ".probe.expect_status"
 )
 
 // Provider implements services.Provider for Docker containers.
 type Provider struct {
-	hostName string
-	client   *client.Client
+	hostName        string
+	client          client.APIClient
+	images          *imageCache
+	inspects        *inspectCache
+	imageStaleAfter time.Duration
+	composeChanges  bool
+	composeLayout   composeLayout
 }
 
 // NewProvider creates a new Docker provider for the given host.
@@ -45,10 +78,37 @@ func NewProvider(hostName string) (*Provider, error) {
 		return nil, fmt.Errorf("failed to create docker client: %w", err)
 	}
 
+	return NewProviderWithClient(hostName, cli), nil
+}
+
+// NewProviderWithClient creates a Docker provider for the given host that
+// talks to Docker through cli, e.g. a fake in tests.
+func NewProviderWithClient(hostName string, cli client.APIClient) *Provider {
 	return &Provider{
 		hostName: hostName,
 		client:   cli,
-	}, nil
+		images:   imageCacheFor(hostName),
+		inspects: inspectCacheFor(hostName),
+	}
+}
+
+// SetImageStaleAfter sets how old a container's image may be before the
+// service is marked stale. Zero disables the staleness indicator.
+func (p *Provider) SetImageStaleAfter(threshold time.Duration) {
+	p.imageStaleAfter = threshold
+}
+
+// SetComposeRoots sets the host's docker_compose_roots and
+// docker_compose_roots_glob patterns. A container is only flagged orphaned
+// if its project directory was in one of them (see isOrphaned).
+func (p *Provider) SetComposeRoots(roots, patterns []string) {
+	p.composeLayout = composeLayout{roots: roots, patterns: patterns}
+}
+
+// SetComposeChangeDetection sets whether services are checked for compose
+// file changes made after their container was created.
+func (p *Provider) SetComposeChangeDetection(enabled bool) {
+	p.composeChanges = enabled
 }
 
 // Close closes the Docker client connection.
@@ -64,20 +124,53 @@ func (p *Provider) Name() string {
 	return "docker"
 }
 
+// Ping checks that the Docker daemon is reachable.
+func (p *Provider) Ping(ctx context.Context) error {
+	if _, err := p.client.Ping(ctx); err != nil {
+		return fmt.Errorf("docker ping failed: %w", err)
+	}
+	return nil
+}
+
+// CheckListContainers checks that the dashboard is allowed to list containers.
+func (p *Provider) CheckListContainers(ctx context.Context) error {
+	if _, err := p.client.ContainerList(ctx, container.ListOptions{All: true, Limit: 1}); err != nil {
+		return fmt.Errorf("failed to list containers: %w", err)
+	}
+	return nil
+}
+
 // GetServices returns all Docker Compose containers as services.
 func (p *Provider) GetServices(ctx context.Context) ([]services.ServiceInfo, error) {
-	svcList, _ := p.GetServicesWithRemaps(ctx)
-	return svcList, nil
+	svcList, _, err := p.GetServicesWithRemaps(ctx)
+	return svcList, err
 }
 
 // GetServicesWithRemaps returns all Docker Compose containers as services,
 // along with any port remapping information from container labels.
-func (p *Provider) GetServicesWithRemaps(ctx context.Context) ([]services.ServiceInfo, []PortRemap) {
+// It fails if the containers can't be listed.
+func (p *Provider) GetServicesWithRemaps(ctx context.Context) ([]services.ServiceInfo, []PortRemap, error) {
 	containers, err := p.client.ContainerList(ctx, container.ListOptions{All: true})
 	if err != nil {
-		return nil, nil
+		return nil, nil, fmt.Errorf("failed to list containers: %w", err)
 	}
 
+	// Look up image metadata once per unique image ID
+	containerImages := make(map[string]string, len(containers))
+	for _, ctr := range containers {
+		if ctr.Labels["com.docker.compose.project"] != "" && ctr.Labels["com.docker.compose.service"] != "" {
+			containerImages[ctr.ID] = ctr.ImageID
+		}
+	}
+	images := p.images.lookup(ctx, p.client, containerImages)
+	now := time.Now()
+
+	// Without the host's platform, no image is flagged as built for another
+	platform, _ := p.Platform(ctx)
+
+	owners := newNamespaceOwners(containers)
+	profiles := make(profileReader)
+
 	var result []services.ServiceInfo
 	var allRemaps []PortRemap
 	for _, ctr := range containers {
@@ -109,20 +202,46 @@ func (p *Provider) GetServicesWithRemaps(ctx context.Context) ([]services.Servic
 		// Extract custom description from label
 		description := ctr.Labels[LabelDescription]
 
+		// Extract display name from label (presentation only, actions keep using service)
+		displayName := strings.TrimSpace(ctr.Labels[LabelDisplayName])
+
 		// Check if service should be hidden
 		hidden := isLabelTrue(ctr.Labels[LabelHidden])
 
 		// Extract Traefik service name if explicitly defined in labels
 		traefikServiceName := extractTraefikServiceName(ctr.Labels)
 
-		// Get log file size by inspecting container
-		logSize := p.getContainerLogSize(ctx, ctr.ID)
+		// URLs from the url label replace the Traefik-matched ones
+		urls := parseURLLabel(ctr.Labels[LabelURL])
+
+		// Get log file size, log driver and state times by inspecting container
+		details := p.inspectContainer(ctx, ctr.ID)
+
+		imageInfo := images[ctr.ImageID]
+		var imagePlatform string
+		if imageInfo.Platform.Known() {
+			imagePlatform = imageInfo.Platform.String()
+		}
+
+		// A stopped container's status carries its exit code; the inspect
+		// above already gave the time it exited
+		exitCode := parseExitCode(ctr.Status)
+		var finishedAt *time.Time
+		if exitCode != nil {
+			finishedAt = details.stateSince
+		}
+
+		// A stopped service behind a compose profile is down on purpose
+		serviceProfiles := profiles.read(ctr.Labels).Of(service)
+		state := profileState(MapState(ctr.State, HealthFromStatus(ctr.Status)), serviceProfiles, exitCode)
 
 		result = append(result, services.ServiceInfo{
 			Name:               service,
+			DisplayName:        displayName,
 			Project:            project,
 			ContainerName:      containerName,
-			State:              ctr.State,
+			ContainerID:        ctr.ID,
+			State:              state,
 			Status:             ctr.Status,
 			Image:              ctr.Image,
 			Source:             "docker",
@@ -131,32 +250,135 @@ func (p *Provider) GetServicesWithRemaps(ctx context.Context) ([]services.Servic
 			Description:        description,
 			Hidden:             hidden,
 			TraefikServiceName: traefikServiceName,
-			LogSize:            logSize,
+			TraefikURLs:        urls,
+			URLOverride:        len(urls) > 0,
+			TraefikIgnore:      isLabelTrue(ctr.Labels[LabelTraefikIgnore]),
+			LogSize:            details.logSize,
+			LogDriver:          details.logDriver,
+			LastStateChange:    details.stateSince,
+			StateSince:         details.stateSince,
+			StartedAt:          details.startedAt,
+			ImageCreated:       imageInfo.Created,
+			ImageDigest:        imageInfo.Digest,
+			Stale:              isImageStale(imageInfo.Created, p.imageStaleAfter, now),
+			ImagePlatform:      imagePlatform,
+			ArchMismatch:       !platform.Runs(imageInfo.Platform),
+			Drifted:            isLabelTrue(ctr.Labels[LabelDrifted]),
+			ConfigDrift:        p.composeChanges && composeChangedSince(ctr.Labels, ctr.Created),
+			ExitCode:           exitCode,
+			FinishedAt:         finishedAt,
+			Networks:           containerNetworks(ctr),
+			NetworkOf:          owners.sharedWith(ctr),
+			Orphaned:           isOrphaned(ctr, p.composeLayout),
+			Profiles:           serviceProfiles,
+			Icon:               strings.TrimSpace(ctr.Labels[LabelIcon]),
+			LogSettings:        parseLogSettings(ctr.Labels),
 		})
 	}
 
-	return result, allRemaps
+	return result, allRemaps, nil
 }
 
-// getContainerLogSize gets the log file size for a container.
-// Returns 0 if the log file cannot be accessed.
-func (p *Provider) getContainerLogSize(ctx context.Context, containerID string) int64 {
-	inspect, err := p.client.ContainerInspect(ctx, containerID)
+// containerDetails are the details of a container only its inspect reports.
+type containerDetails struct {
+	logSize    int64
+	logDriver  string
+	startedAt  *time.Time // When the container last started
+	stateSince *time.Time // When the container entered its current state
+}
+
+// inspectContainer gets the log file size, logging driver and state times
+// of a container. Returns zero values if the container cannot be inspected.
+func (p *Provider) inspectContainer(ctx context.Context, containerID string) containerDetails {
+	inspect, err := p.inspects.inspect(ctx, p.client, containerID)
 	if err != nil {
-		return 0
+		return containerDetails{}
 	}
 
-	logPath := inspect.LogPath
-	if logPath == "" {
-		return 0
+	details := containerDetails{
+		logDriver:  logDriver(inspect),
+		startedAt:  containerStartedAt(inspect.State),
+		stateSince: containerStateSince(inspect.State),
+	}
+	if inspect.LogPath == "" {
+		return details
 	}
+	if fi, err := os.Stat(inspect.LogPath); err == nil {
+		details.logSize = fi.Size()
+	}
+	return details
+}
+
+// containerStateSince returns when a container entered its current state:
+// StartedAt while running, FinishedAt otherwise. Returns nil if the relevant
+// timestamp is unset (Docker reports "0001-01-01T00:00:00Z" for never).
+func containerStateSince(state *container.State) *time.Time {
+	if state == nil {
+		return nil
+	}
+
+	value := state.FinishedAt
+	if state.Running || state.Paused || state.Restarting {
+		value = state.StartedAt
+	}
+	return parseDockerTime(value)
+}
+
+// containerStartedAt returns when a container last started, or nil if it
+// never did.
+func containerStartedAt(state *container.State) *time.Time {
+	if state == nil {
+		return nil
+	}
+	return parseDockerTime(state.StartedAt)
+}
+
+// parseDockerTime parses a timestamp of a container's state. Returns nil
+// if it is unset (Docker reports "0001-01-01T00:00:00Z" for never).
+func parseDockerTime(value string) *time.Time {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil || t.IsZero() || t.Year() <= 1 {
+		return nil
+	}
+	return &t
+}
 
-	fi, err := os.Stat(logPath)
+// exitStatusPattern matches the exit code in the status Docker lists for a
+// stopped container, e.g. "Exited (137) 2 hours ago".
+var exitStatusPattern = regexp.MustCompile(`^Exited \((-?\d+)\)`)
+
+// parseExitCode returns the exit code in a container status string, or nil
+// if the status is not that of an exited container.
+func parseExitCode(status string) *int {
+	match := exitStatusPattern.FindStringSubmatch(strings.TrimSpace(status))
+	if match == nil {
+		return nil
+	}
+	code, err := strconv.Atoi(match[1])
 	if err != nil {
-		return 0
+		return nil
+	}
+	return &code
+}
+
+// containerExit returns the exit code, exit time and error of a stopped
+// container. All are empty for a running container or one that never ran.
+func containerExit(state *container.State) (exitCode *int, finishedAt *time.Time, exitErr string) {
+	if state == nil || state.Running || state.Paused || state.Restarting {
+		return nil, nil, ""
+	}
+	finishedAt = containerStateSince(state)
+	if finishedAt == nil {
+		return nil, nil, "" // Created but never started
 	}
+	code := state.ExitCode
+	return &code, finishedAt, state.Error
+}
 
-	return fi.Size()
+// portKey identifies a published port for deduplication.
+type portKey struct {
+	port  uint16
+	proto string
 }
 
 // extractExposedPorts filters ports to only include those bound to non-localhost addresses.
@@ -167,7 +389,7 @@ func extractExposedPorts(ports []container.Port, labels map[string]string) []ser
 	// Parse hidden ports from comma-separated list
 	hiddenPorts := parseHiddenPorts(labels[LabelPortsHidden])
 
-	seen := make(map[string]bool)
+	seen := make(map[portKey]bool, len(ports))
 	var result []services.PortInfo
 	for _, port := range ports {
 		// Skip ports without a public port (not published)
@@ -179,7 +401,7 @@ func extractExposedPorts(ports []container.Port, labels map[string]string) []ser
 			continue
 		}
 		// Deduplicate by host_port:protocol
-		key := fmt.Sprintf("%d:%s", port.PublicPort, port.Type)
+		key := portKey{port.PublicPort, port.Type}
 		if seen[key] {
 			continue
 		}
@@ -191,6 +413,10 @@ func extractExposedPorts(ports []container.Port, labels map[string]string) []ser
 		portURLProtocol := getPortURLProtocol(labels, port.PublicPort)
 
 		// Include ports bound to 0.0.0.0, empty (all interfaces), or specific non-localhost IPs
+		if result == nil {
+			// Docker usually lists each port twice (IPv4 and IPv6), so this is enough
+			result = make([]services.PortInfo, 0, len(ports))
+		}
 		result = append(result, services.PortInfo{
 			HostPort:      port.PublicPort,
 			ContainerPort: port.PrivatePort,
@@ -210,13 +436,50 @@ func isLabelTrue(value string) bool {
 	return v == "true" || v == "1" || v == "yes"
 }
 
+// parseLogSettings returns the log viewer defaults set by a container's
+// labels, or nil if it sets none. A tail that isn't a positive number and
+// timestamps that isn't a boolean are ignored.
+func parseLogSettings(labels map[string]string) *services.LogSettings {
+	var settings services.LogSettings
+	if n, err := strconv.Atoi(strings.TrimSpace(labels[LabelLogsTail])); err == nil && n > 0 {
+		settings.Tail = n
+	}
+	switch strings.ToLower(strings.TrimSpace(labels[LabelLogsTimestamps])) {
+	case "true", "1", "yes":
+		settings.Timestamps = boolPtr(true)
+	case "false", "0", "no":
+		settings.Timestamps = boolPtr(false)
+	}
+	if settings == (services.LogSettings{}) {
+		return nil
+	}
+	return &settings
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+// parseURLLabel parses a comma-separated list of URLs. Entries that aren't
+// absolute http or https URLs are skipped.
+func parseURLLabel(value string) []string {
+	var urls []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		u, err := url.Parse(part)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			continue
+		}
+		urls = append(urls, part)
+	}
+	return urls
+}
+
 // parseHiddenPorts parses a comma-separated list of port numbers into a set.
 // Example: "8080,443,9000" -> {8080: true, 443: true, 9000: true}
 func parseHiddenPorts(value string) map[uint16]bool {
-	result := make(map[uint16]bool)
 	if value == "" {
-		return result
+		return nil
 	}
+	result := make(map[uint16]bool)
 	for _, part := range strings.Split(value, ",") {
 		part = strings.TrimSpace(part)
 		if port, err := strconv.ParseUint(part, 10, 16); err == nil {
@@ -229,38 +492,48 @@ func parseHiddenPorts(value string) map[uint16]bool {
 // getPortLabel retrieves the custom label for a specific port from Docker labels.
 // Looks for: home.server.dashboard.ports.<port>.label
 func getPortLabel(labels map[string]string, port uint16) string {
-	key := fmt.Sprintf("%s.%d.label", LabelPortsPrefix, port)
-	return labels[key]
+	return portLabelValue(labels, port, "label")
 }
 
 // isPortHiddenByLabel checks if a specific port is hidden via its own label.
 // Looks for: home.server.dashboard.ports.<port>.hidden
 func isPortHiddenByLabel(labels map[string]string, port uint16) bool {
-	key := fmt.Sprintf("%s.%d.hidden", LabelPortsPrefix, port)
-	return isLabelTrue(labels[key])
+	return isLabelTrue(portLabelValue(labels, port, "hidden"))
 }
 
 // getPortURLProtocol retrieves the URL protocol override for a specific port from Docker labels.
 // Looks for: home.server.dashboard.ports.<port>.protocol
 // Returns "http" or "https" if specified, empty string otherwise.
 func getPortURLProtocol(labels map[string]string, port uint16) string {
-	key := fmt.Sprintf("%s.%d.protocol", LabelPortsPrefix, port)
-	proto := strings.ToLower(strings.TrimSpace(labels[key]))
-	if proto == "http" || proto == "https" {
-		return proto
+	proto := strings.TrimSpace(portLabelValue(labels, port, "protocol"))
+	switch {
+	case strings.EqualFold(proto, "http"):
+		return "http"
+	case strings.EqualFold(proto, "https"):
+		return "https"
 	}
 	return ""
 }
 
-// PortRemap represents a port that should be remapped from one service to another.
-// This is used when a service runs in another container's network namespace
-// (e.g., qbittorrent running in gluetun's network).
-type PortRemap struct {
-	Port          uint16 // The host port to remap
-	TargetService string // The service name that should own this port
-	SourceService string // The service name that exposes this port
+// portLabelValue returns the value of the label <LabelPortsPrefix>.<port>.<setting>.
+// The key is built in a stack buffer, since these lookups run three times for
+// every published port of every container.
+func portLabelValue(labels map[string]string, port uint16, setting string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var buf [64]byte
+	key := append(buf[:0], LabelPortsPrefix...)
+	key = append(key, '.')
+	key = strconv.AppendUint(key, uint64(port), 10)
+	key = append(key, '.')
+	key = append(key, setting...)
+	return labels[string(key)]
 }
 
+// PortRemap represents a port that should be remapped from one service to another.
+type PortRemap = services.PortRemap
+
 // traefikServicesPrefix is the prefix for Traefik HTTP services labels.
 const traefikServicesPrefix = "traefik.http.services."
 
@@ -283,7 +556,7 @@ func extractTraefikServiceName(labels map[string]string) string {
 
 // parsePortRemaps extracts port remapping information from container labels.
 // Looks for labels like: home.server.dashboard.remapport.<port>=<target_service>
-// Returns a list of port remaps for this container.
+// Returns a list of port remaps for this container, ordered by port.
 func parsePortRemaps(labels map[string]string, sourceService string) []PortRemap {
 	var remaps []PortRemap
 	prefix := LabelRemapPortPrefix + "."
@@ -307,6 +580,8 @@ func parsePortRemaps(labels map[string]string, sourceService string) []PortRemap
 			SourceService: sourceService,
 		})
 	}
+	// Labels are a map, so order the remaps the same way on every call
+	sort.Slice(remaps, func(i, j int) bool { return remaps[i].Port < remaps[j].Port })
 	return remaps
 }
 
@@ -316,32 +591,57 @@ func (p *Provider) GetService(name string) (services.Service, error) {
 		containerName: name,
 		hostName:      p.hostName,
 		client:        p.client,
+		inspects:      p.inspects,
 	}, nil
 }
 
-// GetLogs streams logs for a specific container.
-func (p *Provider) GetLogs(ctx context.Context, containerName string, tailLines int, follow bool) (io.ReadCloser, error) {
-	options := container.LogsOptions{
-		ShowStdout: true,
-		ShowStderr: true,
-		Follow:     follow,
-		Tail:       fmt.Sprintf("%d", tailLines),
-		Timestamps: true,
+// ContainerNameByID returns the name of the container with the given full or
+// short ID. A container whose name happens to equal the ID is not matched.
+func (p *Provider) ContainerNameByID(ctx context.Context, id string) (string, error) {
+	inspect, err := p.inspects.inspect(ctx, p.client, id)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container %s: %w", id, err)
 	}
+	if inspect.ContainerJSONBase == nil || !strings.HasPrefix(inspect.ID, id) {
+		return "", fmt.Errorf("no container with ID %s", id)
+	}
+	return strings.TrimPrefix(inspect.Name, "/"), nil
+}
 
-	logs, err := p.client.ContainerLogs(ctx, containerName, options)
+// ContainerID returns the full ID of the container named name, or "" if no
+// container has that name. It always asks Docker rather than the inspect
+// cache, so a container recreated under the same name is seen at once.
+func (p *Provider) ContainerID(ctx context.Context, name string) (string, error) {
+	inspect, err := p.client.ContainerInspect(ctx, name)
+	if cerrdefs.IsNotFound(err) {
+		return "", nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get container logs: %w", err)
+		return "", fmt.Errorf("failed to inspect container %s: %w", name, err)
 	}
+	if inspect.ContainerJSONBase == nil {
+		return "", nil
+	}
+	return inspect.ID, nil
+}
+
+// GetLogs streams logs for a specific container. Containers using the
+// journald logging driver are read from the journal.
+func (p *Provider) GetLogs(ctx context.Context, containerName string, tailLines int, follow bool) (io.ReadCloser, error) {
+	return containerLogs(ctx, p.client, containerName, systemd.LogOptions{Tail: tailLines, Follow: follow})
+}
 
-	// Wrap with demultiplexer to strip Docker's 8-byte header
-	return &dockerLogReader{reader: bufio.NewReader(logs), closer: logs}, nil
+// GetLogsWithOptions streams logs for a specific container with the given
+// options. Boot and priority filters only apply to containers using the
+// journald logging driver.
+func (p *Provider) GetLogsWithOptions(ctx context.Context, containerName string, opts systemd.LogOptions) (io.ReadCloser, error) {
+	return containerLogs(ctx, p.client, containerName, opts)
 }
 
 // GetLogPath returns the path to the log file for a container.
 // Returns empty string if the container or log path cannot be found.
 func (p *Provider) GetLogPath(ctx context.Context, containerName string) (string, error) {
-	inspect, err := p.client.ContainerInspect(ctx, containerName)
+	inspect, err := p.inspects.inspect(ctx, p.client, containerName)
 	if err != nil {
 		return "", fmt.Errorf("failed to inspect container: %w", err)
 	}
@@ -351,11 +651,16 @@ func (p *Provider) GetLogPath(ctx context.Context, containerName string) (string
 // TruncateLogs truncates the log file for a container.
 // This clears all logs for the container.
 // Requires CAP_DAC_OVERRIDE capability to bypass file permissions.
+// Returns ErrJournaldLogs for containers using the journald logging driver.
 func (p *Provider) TruncateLogs(ctx context.Context, containerName string) error {
-	logPath, err := p.GetLogPath(ctx, containerName)
+	inspect, err := p.client.ContainerInspect(ctx, containerName)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to inspect container: %w", err)
 	}
+	if logDriver(inspect) == LogDriverJournald {
+		return fmt.Errorf("cannot flush logs of %s: %w", containerName, ErrJournaldLogs)
+	}
+	logPath := inspect.LogPath
 	if logPath == "" {
 		return fmt.Errorf("no log file found for container %s", containerName)
 	}
@@ -374,20 +679,22 @@ func (p *Provider) TruncateLogs(ctx context.Context, containerName string) error
 type DockerService struct {
 	containerName string
 	hostName      string
-	client        *client.Client
+	client        client.APIClient
+	inspects      *inspectCache // nil inspects without caching
 }
 
 // GetInfo returns the current status of the container.
 func (s *DockerService) GetInfo(ctx context.Context) (services.ServiceInfo, error) {
-	inspect, err := s.client.ContainerInspect(ctx, s.containerName)
+	inspect, err := s.inspects.inspect(ctx, s.client, s.containerName)
 	if err != nil {
 		return services.ServiceInfo{}, fmt.Errorf("failed to inspect container: %w", err)
 	}
 
-	state := "stopped"
-	if inspect.State.Running {
-		state = "running"
+	var health string
+	if inspect.State.Health != nil {
+		health = inspect.State.Health.Status
 	}
+	state := MapState(inspect.State.Status, health)
 
 	project := inspect.Config.Labels["com.docker.compose.project"]
 	service := inspect.Config.Labels["com.docker.compose.service"]
@@ -398,13 +705,20 @@ func (s *DockerService) GetInfo(ctx context.Context) (services.ServiceInfo, erro
 	// Extract custom description from label
 	description := inspect.Config.Labels[LabelDescription]
 
+	// Extract display name from label
+	displayName := strings.TrimSpace(inspect.Config.Labels[LabelDisplayName])
+
 	// Check if service should be hidden
 	hidden := isLabelTrue(inspect.Config.Labels[LabelHidden])
 
+	exitCode, finishedAt, exitErr := containerExit(inspect.State)
+
 	return services.ServiceInfo{
 		Name:          service,
+		DisplayName:   displayName,
 		Project:       project,
 		ContainerName: s.containerName,
+		ContainerID:   inspect.ID,
 		State:         state,
 		Status:        inspect.State.Status,
 		Image:         inspect.Image,
@@ -413,6 +727,13 @@ func (s *DockerService) GetInfo(ctx context.Context) (services.ServiceInfo, erro
 		Ports:         ports,
 		Description:   description,
 		Hidden:        hidden,
+		Drifted:       isLabelTrue(inspect.Config.Labels[LabelDrifted]),
+		StateSince:    containerStateSince(inspect.State),
+		StartedAt:     containerStartedAt(inspect.State),
+		ExitCode:      exitCode,
+		FinishedAt:    finishedAt,
+		ExitError:     exitErr,
+		LogSettings:   parseLogSettings(inspect.Config.Labels),
 	}, nil
 }
 
@@ -427,7 +748,7 @@ func extractPortsFromInspect(settings *container.NetworkSettings, labels map[str
 	// Parse hidden ports from comma-separated list
 	hiddenPorts := parseHiddenPorts(labels[LabelPortsHidden])
 
-	seen := make(map[string]bool)
+	seen := make(map[portKey]bool)
 	var result []services.PortInfo
 	for portProto, bindings := range settings.Ports {
 		for _, binding := range bindings {
@@ -441,7 +762,7 @@ func extractPortsFromInspect(settings *container.NetworkSettings, labels map[str
 				continue
 			}
 			// Deduplicate by host_port:protocol
-			key := fmt.Sprintf("%d:%s", hostPort, portProto.Proto())
+			key := portKey{hostPort, portProto.Proto()}
 			if seen[key] {
 				continue
 			}
@@ -485,34 +806,24 @@ func parsePort(s string) uint16 {
 
 // GetLogs returns a stream of logs for the container.
 func (s *DockerService) GetLogs(ctx context.Context, tailLines int, follow bool) (io.ReadCloser, error) {
-	options := container.LogsOptions{
-		ShowStdout: true,
-		ShowStderr: true,
-		Follow:     follow,
-		Tail:       fmt.Sprintf("%d", tailLines),
-		Timestamps: true,
-	}
-
-	logs, err := s.client.ContainerLogs(ctx, s.containerName, options)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get container logs: %w", err)
-	}
-
-	return &dockerLogReader{reader: bufio.NewReader(logs), closer: logs}, nil
+	return containerLogs(ctx, s.client, s.containerName, systemd.LogOptions{Tail: tailLines, Follow: follow})
 }
 
 // Start starts the container.
 func (s *DockerService) Start(ctx context.Context) error {
+	defer s.inspects.observe("", s.containerName, "")
 	return s.client.ContainerStart(ctx, s.containerName, container.StartOptions{})
 }
 
 // Stop stops the container.
 func (s *DockerService) Stop(ctx context.Context) error {
+	defer s.inspects.observe("", s.containerName, "")
 	return s.client.ContainerStop(ctx, s.containerName, container.StopOptions{})
 }
 
 // Restart restarts the container.
 func (s *DockerService) Restart(ctx context.Context) error {
+	defer s.inspects.observe("", s.containerName, "")
 	return s.client.ContainerRestart(ctx, s.containerName, container.StopOptions{})
 }
 
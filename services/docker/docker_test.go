@@ -4,10 +4,20 @@ package docker
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	cerrdefs "github.com/containerd/errdefs"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
 
 	"home_server_dashboard/services"
 )
@@ -691,6 +701,9 @@ func TestLabelConstants(t *testing.T) {
 	if LabelDescription != "home.server.dashboard.description" {
 		t.Errorf("LabelDescription = %q, want %q", LabelDescription, "home.server.dashboard.description")
 	}
+	if LabelDisplayName != "home.server.dashboard.name" {
+		t.Errorf("LabelDisplayName = %q, want %q", LabelDisplayName, "home.server.dashboard.name")
+	}
 	if LabelHidden != "home.server.dashboard.hidden" {
 		t.Errorf("LabelHidden = %q, want %q", LabelHidden, "home.server.dashboard.hidden")
 	}
@@ -703,6 +716,61 @@ func TestLabelConstants(t *testing.T) {
 	if LabelRemapPortPrefix != "home.server.dashboard.remapport" {
 		t.Errorf("LabelRemapPortPrefix = %q, want %q", LabelRemapPortPrefix, "home.server.dashboard.remapport")
 	}
+	if LabelURL != "home.server.dashboard.url" {
+		t.Errorf("LabelURL = %q, want %q", LabelURL, "home.server.dashboard.url")
+	}
+	if LabelTraefikIgnore != "home.server.dashboard.traefik.ignore" {
+		t.Errorf("LabelTraefikIgnore = %q, want %q", LabelTraefikIgnore, "home.server.dashboard.traefik.ignore")
+	}
+	if LabelLogsTail != "home.server.dashboard.logs.tail" {
+		t.Errorf("LabelLogsTail = %q, want %q", LabelLogsTail, "home.server.dashboard.logs.tail")
+	}
+	if LabelLogsTimestamps != "home.server.dashboard.logs.timestamps" {
+		t.Errorf("LabelLogsTimestamps = %q, want %q", LabelLogsTimestamps, "home.server.dashboard.logs.timestamps")
+	}
+}
+
+// TestParseLogSettings tests reading log viewer defaults from labels.
+func TestParseLogSettings(t *testing.T) {
+	tests := []struct {
+		name           string
+		labels         map[string]string
+		wantNil        bool
+		wantTail       int
+		wantTimestamps *bool
+	}{
+		{"no labels", nil, true, 0, nil},
+		{"unrelated labels", map[string]string{LabelIcon: "sonarr.png"}, true, 0, nil},
+		{"tail", map[string]string{LabelLogsTail: "500"}, false, 500, nil},
+		{"tail with spaces", map[string]string{LabelLogsTail: " 20 "}, false, 20, nil},
+		{"timestamps off", map[string]string{LabelLogsTimestamps: "false"}, false, 0, boolPtr(false)},
+		{"timestamps on", map[string]string{LabelLogsTimestamps: "Yes"}, false, 0, boolPtr(true)},
+		{"both", map[string]string{LabelLogsTail: "50000", LabelLogsTimestamps: "0"}, false, 50000, boolPtr(false)},
+		{"invalid tail ignored", map[string]string{LabelLogsTail: "lots"}, true, 0, nil},
+		{"zero tail ignored", map[string]string{LabelLogsTail: "0"}, true, 0, nil},
+		{"invalid timestamps ignored", map[string]string{LabelLogsTimestamps: "sometimes"}, true, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseLogSettings(tt.labels)
+			if tt.wantNil {
+				if got != nil {
+					t.Errorf("parseLogSettings() = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("parseLogSettings() = nil")
+			}
+			if got.Tail != tt.wantTail {
+				t.Errorf("Tail = %d, want %d", got.Tail, tt.wantTail)
+			}
+			if !reflect.DeepEqual(got.Timestamps, tt.wantTimestamps) {
+				t.Errorf("Timestamps = %v, want %v", got.Timestamps, tt.wantTimestamps)
+			}
+		})
+	}
 }
 
 // TestParsePortRemaps tests the parsePortRemaps function.
@@ -800,21 +868,15 @@ func TestParsePortRemaps(t *testing.T) {
 				return // Both are empty, test passes
 			}
 
-			// Check that all expected remaps are present (order may vary due to map iteration)
+			// Remaps come ordered by port, whatever the order of the labels
 			if len(result) != len(tt.expected) {
 				t.Fatalf("parsePortRemaps() returned %d remaps, want %d", len(result), len(tt.expected))
 			}
 
-			// Create a map for easier lookup
-			resultMap := make(map[uint16]PortRemap)
-			for _, r := range result {
-				resultMap[r.Port] = r
-			}
-
-			for _, exp := range tt.expected {
-				got, ok := resultMap[exp.Port]
-				if !ok {
-					t.Errorf("expected remap for port %d not found", exp.Port)
+			for i, exp := range tt.expected {
+				got := result[i]
+				if got.Port != exp.Port {
+					t.Errorf("remap %d: Port = %d, want %d", i, got.Port, exp.Port)
 					continue
 				}
 				if got.TargetService != exp.TargetService {
@@ -905,6 +967,29 @@ func TestExtractTraefikServiceName(t *testing.T) {
 	}
 }
 
+// TestParseURLLabel tests parsing of the url label.
+func TestParseURLLabel(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{"empty", "", nil},
+		{"single", "https://photos.example.com", []string{"https://photos.example.com"}},
+		{"multiple with spaces", "https://a.example.com, http://192.168.1.5:8080/admin", []string{"https://a.example.com", "http://192.168.1.5:8080/admin"}},
+		{"invalid entries skipped", "photos.example.com,ftp://files.example.com,,https://ok.example.com", []string{"https://ok.example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseURLLabel(tt.value)
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("parseURLLabel(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
 // TestGetPortURLProtocol tests the getPortURLProtocol helper function.
 func TestGetPortURLProtocol(t *testing.T) {
 	labels := map[string]string{
@@ -1054,3 +1139,274 @@ func TestExtractExposedPortsWithProtocolOverride(t *testing.T) {
 		})
 	}
 }
+
+func TestContainerStateSince(t *testing.T) {
+	started := "2025-03-01T12:00:00.123456789Z"
+	finished := "2025-03-02T08:30:00Z"
+
+	tests := []struct {
+		name  string
+		state *container.State
+		want  string // RFC 3339, empty for nil
+	}{
+		{"nil state", nil, ""},
+		{"running uses StartedAt", &container.State{Running: true, StartedAt: started, FinishedAt: finished}, started},
+		{"exited uses FinishedAt", &container.State{Running: false, StartedAt: started, FinishedAt: finished}, finished},
+		{"never finished", &container.State{Running: false, StartedAt: started, FinishedAt: "0001-01-01T00:00:00Z"}, ""},
+		{"unparseable", &container.State{Running: true, StartedAt: "garbage"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := containerStateSince(tt.state)
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("containerStateSince() = %v, want nil", got)
+				}
+				return
+			}
+			want, _ := time.Parse(time.RFC3339Nano, tt.want)
+			if got == nil || !got.Equal(want) {
+				t.Errorf("containerStateSince() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestContainerStartedAt(t *testing.T) {
+	started := "2025-03-01T12:00:00.123456789Z"
+
+	if got := containerStartedAt(nil); got != nil {
+		t.Errorf("containerStartedAt(nil) = %v, want nil", got)
+	}
+	// A stopped container still reports when it last started
+	got := containerStartedAt(&container.State{Running: false, StartedAt: started, FinishedAt: "2025-03-02T08:30:00Z"})
+	if want, _ := time.Parse(time.RFC3339Nano, started); got == nil || !got.Equal(want) {
+		t.Errorf("containerStartedAt() = %v, want %v", got, want)
+	}
+	if got := containerStartedAt(&container.State{Status: "created", StartedAt: "0001-01-01T00:00:00Z"}); got != nil {
+		t.Errorf("containerStartedAt() of a created container = %v, want nil", got)
+	}
+}
+
+// stateInspector inspects every container with the same state.
+type stateInspector struct {
+	client.APIClient
+	state *container.State
+}
+
+func (f *stateInspector) ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error) {
+	return container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{ID: containerID, Name: "/" + containerID, State: f.state},
+		Config:            &container.Config{Labels: map[string]string{"com.docker.compose.service": "web"}},
+	}, nil
+}
+
+func TestDockerService_GetInfoTimestamps(t *testing.T) {
+	const started, finished = "2025-03-01T12:00:00Z", "2025-03-02T08:30:00Z"
+	never := "0001-01-01T00:00:00Z"
+
+	tests := []struct {
+		name                                 string
+		state                                *container.State
+		wantStarted, wantSince, wantFinished string // RFC 3339, empty for absent
+	}{
+		{"running", &container.State{Status: "running", Running: true, StartedAt: started, FinishedAt: never}, started, started, ""},
+		{"exited", &container.State{Status: "exited", StartedAt: started, FinishedAt: finished}, started, finished, finished},
+		{"never started", &container.State{Status: "created", StartedAt: never, FinishedAt: never}, "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &DockerService{containerName: "web", hostName: "nas", client: &stateInspector{state: tt.state}}
+			info, err := svc.GetInfo(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := json.Marshal(info)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fields map[string]any
+			json.Unmarshal(data, &fields)
+			for key, want := range map[string]string{"started_at": tt.wantStarted, "state_since": tt.wantSince, "finished_at": tt.wantFinished} {
+				got, ok := fields[key]
+				if want == "" {
+					if ok {
+						t.Errorf("%s = %v, want it omitted", key, got)
+					}
+					continue
+				}
+				if got != want {
+					t.Errorf("%s = %v, want %s", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseExitCode(t *testing.T) {
+	tests := []struct {
+		status string
+		want   int // -1 for nil
+	}{
+		{"Exited (0) 5 seconds ago", 0},
+		{"Exited (137) 2 hours ago", 137},
+		{"Exited (1) About a minute ago", 1},
+		{"Exited (255) 3 weeks ago", 255},
+		{"Exited (143) Less than a second ago", 143},
+		{"  Exited (2) 10 minutes ago", 2},
+		{"Up 3 hours", -1},
+		{"Up 2 minutes (healthy)", -1},
+		{"Up 5 seconds (Paused)", -1},
+		{"Restarting (1) 4 seconds ago", -1},
+		{"Created", -1},
+		{"Dead", -1},
+		{"Exited", -1},
+		{"Exited (abc) 1 hour ago", -1},
+		{"", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			got := parseExitCode(tt.status)
+			if tt.want < 0 {
+				if got != nil {
+					t.Errorf("parseExitCode(%q) = %d, want nil", tt.status, *got)
+				}
+				return
+			}
+			if got == nil || *got != tt.want {
+				t.Errorf("parseExitCode(%q) = %v, want %d", tt.status, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerExit(t *testing.T) {
+	finished := "2025-03-02T08:30:00Z"
+
+	code, at, exitErr := containerExit(&container.State{ExitCode: 137, FinishedAt: finished, Error: "OOMKilled"})
+	if code == nil || *code != 137 || at == nil || at.Format(time.RFC3339) != finished || exitErr != "OOMKilled" {
+		t.Errorf("exited container = %v, %v, %q", code, at, exitErr)
+	}
+
+	code, at, _ = containerExit(&container.State{ExitCode: 0, FinishedAt: finished})
+	if code == nil || *code != 0 || at == nil {
+		t.Errorf("clean exit = %v, %v, want code 0", code, at)
+	}
+
+	for name, state := range map[string]*container.State{
+		"nil":           nil,
+		"running":       {Running: true, FinishedAt: finished, ExitCode: 1},
+		"never started": {FinishedAt: "0001-01-01T00:00:00Z"},
+	} {
+		if code, at, exitErr := containerExit(state); code != nil || at != nil || exitErr != "" {
+			t.Errorf("%s container = %v, %v, %q, want nothing", name, code, at, exitErr)
+		}
+	}
+}
+
+// benchmarkPorts returns n published ports as Docker lists them, each bound on
+// both IPv4 and IPv6, with labels customizing a few of them.
+func benchmarkPorts(n int) ([]container.Port, map[string]string) {
+	var ports []container.Port
+	for i := 0; i < n; i++ {
+		port := uint16(8000 + i)
+		ports = append(ports,
+			container.Port{IP: "0.0.0.0", PrivatePort: port, PublicPort: port, Type: "tcp"},
+			container.Port{IP: "::", PrivatePort: port, PublicPort: port, Type: "tcp"},
+		)
+	}
+	labels := map[string]string{
+		"com.docker.compose.project":                         "media",
+		"com.docker.compose.service":                         "app",
+		LabelPortsHidden:                                     "8001,8002",
+		LabelPortsPrefix + ".8000.label":                     "Web UI",
+		LabelPortsPrefix + ".8003.hidden":                    "true",
+		LabelPortsPrefix + ".8004.protocol":                  "https",
+		"org.opencontainers.image.title":                     "app",
+		"org.opencontainers.image.version":                   "1.0",
+		"traefik.http.routers.app.rule":                      "Host(`app.example.com`)",
+		"traefik.http.services.app.loadbalancer.server.port": "8000",
+	}
+	return ports, labels
+}
+
+// BenchmarkExtractExposedPorts benchmarks port extraction for a container
+// publishing 50 ports. With label keys built without fmt and a struct dedup
+// key this runs at under 10 allocs/op, down from over 600; a result well
+// above that is a regression.
+func BenchmarkExtractExposedPorts(b *testing.B) {
+	ports, labels := benchmarkPorts(50)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		extractExposedPorts(ports, labels)
+	}
+}
+
+// TestGetServices_ListError tests that a Docker daemon that can't list its
+// containers is reported as an error rather than as no services.
+func TestGetServices_ListError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "daemon is unavailable"}`, http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(server.URL, "http://")), client.WithVersion("1.43"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Provider{hostName: "nas", client: cli, images: newImageCache()}
+
+	svcs, err := p.GetServices(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "daemon is unavailable") {
+		t.Errorf("GetServices() = %v, %v, want the daemon's error", svcs, err)
+	}
+	if _, _, err := p.GetServicesWithRemaps(context.Background()); err == nil {
+		t.Error("GetServicesWithRemaps() = nil error, want the daemon's error")
+	}
+}
+
+// namedContainers inspects the containers it has by name, like Docker after
+// a name was reused by a new container.
+type namedContainers struct {
+	client.APIClient
+	ids map[string]string // name -> ID
+}
+
+func (f *namedContainers) ContainerInspect(ctx context.Context, name string) (container.InspectResponse, error) {
+	id, ok := f.ids[name]
+	if !ok {
+		return container.InspectResponse{}, fmt.Errorf("No such container: %s: %w", name, cerrdefs.ErrNotFound)
+	}
+	return container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{ID: id, Name: "/" + name, State: &container.State{Status: "running"}},
+		Config:            &container.Config{},
+	}, nil
+}
+
+func TestProvider_ContainerID(t *testing.T) {
+	cli := &namedContainers{ids: map[string]string{"db": "1111"}}
+	p := NewProviderWithClient("nas", cli)
+
+	if id, err := p.ContainerID(context.Background(), "db"); err != nil || id != "1111" {
+		t.Errorf("ContainerID(db) = %q, %v; want 1111", id, err)
+	}
+
+	// The old container is removed and a new one takes the name; the
+	// cached inspect of the old one must not hide it
+	svc, _ := p.GetService("db")
+	svc.GetInfo(context.Background())
+	cli.ids["db"] = "2222"
+	if id, err := p.ContainerID(context.Background(), "db"); err != nil || id != "2222" {
+		t.Errorf("ContainerID(db) after the name was reused = %q, %v; want 2222", id, err)
+	}
+
+	if id, err := p.ContainerID(context.Background(), "gone"); err != nil || id != "" {
+		t.Errorf("ContainerID(gone) = %q, %v; want no container and no error", id, err)
+	}
+}
@@ -0,0 +1,158 @@
+package docker
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+
+	"home_server_dashboard/services"
+)
+
+// imageInspector is the subset of the Docker client used to look up image metadata.
+type imageInspector interface {
+	ImageInspect(ctx context.Context, imageID string, inspectOpts ...client.ImageInspectOption) (image.InspectResponse, error)
+}
+
+// imageMeta holds the image details shown next to a container.
+type imageMeta struct {
+	Created  *time.Time        // When the image was built (nil if unknown)
+	Digest   string            // Registry digest (e.g., "sha256:abc..."), empty for locally built images
+	Platform services.Platform // Platform the image was built for
+}
+
+// imageCache caches image metadata by image ID so that containers sharing an
+// image cause a single ImageInspect per collection cycle. Image IDs are content
+// addressed, so an entry never goes stale; entries are dropped when a container
+// moves to a different image ID or no container references them any more.
+type imageCache struct {
+	mu         sync.Mutex
+	entries    map[string]imageMeta // image ID -> metadata
+	containers map[string]string    // container ID -> image ID
+}
+
+// newImageCache creates an empty image cache.
+func newImageCache() *imageCache {
+	return &imageCache{
+		entries:    make(map[string]imageMeta),
+		containers: make(map[string]string),
+	}
+}
+
+// imageCaches holds one image cache per host so that the short-lived
+// providers created for each request share inspect results.
+var (
+	imageCachesMu sync.Mutex
+	imageCaches   = make(map[string]*imageCache)
+)
+
+// imageCacheFor returns the shared image cache for a host.
+func imageCacheFor(hostName string) *imageCache {
+	imageCachesMu.Lock()
+	defer imageCachesMu.Unlock()
+
+	cache, ok := imageCaches[hostName]
+	if !ok {
+		cache = newImageCache()
+		imageCaches[hostName] = cache
+	}
+	return cache
+}
+
+// InvalidateImageCache drops the cached metadata of an image on a host, or
+// of every image on it if imageID is empty, so it is inspected again on the
+// next collection. It is called when images are pulled or removed outside
+// the dashboard.
+func InvalidateImageCache(hostName, imageID string) {
+	imageCacheFor(hostName).invalidate(imageID)
+}
+
+// invalidate drops the entry of imageID, or every entry if it is empty.
+func (c *imageCache) invalidate(imageID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if imageID == "" {
+		c.entries = make(map[string]imageMeta)
+		return
+	}
+	delete(c.entries, imageID)
+}
+
+// lookup returns metadata for every image used by the given containers
+// (container ID -> image ID), inspecting each unknown image ID once.
+// Images that fail to inspect are omitted and retried on the next call.
+func (c *imageCache) lookup(ctx context.Context, inspector imageInspector, containerImages map[string]string) map[string]imageMeta {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Invalidate entries for containers whose image changed (e.g., after a pull and recreate)
+	for containerID, imageID := range containerImages {
+		if previous, ok := c.containers[containerID]; ok && previous != imageID {
+			delete(c.entries, previous)
+		}
+	}
+	c.containers = make(map[string]string, len(containerImages))
+	for containerID, imageID := range containerImages {
+		c.containers[containerID] = imageID
+	}
+
+	result := make(map[string]imageMeta)
+	seen := make(map[string]bool)
+	for _, imageID := range containerImages {
+		if imageID == "" || seen[imageID] {
+			continue
+		}
+		seen[imageID] = true
+		meta, ok := c.entries[imageID]
+		if !ok {
+			inspect, err := inspector.ImageInspect(ctx, imageID)
+			if err != nil {
+				continue
+			}
+			meta = imageMetaFromInspect(inspect)
+			c.entries[imageID] = meta
+		}
+		result[imageID] = meta
+	}
+
+	// Drop images no container uses any more
+	for imageID := range c.entries {
+		if _, used := result[imageID]; !used {
+			delete(c.entries, imageID)
+		}
+	}
+
+	return result
+}
+
+// imageMetaFromInspect extracts the build time, registry digest and
+// platform from an image inspect.
+func imageMetaFromInspect(inspect image.InspectResponse) imageMeta {
+	meta := imageMeta{Platform: services.NewPlatform(inspect.Os, inspect.Architecture, inspect.Variant)}
+
+	if created, err := time.Parse(time.RFC3339Nano, inspect.Created); err == nil && !created.IsZero() {
+		meta.Created = &created
+	}
+
+	// RepoDigests look like "nginx@sha256:abc..."; the part after @ is the digest
+	if len(inspect.RepoDigests) > 0 {
+		if _, digest, ok := strings.Cut(inspect.RepoDigests[0], "@"); ok {
+			meta.Digest = digest
+		}
+	}
+
+	return meta
+}
+
+// isImageStale reports whether an image built at created is older than threshold.
+// A zero threshold or unknown build time is never stale.
+func isImageStale(created *time.Time, threshold time.Duration, now time.Time) bool {
+	if created == nil || threshold <= 0 {
+		return false
+	}
+	return now.Sub(*created) > threshold
+}
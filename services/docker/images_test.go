@@ -0,0 +1,169 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+)
+
+// fakeImageInspector returns canned image inspects and counts calls per image ID.
+type fakeImageInspector struct {
+	images map[string]image.InspectResponse
+	calls  map[string]int
+}
+
+func newFakeImageInspector(images map[string]image.InspectResponse) *fakeImageInspector {
+	return &fakeImageInspector{images: images, calls: make(map[string]int)}
+}
+
+func (f *fakeImageInspector) ImageInspect(ctx context.Context, imageID string, inspectOpts ...client.ImageInspectOption) (image.InspectResponse, error) {
+	f.calls[imageID]++
+	inspect, ok := f.images[imageID]
+	if !ok {
+		return image.InspectResponse{}, errors.New("no such image")
+	}
+	return inspect, nil
+}
+
+func (f *fakeImageInspector) totalCalls() int {
+	total := 0
+	for _, n := range f.calls {
+		total += n
+	}
+	return total
+}
+
+func TestImageCacheBatchesInspects(t *testing.T) {
+	inspector := newFakeImageInspector(map[string]image.InspectResponse{
+		"sha256:nginx": {Created: "2024-01-02T03:04:05.123456789Z", RepoDigests: []string{"nginx@sha256:abc"}},
+		"sha256:redis": {Created: "2024-06-01T00:00:00Z"},
+	})
+	cache := newImageCache()
+
+	containers := map[string]string{"c1": "sha256:redis"}
+	for i := 0; i < 10; i++ {
+		containers["nginx-"+string(rune('a'+i))] = "sha256:nginx"
+	}
+
+	result := cache.lookup(context.Background(), inspector, containers)
+	if got := inspector.totalCalls(); got != 2 {
+		t.Errorf("ImageInspect calls = %d, want 2 (one per unique image)", got)
+	}
+
+	nginx := result["sha256:nginx"]
+	if nginx.Digest != "sha256:abc" {
+		t.Errorf("nginx Digest = %q, want %q", nginx.Digest, "sha256:abc")
+	}
+	if nginx.Created == nil || !nginx.Created.Equal(time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)) {
+		t.Errorf("nginx Created = %v, want 2024-01-02T03:04:05.123456789Z", nginx.Created)
+	}
+	if result["sha256:redis"].Digest != "" {
+		t.Errorf("locally built image should have no digest, got %q", result["sha256:redis"].Digest)
+	}
+
+	// A second cycle with the same images is served from the cache
+	cache.lookup(context.Background(), inspector, containers)
+	if got := inspector.totalCalls(); got != 2 {
+		t.Errorf("ImageInspect calls after second cycle = %d, want 2", got)
+	}
+}
+
+func TestImageCacheInvalidatesOnImageChange(t *testing.T) {
+	inspector := newFakeImageInspector(map[string]image.InspectResponse{
+		"sha256:old": {Created: "2023-01-01T00:00:00Z"},
+		"sha256:new": {Created: "2025-01-01T00:00:00Z"},
+	})
+	cache := newImageCache()
+
+	cache.lookup(context.Background(), inspector, map[string]string{"c1": "sha256:old"})
+	result := cache.lookup(context.Background(), inspector, map[string]string{"c1": "sha256:new"})
+
+	if inspector.calls["sha256:new"] != 1 {
+		t.Errorf("new image inspects = %d, want 1", inspector.calls["sha256:new"])
+	}
+	if _, ok := cache.entries["sha256:old"]; ok {
+		t.Error("old image should be evicted after the container moved to a new image")
+	}
+	if created := result["sha256:new"].Created; created == nil || created.Year() != 2025 {
+		t.Errorf("new image Created = %v, want 2025", created)
+	}
+
+	// Switching back must inspect the old image again
+	cache.lookup(context.Background(), inspector, map[string]string{"c1": "sha256:old"})
+	if inspector.calls["sha256:old"] != 2 {
+		t.Errorf("old image inspects = %d, want 2", inspector.calls["sha256:old"])
+	}
+}
+
+func TestImageCacheRetriesFailedInspects(t *testing.T) {
+	inspector := newFakeImageInspector(nil)
+	cache := newImageCache()
+	containers := map[string]string{"c1": "sha256:missing", "c2": "sha256:missing"}
+
+	result := cache.lookup(context.Background(), inspector, containers)
+	if _, ok := result["sha256:missing"]; ok {
+		t.Error("failed inspect should not produce metadata")
+	}
+	cache.lookup(context.Background(), inspector, containers)
+	if inspector.calls["sha256:missing"] != 2 {
+		t.Errorf("inspects = %d, want 2 (one per cycle)", inspector.calls["sha256:missing"])
+	}
+}
+
+func TestInvalidateImageCache(t *testing.T) {
+	inspector := newFakeImageInspector(map[string]image.InspectResponse{
+		"sha256:nginx": {Created: "2024-01-01T00:00:00Z"},
+		"sha256:redis": {Created: "2024-06-01T00:00:00Z"},
+	})
+	containers := map[string]string{"c1": "sha256:nginx", "c2": "sha256:redis"}
+	cache := imageCacheFor("invalidate-test")
+	cache.lookup(context.Background(), inspector, containers)
+
+	// A removed image is inspected again, the others are still cached
+	InvalidateImageCache("invalidate-test", "sha256:nginx")
+	cache.lookup(context.Background(), inspector, containers)
+	if inspector.calls["sha256:nginx"] != 2 || inspector.calls["sha256:redis"] != 1 {
+		t.Errorf("inspects = %v, want nginx again and redis once", inspector.calls)
+	}
+
+	// Without an ID every image is
+	InvalidateImageCache("invalidate-test", "")
+	cache.lookup(context.Background(), inspector, containers)
+	if inspector.calls["sha256:nginx"] != 3 || inspector.calls["sha256:redis"] != 2 {
+		t.Errorf("inspects = %v, want both again", inspector.calls)
+	}
+}
+
+func TestIsImageStale(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	threshold := 180 * 24 * time.Hour
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(-d)
+		return &t
+	}
+
+	tests := []struct {
+		name      string
+		created   *time.Time
+		threshold time.Duration
+		want      bool
+	}{
+		{"unknown build time", nil, threshold, false},
+		{"fresh image", at(24 * time.Hour), threshold, false},
+		{"exactly at threshold", at(threshold), threshold, false},
+		{"just past threshold", at(threshold + time.Second), threshold, true},
+		{"disabled threshold", at(1000 * 24 * time.Hour), 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isImageStale(tt.created, tt.threshold, now); got != tt.want {
+				t.Errorf("isImageStale() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package docker
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"golang.org/x/sync/singleflight"
+)
+
+// inspectTTL is how long a container inspect is reused. The monitor's
+// container events invalidate inspects as soon as a container changes, so
+// this only bounds how stale one can get when no events are watched.
+const inspectTTL = 3 * time.Second
+
+// inspectTimeout bounds an inspect shared by several callers, which no
+// longer ends with the request of the caller that started it.
+const inspectTimeout = 10 * time.Second
+
+// containerInspector is the subset of the Docker client used to inspect containers.
+type containerInspector interface {
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+}
+
+// eventStatus is the container status implied by the container events that
+// change it.
+var eventStatus = map[string]string{
+	"start":   "running",
+	"restart": "running",
+	"unpause": "running",
+	"pause":   "paused",
+	"die":     "exited",
+	"stop":    "exited",
+}
+
+// InspectStats counts how container inspects were answered.
+type InspectStats struct {
+	Hits   uint64 `json:"hits"`   // Answered from the cache or by an inspect already running
+	Misses uint64 `json:"misses"` // Inspects made against Docker
+}
+
+// inspectCache reuses container inspects for inspectTTL, so the service
+// list, the service details and the log and health enrichment inspecting the
+// same container cause one call to Docker. Concurrent inspects of a container
+// share one call. Entries are keyed by the name or ID they were asked for.
+type inspectCache struct {
+	ttl   time.Duration
+	now   func() time.Time
+	group singleflight.Group
+
+	mu       sync.Mutex
+	entries  map[string]inspectEntry // container name or ID -> inspect
+	expected map[string]expectedStatus
+	// gen is bumped by every invalidation, so inspects that were running
+	// when a container changed are neither stored nor shared afterwards
+	gen uint64
+
+	hits, misses atomic.Uint64
+}
+
+// inspectEntry is a cached inspect.
+type inspectEntry struct {
+	inspect container.InspectResponse
+	expires time.Time
+}
+
+// expectedStatus is the status a container's last event implies, checked
+// against inspects cached until it expires.
+type expectedStatus struct {
+	status  string
+	expires time.Time
+}
+
+// newInspectCache creates an empty inspect cache.
+func newInspectCache() *inspectCache {
+	return &inspectCache{
+		ttl:      inspectTTL,
+		now:      time.Now,
+		entries:  make(map[string]inspectEntry),
+		expected: make(map[string]expectedStatus),
+	}
+}
+
+// inspectCaches holds one inspect cache per host so that the short-lived
+// providers created for each request share inspect results.
+var (
+	inspectCachesMu sync.Mutex
+	inspectCaches   = make(map[string]*inspectCache)
+)
+
+// inspectCacheFor returns the shared inspect cache for a host.
+func inspectCacheFor(hostName string) *inspectCache {
+	inspectCachesMu.Lock()
+	defer inspectCachesMu.Unlock()
+
+	cache, ok := inspectCaches[hostName]
+	if !ok {
+		cache = newInspectCache()
+		inspectCaches[hostName] = cache
+	}
+	return cache
+}
+
+// ObserveContainerEvent drops the cached inspect of a container on a host
+// when an event reports it changed, e.g. "start" or "die". The event's
+// container ID and name are both matched, since inspects are cached by the
+// reference they were asked for.
+func ObserveContainerEvent(hostName, containerID, name, action string) {
+	inspectCacheFor(hostName).observe(containerID, name, action)
+}
+
+// InvalidateInspectCache drops every cached inspect on a host.
+func InvalidateInspectCache(hostName string) {
+	inspectCacheFor(hostName).observe("", "", "")
+}
+
+// InspectCacheStats returns how the container inspects on a host were answered.
+func InspectCacheStats(hostName string) InspectStats {
+	c := inspectCacheFor(hostName)
+	return InspectStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// AllInspectCacheStats returns how the container inspects were answered on
+// every host that has inspected containers, keyed by host.
+func AllInspectCacheStats() map[string]InspectStats {
+	inspectCachesMu.Lock()
+	defer inspectCachesMu.Unlock()
+
+	stats := make(map[string]InspectStats, len(inspectCaches))
+	for host, c := range inspectCaches {
+		stats[host] = InspectStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+	}
+	return stats
+}
+
+// inspect returns the inspect of the container ref, a name or an ID, from
+// the cache or from cli. A nil cache always asks cli.
+func (c *inspectCache) inspect(ctx context.Context, cli containerInspector, ref string) (container.InspectResponse, error) {
+	if c == nil {
+		return cli.ContainerInspect(ctx, ref)
+	}
+
+	c.mu.Lock()
+	inspect, ok := c.lookup(ref)
+	gen := c.gen
+	c.mu.Unlock()
+	if ok {
+		c.hits.Add(1)
+		return inspect, nil
+	}
+
+	key := strconv.FormatUint(gen, 10) + "/" + ref
+	result, shared, err := c.await(ctx, key, func() (any, error) {
+		c.misses.Add(1)
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), inspectTimeout)
+		defer cancel()
+		inspect, err := cli.ContainerInspect(fetchCtx, ref)
+		if err == nil {
+			c.store(ref, inspect, gen)
+		}
+		return inspect, err
+	})
+	if shared {
+		c.hits.Add(1)
+	}
+	if err != nil {
+		return container.InspectResponse{}, err
+	}
+	return result.(container.InspectResponse), nil
+}
+
+// await runs fn once for every caller asking for key at the same time, and
+// waits for it or for ctx. shared reports whether another caller ran fn.
+func (c *inspectCache) await(ctx context.Context, key string, fn func() (any, error)) (any, bool, error) {
+	var ran atomic.Bool
+	ch := c.group.DoChan(key, func() (any, error) {
+		ran.Store(true)
+		return fn()
+	})
+	select {
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	case res := <-ch:
+		return res.Val, !ran.Load(), res.Err
+	}
+}
+
+// lookup returns the live entry for ref. An entry contradicting the status
+// implied by the container's last event is treated as missing. The caller
+// holds c.mu.
+func (c *inspectCache) lookup(ref string) (container.InspectResponse, bool) {
+	entry, ok := c.entries[ref]
+	if !ok {
+		return container.InspectResponse{}, false
+	}
+	now := c.now()
+	if !now.Before(entry.expires) {
+		delete(c.entries, ref)
+		return container.InspectResponse{}, false
+	}
+	if base := entry.inspect.ContainerJSONBase; base != nil && base.State != nil {
+		if want, ok := c.expected[base.ID]; ok && now.Before(want.expires) && base.State.Status != want.status {
+			delete(c.entries, ref)
+			return container.InspectResponse{}, false
+		}
+	}
+	return entry.inspect, true
+}
+
+// store caches the inspect of ref, unless the cache was invalidated since
+// the inspect started at generation gen.
+func (c *inspectCache) store(ref string, inspect container.InspectResponse, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen != gen {
+		return
+	}
+	c.entries[ref] = inspectEntry{inspect: inspect, expires: c.now().Add(c.ttl)}
+}
+
+// observe drops the entries of the container with the given ID or name, or
+// every entry if both are empty, and remembers the status action implies.
+// It does nothing on a nil cache.
+func (c *inspectCache) observe(id, name, action string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	now := c.now()
+	for key, want := range c.expected {
+		if !now.Before(want.expires) {
+			delete(c.expected, key)
+		}
+	}
+	if id == "" && name == "" {
+		c.entries = make(map[string]inspectEntry)
+		return
+	}
+	if status, ok := eventStatus[action]; ok && id != "" {
+		c.expected[id] = expectedStatus{status: status, expires: now.Add(c.ttl)}
+	}
+	for ref, entry := range c.entries {
+		base := entry.inspect.ContainerJSONBase
+		if ref == id || ref == name || (base != nil && ((id != "" && base.ID == id) || (name != "" && base.Name == "/"+name))) {
+			delete(c.entries, ref)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package docker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// countingDocker answers inspects of one container with its current status
+// and counts them. With gate set, inspects wait until it is closed.
+type countingDocker struct {
+	client.APIClient
+
+	calls  atomic.Int32
+	gate   chan struct{}
+	status atomic.Value // string
+}
+
+func newCountingDocker(status string) *countingDocker {
+	d := &countingDocker{}
+	d.status.Store(status)
+	return d
+}
+
+func (d *countingDocker) ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error) {
+	d.calls.Add(1)
+	status := d.status.Load().(string)
+	if d.gate != nil {
+		select {
+		case <-d.gate:
+		case <-ctx.Done():
+			return container.InspectResponse{}, ctx.Err()
+		}
+	}
+	return container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			ID:    "abc123",
+			Name:  "/web",
+			State: &container.State{Status: status, Running: status == "running"},
+		},
+		Config: &container.Config{Labels: map[string]string{"com.docker.compose.service": "web"}},
+	}, nil
+}
+
+func TestInspectCache_ParallelGetInfoInspectsOnce(t *testing.T) {
+	cli := newCountingDocker("running")
+	cli.gate = make(chan struct{})
+	cache := newInspectCache()
+	svc := &DockerService{containerName: "web", hostName: "test", client: cli, inspects: cache}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info, err := svc.GetInfo(context.Background())
+			if err == nil && info.State != "running" {
+				t.Errorf("State = %q, want running", info.State)
+			}
+			errs <- err
+		}()
+	}
+
+	// Let every caller join the inspect the first one started
+	for cli.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(cli.gate)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("GetInfo() = %v", err)
+		}
+	}
+
+	if calls := cli.calls.Load(); calls != 1 {
+		t.Errorf("ContainerInspect called %d times, want 1", calls)
+	}
+	if _, err := svc.GetInfo(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls := cli.calls.Load(); calls != 1 {
+		t.Errorf("ContainerInspect called %d times after a cached GetInfo, want 1", calls)
+	}
+	if hits, misses := cache.hits.Load(), cache.misses.Load(); hits != callers || misses != 1 {
+		t.Errorf("hits, misses = %d, %d, want %d, 1", hits, misses, callers)
+	}
+}
+
+func TestInspectCache_EventInvalidates(t *testing.T) {
+	tests := []struct {
+		name   string
+		id     string
+		ref    string
+		action string
+	}{
+		{"by ID", "abc123", "", "die"},
+		{"by name", "", "web", "stop"},
+		{"health status", "abc123", "web", "health_status: unhealthy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := newCountingDocker("running")
+			cache := newInspectCache()
+			ctx := context.Background()
+
+			if _, err := cache.inspect(ctx, cli, "web"); err != nil {
+				t.Fatal(err)
+			}
+			cli.status.Store("exited")
+			if inspect, _ := cache.inspect(ctx, cli, "web"); inspect.State.Status != "running" {
+				t.Fatalf("Status = %q before the event, want the cached running", inspect.State.Status)
+			}
+
+			cache.observe(tt.id, tt.ref, tt.action)
+			inspect, err := cache.inspect(ctx, cli, "web")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if inspect.State.Status != "exited" {
+				t.Errorf("Status = %q after the event, want exited", inspect.State.Status)
+			}
+			if calls := cli.calls.Load(); calls != 2 {
+				t.Errorf("ContainerInspect called %d times, want 2", calls)
+			}
+		})
+	}
+}
+
+func TestInspectCache_OtherContainerEventKeepsEntry(t *testing.T) {
+	cli := newCountingDocker("running")
+	cache := newInspectCache()
+	ctx := context.Background()
+
+	cache.inspect(ctx, cli, "web")
+	cache.observe("def456", "db", "die")
+	cache.inspect(ctx, cli, "web")
+	if calls := cli.calls.Load(); calls != 1 {
+		t.Errorf("ContainerInspect called %d times, want 1", calls)
+	}
+}
+
+func TestInspectCache_InspectRunningAcrossEventIsNotCached(t *testing.T) {
+	cli := newCountingDocker("running")
+	cli.gate = make(chan struct{})
+	cache := newInspectCache()
+	ctx := context.Background()
+
+	done := make(chan container.InspectResponse)
+	go func() {
+		inspect, _ := cache.inspect(ctx, cli, "web")
+		done <- inspect
+	}()
+	for cli.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The container stops while it is being inspected
+	cli.status.Store("exited")
+	cache.observe("abc123", "web", "die")
+	close(cli.gate)
+	<-done
+
+	inspect, err := cache.inspect(ctx, cli, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inspect.State.Status != "exited" {
+		t.Errorf("Status = %q, want exited", inspect.State.Status)
+	}
+	if calls := cli.calls.Load(); calls != 2 {
+		t.Errorf("ContainerInspect called %d times, want 2", calls)
+	}
+}
+
+func TestInspectCache_StateContradictingEventIsMissed(t *testing.T) {
+	cache := newInspectCache()
+	cache.observe("abc123", "web", "die")
+
+	// An inspect from before the stop that got stored anyway
+	cli := newCountingDocker("running")
+	stale, _ := cli.ContainerInspect(context.Background(), "web")
+	cache.store("web", stale, cache.gen)
+
+	if _, ok := cache.lookup("web"); ok {
+		t.Error("lookup() found a running container the last event stopped")
+	}
+}
+
+func TestInspectCache_Expires(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cache := newInspectCache()
+	cache.now = func() time.Time { return now }
+	cli := newCountingDocker("running")
+	ctx := context.Background()
+
+	cache.inspect(ctx, cli, "web")
+	now = now.Add(inspectTTL - time.Millisecond)
+	cache.inspect(ctx, cli, "web")
+	if calls := cli.calls.Load(); calls != 1 {
+		t.Fatalf("ContainerInspect called %d times within the TTL, want 1", calls)
+	}
+	now = now.Add(time.Millisecond)
+	cache.inspect(ctx, cli, "web")
+	if calls := cli.calls.Load(); calls != 2 {
+		t.Errorf("ContainerInspect called %d times after the TTL, want 2", calls)
+	}
+}
+
+func TestDockerService_ActionInvalidatesInspect(t *testing.T) {
+	cli := &startingDocker{countingDocker: newCountingDocker("exited")}
+	cache := newInspectCache()
+	svc := &DockerService{containerName: "web", hostName: "test", client: cli, inspects: cache}
+	ctx := context.Background()
+
+	svc.GetInfo(ctx)
+	if err := svc.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	info, err := svc.GetInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State != "running" {
+		t.Errorf("State = %q after Start, want running", info.State)
+	}
+}
+
+// startingDocker is a countingDocker whose container can be started.
+type startingDocker struct {
+	*countingDocker
+}
+
+func (d *startingDocker) ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error {
+	d.status.Store("running")
+	return nil
+}
+
+func TestAllInspectCacheStats(t *testing.T) {
+	cli := newCountingDocker("running")
+	ctx := context.Background()
+	cache := inspectCacheFor("stats-host")
+	before := AllInspectCacheStats()["stats-host"]
+
+	cache.inspect(ctx, cli, "web")
+	cache.inspect(ctx, cli, "web")
+
+	got := AllInspectCacheStats()["stats-host"]
+	if got.Misses-before.Misses != 1 || got.Hits-before.Hits != 1 {
+		t.Errorf("stats = %+v (from %+v), want one more hit and miss", got, before)
+	}
+	if got != InspectCacheStats("stats-host") {
+		t.Errorf("AllInspectCacheStats = %+v, InspectCacheStats = %+v", got, InspectCacheStats("stats-host"))
+	}
+}
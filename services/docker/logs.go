@@ -0,0 +1,85 @@
+package docker
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+
+	"home_server_dashboard/services/systemd"
+)
+
+// LogDriverJournald is the logging driver that writes container output to the
+// systemd journal instead of a json-file log.
+const LogDriverJournald = "journald"
+
+// ErrJournaldLogs is returned when flushing the logs of a container that uses
+// the journald logging driver; those logs can only be removed with a journal vacuum.
+var ErrJournaldLogs = errors.New("journald-managed container, use journal vacuum (journalctl --vacuum-time or --vacuum-size) instead")
+
+// containerLogReader is the subset of the Docker client used to read container logs.
+type containerLogReader interface {
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+	ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error)
+}
+
+// journalLogs reads a container's logs from the local journal.
+var journalLogs = systemd.ContainerLogs
+
+// logDriver returns the logging driver of an inspected container, or "" if unknown.
+func logDriver(inspect container.InspectResponse) string {
+	if inspect.ContainerJSONBase == nil || inspect.HostConfig == nil {
+		return ""
+	}
+	return inspect.HostConfig.LogConfig.Type
+}
+
+// createdAfter reports whether an inspected container was created after t.
+func createdAfter(inspect container.InspectResponse, t time.Time) bool {
+	if inspect.ContainerJSONBase == nil {
+		return false
+	}
+	created, err := time.Parse(time.RFC3339Nano, inspect.Created)
+	return err == nil && created.After(t)
+}
+
+// containerLogs streams a container's logs. Docker cannot read back logs sent
+// to the journald driver, so those are read with journalctl instead.
+func containerLogs(ctx context.Context, cli containerLogReader, containerName string, opts systemd.LogOptions) (io.ReadCloser, error) {
+	// If the inspect fails, ContainerLogs reports the problem
+	inspect, inspectErr := cli.ContainerInspect(ctx, containerName)
+	if inspectErr == nil && logDriver(inspect) == LogDriverJournald {
+		name := strings.TrimPrefix(inspect.Name, "/")
+		return journalLogs(ctx, name, opts)
+	}
+
+	options := container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     opts.Follow,
+		Tail:       fmt.Sprintf("%d", opts.Tail),
+		Timestamps: !opts.NoTimestamps,
+	}
+	if !opts.Since.IsZero() {
+		// A container recreated since has none of the lines seen, so
+		// resuming would silently skip its whole log
+		if inspectErr == nil && createdAfter(inspect, opts.Since) {
+			return nil, fmt.Errorf("container %s was recreated: %w", containerName, systemd.ErrCannotResume)
+		}
+		options.Since = fmt.Sprintf("%d.%09d", opts.Since.Unix(), opts.Since.Nanosecond())
+		options.Tail = "all"
+	}
+
+	logs, err := cli.ContainerLogs(ctx, containerName, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container logs: %w", err)
+	}
+
+	// Wrap with demultiplexer to strip Docker's 8-byte header
+	return &dockerLogReader{reader: bufio.NewReader(logs), closer: logs}, nil
+}
@@ -0,0 +1,152 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+
+	"home_server_dashboard/services/systemd"
+)
+
+// fakeLogClient returns a fixed inspect result and records log reads.
+type fakeLogClient struct {
+	inspect    container.InspectResponse
+	inspectErr error
+	logsCalled bool
+	options    container.LogsOptions
+}
+
+func (f *fakeLogClient) ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error) {
+	return f.inspect, f.inspectErr
+}
+
+func (f *fakeLogClient) ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error) {
+	f.logsCalled = true
+	f.options = options
+	// One stdout frame: 8-byte header followed by the line
+	return io.NopCloser(strings.NewReader("\x01\x00\x00\x00\x00\x00\x00\x0chello docker\n")), nil
+}
+
+func inspectWithDriver(name, driver string) container.InspectResponse {
+	return container.InspectResponse{ContainerJSONBase: &container.ContainerJSONBase{
+		Name:       "/" + name,
+		HostConfig: &container.HostConfig{LogConfig: container.LogConfig{Type: driver}},
+	}}
+}
+
+func TestLogDriver(t *testing.T) {
+	if got := logDriver(inspectWithDriver("web", "journald")); got != LogDriverJournald {
+		t.Errorf("logDriver() = %q, want %q", got, LogDriverJournald)
+	}
+	if got := logDriver(container.InspectResponse{}); got != "" {
+		t.Errorf("logDriver() without details = %q, want empty", got)
+	}
+	if got := logDriver(container.InspectResponse{ContainerJSONBase: &container.ContainerJSONBase{}}); got != "" {
+		t.Errorf("logDriver() without host config = %q, want empty", got)
+	}
+}
+
+func TestContainerLogs_Driver(t *testing.T) {
+	origJournal := journalLogs
+	defer func() { journalLogs = origJournal }()
+
+	var journalName string
+	var journalOpts systemd.LogOptions
+	journalLogs = func(ctx context.Context, containerName string, opts systemd.LogOptions) (io.ReadCloser, error) {
+		journalName, journalOpts = containerName, opts
+		return io.NopCloser(strings.NewReader("hello journal\n")), nil
+	}
+
+	tests := []struct {
+		name        string
+		client      *fakeLogClient
+		wantJournal bool
+		wantLine    string
+	}{
+		{"json-file", &fakeLogClient{inspect: inspectWithDriver("web", "json-file")}, false, "hello docker\n"},
+		{"journald", &fakeLogClient{inspect: inspectWithDriver("web", "journald")}, true, "hello journal\n"},
+		{"inspect fails", &fakeLogClient{inspectErr: errors.New("no such container")}, false, "hello docker\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			journalName = ""
+			logs, err := containerLogs(context.Background(), tt.client, "web", systemd.LogOptions{Tail: 50, Follow: true})
+			if err != nil {
+				t.Fatalf("containerLogs() = %v", err)
+			}
+			defer logs.Close()
+
+			buf := make([]byte, 64)
+			n, _ := logs.Read(buf)
+			if got := string(buf[:n]); got != tt.wantLine {
+				t.Errorf("first read = %q, want %q", got, tt.wantLine)
+			}
+			if tt.client.logsCalled == tt.wantJournal {
+				t.Errorf("ContainerLogs called = %v, want %v", tt.client.logsCalled, !tt.wantJournal)
+			}
+			if tt.wantJournal {
+				if journalName != "web" {
+					t.Errorf("journal read for %q, want %q", journalName, "web")
+				}
+				if journalOpts.Tail != 50 || !journalOpts.Follow {
+					t.Errorf("journal options = %+v, want tail 50 and follow", journalOpts)
+				}
+			} else if journalName != "" {
+				t.Errorf("journal read for %q, want none", journalName)
+			}
+		})
+	}
+}
+
+func TestContainerLogs_Options(t *testing.T) {
+	client := &fakeLogClient{inspect: inspectWithDriver("web", "json-file")}
+	logs, err := containerLogs(context.Background(), client, "web", systemd.LogOptions{Tail: 500})
+	if err != nil {
+		t.Fatalf("containerLogs() = %v", err)
+	}
+	logs.Close()
+	if client.options.Tail != "500" || !client.options.Timestamps || client.options.Follow {
+		t.Errorf("options = %+v, want tail 500 with timestamps", client.options)
+	}
+
+	logs, err = containerLogs(context.Background(), client, "web", systemd.LogOptions{Tail: 100, NoTimestamps: true})
+	if err != nil {
+		t.Fatalf("containerLogs() = %v", err)
+	}
+	logs.Close()
+	if client.options.Timestamps {
+		t.Error("Timestamps = true, want false")
+	}
+}
+
+func TestContainerLogs_Since(t *testing.T) {
+	since := time.Date(2024, 1, 15, 10, 30, 0, 123456789, time.UTC)
+	inspect := inspectWithDriver("web", "json-file")
+	inspect.Created = "2024-01-15T09:00:00Z"
+	client := &fakeLogClient{inspect: inspect}
+	logs, err := containerLogs(context.Background(), client, "web", systemd.LogOptions{Tail: 500, Since: since})
+	if err != nil {
+		t.Fatalf("containerLogs() = %v", err)
+	}
+	logs.Close()
+	if client.options.Since != "1705314600.123456789" || client.options.Tail != "all" {
+		t.Errorf("options = %+v, want since 1705314600.123456789 and tail all", client.options)
+	}
+
+	// Recreated after the last line seen
+	inspect.Created = "2024-01-15T11:00:00Z"
+	client = &fakeLogClient{inspect: inspect}
+	_, err = containerLogs(context.Background(), client, "web", systemd.LogOptions{Tail: 500, Since: since})
+	if !errors.Is(err, systemd.ErrCannotResume) {
+		t.Errorf("containerLogs() = %v, want ErrCannotResume", err)
+	}
+	if client.logsCalled {
+		t.Error("logs read for a recreated container")
+	}
+}
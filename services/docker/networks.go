@@ -0,0 +1,182 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+
+	"home_server_dashboard/services"
+)
+
+// Network is a Docker network with the services attached to it.
+type Network struct {
+	Name     string           `json:"name"`
+	Driver   string           `json:"driver,omitempty"`
+	Subnets  []string         `json:"subnets,omitempty"`
+	Internal bool             `json:"internal,omitempty"`
+	Services []NetworkService `json:"services"`
+}
+
+// NetworkService is a service attached to a network. Services that share
+// another container's network namespace are listed under that container's
+// networks, with SharedWith naming it.
+type NetworkService struct {
+	Name          string `json:"name"`
+	Project       string `json:"project,omitempty"`
+	ContainerName string `json:"container_name"`
+	IPAddress     string `json:"ip_address,omitempty"`
+	SharedWith    string `json:"shared_with,omitempty"`
+}
+
+// GetNetworks returns the networks on the host with the containers attached
+// to each, from one container list and one network list.
+func (p *Provider) GetNetworks(ctx context.Context) ([]Network, error) {
+	containers, err := p.client.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	networks, err := p.client.NetworkList(ctx, network.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks: %w", err)
+	}
+	return groupNetworks(containers, networks), nil
+}
+
+// containerNetworks returns the networks a container is attached to, sorted
+// by name. Containers in another container's namespace have none of their own.
+func containerNetworks(ctr container.Summary) []services.NetworkAttachment {
+	if ctr.NetworkSettings == nil || len(ctr.NetworkSettings.Networks) == 0 {
+		return nil
+	}
+	attachments := make([]services.NetworkAttachment, 0, len(ctr.NetworkSettings.Networks))
+	for name, endpoint := range ctr.NetworkSettings.Networks {
+		attachment := services.NetworkAttachment{Name: name}
+		if endpoint != nil {
+			attachment.IPAddress = endpoint.IPAddress
+		}
+		attachments = append(attachments, attachment)
+	}
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].Name < attachments[j].Name })
+	return attachments
+}
+
+// containerDisplayName returns a container's name without the leading slash.
+func containerDisplayName(ctr container.Summary) string {
+	if len(ctr.Names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(ctr.Names[0], "/")
+}
+
+// namespaceOwners resolves the target of a "container:<name|id>" network
+// mode to the container that owns the namespace.
+type namespaceOwners struct {
+	containers []container.Summary
+	byName     map[string]int
+}
+
+func newNamespaceOwners(containers []container.Summary) *namespaceOwners {
+	owners := &namespaceOwners{containers: containers, byName: make(map[string]int, len(containers))}
+	for i, ctr := range containers {
+		if name := containerDisplayName(ctr); name != "" {
+			owners.byName[name] = i
+		}
+	}
+	return owners
+}
+
+// owner returns the container whose network namespace ctr shares, or false
+// if ctr has its own namespace or the owner isn't in the list.
+func (o *namespaceOwners) owner(ctr container.Summary) (container.Summary, bool) {
+	target, ok := strings.CutPrefix(ctr.HostConfig.NetworkMode, "container:")
+	if !ok || target == "" {
+		return container.Summary{}, false
+	}
+	if i, ok := o.byName[strings.TrimPrefix(target, "/")]; ok {
+		return o.containers[i], true
+	}
+	// Compose writes the full ID; a short ID is a prefix of it
+	for _, candidate := range o.containers {
+		if strings.HasPrefix(candidate.ID, target) {
+			return candidate, true
+		}
+	}
+	return container.Summary{}, false
+}
+
+// sharedWith returns the service name, or the container name for containers
+// outside compose, whose network namespace ctr shares. Empty if it has its own.
+func (o *namespaceOwners) sharedWith(ctr container.Summary) string {
+	owner, ok := o.owner(ctr)
+	if !ok {
+		return ""
+	}
+	if service := owner.Labels["com.docker.compose.service"]; service != "" {
+		return service
+	}
+	return containerDisplayName(owner)
+}
+
+// groupNetworks lists every network with the compose services attached to
+// it. Driver and subnets come from the network list; networks with no
+// services attached are included so the list matches "docker network ls".
+func groupNetworks(containers []container.Summary, networks []network.Summary) []Network {
+	byName := make(map[string]*Network, len(networks))
+	for _, n := range networks {
+		entry := &Network{Name: n.Name, Driver: n.Driver, Internal: n.Internal, Services: []NetworkService{}}
+		for _, ipam := range n.IPAM.Config {
+			if ipam.Subnet != "" {
+				entry.Subnets = append(entry.Subnets, ipam.Subnet)
+			}
+		}
+		byName[n.Name] = entry
+	}
+
+	owners := newNamespaceOwners(containers)
+	for _, ctr := range containers {
+		service := ctr.Labels["com.docker.compose.service"]
+		if ctr.Labels["com.docker.compose.project"] == "" || service == "" {
+			continue
+		}
+		entry := NetworkService{
+			Name:          service,
+			Project:       ctr.Labels["com.docker.compose.project"],
+			ContainerName: containerDisplayName(ctr),
+		}
+
+		attachments := containerNetworks(ctr)
+		if owner, ok := owners.owner(ctr); ok {
+			entry.SharedWith = owners.sharedWith(ctr)
+			attachments = containerNetworks(owner)
+		}
+
+		for _, attachment := range attachments {
+			n, ok := byName[attachment.Name]
+			if !ok {
+				// Attached to a network created after the network list was taken
+				n = &Network{Name: attachment.Name, Services: []NetworkService{}}
+				byName[attachment.Name] = n
+			}
+			svc := entry
+			svc.IPAddress = attachment.IPAddress
+			n.Services = append(n.Services, svc)
+		}
+	}
+
+	result := make([]Network, 0, len(byName))
+	for _, n := range byName {
+		sort.Slice(n.Services, func(i, j int) bool {
+			if n.Services[i].Name != n.Services[j].Name {
+				return n.Services[i].Name < n.Services[j].Name
+			}
+			return n.Services[i].ContainerName < n.Services[j].ContainerName
+		})
+		result = append(result, *n)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
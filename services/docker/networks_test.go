@@ -0,0 +1,179 @@
+package docker
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+// cannedContainers is a ContainerList response: gluetun with qbittorrent in
+// its network namespace, jellyfin on two networks and a stopped sonarr.
+const cannedContainers = `[
+	{
+		"Id": "1f2e3d4c5b6a79880123456789abcdef0123456789abcdef0123456789abcdef",
+		"Names": ["/gluetun"],
+		"State": "running",
+		"Labels": {"com.docker.compose.project": "media", "com.docker.compose.service": "gluetun"},
+		"HostConfig": {"NetworkMode": "media_default"},
+		"NetworkSettings": {"Networks": {"media_default": {"IPAddress": "172.20.0.2"}}}
+	},
+	{
+		"Id": "aaaa000000000000000000000000000000000000000000000000000000000000",
+		"Names": ["/qbittorrent"],
+		"State": "running",
+		"Labels": {"com.docker.compose.project": "media", "com.docker.compose.service": "qbittorrent"},
+		"HostConfig": {"NetworkMode": "container:1f2e3d4c5b6a79880123456789abcdef0123456789abcdef0123456789abcdef"},
+		"NetworkSettings": {"Networks": {}}
+	},
+	{
+		"Id": "bbbb000000000000000000000000000000000000000000000000000000000000",
+		"Names": ["/jellyfin"],
+		"State": "running",
+		"Labels": {"com.docker.compose.project": "media", "com.docker.compose.service": "jellyfin"},
+		"HostConfig": {"NetworkMode": "media_default"},
+		"NetworkSettings": {"Networks": {
+			"proxy": {"IPAddress": "172.30.0.5"},
+			"media_default": {"IPAddress": "172.20.0.3"}
+		}}
+	},
+	{
+		"Id": "cccc000000000000000000000000000000000000000000000000000000000000",
+		"Names": ["/sonarr"],
+		"State": "exited",
+		"Labels": {"com.docker.compose.project": "media", "com.docker.compose.service": "sonarr"},
+		"HostConfig": {"NetworkMode": "media_default"},
+		"NetworkSettings": {"Networks": {"media_default": {"IPAddress": ""}}}
+	},
+	{
+		"Id": "dddd000000000000000000000000000000000000000000000000000000000000",
+		"Names": ["/vpn"],
+		"State": "running",
+		"Labels": {},
+		"HostConfig": {"NetworkMode": "bridge"},
+		"NetworkSettings": {"Networks": {"bridge": {"IPAddress": "172.17.0.2"}}}
+	},
+	{
+		"Id": "eeee000000000000000000000000000000000000000000000000000000000000",
+		"Names": ["/curl"],
+		"State": "running",
+		"Labels": {"com.docker.compose.project": "tools", "com.docker.compose.service": "curl"},
+		"HostConfig": {"NetworkMode": "container:vpn"},
+		"NetworkSettings": {"Networks": {}}
+	}
+]`
+
+// cannedNetworks is a NetworkList response.
+const cannedNetworks = `[
+	{"Name": "bridge", "Driver": "bridge", "IPAM": {"Config": [{"Subnet": "172.17.0.0/16"}]}},
+	{"Name": "media_default", "Driver": "bridge", "IPAM": {"Config": [{"Subnet": "172.20.0.0/16"}, {"Subnet": "fd00:20::/64"}]}},
+	{"Name": "proxy", "Driver": "bridge", "Internal": true, "IPAM": {"Config": [{"Subnet": "172.30.0.0/16"}]}},
+	{"Name": "host", "Driver": "host", "IPAM": {"Config": []}}
+]`
+
+func loadCannedContainers(t *testing.T) []container.Summary {
+	t.Helper()
+	var containers []container.Summary
+	if err := json.Unmarshal([]byte(cannedContainers), &containers); err != nil {
+		t.Fatalf("failed to decode canned containers: %v", err)
+	}
+	return containers
+}
+
+func TestContainerNetworks(t *testing.T) {
+	containers := loadCannedContainers(t)
+
+	got := containerNetworks(containers[2])
+	if len(got) != 2 || got[0].Name != "media_default" || got[0].IPAddress != "172.20.0.3" || got[1].Name != "proxy" || got[1].IPAddress != "172.30.0.5" {
+		t.Errorf("jellyfin networks = %+v", got)
+	}
+	if got := containerNetworks(containers[1]); got != nil {
+		t.Errorf("qbittorrent networks = %+v, want none", got)
+	}
+	if got := containerNetworks(container.Summary{}); got != nil {
+		t.Errorf("networks without settings = %+v, want none", got)
+	}
+}
+
+func TestNamespaceOwners(t *testing.T) {
+	containers := loadCannedContainers(t)
+	owners := newNamespaceOwners(containers)
+
+	tests := []struct {
+		name     string
+		index    int
+		expected string
+	}{
+		{"own namespace", 0, ""},
+		{"shared by full ID", 1, "gluetun"},
+		{"non-compose owner by name", 5, "vpn"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := owners.sharedWith(containers[tt.index]); got != tt.expected {
+				t.Errorf("sharedWith() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	short := container.Summary{}
+	short.HostConfig.NetworkMode = "container:1f2e3d4c5b6a"
+	if got := owners.sharedWith(short); got != "gluetun" {
+		t.Errorf("sharedWith(short ID) = %q, want %q", got, "gluetun")
+	}
+	missing := container.Summary{}
+	missing.HostConfig.NetworkMode = "container:gone"
+	if got := owners.sharedWith(missing); got != "" {
+		t.Errorf("sharedWith(missing owner) = %q, want empty", got)
+	}
+}
+
+func TestGroupNetworks(t *testing.T) {
+	containers := loadCannedContainers(t)
+	var networks []network.Summary
+	if err := json.Unmarshal([]byte(cannedNetworks), &networks); err != nil {
+		t.Fatalf("failed to decode canned networks: %v", err)
+	}
+
+	got := groupNetworks(containers, networks)
+	byName := make(map[string]Network, len(got))
+	var names []string
+	for _, n := range got {
+		byName[n.Name] = n
+		names = append(names, n.Name)
+	}
+	if want := []string{"bridge", "host", "media_default", "proxy"}; len(names) != len(want) || names[0] != want[0] || names[1] != want[1] || names[2] != want[2] || names[3] != want[3] {
+		t.Fatalf("networks = %v, want %v", names, want)
+	}
+
+	media := byName["media_default"]
+	if media.Driver != "bridge" || len(media.Subnets) != 2 || media.Subnets[0] != "172.20.0.0/16" {
+		t.Errorf("media_default = %+v", media)
+	}
+	var svcs []string
+	for _, svc := range media.Services {
+		svcs = append(svcs, svc.Name+"@"+svc.IPAddress+"<"+svc.SharedWith)
+	}
+	want := []string{"gluetun@172.20.0.2<", "jellyfin@172.20.0.3<", "qbittorrent@172.20.0.2<gluetun", "sonarr@<"}
+	if len(svcs) != len(want) {
+		t.Fatalf("media_default services = %v, want %v", svcs, want)
+	}
+	for i := range want {
+		if svcs[i] != want[i] {
+			t.Errorf("media_default services = %v, want %v", svcs, want)
+			break
+		}
+	}
+
+	if proxy := byName["proxy"]; !proxy.Internal || len(proxy.Services) != 1 || proxy.Services[0].Name != "jellyfin" {
+		t.Errorf("proxy = %+v", proxy)
+	}
+	// Non-compose containers aren't listed, but compose services sharing their namespace are
+	if bridge := byName["bridge"]; len(bridge.Services) != 1 || bridge.Services[0].Name != "curl" || bridge.Services[0].SharedWith != "vpn" {
+		t.Errorf("bridge = %+v", bridge)
+	}
+	if host := byName["host"]; host.Services == nil || len(host.Services) != 0 {
+		t.Errorf("host = %+v, want an empty service list", host)
+	}
+}
@@ -0,0 +1,159 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/docker/api/types/container"
+
+	"home_server_dashboard/services"
+)
+
+// composeWorkingDirLabel is set by compose to the directory the project was brought up from.
+const composeWorkingDirLabel = "com.docker.compose.project.working_dir"
+
+// ErrNotOrphaned is reported for a container asked to be removed that is no
+// longer an orphan, e.g. because it was started or its directory came back.
+var ErrNotOrphaned = errors.New("container is no longer orphaned")
+
+// OrphanedContainer is a stopped compose container whose project directory
+// no longer exists.
+type OrphanedContainer struct {
+	ID            string `json:"id"`
+	ContainerName string `json:"container_name"`
+	Project       string `json:"project"`
+	Service       string `json:"service"`
+	WorkingDir    string `json:"working_dir"`
+	Status        string `json:"status"`
+}
+
+// orphanClient is the subset of the Docker client used to find and remove orphans.
+type orphanClient interface {
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+	ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error
+}
+
+// composeLayout is where a host's compose projects live, as configured by
+// its docker_compose_roots and docker_compose_roots_glob.
+type composeLayout struct {
+	roots    []string
+	patterns []string
+}
+
+// holds reports whether dir is a project directory of the layout whose
+// location the dashboard can see: its parent is a configured root, or dir
+// matches one of the glob patterns, and that parent directory exists.
+func (l composeLayout) holds(dir string) bool {
+	parent := filepath.Dir(dir)
+	configured := false
+	for _, root := range l.roots {
+		if root != "" && filepath.Clean(root) == parent {
+			configured = true
+			break
+		}
+	}
+	for _, pattern := range l.patterns {
+		if configured {
+			break
+		}
+		configured, _ = filepath.Match(pattern, dir)
+	}
+	if !configured {
+		return false
+	}
+	info, err := os.Stat(parent)
+	return err == nil && info.IsDir()
+}
+
+// isOrphaned reports whether a container is stopped and the compose working
+// directory in its labels no longer exists. Running containers are never
+// orphaned, and neither are containers without the label or whose directory
+// can't be checked for reasons other than it being gone.
+//
+// The directory is only judged gone if the layout vouches for it: it was
+// directly in one of the host's compose roots, or matched one of its root
+// patterns, and the directory it was in is still there. Run in a container,
+// the dashboard sees none of the host's compose directories unless they are
+// mounted at the same paths, while directories such as /opt or /srv exist
+// in almost every image, so a project outside the configured roots is left
+// alone rather than flagged.
+func isOrphaned(ctr container.Summary, layout composeLayout) bool {
+	if MapState(ctr.State, "") != services.StateStopped {
+		return false
+	}
+	dir := ctr.Labels[composeWorkingDirLabel]
+	if dir == "" || !filepath.IsAbs(dir) {
+		return false
+	}
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		return false
+	}
+	return layout.holds(filepath.Clean(dir))
+}
+
+// findOrphans returns the orphaned compose containers on the host.
+func findOrphans(ctx context.Context, client orphanClient, layout composeLayout) ([]OrphanedContainer, error) {
+	containers, err := client.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	var orphans []OrphanedContainer
+	for _, ctr := range containers {
+		project := ctr.Labels["com.docker.compose.project"]
+		service := ctr.Labels["com.docker.compose.service"]
+		if project == "" || service == "" || !isOrphaned(ctr, layout) {
+			continue
+		}
+		orphans = append(orphans, OrphanedContainer{
+			ID:            ctr.ID,
+			ContainerName: containerDisplayName(ctr),
+			Project:       project,
+			Service:       service,
+			WorkingDir:    ctr.Labels[composeWorkingDirLabel],
+			Status:        ctr.Status,
+		})
+	}
+	return orphans, nil
+}
+
+// removeOrphans removes the containers with the given IDs, checking first
+// that each is still orphaned. report is called once per ID with the
+// container and the result of its removal; IDs that are no longer orphans
+// are reported with ErrNotOrphaned and left alone.
+func removeOrphans(ctx context.Context, client orphanClient, layout composeLayout, ids []string, report func(OrphanedContainer, error)) error {
+	orphans, err := findOrphans(ctx, client, layout)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]OrphanedContainer, len(orphans))
+	for _, orphan := range orphans {
+		byID[orphan.ID] = orphan
+	}
+
+	for _, id := range ids {
+		orphan, ok := byID[id]
+		if !ok {
+			report(OrphanedContainer{ID: id}, ErrNotOrphaned)
+			continue
+		}
+		// Without force, a container started since the check is not removed
+		report(orphan, client.ContainerRemove(ctx, id, container.RemoveOptions{}))
+	}
+	return nil
+}
+
+// FindOrphans returns the stopped compose containers whose project directory
+// no longer exists.
+func (p *Provider) FindOrphans(ctx context.Context) ([]OrphanedContainer, error) {
+	return findOrphans(ctx, p.client, p.composeLayout)
+}
+
+// RemoveOrphans removes the orphaned containers with the given IDs, as
+// listed by FindOrphans. See removeOrphans.
+func (p *Provider) RemoveOrphans(ctx context.Context, ids []string, report func(OrphanedContainer, error)) error {
+	return removeOrphans(ctx, p.client, p.composeLayout, ids, report)
+}
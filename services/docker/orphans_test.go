@@ -0,0 +1,147 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// fakeOrphanClient returns a fixed container list and records removals.
+type fakeOrphanClient struct {
+	containers []container.Summary
+	removeErr  map[string]error
+	removed    []string
+}
+
+func (f *fakeOrphanClient) ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error) {
+	return f.containers, nil
+}
+
+func (f *fakeOrphanClient) ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error {
+	if options.Force {
+		return errors.New("unexpected forced removal")
+	}
+	if err := f.removeErr[containerID]; err != nil {
+		return err
+	}
+	f.removed = append(f.removed, containerID)
+	return nil
+}
+
+func composeContainer(id, service, state, workingDir string) container.Summary {
+	return container.Summary{
+		ID:     id,
+		Names:  []string{"/old-" + service + "-1"},
+		State:  state,
+		Status: "Exited (0) 3 days ago",
+		Labels: map[string]string{
+			"com.docker.compose.project": "old",
+			"com.docker.compose.service": service,
+			composeWorkingDirLabel:       workingDir,
+		},
+	}
+}
+
+func TestIsOrphaned(t *testing.T) {
+	existing := t.TempDir()
+	root := t.TempDir()
+	deleted := filepath.Join(root, "gone")
+	// The parent of a directory outside the roots exists, like /opt in most images
+	unconfigured := filepath.Join(t.TempDir(), "media")
+	globRoot := t.TempDir()
+	missingRoot := filepath.Join(t.TempDir(), "unmounted")
+	layout := composeLayout{roots: []string{root + "/", missingRoot}, patterns: []string{filepath.Join(globRoot, "*")}}
+
+	tests := []struct {
+		name     string
+		ctr      container.Summary
+		expected bool
+	}{
+		{"stopped, directory deleted", composeContainer("a", "web", "exited", deleted), true},
+		{"created, directory deleted", composeContainer("a", "web", "created", deleted), true},
+		{"stopped, directory exists", composeContainer("a", "web", "exited", existing), false},
+		{"running, directory deleted", composeContainer("a", "web", "running", deleted), false},
+		{"restarting, directory deleted", composeContainer("a", "web", "restarting", deleted), false},
+		{"paused, directory deleted", composeContainer("a", "web", "paused", deleted), false},
+		{"no working dir label", composeContainer("a", "web", "exited", ""), false},
+		// As seen from a container the host's compose directories aren't mounted in
+		{"parent directory not visible", composeContainer("a", "web", "exited", filepath.Join(deleted, "stacks", "web")), false},
+		{"relative working dir", composeContainer("a", "web", "exited", "gone"), false},
+		{"parent exists but is not a compose root", composeContainer("a", "web", "exited", unconfigured), false},
+		{"matches a root pattern", composeContainer("a", "web", "exited", filepath.Join(globRoot, "web")), true},
+		{"root not visible", composeContainer("a", "web", "exited", filepath.Join(missingRoot, "web")), false},
+		{"nested below a root", composeContainer("a", "web", "exited", filepath.Join(root, "stacks", "web")), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isOrphaned(tt.ctr, layout); got != tt.expected {
+				t.Errorf("isOrphaned() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFindOrphans(t *testing.T) {
+	root := t.TempDir()
+	deleted := filepath.Join(root, "gone")
+	standalone := container.Summary{ID: "d", Names: []string{"/scratch"}, State: "exited", Labels: map[string]string{composeWorkingDirLabel: deleted}}
+	client := &fakeOrphanClient{containers: []container.Summary{
+		composeContainer("a", "web", "exited", deleted),
+		composeContainer("b", "db", "running", deleted),
+		composeContainer("c", "cache", "exited", t.TempDir()),
+		standalone,
+	}}
+
+	orphans, err := findOrphans(context.Background(), client, composeLayout{roots: []string{root}})
+	if err != nil {
+		t.Fatalf("findOrphans() = %v", err)
+	}
+	want := []OrphanedContainer{{ID: "a", ContainerName: "old-web-1", Project: "old", Service: "web", WorkingDir: deleted, Status: "Exited (0) 3 days ago"}}
+	if !reflect.DeepEqual(orphans, want) {
+		t.Errorf("findOrphans() = %+v, want %+v", orphans, want)
+	}
+}
+
+func TestRemoveOrphans(t *testing.T) {
+	root := t.TempDir()
+	deleted := filepath.Join(root, "gone")
+	client := &fakeOrphanClient{
+		containers: []container.Summary{
+			composeContainer("a", "web", "exited", deleted),
+			composeContainer("b", "db", "running", deleted),
+			composeContainer("c", "worker", "exited", deleted),
+		},
+		removeErr: map[string]error{"c": errors.New("conflict")},
+	}
+
+	results := make(map[string]error)
+	err := removeOrphans(context.Background(), client, composeLayout{roots: []string{root}}, []string{"a", "b", "c", "missing"}, func(orphan OrphanedContainer, err error) {
+		results[orphan.ID] = err
+	})
+	if err != nil {
+		t.Fatalf("removeOrphans() = %v", err)
+	}
+
+	if !reflect.DeepEqual(client.removed, []string{"a"}) {
+		t.Errorf("removed = %v, want [a]", client.removed)
+	}
+	if len(results) != 4 {
+		t.Fatalf("got %d reports, want 4: %v", len(results), results)
+	}
+	if results["a"] != nil {
+		t.Errorf("a: %v, want removed", results["a"])
+	}
+	// The running container was listed, but isn't removed even though its directory is gone
+	for _, id := range []string{"b", "missing"} {
+		if !errors.Is(results[id], ErrNotOrphaned) {
+			t.Errorf("%s: %v, want ErrNotOrphaned", id, results[id])
+		}
+	}
+	if results["c"] == nil || errors.Is(results["c"], ErrNotOrphaned) {
+		t.Errorf("c: %v, want the removal error", results["c"])
+	}
+}
@@ -0,0 +1,52 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/docker/docker/api/types/system"
+
+	"home_server_dashboard/services"
+)
+
+// daemonInfo is the subset of the Docker client used to look up the
+// platform of the host.
+type daemonInfo interface {
+	Info(ctx context.Context) (system.Info, error)
+}
+
+// hostPlatforms holds the platform of each host's Docker daemon, which
+// doesn't change while the daemon runs, so it is asked only once.
+var (
+	hostPlatformsMu sync.Mutex
+	hostPlatforms   = make(map[string]services.Platform)
+)
+
+// Platform returns the operating system and architecture of the host, as
+// its Docker daemon reports them. Implements services.PlatformProvider.
+func (p *Provider) Platform(ctx context.Context) (services.Platform, error) {
+	return hostPlatform(ctx, p.client, p.hostName)
+}
+
+// hostPlatform returns the platform of hostName's Docker daemon, asking d
+// if it isn't known yet.
+func hostPlatform(ctx context.Context, d daemonInfo, hostName string) (services.Platform, error) {
+	hostPlatformsMu.Lock()
+	platform, ok := hostPlatforms[hostName]
+	hostPlatformsMu.Unlock()
+	if ok {
+		return platform, nil
+	}
+
+	info, err := d.Info(ctx)
+	if err != nil {
+		return services.Platform{}, fmt.Errorf("failed to get docker info: %w", err)
+	}
+	platform = services.NewPlatform(info.OSType, info.Architecture, "")
+
+	hostPlatformsMu.Lock()
+	hostPlatforms[hostName] = platform
+	hostPlatformsMu.Unlock()
+	return platform, nil
+}
@@ -0,0 +1,135 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/client"
+)
+
+// fakePlatformClient is a Docker daemon with canned Info and ImageInspect
+// payloads, counting the calls to Info.
+type fakePlatformClient struct {
+	client.APIClient
+	info       system.Info
+	infoErr    error
+	infoCalls  int
+	images     map[string]image.InspectResponse
+	containers []container.Summary
+}
+
+func (f *fakePlatformClient) Info(ctx context.Context) (system.Info, error) {
+	f.infoCalls++
+	return f.info, f.infoErr
+}
+
+func (f *fakePlatformClient) ImageInspect(ctx context.Context, imageID string, inspectOpts ...client.ImageInspectOption) (image.InspectResponse, error) {
+	inspect, ok := f.images[imageID]
+	if !ok {
+		return image.InspectResponse{}, errors.New("no such image")
+	}
+	return inspect, nil
+}
+
+func (f *fakePlatformClient) ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error) {
+	return f.containers, nil
+}
+
+func (f *fakePlatformClient) ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error) {
+	return container.InspectResponse{}, errors.New("not inspected")
+}
+
+// platformContainer returns a running compose container of service using imageID.
+func platformContainer(service, imageID string) container.Summary {
+	return container.Summary{
+		ID:      service,
+		Names:   []string{"/media-" + service + "-1"},
+		ImageID: imageID,
+		State:   "running",
+		Status:  "Up 5 minutes",
+		Labels: map[string]string{
+			"com.docker.compose.project": "media",
+			"com.docker.compose.service": service,
+		},
+	}
+}
+
+// forgetHostPlatform drops the cached platform of hostName when the test ends.
+func forgetHostPlatform(t *testing.T, hostName string) {
+	t.Cleanup(func() {
+		hostPlatformsMu.Lock()
+		delete(hostPlatforms, hostName)
+		hostPlatformsMu.Unlock()
+	})
+}
+
+func TestProvider_Platform(t *testing.T) {
+	forgetHostPlatform(t, "pi")
+	cli := &fakePlatformClient{info: system.Info{OSType: "linux", Architecture: "aarch64"}}
+	p := NewProviderWithClient("pi", cli)
+
+	for range 2 {
+		platform, err := p.Platform(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if platform.String() != "linux/arm64" {
+			t.Errorf("Platform() = %s, want linux/arm64", platform)
+		}
+	}
+	if cli.infoCalls != 1 {
+		t.Errorf("Info called %d times, want once", cli.infoCalls)
+	}
+
+	forgetHostPlatform(t, "down")
+	failing := NewProviderWithClient("down", &fakePlatformClient{infoErr: errors.New("daemon is unavailable")})
+	if _, err := failing.Platform(context.Background()); err == nil {
+		t.Error("Platform() = nil error for a daemon that can't be reached")
+	}
+}
+
+func TestGetServices_ArchMismatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		hostArch  string
+		infoErr   error
+		image     image.InspectResponse
+		wantImage string
+		mismatch  bool
+	}{
+		{"matched", "x86_64", nil, image.InspectResponse{Os: "linux", Architecture: "amd64"}, "linux/amd64", false},
+		{"arm64 image on amd64", "x86_64", nil, image.InspectResponse{Os: "linux", Architecture: "arm64", Variant: "v8"}, "linux/arm64/v8", true},
+		{"amd64 image on arm64", "aarch64", nil, image.InspectResponse{Os: "linux", Architecture: "amd64"}, "linux/amd64", true},
+		{"32-bit ARM image on arm64", "aarch64", nil, image.InspectResponse{Os: "linux", Architecture: "arm", Variant: "v7"}, "linux/arm/v7", false},
+		{"host platform unknown", "", errors.New("daemon is unavailable"), image.InspectResponse{Os: "linux", Architecture: "arm64"}, "linux/arm64", false},
+		{"image platform unknown", "x86_64", nil, image.InspectResponse{}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hostName := "platform-" + tt.name
+			forgetHostPlatform(t, hostName)
+			cli := &fakePlatformClient{
+				info:       system.Info{OSType: "linux", Architecture: tt.hostArch},
+				infoErr:    tt.infoErr,
+				images:     map[string]image.InspectResponse{"sha256:web": tt.image},
+				containers: []container.Summary{platformContainer("web", "sha256:web")},
+			}
+			p := &Provider{hostName: hostName, client: cli, images: newImageCache(), inspects: newInspectCache()}
+
+			svcs, err := p.GetServices(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(svcs) != 1 {
+				t.Fatalf("GetServices() returned %d services", len(svcs))
+			}
+			if svcs[0].ImagePlatform != tt.wantImage || svcs[0].ArchMismatch != tt.mismatch {
+				t.Errorf("image platform %q, mismatch %v; want %q, %v", svcs[0].ImagePlatform, svcs[0].ArchMismatch, tt.wantImage, tt.mismatch)
+			}
+		})
+	}
+}
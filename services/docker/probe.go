@@ -0,0 +1,83 @@
+package docker
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+
+	"home_server_dashboard/config"
+)
+
+// ProbeFromLabels returns the custom health probe set by a container's
+// probe labels, or nil if it has no probe URL label.
+func ProbeFromLabels(labels map[string]string) (*config.ProbeSpec, error) {
+	rawURL := strings.TrimSpace(labels[LabelProbeURL])
+	if rawURL == "" {
+		return nil, nil
+	}
+	spec, err := config.ParseProbeSpec(rawURL, labels[LabelProbeInterval], labels[LabelProbeTimeout], labels[LabelProbeExpectStatus])
+	if err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// ResolveProbeURL returns the URL to probe for a container's probe URL. A
+// URL on config.ProbeContainerHost goes to the host port its container port
+// is published on, at the address it is bound to or wildcardHost if it is
+// bound to all of them; other URLs are probed as they are. It returns false
+// if the container port isn't published, or is published on all addresses
+// and wildcardHost is empty, so it can't be probed.
+func ResolveProbeURL(rawURL string, ports []container.Port, wildcardHost string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() != config.ProbeContainerHost {
+		return rawURL, true
+	}
+	containerPort := u.Port()
+	if containerPort == "" {
+		containerPort = "80"
+		if u.Scheme == "https" {
+			containerPort = "443"
+		}
+	}
+	for _, port := range ports {
+		if strconv.Itoa(int(port.PrivatePort)) != containerPort || port.PublicPort == 0 || (port.Type != "" && port.Type != "tcp") {
+			continue
+		}
+		ip := port.IP
+		if ip == "" || ip == "0.0.0.0" || ip == "::" {
+			if wildcardHost == "" {
+				continue
+			}
+			ip = wildcardHost
+		}
+		u.Host = net.JoinHostPort(ip, strconv.Itoa(int(port.PublicPort)))
+		return u.String(), true
+	}
+	return "", false
+}
+
+// HostGateway returns the gateway of the first of a container's networks,
+// by name, that has one: an address of the Docker host that its published
+// ports answer on, as seen from another container. It returns "" if the
+// container has no network with a gateway, e.g. one on the host network.
+func HostGateway(ctr container.Summary) string {
+	if ctr.NetworkSettings == nil {
+		return ""
+	}
+	names := make([]string, 0, len(ctr.NetworkSettings.Networks))
+	for name := range ctr.NetworkSettings.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if network := ctr.NetworkSettings.Networks[name]; network != nil && network.Gateway != "" {
+			return network.Gateway
+		}
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package docker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+func TestProbeFromLabels(t *testing.T) {
+	if probe, err := ProbeFromLabels(map[string]string{LabelProbeInterval: "60"}); probe != nil || err != nil {
+		t.Errorf("ProbeFromLabels() without a URL = %+v, %v", probe, err)
+	}
+
+	probe, err := ProbeFromLabels(map[string]string{
+		LabelProbeURL:          "http://container:8080/health",
+		LabelProbeInterval:     "60",
+		LabelProbeTimeout:      "2s",
+		LabelProbeExpectStatus: "204",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if probe.URL != "http://container:8080/health" || probe.Interval != time.Minute || probe.Timeout != 2*time.Second || probe.ExpectStatus != 204 {
+		t.Errorf("ProbeFromLabels() = %+v", probe)
+	}
+
+	if _, err := ProbeFromLabels(map[string]string{LabelProbeURL: "http://container:8080/health", LabelProbeTimeout: "soon"}); err == nil {
+		t.Error("ProbeFromLabels() accepted an invalid timeout")
+	}
+}
+
+func TestResolveProbeURL(t *testing.T) {
+	ports := []container.Port{
+		{IP: "0.0.0.0", PrivatePort: 8080, PublicPort: 18080, Type: "tcp"},
+		{IP: "::", PrivatePort: 8080, PublicPort: 18080, Type: "tcp"},
+		{IP: "192.168.1.10", PrivatePort: 443, PublicPort: 8443, Type: "tcp"},
+		{IP: "0.0.0.0", PrivatePort: 53, PublicPort: 5353, Type: "udp"},
+		{PrivatePort: 9000, Type: "tcp"},
+	}
+	tests := []struct {
+		name     string
+		url      string
+		wildcard string
+		want     string
+		wantOK   bool
+	}{
+		{"published on all addresses", "http://container:8080/health?full=1", "127.0.0.1", "http://127.0.0.1:18080/health?full=1", true},
+		{"published on all addresses, seen from a container", "http://container:8080/health", "172.18.0.1", "http://172.18.0.1:18080/health", true},
+		{"published on all addresses, no way to reach them", "http://container:8080/health", "", "", false},
+		{"published on one address", "https://container/ready", "", "https://192.168.1.10:8443/ready", true},
+		{"exposed but not published", "http://container:9000/health", "127.0.0.1", "", false},
+		{"only published over udp", "http://container:53/", "127.0.0.1", "", false},
+		{"not exposed", "http://container:3000/health", "127.0.0.1", "", false},
+		{"outside the container", "http://10.0.0.5:8080/health", "", "http://10.0.0.5:8080/health", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ResolveProbeURL(tt.url, ports, tt.wildcard)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ResolveProbeURL() = %q, %v; want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestHostGateway(t *testing.T) {
+	ctr := container.Summary{NetworkSettings: &container.NetworkSettingsSummary{Networks: map[string]*network.EndpointSettings{
+		"web_default": {Gateway: "172.19.0.1"},
+		"host":        {},
+		"app_default": {Gateway: "172.18.0.1"},
+	}}}
+	if got := HostGateway(ctr); got != "172.18.0.1" {
+		t.Errorf("HostGateway() = %q, want the gateway of the first network by name", got)
+	}
+	if got := HostGateway(container.Summary{}); got != "" {
+		t.Errorf("HostGateway() without networks = %q", got)
+	}
+}
@@ -0,0 +1,102 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+
+	"home_server_dashboard/services"
+)
+
+// ComposeProfiles are the profiles the services of a compose project belong
+// to. A service in a profile only runs when one of its profiles is active,
+// as with docker compose --profile; the others always run.
+type ComposeProfiles struct {
+	services map[string][]string // Profiles by service, for services that have some
+}
+
+// ReadComposeProfiles reads the profiles of the services in the compose
+// files of a project. A later file's profiles of a service replace an
+// earlier one's, as compose overrides them.
+func ReadComposeProfiles(files []string) (*ComposeProfiles, error) {
+	profiles := &ComposeProfiles{services: make(map[string][]string)}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var compose struct {
+			Services map[string]struct {
+				Profiles []string `yaml:"profiles"`
+			} `yaml:"services"`
+		}
+		if err := yaml.Unmarshal(data, &compose); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		for name, svc := range compose.Services {
+			if svc.Profiles != nil {
+				profiles.services[name] = svc.Profiles
+			}
+		}
+	}
+	return profiles, nil
+}
+
+// Of returns the profiles of service, or nil if it always runs.
+func (c *ComposeProfiles) Of(service string) []string {
+	if c == nil {
+		return nil
+	}
+	return c.services[service]
+}
+
+// Names returns every profile of the project, sorted.
+func (c *ComposeProfiles) Names() []string {
+	if c == nil {
+		return nil
+	}
+	var names []string
+	for _, profiles := range c.services {
+		names = append(names, profiles...)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// Has reports whether profile is a profile of the project.
+func (c *ComposeProfiles) Has(profile string) bool {
+	return slices.Contains(c.Names(), profile)
+}
+
+// profileState returns the state of a container of a service in profiles:
+// not enabled if it is stopped without having failed, since its profile
+// then isn't active, and state otherwise. A container of a profiled service
+// that exited with an error failed like any other.
+func profileState(state services.State, profiles []string, exitCode *int) services.State {
+	if len(profiles) == 0 || state != services.StateStopped || (exitCode != nil && *exitCode != 0) {
+		return state
+	}
+	return services.StateNotEnabled
+}
+
+// profileReader reads the profiles of each compose project once per
+// collection, from the compose files in its containers' labels.
+type profileReader map[string]*ComposeProfiles // By compose files
+
+// read returns the profiles of the project of a container with labels, or
+// nil if its compose files can't be found or read.
+func (r profileReader) read(labels map[string]string) *ComposeProfiles {
+	files := composeFiles(labels)
+	if len(files) == 0 {
+		return nil
+	}
+	key := fmt.Sprint(files)
+	profiles, ok := r[key]
+	if !ok {
+		profiles, _ = ReadComposeProfiles(files)
+		r[key] = profiles
+	}
+	return profiles
+}
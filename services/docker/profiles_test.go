@@ -0,0 +1,115 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"home_server_dashboard/services"
+)
+
+// profilesFixture is a compose file with services in no profile, in one
+// and in two.
+const profilesFixture = "testdata/profiles/compose.yaml"
+
+func TestReadComposeProfiles(t *testing.T) {
+	profiles, err := ReadComposeProfiles([]string{profilesFixture})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for service, want := range map[string][]string{
+		"web":      nil,
+		"db":       nil,
+		"adminer":  {"debug"},
+		"profiler": {"debug", "perf"},
+		"unknown":  nil,
+	} {
+		if got := profiles.Of(service); !reflect.DeepEqual(got, want) {
+			t.Errorf("Of(%q) = %q, want %q", service, got, want)
+		}
+	}
+	if got := profiles.Names(); !reflect.DeepEqual(got, []string{"debug", "perf"}) {
+		t.Errorf("Names() = %q", got)
+	}
+	if !profiles.Has("perf") || profiles.Has("web") {
+		t.Error("Has() doesn't match the profiles of the file")
+	}
+}
+
+func TestReadComposeProfiles_Override(t *testing.T) {
+	override := filepath.Join(t.TempDir(), "compose.override.yaml")
+	content := "services:\n  adminer:\n    profiles: [tools]\n  web:\n    environment: {DEBUG: 1}\n"
+	if err := os.WriteFile(override, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profiles, err := ReadComposeProfiles([]string{profilesFixture, override})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := profiles.Of("adminer"); !reflect.DeepEqual(got, []string{"tools"}) {
+		t.Errorf("Of(adminer) = %q, want the override's profiles", got)
+	}
+	if got := profiles.Of("profiler"); !reflect.DeepEqual(got, []string{"debug", "perf"}) {
+		t.Errorf("Of(profiler) = %q, want the base file's profiles", got)
+	}
+	if got := profiles.Of("web"); got != nil {
+		t.Errorf("Of(web) = %q, want none", got)
+	}
+
+	if _, err := ReadComposeProfiles([]string{filepath.Join(t.TempDir(), "gone.yaml")}); err == nil {
+		t.Error("ReadComposeProfiles() of a missing file = nil error")
+	}
+	var none *ComposeProfiles
+	if none.Of("web") != nil || none.Has("debug") {
+		t.Error("nil profiles should have no profiles")
+	}
+}
+
+func TestProfileState(t *testing.T) {
+	zero, failed := 0, 1
+	tests := []struct {
+		name     string
+		state    services.State
+		profiles []string
+		exitCode *int
+		want     services.State
+	}{
+		{"profiled and never started", services.StateStopped, []string{"debug"}, nil, services.StateNotEnabled},
+		{"profiled and exited cleanly", services.StateStopped, []string{"debug"}, &zero, services.StateNotEnabled},
+		{"profiled and failed", services.StateStopped, []string{"debug"}, &failed, services.StateStopped},
+		{"profiled and running", services.StateRunning, []string{"debug"}, nil, services.StateRunning},
+		{"unprofiled and stopped", services.StateStopped, nil, &zero, services.StateStopped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := profileState(tt.state, tt.profiles, tt.exitCode); got != tt.want {
+				t.Errorf("profileState() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfileReader(t *testing.T) {
+	dir, err := filepath.Abs("testdata/profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := make(profileReader)
+	labels := map[string]string{composeWorkingDirLabel: dir, composeConfigFilesLabel: "compose.yaml"}
+	if got := reader.read(labels).Of("adminer"); !reflect.DeepEqual(got, []string{"debug"}) {
+		t.Errorf("read().Of(adminer) = %q", got)
+	}
+	if len(reader) != 1 {
+		t.Errorf("%d projects read, want 1", len(reader))
+	}
+
+	// Without a resolvable compose file, no service is profiled
+	gone := map[string]string{composeWorkingDirLabel: filepath.Join(t.TempDir(), "gone"), composeConfigFilesLabel: "compose.yaml"}
+	if got := reader.read(gone).Of("adminer"); got != nil {
+		t.Errorf("read() of a missing file = %q", got)
+	}
+	if reader.read(nil) != nil {
+		t.Error("read() without labels found profiles")
+	}
+}
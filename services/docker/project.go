@@ -0,0 +1,59 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// ProjectContainer is a container of a compose project.
+type ProjectContainer struct {
+	Name    string // Container name, e.g. "media-sonarr-1"
+	Service string // Compose service, e.g. "sonarr"
+	Hidden  bool   // Whether the hidden label is set
+}
+
+// containerLister is the subset of the Docker client used to list containers.
+type containerLister interface {
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+}
+
+// ProjectContainers returns the containers of a compose project, running or
+// not, ordered by service and name.
+func (p *Provider) ProjectContainers(ctx context.Context, project string) ([]ProjectContainer, error) {
+	return projectContainers(ctx, p.client, project)
+}
+
+// projectContainers lists the containers of project with cli.
+func projectContainers(ctx context.Context, cli containerLister, project string) ([]ProjectContainer, error) {
+	containers, err := cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", "com.docker.compose.project="+project)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	var result []ProjectContainer
+	for _, ctr := range containers {
+		service := ctr.Labels["com.docker.compose.service"]
+		if ctr.Labels["com.docker.compose.project"] != project || service == "" {
+			continue
+		}
+		result = append(result, ProjectContainer{
+			Name:    containerDisplayName(ctr),
+			Service: service,
+			Hidden:  isLabelTrue(ctr.Labels[LabelHidden]),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Service != result[j].Service {
+			return result[i].Service < result[j].Service
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
@@ -0,0 +1,42 @@
+package docker
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestProjectContainers(t *testing.T) {
+	ctr := func(name, project, service string, labels ...string) container.Summary {
+		summary := container.Summary{
+			Names:  []string{"/" + name},
+			Labels: map[string]string{"com.docker.compose.project": project, "com.docker.compose.service": service},
+		}
+		for i := 0; i+1 < len(labels); i += 2 {
+			summary.Labels[labels[i]] = labels[i+1]
+		}
+		return summary
+	}
+	cli := &fakeOrphanClient{containers: []container.Summary{
+		ctr("media-sonarr-1", "media", "sonarr"),
+		ctr("media-gluetun-1", "media", "gluetun", LabelHidden, "true"),
+		ctr("media-sonarr-2", "media", "sonarr"),
+		ctr("other-web-1", "other", "web"),
+		ctr("media-oneoff", "media", ""),
+	}}
+
+	got, err := projectContainers(context.Background(), cli, "media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ProjectContainer{
+		{Name: "media-gluetun-1", Service: "gluetun", Hidden: true},
+		{Name: "media-sonarr-1", Service: "sonarr"},
+		{Name: "media-sonarr-2", Service: "sonarr"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("projectContainers() = %+v, want %+v", got, want)
+	}
+}
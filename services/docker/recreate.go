@@ -0,0 +1,290 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// containerRecreator is the subset of the Docker client used to recreate a container.
+type containerRecreator interface {
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+	ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error
+	ContainerRename(ctx context.Context, containerID, newContainerName string) error
+	ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error)
+	ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error
+	ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error
+}
+
+// rollbackTimeout bounds the rollback after a failed recreate. Rollback runs
+// even if the request context was cancelled, so the original container isn't
+// left stopped and renamed.
+const rollbackTimeout = 60 * time.Second
+
+// recreator replaces a container with a copy that has a different environment.
+type recreator struct {
+	client containerRecreator
+	// pollInterval is how often the new container's state is checked after starting.
+	pollInterval time.Duration
+	// startChecks is how many consecutive checks the new container must pass as running.
+	startChecks int
+	// now returns the current time (used to name the backup container).
+	now func() time.Time
+}
+
+// ValidateEnvOverrides checks that every override has a usable variable name.
+func ValidateEnvOverrides(overrides map[string]string) error {
+	if len(overrides) == 0 {
+		return fmt.Errorf("no environment overrides given")
+	}
+	for key := range overrides {
+		if key == "" {
+			return fmt.Errorf("environment variable name must not be empty")
+		}
+		if strings.ContainsAny(key, "= \t\n\x00") {
+			return fmt.Errorf("invalid environment variable name %q", key)
+		}
+	}
+	return nil
+}
+
+// ComposeProject returns the compose project a container belongs to, or an
+// empty string for containers not managed by compose.
+func (p *Provider) ComposeProject(ctx context.Context, containerName string) (string, error) {
+	inspect, err := p.client.ContainerInspect(ctx, containerName)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container %s: %w", containerName, err)
+	}
+	if inspect.Config == nil {
+		return "", nil
+	}
+	return inspect.Config.Labels["com.docker.compose.project"], nil
+}
+
+// ComposeWorkingDir returns the directory a container's compose project was
+// brought up from, or an empty string for containers not managed by compose.
+func (p *Provider) ComposeWorkingDir(ctx context.Context, containerName string) (string, error) {
+	inspect, err := p.client.ContainerInspect(ctx, containerName)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container %s: %w", containerName, err)
+	}
+	if inspect.Config == nil {
+		return "", nil
+	}
+	return inspect.Config.Labels[composeWorkingDirLabel], nil
+}
+
+// RecreateWithEnv replaces a container with a copy whose environment has the
+// given overrides applied. The copy keeps the name, image, volumes, networks
+// and labels of the original and is labelled as drifted from compose. The
+// original is stopped and renamed first, and only removed once the copy has
+// stayed running; otherwise the copy is removed and the original restored.
+// Progress messages are reported through progress.
+func (p *Provider) RecreateWithEnv(ctx context.Context, containerName string, overrides map[string]string, progress func(string)) error {
+	r := &recreator{
+		client:       p.client,
+		pollInterval: time.Second,
+		startChecks:  5,
+		now:          time.Now,
+	}
+	return r.recreate(ctx, containerName, overrides, progress)
+}
+
+// recreate performs the stop, rename, create, start and cleanup sequence.
+func (r *recreator) recreate(ctx context.Context, containerName string, overrides map[string]string, progress func(string)) error {
+	if err := ValidateEnvOverrides(overrides); err != nil {
+		return err
+	}
+
+	old, err := r.client.ContainerInspect(ctx, containerName)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container %s: %w", containerName, err)
+	}
+	if old.ContainerJSONBase == nil || old.Config == nil || old.HostConfig == nil {
+		return fmt.Errorf("container %s returned incomplete inspect data", containerName)
+	}
+	name := strings.TrimPrefix(old.Name, "/")
+	wasRunning := old.State != nil && old.State.Running
+
+	config := recreateConfig(old, overrides)
+	networking := recreateNetworking(old.NetworkSettings)
+
+	progress(fmt.Sprintf("Stopping container %s...", name))
+	if err := r.client.ContainerStop(ctx, old.ID, container.StopOptions{}); err != nil {
+		return fmt.Errorf("failed to stop container %s: %w", name, err)
+	}
+
+	backupName := fmt.Sprintf("%s-recreate-%d", name, r.now().Unix())
+	progress(fmt.Sprintf("Renaming %s to %s...", name, backupName))
+	if err := r.client.ContainerRename(ctx, old.ID, backupName); err != nil {
+		err = fmt.Errorf("failed to rename container %s: %w", name, err)
+		return r.rollback(ctx, err, old.ID, "", name, false, wasRunning, progress)
+	}
+
+	progress(fmt.Sprintf("Creating new container %s...", name))
+	created, err := r.client.ContainerCreate(ctx, config, old.HostConfig, networking, nil, name)
+	if err != nil {
+		err = fmt.Errorf("failed to create container %s: %w", name, err)
+		return r.rollback(ctx, err, old.ID, "", name, true, wasRunning, progress)
+	}
+
+	progress(fmt.Sprintf("Starting new container %s...", name))
+	if err := r.client.ContainerStart(ctx, created.ID, container.StartOptions{}); err != nil {
+		err = fmt.Errorf("failed to start container %s: %w", name, err)
+		return r.rollback(ctx, err, old.ID, created.ID, name, true, wasRunning, progress)
+	}
+
+	if err := r.waitRunning(ctx, created.ID); err != nil {
+		err = fmt.Errorf("new container %s did not stay running: %w", name, err)
+		return r.rollback(ctx, err, old.ID, created.ID, name, true, wasRunning, progress)
+	}
+
+	progress(fmt.Sprintf("Removing old container %s...", backupName))
+	if err := r.client.ContainerRemove(ctx, old.ID, container.RemoveOptions{}); err != nil {
+		// The new container is running, so this isn't a failure of the recreate itself
+		progress(fmt.Sprintf("Warning: failed to remove old container %s: %v", backupName, err))
+	}
+	return nil
+}
+
+// waitRunning checks the container until it has been seen running startChecks times in a row.
+func (r *recreator) waitRunning(ctx context.Context, containerID string) error {
+	for i := 0; i < r.startChecks; i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.pollInterval):
+		}
+
+		inspect, err := r.client.ContainerInspect(ctx, containerID)
+		if err != nil {
+			return fmt.Errorf("failed to inspect new container: %w", err)
+		}
+		if inspect.ContainerJSONBase == nil || inspect.State == nil {
+			return fmt.Errorf("new container returned incomplete inspect data")
+		}
+		state := inspect.State
+		if !state.Running || state.Restarting {
+			return fmt.Errorf("container is %s (exit code %d)", state.Status, state.ExitCode)
+		}
+	}
+	return nil
+}
+
+// rollback undoes a partial recreate: the new container (if any) is removed,
+// the original gets its name back (if it was renamed) and is started again if
+// it was running. The returned error describes both the failure and the rollback.
+func (r *recreator) rollback(ctx context.Context, cause error, oldID, newID, name string, renamed, wasRunning bool, progress func(string)) error {
+	progress(fmt.Sprintf("Recreate failed, restoring original container %s...", name))
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+	defer cancel()
+
+	var errs []error
+	if newID != "" {
+		if err := r.client.ContainerRemove(ctx, newID, container.RemoveOptions{Force: true}); err != nil {
+			errs = append(errs, fmt.Errorf("remove new container: %w", err))
+		}
+	}
+	// The name can only be restored once the new container no longer holds it
+	if renamed && len(errs) == 0 {
+		if err := r.client.ContainerRename(ctx, oldID, name); err != nil {
+			errs = append(errs, fmt.Errorf("restore name: %w", err))
+		}
+	}
+	if wasRunning {
+		if err := r.client.ContainerStart(ctx, oldID, container.StartOptions{}); err != nil {
+			errs = append(errs, fmt.Errorf("restart original: %w", err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w (rollback failed: %w)", cause, errors.Join(errs...))
+	}
+	return fmt.Errorf("%w (rolled back to the original container)", cause)
+}
+
+// composeConfigHashLabel is the hash compose keeps of a service's resolved
+// config. Compose recreates a container whose hash differs from the file's,
+// and leaves it alone when they match.
+const composeConfigHashLabel = "com.docker.compose.config-hash"
+
+// recreateConfig copies the container config with the environment overrides
+// applied and the drift label set. The compose config hash is dropped: the
+// copy no longer matches the compose file, and with the hash compose would
+// keep it on the next `up` instead of recreating it from the file.
+func recreateConfig(old container.InspectResponse, overrides map[string]string) *container.Config {
+	config := *old.Config
+	config.Env = mergeEnv(old.Config.Env, overrides)
+
+	config.Labels = make(map[string]string, len(old.Config.Labels)+1)
+	for k, v := range old.Config.Labels {
+		config.Labels[k] = v
+	}
+	delete(config.Labels, composeConfigHashLabel)
+	config.Labels[LabelDrifted] = "true"
+
+	// Docker defaults the hostname to the short container ID; let the new
+	// container get its own instead of inheriting the old ID
+	if len(old.ID) >= 12 && config.Hostname == old.ID[:12] {
+		config.Hostname = ""
+	}
+	return &config
+}
+
+// recreateNetworking rebuilds the network attachments of a container, keeping
+// the settings that were configured (aliases, static IPs, links) and dropping
+// the ones Docker assigns per container.
+func recreateNetworking(settings *container.NetworkSettings) *network.NetworkingConfig {
+	if settings == nil || len(settings.Networks) == 0 {
+		return nil
+	}
+	endpoints := make(map[string]*network.EndpointSettings, len(settings.Networks))
+	for name, ep := range settings.Networks {
+		if ep == nil {
+			continue
+		}
+		endpoints[name] = &network.EndpointSettings{
+			IPAMConfig: ep.IPAMConfig,
+			Links:      ep.Links,
+			Aliases:    ep.Aliases,
+			DriverOpts: ep.DriverOpts,
+		}
+	}
+	return &network.NetworkingConfig{EndpointsConfig: endpoints}
+}
+
+// mergeEnv applies overrides to a KEY=VALUE environment list. Existing
+// variables keep their position; new ones are appended in sorted order.
+func mergeEnv(env []string, overrides map[string]string) []string {
+	merged := make([]string, 0, len(env)+len(overrides))
+	applied := make(map[string]bool, len(overrides))
+	for _, entry := range env {
+		key, _, _ := strings.Cut(entry, "=")
+		if value, ok := overrides[key]; ok {
+			merged = append(merged, key+"="+value)
+			applied[key] = true
+			continue
+		}
+		merged = append(merged, entry)
+	}
+
+	var added []string
+	for key := range overrides {
+		if !applied[key] {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(added)
+	for _, key := range added {
+		merged = append(merged, key+"="+overrides[key])
+	}
+	return merged
+}
@@ -0,0 +1,327 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// fakeRecreateClient records Docker calls and returns scripted results.
+type fakeRecreateClient struct {
+	calls []string
+
+	original container.InspectResponse
+	// newStates are returned in order by inspects of the new container.
+	newStates []container.State
+
+	createErr    error
+	startNewErr  error
+	removeNewErr error
+	removeOldErr error
+
+	created *container.Config
+	network *network.NetworkingConfig
+}
+
+const (
+	fakeOldID = "0123456789abcdef0123"
+	fakeNewID = "fedcba9876543210fedc"
+)
+
+func newFakeRecreateClient() *fakeRecreateClient {
+	return &fakeRecreateClient{
+		original: container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{
+				ID:         fakeOldID,
+				Name:       "/media-jellyfin-1",
+				State:      &container.State{Running: true, Status: "running"},
+				HostConfig: &container.HostConfig{Binds: []string{"/srv/media:/media"}},
+			},
+			Config: &container.Config{
+				Hostname: fakeOldID[:12],
+				Image:    "jellyfin/jellyfin:latest",
+				Env:      []string{"PATH=/usr/bin", "LOG_LEVEL=info", "TZ=UTC"},
+				Labels: map[string]string{
+					"com.docker.compose.project": "media",
+					composeConfigHashLabel:       "3f2a9c",
+					"com.docker.compose.service": "jellyfin",
+				},
+			},
+			NetworkSettings: &container.NetworkSettings{
+				Networks: map[string]*network.EndpointSettings{
+					"media_default": {Aliases: []string{"jellyfin"}, IPAddress: "172.18.0.5", EndpointID: "ep1"},
+				},
+			},
+		},
+		newStates: []container.State{{Running: true, Status: "running"}, {Running: true, Status: "running"}},
+	}
+}
+
+func (f *fakeRecreateClient) ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error) {
+	f.calls = append(f.calls, "inspect "+containerID)
+	if containerID == fakeNewID {
+		if len(f.newStates) == 0 {
+			return container.InspectResponse{}, errors.New("no scripted state")
+		}
+		state := f.newStates[0]
+		f.newStates = f.newStates[1:]
+		return container.InspectResponse{ContainerJSONBase: &container.ContainerJSONBase{ID: fakeNewID, State: &state}}, nil
+	}
+	return f.original, nil
+}
+
+func (f *fakeRecreateClient) ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error {
+	f.calls = append(f.calls, "stop "+containerID)
+	return nil
+}
+
+func (f *fakeRecreateClient) ContainerRename(ctx context.Context, containerID, newContainerName string) error {
+	f.calls = append(f.calls, "rename "+containerID+" "+newContainerName)
+	return nil
+}
+
+func (f *fakeRecreateClient) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error) {
+	f.calls = append(f.calls, "create "+containerName)
+	f.created = config
+	f.network = networkingConfig
+	if f.createErr != nil {
+		return container.CreateResponse{}, f.createErr
+	}
+	return container.CreateResponse{ID: fakeNewID}, nil
+}
+
+func (f *fakeRecreateClient) ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error {
+	f.calls = append(f.calls, "start "+containerID)
+	if containerID == fakeNewID {
+		return f.startNewErr
+	}
+	return nil
+}
+
+func (f *fakeRecreateClient) ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error {
+	f.calls = append(f.calls, fmt.Sprintf("remove %s force=%t", containerID, options.Force))
+	if containerID == fakeNewID {
+		return f.removeNewErr
+	}
+	return f.removeOldErr
+}
+
+func newTestRecreator(client containerRecreator) *recreator {
+	return &recreator{
+		client:       client,
+		pollInterval: time.Millisecond,
+		startChecks:  2,
+		now:          func() time.Time { return time.Unix(1700000000, 0) },
+	}
+}
+
+func recreateWith(t *testing.T, f *fakeRecreateClient) ([]string, error) {
+	t.Helper()
+	var progress []string
+	err := newTestRecreator(f).recreate(context.Background(), "media-jellyfin-1", map[string]string{"LOG_LEVEL": "debug", "DEBUG": "1"}, func(msg string) {
+		progress = append(progress, msg)
+	})
+	return progress, err
+}
+
+func TestRecreate_Success(t *testing.T) {
+	f := newFakeRecreateClient()
+
+	progress, err := recreateWith(t, f)
+	if err != nil {
+		t.Fatalf("recreate() = %v", err)
+	}
+
+	want := []string{
+		"inspect media-jellyfin-1",
+		"stop " + fakeOldID,
+		"rename " + fakeOldID + " media-jellyfin-1-recreate-1700000000",
+		"create media-jellyfin-1",
+		"start " + fakeNewID,
+		"inspect " + fakeNewID,
+		"inspect " + fakeNewID,
+		"remove " + fakeOldID + " force=false",
+	}
+	if !reflect.DeepEqual(f.calls, want) {
+		t.Errorf("calls =\n%s\nwant\n%s", strings.Join(f.calls, "\n"), strings.Join(want, "\n"))
+	}
+	if len(progress) == 0 {
+		t.Error("no progress reported")
+	}
+
+	// Environment overrides are merged, keeping order and appending new variables
+	wantEnv := []string{"PATH=/usr/bin", "LOG_LEVEL=debug", "TZ=UTC", "DEBUG=1"}
+	if !reflect.DeepEqual(f.created.Env, wantEnv) {
+		t.Errorf("Env = %v, want %v", f.created.Env, wantEnv)
+	}
+	// Labels are kept and the drift label is added
+	if f.created.Labels["com.docker.compose.project"] != "media" || f.created.Labels[LabelDrifted] != "true" {
+		t.Errorf("Labels = %v", f.created.Labels)
+	}
+	// The compose config hash is dropped, so compose recreates the container
+	if _, ok := f.created.Labels[composeConfigHashLabel]; ok {
+		t.Errorf("Labels = %v, want no compose config hash", f.created.Labels)
+	}
+	// The original's labels are not modified
+	if _, ok := f.original.Config.Labels[LabelDrifted]; ok {
+		t.Error("original container labels were modified")
+	}
+	// The auto-generated hostname is not copied
+	if f.created.Hostname != "" {
+		t.Errorf("Hostname = %q, want empty", f.created.Hostname)
+	}
+	// Networks keep configured settings but not per-container ones
+	ep := f.network.EndpointsConfig["media_default"]
+	if ep == nil || !reflect.DeepEqual(ep.Aliases, []string{"jellyfin"}) || ep.IPAddress != "" || ep.EndpointID != "" {
+		t.Errorf("network endpoint = %+v", ep)
+	}
+}
+
+func TestRecreate_FailedStartRollsBack(t *testing.T) {
+	f := newFakeRecreateClient()
+	f.startNewErr = errors.New("port is already allocated")
+
+	_, err := recreateWith(t, f)
+	if err == nil {
+		t.Fatal("recreate() = nil, want error")
+	}
+	if !strings.Contains(err.Error(), "port is already allocated") || !strings.Contains(err.Error(), "rolled back") {
+		t.Errorf("error = %v, want cause and rollback note", err)
+	}
+
+	want := []string{
+		"inspect media-jellyfin-1",
+		"stop " + fakeOldID,
+		"rename " + fakeOldID + " media-jellyfin-1-recreate-1700000000",
+		"create media-jellyfin-1",
+		"start " + fakeNewID,
+		"remove " + fakeNewID + " force=true",
+		"rename " + fakeOldID + " media-jellyfin-1",
+		"start " + fakeOldID,
+	}
+	if !reflect.DeepEqual(f.calls, want) {
+		t.Errorf("calls =\n%s\nwant\n%s", strings.Join(f.calls, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRecreate_NewContainerExitsRollsBack(t *testing.T) {
+	f := newFakeRecreateClient()
+	f.newStates = []container.State{{Running: true, Status: "running"}, {Status: "exited", ExitCode: 1}}
+
+	_, err := recreateWith(t, f)
+	if err == nil || !strings.Contains(err.Error(), "exit code 1") {
+		t.Fatalf("recreate() = %v, want exit code in error", err)
+	}
+
+	last := f.calls[len(f.calls)-3:]
+	want := []string{
+		"remove " + fakeNewID + " force=true",
+		"rename " + fakeOldID + " media-jellyfin-1",
+		"start " + fakeOldID,
+	}
+	if !reflect.DeepEqual(last, want) {
+		t.Errorf("rollback calls = %v, want %v", last, want)
+	}
+}
+
+func TestRecreate_FailedCreateRestoresOriginal(t *testing.T) {
+	f := newFakeRecreateClient()
+	f.createErr = errors.New("no such image")
+
+	_, err := recreateWith(t, f)
+	if err == nil {
+		t.Fatal("recreate() = nil, want error")
+	}
+
+	last := f.calls[len(f.calls)-2:]
+	want := []string{
+		"rename " + fakeOldID + " media-jellyfin-1",
+		"start " + fakeOldID,
+	}
+	if !reflect.DeepEqual(last, want) {
+		t.Errorf("rollback calls = %v, want %v", last, want)
+	}
+	for _, call := range f.calls {
+		if strings.HasPrefix(call, "remove") {
+			t.Errorf("unexpected %q with no new container created", call)
+		}
+	}
+}
+
+func TestRecreate_StoppedOriginalIsNotRestarted(t *testing.T) {
+	f := newFakeRecreateClient()
+	f.original.State = &container.State{Status: "exited"}
+	f.startNewErr = errors.New("boom")
+
+	if _, err := recreateWith(t, f); err == nil {
+		t.Fatal("recreate() = nil, want error")
+	}
+	if last := f.calls[len(f.calls)-1]; last != "rename "+fakeOldID+" media-jellyfin-1" {
+		t.Errorf("last call = %q, want the original's name restored without starting it", last)
+	}
+}
+
+func TestRecreate_RollbackFailureIsReported(t *testing.T) {
+	f := newFakeRecreateClient()
+	f.startNewErr = errors.New("boom")
+	f.removeNewErr = errors.New("device busy")
+
+	_, err := recreateWith(t, f)
+	if err == nil || !strings.Contains(err.Error(), "rollback failed") || !strings.Contains(err.Error(), "device busy") {
+		t.Fatalf("recreate() = %v, want rollback failure", err)
+	}
+	// The name is still held by the new container, so it can't be restored
+	for _, call := range f.calls {
+		if call == "rename "+fakeOldID+" media-jellyfin-1" {
+			t.Error("original renamed back while the new container still exists")
+		}
+	}
+}
+
+func TestRecreate_OldRemovalFailureIsWarning(t *testing.T) {
+	f := newFakeRecreateClient()
+	f.removeOldErr = errors.New("in use")
+
+	progress, err := recreateWith(t, f)
+	if err != nil {
+		t.Fatalf("recreate() = %v, want success", err)
+	}
+	if last := progress[len(progress)-1]; !strings.Contains(last, "Warning") {
+		t.Errorf("last progress = %q, want warning", last)
+	}
+}
+
+func TestValidateEnvOverrides(t *testing.T) {
+	tests := []struct {
+		overrides map[string]string
+		wantErr   bool
+	}{
+		{map[string]string{"LOG_LEVEL": "debug"}, false},
+		{map[string]string{"EMPTY": ""}, false},
+		{nil, true},
+		{map[string]string{"": "x"}, true},
+		{map[string]string{"A=B": "x"}, true},
+		{map[string]string{"A B": "x"}, true},
+	}
+	for _, tt := range tests {
+		if err := ValidateEnvOverrides(tt.overrides); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateEnvOverrides(%v) = %v, wantErr %v", tt.overrides, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMergeEnv(t *testing.T) {
+	got := mergeEnv([]string{"A=1", "B=2", "NOVALUE"}, map[string]string{"B": "3", "Z": "9", "C": "8"})
+	want := []string{"A=1", "B=3", "NOVALUE", "C=8", "Z=9"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeEnv() = %v, want %v", got, want)
+	}
+}
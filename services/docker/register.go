@@ -0,0 +1,30 @@
+package docker
+
+import (
+	"home_server_dashboard/config"
+	"home_server_dashboard/services"
+)
+
+func init() {
+	services.Register(services.Registration{
+		Source: "docker",
+		Order:  10,
+		Configured: func(host *config.HostConfig) bool {
+			return host.HasDocker()
+		},
+		Factory:      newProviderForHost,
+		Capabilities: services.Capabilities{Logs: true, Actions: true, NeedsClose: true, LocalOnly: true},
+	})
+}
+
+// newProviderForHost creates the Docker provider for the local host.
+func newProviderForHost(cfg *config.Config, host *config.HostConfig) (services.Provider, error) {
+	provider, err := NewProvider(host.Name)
+	if err != nil {
+		return nil, err
+	}
+	provider.SetImageStaleAfter(cfg.GetImageStaleAfter())
+	provider.SetComposeChangeDetection(cfg.GetComposeChangeDetection())
+	provider.SetComposeRoots(host.DockerComposeRoots, host.DockerComposeRootsGlob)
+	return provider, nil
+}
@@ -0,0 +1,48 @@
+package docker
+
+import (
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+
+	"home_server_dashboard/services"
+)
+
+// MapState maps a container's state and health check status to a service
+// state. health is empty for containers without a health check.
+func MapState(state, health string) services.State {
+	switch state {
+	case container.StateRunning:
+		switch health {
+		case container.Unhealthy:
+			return services.StateUnhealthy
+		case container.Starting:
+			return services.StateStarting
+		}
+		return services.StateRunning
+	case container.StatePaused:
+		return services.StatePaused
+	case container.StateRestarting:
+		return services.StateStarting
+	case container.StateRemoving:
+		return services.StateStopping
+	case container.StateCreated, container.StateExited, container.StateDead:
+		return services.StateStopped
+	}
+	return services.StateUnknown
+}
+
+// HealthFromStatus extracts the health check status from a container's
+// status text, e.g. "Up 2 minutes (health: starting)". Container lists carry
+// health only in the status text.
+func HealthFromStatus(status string) string {
+	switch {
+	case strings.HasSuffix(status, "(unhealthy)"):
+		return container.Unhealthy
+	case strings.HasSuffix(status, "(healthy)"):
+		return container.Healthy
+	case strings.HasSuffix(status, "(health: starting)"):
+		return container.Starting
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package docker
+
+import (
+	"testing"
+
+	"home_server_dashboard/services"
+)
+
+func TestMapState(t *testing.T) {
+	tests := []struct {
+		state    string
+		health   string
+		expected services.State
+	}{
+		{"running", "", services.StateRunning},
+		{"running", "healthy", services.StateRunning},
+		{"running", "none", services.StateRunning},
+		{"running", "starting", services.StateStarting},
+		{"running", "unhealthy", services.StateUnhealthy},
+		{"paused", "", services.StatePaused},
+		{"paused", "unhealthy", services.StatePaused},
+		{"restarting", "", services.StateStarting},
+		{"removing", "", services.StateStopping},
+		{"created", "", services.StateStopped},
+		{"exited", "", services.StateStopped},
+		{"dead", "", services.StateStopped},
+		{"", "", services.StateUnknown},
+		{"zombie", "", services.StateUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.state+"/"+tt.health, func(t *testing.T) {
+			if got := MapState(tt.state, tt.health); got != tt.expected {
+				t.Errorf("MapState(%q, %q) = %q, want %q", tt.state, tt.health, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHealthFromStatus(t *testing.T) {
+	tests := []struct {
+		status   string
+		expected string
+	}{
+		{"Up 2 hours (healthy)", "healthy"},
+		{"Up 2 hours (unhealthy)", "unhealthy"},
+		{"Up 3 seconds (health: starting)", "starting"},
+		{"Up 2 hours", ""},
+		{"Exited (1) 5 minutes ago", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := HealthFromStatus(tt.status); got != tt.expected {
+			t.Errorf("HealthFromStatus(%q) = %q, want %q", tt.status, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ProviderError reports that a provider could not be reached or did not
+// respond before its deadline. Callers use it to mark the provider as
+// unavailable instead of failing the whole request.
+type ProviderError struct {
+	Provider string // Provider name (e.g., "docker", "systemd")
+	Host     string // Host name from config
+	Err      error  // Underlying error
+}
+
+// NewProviderError wraps err as a ProviderError for the given provider and host.
+func NewProviderError(provider, host string, err error) *ProviderError {
+	return &ProviderError{
+		Provider: provider,
+		Host:     host,
+		Err:      err,
+	}
+}
+
+// Error implements the error interface.
+func (e *ProviderError) Error() string {
+	if e.Timeout() {
+		return fmt.Sprintf("%s provider on %s unavailable: timed out", e.Provider, e.Host)
+	}
+	return fmt.Sprintf("%s provider on %s unavailable: %v", e.Provider, e.Host, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout returns true if the provider failed because its deadline expired.
+func (e *ProviderError) Timeout() bool {
+	return errors.Is(e.Err, context.DeadlineExceeded)
+}
@@ -0,0 +1,246 @@
+// Package hatest serves a fake Home Assistant and Supervisor API for tests,
+// with a few add-ons, their logs and info about the Supervisor, Core and
+// host.
+package hatest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Jobs the fake Supervisor runs, which each poll of GET /jobs/{uuid} moves
+// along one step:
+//   - JobAddonUpdate, started by updating esphome, reports its progress
+//     through two stages and completes.
+//   - JobBackup, started by a full backup, fails.
+//   - JobVanishing, started by restarting the ssh add-on, runs once and is
+//     then gone, as after a Supervisor restart.
+const (
+	JobAddonUpdate = "job-addon-update"
+	JobBackup      = "job-backup"
+	JobVanishing   = "job-vanishing"
+)
+
+// jobSteps are the states each job reports on successive polls; past the
+// last, a job keeps reporting it, unless it is nil and the job is gone.
+var jobSteps = map[string][]map[string]interface{}{
+	JobAddonUpdate: {
+		{"progress": 0, "stage": "pull", "done": false},
+		{"progress": 50, "stage": "pull", "done": false},
+		{"progress": 100, "stage": "start", "done": true},
+	},
+	JobBackup: {
+		{"progress": 10, "stage": "addons", "done": false},
+		{"progress": 10, "stage": "addons", "done": true, "errors": []map[string]string{
+			{"type": "BackupError", "message": "No space left on device"},
+		}},
+	},
+	JobVanishing: {
+		{"progress": 0, "stage": nil, "done": false},
+		nil,
+	},
+}
+
+// jobNames are the Supervisor's names of the fake jobs.
+var jobNames = map[string]string{
+	JobAddonUpdate: "addon_manager_update",
+	JobBackup:      "backup_manager_full_backup",
+	JobVanishing:   "addon_restart",
+}
+
+// NewServer starts a fake Home Assistant API, answering its health check at
+// /api/, and Supervisor API. Requests need a bearer token, any will do.
+// The caller closes it.
+func NewServer() *httptest.Server {
+	var mu sync.Mutex
+	polls := make(map[string]int) // by the UUID of each job started
+	// job returns the state of a started job, nil if it's gone, advancing it
+	// a step if poll is set. mu must be held.
+	job := func(uuid string, poll bool) map[string]interface{} {
+		n, ok := polls[uuid]
+		if !ok {
+			return nil
+		}
+		step := jobSteps[uuid][min(n, len(jobSteps[uuid])-1)]
+		if poll {
+			polls[uuid]++
+		}
+		if step == nil {
+			return nil
+		}
+		state := map[string]interface{}{"uuid": uuid, "name": jobNames[uuid], "errors": []interface{}{}, "child_jobs": []interface{}{}}
+		for k, v := range step {
+			state[k] = v
+		}
+		return state
+	}
+	startJob := func(w http.ResponseWriter, r *http.Request, uuid string) {
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		mu.Lock()
+		polls[uuid] = 0
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"result": "ok", "data": map[string]string{"job_id": uuid}})
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Check authorization header
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/api/":
+			// The Home Assistant API's health check
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{"message": "API running."})
+		case "/addons":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"result": "ok",
+				"data": map[string]interface{}{
+					"addons": []map[string]interface{}{
+						{
+							"slug":        "esphome",
+							"name":        "ESPHome",
+							"description": "ESPHome addon for Home Assistant",
+							"state":       "started",
+							"version":     "2024.1.0",
+							"installed":   true,
+							"available":   true,
+						},
+						{
+							"slug":        "ssh",
+							"name":        "SSH & Web Terminal",
+							"description": "SSH server addon",
+							"state":       "stopped",
+							"version":     "9.9.0",
+							"installed":   true,
+							"available":   true,
+						},
+						{
+							"slug":        "notinstalled",
+							"name":        "Not Installed",
+							"description": "Not installed addon",
+							"state":       "unknown",
+							"version":     "1.0.0",
+							"installed":   false,
+							"available":   true,
+						},
+					},
+				},
+			})
+		case "/addons/esphome/logs":
+			w.Write([]byte("ESPHome log line 1\nESPHome log line 2\n"))
+		case "/addons/esphome/logs/follow":
+			w.Write([]byte("ESPHome streaming log\n"))
+		case "/addons/esphome/restart", "/addons/esphome/start", "/addons/esphome/stop":
+			if r.Method == "POST" {
+				json.NewEncoder(w).Encode(map[string]string{"result": "ok"})
+			} else {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/addons/esphome/update":
+			startJob(w, r, JobAddonUpdate)
+		case "/addons/ssh/restart":
+			startJob(w, r, JobVanishing)
+		case "/backups/new/full":
+			startJob(w, r, JobBackup)
+		case "/jobs/info":
+			mu.Lock()
+			jobs := []map[string]interface{}{}
+			for uuid := range polls {
+				if state := job(uuid, false); state != nil {
+					jobs = append(jobs, state)
+				}
+			}
+			mu.Unlock()
+			json.NewEncoder(w).Encode(map[string]interface{}{"result": "ok", "data": map[string]interface{}{"jobs": jobs}})
+		case "/core/start", "/core/stop", "/core/restart":
+			if r.Method == "POST" {
+				json.NewEncoder(w).Encode(map[string]string{"result": "ok"})
+			} else {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/core/logs":
+			w.Write([]byte("Core log line 1\nCore log line 2\n"))
+		case "/supervisor/logs":
+			w.Write([]byte("Supervisor log line 1\n"))
+		case "/host/logs":
+			w.Write([]byte("Host log line 1\n"))
+		case "/supervisor/info":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"result": "ok",
+				"data": map[string]interface{}{
+					"version": "2024.01.0",
+					"healthy": true,
+					"channel": "stable",
+				},
+			})
+		case "/core/info":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"result": "ok",
+				"data": map[string]interface{}{
+					"version": "2024.1.0",
+					"state":   "running",
+				},
+			})
+		case "/host/info":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"result": "ok",
+				"data": map[string]interface{}{
+					"hostname":         "homeassistant",
+					"operating_system": "Home Assistant OS 11.0",
+					"kernel":           "6.1.0",
+				},
+			})
+		default:
+			if uuid, ok := strings.CutPrefix(r.URL.Path, "/jobs/"); ok {
+				mu.Lock()
+				state := job(uuid, true)
+				mu.Unlock()
+				if state == nil {
+					w.WriteHeader(http.StatusBadRequest)
+					json.NewEncoder(w).Encode(map[string]string{"result": "error", "message": "No job found with id " + uuid})
+					return
+				}
+				json.NewEncoder(w).Encode(map[string]interface{}{"result": "ok", "data": state})
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+// Transport sends every request to the server at URL, keeping its path, so
+// clients built for http://supervisor or a host's Home Assistant port reach
+// a test server.
+type Transport struct {
+	URL string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Rewrite the URL to point to the test server
+	newURL := t.URL + req.URL.Path
+	newReq, err := http.NewRequestWithContext(req.Context(), req.Method, newURL, req.Body)
+	if err != nil {
+		return nil, err
+	}
+	// Copy headers
+	for k, v := range req.Header {
+		newReq.Header[k] = v
+	}
+	return http.DefaultClient.Do(newReq)
+}
+
+// Client returns an HTTP client whose requests all go to the server at url.
+func Client(url string) *http.Client {
+	return &http.Client{Transport: &Transport{URL: url}}
+}
@@ -7,7 +7,6 @@ package homeassistant
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -18,14 +17,19 @@ import (
 	"os/user"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/ssh"
 
+	goclient "github.com/mutablelogic/go-client"
 	ha "github.com/mutablelogic/go-client/pkg/homeassistant"
 
 	"home_server_dashboard/config"
+	"home_server_dashboard/connlimit"
+	"home_server_dashboard/httpclient"
 	"home_server_dashboard/services"
+	"home_server_dashboard/version"
 )
 
 // Addon represents a Home Assistant addon from the Supervisor API.
@@ -79,6 +83,15 @@ type HostInfo struct {
 	} `json:"data"`
 }
 
+// Limits for the Supervisor API calls GetServices makes through the SSH tunnel.
+const (
+	// supervisorConcurrency is how many calls run at once, each on its own
+	// channel of the tunnel's SSH connection.
+	supervisorConcurrency = 3
+	// supervisorCallTimeout bounds each call so a slow endpoint can't hold up the list.
+	supervisorCallTimeout = 5 * time.Second
+)
+
 // Provider implements the services.Provider interface for Home Assistant.
 type Provider struct {
 	hostConfig       *config.HostConfig
@@ -87,6 +100,28 @@ type Provider struct {
 	sshClient        *ssh.Client   // SSH connection for tunneling
 	supervisorToken  string        // Token from SUPERVISOR_TOKEN env var
 	hostName         string
+	container        ContainerDelegate // Docker access to the HA container, for Container installs
+}
+
+// ContainerDelegate streams the logs of and controls Docker containers on the
+// dashboard's host. Home Assistant Container and Supervised installs use it for
+// the core service when the Supervisor API isn't available. The docker
+// provider implements it; since this package can't import that one, handlers
+// wires it up with SetContainerDelegate.
+type ContainerDelegate interface {
+	GetLogs(ctx context.Context, containerName string, tailLines int, follow bool) (io.ReadCloser, error)
+	GetService(containerName string) (services.Service, error)
+	Close() error
+}
+
+// newContainerDelegate creates the ContainerDelegate of a host; nil until
+// SetContainerDelegate is called.
+var newContainerDelegate func(host *config.HostConfig) (ContainerDelegate, error)
+
+// SetContainerDelegate sets how providers reach the Docker container of Home
+// Assistant Container installs on the local host.
+func SetContainerDelegate(factory func(host *config.HostConfig) (ContainerDelegate, error)) {
+	newContainerDelegate = factory
 }
 
 // Service implements the services.Service interface for Home Assistant.
@@ -122,6 +157,17 @@ func (t *sshTunnelTransport) RoundTrip(req *http.Request) (*http.Response, error
 	return transport.RoundTrip(req)
 }
 
+// acquireSSHSlot waits for a free SSH session slot for address.
+// NewProvider has no caller context, so the wait is bounded by sshSlotTimeout.
+func acquireSSHSlot(address string) (func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sshSlotTimeout)
+	defer cancel()
+	return connlimit.Acquire(ctx, address)
+}
+
+// sshSlotTimeout bounds how long NewProvider waits for a free SSH session slot.
+const sshSlotTimeout = 30 * time.Second
+
 // getSSHClientConfig returns SSH client configuration using the user's default keys.
 func getSSHClientConfig() (*ssh.ClientConfig, error) {
 	// Get user's home directory for SSH keys
@@ -194,32 +240,9 @@ func fetchSupervisorToken(client *ssh.Client) (string, error) {
 // NewProvider creates a new Home Assistant provider for the given host config.
 // Returns nil if Home Assistant is not configured for this host.
 func NewProvider(hostConfig *config.HostConfig) (*Provider, error) {
-	if !hostConfig.HasHomeAssistant() {
-		return nil, nil
-	}
-
-	endpoint := hostConfig.GetHomeAssistantEndpoint()
-	if endpoint == "" {
-		return nil, fmt.Errorf("failed to build Home Assistant endpoint for host %s", hostConfig.Name)
-	}
-
-	haClient, err := ha.New(endpoint, hostConfig.HomeAssistant.LongLivedToken)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Home Assistant client: %w", err)
-	}
-
-	// If using HTTPS with certificate verification disabled, create an isolated
-	// transport instead of using OptSkipVerify() which modifies the global http.DefaultTransport
-	if hostConfig.HomeAssistant.UseHTTPS && hostConfig.HomeAssistant.IgnoreHTTPSErrors {
-		haClient.Client.Transport = &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-	}
-
-	provider := &Provider{
-		hostConfig: hostConfig,
-		client:     haClient,
-		hostName:   hostConfig.Name,
+	provider, err := newProvider(hostConfig)
+	if provider == nil {
+		return nil, err
 	}
 
 	// Set up Supervisor API access via SSH tunnel if HAOS is configured
@@ -229,16 +252,20 @@ func NewProvider(hostConfig *config.HostConfig) (*Provider, error) {
 		if err != nil {
 			log.Printf("Warning: Failed to get SSH config for %s: %v", hostConfig.Name, err)
 		} else {
-			// Connect to SSH addon
+			// Connect to SSH addon, holding an SSH slot until the token is fetched
 			sshAddr := fmt.Sprintf("%s:%d", hostConfig.Address, hostConfig.GetSSHAddonPort())
-			sshClient, err := ssh.Dial("tcp", sshAddr, sshConfig)
+			release, err := acquireSSHSlot(hostConfig.Address)
 			if err != nil {
 				log.Printf("Warning: Failed to connect to SSH addon at %s: %v", sshAddr, err)
+			} else if sshClient, err := ssh.Dial("tcp", sshAddr, sshConfig); err != nil {
+				release()
+				log.Printf("Warning: Failed to connect to SSH addon at %s: %v", sshAddr, err)
 			} else {
 				provider.sshClient = sshClient
 
 				// Fetch SUPERVISOR_TOKEN from the SSH addon container
 				supervisorToken, err := fetchSupervisorToken(sshClient)
+				release()
 				if err != nil {
 					log.Printf("Warning: Failed to fetch SUPERVISOR_TOKEN from %s: %v", sshAddr, err)
 					sshClient.Close()
@@ -259,17 +286,91 @@ func NewProvider(hostConfig *config.HostConfig) (*Provider, error) {
 		}
 	}
 
+	// Without the Supervisor API, a local HA container is reached through Docker
+	if !provider.HasSupervisorAPI() && hostConfig.HomeAssistant.ContainerName != "" && hostConfig.IsLocal() && newContainerDelegate != nil {
+		container, err := newContainerDelegate(hostConfig)
+		if err != nil {
+			log.Printf("Warning: Failed to reach Home Assistant container %s on %s: %v", hostConfig.HomeAssistant.ContainerName, hostConfig.Name, err)
+		} else {
+			provider.container = container
+		}
+	}
+
 	return provider, nil
 }
 
-// Close closes any open connections (SSH tunnel).
+// NewProviderWithClients creates a Home Assistant provider that reaches the
+// Home Assistant API through core and, for HAOS hosts, the Supervisor API
+// through supervisor with token, instead of tunneling to the host. Tests use
+// it to point a provider at fake servers.
+func NewProviderWithClients(hostConfig *config.HostConfig, core, supervisor *http.Client, token string) (*Provider, error) {
+	provider, err := newProvider(hostConfig)
+	if provider == nil {
+		return nil, err
+	}
+	provider.client.Client.Client = core
+	if hostConfig.HasSupervisorAPI() {
+		provider.supervisorClient = supervisor
+		provider.supervisorToken = token
+	}
+	return provider, nil
+}
+
+// newProvider creates a provider with a Home Assistant API client for
+// hostConfig and no Supervisor API access.
+func newProvider(hostConfig *config.HostConfig) (*Provider, error) {
+	if !hostConfig.HasHomeAssistant() {
+		return nil, nil
+	}
+
+	endpoint := hostConfig.GetHomeAssistantEndpoint()
+	if endpoint == "" {
+		return nil, fmt.Errorf("failed to build Home Assistant endpoint for host %s", hostConfig.Name)
+	}
+
+	haClient, err := ha.New(endpoint, hostConfig.HomeAssistant.LongLivedToken, goclient.OptUserAgent(version.UserAgent()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Home Assistant client: %w", err)
+	}
+
+	// Connect with the host's outbound settings, through a transport of its own
+	// rather than OptSkipVerify(), which modifies the global http.DefaultTransport.
+	// ignore_https_errors predates outbound.insecure and still skips certificate checks.
+	outbound := config.Get().OutboundFor(hostConfig)
+	if hostConfig.HomeAssistant.UseHTTPS && hostConfig.HomeAssistant.IgnoreHTTPSErrors {
+		outbound.Insecure = true
+	}
+	haClient.Client.Transport = httpclient.Transport(outbound)
+
+	return &Provider{
+		hostConfig: hostConfig,
+		client:     haClient,
+		hostName:   hostConfig.Name,
+	}, nil
+}
+
+// Close closes any open connections (SSH tunnel, Docker client).
 func (p *Provider) Close() error {
+	if p.container != nil {
+		p.container.Close()
+	}
 	if p.sshClient != nil {
 		return p.sshClient.Close()
 	}
 	return nil
 }
 
+// UsesContainer reports whether the core service's logs and actions go
+// through Docker, for Container installs without the Supervisor API.
+func (p *Provider) UsesContainer() bool {
+	return p.container != nil && !p.HasSupervisorAPI()
+}
+
+// coreContainer returns the Docker service of the HA container.
+func (p *Provider) coreContainer() (services.Service, error) {
+	return p.container.GetService(p.hostConfig.HomeAssistant.ContainerName)
+}
+
 // Name returns the provider name.
 func (p *Provider) Name() string {
 	return "homeassistant"
@@ -279,32 +380,83 @@ func (p *Provider) Name() string {
 // For standard HA, returns just the core HA service.
 // For HAOS, returns Core, Supervisor, Host, and all installed Addons.
 func (p *Provider) GetServices(ctx context.Context) ([]services.ServiceInfo, error) {
-	// Always include the core HA service
-	coreInfo, err := p.getServiceInfo(ctx)
-	if err != nil {
-		return nil, err
-	}
-
 	// If not HAOS, just return the core service
 	if !p.HasSupervisorAPI() {
+		coreInfo, err := p.getServiceInfo(ctx)
+		if err != nil {
+			return nil, err
+		}
 		return []services.ServiceInfo{coreInfo}, nil
 	}
 
-	// For HAOS, get additional services
-	servicesList := []services.ServiceInfo{coreInfo}
+	// For HAOS, the health check and the Supervisor API calls run concurrently
+	// so each round trip through the SSH tunnel doesn't add to the refresh.
+	// Supervisor and host info rarely change and come from the cache when fresh.
+	supervisorInfo, hostInfo := p.cachedInfo()
+	var (
+		wg                sync.WaitGroup
+		coreInfo          services.ServiceInfo
+		coreErr           error
+		addons            []Addon
+		addonsErr         error
+		fetchedSupervisor *SupervisorInfo
+		supervisorErr     error
+		fetchedHost       *HostInfo
+		hostErr           error
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		coreInfo, coreErr = p.getServiceInfo(ctx)
+	}()
+
+	slots := make(chan struct{}, supervisorConcurrency)
+	supervisorCall := func(call func(ctx context.Context)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				return
+			}
+			callCtx, cancel := context.WithTimeout(ctx, supervisorCallTimeout)
+			defer cancel()
+			call(callCtx)
+		}()
+	}
+	// Supervisor or host info that can't be fetched leaves its state unknown
+	if supervisorInfo == nil {
+		supervisorCall(func(ctx context.Context) { fetchedSupervisor, supervisorErr = p.GetSupervisorInfo(ctx) })
+	}
+	if hostInfo == nil {
+		supervisorCall(func(ctx context.Context) { fetchedHost, hostErr = p.GetHostInfo(ctx) })
+	}
+	supervisorCall(func(ctx context.Context) { addons, addonsErr = p.GetAddons(ctx) })
+	wg.Wait()
 
-	// Add Supervisor service
-	supervisorInfo := p.getSupervisorServiceInfo(ctx)
-	servicesList = append(servicesList, supervisorInfo)
+	if coreErr != nil {
+		return nil, coreErr
+	}
+	p.storeInfo(fetchedSupervisor, fetchedHost)
+	if fetchedSupervisor != nil {
+		supervisorInfo = fetchedSupervisor
+	}
+	if fetchedHost != nil {
+		hostInfo = fetchedHost
+	}
 
-	// Add Host service
-	hostInfo := p.getHostServiceInfo(ctx)
-	servicesList = append(servicesList, hostInfo)
+	servicesList := []services.ServiceInfo{
+		coreInfo,
+		p.supervisorServiceInfo(supervisorInfo, supervisorErr),
+		p.hostServiceInfo(hostInfo, hostErr),
+	}
 
 	// Add all installed addons
-	addons, err := p.GetAddons(ctx)
-	if err != nil {
-		log.Printf("Failed to get addons from %s: %v", p.hostName, err)
+	if addonsErr != nil {
+		log.Printf("Failed to get addons from %s: %v", p.hostName, addonsErr)
 	} else {
 		for _, addon := range addons {
 			addonInfo := p.addonToServiceInfo(addon)
@@ -315,6 +467,63 @@ func (p *Provider) GetServices(ctx context.Context) ([]services.ServiceInfo, err
 	return servicesList, nil
 }
 
+// infoCacheEntry holds the last Supervisor and host info fetched for a host.
+type infoCacheEntry struct {
+	supervisor   *SupervisorInfo
+	supervisorAt time.Time
+	host         *HostInfo
+	hostAt       time.Time
+}
+
+var (
+	infoCacheMu sync.Mutex
+	infoCache   = make(map[string]infoCacheEntry) // key: host name
+)
+
+// infoMaxAge returns how long cached Supervisor and host info are used:
+// the host's poll interval, so each poll fetches them at most once.
+func (p *Provider) infoMaxAge() time.Duration {
+	return p.hostConfig.GetPollInterval(config.Get().GetPollInterval())
+}
+
+// cachedInfo returns the cached Supervisor and host info of this host, or nil
+// for each that is missing or older than infoMaxAge.
+func (p *Provider) cachedInfo() (*SupervisorInfo, *HostInfo) {
+	infoCacheMu.Lock()
+	defer infoCacheMu.Unlock()
+
+	entry := infoCache[p.hostName]
+	maxAge := p.infoMaxAge()
+	var supervisorInfo *SupervisorInfo
+	var hostInfo *HostInfo
+	if entry.supervisor != nil && time.Since(entry.supervisorAt) < maxAge {
+		supervisorInfo = entry.supervisor
+	}
+	if entry.host != nil && time.Since(entry.hostAt) < maxAge {
+		hostInfo = entry.host
+	}
+	return supervisorInfo, hostInfo
+}
+
+// storeInfo caches freshly fetched Supervisor and host info; nil values are ignored.
+func (p *Provider) storeInfo(supervisorInfo *SupervisorInfo, hostInfo *HostInfo) {
+	if supervisorInfo == nil && hostInfo == nil {
+		return
+	}
+	infoCacheMu.Lock()
+	defer infoCacheMu.Unlock()
+
+	entry := infoCache[p.hostName]
+	now := time.Now()
+	if supervisorInfo != nil {
+		entry.supervisor, entry.supervisorAt = supervisorInfo, now
+	}
+	if hostInfo != nil {
+		entry.host, entry.hostAt = hostInfo, now
+	}
+	infoCache[p.hostName] = entry
+}
+
 // GetService returns a specific service by name.
 func (p *Provider) GetService(name string) (services.Service, error) {
 	// Core HA service
@@ -363,14 +572,21 @@ func (p *Provider) GetService(name string) (services.Service, error) {
 	return nil, fmt.Errorf("service not found: %s", name)
 }
 
-// getSupervisorServiceInfo builds ServiceInfo for the Supervisor.
+// getSupervisorServiceInfo fetches the Supervisor info and builds its ServiceInfo.
 func (p *Provider) getSupervisorServiceInfo(ctx context.Context) services.ServiceInfo {
+	supervisorInfo, err := p.GetSupervisorInfo(ctx)
+	return p.supervisorServiceInfo(supervisorInfo, err)
+}
+
+// supervisorServiceInfo builds ServiceInfo for the Supervisor. supervisorInfo
+// is nil if it couldn't be fetched, with err saying why; the Supervisor's
+// state is then unknown.
+func (p *Provider) supervisorServiceInfo(supervisorInfo *SupervisorInfo, err error) services.ServiceInfo {
 	info := services.ServiceInfo{
 		Name:          "ha-supervisor",
 		Project:       "homeassistant",
 		ContainerName: "hassio_supervisor",
-		State:         "running",
-		Status:        "Running",
+		State:         services.StateRunning,
 		Image:         "-",
 		Source:        "homeassistant",
 		Host:          p.hostName,
@@ -378,26 +594,35 @@ func (p *Provider) getSupervisorServiceInfo(ctx context.Context) services.Servic
 		Description:   "Home Assistant Supervisor",
 	}
 
-	// Try to get supervisor info for version
-	if supervisorInfo, err := p.GetSupervisorInfo(ctx); err == nil {
-		info.Status = fmt.Sprintf("v%s", supervisorInfo.Data.Version)
-		if !supervisorInfo.Data.Healthy {
-			info.State = "unhealthy"
-			info.Status = "Unhealthy - " + info.Status
-		}
+	if supervisorInfo == nil {
+		info.State, info.Status = services.StateUnknown, unknownStatus(err)
+		return info
+	}
+
+	// Use the supervisor info for the version
+	info.Status = fmt.Sprintf("v%s", supervisorInfo.Data.Version)
+	if !supervisorInfo.Data.Healthy {
+		info.State = services.StateUnhealthy
+		info.Status = "Unhealthy - " + info.Status
 	}
 
 	return info
 }
 
-// getHostServiceInfo builds ServiceInfo for the Host OS.
+// getHostServiceInfo fetches the host info and builds its ServiceInfo.
 func (p *Provider) getHostServiceInfo(ctx context.Context) services.ServiceInfo {
+	hostInfo, err := p.GetHostInfo(ctx)
+	return p.hostServiceInfo(hostInfo, err)
+}
+
+// hostServiceInfo builds ServiceInfo for the Host OS. hostInfo is nil if it
+// couldn't be fetched, with err saying why; the host's state is then unknown.
+func (p *Provider) hostServiceInfo(hostInfo *HostInfo, err error) services.ServiceInfo {
 	info := services.ServiceInfo{
 		Name:          "ha-host",
 		Project:       "homeassistant",
 		ContainerName: "host",
-		State:         "running",
-		Status:        "Running",
+		State:         services.StateRunning,
 		Image:         "-",
 		Source:        "homeassistant",
 		Host:          p.hostName,
@@ -405,19 +630,31 @@ func (p *Provider) getHostServiceInfo(ctx context.Context) services.ServiceInfo
 		Description:   "Home Assistant OS Host",
 	}
 
-	// Try to get host info
-	if hostInfo, err := p.GetHostInfo(ctx); err == nil {
-		info.Status = fmt.Sprintf("%s (%s)", hostInfo.Data.OperatingSystem, hostInfo.Data.Kernel)
-		info.Description = fmt.Sprintf("Host: %s", hostInfo.Data.Hostname)
+	if hostInfo == nil {
+		info.State, info.Status = services.StateUnknown, unknownStatus(err)
+		return info
 	}
 
+	info.Status = fmt.Sprintf("%s (%s)", hostInfo.Data.OperatingSystem, hostInfo.Data.Kernel)
+	info.Description = fmt.Sprintf("Host: %s", hostInfo.Data.Hostname)
+
 	return info
 }
 
+// unknownStatus is the status of a service whose info couldn't be fetched
+// because of err.
+func unknownStatus(err error) string {
+	if err == nil {
+		return "Unknown"
+	}
+	return "Unknown: " + err.Error()
+}
+
 // addonToServiceInfo converts an Addon to ServiceInfo.
 func (p *Provider) addonToServiceInfo(addon Addon) services.ServiceInfo {
 	return services.ServiceInfo{
 		Name:          "addon-" + addon.Slug,
+		DisplayName:   addon.Name,
 		Project:       "homeassistant-addons",
 		ContainerName: "addon_" + addon.Slug,
 		State:         addonStateToServiceState(addon.State),
@@ -432,7 +669,8 @@ func (p *Provider) addonToServiceInfo(addon Addon) services.ServiceInfo {
 
 // GetLogs returns logs for the specified service.
 // For HAOS, supports Core, Supervisor, Host, and Addon logs.
-// For non-HAOS, returns a stub message.
+// For Container installs with a container_name, streams the core container's logs.
+// Otherwise, returns a stub message.
 func (p *Provider) GetLogs(ctx context.Context, serviceName string, tailLines int, follow bool) (io.ReadCloser, error) {
 	// If we have Supervisor API access, route to appropriate log source
 	if p.HasSupervisorAPI() {
@@ -449,6 +687,11 @@ func (p *Provider) GetLogs(ctx context.Context, serviceName string, tailLines in
 		}
 	}
 
+	// Container installs stream the core container's logs through Docker
+	if p.UsesContainer() && (serviceName == "homeassistant" || serviceName == "ha-core") {
+		return p.container.GetLogs(ctx, p.hostConfig.HomeAssistant.ContainerName, tailLines, follow)
+	}
+
 	// Fallback message for non-HAOS or unknown service
 	msg := `═══════════════════════════════════════════════════════════════
 Logs are not available for Home Assistant via this dashboard.
@@ -464,18 +707,34 @@ Home Assistant logs can be viewed through:
 }
 
 // CheckHealth checks if the Home Assistant API is reachable.
-// Returns "running" if healthy, "stopped" if unreachable.
-func (p *Provider) CheckHealth(ctx context.Context) (state, status string, err error) {
+// Returns StateRunning if healthy, StateUnknown if unreachable: an API that
+// doesn't answer doesn't say whether Home Assistant is running.
+func (p *Provider) CheckHealth(ctx context.Context) (state services.State, status string, err error) {
 	msg, err := p.client.Health(ctx)
 	if err != nil {
-		return "stopped", "Unreachable: " + err.Error(), err
+		return services.StateUnknown, "Unreachable: " + err.Error(), err
 	}
 
 	if msg == "API running." {
-		return "running", "API running", nil
+		return services.StateRunning, "API running", nil
 	}
 
-	return "running", msg, nil
+	return services.StateRunning, msg, nil
+}
+
+// PingSupervisor checks that the Supervisor API is reachable and accepts the token.
+func (p *Provider) PingSupervisor(ctx context.Context) error {
+	resp, err := p.supervisorRequest(ctx, "GET", "/supervisor/ping", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("supervisor ping returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
 }
 
 // getServiceInfo builds the ServiceInfo for the Home Assistant instance.
@@ -556,6 +815,7 @@ func (p *Provider) supervisorRequest(ctx context.Context, method, path string, b
 	}
 
 	req.Header.Set("Authorization", "Bearer "+p.supervisorToken)
+	req.Header.Set("User-Agent", version.UserAgent())
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 
@@ -580,6 +840,7 @@ func (p *Provider) supervisorLogsRequest(ctx context.Context, path string) (*htt
 	}
 
 	req.Header.Set("Authorization", "Bearer "+p.supervisorToken)
+	req.Header.Set("User-Agent", version.UserAgent())
 	req.Header.Set("Accept", "text/plain")
 
 	return p.supervisorClient.Do(req)
@@ -766,25 +1027,16 @@ func (p *Provider) GetHostInfo(ctx context.Context) (*HostInfo, error) {
 	return &info, nil
 }
 
-// AddonControl controls an addon (start, stop, restart).
-func (p *Provider) AddonControl(ctx context.Context, slug, action string) error {
+// AddonControl controls an addon (start, stop, restart). It returns the UUID
+// of the Supervisor job carrying out the action, or "" if the action was
+// done before the Supervisor answered.
+func (p *Provider) AddonControl(ctx context.Context, slug, action string) (string, error) {
 	if action != "start" && action != "stop" && action != "restart" {
-		return fmt.Errorf("invalid action: %s", action)
+		return "", fmt.Errorf("invalid action: %s", action)
 	}
 
 	path := fmt.Sprintf("/addons/%s/%s", slug, action)
-	resp, err := p.supervisorRequest(ctx, "POST", path, nil)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("addon %s %s failed (%d): %s", slug, action, resp.StatusCode, string(body))
-	}
-
-	return nil
+	return p.supervisorAction(ctx, path, nil, fmt.Sprintf("addon %s %s", slug, action))
 }
 
 // CoreControl controls HA Core via Supervisor API (start, stop, restart).
@@ -815,14 +1067,18 @@ func (p *Provider) HasSupervisorAPI() bool {
 }
 
 // addonStateToServiceState converts addon state to standardized service state.
-func addonStateToServiceState(state string) string {
+// An addon in "error" failed to start or crashed and isn't running, so it is
+// reported stopped, like a failed systemd unit.
+func addonStateToServiceState(state string) services.State {
 	switch strings.ToLower(state) {
 	case "started":
-		return "running"
-	case "stopped", "error", "unknown":
-		return "stopped"
+		return services.StateRunning
+	case "startup":
+		return services.StateStarting
+	case "stopped", "error":
+		return services.StateStopped
 	default:
-		return "stopped"
+		return services.StateUnknown
 	}
 }
 
@@ -857,45 +1113,58 @@ func (s *Service) GetLogs(ctx context.Context, tailLines int, follow bool) (io.R
 }
 
 // Start starts the service.
-// Supported for addons and HA Core (HAOS only via Supervisor API).
+// Supported for addons and HA Core (via Supervisor API on HAOS, or Docker for Container installs).
 func (s *Service) Start(ctx context.Context) error {
 	switch s.serviceType {
 	case "addon":
-		return s.provider.AddonControl(ctx, s.addonSlug, "start")
+		return s.addonControl(ctx, "start")
 	case "core", "":
-		// Core start is only supported via Supervisor API on HAOS
 		if s.provider.HasSupervisorAPI() {
 			return s.provider.CoreControl(ctx, "start")
 		}
-		return fmt.Errorf("start is not supported for Home Assistant Core on non-HAOS installations")
+		if s.provider.UsesContainer() {
+			container, err := s.provider.coreContainer()
+			if err != nil {
+				return err
+			}
+			return container.Start(ctx)
+		}
+		return fmt.Errorf("start is not supported for Home Assistant Core without the Supervisor API or a container_name")
 	default:
 		return fmt.Errorf("start is not supported for %s", s.GetName())
 	}
 }
 
 // Stop stops the service.
-// Supported for addons and HA Core (HAOS only via Supervisor API).
+// Supported for addons and HA Core (via Supervisor API on HAOS, or Docker for Container installs).
 func (s *Service) Stop(ctx context.Context) error {
 	switch s.serviceType {
 	case "addon":
-		return s.provider.AddonControl(ctx, s.addonSlug, "stop")
+		return s.addonControl(ctx, "stop")
 	case "core", "":
-		// Core stop is only supported via Supervisor API on HAOS
 		if s.provider.HasSupervisorAPI() {
 			return s.provider.CoreControl(ctx, "stop")
 		}
-		return fmt.Errorf("stop is not supported for Home Assistant Core on non-HAOS installations")
+		if s.provider.UsesContainer() {
+			container, err := s.provider.coreContainer()
+			if err != nil {
+				return err
+			}
+			return container.Stop(ctx)
+		}
+		return fmt.Errorf("stop is not supported for Home Assistant Core without the Supervisor API or a container_name")
 	default:
 		return fmt.Errorf("stop is not supported for %s", s.GetName())
 	}
 }
 
 // Restart restarts the service.
-// For HA Core on HAOS, uses Supervisor API. Otherwise, uses HA REST API.
+// For HA Core on HAOS, uses Supervisor API; for Container installs, Docker.
+// Otherwise, uses HA REST API.
 func (s *Service) Restart(ctx context.Context) error {
 	switch s.serviceType {
 	case "addon":
-		return s.provider.AddonControl(ctx, s.addonSlug, "restart")
+		return s.addonControl(ctx, "restart")
 	case "supervisor", "host":
 		return fmt.Errorf("restart is not supported for %s", s.GetName())
 	default: // "core" or empty
@@ -903,10 +1172,27 @@ func (s *Service) Restart(ctx context.Context) error {
 		if s.provider.HasSupervisorAPI() {
 			return s.provider.CoreControl(ctx, "restart")
 		}
+		if s.provider.UsesContainer() {
+			container, err := s.provider.coreContainer()
+			if err != nil {
+				return err
+			}
+			return container.Restart(ctx)
+		}
 		return s.provider.Restart(ctx)
 	}
 }
 
+// addonControl carries out action on the addon, waiting for the Supervisor
+// job it starts, if any, to finish.
+func (s *Service) addonControl(ctx context.Context, action string) error {
+	jobID, err := s.provider.AddonControl(ctx, s.addonSlug, action)
+	if err != nil {
+		return err
+	}
+	return s.provider.awaitJob(ctx, jobID)
+}
+
 // GetName returns the service name.
 func (s *Service) GetName() string {
 	switch s.serviceType {
@@ -3,12 +3,23 @@ package homeassistant
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	ha "github.com/mutablelogic/go-client/pkg/homeassistant"
 
 	"home_server_dashboard/config"
+	"home_server_dashboard/services/homeassistant/hatest"
+	"home_server_dashboard/services"
+	"home_server_dashboard/version"
 )
 
 // TestNewProvider tests the NewProvider function with various configurations.
@@ -344,102 +355,7 @@ func mockHAServer(t *testing.T, healthResponse string, statusCode int) *httptest
 
 // mockSupervisorServer creates a mock Supervisor API server for testing.
 func mockSupervisorServer(t *testing.T) *httptest.Server {
-	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Check authorization header
-		auth := r.Header.Get("Authorization")
-		if !strings.HasPrefix(auth, "Bearer ") {
-			w.WriteHeader(http.StatusUnauthorized)
-			return
-		}
-
-		switch r.URL.Path {
-		case "/addons":
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"result": "ok",
-				"data": map[string]interface{}{
-					"addons": []map[string]interface{}{
-						{
-							"slug":        "esphome",
-							"name":        "ESPHome",
-							"description": "ESPHome addon for Home Assistant",
-							"state":       "started",
-							"version":     "2024.1.0",
-							"installed":   true,
-							"available":   true,
-						},
-						{
-							"slug":        "ssh",
-							"name":        "SSH & Web Terminal",
-							"description": "SSH server addon",
-							"state":       "stopped",
-							"version":     "9.9.0",
-							"installed":   true,
-							"available":   true,
-						},
-						{
-							"slug":        "notinstalled",
-							"name":        "Not Installed",
-							"description": "Not installed addon",
-							"state":       "unknown",
-							"version":     "1.0.0",
-							"installed":   false,
-							"available":   true,
-						},
-					},
-				},
-			})
-		case "/addons/esphome/logs":
-			w.Write([]byte("ESPHome log line 1\nESPHome log line 2\n"))
-		case "/addons/esphome/logs/follow":
-			w.Write([]byte("ESPHome streaming log\n"))
-		case "/addons/esphome/restart", "/addons/esphome/start", "/addons/esphome/stop":
-			if r.Method == "POST" {
-				json.NewEncoder(w).Encode(map[string]string{"result": "ok"})
-			} else {
-				w.WriteHeader(http.StatusMethodNotAllowed)
-			}
-		case "/core/start", "/core/stop", "/core/restart":
-			if r.Method == "POST" {
-				json.NewEncoder(w).Encode(map[string]string{"result": "ok"})
-			} else {
-				w.WriteHeader(http.StatusMethodNotAllowed)
-			}
-		case "/core/logs":
-			w.Write([]byte("Core log line 1\nCore log line 2\n"))
-		case "/supervisor/logs":
-			w.Write([]byte("Supervisor log line 1\n"))
-		case "/host/logs":
-			w.Write([]byte("Host log line 1\n"))
-		case "/supervisor/info":
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"result": "ok",
-				"data": map[string]interface{}{
-					"version": "2024.01.0",
-					"healthy": true,
-					"channel": "stable",
-				},
-			})
-		case "/core/info":
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"result": "ok",
-				"data": map[string]interface{}{
-					"version": "2024.1.0",
-					"state":   "running",
-				},
-			})
-		case "/host/info":
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"result": "ok",
-				"data": map[string]interface{}{
-					"hostname":         "homeassistant",
-					"operating_system": "Home Assistant OS 11.0",
-					"kernel":           "6.1.0",
-				},
-			})
-		default:
-			w.WriteHeader(http.StatusNotFound)
-		}
-	}))
+	return hatest.NewServer()
 }
 
 // TestHasSupervisorAPI tests the HasSupervisorAPI helper.
@@ -555,15 +471,17 @@ func TestGetSSHAddonPort(t *testing.T) {
 func TestAddonStateToServiceState(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected string
+		expected services.State
 	}{
-		{"started", "running"},
-		{"STARTED", "running"},
-		{"Started", "running"},
-		{"stopped", "stopped"},
-		{"error", "stopped"},
-		{"unknown", "stopped"},
-		{"invalid", "stopped"},
+		{"started", services.StateRunning},
+		{"STARTED", services.StateRunning},
+		{"Started", services.StateRunning},
+		{"startup", services.StateStarting},
+		{"stopped", services.StateStopped},
+		{"error", services.StateStopped},
+		{"unknown", services.StateUnknown},
+		{"", services.StateUnknown},
+		{"invalid", services.StateUnknown},
 	}
 
 	for _, tt := range tests {
@@ -576,6 +494,28 @@ func TestAddonStateToServiceState(t *testing.T) {
 	}
 }
 
+func TestAddonToServiceInfoDisplayName(t *testing.T) {
+	provider, err := NewProvider(&config.HostConfig{
+		Name:    "testhost",
+		Address: "192.168.1.100",
+		HomeAssistant: &config.HomeAssistantConfig{
+			Port:           8123,
+			LongLivedToken: "test-token",
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewProvider() error: %v", err)
+	}
+
+	info := provider.addonToServiceInfo(Addon{Slug: "core_mosquitto", Name: "Mosquitto broker", State: "started"})
+	if info.Name != "addon-core_mosquitto" {
+		t.Errorf("Name = %q, want %q", info.Name, "addon-core_mosquitto")
+	}
+	if info.DisplayName != "Mosquitto broker" {
+		t.Errorf("DisplayName = %q, want %q", info.DisplayName, "Mosquitto broker")
+	}
+}
+
 // TestGetServiceHAOS tests GetService with HAOS service types.
 // Note: Without SUPERVISOR_TOKEN env var and SSH connection, supervisor/host/addon
 // services won't be available. This test verifies the behavior in both scenarios.
@@ -720,34 +660,13 @@ func createMockSupervisorProvider(t *testing.T, server *httptest.Server) *Provid
 	// Replace the supervisor client with a mock that points to our test server
 	// We need to create a custom transport that rewrites URLs to the test server
 	provider.supervisorClient = &http.Client{
-		Transport: &mockSupervisorTransport{
-			testServerURL: server.URL,
-		},
+		Transport: &hatest.Transport{URL: server.URL},
 	}
 	provider.supervisorToken = "mock-supervisor-token"
 
 	return provider
 }
 
-// mockSupervisorTransport rewrites Supervisor API requests to a test server.
-type mockSupervisorTransport struct {
-	testServerURL string
-}
-
-func (t *mockSupervisorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	// Rewrite the URL to point to the test server
-	newURL := t.testServerURL + req.URL.Path
-	newReq, err := http.NewRequestWithContext(req.Context(), req.Method, newURL, req.Body)
-	if err != nil {
-		return nil, err
-	}
-	// Copy headers
-	for k, v := range req.Header {
-		newReq.Header[k] = v
-	}
-	return http.DefaultClient.Do(newReq)
-}
-
 // TestCoreControl tests the CoreControl method via mock Supervisor API.
 func TestCoreControl(t *testing.T) {
 	server := mockSupervisorServer(t)
@@ -804,7 +723,7 @@ func TestAddonControl(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := provider.AddonControl(context.Background(), tt.slug, tt.action)
+			_, err := provider.AddonControl(context.Background(), tt.slug, tt.action)
 			if tt.wantErr {
 				if err == nil {
 					t.Error("AddonControl() expected error, got nil")
@@ -920,3 +839,357 @@ func TestCoreControlWithoutSupervisorAPI(t *testing.T) {
 		}
 	}
 }
+
+// TestCheckHealth_SendsUserAgent verifies the Home Assistant client identifies itself.
+func TestCheckHealth_SendsUserAgent(t *testing.T) {
+	var gotUA string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUA = r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message": "API running."}`))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to parse server URL: %v", err)
+	}
+	port, _ := strconv.Atoi(u.Port())
+
+	provider, err := NewProvider(&config.HostConfig{
+		Name:    "testhost",
+		Address: u.Hostname(),
+		HomeAssistant: &config.HomeAssistantConfig{
+			Port:           port,
+			LongLivedToken: "test-token",
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewProvider() error: %v", err)
+	}
+	defer provider.Close()
+
+	if _, _, err := provider.CheckHealth(context.Background()); err != nil {
+		t.Fatalf("CheckHealth() error: %v", err)
+	}
+
+	if gotUA != version.UserAgent() {
+		t.Errorf("User-Agent = %q, want %q", gotUA, version.UserAgent())
+	}
+}
+
+// TestSupervisorRequest_SendsUserAgent verifies Supervisor API requests identify themselves.
+func TestSupervisorRequest_SendsUserAgent(t *testing.T) {
+	var gotUA string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUA = r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"result": "ok", "data": {"addons": []}}`))
+	}))
+	defer server.Close()
+
+	provider := createMockSupervisorProvider(t, server)
+
+	if _, err := provider.GetAddons(context.Background()); err != nil {
+		t.Fatalf("GetAddons() error: %v", err)
+	}
+
+	if gotUA != version.UserAgent() {
+		t.Errorf("User-Agent = %q, want %q", gotUA, version.UserAgent())
+	}
+}
+
+// TestGetServices_ConcurrentAndCached verifies that a HAOS host's API calls
+// overlap and that Supervisor and host info are reused within a poll interval.
+func TestGetServices_ConcurrentAndCached(t *testing.T) {
+	const latency = 200 * time.Millisecond
+
+	haServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(latency)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message": "API running."}`))
+	}))
+	defer haServer.Close()
+
+	mock := mockSupervisorServer(t)
+	defer mock.Close()
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	supervisorServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.URL.Path]++
+		mu.Unlock()
+		time.Sleep(latency)
+		mock.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer supervisorServer.Close()
+
+	haClient, err := ha.New(haServer.URL+"/api/", "test-token")
+	if err != nil {
+		t.Fatalf("ha.New() error: %v", err)
+	}
+	provider := &Provider{
+		hostConfig: &config.HostConfig{
+			Name:    "latencyhost",
+			Address: "192.168.1.100",
+			HomeAssistant: &config.HomeAssistantConfig{
+				LongLivedToken:    "test-token",
+				IsHomeAssistantOS: true,
+				SSHAddonPort:      22,
+			},
+		},
+		client:           haClient,
+		supervisorClient: &http.Client{Transport: &hatest.Transport{URL: supervisorServer.URL}},
+		supervisorToken:  "mock-supervisor-token",
+		hostName:         "latencyhost",
+	}
+	t.Cleanup(func() {
+		infoCacheMu.Lock()
+		delete(infoCache, "latencyhost")
+		infoCacheMu.Unlock()
+	})
+
+	start := time.Now()
+	svcList, err := provider.GetServices(context.Background())
+	if err != nil {
+		t.Fatalf("GetServices() error: %v", err)
+	}
+	// Four sequential calls would take 4x the latency
+	if elapsed := time.Since(start); elapsed >= 2*latency {
+		t.Errorf("GetServices() took %v, want the calls to overlap (< %v)", elapsed, 2*latency)
+	}
+	if len(svcList) != 6 {
+		t.Fatalf("got %d services, want core, supervisor, host and 3 addons", len(svcList))
+	}
+	if svcList[1].Status != "v2024.01.0" || svcList[2].Status != "Home Assistant OS 11.0 (6.1.0)" {
+		t.Errorf("supervisor status = %q, host status = %q", svcList[1].Status, svcList[2].Status)
+	}
+
+	// Within the poll interval only the addons are fetched again
+	again, err := provider.GetServices(context.Background())
+	if err != nil {
+		t.Fatalf("second GetServices() error: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if calls["/supervisor/info"] != 1 || calls["/host/info"] != 1 || calls["/addons"] != 2 {
+		t.Errorf("calls = %v, want supervisor and host info once and addons twice", calls)
+	}
+	if again[1].Status != svcList[1].Status || again[2].Status != svcList[2].Status {
+		t.Errorf("cached statuses = %q, %q", again[1].Status, again[2].Status)
+	}
+}
+
+// TestGetServices_APIErrors verifies that services whose API calls fail are
+// reported as unknown with the error, not as running or stopped.
+func TestGetServices_APIErrors(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	haClient, err := ha.New(failing.URL+"/api/", "test-token")
+	if err != nil {
+		t.Fatalf("ha.New() error: %v", err)
+	}
+	provider := &Provider{
+		hostConfig: &config.HostConfig{
+			Name:    "failinghost",
+			Address: "192.168.1.100",
+			HomeAssistant: &config.HomeAssistantConfig{
+				LongLivedToken:    "test-token",
+				IsHomeAssistantOS: true,
+				SSHAddonPort:      22,
+			},
+		},
+		client:           haClient,
+		supervisorClient: &http.Client{Transport: &hatest.Transport{URL: failing.URL}},
+		supervisorToken:  "mock-supervisor-token",
+		hostName:         "failinghost",
+	}
+
+	svcList, err := provider.GetServices(context.Background())
+	if err != nil {
+		t.Fatalf("GetServices() error: %v", err)
+	}
+	if len(svcList) != 3 {
+		t.Fatalf("got %d services, want core, supervisor and host", len(svcList))
+	}
+	wantPrefix := map[string]string{"homeassistant": "Unreachable: ", "ha-supervisor": "Unknown: ", "ha-host": "Unknown: "}
+	for _, svc := range svcList {
+		if svc.State != services.StateUnknown {
+			t.Errorf("%s state = %q, want unknown", svc.Name, svc.State)
+		}
+		if !strings.HasPrefix(svc.Status, wantPrefix[svc.Name]) || !strings.Contains(svc.Status, "500") {
+			t.Errorf("%s status = %q, want %q and the error", svc.Name, svc.Status, wantPrefix[svc.Name])
+		}
+	}
+
+	// The services' own GetInfo says the same
+	for _, name := range []string{"ha-supervisor", "ha-host"} {
+		svc, _ := provider.GetService(name)
+		info, _ := svc.GetInfo(context.Background())
+		if info.State != services.StateUnknown || !strings.HasPrefix(info.Status, "Unknown: ") {
+			t.Errorf("%s GetInfo() = %q, %q, want unknown with the error", name, info.State, info.Status)
+		}
+	}
+}
+
+// fakeContainer is a ContainerDelegate that records what it is asked to do.
+type fakeContainer struct {
+	logs    []string // containers whose logs were read
+	actions []string // "<action> <container>"
+	closed  bool
+}
+
+func (f *fakeContainer) GetLogs(ctx context.Context, containerName string, tailLines int, follow bool) (io.ReadCloser, error) {
+	f.logs = append(f.logs, containerName)
+	return io.NopCloser(strings.NewReader("container log line\n")), nil
+}
+
+func (f *fakeContainer) GetService(containerName string) (services.Service, error) {
+	return &fakeContainerService{parent: f, name: containerName}, nil
+}
+
+func (f *fakeContainer) Close() error {
+	f.closed = true
+	return nil
+}
+
+type fakeContainerService struct {
+	parent *fakeContainer
+	name   string
+}
+
+func (s *fakeContainerService) GetInfo(ctx context.Context) (services.ServiceInfo, error) {
+	return services.ServiceInfo{Name: s.name}, nil
+}
+
+func (s *fakeContainerService) GetLogs(ctx context.Context, tailLines int, follow bool) (io.ReadCloser, error) {
+	return s.parent.GetLogs(ctx, s.name, tailLines, follow)
+}
+
+func (s *fakeContainerService) Start(ctx context.Context) error   { return s.record("start") }
+func (s *fakeContainerService) Stop(ctx context.Context) error    { return s.record("stop") }
+func (s *fakeContainerService) Restart(ctx context.Context) error { return s.record("restart") }
+func (s *fakeContainerService) GetName() string                   { return s.name }
+func (s *fakeContainerService) GetHost() string                   { return "localhost" }
+func (s *fakeContainerService) GetSource() string                 { return "docker" }
+
+func (s *fakeContainerService) record(action string) error {
+	s.parent.actions = append(s.parent.actions, action+" "+s.name)
+	return nil
+}
+
+// TestContainerDelegate_Routing verifies which installs send core logs and
+// actions to the Docker container instead of the Supervisor API or the stub.
+func TestContainerDelegate_Routing(t *testing.T) {
+	fake := &fakeContainer{}
+	SetContainerDelegate(func(host *config.HostConfig) (ContainerDelegate, error) {
+		return fake, nil
+	})
+	t.Cleanup(func() { SetContainerDelegate(nil) })
+
+	newHost := func(address string, haos bool) *config.HostConfig {
+		return &config.HostConfig{
+			Name:    "testhost",
+			Address: address,
+			HomeAssistant: &config.HomeAssistantConfig{
+				LongLivedToken:    "test-token",
+				IsHomeAssistantOS: haos,
+				SSHAddonPort:      22,
+				ContainerName:     "homeassistant",
+			},
+		}
+	}
+	readLogs := func(t *testing.T, p *Provider) string {
+		t.Helper()
+		logs, err := p.GetLogs(context.Background(), "homeassistant", 100, false)
+		if err != nil {
+			t.Fatalf("GetLogs() error: %v", err)
+		}
+		defer logs.Close()
+		data, _ := io.ReadAll(logs)
+		return string(data)
+	}
+
+	t.Run("container", func(t *testing.T) {
+		fake.logs, fake.actions = nil, nil
+		provider, err := NewProvider(newHost("localhost", false))
+		if err != nil {
+			t.Fatalf("NewProvider() error: %v", err)
+		}
+		if !provider.UsesContainer() {
+			t.Fatal("UsesContainer() = false for a local container install")
+		}
+		if got := readLogs(t, provider); got != "container log line\n" || len(fake.logs) != 1 {
+			t.Errorf("GetLogs() = %q, container logs read %v", got, fake.logs)
+		}
+
+		svc, _ := provider.GetService("homeassistant")
+		ctx := context.Background()
+		if err := svc.Stop(ctx); err != nil {
+			t.Errorf("Stop() error: %v", err)
+		}
+		if err := svc.Start(ctx); err != nil {
+			t.Errorf("Start() error: %v", err)
+		}
+		if err := svc.Restart(ctx); err != nil {
+			t.Errorf("Restart() error: %v", err)
+		}
+		want := []string{"stop homeassistant", "start homeassistant", "restart homeassistant"}
+		if !reflect.DeepEqual(fake.actions, want) {
+			t.Errorf("container actions = %v, want %v", fake.actions, want)
+		}
+
+		provider.Close()
+		if !fake.closed {
+			t.Error("Close() did not close the container delegate")
+		}
+	})
+
+	t.Run("HAOS", func(t *testing.T) {
+		fake.logs, fake.actions = nil, nil
+		server := mockSupervisorServer(t)
+		defer server.Close()
+		provider := createMockSupervisorProvider(t, server)
+		provider.hostConfig.HomeAssistant.ContainerName = "homeassistant"
+		provider.container = fake
+
+		if provider.UsesContainer() {
+			t.Error("UsesContainer() = true with the Supervisor API available")
+		}
+		if got := readLogs(t, provider); !strings.Contains(got, "Core log line 1") || len(fake.logs) != 0 {
+			t.Errorf("GetLogs() = %q, container logs read %v", got, fake.logs)
+		}
+		svc, _ := provider.GetService("homeassistant")
+		if err := svc.Stop(context.Background()); err != nil {
+			t.Errorf("Stop() error: %v", err)
+		}
+		if len(fake.actions) != 0 {
+			t.Errorf("container actions = %v, want none on HAOS", fake.actions)
+		}
+	})
+
+	t.Run("bare", func(t *testing.T) {
+		fake.logs, fake.actions = nil, nil
+		// A remote host's container can't be reached through the local Docker socket
+		provider, err := NewProvider(newHost("192.168.1.100", false))
+		if err != nil {
+			t.Fatalf("NewProvider() error: %v", err)
+		}
+		if provider.UsesContainer() {
+			t.Error("UsesContainer() = true for a remote host")
+		}
+		if got := readLogs(t, provider); !strings.Contains(got, "Logs are not available") {
+			t.Errorf("GetLogs() = %q, want the stub message", got)
+		}
+		svc, _ := provider.GetService("homeassistant")
+		if err := svc.Start(context.Background()); err == nil {
+			t.Error("Start() succeeded without the Supervisor API or a container")
+		}
+		if len(fake.logs) != 0 || len(fake.actions) != 0 {
+			t.Errorf("container used: logs %v, actions %v", fake.logs, fake.actions)
+		}
+	})
+}
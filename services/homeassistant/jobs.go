@@ -0,0 +1,198 @@
+package homeassistant
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Job is a Supervisor job: an add-on update, a backup or another operation
+// the Supervisor runs in the background, from /jobs/info or /jobs/{uuid}.
+type Job struct {
+	UUID      string     `json:"uuid"`
+	Name      string     `json:"name"`
+	Reference string     `json:"reference"`
+	Progress  float64    `json:"progress"`
+	Stage     string     `json:"stage"`
+	Done      bool       `json:"done"`
+	Errors    []JobError `json:"errors"`
+	ChildJobs []Job      `json:"child_jobs"`
+}
+
+// JobError is an error a Supervisor job ran into.
+type JobError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// Err returns the errors of a job, or nil if it had none.
+func (j *Job) Err() error {
+	if len(j.Errors) == 0 {
+		return nil
+	}
+	messages := make([]string, len(j.Errors))
+	for i, e := range j.Errors {
+		messages[i] = e.Message
+	}
+	return fmt.Errorf("job %s failed: %s", j.Name, strings.Join(messages, "; "))
+}
+
+// ErrJobOutcomeUnknown is returned by WaitForJob when a job can no longer be
+// found, as when the Supervisor restarted while it ran: whether it completed
+// can't be told.
+var ErrJobOutcomeUnknown = errors.New("job outcome unknown")
+
+// How often WaitForJob polls a job, and how long a job can't be found before
+// its outcome is taken to be unknown. Variables so tests can shorten them.
+var (
+	jobPollInterval  = 2 * time.Second
+	jobVanishTimeout = 30 * time.Second
+)
+
+// GetJobs returns the Supervisor's current jobs.
+func (p *Provider) GetJobs(ctx context.Context) ([]Job, error) {
+	var data struct {
+		Jobs []Job `json:"jobs"`
+	}
+	if err := p.supervisorGet(ctx, "/jobs/info", &data); err != nil {
+		return nil, err
+	}
+	return data.Jobs, nil
+}
+
+// GetJob returns the Supervisor job with the given UUID.
+func (p *Provider) GetJob(ctx context.Context, uuid string) (*Job, error) {
+	var job Job
+	if err := p.supervisorGet(ctx, "/jobs/"+uuid, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// supervisorGet fetches path from the Supervisor API and decodes the data of
+// its response into data.
+func (p *Provider) supervisorGet(ctx context.Context, path string, data any) error {
+	resp, err := p.supervisorRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("supervisor API returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Result  string          `json:"result"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", path, err)
+	}
+	if result.Result != "ok" {
+		return fmt.Errorf("supervisor API error: %s", result.Message)
+	}
+	return json.Unmarshal(result.Data, data)
+}
+
+// supervisorAction POSTs body, if not nil, to path on the Supervisor API and
+// returns the UUID of the job it started, or "" if the Supervisor completed
+// it before answering. what describes the action in errors.
+func (p *Provider) supervisorAction(ctx context.Context, path string, body any, what string) (string, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return "", err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	resp, err := p.supervisorRequest(ctx, "POST", path, reqBody)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s failed (%d): %s", what, resp.StatusCode, string(respBody))
+	}
+
+	var result struct {
+		Data struct {
+			JobID string `json:"job_id"`
+		} `json:"data"`
+	}
+	json.Unmarshal(respBody, &result) // Actions that run no job may answer with no data
+	return result.Data.JobID, nil
+}
+
+// AddonUpdate starts updating an addon to its latest version, returning the
+// UUID of the Supervisor job doing it.
+func (p *Provider) AddonUpdate(ctx context.Context, slug string) (string, error) {
+	path := fmt.Sprintf("/addons/%s/update", slug)
+	return p.supervisorAction(ctx, path, map[string]bool{"background": true}, fmt.Sprintf("addon %s update", slug))
+}
+
+// CreateBackup starts a full backup named name, returning the UUID of the
+// Supervisor job doing it.
+func (p *Provider) CreateBackup(ctx context.Context, name string) (string, error) {
+	body := map[string]any{"name": name, "background": true}
+	return p.supervisorAction(ctx, "/backups/new/full", body, "backup")
+}
+
+// WaitForJob polls the Supervisor job with the given UUID until it's done,
+// calling progress, if not nil, whenever its progress or stage changes. It
+// returns the job's errors, if any, or ErrJobOutcomeUnknown if the job
+// couldn't be found for jobVanishTimeout.
+func (p *Provider) WaitForJob(ctx context.Context, uuid string, progress func(Job)) (*Job, error) {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	var last *Job
+	var missingSince time.Time
+	for {
+		job, err := p.GetJob(ctx, uuid)
+		switch {
+		case err == nil:
+			missingSince = time.Time{}
+			if progress != nil && (last == nil || job.Progress != last.Progress || job.Stage != last.Stage) {
+				progress(*job)
+			}
+			last = job
+			if job.Done {
+				return job, job.Err()
+			}
+		case ctx.Err() != nil:
+			return last, ctx.Err()
+		case missingSince.IsZero():
+			// Gone, or the Supervisor is restarting; it may come back
+			missingSince = time.Now()
+		case time.Since(missingSince) >= jobVanishTimeout:
+			return last, fmt.Errorf("%w: job %s not found for %s: %v", ErrJobOutcomeUnknown, uuid, jobVanishTimeout, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return last, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// awaitJob waits for the Supervisor job with the given UUID, if any.
+func (p *Provider) awaitJob(ctx context.Context, uuid string) error {
+	if uuid == "" {
+		return nil
+	}
+	_, err := p.WaitForJob(ctx, uuid, nil)
+	return err
+}
@@ -0,0 +1,109 @@
+package homeassistant
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"home_server_dashboard/services/homeassistant/hatest"
+)
+
+// shortenJobTimers makes WaitForJob poll every millisecond and give up on
+// vanished jobs after 20ms for the rest of the test.
+func shortenJobTimers(t *testing.T) {
+	interval, timeout := jobPollInterval, jobVanishTimeout
+	jobPollInterval, jobVanishTimeout = time.Millisecond, 20*time.Millisecond
+	t.Cleanup(func() { jobPollInterval, jobVanishTimeout = interval, timeout })
+}
+
+func TestStartJobs(t *testing.T) {
+	server := mockSupervisorServer(t)
+	defer server.Close()
+	provider := createMockSupervisorProvider(t, server)
+	ctx := context.Background()
+
+	if jobID, err := provider.AddonUpdate(ctx, "esphome"); err != nil || jobID != hatest.JobAddonUpdate {
+		t.Errorf("AddonUpdate() = %q, %v, want %q", jobID, err, hatest.JobAddonUpdate)
+	}
+	if jobID, err := provider.CreateBackup(ctx, "before upgrade"); err != nil || jobID != hatest.JobBackup {
+		t.Errorf("CreateBackup() = %q, %v, want %q", jobID, err, hatest.JobBackup)
+	}
+	if jobID, err := provider.AddonControl(ctx, "esphome", "restart"); err != nil || jobID != "" {
+		t.Errorf("AddonControl() = %q, %v, want no job", jobID, err)
+	}
+	if _, err := provider.AddonUpdate(ctx, "unknown"); err == nil {
+		t.Error("AddonUpdate() of an unknown addon succeeded")
+	}
+
+	jobs, err := provider.GetJobs(ctx)
+	if err != nil || len(jobs) != 2 {
+		t.Fatalf("GetJobs() = %+v, %v, want the update and the backup", jobs, err)
+	}
+}
+
+func TestWaitForJob(t *testing.T) {
+	shortenJobTimers(t)
+	server := mockSupervisorServer(t)
+	defer server.Close()
+	provider := createMockSupervisorProvider(t, server)
+	ctx := context.Background()
+
+	t.Run("completes", func(t *testing.T) {
+		jobID, _ := provider.AddonUpdate(ctx, "esphome")
+		var reported []string
+		job, err := provider.WaitForJob(ctx, jobID, func(job Job) {
+			reported = append(reported, job.Stage)
+		})
+		if err != nil || job == nil || !job.Done || job.Progress != 100 {
+			t.Fatalf("WaitForJob() = %+v, %v, want the done job", job, err)
+		}
+		// The 0% and 50% polls of the pull stage and the completed start
+		if strings.Join(reported, ",") != "pull,pull,start" {
+			t.Errorf("reported stages %q, want pull,pull,start", reported)
+		}
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		jobID, _ := provider.CreateBackup(ctx, "nightly")
+		_, err := provider.WaitForJob(ctx, jobID, nil)
+		if err == nil || !strings.Contains(err.Error(), "No space left on device") {
+			t.Errorf("WaitForJob() error = %v, want the backup's error", err)
+		}
+	})
+
+	t.Run("vanishes", func(t *testing.T) {
+		jobID, _ := provider.AddonControl(ctx, "ssh", "restart")
+		job, err := provider.WaitForJob(ctx, jobID, nil)
+		if !errors.Is(err, ErrJobOutcomeUnknown) {
+			t.Fatalf("WaitForJob() error = %v, want ErrJobOutcomeUnknown", err)
+		}
+		if job == nil || job.Done {
+			t.Errorf("WaitForJob() job = %+v, want the last state seen", job)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := provider.WaitForJob(cancelled, "job-unknown", nil); !errors.Is(err, context.Canceled) {
+			t.Errorf("WaitForJob() error = %v, want context.Canceled", err)
+		}
+	})
+}
+
+func TestServiceRestart_WaitsForJob(t *testing.T) {
+	shortenJobTimers(t)
+	server := mockSupervisorServer(t)
+	defer server.Close()
+	provider := createMockSupervisorProvider(t, server)
+
+	svc, err := provider.GetService("addon-ssh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Restart(context.Background()); !errors.Is(err, ErrJobOutcomeUnknown) {
+		t.Errorf("Restart() error = %v, want ErrJobOutcomeUnknown", err)
+	}
+}
@@ -0,0 +1,26 @@
+package homeassistant
+
+import (
+	"home_server_dashboard/config"
+	"home_server_dashboard/services"
+)
+
+func init() {
+	services.Register(services.Registration{
+		Source:  "homeassistant",
+		Aliases: []string{"homeassistant-addon"},
+		Order:   30,
+		Configured: func(host *config.HostConfig) bool {
+			return host.HasHomeAssistant()
+		},
+		Factory: func(cfg *config.Config, host *config.HostConfig) (services.Provider, error) {
+			provider, err := NewProvider(host)
+			if err != nil || provider == nil {
+				// Avoid returning a typed nil
+				return nil, err
+			}
+			return provider, nil
+		},
+		Capabilities: services.Capabilities{Logs: true, Actions: true, NeedsClose: true},
+	})
+}
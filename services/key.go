@@ -0,0 +1,76 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Key identifies a service: the host it runs on, the source reporting it,
+// the compose project it belongs to and its name. Fields a lookup doesn't
+// need are left empty; the monitor, for instance, keys services by host and
+// name only.
+//
+// Keys are comparable, so they can key maps directly instead of strings
+// glued together from the fields, which collide when a field contains the
+// separator.
+type Key struct {
+	Host    string
+	Source  string
+	Project string
+	Name    string
+}
+
+// KeyOf returns the key of svc.
+func KeyOf(svc ServiceInfo) Key {
+	return Key{Host: svc.Host, Source: svc.Source, Project: svc.Project, Name: svc.Name}
+}
+
+// keySeparator separates the fields of an encoded key.
+const keySeparator = "/"
+
+// keyEscaper escapes the characters that would make an encoded key
+// ambiguous: the separator and the escape character itself.
+var keyEscaper = strings.NewReplacer("%", "%25", "/", "%2F")
+
+// String returns the canonical encoding of k: its fields in order, separated
+// by slashes, with slashes and percent signs in them percent-encoded, e.g.
+// "nas/docker/media/sonarr". ParseKey reverses it.
+func (k Key) String() string {
+	return keyEscaper.Replace(k.Host) + keySeparator +
+		keyEscaper.Replace(k.Source) + keySeparator +
+		keyEscaper.Replace(k.Project) + keySeparator +
+		keyEscaper.Replace(k.Name)
+}
+
+// ParseKey parses a key encoded by Key.String.
+func ParseKey(s string) (Key, error) {
+	parts := strings.Split(s, keySeparator)
+	if len(parts) != 4 {
+		return Key{}, fmt.Errorf("invalid service key %q: want 4 fields, got %d", s, len(parts))
+	}
+	for i, part := range parts {
+		field, err := url.PathUnescape(part)
+		if err != nil {
+			return Key{}, fmt.Errorf("invalid service key %q: %w", s, err)
+		}
+		parts[i] = field
+	}
+	return Key{Host: parts[0], Source: parts[1], Project: parts[2], Name: parts[3]}, nil
+}
+
+// MarshalText encodes k as its String, so keys are plain strings in JSON,
+// map keys included.
+func (k Key) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText decodes a key encoded by MarshalText.
+func (k *Key) UnmarshalText(text []byte) error {
+	parsed, err := ParseKey(string(text))
+	if err != nil {
+		return err
+	}
+	*k = parsed
+	return nil
+}
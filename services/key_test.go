@@ -0,0 +1,100 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestKey_RoundTrip(t *testing.T) {
+	tests := []struct {
+		key  Key
+		want string
+	}{
+		{Key{Host: "nas", Source: "docker", Project: "media", Name: "sonarr"}, "nas/docker/media/sonarr"},
+		{Key{Host: "nas", Name: "nginx"}, "nas///nginx"},
+		{Key{}, "///"},
+		// Fields holding the separator or the escape character
+		{Key{Host: "a/b", Name: "c"}, "a%2Fb///c"},
+		{Key{Host: "a", Name: "b/c/d"}, "a///b%2Fc%2Fd"},
+		{Key{Host: "100%", Project: "%2F", Name: "x"}, "100%25//%252F/x"},
+		// Separators of the old ad hoc keys are kept as they are
+		{Key{Host: "nas:8080", Name: "redis:cache"}, "nas:8080///redis:cache"},
+		{Key{Host: "pi", Name: "svc with spaces"}, "pi///svc with spaces"},
+	}
+
+	for _, tt := range tests {
+		got := tt.key.String()
+		if got != tt.want {
+			t.Errorf("%#v.String() = %q, want %q", tt.key, got, tt.want)
+		}
+		parsed, err := ParseKey(got)
+		if err != nil {
+			t.Errorf("ParseKey(%q) error = %v", got, err)
+			continue
+		}
+		if parsed != tt.key {
+			t.Errorf("ParseKey(%q) = %#v, want %#v", got, parsed, tt.key)
+		}
+	}
+}
+
+func TestKey_DelimiterInFieldsDoesNotCollide(t *testing.T) {
+	// Joined naively, these would all be "a/b/c/d/e"
+	keys := []Key{
+		{Host: "a/b", Source: "c", Project: "d", Name: "e"},
+		{Host: "a", Source: "b/c", Project: "d", Name: "e"},
+		{Host: "a", Source: "b", Project: "c/d", Name: "e"},
+		{Host: "a", Source: "b", Project: "c", Name: "d/e"},
+	}
+	seen := make(map[string]Key)
+	for _, key := range keys {
+		s := key.String()
+		if other, ok := seen[s]; ok {
+			t.Errorf("%#v and %#v both encode to %q", key, other, s)
+		}
+		seen[s] = key
+	}
+}
+
+func TestParseKey_Invalid(t *testing.T) {
+	for _, s := range []string{"", "nas", "nas/nginx", "a/b/c/d/e", "nas///bad%zz", "nas///trailing%"} {
+		if key, err := ParseKey(s); err == nil {
+			t.Errorf("ParseKey(%q) = %#v, want an error", s, key)
+		}
+	}
+}
+
+func TestKey_JSON(t *testing.T) {
+	key := Key{Host: "a/b", Source: "docker", Project: "media", Name: "sonarr"}
+
+	data, err := json.Marshal(map[string]any{"key": key, "by_key": map[Key]int{key: 1}})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := `{"by_key":{"a%2Fb/docker/media/sonarr":1},"key":"a%2Fb/docker/media/sonarr"}`
+	if string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+
+	var decoded struct {
+		Key   Key         `json:"key"`
+		ByKey map[Key]int `json:"by_key"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if decoded.Key != key || decoded.ByKey[key] != 1 {
+		t.Errorf("Unmarshal() = %#v, want %#v", decoded, key)
+	}
+
+	if err := json.Unmarshal([]byte(`{"key":"nas/nginx"}`), &decoded); err == nil {
+		t.Error("Unmarshal() of a malformed key succeeded")
+	}
+}
+
+func TestKeyOf(t *testing.T) {
+	svc := ServiceInfo{Host: "nas", Source: "docker", Project: "media", Name: "sonarr", ContainerName: "media-sonarr-1"}
+	if got, want := KeyOf(svc), (Key{Host: "nas", Source: "docker", Project: "media", Name: "sonarr"}); got != want {
+		t.Errorf("KeyOf() = %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+// Platform is the operating system and CPU architecture of a host or an
+// image. The architecture is named the way images name it ("amd64",
+// "arm64"), whatever the host reported.
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"` // CPU variant, e.g. "v7" (ARM only)
+}
+
+// PlatformProvider is implemented by providers that can tell the platform
+// of the host their services run on.
+type PlatformProvider interface {
+	// Platform returns the platform of the provider's host.
+	Platform(ctx context.Context) (Platform, error)
+}
+
+// archAliases maps the machine names uname and the Docker daemon report to
+// the architectures images are built for.
+var archAliases = map[string]string{
+	"x86_64":  "amd64",
+	"x86-64":  "amd64",
+	"i386":    "386",
+	"i686":    "386",
+	"aarch64": "arm64",
+	"armv8l":  "arm64",
+	"armv7l":  "arm",
+	"armv6l":  "arm",
+	"armhf":   "arm",
+}
+
+// NormalizeArch returns the image architecture of a machine name such as
+// "x86_64" or "aarch64". Names it doesn't know are returned lower-case.
+func NormalizeArch(arch string) string {
+	arch = strings.ToLower(strings.TrimSpace(arch))
+	if alias, ok := archAliases[arch]; ok {
+		return alias
+	}
+	return arch
+}
+
+// NewPlatform returns the platform of an OS and architecture as a host or
+// image reports them, e.g. "Linux" and "x86_64".
+func NewPlatform(os, arch, variant string) Platform {
+	return Platform{
+		OS:           strings.ToLower(strings.TrimSpace(os)),
+		Architecture: NormalizeArch(arch),
+		Variant:      strings.TrimSpace(variant),
+	}
+}
+
+// String returns the platform the way docker --platform takes it, e.g.
+// "linux/arm/v7".
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// Known reports whether the architecture is known.
+func (p Platform) Known() bool {
+	return p.Architecture != ""
+}
+
+// compatibleArch lists the architectures a host can run besides its own.
+var compatibleArch = map[string][]string{
+	"amd64": {"386"},
+	"arm64": {"arm"},
+}
+
+// Runs reports whether a host of platform p can run an image built for
+// image. It is true if either architecture is unknown, so only a certain
+// mismatch is reported.
+func (p Platform) Runs(image Platform) bool {
+	if !p.Known() || !image.Known() {
+		return true
+	}
+	if p.OS != "" && image.OS != "" && p.OS != image.OS {
+		return false
+	}
+	return p.Architecture == image.Architecture || slices.Contains(compatibleArch[p.Architecture], image.Architecture)
+}
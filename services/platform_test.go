@@ -0,0 +1,48 @@
+package services
+
+import "testing"
+
+func TestNewPlatform(t *testing.T) {
+	tests := []struct {
+		os, arch, variant string
+		want              string
+	}{
+		{"linux", "x86_64", "", "linux/amd64"},
+		{"Linux", "aarch64", "", "linux/arm64"},
+		{"linux", "armv7l", "v7", "linux/arm/v7"},
+		{"linux", "amd64", "", "linux/amd64"},
+		{"linux", "riscv64", "", "linux/riscv64"},
+	}
+	for _, tt := range tests {
+		if got := NewPlatform(tt.os, tt.arch, tt.variant).String(); got != tt.want {
+			t.Errorf("NewPlatform(%q, %q, %q) = %s, want %s", tt.os, tt.arch, tt.variant, got, tt.want)
+		}
+	}
+}
+
+func TestPlatform_Runs(t *testing.T) {
+	amd64 := NewPlatform("linux", "x86_64", "")
+	arm64 := NewPlatform("linux", "aarch64", "")
+	tests := []struct {
+		name  string
+		host  Platform
+		image Platform
+		want  bool
+	}{
+		{"same", amd64, Platform{OS: "linux", Architecture: "amd64"}, true},
+		{"arm64 image on amd64", amd64, Platform{OS: "linux", Architecture: "arm64"}, false},
+		{"amd64 image on arm64", arm64, Platform{OS: "linux", Architecture: "amd64"}, false},
+		{"32-bit image on amd64", amd64, Platform{OS: "linux", Architecture: "386"}, true},
+		{"32-bit ARM image on arm64", arm64, Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, true},
+		{"windows image", amd64, Platform{OS: "windows", Architecture: "amd64"}, false},
+		{"unknown image", amd64, Platform{}, true},
+		{"unknown host", Platform{}, Platform{OS: "linux", Architecture: "arm64"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.host.Runs(tt.image); got != tt.want {
+				t.Errorf("%s.Runs(%s) = %v, want %v", tt.host, tt.image, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"home_server_dashboard/config"
+)
+
+// Factory creates the provider of a source for a host. It may return a nil
+// provider when the source cannot serve that host.
+type Factory func(cfg *config.Config, host *config.HostConfig) (Provider, error)
+
+// Capabilities describes what a registered source supports.
+type Capabilities struct {
+	// Logs is true if the provider streams service logs through GetLogs.
+	Logs bool
+	// Actions is true if services support start/stop/restart through GetService.
+	Actions bool
+	// NeedsClose is true if the provider holds connections and implements io.Closer.
+	NeedsClose bool
+	// LocalOnly is true if the source only exists on the local host (e.g. the Docker socket).
+	LocalOnly bool
+	// Poll is true if the monitor should poll the provider for state changes.
+	// Built-in sources have their own event watchers and leave it unset.
+	Poll bool
+}
+
+// Registration is a service source known to the dashboard.
+type Registration struct {
+	// Source is the value of ServiceInfo.Source for the source's services.
+	Source string
+	// Aliases are other Source values the provider reports (e.g. "homeassistant-addon").
+	Aliases []string
+	// Order sorts the sources when services are collected (lower first).
+	Order int
+	// Configured reports whether the source is set up on a host. Nil means
+	// every host (or the local host for LocalOnly sources).
+	Configured func(host *config.HostConfig) bool
+	// Factory creates the provider. Sources without a factory are known but
+	// not collected generically (e.g. Traefik, which enriches other services).
+	Factory Factory
+	Capabilities
+}
+
+// RemapProvider is implemented by providers whose services can move their
+// ports to other services.
+type RemapProvider interface {
+	// GetServicesWithRemaps returns the services along with their port remaps.
+	GetServicesWithRemaps(ctx context.Context) ([]ServiceInfo, []PortRemap, error)
+}
+
+// PortRemap represents a port that should be remapped from one service to another.
+// This is used when a service runs in another container's network namespace
+// (e.g., qbittorrent running in gluetun's network).
+type PortRemap struct {
+	Port          uint16 // The host port to remap
+	TargetService string // The service name that should own this port
+	SourceService string // The service name that exposes this port
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Registration) // key: source
+)
+
+// Register adds a source. Provider packages call it from init; registering a
+// source again replaces it.
+func Register(reg Registration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[reg.Source] = reg
+}
+
+// Unregister removes a source.
+func Unregister(source string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(registry, source)
+}
+
+// Lookup returns the registration for a source or one of its aliases.
+func Lookup(source string) (Registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	if reg, ok := registry[source]; ok {
+		return reg, true
+	}
+	for _, reg := range registry {
+		for _, alias := range reg.Aliases {
+			if alias == source {
+				return reg, true
+			}
+		}
+	}
+	return Registration{}, false
+}
+
+// Registered returns every registered source, sorted by Order then Source.
+func Registered() []Registration {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	regs := make([]Registration, 0, len(registry))
+	for _, reg := range registry {
+		regs = append(regs, reg)
+	}
+	sort.Slice(regs, func(i, j int) bool {
+		if regs[i].Order != regs[j].Order {
+			return regs[i].Order < regs[j].Order
+		}
+		return regs[i].Source < regs[j].Source
+	})
+	return regs
+}
+
+// IsConfigured reports whether the source is set up on host. Nothing is
+// configured on a disabled host.
+func (r Registration) IsConfigured(host *config.HostConfig) bool {
+	if !host.IsEnabled() {
+		return false
+	}
+	if r.Configured == nil {
+		return true
+	}
+	return r.Configured(host)
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"testing"
+
+	"home_server_dashboard/config"
+)
+
+func TestRegistry(t *testing.T) {
+	Register(Registration{Source: "test-b", Order: 5})
+	Register(Registration{Source: "test-a", Order: 5, Aliases: []string{"test-a-extra"}, Capabilities: Capabilities{Logs: true}})
+	Register(Registration{Source: "test-first", Order: -1})
+	defer func() {
+		Unregister("test-a")
+		Unregister("test-b")
+		Unregister("test-first")
+	}()
+
+	var order []string
+	for _, reg := range Registered() {
+		order = append(order, reg.Source)
+	}
+	want := []string{"test-first", "test-a", "test-b"}
+	if len(order) < 3 || order[0] != want[0] || order[1] != want[1] || order[2] != want[2] {
+		t.Errorf("Registered() order = %v, want %v first", order, want)
+	}
+
+	if reg, ok := Lookup("test-a-extra"); !ok || reg.Source != "test-a" || !reg.Logs {
+		t.Errorf("Lookup(alias) = %+v, %v", reg, ok)
+	}
+	if _, ok := Lookup("test-missing"); ok {
+		t.Error("Lookup() of unknown source succeeded")
+	}
+
+	// Registering again replaces the source
+	Register(Registration{Source: "test-b", Order: 5, Capabilities: Capabilities{Actions: true}})
+	if reg, _ := Lookup("test-b"); !reg.Actions {
+		t.Error("Register() did not replace the registration")
+	}
+
+	Unregister("test-b")
+	if _, ok := Lookup("test-b"); ok {
+		t.Error("Lookup() found an unregistered source")
+	}
+}
+
+func TestRegistration_IsConfigured(t *testing.T) {
+	host := &config.HostConfig{Name: "nas", SystemdServices: []string{"docker.service"}}
+
+	if !(Registration{}).IsConfigured(host) {
+		t.Error("IsConfigured() without a Configured func = false, want true")
+	}
+	reg := Registration{Configured: func(h *config.HostConfig) bool { return len(h.SystemdServices) > 0 }}
+	if !reg.IsConfigured(host) {
+		t.Error("IsConfigured() = false, want true")
+	}
+	if reg.IsConfigured(&config.HostConfig{Name: "pi"}) {
+		t.Error("IsConfigured() for host without units = true, want false")
+	}
+
+	disabled := false
+	host.Enabled = &disabled
+	if (Registration{}).IsConfigured(host) || reg.IsConfigured(host) {
+		t.Error("IsConfigured() for a disabled host = true, want false")
+	}
+}
@@ -4,6 +4,7 @@ package services
 import (
 	"context"
 	"io"
+	"time"
 )
 
 // PortInfo represents an exposed port on a service.
@@ -16,26 +17,142 @@ type PortInfo struct {
 	URLProtocol   string `json:"url_protocol,omitempty"`    // URL protocol override ("http" or "https", from Docker label)
 	SourceService string `json:"source_service,omitempty"`  // Service that exposes this port (for remapped ports on target)
 	TargetService string `json:"target_service,omitempty"`  // Service this port is remapped to (for remapped ports on source)
+	URL           string `json:"url,omitempty"`             // Link to the port built from the host address (IPv6-safe)
 }
 
 // ServiceInfo represents the status information for any service.
 type ServiceInfo struct {
-	Name               string     `json:"name"`                           // Service/unit name
-	Project            string     `json:"project"`                        // Docker project or "systemd"
-	ContainerName      string     `json:"container_name"`                 // Container name or unit name
-	State              string     `json:"state"`                          // "running" or "stopped"
-	Status             string     `json:"status"`                         // Human-readable status
-	Image              string     `json:"image"`                          // Docker image or "-"
-	Source             string     `json:"source"`                         // "docker" or "systemd"
-	Host               string     `json:"host"`                           // Host name from config
-	HostIP             string     `json:"host_ip"`                        // Private IP address for port links
-	Ports              []PortInfo `json:"ports"`                          // Exposed ports (non-localhost bindings)
-	TraefikURLs        []string   `json:"traefik_urls"`                   // Traefik-exposed hostnames (as full URLs)
-	TraefikServiceName string     `json:"traefik_service_name,omitempty"` // Traefik service name from labels (if different from Name)
-	Description        string     `json:"description"`                    // Service description (from Docker label or systemd unit)
-	Hidden             bool       `json:"hidden,omitempty"`               // If true, service should be hidden from UI
-	ReadOnly           bool       `json:"readonly,omitempty"`             // If true, start/stop/restart actions are disabled for ALL users
-	LogSize            int64      `json:"log_size,omitempty"`             // Size of log file in bytes (Docker only)
+	Name                string              `json:"name"`                           // Service/unit name
+	DisplayName         string              `json:"display_name,omitempty"`         // Friendly name for the UI (presentation only, defaults to Name)
+	Project             string              `json:"project"`                        // Docker project or "systemd"
+	ContainerName       string              `json:"container_name"`                 // Container name or unit name
+	ContainerID         string              `json:"container_id,omitempty"`         // Full ID of the container, which changes when it is recreated under the same name (Docker only)
+	State               State               `json:"state"`                          // Normalized state, e.g. "running", "starting" or "stopped"
+	Status              string              `json:"status"`                         // Human-readable status
+	Image               string              `json:"image"`                          // Docker image or "-"
+	Source              string              `json:"source"`                         // "docker" or "systemd"
+	Host                string              `json:"host"`                           // Host name from config
+	HostIP              string              `json:"host_ip"`                        // Private IP address or hostname for port links
+	Ports               []PortInfo          `json:"ports"`                          // Exposed ports (non-localhost bindings)
+	TraefikURLs         []string            `json:"traefik_urls"`                   // Traefik-exposed hostnames (as full URLs)
+	TraefikServiceName  string              `json:"traefik_service_name,omitempty"` // Traefik service name from labels (if different from Name)
+	URLOverride         bool                `json:"url_override,omitempty"`         // If true, TraefikURLs come from a url label and Traefik matching is skipped
+	TraefikIgnore       bool                `json:"traefik_ignore,omitempty"`       // If true, only TraefikServiceName is matched to Traefik, with no name-pattern fallbacks
+	Description         string              `json:"description"`                    // Service description (from Docker label or systemd unit)
+	Hidden              bool                `json:"hidden,omitempty"`               // If true, service should be hidden from UI
+	ReadOnly            bool                `json:"readonly,omitempty"`             // If true, start/stop/restart actions are disabled for ALL users
+	LogSize             int64               `json:"log_size,omitempty"`             // Size of log file in bytes (Docker only)
+	LogDriver           string              `json:"log_driver,omitempty"`           // Docker logging driver (e.g., "json-file", "journald"); Docker only
+	LastStateChange     *time.Time          `json:"last_state_change,omitempty"`    // When the service last entered its current state, from its provider or else as the monitor saw it
+	StateSince          *time.Time          `json:"state_since,omitempty"`          // When the service entered its current state, as its provider reports it
+	StartedAt           *time.Time          `json:"started_at,omitempty"`           // When the service last started, as its provider reports it
+	ImageCreated        *time.Time          `json:"image_created,omitempty"`        // When the container's image was built (Docker only)
+	ImageDigest         string              `json:"image_digest,omitempty"`         // Registry digest of the container's image (Docker only)
+	Stale               bool                `json:"stale,omitempty"`                // If true, the image is older than the configured staleness threshold
+	ImagePlatform       string              `json:"image_platform,omitempty"`       // Platform the container's image was built for, e.g. "linux/arm64" (Docker only)
+	ArchMismatch        bool                `json:"arch_mismatch,omitempty"`        // If true, the image was built for an architecture the host can't run, so the container fails with "exec format error" (Docker only)
+	Drifted             bool                `json:"drifted,omitempty"`              // If true, the container was recreated outside compose and differs from its compose file
+	ConfigDrift         bool                `json:"config_drift,omitempty"`         // If true, the compose file changed after the container was created, so it needs an up (Docker only)
+	IsSelf              bool                `json:"is_self,omitempty"`              // If true, this service is the dashboard itself; acting on it drops the connection
+	ProxyDependency     bool                `json:"proxy_dependency,omitempty"`     // If true, the request that listed it came through this service, so stopping or restarting it drops the connection
+	ExitCode            *int                `json:"exit_code,omitempty"`            // Exit code of a stopped container (Docker only)
+	FinishedAt          *time.Time          `json:"finished_at,omitempty"`          // When a stopped container exited or a stopped systemd unit went inactive
+	ExitError           string              `json:"exit_error,omitempty"`           // Error Docker reported for the last exit, if any (Docker only)
+	Networks            []NetworkAttachment `json:"networks,omitempty"`             // Networks the container is attached to (Docker only)
+	NetworkOf           string              `json:"network_of,omitempty"`           // Service whose network namespace the container shares (network_mode: container:<name>)
+	Orphaned            bool                `json:"orphaned,omitempty"`             // If true, the container is stopped and its compose project directory no longer exists
+	Profiles            []string            `json:"profiles,omitempty"`             // Compose profiles the service belongs to; it only runs when one of them is active (Docker only)
+	Icon                string              `json:"icon,omitempty"`                 // Icon for other dashboards, from the icon label (Docker only)
+	LastAction          *LastAction         `json:"last_action,omitempty"`          // Most recent action run on the service from the dashboard
+	ExternallyRestarted bool                `json:"externally_restarted,omitempty"` // If true, the container started after the last dashboard action without the dashboard starting it (Docker only)
+	LogSettings         *LogSettings        `json:"log_settings,omitempty"`         // Log viewer defaults set for the service, from labels or the systemd_services entry
+	StatsSampled        bool                `json:"stats_sampled,omitempty"`        // If true, recent CPU and memory samples are available from the stats endpoint (Docker only)
+	Failure             *FailureInfo        `json:"failure,omitempty"`              // Why a failed unit failed, if the host lists failure details (systemd only)
+	Probe               *ProbeResult        `json:"probe,omitempty"`                // Last result of the service's custom health probe, if it has one; a running service whose probe fails is unhealthy
+}
+
+// FailureInfo describes why a systemd unit is in the failed state.
+type FailureInfo struct {
+	Result       string   `json:"result"`                  // How the last run ended, e.g. "exit-code", "signal", "oom-kill" or "watchdog"
+	NRestarts    int      `json:"n_restarts"`              // How often systemd restarted the unit automatically
+	InvocationID string   `json:"invocation_id,omitempty"` // ID of the failed run in the journal
+	Logs         []string `json:"logs,omitempty"`          // Last lines the failed run logged, oldest first
+}
+
+// IntendedConfig is the config a compose file declares for a service.
+type IntendedConfig struct {
+	Ports         []string `json:"ports"`              // Published ports as "[host_ip:]host_port:container_port/protocol", or "container_port/protocol" for any host port, sorted
+	RestartPolicy string   `json:"restart_policy"`     // "no", "always", "unless-stopped" or "on-failure[:max_retries]"
+	Profiles      []string `json:"profiles,omitempty"` // Profiles the service belongs to
+}
+
+// RuntimeConfig is the config a container was created with, in the form of
+// IntendedConfig.
+type RuntimeConfig struct {
+	Ports         []string `json:"ports"`
+	RestartPolicy string   `json:"restart_policy"`
+}
+
+// ConfigComparison compares the config a compose file declares for a service
+// with the config its container runs with.
+type ConfigComparison struct {
+	Files         []string        `json:"files,omitempty"`    // Compose files the service was brought up from
+	Intended      *IntendedConfig `json:"intended,omitempty"` // Left out if the files can't be read or don't declare the service
+	Actual        RuntimeConfig   `json:"actual"`
+	Discrepancies []string        `json:"discrepancies"` // Where the two differ, readable as they are
+}
+
+// StatsSample is a container's CPU and memory use at one point in time.
+type StatsSample struct {
+	Time       time.Time `json:"t"`
+	CPUPercent float64   `json:"cpu_pct"`   // CPU use since the previous sample, 100 per busy core
+	MemBytes   uint64    `json:"mem_bytes"` // Memory in use, without the page cache
+}
+
+// Results of a custom health probe.
+const (
+	ProbePassing     = "passing"
+	ProbeFailing     = "failing"
+	ProbeUnprobeable = "unprobeable" // The probe's container port isn't published
+)
+
+// ProbeResult is the last result of a service's custom health probe.
+type ProbeResult struct {
+	Status    string    `json:"status"`           // ProbePassing, ProbeFailing or ProbeUnprobeable
+	Detail    string    `json:"detail,omitempty"` // Why the probe failed or can't run
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// MaintenanceWindow is a planned maintenance of a host, during which the
+// notifications about it are held back.
+type MaintenanceWindow struct {
+	Host  string    `json:"host"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	User  string    `json:"user,omitempty"` // Who started it
+}
+
+// LogSettings are a service's own defaults for its log viewer. They apply
+// when a logs request doesn't ask for a tail size or timestamps itself, and
+// take precedence over the global log_tail.
+type LogSettings struct {
+	Tail       int   `json:"tail,omitempty"`       // Number of lines shown when the viewer opens; 0 uses the global default
+	Timestamps *bool `json:"timestamps,omitempty"` // Whether each line starts with its timestamp; nil shows them
+}
+
+// LastAction is the most recent start, stop or restart run on a service from
+// the dashboard, as kept in the action history.
+type LastAction struct {
+	Action string    `json:"action"`         // "start", "stop" or "restart"
+	User   string    `json:"user,omitempty"` // Who ran it
+	Time   time.Time `json:"time"`           // When it started
+	Result string    `json:"result"`         // "running", "success", "failed" or "interrupted"
+}
+
+// NetworkAttachment is a network a container is attached to.
+type NetworkAttachment struct {
+	Name      string `json:"name"`                 // Network name
+	IPAddress string `json:"ip_address,omitempty"` // Container's address on the network, empty while stopped
 }
 
 // LogStreamer provides a stream of log data.
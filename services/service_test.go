@@ -90,6 +90,7 @@ func TestServiceInfo_JSONSerialization(t *testing.T) {
 func TestServiceInfo_JSONFieldNames(t *testing.T) {
 	info := ServiceInfo{
 		Name:          "test",
+		DisplayName:   "Test Service",
 		Project:       "proj",
 		ContainerName: "cont",
 		State:         "running",
@@ -113,6 +114,7 @@ func TestServiceInfo_JSONFieldNames(t *testing.T) {
 
 	expectedFields := []string{
 		`"name"`,
+		`"display_name"`,
 		`"project"`,
 		`"container_name"`,
 		`"state"`,
@@ -206,7 +208,7 @@ func TestServiceInfo_SliceSerialization(t *testing.T) {
 // TestServiceStateValues tests expected state values.
 func TestServiceStateValues(t *testing.T) {
 	// These are the standard state values used throughout the application
-	validStates := []string{"running", "stopped"}
+	validStates := []State{StateRunning, StateStopped, StateStarting, StateStopping, StatePaused, StateUnhealthy, StateUnknown, StateNotEnabled}
 
 	for _, state := range validStates {
 		info := ServiceInfo{State: state}
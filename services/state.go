@@ -0,0 +1,58 @@
+package services
+
+import "encoding/json"
+
+// State is the normalized state of a service. Providers map their native
+// states (Docker container state and health, systemd ActiveState/SubState,
+// Home Assistant addon state) onto these values.
+type State string
+
+const (
+	StateRunning   State = "running"   // Up and, as far as we know, working
+	StateStopped   State = "stopped"   // Not running: exited, inactive or failed
+	StateStarting  State = "starting"  // Coming up: activating, restarting, health check pending
+	StateStopping  State = "stopping"  // Going down: deactivating, being removed
+	StatePaused    State = "paused"    // Frozen by Docker; processes exist but don't run
+	StateUnhealthy State = "unhealthy" // Running but failing its health check
+	StateUnknown   State = "unknown"   // The provider reported something we can't interpret
+
+	// StateNotEnabled is a stopped compose service that only runs when one
+	// of its profiles is active, so it is down on purpose.
+	StateNotEnabled State = "not_enabled"
+)
+
+// Up returns true if the service's processes are alive, i.e. it can be
+// stopped or restarted rather than started.
+func (s State) Up() bool {
+	switch s {
+	case StateRunning, StateUnhealthy, StateStarting, StateStopping:
+		return true
+	}
+	return false
+}
+
+// Transitional returns true for states a service passes through on its way
+// to running or stopped.
+func (s State) Transitional() bool {
+	return s == StateStarting || s == StateStopping
+}
+
+// Legacy returns the state collapsed to "running" or "stopped", as reported
+// before services had finer-grained states.
+func (s State) Legacy() string {
+	if s.Up() {
+		return string(StateRunning)
+	}
+	return string(StateStopped)
+}
+
+// MarshalJSON adds legacy_state, the state collapsed by State.Legacy, for
+// clients that only understand "running" and "stopped".
+// TODO: drop legacy_state one release after the finer-grained states shipped.
+func (s ServiceInfo) MarshalJSON() ([]byte, error) {
+	type plain ServiceInfo
+	return json.Marshal(struct {
+		plain
+		LegacyState string `json:"legacy_state"`
+	}{plain(s), s.State.Legacy()})
+}
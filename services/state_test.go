@@ -0,0 +1,60 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestState_UpAndLegacy(t *testing.T) {
+	tests := []struct {
+		state        State
+		up           bool
+		transitional bool
+		legacy       string
+	}{
+		{StateRunning, true, false, "running"},
+		{StateUnhealthy, true, false, "running"},
+		{StateStarting, true, true, "running"},
+		{StateStopping, true, true, "running"},
+		{StatePaused, false, false, "stopped"},
+		{StateStopped, false, false, "stopped"},
+		{StateUnknown, false, false, "stopped"},
+		{StateNotEnabled, false, false, "stopped"},
+		{"", false, false, "stopped"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.state), func(t *testing.T) {
+			if got := tt.state.Up(); got != tt.up {
+				t.Errorf("Up() = %v, want %v", got, tt.up)
+			}
+			if got := tt.state.Transitional(); got != tt.transitional {
+				t.Errorf("Transitional() = %v, want %v", got, tt.transitional)
+			}
+			if got := tt.state.Legacy(); got != tt.legacy {
+				t.Errorf("Legacy() = %q, want %q", got, tt.legacy)
+			}
+		})
+	}
+}
+
+func TestServiceInfo_MarshalLegacyState(t *testing.T) {
+	data, err := json.Marshal(ServiceInfo{Name: "db", State: StateStarting})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	for _, want := range []string{`"name":"db"`, `"state":"starting"`, `"legacy_state":"running"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("JSON %s does not contain %s", data, want)
+		}
+	}
+
+	var decoded ServiceInfo
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if decoded.Name != "db" || decoded.State != StateStarting {
+		t.Errorf("decoded = %+v", decoded)
+	}
+}
@@ -0,0 +1,107 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/connlimit"
+)
+
+// IsLocal reports whether the provider manages units on this machine.
+func (p *Provider) IsLocal() bool {
+	return p.isLocal
+}
+
+// CheckSSH verifies that a non-interactive SSH login to the remote host works.
+// Returns nil for local providers.
+func (p *Provider) CheckSSH(ctx context.Context) error {
+	if p.isLocal {
+		return nil
+	}
+	return p.runRemoteCheck(ctx, "true")
+}
+
+// CheckJournal verifies that journalctl is available for reading unit logs.
+// With sudo journal access it checks that journalctl runs through passwordless sudo.
+func (p *Provider) CheckJournal(ctx context.Context) error {
+	if p.journalAccess == config.JournalAccessSudo {
+		return p.checkSudoJournal(ctx)
+	}
+	if p.isLocal {
+		output, err := exec.CommandContext(ctx, "journalctl", "--version").CombinedOutput()
+		if err != nil {
+			return commandError("journalctl", output, err)
+		}
+		return nil
+	}
+	return p.runRemoteCheck(ctx, "journalctl", "--version")
+}
+
+// CheckUnit verifies that a configured unit can be queried and is known to systemd.
+func (p *Provider) CheckUnit(ctx context.Context, unitName string) error {
+	entry, _ := p.findEntry(unitName)
+
+	var status string
+	switch {
+	case !p.isLocal && entry.User != "":
+		info, err := p.getRemoteUserUnitInfo(ctx, entry)
+		if err != nil {
+			return err
+		}
+		status = info.Status
+	case !p.isLocal:
+		info, err := p.getRemoteUnitInfo(ctx, entry)
+		if err != nil {
+			return err
+		}
+		status = info.Status
+	default:
+		svc, err := p.GetService(unitName)
+		if err != nil {
+			return err
+		}
+		info, err := svc.GetInfo(ctx)
+		if err != nil {
+			return err
+		}
+		status = info.Status
+	}
+
+	if status == "not found" {
+		return fmt.Errorf("unit %s not found", unitName)
+	}
+	return nil
+}
+
+// runRemoteCheck runs a command on the remote host in batch mode so a missing
+// key fails instead of prompting for a password. It always logs in itself,
+// never over a shared connection, since the login is what is being checked.
+func (p *Provider) runRemoteCheck(ctx context.Context, command ...string) error {
+	args := append([]string{"-o", "BatchMode=yes"}, p.getSSHBaseArgs()...)
+	args = append(args, p.getSSHTarget())
+	args = append(args, command...)
+
+	release, err := connlimit.Acquire(ctx, p.address)
+	if err != nil {
+		return fmt.Errorf("waiting for SSH slot: %w", err)
+	}
+	defer release()
+
+	output, err := exec.CommandContext(ctx, "ssh", args...).CombinedOutput()
+	if err != nil {
+		return commandError("ssh", output, err)
+	}
+	return nil
+}
+
+// commandError combines a failed command's error with its output, which
+// usually holds the useful part (e.g., "Permission denied (publickey)").
+func commandError(name string, output []byte, err error) error {
+	if msg := strings.TrimSpace(string(output)); msg != "" {
+		return fmt.Errorf("%s failed: %w: %s", name, err, msg)
+	}
+	return fmt.Errorf("%s failed: %w", name, err)
+}
@@ -0,0 +1,184 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"home_server_dashboard/connlimit"
+	"home_server_dashboard/services"
+	"home_server_dashboard/sshmux"
+)
+
+// failureLogLines is how many journal lines of the failed run are kept.
+const failureLogLines = 5
+
+// failureProperties are the unit properties read to describe a failure.
+const failureProperties = "ActiveState,Result,NRestarts,InvocationID"
+
+// invocationIDPattern matches the invocation IDs systemctl show prints. The
+// ID is checked before it goes into a remote shell command.
+var invocationIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// runCommand runs name with args and returns its stdout. It is replaced in
+// tests.
+var runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).Output()
+}
+
+// parseProperties parses the KEY=value lines printed by systemctl show.
+func parseProperties(output string) map[string]string {
+	props := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 {
+			props[parts[0]] = strings.TrimSpace(parts[1])
+		}
+	}
+	return props
+}
+
+// parseFailureInfo builds the failure details of a unit from its
+// properties, or returns nil if the unit isn't failed.
+func parseFailureInfo(props map[string]string) *services.FailureInfo {
+	if props["ActiveState"] != "failed" {
+		return nil
+	}
+	info := &services.FailureInfo{Result: props["Result"]}
+	if n, err := strconv.Atoi(props["NRestarts"]); err == nil {
+		info.NRestarts = n
+	}
+	if id := props["InvocationID"]; invocationIDPattern.MatchString(id) {
+		info.InvocationID = id
+	}
+	return info
+}
+
+// invocationJournalArgs builds the journalctl arguments for the last lines
+// logged by one run of a unit.
+func invocationJournalArgs(invocationID, user string) []string {
+	args := []string{"_SYSTEMD_INVOCATION_ID=" + invocationID, "-n", strconv.Itoa(failureLogLines), "--no-pager", "-o", "cat"}
+	if user != "" {
+		args = append([]string{"--user"}, args...)
+	}
+	return args
+}
+
+// failureShowCommand builds the systemctl show command for the failure
+// properties of the unit. Remote units run it over SSH.
+func (s *SystemdService) failureShowCommand() []string {
+	show := []string{"show", s.unitName, "--property=" + failureProperties}
+	switch {
+	case s.isLocal && s.user != "":
+		return append([]string{"systemctl", "--user", "--machine=" + s.user + "@"}, show...)
+	case s.isLocal:
+		return append([]string{"systemctl"}, show...)
+	case s.user != "":
+		return s.remoteCommand(append([]string{"systemctl", "--user"}, show...))
+	}
+	return s.remoteCommand(append([]string{"systemctl"}, show...))
+}
+
+// failureLogsCommand builds the journalctl command for the lines logged by
+// the unit's failed run.
+func (s *SystemdService) failureLogsCommand(invocationID string) []string {
+	command := journalctlCommand(s.journalAccess, s.user, invocationJournalArgs(invocationID, s.user))
+	if s.isLocal {
+		return command
+	}
+	return s.remoteCommand(command)
+}
+
+// remoteCommand wraps command in ssh to the unit's host. User units run it
+// as their owner via sudo, like logsCommand. The remote shell runs the
+// command, so every argument is quoted.
+func (s *SystemdService) remoteCommand(command []string) []string {
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		quoted[i] = ShellQuote(arg)
+	}
+	sshArgs := append([]string{"ssh"}, s.getSSHBaseArgs()...)
+	if s.user != "" {
+		user := ShellQuote(s.user)
+		shellCmd := fmt.Sprintf("sudo -u %s XDG_RUNTIME_DIR=/run/user/$(id -u %s) %s",
+			user, user, strings.Join(quoted, " "))
+		return append(sshArgs, s.getSSHTarget(), "bash", "-c", ShellQuote(shellCmd))
+	}
+	sshArgs = append(sshArgs, s.getSSHTarget())
+	return append(sshArgs, quoted...)
+}
+
+// run runs command, holding an SSH slot for the unit's host if it is remote.
+// ssh runs over the host's shared connection, if there is one.
+func (s *SystemdService) run(ctx context.Context, command []string) ([]byte, error) {
+	if !s.isLocal {
+		release, err := connlimit.Acquire(ctx, s.address)
+		if err != nil {
+			return nil, fmt.Errorf("waiting for SSH slot: %w", err)
+		}
+		defer release()
+	}
+	name, args := command[0], command[1:]
+	if name == "ssh" {
+		args = append(sshmux.Args(ctx, s.getSSHBaseArgs(), s.getSSHTarget()), args...)
+	}
+	var output []byte
+	var err error
+	if s.runner != nil {
+		output, err = s.runner(ctx, name, args...)
+	} else {
+		output, err = runCommand(ctx, name, args...)
+	}
+	if name == "ssh" {
+		err = sshLost(s.getSSHBaseArgs(), s.getSSHTarget(), err)
+	}
+	return output, err
+}
+
+// Failure returns why the unit failed: the Result systemd recorded (e.g.
+// "exit-code", "signal", "oom-kill" or "watchdog"), how often it was
+// restarted, and the last lines its failed run logged. It returns nil if
+// the unit isn't failed, without reading the journal. The logs are left out
+// if the journal can't be read.
+func (s *SystemdService) Failure(ctx context.Context) (*services.FailureInfo, error) {
+	output, err := s.run(ctx, s.failureShowCommand())
+	if err != nil {
+		return nil, fmt.Errorf("systemctl show failed: %w", err)
+	}
+	info := parseFailureInfo(parseProperties(string(output)))
+	if info == nil || info.InvocationID == "" {
+		return info, nil
+	}
+
+	output, err = s.run(ctx, s.failureLogsCommand(info.InvocationID))
+	if err != nil {
+		log.Printf("Failed to read the journal of the failed run of %s on %s: %v", s.unitName, s.hostName, err)
+		return info, nil
+	}
+	for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		if line != "" {
+			info.Logs = append(info.Logs, line)
+		}
+	}
+	return info, nil
+}
+
+// addFailureDetails sets Failure on the failed units in svcs.
+func (p *Provider) addFailureDetails(ctx context.Context, svcs []services.ServiceInfo) {
+	for i := range svcs {
+		if !strings.HasPrefix(svcs[i].Status, "failed ") {
+			continue
+		}
+		svc, _ := p.GetService(svcs[i].Name)
+		failure, err := svc.(*SystemdService).Failure(ctx)
+		if err != nil {
+			log.Printf("Failed to get failure details of %s on %s: %v", svcs[i].Name, p.hostName, err)
+			continue
+		}
+		svcs[i].Failure = failure
+	}
+}
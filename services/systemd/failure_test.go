@@ -0,0 +1,201 @@
+package systemd
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"home_server_dashboard/services"
+)
+
+// fakeCommands replaces runCommand with one answering each command by the
+// first output whose key its argv contains, and records the argv of each.
+func fakeCommands(t *testing.T, outputs map[string]string) *[]string {
+	t.Helper()
+	var calls []string
+	orig := runCommand
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		argv := strings.Join(append([]string{name}, args...), " ")
+		calls = append(calls, argv)
+		for key, output := range outputs {
+			if strings.Contains(argv, key) {
+				return []byte(output), nil
+			}
+		}
+		return nil, errors.New("exit status 1")
+	}
+	t.Cleanup(func() { runCommand = orig })
+	return &calls
+}
+
+const testInvocationID = "0123456789abcdef0123456789abcdef"
+
+func TestParseFailureInfo(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   *services.FailureInfo
+	}{
+		{
+			name:   "failed",
+			output: "ActiveState=failed\nResult=oom-kill\nNRestarts=3\nInvocationID=" + testInvocationID + "\n",
+			want:   &services.FailureInfo{Result: "oom-kill", NRestarts: 3, InvocationID: testInvocationID},
+		},
+		{
+			name:   "failed without a run",
+			output: "ActiveState=failed\nResult=start-limit-hit\nNRestarts=0\nInvocationID=\n",
+			want:   &services.FailureInfo{Result: "start-limit-hit"},
+		},
+		{
+			name:   "malformed invocation ID",
+			output: "ActiveState=failed\nResult=signal\nNRestarts=x\nInvocationID=$(reboot)\n",
+			want:   &services.FailureInfo{Result: "signal"},
+		},
+		{
+			name:   "active",
+			output: "ActiveState=active\nResult=success\nNRestarts=1\n",
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseFailureInfo(parseProperties(tt.output))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFailureInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFailureCommands tests the full argv of the commands Failure runs.
+func TestFailureCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		svc      *SystemdService
+		wantShow string
+		wantLogs string
+	}{
+		{
+			name:     "local",
+			svc:      &SystemdService{unitName: "nginx.service", isLocal: true},
+			wantShow: "systemctl show nginx.service --property=ActiveState,Result,NRestarts,InvocationID",
+			wantLogs: "journalctl _SYSTEMD_INVOCATION_ID=" + testInvocationID + " -n 5 --no-pager -o cat",
+		},
+		{
+			name:     "local user unit",
+			svc:      &SystemdService{unitName: "app.service", isLocal: true, user: "alice"},
+			wantShow: "systemctl --user --machine=alice@ show app.service --property=ActiveState,Result,NRestarts,InvocationID",
+			wantLogs: "journalctl --user _SYSTEMD_INVOCATION_ID=" + testInvocationID + " -n 5 --no-pager -o cat",
+		},
+		{
+			name:     "remote with sudo",
+			svc:      &SystemdService{unitName: "nginx.service", address: "nas", sshConfig: &SSHConfig{Username: "admin"}, journalAccess: "sudo"},
+			wantShow: "ssh -o ConnectTimeout=5 -o StrictHostKeyChecking=accept-new admin@nas systemctl show nginx.service --property=ActiveState,Result,NRestarts,InvocationID",
+			wantLogs: "ssh -o ConnectTimeout=5 -o StrictHostKeyChecking=accept-new admin@nas sudo -n journalctl _SYSTEMD_INVOCATION_ID=" + testInvocationID + " -n 5 --no-pager -o cat",
+		},
+		{
+			name:     "remote user unit",
+			svc:      &SystemdService{unitName: "app.service", address: "nas", user: "alice"},
+			wantShow: "ssh -o ConnectTimeout=5 -o StrictHostKeyChecking=accept-new nas bash -c 'sudo -u alice XDG_RUNTIME_DIR=/run/user/$(id -u alice) systemctl --user show app.service --property=ActiveState,Result,NRestarts,InvocationID'",
+			wantLogs: "ssh -o ConnectTimeout=5 -o StrictHostKeyChecking=accept-new nas bash -c 'sudo -u alice XDG_RUNTIME_DIR=/run/user/$(id -u alice) journalctl --user _SYSTEMD_INVOCATION_ID=" + testInvocationID + " -n 5 --no-pager -o cat'",
+		},
+		{
+			name:     "remote unit name with shell characters",
+			svc:      &SystemdService{unitName: "x;rm -rf ~", address: "nas"},
+			wantShow: "ssh -o ConnectTimeout=5 -o StrictHostKeyChecking=accept-new nas systemctl show 'x;rm -rf ~' --property=ActiveState,Result,NRestarts,InvocationID",
+			wantLogs: "ssh -o ConnectTimeout=5 -o StrictHostKeyChecking=accept-new nas journalctl _SYSTEMD_INVOCATION_ID=" + testInvocationID + " -n 5 --no-pager -o cat",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(tt.svc.failureShowCommand(), " "); got != tt.wantShow {
+				t.Errorf("failureShowCommand() = %q, want %q", got, tt.wantShow)
+			}
+			if got := strings.Join(tt.svc.failureLogsCommand(testInvocationID), " "); got != tt.wantLogs {
+				t.Errorf("failureLogsCommand() = %q, want %q", got, tt.wantLogs)
+			}
+		})
+	}
+}
+
+func TestFailure(t *testing.T) {
+	calls := fakeCommands(t, map[string]string{
+		"show":                   "ActiveState=failed\nResult=exit-code\nNRestarts=2\nInvocationID=" + testInvocationID + "\n",
+		"_SYSTEMD_INVOCATION_ID": "starting\npanic: boom\n\nexit status 2\n",
+	})
+	svc := &SystemdService{unitName: "app.service", address: "nas"}
+
+	got, err := svc.Failure(context.Background())
+	if err != nil {
+		t.Fatalf("Failure() = %v", err)
+	}
+	want := &services.FailureInfo{Result: "exit-code", NRestarts: 2, InvocationID: testInvocationID,
+		Logs: []string{"starting", "panic: boom", "exit status 2"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Failure() = %+v, want %+v", got, want)
+	}
+	if len(*calls) != 2 {
+		t.Errorf("ran %d commands, want systemctl show and journalctl: %q", len(*calls), *calls)
+	}
+}
+
+// TestFailure_HealthyUnit tests that a unit that isn't failed costs one
+// systemctl show and no journal read.
+func TestFailure_HealthyUnit(t *testing.T) {
+	calls := fakeCommands(t, map[string]string{
+		"show":                   "ActiveState=active\nResult=success\nNRestarts=0\nInvocationID=" + testInvocationID + "\n",
+		"_SYSTEMD_INVOCATION_ID": "should not be read\n",
+	})
+	svc := &SystemdService{unitName: "nginx.service", isLocal: true}
+
+	got, err := svc.Failure(context.Background())
+	if err != nil || got != nil {
+		t.Errorf("Failure() = %+v, %v, want nil", got, err)
+	}
+	if len(*calls) != 1 {
+		t.Errorf("ran %q, want only systemctl show", *calls)
+	}
+}
+
+// TestFailure_JournalUnreadable tests that the failure is still reported
+// when its logs can't be read.
+func TestFailure_JournalUnreadable(t *testing.T) {
+	fakeCommands(t, map[string]string{
+		"show": "ActiveState=failed\nResult=watchdog\nNRestarts=0\nInvocationID=" + testInvocationID + "\n",
+	})
+	svc := &SystemdService{unitName: "app.service", isLocal: true}
+
+	got, err := svc.Failure(context.Background())
+	if err != nil {
+		t.Fatalf("Failure() = %v", err)
+	}
+	if got == nil || got.Result != "watchdog" || got.Logs != nil {
+		t.Errorf("Failure() = %+v, want the watchdog result without logs", got)
+	}
+}
+
+// TestAddFailureDetails tests that only failed units of a listing are
+// looked into.
+func TestAddFailureDetails(t *testing.T) {
+	calls := fakeCommands(t, map[string]string{
+		"show broken.service": "ActiveState=failed\nResult=signal\nNRestarts=1\nInvocationID=\n",
+	})
+	p := NewProviderWithEntries("nas", "localhost", []ServiceEntry{{Name: "broken.service"}, {Name: "nginx.service"}}, nil)
+	svcs := []services.ServiceInfo{
+		{Name: "broken.service", Status: "failed (failed)"},
+		{Name: "nginx.service", Status: "active (running)"},
+	}
+
+	p.addFailureDetails(context.Background(), svcs)
+	if svcs[0].Failure == nil || svcs[0].Failure.Result != "signal" {
+		t.Errorf("failed unit: Failure = %+v, want the signal result", svcs[0].Failure)
+	}
+	if svcs[1].Failure != nil {
+		t.Errorf("running unit: Failure = %+v, want nil", svcs[1].Failure)
+	}
+	if len(*calls) != 1 {
+		t.Errorf("ran %q, want one systemctl show", *calls)
+	}
+}
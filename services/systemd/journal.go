@@ -0,0 +1,196 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"os/user"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"home_server_dashboard/config"
+)
+
+// journalGroup is the group whose members may read the whole system journal.
+const journalGroup = "systemd-journal"
+
+// JournalAccessError reports that journalctl ran but may not read the journal,
+// e.g. because the user is not in the systemd-journal group or passwordless
+// sudo is not set up. journalctl itself exits quietly in that case, so without
+// this error the log viewer just stays empty.
+type JournalAccessError struct {
+	Host   string
+	User   string   // User journalctl runs as; empty if unknown
+	Remote bool     // journalctl runs over SSH
+	Access string   // config.JournalAccessGroup or config.JournalAccessSudo
+	Groups []string // Groups that grant access, as listed by journalctl
+	Detail string   // The line journalctl or sudo printed
+}
+
+func (e *JournalAccessError) Error() string {
+	who := "the dashboard user"
+	switch {
+	case e.User != "":
+		who = fmt.Sprintf("user %q", e.User)
+	case e.Remote:
+		who = "the SSH user"
+	}
+
+	if e.Access == config.JournalAccessSudo {
+		return fmt.Sprintf("cannot read the journal on %s: %s may not run journalctl through passwordless sudo (%s); allow it with a NOPASSWD sudoers rule or add the user to the %s group and set journal_access to %q",
+			e.Host, who, e.Detail, journalGroup, config.JournalAccessGroup)
+	}
+
+	groups := e.Groups
+	if len(groups) == 0 {
+		groups = []string{journalGroup}
+	}
+	membership := "the " + groups[0] + " group"
+	if len(groups) > 1 {
+		membership = "any of the " + strings.Join(groups, ", ") + " groups"
+	}
+	return fmt.Sprintf("cannot read the journal on %s: %s is not in %s (%s); add the user to the %s group or set journal_access to %q",
+		e.Host, who, membership, e.Detail, journalGroup, config.JournalAccessSudo)
+}
+
+// journalAccessMarkers are substrings of the stderr lines journalctl and sudo
+// print when the journal can't be read.
+var journalAccessMarkers = []string{
+	"No journal files were opened",
+	"insufficient permissions",
+	"not seeing messages from other users and the system",
+	"sudo: a password is required",
+	"sudo: a terminal is required",
+	"is not allowed to execute",
+	"is not in the sudoers file",
+}
+
+// journalGroupsPattern matches the groups journalctl lists in its hint, e.g.
+// "Users in groups 'adm', 'systemd-journal', 'wheel' can see all messages."
+var journalGroupsPattern = regexp.MustCompile(`Users in groups? (.+?) can see all messages`)
+
+// parseJournalAccess reports whether a line of journalctl's stderr says the
+// journal can't be read, and returns the groups it lists as granting access.
+func parseJournalAccess(line string) (groups []string, denied bool) {
+	if m := journalGroupsPattern.FindStringSubmatch(line); m != nil {
+		for _, group := range strings.Split(m[1], ",") {
+			if group = strings.Trim(strings.TrimSpace(group), "'\""); group != "" {
+				groups = append(groups, group)
+			}
+		}
+	}
+	for _, marker := range journalAccessMarkers {
+		if strings.Contains(line, marker) {
+			return groups, true
+		}
+	}
+	return groups, false
+}
+
+// journalctlCommand returns the command line that runs journalctl with args.
+// With sudo access, system journals are read through "sudo -n" so a missing
+// sudoers rule fails instead of prompting. User units are read as their
+// owner and never use sudo.
+func journalctlCommand(access, unitUser string, args []string) []string {
+	command := []string{"journalctl"}
+	if access == config.JournalAccessSudo && unitUser == "" {
+		command = []string{"sudo", "-n", "journalctl"}
+	}
+	return append(command, args...)
+}
+
+// newJournalAccessError returns the error template filled in when journalctl
+// on host can't read the journal.
+func newJournalAccessError(host string, isLocal bool, sshConfig *SSHConfig, access string) *JournalAccessError {
+	if access == "" {
+		access = config.JournalAccessGroup
+	}
+	e := &JournalAccessError{Host: host, Remote: !isLocal, Access: access}
+	switch {
+	case !isLocal && sshConfig != nil:
+		e.User = sshConfig.Username
+	case isLocal:
+		if current, err := user.Current(); err == nil {
+			e.User = current.Username
+		}
+	}
+	return e
+}
+
+// sudoCheckTTL is how long the result of a sudo check is trusted.
+const sudoCheckTTL = 5 * time.Minute
+
+// sudoCheck records when checkSudoJournal last ran for a host.
+type sudoCheck struct {
+	at      time.Time
+	running bool
+}
+
+var (
+	sudoChecksMu sync.Mutex
+	sudoChecks   = make(map[string]sudoCheck) // key: SSH target or "localhost"
+)
+
+// sudoCheckKey returns the cache key of the provider's sudo check.
+func (p *Provider) sudoCheckKey() string {
+	if p.isLocal {
+		return "localhost"
+	}
+	return p.getSSHTarget()
+}
+
+// validateSudoJournal checks in the background that passwordless sudo
+// journalctl works when the host uses sudo access. Providers are created per
+// request, so the result is cached and a failure is logged once per check.
+func (p *Provider) validateSudoJournal() {
+	if p.journalAccess != config.JournalAccessSudo {
+		return
+	}
+	key := p.sudoCheckKey()
+
+	sudoChecksMu.Lock()
+	cached, ok := sudoChecks[key]
+	if ok && (cached.running || time.Since(cached.at) < sudoCheckTTL) {
+		sudoChecksMu.Unlock()
+		return
+	}
+	sudoChecks[key] = sudoCheck{at: cached.at, running: true}
+	sudoChecksMu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := p.checkSudoJournal(ctx); err != nil {
+			log.Printf("systemd: %v", err)
+		}
+	}()
+}
+
+// checkSudoJournal runs "sudo -n journalctl --version" on the host.
+func (p *Provider) checkSudoJournal(ctx context.Context) error {
+	command := journalctlCommand(config.JournalAccessSudo, "", []string{"--version"})
+
+	var err error
+	if p.isLocal {
+		var output []byte
+		output, err = exec.CommandContext(ctx, command[0], command[1:]...).CombinedOutput()
+		if err != nil {
+			err = commandError("sudo journalctl", output, err)
+		}
+	} else {
+		err = p.runRemoteCheck(ctx, command...)
+	}
+	if err != nil {
+		accessErr := newJournalAccessError(p.hostName, p.isLocal, p.sshConfig, config.JournalAccessSudo)
+		accessErr.Detail = err.Error()
+		err = accessErr
+	}
+
+	sudoChecksMu.Lock()
+	sudoChecks[p.sudoCheckKey()] = sudoCheck{at: time.Now()}
+	sudoChecksMu.Unlock()
+	return err
+}
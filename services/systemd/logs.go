@@ -0,0 +1,210 @@
+package systemd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Priorities lists the journal priority names accepted by journalctl -p,
+// from most to least severe.
+var Priorities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// LogOptions controls which journal entries GetLogsWithOptions returns.
+type LogOptions struct {
+	Tail     int    // Number of lines to show initially
+	Follow   bool   // Keep streaming new entries
+	Boot     *int   // Boot offset for journalctl -b (0 = current, -1 = previous); nil for all boots
+	Priority string // Maximum priority for journalctl -p (e.g. "err"); empty for all
+	// NoTimestamps leaves the timestamp out of each line. Journal lines are
+	// then just the message, without the host and unit either.
+	NoTimestamps bool
+	// Structured reads the journal as JSON, one entry per line, so each
+	// line carries the entry's cursor. ParseJournalEntry formats the lines.
+	Structured bool
+	// AfterCursor resumes a journal read with the entries after this cursor
+	// instead of the last Tail entries.
+	AfterCursor string
+	// Since resumes a Docker container's logs with the lines logged after it
+	// instead of the last Tail lines. Journal reads ignore it.
+	Since time.Time
+}
+
+// ErrCannotResume is returned, wrapped, when logs can't resume from where
+// they were asked to: the journal can't seek to the cursor, or the container
+// was recreated since. The caller starts over from the tail.
+var ErrCannotResume = errors.New("logs cannot resume from the last line seen")
+
+// Following reports whether the stream keeps waiting for new entries.
+// Following a previous boot never yields new entries, so it is treated as a
+// one-shot read that ends once the history has been sent.
+func (o LogOptions) Following() bool {
+	return o.Follow && (o.Boot == nil || *o.Boot == 0)
+}
+
+// ParsePriority validates a priority name for journalctl -p.
+// An empty string means no filter.
+func ParsePriority(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	value = strings.ToLower(value)
+	for _, p := range Priorities {
+		if value == p {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("invalid priority %q: must be one of %s", value, strings.Join(Priorities, ", "))
+}
+
+// ParseBoot validates a boot offset for journalctl -b, such as "0" for the
+// current boot or "-1" for the previous one. An empty string means all boots.
+func ParseBoot(value string) (*int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	boot, err := strconv.Atoi(value)
+	if err != nil || boot > 0 {
+		return nil, fmt.Errorf("invalid boot %q: must be 0 for the current boot or a negative offset such as -1", value)
+	}
+	return &boot, nil
+}
+
+// journalctlArgs builds the journalctl arguments for a unit's logs.
+func journalctlArgs(unitName string, user string, opts LogOptions) []string {
+	args := append([]string{"-u", unitName}, journalOptionArgs(opts)...)
+	if user != "" {
+		args = append([]string{"--user"}, args...)
+	}
+	return args
+}
+
+// containerJournalctlArgs builds the journalctl arguments for the logs of a
+// Docker container that uses the journald logging driver.
+func containerJournalctlArgs(containerName string, opts LogOptions) []string {
+	return append(journalOptionArgs(opts), "CONTAINER_NAME="+containerName)
+}
+
+// journalOptionArgs builds the journalctl arguments for opts.
+func journalOptionArgs(opts LogOptions) []string {
+	output := "short-iso"
+	if opts.NoTimestamps {
+		output = "cat"
+	}
+	if opts.Structured {
+		output = "json"
+	}
+	lines := fmt.Sprintf("%d", opts.Tail)
+	if opts.AfterCursor != "" {
+		// Everything after the cursor, however much was missed
+		lines = "all"
+	}
+	args := []string{"-n", lines, "--no-pager", "-o", output}
+	if opts.AfterCursor != "" {
+		args = append(args, "--after-cursor="+opts.AfterCursor)
+	}
+	if opts.Boot != nil {
+		args = append(args, "-b", fmt.Sprintf("%d", *opts.Boot))
+	}
+	if opts.Priority != "" {
+		args = append(args, "-p", opts.Priority)
+	}
+	if opts.Following() {
+		args = append(args, "-f")
+	}
+	return args
+}
+
+// cursorPattern matches a journal cursor, e.g.
+// "s=6f2a...;i=1c4;b=0e9b...;m=9a1f;t=60f1...;x=3b2c...".
+var cursorPattern = regexp.MustCompile(`^[a-z]=[0-9a-f]+(;[a-z]=[0-9a-f]+)*$`)
+
+// ValidCursor reports whether value is shaped like a journal cursor. Cursors
+// come from clients and end up in a remote shell command, so anything else
+// is refused.
+func ValidCursor(value string) bool {
+	return cursorPattern.MatchString(value)
+}
+
+// journalEntry holds the fields of a journal entry that journalctl -o json
+// prints and the log viewer shows.
+type journalEntry struct {
+	Cursor     string          `json:"__CURSOR"`
+	Realtime   string          `json:"__REALTIME_TIMESTAMP"` // microseconds since the epoch
+	Hostname   string          `json:"_HOSTNAME"`
+	Identifier string          `json:"SYSLOG_IDENTIFIER"`
+	Comm       string          `json:"_COMM"`
+	PID        string          `json:"_PID"`
+	Message    json.RawMessage `json:"MESSAGE"`
+}
+
+// shortISOLayout is the timestamp format of journalctl -o short-iso.
+const shortISOLayout = "2006-01-02T15:04:05-0700"
+
+// ParseJournalEntry parses a line of Structured journal output into the
+// entry's cursor and its text, formatted as -o short-iso formats it, or as
+// -o cat does with noTimestamps.
+func ParseJournalEntry(line []byte, noTimestamps bool) (cursor, text string, err error) {
+	var entry journalEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return "", "", fmt.Errorf("invalid journal entry: %w", err)
+	}
+	message := journalMessage(entry.Message)
+	if noTimestamps {
+		return entry.Cursor, message, nil
+	}
+
+	var b strings.Builder
+	if usec, err := strconv.ParseInt(entry.Realtime, 10, 64); err == nil {
+		b.WriteString(time.UnixMicro(usec).Format(shortISOLayout))
+		b.WriteByte(' ')
+	}
+	if entry.Hostname != "" {
+		b.WriteString(entry.Hostname)
+		b.WriteByte(' ')
+	}
+	identifier := entry.Identifier
+	if identifier == "" {
+		identifier = entry.Comm
+	}
+	b.WriteString(identifier)
+	if entry.PID != "" {
+		fmt.Fprintf(&b, "[%s]", entry.PID)
+	}
+	b.WriteString(": ")
+	b.WriteString(message)
+	return entry.Cursor, b.String(), nil
+}
+
+// journalMessage decodes a MESSAGE field, which journalctl prints as a
+// string, or as an array of bytes when it isn't valid UTF-8.
+func journalMessage(raw json.RawMessage) string {
+	var message string
+	if err := json.Unmarshal(raw, &message); err == nil {
+		return message
+	}
+	var data []int
+	if err := json.Unmarshal(raw, &data); err == nil {
+		bytes := make([]byte, len(data))
+		for i, c := range data {
+			bytes[i] = byte(c)
+		}
+		return strings.ToValidUTF8(string(bytes), "\ufffd")
+	}
+	return ""
+}
+
+// ShellQuote quotes arg for a POSIX shell if it holds characters the shell
+// would interpret, such as the semicolons of a journal cursor.
+func ShellQuote(arg string) string {
+	if arg != "" && strings.IndexFunc(arg, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("@%+=:,./-_", r))
+	}) < 0 {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
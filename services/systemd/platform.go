@@ -0,0 +1,40 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"home_server_dashboard/connlimit"
+	"home_server_dashboard/services"
+)
+
+// Platform returns the operating system and architecture of the host, from
+// uname, run over SSH for a remote host. Implements
+// services.PlatformProvider, so hosts without Docker show theirs too.
+func (p *Provider) Platform(ctx context.Context) (services.Platform, error) {
+	name, args := "uname", []string{"-sm"}
+	if !p.isLocal {
+		args = append(p.getSSHBaseArgs(), p.getSSHTarget(), "uname", "-sm")
+		name = "ssh"
+		release, err := connlimit.Acquire(ctx, p.address)
+		if err != nil {
+			return services.Platform{}, fmt.Errorf("waiting for SSH slot: %w", err)
+		}
+		defer release()
+	}
+	output, err := p.command(ctx, name, args...)
+	if err != nil {
+		return services.Platform{}, fmt.Errorf("uname failed: %w", err)
+	}
+	return parseUname(string(output))
+}
+
+// parseUname parses the output of uname -sm, e.g. "Linux aarch64".
+func parseUname(output string) (services.Platform, error) {
+	fields := strings.Fields(output)
+	if len(fields) != 2 {
+		return services.Platform{}, fmt.Errorf("unexpected uname output %q", strings.TrimSpace(output))
+	}
+	return services.NewPlatform(fields[0], fields[1], ""), nil
+}
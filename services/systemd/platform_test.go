@@ -0,0 +1,49 @@
+package systemd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestProvider_Platform(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		output  string
+		err     error
+		want    string
+		wantCmd string
+		wantErr bool
+	}{
+		{"local", "localhost", "Linux x86_64\n", nil, "linux/amd64", "uname -sm", false},
+		{"remote", "192.168.1.20", "Linux aarch64\n", nil, "linux/arm64", "ssh -o ConnectTimeout=5 -o StrictHostKeyChecking=accept-new pi@192.168.1.20 uname -sm", false},
+		{"unreachable", "192.168.1.20", "", errors.New("exit status 255"), "", "", true},
+		{"unexpected output", "localhost", "uname: invalid option\n", nil, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProviderWithEntries("pi", tt.address, nil, &SSHConfig{Username: "pi"})
+			var command string
+			p.SetRunner(func(ctx context.Context, name string, args ...string) ([]byte, error) {
+				command = strings.Join(append([]string{name}, args...), " ")
+				return []byte(tt.output), tt.err
+			})
+
+			platform, err := p.Platform(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Platform() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if platform.String() != tt.want {
+				t.Errorf("Platform() = %s, want %s", platform, tt.want)
+			}
+			if command != tt.wantCmd {
+				t.Errorf("ran %q, want %q", command, tt.wantCmd)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package systemd
+
+import (
+	"home_server_dashboard/config"
+	"home_server_dashboard/services"
+)
+
+func init() {
+	services.Register(services.Registration{
+		Source: "systemd",
+		Order:  20,
+		Configured: func(host *config.HostConfig) bool {
+			return host.HasSystemd()
+		},
+		Factory: func(cfg *config.Config, host *config.HostConfig) (services.Provider, error) {
+			return NewProviderForHost(host), nil
+		},
+		Capabilities: services.Capabilities{Logs: true, Actions: true},
+	})
+}
@@ -0,0 +1,168 @@
+package systemd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"home_server_dashboard/sshmux"
+)
+
+// defaultSSHConnectTimeout is how long ssh waits for a connection when the
+// host's SSH config doesn't say.
+const defaultSSHConnectTimeout = 5 * time.Second
+
+// sshConnectAttempts is how many times a remote action tries to connect.
+// Only failures to connect are retried; once connected the command never is.
+const sshConnectAttempts = 2
+
+// sshConnectionFailed is the exit status of ssh itself failing, as opposed
+// to the remote command.
+const sshConnectionFailed = 255
+
+// connectedMarker is echoed by the remote shell before the command runs, so
+// the connection is known to be up before the command finishes.
+const connectedMarker = "__home_server_dashboard_connected__"
+
+// ProgressFunc receives progress messages while an action runs on a remote
+// host, such as "connected" or "command finished (exit 0, 1.2s)".
+type ProgressFunc func(message string)
+
+// runSSH runs ssh with args, writing its stdout and stderr to out. It is
+// replaced in tests.
+var runSSH = func(ctx context.Context, args []string, out io.Writer) error {
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
+
+// sshBaseArgs returns the common SSH arguments including the connect
+// timeout and port if configured.
+func sshBaseArgs(sshConfig *SSHConfig) []string {
+	timeout := defaultSSHConnectTimeout
+	if sshConfig != nil && sshConfig.ConnectTimeout > 0 {
+		timeout = sshConfig.ConnectTimeout
+	}
+	// ssh only takes whole seconds
+	seconds := int((timeout + time.Second - 1) / time.Second)
+	args := []string{"-o", fmt.Sprintf("ConnectTimeout=%d", seconds), "-o", "StrictHostKeyChecking=accept-new"}
+	if sshConfig != nil && sshConfig.Port > 0 {
+		args = append(args, "-p", fmt.Sprintf("%d", sshConfig.Port))
+	}
+	return args
+}
+
+// sshLost returns err as sshmux.ErrConnectionLost if ssh itself failed
+// (exit status 255), telling the shared connection baseArgs open to target,
+// and any other error as it is.
+func sshLost(baseArgs []string, target string, err error) error {
+	if code, exited := exitCode(err); exited && code == sshConnectionFailed {
+		sshmux.Lost(baseArgs, target)
+		return sshmux.ConnectionLost(err)
+	}
+	return err
+}
+
+// markerWriter collects command output, leaving out the connected marker
+// and calling onMarker when it is seen.
+type markerWriter struct {
+	onMarker func()
+	seen     bool
+	pending  []byte // Output after the last newline
+	output   bytes.Buffer
+}
+
+func (w *markerWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		line := w.pending[:i+1]
+		if !w.seen && strings.TrimSpace(string(line)) == connectedMarker {
+			w.seen = true
+			w.onMarker()
+		} else {
+			w.output.Write(line)
+		}
+		w.pending = w.pending[i+1:]
+	}
+	return len(p), nil
+}
+
+// String returns the output, without the marker.
+func (w *markerWriter) String() string {
+	return strings.TrimSpace(w.output.String() + string(w.pending))
+}
+
+// exitCode returns the exit status in err, or false if the command didn't
+// exit (e.g. it couldn't be started or was killed when ctx ended).
+func exitCode(err error) (int, bool) {
+	if err == nil {
+		return 0, true
+	}
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		return exitErr.ExitCode(), true
+	}
+	return 0, false
+}
+
+// runRemoteCommand runs command on target over SSH, reporting each step to
+// progress if it isn't nil. A connection that fails before the command starts
+// is retried; a failing command is not, and one whose connection drops while
+// it runs fails with sshmux.ErrConnectionLost. The error includes the
+// command's output.
+func runRemoteCommand(ctx context.Context, baseArgs []string, target string, command []string, label string, progress ProgressFunc) error {
+	report := func(format string, args ...any) {
+		if progress != nil {
+			progress(fmt.Sprintf(format, args...))
+		}
+	}
+
+	// ssh joins the command with spaces for the remote shell, so the marker
+	// is echoed by the same shell that then runs the command
+	remote := append([]string{target, "echo", connectedMarker, "&&"}, command...)
+
+	for attempt := 1; ; attempt++ {
+		report("connecting to %s (attempt %d)", target, attempt)
+		// Each attempt asks for the shared connection again, so a retry
+		// reopens one that was lost
+		args := append(sshmux.Args(ctx, baseArgs, target), baseArgs...)
+		args = append(args, remote...)
+		var start time.Time
+		out := &markerWriter{onMarker: func() {
+			report("connected")
+			report("running %s", label)
+			start = time.Now()
+		}}
+		err := runSSH(ctx, args, out)
+
+		code, exited := exitCode(err)
+		if !out.seen {
+			if exited && code == sshConnectionFailed && attempt < sshConnectAttempts && ctx.Err() == nil {
+				sshmux.Lost(baseArgs, target)
+				report("connection failed, retrying")
+				continue
+			}
+		} else if exited {
+			report("command finished (exit %d, %s)", code, time.Since(start).Round(100*time.Millisecond))
+		}
+
+		if err != nil {
+			err = sshLost(baseArgs, target, err)
+			if output := out.String(); output != "" {
+				return fmt.Errorf("%w: %s", err, output)
+			}
+			return err
+		}
+		return nil
+	}
+}
@@ -0,0 +1,283 @@
+package systemd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"home_server_dashboard/services"
+	"home_server_dashboard/sshmux"
+)
+
+// fakeExit is an error carrying an exit status, like *exec.ExitError.
+type fakeExit int
+
+func (e fakeExit) Error() string { return fmt.Sprintf("exit status %d", int(e)) }
+func (e fakeExit) ExitCode() int { return int(e) }
+
+// sshRun is the output and result of one fake ssh run.
+type sshRun struct {
+	output string
+	err    error
+}
+
+// fakeSSH replaces runSSH with runs returning the given results in order, and
+// records the arguments of each.
+func fakeSSH(t *testing.T, runs ...sshRun) *[][]string {
+	t.Helper()
+	var calls [][]string
+	orig := runSSH
+	runSSH = func(ctx context.Context, args []string, out io.Writer) error {
+		calls = append(calls, args)
+		if len(calls) > len(runs) {
+			t.Fatalf("ssh run %d, want %d runs", len(calls), len(runs))
+		}
+		run := runs[len(calls)-1]
+		io.WriteString(out, run.output)
+		return run.err
+	}
+	t.Cleanup(func() { runSSH = orig })
+	return &calls
+}
+
+// recordProgress returns a progress func and the messages it received, with
+// durations replaced by "<d>" so they can be compared.
+func recordProgress() (ProgressFunc, *[]string) {
+	var messages []string
+	return func(message string) {
+		if i := strings.LastIndex(message, ", "); i >= 0 && strings.HasPrefix(message, "command finished") {
+			message = message[:i] + ", <d>)"
+		}
+		messages = append(messages, message)
+	}, &messages
+}
+
+func TestRunRemoteSystemctl_Progress(t *testing.T) {
+	calls := fakeSSH(t, sshRun{output: connectedMarker + "\n"})
+	progress, messages := recordProgress()
+
+	svc := &SystemdService{unitName: "nginx.service", address: "192.168.1.9",
+		sshConfig: &SSHConfig{Username: "admin"}, progress: progress}
+	if err := svc.Restart(context.Background()); err != nil {
+		t.Fatalf("Restart() = %v", err)
+	}
+
+	want := []string{
+		"connecting to admin@192.168.1.9 (attempt 1)",
+		"connected",
+		"running systemctl restart",
+		"command finished (exit 0, <d>)",
+	}
+	if !reflect.DeepEqual(*messages, want) {
+		t.Errorf("progress = %q, want %q", *messages, want)
+	}
+
+	args := strings.Join((*calls)[0], " ")
+	if !strings.HasSuffix(args, "admin@192.168.1.9 echo "+connectedMarker+" && sudo systemctl restart nginx.service") {
+		t.Errorf("ssh args = %s", args)
+	}
+}
+
+func TestRunRemoteSystemctl_RetriesConnection(t *testing.T) {
+	fakeSSH(t,
+		sshRun{output: "ssh: connect to host 192.168.1.9 port 22: Connection timed out\n", err: fakeExit(255)},
+		sshRun{output: connectedMarker + "\n"},
+	)
+	progress, messages := recordProgress()
+
+	svc := &SystemdService{unitName: "nginx.service", address: "192.168.1.9", progress: progress}
+	if err := svc.Start(context.Background()); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+
+	want := []string{
+		"connecting to 192.168.1.9 (attempt 1)",
+		"connection failed, retrying",
+		"connecting to 192.168.1.9 (attempt 2)",
+		"connected",
+		"running systemctl start",
+		"command finished (exit 0, <d>)",
+	}
+	if !reflect.DeepEqual(*messages, want) {
+		t.Errorf("progress = %q, want %q", *messages, want)
+	}
+}
+
+func TestRunRemoteSystemctl_ConnectionFails(t *testing.T) {
+	refused := sshRun{output: "ssh: connect to host 192.168.1.9 port 22: Connection refused\n", err: fakeExit(255)}
+	calls := fakeSSH(t, refused, refused)
+
+	svc := &SystemdService{unitName: "nginx.service", address: "192.168.1.9"}
+	err := svc.Stop(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "Connection refused") {
+		t.Errorf("Stop() = %v, want the ssh error", err)
+	}
+	if len(*calls) != sshConnectAttempts {
+		t.Errorf("ssh ran %d times, want %d", len(*calls), sshConnectAttempts)
+	}
+}
+
+func TestRunRemoteSystemctl_CommandFailsIsNotRetried(t *testing.T) {
+	// Exit 255 after connecting is the command's, so it must not run again
+	calls := fakeSSH(t, sshRun{output: connectedMarker + "\nJob for nginx.service failed.\n", err: fakeExit(255)})
+	progress, messages := recordProgress()
+
+	svc := &SystemdService{unitName: "nginx.service", address: "192.168.1.9", progress: progress}
+	err := svc.Restart(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "Job for nginx.service failed.") {
+		t.Errorf("Restart() = %v, want the command output", err)
+	}
+	if strings.Contains(err.Error(), connectedMarker) {
+		t.Errorf("error includes the marker: %v", err)
+	}
+	if len(*calls) != 1 {
+		t.Errorf("ssh ran %d times, want 1", len(*calls))
+	}
+	if last := (*messages)[len(*messages)-1]; last != "command finished (exit 255, <d>)" {
+		t.Errorf("last progress = %q", last)
+	}
+}
+
+// shareConnections configures a default sshmux.Manager whose masters always
+// connect, without running ssh.
+func shareConnections(t *testing.T) {
+	t.Helper()
+	mux, err := sshmux.New(t.TempDir(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.SetRunner(func(ctx context.Context, args ...string) ([]byte, error) {
+		if slices.Contains(args, "check") {
+			return nil, errors.New("no master")
+		}
+		return nil, nil
+	})
+	sshmux.Configure(mux)
+	t.Cleanup(func() { sshmux.Configure(nil) })
+}
+
+func TestProvider_RetriesLostSharedConnection(t *testing.T) {
+	shareConnections(t)
+	p := NewProviderWithEntries("pi", "192.168.1.20", nil, &SSHConfig{Username: "pi"})
+	var commands []string
+	p.SetRunner(func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		commands = append(commands, strings.Join(args, " "))
+		if len(commands) == 1 {
+			return nil, fakeExit(255)
+		}
+		return []byte("Linux aarch64\n"), nil
+	})
+
+	if _, err := p.Platform(context.Background()); err != nil {
+		t.Fatalf("Platform() = %v, want the command run again", err)
+	}
+	if len(commands) != 2 {
+		t.Fatalf("ssh ran %d times, want 2", len(commands))
+	}
+	for _, command := range commands {
+		if !strings.HasPrefix(command, "-o ControlMaster=no -o ControlPath=") {
+			t.Errorf("ssh args = %s, want them over the shared connection", command)
+		}
+	}
+	if stats := sshmux.HostStats(nil, "pi@192.168.1.20"); stats.Connects != 2 || stats.Sessions != 2 {
+		t.Errorf("HostStats() = %+v, want the lost connection reopened", stats)
+	}
+}
+
+func TestRunRemoteSystemctl_ConnectionLost(t *testing.T) {
+	// ssh can't tell a dropped connection from a command exiting 255, so
+	// both are reported as lost and neither is run again
+	calls := fakeSSH(t, sshRun{output: connectedMarker + "\nConnection to 192.168.1.9 closed by remote host.\n", err: fakeExit(255)})
+
+	svc := &SystemdService{unitName: "nginx.service", address: "192.168.1.9"}
+	err := svc.Restart(context.Background())
+	if !errors.Is(err, sshmux.ErrConnectionLost) {
+		t.Errorf("Restart() = %v, want sshmux.ErrConnectionLost", err)
+	}
+	if len(*calls) != 1 {
+		t.Errorf("ssh ran %d times, want 1", len(*calls))
+	}
+}
+
+func TestRunRemoteSystemctl_SilentWithoutProgress(t *testing.T) {
+	fakeSSH(t, sshRun{output: connectedMarker + "\n"})
+
+	// The monitor's providers never set progress
+	p := NewProviderWithEntries("pi", "192.168.1.9", []ServiceEntry{{Name: "nginx.service"}}, nil)
+	svc, _ := p.GetService("nginx.service")
+	if err := svc.Restart(context.Background()); err != nil {
+		t.Fatalf("Restart() = %v", err)
+	}
+
+	progress, messages := recordProgress()
+	p.SetProgress(progress)
+	fakeSSH(t, sshRun{output: connectedMarker + "\n"})
+	svc, _ = p.GetService("nginx.service")
+	if err := svc.Restart(context.Background()); err != nil {
+		t.Fatalf("Restart() = %v", err)
+	}
+	if len(*messages) == 0 {
+		t.Error("no progress from a service of a provider with progress set")
+	}
+}
+
+func TestRunRemoteSystemctl_Canceled(t *testing.T) {
+	calls := fakeSSH(t, sshRun{err: errors.New("signal: killed")})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	svc := &SystemdService{unitName: "nginx.service", address: "192.168.1.9"}
+	if err := svc.Restart(ctx); err == nil {
+		t.Error("Restart() = nil, want error")
+	}
+	if len(*calls) > 1 {
+		t.Errorf("ssh ran %d times after cancel, want at most 1", len(*calls))
+	}
+}
+
+func TestSSHBaseArgs_ConnectTimeout(t *testing.T) {
+	tests := []struct {
+		name      string
+		sshConfig *SSHConfig
+		want      string
+	}{
+		{"default", nil, "ConnectTimeout=5"},
+		{"configured", &SSHConfig{ConnectTimeout: 30 * time.Second}, "ConnectTimeout=30"},
+		{"rounded up", &SSHConfig{ConnectTimeout: 1500 * time.Millisecond}, "ConnectTimeout=2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sshBaseArgs(tt.sshConfig)[1]; got != tt.want {
+				t.Errorf("sshBaseArgs()[1] = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestGetServices_Unreachable tests that units whose state can't be read over
+// SSH are reported unknown with the error, not stopped.
+func TestGetServices_Unreachable(t *testing.T) {
+	fakeCommands(t, map[string]string{
+		"show nginx.service": "ActiveState=active\nSubState=running\nLoadState=loaded\n",
+	})
+	p := NewProviderWithEntries("pi", "192.168.1.9", []ServiceEntry{{Name: "nginx.service"}, {Name: "db.service"}, {Name: "app.service", User: "admin"}}, nil)
+
+	svcs, err := p.GetServices(context.Background())
+	if err != nil {
+		t.Fatalf("GetServices() = %v", err)
+	}
+	if svcs[0].State != services.StateRunning {
+		t.Errorf("readable unit: state = %q, want running", svcs[0].State)
+	}
+	for _, svc := range svcs[1:] {
+		if svc.State != services.StateUnknown || svc.Status != "unreachable: SSH failed: exit status 1" {
+			t.Errorf("%s: state = %q, status = %q, want unknown with the error", svc.Name, svc.State, svc.Status)
+		}
+	}
+}
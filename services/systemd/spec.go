@@ -0,0 +1,58 @@
+package systemd
+
+import (
+	"home_server_dashboard/config"
+	"home_server_dashboard/services"
+)
+
+// EntryFromSpec converts a parsed config service spec into a provider entry.
+func EntryFromSpec(spec config.ServiceSpec) ServiceEntry {
+	return ServiceEntry{
+		Name:        spec.UnitName,
+		User:        spec.User,
+		ReadOnly:    spec.ReadOnly,
+		Ports:       spec.Ports,
+		DisplayName: spec.DisplayName,
+		LogSettings: logSettingsFromSpec(spec),
+	}
+}
+
+// logSettingsFromSpec returns the log viewer defaults set in a spec, or nil
+// if it sets none.
+func logSettingsFromSpec(spec config.ServiceSpec) *services.LogSettings {
+	if spec.LogTail == 0 && spec.LogTimestamps == nil {
+		return nil
+	}
+	return &services.LogSettings{Tail: spec.LogTail, Timestamps: spec.LogTimestamps}
+}
+
+// EntriesFromSpecs converts parsed config service specs into provider entries.
+func EntriesFromSpecs(specs []config.ServiceSpec) []ServiceEntry {
+	entries := make([]ServiceEntry, 0, len(specs))
+	for _, spec := range specs {
+		entries = append(entries, EntryFromSpec(spec))
+	}
+	return entries
+}
+
+// SSHConfigFromHost returns the SSH settings for a host, or nil if none are configured.
+func SSHConfigFromHost(host *config.HostConfig) *SSHConfig {
+	if host == nil || host.SSHConfig == nil {
+		return nil
+	}
+	return &SSHConfig{
+		Username:       host.SSHConfig.Username,
+		Port:           host.SSHConfig.Port,
+		ConnectTimeout: host.GetSSHConnectTimeout(),
+	}
+}
+
+// NewProviderForHost creates a provider for every unit configured on a host.
+// Hosts with sudo journal access get passwordless sudo checked in the background.
+func NewProviderForHost(host *config.HostConfig) *Provider {
+	p := NewProviderWithEntries(host.Name, host.Address, EntriesFromSpecs(host.GetServiceSpecs()), SSHConfigFromHost(host))
+	p.journalAccess = host.GetJournalAccess()
+	p.failureDetails = host.SystemdFailureDetails
+	p.validateSudoJournal()
+	return p
+}
@@ -0,0 +1,42 @@
+package systemd
+
+import (
+	"strings"
+
+	"home_server_dashboard/services"
+)
+
+// MapState maps a unit's ActiveState and SubState to a service state.
+// activeState may be empty when only the SubState is known, as in D-Bus
+// SubState updates; the SubState then decides.
+func MapState(activeState, subState string) services.State {
+	switch activeState {
+	case "active", "reloading", "refreshing":
+		return services.StateRunning
+	case "activating":
+		return services.StateStarting
+	case "deactivating":
+		return services.StateStopping
+	case "inactive", "failed", "maintenance":
+		return services.StateStopped
+	case "":
+		return subStateToState(subState)
+	}
+	return services.StateUnknown
+}
+
+// subStateToState maps a SubState of a service, socket, timer or mount unit.
+func subStateToState(subState string) services.State {
+	switch subState {
+	case "running", "exited", "reload", "listening", "waiting", "elapsed", "mounted", "plugged", "active":
+		return services.StateRunning
+	case "condition", "start-pre", "start", "start-post", "auto-restart", "auto-restart-queued":
+		return services.StateStarting
+	case "dead", "failed", "inactive":
+		return services.StateStopped
+	}
+	if strings.HasPrefix(subState, "stop") || strings.HasPrefix(subState, "final") {
+		return services.StateStopping
+	}
+	return services.StateUnknown
+}
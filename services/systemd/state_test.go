@@ -0,0 +1,49 @@
+package systemd
+
+import (
+	"testing"
+
+	"home_server_dashboard/services"
+)
+
+func TestMapState(t *testing.T) {
+	tests := []struct {
+		activeState string
+		subState    string
+		expected    services.State
+	}{
+		{"active", "running", services.StateRunning},
+		{"active", "exited", services.StateRunning}, // oneshot with RemainAfterExit
+		{"active", "listening", services.StateRunning},
+		{"reloading", "reload", services.StateRunning},
+		{"activating", "start-pre", services.StateStarting},
+		{"activating", "start", services.StateStarting},
+		{"activating", "auto-restart", services.StateStarting},
+		{"deactivating", "stop-sigterm", services.StateStopping},
+		{"inactive", "dead", services.StateStopped},
+		{"failed", "failed", services.StateStopped},
+		{"maintenance", "cleaning", services.StateStopped},
+		{"bogus", "running", services.StateUnknown},
+
+		// SubState only, as in D-Bus SubState updates
+		{"", "running", services.StateRunning},
+		{"", "exited", services.StateRunning},
+		{"", "start-post", services.StateStarting},
+		{"", "auto-restart", services.StateStarting},
+		{"", "stop", services.StateStopping},
+		{"", "stop-post", services.StateStopping},
+		{"", "final-sigkill", services.StateStopping},
+		{"", "dead", services.StateStopped},
+		{"", "failed", services.StateStopped},
+		{"", "", services.StateUnknown},
+		{"", "unknown", services.StateUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.activeState+"/"+tt.subState, func(t *testing.T) {
+			if got := MapState(tt.activeState, tt.subState); got != tt.expected {
+				t.Errorf("MapState(%q, %q) = %q, want %q", tt.activeState, tt.subState, got, tt.expected)
+			}
+		})
+	}
+}
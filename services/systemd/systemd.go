@@ -2,15 +2,21 @@
 package systemd
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/coreos/go-systemd/v22/dbus"
 
+	"home_server_dashboard/config"
+	"home_server_dashboard/connlimit"
 	"home_server_dashboard/services"
+	"home_server_dashboard/sshmux"
 )
 
 // ServiceEntry represents a systemd service with optional flags.
@@ -24,6 +30,10 @@ type ServiceEntry struct {
 	ReadOnly bool
 	// Ports are the port numbers advertised for this service in the UI
 	Ports []uint16
+	// DisplayName is an optional friendly name shown in the UI instead of the unit name
+	DisplayName string
+	// LogSettings are the unit's log viewer defaults; nil uses the global ones
+	LogSettings *services.LogSettings
 }
 
 // SSHConfig holds SSH connection settings for remote hosts.
@@ -34,6 +44,9 @@ type SSHConfig struct {
 	// Port is the SSH port to use when connecting.
 	// If 0, the default SSH port (22) is used.
 	Port int
+	// ConnectTimeout is how long ssh waits for the connection.
+	// If 0, defaultSSHConnectTimeout is used.
+	ConnectTimeout time.Duration
 }
 
 // Provider implements services.Provider for systemd services.
@@ -43,8 +56,22 @@ type Provider struct {
 	entries   []ServiceEntry
 	isLocal   bool
 	sshConfig *SSHConfig
+	// journalAccess is how journalctl reads the system journal
+	// (config.JournalAccessGroup or config.JournalAccessSudo).
+	journalAccess string
+	// progress receives progress of actions on remote units. Nil keeps
+	// them silent, as the monitor wants.
+	progress ProgressFunc
+	// failureDetails adds the failure details of failed units to GetServices.
+	failureDetails bool
+	// runner runs the commands for remote units. Nil runs them for real.
+	runner Runner
 }
 
+// Runner runs a command and returns its output. Remote units are queried
+// and controlled through one; tests replace it with SetRunner.
+type Runner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
 // portsToPortInfo converts a slice of port numbers to PortInfo structs.
 func portsToPortInfo(ports []uint16) []services.PortInfo {
 	if len(ports) == 0 {
@@ -94,11 +121,45 @@ func (p *Provider) getSSHTarget() string {
 
 // getSSHBaseArgs returns the common SSH arguments including port if configured.
 func (p *Provider) getSSHBaseArgs() []string {
-	args := []string{"-o", "ConnectTimeout=5", "-o", "StrictHostKeyChecking=accept-new"}
-	if p.sshConfig != nil && p.sshConfig.Port > 0 {
-		args = append(args, "-p", fmt.Sprintf("%d", p.sshConfig.Port))
+	return sshBaseArgs(p.sshConfig)
+}
+
+// SetRunner sets the Runner that runs the commands of this provider and of
+// services returned by GetService afterwards. Nil runs them for real.
+func (p *Provider) SetRunner(run Runner) {
+	p.runner = run
+}
+
+// command runs name with args through the provider's Runner. ssh runs over
+// the host's shared connection, if there is one, and fails with
+// sshmux.ErrConnectionLost if the connection does. The commands run this way
+// only read, so one that lost a shared connection is run once more.
+func (p *Provider) command(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if name != "ssh" {
+		return p.run(ctx, name, args...)
+	}
+	for attempt := 1; ; attempt++ {
+		mux := sshmux.Args(ctx, p.getSSHBaseArgs(), p.getSSHTarget())
+		output, err := p.run(ctx, name, append(mux, args...)...)
+		err = sshLost(p.getSSHBaseArgs(), p.getSSHTarget(), err)
+		if mux == nil || attempt == 2 || !errors.Is(err, sshmux.ErrConnectionLost) || ctx.Err() != nil {
+			return output, err
+		}
+	}
+}
+
+// run runs name with args through the provider's Runner.
+func (p *Provider) run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if p.runner != nil {
+		return p.runner(ctx, name, args...)
 	}
-	return args
+	return runCommand(ctx, name, args...)
+}
+
+// SetProgress sets the function that receives progress of actions on remote
+// units of services returned by GetService afterwards. Nil turns it off.
+func (p *Provider) SetProgress(progress func(message string)) {
+	p.progress = progress
 }
 
 // Name returns the provider name.
@@ -117,11 +178,17 @@ func (p *Provider) findEntry(unitName string) (ServiceEntry, bool) {
 }
 
 // GetServices returns all configured systemd services.
+// With failure details turned on, failed units carry their Failure.
 func (p *Provider) GetServices(ctx context.Context) ([]services.ServiceInfo, error) {
+	getServices := p.getRemoteServices
 	if p.isLocal {
-		return p.getLocalServices(ctx)
+		getServices = p.getLocalServices
+	}
+	result, err := getServices(ctx)
+	if err == nil && p.failureDetails {
+		p.addFailureDetails(ctx, result)
 	}
-	return p.getRemoteServices(ctx)
+	return result, err
 }
 
 // getLocalServices queries systemd services on localhost via D-Bus.
@@ -186,12 +253,6 @@ func (p *Provider) getLocalSystemServices(ctx context.Context, entries []Service
 			continue
 		}
 
-		// Map systemd states to our status format
-		state := "stopped"
-		if unit.ActiveState == "active" {
-			state = "running"
-		}
-
 		status := fmt.Sprintf("%s (%s)", unit.ActiveState, unit.SubState)
 
 		// Get unit description from D-Bus
@@ -201,7 +262,7 @@ func (p *Provider) getLocalSystemServices(ctx context.Context, entries []Service
 			Name:          unit.Name,
 			Project:       "systemd",
 			ContainerName: unit.Name,
-			State:         state,
+			State:         MapState(unit.ActiveState, unit.SubState),
 			Status:        status,
 			Image:         "-",
 			Source:        "systemd",
@@ -209,6 +270,8 @@ func (p *Provider) getLocalSystemServices(ctx context.Context, entries []Service
 			Description:   description,
 			ReadOnly:      entry.ReadOnly,
 			Ports:         portsToPortInfo(entry.Ports),
+			DisplayName:   entry.DisplayName,
+			LogSettings:   entry.LogSettings,
 		})
 
 		// Remove from desired units to track what we found
@@ -217,24 +280,24 @@ func (p *Provider) getLocalSystemServices(ctx context.Context, entries []Service
 
 	// For units not found in running list, check if they exist but are inactive
 	for unitName, entry := range desiredUnits {
-		info, err := p.getLocalUnitInfo(ctx, conn, unitName)
+		info, err := p.getLocalUnitInfo(ctx, conn, entry)
 		if err != nil {
 			result = append(result, services.ServiceInfo{
 				Name:          unitName,
 				Project:       "systemd",
 				ContainerName: unitName,
-				State:         "stopped",
-				Status:        "not found",
+				State:         services.StateUnknown,
+				Status:        "error: " + err.Error(),
 				Image:         "-",
 				Source:        "systemd",
 				Host:          p.hostName,
 				ReadOnly:      entry.ReadOnly,
-			Ports:         portsToPortInfo(entry.Ports),
+				Ports:         portsToPortInfo(entry.Ports),
+				DisplayName:   entry.DisplayName,
+				LogSettings:   entry.LogSettings,
 			})
 			continue
 		}
-		info.ReadOnly = entry.ReadOnly
-		info.Ports = portsToPortInfo(entry.Ports)
 		result = append(result, info)
 	}
 
@@ -266,13 +329,15 @@ func (p *Provider) getLocalUserServices(ctx context.Context, entries []ServiceEn
 						Name:          entry.Name,
 						Project:       "systemd-user",
 						ContainerName: fmt.Sprintf("%s@%s", user, entry.Name),
-						State:         "stopped",
-						Status:        "not found",
+						State:         services.StateUnknown,
+						Status:        "error: " + err.Error(),
 						Image:         "-",
 						Source:        "systemd",
 						Host:          p.hostName,
 						ReadOnly:      entry.ReadOnly,
 						Ports:         portsToPortInfo(entry.Ports),
+						DisplayName:   entry.DisplayName,
+						LogSettings:   entry.LogSettings,
 					})
 					continue
 				}
@@ -287,13 +352,15 @@ func (p *Provider) getLocalUserServices(ctx context.Context, entries []ServiceEn
 						Name:          entry.Name,
 						Project:       "systemd-user",
 						ContainerName: fmt.Sprintf("%s@%s", user, entry.Name),
-						State:         "stopped",
+						State:         services.StateUnknown,
 						Status:        "error: " + err.Error(),
 						Image:         "-",
 						Source:        "systemd",
 						Host:          p.hostName,
 						ReadOnly:      entry.ReadOnly,
 						Ports:         portsToPortInfo(entry.Ports),
+						DisplayName:   entry.DisplayName,
+						LogSettings:   entry.LogSettings,
 					})
 					continue
 				}
@@ -313,11 +380,6 @@ func (p *Provider) getUserUnitInfo(ctx context.Context, conn *dbus.Conn, entry S
 	}
 
 	activeState := strings.Trim(prop.Value.String(), "\"")
-	state := "stopped"
-	if activeState == "active" {
-		state = "running"
-	}
-
 	subProp, _ := conn.GetUnitPropertyContext(ctx, entry.Name, "SubState")
 	subState := "unknown"
 	if subProp != nil {
@@ -331,11 +393,11 @@ func (p *Provider) getUserUnitInfo(ctx context.Context, conn *dbus.Conn, entry S
 		description = strings.Trim(descProp.Value.String(), "\"")
 	}
 
-	return services.ServiceInfo{
+	info := services.ServiceInfo{
 		Name:          entry.Name,
 		Project:       "systemd-user",
 		ContainerName: fmt.Sprintf("%s@%s", user, entry.Name),
-		State:         state,
+		State:         MapState(activeState, subState),
 		Status:        fmt.Sprintf("%s (%s)", activeState, subState),
 		Image:         "-",
 		Source:        "systemd",
@@ -343,7 +405,11 @@ func (p *Provider) getUserUnitInfo(ctx context.Context, conn *dbus.Conn, entry S
 		Description:   description,
 		ReadOnly:      entry.ReadOnly,
 		Ports:         portsToPortInfo(entry.Ports),
-	}, nil
+		DisplayName:   entry.DisplayName,
+		LogSettings:   entry.LogSettings,
+	}
+	setDBusTimestamps(ctx, conn, entry.Name, &info)
+	return info, nil
 }
 
 // getUserUnitInfoViaExec gets user service info by running systemctl --user command.
@@ -372,21 +438,16 @@ func (p *Provider) getUserUnitInfoViaExec(ctx context.Context, entry ServiceEntr
 	loadState := props["LoadState"]
 	description := props["Description"]
 
-	state := "stopped"
-	if activeState == "active" {
-		state = "running"
-	}
-
 	status := fmt.Sprintf("%s (%s)", activeState, subState)
 	if loadState == "not-found" {
 		status = "not found"
 	}
 
-	return services.ServiceInfo{
+	info := services.ServiceInfo{
 		Name:          entry.Name,
 		Project:       "systemd-user",
 		ContainerName: fmt.Sprintf("%s@%s", user, entry.Name),
-		State:         state,
+		State:         MapState(activeState, subState),
 		Status:        status,
 		Image:         "-",
 		Source:        "systemd",
@@ -394,22 +455,29 @@ func (p *Provider) getUserUnitInfoViaExec(ctx context.Context, entry ServiceEntr
 		Description:   description,
 		ReadOnly:      entry.ReadOnly,
 		Ports:         portsToPortInfo(entry.Ports),
-	}, nil
+		DisplayName:   entry.DisplayName,
+		LogSettings:   entry.LogSettings,
+	}
+	readShowTimestamps(&info, func(args ...string) ([]byte, error) {
+		return exec.CommandContext(ctx, "systemctl", append([]string{"--user", "--machine=" + user + "@", "show", entry.Name}, args...)...).Output()
+	})
+	return info, nil
+}
+
+// unitProperties reads the properties of a unit; *dbus.Conn implements it.
+type unitProperties interface {
+	GetUnitPropertyContext(ctx context.Context, unit string, propertyName string) (*dbus.Property, error)
 }
 
 // getLocalUnitInfo gets info for a single unit via D-Bus.
-func (p *Provider) getLocalUnitInfo(ctx context.Context, conn *dbus.Conn, unitName string) (services.ServiceInfo, error) {
+func (p *Provider) getLocalUnitInfo(ctx context.Context, conn unitProperties, entry ServiceEntry) (services.ServiceInfo, error) {
+	unitName := entry.Name
 	prop, err := conn.GetUnitPropertyContext(ctx, unitName, "ActiveState")
 	if err != nil {
 		return services.ServiceInfo{}, err
 	}
 
 	activeState := strings.Trim(prop.Value.String(), "\"")
-	state := "stopped"
-	if activeState == "active" {
-		state = "running"
-	}
-
 	subProp, _ := conn.GetUnitPropertyContext(ctx, unitName, "SubState")
 	subState := "unknown"
 	if subProp != nil {
@@ -419,21 +487,27 @@ func (p *Provider) getLocalUnitInfo(ctx context.Context, conn *dbus.Conn, unitNa
 	// Get unit description
 	description := p.getLocalUnitDescription(ctx, conn, unitName)
 
-	return services.ServiceInfo{
+	info := services.ServiceInfo{
 		Name:          unitName,
 		Project:       "systemd",
 		ContainerName: unitName,
-		State:         state,
+		State:         MapState(activeState, subState),
 		Status:        fmt.Sprintf("%s (%s)", activeState, subState),
 		Image:         "-",
 		Source:        "systemd",
 		Host:          p.hostName,
 		Description:   description,
-	}, nil
+		ReadOnly:      entry.ReadOnly,
+		Ports:         portsToPortInfo(entry.Ports),
+		DisplayName:   entry.DisplayName,
+		LogSettings:   entry.LogSettings,
+	}
+	setDBusTimestamps(ctx, conn, unitName, &info)
+	return info, nil
 }
 
 // getLocalUnitDescription gets the description for a unit via D-Bus.
-func (p *Provider) getLocalUnitDescription(ctx context.Context, conn *dbus.Conn, unitName string) string {
+func (p *Provider) getLocalUnitDescription(ctx context.Context, conn unitProperties, unitName string) string {
 	prop, err := conn.GetUnitPropertyContext(ctx, unitName, "Description")
 	if err != nil {
 		return ""
@@ -454,7 +528,7 @@ func (p *Provider) getRemoteServices(ctx context.Context) ([]services.ServiceInf
 			info, err = p.getRemoteUserUnitInfo(ctx, entry)
 		} else {
 			// System service: use systemctl via SSH
-			info, err = p.getRemoteUnitInfo(ctx, entry.Name)
+			info, err = p.getRemoteUnitInfo(ctx, entry)
 		}
 
 		if err != nil {
@@ -468,18 +542,18 @@ func (p *Provider) getRemoteServices(ctx context.Context) ([]services.ServiceInf
 				Name:          entry.Name,
 				Project:       project,
 				ContainerName: containerName,
-				State:         "stopped",
-				Status:        "unreachable",
+				State:         services.StateUnknown,
+				Status:        "unreachable: " + err.Error(),
 				Image:         "-",
 				Source:        "systemd",
 				Host:          p.hostName,
 				ReadOnly:      entry.ReadOnly,
 				Ports:         portsToPortInfo(entry.Ports),
+				DisplayName:   entry.DisplayName,
+				LogSettings:   entry.LogSettings,
 			})
 			continue
 		}
-		info.ReadOnly = entry.ReadOnly
-		info.Ports = portsToPortInfo(entry.Ports)
 		result = append(result, info)
 	}
 
@@ -490,16 +564,14 @@ func (p *Provider) getRemoteServices(ctx context.Context) ([]services.ServiceInf
 func (p *Provider) getRemoteUserUnitInfo(ctx context.Context, entry ServiceEntry) (services.ServiceInfo, error) {
 	// For user services, we need to run systemctl --user as the specified user
 	// Using sudo -u <user> with XDG_RUNTIME_DIR set
-	shellCmd := fmt.Sprintf("sudo -u %s XDG_RUNTIME_DIR=/run/user/$(id -u %s) systemctl --user show %s --property=ActiveState,SubState,LoadState,Description",
-		entry.User, entry.User, entry.Name)
-
-	sshArgs := p.getSSHBaseArgs()
-	sshArgs = append(sshArgs, p.getSSHTarget(), "bash", "-c", shellCmd)
-	cmd := exec.CommandContext(ctx, "ssh", sshArgs...)
-
-	output, err := cmd.Output()
+	show := func(args ...string) ([]byte, error) {
+		shellCmd := fmt.Sprintf("sudo -u %s XDG_RUNTIME_DIR=/run/user/$(id -u %s) systemctl --user show %s %s",
+			entry.User, entry.User, entry.Name, strings.Join(args, " "))
+		return p.remoteCommand(ctx, "bash", "-c", shellCmd)
+	}
+	output, err := show("--property=ActiveState,SubState,LoadState,Description")
 	if err != nil {
-		return services.ServiceInfo{}, fmt.Errorf("SSH failed: %w", err)
+		return services.ServiceInfo{}, err
 	}
 
 	// Parse the output
@@ -516,21 +588,16 @@ func (p *Provider) getRemoteUserUnitInfo(ctx context.Context, entry ServiceEntry
 	loadState := props["LoadState"]
 	description := props["Description"]
 
-	state := "stopped"
-	if activeState == "active" {
-		state = "running"
-	}
-
 	status := fmt.Sprintf("%s (%s)", activeState, subState)
 	if loadState == "not-found" {
 		status = "not found"
 	}
 
-	return services.ServiceInfo{
+	info := services.ServiceInfo{
 		Name:          entry.Name,
 		Project:       "systemd-user",
 		ContainerName: fmt.Sprintf("%s@%s", entry.User, entry.Name),
-		State:         state,
+		State:         MapState(activeState, subState),
 		Status:        status,
 		Image:         "-",
 		Source:        "systemd",
@@ -538,18 +605,22 @@ func (p *Provider) getRemoteUserUnitInfo(ctx context.Context, entry ServiceEntry
 		Description:   description,
 		ReadOnly:      entry.ReadOnly,
 		Ports:         portsToPortInfo(entry.Ports),
-	}, nil
+		DisplayName:   entry.DisplayName,
+		LogSettings:   entry.LogSettings,
+	}
+	readShowTimestamps(&info, show)
+	return info, nil
 }
 
 // getRemoteUnitInfo gets info for a single unit via SSH.
-func (p *Provider) getRemoteUnitInfo(ctx context.Context, unitName string) (services.ServiceInfo, error) {
-	sshArgs := p.getSSHBaseArgs()
-	sshArgs = append(sshArgs, p.getSSHTarget(), "systemctl", "show", unitName, "--property=ActiveState,SubState,LoadState,Description")
-	cmd := exec.CommandContext(ctx, "ssh", sshArgs...)
-
-	output, err := cmd.Output()
+func (p *Provider) getRemoteUnitInfo(ctx context.Context, entry ServiceEntry) (services.ServiceInfo, error) {
+	unitName := entry.Name
+	show := func(args ...string) ([]byte, error) {
+		return p.remoteCommand(ctx, append([]string{"systemctl", "show", unitName}, args...)...)
+	}
+	output, err := show("--property=ActiveState,SubState,LoadState,Description")
 	if err != nil {
-		return services.ServiceInfo{}, fmt.Errorf("SSH failed: %w", err)
+		return services.ServiceInfo{}, err
 	}
 
 	// Parse the output
@@ -566,64 +637,95 @@ func (p *Provider) getRemoteUnitInfo(ctx context.Context, unitName string) (serv
 	loadState := props["LoadState"]
 	description := props["Description"]
 
-	state := "stopped"
-	if activeState == "active" {
-		state = "running"
-	}
-
 	status := fmt.Sprintf("%s (%s)", activeState, subState)
 	if loadState == "not-found" {
 		status = "not found"
 	}
 
-	return services.ServiceInfo{
+	info := services.ServiceInfo{
 		Name:          unitName,
 		Project:       "systemd",
 		ContainerName: unitName,
-		State:         state,
+		State:         MapState(activeState, subState),
 		Status:        status,
 		Image:         "-",
 		Source:        "systemd",
 		Host:          p.hostName,
 		Description:   description,
-	}, nil
+		ReadOnly:      entry.ReadOnly,
+		Ports:         portsToPortInfo(entry.Ports),
+		DisplayName:   entry.DisplayName,
+		LogSettings:   entry.LogSettings,
+	}
+	readShowTimestamps(&info, show)
+	return info, nil
+}
+
+// remoteCommand runs a command on the host over SSH, in one of its SSH slots.
+func (p *Provider) remoteCommand(ctx context.Context, command ...string) ([]byte, error) {
+	sshArgs := p.getSSHBaseArgs()
+	sshArgs = append(sshArgs, p.getSSHTarget())
+	sshArgs = append(sshArgs, command...)
+
+	release, err := connlimit.Acquire(ctx, p.address)
+	if err != nil {
+		return nil, fmt.Errorf("waiting for SSH slot: %w", err)
+	}
+	output, err := p.command(ctx, "ssh", sshArgs...)
+	release()
+	if err != nil {
+		return nil, fmt.Errorf("SSH failed: %w", err)
+	}
+	return output, nil
 }
 
 // GetService returns a specific systemd service by unit name.
 func (p *Provider) GetService(name string) (services.Service, error) {
 	entry, _ := p.findEntry(name)
 	return &SystemdService{
-		unitName:  name,
-		hostName:  p.hostName,
-		address:   p.address,
-		isLocal:   p.isLocal,
-		user:      entry.User,
-		sshConfig: p.sshConfig,
+		unitName:      name,
+		hostName:      p.hostName,
+		address:       p.address,
+		isLocal:       p.isLocal,
+		user:          entry.User,
+		sshConfig:     p.sshConfig,
+		journalAccess: p.journalAccess,
+		progress:      p.progress,
+		runner:        p.runner,
 	}, nil
 }
 
 // GetLogs streams logs for a specific unit.
 func (p *Provider) GetLogs(ctx context.Context, unitName string, tailLines int, follow bool) (io.ReadCloser, error) {
+	return p.GetLogsWithOptions(ctx, unitName, LogOptions{Tail: tailLines, Follow: follow})
+}
+
+// GetLogsWithOptions streams logs for a specific unit, filtered by boot and priority.
+func (p *Provider) GetLogsWithOptions(ctx context.Context, unitName string, opts LogOptions) (io.ReadCloser, error) {
 	entry, _ := p.findEntry(unitName)
 	svc := &SystemdService{
-		unitName:  unitName,
-		hostName:  p.hostName,
-		address:   p.address,
-		isLocal:   p.isLocal,
-		user:      entry.User,
-		sshConfig: p.sshConfig,
-	}
-	return svc.GetLogs(ctx, tailLines, follow)
+		unitName:      unitName,
+		hostName:      p.hostName,
+		address:       p.address,
+		isLocal:       p.isLocal,
+		user:          entry.User,
+		sshConfig:     p.sshConfig,
+		journalAccess: p.journalAccess,
+	}
+	return svc.GetLogsWithOptions(ctx, opts)
 }
 
 // SystemdService represents a single systemd unit.
 type SystemdService struct {
-	unitName  string
-	hostName  string
-	address   string
-	isLocal   bool
-	user      string     // User for user-level services (empty for system services)
-	sshConfig *SSHConfig // SSH configuration for remote hosts
+	unitName      string
+	hostName      string
+	address       string
+	isLocal       bool
+	user          string       // User for user-level services (empty for system services)
+	sshConfig     *SSHConfig   // SSH configuration for remote hosts
+	journalAccess string       // How journalctl reads the system journal
+	progress      ProgressFunc // Receives progress of remote actions; may be nil
+	runner        Runner       // Runs the unit's commands; nil runs them for real
 }
 
 // getSSHTarget returns the SSH target string (user@host or just host).
@@ -636,11 +738,14 @@ func (s *SystemdService) getSSHTarget() string {
 
 // getSSHBaseArgs returns the common SSH arguments including port if configured.
 func (s *SystemdService) getSSHBaseArgs() []string {
-	args := []string{"-o", "ConnectTimeout=5", "-o", "StrictHostKeyChecking=accept-new"}
-	if s.sshConfig != nil && s.sshConfig.Port > 0 {
-		args = append(args, "-p", fmt.Sprintf("%d", s.sshConfig.Port))
-	}
-	return args
+	return sshBaseArgs(s.sshConfig)
+}
+
+// SetProgress sets the function that receives progress of actions when the
+// unit is remote: connecting, connected, running and the command's exit
+// status. Nil turns it off.
+func (s *SystemdService) SetProgress(progress func(message string)) {
+	s.progress = progress
 }
 
 // GetInfo returns the current status of the unit.
@@ -663,11 +768,6 @@ func (s *SystemdService) GetInfo(ctx context.Context) (services.ServiceInfo, err
 		}
 
 		activeState := strings.Trim(prop.Value.String(), "\"")
-		state := "stopped"
-		if activeState == "active" {
-			state = "running"
-		}
-
 		subProp, _ := conn.GetUnitPropertyContext(ctx, s.unitName, "SubState")
 		subState := "unknown"
 		if subProp != nil {
@@ -685,7 +785,7 @@ func (s *SystemdService) GetInfo(ctx context.Context) (services.ServiceInfo, err
 			Name:          s.unitName,
 			Project:       "systemd",
 			ContainerName: s.unitName,
-			State:         state,
+			State:         MapState(activeState, subState),
 			Status:        fmt.Sprintf("%s (%s)", activeState, subState),
 			Image:         "-",
 			Source:        "systemd",
@@ -700,7 +800,7 @@ func (s *SystemdService) GetInfo(ctx context.Context) (services.ServiceInfo, err
 		return provider.getRemoteUserUnitInfo(ctx, ServiceEntry{Name: s.unitName, User: s.user})
 	}
 	provider := &Provider{address: s.address, hostName: s.hostName}
-	return provider.getRemoteUnitInfo(ctx, s.unitName)
+	return provider.getRemoteUnitInfo(ctx, ServiceEntry{Name: s.unitName})
 }
 
 // getLocalUserInfo gets info for a local user service.
@@ -716,11 +816,6 @@ func (s *SystemdService) getLocalUserInfo(ctx context.Context) (services.Service
 		}
 
 		activeState := strings.Trim(prop.Value.String(), "\"")
-		state := "stopped"
-		if activeState == "active" {
-			state = "running"
-		}
-
 		subProp, _ := conn.GetUnitPropertyContext(ctx, s.unitName, "SubState")
 		subState := "unknown"
 		if subProp != nil {
@@ -733,17 +828,19 @@ func (s *SystemdService) getLocalUserInfo(ctx context.Context) (services.Service
 			description = strings.Trim(descProp.Value.String(), "\"")
 		}
 
-		return services.ServiceInfo{
+		info := services.ServiceInfo{
 			Name:          s.unitName,
 			Project:       "systemd-user",
 			ContainerName: fmt.Sprintf("%s@%s", s.user, s.unitName),
-			State:         state,
+			State:         MapState(activeState, subState),
 			Status:        fmt.Sprintf("%s (%s)", activeState, subState),
 			Image:         "-",
 			Source:        "systemd",
 			Host:          s.hostName,
 			Description:   description,
-		}, nil
+		}
+		setDBusTimestamps(ctx, conn, s.unitName, &info)
+		return info, nil
 	}
 
 	// Fall back to exec with --machine option
@@ -768,77 +865,111 @@ func (s *SystemdService) getLocalUserInfo(ctx context.Context) (services.Service
 	loadState := props["LoadState"]
 	description := props["Description"]
 
-	state := "stopped"
-	if activeState == "active" {
-		state = "running"
-	}
-
 	status := fmt.Sprintf("%s (%s)", activeState, subState)
 	if loadState == "not-found" {
 		status = "not found"
 	}
 
-	return services.ServiceInfo{
+	info := services.ServiceInfo{
 		Name:          s.unitName,
 		Project:       "systemd-user",
 		ContainerName: fmt.Sprintf("%s@%s", s.user, s.unitName),
-		State:         state,
+		State:         MapState(activeState, subState),
 		Status:        status,
 		Image:         "-",
 		Source:        "systemd",
 		Host:          s.hostName,
 		Description:   description,
-	}, nil
+	}
+	readShowTimestamps(&info, func(args ...string) ([]byte, error) {
+		return exec.CommandContext(ctx, "systemctl", append([]string{"--user", "--machine=" + s.user + "@", "show", s.unitName}, args...)...).Output()
+	})
+	return info, nil
 }
 
 // GetLogs returns a stream of logs for the unit.
 func (s *SystemdService) GetLogs(ctx context.Context, tailLines int, follow bool) (io.ReadCloser, error) {
-	var cmd *exec.Cmd
+	return s.GetLogsWithOptions(ctx, LogOptions{Tail: tailLines, Follow: follow})
+}
 
-	// Build base args for journalctl
-	args := []string{"-u", s.unitName, "-n", fmt.Sprintf("%d", tailLines), "--no-pager", "-o", "short-iso"}
-	if follow {
-		args = append(args, "-f")
-	}
+// logsCommand builds the journalctl command for the unit's logs.
+// Local units run journalctl directly; remote units run it over SSH.
+func (s *SystemdService) logsCommand(ctx context.Context, opts LogOptions) *exec.Cmd {
+	// For local user services, the --user flag works when the dashboard
+	// runs as the same user
+	args := journalctlArgs(s.unitName, s.user, opts)
 
 	if s.isLocal {
-		if s.user != "" {
-			// For local user services, just use --user flag
-			// This works when the dashboard runs as the same user
-			args = append([]string{"--user"}, args...)
-		}
-		cmd = exec.CommandContext(ctx, "journalctl", args...)
+		command := journalctlCommand(s.journalAccess, s.user, args)
+		return exec.CommandContext(ctx, command[0], command[1:]...)
+	}
+
+	// The remote shell runs the command, so the arguments are quoted
+	for i, arg := range args {
+		args[i] = ShellQuote(arg)
+	}
+	sshArgs := s.getSSHBaseArgs()
+	sshArgs = append(sshmux.Args(ctx, sshArgs, s.getSSHTarget()), sshArgs...)
+	if s.user != "" {
+		// For remote user services, run as that user via sudo
+		shellCmd := fmt.Sprintf("sudo -u %s XDG_RUNTIME_DIR=/run/user/$(id -u %s) journalctl %s",
+			s.user, s.user, strings.Join(args, " "))
+		sshArgs = append(sshArgs, s.getSSHTarget(), "bash", "-c", shellCmd)
 	} else {
-		if s.user != "" {
-			// For remote user services, run as that user via sudo
-			// Add --user flag to the args
-			userArgs := append([]string{"--user"}, args...)
-			shellCmd := fmt.Sprintf("sudo -u %s XDG_RUNTIME_DIR=/run/user/$(id -u %s) journalctl %s",
-				s.user, s.user, strings.Join(userArgs, " "))
-			sshArgs := s.getSSHBaseArgs()
-			sshArgs = append(sshArgs, s.getSSHTarget(), "bash", "-c", shellCmd)
-			cmd = exec.CommandContext(ctx, "ssh", sshArgs...)
-		} else {
-			sshArgs := s.getSSHBaseArgs()
-			sshArgs = append(sshArgs, s.getSSHTarget(), "journalctl")
-			sshArgs = append(sshArgs, args...)
-			cmd = exec.CommandContext(ctx, "ssh", sshArgs...)
+		sshArgs = append(sshArgs, s.getSSHTarget())
+		sshArgs = append(sshArgs, journalctlCommand(s.journalAccess, "", args)...)
+	}
+	return exec.CommandContext(ctx, "ssh", sshArgs...)
+}
+
+// GetLogsWithOptions returns a stream of logs for the unit, filtered by boot and priority.
+func (s *SystemdService) GetLogsWithOptions(ctx context.Context, opts LogOptions) (io.ReadCloser, error) {
+	cmd := s.logsCommand(ctx, opts)
+
+	// Remote streams only hold an SSH slot while the session is being started,
+	// so open log viewers don't starve polling of the same host
+	if !s.isLocal {
+		release, err := connlimit.Acquire(ctx, s.address)
+		if err != nil {
+			return nil, fmt.Errorf("waiting for SSH slot: %w", err)
 		}
+		defer release()
 	}
 
+	return startJournal(cmd, newJournalAccessError(s.hostName, s.isLocal, s.sshConfig, s.journalAccess))
+}
+
+// ContainerLogs streams the journal entries of a Docker container on the
+// local host that uses the journald logging driver.
+func ContainerLogs(ctx context.Context, containerName string, opts LogOptions) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, "journalctl", containerJournalctlArgs(containerName, opts)...)
+	return startJournal(cmd, newJournalAccessError("localhost", true, nil, config.JournalAccessGroup))
+}
+
+// startJournal starts a journalctl command and returns its output. If
+// journalctl reports that it may not read the journal, the output ends with
+// access filled in as the error.
+func startJournal(cmd *exec.Cmd, access *JournalAccessError) (io.ReadCloser, error) {
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
 
 	if err := cmd.Start(); err != nil {
 		return nil, fmt.Errorf("failed to start journalctl: %w", err)
 	}
 
-	return &journalReader{
-		stdout: stdout,
-		cmd:    cmd,
-	}, nil
+	r := &journalReader{
+		stdout:     stdout,
+		cmd:        cmd,
+		stderrDone: make(chan struct{}),
+	}
+	go r.watchStderr(stderr, access)
+	return r, nil
 }
 
 // Start starts the unit.
@@ -968,31 +1099,24 @@ func (s *SystemdService) runLocalUserSystemctl(ctx context.Context, action strin
 // Requires sudoers configuration on the remote host.
 // For user services, runs systemctl --user as the specified user.
 func (s *SystemdService) runRemoteSystemctl(ctx context.Context, action string) error {
-	var cmd *exec.Cmd
-
+	var command []string
 	if s.user != "" {
 		// For user services, run systemctl --user as the specified user via sudo
 		shellCmd := fmt.Sprintf("sudo -u %s XDG_RUNTIME_DIR=/run/user/$(id -u %s) systemctl --user %s %s",
 			s.user, s.user, action, s.unitName)
-		sshArgs := s.getSSHBaseArgs()
-		sshArgs = append(sshArgs, s.getSSHTarget(), "bash", "-c", shellCmd)
-		cmd = exec.CommandContext(ctx, "ssh", sshArgs...)
+		command = []string{"bash", "-c", shellCmd}
 	} else {
 		// System service uses sudo systemctl
-		sshArgs := s.getSSHBaseArgs()
-		sshArgs = append(sshArgs, s.getSSHTarget(), "sudo", "systemctl", action, s.unitName)
-		cmd = exec.CommandContext(ctx, "ssh", sshArgs...)
+		command = []string{"sudo", "systemctl", action, s.unitName}
 	}
 
-	output, err := cmd.CombinedOutput()
+	release, err := connlimit.Acquire(ctx, s.address)
 	if err != nil {
-		outputStr := strings.TrimSpace(string(output))
-		if outputStr != "" {
-			return fmt.Errorf("%w: %s", err, outputStr)
-		}
-		return err
+		return fmt.Errorf("waiting for SSH slot: %w", err)
 	}
-	return nil
+	defer release()
+
+	return runRemoteCommand(ctx, s.getSSHBaseArgs(), s.getSSHTarget(), command, "systemctl "+action, s.progress)
 }
 
 // GetName returns the unit name.
@@ -1014,11 +1138,61 @@ func (s *SystemdService) GetSource() string {
 type journalReader struct {
 	stdout io.ReadCloser
 	cmd    *exec.Cmd
+
+	// stderrDone is closed once stderr has been read; accessErr is set
+	// before that if journalctl could not read the journal, and resumeErr
+	// if it could not seek to the cursor to resume after.
+	stderrDone chan struct{}
+	accessErr  error
+	resumeErr  error
 }
 
-// Read reads from the journal output.
+// watchStderr scans journalctl's stderr for access problems. journalctl
+// keeps following even when it can only see the user's own journal, so the
+// process is killed to end the stream with the error.
+func (r *journalReader) watchStderr(stderr io.Reader, access *JournalAccessError) {
+	defer close(r.stderrDone)
+
+	var groups []string
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "Failed to seek to cursor") && r.resumeErr == nil {
+			r.resumeErr = fmt.Errorf("%s: %w", line, ErrCannotResume)
+		}
+		lineGroups, denied := parseJournalAccess(line)
+		groups = append(groups, lineGroups...)
+		if denied && r.accessErr == nil {
+			e := *access
+			e.Detail = line
+			r.accessErr = &e
+			// The rest of the hint is already buffered and still gets read
+			if r.cmd.Process != nil {
+				r.cmd.Process.Kill()
+			}
+		}
+	}
+	if e, ok := r.accessErr.(*JournalAccessError); ok {
+		e.Groups = groups
+	}
+}
+
+// Read reads from the journal output. Once the output ends, Read returns a
+// *JournalAccessError instead of io.EOF if journalctl could not read the
+// journal, or an error wrapping ErrCannotResume if it could not seek to the
+// cursor it was to resume after.
 func (r *journalReader) Read(p []byte) (n int, err error) {
-	return r.stdout.Read(p)
+	n, err = r.stdout.Read(p)
+	if err == io.EOF && r.stderrDone != nil {
+		<-r.stderrDone
+		if r.accessErr != nil {
+			return n, r.accessErr
+		}
+		if r.resumeErr != nil {
+			return n, r.resumeErr
+		}
+	}
+	return n, err
 }
 
 // Close closes the journal stream and kills the process.
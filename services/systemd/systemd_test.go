@@ -4,9 +4,18 @@ package systemd
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
+	"os/exec"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/services"
 )
 
 // TestNewProvider tests the NewProvider constructor.
@@ -430,6 +439,29 @@ func TestNewProviderWithEntries_ReadOnly(t *testing.T) {
 	}
 }
 
+// TestNewProviderForHost tests that a provider built from host config uses parsed specs.
+func TestNewProviderForHost(t *testing.T) {
+	host := &config.HostConfig{
+		Name:            "nas",
+		Address:         "192.168.1.20",
+		SystemdServices: []string{"docker.service", "xero:zunesync.service#8080:ro|name=Zune Sync", ":ro"},
+		SSHConfig:       &config.SSHConfig{Username: "admin", Port: 2222},
+	}
+
+	p := NewProviderForHost(host)
+
+	if len(p.entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", p.entries)
+	}
+	want := ServiceEntry{Name: "zunesync.service", User: "xero", ReadOnly: true, Ports: []uint16{8080}, DisplayName: "Zune Sync"}
+	if got := p.entries[1]; !reflect.DeepEqual(got, want) {
+		t.Errorf("entries[1] = %+v, want %+v", got, want)
+	}
+	if target := p.getSSHTarget(); target != "admin@192.168.1.20" {
+		t.Errorf("getSSHTarget() = %q, want %q", target, "admin@192.168.1.20")
+	}
+}
+
 // TestNewProvider_AlwaysNonReadOnly tests that NewProvider creates non-read-only entries.
 func TestNewProvider_AlwaysNonReadOnly(t *testing.T) {
 	// Even if the unit name contains ":ro", NewProvider should not parse it
@@ -732,3 +764,568 @@ func TestProvider_getSSHBaseArgs(t *testing.T) {
 		}
 	})
 }
+
+// TestParseSystemdTimestamp tests parsing of systemctl show timestamp properties.
+func TestParseSystemdTimestamp(t *testing.T) {
+	want := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		input string
+		want  *time.Time
+	}{
+		{"empty", "", nil},
+		{"never entered", "n/a", nil},
+		{"unix format", "@1740830400", &want},
+		// The host's local time is ambiguous, so it isn't parsed
+		{"default format", "Sat 2025-03-01 12:00:00 UTC", nil},
+		{"garbage", "@yesterday", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseSystemdTimestamp(tt.input)
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("parseSystemdTimestamp(%q) = %v, want nil", tt.input, got)
+				}
+				return
+			}
+			if got == nil || !got.Equal(*tt.want) {
+				t.Errorf("parseSystemdTimestamp(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestUsecToTime tests conversion of D-Bus microsecond timestamps.
+func TestUsecToTime(t *testing.T) {
+	if got := usecToTime(0); got != nil {
+		t.Errorf("usecToTime(0) = %v, want nil", got)
+	}
+	got := usecToTime(1740830400000000)
+	if got == nil || !got.Equal(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("usecToTime() = %v, want 2025-03-01 12:00:00 UTC", got)
+	}
+}
+
+// TestSetTimestamps tests which timestamps a unit gets from its properties,
+// given as the microseconds since the epoch D-Bus reports.
+func TestSetTimestamps(t *testing.T) {
+	entered := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	left := time.Date(2025, 3, 2, 8, 30, 0, 0, time.UTC)
+	usec := map[string]uint64{
+		"StateChangeTimestamp":   uint64(left.UnixMicro()),
+		"ActiveEnterTimestamp":   uint64(entered.UnixMicro()),
+		"InactiveEnterTimestamp": uint64(left.UnixMicro()),
+	}
+	get := func(property string) *time.Time { return usecToTime(usec[property]) }
+
+	stopped := services.ServiceInfo{State: services.StateStopped}
+	setTimestamps(&stopped, get)
+	if stopped.StartedAt == nil || !stopped.StartedAt.Equal(entered) {
+		t.Errorf("StartedAt = %v, want %v", stopped.StartedAt, entered)
+	}
+	if stopped.FinishedAt == nil || !stopped.FinishedAt.Equal(left) {
+		t.Errorf("FinishedAt = %v, want %v", stopped.FinishedAt, left)
+	}
+	if stopped.StateSince == nil || !stopped.StateSince.Equal(left) || stopped.LastStateChange != stopped.StateSince {
+		t.Errorf("StateSince, LastStateChange = %v, %v, want %v", stopped.StateSince, stopped.LastStateChange, left)
+	}
+
+	// A running unit's InactiveEnterTimestamp is from an earlier stop
+	running := services.ServiceInfo{State: services.StateRunning}
+	setTimestamps(&running, get)
+	if running.FinishedAt != nil {
+		t.Errorf("FinishedAt of a running unit = %v, want nil", running.FinishedAt)
+	}
+
+	// systemd reports 0 for a unit that never started
+	usec = map[string]uint64{}
+	never := services.ServiceInfo{State: services.StateStopped}
+	setTimestamps(&never, get)
+	if never.StartedAt != nil || never.FinishedAt != nil || never.StateSince != nil {
+		t.Errorf("timestamps of a unit that never started = %v, %v, %v, want nil", never.StartedAt, never.FinishedAt, never.StateSince)
+	}
+}
+
+// TestGetRemoteUnitInfo_Timestamps tests that the timestamps of a remote
+// unit are asked for as unix times, apart from its state, and parsed from
+// systemctl show.
+func TestGetRemoteUnitInfo_Timestamps(t *testing.T) {
+	tests := []struct {
+		name         string
+		output       string
+		wantStarted  bool
+		wantFinished bool
+	}{
+		{
+			name:         "failed unit",
+			output:       "StateChangeTimestamp=@1740830700\nActiveEnterTimestamp=@1740830400\nInactiveEnterTimestamp=@1740830700\n",
+			wantStarted:  true,
+			wantFinished: true,
+		},
+		{
+			name:   "never started",
+			output: "StateChangeTimestamp=n/a\nActiveEnterTimestamp=n/a\nInactiveEnterTimestamp=n/a\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			p := &Provider{hostName: "pi", address: "192.168.1.20"}
+			p.SetRunner(func(ctx context.Context, name string, a ...string) ([]byte, error) {
+				joined := strings.Join(a, " ")
+				calls = append(calls, joined)
+				if strings.Contains(joined, timestampFormat) {
+					return []byte(tt.output), nil
+				}
+				return []byte("ActiveState=failed\nSubState=failed\nLoadState=loaded\n"), nil
+			})
+
+			info, err := p.getRemoteUnitInfo(context.Background(), ServiceEntry{Name: "nginx.service"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(calls) != 2 || strings.Contains(calls[0], timestampFormat) || !strings.Contains(calls[1], timestampProperties) {
+				t.Errorf("systemctl show calls = %q, want the state, then the timestamp properties as unix times", calls)
+			}
+			if info.State != services.StateStopped {
+				t.Errorf("State = %q, want stopped", info.State)
+			}
+			if (info.StartedAt != nil) != tt.wantStarted || (info.FinishedAt != nil) != tt.wantFinished || (info.StateSince != nil) != tt.wantStarted {
+				t.Errorf("StartedAt, FinishedAt, StateSince = %v, %v, %v", info.StartedAt, info.FinishedAt, info.StateSince)
+			}
+			if tt.wantStarted && info.StartedAt.UTC().Format(time.RFC3339) != "2025-03-01T12:00:00Z" {
+				t.Errorf("StartedAt = %v, want 2025-03-01T12:00:00Z", info.StartedAt.UTC())
+			}
+		})
+	}
+}
+
+// TestGetRemoteUnitInfo_OldSystemd tests that the state of units on a host
+// whose systemctl rejects --timestamp=unix (before systemd 248) is still
+// read, without timestamps.
+func TestGetRemoteUnitInfo_OldSystemd(t *testing.T) {
+	p := &Provider{hostName: "pi", address: "192.168.1.20", entries: []ServiceEntry{
+		{Name: "nginx.service"},
+		{Name: "zunesync.service", User: "xero"},
+	}}
+	p.SetRunner(func(ctx context.Context, name string, a ...string) ([]byte, error) {
+		if strings.Contains(strings.Join(a, " "), "--timestamp") {
+			return nil, errors.New("systemctl: unrecognized option '--timestamp=unix'")
+		}
+		return []byte("ActiveState=active\nSubState=running\nLoadState=loaded\n"), nil
+	})
+
+	svcs, err := p.getRemoteServices(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(svcs) != 2 {
+		t.Fatalf("services = %+v, want both units", svcs)
+	}
+	for _, svc := range svcs {
+		if svc.State != services.StateRunning {
+			t.Errorf("%s: State = %q (%s), want running", svc.Name, svc.State, svc.Status)
+		}
+		if svc.StartedAt != nil || svc.StateSince != nil {
+			t.Errorf("%s: StartedAt, StateSince = %v, %v, want nil", svc.Name, svc.StartedAt, svc.StateSince)
+		}
+	}
+}
+
+// TestGetRemoteServices_EntrySettings tests that reachable remote units
+// carry the display name, log settings, ports and read-only flag of their
+// entry, like unreachable ones do.
+func TestGetRemoteServices_EntrySettings(t *testing.T) {
+	logs := &services.LogSettings{Tail: 500}
+	p := &Provider{hostName: "pi", address: "192.168.1.20", entries: []ServiceEntry{
+		{Name: "nginx.service", DisplayName: "Web Server", LogSettings: logs, Ports: []uint16{80}, ReadOnly: true},
+		{Name: "zunesync.service", User: "xero", DisplayName: "Zune Sync", LogSettings: logs},
+	}}
+	p.SetRunner(func(ctx context.Context, name string, a ...string) ([]byte, error) {
+		return []byte("ActiveState=active\nSubState=running\nLoadState=loaded\n"), nil
+	})
+
+	svcs, err := p.getRemoteServices(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(svcs) != 2 {
+		t.Fatalf("services = %+v, want both units", svcs)
+	}
+	if svc := svcs[0]; svc.DisplayName != "Web Server" || svc.LogSettings != logs || len(svc.Ports) != 1 || !svc.ReadOnly {
+		t.Errorf("system unit = %+v, want the settings of its entry", svc)
+	}
+	if svc := svcs[1]; svc.DisplayName != "Zune Sync" || svc.LogSettings != logs {
+		t.Errorf("user unit = %+v, want the settings of its entry", svc)
+	}
+}
+
+// fakeUnitProperties serves fixed string properties of a unit.
+type fakeUnitProperties map[string]string
+
+func (f fakeUnitProperties) GetUnitPropertyContext(ctx context.Context, unit string, propertyName string) (*dbus.Property, error) {
+	value, ok := f[propertyName]
+	if !ok {
+		return nil, errors.New("no such property")
+	}
+	prop := dbus.PropDescription(value)
+	prop.Name = propertyName
+	return &prop, nil
+}
+
+// TestGetLocalUnitInfo_EntrySettings tests that an inactive local unit,
+// which isn't in the list of loaded units, keeps the settings of its entry.
+func TestGetLocalUnitInfo_EntrySettings(t *testing.T) {
+	logs := &services.LogSettings{Tail: 500}
+	p := &Provider{hostName: "nas", isLocal: true}
+	conn := fakeUnitProperties{"ActiveState": "inactive", "SubState": "dead", "Description": "Backup job"}
+
+	info, err := p.getLocalUnitInfo(context.Background(), conn, ServiceEntry{
+		Name: "backup.service", DisplayName: "Backups", LogSettings: logs, Ports: []uint16{8080}, ReadOnly: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State != services.StateStopped || info.Description != "Backup job" {
+		t.Errorf("info = %+v, want a stopped unit with its description", info)
+	}
+	if info.DisplayName != "Backups" || info.LogSettings != logs || len(info.Ports) != 1 || !info.ReadOnly {
+		t.Errorf("info = %+v, want the settings of its entry", info)
+	}
+}
+
+func intPtr(i int) *int { return &i }
+
+// TestJournalctlArgs tests the journalctl arguments for combinations of log options.
+func TestJournalctlArgs(t *testing.T) {
+	base := "-u nginx.service -n 100 --no-pager -o short-iso"
+
+	tests := []struct {
+		name string
+		user string
+		opts LogOptions
+		want string
+	}{
+		{"tail only", "", LogOptions{Tail: 100}, base},
+		{"follow", "", LogOptions{Tail: 100, Follow: true}, base + " -f"},
+		{"current boot keeps following", "", LogOptions{Tail: 100, Follow: true, Boot: intPtr(0)}, base + " -b 0 -f"},
+		{"previous boot drops follow", "", LogOptions{Tail: 100, Follow: true, Boot: intPtr(-1)}, base + " -b -1"},
+		{"priority", "", LogOptions{Tail: 100, Follow: true, Priority: "err"}, base + " -p err -f"},
+		{"boot and priority", "", LogOptions{Tail: 100, Boot: intPtr(-2), Priority: "warning"}, base + " -b -2 -p warning"},
+		{"user unit", "alice", LogOptions{Tail: 100, Priority: "err"}, "--user " + base + " -p err"},
+		{"without timestamps", "", LogOptions{Tail: 500, NoTimestamps: true}, "-u nginx.service -n 500 --no-pager -o cat"},
+		{"structured", "", LogOptions{Tail: 100, Structured: true, NoTimestamps: true}, "-u nginx.service -n 100 --no-pager -o json"},
+		{"after cursor", "", LogOptions{Tail: 100, Follow: true, Structured: true, AfterCursor: "s=ab;i=1c4"}, "-u nginx.service -n all --no-pager -o json --after-cursor=s=ab;i=1c4 -f"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(journalctlArgs("nginx.service", tt.user, tt.opts), " ")
+			if got != tt.want {
+				t.Errorf("journalctlArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestContainerJournalctlArgs tests the journalctl arguments for a container using the journald log driver.
+func TestContainerJournalctlArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		opts LogOptions
+		want string
+	}{
+		{"tail only", LogOptions{Tail: 100}, "-n 100 --no-pager -o short-iso CONTAINER_NAME=immich-server"},
+		{"follow", LogOptions{Tail: 100, Follow: true}, "-n 100 --no-pager -o short-iso -f CONTAINER_NAME=immich-server"},
+		{"priority", LogOptions{Tail: 20, Priority: "err"}, "-n 20 --no-pager -o short-iso -p err CONTAINER_NAME=immich-server"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(containerJournalctlArgs("immich-server", tt.opts), " ")
+			if got != tt.want {
+				t.Errorf("containerJournalctlArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLogsCommand tests the full argv for local and remote log commands.
+func TestLogsCommand(t *testing.T) {
+	opts := LogOptions{Tail: 50, Boot: intPtr(-1), Priority: "err"}
+
+	tests := []struct {
+		name string
+		svc  *SystemdService
+		want string
+	}{
+		{
+			name: "local",
+			svc:  &SystemdService{unitName: "nginx.service", isLocal: true},
+			want: "journalctl -u nginx.service -n 50 --no-pager -o short-iso -b -1 -p err",
+		},
+		{
+			name: "remote",
+			svc:  &SystemdService{unitName: "nginx.service", address: "nas", sshConfig: &SSHConfig{Username: "admin", Port: 2222}},
+			want: "ssh -o ConnectTimeout=5 -o StrictHostKeyChecking=accept-new -p 2222 admin@nas journalctl -u nginx.service -n 50 --no-pager -o short-iso -b -1 -p err",
+		},
+		{
+			name: "remote user unit",
+			svc:  &SystemdService{unitName: "app.service", address: "nas", user: "alice"},
+			want: "ssh -o ConnectTimeout=5 -o StrictHostKeyChecking=accept-new nas bash -c sudo -u alice XDG_RUNTIME_DIR=/run/user/$(id -u alice) journalctl --user -u app.service -n 50 --no-pager -o short-iso -b -1 -p err",
+		},
+		{
+			name: "local with sudo",
+			svc:  &SystemdService{unitName: "nginx.service", isLocal: true, journalAccess: "sudo"},
+			want: "sudo -n journalctl -u nginx.service -n 50 --no-pager -o short-iso -b -1 -p err",
+		},
+		{
+			name: "remote with sudo",
+			svc:  &SystemdService{unitName: "nginx.service", address: "nas", journalAccess: "sudo"},
+			want: "ssh -o ConnectTimeout=5 -o StrictHostKeyChecking=accept-new nas sudo -n journalctl -u nginx.service -n 50 --no-pager -o short-iso -b -1 -p err",
+		},
+		{
+			name: "local user unit ignores sudo",
+			svc:  &SystemdService{unitName: "app.service", isLocal: true, user: "alice", journalAccess: "sudo"},
+			want: "journalctl --user -u app.service -n 50 --no-pager -o short-iso -b -1 -p err",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := tt.svc.logsCommand(context.Background(), opts)
+			if got := strings.Join(cmd.Args, " "); got != tt.want {
+				t.Errorf("logsCommand() args = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLogsCommand_AfterCursor tests that the cursor's semicolons reach
+// journalctl on a remote host instead of splitting the command.
+func TestLogsCommand_AfterCursor(t *testing.T) {
+	opts := LogOptions{Tail: 50, Structured: true, AfterCursor: "s=ab;i=1c4"}
+
+	local := &SystemdService{unitName: "nginx.service", isLocal: true}
+	if got := strings.Join(local.logsCommand(context.Background(), opts).Args, " "); got != "journalctl -u nginx.service -n all --no-pager -o json --after-cursor=s=ab;i=1c4" {
+		t.Errorf("local logsCommand() args = %q", got)
+	}
+
+	remote := &SystemdService{unitName: "nginx.service", address: "nas"}
+	want := "ssh -o ConnectTimeout=5 -o StrictHostKeyChecking=accept-new nas journalctl -u nginx.service -n all --no-pager -o json '--after-cursor=s=ab;i=1c4'"
+	if got := strings.Join(remote.logsCommand(context.Background(), opts).Args, " "); got != want {
+		t.Errorf("remote logsCommand() args = %q, want %q", got, want)
+	}
+}
+
+// TestValidCursor tests which cursors are passed on to journalctl.
+func TestValidCursor(t *testing.T) {
+	tests := []struct {
+		cursor string
+		want   bool
+	}{
+		{"s=6f2a0c;i=1c4;b=0e9b;m=9a1f;t=60f1;x=3b2c", true},
+		{"s=6f2a0c", true},
+		{"", false},
+		{"s=6f2a0c;i=1c4; rm -rf /", false},
+		{"s=6f2a0c;$(reboot)", false},
+		{"1705314600123456789", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidCursor(tt.cursor); got != tt.want {
+			t.Errorf("ValidCursor(%q) = %v, want %v", tt.cursor, got, tt.want)
+		}
+	}
+}
+
+// TestParseJournalEntry tests formatting JSON journal entries as journalctl's
+// text output formats them.
+func TestParseJournalEntry(t *testing.T) {
+	line := []byte(`{"__CURSOR":"s=ab;i=1c4","__REALTIME_TIMESTAMP":"1705314600000000","_HOSTNAME":"nas","SYSLOG_IDENTIFIER":"nginx","_PID":"812","MESSAGE":"started"}`)
+	cursor, text, err := ParseJournalEntry(line, false)
+	if err != nil {
+		t.Fatalf("ParseJournalEntry() = %v", err)
+	}
+	wantText := time.UnixMicro(1705314600000000).Format(shortISOLayout) + " nas nginx[812]: started"
+	if cursor != "s=ab;i=1c4" || text != wantText {
+		t.Errorf("ParseJournalEntry() = %q, %q, want %q, %q", cursor, text, "s=ab;i=1c4", wantText)
+	}
+
+	// Without timestamps only the message remains, and messages that
+	// aren't UTF-8 come as an array of bytes
+	_, text, err = ParseJournalEntry([]byte(`{"__CURSOR":"s=ab;i=1c5","MESSAGE":[104,105,255]}`), true)
+	if err != nil || text != "hi\ufffd" {
+		t.Errorf("ParseJournalEntry() = %q, %v, want %q", text, err, "hi\ufffd")
+	}
+
+	if _, _, err := ParseJournalEntry([]byte("-- No entries --"), false); err == nil {
+		t.Error("ParseJournalEntry() of text output = nil error, want an error")
+	}
+}
+
+// TestStartJournal_CannotResume tests that a stream whose cursor journalctl
+// can't seek to ends with ErrCannotResume.
+func TestStartJournal_CannotResume(t *testing.T) {
+	cmd := exec.Command("sh", "-c", `echo "Failed to seek to cursor: Invalid argument" >&2`)
+	logs, err := startJournal(cmd, &JournalAccessError{Host: "nas"})
+	if err != nil {
+		t.Fatalf("startJournal() = %v", err)
+	}
+	defer logs.Close()
+	if _, err := io.ReadAll(logs); !errors.Is(err, ErrCannotResume) {
+		t.Errorf("ReadAll() = %v, want ErrCannotResume", err)
+	}
+}
+
+// TestParseJournalAccess tests recognizing journalctl and sudo access problems.
+func TestParseJournalAccess(t *testing.T) {
+	tests := []struct {
+		line       string
+		wantDenied bool
+		wantGroups []string
+	}{
+		{"No journal files were opened due to insufficient permissions.", true, nil},
+		{"Hint: You are currently not seeing messages from other users and the system.", true, nil},
+		{"      Users in groups 'adm', 'systemd-journal', 'wheel' can see all messages.", false, []string{"adm", "systemd-journal", "wheel"}},
+		{"Users in group 'systemd-journal' can see all messages.", false, []string{"systemd-journal"}},
+		{"sudo: a password is required", true, nil},
+		{"admin is not in the sudoers file.  This incident will be reported.", true, nil},
+		{"-- No entries --", false, nil},
+		{"admin@nas: Permission denied (publickey).", false, nil},
+	}
+
+	for _, tt := range tests {
+		groups, denied := parseJournalAccess(tt.line)
+		if denied != tt.wantDenied || !reflect.DeepEqual(groups, tt.wantGroups) {
+			t.Errorf("parseJournalAccess(%q) = %v, %v, want %v, %v", tt.line, groups, denied, tt.wantGroups, tt.wantDenied)
+		}
+	}
+}
+
+// TestJournalAccessError tests that the error names the missing membership or sudo rule.
+func TestJournalAccessError(t *testing.T) {
+	err := &JournalAccessError{Host: "nas", User: "dash", Remote: true, Access: "group", Detail: "No journal files were opened due to insufficient permissions."}
+	if msg := err.Error(); !strings.Contains(msg, `user "dash" is not in the systemd-journal group`) || !strings.Contains(msg, `journal_access to "sudo"`) {
+		t.Errorf("Error() = %q", msg)
+	}
+
+	err.Groups = []string{"adm", "systemd-journal"}
+	if msg := err.Error(); !strings.Contains(msg, "not in any of the adm, systemd-journal groups") {
+		t.Errorf("Error() with groups = %q", msg)
+	}
+
+	err = &JournalAccessError{Host: "pi", Remote: true, Access: "sudo", Detail: "sudo: a password is required"}
+	if msg := err.Error(); !strings.Contains(msg, "the SSH user may not run journalctl through passwordless sudo") {
+		t.Errorf("Error() with sudo = %q", msg)
+	}
+}
+
+// TestStartJournal_AccessError tests that a stream ends with the access error
+// journalctl reports, even when journalctl keeps running.
+func TestStartJournal_AccessError(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     string
+		wantGroups []string
+	}{
+		{
+			name:   "no journal files",
+			script: `echo "No journal files were opened due to insufficient permissions." >&2`,
+		},
+		{
+			name:       "following own journal only",
+			script:     `printf "Hint: You are currently not seeing messages from other users and the system.\n      Users in groups 'adm', 'systemd-journal' can see all messages.\n" >&2; exec sleep 30`,
+			wantGroups: []string{"adm", "systemd-journal"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command("sh", "-c", tt.script)
+			logs, err := startJournal(cmd, &JournalAccessError{Host: "nas", Access: "group"})
+			if err != nil {
+				t.Fatalf("startJournal() = %v", err)
+			}
+			defer logs.Close()
+
+			done := make(chan error, 1)
+			go func() {
+				_, err := io.ReadAll(logs)
+				done <- err
+			}()
+			select {
+			case err = <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("stream did not end")
+			}
+
+			var accessErr *JournalAccessError
+			if !errors.As(err, &accessErr) {
+				t.Fatalf("ReadAll() = %v, want *JournalAccessError", err)
+			}
+			if accessErr.Host != "nas" || accessErr.Detail == "" || !reflect.DeepEqual(accessErr.Groups, tt.wantGroups) {
+				t.Errorf("access error = %+v", accessErr)
+			}
+		})
+	}
+
+	// Output without access problems ends normally
+	logs, err := startJournal(exec.Command("sh", "-c", "echo line"), &JournalAccessError{Host: "nas"})
+	if err != nil {
+		t.Fatalf("startJournal() = %v", err)
+	}
+	defer logs.Close()
+	if data, err := io.ReadAll(logs); err != nil || string(data) != "line\n" {
+		t.Errorf("ReadAll() = %q, %v", data, err)
+	}
+}
+
+// TestParsePriority tests priority validation.
+func TestParsePriority(t *testing.T) {
+	for _, valid := range []string{"", "emerg", "err", "ERR", "debug"} {
+		if _, err := ParsePriority(valid); err != nil {
+			t.Errorf("ParsePriority(%q) = %v, want nil", valid, err)
+		}
+	}
+
+	_, err := ParsePriority("error")
+	if err == nil {
+		t.Fatal("ParsePriority(\"error\") = nil, want error")
+	}
+	if !strings.Contains(err.Error(), "emerg, alert, crit, err, warning, notice, info, debug") {
+		t.Errorf("error %q should list accepted priorities", err)
+	}
+}
+
+// TestParseBoot tests boot offset validation.
+func TestParseBoot(t *testing.T) {
+	if boot, err := ParseBoot(""); err != nil || boot != nil {
+		t.Errorf("ParseBoot(\"\") = %v, %v; want nil, nil", boot, err)
+	}
+	if boot, err := ParseBoot("-1"); err != nil || boot == nil || *boot != -1 {
+		t.Errorf("ParseBoot(\"-1\") = %v, %v; want -1", boot, err)
+	}
+	for _, invalid := range []string{"1", "last", "-1.5"} {
+		if _, err := ParseBoot(invalid); err == nil {
+			t.Errorf("ParseBoot(%q) = nil error, want error", invalid)
+		}
+	}
+}
+
+// TestEntryFromSpec_LogSettings tests that log viewer options in a spec reach the entry.
+func TestEntryFromSpec_LogSettings(t *testing.T) {
+	if entry := EntryFromSpec(config.ParseServiceSpec("nginx.service")); entry.LogSettings != nil {
+		t.Errorf("LogSettings = %+v, want nil without options", entry.LogSettings)
+	}
+
+	entry := EntryFromSpec(config.ParseServiceSpec("nginx.service|tail=500|timestamps=false"))
+	if entry.LogSettings == nil || entry.LogSettings.Tail != 500 ||
+		entry.LogSettings.Timestamps == nil || *entry.LogSettings.Timestamps {
+		t.Errorf("LogSettings = %+v, want tail 500 without timestamps", entry.LogSettings)
+	}
+}
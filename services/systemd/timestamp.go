@@ -0,0 +1,97 @@
+package systemd
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"home_server_dashboard/services"
+)
+
+// parseSystemdTimestamp parses a timestamp property from `systemctl show
+// --timestamp=unix`, such as StateChangeTimestamp, which is the seconds
+// since the epoch after an "@". Returns nil if the value is empty, "n/a", or
+// in another format: the default format is in the time zone of the host
+// systemctl ran on, which may not be the dashboard's.
+func parseSystemdTimestamp(value string) *time.Time {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "@") {
+		return nil
+	}
+	secs, err := strconv.ParseInt(value[1:], 10, 64)
+	if err != nil {
+		return nil
+	}
+	t := time.Unix(secs, 0)
+	return &t
+}
+
+// usecToTime converts a systemd microsecond timestamp to a time.
+// Returns nil for zero, which systemd uses for "never".
+func usecToTime(usec uint64) *time.Time {
+	if usec == 0 {
+		return nil
+	}
+	t := time.UnixMicro(int64(usec))
+	return &t
+}
+
+// timestampProperties are the unit properties read to timestamp its state,
+// as passed to systemctl show --property.
+const timestampProperties = "StateChangeTimestamp,ActiveEnterTimestamp,InactiveEnterTimestamp"
+
+// timestampFormat makes systemctl show print timestamps as seconds since the
+// epoch, which don't depend on the time zone of the host (systemd 248+).
+const timestampFormat = "--timestamp=unix"
+
+// setTimestamps sets the timestamps of a unit's info from its
+// timestampProperties, as returned by get: StateSince and LastStateChange
+// from StateChangeTimestamp, StartedAt from ActiveEnterTimestamp, and
+// FinishedAt from InactiveEnterTimestamp if the unit is stopped. Properties
+// systemd doesn't know are left nil.
+func setTimestamps(info *services.ServiceInfo, get func(property string) *time.Time) {
+	info.StateSince = get("StateChangeTimestamp")
+	info.LastStateChange = info.StateSince
+	info.StartedAt = get("ActiveEnterTimestamp")
+	if info.State == services.StateStopped {
+		info.FinishedAt = get("InactiveEnterTimestamp")
+	}
+}
+
+// readShowTimestamps sets the timestamps of a unit's info from a systemctl
+// show of its timestampProperties with timestampFormat, run by show with
+// the arguments to add. It is a call of its own, after the unit's state is
+// read, because systemd before 248 rejects timestampFormat: there show
+// fails, and the timestamps are left nil.
+func readShowTimestamps(info *services.ServiceInfo, show func(args ...string) ([]byte, error)) {
+	output, err := show(timestampFormat, "--property="+timestampProperties)
+	if err != nil {
+		return
+	}
+	props := make(map[string]string)
+	for _, line := range strings.Split(string(output), "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			props[key] = strings.TrimSpace(value)
+		}
+	}
+	setTimestamps(info, func(property string) *time.Time {
+		return parseSystemdTimestamp(props[property])
+	})
+}
+
+// setDBusTimestamps sets the timestamps of a unit's info from its D-Bus
+// properties, which are in microseconds since the epoch.
+func setDBusTimestamps(ctx context.Context, conn unitProperties, unitName string, info *services.ServiceInfo) {
+	setTimestamps(info, func(property string) *time.Time {
+		prop, err := conn.GetUnitPropertyContext(ctx, unitName, property)
+		if err != nil || prop == nil {
+			return nil
+		}
+		usec, ok := prop.Value.Value().(uint64)
+		if !ok {
+			return nil
+		}
+		return usecToTime(usec)
+	})
+}
@@ -0,0 +1,13 @@
+package traefik
+
+import "home_server_dashboard/services"
+
+func init() {
+	// Traefik services are collected separately, after the other sources, so
+	// that routers already matched to a service aren't listed twice. They
+	// have no logs and cannot be controlled.
+	services.Register(services.Registration{
+		Source: "traefik",
+		Order:  40,
+	})
+}
@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"home_server_dashboard/services"
+	"home_server_dashboard/version"
 )
 
 // TraefikAPIService represents a service from the Traefik API.
@@ -42,12 +43,13 @@ type Provider struct {
 }
 
 // NewProvider creates a new Traefik service provider.
-// sshConfig is optional and only used for remote hosts.
-func NewProvider(hostName, hostAddress string, apiPort int, sshConfig *SSHConfig) *Provider {
+// sshConfig is optional and only used for remote hosts; httpClient is
+// optional too (see NewClient).
+func NewProvider(hostName, hostAddress string, apiPort int, sshConfig *SSHConfig, httpClient *http.Client) *Provider {
 	return &Provider{
 		hostName:    hostName,
 		hostAddress: hostAddress,
-		client:      NewClient(hostName, hostAddress, apiPort, sshConfig),
+		client:      NewClient(hostName, hostAddress, apiPort, sshConfig, httpClient),
 	}
 }
 
@@ -106,7 +108,7 @@ func (p *Provider) GetServices(ctx context.Context, existingServices map[string]
 		}
 
 		// Determine state based on status and server health
-		state := "stopped"
+		state := services.StateStopped
 		status := "disabled"
 		if svc.Status == "enabled" {
 			// Check if any server is UP
@@ -124,17 +126,17 @@ func (p *Provider) GetServices(ctx context.Context, existingServices map[string]
 			}
 
 			if hasUpServer {
-				state = "running"
+				state = services.StateRunning
 				status = "healthy"
 			} else if allDown && len(svc.ServerStatus) > 0 {
-				state = "stopped"
+				state = services.StateStopped
 				status = "all servers down"
 			} else if len(svc.ServerStatus) == 0 {
 				// No servers configured yet
-				state = "stopped"
+				state = services.StateStopped
 				status = "no servers"
 			} else {
-				state = "running"
+				state = services.StateRunning
 				status = "degraded"
 			}
 		}
@@ -199,7 +201,7 @@ func (s *TraefikService) GetInfo(ctx context.Context) (services.ServiceInfo, err
 		normalizedName := normalizeServiceName(svc.Name)
 		if normalizedName == s.name || svc.Name == s.name {
 			// Determine state based on status and server health
-			state := "stopped"
+			state := services.StateStopped
 			status := "disabled"
 			if svc.Status == "enabled" {
 				hasUpServer := false
@@ -210,13 +212,13 @@ func (s *TraefikService) GetInfo(ctx context.Context) (services.ServiceInfo, err
 					}
 				}
 				if hasUpServer {
-					state = "running"
+					state = services.StateRunning
 					status = "healthy"
 				} else if len(svc.ServerStatus) > 0 {
-					state = "stopped"
+					state = services.StateStopped
 					status = "all servers down"
 				} else {
-					state = "stopped"
+					state = services.StateStopped
 					status = "no servers"
 				}
 			}
@@ -288,6 +290,7 @@ func (c *Client) GetTraefikServices(ctx context.Context) ([]TraefikAPIService, e
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	req.Header.Set("User-Agent", version.UserAgent())
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -12,6 +12,9 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"home_server_dashboard/connlimit"
+	"home_server_dashboard/version"
 )
 
 // Config holds Traefik API connection settings.
@@ -60,20 +63,24 @@ type Client struct {
 
 // NewClient creates a new Traefik API client.
 // For remote hosts, it will create an SSH tunnel when needed.
-// sshConfig is optional and only used for remote hosts.
-func NewClient(hostName, hostAddress string, apiPort int, sshConfig *SSHConfig) *Client {
+// sshConfig is optional and only used for remote hosts. httpClient is the
+// client API requests are made with, or nil for one with a 10 second timeout.
+func NewClient(hostName, hostAddress string, apiPort int, sshConfig *SSHConfig, httpClient *http.Client) *Client {
 	if apiPort == 0 {
 		apiPort = 8080 // Default Traefik API port
 	}
+	if httpClient == nil {
+		httpClient = &http.Client{
+			Timeout: 10 * time.Second,
+		}
+	}
 	return &Client{
 		hostName:       hostName,
 		hostAddress:    hostAddress,
 		apiPort:        apiPort,
 		sshConfig:      sshConfig,
 		matcherService: NewMatcherLookupService(hostName),
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		httpClient:     httpClient,
 	}
 }
 
@@ -152,6 +159,13 @@ func (c *Client) ensureTunnel(ctx context.Context) (int, error) {
 	
 	cmd := exec.CommandContext(ctx, "ssh", sshArgs...)
 	
+	// Hold an SSH slot while the tunnel is being established
+	release, err := connlimit.Acquire(ctx, c.hostAddress)
+	if err != nil {
+		return 0, fmt.Errorf("waiting for SSH slot: %w", err)
+	}
+	defer release()
+	
 	if err := cmd.Start(); err != nil {
 		return 0, fmt.Errorf("failed to start SSH tunnel: %w", err)
 	}
@@ -201,6 +215,7 @@ func (c *Client) GetRouters(ctx context.Context) ([]Router, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	req.Header.Set("User-Agent", version.UserAgent())
 	
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -221,6 +236,32 @@ func (c *Client) GetRouters(ctx context.Context) ([]Router, error) {
 	return routers, nil
 }
 
+// CheckAPI checks that the Traefik API is reachable by fetching /api/overview.
+func (c *Client) CheckAPI(ctx context.Context) error {
+	baseURL, err := c.getAPIBaseURL(ctx)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/api/overview", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch overview: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("Traefik API returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
 // ExtractHostnames extracts all hostnames from a Traefik rule string.
 // Handles rules like: Host(`example.com`), Host(`a.com`) || Host(`b.com`)
 // Also handles HostRegexp patterns where possible.
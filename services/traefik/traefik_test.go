@@ -5,7 +5,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"testing"
+
+	"home_server_dashboard/version"
 )
 
 func TestExtractHostnames(t *testing.T) {
@@ -125,7 +129,7 @@ func TestNormalizeServiceName(t *testing.T) {
 }
 
 func TestNewClient(t *testing.T) {
-	client := NewClient("testhost", "192.168.1.100", 8080, nil)
+	client := NewClient("testhost", "192.168.1.100", 8080, nil, nil)
 	if client == nil {
 		t.Fatal("NewClient returned nil")
 	}
@@ -141,7 +145,7 @@ func TestNewClient(t *testing.T) {
 }
 
 func TestNewClientDefaultPort(t *testing.T) {
-	client := NewClient("testhost", "localhost", 0, nil)
+	client := NewClient("testhost", "localhost", 0, nil, nil)
 	if client.apiPort != 8080 {
 		t.Errorf("apiPort = %d, expected default 8080", client.apiPort)
 	}
@@ -160,7 +164,7 @@ func TestClientIsLocal(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.address, func(t *testing.T) {
-			client := NewClient("test", tt.address, 8080, nil)
+			client := NewClient("test", tt.address, 8080, nil, nil)
 			if client.isLocal() != tt.expected {
 				t.Errorf("isLocal() for address %q = %v, expected %v", tt.address, client.isLocal(), tt.expected)
 			}
@@ -385,7 +389,7 @@ func TestGetClaimedBackendServices(t *testing.T) {
 	defer server.Close()
 
 	// Create client (we'll manually override the URL for testing)
-	client := NewClient("test", "localhost", 8080, nil)
+	client := NewClient("test", "localhost", 8080, nil, nil)
 	defer client.Close()
 
 	// Parse server URL to get port
@@ -440,3 +444,29 @@ func TestGetClaimedBackendServices(t *testing.T) {
 
 	_ = port // Avoid unused variable error
 }
+
+func TestGetRouters_SendsUserAgent(t *testing.T) {
+	var gotUA string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUA = r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse server URL: %v", err)
+	}
+	port, _ := strconv.Atoi(u.Port())
+
+	client := NewClient("test", "localhost", port, nil, nil)
+	defer client.Close()
+
+	if _, err := client.GetRouters(context.Background()); err != nil {
+		t.Fatalf("GetRouters failed: %v", err)
+	}
+	if gotUA != version.UserAgent() {
+		t.Errorf("User-Agent = %q, want %q", gotUA, version.UserAgent())
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+)
+
+// HostPortURL builds a URL for a port on a host, e.g. "http://192.168.1.10:8080".
+// IPv6 addresses are bracketed and hostnames are used as-is. The host may
+// already be bracketed. Returns an empty string if host is empty.
+func HostPortURL(scheme, host string, port uint16) string {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "" {
+		return ""
+	}
+	if scheme == "" {
+		scheme = "http"
+	}
+	// Built by hand rather than with fmt and net.JoinHostPort since this runs
+	// for every port on every services request
+	var b strings.Builder
+	b.Grow(len(scheme) + len(host) + 11)
+	b.WriteString(scheme)
+	b.WriteString("://")
+	if strings.Contains(host, ":") {
+		b.WriteByte('[')
+		b.WriteString(host)
+		b.WriteByte(']')
+	} else {
+		b.WriteString(host)
+	}
+	b.WriteByte(':')
+	var num [5]byte
+	b.Write(strconv.AppendUint(num[:0], uint64(port), 10))
+	return b.String()
+}
+
+// SetPortURLs fills in PortInfo.URL for each visible port using the service's HostIP.
+// Ports on services without a HostIP are left for the frontend to resolve.
+func SetPortURLs(svc *ServiceInfo) {
+	if svc.HostIP == "" {
+		return
+	}
+	for i := range svc.Ports {
+		port := &svc.Ports[i]
+		if port.HostPort == 0 || port.TargetService != "" {
+			continue
+		}
+		port.URL = HostPortURL(port.URLProtocol, svc.HostIP, port.HostPort)
+	}
+}
+
+// PrimaryURL returns the URL that best represents a service, for linking to
+// it from elsewhere. URLs from the url label win, then Traefik URLs (https
+// first), then the service's own ports: labelled ports before unlabelled ones
+// and TCP before UDP. Hidden ports and ports remapped to another service are
+// skipped. Returns an empty string if the service has no URL.
+func PrimaryURL(svc ServiceInfo) string {
+	if len(svc.TraefikURLs) > 0 {
+		if svc.URLOverride {
+			return svc.TraefikURLs[0]
+		}
+		for _, url := range svc.TraefikURLs {
+			if strings.HasPrefix(url, "https://") {
+				return url
+			}
+		}
+		return svc.TraefikURLs[0]
+	}
+
+	best, bestRank := "", 0
+	for _, port := range svc.Ports {
+		if port.Hidden || port.URL == "" || port.TargetService != "" {
+			continue
+		}
+		rank := 1
+		if port.Label != "" {
+			rank += 2
+		}
+		if port.Protocol != "udp" {
+			rank++
+		}
+		if rank > bestRank {
+			best, bestRank = port.URL, rank
+		}
+	}
+	return best
+}
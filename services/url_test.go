@@ -0,0 +1,91 @@
+package services
+
+import "testing"
+
+func TestHostPortURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		scheme string
+		host   string
+		port   uint16
+		want   string
+	}{
+		{"ipv4", "http", "192.168.1.10", 8080, "http://192.168.1.10:8080"},
+		{"ipv6", "http", "fd00::10", 8080, "http://[fd00::10]:8080"},
+		{"bracketed ipv6", "https", "[fd00::10]", 443, "https://[fd00::10]:443"},
+		{"hostname", "http", "nas.lan", 3000, "http://nas.lan:3000"},
+		{"default scheme", "", "10.0.0.2", 80, "http://10.0.0.2:80"},
+		{"empty host", "http", "", 80, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HostPortURL(tt.scheme, tt.host, tt.port); got != tt.want {
+				t.Errorf("HostPortURL(%q, %q, %d) = %q, want %q", tt.scheme, tt.host, tt.port, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetPortURLs(t *testing.T) {
+	svc := ServiceInfo{
+		HostIP: "fd00::10",
+		Ports: []PortInfo{
+			{HostPort: 8080, Protocol: "tcp"},
+			{HostPort: 8443, Protocol: "tcp", URLProtocol: "https"},
+			{HostPort: 8193, Protocol: "tcp", TargetService: "qbittorrent"},
+		},
+	}
+
+	SetPortURLs(&svc)
+
+	if got := svc.Ports[0].URL; got != "http://[fd00::10]:8080" {
+		t.Errorf("Ports[0].URL = %q, want http://[fd00::10]:8080", got)
+	}
+	if got := svc.Ports[1].URL; got != "https://[fd00::10]:8443" {
+		t.Errorf("Ports[1].URL = %q, want https://[fd00::10]:8443", got)
+	}
+	if got := svc.Ports[2].URL; got != "" {
+		t.Errorf("Ports[2].URL = %q, want empty for remapped port", got)
+	}
+
+	// Without a HostIP, the frontend falls back to the page hostname
+	noIP := ServiceInfo{Ports: []PortInfo{{HostPort: 80}}}
+	SetPortURLs(&noIP)
+	if noIP.Ports[0].URL != "" {
+		t.Errorf("URL = %q, want empty without HostIP", noIP.Ports[0].URL)
+	}
+}
+
+func TestPrimaryURL(t *testing.T) {
+	web := PortInfo{HostPort: 8080, Protocol: "tcp", URL: "http://10.0.0.2:8080"}
+	admin := PortInfo{HostPort: 9090, Protocol: "tcp", Label: "Admin", URL: "http://10.0.0.2:9090"}
+	dns := PortInfo{HostPort: 53, Protocol: "udp", URL: "http://10.0.0.2:53"}
+	hidden := PortInfo{HostPort: 7000, Protocol: "tcp", Label: "Debug", Hidden: true, URL: "http://10.0.0.2:7000"}
+	remapped := PortInfo{HostPort: 8193, Protocol: "tcp", Label: "qBittorrent", TargetService: "qbittorrent"}
+
+	tests := []struct {
+		name string
+		svc  ServiceInfo
+		want string
+	}{
+		{"url label wins", ServiceInfo{TraefikURLs: []string{"http://plain.example.com", "https://secure.example.com"}, URLOverride: true, Ports: []PortInfo{admin}}, "http://plain.example.com"},
+		{"traefik over ports", ServiceInfo{TraefikURLs: []string{"https://app.example.com"}, Ports: []PortInfo{admin}}, "https://app.example.com"},
+		{"traefik https first", ServiceInfo{TraefikURLs: []string{"http://app.example.com", "https://app.example.com"}}, "https://app.example.com"},
+		{"traefik without https", ServiceInfo{TraefikURLs: []string{"http://b.example.com", "http://a.example.com"}}, "http://b.example.com"},
+		{"labelled port", ServiceInfo{Ports: []PortInfo{web, admin}}, "http://10.0.0.2:9090"},
+		{"tcp before udp", ServiceInfo{Ports: []PortInfo{dns, web}}, "http://10.0.0.2:8080"},
+		{"first of equals", ServiceInfo{Ports: []PortInfo{web, {HostPort: 8081, Protocol: "tcp", URL: "http://10.0.0.2:8081"}}}, "http://10.0.0.2:8080"},
+		{"udp only", ServiceInfo{Ports: []PortInfo{dns}}, "http://10.0.0.2:53"},
+		{"hidden and remapped skipped", ServiceInfo{Ports: []PortInfo{hidden, remapped, web}}, "http://10.0.0.2:8080"},
+		{"port without url", ServiceInfo{Ports: []PortInfo{{HostPort: 80, Protocol: "tcp"}}}, ""},
+		{"nothing", ServiceInfo{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PrimaryURL(tt.svc); got != tt.want {
+				t.Errorf("PrimaryURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
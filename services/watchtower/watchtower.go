@@ -15,6 +15,8 @@ import (
 	"time"
 
 	"home_server_dashboard/config"
+	"home_server_dashboard/httpclient"
+	"home_server_dashboard/version"
 )
 
 // Metrics represents the parsed Watchtower metrics.
@@ -46,7 +48,8 @@ type Client struct {
 	lastUpdateTime time.Time
 }
 
-// NewClient creates a new Watchtower API client from host configuration.
+// NewClient creates a new Watchtower API client from host configuration,
+// connecting with the host's outbound settings.
 // Returns nil if Watchtower is not configured or token is missing.
 func NewClient(hostCfg *config.HostConfig) *Client {
 	if hostCfg == nil || !hostCfg.HasWatchtower() {
@@ -69,9 +72,7 @@ func NewClient(hostCfg *config.HostConfig) *Client {
 	return &Client{
 		baseURL: baseURL,
 		token:   token,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		httpClient: httpclient.New(config.Get().OutboundFor(hostCfg), 10*time.Second),
 	}
 }
 
@@ -82,6 +83,7 @@ func (c *Client) GetMetrics(ctx context.Context) (*Metrics, error) {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("User-Agent", version.UserAgent())
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
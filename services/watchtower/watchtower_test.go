@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"home_server_dashboard/config"
+	"home_server_dashboard/version"
 )
 
 const sampleMetrics = `# HELP watchtower_containers_scanned Number of containers scanned for changes by watchtower during the last scan
@@ -159,6 +160,31 @@ func TestClient_GetMetrics(t *testing.T) {
 	}
 }
 
+func TestClient_GetMetrics_SendsUserAgent(t *testing.T) {
+	var gotUA string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUA = r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(sampleMetrics))
+	}))
+	defer server.Close()
+
+	client := &Client{
+		baseURL: server.URL,
+		token:   "test-token",
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+
+	if _, err := client.GetMetrics(context.Background()); err != nil {
+		t.Fatalf("GetMetrics failed: %v", err)
+	}
+	if gotUA != version.UserAgent() {
+		t.Errorf("expected User-Agent %q, got %q", version.UserAgent(), gotUA)
+	}
+}
+
 func TestClient_GetMetrics_Unauthorized(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusUnauthorized)
@@ -0,0 +1,423 @@
+// Package sshmux shares one SSH connection to each remote host among the
+// dashboard's ssh commands. The first command to a host opens a master
+// connection in the background with OpenSSH connection multiplexing, and
+// later commands run as sessions over it instead of each doing a handshake.
+// The master sends keepalives, exits after sitting idle, and is opened again
+// by the next command. A master that fails to connect is retried with
+// backoff; commands connect on their own in the meantime.
+package sshmux
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultIdle is how long a master connection stays open without sessions.
+const DefaultIdle = 10 * time.Minute
+
+const (
+	// keepaliveInterval and keepaliveCountMax make a master give up on a
+	// host that stopped answering after about 45 seconds.
+	keepaliveInterval = 15 * time.Second
+	keepaliveCountMax = 3
+
+	// checkAfter is how long a master is trusted to be up before it is
+	// checked again; it may have exited idle or lost the host since.
+	checkAfter = 30 * time.Second
+
+	// minBackoff and maxBackoff bound the wait before a master that failed
+	// to connect is tried again, doubled each failure.
+	minBackoff = 2 * time.Second
+	maxBackoff = 2 * time.Minute
+)
+
+// ErrConnectionLost is wrapped by errors of commands whose SSH connection
+// failed or dropped, as opposed to commands that ran and failed. The
+// command may not have run, or run only partly, so callers may retry it
+// once if it is safe to.
+var ErrConnectionLost = errors.New("SSH connection lost")
+
+// ConnectionLost returns err wrapped as ErrConnectionLost.
+func ConnectionLost(err error) error {
+	return fmt.Errorf("%w: %w", ErrConnectionLost, err)
+}
+
+// State is the state of the shared connection to a host.
+type State string
+
+const (
+	StateConnected    State = "connected"    // Commands run over the master
+	StateDisconnected State = "disconnected" // The master failed or was lost; the next command reconnects
+)
+
+// Stats describes the shared connection to a host.
+type Stats struct {
+	State     State      `json:"state,omitempty"`      // Empty until a command was run
+	Connects  uint64     `json:"connects"`             // Master connections opened
+	Sessions  uint64     `json:"sessions"`             // Commands run
+	Reused    uint64     `json:"reused"`               // Commands run over a master that was already open
+	LastError string     `json:"last_error,omitempty"` // Why the last connect failed
+	RetryAt   *time.Time `json:"retry_at,omitempty"`   // When a failed master is tried again
+}
+
+// Runner runs ssh with args, returning its combined output.
+type Runner func(ctx context.Context, args ...string) ([]byte, error)
+
+// Manager keeps the master connections, one per SSH target, port and
+// identity.
+type Manager struct {
+	dir  string
+	idle time.Duration
+	run  Runner
+	now  func() time.Time
+
+	mu    sync.Mutex
+	hosts map[string]*conn // by connKey
+}
+
+// conn is the master connection to one target.
+type conn struct {
+	path       string // control socket
+	stats      Stats
+	checked    time.Time     // when the master was last known to be up
+	failures   int           // consecutive failed connects
+	retryAt    time.Time     // no connect is tried before
+	connecting chan struct{} // closed when the connect in progress ends, nil if none
+}
+
+// New returns a Manager keeping its control sockets in dir, which is created
+// readable only by the dashboard's user. A dir that already exists must be a
+// directory of the dashboard's user, not a link, so another user can't have
+// made it to catch the sockets. Masters exit after idle without sessions.
+func New(dir string, idle time.Duration) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Getuid() {
+		return nil, fmt.Errorf("%s is owned by another user (uid %d)", dir, st.Uid)
+	}
+	if err := os.Chmod(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Manager{dir: dir, idle: idle, run: runSSH, now: time.Now, hosts: make(map[string]*conn)}, nil
+}
+
+// SocketDir returns the directory control sockets are kept in by default:
+// one in $XDG_RUNTIME_DIR, private to the user, or else one named for the
+// user in the temporary directory.
+func SocketDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "home-server-dashboard-ssh")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("home-server-dashboard-ssh-%d", os.Getuid()))
+}
+
+// SetRunner sets the Runner that runs the Manager's ssh commands. Nil runs
+// them for real.
+func (m *Manager) SetRunner(run Runner) {
+	if run == nil {
+		run = runSSH
+	}
+	m.run = run
+}
+
+// runSSH is the Runner of a Manager, unless SetRunner replaced it.
+func runSSH(ctx context.Context, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	// A master forking into the background may keep the output open
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrWaitDelay) {
+		err = nil
+	}
+	return out.Bytes(), err
+}
+
+// connKey identifies the connection baseArgs open to target: the target
+// with the port and identity file among baseArgs, like OpenSSH's %C. Hosts
+// behind one address on different ports get a connection each.
+func connKey(baseArgs []string, target string) string {
+	var port, identity string
+	for i := 0; i < len(baseArgs); i++ {
+		arg := baseArgs[i]
+		next := ""
+		if i+1 < len(baseArgs) {
+			next = baseArgs[i+1]
+		}
+		switch {
+		case arg == "-p":
+			port, i = next, i+1
+		case strings.HasPrefix(arg, "-p"):
+			port = arg[2:]
+		case arg == "-i":
+			identity, i = next, i+1
+		case strings.HasPrefix(arg, "-i"):
+			identity = arg[2:]
+		case arg == "-o":
+			if key, value, ok := strings.Cut(next, "="); ok {
+				switch strings.ToLower(key) {
+				case "port":
+					port = value
+				case "identityfile":
+					identity = value
+				}
+			}
+			i++
+		}
+	}
+	return target + "\x00" + port + "\x00" + identity
+}
+
+// hostConn returns the connection baseArgs open to target, creating it if
+// needed. m.mu must be held.
+func (m *Manager) hostConn(baseArgs []string, target string) *conn {
+	key := connKey(baseArgs, target)
+	c, ok := m.hosts[key]
+	if !ok {
+		// Named by a hash so any target fits the socket path length limit
+		sum := sha256.Sum256([]byte(key))
+		c = &conn{path: filepath.Join(m.dir, hex.EncodeToString(sum[:8]))}
+		m.hosts[key] = c
+	}
+	return c
+}
+
+// Args returns the ssh options that run a command to target over the shared
+// connection, opening it first if needed. baseArgs are the options the
+// command connects with, such as the port. Args returns nil if there is no
+// connection and one can't be opened now, so the command connects on its
+// own, and always on a nil Manager.
+func (m *Manager) Args(ctx context.Context, baseArgs []string, target string) []string {
+	if m == nil {
+		return nil
+	}
+	path, ok := m.ready(ctx, baseArgs, target)
+	if !ok {
+		return nil
+	}
+	return []string{"-o", "ControlMaster=no", "-o", "ControlPath=" + path}
+}
+
+// ready makes sure the master to target is up, returning its control
+// socket. Concurrent callers wait for a single connect.
+func (m *Manager) ready(ctx context.Context, baseArgs []string, target string) (string, bool) {
+	m.mu.Lock()
+	c := m.hostConn(baseArgs, target)
+	c.stats.Sessions++
+	for c.connecting != nil {
+		wait := c.connecting
+		m.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return "", false
+		}
+		m.mu.Lock()
+	}
+
+	now := m.now()
+	if c.stats.State == StateConnected && now.Sub(c.checked) < checkAfter {
+		c.stats.Reused++
+		m.mu.Unlock()
+		return c.path, true
+	}
+	if now.Before(c.retryAt) {
+		m.mu.Unlock()
+		return "", false
+	}
+	done := make(chan struct{})
+	c.connecting = done
+	m.mu.Unlock()
+
+	// A master may be up that this Manager doesn't know of or hasn't checked
+	// lately; only if it isn't is one opened
+	reused := m.check(ctx, c.path, baseArgs, target) == nil
+	var err error
+	if !reused {
+		err = m.connect(ctx, c.path, baseArgs, target)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	close(done)
+	c.connecting = nil
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// Given up on by the caller, not failed
+		return "", false
+	case err != nil:
+		c.failures++
+		c.retryAt = m.now().Add(backoff(c.failures))
+		retryAt := c.retryAt
+		c.stats.State = StateDisconnected
+		c.stats.LastError = err.Error()
+		c.stats.RetryAt = &retryAt
+		return "", false
+	}
+	c.failures = 0
+	c.retryAt = time.Time{}
+	c.checked = m.now()
+	c.stats.State = StateConnected
+	c.stats.LastError = ""
+	c.stats.RetryAt = nil
+	if reused {
+		c.stats.Reused++
+	} else {
+		c.stats.Connects++
+	}
+	return c.path, true
+}
+
+// backoff returns the wait after failures consecutive failed connects.
+func backoff(failures int) time.Duration {
+	d := minBackoff << (failures - 1)
+	if d > maxBackoff || d <= 0 {
+		return maxBackoff
+	}
+	return d
+}
+
+// check asks the master on path whether it is up.
+func (m *Manager) check(ctx context.Context, path string, baseArgs []string, target string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	args := append([]string{"-O", "check", "-o", "ControlPath=" + path}, baseArgs...)
+	_, err := m.run(ctx, append(args, target)...)
+	return err
+}
+
+// connect opens a master to target on path, which goes into the background
+// once it has logged in.
+func (m *Manager) connect(ctx context.Context, path string, baseArgs []string, target string) error {
+	// A master that was killed leaves its socket behind, which would stop
+	// a new one from listening
+	os.Remove(path)
+
+	args := []string{
+		"-M", "-N", "-f",
+		"-o", "ControlMaster=yes",
+		"-o", "ControlPath=" + path,
+		"-o", fmt.Sprintf("ControlPersist=%d", int(m.idle.Seconds())),
+		"-o", fmt.Sprintf("ServerAliveInterval=%d", int(keepaliveInterval.Seconds())),
+		"-o", fmt.Sprintf("ServerAliveCountMax=%d", keepaliveCountMax),
+		"-o", "BatchMode=yes",
+	}
+	args = append(append(args, baseArgs...), target)
+	if output, err := m.run(ctx, args...); err != nil {
+		if output := strings.TrimSpace(string(output)); output != "" {
+			return fmt.Errorf("%w: %s", err, output)
+		}
+		return err
+	}
+	return nil
+}
+
+// Lost records that a command baseArgs ran to target lost its connection,
+// so the next command checks the master before running over it.
+func (m *Manager) Lost(baseArgs []string, target string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c, ok := m.hosts[connKey(baseArgs, target)]; ok && c.stats.State == StateConnected {
+		c.stats.State = StateDisconnected
+		c.checked = time.Time{}
+	}
+}
+
+// Stats returns the state of the shared connection baseArgs open to target.
+func (m *Manager) Stats(baseArgs []string, target string) Stats {
+	if m == nil {
+		return Stats{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.hosts[connKey(baseArgs, target)]
+	if !ok {
+		return Stats{}
+	}
+	return c.stats
+}
+
+// Close asks every master to exit.
+func (m *Manager) Close() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	paths := make(map[string]string, len(m.hosts))
+	for key, c := range m.hosts {
+		if c.stats.State == StateConnected {
+			target, _, _ := strings.Cut(key, "\x00")
+			paths[c.path] = target
+			c.stats.State = StateDisconnected
+		}
+	}
+	m.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for path, target := range paths {
+		m.run(ctx, "-O", "exit", "-o", "ControlPath="+path, target)
+	}
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultManager *Manager
+)
+
+// Configure sets the Manager the SSH-based providers share, or turns
+// sharing off with nil.
+func Configure(m *Manager) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultManager = m
+}
+
+// Default returns the shared Manager, nil if sharing is off.
+func Default() *Manager {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultManager
+}
+
+// Args returns the options that run a command to target over the default
+// Manager's connection. See Manager.Args.
+func Args(ctx context.Context, baseArgs []string, target string) []string {
+	return Default().Args(ctx, baseArgs, target)
+}
+
+// Lost records a lost connection to target on the default Manager.
+func Lost(baseArgs []string, target string) {
+	Default().Lost(baseArgs, target)
+}
+
+// HostStats returns the default Manager's connection baseArgs open to
+// target.
+func HostStats(baseArgs []string, target string) Stats {
+	return Default().Stats(baseArgs, target)
+}
@@ -0,0 +1,334 @@
+//go:build integration
+
+// This file contains integration tests that run the real ssh against a
+// throwaway sshd listening on localhost, started with its own host key and
+// authorized key. They are skipped where sshd can't be started.
+// Run with: go test -tags=integration ./sshmux/...
+package sshmux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSSHD is an sshd the current user can log in to with a key.
+type testSSHD struct {
+	t      *testing.T
+	path   string // sshd binary
+	config string // sshd_config
+	port   string
+	key    string // the user's private key
+	cmd    *exec.Cmd
+	exited chan struct{}
+}
+
+// startSSHD starts an sshd on a free localhost port, skipping the test if
+// there is no sshd or it won't start here.
+func startSSHD(t *testing.T) *testSSHD {
+	t.Helper()
+	if _, err := exec.LookPath("ssh"); err != nil {
+		t.Skip("ssh not installed")
+	}
+	keygen, err := exec.LookPath("ssh-keygen")
+	if err != nil {
+		t.Skip("ssh-keygen not installed")
+	}
+	path, err := exec.LookPath("sshd")
+	if err != nil {
+		// Usually not on the PATH of non-root users
+		path = "/usr/sbin/sshd"
+		if _, err := os.Stat(path); err != nil {
+			t.Skip("sshd not installed")
+		}
+	}
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("needs /proc to restart sshd with its sessions")
+	}
+
+	dir := t.TempDir()
+	s := &testSSHD{t: t, path: path, config: filepath.Join(dir, "sshd_config"), key: filepath.Join(dir, "id_ed25519")}
+	for _, key := range []string{filepath.Join(dir, "host_ed25519"), s.key} {
+		if out, err := exec.Command(keygen, "-q", "-t", "ed25519", "-N", "", "-f", key).CombinedOutput(); err != nil {
+			t.Fatalf("ssh-keygen: %v: %s", err, out)
+		}
+	}
+	pub, err := os.ReadFile(s.key + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "authorized_keys"), pub, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, s.port, _ = net.SplitHostPort(l.Addr().String())
+	l.Close()
+
+	config := fmt.Sprintf(`Port %s
+ListenAddress 127.0.0.1
+HostKey %s
+AuthorizedKeysFile %s
+PidFile %s
+PasswordAuthentication no
+KbdInteractiveAuthentication no
+StrictModes no
+UsePAM no
+`, s.port, filepath.Join(dir, "host_ed25519"), filepath.Join(dir, "authorized_keys"), filepath.Join(dir, "sshd.pid"))
+	if err := os.WriteFile(s.config, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.start(); err != nil {
+		t.Skipf("sshd won't start here: %v", err)
+	}
+	t.Cleanup(s.stop)
+	return s
+}
+
+// start runs sshd in the foreground and waits for it to accept connections.
+func (s *testSSHD) start() error {
+	var out strings.Builder
+	s.cmd = exec.Command(s.path, "-D", "-e", "-f", s.config)
+	s.cmd.Stdout = &out
+	s.cmd.Stderr = &out
+	if err := s.cmd.Start(); err != nil {
+		return err
+	}
+	s.exited = make(chan struct{})
+	go func() {
+		s.cmd.Wait()
+		close(s.exited)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case <-s.exited:
+			return fmt.Errorf("sshd exited: %s", strings.TrimSpace(out.String()))
+		default:
+		}
+		if conn, err := net.DialTimeout("tcp", "127.0.0.1:"+s.port, time.Second); err == nil {
+			conn.Close()
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	s.stop()
+	return fmt.Errorf("sshd not listening after 5s: %s", strings.TrimSpace(out.String()))
+}
+
+// stop kills sshd along with the processes serving its connections, as a
+// restart of the host would, so their clients are disconnected.
+func (s *testSSHD) stop() {
+	if s.cmd == nil || s.cmd.Process == nil {
+		return
+	}
+	pids := append(descendants(s.cmd.Process.Pid), s.cmd.Process.Pid)
+	for _, pid := range pids {
+		if p, err := os.FindProcess(pid); err == nil {
+			p.Kill()
+		}
+	}
+	<-s.exited
+	s.cmd = nil
+}
+
+// restart stops sshd and starts it again on the same port.
+func (s *testSSHD) restart() {
+	s.t.Helper()
+	s.stop()
+	if err := s.start(); err != nil {
+		s.t.Fatalf("restarting sshd: %v", err)
+	}
+}
+
+// descendants returns the processes descended from pid, from /proc.
+func descendants(pid int) []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	children := make(map[int][]int)
+	for _, entry := range entries {
+		child, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// The command name in parentheses may hold spaces; the state and
+		// parent follow it
+		i := strings.LastIndexByte(string(stat), ')')
+		if i < 0 {
+			continue
+		}
+		fields := strings.Fields(string(stat[i+1:]))
+		if len(fields) < 2 {
+			continue
+		}
+		if parent, err := strconv.Atoi(fields[1]); err == nil {
+			children[parent] = append(children[parent], child)
+		}
+	}
+
+	var pids []int
+	queue := children[pid]
+	for len(queue) > 0 {
+		pids = append(pids, queue[0])
+		queue = append(queue[1:], children[queue[0]]...)
+	}
+	return pids
+}
+
+// baseArgs returns the options a command logs in to s with.
+func (s *testSSHD) baseArgs() []string {
+	return []string{
+		"-F", "/dev/null",
+		"-p", s.port,
+		"-i", s.key,
+		"-o", "IdentitiesOnly=yes",
+		"-o", "StrictHostKeyChecking=no",
+		"-o", "UserKnownHostsFile=/dev/null",
+		"-o", "LogLevel=ERROR",
+		"-o", "BatchMode=yes",
+	}
+}
+
+// target returns the current user at s.
+func (s *testSSHD) target(t *testing.T) string {
+	t.Helper()
+	u, err := user.Current()
+	if err != nil {
+		t.Skipf("no current user: %v", err)
+	}
+	return u.Username + "@127.0.0.1"
+}
+
+// runOK runs ssh with args, failing the test if it fails.
+func runOK(t *testing.T, args ...string) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "ssh", args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("ssh %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return string(out)
+}
+
+// session runs echo on target over the shared connection m gives it. The
+// session's own connections are made to fail with ProxyCommand, so it only
+// succeeds over the master.
+func session(t *testing.T, m *Manager, base []string, target string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	mux := m.Args(ctx, base, target)
+	if mux == nil {
+		t.Fatalf("Args() = nil, want the session run over a master; stats %+v", m.Stats(base, target))
+	}
+	args := append(append(mux, base...), "-o", "ProxyCommand=false", target, "echo", "over-master")
+	if out := runOK(t, args...); strings.TrimSpace(out) != "over-master" {
+		t.Fatalf("session output = %q", out)
+	}
+}
+
+// masterUp reports whether the master on path answers -O check.
+func masterUp(base []string, path, target string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	args := append([]string{"-O", "check", "-o", "ControlPath=" + path}, base...)
+	return exec.CommandContext(ctx, "ssh", append(args, target)...).Run() == nil
+}
+
+func TestIntegration_ControlMaster(t *testing.T) {
+	sshd := startSSHD(t)
+	base, target := sshd.baseArgs(), sshd.target(t)
+
+	m, err := New(t.TempDir(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.Close)
+
+	// The first command opens a master, which goes into the background: Args
+	// returns once it has logged in, not when it exits, and the master
+	// outlives the context it was opened with
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	start := time.Now()
+	mux := m.Args(ctx, base, target)
+	elapsed := time.Since(start)
+	cancel()
+	if mux == nil {
+		t.Fatalf("Args() = nil, want a master; stats %+v", m.Stats(base, target))
+	}
+	if elapsed > 10*time.Second {
+		t.Errorf("opening the master took %v, want it to return once backgrounded", elapsed)
+	}
+
+	path := controlPath(mux)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("control socket: %v", err)
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		t.Errorf("%s has mode %v, want a socket", path, info.Mode())
+	}
+	if !masterUp(base, path, target) {
+		t.Fatal("ssh -O check fails after the context the master was opened with ended")
+	}
+
+	// Later commands run over it instead of connecting
+	for range 3 {
+		session(t, m, base, target)
+	}
+	stats := m.Stats(base, target)
+	if stats.State != StateConnected || stats.Connects != 1 || stats.Sessions != 4 || stats.Reused != 3 {
+		t.Errorf("Stats() = %+v, want 1 connect reused by the 3 sessions after it", stats)
+	}
+
+	// Restarting sshd drops the master; once a command reports the lost
+	// connection the next one opens a new master
+	sshd.restart()
+	deadline := time.Now().Add(10 * time.Second)
+	for masterUp(base, path, target) {
+		if time.Now().After(deadline) {
+			t.Fatal("master still up 10s after sshd was restarted")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	m.Lost(base, target)
+
+	session(t, m, base, target)
+	if !masterUp(base, path, target) {
+		t.Error("ssh -O check fails after reconnecting")
+	}
+	stats = m.Stats(base, target)
+	if stats.State != StateConnected || stats.Connects != 2 || stats.Sessions != 5 || stats.Reused != 3 {
+		t.Errorf("Stats() after the restart = %+v, want a second connect", stats)
+	}
+
+	// Close asks the master to exit, which removes its socket
+	m.Close()
+	if masterUp(base, path, target) {
+		t.Error("master still up after Close()")
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("control socket after Close(): %v, want it removed", err)
+	}
+}
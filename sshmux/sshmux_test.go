@@ -0,0 +1,280 @@
+package sshmux
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSSH plays ssh for a Manager: "-M" opens a master by creating its
+// control socket, "-O check" succeeds while the socket is there, and
+// "-O exit" removes it.
+type fakeSSH struct {
+	mu     sync.Mutex
+	calls  []string
+	refuse bool          // masters fail to log in
+	delay  time.Duration // how long a master takes to log in
+}
+
+func (f *fakeSSH) run(ctx context.Context, args ...string) ([]byte, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, strings.Join(args, " "))
+	refuse, delay := f.refuse, f.delay
+	f.mu.Unlock()
+
+	path := controlPath(args)
+	switch {
+	case slices.Contains(args, "-M"):
+		time.Sleep(delay)
+		if refuse {
+			return []byte("ssh: connect to host nas port 22: Connection refused"), errors.New("exit status 255")
+		}
+		return nil, os.WriteFile(path, nil, 0o600)
+	case slices.Contains(args, "check"):
+		_, err := os.Stat(path)
+		return nil, err
+	case slices.Contains(args, "exit"):
+		return nil, os.Remove(path)
+	}
+	return nil, errors.New("unexpected ssh call")
+}
+
+// count returns how many calls had arg.
+func (f *fakeSSH) count(arg string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, call := range f.calls {
+		if slices.Contains(strings.Fields(call), arg) {
+			n++
+		}
+	}
+	return n
+}
+
+func controlPath(args []string) string {
+	for _, arg := range args {
+		if path, ok := strings.CutPrefix(arg, "ControlPath="); ok {
+			return path
+		}
+	}
+	return ""
+}
+
+// testManager returns a Manager whose ssh is fake and whose clock is set
+// by the returned pointer.
+func testManager(t *testing.T) (*Manager, *fakeSSH, *time.Time) {
+	t.Helper()
+	m, err := New(t.TempDir(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeSSH{}
+	m.run = fake.run
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	return m, fake, &now
+}
+
+func TestManager_SharesOneConnection(t *testing.T) {
+	m, fake, _ := testManager(t)
+	fake.delay = 20 * time.Millisecond
+	base := []string{"-p", "2222"}
+
+	var wg sync.WaitGroup
+	args := make([][]string, 5)
+	for i := range args {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			args[i] = m.Args(context.Background(), base, "admin@nas")
+		}()
+	}
+	wg.Wait()
+
+	if n := fake.count("-M"); n != 1 {
+		t.Errorf("%d masters opened for 5 concurrent commands, want 1", n)
+	}
+	for _, a := range args {
+		if !slices.Contains(a, "ControlMaster=no") || controlPath(a) == "" {
+			t.Errorf("Args() = %q, want the command run over the master", a)
+		}
+	}
+	for _, call := range fake.calls {
+		if strings.Contains(call, "-M") && !strings.HasSuffix(call, "-p 2222 admin@nas") {
+			t.Errorf("master opened with %q, want the command's options and target", call)
+		}
+	}
+
+	stats := m.Stats(base, "admin@nas")
+	if stats.State != StateConnected || stats.Connects != 1 || stats.Sessions != 5 || stats.Reused != 4 {
+		t.Errorf("Stats() = %+v, want 1 connect reused by the other 4 sessions", stats)
+	}
+	if other := m.Stats(nil, "pi"); other.Sessions != 0 {
+		t.Errorf("another host's stats = %+v", other)
+	}
+}
+
+func TestManager_ConnectionPerPort(t *testing.T) {
+	m, fake, _ := testManager(t)
+	ctx := context.Background()
+
+	// Two VMs forwarded from one address on different ports
+	vm1 := controlPath(m.Args(ctx, []string{"-p", "2201"}, "admin@gateway"))
+	vm2 := controlPath(m.Args(ctx, []string{"-o", "Port=2202"}, "admin@gateway"))
+	if vm1 == "" || vm1 == vm2 {
+		t.Errorf("control sockets %q and %q, want one per port", vm1, vm2)
+	}
+	if n := fake.count("-M"); n != 2 {
+		t.Errorf("%d masters opened, want one per port", n)
+	}
+	other := controlPath(m.Args(ctx, []string{"-p", "2201", "-i", "/keys/other"}, "admin@gateway"))
+	if other == vm1 {
+		t.Error("another identity shares the master")
+	}
+	if stats := m.Stats([]string{"-p2201"}, "admin@gateway"); stats.Sessions != 1 {
+		t.Errorf("Stats() = %+v, want the session on port 2201", stats)
+	}
+}
+
+func TestNew_RefusesAnotherUsersDir(t *testing.T) {
+	dir := t.TempDir()
+	link := dir + "/link"
+	if err := os.Symlink(t.TempDir(), link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(link, time.Minute); err == nil {
+		t.Error("New() on a symlink succeeded, want an error")
+	}
+	if os.Getuid() != 0 {
+		// / is root's
+		if _, err := New("/", time.Minute); err == nil {
+			t.Error("New() on a directory of another user succeeded, want an error")
+		}
+	}
+}
+
+func TestSocketDir(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	if got := SocketDir(); got != "/run/user/1000/home-server-dashboard-ssh" {
+		t.Errorf("SocketDir() = %s, want it in XDG_RUNTIME_DIR", got)
+	}
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	if got := SocketDir(); !strings.HasPrefix(got, os.TempDir()) {
+		t.Errorf("SocketDir() = %s, want it in the temporary directory", got)
+	}
+}
+
+func TestManager_ReconnectsAfterServerRestart(t *testing.T) {
+	m, fake, now := testManager(t)
+	ctx := context.Background()
+
+	path := controlPath(m.Args(ctx, nil, "nas"))
+	// The server restarted, taking the master down with it
+	os.Remove(path)
+
+	// Until it is checked again, the master is trusted
+	m.Args(ctx, nil, "nas")
+	if n := fake.count("-M"); n != 1 {
+		t.Fatalf("%d masters opened before the check, want 1", n)
+	}
+
+	*now = now.Add(checkAfter)
+	if args := m.Args(ctx, nil, "nas"); args == nil {
+		t.Fatal("no master after the server came back")
+	}
+	if n := fake.count("-M"); n != 2 {
+		t.Errorf("%d masters opened, want a second one after the restart", n)
+	}
+	if stats := m.Stats(nil, "nas"); stats.Connects != 2 || stats.Reused != 1 || stats.Sessions != 3 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	// A command that lost its connection has the master checked at once
+	m.Lost(nil, "nas")
+	if stats := m.Stats(nil, "nas"); stats.State != StateDisconnected {
+		t.Errorf("state after a lost connection = %s", stats.State)
+	}
+	m.Args(ctx, nil, "nas")
+	if n, checks := fake.count("-M"), fake.count("check"); n != 2 || checks != 1 {
+		t.Errorf("masters opened = %d, checks = %d; want the master still up found by a check", n, checks)
+	}
+	if stats := m.Stats(nil, "nas"); stats.State != StateConnected || stats.Reused != 2 {
+		t.Errorf("Stats() = %+v, want the master reused", stats)
+	}
+}
+
+func TestManager_BacksOffFailedConnects(t *testing.T) {
+	m, fake, now := testManager(t)
+	ctx := context.Background()
+	fake.refuse = true
+
+	if args := m.Args(ctx, nil, "nas"); args != nil {
+		t.Errorf("Args() = %q, want the command to connect on its own", args)
+	}
+	stats := m.Stats(nil, "nas")
+	if stats.State != StateDisconnected || !strings.Contains(stats.LastError, "Connection refused") || stats.RetryAt == nil {
+		t.Errorf("Stats() = %+v, want the failure and its retry time", stats)
+	}
+
+	// No connect is tried during the backoff
+	m.Args(ctx, nil, "nas")
+	if n := fake.count("-M"); n != 1 {
+		t.Errorf("%d connects during the backoff, want 1", n)
+	}
+
+	// The backoff doubles with each failure
+	*now = now.Add(minBackoff)
+	m.Args(ctx, nil, "nas")
+	if retry := m.Stats(nil, "nas").RetryAt; retry == nil || retry.Sub(*now) != 2*minBackoff {
+		t.Errorf("second retry at %v, want %v later", retry, 2*minBackoff)
+	}
+
+	fake.refuse = false
+	*now = now.Add(2 * minBackoff)
+	if args := m.Args(ctx, nil, "nas"); args == nil {
+		t.Error("no master once the host answered again")
+	}
+	if stats := m.Stats(nil, "nas"); stats.State != StateConnected || stats.LastError != "" || stats.RetryAt != nil {
+		t.Errorf("Stats() = %+v, want a clean connection", stats)
+	}
+}
+
+func TestManager_Close(t *testing.T) {
+	m, fake, _ := testManager(t)
+	path := controlPath(m.Args(context.Background(), nil, "nas"))
+	m.Close()
+	if fake.count("exit") != 1 {
+		t.Errorf("calls = %q, want the master asked to exit", fake.calls)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("control socket still there: %v", err)
+	}
+	if state := m.Stats(nil, "nas").State; state != StateDisconnected {
+		t.Errorf("state after Close = %s", state)
+	}
+}
+
+func TestNilManager(t *testing.T) {
+	var m *Manager
+	if args := m.Args(context.Background(), nil, "nas"); args != nil {
+		t.Errorf("Args() = %q, want none", args)
+	}
+	m.Lost(nil, "nas")
+	m.Close()
+	if stats := m.Stats(nil, "nas"); stats != (Stats{}) {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestConnectionLost(t *testing.T) {
+	err := ConnectionLost(errors.New("exit status 255"))
+	if !errors.Is(err, ErrConnectionLost) || !strings.Contains(err.Error(), "exit status 255") {
+		t.Errorf("ConnectionLost() = %v", err)
+	}
+}
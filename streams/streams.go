@@ -0,0 +1,165 @@
+// Package streams keeps track of open SSE connections (log streams and action
+// progress) so admins can see who is streaming what, runaway clients can be
+// capped, and a stream can be closed from the server side.
+package streams
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Default limits.
+const (
+	// DefaultPerUser is how many streams one user may have open at once.
+	DefaultPerUser = 10
+	// DefaultGlobal is how many streams may be open at once in total.
+	DefaultGlobal = 100
+)
+
+// Errors returned by Register when a limit is reached.
+var (
+	ErrUserLimit   = errors.New("too many open streams for this user")
+	ErrGlobalLimit = errors.New("too many open streams")
+)
+
+// Info describes an open stream.
+type Info struct {
+	ID       string    `json:"id"`
+	User     string    `json:"user"`             // user the stream is counted against
+	Client   string    `json:"client,omitempty"` // client IP address
+	Endpoint string    `json:"endpoint"`         // request path, e.g. "/api/logs/systemd"
+	Target   string    `json:"target,omitempty"` // service being streamed, e.g. "nas/docker.service"
+	Started  time.Time `json:"started"`
+}
+
+// Observer is told when streams open and close. It is called with the
+// registry locked, so it must be quick and must not call back into it.
+type Observer interface {
+	StreamOpened(info Info)
+	StreamClosed(info Info, open time.Duration)
+}
+
+// stream is a registered connection.
+type stream struct {
+	info   Info
+	cancel context.CancelFunc
+}
+
+// Registry tracks open streams. It is safe for concurrent use.
+type Registry struct {
+	mu       sync.Mutex
+	perUser  int
+	global   int
+	streams  map[string]*stream // key: stream ID
+	nextID   int
+	now      func() time.Time
+	observer Observer
+}
+
+// New creates a registry allowing perUser open streams per user and global
+// open streams in total. Non-positive values use the defaults.
+func New(perUser, global int) *Registry {
+	if perUser <= 0 {
+		perUser = DefaultPerUser
+	}
+	if global <= 0 {
+		global = DefaultGlobal
+	}
+	return &Registry{
+		perUser: perUser,
+		global:  global,
+		streams: make(map[string]*stream),
+		now:     time.Now,
+	}
+}
+
+// SetObserver sets the observer told about streams opening and closing.
+func (reg *Registry) SetObserver(o Observer) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.observer = o
+}
+
+// Register adds a stream described by info (its ID and Started are filled in)
+// and returns a context derived from ctx that is canceled when the stream is
+// closed with Cancel. The returned done function removes the stream and must
+// be called when the handler returns; it is safe to call more than once.
+// ErrUserLimit or ErrGlobalLimit is returned if a limit has been reached.
+func (reg *Registry) Register(ctx context.Context, info Info) (context.Context, func(), error) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if len(reg.streams) >= reg.global {
+		return nil, nil, ErrGlobalLimit
+	}
+	open := 0
+	for _, s := range reg.streams {
+		if s.info.User == info.User {
+			open++
+		}
+	}
+	if open >= reg.perUser {
+		return nil, nil, ErrUserLimit
+	}
+
+	reg.nextID++
+	info.ID = strconv.Itoa(reg.nextID)
+	info.Started = reg.now()
+	ctx, cancel := context.WithCancel(ctx)
+	reg.streams[info.ID] = &stream{info: info, cancel: cancel}
+	if reg.observer != nil {
+		reg.observer.StreamOpened(info)
+	}
+
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			cancel()
+			reg.mu.Lock()
+			delete(reg.streams, info.ID)
+			if reg.observer != nil {
+				reg.observer.StreamClosed(info, reg.now().Sub(info.Started))
+			}
+			reg.mu.Unlock()
+		})
+	}, nil
+}
+
+// List returns the open streams, oldest first.
+func (reg *Registry) List() []Info {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	list := make([]Info, 0, len(reg.streams))
+	for _, s := range reg.streams {
+		list = append(list, s.info)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Started.Equal(list[j].Started) {
+			return list[i].Started.Before(list[j].Started)
+		}
+		a, _ := strconv.Atoi(list[i].ID)
+		b, _ := strconv.Atoi(list[j].ID)
+		return a < b
+	})
+	return list
+}
+
+// Cancel closes the stream with the given ID by canceling its context. The
+// stream stays listed until its handler returns. It reports whether the
+// stream was found.
+func (reg *Registry) Cancel(id string) bool {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	s, ok := reg.streams[id]
+	if !ok {
+		return false
+	}
+	s.cancel()
+	return true
+}
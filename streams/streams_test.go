@@ -0,0 +1,146 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegister_Limits(t *testing.T) {
+	reg := New(2, 3)
+	ctx := context.Background()
+
+	var dones []func()
+	for i := 0; i < 2; i++ {
+		_, done, err := reg.Register(ctx, Info{User: "alice", Endpoint: "/api/logs"})
+		if err != nil {
+			t.Fatalf("Register() #%d = %v", i, err)
+		}
+		dones = append(dones, done)
+	}
+
+	if _, _, err := reg.Register(ctx, Info{User: "alice"}); !errors.Is(err, ErrUserLimit) {
+		t.Errorf("Register() over user limit = %v, want ErrUserLimit", err)
+	}
+
+	_, bobDone, err := reg.Register(ctx, Info{User: "bob"})
+	if err != nil {
+		t.Fatalf("Register() for another user = %v", err)
+	}
+	if _, _, err := reg.Register(ctx, Info{User: "carol"}); !errors.Is(err, ErrGlobalLimit) {
+		t.Errorf("Register() over global limit = %v, want ErrGlobalLimit", err)
+	}
+
+	// Closing a stream frees its slot, and done is idempotent
+	dones[0]()
+	dones[0]()
+	if _, _, err := reg.Register(ctx, Info{User: "alice"}); err != nil {
+		t.Errorf("Register() after done = %v", err)
+	}
+	bobDone()
+	if got := len(reg.List()); got != 2 {
+		t.Errorf("List() has %d streams, want 2", got)
+	}
+}
+
+func TestRegister_DefaultLimits(t *testing.T) {
+	reg := New(0, -1)
+	if reg.perUser != DefaultPerUser || reg.global != DefaultGlobal {
+		t.Errorf("New(0, -1) limits = %d/%d, want %d/%d", reg.perUser, reg.global, DefaultPerUser, DefaultGlobal)
+	}
+}
+
+func TestList(t *testing.T) {
+	reg := New(5, 5)
+	_, done1, _ := reg.Register(context.Background(), Info{User: "alice", Endpoint: "/api/logs/systemd", Target: "nas/docker.service"})
+	_, done2, _ := reg.Register(context.Background(), Info{User: "bob", Endpoint: "/api/logs", Target: "nginx"})
+	defer done2()
+
+	list := reg.List()
+	if len(list) != 2 {
+		t.Fatalf("List() has %d streams, want 2", len(list))
+	}
+	if list[0].ID != "1" || list[0].User != "alice" || list[0].Target != "nas/docker.service" || list[0].Started.IsZero() {
+		t.Errorf("list[0] = %+v", list[0])
+	}
+	if list[1].ID != "2" || list[1].Endpoint != "/api/logs" {
+		t.Errorf("list[1] = %+v", list[1])
+	}
+
+	done1()
+	list = reg.List()
+	if len(list) != 1 || list[0].ID != "2" {
+		t.Errorf("List() after done = %+v, want only stream 2", list)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	reg := New(5, 5)
+	parent, cancelParent := context.WithCancel(context.Background())
+	defer cancelParent()
+
+	ctx, done, err := reg.Register(parent, Info{User: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	if reg.Cancel("99") {
+		t.Error("Cancel() of unknown stream = true, want false")
+	}
+	if !reg.Cancel("1") {
+		t.Fatal("Cancel() = false, want true")
+	}
+	select {
+	case <-ctx.Done():
+	default:
+		t.Fatal("stream context not canceled")
+	}
+	if parent.Err() != nil {
+		t.Error("Cancel() canceled the parent context")
+	}
+	// Still listed until the handler returns
+	if len(reg.List()) != 1 {
+		t.Error("canceled stream removed before done")
+	}
+	done()
+	if len(reg.List()) != 0 {
+		t.Error("stream still listed after done")
+	}
+}
+
+// recordingObserver records the streams it is told about.
+type recordingObserver struct {
+	opened []Info
+	closed []time.Duration
+}
+
+func (o *recordingObserver) StreamOpened(info Info) { o.opened = append(o.opened, info) }
+
+func (o *recordingObserver) StreamClosed(info Info, open time.Duration) {
+	o.closed = append(o.closed, open)
+}
+
+func TestRegister_Observer(t *testing.T) {
+	reg := New(0, 0)
+	start := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	reg.now = func() time.Time { return start }
+	obs := &recordingObserver{}
+	reg.SetObserver(obs)
+
+	_, done, err := reg.Register(context.Background(), Info{User: "alice", Target: "nas/nginx.service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(obs.opened) != 1 || obs.opened[0].Target != "nas/nginx.service" || obs.opened[0].ID == "" {
+		t.Errorf("opened = %+v, want the registered stream", obs.opened)
+	}
+
+	reg.now = func() time.Time { return start.Add(90 * time.Second) }
+	done()
+	done()
+	if len(obs.closed) != 1 || obs.closed[0] != 90*time.Second {
+		t.Errorf("closed = %v, want one stream open 90s", obs.closed)
+	}
+}
@@ -11,6 +11,9 @@ type HostServices struct {
 	Name     string
 	Address  string
 	Services []string
+	// JournalSudo adds rules for reading unit logs with "sudo -n journalctl"
+	// (journal_access "sudo").
+	JournalSudo bool
 }
 
 // IsLocal returns true if the host is localhost.
@@ -83,6 +86,11 @@ func Generate(hosts []HostServices, username string) string {
 			b.WriteString(fmt.Sprintf("%s ALL=(ALL) NOPASSWD: /usr/bin/systemctl stop %s\n", username, svc))
 			b.WriteString(fmt.Sprintf("%s ALL=(ALL) NOPASSWD: /usr/bin/systemctl restart %s\n", username, svc))
 		}
+		if host.JournalSudo {
+			// The dashboard always passes --no-pager, so journalctl can't spawn a pager as root
+			b.WriteString(fmt.Sprintf("%s ALL=(ALL) NOPASSWD: /usr/bin/journalctl --version\n", username))
+			b.WriteString(fmt.Sprintf("%s ALL=(ALL) NOPASSWD: /usr/bin/journalctl -u * -n * --no-pager -o short-iso*\n", username))
+		}
 		b.WriteString("\n")
 	}
 
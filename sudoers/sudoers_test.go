@@ -115,6 +115,25 @@ func TestGenerate_MixedHosts(t *testing.T) {
 	}
 }
 
+func TestGenerate_JournalSudo(t *testing.T) {
+	hosts := []HostServices{
+		{Name: "pi", Address: "192.168.1.20", Services: []string{"pihole-FTL.service"}, JournalSudo: true},
+		{Name: "nas", Address: "192.168.1.10", Services: []string{"nginx.service"}},
+	}
+
+	result := Generate(hosts, "admin")
+
+	if !strings.Contains(result, "admin ALL=(ALL) NOPASSWD: /usr/bin/journalctl --version") {
+		t.Error("Expected journalctl version rule for a host with sudo journal access")
+	}
+	if !strings.Contains(result, "admin ALL=(ALL) NOPASSWD: /usr/bin/journalctl -u * -n * --no-pager") {
+		t.Error("Expected journalctl rule for a host with sudo journal access")
+	}
+	if strings.Count(result, "/usr/bin/journalctl --version") != 1 {
+		t.Error("Expected journalctl rules only for the host with sudo journal access")
+	}
+}
+
 func TestIsLocal(t *testing.T) {
 	tests := []struct {
 		address string
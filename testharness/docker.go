@@ -0,0 +1,161 @@
+package testharness
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/client"
+)
+
+// Docker is a fake Docker daemon holding compose containers. It implements
+// the calls the Docker provider makes to list services; any other call
+// panics on the nil client.APIClient it embeds.
+type Docker struct {
+	client.APIClient
+
+	mu         sync.Mutex
+	containers []container.Summary
+}
+
+// Container is a compose container for Docker.Add.
+type Container struct {
+	Project string
+	Service string
+	Image   string
+	// Ports are the host ports published on all interfaces.
+	Ports []uint16
+	// Labels are added to the compose labels.
+	Labels map[string]string
+}
+
+// Add adds a running container.
+func (d *Docker) Add(c Container) {
+	labels := map[string]string{
+		"com.docker.compose.project": c.Project,
+		"com.docker.compose.service": c.Service,
+	}
+	for key, value := range c.Labels {
+		labels[key] = value
+	}
+	var ports []container.Port
+	for _, port := range c.Ports {
+		ports = append(ports, container.Port{IP: "0.0.0.0", PrivatePort: port, PublicPort: port, Type: "tcp"})
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	id := c.Project + "-" + c.Service
+	d.containers = append(d.containers, container.Summary{
+		ID:      id,
+		Names:   []string{"/" + id + "-1"},
+		Image:   c.Image,
+		ImageID: "sha256:" + c.Image,
+		Labels:  labels,
+		Ports:   ports,
+		State:   "running",
+		Status:  "Up 2 hours",
+	})
+}
+
+// Label sets a label on the container of service.
+func (d *Docker) Label(service, key, value string) {
+	d.update(service, func(ctr *container.Summary) { ctr.Labels[key] = value })
+}
+
+// Exit stops the container of service with exitCode.
+func (d *Docker) Exit(service string, exitCode int) {
+	d.update(service, func(ctr *container.Summary) {
+		ctr.State = "exited"
+		ctr.Status = "Exited (" + strconv.Itoa(exitCode) + ") 5 minutes ago"
+	})
+}
+
+func (d *Docker) update(service string, change func(ctr *container.Summary)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.containers {
+		if d.containers[i].Labels["com.docker.compose.service"] == service {
+			change(&d.containers[i])
+			return
+		}
+	}
+	panic("testharness: no container for service " + service)
+}
+
+func (d *Docker) find(id string) (container.Summary, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, ctr := range d.containers {
+		if ctr.ID == id || ctr.Names[0] == "/"+id {
+			return ctr, true
+		}
+	}
+	return container.Summary{}, false
+}
+
+// Ping implements client.APIClient.
+func (d *Docker) Ping(ctx context.Context) (types.Ping, error) {
+	return types.Ping{APIVersion: "1.47"}, nil
+}
+
+// ContainerList implements client.APIClient. It ignores the options and
+// lists every container.
+func (d *Docker) ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	containers := make([]container.Summary, len(d.containers))
+	for i, ctr := range d.containers {
+		labels := make(map[string]string, len(ctr.Labels))
+		for key, value := range ctr.Labels {
+			labels[key] = value
+		}
+		ctr.Labels = labels
+		containers[i] = ctr
+	}
+	return containers, nil
+}
+
+// ContainerInspect implements client.APIClient.
+func (d *Docker) ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error) {
+	ctr, ok := d.find(containerID)
+	if !ok {
+		return container.InspectResponse{}, fmt.Errorf("no such container: %s", containerID)
+	}
+	state := &container.State{
+		Status:    ctr.State,
+		Running:   ctr.State == "running",
+		StartedAt: "2024-01-15T10:00:00Z",
+	}
+	if !state.Running {
+		state.FinishedAt = "2024-01-15T12:00:00Z"
+	}
+	return container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			ID:         ctr.ID,
+			Name:       ctr.Names[0],
+			State:      state,
+			HostConfig: &container.HostConfig{LogConfig: container.LogConfig{Type: "json-file"}},
+		},
+	}, nil
+}
+
+// ImageInspect implements client.APIClient.
+func (d *Docker) ImageInspect(ctx context.Context, imageID string, opts ...client.ImageInspectOption) (image.InspectResponse, error) {
+	return image.InspectResponse{ID: imageID, Created: "2024-01-01T00:00:00Z", Os: "linux", Architecture: "amd64"}, nil
+}
+
+// Info implements client.APIClient.
+func (d *Docker) Info(ctx context.Context) (system.Info, error) {
+	return system.Info{OSType: "linux", Architecture: "x86_64"}, nil
+}
+
+// Close implements client.APIClient.
+func (d *Docker) Close() error {
+	return nil
+}
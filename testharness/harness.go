@@ -0,0 +1,189 @@
+// Package testharness runs the service providers against fake backends, so
+// tests can collect services end to end without Docker, SSH, Traefik or Home
+// Assistant. A Harness holds a fake Docker daemon, systemctl over SSH, a
+// Traefik API and a Home Assistant Supervisor, a configuration of three hosts
+// using them, and a provider registry whose factories create the real
+// providers wired to the fakes. Scenario methods break the backends in the
+// ways production hosts do.
+package testharness
+
+import (
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+
+	"home_server_dashboard/config"
+	"home_server_dashboard/resilience"
+	"home_server_dashboard/services"
+	"home_server_dashboard/services/docker"
+	"home_server_dashboard/services/homeassistant"
+	"home_server_dashboard/services/homeassistant/hatest"
+	"home_server_dashboard/services/systemd"
+)
+
+// Hosts of the harness configuration.
+const (
+	// ServerHost runs Docker and Traefik, and the dashboard itself.
+	ServerHost = "server"
+	// RemoteHost runs systemd units reached over SSH.
+	RemoteHost = "pi"
+	// HomeAssistantHost runs Home Assistant OS with the Supervisor API.
+	HomeAssistantHost = "hass"
+)
+
+// supervisorToken is the Supervisor token of the fake Home Assistant.
+const supervisorToken = "harness-supervisor-token"
+
+// Harness is a home lab of fake backends and the configuration to reach it.
+type Harness struct {
+	Config        *config.Config
+	Docker        *Docker
+	Systemd       *Systemd
+	Traefik       *Traefik
+	HomeAssistant *httptest.Server
+}
+
+// New starts the fake backends of a healthy home lab:
+//   - on ServerHost, the jellyfin container behind Traefik, and gluetun with
+//     qbittorrent in its network namespace, gluetun's port 8080 remapped to
+//     qbittorrent; Traefik also knows the file-provided nas-admin service
+//   - on RemoteHost, the nginx and pihole-FTL units
+//   - on HomeAssistantHost, Home Assistant with the add-ons of hatest.
+//
+// The backends are stopped when t ends. Each harness starts with the circuit
+// breakers closed and the image and inspect caches empty.
+func New(t testing.TB) *Harness {
+	t.Helper()
+
+	h := &Harness{
+		Docker:        &Docker{},
+		Systemd:       &Systemd{},
+		Traefik:       newTraefik(),
+		HomeAssistant: hatest.NewServer(),
+	}
+	t.Cleanup(h.Traefik.Server.Close)
+	t.Cleanup(h.HomeAssistant.Close)
+
+	// Breakers opened and images and containers cached by an earlier
+	// harness would leak into this one
+	resilience.Configure(resilience.DefaultThreshold, resilience.DefaultCooldown)
+	docker.InvalidateImageCache(ServerHost, "")
+	docker.InvalidateInspectCache(ServerHost)
+	t.Cleanup(func() {
+		resilience.Configure(resilience.DefaultThreshold, resilience.DefaultCooldown)
+	})
+
+	h.Docker.Add(Container{Project: "media", Service: "jellyfin", Image: "jellyfin/jellyfin:10.9", Ports: []uint16{8096}})
+	h.Docker.Add(Container{Project: "vpn", Service: "gluetun", Image: "qmcgaw/gluetun:v3", Ports: []uint16{8080},
+		Labels: map[string]string{docker.LabelRemapPortPrefix + ".8080": "qbittorrent"}})
+	h.Docker.Add(Container{Project: "vpn", Service: "qbittorrent", Image: "linuxserver/qbittorrent:4.6"})
+	h.Traefik.Route("jellyfin", "jellyfin.home.lan")
+	h.Traefik.AddService("nas-admin", "http://192.168.1.2:5000", "UP")
+
+	h.Systemd.Set(RemoteHost, "nginx.service", Unit{ActiveState: "active", SubState: "running", Description: "A high performance web server"})
+	h.Systemd.Set(RemoteHost, "pihole-FTL.service", Unit{ActiveState: "active", SubState: "running", Description: "Pi-hole FTL"})
+
+	h.Config = &config.Config{
+		// Short enough that a hung host doesn't stall the tests
+		ServicesTimeout: 1,
+		Hosts: []config.HostConfig{
+			{
+				Name:    ServerHost,
+				Address: "127.0.0.1",
+				Traefik: config.TraefikConfig{Enabled: true, APIPort: h.Traefik.Port()},
+			},
+			{
+				Name:            RemoteHost,
+				Address:         "192.168.1.20",
+				SystemdServices: []string{"nginx.service", "pihole-FTL.service"},
+			},
+			{
+				Name:    HomeAssistantHost,
+				Address: "192.168.1.30",
+				HomeAssistant: &config.HomeAssistantConfig{
+					Port:              8123,
+					LongLivedToken:    "harness-token",
+					IsHomeAssistantOS: true,
+					SSHAddonPort:      22,
+				},
+			},
+		},
+	}
+	return h
+}
+
+// HostTimesOut makes host stop answering: every command run on it hangs
+// until the services timeout gives up on it.
+func (h *Harness) HostTimesOut(host string) {
+	h.Systemd.Hang(host)
+}
+
+// RemapToMissingTarget remaps port of service's container to target, a
+// service that doesn't exist.
+func (h *Harness) RemapToMissingTarget(service string, port uint16, target string) {
+	h.Docker.Label(service, docker.LabelRemapPortPrefix+"."+strconv.Itoa(int(port)), target)
+}
+
+// TraefikFails makes the Traefik API answer every request with a 500.
+func (h *Harness) TraefikFails() {
+	h.Traefik.Fail(500)
+}
+
+// Registry returns a provider registry with the Docker, systemd and Home
+// Assistant sources, registered as their packages register them, but with
+// factories that create providers using the harness's fakes.
+func (h *Harness) Registry() Registry {
+	factories := map[string]services.Factory{
+		"docker": func(cfg *config.Config, host *config.HostConfig) (services.Provider, error) {
+			provider := docker.NewProviderWithClient(host.Name, h.Docker)
+			provider.SetImageStaleAfter(cfg.GetImageStaleAfter())
+			provider.SetComposeChangeDetection(cfg.GetComposeChangeDetection())
+			return provider, nil
+		},
+		"systemd": func(cfg *config.Config, host *config.HostConfig) (services.Provider, error) {
+			provider := systemd.NewProviderForHost(host)
+			provider.SetRunner(h.Systemd.Runner(host.Name))
+			return provider, nil
+		},
+		"homeassistant": func(cfg *config.Config, host *config.HostConfig) (services.Provider, error) {
+			client := hatest.Client(h.HomeAssistant.URL)
+			provider, err := homeassistant.NewProviderWithClients(host, client, client, supervisorToken)
+			if err != nil || provider == nil {
+				// Avoid returning a typed nil
+				return nil, err
+			}
+			return provider, nil
+		},
+	}
+
+	var reg Registry
+	for source, factory := range factories {
+		registration, ok := services.Lookup(source)
+		if !ok {
+			panic("testharness: source " + source + " is not registered")
+		}
+		registration.Factory = factory
+		reg = append(reg, registration)
+	}
+	slices.SortFunc(reg, func(a, b services.Registration) int { return a.Order - b.Order })
+	return reg
+}
+
+// Registry is a provider registry holding only its own registrations.
+type Registry []services.Registration
+
+// Lookup returns the registration for a source or one of its aliases.
+func (r Registry) Lookup(source string) (services.Registration, bool) {
+	for _, reg := range r {
+		if reg.Source == source || slices.Contains(reg.Aliases, source) {
+			return reg, true
+		}
+	}
+	return services.Registration{}, false
+}
+
+// Registered returns every registration, sorted by Order.
+func (r Registry) Registered() []services.Registration {
+	return r
+}
@@ -0,0 +1,76 @@
+package testharness
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"home_server_dashboard/services/systemd"
+)
+
+// Unit is the state of a fake systemd unit, as `systemctl show` reports it.
+type Unit struct {
+	ActiveState string
+	SubState    string
+	Description string
+}
+
+// Systemd fakes the systemctl commands the systemd provider runs over SSH
+// on remote hosts.
+type Systemd struct {
+	mu    sync.Mutex
+	units map[string]map[string]Unit // host name -> unit name -> unit
+	hung  map[string]bool            // hosts whose commands never return
+}
+
+// Set sets the state of unit on host.
+func (s *Systemd) Set(host, unit string, state Unit) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.units == nil {
+		s.units = make(map[string]map[string]Unit)
+	}
+	if s.units[host] == nil {
+		s.units[host] = make(map[string]Unit)
+	}
+	s.units[host][unit] = state
+}
+
+// Hang makes every command on host block until its context is done, as SSH
+// to a host that stopped answering does.
+func (s *Systemd) Hang(host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hung == nil {
+		s.hung = make(map[string]bool)
+	}
+	s.hung[host] = true
+}
+
+// Runner returns the systemd.Runner for host's provider.
+func (s *Systemd) Runner(host string) systemd.Runner {
+	return func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		s.mu.Lock()
+		hung := s.hung[host]
+		s.mu.Unlock()
+		if hung {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+
+		// ssh [options] <target> systemctl show <unit> --property=...
+		show := slices.Index(args, "show")
+		if name != "ssh" || show < 1 || args[show-1] != "systemctl" || show+1 >= len(args) {
+			return nil, fmt.Errorf("testharness: unexpected command %s %v", name, args)
+		}
+		s.mu.Lock()
+		unit, ok := s.units[host][args[show+1]]
+		s.mu.Unlock()
+		if !ok {
+			return []byte("ActiveState=inactive\nSubState=dead\nLoadState=not-found\n"), nil
+		}
+		return fmt.Appendf(nil, "ActiveState=%s\nSubState=%s\nLoadState=loaded\nDescription=%s\nStateChangeTimestamp=@1705312800\nActiveEnterTimestamp=@1705312800\n",
+			unit.ActiveState, unit.SubState, unit.Description), nil
+	}
+}
@@ -0,0 +1,94 @@
+package testharness
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"home_server_dashboard/services/traefik"
+)
+
+// Traefik is a fake Traefik API serving routers and services.
+type Traefik struct {
+	Server *httptest.Server
+
+	mu       sync.Mutex
+	routers  []traefik.Router
+	services []traefik.TraefikAPIService
+	status   int // status of every response; 0 serves the API
+}
+
+// newTraefik starts a fake Traefik API. The caller closes its Server.
+func newTraefik() *Traefik {
+	t := &Traefik{}
+	t.Server = httptest.NewServer(http.HandlerFunc(t.serve))
+	return t
+}
+
+// Port returns the port the API listens on.
+func (t *Traefik) Port() int {
+	u, _ := url.Parse(t.Server.URL)
+	port, _ := strconv.Atoi(u.Port())
+	return port
+}
+
+// Route adds an enabled router sending requests for host to service.
+func (t *Traefik) Route(service, host string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.routers = append(t.routers, traefik.Router{
+		Name:    service + "@docker",
+		Rule:    "Host(`" + host + "`)",
+		Service: service + "@docker",
+		Status:  "enabled",
+	})
+}
+
+// AddService adds a service Traefik knows of on its own, with one server
+// whose status is "UP" or "DOWN".
+func (t *Traefik) AddService(name, server, status string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.services = append(t.services, traefik.TraefikAPIService{
+		Name:         name + "@file",
+		Type:         "loadbalancer",
+		Status:       "enabled",
+		Provider:     "file",
+		ServerStatus: map[string]string{server: status},
+	})
+}
+
+// Fail makes every request fail with status, e.g. 500.
+func (t *Traefik) Fail(status int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status = status
+}
+
+func (t *Traefik) serve(w http.ResponseWriter, r *http.Request) {
+	t.mu.Lock()
+	status, routers, svcs := t.status, t.routers, t.services
+	t.mu.Unlock()
+
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	var body any
+	switch r.URL.Path {
+	case "/api/http/routers":
+		body = routers
+	case "/api/http/services":
+		body = svcs
+	case "/api/overview":
+		body = map[string]any{}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,428 @@
+// Package usagestats counts how each service is used: how often and for how
+// long its logs are streamed, and which actions are run on it with what
+// outcome, so the services that need attention most stand out. Counting is
+// a couple of atomic increments; the counts are rolled into daily totals
+// periodically, and those are saved to disk so they survive restarts.
+package usagestats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"home_server_dashboard/actionhistory"
+	"home_server_dashboard/services"
+	"home_server_dashboard/streams"
+)
+
+// Defaults.
+const (
+	// DefaultFlushInterval is how often the live counts are rolled into the
+	// daily totals and saved.
+	DefaultFlushInterval = time.Minute
+	// DefaultRetentionDays is how many days of totals are kept.
+	DefaultRetentionDays = 90
+)
+
+// Metrics services are ranked by. Actions of one type are ranked as
+// "actions:<type>", e.g. "actions:restart".
+const (
+	MetricStreamOpens   = "stream_opens"
+	MetricStreamMinutes = "stream_minutes"
+	MetricActions       = "actions"
+	MetricFailedActions = "failed_actions"
+)
+
+// dayFormat is how days are keyed, in local time.
+const dayFormat = "2006-01-02"
+
+// logEndpoint is the path prefix of the log streams; other streams, such as
+// action progress, aren't counted as watching a service.
+const logEndpoint = "/api/logs"
+
+// Usage is how one service was used over some period.
+type Usage struct {
+	Host        string           `json:"host"`
+	Service     string           `json:"service"`
+	StreamOpens int64            `json:"stream_opens,omitempty"`
+	StreamMs    int64            `json:"stream_ms,omitempty"` // total time its logs were streamed
+	Actions     map[string]int64 `json:"actions,omitempty"`   // key: "<action>:<outcome>", e.g. "restart:failed"
+}
+
+// key returns the key u is kept under.
+func (u *Usage) key() services.Key {
+	return services.Key{Host: u.Host, Name: u.Service}
+}
+
+// add adds the counts of other to u.
+func (u *Usage) add(other *Usage) {
+	u.StreamOpens += other.StreamOpens
+	u.StreamMs += other.StreamMs
+	for key, n := range other.Actions {
+		if u.Actions == nil {
+			u.Actions = make(map[string]int64)
+		}
+		u.Actions[key] += n
+	}
+}
+
+// counters are the counts of one service since the last flush.
+type counters struct {
+	streamOpens atomic.Int64
+	streamMs    atomic.Int64
+	actions     sync.Map // "<action>:<outcome>" -> *atomic.Int64
+}
+
+// Ranked is a service and its value of a metric.
+type Ranked struct {
+	Host    string  `json:"host"`
+	Service string  `json:"service"`
+	Value   float64 `json:"value"`
+}
+
+// Report is the top services by each metric over a window of days.
+type Report struct {
+	Days  int                 `json:"days"`
+	Since string              `json:"since"` // first day of the window, e.g. "2026-10-10"
+	Top   map[string][]Ranked `json:"top"`   // key: metric
+}
+
+// Store counts service usage. It implements streams.Observer and is safe for
+// concurrent use.
+type Store struct {
+	// mu guards the live map: it is held for reading while counting and for
+	// writing while the map is swapped out by a flush.
+	mu   sync.RWMutex
+	live map[services.Key]*counters // key: host and service
+
+	daysMu    sync.Mutex
+	days      map[string]map[services.Key]*Usage // key: day, then host and service
+	retention int
+	path      string // file the totals are saved to ("" keeps them in memory only)
+	now       func() time.Time
+
+	runMu   sync.Mutex
+	running bool
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewStore creates an in-memory store keeping retentionDays days of totals.
+func NewStore(retentionDays int) *Store {
+	if retentionDays <= 0 {
+		retentionDays = DefaultRetentionDays
+	}
+	return &Store{
+		live:      make(map[services.Key]*counters),
+		days:      make(map[string]map[services.Key]*Usage),
+		retention: retentionDays,
+		now:       time.Now,
+	}
+}
+
+// Open creates a store whose daily totals are saved to path. Totals already
+// in the file are loaded; a missing file starts with none.
+func Open(path string, retentionDays int) (*Store, error) {
+	s := NewStore(retentionDays)
+	s.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage stats: %w", err)
+	}
+
+	var days map[string][]*Usage
+	if err := json.Unmarshal(data, &days); err != nil {
+		return nil, fmt.Errorf("failed to parse usage stats %s: %w", path, err)
+	}
+	for day, list := range days {
+		bucket := make(map[services.Key]*Usage, len(list))
+		for _, u := range list {
+			bucket[u.key()] = u
+		}
+		s.days[day] = bucket
+	}
+	return s, nil
+}
+
+// count runs fn on the live counters of a service, creating them if needed.
+func (s *Store) count(host, service string, fn func(c *counters)) {
+	key := services.Key{Host: host, Name: service}
+	for {
+		s.mu.RLock()
+		c, ok := s.live[key]
+		if ok {
+			fn(c)
+			s.mu.RUnlock()
+			return
+		}
+		s.mu.RUnlock()
+
+		s.mu.Lock()
+		if _, ok := s.live[key]; !ok {
+			s.live[key] = &counters{}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// logTarget returns the host and service a log stream is on, or false if
+// info isn't a log stream of a service.
+func logTarget(info streams.Info) (host, service string, ok bool) {
+	if !strings.HasPrefix(info.Endpoint, logEndpoint) {
+		return "", "", false
+	}
+	host, service, ok = strings.Cut(info.Target, "/")
+	return host, service, ok && host != "" && service != ""
+}
+
+// StreamOpened implements streams.Observer by counting log streams opened.
+func (s *Store) StreamOpened(info streams.Info) {
+	if host, service, ok := logTarget(info); ok {
+		s.count(host, service, func(c *counters) { c.streamOpens.Add(1) })
+	}
+}
+
+// StreamClosed implements streams.Observer by adding up how long log
+// streams were open.
+func (s *Store) StreamClosed(info streams.Info, open time.Duration) {
+	if host, service, ok := logTarget(info); ok {
+		s.count(host, service, func(c *counters) { c.streamMs.Add(open.Milliseconds()) })
+	}
+}
+
+// ActionFinished counts a finished action by its type and outcome. It is
+// meant to be set as the observer of an actionhistory.Store.
+func (s *Store) ActionFinished(rec actionhistory.Record) {
+	key := rec.Action + ":" + rec.Outcome
+	s.count(rec.Host, rec.Service, func(c *counters) {
+		n, _ := c.actions.LoadOrStore(key, new(atomic.Int64))
+		n.(*atomic.Int64).Add(1)
+	})
+}
+
+// Flush rolls the live counts into today's totals, drops the totals that
+// are older than the retention and saves the rest.
+func (s *Store) Flush() {
+	s.mu.Lock()
+	live := s.live
+	s.live = make(map[services.Key]*counters)
+	s.mu.Unlock()
+
+	s.daysMu.Lock()
+	defer s.daysMu.Unlock()
+
+	now := s.now()
+	today := now.Format(dayFormat)
+	for key, c := range live {
+		u := Usage{Host: key.Host, Service: key.Name, StreamOpens: c.streamOpens.Load(), StreamMs: c.streamMs.Load()}
+		c.actions.Range(func(k, n any) bool {
+			if u.Actions == nil {
+				u.Actions = make(map[string]int64)
+			}
+			u.Actions[k.(string)] = n.(*atomic.Int64).Load()
+			return true
+		})
+
+		bucket := s.days[today]
+		if bucket == nil {
+			bucket = make(map[services.Key]*Usage)
+			s.days[today] = bucket
+		}
+		if total, ok := bucket[key]; ok {
+			total.add(&u)
+		} else {
+			bucket[key] = &u
+		}
+	}
+
+	oldest := now.AddDate(0, 0, -(s.retention - 1)).Format(dayFormat)
+	for day := range s.days {
+		if day < oldest {
+			delete(s.days, day)
+		}
+	}
+	if len(live) > 0 {
+		s.save()
+	}
+}
+
+// save writes the daily totals to the store's file, if it has one. Called
+// with s.daysMu held.
+func (s *Store) save() {
+	if s.path == "" {
+		return
+	}
+
+	days := make(map[string][]*Usage, len(s.days))
+	for day, bucket := range s.days {
+		list := make([]*Usage, 0, len(bucket))
+		for _, u := range bucket {
+			list = append(list, u)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Host != list[j].Host {
+				return list[i].Host < list[j].Host
+			}
+			return list[i].Service < list[j].Service
+		})
+		days[day] = list
+	}
+
+	data, err := json.Marshal(days)
+	if err != nil {
+		log.Printf("Warning: failed to encode usage stats: %v", err)
+		return
+	}
+	// Write to a temporary file first so a crash can't leave truncated totals
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".usage-stats-*")
+	if err != nil {
+		log.Printf("Warning: failed to save usage stats: %v", err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("Warning: failed to save usage stats: %v", err)
+	}
+}
+
+// Start flushes the live counts every interval in the background.
+func (s *Store) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stop = make(chan struct{})
+
+	s.wg.Add(1)
+	go func(stop <-chan struct{}) {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				s.Flush()
+			}
+		}
+	}(s.stop)
+}
+
+// Stop stops the background flushes and flushes what was counted since the
+// last one, so nothing is lost on shutdown.
+func (s *Store) Stop() {
+	s.runMu.Lock()
+	if s.running {
+		s.running = false
+		close(s.stop)
+	}
+	s.runMu.Unlock()
+
+	s.wg.Wait()
+	s.Flush()
+}
+
+// RetentionDays returns how many days of totals are kept.
+func (s *Store) RetentionDays() int {
+	return s.retention
+}
+
+// Top returns the limit most used services by each metric over the last
+// days days, today included. Counts not yet flushed are included.
+func (s *Store) Top(days, limit int) Report {
+	s.Flush()
+
+	s.daysMu.Lock()
+	since := s.now().AddDate(0, 0, -(days - 1)).Format(dayFormat)
+	totals := make(map[services.Key]*Usage)
+	for day, bucket := range s.days {
+		if day < since {
+			continue
+		}
+		for key, u := range bucket {
+			total, ok := totals[key]
+			if !ok {
+				total = &Usage{Host: u.Host, Service: u.Service}
+				totals[key] = total
+			}
+			total.add(u)
+		}
+	}
+	s.daysMu.Unlock()
+
+	return Report{Days: days, Since: since, Top: rank(totals, limit)}
+}
+
+// rank orders the services by each metric, highest first, keeping the first
+// limit with a value above zero. Ties are ordered by host and service.
+func rank(totals map[services.Key]*Usage, limit int) map[string][]Ranked {
+	values := make(map[string][]Ranked)
+	addValue := func(metric string, u *Usage, value float64) {
+		if value > 0 {
+			values[metric] = append(values[metric], Ranked{Host: u.Host, Service: u.Service, Value: value})
+		}
+	}
+	for _, u := range totals {
+		addValue(MetricStreamOpens, u, float64(u.StreamOpens))
+		addValue(MetricStreamMinutes, u, float64(u.StreamMs)/float64(time.Minute/time.Millisecond))
+
+		var all, failed int64
+		byType := make(map[string]int64)
+		for key, n := range u.Actions {
+			action, outcome, _ := strings.Cut(key, ":")
+			all += n
+			byType[action] += n
+			if outcome == actionhistory.OutcomeFailed {
+				failed += n
+			}
+		}
+		addValue(MetricActions, u, float64(all))
+		addValue(MetricFailedActions, u, float64(failed))
+		for action, n := range byType {
+			addValue(MetricActions+":"+action, u, float64(n))
+		}
+	}
+
+	top := make(map[string][]Ranked, len(values))
+	for metric, list := range values {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Value != list[j].Value {
+				return list[i].Value > list[j].Value
+			}
+			if list[i].Host != list[j].Host {
+				return list[i].Host < list[j].Host
+			}
+			return list[i].Service < list[j].Service
+		})
+		if limit > 0 && len(list) > limit {
+			list = list[:limit]
+		}
+		top[metric] = list
+	}
+	return top
+}
@@ -0,0 +1,213 @@
+package usagestats
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"home_server_dashboard/actionhistory"
+	"home_server_dashboard/services"
+	"home_server_dashboard/streams"
+)
+
+// day returns noon of the given day in October 2026, local time.
+func day(d int) time.Time {
+	return time.Date(2026, 10, d, 12, 0, 0, 0, time.Local)
+}
+
+// fakeStream streams target from endpoint for open through reg.
+func fakeStream(t *testing.T, reg *streams.Registry, endpoint, target string) func() {
+	t.Helper()
+	_, done, err := reg.Register(context.Background(), streams.Info{User: "alice", Endpoint: endpoint, Target: target})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return done
+}
+
+// fakeAction runs an action on service through history that completes with
+// outcome ("" leaves it interrupted).
+func fakeAction(history *actionhistory.Store, service, action, outcome string) {
+	rec := history.Begin("nas", service, "docker", action, "alice")
+	if outcome != "" {
+		rec.Wrap(func(string, string) {})("complete", outcome)
+	}
+	rec.Close()
+}
+
+func TestStore_CountsStreamsAndActions(t *testing.T) {
+	s := NewStore(0)
+	s.now = func() time.Time { return day(16) }
+
+	reg := streams.New(0, 0)
+	reg.SetObserver(s)
+	history := actionhistory.NewStore(0, 0)
+	history.OnFinish(s.ActionFinished)
+
+	// Two log streams of jellyfin, one of nginx; action progress isn't watching
+	fakeStream(t, reg, "/api/logs", "nas/jellyfin")()
+	fakeStream(t, reg, "/api/logs", "nas/jellyfin")()
+	fakeStream(t, reg, "/api/logs/systemd", "nas/nginx.service")()
+	fakeStream(t, reg, "/api/services/restart", "nas/jellyfin")()
+	fakeStream(t, reg, "/api/services/cleanup", "orphaned containers")()
+
+	fakeAction(history, "jellyfin", "restart", "success")
+	fakeAction(history, "jellyfin", "restart", "failed")
+	fakeAction(history, "jellyfin", "stop", "")
+
+	s.Flush()
+	jellyfin := s.days["2026-10-16"][services.Key{Host: "nas", Name: "jellyfin"}]
+	if jellyfin == nil {
+		t.Fatalf("days = %+v, want jellyfin counted today", s.days)
+	}
+	want := map[string]int64{"restart:success": 1, "restart:failed": 1, "stop:interrupted": 1}
+	if jellyfin.StreamOpens != 2 || !reflect.DeepEqual(jellyfin.Actions, want) {
+		t.Errorf("jellyfin = %+v, want 2 streams and actions %v", jellyfin, want)
+	}
+	if nginx := s.days["2026-10-16"][services.Key{Host: "nas", Name: "nginx.service"}]; nginx == nil || nginx.StreamOpens != 1 {
+		t.Errorf("nginx = %+v, want 1 stream", nginx)
+	}
+	if len(s.days["2026-10-16"]) != 2 {
+		t.Errorf("counted %d services, want 2", len(s.days["2026-10-16"]))
+	}
+}
+
+func TestStore_StreamDuration(t *testing.T) {
+	s := NewStore(0)
+	s.now = func() time.Time { return day(16) }
+
+	s.StreamOpened(streams.Info{Endpoint: "/api/logs", Target: "nas/jellyfin"})
+	s.Flush()
+	// A stream closing after a flush adds its time to the next one
+	s.StreamClosed(streams.Info{Endpoint: "/api/logs", Target: "nas/jellyfin"}, 3*time.Minute)
+	s.StreamClosed(streams.Info{Endpoint: "/api/logs", Target: "nas/jellyfin"}, 90*time.Second)
+	s.Flush()
+
+	if got := s.days["2026-10-16"][services.Key{Host: "nas", Name: "jellyfin"}]; got.StreamOpens != 1 || got.StreamMs != 270000 {
+		t.Errorf("jellyfin = %+v, want 1 stream of 4.5 minutes", got)
+	}
+}
+
+func TestStore_DailyRollup(t *testing.T) {
+	s := NewStore(3)
+	now := day(14)
+	s.now = func() time.Time { return now }
+	restart := actionhistory.Record{Host: "nas", Service: "jellyfin", Action: "restart", Outcome: actionhistory.OutcomeSuccess}
+
+	s.ActionFinished(restart)
+	s.Flush()
+	s.ActionFinished(restart)
+	s.Flush()
+	now = day(15)
+	s.ActionFinished(restart)
+	s.Flush()
+
+	if got := s.days["2026-10-14"][services.Key{Host: "nas", Name: "jellyfin"}].Actions["restart:success"]; got != 2 {
+		t.Errorf("14th = %d restarts, want 2 from two flushes", got)
+	}
+	if got := s.days["2026-10-15"][services.Key{Host: "nas", Name: "jellyfin"}].Actions["restart:success"]; got != 1 {
+		t.Errorf("15th = %d restarts, want 1", got)
+	}
+
+	// Days that fall out of the retention are dropped
+	now = day(17)
+	s.ActionFinished(restart)
+	s.Flush()
+	if _, ok := s.days["2026-10-14"]; ok {
+		t.Error("14th kept, want it dropped after 3 days")
+	}
+	if len(s.days) != 2 {
+		t.Errorf("kept %d days, want the 15th and 17th", len(s.days))
+	}
+}
+
+func TestTop(t *testing.T) {
+	s := NewStore(0)
+	now := day(9)
+	s.now = func() time.Time { return now }
+	logs := func(service string, n int, each time.Duration) {
+		for i := 0; i < n; i++ {
+			info := streams.Info{Endpoint: "/api/logs", Target: "nas/" + service}
+			s.StreamOpened(info)
+			s.StreamClosed(info, each)
+		}
+	}
+	action := func(service, action, outcome string, n int) {
+		for i := 0; i < n; i++ {
+			s.ActionFinished(actionhistory.Record{Host: "nas", Service: service, Action: action, Outcome: outcome})
+		}
+	}
+
+	// Outside a 7-day window ending on the 16th
+	logs("old", 50, time.Hour)
+	s.Flush()
+
+	now = day(12)
+	logs("jellyfin", 2, time.Minute)
+	action("jellyfin", "restart", "failed", 2)
+	s.Flush()
+
+	now = day(16)
+	logs("jellyfin", 1, 3*time.Minute)
+	logs("sonarr", 4, 30*time.Second)
+	logs("nginx", 4, 30*time.Second)
+	action("sonarr", "restart", "success", 1)
+	action("sonarr", "stop", "success", 3)
+	// Not flushed: Top includes it anyway
+
+	report := s.Top(7, 2)
+	if report.Days != 7 || report.Since != "2026-10-10" {
+		t.Errorf("window = %d days since %s, want 7 since 2026-10-10", report.Days, report.Since)
+	}
+	ranked := func(metric string) []Ranked { return report.Top[metric] }
+
+	// 4 opens each for nginx and sonarr beat jellyfin's 3; ties go by name
+	if got, want := ranked(MetricStreamOpens), []Ranked{{"nas", "nginx", 4}, {"nas", "sonarr", 4}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stream_opens = %v, want %v", got, want)
+	}
+	// jellyfin streamed 2x1 + 3 minutes across two days
+	if got := ranked(MetricStreamMinutes); len(got) != 2 || got[0].Service != "jellyfin" || got[0].Value != 5 || got[1].Value != 2 {
+		t.Errorf("stream_minutes = %v, want jellyfin 5, then 2", got)
+	}
+	if got, want := ranked(MetricActions), []Ranked{{"nas", "sonarr", 4}, {"nas", "jellyfin", 2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("actions = %v, want %v", got, want)
+	}
+	if got, want := ranked(MetricFailedActions), []Ranked{{"nas", "jellyfin", 2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("failed_actions = %v, want %v", got, want)
+	}
+	if got, want := ranked("actions:restart"), []Ranked{{"nas", "jellyfin", 2}, {"nas", "sonarr", 1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("actions:restart = %v, want %v", got, want)
+	}
+	if got, want := ranked("actions:stop"), []Ranked{{"nas", "sonarr", 3}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("actions:stop = %v, want %v", got, want)
+	}
+
+	// A wider window takes in the old service
+	if got := s.Top(30, 1).Top[MetricStreamOpens]; len(got) != 1 || got[0].Service != "old" {
+		t.Errorf("30-day stream_opens = %v, want old first", got)
+	}
+}
+
+func TestOpen_PersistsAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	s, err := Open(path, 0)
+	if err != nil {
+		t.Fatalf("Open() = %v", err)
+	}
+	s.now = func() time.Time { return day(16) }
+	s.StreamOpened(streams.Info{Endpoint: "/api/logs", Target: "nas/jellyfin"})
+	s.Start(time.Hour)
+	s.Stop() // flushes on the way out
+
+	reopened, err := Open(path, 0)
+	if err != nil {
+		t.Fatalf("Open() after restart = %v", err)
+	}
+	reopened.now = func() time.Time { return day(16) }
+	reopened.StreamOpened(streams.Info{Endpoint: "/api/logs", Target: "nas/jellyfin"})
+	if got := reopened.Top(1, 10).Top[MetricStreamOpens]; len(got) != 1 || got[0].Value != 2 {
+		t.Errorf("stream_opens = %v, want both runs counted", got)
+	}
+}
@@ -0,0 +1,80 @@
+// Package version exposes build information stamped into the binary at build time.
+// Values are set with -ldflags, for example:
+//
+//	go build -ldflags "-X home_server_dashboard/version.Version=1.2.0 \
+//	  -X home_server_dashboard/version.Commit=$(git rev-parse --short HEAD) \
+//	  -X home_server_dashboard/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// When the commit is not stamped, the VCS information recorded by the Go
+// toolchain is used instead.
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build information set via -ldflags.
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// Info describes the running binary.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information for the running binary.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+
+	// Fall back to the VCS stamp embedded by the Go toolchain
+	if info.Commit == "unknown" || info.BuildDate == "unknown" {
+		if bi, ok := debug.ReadBuildInfo(); ok {
+			for _, setting := range bi.Settings {
+				switch setting.Key {
+				case "vcs.revision":
+					if info.Commit == "unknown" && setting.Value != "" {
+						info.Commit = shortCommit(setting.Value)
+					}
+				case "vcs.time":
+					if info.BuildDate == "unknown" && setting.Value != "" {
+						info.BuildDate = setting.Value
+					}
+				}
+			}
+		}
+	}
+
+	return info
+}
+
+// String returns a one-line human-readable description of the build.
+func (i Info) String() string {
+	return fmt.Sprintf("%s (commit %s, built %s, %s)", i.Version, i.Commit, i.BuildDate, i.GoVersion)
+}
+
+// UserAgent returns the User-Agent header value used for outbound HTTP requests.
+func UserAgent() string {
+	info := Get()
+	return fmt.Sprintf("home-server-dashboard/%s (+%s)", info.Version, info.Commit)
+}
+
+// shortCommit truncates a full commit hash to 12 characters.
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
@@ -0,0 +1,63 @@
+package version
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestGet_UsesStampedValues(t *testing.T) {
+	origVersion, origCommit, origDate := Version, Commit, BuildDate
+	defer func() { Version, Commit, BuildDate = origVersion, origCommit, origDate }()
+
+	Version = "1.2.3"
+	Commit = "abc1234"
+	BuildDate = "2025-01-02T03:04:05Z"
+
+	info := Get()
+	if info.Version != "1.2.3" {
+		t.Errorf("Version = %q, want 1.2.3", info.Version)
+	}
+	if info.Commit != "abc1234" {
+		t.Errorf("Commit = %q, want abc1234", info.Commit)
+	}
+	if info.BuildDate != "2025-01-02T03:04:05Z" {
+		t.Errorf("BuildDate = %q, want 2025-01-02T03:04:05Z", info.BuildDate)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("GoVersion = %q, want %q", info.GoVersion, runtime.Version())
+	}
+}
+
+func TestInfo_String(t *testing.T) {
+	info := Info{Version: "1.0.0", Commit: "deadbeef", BuildDate: "today", GoVersion: "go1.25"}
+	want := "1.0.0 (commit deadbeef, built today, go1.25)"
+	if got := info.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	origVersion, origCommit := Version, Commit
+	defer func() { Version, Commit = origVersion, origCommit }()
+
+	Version = "2.0.0"
+	Commit = "cafe123"
+
+	ua := UserAgent()
+	if !strings.HasPrefix(ua, "home-server-dashboard/2.0.0") {
+		t.Errorf("UserAgent() = %q, want prefix home-server-dashboard/2.0.0", ua)
+	}
+	if !strings.Contains(ua, "cafe123") {
+		t.Errorf("UserAgent() = %q, want it to contain the commit", ua)
+	}
+}
+
+func TestShortCommit(t *testing.T) {
+	if got := shortCommit("0123456789abcdef0123"); got != "0123456789ab" {
+		t.Errorf("shortCommit() = %q, want 0123456789ab", got)
+	}
+	if got := shortCommit("abc"); got != "abc" {
+		t.Errorf("shortCommit() = %q, want abc", got)
+	}
+}
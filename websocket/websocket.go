@@ -43,6 +43,8 @@ type MessageType string
 const (
 	// MessageTypeServiceUpdate is sent when a service state changes.
 	MessageTypeServiceUpdate MessageType = "service_update"
+	// MessageTypeServiceRemoved is sent when the monitor forgets a service that is no longer reported.
+	MessageTypeServiceRemoved MessageType = "service_removed"
 	// MessageTypeHostUnreachable is sent when a host becomes unreachable.
 	MessageTypeHostUnreachable MessageType = "host_unreachable"
 	// MessageTypeHostRecovered is sent when a host recovers.
@@ -66,25 +68,67 @@ type ServiceUpdatePayload struct {
 	PreviousState string `json:"previous_state"`
 	CurrentState  string `json:"current_state"`
 	Status        string `json:"status"`
+	// Maintenance is set if the host is in a maintenance window, so the
+	// change isn't notified.
+	Maintenance bool `json:"maintenance,omitempty"`
+}
+
+// ServiceRemovedPayload identifies a service that was removed.
+type ServiceRemovedPayload struct {
+	Host        string `json:"host"`
+	ServiceName string `json:"service_name"`
+	Source      string `json:"source"`
 }
 
 // HostEventPayload contains information about a host event.
 type HostEventPayload struct {
-	Host   string `json:"host"`
-	Reason string `json:"reason,omitempty"`
+	Host        string `json:"host"`
+	Reason      string `json:"reason,omitempty"`
+	Maintenance bool   `json:"maintenance,omitempty"`
+}
+
+// Viewer decides which events a client may receive. It is implemented by *auth.User.
+type Viewer interface {
+	CanAccessService(host, serviceName string) bool
+	CanAccessHost(host string) bool
+}
+
+// ViewerFunc returns the viewer for a connection request, or nil if the
+// connection is unrestricted (e.g., authentication is disabled).
+type ViewerFunc func(r *http.Request) Viewer
+
+// outbound is a serialized message along with the service it concerns,
+// so delivery can be filtered per client.
+type outbound struct {
+	data    []byte
+	host    string // empty for messages every client receives
+	service string // empty for host-level events
 }
 
 // Client represents a connected WebSocket client.
 type Client struct {
-	hub  *Hub
-	conn *websocket.Conn
-	send chan []byte
+	hub    *Hub
+	conn   *websocket.Conn
+	send   chan []byte
+	viewer Viewer // nil receives everything
+}
+
+// canReceive reports whether the client may see a message. Host-level events
+// go to clients that can access at least one service on the host.
+func (c *Client) canReceive(msg outbound) bool {
+	if c.viewer == nil || msg.host == "" {
+		return true
+	}
+	if msg.service == "" {
+		return c.viewer.CanAccessHost(msg.host)
+	}
+	return c.viewer.CanAccessService(msg.host, msg.service)
 }
 
 // Hub maintains the set of active clients and broadcasts messages to them.
 type Hub struct {
 	clients    map[*Client]bool
-	broadcast  chan []byte
+	broadcast  chan outbound
 	register   chan *Client
 	unregister chan *Client
 	mu         sync.RWMutex
@@ -95,13 +139,16 @@ type Hub struct {
 	// Event bus subscription
 	eventBus      *events.Bus
 	subscriptions []*events.Subscription
+
+	// viewerFunc identifies the user behind each connection (nil if unrestricted)
+	viewerFunc ViewerFunc
 }
 
 // NewHub creates a new WebSocket hub.
 func NewHub(eventBus *events.Bus) *Hub {
 	return &Hub{
 		clients:    make(map[*Client]bool),
-		broadcast:  make(chan []byte, 256),
+		broadcast:  make(chan outbound, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		stopCh:     make(chan struct{}),
@@ -109,6 +156,13 @@ func NewHub(eventBus *events.Bus) *Hub {
 	}
 }
 
+// SetViewerFunc sets how the hub identifies the user behind a connection.
+// Clients only receive events about services their viewer can access.
+// Must be called before Handler serves connections.
+func (h *Hub) SetViewerFunc(fn ViewerFunc) {
+	h.viewerFunc = fn
+}
+
 // Start begins the hub's main loop.
 func (h *Hub) Start() {
 	h.mu.Lock()
@@ -156,7 +210,7 @@ func (h *Hub) subscribeToEvents() {
 	h.subscriptions = append(h.subscriptions,
 		h.eventBus.Subscribe(events.ServiceStateChanged, func(e events.Event) {
 			evt := e.(*events.ServiceStateChangedEvent)
-			h.broadcastMessage(Message{
+			h.broadcastMessage(evt.Host, evt.ServiceName, Message{
 				Type:      MessageTypeServiceUpdate,
 				Timestamp: evt.Timestamp().UnixMilli(),
 				Payload: ServiceUpdatePayload{
@@ -166,6 +220,60 @@ func (h *Hub) subscribeToEvents() {
 					PreviousState: evt.PreviousState,
 					CurrentState:  evt.CurrentState,
 					Status:        evt.Status,
+					Maintenance:   events.InMaintenance(evt),
+				},
+			})
+		}),
+	)
+
+	// Restarts and health changes refresh the service's status badge without
+	// changing its state
+	h.subscriptions = append(h.subscriptions,
+		h.eventBus.Subscribe(events.ServiceRestarted, func(e events.Event) {
+			evt := e.(*events.ServiceRestartedEvent)
+			h.broadcastMessage(evt.Host, evt.ServiceName, Message{
+				Type:      MessageTypeServiceUpdate,
+				Timestamp: evt.Timestamp().UnixMilli(),
+				Payload: ServiceUpdatePayload{
+					Host:          evt.Host,
+					ServiceName:   evt.ServiceName,
+					Source:        evt.Source,
+					PreviousState: "running",
+					CurrentState:  "running",
+					Status:        "Restarted (" + evt.Reason + ")",
+					Maintenance:   events.InMaintenance(evt),
+				},
+			})
+		}),
+		h.eventBus.Subscribe(events.ServiceHealthChanged, func(e events.Event) {
+			evt := e.(*events.ServiceHealthChangedEvent)
+			h.broadcastMessage(evt.Host, evt.ServiceName, Message{
+				Type:      MessageTypeServiceUpdate,
+				Timestamp: evt.Timestamp().UnixMilli(),
+				Payload: ServiceUpdatePayload{
+					Host:          evt.Host,
+					ServiceName:   evt.ServiceName,
+					Source:        evt.Source,
+					PreviousState: "running",
+					CurrentState:  "running",
+					Status:        "Up (" + evt.CurrentHealth + ")",
+					Maintenance:   events.InMaintenance(evt),
+				},
+			})
+		}),
+	)
+
+	// Subscribe to service removed events
+	h.subscriptions = append(h.subscriptions,
+		h.eventBus.Subscribe(events.ServiceRemoved, func(e events.Event) {
+			evt := e.(*events.ServiceRemovedEvent)
+			h.broadcastMessage(evt.Host, evt.ServiceName, Message{
+				Type:      MessageTypeServiceRemoved,
+				Timestamp: evt.Timestamp().UnixMilli(),
+				Payload: ServiceRemovedPayload{
+					Host:        evt.Host,
+					ServiceName: evt.ServiceName,
+					Source:      evt.Source,
 				},
 			})
 		}),
@@ -175,12 +283,13 @@ func (h *Hub) subscribeToEvents() {
 	h.subscriptions = append(h.subscriptions,
 		h.eventBus.Subscribe(events.HostUnreachable, func(e events.Event) {
 			evt := e.(*events.HostUnreachableEvent)
-			h.broadcastMessage(Message{
+			h.broadcastMessage(evt.Host, "", Message{
 				Type:      MessageTypeHostUnreachable,
 				Timestamp: evt.Timestamp().UnixMilli(),
 				Payload: HostEventPayload{
-					Host:   evt.Host,
-					Reason: evt.Reason,
+					Host:        evt.Host,
+					Reason:      evt.Reason,
+					Maintenance: events.InMaintenance(evt),
 				},
 			})
 		}),
@@ -190,19 +299,21 @@ func (h *Hub) subscribeToEvents() {
 	h.subscriptions = append(h.subscriptions,
 		h.eventBus.Subscribe(events.HostRecovered, func(e events.Event) {
 			evt := e.(*events.HostRecoveredEvent)
-			h.broadcastMessage(Message{
+			h.broadcastMessage(evt.Host, "", Message{
 				Type:      MessageTypeHostRecovered,
 				Timestamp: evt.Timestamp().UnixMilli(),
 				Payload: HostEventPayload{
-					Host: evt.Host,
+					Host:        evt.Host,
+					Maintenance: events.InMaintenance(evt),
 				},
 			})
 		}),
 	)
 }
 
-// broadcastMessage serializes and broadcasts a message to all clients.
-func (h *Hub) broadcastMessage(msg Message) {
+// broadcastMessage serializes and broadcasts a message to the clients allowed
+// to see events about the service on host (or the host itself if service is empty).
+func (h *Hub) broadcastMessage(host, service string, msg Message) {
 	data, err := json.Marshal(msg)
 	if err != nil {
 		log.Printf("WebSocket: failed to marshal message: %v", err)
@@ -210,7 +321,7 @@ func (h *Hub) broadcastMessage(msg Message) {
 	}
 
 	select {
-	case h.broadcast <- data:
+	case h.broadcast <- outbound{data: data, host: host, service: service}:
 	default:
 		log.Printf("WebSocket: broadcast channel full, dropping message")
 	}
@@ -252,8 +363,11 @@ func (h *Hub) run() {
 		case message := <-h.broadcast:
 			h.mu.RLock()
 			for client := range h.clients {
+				if !client.canReceive(message) {
+					continue
+				}
 				select {
-				case client.send <- message:
+				case client.send <- message.data:
 				default:
 					// Client buffer full, close connection
 					go func(c *Client) {
@@ -287,6 +401,9 @@ func (h *Hub) Handler() http.HandlerFunc {
 			conn: conn,
 			send: make(chan []byte, 256),
 		}
+		if h.viewerFunc != nil {
+			client.viewer = h.viewerFunc(r)
+		}
 
 		h.register <- client
 
@@ -217,6 +217,103 @@ func TestHubBroadcast(t *testing.T) {
 	}
 }
 
+// TestHubRestartAndHealthEvents tests that restarts and health changes are sent as service updates.
+func TestHubRestartAndHealthEvents(t *testing.T) {
+	eventBus := events.NewBus(false)
+	hub := NewHub(eventBus)
+	hub.Start()
+	defer hub.Stop()
+
+	server := httptest.NewServer(hub.Handler())
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect to WebSocket: %v", err)
+	}
+	defer conn.Close()
+
+	// Wait for client to register
+	time.Sleep(50 * time.Millisecond)
+
+	tests := []struct {
+		event  events.Event
+		status string
+	}{
+		{events.NewServiceRestartedEvent("nas", "nginx", "docker", "exit code 1"), "Restarted (exit code 1)"},
+		{events.NewServiceHealthChangedEvent("nas", "nginx", "docker", "healthy", "unhealthy"), "Up (unhealthy)"},
+	}
+
+	for _, tt := range tests {
+		eventBus.Publish(tt.event)
+
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read WebSocket message: %v", err)
+		}
+
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("Failed to unmarshal message: %v", err)
+		}
+		if msg.Type != MessageTypeServiceUpdate {
+			t.Errorf("Expected message type %s, got %s", MessageTypeServiceUpdate, msg.Type)
+		}
+
+		payloadJSON, _ := json.Marshal(msg.Payload)
+		var payload ServiceUpdatePayload
+		json.Unmarshal(payloadJSON, &payload)
+		if payload.CurrentState != "running" || payload.Status != tt.status {
+			t.Errorf("payload = %+v, want running with status %q", payload, tt.status)
+		}
+	}
+}
+
+func TestHubServiceRemovedEvent(t *testing.T) {
+	eventBus := events.NewBus(false)
+	hub := NewHub(eventBus)
+	hub.Start()
+	defer hub.Stop()
+
+	server := httptest.NewServer(hub.Handler())
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect to WebSocket: %v", err)
+	}
+	defer conn.Close()
+
+	// Wait for client to register
+	time.Sleep(50 * time.Millisecond)
+
+	eventBus.Publish(events.NewServiceRemovedEvent("nas", "redis", "docker", "no longer reported"))
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read WebSocket message: %v", err)
+	}
+
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("Failed to unmarshal message: %v", err)
+	}
+	if msg.Type != MessageTypeServiceRemoved {
+		t.Errorf("Expected message type %s, got %s", MessageTypeServiceRemoved, msg.Type)
+	}
+
+	payloadJSON, _ := json.Marshal(msg.Payload)
+	var payload ServiceRemovedPayload
+	json.Unmarshal(payloadJSON, &payload)
+	if payload != (ServiceRemovedPayload{Host: "nas", ServiceName: "redis", Source: "docker"}) {
+		t.Errorf("payload = %+v", payload)
+	}
+}
+
 // TestHubHostEvents tests host unreachable and recovered events.
 func TestHubHostEvents(t *testing.T) {
 	eventBus := events.NewBus(false)
@@ -257,8 +354,10 @@ func TestHubHostEvents(t *testing.T) {
 		t.Errorf("Expected message type %s, got %s", MessageTypeHostUnreachable, msg.Type)
 	}
 
-	// Test host recovered
-	eventBus.Publish(events.NewHostRecoveredEvent("server1"))
+	// Test host recovered, during a maintenance window
+	recovered := events.NewHostRecoveredEvent("server1")
+	recovered.MarkMaintenance()
+	eventBus.Publish(recovered)
 
 	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
 	_, data, err = conn.ReadMessage()
@@ -273,6 +372,130 @@ func TestHubHostEvents(t *testing.T) {
 	if msg.Type != MessageTypeHostRecovered {
 		t.Errorf("Expected message type %s, got %s", MessageTypeHostRecovered, msg.Type)
 	}
+	if !strings.Contains(string(data), `"maintenance":true`) {
+		t.Errorf("Expected the maintenance flag in %s", data)
+	}
+}
+
+// fakeViewer grants access to a fixed set of services, or everything if global.
+type fakeViewer struct {
+	global  bool
+	allowed map[string][]string // host -> service names
+}
+
+func (v fakeViewer) CanAccessService(host, serviceName string) bool {
+	if v.global {
+		return true
+	}
+	for _, svc := range v.allowed[host] {
+		if svc == serviceName {
+			return true
+		}
+	}
+	return false
+}
+
+func (v fakeViewer) CanAccessHost(host string) bool {
+	return v.global || len(v.allowed[host]) > 0
+}
+
+// readAll reads messages until none arrive for a short while, returning
+// "type host/service" for service updates and "type host" for host events.
+func readAll(t *testing.T, conn *websocket.Conn) []string {
+	t.Helper()
+	var got []string
+	for {
+		conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return got
+		}
+		// The write pump batches queued messages separated by newlines
+		for _, line := range strings.Split(string(data), "\n") {
+			var msg struct {
+				Type    MessageType `json:"type"`
+				Payload struct {
+					Host        string `json:"host"`
+					ServiceName string `json:"service_name"`
+				} `json:"payload"`
+			}
+			if err := json.Unmarshal([]byte(line), &msg); err != nil {
+				t.Fatalf("Failed to unmarshal message %q: %v", line, err)
+			}
+			entry := string(msg.Type) + " " + msg.Payload.Host
+			if msg.Payload.ServiceName != "" {
+				entry += "/" + msg.Payload.ServiceName
+			}
+			got = append(got, entry)
+		}
+	}
+}
+
+// TestHubFiltersEventsPerViewer tests that clients only receive events for services they can access.
+func TestHubFiltersEventsPerViewer(t *testing.T) {
+	eventBus := events.NewBus(false)
+	hub := NewHub(eventBus)
+	viewers := map[string]Viewer{
+		"alice": fakeViewer{allowed: map[string][]string{"nas": {"nginx"}}},
+		"bob":   fakeViewer{allowed: map[string][]string{"pi": {"grafana"}}},
+		"admin": fakeViewer{global: true},
+	}
+	hub.SetViewerFunc(func(r *http.Request) Viewer {
+		if v, ok := viewers[r.Header.Get("X-Test-User")]; ok {
+			return v
+		}
+		return nil
+	})
+	hub.Start()
+	defer hub.Stop()
+
+	server := httptest.NewServer(hub.Handler())
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/"
+
+	conns := make(map[string]*websocket.Conn)
+	for _, name := range []string{"alice", "bob", "admin", "anonymous"} {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"X-Test-User": {name}})
+		if err != nil {
+			t.Fatalf("Failed to connect %s: %v", name, err)
+		}
+		defer conn.Close()
+		conns[name] = conn
+	}
+
+	// Wait for clients to register
+	time.Sleep(50 * time.Millisecond)
+
+	eventBus.Publish(events.NewServiceStateChangedEvent("nas", "nginx", "docker", "running", "stopped", "Exited (1)"))
+	eventBus.Publish(events.NewServiceStateChangedEvent("nas", "postgres", "docker", "running", "stopped", "Exited (1)"))
+	eventBus.Publish(events.NewServiceStateChangedEvent("pi", "grafana", "systemd", "stopped", "running", "active"))
+	eventBus.Publish(events.NewServiceHealthChangedEvent("nas", "nginx", "docker", "healthy", "unhealthy"))
+	eventBus.Publish(events.NewHostUnreachableEvent("nas", "timeout"))
+	eventBus.Publish(events.NewHostRecoveredEvent("pi"))
+	eventBus.Publish(events.NewHostUnreachableEvent("backup", "timeout"))
+
+	all := []string{
+		"service_update nas/nginx",
+		"service_update nas/postgres",
+		"service_update pi/grafana",
+		"service_update nas/nginx",
+		"host_unreachable nas",
+		"host_recovered pi",
+		"host_unreachable backup",
+	}
+	want := map[string][]string{
+		"alice":     {"service_update nas/nginx", "service_update nas/nginx", "host_unreachable nas"},
+		"bob":       {"service_update pi/grafana", "host_recovered pi"},
+		"admin":     all,
+		"anonymous": all, // no viewer means auth is disabled
+	}
+
+	for name, conn := range conns {
+		got := readAll(t, conn)
+		if strings.Join(got, ", ") != strings.Join(want[name], ", ") {
+			t.Errorf("%s received %v, want %v", name, got, want[name])
+		}
+	}
 }
 
 // TestHubMultipleClients tests broadcast to multiple clients.